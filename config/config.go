@@ -11,6 +11,7 @@ import (
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	gormlogger "gorm.io/gorm/logger"
+	"p9e.in/ugcl/pkg/datascope"
 )
 
 var DB *gorm.DB
@@ -47,6 +48,13 @@ func Connect() {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
+	// Row-level vertical/site scoping for queries run with a request's data scope;
+	// project children (zones, nodes, tasks, ...) are scoped through their project.
+	datascope.ScopeThrough("project_id", "projects")
+	if err := DB.Use(datascope.Plugin{}); err != nil {
+		log.Fatal("Failed to register data scope plugin:", err)
+	}
+
 	// Configure connection pool for optimal performance
 	sqlDB, err := DB.DB()
 	if err != nil {
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/go-gormigrate/gormigrate/v2"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -904,6 +906,1564 @@ func Migrations(db *gorm.DB) error {
 					}
 				}
 
+				return nil
+			},
+		},
+		{
+			ID: "20261016_usage_metering",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(
+					&models.UsageAggregate{},
+					&models.UsageActiveUser{},
+				); err != nil {
+					return err
+				}
+
+				queries := []string{
+					// Upsert targets for the meter flush and the derived-metric aggregator.
+					"CREATE UNIQUE INDEX IF NOT EXISTS idx_usage_aggregates_vertical_period_metric ON usage_aggregates(business_vertical_id, period, metric)",
+					"CREATE UNIQUE INDEX IF NOT EXISTS idx_usage_active_users_vertical_period_user ON usage_active_users(business_vertical_id, period, user_id)",
+					// Derived SMS/WhatsApp counts scan notifications by channel within a month.
+					"CREATE INDEX IF NOT EXISTS idx_notifications_channel_created ON notifications(channel, created_at)",
+					`INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at)
+					 VALUES (gen_random_uuid(), 'view_billing_reports', 'View usage metering and chargeback reports', 'billing', 'read', NOW(), NOW())
+					 ON CONFLICT (name) DO NOTHING`,
+					`INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at)
+					 VALUES (gen_random_uuid(), 'manage_billing', 'Recompute usage aggregates for billing periods', 'billing', 'manage', NOW(), NOW())
+					 ON CONFLICT (name) DO NOTHING`,
+				}
+
+				for _, q := range queries {
+					if err := tx.Exec(q).Error; err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+		},
+		{
+			ID: "20261016_project_blueprints",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(
+					&models.ProjectBlueprint{},
+					&models.Project{},
+				); err != nil {
+					return err
+				}
+
+				queries := []string{
+					"CREATE INDEX IF NOT EXISTS idx_project_blueprints_vertical_active ON project_blueprints(business_vertical_id, is_active) WHERE deleted_at IS NULL",
+					`INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at)
+					 VALUES (gen_random_uuid(), 'project:blueprint_read', 'View project blueprints', 'project', 'blueprint_read', NOW(), NOW())
+					 ON CONFLICT (name) DO NOTHING`,
+					`INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at)
+					 VALUES (gen_random_uuid(), 'project:blueprint_manage', 'Create, update and capture project blueprints', 'project', 'blueprint_manage', NOW(), NOW())
+					 ON CONFLICT (name) DO NOTHING`,
+				}
+
+				for _, q := range queries {
+					if err := tx.Exec(q).Error; err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+		},
+		{
+			ID: "20261016_portfolio_snapshots",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.PortfolioSnapshot{}); err != nil {
+					return err
+				}
+
+				queries := []string{
+					// Critical incident and overdue counts group open tasks by project.
+					"CREATE INDEX IF NOT EXISTS idx_tasks_project_priority_status ON tasks(project_id, priority, status) WHERE deleted_at IS NULL",
+					`INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at)
+					 VALUES (gen_random_uuid(), 'portfolio:read', 'View the cross-project portfolio dashboard and trends', 'portfolio', 'read', NOW(), NOW())
+					 ON CONFLICT (name) DO NOTHING`,
+					`INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at)
+					 VALUES (gen_random_uuid(), 'portfolio:manage', 'Take portfolio risk snapshots on demand', 'portfolio', 'manage', NOW(), NOW())
+					 ON CONFLICT (name) DO NOTHING`,
+				}
+
+				for _, q := range queries {
+					if err := tx.Exec(q).Error; err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+		},
+		{
+			ID: "20261016_policy_evaluation_log",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.PolicyEvaluation{}); err != nil {
+					return err
+				}
+
+				queries := []string{
+					// RBAC decisions are recorded without a deciding policy.
+					"ALTER TABLE policy_evaluations ALTER COLUMN policy_id DROP NOT NULL",
+					"CREATE INDEX IF NOT EXISTS idx_policy_evaluations_time_desc ON policy_evaluations(evaluation_time DESC)",
+					"CREATE INDEX IF NOT EXISTS idx_policy_evaluations_source_effect_time ON policy_evaluations(source, effect, evaluation_time DESC)",
+					`INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at)
+					 VALUES (gen_random_uuid(), 'view_policy_evaluations', 'View policy evaluation audit logs', 'policy_evaluation', 'read', NOW(), NOW())
+					 ON CONFLICT (name) DO NOTHING`,
+				}
+
+				for _, q := range queries {
+					if err := tx.Exec(q).Error; err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+		},
+		{
+			// Read-only auditor role: rejected on every write by ReadOnlyRoleMiddleware,
+			// read access across finance, purchases, workflows and audit logs, plus evidence exports.
+			ID: "20261016_auditor_role",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.Role{}, &models.BusinessRole{}); err != nil {
+					return err
+				}
+
+				queries := []string{
+					`INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at)
+					 VALUES (gen_random_uuid(), 'audit:export', 'Export audit evidence bundles', 'audit', 'export', NOW(), NOW())
+					 ON CONFLICT (name) DO NOTHING`,
+					`INSERT INTO roles (id, name, description, is_active, is_global, level, is_read_only, created_at, updated_at)
+					 VALUES (gen_random_uuid(), 'auditor', 'External auditor with read-only access and evidence export', true, true, 4, true, NOW(), NOW())
+					 ON CONFLICT (name) DO UPDATE SET is_read_only = true`,
+					`INSERT INTO role_permissions (role_id, permission_id, created_at)
+					 SELECT r.id, p.id, NOW() FROM roles r, permissions p
+					 WHERE r.name = 'auditor' AND p.name IN (
+					   'audit:export', 'finance:read', 'purchase:read', 'inventory:read', 'bg:read', 'lc:read',
+					   'insurance:read', 'risk:read', 'read_payments', 'read_materials', 'view_forms',
+					   'document:read', 'project:read', 'task:read', 'report:read', 'report:export',
+					   'dashboard:view', 'portfolio:read', 'view_policy_evaluations'
+					 )
+					 ON CONFLICT DO NOTHING`,
+				}
+
+				for _, q := range queries {
+					if err := tx.Exec(q).Error; err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+		},
+		{
+			ID: "20261016_app_telemetry_events",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.AppTelemetryEvent{}); err != nil {
+					return err
+				}
+
+				queries := []string{
+					`INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at)
+					 VALUES (gen_random_uuid(), 'telemetry:read', 'View mobile app usage telemetry and crash reports', 'telemetry', 'read', NOW(), NOW())
+					 ON CONFLICT (name) DO NOTHING`,
+				}
+
+				for _, q := range queries {
+					if err := tx.Exec(q).Error; err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+		},
+		{
+			ID: "20261016_chat_attention",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(
+					&models.ChatMessage{},
+					&models.ChatParticipant{},
+					&models.ChatMessageMention{},
+					&models.ChatMessageAck{},
+				)
+			},
+		},
+		{
+			ID: "20261016_time_bound_roles",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.User{}, &models.UserBusinessRole{})
+			},
+		},
+		{
+			ID: "20261016_chat_voice_transcripts",
+			Migrate: func(tx *gorm.DB) error {
+				queries := []string{
+					`CREATE INDEX IF NOT EXISTS idx_chat_attachments_transcription_pending
+					 ON chat_attachments (created_at)
+					 WHERE metadata->>'transcription_status' = 'pending'`,
+				}
+
+				for _, q := range queries {
+					if err := tx.Exec(q).Error; err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+		},
+		{
+			ID: "20261016_site_scoped_roles",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.UserBusinessRole{})
+			},
+		},
+		{
+			ID: "20261016_chat_canned_responses",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.ChatCannedResponse{}); err != nil {
+					return err
+				}
+
+				queries := []string{
+					`INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at)
+					 VALUES (gen_random_uuid(), 'chat:canned_response:manage', 'Manage chat canned responses for a business vertical', 'chat_canned_response', 'manage', NOW(), NOW())
+					 ON CONFLICT (name) DO NOTHING`,
+				}
+
+				for _, q := range queries {
+					if err := tx.Exec(q).Error; err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+		},
+		{
+			ID: "20261016_role_assignment_audit",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.RoleAssignmentAuditLog{})
+			},
+		},
+		{
+			ID: "20261016_sensor_devices",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.SensorDevice{}, &models.SensorReading{}); err != nil {
+					return err
+				}
+
+				queries := []string{
+					`INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at)
+					 VALUES (gen_random_uuid(), 'sensor:device:manage', 'Approve, reject and manage IoT sensor devices', 'sensor_device', 'manage', NOW(), NOW())
+					 ON CONFLICT (name) DO NOTHING`,
+					`INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at)
+					 VALUES (gen_random_uuid(), 'sensor:reading:read', 'View readings posted by IoT sensor devices', 'sensor_reading', 'read', NOW(), NOW())
+					 ON CONFLICT (name) DO NOTHING`,
+				}
+
+				for _, q := range queries {
+					if err := tx.Exec(q).Error; err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+		},
+		{
+			ID: "20261016_business_role_versions",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.BusinessRole{}, &models.BusinessRoleVersion{}); err != nil {
+					return err
+				}
+
+				// Existing roles start at version 1; snapshot their current definitions
+				return tx.Exec(`INSERT INTO business_role_versions (id, business_role_id, version, name, display_name, description, level, permissions, created_at)
+					SELECT gen_random_uuid(), br.id, COALESCE(br.version, 1), br.name, br.display_name, br.description, br.level,
+						ARRAY(SELECT p.name FROM business_role_permissions brp JOIN permissions p ON p.id = brp.permission_id
+							WHERE brp.business_role_id = br.id ORDER BY p.name),
+						NOW()
+					FROM business_roles br
+					ON CONFLICT DO NOTHING`).Error
+			},
+		},
+		{
+			ID: "20261016_emergency_broadcasts",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.EmergencyBroadcast{}, &models.EmergencyBroadcastRecipient{}); err != nil {
+					return err
+				}
+
+				queries := []string{
+					`INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at)
+					 VALUES (gen_random_uuid(), 'emergency:broadcast:send', 'Send and close emergency broadcasts to site users', 'emergency_broadcast', 'send', NOW(), NOW())
+					 ON CONFLICT (name) DO NOTHING`,
+					`INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at)
+					 VALUES (gen_random_uuid(), 'emergency:broadcast:view', 'View emergency broadcasts and their acknowledgment dashboard', 'emergency_broadcast', 'view', NOW(), NOW())
+					 ON CONFLICT (name) DO NOTHING`,
+				}
+
+				for _, q := range queries {
+					if err := tx.Exec(q).Error; err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+		},
+		{
+			ID: "20261016_separation_of_duties",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.SoDRule{}, &models.SoDOverride{})
+			},
+		},
+		{
+			ID: "20261016_break_glass",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.BreakGlassGrant{}, &models.BreakGlassEvent{}); err != nil {
+					return err
+				}
+
+				queries := []string{
+					`INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at)
+					 VALUES (gen_random_uuid(), 'break_glass:request', 'Activate time-boxed emergency permissions with a justification', 'break_glass', 'request', NOW(), NOW())
+					 ON CONFLICT (name) DO NOTHING`,
+					`INSERT INTO role_permissions (role_id, permission_id, created_at)
+					 SELECT r.id, p.id, NOW() FROM roles r, permissions p
+					 WHERE r.name IN ('System_Admin', 'Admin') AND p.name = 'break_glass:request'
+					 ON CONFLICT DO NOTHING`,
+				}
+
+				for _, q := range queries {
+					if err := tx.Exec(q).Error; err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+		},
+		{
+			ID: "20261016_permission_denies",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.PermissionDeny{})
+			},
+		},
+		{
+			// Versioned history of roles, business roles and their permission sets
+			ID: "20261016_role_changes",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.RoleChange{})
+			},
+		},
+		{
+			// Companies (tenants) above business verticals; existing verticals and users
+			// move into the DEFAULT company
+			ID: "20261016_companies",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.Company{}, &models.BusinessVertical{}, &models.User{}); err != nil {
+					return err
+				}
+				company := models.Company{Name: "Default", Code: models.DefaultCompanyCode, LegalName: "Default", IsActive: true}
+				if err := tx.Where("code = ?", company.Code).FirstOrCreate(&company).Error; err != nil {
+					return err
+				}
+				if err := tx.Exec("UPDATE business_verticals SET company_id = ? WHERE company_id IS NULL", company.ID).Error; err != nil {
+					return err
+				}
+				return tx.Exec("UPDATE users SET company_id = ? WHERE company_id IS NULL", company.ID).Error
+			},
+		},
+		{
+			// Deferred workflow transition actions (notifications and webhooks)
+			ID: "20261016_workflow_action_outbox",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.WorkflowActionOutbox{})
+			},
+		},
+		{
+			ID: "20261016_approval_delegations",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.ApprovalDelegation{}, &models.WorkflowTransition{})
+			},
+		},
+		{
+			ID: "20261016_workflow_definition_versions",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(
+					&models.WorkflowDefinition{},
+					&models.WorkflowDefinitionVersion{},
+					&models.FormSubmission{},
+				); err != nil {
+					return err
+				}
+
+				// Each existing definition becomes version 1 and its instances are pinned to it
+				if err := tx.Exec(`
+					INSERT INTO workflow_definition_versions (workflow_id, version_number, version, initial_state, states, transitions, created_at)
+					SELECT w.id, w.current_version, w.version, w.initial_state, w.states, w.transitions, NOW()
+					FROM workflow_definitions w
+					WHERE NOT EXISTS (
+						SELECT 1 FROM workflow_definition_versions v
+						WHERE v.workflow_id = w.id AND v.version_number = w.current_version
+					)`).Error; err != nil {
+					return err
+				}
+				if err := tx.Exec("UPDATE form_submissions SET workflow_version = 1 WHERE workflow_id IS NOT NULL AND workflow_version IS NULL").Error; err != nil {
+					return err
+				}
+
+				// Dedicated form tables live in their module's schema when it has one
+				type formTable struct {
+					DBTableName string
+					SchemaName  string
+				}
+				var formTables []formTable
+				if err := tx.Raw(`
+					SELECT f.db_table_name, COALESCE(m.schema_name, '') AS schema_name
+					FROM app_forms f LEFT JOIN modules m ON m.id = f.module_id
+					WHERE f.db_table_name <> ''`).Scan(&formTables).Error; err != nil {
+					return err
+				}
+				for _, ft := range formTables {
+					table := ft.DBTableName
+					if ft.SchemaName != "" && ft.SchemaName != "public" {
+						table = ft.SchemaName + "." + table
+					}
+					var exists bool
+					if err := tx.Raw("SELECT to_regclass(?) IS NOT NULL", table).Scan(&exists).Error; err != nil {
+						return err
+					}
+					if !exists {
+						continue // the table is created with the form's first submission
+					}
+					if err := tx.Exec("ALTER TABLE " + table + " ADD COLUMN IF NOT EXISTS workflow_version INTEGER").Error; err != nil {
+						return err
+					}
+					if err := tx.Exec("UPDATE " + table + " SET workflow_version = 1 WHERE workflow_id IS NOT NULL AND workflow_version IS NULL").Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			// Transitions also record instances in dedicated form tables, whose IDs are not
+			// in form_submissions
+			ID: "20261016_workflow_transitions_any_instance",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.Exec(`
+					DO $$
+					DECLARE c record;
+					BEGIN
+						FOR c IN
+							SELECT conname FROM pg_constraint
+							WHERE conrelid = 'workflow_transitions'::regclass
+							  AND confrelid = 'form_submissions'::regclass
+							  AND contype = 'f'
+						LOOP
+							EXECUTE format('ALTER TABLE workflow_transitions DROP CONSTRAINT %I', c.conname);
+						END LOOP;
+					END $$`).Error
+			},
+		},
+		{
+			ID: "20261016_form_schema_versions",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.FormSchemaVersion{})
+			},
+		},
+		{
+			ID: "20261016_form_record_exports",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.FormRecordExport{})
+			},
+		},
+		{
+			ID: "20261016_form_files",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.FormFile{})
+			},
+		},
+		{
+			ID: "20261016_form_record_audit",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.FormRecordAudit{})
+			},
+		},
+		{
+			ID: "20261016_form_templates",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.FormTemplate{})
+			},
+		},
+		{
+			// Map tiles and nearby searches look nodes up by location
+			ID: "20261016_nodes_location_index",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.Exec("CREATE INDEX IF NOT EXISTS idx_nodes_location ON nodes USING GIST (location)").Error
+			},
+		},
+		{
+			ID: "20261016_task_attendances",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.TaskAttendance{}); err != nil {
+					return err
+				}
+				// A worker is checked in to one task at a time
+				return tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS uq_task_attendances_active_user ON task_attendances(user_id) WHERE deleted_at IS NULL AND status = 'active'").Error
+			},
+		},
+		{
+			ID: "20261016_task_comment_mentions",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.TaskComment{})
+			},
+		},
+		{
+			ID: "20261016_task_daily_progress",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.Tasks{}, &models.TaskDailyProgress{})
+			},
+		},
+		{
+			ID: "20261016_budget_alerts",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.BudgetThreshold{}, &models.BudgetAlert{}); err != nil {
+					return err
+				}
+				// A subject has one open alert per threshold level
+				return tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS uq_budget_alerts_open ON budget_alerts(scope, subject_id, threshold_percent) WHERE status = 'open'").Error
+			},
+		},
+		{
+			ID: "20261016_measurement_book_verification",
+			Migrate: func(tx *gorm.DB) error {
+				// Entries recorded before verification already count as executed
+				legacy := !tx.Migrator().HasColumn(&models.MBEntry{}, "status")
+				if err := tx.AutoMigrate(&models.MBEntry{}); err != nil {
+					return err
+				}
+				if legacy {
+					if err := tx.Exec("UPDATE mb_entries SET status = ?, approved_at = created_at", models.MBStatusApproved).Error; err != nil {
+						return err
+					}
+				}
+				// Entries already billed on a line are billed
+				if err := tx.Exec("UPDATE mb_entries SET ra_bill_id = l.ra_bill_id FROM ra_bill_lines l WHERE l.mb_entry_id = mb_entries.id AND mb_entries.ra_bill_id IS NULL").Error; err != nil {
+					return err
+				}
+
+				permissions := []struct{ Name, Description, Action string }{
+					{"project:mb_check", "Check or reject prepared measurement book entries", "mb_check"},
+					{"project:mb_approve", "Approve checked measurement book entries", "mb_approve"},
+				}
+				for _, p := range permissions {
+					if err := tx.Exec(
+						"INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at) VALUES (?, ?, ?, 'project', ?, NOW(), NOW()) ON CONFLICT (name) DO NOTHING",
+						uuid.New(), p.Name, p.Description, p.Action,
+					).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "20261016_subcontract_work_orders",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(
+					&models.Subcontractor{},
+					&models.WorkOrder{},
+					&models.WorkOrderLine{},
+					&models.WorkOrderCertificate{},
+					&models.SubcontractBill{},
+				); err != nil {
+					return err
+				}
+
+				queries := []string{
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_subcontractors_business_code ON subcontractors(business_vertical_id, code) WHERE deleted_at IS NULL",
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_work_orders_business_number ON work_orders(business_vertical_id, order_number) WHERE deleted_at IS NULL",
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_work_order_certificates_number ON work_order_certificates(work_order_id, certificate_number)",
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_subcontract_bills_business_number ON subcontract_bills(business_vertical_id, bill_number)",
+				}
+				for _, q := range queries {
+					if err := tx.Exec(q).Error; err != nil {
+						return err
+					}
+				}
+
+				permissions := []struct{ Name, Description, Action string }{
+					{"subcontract:read", "View all subcontractors, work orders and bills", "read"},
+					{"subcontract:read_own", "View own subcontractor's work orders and bills", "read_own"},
+					{"subcontract:manage", "Manage subcontractors, work orders, certificates and bills", "manage"},
+					{"subcontract:certify", "Certify work order progress", "certify"},
+					{"subcontract:approve", "Issue work orders and approve subcontract bills", "approve"},
+					{"subcontract:pay", "Mark approved subcontract bills as paid", "pay"},
+				}
+				for _, p := range permissions {
+					if err := tx.Exec(
+						"INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at) VALUES (?, ?, ?, 'subcontract', ?, NOW(), NOW()) ON CONFLICT (name) DO NOTHING",
+						uuid.New(), p.Name, p.Description, p.Action,
+					).Error; err != nil {
+						return err
+					}
+				}
+
+				// Sub_Contractor users see their own subcontractor's records
+				return tx.Exec(`INSERT INTO business_role_permissions (business_role_id, permission_id, created_at)
+					SELECT br.id, p.id, NOW() FROM business_roles br, permissions p
+					WHERE br.name = 'Sub_Contractor' AND p.name = 'subcontract:read_own'
+					AND NOT EXISTS (SELECT 1 FROM business_role_permissions WHERE business_role_id = br.id AND permission_id = p.id)`).Error
+			},
+		},
+		{
+			// Requisition -> purchase order -> GRN -> vendor invoice chain, approved through the
+			// multi_level_approval workflow, with GRNs posting into the stock ledger
+			ID: "20261016_procurement",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(
+					&models.PurchaseRequisition{},
+					&models.PurchaseRequisitionLine{},
+					&models.PurchaseOrder{},
+					&models.PurchaseOrderLine{},
+					&models.GoodsReceiptNote{},
+					&models.StockLedgerEntry{},
+					&models.VendorInvoice{},
+				); err != nil {
+					return err
+				}
+
+				queries := []string{
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_purchase_requisitions_business_number ON purchase_requisitions(business_vertical_id, requisition_number) WHERE deleted_at IS NULL",
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_purchase_orders_business_number ON purchase_orders(business_vertical_id, order_number) WHERE deleted_at IS NULL",
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_goods_receipt_notes_business_number ON goods_receipt_notes(business_vertical_id, grn_number)",
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_vendor_invoices_order_number ON vendor_invoices(purchase_order_id, invoice_number)",
+				}
+				for _, q := range queries {
+					if err := tx.Exec(q).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "20261016_vendors",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.Vendor{}, &models.PurchaseOrder{}, &models.GoodsReceiptNote{}); err != nil {
+					return err
+				}
+
+				// Orders raised before the vendor master named their vendors in free text; each
+				// distinct name becomes a vendor, with the details of its latest order
+				queries := []string{
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_vendors_business_code ON vendors(business_vertical_id, code) WHERE deleted_at IS NULL",
+					`INSERT INTO vendors (id, business_vertical_id, code, name, gstin, contact_name, phone, email, address,
+						categories, applicable_site_ids, is_active, created_by, created_at, updated_at)
+					 SELECT gen_random_uuid(), latest.business_vertical_id,
+						'VEN-' || LPAD((ROW_NUMBER() OVER (PARTITION BY latest.business_vertical_id ORDER BY latest.vendor_name))::text, 4, '0'),
+						latest.vendor_name, latest.vendor_gstin, latest.vendor_contact, latest.vendor_phone, latest.vendor_email,
+						latest.vendor_address, '[]', '[]', true, latest.created_by, NOW(), NOW()
+					 FROM (SELECT DISTINCT ON (business_vertical_id, vendor_name) *
+						FROM purchase_orders WHERE vendor_id IS NULL
+						ORDER BY business_vertical_id, vendor_name, created_at DESC) latest
+					 WHERE NOT EXISTS (SELECT 1 FROM vendors v
+						WHERE v.business_vertical_id = latest.business_vertical_id AND v.name = latest.vendor_name)`,
+					`UPDATE purchase_orders po SET vendor_id = v.id FROM vendors v
+					 WHERE po.vendor_id IS NULL AND v.business_vertical_id = po.business_vertical_id
+						AND v.name = po.vendor_name AND v.deleted_at IS NULL`,
+					`UPDATE goods_receipt_notes grn SET vendor_id = po.vendor_id FROM purchase_orders po
+					 WHERE grn.vendor_id IS NULL AND po.id = grn.purchase_order_id`,
+				}
+				for _, q := range queries {
+					if err := tx.Exec(q).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "20261016_material_reconciliation",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.StockLedgerEntry{}, &models.BOQMaterialNorm{}); err != nil {
+					return err
+				}
+				return tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS uq_boq_material_norms_item_material ON boq_material_norms(boq_item_id, material_code) WHERE deleted_at IS NULL").Error
+			},
+		},
+		{
+			// Asset register with custody, preventive maintenance schedules that the
+			// maintenance scheduler raises tasks from, and downtime logging
+			ID: "20261016_assets",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(
+					&models.Asset{},
+					&models.AssetCustodyTransfer{},
+					&models.MaintenanceSchedule{},
+					&models.MaintenanceTask{},
+					&models.AssetDowntime{},
+				); err != nil {
+					return err
+				}
+
+				queries := []string{
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_assets_business_tag ON assets(business_vertical_id, asset_tag) WHERE deleted_at IS NULL",
+					// One preventive task per schedule and due date, so repeated scheduler runs raise it once
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_maintenance_tasks_schedule_due ON maintenance_tasks(schedule_id, due_on) WHERE schedule_id IS NOT NULL",
+					// At most one open downtime per asset
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_asset_downtimes_open ON asset_downtimes(asset_id) WHERE ended_at IS NULL",
+				}
+				for _, q := range queries {
+					if err := tx.Exec(q).Error; err != nil {
+						return err
+					}
+				}
+
+				permissions := []struct{ Name, Description, Action string }{
+					{"asset:read", "View assets, maintenance schedules and downtime", "read"},
+					{"asset:manage", "Manage assets, custody, maintenance schedules and all asset maintenance", "manage"},
+				}
+				for _, p := range permissions {
+					if err := tx.Exec(
+						"INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at) VALUES (?, ?, ?, 'asset', ?, NOW(), NOW()) ON CONFLICT (name) DO NOTHING",
+						uuid.New(), p.Name, p.Description, p.Action,
+					).Error; err != nil {
+						return err
+					}
+				}
+
+				grants := map[string][]string{
+					"asset:read":   {"HO_Admin", "HO_Manager", "Water_Admin", "Solar_Admin", "Sr_Engineer", "Engineer", "Supervisor", "Operator"},
+					"asset:manage": {"HO_Admin", "HO_Manager", "Water_Admin", "Solar_Admin"},
+				}
+				for permission, roles := range grants {
+					if err := tx.Exec(`INSERT INTO business_role_permissions (business_role_id, permission_id, created_at)
+						SELECT br.id, p.id, NOW() FROM business_roles br, permissions p
+						WHERE br.name IN ? AND p.name = ?
+						AND NOT EXISTS (SELECT 1 FROM business_role_permissions WHERE business_role_id = br.id AND permission_id = p.id)`,
+						roles, permission).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			// Expense claims approved through the multi-level approval workflow, limited by
+			// per-role expense policies and paid out in finance reimbursement batches
+			ID: "20261016_expense_claims",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(
+					&models.ExpenseClaim{},
+					&models.ExpenseClaimItem{},
+					&models.ExpensePolicy{},
+					&models.ReimbursementBatch{},
+				); err != nil {
+					return err
+				}
+
+				queries := []string{
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_expense_claims_business_number ON expense_claims(business_vertical_id, claim_number)",
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_expense_policies_role_category ON expense_policies(business_role_id, category)",
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_reimbursement_batches_business_number ON reimbursement_batches(business_vertical_id, batch_number)",
+				}
+				for _, q := range queries {
+					if err := tx.Exec(q).Error; err != nil {
+						return err
+					}
+				}
+
+				permissions := []struct{ Name, Description, Action string }{
+					{"expense:create", "Raise own expense claims", "create"},
+					{"expense:read", "View all expense claims", "read"},
+					{"expense:policy", "Manage expense policy limits per role", "policy"},
+				}
+				for _, p := range permissions {
+					if err := tx.Exec(
+						"INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at) VALUES (?, ?, ?, 'expense', ?, NOW(), NOW()) ON CONFLICT (name) DO NOTHING",
+						uuid.New(), p.Name, p.Description, p.Action,
+					).Error; err != nil {
+						return err
+					}
+				}
+
+				// Every staff role can claim expenses; administrators see them all and set the limits
+				grants := map[string][]string{
+					"expense:create": {"HO_Admin", "HO_Manager", "Water_Admin", "Solar_Admin", "Area_Project_Manager", "Sr_Engineer", "Engineer", "Supervisor", "Operator"},
+					"expense:read":   {"HO_Admin", "HO_Manager"},
+					"expense:policy": {"HO_Admin"},
+				}
+				for permission, roles := range grants {
+					if err := tx.Exec(`INSERT INTO business_role_permissions (business_role_id, permission_id, created_at)
+						SELECT br.id, p.id, NOW() FROM business_roles br, permissions p
+						WHERE br.name IN ? AND p.name = ?
+						AND NOT EXISTS (SELECT 1 FROM business_role_permissions WHERE business_role_id = br.id AND permission_id = p.id)`,
+						roles, permission).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "20261016_payroll",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(
+					&models.SalaryStructure{},
+					&models.PayrollStatutoryRules{},
+					&models.EmployeeLeave{},
+					&models.PayrollRun{},
+					&models.Payslip{},
+				); err != nil {
+					return err
+				}
+
+				queries := []string{
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_payroll_runs_business_period ON payroll_runs(business_vertical_id, period)",
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_payslips_run_user ON payslips(run_id, user_id)",
+				}
+				for _, q := range queries {
+					if err := tx.Exec(q).Error; err != nil {
+						return err
+					}
+				}
+
+				// Payroll runs follow their own approval workflow, pinned at version 1
+				var count int64
+				if err := tx.Model(&models.WorkflowDefinition{}).Where("code = ?", models.PayrollWorkflowCode).Count(&count).Error; err != nil {
+					return err
+				}
+				if count == 0 {
+					workflow := models.PayrollWorkflowDefinition()
+					workflow.CurrentVersion = 1
+					if err := tx.Create(&workflow).Error; err != nil {
+						return err
+					}
+					snapshot := workflow.Snapshot("")
+					if err := tx.Create(&snapshot).Error; err != nil {
+						return err
+					}
+				}
+
+				permissions := []struct{ Name, Description, Action string }{
+					{"payroll:generate", "Generate payroll", "generate"},
+					{"payroll:approve", "Approve payroll", "approve"},
+				}
+				for _, p := range permissions {
+					if err := tx.Exec(
+						"INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at) VALUES (?, ?, ?, 'payroll', ?, NOW(), NOW()) ON CONFLICT (name) DO NOTHING",
+						uuid.New(), p.Name, p.Description, p.Action,
+					).Error; err != nil {
+						return err
+					}
+				}
+
+				// Head office HR drafts payroll; HR and head office administration approve it
+				grants := map[string][]string{
+					"payroll:generate": {"HO_HR"},
+					"payroll:approve":  {"HO_HR", "HO_Admin"},
+				}
+				for permission, roles := range grants {
+					if err := tx.Exec(`INSERT INTO business_role_permissions (business_role_id, permission_id, created_at)
+						SELECT br.id, p.id, NOW() FROM business_roles br, permissions p
+						WHERE br.name IN ? AND p.name = ?
+						AND NOT EXISTS (SELECT 1 FROM business_role_permissions WHERE business_role_id = br.id AND permission_id = p.id)`,
+						roles, permission).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "20261016_attendance_shifts",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(
+					&models.Shift{},
+					&models.ShiftAssignment{},
+					&models.AttendanceDay{},
+					&models.SalaryStructure{},
+					&models.Payslip{},
+				); err != nil {
+					return err
+				}
+
+				queries := []string{
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_shifts_business_code ON shifts(business_vertical_id, code)",
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_attendance_days_user_date ON attendance_days(user_id, date)",
+				}
+				for _, q := range queries {
+					if err := tx.Exec(q).Error; err != nil {
+						return err
+					}
+				}
+
+				if err := tx.Exec(
+					"INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at) VALUES (?, ?, ?, 'attendance', ?, NOW(), NOW()) ON CONFLICT (name) DO NOTHING",
+					uuid.New(), "attendance:manage", "Manage shifts and record attendance", "manage",
+				).Error; err != nil {
+					return err
+				}
+
+				// Read access to shifts and the muster roll comes with attendance:read
+				roles := []string{"HO_HR", "Water_Admin", "Solar_Admin", "Area_Project_Manager", "Supervisor"}
+				return tx.Exec(`INSERT INTO business_role_permissions (business_role_id, permission_id, created_at)
+					SELECT br.id, p.id, NOW() FROM business_roles br, permissions p
+					WHERE br.name IN ? AND p.name = ?
+					AND NOT EXISTS (SELECT 1 FROM business_role_permissions WHERE business_role_id = br.id AND permission_id = p.id)`,
+					roles, "attendance:manage").Error
+			},
+		},
+		{
+			ID: "20261016_leave",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(
+					&models.LeaveType{},
+					&models.LeaveBalance{},
+					&models.LeaveApplication{},
+					&models.EmployeeLeave{},
+				); err != nil {
+					return err
+				}
+
+				queries := []string{
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_leave_types_business_code ON leave_types(business_vertical_id, code)",
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_leave_balances_user_type_year ON leave_balances(user_id, leave_type_id, year)",
+				}
+				for _, q := range queries {
+					if err := tx.Exec(q).Error; err != nil {
+						return err
+					}
+				}
+
+				// Leave applications follow the standard approval workflow, which until now
+				// only the seeder created
+				var count int64
+				if err := tx.Model(&models.WorkflowDefinition{}).Where("code = ?", models.StandardApprovalWorkflowCode).Count(&count).Error; err != nil {
+					return err
+				}
+				if count == 0 {
+					workflow := models.StandardApprovalWorkflowDefinition()
+					workflow.CurrentVersion = 1
+					if err := tx.Create(&workflow).Error; err != nil {
+						return err
+					}
+					snapshot := workflow.Snapshot("")
+					if err := tx.Create(&snapshot).Error; err != nil {
+						return err
+					}
+				}
+
+				permissions := []struct{ Name, Description, Resource, Action string }{
+					{"leave:apply", "Apply for leave", "leave", "apply"},
+					{"leave:manage", "Manage leave types and balances", "leave", "manage"},
+					{"workflow:approve", "Approve submissions in the standard approval workflow", "workflow", "approve"},
+				}
+				for _, p := range permissions {
+					if err := tx.Exec(
+						"INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at) VALUES (?, ?, ?, ?, ?, NOW(), NOW()) ON CONFLICT (name) DO NOTHING",
+						uuid.New(), p.Name, p.Description, p.Resource, p.Action,
+					).Error; err != nil {
+						return err
+					}
+				}
+
+				// Every role may apply for leave; HR manages it, and HR and managers approve it
+				if err := tx.Exec(`INSERT INTO business_role_permissions (business_role_id, permission_id, created_at)
+					SELECT br.id, p.id, NOW() FROM business_roles br, permissions p
+					WHERE p.name = ?
+					AND NOT EXISTS (SELECT 1 FROM business_role_permissions WHERE business_role_id = br.id AND permission_id = p.id)`,
+					"leave:apply").Error; err != nil {
+					return err
+				}
+				grants := map[string][]string{
+					"leave:manage":     {"HO_HR"},
+					"workflow:approve": {"HO_HR", "HO_Manager", "Area_Project_Manager"},
+				}
+				for permission, roles := range grants {
+					if err := tx.Exec(`INSERT INTO business_role_permissions (business_role_id, permission_id, created_at)
+						SELECT br.id, p.id, NOW() FROM business_roles br, permissions p
+						WHERE br.name IN ? AND p.name = ?
+						AND NOT EXISTS (SELECT 1 FROM business_role_permissions WHERE business_role_id = br.id AND permission_id = p.id)`,
+						roles, permission).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "20261016_employees",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(
+					&models.Employee{},
+					&models.EmployeeDocument{},
+					&models.EmployeeChecklistTemplate{},
+					&models.EmployeeChecklistItem{},
+				); err != nil {
+					return err
+				}
+
+				queries := []string{
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_employees_business_code ON employees(business_vertical_id, employee_code) WHERE deleted_at IS NULL",
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_employees_business_user ON employees(business_vertical_id, user_id) WHERE user_id IS NOT NULL AND deleted_at IS NULL",
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_employee_checklist_templates_business_kind ON employee_checklist_templates(business_vertical_id, kind)",
+				}
+				for _, q := range queries {
+					if err := tx.Exec(q).Error; err != nil {
+						return err
+					}
+				}
+
+				// The hr permissions were only seeded; HR holds all of them
+				permissions := []struct{ Name, Description, Resource, Action string }{
+					{"hr:create", "Add new employee", "hr", "create"},
+					{"hr:read", "View employee details", "hr", "read"},
+					{"hr:update", "Edit employee info", "hr", "update"},
+					{"hr:delete", "Remove employee", "hr", "delete"},
+				}
+				for _, p := range permissions {
+					if err := tx.Exec(
+						"INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at) VALUES (?, ?, ?, ?, ?, NOW(), NOW()) ON CONFLICT (name) DO NOTHING",
+						uuid.New(), p.Name, p.Description, p.Resource, p.Action,
+					).Error; err != nil {
+						return err
+					}
+					if err := tx.Exec(`INSERT INTO business_role_permissions (business_role_id, permission_id, created_at)
+						SELECT br.id, p.id, NOW() FROM business_roles br, permissions p
+						WHERE br.name IN ? AND p.name = ?
+						AND NOT EXISTS (SELECT 1 FROM business_role_permissions WHERE business_role_id = br.id AND permission_id = p.id)`,
+						[]string{"HO_HR"}, p.Name).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "20261016_ledger",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(
+					&models.LedgerAccount{},
+					&models.CostCenter{},
+					&models.JournalEntry{},
+					&models.JournalLine{},
+					&models.LedgerPeriodLock{},
+				); err != nil {
+					return err
+				}
+
+				queries := []string{
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_ledger_accounts_business_code ON ledger_accounts(business_vertical_id, code)",
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_cost_centers_business_code ON cost_centers(business_vertical_id, code)",
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_journal_entries_business_number ON journal_entries(business_vertical_id, entry_number)",
+					// A payroll run, invoice, claim or batch posts once; a reversal is its own source
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_journal_entries_source ON journal_entries(source, source_id) WHERE source <> 'manual'",
+					"CREATE UNIQUE INDEX IF NOT EXISTS uq_ledger_period_locks_business_period ON ledger_period_locks(business_vertical_id, period)",
+				}
+				for _, q := range queries {
+					if err := tx.Exec(q).Error; err != nil {
+						return err
+					}
+				}
+
+				// The finance permissions were only seeded; grant them as the seeder does
+				permissions := []struct{ Name, Description, Resource, Action string }{
+					{"finance:create", "Create financial entry", "finance", "create"},
+					{"finance:read", "View financial records", "finance", "read"},
+					{"finance:update", "Edit financial record", "finance", "update"},
+					{"finance:approve", "Approve transactions", "finance", "approve"},
+				}
+				for _, p := range permissions {
+					if err := tx.Exec(
+						"INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at) VALUES (?, ?, ?, ?, ?, NOW(), NOW()) ON CONFLICT (name) DO NOTHING",
+						uuid.New(), p.Name, p.Description, p.Resource, p.Action,
+					).Error; err != nil {
+						return err
+					}
+				}
+				grants := map[string][]string{
+					"finance:create":  {"HO_Admin", "Water_Admin", "Solar_Admin"},
+					"finance:read":    {"HO_Admin", "HO_Manager", "Water_Admin", "Solar_Admin"},
+					"finance:update":  {"HO_Admin", "HO_Manager", "Water_Admin", "Solar_Admin"},
+					"finance:approve": {"HO_Admin", "Water_Admin", "Solar_Admin"},
+				}
+				for permission, roles := range grants {
+					if err := tx.Exec(`INSERT INTO business_role_permissions (business_role_id, permission_id, created_at)
+						SELECT br.id, p.id, NOW() FROM business_roles br, permissions p
+						WHERE br.name IN ? AND p.name = ?
+						AND NOT EXISTS (SELECT 1 FROM business_role_permissions WHERE business_role_id = br.id AND permission_id = p.id)`,
+						roles, permission).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "20261016_invoice_matching",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(
+					&models.Vendor{},
+					&models.VendorInvoice{},
+					&models.VendorInvoicePayment{},
+					&models.InvoiceMatchTolerance{},
+				); err != nil {
+					return err
+				}
+				// Invoices recorded before due dates were tracked fall due after the default credit period
+				return tx.Exec("UPDATE vendor_invoices SET due_date = invoice_date + 30 WHERE due_date IS NULL").Error
+			},
+		},
+		{
+			ID: "20261016_approval_limits",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.ApprovalLimit{}); err != nil {
+					return err
+				}
+				if err := tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS uq_approval_limits_role_document ON approval_limits(business_role_id, document_type)").Error; err != nil {
+					return err
+				}
+
+				if err := tx.Exec(
+					"INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at) VALUES (?, ?, ?, 'finance', 'limits', NOW(), NOW()) ON CONFLICT (name) DO NOTHING",
+					uuid.New(), "finance:limits", "Manage financial approval limits per role",
+				).Error; err != nil {
+					return err
+				}
+				return tx.Exec(`INSERT INTO business_role_permissions (business_role_id, permission_id, created_at)
+					SELECT br.id, p.id, NOW() FROM business_roles br, permissions p
+					WHERE br.name IN ? AND p.name = ?
+					AND NOT EXISTS (SELECT 1 FROM business_role_permissions WHERE business_role_id = br.id AND permission_id = p.id)`,
+					[]string{"HO_Admin"}, "finance:limits").Error
+			},
+		},
+		{
+			ID: "20261016_currencies",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(
+					&models.Currency{},
+					&models.ExchangeRate{},
+					&models.Project{},
+					&models.BudgetAllocation{},
+					&models.PurchaseOrder{},
+					&models.VendorInvoice{},
+				); err != nil {
+					return err
+				}
+				if err := tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS uq_exchange_rates_currency_date ON exchange_rates(currency_code, rate_date)").Error; err != nil {
+					return err
+				}
+
+				// Base currency amounts, kept by the database from each row's rate
+				generated := []string{
+					"ALTER TABLE projects ADD COLUMN IF NOT EXISTS base_total_budget DECIMAL(15,2) GENERATED ALWAYS AS (ROUND(total_budget * exchange_rate, 2)) STORED",
+					"ALTER TABLE projects ADD COLUMN IF NOT EXISTS base_spent_budget DECIMAL(15,2) GENERATED ALWAYS AS (ROUND(spent_budget * exchange_rate, 2)) STORED",
+					"ALTER TABLE budget_allocations ADD COLUMN IF NOT EXISTS base_planned_amount DECIMAL(15,2) GENERATED ALWAYS AS (ROUND(planned_amount * exchange_rate, 2)) STORED",
+					"ALTER TABLE budget_allocations ADD COLUMN IF NOT EXISTS base_actual_amount DECIMAL(15,2) GENERATED ALWAYS AS (ROUND(actual_amount * exchange_rate, 2)) STORED",
+					"ALTER TABLE purchase_orders ADD COLUMN IF NOT EXISTS base_total_amount DECIMAL(15,2) GENERATED ALWAYS AS (ROUND(total_amount * exchange_rate, 2)) STORED",
+					"ALTER TABLE vendor_invoices ADD COLUMN IF NOT EXISTS base_total_amount DECIMAL(15,2) GENERATED ALWAYS AS (ROUND(total_amount * exchange_rate, 2)) STORED",
+					"ALTER TABLE vendor_invoices ADD COLUMN IF NOT EXISTS base_paid_amount DECIMAL(15,2) GENERATED ALWAYS AS (ROUND(paid_amount * exchange_rate, 2)) STORED",
+				}
+				for _, stmt := range generated {
+					if err := tx.Exec(stmt).Error; err != nil {
+						return err
+					}
+				}
+
+				return tx.Exec(`INSERT INTO currencies (code, name, symbol, decimal_places, is_active, created_at, updated_at) VALUES
+					('INR', 'Indian Rupee', '₹', 2, true, NOW(), NOW()),
+					('USD', 'US Dollar', '$', 2, true, NOW(), NOW()),
+					('EUR', 'Euro', '€', 2, true, NOW(), NOW()),
+					('GBP', 'Pound Sterling', '£', 2, true, NOW(), NOW()),
+					('AED', 'UAE Dirham', 'AED', 2, true, NOW(), NOW())
+					ON CONFLICT (code) DO NOTHING`).Error
+			},
+		},
+		{
+			ID: "20261016_cost_center_tagging",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(
+					&models.Project{},
+					&models.Tasks{},
+					&models.PurchaseOrder{},
+					&models.ExpenseClaimItem{},
+					&models.Employee{},
+					&models.Payslip{},
+				); err != nil {
+					return err
+				}
+				// Orders raised before they carried a project take their requisition's
+				return tx.Exec(`UPDATE purchase_orders po SET project_id = pr.project_id
+					FROM purchase_requisitions pr
+					WHERE pr.id = po.requisition_id AND po.project_id IS NULL AND pr.project_id IS NOT NULL`).Error
+			},
+		},
+		{
+			ID: "20261016_gst_reporting",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.Project{})
+			},
+		},
+		{
+			ID: "20261016_solar_generation",
+			Migrate: func(tx *gorm.DB) error {
+				// Partitioned by month on recorded_at; the key makes ingestion idempotent per
+				// device and timestamp
+				queries := []string{
+					`CREATE TABLE IF NOT EXISTS solar_generation_readings (
+						device_id uuid NOT NULL,
+						recorded_at timestamptz NOT NULL,
+						business_vertical_id uuid NOT NULL,
+						site_id uuid NOT NULL,
+						energy_kwh decimal(14,4) NOT NULL,
+						power_kw decimal(12,3),
+						dc_voltage decimal(8,2),
+						inverter_temp_c decimal(6,2),
+						inverter_status varchar(30),
+						received_at timestamptz NOT NULL,
+						received_by varchar(255),
+						PRIMARY KEY (device_id, recorded_at)
+					) PARTITION BY RANGE (recorded_at)`,
+					"CREATE INDEX IF NOT EXISTS idx_solar_generation_site_time ON solar_generation_readings (site_id, recorded_at)",
+					"CREATE INDEX IF NOT EXISTS idx_solar_generation_vertical_time ON solar_generation_readings (business_vertical_id, recorded_at)",
+				}
+				for _, q := range queries {
+					if err := tx.Exec(q).Error; err != nil {
+						return err
+					}
+				}
+				now := time.Now()
+				for _, month := range []time.Time{now, now.AddDate(0, 1, 0)} {
+					if err := models.EnsureSolarGenerationPartition(tx, month); err != nil {
+						return err
+					}
+				}
+
+				if err := tx.Exec(
+					"INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at) VALUES (?, ?, ?, 'solar', 'write', NOW(), NOW()) ON CONFLICT (name) DO NOTHING",
+					uuid.New(), "solar:write_generation", "Record solar generation data",
+				).Error; err != nil {
+					return err
+				}
+				return tx.Exec(`INSERT INTO business_role_permissions (business_role_id, permission_id, created_at)
+					SELECT br.id, p.id, NOW() FROM business_roles br, permissions p
+					WHERE br.name IN ? AND p.name = ?
+					AND NOT EXISTS (SELECT 1 FROM business_role_permissions WHERE business_role_id = br.id AND permission_id = p.id)`,
+					[]string{"Solar_Admin", "Sr_Engineer"}, "solar:write_generation").Error
+			},
+		},
+		{
+			ID: "20261016_device_health",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.SensorDevice{})
+			},
+		},
+		{
+			ID: "20261016_solar_performance",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.SolarPlant{})
+			},
+		},
+		{
+			ID: "20261016_solar_panel_registry",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.SolarBlock{}, &models.SolarString{}, &models.SolarPanel{}, &models.SolarStringFault{})
+			},
+		},
+		{
+			ID: "20261016_maintenance_ticketing",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.MaintenanceTask{})
+			},
+		},
+		{
+			ID: "20261016_water_quality",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(
+					&models.WaterQualityParameter{},
+					&models.WaterSamplingPoint{},
+					&models.WaterQualityTest{},
+					&models.WaterQualityResult{},
+				); err != nil {
+					return err
+				}
+				var verticals []models.BusinessVertical
+				if err := tx.Where("code = ?", "WATER").Find(&verticals).Error; err != nil {
+					return err
+				}
+				for _, vertical := range verticals {
+					for _, parameter := range models.DefaultWaterQualityParameters(vertical.ID) {
+						if err := tx.Where("business_vertical_id = ? AND code = ?", vertical.ID, parameter.Code).
+							FirstOrCreate(&parameter).Error; err != nil {
+							return err
+						}
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "20261016_pump_operations",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(
+					&models.WaterPump{},
+					&models.PumpDailyLog{},
+				)
+			},
+		},
+		{
+			ID: "20261016_water_nrw",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(
+					&models.WaterZone{},
+					&models.WaterZoneLoss{},
+				)
+			},
+		},
+		{
+			ID: "20261016_telemetry_alarms",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(
+					&models.AlarmRule{},
+					&models.AlarmRuleState{},
+					&models.Alarm{},
+					&models.AlarmEvent{},
+				); err != nil {
+					return err
+				}
+				// A device has one unresolved alarm per rule at a time
+				if err := tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS uq_alarms_open_rule_device ON alarms(rule_id, device_id) WHERE status <> 'resolved'").Error; err != nil {
+					return err
+				}
+
+				permissions := []struct{ Name, Description, Action string }{
+					{"alarm:read", "View telemetry alarms and alarm rules", "read"},
+					{"alarm:respond", "Acknowledge, assign and resolve telemetry alarms", "respond"},
+					{"alarm:manage", "Define telemetry alarm rules", "manage"},
+				}
+				for _, p := range permissions {
+					if err := tx.Exec(
+						"INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at) VALUES (?, ?, ?, 'alarm', ?, NOW(), NOW()) ON CONFLICT (name) DO NOTHING",
+						uuid.New(), p.Name, p.Description, p.Action,
+					).Error; err != nil {
+						return err
+					}
+				}
+				grants := map[string][]string{
+					"alarm:read": {"HO_Admin", "HO_Manager", "Water_Admin", "Solar_Admin", "Area_Project_Manager",
+						"Sr_Engineer", "Engineer", "Supervisor", "Operator"},
+					"alarm:respond": {"HO_Admin", "Water_Admin", "Solar_Admin", "Area_Project_Manager", "Sr_Engineer",
+						"Engineer", "Supervisor", "Operator"},
+					"alarm:manage": {"HO_Admin", "Water_Admin", "Solar_Admin"},
+				}
+				for permission, roles := range grants {
+					if err := tx.Exec(`INSERT INTO business_role_permissions (business_role_id, permission_id, created_at)
+						SELECT br.id, p.id, NOW() FROM business_roles br, permissions p
+						WHERE br.name IN ? AND p.name = ?
+						AND NOT EXISTS (SELECT 1 FROM business_role_permissions WHERE business_role_id = br.id AND permission_id = p.id)`,
+						roles, permission).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "20261016_push_deliveries",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(
+					&models.PushDelivery{},
+					&models.NotificationPreference{},
+				)
+			},
+		},
+		{
+			ID: "20261016_sms_channel",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(
+					&models.SMSMessage{},
+					&models.SMSOTP{},
+				)
+			},
+		},
+		{
+			ID: "20261016_notification_digests",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(
+					&models.Notification{},
+					&models.NotificationPreference{},
+					&models.NotificationDigest{},
+				); err != nil {
+					return err
+				}
+				return tx.Exec(`CREATE INDEX IF NOT EXISTS idx_notifications_digest_pending
+					ON notifications (user_id, digest_frequency)
+					WHERE digest_frequency <> '' AND digest_id IS NULL`).Error
+			},
+		},
+		{
+			ID: "20261016_announcements",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(
+					&models.Announcement{},
+					&models.AnnouncementReceipt{},
+				); err != nil {
+					return err
+				}
+
+				permissions := []struct{ Name, Description, Action string }{
+					{"announcement:read", "View announcements and who has seen or accepted them", "read"},
+					{"announcement:manage", "Post, edit and withdraw announcements", "manage"},
+				}
+				for _, p := range permissions {
+					if err := tx.Exec(
+						"INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at) VALUES (?, ?, ?, 'announcement', ?, NOW(), NOW()) ON CONFLICT (name) DO NOTHING",
+						uuid.New(), p.Name, p.Description, p.Action,
+					).Error; err != nil {
+						return err
+					}
+				}
+				grants := map[string][]string{
+					"announcement:read":   {"HO_Admin", "HO_Manager", "Water_Admin", "Solar_Admin", "Area_Project_Manager"},
+					"announcement:manage": {"HO_Admin", "HO_Manager", "Water_Admin", "Solar_Admin", "Area_Project_Manager"},
+				}
+				for permission, roles := range grants {
+					if err := tx.Exec(`INSERT INTO business_role_permissions (business_role_id, permission_id, created_at)
+						SELECT br.id, p.id, NOW() FROM business_roles br, permissions p
+						WHERE br.name IN ? AND p.name = ?
+						AND NOT EXISTS (SELECT 1 FROM business_role_permissions WHERE business_role_id = br.id AND permission_id = p.id)`,
+						roles, permission).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "20261016_notification_delivery_attempts",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(
+					&models.NotificationDeliveryAttempt{},
+					&models.SMSMessage{},
+				)
+			},
+		},
+		{
+			ID: "20261016_reminders",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.Reminder{}); err != nil {
+					return err
+				}
+
+				if err := tx.Exec(
+					"INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at) VALUES (?, ?, ?, 'reminder', ?, NOW(), NOW()) ON CONFLICT (name) DO NOTHING",
+					uuid.New(), "reminder:manage", "Set and cancel reminders for other users and roles", "manage",
+				).Error; err != nil {
+					return err
+				}
+				return tx.Exec(`INSERT INTO business_role_permissions (business_role_id, permission_id, created_at)
+					SELECT br.id, p.id, NOW() FROM business_roles br, permissions p
+					WHERE br.name IN ? AND p.name = ?
+					AND NOT EXISTS (SELECT 1 FROM business_role_permissions WHERE business_role_id = br.id AND permission_id = p.id)`,
+					[]string{"HO_Admin", "HO_Manager", "Water_Admin", "Solar_Admin", "Area_Project_Manager", "Sr_Engineer", "Supervisor"},
+					"reminder:manage").Error
+			},
+		},
+		{
+			ID: "20261016_document_folders_and_uploads",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(
+					&models.DocumentFolder{},
+					&models.DocumentUpload{},
+					&models.Document{},
+				)
+			},
+		},
+		// Full-text search over documents: extracted file text (PDF text layer or OCR)
+		// weighted below the title and description, kept current by a generated column.
+		// 'simple' as for form records, since work orders mix languages and codes.
+		{
+			ID: "20261016_document_text_search",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.Document{}); err != nil {
+					return err
+				}
+				statements := []string{
+					`ALTER TABLE documents ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+						setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+						setweight(to_tsvector('simple', coalesce(description, '')), 'B') ||
+						setweight(to_tsvector('simple', coalesce(content_text, '')), 'C')
+					) STORED`,
+					"CREATE INDEX IF NOT EXISTS idx_documents_search_vector ON documents USING GIN (search_vector)",
+				}
+				for _, stmt := range statements {
+					if err := tx.Exec(stmt).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		// Business vertical names and codes are unique per company rather than globally, so
+		// each tenant can have its own WATER vertical
+		{
+			ID: "20261016_business_verticals_unique_per_company",
+			Migrate: func(tx *gorm.DB) error {
+				statements := []string{
+					"DROP INDEX IF EXISTS idx_business_verticals_name",
+					"DROP INDEX IF EXISTS idx_business_verticals_code",
+					"ALTER TABLE business_verticals DROP CONSTRAINT IF EXISTS uni_business_verticals_name",
+					"ALTER TABLE business_verticals DROP CONSTRAINT IF EXISTS uni_business_verticals_code",
+					"ALTER TABLE business_verticals DROP CONSTRAINT IF EXISTS business_verticals_name_key",
+					"ALTER TABLE business_verticals DROP CONSTRAINT IF EXISTS business_verticals_code_key",
+				}
+				for _, stmt := range statements {
+					if err := tx.Exec(stmt).Error; err != nil {
+						return err
+					}
+				}
+				return tx.AutoMigrate(&models.BusinessVertical{})
+			},
+		},
+		{
+			// Failed attempts of timed workflow transitions, backing off the failing instances
+			ID: "20261016_workflow_timer_failures",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.WorkflowTimerFailure{})
+			},
+		},
+		{
+			// Every handler reads through the caller's data scope; head office roles keep their
+			// cross-vertical view
+			ID: "20261016_data_all_verticals",
+			Migrate: func(tx *gorm.DB) error {
+				queries := []string{
+					`INSERT INTO permissions (id, name, description, resource, action, created_at, updated_at)
+					 VALUES (gen_random_uuid(), 'data:all_verticals', 'Read and write every business vertical''s records', 'data', 'all_verticals', NOW(), NOW())
+					 ON CONFLICT (name) DO NOTHING`,
+					`INSERT INTO role_permissions (role_id, permission_id, created_at)
+					 SELECT r.id, p.id, NOW() FROM roles r, permissions p
+					 WHERE r.name IN ('System_Admin', 'Admin') AND p.name = 'data:all_verticals'
+					 ON CONFLICT DO NOTHING`,
+				}
+
+				for _, q := range queries {
+					if err := tx.Exec(q).Error; err != nil {
+						return err
+					}
+				}
+
 				return nil
 			},
 		},
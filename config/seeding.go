@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -104,6 +106,20 @@ func SeedPermissions() {
 		{ID: uuid.New(), Name: "finance:read", Resource: "finance", Action: "read", Description: "View financial records"},
 		{ID: uuid.New(), Name: "finance:update", Resource: "finance", Action: "update", Description: "Edit financial record"},
 		{ID: uuid.New(), Name: "finance:approve", Resource: "finance", Action: "approve", Description: "Approve transactions"},
+		{ID: uuid.New(), Name: "finance:limits", Resource: "finance", Action: "limits", Description: "Manage financial approval limits per role"},
+
+		// Subcontracting
+		{ID: uuid.New(), Name: "subcontract:read", Resource: "subcontract", Action: "read", Description: "View all subcontractors, work orders and bills"},
+		{ID: uuid.New(), Name: "subcontract:read_own", Resource: "subcontract", Action: "read_own", Description: "View own subcontractor's work orders and bills"},
+		{ID: uuid.New(), Name: "subcontract:manage", Resource: "subcontract", Action: "manage", Description: "Manage subcontractors, work orders, certificates and bills"},
+		{ID: uuid.New(), Name: "subcontract:certify", Resource: "subcontract", Action: "certify", Description: "Certify work order progress"},
+		{ID: uuid.New(), Name: "subcontract:approve", Resource: "subcontract", Action: "approve", Description: "Issue work orders and approve subcontract bills"},
+		{ID: uuid.New(), Name: "subcontract:pay", Resource: "subcontract", Action: "pay", Description: "Mark approved subcontract bills as paid"},
+
+		// Expense claims
+		{ID: uuid.New(), Name: "expense:create", Resource: "expense", Action: "create", Description: "Raise own expense claims"},
+		{ID: uuid.New(), Name: "expense:read", Resource: "expense", Action: "read", Description: "View all expense claims"},
+		{ID: uuid.New(), Name: "expense:policy", Resource: "expense", Action: "policy", Description: "Manage expense policy limits per role"},
 
 		// Bank Guarantee
 		{ID: uuid.New(), Name: "bg:create", Resource: "bg", Action: "create", Description: "Create bank guarantee"},
@@ -139,6 +155,10 @@ func SeedPermissions() {
 		{ID: uuid.New(), Name: "risk:update", Resource: "risk", Action: "update", Description: "Update risk assessments"},
 		{ID: uuid.New(), Name: "risk:approve", Resource: "risk", Action: "approve", Description: "Approve risk assessments"},
 
+		// Form Records
+		{ID: uuid.New(), Name: "form_record:restore", Resource: "form_record", Action: "restore", Description: "View deleted form records and restore them"},
+		{ID: uuid.New(), Name: "form_record:purge", Resource: "form_record", Action: "purge", Description: "Permanently delete form records from the recycle bin"},
+
 		// Documents / DMS
 		{ID: uuid.New(), Name: "document:upload", Resource: "document", Action: "create", Description: "Upload document"},
 		{ID: uuid.New(), Name: "document:read", Resource: "document", Action: "read", Description: "View document"},
@@ -168,9 +188,11 @@ func SeedPermissions() {
 		{ID: uuid.New(), Name: "business:read", Resource: "business", Action: "read", Description: "View business vertical"},
 		{ID: uuid.New(), Name: "business:update", Resource: "business", Action: "update", Description: "Edit business vertical"},
 		{ID: uuid.New(), Name: "business:delete", Resource: "business", Action: "delete", Description: "Delete business vertical"},
+		{ID: uuid.New(), Name: "data:all_verticals", Resource: "data", Action: "all_verticals", Description: "Read and write every business vertical's records"},
 
 		// Solar Vertical Specific
 		{ID: uuid.New(), Name: "solar:read_generation", Resource: "solar", Action: "read", Description: "View solar generation data"},
+		{ID: uuid.New(), Name: "solar:write_generation", Resource: "solar", Action: "write", Description: "Record solar generation data"},
 		{ID: uuid.New(), Name: "solar:manage_panels", Resource: "solar", Action: "manage", Description: "Manage solar panel configurations"},
 		{ID: uuid.New(), Name: "solar:maintenance", Resource: "solar", Action: "maintenance", Description: "Perform solar equipment maintenance"},
 
@@ -194,6 +216,10 @@ func SeedPermissions() {
 		{ID: uuid.New(), Name: "attendance:checkout", Resource: "attendance", Action: "checkout", Description: "Check out from a site attendance session"},
 		{ID: uuid.New(), Name: "attendance:read", Resource: "attendance", Action: "read", Description: "View attendance sessions, logs, and timelines"},
 		{ID: uuid.New(), Name: "attendance:headcount", Resource: "attendance", Action: "headcount", Description: "View live attendance headcount by site"},
+		{ID: uuid.New(), Name: "attendance:manage", Resource: "attendance", Action: "manage", Description: "Manage shifts and record attendance"},
+		{ID: uuid.New(), Name: "leave:apply", Resource: "leave", Action: "apply", Description: "Apply for leave"},
+		{ID: uuid.New(), Name: "leave:manage", Resource: "leave", Action: "manage", Description: "Manage leave types and balances"},
+		{ID: uuid.New(), Name: "workflow:approve", Resource: "workflow", Action: "approve", Description: "Approve submissions in the standard approval workflow"},
 
 		// ABAC & Policy Management
 		{ID: uuid.New(), Name: "manage_policies", Resource: "policy", Action: "manage", Description: "Manage access control policies"},
@@ -263,6 +289,7 @@ func SeedPermissions() {
 			Permissions: []models.Permission{
 				{Name: "user:create"}, {Name: "user:read"}, {Name: "user:update"}, {Name: "user:delete"},
 				{Name: "role:read"}, {Name: "role:assign"}, {Name: "business:read"},
+				{Name: "data:all_verticals"},
 			},
 		},
 		{
@@ -279,6 +306,8 @@ func SeedPermissions() {
 				{Name: "report:read"}, {Name: "report:export"},
 				{Name: "document:upload"}, {Name: "document:read"}, {Name: "document:update"}, {Name: "document:delete"},
 				{Name: "document:manage_categories"}, {Name: "document:manage_tags"}, {Name: "document:share"}, {Name: "document:manage_permissions"},
+				{Name: "form_record:restore"}, {Name: "form_record:purge"},
+				{Name: "data:all_verticals"},
 			},
 		},
 		{
@@ -308,6 +337,7 @@ func SeedPermissions() {
 
 	for _, roleData := range globalRoles {
 		var role models.Role
+		created := false
 		err := DB.Where("name = ?", roleData.Name).First(&role).Error
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			role = models.Role{
@@ -320,6 +350,7 @@ func SeedPermissions() {
 				log.Printf("Error creating role %s: %v", roleData.Name, err)
 				continue
 			}
+			created = true
 			log.Printf("Created role: %s", roleData.Name)
 		} else if err != nil {
 			log.Printf("DB error fetching role %s: %v", roleData.Name, err)
@@ -334,6 +365,11 @@ func SeedPermissions() {
 			}
 		}
 
+		before, err := models.SnapshotRole(DB, models.RoleTypeGlobal, role.ID)
+		if err != nil {
+			log.Printf("Error snapshotting role %s: %v", roleData.Name, err)
+		}
+
 		// Clear existing permissions
 		DB.Exec("DELETE FROM role_permissions WHERE role_id = ?", role.ID)
 
@@ -350,6 +386,11 @@ func SeedPermissions() {
 		var assignedCount int64
 		DB.Table("role_permissions").Where("role_id = ?", role.ID).Count(&assignedCount)
 		log.Printf("Assigned %d permissions to role '%s'", assignedCount, role.Name)
+
+		action, before := seedingRoleChange(created, before)
+		if err := models.RecordRoleChange(DB, models.RoleTypeGlobal, role.ID, action, models.RoleChangeSourceSeeding, nil, before); err != nil {
+			log.Printf("Error recording history of role %s: %v", roleData.Name, err)
+		}
 	}
 }
 
@@ -357,8 +398,55 @@ func SeedPermissions() {
 // Business Verticals Seeding
 // =====================================================
 
+// SeedCompany returns the company seeded data belongs to, creating it when missing. Set
+// SEED_COMPANY_CODE (and SEED_COMPANY_NAME) to seed a deployment's other legal entities;
+// it defaults to the DEFAULT company existing data was migrated into.
+func SeedCompany() *models.Company {
+	code := strings.ToUpper(strings.TrimSpace(os.Getenv("SEED_COMPANY_CODE")))
+	if code == "" {
+		code = models.DefaultCompanyCode
+	}
+
+	var company models.Company
+	if err := DB.Where("code = ?", code).First(&company).Error; err == nil {
+		return &company
+	}
+
+	name := strings.TrimSpace(os.Getenv("SEED_COMPANY_NAME"))
+	if name == "" {
+		name = code
+	}
+	company = models.Company{Name: name, Code: code, LegalName: name, IsActive: true}
+	if err := DB.Create(&company).Error; err != nil {
+		log.Printf("Error creating company %s: %v", code, err)
+		return nil
+	}
+	log.Printf("Created company: %s (ID: %s)", code, company.ID)
+	return &company
+}
+
+// seedCompanyID is the ID of the seeding company, or nil when it could not be created
+func seedCompanyID() *uuid.UUID {
+	if company := SeedCompany(); company != nil {
+		return &company.ID
+	}
+	return nil
+}
+
+// seedCompanyVertical loads the seeding company's vertical with code; vertical codes are
+// unique only within a company
+func seedCompanyVertical(companyID *uuid.UUID, code string, vertical *models.BusinessVertical) error {
+	query := DB.Where("code = ?", code)
+	if companyID != nil {
+		query = query.Where("company_id = ?", *companyID)
+	}
+	return query.First(vertical).Error
+}
+
 // SeedBusinessVerticals creates default business verticals and their roles
 func SeedBusinessVerticals() {
+	companyID := seedCompanyID()
+
 	defaultBusinesses := []struct {
 		Name        string
 		Code        string
@@ -372,7 +460,7 @@ func SeedBusinessVerticals() {
 
 	for _, businessData := range defaultBusinesses {
 		var business models.BusinessVertical
-		err := DB.Where("code = ?", businessData.Code).First(&business).Error
+		err := seedCompanyVertical(companyID, businessData.Code, &business)
 
 		if err != nil {
 			defaultSettings := "{}"
@@ -382,6 +470,7 @@ func SeedBusinessVerticals() {
 				Description: businessData.Description,
 				IsActive:    true,
 				Settings:    &defaultSettings,
+				CompanyID:   companyID,
 			}
 
 			if err := DB.Create(&business).Error; err != nil {
@@ -428,6 +517,7 @@ func createDefaultBusinessRoles(businessID uuid.UUID, businessCode string) {
 
 	for _, roleData := range defaultRoles {
 		var role models.BusinessRole
+		created := false
 		err := DB.Where("name = ? AND business_vertical_id = ?", roleData.Name, businessID).First(&role).Error
 
 		if err != nil {
@@ -444,9 +534,15 @@ func createDefaultBusinessRoles(businessID uuid.UUID, businessCode string) {
 				log.Printf("Error creating business role %s: %v", roleData.Name, err)
 				continue
 			}
+			created = true
 			log.Printf("Created business role: %s", roleData.DisplayName)
 		}
 
+		before, err := models.SnapshotRole(DB, models.RoleTypeBusiness, role.ID)
+		if err != nil {
+			log.Printf("Error snapshotting business role %s: %v", roleData.Name, err)
+		}
+
 		// Assign permissions
 		if len(roleData.Permissions) > 0 {
 			DB.Exec("DELETE FROM business_role_permissions WHERE business_role_id = ?", role.ID)
@@ -462,9 +558,24 @@ func createDefaultBusinessRoles(businessID uuid.UUID, businessCode string) {
 				}
 			}
 		}
+
+		action, before := seedingRoleChange(created, before)
+		if err := models.RecordRoleChange(DB, models.RoleTypeBusiness, role.ID, action, models.RoleChangeSourceSeeding, nil, before); err != nil {
+			log.Printf("Error recording history of business role %s: %v", roleData.Name, err)
+		}
 	}
 }
 
+// seedingRoleChange returns the history action for a role seeding has just saved and the
+// snapshot to compare it with: a role seeding created is recorded as "created" with all
+// its permissions added, any other as "updated" from its state before seeding.
+func seedingRoleChange(created bool, before *models.RoleSnapshot) (string, *models.RoleSnapshot) {
+	if created {
+		return models.RoleChangeCreated, nil
+	}
+	return models.RoleChangeUpdated, before
+}
+
 func getHORoles(businessID uuid.UUID) []models.BusinessRole {
 	return []models.BusinessRole{
 		{
@@ -597,7 +708,7 @@ func getSolarRoles(businessID uuid.UUID) []models.BusinessRole {
 				{Name: "lc:create"}, {Name: "lc:read"}, {Name: "lc:update"}, {Name: "lc:issue"}, {Name: "lc:amendment"}, {Name: "lc:negotiation"}, {Name: "lc:claim"},
 				{Name: "insurance:create"}, {Name: "insurance:read"}, {Name: "insurance:update"}, {Name: "insurance:renew"}, {Name: "insurance:file_claim"}, {Name: "insurance:approve_claim"},
 				{Name: "risk:assess"}, {Name: "risk:read"}, {Name: "risk:update"}, {Name: "risk:approve"},
-				{Name: "solar:read_generation"}, {Name: "solar:manage_panels"}, {Name: "solar:maintenance"},
+				{Name: "solar:read_generation"}, {Name: "solar:write_generation"}, {Name: "solar:manage_panels"}, {Name: "solar:maintenance"},
 				{Name: "report:read"}, {Name: "report:export"},
 				{Name: "document:upload"}, {Name: "document:read"}, {Name: "document:update"}, {Name: "document:delete"},
 				{Name: "document:manage_categories"}, {Name: "document:manage_tags"}, {Name: "document:share"}, {Name: "document:manage_permissions"},
@@ -619,7 +730,7 @@ func getSolarRoles(businessID uuid.UUID) []models.BusinessRole {
 			Name: "Sr_Engineer", DisplayName: "Solar Sr Engineer", Description: "Manage panels, solar generation, maintenance",
 			BusinessVerticalID: businessID, Level: 3, IsActive: true,
 			Permissions: []models.Permission{
-				{Name: "solar:read_generation"}, {Name: "solar:manage_panels"}, {Name: "solar:maintenance"},
+				{Name: "solar:read_generation"}, {Name: "solar:write_generation"}, {Name: "solar:manage_panels"}, {Name: "solar:maintenance"},
 				{Name: "attendance:checkin"}, {Name: "attendance:heartbeat"}, {Name: "attendance:checkout"},
 				{Name: "attendance:read"}, {Name: "attendance:headcount"},
 			},
@@ -630,11 +741,12 @@ func getSolarRoles(businessID uuid.UUID) []models.BusinessRole {
 func getContractorRoles(businessID uuid.UUID) []models.BusinessRole {
 	return []models.BusinessRole{
 		{
-			Name: "Sub_Contractor", DisplayName: "Sub Contractor", Description: "Read-only access to Projects, Materials, Inventory",
+			Name: "Sub_Contractor", DisplayName: "Sub Contractor", Description: "Read-only access to Projects, Materials, Inventory and own work orders",
 			BusinessVerticalID: businessID, Level: 5, IsActive: true,
 			Permissions: []models.Permission{
 				{Name: "project:read"},
 				{Name: "inventory:read"},
+				{Name: "subcontract:read_own"},
 			},
 		},
 	}
@@ -649,30 +761,7 @@ func SeedWorkflows() {
 	log.Println("Seeding default workflows...")
 
 	// Standard Approval Workflow - Draft -> Submitted -> Approved/Rejected
-	approvalWorkflow := models.WorkflowDefinition{
-		Code:         "standard_approval",
-		Name:         "Standard Approval Workflow",
-		Description:  "Basic approval workflow with draft, submit, approve, and reject states",
-		Version:      "1.0.0",
-		InitialState: "draft",
-		States: []byte(`[
-			{"code": "draft", "name": "Draft", "description": "Initial draft state", "color": "gray", "is_final": false},
-			{"code": "submitted", "name": "Submitted", "description": "Submitted for review", "color": "blue", "is_final": false},
-			{"code": "approved", "name": "Approved", "description": "Approved by reviewer", "color": "green", "is_final": true},
-			{"code": "rejected", "name": "Rejected", "description": "Rejected by reviewer", "color": "red", "is_final": true}
-		]`),
-		Transitions: []byte(`[
-			{"from": "draft", "to": "submitted", "action": "submit", "label": "Submit for Review", "required_permission": "",
-				"notifications": [{"title_template": "Form submitted: {{.FormCode}}", "body_template": "Your {{.FormCode}} submission ({{.SubmissionID}}) has been submitted for review.", "channels": ["in_app"], "recipients": [{"type": "submitter"}]}]},
-			{"from": "submitted", "to": "approved", "action": "approve", "label": "Approve", "required_permission": "workflow:approve",
-				"notifications": [{"title_template": "Form approved: {{.FormCode}}", "body_template": "Your {{.FormCode}} submission ({{.SubmissionID}}) has been approved by {{.ApproverName}}.", "channels": ["in_app"], "priority": "high", "recipients": [{"type": "submitter"}]}]},
-			{"from": "submitted", "to": "rejected", "action": "reject", "label": "Reject", "required_permission": "workflow:approve",
-				"notifications": [{"title_template": "Form rejected: {{.FormCode}}", "body_template": "Your {{.FormCode}} submission ({{.SubmissionID}}) was rejected by {{.ApproverName}}. Comment: {{.Comment}}", "channels": ["in_app"], "priority": "high", "recipients": [{"type": "submitter"}]}]},
-			{"from": "rejected", "to": "draft", "action": "revise", "label": "Revise", "required_permission": "",
-				"notifications": [{"title_template": "Revision requested: {{.FormCode}}", "body_template": "Your {{.FormCode}} submission ({{.SubmissionID}}) has been sent back for revision. Please update and resubmit.", "channels": ["in_app"], "priority": "high", "recipients": [{"type": "submitter"}]}]}
-		]`),
-		IsActive: true,
-	}
+	approvalWorkflow := models.StandardApprovalWorkflowDefinition()
 
 	// Multi-Level Approval Workflow
 	multiLevelWorkflow := models.WorkflowDefinition{
@@ -827,6 +916,7 @@ func SeedWorkflows() {
 		lcWorkflow,
 		insurancePolicyWorkflow,
 		insuranceClaimWorkflow,
+		models.PayrollWorkflowDefinition(),
 	}
 
 	log.Printf("Attempting to seed %d workflows...", len(workflows))
@@ -1252,15 +1342,16 @@ func SeedFinanceModulesAndForms() {
 func SeedSites() {
 	log.Println("Seeding default sites...")
 
+	companyID := seedCompanyID()
 	var waterBusiness, solarBusiness models.BusinessVertical
 
-	if err := DB.Where("code = ?", "WATER").First(&waterBusiness).Error; err != nil {
+	if err := seedCompanyVertical(companyID, "WATER", &waterBusiness); err != nil {
 		log.Printf("Water Works business vertical not found: %v", err)
 	} else {
 		seedWaterSites(waterBusiness.ID)
 	}
 
-	if err := DB.Where("code = ?", "SOLAR").First(&solarBusiness).Error; err != nil {
+	if err := seedCompanyVertical(companyID, "SOLAR", &solarBusiness); err != nil {
 		log.Printf("Solar Works business vertical not found: %v", err)
 	} else {
 		seedSolarSites(solarBusiness.ID)
@@ -1375,10 +1466,11 @@ func MigrateToNewRBAC() {
 	DB.Find(&users)
 	log.Printf("Found %d users to migrate", len(users))
 
+	companyID := seedCompanyID()
 	var waterVertical, solarVertical, hoVertical models.BusinessVertical
-	DB.Where("code = ?", "WATER").First(&waterVertical)
-	DB.Where("code = ?", "SOLAR").First(&solarVertical)
-	DB.Where("code = ?", "HO").First(&hoVertical)
+	seedCompanyVertical(companyID, "WATER", &waterVertical)
+	seedCompanyVertical(companyID, "SOLAR", &solarVertical)
+	seedCompanyVertical(companyID, "HO", &hoVertical)
 
 	if waterVertical.ID == uuid.Nil {
 		log.Printf("Water vertical not found - run SeedBusinessVerticals first")
@@ -1441,11 +1533,13 @@ func SeedUsers() {
 		return
 	}
 
+	companyID := seedCompanyID()
+
 	// Get business verticals
 	var waterVertical, solarVertical, hoVertical models.BusinessVertical
-	DB.Where("code = ?", "WATER").First(&waterVertical)
-	DB.Where("code = ?", "SOLAR").First(&solarVertical)
-	DB.Where("code = ?", "HO").First(&hoVertical)
+	seedCompanyVertical(companyID, "WATER", &waterVertical)
+	seedCompanyVertical(companyID, "SOLAR", &solarVertical)
+	seedCompanyVertical(companyID, "HO", &hoVertical)
 
 	// Get business roles for each vertical
 	var waterAdminRole, solarAdminRole, hoAdminRole models.BusinessRole
@@ -1541,6 +1635,7 @@ func SeedUsers() {
 			PasswordHash:       string(passwordHash),
 			RoleID:             userData.RoleID,
 			BusinessVerticalID: userData.BusinessVerticalID,
+			CompanyID:          companyID,
 			IsActive:           true,
 		}
 
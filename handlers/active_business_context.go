@@ -6,7 +6,6 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 )
@@ -78,7 +77,7 @@ func GetActiveBusinessContext(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var business models.BusinessVertical
-	if err := config.DB.First(&business, "id = ?", businessID).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&business, "id = ?", businessID).Error; err != nil {
 		http.Error(w, "business not found", http.StatusNotFound)
 		return
 	}
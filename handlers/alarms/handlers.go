@@ -0,0 +1,438 @@
+package alarms
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/handlers/chat"
+	"p9e.in/ugcl/handlers/httputil"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// Handler serves the alarm rule and alarm endpoints
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates an alarm handler
+func NewHandler() *Handler {
+	return &Handler{service: NewService()}
+}
+
+// writeLifecycleErr reports a lifecycle step that failed
+func writeLifecycleErr(w http.ResponseWriter, err error) {
+	if errors.Is(err, errStore) {
+		http.Error(w, "failed to update alarm", http.StatusInternalServerError)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusConflict)
+}
+
+// alarmRuleRequest is the body of rule create and update requests
+type alarmRuleRequest struct {
+	Name              *string     `json:"name"`
+	DeviceType        string      `json:"device_type"`
+	Metric            string      `json:"metric"`
+	Comparator        *string     `json:"comparator"`
+	Threshold         *float64    `json:"threshold"`
+	DurationSeconds   *int        `json:"duration_seconds"`
+	Severity          *string     `json:"severity"`
+	SiteID            *uuid.UUID  `json:"site_id"`
+	DeviceID          *uuid.UUID  `json:"device_id"`
+	AutoResolve       *bool       `json:"auto_resolve"`
+	NotifyUserIDs     []uuid.UUID `json:"notify_user_ids"`
+	DefaultAssigneeID *uuid.UUID  `json:"default_assignee_id"`
+	ConversationID    *uuid.UUID  `json:"conversation_id"`
+	IsActive          *bool       `json:"is_active"`
+}
+
+// activeUser reports whether id is an active user
+func (h *Handler) activeUser(id uuid.UUID) bool {
+	var count int64
+	h.service.db.Model(&models.User{}).Where("id = ? AND is_active = ?", id, true).Count(&count)
+	return count > 0
+}
+
+// checkRuleTargets checks the rule's site and device belong to the business and the
+// request's scope, its users exist and, when it posts to chat, that the user saving it
+// is in the conversation, as alarms are posted in their name
+func (h *Handler) checkRuleTargets(r *http.Request, businessID uuid.UUID, rule *models.AlarmRule, userID string) (int, string) {
+	if rule.SiteID != nil {
+		var count int64
+		h.service.db.Model(&models.Site{}).Where("id = ? AND business_vertical_id = ?", *rule.SiteID, businessID).Count(&count)
+		if count == 0 {
+			return http.StatusBadRequest, "site not found in this business"
+		}
+	}
+	if !middleware.SiteInScope(r, rule.SiteID) {
+		return http.StatusForbidden, "site is outside your access scope"
+	}
+	if rule.DeviceID != nil {
+		var device models.SensorDevice
+		if err := h.service.db.Where("business_vertical_id = ?", businessID).First(&device, "id = ?", *rule.DeviceID).Error; err != nil {
+			return http.StatusBadRequest, "device not found in this business"
+		}
+		if string(device.DeviceType) != rule.DeviceType {
+			return http.StatusBadRequest, "device_id is not a " + rule.DeviceType + " device"
+		}
+		if rule.SiteID != nil && (device.SiteID == nil || *device.SiteID != *rule.SiteID) {
+			return http.StatusBadRequest, "device is not at the rule's site"
+		}
+	}
+	for _, id := range rule.NotifyUserIDs {
+		if uid, err := uuid.Parse(id); err != nil || !h.activeUser(uid) {
+			return http.StatusBadRequest, "notify_user_ids: user " + id + " not found"
+		}
+	}
+	if rule.DefaultAssigneeID != nil && !h.activeUser(*rule.DefaultAssigneeID) {
+		return http.StatusBadRequest, "default assignee not found"
+	}
+	if rule.ConversationID != nil && !chat.NewChatService().IsParticipant(*rule.ConversationID, userID) {
+		return http.StatusBadRequest, "you must be a participant of the conversation alarms are posted to"
+	}
+	return 0, ""
+}
+
+// applyRuleRequest copies the fields present in the request onto the rule
+func applyRuleRequest(rule *models.AlarmRule, req alarmRuleRequest) {
+	if req.Name != nil {
+		rule.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Comparator != nil {
+		rule.Comparator = strings.ToLower(strings.TrimSpace(*req.Comparator))
+	}
+	if req.Threshold != nil {
+		rule.Threshold = *req.Threshold
+	}
+	if req.DurationSeconds != nil {
+		rule.DurationSeconds = *req.DurationSeconds
+	}
+	if req.Severity != nil {
+		rule.Severity = strings.ToLower(strings.TrimSpace(*req.Severity))
+	}
+	if req.AutoResolve != nil {
+		rule.AutoResolve = *req.AutoResolve
+	}
+	if req.NotifyUserIDs != nil {
+		rule.NotifyUserIDs = models.StringArray{}
+		for _, id := range req.NotifyUserIDs {
+			rule.NotifyUserIDs = append(rule.NotifyUserIDs, id.String())
+		}
+	}
+	if req.DefaultAssigneeID != nil {
+		rule.DefaultAssigneeID = req.DefaultAssigneeID
+		if *req.DefaultAssigneeID == uuid.Nil {
+			rule.DefaultAssigneeID = nil
+		}
+	}
+	if req.ConversationID != nil {
+		rule.ConversationID = req.ConversationID
+		if *req.ConversationID == uuid.Nil {
+			rule.ConversationID = nil
+		}
+	}
+	if req.IsActive != nil {
+		rule.IsActive = *req.IsActive
+	}
+}
+
+// ListRules lists the business's alarm rules (?device_type=&site_id=&active=true|false).
+// Users limited to some sites see the rules of those sites and the vertical-wide ones.
+// GET /api/v1/business/{businessCode}/alarms/rules
+func (h *Handler) ListRules(w http.ResponseWriter, r *http.Request) {
+	businessID := middleware.GetCurrentBusinessID(r)
+	if businessID == uuid.Nil {
+		http.Error(w, "invalid business identifier", http.StatusBadRequest)
+		return
+	}
+
+	query := h.service.db.Preload("Site").Where("business_vertical_id = ?", businessID)
+	q := r.URL.Query()
+	if t := strings.TrimSpace(q.Get("device_type")); t != "" {
+		query = query.Where("device_type = ?", t)
+	}
+	if siteID, err := uuid.Parse(q.Get("site_id")); err == nil {
+		query = query.Where("site_id = ?", siteID)
+	}
+	if active, err := strconv.ParseBool(q.Get("active")); err == nil {
+		query = query.Where("is_active = ?", active)
+	}
+	if siteIDs, restricted := middleware.GetSiteScope(r); restricted {
+		query = query.Where("site_id IS NULL OR site_id IN ?", siteIDs)
+	}
+
+	var rules []models.AlarmRule
+	if err := query.Order("name").Find(&rules).Error; err != nil {
+		http.Error(w, "failed to list alarm rules", http.StatusInternalServerError)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusOK, map[string]interface{}{"rules": rules, "count": len(rules)})
+}
+
+// CreateRule defines an alarm on a device type's metric: the comparator and threshold a
+// reading breaches, how long the breach must last, the severity, who is told and the
+// chat conversation alarms are posted to
+// POST /api/v1/business/{businessCode}/alarms/rules
+func (h *Handler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	businessID := middleware.GetCurrentBusinessID(r)
+	if businessID == uuid.Nil {
+		http.Error(w, "invalid business identifier", http.StatusBadRequest)
+		return
+	}
+	userID := middleware.GetClaims(r).UserID
+
+	var req alarmRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	rule := models.AlarmRule{
+		BusinessVerticalID: businessID,
+		DeviceType:         strings.TrimSpace(req.DeviceType),
+		Metric:             strings.TrimSpace(req.Metric),
+		Severity:           models.AlarmSeverityMedium,
+		SiteID:             req.SiteID,
+		DeviceID:           req.DeviceID,
+		NotifyUserIDs:      models.StringArray{},
+		IsActive:           true,
+		CreatedBy:          userID,
+		UpdatedBy:          userID,
+	}
+	if req.Threshold == nil {
+		http.Error(w, "threshold is required", http.StatusBadRequest)
+		return
+	}
+	applyRuleRequest(&rule, req)
+	if err := rule.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if status, msg := h.checkRuleTargets(r, businessID, &rule, userID); status != 0 {
+		http.Error(w, msg, status)
+		return
+	}
+
+	if err := h.service.db.Create(&rule).Error; err != nil {
+		http.Error(w, "failed to create alarm rule", http.StatusInternalServerError)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusCreated, map[string]interface{}{"rule": rule})
+}
+
+// UpdateRule changes a rule's condition, severity, routing or whether it is active. Its
+// device type, metric, site and device stay as created. Alarms already raised keep the
+// condition they were raised on.
+// PUT /api/v1/business/{businessCode}/alarms/rules/{id}
+func (h *Handler) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	businessID := middleware.GetCurrentBusinessID(r)
+	if businessID == uuid.Nil {
+		http.Error(w, "invalid business identifier", http.StatusBadRequest)
+		return
+	}
+	var rule models.AlarmRule
+	if err := h.service.db.Where("business_vertical_id = ?", businessID).First(&rule, "id = ?", mux.Vars(r)["id"]).Error; err != nil ||
+		(rule.SiteID != nil && !middleware.SiteInScope(r, rule.SiteID)) {
+		http.Error(w, "alarm rule not found", http.StatusNotFound)
+		return
+	}
+	userID := middleware.GetClaims(r).UserID
+
+	var req alarmRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	applyRuleRequest(&rule, req)
+	if req.ConversationID != nil && rule.ConversationID != nil {
+		// alarms are posted as whoever last routed the rule to chat
+		rule.CreatedBy = userID
+	}
+	rule.UpdatedBy = userID
+	if err := rule.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if status, msg := h.checkRuleTargets(r, businessID, &rule, rule.CreatedBy); status != 0 {
+		http.Error(w, msg, status)
+		return
+	}
+
+	if err := h.service.db.Select("name", "comparator", "threshold", "duration_seconds", "severity", "auto_resolve",
+		"notify_user_ids", "default_assignee_id", "conversation_id", "is_active", "created_by", "updated_by").
+		Updates(&rule).Error; err != nil {
+		http.Error(w, "failed to update alarm rule", http.StatusInternalServerError)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusOK, map[string]interface{}{"rule": rule})
+}
+
+// ListAlarms lists the business's alarms, newest first. ?status= is raised, acknowledged,
+// resolved or open (raised or acknowledged); ?site_id=, ?device_id=, ?rule_id=,
+// ?severity= and ?assignee_id= (or "me") narrow them.
+// GET /api/v1/business/{businessCode}/alarms
+func (h *Handler) ListAlarms(w http.ResponseWriter, r *http.Request) {
+	businessID := middleware.GetCurrentBusinessID(r)
+	if businessID == uuid.Nil {
+		http.Error(w, "invalid business identifier", http.StatusBadRequest)
+		return
+	}
+
+	q := r.URL.Query()
+	query := h.service.db.Preload("Rule").Preload("Device").Preload("Site").Where("business_vertical_id = ?", businessID)
+	switch status := q.Get("status"); status {
+	case "":
+	case "open":
+		query = query.Where("status <> ?", models.AlarmResolved)
+	case models.AlarmRaised, models.AlarmAcknowledged, models.AlarmResolved:
+		query = query.Where("status = ?", status)
+	default:
+		http.Error(w, "status must be raised, acknowledged, resolved or open", http.StatusBadRequest)
+		return
+	}
+	for _, param := range []string{"site_id", "device_id", "rule_id"} {
+		if id, err := uuid.Parse(q.Get(param)); err == nil {
+			query = query.Where(param+" = ?", id)
+		}
+	}
+	if severity := q.Get("severity"); severity != "" {
+		query = query.Where("severity = ?", severity)
+	}
+	if assignee := q.Get("assignee_id"); assignee == "me" {
+		query = query.Where("assignee_id = ?", middleware.GetClaims(r).UserID)
+	} else if id, err := uuid.Parse(assignee); err == nil {
+		query = query.Where("assignee_id = ?", id)
+	}
+	if siteIDs, restricted := middleware.GetSiteScope(r); restricted {
+		query = query.Where("site_id IN ?", siteIDs)
+	}
+	limit := 50
+	if l, err := strconv.Atoi(q.Get("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+
+	var alarms []models.Alarm
+	if err := query.Order("raised_at DESC").Limit(limit).Find(&alarms).Error; err != nil {
+		http.Error(w, "failed to list alarms", http.StatusInternalServerError)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusOK, map[string]interface{}{"alarms": alarms, "count": len(alarms)})
+}
+
+// loadBusinessAlarm loads the alarm in the URL with its rule, if it belongs to the
+// business and the user's sites
+func (h *Handler) loadBusinessAlarm(w http.ResponseWriter, r *http.Request) (*models.Alarm, bool) {
+	businessID := middleware.GetCurrentBusinessID(r)
+	if businessID == uuid.Nil {
+		http.Error(w, "invalid business identifier", http.StatusBadRequest)
+		return nil, false
+	}
+	var alarm models.Alarm
+	if err := h.service.db.Preload("Rule").Where("business_vertical_id = ?", businessID).
+		First(&alarm, "id = ?", mux.Vars(r)["id"]).Error; err != nil || !middleware.SiteInScope(r, alarm.SiteID) {
+		http.Error(w, "alarm not found", http.StatusNotFound)
+		return nil, false
+	}
+	return &alarm, true
+}
+
+// GetAlarm returns an alarm with its device, site and history
+// GET /api/v1/business/{businessCode}/alarms/{id}
+func (h *Handler) GetAlarm(w http.ResponseWriter, r *http.Request) {
+	alarm, ok := h.loadBusinessAlarm(w, r)
+	if !ok {
+		return
+	}
+	if err := h.service.db.Preload("Device").Preload("Site").Preload("Events", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at")
+	}).First(alarm, "id = ?", alarm.ID).Error; err != nil {
+		http.Error(w, "failed to load alarm", http.StatusInternalServerError)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusOK, map[string]interface{}{"alarm": alarm})
+}
+
+// alarmActionRequest is the body of acknowledge, assign and resolve requests
+type alarmActionRequest struct {
+	AssigneeID uuid.UUID `json:"assignee_id"`
+	Note       string    `json:"note"`
+}
+
+func decodeAction(w http.ResponseWriter, r *http.Request) (alarmActionRequest, bool) {
+	var req alarmActionRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return req, false
+		}
+	}
+	req.Note = strings.TrimSpace(req.Note)
+	return req, true
+}
+
+// AcknowledgeAlarm records that the current user has taken a raised alarm on; body
+// {"note": "..."} is optional
+// POST /api/v1/business/{businessCode}/alarms/{id}/acknowledge
+func (h *Handler) AcknowledgeAlarm(w http.ResponseWriter, r *http.Request) {
+	alarm, ok := h.loadBusinessAlarm(w, r)
+	if !ok {
+		return
+	}
+	req, ok := decodeAction(w, r)
+	if !ok {
+		return
+	}
+	if err := h.service.Acknowledge(alarm, middleware.GetClaims(r).UserID, req.Note); err != nil {
+		writeLifecycleErr(w, err)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusOK, map[string]interface{}{"alarm": alarm})
+}
+
+// AssignAlarm hands an unresolved alarm to a user, who is notified;
+// body {"assignee_id": "...", "note": "..."}
+// POST /api/v1/business/{businessCode}/alarms/{id}/assign
+func (h *Handler) AssignAlarm(w http.ResponseWriter, r *http.Request) {
+	alarm, ok := h.loadBusinessAlarm(w, r)
+	if !ok {
+		return
+	}
+	req, ok := decodeAction(w, r)
+	if !ok {
+		return
+	}
+	if req.AssigneeID == uuid.Nil || !h.activeUser(req.AssigneeID) {
+		http.Error(w, "assignee not found", http.StatusBadRequest)
+		return
+	}
+	if err := h.service.Assign(alarm, req.AssigneeID, middleware.GetClaims(r).UserID, req.Note); err != nil {
+		writeLifecycleErr(w, err)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusOK, map[string]interface{}{"alarm": alarm})
+}
+
+// ResolveAlarm closes an alarm, acknowledged or not; body {"note": "..."} records what
+// was done
+// POST /api/v1/business/{businessCode}/alarms/{id}/resolve
+func (h *Handler) ResolveAlarm(w http.ResponseWriter, r *http.Request) {
+	alarm, ok := h.loadBusinessAlarm(w, r)
+	if !ok {
+		return
+	}
+	req, ok := decodeAction(w, r)
+	if !ok {
+		return
+	}
+	if err := h.service.Resolve(alarm, middleware.GetClaims(r).UserID, req.Note); err != nil {
+		writeLifecycleErr(w, err)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusOK, map[string]interface{}{"alarm": alarm})
+}
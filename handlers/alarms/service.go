@@ -0,0 +1,404 @@
+// Package alarms raises alarms on solar and water telemetry that breaches user-defined
+// rules, tracks each from raised through acknowledged to resolved, and routes it to
+// in-app notifications, SMS for critical alarms, and the rule's chat conversation.
+package alarms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/handlers"
+	"p9e.in/ugcl/handlers/chat"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/hooks"
+)
+
+// respondPermission is held by the users told about alarms whose rule names nobody
+const respondPermission = "alarm:respond"
+
+// respondersSQL lists the users holding respondPermission in a vertical through a role
+// that covers the whole vertical or the alarm's site
+const respondersSQL = `SELECT DISTINCT ubr.user_id
+	FROM user_business_roles ubr
+	JOIN business_roles br ON br.id = ubr.business_role_id
+	JOIN business_role_permissions brp ON brp.business_role_id = br.id
+	JOIN permissions p ON p.id = brp.permission_id
+	WHERE br.business_vertical_id = ? AND br.is_active AND ubr.is_active
+	AND (ubr.valid_from IS NULL OR ubr.valid_from <= NOW())
+	AND (ubr.valid_until IS NULL OR ubr.valid_until > NOW())
+	AND (ubr.site_id IS NULL OR ubr.site_id = ?)
+	AND p.name = ?`
+
+// errStore wraps database failures, as opposed to lifecycle steps that are not allowed
+var errStore = errors.New("failed to save the alarm")
+
+// errChanged is returned when another user moved the alarm on first
+var errChanged = errors.New("the alarm was changed meanwhile; reload it and try again")
+
+// alarmPlugin evaluates every telemetry reading against the alarm rules
+type alarmPlugin struct{}
+
+func init() {
+	hooks.RegisterPlugin(alarmPlugin{})
+}
+
+func (alarmPlugin) Name() string { return "telemetry-alarms" }
+
+func (alarmPlugin) Register(r *hooks.Registrar) error {
+	r.OnTelemetryReading(func(ctx context.Context, event hooks.TelemetryReadingEvent) error {
+		return NewService().Evaluate(ctx, event)
+	})
+	return nil
+}
+
+// Service evaluates readings against alarm rules and moves alarms through their lifecycle
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService creates an alarm service
+func NewService() *Service {
+	return &Service{db: config.DB}
+}
+
+// Evaluate checks a reading against the active rules covering its device and metric,
+// raising, updating, clearing or auto-resolving the device's alarms as it does
+func (s *Service) Evaluate(ctx context.Context, event hooks.TelemetryReadingEvent) error {
+	deviceID, err := uuid.Parse(event.DeviceID)
+	if err != nil {
+		return nil
+	}
+	query := s.db.WithContext(ctx).
+		Where("business_vertical_id = ? AND device_type = ? AND metric = ? AND is_active = ?",
+			event.BusinessVerticalID, event.DeviceType, event.Metric, true).
+		Where("device_id IS NULL OR device_id = ?", deviceID)
+	if event.SiteID != nil {
+		query = query.Where("site_id IS NULL OR site_id = ?", *event.SiteID)
+	} else {
+		query = query.Where("site_id IS NULL")
+	}
+	var rules []models.AlarmRule
+	if err := query.Find(&rules).Error; err != nil {
+		return err
+	}
+
+	var firstErr error
+	for i := range rules {
+		if err := s.evaluateRule(ctx, &rules[i], deviceID, event); err != nil {
+			log.Printf("Error evaluating alarm rule %s on device %s: %v", rules[i].ID, deviceID, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// evaluateRule advances the rule's state for the device under a row lock, so readings
+// of one device evaluated concurrently cannot raise the same alarm twice
+func (s *Service) evaluateRule(ctx context.Context, rule *models.AlarmRule, deviceID uuid.UUID, event hooks.TelemetryReadingEvent) error {
+	var raised, resolved *models.Alarm
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		state := models.AlarmRuleState{RuleID: rule.ID, DeviceID: deviceID}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&state).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("rule_id = ? AND device_id = ?", rule.ID, deviceID).First(&state).Error; err != nil {
+			return err
+		}
+		breaching, due, ok := state.Step(*rule, event.Value, event.RecordedAt)
+		if !ok {
+			return nil
+		}
+		if err := tx.Save(&state).Error; err != nil {
+			return err
+		}
+
+		var alarm models.Alarm
+		err := tx.Where("rule_id = ? AND device_id = ? AND status <> ?", rule.ID, deviceID, models.AlarmResolved).First(&alarm).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		open := err == nil
+		value := event.Value
+		now := time.Now()
+
+		switch {
+		case breaching && open:
+			updates := map[string]interface{}{"last_value": value}
+			if alarm.ClearedAt != nil {
+				updates["cleared_at"] = nil
+				if err := addEvent(tx, alarm.ID, models.AlarmEventRecurred, "", &value, ""); err != nil {
+					return err
+				}
+			}
+			return tx.Model(&models.Alarm{}).Where("id = ?", alarm.ID).Updates(updates).Error
+
+		case breaching && due:
+			alarm = models.Alarm{
+				BusinessVerticalID: rule.BusinessVerticalID,
+				RuleID:             rule.ID,
+				DeviceID:           deviceID,
+				SiteID:             event.SiteID,
+				Metric:             rule.Metric,
+				Condition:          rule.Condition(),
+				Severity:           rule.Severity,
+				Status:             models.AlarmRaised,
+				TriggerValue:       value,
+				LastValue:          value,
+				BreachStartedAt:    *state.BreachStartedAt,
+				RaisedAt:           now,
+			}
+			if rule.DefaultAssigneeID != nil {
+				alarm.AssigneeID = rule.DefaultAssigneeID
+				alarm.AssignedAt = &now
+			}
+			if err := tx.Create(&alarm).Error; err != nil {
+				return err
+			}
+			raised = &alarm
+			return addEvent(tx, alarm.ID, models.AlarmEventRaised, "", &value, "")
+
+		case !breaching && open && alarm.ClearedAt == nil:
+			at := event.RecordedAt
+			alarm.ClearedAt = &at
+			alarm.LastValue = value
+			if err := addEvent(tx, alarm.ID, models.AlarmEventCleared, "", &value, ""); err != nil {
+				return err
+			}
+			columns := []string{"cleared_at", "last_value"}
+			if rule.AutoResolve {
+				_ = alarm.Resolve("", "readings back to normal", now)
+				columns = append(columns, "status", "resolved_at", "resolved_by", "resolution_note")
+				if err := addEvent(tx, alarm.ID, models.AlarmEventResolved, "", nil, alarm.ResolutionNote); err != nil {
+					return err
+				}
+				resolved = &alarm
+			}
+			return tx.Model(&alarm).Select(columns).Updates(&alarm).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if raised != nil {
+		s.route(rule, raised)
+	}
+	if resolved != nil {
+		s.postUpdate(rule, resolved, fmt.Sprintf("✅ Resolved: %s is back to normal (%v)", resolved.Metric, resolved.LastValue))
+	}
+	return nil
+}
+
+func addEvent(tx *gorm.DB, alarmID uuid.UUID, action, actorID string, value *float64, note string) error {
+	return tx.Create(&models.AlarmEvent{AlarmID: alarmID, Action: action, ActorID: actorID, Value: value, Note: note}).Error
+}
+
+// describe names the alarm's device and site for messages
+func (s *Service) describe(alarm *models.Alarm) string {
+	var device models.SensorDevice
+	where := alarm.DeviceID.String()
+	if err := s.db.Preload("Site").First(&device, "id = ?", alarm.DeviceID).Error; err == nil {
+		where = device.DeviceKey
+		if device.Name != "" {
+			where = device.Name
+		}
+		if device.Site != nil {
+			where += ", " + device.Site.Name
+		}
+	}
+	return where
+}
+
+// route tells the rule's users and the alarm's assignee about a raised alarm, or the
+// site's responders when the rule names nobody, and posts it to the rule's conversation
+func (s *Service) route(rule *models.AlarmRule, alarm *models.Alarm) {
+	title := fmt.Sprintf("%s alarm: %s", strings.ToUpper(alarm.Severity[:1])+alarm.Severity[1:], rule.Name)
+	body := fmt.Sprintf("%s: %s (value %v since %s)", s.describe(alarm), alarm.Condition, alarm.TriggerValue,
+		alarm.BreachStartedAt.Format("02 Jan 15:04 MST"))
+
+	if rule.ConversationID != nil {
+		message, err := chat.NewChatService().SendMessage(*rule.ConversationID, rule.CreatedBy, models.SendMessageRequest{
+			Content:  fmt.Sprintf("🚨 %s\n\n%s", title, body),
+			Metadata: map[string]interface{}{"alarm_id": alarm.ID.String()},
+		})
+		if err != nil {
+			log.Printf("Error posting alarm %s to conversation %s: %v", alarm.ID, *rule.ConversationID, err)
+		} else {
+			alarm.ConversationID = rule.ConversationID
+			alarm.ChatMessageID = &message.ID
+			if err := s.db.Model(&models.Alarm{}).Where("id = ?", alarm.ID).Updates(map[string]interface{}{
+				"conversation_id": rule.ConversationID,
+				"chat_message_id": message.ID,
+			}).Error; err != nil {
+				log.Printf("Error saving alarm %s chat message: %v", alarm.ID, err)
+			}
+		}
+	}
+
+	recipients := map[string]bool{}
+	for _, id := range rule.NotifyUserIDs {
+		recipients[id] = true
+	}
+	if alarm.AssigneeID != nil {
+		recipients[alarm.AssigneeID.String()] = true
+	}
+	if len(recipients) == 0 {
+		var userIDs []string
+		if err := s.db.Raw(respondersSQL, alarm.BusinessVerticalID, alarm.SiteID, respondPermission).Scan(&userIDs).Error; err != nil {
+			log.Printf("Error loading responders for alarm %s: %v", alarm.ID, err)
+		}
+		for _, id := range userIDs {
+			recipients[id] = true
+		}
+	}
+	for userID := range recipients {
+		s.notify(alarm, userID, title, body)
+	}
+	if alarm.Severity == models.AlarmSeverityCritical {
+		go s.text(alarm, recipients, title, body)
+	}
+}
+
+// text sends a critical alarm by SMS to the recipients who are not on the app
+func (s *Service) text(alarm *models.Alarm, recipients map[string]bool, title, body string) {
+	sms := handlers.NewSMSService()
+	text := fmt.Sprintf("UGCL %s. %s", title, body)
+	for userID := range recipients {
+		if _, err := sms.SendToUserWithoutApp(context.Background(), userID, models.NotificationTypeTelemetryAlarm,
+			models.SMSPurposeAlarm, alarm.ID, text); err != nil {
+			log.Printf("Error texting alarm %s to %s: %v", alarm.ID, userID, err)
+		}
+	}
+}
+
+// notify stores an in-app notification of the alarm for a user
+func (s *Service) notify(alarm *models.Alarm, userID, title, body string) {
+	priority := models.NotificationPriorityNormal
+	switch alarm.Severity {
+	case models.AlarmSeverityLow:
+		priority = models.NotificationPriorityLow
+	case models.AlarmSeverityHigh:
+		priority = models.NotificationPriorityHigh
+	case models.AlarmSeverityCritical:
+		priority = models.NotificationPriorityCritical
+	}
+	now := time.Now()
+	notification := &models.Notification{
+		UserID:             userID,
+		Type:               models.NotificationTypeTelemetryAlarm,
+		Priority:           priority,
+		Title:              title,
+		Body:               body,
+		ActionURL:          "/alarms/" + alarm.ID.String(),
+		BusinessVerticalID: &alarm.BusinessVerticalID,
+		ConversationID:     alarm.ConversationID,
+		MessageID:          alarm.ChatMessageID,
+		Status:             models.NotificationStatusSent,
+		Channel:            models.NotificationChannelInApp,
+		SentAt:             &now,
+		Metadata:           models.JSONMap{"alarm_id": alarm.ID.String(), "rule_id": alarm.RuleID.String(), "device_id": alarm.DeviceID.String()},
+	}
+	if err := s.db.Create(notification).Error; err != nil {
+		log.Printf("Error notifying %s of alarm %s: %v", userID, alarm.ID, err)
+		return
+	}
+	handlers.NewNotificationService().QueueMobilePush(notification, body, map[string]string{"alarm_id": alarm.ID.String()})
+}
+
+// postUpdate replies to the alarm's chat message, if it was posted, as the rule's owner
+func (s *Service) postUpdate(rule *models.AlarmRule, alarm *models.Alarm, text string) {
+	if alarm.ConversationID == nil || alarm.ChatMessageID == nil {
+		return
+	}
+	if _, err := chat.NewChatService().SendMessage(*alarm.ConversationID, rule.CreatedBy, models.SendMessageRequest{
+		Content:   text,
+		ReplyToID: alarm.ChatMessageID,
+		Metadata:  map[string]interface{}{"alarm_id": alarm.ID.String()},
+	}); err != nil {
+		log.Printf("Error posting alarm %s update to chat: %v", alarm.ID, err)
+	}
+}
+
+// transition saves a lifecycle step the alarm has already taken in memory, provided the
+// alarm was still in fromStatus, and records it in the history
+func (s *Service) transition(alarm *models.Alarm, fromStatus string, columns []string, action, actorID, note string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Alarm{}).Where("id = ? AND status = ?", alarm.ID, fromStatus).Select(columns).Updates(alarm)
+		if result.Error != nil {
+			return fmt.Errorf("%w: %v", errStore, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return errChanged
+		}
+		if err := addEvent(tx, alarm.ID, action, actorID, nil, note); err != nil {
+			return fmt.Errorf("%w: %v", errStore, err)
+		}
+		return nil
+	})
+}
+
+// Acknowledge records that the user has taken the alarm on
+func (s *Service) Acknowledge(alarm *models.Alarm, userID, note string) error {
+	from := alarm.Status
+	if err := alarm.Acknowledge(userID, time.Now()); err != nil {
+		return err
+	}
+	if err := s.transition(alarm, from, []string{"status", "acknowledged_at", "acknowledged_by"}, models.AlarmEventAcknowledged, userID, note); err != nil {
+		return err
+	}
+	if alarm.Rule != nil {
+		s.postUpdate(alarm.Rule, alarm, "👀 Acknowledged"+withNote(note))
+	}
+	return nil
+}
+
+// Assign hands the alarm to a user, who is told
+func (s *Service) Assign(alarm *models.Alarm, assigneeID uuid.UUID, userID, note string) error {
+	from := alarm.Status
+	if err := alarm.Assign(assigneeID, userID, time.Now()); err != nil {
+		return err
+	}
+	if err := s.transition(alarm, from, []string{"assignee_id", "assigned_at", "assigned_by"}, models.AlarmEventAssigned, userID, note); err != nil {
+		return err
+	}
+	if alarm.Rule != nil {
+		title := "Alarm assigned to you: " + alarm.Rule.Name
+		s.notify(alarm, assigneeID.String(), title, fmt.Sprintf("%s: %s", s.describe(alarm), alarm.Condition)+withNote(note))
+		s.postUpdate(alarm.Rule, alarm, "👤 Assigned"+withNote(note))
+	}
+	return nil
+}
+
+// Resolve closes the alarm
+func (s *Service) Resolve(alarm *models.Alarm, userID, note string) error {
+	from := alarm.Status
+	if err := alarm.Resolve(userID, note, time.Now()); err != nil {
+		return err
+	}
+	if err := s.transition(alarm, from, []string{"status", "resolved_at", "resolved_by", "resolution_note"}, models.AlarmEventResolved, userID, note); err != nil {
+		return err
+	}
+	if alarm.Rule != nil {
+		s.postUpdate(alarm.Rule, alarm, "✅ Resolved"+withNote(note))
+	}
+	return nil
+}
+
+func withNote(note string) string {
+	if note == "" {
+		return ""
+	}
+	return ": " + note
+}
@@ -0,0 +1,345 @@
+package announcements
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/handlers/httputil"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// Handler serves the announcement endpoints
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates an announcement handler
+func NewHandler() *Handler {
+	return &Handler{service: NewService()}
+}
+
+// targetsInScope reports whether the current user may address the sites. Site-restricted
+// admins must target sites, all within their scope.
+func targetsInScope(r *http.Request, siteIDs []string) bool {
+	if len(siteIDs) == 0 {
+		return middleware.SiteInScope(r, nil)
+	}
+	for _, id := range siteIDs {
+		siteID, err := uuid.Parse(id)
+		if err != nil || !middleware.SiteInScope(r, &siteID) {
+			return false
+		}
+	}
+	return true
+}
+
+// CreateAnnouncement posts an announcement to the business, optionally narrowed to sites
+// and roles; it goes out at starts_at, or now when none is given
+// POST /api/v1/business/{businessCode}/announcements
+func (h *Handler) CreateAnnouncement(w http.ResponseWriter, r *http.Request) {
+	businessID := middleware.GetCurrentBusinessID(r)
+	if businessID == uuid.Nil {
+		http.Error(w, "invalid business identifier", http.StatusBadRequest)
+		return
+	}
+	userID, ok := httputil.ClaimsUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req AnnouncementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !targetsInScope(r, idStrings(req.SiteIDs)) {
+		http.Error(w, "sites are outside your access scope", http.StatusForbidden)
+		return
+	}
+
+	announcement, err := h.service.Create(businessID, userID, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusCreated, map[string]interface{}{"announcement": announcement})
+}
+
+// announcementListItem is an announcement with its state and receipt counts
+type announcementListItem struct {
+	models.Announcement
+	State    string `json:"state"`
+	Total    int    `json:"total"`
+	Seen     int    `json:"seen"`
+	Accepted int    `json:"accepted"`
+}
+
+// ListAnnouncements lists the business's announcements, newest first, with how many
+// recipients have seen and accepted each (?state=scheduled|live|ended|withdrawn)
+// GET /api/v1/business/{businessCode}/announcements
+func (h *Handler) ListAnnouncements(w http.ResponseWriter, r *http.Request) {
+	businessID := middleware.GetCurrentBusinessID(r)
+	if businessID == uuid.Nil {
+		http.Error(w, "invalid business identifier", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	query := h.service.db.Where("business_vertical_id = ?", businessID)
+	switch r.URL.Query().Get("state") {
+	case models.AnnouncementScheduled:
+		query = query.Where("withdrawn_at IS NULL AND starts_at > ?", now)
+	case models.AnnouncementLive:
+		query = query.Where("withdrawn_at IS NULL AND starts_at <= ? AND (ends_at IS NULL OR ends_at > ?)", now, now)
+	case models.AnnouncementEnded:
+		query = query.Where("withdrawn_at IS NULL AND ends_at <= ?", now)
+	case models.AnnouncementWithdrawn:
+		query = query.Where("withdrawn_at IS NOT NULL")
+	}
+	limit := 50
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+
+	var announcements []models.Announcement
+	if err := query.Order("starts_at DESC").Limit(limit).Find(&announcements).Error; err != nil {
+		http.Error(w, "failed to list announcements", http.StatusInternalServerError)
+		return
+	}
+
+	ids := make([]uuid.UUID, 0, len(announcements))
+	for _, a := range announcements {
+		if targetsInScope(r, a.SiteIDs) {
+			ids = append(ids, a.ID)
+		}
+	}
+	var counts []struct {
+		AnnouncementID uuid.UUID
+		Total          int
+		Seen           int
+		Accepted       int
+	}
+	if len(ids) > 0 {
+		if err := h.service.db.Model(&models.AnnouncementReceipt{}).
+			Select("announcement_id, COUNT(*) AS total, COUNT(seen_at) AS seen, COUNT(accepted_at) AS accepted").
+			Where("announcement_id IN ?", ids).
+			Group("announcement_id").
+			Scan(&counts).Error; err != nil {
+			http.Error(w, "failed to count receipts", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	items := make([]announcementListItem, 0, len(ids))
+	for _, a := range announcements {
+		if !targetsInScope(r, a.SiteIDs) {
+			continue
+		}
+		item := announcementListItem{Announcement: a, State: a.State(now)}
+		for _, c := range counts {
+			if c.AnnouncementID == a.ID {
+				item.Total, item.Seen, item.Accepted = c.Total, c.Seen, c.Accepted
+			}
+		}
+		items = append(items, item)
+	}
+	httputil.WriteJSON(w, http.StatusOK, map[string]interface{}{"announcements": items})
+}
+
+// GetAnnouncementDashboard returns the announcement with its receipt summary and who has
+// seen or accepted it (?status=unseen|seen|accepted|pending filters the recipients;
+// pending means not yet accepted)
+// GET /api/v1/business/{businessCode}/announcements/{id}
+func (h *Handler) GetAnnouncementDashboard(w http.ResponseWriter, r *http.Request) {
+	announcement, ok := h.loadBusinessAnnouncement(w, r)
+	if !ok {
+		return
+	}
+
+	var receipts []models.AnnouncementReceipt
+	if err := h.service.db.Where("announcement_id = ?", announcement.ID).
+		Order("seen_at IS NOT NULL, name").
+		Find(&receipts).Error; err != nil {
+		http.Error(w, "failed to load recipients", http.StatusInternalServerError)
+		return
+	}
+
+	var keep func(models.AnnouncementReceipt) bool
+	switch r.URL.Query().Get("status") {
+	case "unseen":
+		keep = func(rec models.AnnouncementReceipt) bool { return rec.SeenAt == nil }
+	case "seen":
+		keep = func(rec models.AnnouncementReceipt) bool { return rec.SeenAt != nil }
+	case "accepted":
+		keep = func(rec models.AnnouncementReceipt) bool { return rec.AcceptedAt != nil }
+	case "pending":
+		keep = func(rec models.AnnouncementReceipt) bool { return rec.AcceptedAt == nil }
+	}
+	filtered := receipts
+	if keep != nil {
+		filtered = receipts[:0:0]
+		for _, rec := range receipts {
+			if keep(rec) {
+				filtered = append(filtered, rec)
+			}
+		}
+	}
+
+	now := time.Now()
+	httputil.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"announcement": announcement,
+		"state":        announcement.State(now),
+		"summary":      Summarize(announcement, receipts),
+		"recipients":   filtered,
+		"generated_at": now,
+	})
+}
+
+// UpdateAnnouncement edits an announcement; targets and start are fixed once it is published
+// PUT /api/v1/business/{businessCode}/announcements/{id}
+func (h *Handler) UpdateAnnouncement(w http.ResponseWriter, r *http.Request) {
+	userID, ok := httputil.ClaimsUserID(w, r)
+	if !ok {
+		return
+	}
+	announcement, ok := h.loadBusinessAnnouncement(w, r)
+	if !ok {
+		return
+	}
+
+	var req AnnouncementRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !targetsInScope(r, idStrings(req.SiteIDs)) {
+		http.Error(w, "sites are outside your access scope", http.StatusForbidden)
+		return
+	}
+
+	if err := h.service.Update(announcement, userID, req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusOK, map[string]interface{}{"announcement": announcement})
+}
+
+// WithdrawAnnouncement takes an announcement down and clears it from recipients' notifications
+// POST /api/v1/business/{businessCode}/announcements/{id}/withdraw
+func (h *Handler) WithdrawAnnouncement(w http.ResponseWriter, r *http.Request) {
+	userID, ok := httputil.ClaimsUserID(w, r)
+	if !ok {
+		return
+	}
+	announcement, ok := h.loadBusinessAnnouncement(w, r)
+	if !ok {
+		return
+	}
+	if announcement.WithdrawnAt != nil {
+		http.Error(w, "announcement is already withdrawn", http.StatusConflict)
+		return
+	}
+
+	if err := h.service.Withdraw(announcement, userID); err != nil {
+		http.Error(w, "failed to withdraw announcement", http.StatusInternalServerError)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusOK, map[string]interface{}{"announcement": announcement})
+}
+
+// myAnnouncement is a live announcement with the current user's receipt
+type myAnnouncement struct {
+	models.Announcement
+	SeenAt     *time.Time `json:"seen_at,omitempty"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+}
+
+// ListMyAnnouncements returns the live announcements addressed to the current user, newest
+// first (?pending=true keeps those not yet seen, or not yet accepted when acceptance is asked)
+// GET /api/v1/announcements
+func (h *Handler) ListMyAnnouncements(w http.ResponseWriter, r *http.Request) {
+	userID, ok := httputil.ClaimsUserID(w, r)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	query := h.service.db.Table("announcements").
+		Select("announcements.*, ar.seen_at, ar.accepted_at").
+		Joins("JOIN announcement_receipts ar ON ar.announcement_id = announcements.id").
+		Where("ar.user_id = ? AND announcements.withdrawn_at IS NULL", userID).
+		Where("announcements.starts_at <= ? AND (announcements.ends_at IS NULL OR announcements.ends_at > ?)", now, now)
+	if pending, _ := strconv.ParseBool(r.URL.Query().Get("pending")); pending {
+		query = query.Where("ar.seen_at IS NULL OR (announcements.requires_acceptance AND ar.accepted_at IS NULL)")
+	}
+
+	var announcements []myAnnouncement
+	if err := query.Order("announcements.starts_at DESC").Scan(&announcements).Error; err != nil {
+		http.Error(w, "failed to load announcements", http.StatusInternalServerError)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusOK, map[string]interface{}{"announcements": announcements})
+}
+
+// MarkAnnouncementSeen records that the current user opened an announcement
+// POST /api/v1/announcements/{id}/seen
+func (h *Handler) MarkAnnouncementSeen(w http.ResponseWriter, r *http.Request) {
+	h.recordReceipt(w, r, h.service.MarkSeen)
+}
+
+// AcceptAnnouncement records the current user's acceptance of an announcement that asks for it
+// POST /api/v1/announcements/{id}/accept
+func (h *Handler) AcceptAnnouncement(w http.ResponseWriter, r *http.Request) {
+	h.recordReceipt(w, r, h.service.Accept)
+}
+
+func (h *Handler) recordReceipt(w http.ResponseWriter, r *http.Request, record func(announcementID, userID uuid.UUID) (*models.AnnouncementReceipt, error)) {
+	userID, ok := httputil.ClaimsUserID(w, r)
+	if !ok {
+		return
+	}
+	announcementID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid announcement ID", http.StatusBadRequest)
+		return
+	}
+
+	receipt, err := record(announcementID, userID)
+	if errors.Is(err, ErrNotRecipient) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusOK, map[string]interface{}{"receipt": receipt})
+}
+
+// loadBusinessAnnouncement loads the {id} announcement of the current business, honouring site scope
+func (h *Handler) loadBusinessAnnouncement(w http.ResponseWriter, r *http.Request) (*models.Announcement, bool) {
+	businessID := middleware.GetCurrentBusinessID(r)
+	if businessID == uuid.Nil {
+		http.Error(w, "invalid business identifier", http.StatusBadRequest)
+		return nil, false
+	}
+	announcementID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid announcement ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	var announcement models.Announcement
+	if err := h.service.db.Where("id = ? AND business_vertical_id = ?", announcementID, businessID).
+		First(&announcement).Error; err != nil || !targetsInScope(r, announcement.SiteIDs) {
+		http.Error(w, "announcement not found", http.StatusNotFound)
+		return nil, false
+	}
+	return &announcement, true
+}
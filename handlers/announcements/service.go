@@ -0,0 +1,420 @@
+// Package announcements lets admins post notices to a business vertical, narrowed to sites
+// and business roles, over a publication window. Each recipient gets a notification and
+// push when the announcement goes live, and their receipt tracks whether they have seen
+// it and, where asked, accepted it.
+package announcements
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/handlers"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/jobrunner"
+)
+
+// ErrNotRecipient is returned when the user is not in an announcement's audience
+var ErrNotRecipient = errors.New("announcement not found")
+
+// AnnouncementRequest is the payload for creating or editing an announcement
+type AnnouncementRequest struct {
+	Title              string                      `json:"title"`
+	Body               string                      `json:"body"`
+	Priority           models.NotificationPriority `json:"priority"`
+	RequiresAcceptance bool                        `json:"requires_acceptance"`
+	SiteIDs            []uuid.UUID                 `json:"site_ids"`
+	RoleIDs            []uuid.UUID                 `json:"role_ids"`
+	StartsAt           *time.Time                  `json:"starts_at"` // default now
+	EndsAt             *time.Time                  `json:"ends_at"`
+}
+
+// Service creates, publishes and withdraws announcements and records receipts
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService creates an announcement service
+func NewService() *Service {
+	return &Service{db: config.DB}
+}
+
+// Create stores the announcement and publishes it straight away when its window has
+// already started; later ones are published by the Publisher.
+func (s *Service) Create(businessID, createdBy uuid.UUID, req AnnouncementRequest) (*models.Announcement, error) {
+	now := time.Now()
+	announcement := &models.Announcement{
+		BusinessVerticalID: businessID,
+		CreatedBy:          createdBy,
+		StartsAt:           now,
+	}
+	if err := s.apply(announcement, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.Create(announcement).Error; err != nil {
+		return nil, fmt.Errorf("failed to create announcement: %w", err)
+	}
+
+	if announcement.State(now) == models.AnnouncementLive {
+		if _, err := s.Publish(announcement.ID); err != nil {
+			log.Printf("⚠️ announcement %s: publishing failed, the publisher will retry: %v", announcement.ID, err)
+		}
+		s.db.First(announcement, "id = ?", announcement.ID)
+	}
+	return announcement, nil
+}
+
+// Update edits an announcement. Once published its audience is fixed, so the targets
+// and start can no longer change.
+func (s *Service) Update(announcement *models.Announcement, updatedBy uuid.UUID, req AnnouncementRequest) error {
+	if announcement.WithdrawnAt != nil {
+		return errors.New("announcement has been withdrawn")
+	}
+	if announcement.PublishedAt != nil {
+		if !sameIDs(announcement.SiteIDs, req.SiteIDs) || !sameIDs(announcement.RoleIDs, req.RoleIDs) ||
+			(req.StartsAt != nil && !req.StartsAt.Equal(announcement.StartsAt)) {
+			return errors.New("targets and start cannot change once an announcement is published")
+		}
+	}
+	if err := s.apply(announcement, req); err != nil {
+		return err
+	}
+	announcement.UpdatedBy = &updatedBy
+	return s.db.Save(announcement).Error
+}
+
+// apply copies the request onto the announcement and checks its targets belong to the
+// announcement's business vertical
+func (s *Service) apply(announcement *models.Announcement, req AnnouncementRequest) error {
+	announcement.Title = req.Title
+	announcement.Body = req.Body
+	announcement.Priority = req.Priority
+	announcement.RequiresAcceptance = req.RequiresAcceptance
+	announcement.SiteIDs = idStrings(req.SiteIDs)
+	announcement.RoleIDs = idStrings(req.RoleIDs)
+	if req.StartsAt != nil {
+		announcement.StartsAt = *req.StartsAt
+	}
+	announcement.EndsAt = req.EndsAt
+	if err := announcement.Validate(); err != nil {
+		return err
+	}
+
+	if n := len(req.SiteIDs); n > 0 {
+		var found int64
+		if err := s.db.Model(&models.Site{}).
+			Where("id IN ? AND business_vertical_id = ?", req.SiteIDs, announcement.BusinessVerticalID).
+			Count(&found).Error; err != nil {
+			return err
+		}
+		if int(found) != n {
+			return errors.New("site_ids must be sites of this business")
+		}
+	}
+	if n := len(req.RoleIDs); n > 0 {
+		var found int64
+		if err := s.db.Model(&models.BusinessRole{}).
+			Where("id IN ? AND business_vertical_id = ?", req.RoleIDs, announcement.BusinessVerticalID).
+			Count(&found).Error; err != nil {
+			return err
+		}
+		if int(found) != n {
+			return errors.New("role_ids must be roles of this business")
+		}
+	}
+	return nil
+}
+
+// audience returns the active users holding a current role in the announcement's vertical,
+// limited to its roles and, when it targets sites, to users assigned to one of them by
+// site access or a site-scoped role
+func (s *Service) audience(announcement *models.Announcement, now time.Time) ([]models.User, error) {
+	grants := func() *gorm.DB {
+		q := s.db.Model(&models.UserBusinessRole{}).Select("user_id").
+			Where("is_active = ? AND (valid_from IS NULL OR valid_from <= ?) AND (valid_until IS NULL OR valid_until > ?)", true, now, now).
+			Where("business_role_id IN (?)", s.db.Model(&models.BusinessRole{}).Select("id").
+				Where("business_vertical_id = ? AND is_active = ?", announcement.BusinessVerticalID, true))
+		if len(announcement.RoleIDs) > 0 {
+			q = q.Where("business_role_id IN ?", []string(announcement.RoleIDs))
+		}
+		return q
+	}
+
+	query := s.db.Select("id", "name").Where("is_active = ? AND id IN (?)", true, grants())
+	if len(announcement.SiteIDs) > 0 {
+		sites := []string(announcement.SiteIDs)
+		siteAccess := s.db.Model(&models.UserSiteAccess{}).Select("user_id").Where("site_id IN ?", sites)
+		query = query.Where("id IN (?) OR id IN (?)", siteAccess, grants().Where("site_id IN ?", sites))
+	}
+
+	var users []models.User
+	err := query.Find(&users).Error
+	return users, err
+}
+
+// Publish resolves the audience of a live announcement, gives each user a receipt and
+// notifies them. It returns the number of recipients, or 0 when the announcement was
+// already published or is no longer live.
+func (s *Service) Publish(announcementID uuid.UUID) (int, error) {
+	now := time.Now()
+	var announcement models.Announcement
+	var receipts []models.AnnouncementReceipt
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("id = ? AND published_at IS NULL", announcementID).
+			First(&announcement).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		if announcement.State(now) != models.AnnouncementLive {
+			return nil
+		}
+
+		users, err := s.audience(&announcement, now)
+		if err != nil {
+			return fmt.Errorf("failed to resolve audience: %w", err)
+		}
+		receipts = make([]models.AnnouncementReceipt, len(users))
+		for i, user := range users {
+			receipts[i] = models.AnnouncementReceipt{ID: uuid.New(), AnnouncementID: announcement.ID, UserID: user.ID, Name: user.Name}
+		}
+		if len(receipts) > 0 {
+			if err := tx.CreateInBatches(receipts, 200).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&announcement).Update("published_at", now).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if len(receipts) > 0 {
+		log.Printf("📣 Announcement %s published to %d users", announcement.ID, len(receipts))
+		go s.notify(&announcement, receipts)
+	}
+	return len(receipts), nil
+}
+
+// notify puts the announcement in each recipient's notification center and queues a push
+func (s *Service) notify(announcement *models.Announcement, receipts []models.AnnouncementReceipt) {
+	ns := handlers.NewNotificationService()
+	now := time.Now()
+	actionURL := "/announcements/" + announcement.ID.String()
+	for _, receipt := range receipts {
+		notification := &models.Notification{
+			UserID:             receipt.UserID.String(),
+			Type:               models.NotificationTypeAnnouncement,
+			Priority:           announcement.Priority,
+			Title:              announcement.Title,
+			Body:               announcement.Body,
+			ActionURL:          actionURL,
+			BusinessVerticalID: &announcement.BusinessVerticalID,
+			Metadata: models.JSONMap{
+				"announcement_id":     announcement.ID.String(),
+				"requires_acceptance": announcement.RequiresAcceptance,
+			},
+			Status:  models.NotificationStatusSent,
+			Channel: models.NotificationChannelInApp,
+			SentAt:  &now,
+		}
+		if err := s.db.Create(notification).Error; err != nil {
+			log.Printf("⚠️ announcement %s: failed to notify %s: %v", announcement.ID, receipt.UserID, err)
+			continue
+		}
+		if err := s.db.Model(&models.AnnouncementReceipt{}).Where("id = ?", receipt.ID).
+			Update("notification_id", notification.ID).Error; err != nil {
+			log.Printf("⚠️ announcement %s: failed to link notification for %s: %v", announcement.ID, receipt.UserID, err)
+		}
+		ns.QueueMobilePush(notification, announcement.Body, map[string]string{"announcement_id": announcement.ID.String()})
+	}
+}
+
+// Withdraw takes the announcement down and archives its notifications
+func (s *Service) Withdraw(announcement *models.Announcement, withdrawnBy uuid.UUID) error {
+	now := time.Now()
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(announcement).Updates(map[string]interface{}{
+			"withdrawn_at": now,
+			"withdrawn_by": withdrawnBy,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Notification{}).
+			Where("id IN (?) AND archived_at IS NULL", tx.Model(&models.AnnouncementReceipt{}).
+				Select("notification_id").Where("announcement_id = ?", announcement.ID)).
+			Updates(map[string]interface{}{
+				"archived_at": now,
+				"read_at":     gorm.Expr("COALESCE(read_at, ?)", now),
+				"status":      models.NotificationStatusArchived,
+			}).Error
+	})
+}
+
+// receipt loads the user's receipt for a live announcement
+func (s *Service) receipt(announcementID, userID uuid.UUID) (*models.Announcement, *models.AnnouncementReceipt, error) {
+	var announcement models.Announcement
+	if err := s.db.First(&announcement, "id = ?", announcementID).Error; err != nil {
+		return nil, nil, ErrNotRecipient
+	}
+	var receipt models.AnnouncementReceipt
+	if err := s.db.Where("announcement_id = ? AND user_id = ?", announcementID, userID).First(&receipt).Error; err != nil {
+		return nil, nil, ErrNotRecipient
+	}
+	if announcement.State(time.Now()) != models.AnnouncementLive {
+		return nil, nil, errors.New("announcement is no longer live")
+	}
+	return &announcement, &receipt, nil
+}
+
+// MarkSeen records that the user opened the announcement and marks its notification read
+func (s *Service) MarkSeen(announcementID, userID uuid.UUID) (*models.AnnouncementReceipt, error) {
+	_, receipt, err := s.receipt(announcementID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if receipt.MarkSeen(time.Now()) {
+		if err := s.record(receipt, map[string]interface{}{"seen_at": receipt.SeenAt}); err != nil {
+			return nil, err
+		}
+	}
+	return receipt, nil
+}
+
+// Accept records the user's acceptance of an announcement that asks for it
+func (s *Service) Accept(announcementID, userID uuid.UUID) (*models.AnnouncementReceipt, error) {
+	announcement, receipt, err := s.receipt(announcementID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := receipt.Accept(announcement, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := s.record(receipt, map[string]interface{}{"seen_at": receipt.SeenAt, "accepted_at": receipt.AcceptedAt}); err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+// record saves a receipt update and marks the announcement's notification read
+func (s *Service) record(receipt *models.AnnouncementReceipt, updates map[string]interface{}) error {
+	if err := s.db.Model(&models.AnnouncementReceipt{}).Where("id = ?", receipt.ID).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to record receipt: %w", err)
+	}
+	if receipt.NotificationID != nil {
+		if err := s.db.Model(&models.Notification{}).
+			Where("id = ? AND read_at IS NULL", *receipt.NotificationID).
+			Updates(map[string]interface{}{"read_at": receipt.SeenAt, "status": models.NotificationStatusRead}).Error; err != nil {
+			log.Printf("⚠️ announcement %s: failed to mark notification read for %s: %v", receipt.AnnouncementID, receipt.UserID, err)
+		}
+	}
+	return nil
+}
+
+// ReceiptSummary counts an announcement's recipients by how far they have got
+type ReceiptSummary struct {
+	Total             int     `json:"total"`
+	Seen              int     `json:"seen"`
+	Unseen            int     `json:"unseen"`
+	Accepted          int     `json:"accepted"`
+	PendingAcceptance int     `json:"pending_acceptance"`
+	SeenRate          float64 `json:"seen_rate"`
+	AcceptedRate      float64 `json:"accepted_rate,omitempty"`
+}
+
+// Summarize counts the receipts of an announcement
+func Summarize(announcement *models.Announcement, receipts []models.AnnouncementReceipt) ReceiptSummary {
+	summary := ReceiptSummary{Total: len(receipts)}
+	for _, r := range receipts {
+		if r.SeenAt != nil {
+			summary.Seen++
+		} else {
+			summary.Unseen++
+		}
+		if r.AcceptedAt != nil {
+			summary.Accepted++
+		} else if announcement.RequiresAcceptance {
+			summary.PendingAcceptance++
+		}
+	}
+	if summary.Total > 0 {
+		summary.SeenRate = float64(summary.Seen) / float64(summary.Total)
+		if announcement.RequiresAcceptance {
+			summary.AcceptedRate = float64(summary.Accepted) / float64(summary.Total)
+		}
+	}
+	return summary
+}
+
+// Publisher publishes scheduled announcements once their window starts
+type Publisher struct {
+	service *Service
+	*jobrunner.Runner
+}
+
+// NewPublisher creates the announcement publisher
+func NewPublisher() *Publisher {
+	p := &Publisher{service: NewService()}
+	p.Runner = jobrunner.New("Announcement publisher", func() {
+		if n, err := p.PublishDue(time.Now()); err != nil {
+			log.Printf("Error publishing announcements: %v", err)
+		} else if n > 0 {
+			log.Printf("Announcement publisher: published %d announcements", n)
+		}
+	})
+	return p
+}
+
+// PublishDue publishes every unpublished announcement whose window is open and returns
+// how many were published.
+func (p *Publisher) PublishDue(now time.Time) (int, error) {
+	var due []uuid.UUID
+	if err := p.service.db.Model(&models.Announcement{}).
+		Where("published_at IS NULL AND withdrawn_at IS NULL AND starts_at <= ? AND (ends_at IS NULL OR ends_at > ?)", now, now).
+		Pluck("id", &due).Error; err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for _, id := range due {
+		if _, err := p.service.Publish(id); err != nil {
+			log.Printf("❌ Failed to publish announcement %s: %v", id, err)
+			continue
+		}
+		published++
+	}
+	return published, nil
+}
+
+func idStrings(ids []uuid.UUID) pq.StringArray {
+	out := make(pq.StringArray, len(ids))
+	for i, id := range ids {
+		out[i] = id.String()
+	}
+	return out
+}
+
+// sameIDs reports whether ids holds the same IDs as stored, in any order
+func sameIDs(stored pq.StringArray, ids []uuid.UUID) bool {
+	if len(stored) != len(ids) {
+		return false
+	}
+	set := make(map[string]bool, len(stored))
+	for _, id := range stored {
+		set[id] = true
+	}
+	for _, id := range ids {
+		if !set[id.String()] {
+			return false
+		}
+	}
+	return true
+}
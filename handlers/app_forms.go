@@ -229,7 +229,7 @@ func GetFormsForVertical(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var matchedVerticals []models.BusinessVertical
-	if err := config.DB.Where("LOWER(code) = LOWER(?)", verticalCode).Find(&matchedVerticals).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("LOWER(code) = LOWER(?)", verticalCode).Find(&matchedVerticals).Error; err != nil {
 		log.Printf("⚠️ Failed to resolve business vertical %s: %v", verticalCode, err)
 	}
 
@@ -266,7 +266,7 @@ func GetFormsForVertical(w http.ResponseWriter, r *http.Request) {
 		filterCondition = filterCondition + " OR accessible_verticals ?| ARRAY[" + strings.Join(arrayPlaceholders, ",") + "]"
 	}
 
-	query := config.DB.
+	query := middleware.ScopedDB(r).
 		Select("id, code, title, description, module_id, route, icon, display_order, required_permission, accessible_verticals, is_active").
 		Preload("Module").
 		Where(filterCondition, filterArgs...).
@@ -295,8 +295,9 @@ func GetFormsForVertical(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Fallback: derive vertical mapping from user's business roles when DB code lookup misses.
+	now := time.Now()
 	for _, ubr := range user.UserBusinessRoles {
-		if !ubr.IsActive || ubr.BusinessRole.ID == uuid.Nil {
+		if !ubr.IsEffectiveAt(now) || ubr.BusinessRole.ID == uuid.Nil {
 			continue
 		}
 
@@ -416,7 +417,7 @@ func GetFormByCode(w http.ResponseWriter, r *http.Request) {
 
 	// Get the form
 	var form models.AppForm
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Preload("Module").
 		Where("code = ? AND is_active = ?", formCode, true).
 		// Where("accessible_verticals @> ?", `["`+verticalCode+`"]`).
@@ -429,14 +430,15 @@ func GetFormByCode(w http.ResponseWriter, r *http.Request) {
 	// Check permission — allow via global role OR any business role in this vertical
 	if !isPublicFormPermission(form.RequiredPermission) {
 		var verticalForForm []models.BusinessVertical
-		_ = config.DB.Where("LOWER(code) = LOWER(?)", verticalCode).Find(&verticalForForm)
+		_ = middleware.ScopedDB(r).Where("LOWER(code) = LOWER(?)", verticalCode).Find(&verticalForForm)
 		requestedVertical := strings.ToLower(strings.TrimSpace(verticalCode))
 		verticalIDSet := make(map[uuid.UUID]struct{}, len(verticalForForm)+len(user.UserBusinessRoles))
 		for _, v := range verticalForForm {
 			verticalIDSet[v.ID] = struct{}{}
 		}
+		now := time.Now()
 		for _, ubr := range user.UserBusinessRoles {
-			if !ubr.IsActive || ubr.BusinessRole.ID == uuid.Nil {
+			if !ubr.IsEffectiveAt(now) || ubr.BusinessRole.ID == uuid.Nil {
 				continue
 			}
 			roleVerticalID := strings.ToLower(strings.TrimSpace(ubr.BusinessRole.BusinessVerticalID.String()))
@@ -535,7 +537,7 @@ func GetAllAppForms(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var forms []models.AppForm
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Preload("Module").
 		Order("module_id ASC, display_order ASC").
 		Find(&forms).Error; err != nil {
@@ -648,22 +650,23 @@ func GetFormLookupOptions(w http.ResponseWriter, r *http.Request) {
 	user := userCtx.User
 
 	var form models.AppForm
-	if err := config.DB.Preload("Module").Where("code = ? AND is_active = ?", formCode, true).First(&form).Error; err != nil {
+	if err := middleware.ScopedDB(r).Preload("Module").Where("code = ? AND is_active = ?", formCode, true).First(&form).Error; err != nil {
 		http.Error(w, "form not found", http.StatusNotFound)
 		return
 	}
 
 	if !isPublicFormPermission(form.RequiredPermission) {
 		var verticals []models.BusinessVertical
-		_ = config.DB.Where("LOWER(code) = LOWER(?)", verticalCode).Find(&verticals)
+		_ = middleware.ScopedDB(r).Where("LOWER(code) = LOWER(?)", verticalCode).Find(&verticals)
 
 		requestedVertical := strings.ToLower(strings.TrimSpace(verticalCode))
 		verticalIDSet := make(map[uuid.UUID]struct{}, len(verticals)+len(user.UserBusinessRoles))
 		for _, v := range verticals {
 			verticalIDSet[v.ID] = struct{}{}
 		}
+		now := time.Now()
 		for _, ubr := range user.UserBusinessRoles {
-			if !ubr.IsActive || ubr.BusinessRole.ID == uuid.Nil {
+			if !ubr.IsEffectiveAt(now) || ubr.BusinessRole.ID == uuid.Nil {
 				continue
 			}
 			roleVerticalID := strings.ToLower(strings.TrimSpace(ubr.BusinessRole.BusinessVerticalID.String()))
@@ -756,14 +759,14 @@ func UpdateFormVerticalAccess(w http.ResponseWriter, r *http.Request) {
 
 	// Get the form
 	var form models.AppForm
-	if err := config.DB.Where("code = ?", formCode).First(&form).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("code = ?", formCode).First(&form).Error; err != nil {
 		http.Error(w, "form not found", http.StatusNotFound)
 		return
 	}
 
 	// Update accessible verticals
 	form.AccessibleVerticals = requestBody.VerticalCodes
-	if err := config.DB.Save(&form).Error; err != nil {
+	if err := middleware.ScopedDB(r).Save(&form).Error; err != nil {
 		log.Printf("❌ Error updating form: %v", err)
 		http.Error(w, "failed to update form", http.StatusInternalServerError)
 		return
@@ -819,7 +822,7 @@ func CreateForm(w http.ResponseWriter, r *http.Request) {
 
 	// Get the module to retrieve its schema name
 	var module models.Module
-	if err := config.DB.First(&module, "id = ?", form.ModuleID).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&module, "id = ?", form.ModuleID).Error; err != nil {
 		log.Printf("❌ Module not found for form %s: %v", form.Code, err)
 		http.Error(w, "module not found", http.StatusBadRequest)
 		return
@@ -830,52 +833,18 @@ func CreateForm(w http.ResponseWriter, r *http.Request) {
 		// Generate table name from form code (sanitized)
 		form.DBTableName = generateTableName(form.Code)
 	}
-
-	tx := config.DB.Begin()
-	if tx.Error != nil {
-		log.Printf("❌ Error starting transaction for form create: %v", tx.Error)
-		http.Error(w, "failed to create form", http.StatusInternalServerError)
+	if _, err := formIdentifier(form.DBTableName); err != nil {
+		http.Error(w, "invalid table_name: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Create form record in database first
-	if err := tx.Create(&form).Error; err != nil {
-		tx.Rollback()
+	tableCreated, err := createAppForm(&form, &module)
+	if err != nil {
 		log.Printf("❌ Error creating form: %v", err)
 		http.Error(w, "failed to create form", http.StatusInternalServerError)
 		return
 	}
 
-	if form.IsActive {
-		if _, err := reports.EnsureReportFormViewForForm(tx, form); err != nil {
-			tx.Rollback()
-			log.Printf("❌ Error creating report view for form %s: %v", form.Code, err)
-			http.Error(w, "failed to create reporting view for form", http.StatusInternalServerError)
-			return
-		}
-	}
-
-	if err := tx.Commit().Error; err != nil {
-		log.Printf("❌ Error committing form create transaction: %v", err)
-		http.Error(w, "failed to create form", http.StatusInternalServerError)
-		return
-	}
-
-	// Create dedicated table for the form in the module's schema
-	var schemaName string
-	var tableCreated bool
-	if module.SchemaName != "" {
-		formTableManager := NewFormTableManager()
-		if err := formTableManager.CreateFormTableInSchema(&form, module.SchemaName); err != nil {
-			log.Printf("⚠️  Warning: Failed to create dedicated table for form %s in schema %s: %v", form.Code, module.SchemaName, err)
-			// Don't fail the request - the form is created, table creation is optional
-		} else {
-			schemaName = module.SchemaName
-			tableCreated = true
-			log.Printf("✅ Created dedicated table %s.%s for form %s", module.SchemaName, form.DBTableName, form.Code)
-		}
-	}
-
 	log.Printf("✅ Created new form: %s", form.Code)
 	invalidateFormsCache()
 
@@ -885,9 +854,9 @@ func CreateForm(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if tableCreated {
-		response["schema_name"] = schemaName
+		response["schema_name"] = module.SchemaName
 		response["table_name"] = form.DBTableName
-		response["full_table_name"] = fmt.Sprintf("%s.%s", schemaName, form.DBTableName)
+		response["full_table_name"] = fmt.Sprintf("%s.%s", module.SchemaName, form.DBTableName)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -895,6 +864,46 @@ func CreateForm(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// createAppForm saves a new form with its reporting view, then creates its dedicated table
+// in the module's schema. It reports whether the table was created; failing to create it
+// does not fail the form, as the table is also created with the first submission.
+func createAppForm(form *models.AppForm, module *models.Module) (bool, error) {
+	tx := config.DB.Begin()
+	if tx.Error != nil {
+		return false, fmt.Errorf("failed to start transaction: %w", tx.Error)
+	}
+
+	// Create form record in database first
+	if err := tx.Create(form).Error; err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if form.IsActive {
+		if _, err := reports.EnsureReportFormViewForForm(tx, *form); err != nil {
+			tx.Rollback()
+			return false, fmt.Errorf("failed to create reporting view: %w", err)
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return false, err
+	}
+
+	// Create dedicated table for the form in the module's schema
+	if module.SchemaName == "" {
+		return false, nil
+	}
+	formTableManager := NewFormTableManager()
+	if err := formTableManager.CreateFormTableInSchema(form, module.SchemaName); err != nil {
+		log.Printf("⚠️  Warning: Failed to create dedicated table for form %s in schema %s: %v", form.Code, module.SchemaName, err)
+		// Don't fail the request - the form is created, table creation is optional
+		return false, nil
+	}
+	log.Printf("✅ Created dedicated table %s.%s for form %s", module.SchemaName, form.DBTableName, form.Code)
+	return true, nil
+}
+
 // generateTableName generates a valid PostgreSQL table name from form code
 func generateTableName(formCode string) string {
 	// Convert to lowercase
@@ -947,12 +956,12 @@ func ToggleFormStatus(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var form models.AppForm
-	if err := config.DB.Where("code = ?", formCode).First(&form).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("code = ?", formCode).First(&form).Error; err != nil {
 		http.Error(w, "form not found", http.StatusNotFound)
 		return
 	}
 
-	tx := config.DB.Begin()
+	tx := middleware.ScopedDB(r).Begin()
 	if tx.Error != nil {
 		log.Printf("❌ Error starting transaction for form status update: %v", tx.Error)
 		http.Error(w, "failed to update form status", http.StatusInternalServerError)
@@ -1010,7 +1019,7 @@ func UpdateForm(w http.ResponseWriter, r *http.Request) {
 
 	// Get existing form
 	var existingForm models.AppForm
-	if err := config.DB.Where("code = ?", formCode).First(&existingForm).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("code = ?", formCode).First(&existingForm).Error; err != nil {
 		http.Error(w, "form not found", http.StatusNotFound)
 		return
 	}
@@ -1032,6 +1041,18 @@ func UpdateForm(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("📝 Updating form: %s, title=%s, description=%s", formCode, updateData.Title, updateData.Description)
 
+	// The dedicated table follows field changes; note its columns before the update
+	tableManager := NewFormTableManager()
+	tableSchemaName := ""
+	var formModule models.Module
+	if err := middleware.ScopedDB(r).First(&formModule, "id = ?", existingForm.ModuleID).Error; err == nil {
+		tableSchemaName = formModule.SchemaName
+	}
+	previousColumns, previousColumnsErr := tableManager.FormColumns(&existingForm)
+	if previousColumnsErr != nil {
+		log.Printf("⚠️  Cannot read current columns of form %s, its table will not be migrated: %v", formCode, previousColumnsErr)
+	}
+
 	// Update allowed fields
 	if updateData.Title != "" {
 		existingForm.Title = updateData.Title
@@ -1073,6 +1094,10 @@ func UpdateForm(w http.ResponseWriter, r *http.Request) {
 		existingForm.AccessibleVerticals = updateData.AccessibleVerticals
 	}
 	if updateData.DBTableName != "" {
+		if _, err := formIdentifier(updateData.DBTableName); err != nil {
+			http.Error(w, "invalid table_name: "+err.Error(), http.StatusBadRequest)
+			return
+		}
 		existingForm.DBTableName = updateData.DBTableName
 	}
 	// Honour explicit is_active when sent in payload
@@ -1080,13 +1105,39 @@ func UpdateForm(w http.ResponseWriter, r *http.Request) {
 		existingForm.IsActive = updateData.IsActive
 	}
 
-	tx := config.DB.Begin()
+	var schemaDiff models.FormSchemaDiff
+	if previousColumnsErr == nil {
+		columns, err := tableManager.FormColumns(&existingForm)
+		if err != nil {
+			http.Error(w, "invalid form fields: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		schemaDiff = models.DiffFormColumns(previousColumns, columns)
+	}
+	allowDestructive := r.URL.Query().Get("allow_destructive") == "true"
+	if schemaDiff.Destructive() && !allowDestructive {
+		writeJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":   errDestructiveFormSchemaChange.Error(),
+			"changes": schemaDiff,
+		})
+		return
+	}
+
+	tx := middleware.ScopedDB(r).Begin()
 	if tx.Error != nil {
 		log.Printf("❌ Error starting transaction for form update: %v", tx.Error)
 		http.Error(w, "failed to update form", http.StatusInternalServerError)
 		return
 	}
 
+	schemaDiff, err := tableManager.MigrateFormTable(tx, &existingForm, tableSchemaName, previousColumns, schemaDiff, allowDestructive, claims.UserID)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("❌ Error migrating table of form %s: %v", formCode, err)
+		http.Error(w, "failed to migrate form table: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	// Save updates
 	if err := tx.Save(&existingForm).Error; err != nil {
 		tx.Rollback()
@@ -1115,8 +1166,9 @@ func UpdateForm(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "form updated successfully",
-		"form":    existingForm.ToDTO(),
+		"message":        "form updated successfully",
+		"form":           existingForm.ToDTO(),
+		"schema_changes": schemaDiff,
 	})
 }
 
@@ -1133,12 +1185,12 @@ func DeleteForm(w http.ResponseWriter, r *http.Request) {
 	formCode := vars["formCode"]
 
 	var form models.AppForm
-	if err := config.DB.Where("code = ?", formCode).First(&form).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("code = ?", formCode).First(&form).Error; err != nil {
 		http.Error(w, "form not found", http.StatusNotFound)
 		return
 	}
 
-	if err := config.DB.Delete(&form).Error; err != nil {
+	if err := middleware.ScopedDB(r).Delete(&form).Error; err != nil {
 		log.Printf("❌ Error deleting form %s: %v", formCode, err)
 		http.Error(w, "failed to delete form", http.StatusInternalServerError)
 		return
@@ -0,0 +1,385 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/telemetry"
+)
+
+const (
+	maxTelemetryBatchEvents = 500
+	maxTelemetryBodyBytes   = 1 << 20
+	// Events older than this are rejected; clients flush their offline buffer well within it.
+	maxTelemetryEventAge = 30 * 24 * time.Hour
+)
+
+// AppTelemetryHandler ingests opt-in mobile feature telemetry and reports module usage.
+type AppTelemetryHandler struct {
+	db *gorm.DB
+}
+
+func NewAppTelemetryHandler() *AppTelemetryHandler {
+	return &AppTelemetryHandler{db: config.DB}
+}
+
+type telemetryEventInput struct {
+	Kind       string                 `json:"kind"`
+	Module     string                 `json:"module"`
+	Name       string                 `json:"name"`
+	Message    string                 `json:"message"`
+	DurationMs int64                  `json:"duration_ms"`
+	OccurredAt *time.Time             `json:"occurred_at"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type telemetryBatchInput struct {
+	OptIn      bool                  `json:"opt_in"`
+	Platform   string                `json:"platform"`
+	AppVersion string                `json:"app_version"`
+	OSVersion  string                `json:"os_version"`
+	SessionID  string                `json:"session_id"`
+	Events     []telemetryEventInput `json:"events"`
+}
+
+// IngestAppTelemetry accepts a batch of feature-usage events and crash breadcrumbs.
+// The batch must carry opt_in=true. Events are anonymized before they are queued and
+// written asynchronously; while ingestion is switched off the batch is acknowledged and discarded.
+func (h *AppTelemetryHandler) IngestAppTelemetry(w http.ResponseWriter, r *http.Request) {
+	collector := telemetry.Default()
+	if collector == nil {
+		writeJSON(w, http.StatusAccepted, map[string]interface{}{"enabled": false, "accepted": 0})
+		return
+	}
+
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var batch telemetryBatchInput
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxTelemetryBodyBytes)).Decode(&batch); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !batch.OptIn {
+		http.Error(w, "telemetry is opt-in: set opt_in to true once the user has consented", http.StatusBadRequest)
+		return
+	}
+	if len(batch.Events) == 0 {
+		http.Error(w, "events are required", http.StatusBadRequest)
+		return
+	}
+	if len(batch.Events) > maxTelemetryBatchEvents {
+		http.Error(w, "too many events in one batch (max 500)", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var verticalID *uuid.UUID
+	if businessContext := middleware.GetUserBusinessContext(r); businessContext != nil {
+		if businessID, ok := businessContext["business_id"].(uuid.UUID); ok && businessID != uuid.Nil {
+			verticalID = &businessID
+		}
+	}
+
+	now := time.Now().UTC()
+	anonymousID := telemetry.AnonymousID(claims.UserID)
+	platform, _ := telemetry.NormalizeIdentifier(batch.Platform, 20)
+	appVersion := telemetry.Truncate(strings.TrimSpace(batch.AppVersion), 30)
+	osVersion := telemetry.Truncate(strings.TrimSpace(batch.OSVersion), 30)
+	sessionID := telemetry.Truncate(strings.TrimSpace(batch.SessionID), 64)
+
+	events := make([]models.AppTelemetryEvent, 0, len(batch.Events))
+	rejected := 0
+	for _, input := range batch.Events {
+		kind := models.AppTelemetryKind(strings.ToLower(strings.TrimSpace(input.Kind)))
+		if kind == "" {
+			kind = models.AppTelemetryKindFeature
+		}
+		module, moduleOK := telemetry.NormalizeIdentifier(input.Module, 50)
+		name, nameOK := telemetry.NormalizeIdentifier(input.Name, 100)
+		if !moduleOK || !nameOK ||
+			(kind != models.AppTelemetryKindFeature && kind != models.AppTelemetryKindBreadcrumb && kind != models.AppTelemetryKindCrash) {
+			rejected++
+			continue
+		}
+
+		occurredAt := now
+		if input.OccurredAt != nil && !input.OccurredAt.IsZero() {
+			occurredAt = input.OccurredAt.UTC()
+			if occurredAt.After(now) {
+				occurredAt = now
+			}
+			if now.Sub(occurredAt) > maxTelemetryEventAge {
+				rejected++
+				continue
+			}
+		}
+
+		event := models.AppTelemetryEvent{
+			AnonymousID:        anonymousID,
+			SessionID:          sessionID,
+			BusinessVerticalID: verticalID,
+			Kind:               kind,
+			Module:             module,
+			Name:               name,
+			Platform:           platform,
+			AppVersion:         appVersion,
+			OSVersion:          osVersion,
+			Properties:         telemetry.SanitizeProperties(input.Properties),
+			OccurredAt:         occurredAt,
+			ReceivedAt:         now,
+		}
+		if input.DurationMs > 0 {
+			event.DurationMs = input.DurationMs
+		}
+		if kind != models.AppTelemetryKindFeature {
+			event.Message = telemetry.TruncateMessage(input.Message)
+		}
+		events = append(events, event)
+	}
+
+	accepted := collector.Add(events)
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"enabled":  true,
+		"accepted": accepted,
+		"rejected": rejected,
+		"dropped":  len(events) - accepted,
+	})
+}
+
+type telemetryModuleUsage struct {
+	Module        string  `json:"module"`
+	FeatureEvents int64   `json:"feature_events"`
+	Users         int64   `json:"users"`
+	Sessions      int64   `json:"sessions"`
+	Crashes       int64   `json:"crashes"`
+	AvgDurationMs float64 `json:"avg_duration_ms"`
+}
+
+type telemetryEventCount struct {
+	Module string `json:"module"`
+	Name   string `json:"name"`
+	Events int64  `json:"events"`
+	Users  int64  `json:"users"`
+}
+
+type telemetryCrashCount struct {
+	Platform   string `json:"platform"`
+	AppVersion string `json:"app_version"`
+	Crashes    int64  `json:"crashes"`
+	Users      int64  `json:"users"`
+}
+
+// GetAppTelemetrySummary aggregates usage by module so product can see which modules
+// field staff actually use. Filters: from, to (default last 30 days), business_vertical_id,
+// platform, app_version, limit (top events, default 50).
+func (h *AppTelemetryHandler) GetAppTelemetrySummary(w http.ResponseWriter, r *http.Request) {
+	scoped, from, to, ok := h.telemetryScope(w, r)
+	if !ok {
+		return
+	}
+
+	var byModule []telemetryModuleUsage
+	if err := scoped().
+		Select(`module,
+			COUNT(*) FILTER (WHERE kind = 'feature') AS feature_events,
+			COUNT(DISTINCT anonymous_id) FILTER (WHERE kind = 'feature') AS users,
+			COUNT(DISTINCT session_id) AS sessions,
+			COUNT(*) FILTER (WHERE kind = 'crash') AS crashes,
+			COALESCE(ROUND(AVG(duration_ms) FILTER (WHERE kind = 'feature' AND duration_ms > 0), 2), 0) AS avg_duration_ms`).
+		Group("module").
+		Order("feature_events DESC").
+		Scan(&byModule).Error; err != nil {
+		http.Error(w, "failed to aggregate telemetry", http.StatusInternalServerError)
+		return
+	}
+
+	limit := 50
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 500 {
+		limit = l
+	}
+	var topEvents []telemetryEventCount
+	if err := scoped().
+		Select("module, name, COUNT(*) AS events, COUNT(DISTINCT anonymous_id) AS users").
+		Where("kind = ?", models.AppTelemetryKindFeature).
+		Group("module, name").
+		Order("events DESC").
+		Limit(limit).
+		Scan(&topEvents).Error; err != nil {
+		http.Error(w, "failed to aggregate telemetry events", http.StatusInternalServerError)
+		return
+	}
+
+	var crashes []telemetryCrashCount
+	if err := scoped().
+		Select("platform, app_version, COUNT(*) AS crashes, COUNT(DISTINCT anonymous_id) AS users").
+		Where("kind = ?", models.AppTelemetryKindCrash).
+		Group("platform, app_version").
+		Order("crashes DESC").
+		Scan(&crashes).Error; err != nil {
+		http.Error(w, "failed to aggregate crashes", http.StatusInternalServerError)
+		return
+	}
+
+	var totals struct {
+		Users    int64 `json:"users"`
+		Sessions int64 `json:"sessions"`
+		Events   int64 `json:"events"`
+	}
+	if err := scoped().
+		Select("COUNT(DISTINCT anonymous_id) AS users, COUNT(DISTINCT session_id) AS sessions, COUNT(*) AS events").
+		Scan(&totals).Error; err != nil {
+		http.Error(w, "failed to aggregate telemetry totals", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"from":               from,
+		"to":                 to,
+		"totals":             totals,
+		"by_module":          byModule,
+		"top_events":         topEvents,
+		"crashes_by_version": crashes,
+		"ingestion_enabled":  telemetry.Default() != nil,
+	}
+	if collector := telemetry.Default(); collector != nil {
+		response["ingestion"] = collector.Stats()
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// ListAppCrashes returns recent crashes, each with the breadcrumbs that preceded it in
+// the same session. Accepts the summary filters plus module and limit (default 50).
+func (h *AppTelemetryHandler) ListAppCrashes(w http.ResponseWriter, r *http.Request) {
+	scoped, _, _, ok := h.telemetryScope(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 50
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+	query := scoped().Where("kind = ?", models.AppTelemetryKindCrash)
+	if module, ok := telemetry.NormalizeIdentifier(r.URL.Query().Get("module"), 50); ok {
+		query = query.Where("module = ?", module)
+	}
+
+	var crashes []models.AppTelemetryEvent
+	if err := query.Order("occurred_at DESC").Limit(limit).Find(&crashes).Error; err != nil {
+		http.Error(w, "failed to load crashes", http.StatusInternalServerError)
+		return
+	}
+
+	sessionIDs := make([]string, 0, len(crashes))
+	for _, crash := range crashes {
+		if crash.SessionID != "" {
+			sessionIDs = append(sessionIDs, crash.SessionID)
+		}
+	}
+	breadcrumbsBySession := make(map[string][]models.AppTelemetryEvent)
+	if len(sessionIDs) > 0 {
+		var breadcrumbs []models.AppTelemetryEvent
+		if err := middleware.WithDataScope(r, h.db).Where("kind = ? AND session_id IN ?", models.AppTelemetryKindBreadcrumb, sessionIDs).
+			Order("occurred_at ASC").Find(&breadcrumbs).Error; err != nil {
+			http.Error(w, "failed to load breadcrumbs", http.StatusInternalServerError)
+			return
+		}
+		for _, crumb := range breadcrumbs {
+			breadcrumbsBySession[crumb.SessionID] = append(breadcrumbsBySession[crumb.SessionID], crumb)
+		}
+	}
+
+	const maxBreadcrumbs = 20
+	type crashWithTrail struct {
+		models.AppTelemetryEvent
+		Breadcrumbs []models.AppTelemetryEvent `json:"breadcrumbs"`
+	}
+	result := make([]crashWithTrail, 0, len(crashes))
+	for _, crash := range crashes {
+		trail := make([]models.AppTelemetryEvent, 0)
+		for _, crumb := range breadcrumbsBySession[crash.SessionID] {
+			if !crumb.OccurredAt.After(crash.OccurredAt) {
+				trail = append(trail, crumb)
+			}
+		}
+		if len(trail) > maxBreadcrumbs {
+			trail = trail[len(trail)-maxBreadcrumbs:]
+		}
+		result = append(result, crashWithTrail{AppTelemetryEvent: crash, Breadcrumbs: trail})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"crashes": result, "count": len(result)})
+}
+
+// telemetryScope parses the shared filters and pins business-scoped callers to their vertical.
+func (h *AppTelemetryHandler) telemetryScope(w http.ResponseWriter, r *http.Request) (func() *gorm.DB, time.Time, time.Time, bool) {
+	q := r.URL.Query()
+	to := time.Now().UTC()
+	from := to.AddDate(0, 0, -30)
+	if raw := strings.TrimSpace(q.Get("from")); raw != "" {
+		parsed, err := parseEvaluationTime(raw)
+		if err != nil {
+			http.Error(w, "invalid from: use RFC3339 or YYYY-MM-DD", http.StatusBadRequest)
+			return nil, from, to, false
+		}
+		from = parsed
+	}
+	if raw := strings.TrimSpace(q.Get("to")); raw != "" {
+		parsed, err := parseEvaluationTime(raw)
+		if err != nil {
+			http.Error(w, "invalid to: use RFC3339 or YYYY-MM-DD", http.StatusBadRequest)
+			return nil, from, to, false
+		}
+		if len(raw) == len("2006-01-02") {
+			parsed = parsed.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		to = parsed
+	}
+
+	var verticalID *uuid.UUID
+	if raw := strings.TrimSpace(q.Get("business_vertical_id")); raw != "" {
+		parsed, err := uuid.Parse(raw)
+		if err != nil {
+			http.Error(w, "invalid business_vertical_id", http.StatusBadRequest)
+			return nil, from, to, false
+		}
+		verticalID = &parsed
+	}
+	if businessContext := middleware.GetUserBusinessContext(r); businessContext != nil {
+		if businessID, ok := businessContext["business_id"].(uuid.UUID); ok && businessID != uuid.Nil {
+			if verticalID != nil && *verticalID != businessID {
+				http.Error(w, "access denied to requested business vertical", http.StatusForbidden)
+				return nil, from, to, false
+			}
+			verticalID = &businessID
+		}
+	}
+	platform, _ := telemetry.NormalizeIdentifier(q.Get("platform"), 20)
+	appVersion := strings.TrimSpace(q.Get("app_version"))
+
+	scoped := func() *gorm.DB {
+		query := middleware.WithDataScope(r, h.db).Model(&models.AppTelemetryEvent{}).Where("occurred_at BETWEEN ? AND ?", from, to)
+		if verticalID != nil {
+			query = query.Where("business_vertical_id = ?", *verticalID)
+		}
+		if platform != "" {
+			query = query.Where("platform = ?", platform)
+		}
+		if appVersion != "" {
+			query = query.Where("app_version = ?", appVersion)
+		}
+		return query
+	}
+	return scoped, from, to, true
+}
@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+type createApprovalDelegationRequest struct {
+	DelegatorID        *uuid.UUID `json:"delegator_id,omitempty"` // super admins only; defaults to the caller
+	DelegateID         uuid.UUID  `json:"delegate_id"`
+	BusinessVerticalID *uuid.UUID `json:"business_vertical_id,omitempty"`
+	Permissions        []string   `json:"permissions,omitempty"`
+	StartsAt           *time.Time `json:"starts_at,omitempty"` // defaults to now
+	EndsAt             time.Time  `json:"ends_at"`
+	Reason             string     `json:"reason"`
+}
+
+// CreateApprovalDelegation lets the caller hand their workflow approvals to another user
+// for a period, e.g. while on leave. Super admins may record a delegation for someone else.
+// POST /api/v1/approval-delegations
+func CreateApprovalDelegation(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	callerID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		http.Error(w, "invalid user ID in claims", http.StatusUnauthorized)
+		return
+	}
+
+	var req createApprovalDelegationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	delegatorID := callerID
+	if req.DelegatorID != nil && *req.DelegatorID != callerID {
+		if !middleware.IsSuperAdmin(callerID) {
+			http.Error(w, "only super admins may delegate on behalf of another user", http.StatusForbidden)
+			return
+		}
+		delegatorID = *req.DelegatorID
+	}
+
+	now := time.Now()
+	delegation := models.ApprovalDelegation{
+		DelegatorID:        delegatorID,
+		DelegateID:         req.DelegateID,
+		BusinessVerticalID: req.BusinessVerticalID,
+		Permissions:        normalizeDelegationPermissions(req.Permissions),
+		StartsAt:           now,
+		EndsAt:             req.EndsAt,
+		Reason:             strings.TrimSpace(req.Reason),
+		Status:             models.ApprovalDelegationActive,
+		CreatedBy:          callerID,
+	}
+	if req.StartsAt != nil {
+		delegation.StartsAt = *req.StartsAt
+	}
+	if err := delegation.Validate(now); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var delegate models.User
+	if err := middleware.ScopedDB(r).Where("id = ? AND is_active = ?", delegation.DelegateID, true).First(&delegate).Error; err != nil {
+		http.Error(w, "delegate not found", http.StatusNotFound)
+		return
+	}
+	var delegator models.User
+	if err := middleware.ScopedDB(r).First(&delegator, "id = ?", delegation.DelegatorID).Error; err != nil {
+		http.Error(w, "delegator not found", http.StatusNotFound)
+		return
+	}
+	if delegation.BusinessVerticalID != nil {
+		var vertical models.BusinessVertical
+		if err := middleware.ScopedDB(r).Where("id = ? AND is_active = ?", *delegation.BusinessVerticalID, true).First(&vertical).Error; err != nil {
+			http.Error(w, "business vertical not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	// One delegate at a time per scope keeps it clear who decides for the delegator
+	overlap := middleware.ScopedDB(r).Model(&models.ApprovalDelegation{}).
+		Where("delegator_id = ? AND status = ? AND starts_at < ? AND ends_at > ?",
+			delegation.DelegatorID, models.ApprovalDelegationActive, delegation.EndsAt, delegation.StartsAt)
+	if delegation.BusinessVerticalID != nil {
+		overlap = overlap.Where("(business_vertical_id IS NULL OR business_vertical_id = ?)", *delegation.BusinessVerticalID)
+	}
+	var overlapping int64
+	if err := overlap.Count(&overlapping).Error; err != nil {
+		http.Error(w, "failed to check existing delegations", http.StatusInternalServerError)
+		return
+	}
+	if overlapping > 0 {
+		http.Error(w, "an active delegation already covers this period; revoke it first", http.StatusConflict)
+		return
+	}
+
+	if err := middleware.ScopedDB(r).Create(&delegation).Error; err != nil {
+		http.Error(w, "failed to create delegation", http.StatusInternalServerError)
+		return
+	}
+
+	period := fmt.Sprintf("%s to %s", delegation.StartsAt.Format("02 Jan 2006 15:04"), delegation.EndsAt.Format("02 Jan 2006 15:04"))
+	notifyDelegationParties(&delegation, "Approval delegation created",
+		fmt.Sprintf("%s will take your workflow approvals from %s.", delegate.Name, period),
+		fmt.Sprintf("%s has delegated their workflow approvals to you from %s.", delegator.Name, period),
+		models.JSONMap{})
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"delegation": delegation})
+}
+
+// ListMyApprovalDelegations returns delegations the caller gave or received
+// (?role=delegator|delegate, ?status=)
+// GET /api/v1/approval-delegations
+func ListMyApprovalDelegations(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := middleware.ScopedDB(r).Preload("Delegator").Preload("Delegate").Preload("BusinessVertical")
+	switch r.URL.Query().Get("role") {
+	case "delegator":
+		query = query.Where("delegator_id = ?", claims.UserID)
+	case "delegate":
+		query = query.Where("delegate_id = ?", claims.UserID)
+	default:
+		query = query.Where("delegator_id = ? OR delegate_id = ?", claims.UserID, claims.UserID)
+	}
+	if status := strings.TrimSpace(r.URL.Query().Get("status")); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var delegations []models.ApprovalDelegation
+	if err := query.Order("starts_at DESC").Limit(100).Find(&delegations).Error; err != nil {
+		http.Error(w, "failed to load delegations", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"delegations": delegations})
+}
+
+// RevokeApprovalDelegation ends a delegation early; the delegator, the delegate and super
+// admins may revoke it.
+// POST /api/v1/approval-delegations/{id}/revoke
+func RevokeApprovalDelegation(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	actorID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		http.Error(w, "invalid user ID in claims", http.StatusUnauthorized)
+		return
+	}
+	delegationID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid delegation ID", http.StatusBadRequest)
+		return
+	}
+
+	var delegation models.ApprovalDelegation
+	if err := middleware.ScopedDB(r).First(&delegation, "id = ?", delegationID).Error; err != nil {
+		http.Error(w, "delegation not found", http.StatusNotFound)
+		return
+	}
+	if delegation.DelegatorID != actorID && delegation.DelegateID != actorID && !middleware.IsSuperAdmin(actorID) {
+		http.Error(w, "delegation not found", http.StatusNotFound)
+		return
+	}
+
+	now := time.Now()
+	result := middleware.ScopedDB(r).Model(&models.ApprovalDelegation{}).
+		Where("id = ? AND status = ?", delegation.ID, models.ApprovalDelegationActive).
+		Updates(map[string]interface{}{
+			"status":     models.ApprovalDelegationRevoked,
+			"revoked_at": now,
+			"revoked_by": actorID,
+		})
+	if result.Error != nil {
+		http.Error(w, "failed to revoke delegation", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "delegation is no longer active", http.StatusConflict)
+		return
+	}
+
+	middleware.ScopedDB(r).First(&delegation, "id = ?", delegation.ID)
+	notifyDelegationParties(&delegation, "Approval delegation revoked",
+		"Your approval delegation has been revoked; approvals come back to you.",
+		"An approval delegation to you has been revoked.",
+		models.JSONMap{"revoked_by": actorID.String()})
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"delegation": delegation})
+}
+
+func normalizeDelegationPermissions(permissions []string) []string {
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, p := range permissions {
+		p = strings.TrimSpace(p)
+		if p == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		normalized = append(normalized, p)
+	}
+	return normalized
+}
+
+// workflowDelegationFor returns the delegation under which the caller may take a
+// transition requiring permission in the vertical, or nil when they hold the permission
+// themselves, none is required or no delegation applies.
+func workflowDelegationFor(actorID string, userPermissions []string, businessID uuid.UUID, permission string) (*models.ApprovalDelegation, error) {
+	if permission == "" || businessID == uuid.Nil || hasWorkflowPermission(userPermissions, permission) {
+		return nil, nil
+	}
+	delegateID, err := uuid.Parse(actorID)
+	if err != nil {
+		return nil, nil
+	}
+	return middleware.FindApprovalDelegation(delegateID, businessID, permission)
+}
+
+// recordDelegatedDecision marks transition as taken on behalf of the delegator and counts
+// the delegation's use, inside the transition's transaction
+func recordDelegatedDecision(tx *gorm.DB, transition *models.WorkflowTransition, delegation *models.ApprovalDelegation) error {
+	if delegation == nil {
+		return nil
+	}
+	transition.OnBehalfOfID = delegation.DelegatorID.String()
+	if delegation.Delegator != nil {
+		transition.OnBehalfOfName = delegation.Delegator.Name
+	}
+	transition.DelegationID = &delegation.ID
+	return middleware.RecordApprovalDelegationUse(tx, delegation.ID)
+}
+
+// notifyDelegatedDecision tells both parties that a decision was taken under the delegation
+func notifyDelegatedDecision(delegation *models.ApprovalDelegation, transition *models.WorkflowTransition, formCode string) {
+	if delegation == nil {
+		return
+	}
+	delegatorName := transition.OnBehalfOfName
+	if delegatorName == "" {
+		delegatorName = transition.OnBehalfOfID
+	}
+	notifyDelegationParties(delegation, "Workflow decision taken on your behalf",
+		fmt.Sprintf("%s took '%s' on %s submission %s on your behalf (%s → %s).",
+			transition.ActorName, transition.Action, formCode, transition.SubmissionID, transition.FromState, transition.ToState),
+		fmt.Sprintf("You took '%s' on %s submission %s on behalf of %s (%s → %s).",
+			transition.Action, formCode, transition.SubmissionID, delegatorName, transition.FromState, transition.ToState),
+		models.JSONMap{
+			"transition_id": transition.ID.String(),
+			"submission_id": transition.SubmissionID.String(),
+			"form_code":     formCode,
+			"action":        transition.Action,
+		})
+}
+
+// notifyDelegationParties sends the delegator and the delegate their in-app notification
+func notifyDelegationParties(delegation *models.ApprovalDelegation, title, delegatorBody, delegateBody string, metadata models.JSONMap) {
+	now := time.Now()
+	for _, recipient := range []struct {
+		userID uuid.UUID
+		body   string
+	}{
+		{delegation.DelegatorID, delegatorBody},
+		{delegation.DelegateID, delegateBody},
+	} {
+		recipientMetadata := models.JSONMap{"delegation_id": delegation.ID.String()}
+		for k, v := range metadata {
+			recipientMetadata[k] = v
+		}
+		notification := &models.Notification{
+			UserID:             recipient.userID.String(),
+			Type:               models.NotificationTypeApprovalDelegation,
+			Priority:           models.NotificationPriorityNormal,
+			Title:              title,
+			Body:               recipient.body,
+			ActionURL:          "/approval-delegations",
+			BusinessVerticalID: delegation.BusinessVerticalID,
+			Status:             models.NotificationStatusSent,
+			Channel:            models.NotificationChannelInApp,
+			SentAt:             &now,
+			Metadata:           recipientMetadata,
+		}
+		if err := config.DB.Create(notification).Error; err != nil {
+			log.Printf("⚠️  Failed to notify %s about approval delegation %s: %v", recipient.userID, delegation.ID, err)
+			continue
+		}
+		NewNotificationService().QueueMobilePush(notification, recipient.body, nil)
+	}
+}
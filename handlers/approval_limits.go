@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// checkApprovalLimit refuses the final approval of a document whose amount is above the
+// approver's limit. Businesses without limits for the document type are not limited, and
+// super admins may approve any amount.
+func checkApprovalLimit(db *gorm.DB, rec procurementRecord, claims *middleware.Claims) error {
+	if claims.Role == "super_admin" {
+		return nil
+	}
+	var limits []models.ApprovalLimit
+	if err := db.Where("business_vertical_id = ? AND document_type = ?", rec.businessID, rec.limitType).
+		Find(&limits).Error; err != nil {
+		return err
+	}
+	if len(limits) == 0 {
+		return nil
+	}
+
+	var roleIDs []uuid.UUID
+	now := time.Now()
+	if err := db.Table("user_business_roles").
+		Where("user_id = ? AND is_active = ? AND (valid_from IS NULL OR valid_from <= ?) AND (valid_until IS NULL OR valid_until > ?)",
+			claims.UserID, true, now, now).
+		Pluck("business_role_id", &roleIDs).Error; err != nil {
+		return err
+	}
+	limit, ok := models.ApprovalLimitFor(limits, roleIDs)
+	if !ok {
+		return apiError{status: http.StatusForbidden, message: fmt.Sprintf("your roles have no %s approval limit; someone with one must approve", rec.limitType)}
+	}
+	if rec.amount > limit {
+		return apiError{status: http.StatusForbidden, message: fmt.Sprintf("%.2f is above your %s approval limit of %.2f; someone with a higher limit must approve", rec.amount, rec.limitType, limit)}
+	}
+	return nil
+}
+
+// ==========================
+// Approval limit handlers
+// ==========================
+
+// ListApprovalLimits lists the business's approval limit matrix. ?document_type= and
+// ?business_role_id= narrow it.
+// GET /api/v1/business/{businessCode}/approval-limits
+func ListApprovalLimits(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load approval limits")
+		return
+	}
+
+	query := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID)
+	q := r.URL.Query()
+	if v := q.Get("document_type"); v != "" {
+		query = query.Where("document_type = ?", v)
+	}
+	if v := q.Get("business_role_id"); v != "" {
+		query = query.Where("business_role_id = ?", v)
+	}
+	var items []models.ApprovalLimit
+	if err := query.Order("document_type, max_amount").Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch approval limits", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// UpsertApprovalLimit sets the largest amount a business role may give final approval to
+// on a type of document (purchase, expense or invoice), replacing any limit it had
+// POST /api/v1/business/{businessCode}/approval-limits
+func UpsertApprovalLimit(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to save approval limit")
+		return
+	}
+
+	var req struct {
+		BusinessRoleID uuid.UUID `json:"business_role_id"`
+		DocumentType   string    `json:"document_type"`
+		MaxAmount      float64   `json:"max_amount"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !models.ValidApprovalLimitDocument(req.DocumentType) {
+		http.Error(w, "document_type must be purchase, expense or invoice", http.StatusBadRequest)
+		return
+	}
+	if req.MaxAmount < 0 {
+		http.Error(w, "max_amount cannot be negative", http.StatusBadRequest)
+		return
+	}
+	var count int64
+	middleware.ScopedDB(r).Model(&models.BusinessRole{}).Where("id = ? AND business_vertical_id = ?", req.BusinessRoleID, businessID).Count(&count)
+	if count == 0 {
+		http.Error(w, "business role not found in this business", http.StatusBadRequest)
+		return
+	}
+
+	userID := middleware.GetClaims(r).UserID
+	limit := models.ApprovalLimit{
+		BusinessVerticalID: businessID,
+		BusinessRoleID:     req.BusinessRoleID,
+		DocumentType:       req.DocumentType,
+		MaxAmount:          req.MaxAmount,
+		CreatedBy:          userID,
+		UpdatedBy:          userID,
+	}
+	if err := middleware.ScopedDB(r).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "business_role_id"}, {Name: "document_type"}},
+		DoUpdates: clause.AssignmentColumns([]string{"max_amount", "updated_by", "updated_at"}),
+	}).Create(&limit).Error; err != nil {
+		http.Error(w, "failed to save approval limit", http.StatusInternalServerError)
+		return
+	}
+	middleware.ScopedDB(r).Where("business_role_id = ? AND document_type = ?", limit.BusinessRoleID, limit.DocumentType).First(&limit)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "approval limit saved", "item": limit})
+}
+
+// DeleteApprovalLimit removes an approval limit. The role can no longer give final
+// approval to the document type unless it was the type's last limit, which lifts limits
+// on it altogether.
+// DELETE /api/v1/business/{businessCode}/approval-limits/{id}
+func DeleteApprovalLimit(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to delete approval limit")
+		return
+	}
+
+	result := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ?", mux.Vars(r)["id"], businessID).Delete(&models.ApprovalLimit{})
+	if result.Error != nil {
+		http.Error(w, "failed to delete approval limit", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "approval limit not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "approval limit deleted"})
+}
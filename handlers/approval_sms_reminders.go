@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/jobrunner"
+)
+
+// approvalReminderWindow is how far past the reminder delay a pending approval is still
+// chased; older ones were either missed while SMS was off or are no longer current
+const approvalReminderWindow = 24 * time.Hour
+
+// ApprovalReminderJob texts field staff who are reached by SMS rather than the app about
+// approvals that have waited on them too long: unread workflow notifications whose record
+// has not moved since, in a state the user holds a permission to act on.
+type ApprovalReminderJob struct {
+	db    *gorm.DB
+	ns    *NotificationService
+	sms   *SMSService
+	after time.Duration
+	*jobrunner.Runner
+}
+
+// NewApprovalReminderJob creates the job; approvals are chased once they have waited after
+func NewApprovalReminderJob(after time.Duration) *ApprovalReminderJob {
+	j := &ApprovalReminderJob{
+		db:    config.DB,
+		ns:    NewNotificationService(),
+		sms:   NewSMSService(),
+		after: after,
+	}
+	j.Runner = jobrunner.New("Approval SMS reminder job", func() {
+		if n, err := j.SendDue(time.Now()); err != nil {
+			log.Printf("Error sending approval SMS reminders: %v", err)
+		} else if n > 0 {
+			log.Printf("Approval SMS reminder job: sent %d reminders", n)
+		}
+	})
+	return j
+}
+
+// pendingApproval is a workflow notification still waiting on its recipient
+type pendingApproval struct {
+	ID         uuid.UUID
+	UserID     string
+	Title      string
+	WorkflowID uuid.UUID
+	ToState    string
+	CreatedAt  time.Time
+}
+
+// SendDue texts a reminder for each approval that has waited past the delay and returns
+// how many went out.
+func (j *ApprovalReminderJob) SendDue(now time.Time) (int, error) {
+	if smsGateway() == nil {
+		return 0, nil
+	}
+
+	var pending []pendingApproval
+	if err := j.db.Raw(`
+		SELECT n.id, n.user_id, n.title, n.workflow_id, t.to_state, n.created_at
+		FROM notifications n
+		JOIN workflow_transitions t ON t.id = n.transition_id
+		WHERE n.type = ? AND n.read_at IS NULL AND n.workflow_id IS NOT NULL
+		  AND n.created_at <= ? AND n.created_at > ?
+		  AND NOT EXISTS (
+			SELECT 1 FROM workflow_transitions later
+			WHERE later.submission_id = t.submission_id AND later.created_at > t.created_at
+		  )
+		  AND NOT EXISTS (
+			SELECT 1 FROM sms_messages s
+			WHERE s.purpose = ? AND s.reference_id = n.id AND s.status <> ?
+		  )
+		ORDER BY n.created_at`,
+		models.NotificationTypeWorkflowTransition, now.Add(-j.after), now.Add(-j.after-approvalReminderWindow),
+		models.SMSPurposeApprovalReminder, models.SMSFailed,
+	).Scan(&pending).Error; err != nil {
+		return 0, err
+	}
+
+	// who may act from each state, per workflow, and who holds each permission
+	statePermissions := map[uuid.UUID]map[string][]string{}
+	holders := map[string]map[string]bool{}
+	canAct := func(p pendingApproval) bool {
+		byState, ok := statePermissions[p.WorkflowID]
+		if !ok {
+			byState = map[string][]string{}
+			var workflow models.WorkflowDefinition
+			if err := j.db.First(&workflow, "id = ?", p.WorkflowID).Error; err == nil {
+				if transitions, err := workflow.ParseTransitions(); err == nil {
+					for _, t := range transitions {
+						if permission := t.RequiredPermissionCode(); permission != "" {
+							byState[t.From] = append(byState[t.From], permission)
+						}
+					}
+				}
+			}
+			statePermissions[p.WorkflowID] = byState
+		}
+		for _, permission := range byState[p.ToState] {
+			users, ok := holders[permission]
+			if !ok {
+				users = map[string]bool{}
+				ids, err := j.ns.getUsersByPermission(permission)
+				if err != nil {
+					log.Printf("⚠️ approval reminders: failed to resolve holders of %s: %v", permission, err)
+				}
+				for _, id := range ids {
+					users[id] = true
+				}
+				holders[permission] = users
+			}
+			if users[p.UserID] {
+				return true
+			}
+		}
+		return false
+	}
+
+	sent := 0
+	for _, p := range pending {
+		if !canAct(p) {
+			continue
+		}
+		hours := int(now.Sub(p.CreatedAt).Hours())
+		text := fmt.Sprintf("UGCL: approval pending for %dh - %s. Please review it in UGCL.", hours, p.Title)
+		ok, err := j.sms.SendToUserWithoutApp(context.Background(), p.UserID, models.NotificationTypeWorkflowTransition,
+			models.SMSPurposeApprovalReminder, p.ID, text)
+		if err != nil {
+			log.Printf("⚠️ approval reminders: failed to text %s about notification %s: %v", p.UserID, p.ID, err)
+			continue
+		}
+		if ok {
+			sent++
+		}
+	}
+	return sent, nil
+}
@@ -0,0 +1,1238 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// assetBusinessID returns the business in the request, or an apiError
+func assetBusinessID(r *http.Request) (uuid.UUID, error) {
+	businessID := middleware.GetCurrentBusinessID(r)
+	if businessID == uuid.Nil {
+		return uuid.Nil, apiError{status: http.StatusBadRequest, message: "business ID required"}
+	}
+	return businessID, nil
+}
+
+// loadAsset loads the asset with the id in the request's key within the business
+func loadAsset(db *gorm.DB, r *http.Request, key string, businessID uuid.UUID) (*models.Asset, error) {
+	id, err := uuid.Parse(mux.Vars(r)[key])
+	if err != nil {
+		return nil, apiError{status: http.StatusBadRequest, message: "invalid asset id"}
+	}
+	var asset models.Asset
+	if err := db.Where("business_vertical_id = ? AND deleted_at IS NULL", businessID).First(&asset, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError{status: http.StatusNotFound, message: "asset not found"}
+		}
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// canMaintainAsset reports whether the user may work on the asset's maintenance: with
+// asset:manage, or the maintenance permission of the asset's domain
+func canMaintainAsset(r *http.Request, asset *models.Asset) bool {
+	permissions := middleware.GetEffectivePermissions(r)
+	return hasWorkflowPermission(permissions, "asset:manage") ||
+		hasWorkflowPermission(permissions, models.AssetMaintenancePermission(asset.Domain))
+}
+
+// requireAssetMaintainer returns an apiError unless the user may maintain the asset
+func requireAssetMaintainer(r *http.Request, asset *models.Asset) error {
+	if !canMaintainAsset(r, asset) {
+		return apiError{status: http.StatusForbidden, message: fmt.Sprintf("insufficient permissions: requires '%s'", models.AssetMaintenancePermission(asset.Domain))}
+	}
+	return nil
+}
+
+// maintainedDomains returns the asset domains whose maintenance the user may see, or nil
+// when they may see all of it
+func maintainedDomains(r *http.Request) []string {
+	permissions := middleware.GetEffectivePermissions(r)
+	if hasWorkflowPermission(permissions, "asset:read") || hasWorkflowPermission(permissions, "asset:manage") {
+		return nil
+	}
+	domains := []string{}
+	for _, domain := range []string{models.AssetDomainSolar, models.AssetDomainWater, models.AssetDomainGeneral} {
+		if hasWorkflowPermission(permissions, models.AssetMaintenancePermission(domain)) {
+			domains = append(domains, domain)
+		}
+	}
+	return domains
+}
+
+// checkBusinessSite returns an apiError unless the site belongs to the business
+func checkBusinessSite(businessID uuid.UUID, siteID *uuid.UUID) error {
+	if siteID == nil {
+		return nil
+	}
+	var count int64
+	config.DB.Model(&models.Site{}).Where("id = ? AND business_vertical_id = ?", *siteID, businessID).Count(&count)
+	if count == 0 {
+		return apiError{status: http.StatusBadRequest, message: "site not found in this business"}
+	}
+	return nil
+}
+
+// ==========================
+// Asset register handlers
+// ==========================
+
+// assetRequest is the body of asset create and update requests
+type assetRequest struct {
+	AssetTag        string     `json:"asset_tag"`
+	Name            string     `json:"name"`
+	Category        string     `json:"category"`
+	Domain          string     `json:"domain"`
+	Manufacturer    string     `json:"manufacturer"`
+	ModelNumber     string     `json:"model_number"`
+	SerialNumber    string     `json:"serial_number"`
+	SiteID          *uuid.UUID `json:"site_id"`
+	LocationNote    string     `json:"location_note"`
+	Latitude        *float64   `json:"latitude"`
+	Longitude       *float64   `json:"longitude"`
+	CustodianID     string     `json:"custodian_id"`
+	CustodianName   string     `json:"custodian_name"`
+	CommissionedOn  *time.Time `json:"commissioned_on"`
+	WarrantyUntil   *time.Time `json:"warranty_until"`
+	PurchaseOrderID *uuid.UUID `json:"purchase_order_id"`
+	Status          string     `json:"status"`
+	Attributes      []string   `json:"attributes"`
+	Remarks         string     `json:"remarks"`
+}
+
+// ListAssets lists the business's assets. ?site_id=, ?category=, ?domain=, ?status=,
+// ?custodian_id= and ?q= (part of the tag, name or serial number) narrow them.
+// GET /api/v1/business/{businessCode}/assets
+func ListAssets(w http.ResponseWriter, r *http.Request) {
+	businessID, err := assetBusinessID(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load assets")
+		return
+	}
+
+	query := middleware.ScopedDB(r).Preload("Site").Where("business_vertical_id = ? AND deleted_at IS NULL", businessID)
+	q := r.URL.Query()
+	for param, column := range map[string]string{
+		"site_id": "site_id", "category": "category", "domain": "domain", "status": "status", "custodian_id": "custodian_id",
+	} {
+		if v := q.Get(param); v != "" {
+			query = query.Where(column+" = ?", v)
+		}
+	}
+	if v := strings.TrimSpace(q.Get("q")); v != "" {
+		like := "%" + v + "%"
+		query = query.Where("(asset_tag ILIKE ? OR name ILIKE ? OR serial_number ILIKE ?)", like, like, like)
+	}
+	var items []models.Asset
+	if err := query.Order("asset_tag").Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch assets", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// CreateAsset registers an asset in the business
+// POST /api/v1/business/{businessCode}/assets
+func CreateAsset(w http.ResponseWriter, r *http.Request) {
+	businessID, err := assetBusinessID(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to create asset")
+		return
+	}
+
+	var req assetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.AssetTag = strings.TrimSpace(req.AssetTag)
+	req.Name = strings.TrimSpace(req.Name)
+	req.Category = strings.ToLower(strings.TrimSpace(req.Category))
+	if req.AssetTag == "" || req.Name == "" || req.Category == "" {
+		http.Error(w, "asset_tag, name and category are required", http.StatusBadRequest)
+		return
+	}
+	if req.Domain == "" {
+		req.Domain = models.AssetDomainGeneral
+	}
+	if !models.ValidAssetDomain(req.Domain) {
+		http.Error(w, "domain must be solar, water or general", http.StatusBadRequest)
+		return
+	}
+	if err := checkBusinessSite(businessID, req.SiteID); err != nil {
+		writeSubcontractErr(w, err, "failed to create asset")
+		return
+	}
+
+	var count int64
+	middleware.ScopedDB(r).Model(&models.Asset{}).
+		Where("business_vertical_id = ? AND asset_tag = ? AND deleted_at IS NULL", businessID, req.AssetTag).Count(&count)
+	if count > 0 {
+		http.Error(w, "an asset with this tag already exists", http.StatusConflict)
+		return
+	}
+	serial := strings.TrimSpace(req.SerialNumber)
+	if serial != "" {
+		middleware.ScopedDB(r).Model(&models.Asset{}).
+			Where("business_vertical_id = ? AND serial_number = ? AND manufacturer = ? AND deleted_at IS NULL", businessID, serial, strings.TrimSpace(req.Manufacturer)).
+			Count(&count)
+		if count > 0 {
+			http.Error(w, "an asset with this manufacturer and serial number already exists", http.StatusConflict)
+			return
+		}
+	}
+
+	asset := models.Asset{
+		BusinessVerticalID: businessID,
+		AssetTag:           req.AssetTag,
+		Name:               req.Name,
+		Category:           req.Category,
+		Domain:             req.Domain,
+		Manufacturer:       strings.TrimSpace(req.Manufacturer),
+		ModelNumber:        strings.TrimSpace(req.ModelNumber),
+		SerialNumber:       serial,
+		SiteID:             req.SiteID,
+		LocationNote:       req.LocationNote,
+		Latitude:           req.Latitude,
+		Longitude:          req.Longitude,
+		CustodianID:        strings.TrimSpace(req.CustodianID),
+		CustodianName:      strings.TrimSpace(req.CustodianName),
+		CommissionedOn:     req.CommissionedOn,
+		WarrantyUntil:      req.WarrantyUntil,
+		PurchaseOrderID:    req.PurchaseOrderID,
+		Status:             models.AssetInService,
+		Attributes:         models.StringArray(req.Attributes),
+		Remarks:            req.Remarks,
+		CreatedBy:          middleware.GetClaims(r).UserID,
+	}
+	if asset.Attributes == nil {
+		asset.Attributes = models.StringArray{}
+	}
+	if err := middleware.ScopedDB(r).Create(&asset).Error; err != nil {
+		http.Error(w, "failed to create asset", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "asset created", "item": asset})
+}
+
+// GetAsset returns an asset with its maintenance schedules, open maintenance tasks,
+// custody history and its availability over the last 30 days
+// GET /api/v1/business/{businessCode}/assets/{id}
+func GetAsset(w http.ResponseWriter, r *http.Request) {
+	businessID, err := assetBusinessID(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load asset")
+		return
+	}
+	asset, err := loadAsset(middleware.ScopedDB(r).Preload("Site"), r, "id", businessID)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load asset")
+		return
+	}
+
+	var schedules []models.MaintenanceSchedule
+	var tasks []models.MaintenanceTask
+	var custody []models.AssetCustodyTransfer
+	var downtimes []models.AssetDowntime
+	now := time.Now()
+	from := now.AddDate(0, 0, -30)
+	for _, q := range []struct {
+		query *gorm.DB
+		dst   interface{}
+	}{
+		{middleware.ScopedDB(r).Where("asset_id = ?", asset.ID).Order("next_due_on"), &schedules},
+		{middleware.ScopedDB(r).Where("asset_id = ? AND status IN ?", asset.ID, []string{models.MaintenanceOpen, models.MaintenanceInProgress}).Order("due_on"), &tasks},
+		{middleware.ScopedDB(r).Where("asset_id = ?", asset.ID).Order("transferred_at DESC"), &custody},
+		{middleware.ScopedDB(r).Where("asset_id = ? AND (ended_at IS NULL OR ended_at > ?)", asset.ID, from).Order("started_at DESC"), &downtimes},
+	} {
+		if err := q.query.Find(q.dst).Error; err != nil {
+			http.Error(w, "failed to load asset details", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"item":                  asset,
+		"maintenance_schedules": schedules,
+		"open_tasks":            tasks,
+		"custody_history":       custody,
+		"recent_downtime":       downtimes,
+		"availability_30d":      models.SummarizeDowntime(downtimes, from, now),
+	})
+}
+
+// UpdateAsset updates an asset's details and status. Its site and custodian change by
+// custody transfer, so the move is recorded.
+// PUT /api/v1/business/{businessCode}/assets/{id}
+func UpdateAsset(w http.ResponseWriter, r *http.Request) {
+	businessID, err := assetBusinessID(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to update asset")
+		return
+	}
+	asset, err := loadAsset(middleware.ScopedDB(r), r, "id", businessID)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load asset")
+		return
+	}
+
+	var req assetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Domain != "" && !models.ValidAssetDomain(req.Domain) {
+		http.Error(w, "domain must be solar, water or general", http.StatusBadRequest)
+		return
+	}
+	switch req.Status {
+	case "", models.AssetInService, models.AssetUnderMaintenance, models.AssetDown, models.AssetRetired:
+	default:
+		http.Error(w, "status must be in_service, under_maintenance, down or retired", http.StatusBadRequest)
+		return
+	}
+
+	updates := map[string]interface{}{"updated_by": middleware.GetClaims(r).UserID}
+	for column, value := range map[string]string{
+		"name": req.Name, "category": strings.ToLower(req.Category), "domain": req.Domain, "manufacturer": req.Manufacturer,
+		"model_number": req.ModelNumber, "serial_number": req.SerialNumber, "location_note": req.LocationNote,
+		"status": req.Status, "remarks": req.Remarks,
+	} {
+		if strings.TrimSpace(value) != "" {
+			updates[column] = strings.TrimSpace(value)
+		}
+	}
+	for column, value := range map[string]interface{}{
+		"latitude": req.Latitude, "longitude": req.Longitude, "commissioned_on": req.CommissionedOn,
+		"warranty_until": req.WarrantyUntil, "purchase_order_id": req.PurchaseOrderID,
+	} {
+		switch v := value.(type) {
+		case *float64:
+			if v != nil {
+				updates[column] = *v
+			}
+		case *time.Time:
+			if v != nil {
+				updates[column] = *v
+			}
+		case *uuid.UUID:
+			if v != nil {
+				updates[column] = *v
+			}
+		}
+	}
+	if req.Attributes != nil {
+		updates["attributes"] = models.StringArray(req.Attributes)
+	}
+
+	if err := middleware.ScopedDB(r).Model(asset).Updates(updates).Error; err != nil {
+		http.Error(w, "failed to update asset", http.StatusInternalServerError)
+		return
+	}
+	middleware.ScopedDB(r).Preload("Site").First(asset, "id = ?", asset.ID)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "asset updated", "item": asset})
+}
+
+// TransferAssetCustody hands an asset to a new custodian, moves it to another site, or
+// both, and records the transfer
+// POST /api/v1/business/{businessCode}/assets/{id}/custody
+func TransferAssetCustody(w http.ResponseWriter, r *http.Request) {
+	businessID, err := assetBusinessID(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to transfer asset")
+		return
+	}
+	asset, err := loadAsset(middleware.ScopedDB(r), r, "id", businessID)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load asset")
+		return
+	}
+
+	var req struct {
+		ToCustodianID   string     `json:"to_custodian_id"`
+		ToCustodianName string     `json:"to_custodian_name"`
+		ToSiteID        *uuid.UUID `json:"to_site_id"`
+		LocationNote    string     `json:"location_note"`
+		Remarks         string     `json:"remarks"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.ToCustodianID = strings.TrimSpace(req.ToCustodianID)
+	if req.ToCustodianID == "" && req.ToSiteID == nil {
+		http.Error(w, "to_custodian_id or to_site_id is required", http.StatusBadRequest)
+		return
+	}
+	if asset.Status == models.AssetRetired {
+		http.Error(w, "a retired asset cannot be transferred", http.StatusConflict)
+		return
+	}
+	if err := checkBusinessSite(businessID, req.ToSiteID); err != nil {
+		writeSubcontractErr(w, err, "failed to transfer asset")
+		return
+	}
+
+	transfer := models.AssetCustodyTransfer{
+		AssetID:         asset.ID,
+		FromCustodianID: asset.CustodianID,
+		ToCustodianID:   asset.CustodianID,
+		ToCustodianName: asset.CustodianName,
+		FromSiteID:      asset.SiteID,
+		ToSiteID:        asset.SiteID,
+		Remarks:         req.Remarks,
+		TransferredBy:   middleware.GetClaims(r).UserID,
+		TransferredAt:   time.Now(),
+	}
+	if req.ToCustodianID != "" {
+		transfer.ToCustodianID = req.ToCustodianID
+		transfer.ToCustodianName = strings.TrimSpace(req.ToCustodianName)
+	}
+	if req.ToSiteID != nil {
+		transfer.ToSiteID = req.ToSiteID
+	}
+	updates := map[string]interface{}{
+		"custodian_id":   transfer.ToCustodianID,
+		"custodian_name": transfer.ToCustodianName,
+		"site_id":        transfer.ToSiteID,
+		"updated_by":     transfer.TransferredBy,
+	}
+	if req.LocationNote != "" {
+		updates["location_note"] = req.LocationNote
+	}
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(asset).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.Create(&transfer).Error
+	})
+	if err != nil {
+		http.Error(w, "failed to transfer asset", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "asset transferred", "transfer": transfer})
+}
+
+// ==========================
+// Maintenance schedule handlers
+// ==========================
+
+// maintenanceScheduleRequest is the body of maintenance schedule create and update requests
+type maintenanceScheduleRequest struct {
+	Title        string     `json:"title"`
+	Description  string     `json:"description"`
+	Checklist    []string   `json:"checklist"`
+	IntervalDays *int       `json:"interval_days"`
+	LeadDays     *int       `json:"lead_days"`
+	NextDueOn    *time.Time `json:"next_due_on"`
+	AssigneeID   *string    `json:"assignee_id"`
+	IsActive     *bool      `json:"is_active"`
+}
+
+// apply copies the request onto the schedule, leaving what it omits as it is
+func (req maintenanceScheduleRequest) apply(s *models.MaintenanceSchedule) {
+	if t := strings.TrimSpace(req.Title); t != "" {
+		s.Title = t
+	}
+	if req.Description != "" {
+		s.Description = req.Description
+	}
+	if req.Checklist != nil {
+		s.Checklist = models.StringArray(req.Checklist)
+	}
+	if req.IntervalDays != nil {
+		s.IntervalDays = *req.IntervalDays
+	}
+	if req.LeadDays != nil {
+		s.LeadDays = *req.LeadDays
+	}
+	if req.NextDueOn != nil {
+		s.NextDueOn = *req.NextDueOn
+	}
+	if req.AssigneeID != nil {
+		s.AssigneeID = strings.TrimSpace(*req.AssigneeID)
+	}
+	if req.IsActive != nil {
+		s.IsActive = *req.IsActive
+	}
+	if s.Checklist == nil {
+		s.Checklist = models.StringArray{}
+	}
+}
+
+// ListMaintenanceSchedules lists an asset's preventive maintenance schedules
+// GET /api/v1/business/{businessCode}/assets/{id}/maintenance-schedules
+func ListMaintenanceSchedules(w http.ResponseWriter, r *http.Request) {
+	businessID, err := assetBusinessID(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load schedules")
+		return
+	}
+	asset, err := loadAsset(middleware.ScopedDB(r), r, "id", businessID)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load asset")
+		return
+	}
+
+	var items []models.MaintenanceSchedule
+	if err := middleware.ScopedDB(r).Where("asset_id = ?", asset.ID).Order("next_due_on").Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch schedules", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// CreateMaintenanceSchedule sets up preventive maintenance on an asset every interval_days
+// from next_due_on. Tasks are raised lead_days ahead of each due date.
+// POST /api/v1/business/{businessCode}/assets/{id}/maintenance-schedules
+func CreateMaintenanceSchedule(w http.ResponseWriter, r *http.Request) {
+	businessID, err := assetBusinessID(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to create schedule")
+		return
+	}
+	asset, err := loadAsset(middleware.ScopedDB(r), r, "id", businessID)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load asset")
+		return
+	}
+
+	var req maintenanceScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	schedule := models.MaintenanceSchedule{
+		AssetID:   asset.ID,
+		IsActive:  true,
+		CreatedBy: middleware.GetClaims(r).UserID,
+	}
+	req.apply(&schedule)
+	if err := schedule.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := middleware.ScopedDB(r).Create(&schedule).Error; err != nil {
+		http.Error(w, "failed to create schedule", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "maintenance schedule created", "item": schedule})
+}
+
+// UpdateMaintenanceSchedule changes a maintenance schedule, or pauses it with is_active
+// PUT /api/v1/business/{businessCode}/maintenance-schedules/{id}
+func UpdateMaintenanceSchedule(w http.ResponseWriter, r *http.Request) {
+	businessID, err := assetBusinessID(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to update schedule")
+		return
+	}
+	var schedule models.MaintenanceSchedule
+	if err := middleware.ScopedDB(r).Joins("JOIN assets ON assets.id = maintenance_schedules.asset_id").
+		Where("maintenance_schedules.id = ? AND assets.business_vertical_id = ?", mux.Vars(r)["id"], businessID).
+		First(&schedule).Error; err != nil {
+		http.Error(w, "schedule not found", http.StatusNotFound)
+		return
+	}
+
+	var req maintenanceScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.apply(&schedule)
+	if err := schedule.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	schedule.UpdatedBy = middleware.GetClaims(r).UserID
+	if err := middleware.ScopedDB(r).Save(&schedule).Error; err != nil {
+		http.Error(w, "failed to update schedule", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "maintenance schedule updated", "item": schedule})
+}
+
+// ==========================
+// Maintenance task handlers
+// ==========================
+
+// ListMaintenanceTasks lists maintenance tasks on the assets the user maintains.
+// ?asset_id=, ?site_id=, ?domain=, ?kind=, ?status=, ?assignee=me and ?overdue=true
+// narrow them.
+// GET /api/v1/business/{businessCode}/maintenance-tasks
+func ListMaintenanceTasks(w http.ResponseWriter, r *http.Request) {
+	businessID, err := assetBusinessID(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load maintenance tasks")
+		return
+	}
+
+	query := middleware.ScopedDB(r).Preload("Asset").
+		Joins("JOIN assets ON assets.id = maintenance_tasks.asset_id").
+		Where("maintenance_tasks.business_vertical_id = ? AND assets.deleted_at IS NULL", businessID)
+	if domains := maintainedDomains(r); domains != nil {
+		query = query.Where("assets.domain IN ?", append(domains, ""))
+	}
+	q := r.URL.Query()
+	for param, column := range map[string]string{
+		"asset_id": "maintenance_tasks.asset_id", "site_id": "assets.site_id", "domain": "assets.domain",
+		"kind": "maintenance_tasks.kind", "status": "maintenance_tasks.status",
+	} {
+		if v := q.Get(param); v != "" {
+			query = query.Where(column+" = ?", v)
+		}
+	}
+	if q.Get("assignee") == "me" {
+		query = query.Where("maintenance_tasks.assignee_id = ?", middleware.GetClaims(r).UserID)
+	}
+	if q.Get("overdue") == "true" {
+		query = query.Where("maintenance_tasks.status IN ? AND maintenance_tasks.due_on < ?",
+			[]string{models.MaintenanceOpen, models.MaintenanceInProgress}, time.Now().UTC().Format("2006-01-02"))
+	}
+	var items []models.MaintenanceTask
+	if err := query.Order("maintenance_tasks.due_on, maintenance_tasks.created_at").Limit(1000).Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch maintenance tasks", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// CreateMaintenanceTask raises a maintenance ticket on an asset: corrective by default,
+// for a breakdown, or preventive outside the asset's schedules. The assignee is told.
+// POST /api/v1/business/{businessCode}/assets/{id}/maintenance-tasks
+func CreateMaintenanceTask(w http.ResponseWriter, r *http.Request) {
+	businessID, err := assetBusinessID(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to create maintenance task")
+		return
+	}
+	asset, err := loadAsset(middleware.ScopedDB(r), r, "id", businessID)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load asset")
+		return
+	}
+	if err := requireAssetMaintainer(r, asset); err != nil {
+		writeSubcontractErr(w, err, "failed to create maintenance task")
+		return
+	}
+
+	var req struct {
+		Kind        string     `json:"kind"`
+		Title       string     `json:"title"`
+		Description string     `json:"description"`
+		Checklist   []string   `json:"checklist"`
+		DueOn       *time.Time `json:"due_on"`
+		AssigneeID  string     `json:"assignee_id"`
+		DowntimeID  *uuid.UUID `json:"downtime_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Title) == "" {
+		http.Error(w, "title is required", http.StatusBadRequest)
+		return
+	}
+	switch req.Kind {
+	case "":
+		req.Kind = models.MaintenanceCorrective
+	case models.MaintenanceCorrective, models.MaintenancePreventive:
+	default:
+		http.Error(w, "kind must be corrective or preventive", http.StatusBadRequest)
+		return
+	}
+	if req.DowntimeID != nil {
+		var count int64
+		middleware.ScopedDB(r).Model(&models.AssetDowntime{}).Where("id = ? AND asset_id = ?", *req.DowntimeID, asset.ID).Count(&count)
+		if count == 0 {
+			http.Error(w, "downtime not found for this asset", http.StatusBadRequest)
+			return
+		}
+	}
+
+	task := newCorrectiveTask(asset, strings.TrimSpace(req.Title), middleware.GetClaims(r).UserID)
+	task.Kind = req.Kind
+	task.Description = req.Description
+	task.DowntimeID = req.DowntimeID
+	if req.Checklist != nil {
+		task.Checklist = models.StringArray(req.Checklist)
+	}
+	if req.DueOn != nil {
+		task.DueOn = *req.DueOn
+	}
+	if a := strings.TrimSpace(req.AssigneeID); a != "" {
+		task.AssigneeID = a
+	}
+	if err := middleware.ScopedDB(r).Create(&task).Error; err != nil {
+		http.Error(w, "failed to create maintenance task", http.StatusInternalServerError)
+		return
+	}
+	notifyMaintenanceAssignee(&task, asset)
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "maintenance task created", "item": task})
+}
+
+// newCorrectiveTask returns an open corrective task on the asset, due today and assigned
+// to its custodian
+func newCorrectiveTask(asset *models.Asset, title, createdBy string) models.MaintenanceTask {
+	return models.MaintenanceTask{
+		BusinessVerticalID:  asset.BusinessVerticalID,
+		AssetID:             asset.ID,
+		Kind:                models.MaintenanceCorrective,
+		Title:               title,
+		Checklist:           models.StringArray{},
+		EvidenceDocumentIDs: models.StringArray{},
+		DueOn:               time.Now().UTC(),
+		AssigneeID:          asset.CustodianID,
+		Status:              models.MaintenanceOpen,
+		CreatedBy:           createdBy,
+	}
+}
+
+// TakeMaintenanceTaskAction assigns, starts, completes or cancels a maintenance task.
+// Assigning tells the new assignee. Completing needs photos of the finished work as DMS
+// documents in evidence_document_ids. Completing or cancelling preventive maintenance
+// moves its schedule on to the next due date: counted from when the work was done, or
+// from the skipped due date.
+// POST /api/v1/business/{businessCode}/maintenance-tasks/{id}/{action}
+func TakeMaintenanceTaskAction(w http.ResponseWriter, r *http.Request) {
+	businessID, err := assetBusinessID(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to update maintenance task")
+		return
+	}
+	var task models.MaintenanceTask
+	if err := middleware.ScopedDB(r).Preload("Asset").Where("business_vertical_id = ?", businessID).
+		First(&task, "id = ?", mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "maintenance task not found", http.StatusNotFound)
+		return
+	}
+	if err := requireAssetMaintainer(r, task.Asset); err != nil {
+		writeSubcontractErr(w, err, "failed to update maintenance task")
+		return
+	}
+
+	var req struct {
+		Findings            string     `json:"findings"`
+		CompletedAt         *time.Time `json:"completed_at"`
+		AssigneeID          string     `json:"assignee_id"`
+		EvidenceDocumentIDs []string   `json:"evidence_document_ids"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	action := mux.Vars(r)["action"]
+	userID := middleware.GetClaims(r).UserID
+	now := time.Now()
+	from := []string{models.MaintenanceOpen, models.MaintenanceInProgress}
+	updates := map[string]interface{}{}
+	switch action {
+	case "assign":
+		assignee, err := uuid.Parse(strings.TrimSpace(req.AssigneeID))
+		if err != nil {
+			http.Error(w, "assignee_id must be a user ID", http.StatusBadRequest)
+			return
+		}
+		var count int64
+		middleware.ScopedDB(r).Model(&models.User{}).Where("id = ? AND is_active = ?", assignee, true).Count(&count)
+		if count == 0 {
+			http.Error(w, "assignee not found", http.StatusBadRequest)
+			return
+		}
+		updates["assignee_id"] = assignee.String()
+		updates["assigned_by"] = userID
+		updates["assigned_at"] = now
+	case "start":
+		from = []string{models.MaintenanceOpen}
+		updates["status"] = models.MaintenanceInProgress
+		updates["started_at"] = now
+	case "complete":
+		completedAt := now
+		if req.CompletedAt != nil {
+			completedAt = *req.CompletedAt
+		}
+		if completedAt.After(now) {
+			http.Error(w, "completed_at cannot be in the future", http.StatusBadRequest)
+			return
+		}
+		evidence, err := maintenanceEvidence(businessID, req.EvidenceDocumentIDs)
+		if err != nil {
+			writeSubcontractErr(w, err, "failed to update maintenance task")
+			return
+		}
+		updates["status"] = models.MaintenanceDone
+		updates["evidence_document_ids"] = evidence
+		updates["completed_at"] = completedAt
+		updates["completed_by"] = userID
+		updates["findings"] = req.Findings
+		if task.StartedAt == nil {
+			updates["started_at"] = completedAt
+		}
+	case "cancel":
+		if strings.TrimSpace(req.Findings) == "" {
+			http.Error(w, "findings are required to say why the task is cancelled", http.StatusBadRequest)
+			return
+		}
+		updates["status"] = models.MaintenanceCancelled
+		updates["findings"] = req.Findings
+	default:
+		http.Error(w, "action must be assign, start, complete or cancel", http.StatusBadRequest)
+		return
+	}
+
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.MaintenanceTask{}).Where("id = ? AND status IN ?", task.ID, from).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return apiError{status: http.StatusConflict, message: fmt.Sprintf("a %s task cannot be %s", task.Status, strings.TrimSuffix(action, "e")+"ed")}
+		}
+		if task.ScheduleID == nil || action == "start" || action == "assign" {
+			return nil
+		}
+		var schedule models.MaintenanceSchedule
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&schedule, "id = ?", *task.ScheduleID).Error; err != nil {
+			return err
+		}
+		// Only the task for the schedule's current due date moves it on
+		if !schedule.NextDueOn.Equal(task.DueOn) {
+			return nil
+		}
+		if action == "complete" {
+			schedule.Advance(updates["completed_at"].(time.Time))
+		} else {
+			lastDone := schedule.LastDoneOn
+			schedule.Advance(task.DueOn)
+			schedule.LastDoneOn = lastDone
+		}
+		return tx.Model(&schedule).Select("next_due_on", "last_done_on").Updates(&schedule).Error
+	})
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to update maintenance task")
+		return
+	}
+	middleware.ScopedDB(r).First(&task, "id = ?", task.ID)
+	if action == "assign" {
+		notifyMaintenanceAssignee(&task, task.Asset)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "maintenance task " + task.Status, "item": task})
+}
+
+// maintenanceEvidence checks closure evidence: at least one photo, each an image document
+// uploaded to the business's DMS
+func maintenanceEvidence(businessID uuid.UUID, documentIDs []string) (models.StringArray, error) {
+	if len(documentIDs) == 0 {
+		return nil, apiError{status: http.StatusBadRequest, message: "evidence_document_ids must include at least one photo of the finished work"}
+	}
+	ids := make([]uuid.UUID, 0, len(documentIDs))
+	evidence := models.StringArray{}
+	for _, v := range documentIDs {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return nil, apiError{status: http.StatusBadRequest, message: fmt.Sprintf("invalid evidence document id %q", v)}
+		}
+		ids = append(ids, id)
+		evidence = append(evidence, id.String())
+	}
+	var count int64
+	config.DB.Model(&models.Document{}).
+		Where("id IN ? AND business_vertical_id = ? AND file_type LIKE 'image/%' AND status <> ?", ids, businessID, models.DocumentStatusDeleted).
+		Count(&count)
+	if int(count) != len(ids) {
+		return nil, apiError{status: http.StatusBadRequest, message: "evidence must be photos uploaded to this business's documents"}
+	}
+	return evidence, nil
+}
+
+// notifyMaintenanceAssignee tells a task's assignee it is theirs
+func notifyMaintenanceAssignee(task *models.MaintenanceTask, asset *models.Asset) {
+	if task.AssigneeID == "" || asset == nil {
+		return
+	}
+	now := time.Now()
+	notification := &models.Notification{
+		UserID:             task.AssigneeID,
+		Type:               models.NotificationTypeTaskAssigned,
+		Priority:           models.NotificationPriorityNormal,
+		Title:              "Maintenance assigned",
+		Body:               fmt.Sprintf("%s on %s (%s), due %s.", task.Title, asset.Name, asset.AssetTag, task.DueOn.Format("02 Jan 2006")),
+		ActionURL:          fmt.Sprintf("/maintenance-tasks/%s", task.ID),
+		BusinessVerticalID: &task.BusinessVerticalID,
+		Status:             models.NotificationStatusSent,
+		Channel:            models.NotificationChannelInApp,
+		SentAt:             &now,
+		Metadata:           models.JSONMap{"maintenance_task_id": task.ID.String(), "asset_id": asset.ID.String()},
+	}
+	if err := config.DB.Create(notification).Error; err != nil {
+		log.Printf("Error notifying %s of maintenance task %s: %v", task.AssigneeID, task.ID, err)
+	}
+}
+
+// ==========================
+// Downtime handlers
+// ==========================
+
+// ReportAssetDowntime records an asset going out of service and marks it down. With
+// raise_task, a corrective maintenance task is raised against the downtime.
+// POST /api/v1/business/{businessCode}/assets/{id}/downtime
+func ReportAssetDowntime(w http.ResponseWriter, r *http.Request) {
+	businessID, err := assetBusinessID(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to record downtime")
+		return
+	}
+	asset, err := loadAsset(middleware.ScopedDB(r), r, "id", businessID)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load asset")
+		return
+	}
+	if err := requireAssetMaintainer(r, asset); err != nil {
+		writeSubcontractErr(w, err, "failed to record downtime")
+		return
+	}
+
+	var req struct {
+		StartedAt   *time.Time `json:"started_at"`
+		Cause       string     `json:"cause"`
+		Description string     `json:"description"`
+		RaiseTask   bool       `json:"raise_task"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	switch req.Cause {
+	case "breakdown", "preventive", "power_failure", "other":
+	default:
+		http.Error(w, "cause must be breakdown, preventive, power_failure or other", http.StatusBadRequest)
+		return
+	}
+	now := time.Now()
+	userID := middleware.GetClaims(r).UserID
+	downtime := models.AssetDowntime{
+		BusinessVerticalID: businessID,
+		AssetID:            asset.ID,
+		StartedAt:          now,
+		Cause:              req.Cause,
+		Description:        req.Description,
+		ReportedBy:         userID,
+	}
+	if req.StartedAt != nil {
+		if req.StartedAt.After(now) {
+			http.Error(w, "started_at cannot be in the future", http.StatusBadRequest)
+			return
+		}
+		downtime.StartedAt = *req.StartedAt
+	}
+
+	var task *models.MaintenanceTask
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		// Lock the asset so it cannot be reported down twice at once
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&models.Asset{}, "id = ?", asset.ID).Error; err != nil {
+			return err
+		}
+		var open int64
+		tx.Model(&models.AssetDowntime{}).Where("asset_id = ? AND ended_at IS NULL", asset.ID).Count(&open)
+		if open > 0 {
+			return apiError{status: http.StatusConflict, message: "the asset is already down"}
+		}
+		if err := tx.Create(&downtime).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(asset).Update("status", models.AssetDown).Error; err != nil {
+			return err
+		}
+		if !req.RaiseTask {
+			return nil
+		}
+		t := newCorrectiveTask(asset, "Restore "+asset.Name+" ("+req.Cause+")", userID)
+		t.Description = req.Description
+		t.DowntimeID = &downtime.ID
+		task = &t
+		return tx.Create(task).Error
+	})
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to record downtime")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "downtime recorded", "item": downtime, "task": task})
+}
+
+// CloseAssetDowntime records an asset back in service
+// POST /api/v1/business/{businessCode}/asset-downtime/{id}/close
+func CloseAssetDowntime(w http.ResponseWriter, r *http.Request) {
+	businessID, err := assetBusinessID(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to close downtime")
+		return
+	}
+	var downtime models.AssetDowntime
+	if err := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID).First(&downtime, "id = ?", mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "downtime not found", http.StatusNotFound)
+		return
+	}
+	var asset models.Asset
+	if err := middleware.ScopedDB(r).First(&asset, "id = ?", downtime.AssetID).Error; err != nil {
+		http.Error(w, "failed to load asset", http.StatusInternalServerError)
+		return
+	}
+	if err := requireAssetMaintainer(r, &asset); err != nil {
+		writeSubcontractErr(w, err, "failed to close downtime")
+		return
+	}
+
+	var req struct {
+		EndedAt    *time.Time `json:"ended_at"`
+		Resolution string     `json:"resolution"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Resolution) == "" {
+		http.Error(w, "resolution is required", http.StatusBadRequest)
+		return
+	}
+	endedAt := time.Now()
+	if req.EndedAt != nil {
+		if req.EndedAt.After(endedAt) || req.EndedAt.Before(downtime.StartedAt) {
+			http.Error(w, "ended_at must fall between the start of the downtime and now", http.StatusBadRequest)
+			return
+		}
+		endedAt = *req.EndedAt
+	}
+
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.AssetDowntime{}).Where("id = ? AND ended_at IS NULL", downtime.ID).Updates(map[string]interface{}{
+			"ended_at":    endedAt,
+			"resolution":  req.Resolution,
+			"resolved_by": middleware.GetClaims(r).UserID,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return apiError{status: http.StatusConflict, message: "the downtime is already closed"}
+		}
+		return tx.Model(&models.Asset{}).Where("id = ? AND status = ?", asset.ID, models.AssetDown).
+			Update("status", models.AssetInService).Error
+	})
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to close downtime")
+		return
+	}
+	middleware.ScopedDB(r).First(&downtime, "id = ?", downtime.ID)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "downtime closed", "item": downtime})
+}
+
+// ListAssetDowntime lists an asset's downtime over a period with its availability.
+// ?from= and ?to= (YYYY-MM-DD) set the period, by default the last 30 days.
+// GET /api/v1/business/{businessCode}/assets/{id}/downtime
+func ListAssetDowntime(w http.ResponseWriter, r *http.Request) {
+	businessID, err := assetBusinessID(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load downtime")
+		return
+	}
+	asset, err := loadAsset(middleware.ScopedDB(r), r, "id", businessID)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load asset")
+		return
+	}
+	fromDay, toDay, err := vendorReportPeriod(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load downtime")
+		return
+	}
+	to := time.Now()
+	if toDay != nil {
+		to = toDay.AddDate(0, 0, 1)
+	}
+	from := to.AddDate(0, 0, -30)
+	if fromDay != nil {
+		from = *fromDay
+	}
+	if !to.After(from) {
+		http.Error(w, "to must not be before from", http.StatusBadRequest)
+		return
+	}
+
+	var items []models.AssetDowntime
+	if err := middleware.ScopedDB(r).Where("asset_id = ? AND started_at < ? AND (ended_at IS NULL OR ended_at > ?)", asset.ID, to, from).
+		Order("started_at DESC").Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch downtime", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"items":        items,
+		"count":        len(items),
+		"from":         from,
+		"to":           to,
+		"availability": models.SummarizeDowntime(items, from, to),
+	})
+}
+
+// siteAvailability is the availability of the assets at one site, or of those at no site
+type siteAvailability struct {
+	SiteID         *uuid.UUID `json:"site_id"`
+	SiteName       string     `json:"site_name,omitempty"`
+	OpenTickets    int64      `json:"open_tickets"`
+	OverdueTickets int64      `json:"overdue_tickets"`
+	models.AssetAvailability
+}
+
+// GetMaintenanceAvailability rolls up the availability, MTTR and MTBF of the in-service
+// and down assets the user maintains per site, with their open and overdue maintenance
+// tickets. ?from= and ?to= (YYYY-MM-DD) set the period, by default the last 30 days;
+// ?domain= and ?site_id= narrow the assets.
+// GET /api/v1/business/{businessCode}/maintenance/availability
+func GetMaintenanceAvailability(w http.ResponseWriter, r *http.Request) {
+	businessID, err := assetBusinessID(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load availability")
+		return
+	}
+	fromDay, toDay, err := vendorReportPeriod(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load availability")
+		return
+	}
+	now := time.Now()
+	to := now
+	if toDay != nil && toDay.AddDate(0, 0, 1).Before(now) {
+		to = toDay.AddDate(0, 0, 1)
+	}
+	from := to.AddDate(0, 0, -30)
+	if fromDay != nil {
+		from = *fromDay
+	}
+	if !to.After(from) {
+		http.Error(w, "to must not be before from", http.StatusBadRequest)
+		return
+	}
+
+	query := middleware.ScopedDB(r).Preload("Site").
+		Where("business_vertical_id = ? AND deleted_at IS NULL AND status IN ?", businessID, []string{models.AssetInService, models.AssetUnderMaintenance, models.AssetDown})
+	if domains := maintainedDomains(r); domains != nil {
+		query = query.Where("domain IN ?", domains)
+	}
+	if siteIDs, restricted := middleware.GetSiteScope(r); restricted {
+		query = query.Where("site_id IN ?", siteIDs)
+	}
+	if v := r.URL.Query().Get("domain"); v != "" {
+		query = query.Where("domain = ?", v)
+	}
+	if id, ok := parseUUIDQuery(r, "site_id"); ok {
+		query = query.Where("site_id = ?", id)
+	}
+	var assets []models.Asset
+	if err := query.Find(&assets).Error; err != nil {
+		http.Error(w, "failed to fetch assets", http.StatusInternalServerError)
+		return
+	}
+	assetIDs := make([]uuid.UUID, 0, len(assets))
+	for _, a := range assets {
+		assetIDs = append(assetIDs, a.ID)
+	}
+
+	var downtimes []models.AssetDowntime
+	var tickets []struct {
+		AssetID uuid.UUID
+		Open    int64
+		Overdue int64
+	}
+	if len(assetIDs) > 0 {
+		if err := middleware.ScopedDB(r).Where("asset_id IN ? AND started_at < ? AND (ended_at IS NULL OR ended_at > ?)", assetIDs, to, from).
+			Find(&downtimes).Error; err != nil {
+			http.Error(w, "failed to fetch downtime", http.StatusInternalServerError)
+			return
+		}
+		if err := middleware.ScopedDB(r).Model(&models.MaintenanceTask{}).
+			Select("asset_id, COUNT(*) AS open, COUNT(*) FILTER (WHERE due_on < ?) AS overdue", now.UTC().Format("2006-01-02")).
+			Where("asset_id IN ? AND status IN ?", assetIDs, []string{models.MaintenanceOpen, models.MaintenanceInProgress}).
+			Group("asset_id").Scan(&tickets).Error; err != nil {
+			http.Error(w, "failed to fetch maintenance tasks", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Group the assets, their downtime and tickets by site
+	siteOf := make(map[uuid.UUID]uuid.UUID, len(assets))
+	type siteGroup struct {
+		row       siteAvailability
+		assets    int
+		downtimes []models.AssetDowntime
+	}
+	groups := map[uuid.UUID]*siteGroup{}
+	var order []uuid.UUID
+	for _, a := range assets {
+		key := uuid.Nil
+		if a.SiteID != nil {
+			key = *a.SiteID
+		}
+		siteOf[a.ID] = key
+		g, ok := groups[key]
+		if !ok {
+			g = &siteGroup{row: siteAvailability{SiteID: a.SiteID}}
+			if a.Site != nil {
+				g.row.SiteName = a.Site.Name
+			}
+			groups[key] = g
+			order = append(order, key)
+		}
+		g.assets++
+	}
+	for _, d := range downtimes {
+		g := groups[siteOf[d.AssetID]]
+		g.downtimes = append(g.downtimes, d)
+	}
+	for _, t := range tickets {
+		g := groups[siteOf[t.AssetID]]
+		g.row.OpenTickets += t.Open
+		g.row.OverdueTickets += t.Overdue
+	}
+
+	sites := make([]siteAvailability, 0, len(order))
+	for _, key := range order {
+		g := groups[key]
+		g.row.AssetAvailability = models.SummarizeSiteDowntime(g.assets, g.downtimes, from, to)
+		sites = append(sites, g.row)
+	}
+	sort.Slice(sites, func(i, j int) bool { return sites[i].SiteName < sites[j].SiteName })
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"from":    from,
+		"to":      to,
+		"sites":   sites,
+		"overall": models.SummarizeSiteDowntime(len(assets), downtimes, from, to),
+	})
+}
@@ -131,7 +131,7 @@ func CheckInAttendance(w http.ResponseWriter, r *http.Request) {
 
 	event := buildAttendanceEvent(session.ID, user.ID, site.ID, businessID, models.AttendanceEventTypeCheckIn, req, capturedAt, validation, anomalyFlags, metadata)
 
-	if err := config.DB.Transaction(func(tx *gorm.DB) error {
+	if err := middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
 		if err := tx.Create(&session).Error; err != nil {
 			return err
 		}
@@ -231,7 +231,7 @@ func HeartbeatAttendance(w http.ResponseWriter, r *http.Request) {
 	session.ValidationReason = stringPtr(validation.ValidationReason)
 	session.AnomalyFlags = anomalyFlags
 
-	if err := config.DB.Transaction(func(tx *gorm.DB) error {
+	if err := middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
 		if err := tx.Create(&ping).Error; err != nil {
 			return err
 		}
@@ -318,7 +318,7 @@ func CheckOutAttendance(w http.ResponseWriter, r *http.Request) {
 	session.ValidationReason = stringPtr(validation.ValidationReason)
 	session.AnomalyFlags = anomalyFlags
 
-	if err := config.DB.Transaction(func(tx *gorm.DB) error {
+	if err := middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
 		if err := tx.Create(&event).Error; err != nil {
 			return err
 		}
@@ -327,6 +327,7 @@ func CheckOutAttendance(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to complete attendance session", http.StatusInternalServerError)
 		return
 	}
+	recordGeofencedAttendanceDay(session)
 
 	respondJSON(w, http.StatusOK, attendanceCommandResponse{Session: session, Validation: validation, Event: &event})
 }
@@ -339,7 +340,7 @@ func GetActiveAttendanceSessions(w http.ResponseWriter, r *http.Request) {
 	}
 
 	page, limit := parsePagination(r)
-	query := config.DB.Model(&models.AttendanceSession{}).
+	query := middleware.ScopedDB(r).Model(&models.AttendanceSession{}).
 		Preload("User").
 		Preload("Site").
 		Where("business_vertical_id = ? AND status = ?", businessID, models.AttendanceSessionStatusActive)
@@ -379,7 +380,7 @@ func GetAttendanceLogs(w http.ResponseWriter, r *http.Request) {
 	}
 
 	page, limit := parsePagination(r)
-	query := config.DB.Model(&models.AttendanceSession{}).
+	query := middleware.ScopedDB(r).Model(&models.AttendanceSession{}).
 		Preload("User").
 		Preload("Site").
 		Where("business_vertical_id = ?", businessID)
@@ -430,7 +431,7 @@ func GetAttendanceHeadcount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := config.DB.Table("attendance_sessions").
+	query := middleware.ScopedDB(r).Table("attendance_sessions").
 		Select("attendance_sessions.site_id, sites.name as site_name, COUNT(attendance_sessions.id) as active_count, MAX(attendance_sessions.last_seen_at) as last_seen_at").
 		Joins("JOIN sites ON sites.id = attendance_sessions.site_id").
 		Where("attendance_sessions.business_vertical_id = ? AND attendance_sessions.status = ? AND attendance_sessions.deleted_at IS NULL", businessID, models.AttendanceSessionStatusActive).
@@ -471,7 +472,7 @@ func GetEmployeeAttendanceTimeline(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := config.DB.Model(&models.AttendanceSession{}).
+	query := middleware.ScopedDB(r).Model(&models.AttendanceSession{}).
 		Preload("Site").
 		Preload("Events", func(tx *gorm.DB) *gorm.DB {
 			return tx.Order("event_time ASC")
@@ -516,14 +517,14 @@ func getBusinessIDFromContext(r *http.Request) (uuid.UUID, error) {
 
 func loadAccessibleSite(r *http.Request, user models.User, businessID uuid.UUID, siteID uuid.UUID) (models.Site, error) {
 	var site models.Site
-	if err := config.DB.Where("id = ? AND business_vertical_id = ? AND is_active = ?", siteID, businessID, true).First(&site).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ? AND is_active = ?", siteID, businessID, true).First(&site).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return site, errors.New("site not found")
 		}
 		return site, err
 	}
 
-	if user.RoleModel != nil && user.RoleModel.Name == "super_admin" {
+	if role := user.EffectiveRole(); role != nil && role.Name == "super_admin" {
 		return site, nil
 	}
 
@@ -532,7 +533,7 @@ func loadAccessibleSite(r *http.Request, user models.User, businessID uuid.UUID,
 	}
 
 	var count int64
-	if err := config.DB.Model(&models.UserSiteAccess{}).
+	if err := middleware.ScopedDB(r).Model(&models.UserSiteAccess{}).
 		Where("user_id = ? AND site_id = ? AND can_read = ?", user.ID, siteID, true).
 		Count(&count).Error; err != nil {
 		return site, err
@@ -0,0 +1,1117 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+const (
+	// attendanceDayMaxRange caps the days one compute request or report may cover
+	attendanceDayMaxRange = 62
+	// attendanceImportMaxRows caps the rows one attendance upload may hold
+	attendanceImportMaxRows = 5000
+	// attendanceTimezone places days for employees without a shift
+	attendanceTimezone = "Asia/Kolkata"
+)
+
+// shiftResolver finds the shift an employee works on a day: their assignment, else the
+// default shift of the site, else the business's default shift
+type shiftResolver struct {
+	shifts      map[uuid.UUID]*models.Shift
+	assignments map[uuid.UUID][]models.ShiftAssignment
+	siteDefault map[uuid.UUID]*models.Shift
+	defaultAll  *models.Shift
+}
+
+// loadShiftResolver loads the business's active shifts and the assignments that overlap
+// from and to
+func loadShiftResolver(db *gorm.DB, businessID uuid.UUID, from, to time.Time) (*shiftResolver, error) {
+	var shifts []models.Shift
+	if err := db.Where("business_vertical_id = ? AND is_active = ?", businessID, true).Find(&shifts).Error; err != nil {
+		return nil, err
+	}
+	res := &shiftResolver{
+		shifts:      map[uuid.UUID]*models.Shift{},
+		assignments: map[uuid.UUID][]models.ShiftAssignment{},
+		siteDefault: map[uuid.UUID]*models.Shift{},
+	}
+	for i := range shifts {
+		s := &shifts[i]
+		res.shifts[s.ID] = s
+		if !s.IsDefault {
+			continue
+		}
+		if s.SiteID != nil {
+			res.siteDefault[*s.SiteID] = s
+		} else {
+			res.defaultAll = s
+		}
+	}
+
+	var assignments []models.ShiftAssignment
+	if err := db.Where("business_vertical_id = ? AND effective_from <= ? AND (effective_to IS NULL OR effective_to >= ?)",
+		businessID, to, from).Order("effective_from DESC").Find(&assignments).Error; err != nil {
+		return nil, err
+	}
+	for _, a := range assignments {
+		res.assignments[a.UserID] = append(res.assignments[a.UserID], a)
+	}
+	return res, nil
+}
+
+// forDay returns the employee's shift on the date at the site, or nil if none applies
+func (res *shiftResolver) forDay(userID, siteID uuid.UUID, date time.Time) *models.Shift {
+	for _, a := range res.assignments[userID] {
+		if !a.EffectiveFrom.After(date) && (a.EffectiveTo == nil || !a.EffectiveTo.Before(date)) {
+			if s, ok := res.shifts[a.ShiftID]; ok {
+				return s
+			}
+		}
+	}
+	if s, ok := res.siteDefault[siteID]; ok {
+		return s
+	}
+	return res.defaultAll
+}
+
+// attendanceLocation returns where an employee's days are counted: their shift's timezone
+func attendanceLocation(shift *models.Shift) *time.Location {
+	if shift != nil {
+		return shift.Location()
+	}
+	loc, err := time.LoadLocation(attendanceTimezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// calendarDate returns t's date in loc as midnight UTC, as date columns hold it
+func calendarDate(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// saveAttendanceDay stores the day, replacing the employee's record for the date. A
+// geofenced day never replaces one entered manually or uploaded.
+func saveAttendanceDay(db *gorm.DB, day *models.AttendanceDay) error {
+	conflict := clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "date"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"business_vertical_id", "site_id", "shift_id", "source", "check_in_at", "check_out_at", "status",
+			"worked_minutes", "late_minutes", "is_late", "overtime_minutes", "remarks", "recorded_by", "updated_at",
+		}),
+	}
+	if day.Source == models.AttendanceSourceGeofenced {
+		conflict.Where = clause.Where{Exprs: []clause.Expression{
+			clause.Eq{Column: clause.Column{Table: "attendance_days", Name: "source"}, Value: models.AttendanceSourceGeofenced},
+		}}
+	}
+	return db.Clauses(conflict).Create(day).Error
+}
+
+// deriveGeofencedDay builds the employee's day from the attendance sessions they checked
+// in to on it, from the first check-in to the last check-out. It returns nil if they did
+// not check in or every check-in was rejected.
+func deriveGeofencedDay(db *gorm.DB, res *shiftResolver, businessID, userID uuid.UUID, date time.Time) (*models.AttendanceDay, error) {
+	// The shift depends on the site, which depends on the sessions; place the day with
+	// the employee's assigned or business shift first
+	loc := attendanceLocation(res.forDay(userID, uuid.Nil, date))
+	dayStart := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+
+	var sessions []models.AttendanceSession
+	if err := db.Where("business_vertical_id = ? AND user_id = ? AND check_in_at >= ? AND check_in_at < ? AND validation_status <> ?",
+		businessID, userID, dayStart, dayStart.AddDate(0, 0, 1), models.AttendanceValidationRejected).
+		Order("check_in_at").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, nil
+	}
+
+	first := sessions[0]
+	checkIn := first.CheckInAt
+	day := &models.AttendanceDay{
+		BusinessVerticalID: businessID,
+		SiteID:             first.SiteID,
+		UserID:             userID,
+		Date:               date,
+		Source:             models.AttendanceSourceGeofenced,
+		CheckInAt:          &checkIn,
+	}
+	for _, s := range sessions {
+		if s.CheckOutAt != nil && (day.CheckOutAt == nil || s.CheckOutAt.After(*day.CheckOutAt)) {
+			checkOut := *s.CheckOutAt
+			day.CheckOutAt = &checkOut
+		}
+	}
+	shift := res.forDay(userID, first.SiteID, date)
+	if shift != nil {
+		day.ShiftID = &shift.ID
+	}
+	day.Evaluate(shift)
+	return day, nil
+}
+
+// recordGeofencedAttendanceDay updates the day of a session just checked out of
+func recordGeofencedAttendanceDay(session models.AttendanceSession) {
+	date := calendarDate(session.CheckInAt, attendanceLocation(nil))
+	res, err := loadShiftResolver(config.DB, session.BusinessVerticalID, date, date)
+	if err == nil {
+		date = calendarDate(session.CheckInAt, attendanceLocation(res.forDay(session.UserID, session.SiteID, date)))
+		var day *models.AttendanceDay
+		if day, err = deriveGeofencedDay(config.DB, res, session.BusinessVerticalID, session.UserID, date); err == nil && day != nil {
+			err = saveAttendanceDay(config.DB, day)
+		}
+	}
+	if err != nil {
+		log.Printf("⚠️ failed to record attendance day for session %s: %v", session.ID, err)
+	}
+}
+
+// parseAttendanceDate parses a YYYY-MM-DD date
+func parseAttendanceDate(v string) (time.Time, error) {
+	date, err := time.Parse("2006-01-02", strings.TrimSpace(v))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a YYYY-MM-DD date", v)
+	}
+	return date, nil
+}
+
+// enteredAttendanceDay builds a day entered by hand or uploaded. Check-in and check-out
+// are HH:MM times on the date in the shift's timezone; a check-out before the check-in
+// is the next morning. A status, if given, overrides the evaluated one, as for leave.
+func enteredAttendanceDay(res *shiftResolver, businessID, userID, siteID uuid.UUID, date time.Time, checkIn, checkOut, status, remarks, source, recordedBy string) (*models.AttendanceDay, error) {
+	shift := res.forDay(userID, siteID, date)
+	loc := attendanceLocation(shift)
+	at := func(v string) (*time.Time, error) {
+		if strings.TrimSpace(v) == "" {
+			return nil, nil
+		}
+		clock, err := time.Parse("15:04", strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an HH:MM time", v)
+		}
+		t := time.Date(date.Year(), date.Month(), date.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+		return &t, nil
+	}
+
+	day := &models.AttendanceDay{
+		BusinessVerticalID: businessID,
+		SiteID:             siteID,
+		UserID:             userID,
+		Date:               date,
+		Source:             source,
+		Remarks:            strings.TrimSpace(remarks),
+		RecordedBy:         recordedBy,
+	}
+	var err error
+	if day.CheckInAt, err = at(checkIn); err != nil {
+		return nil, fmt.Errorf("check_in: %v", err)
+	}
+	if day.CheckOutAt, err = at(checkOut); err != nil {
+		return nil, fmt.Errorf("check_out: %v", err)
+	}
+	if day.CheckOutAt != nil {
+		if day.CheckInAt == nil {
+			return nil, errors.New("a check_out needs a check_in")
+		}
+		if !day.CheckOutAt.After(*day.CheckInAt) {
+			next := day.CheckOutAt.AddDate(0, 0, 1)
+			day.CheckOutAt = &next
+		}
+	}
+	if shift != nil {
+		day.ShiftID = &shift.ID
+	}
+	day.Evaluate(shift)
+	if status = strings.TrimSpace(status); status != "" {
+		if !models.ValidAttendanceStatus(status) {
+			return nil, fmt.Errorf("unknown status %q", status)
+		}
+		day.Status = status
+	}
+	return day, nil
+}
+
+// ==========================
+// Shift handlers
+// ==========================
+
+// ListShifts lists the business's shifts. ?site_id= narrows them to a site's shifts and
+// the business-wide ones.
+// GET /api/v1/business/{businessCode}/attendance/shifts
+func ListShifts(w http.ResponseWriter, r *http.Request) {
+	businessID, err := getBusinessIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID)
+	if siteID, ok := parseUUIDQuery(r, "site_id"); ok {
+		query = query.Where("site_id = ? OR site_id IS NULL", siteID)
+	}
+	if r.URL.Query().Get("active") == "true" {
+		query = query.Where("is_active = ?", true)
+	}
+	var shifts []models.Shift
+	if err := query.Order("code").Find(&shifts).Error; err != nil {
+		http.Error(w, "failed to fetch shifts", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"data": shifts, "total": len(shifts)})
+}
+
+// saveShift validates the shift and stores it, keeping it the only default of its site,
+// or of the business for a shift without a site
+func saveShift(w http.ResponseWriter, shift *models.Shift, create bool) bool {
+	if shift.Timezone == "" {
+		shift.Timezone = attendanceTimezone
+	}
+	if shift.WeeklyOffs == nil {
+		shift.WeeklyOffs = models.StringArray{"sunday"}
+	}
+	if err := shift.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if shift.SiteID != nil {
+		var count int64
+		config.DB.Model(&models.Site{}).Where("id = ? AND business_vertical_id = ?", *shift.SiteID, shift.BusinessVerticalID).Count(&count)
+		if count == 0 {
+			http.Error(w, "site not found in this business", http.StatusBadRequest)
+			return false
+		}
+	}
+	var count int64
+	config.DB.Model(&models.Shift{}).Where("business_vertical_id = ? AND code = ? AND id <> ?", shift.BusinessVerticalID, shift.Code, shift.ID).Count(&count)
+	if count > 0 {
+		http.Error(w, "a shift with this code already exists", http.StatusConflict)
+		return false
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if shift.IsDefault {
+			others := tx.Model(&models.Shift{}).Where("business_vertical_id = ? AND id <> ? AND is_default = ?", shift.BusinessVerticalID, shift.ID, true)
+			if shift.SiteID != nil {
+				others = others.Where("site_id = ?", *shift.SiteID)
+			} else {
+				others = others.Where("site_id IS NULL")
+			}
+			if err := others.Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		if create {
+			if err := tx.Create(shift).Error; err != nil {
+				return err
+			}
+		}
+		// Create would skip false flags in favour of the column defaults
+		return tx.Model(shift).Select("*").Omit("id", "created_at", "created_by").Updates(shift).Error
+	})
+	if err != nil {
+		http.Error(w, "failed to save shift", http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+// shiftRequest is the body of shift create and update requests
+type shiftRequest struct {
+	SiteID             *uuid.UUID         `json:"site_id"`
+	Code               string             `json:"code"`
+	Name               string             `json:"name"`
+	StartTime          string             `json:"start_time"`
+	EndTime            string             `json:"end_time"`
+	Timezone           string             `json:"timezone"`
+	BreakMinutes       int                `json:"break_minutes"`
+	GraceMinutes       int                `json:"grace_minutes"`
+	HalfDayLateMinutes int                `json:"half_day_late_minutes"`
+	FullDayMinutes     int                `json:"full_day_minutes"`
+	HalfDayMinutes     int                `json:"half_day_minutes"`
+	OvertimeMinMinutes int                `json:"overtime_min_minutes"`
+	WeeklyOffs         models.StringArray `json:"weekly_offs"`
+	IsDefault          bool               `json:"is_default"`
+	IsActive           *bool              `json:"is_active"`
+}
+
+// apply copies the request onto the shift
+func (req shiftRequest) apply(shift *models.Shift) {
+	shift.SiteID = req.SiteID
+	shift.Code = strings.ToUpper(strings.TrimSpace(req.Code))
+	shift.Name = strings.TrimSpace(req.Name)
+	shift.StartTime = strings.TrimSpace(req.StartTime)
+	shift.EndTime = strings.TrimSpace(req.EndTime)
+	shift.Timezone = strings.TrimSpace(req.Timezone)
+	shift.BreakMinutes = req.BreakMinutes
+	shift.GraceMinutes = req.GraceMinutes
+	shift.HalfDayLateMinutes = req.HalfDayLateMinutes
+	shift.FullDayMinutes = req.FullDayMinutes
+	shift.HalfDayMinutes = req.HalfDayMinutes
+	shift.OvertimeMinMinutes = req.OvertimeMinMinutes
+	shift.WeeklyOffs = req.WeeklyOffs
+	shift.IsDefault = req.IsDefault
+	if req.IsActive != nil {
+		shift.IsActive = *req.IsActive
+	}
+}
+
+// CreateShift defines a shift
+// POST /api/v1/business/{businessCode}/attendance/shifts
+func CreateShift(w http.ResponseWriter, r *http.Request) {
+	businessID, err := getBusinessIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req shiftRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	userID := middleware.GetClaims(r).UserID
+	shift := models.Shift{BusinessVerticalID: businessID, IsActive: true, CreatedBy: userID, UpdatedBy: userID}
+	req.apply(&shift)
+	if !saveShift(w, &shift, true) {
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, map[string]interface{}{"message": "shift created", "data": shift})
+}
+
+// UpdateShift changes a shift. Days already recorded keep the status they were given.
+// PUT /api/v1/business/{businessCode}/attendance/shifts/{id}
+func UpdateShift(w http.ResponseWriter, r *http.Request) {
+	businessID, err := getBusinessIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var shift models.Shift
+	if err := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID).First(&shift, "id = ?", mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "shift not found", http.StatusNotFound)
+		return
+	}
+
+	var req shiftRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.apply(&shift)
+	shift.UpdatedBy = middleware.GetClaims(r).UserID
+	if !saveShift(w, &shift, false) {
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"message": "shift updated", "data": shift})
+}
+
+// ListShiftAssignments lists shift assignments, latest first. ?user_id=, ?shift_id= and
+// ?date= (in force on the date) narrow them.
+// GET /api/v1/business/{businessCode}/attendance/shift-assignments
+func ListShiftAssignments(w http.ResponseWriter, r *http.Request) {
+	businessID, err := getBusinessIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := middleware.ScopedDB(r).Preload("Shift").Where("business_vertical_id = ?", businessID)
+	if userID, ok := parseUUIDQuery(r, "user_id"); ok {
+		query = query.Where("user_id = ?", userID)
+	}
+	if shiftID, ok := parseUUIDQuery(r, "shift_id"); ok {
+		query = query.Where("shift_id = ?", shiftID)
+	}
+	if v := r.URL.Query().Get("date"); v != "" {
+		date, err := parseAttendanceDate(v)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query = query.Where("effective_from <= ? AND (effective_to IS NULL OR effective_to >= ?)", date, date)
+	}
+	var assignments []models.ShiftAssignment
+	if err := query.Order("effective_from DESC").Find(&assignments).Error; err != nil {
+		http.Error(w, "failed to fetch shift assignments", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"data": assignments, "total": len(assignments)})
+}
+
+// AssignShift puts employees on a shift from a date, ending the assignments they had
+// from before it. Assignments starting on or after the date are replaced.
+// POST /api/v1/business/{businessCode}/attendance/shift-assignments
+func AssignShift(w http.ResponseWriter, r *http.Request) {
+	businessID, err := getBusinessIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		ShiftID       uuid.UUID   `json:"shift_id"`
+		UserIDs       []uuid.UUID `json:"user_ids"`
+		EffectiveFrom string      `json:"effective_from"`
+		EffectiveTo   string      `json:"effective_to"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.UserIDs) == 0 {
+		http.Error(w, "user_ids are required", http.StatusBadRequest)
+		return
+	}
+	from, err := parseAttendanceDate(req.EffectiveFrom)
+	if err != nil {
+		http.Error(w, "effective_from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var to *time.Time
+	if req.EffectiveTo != "" {
+		date, err := parseAttendanceDate(req.EffectiveTo)
+		if err != nil || date.Before(from) {
+			http.Error(w, "effective_to must be a date not before effective_from", http.StatusBadRequest)
+			return
+		}
+		to = &date
+	}
+	var count int64
+	middleware.ScopedDB(r).Model(&models.Shift{}).Where("id = ? AND business_vertical_id = ? AND is_active = ?", req.ShiftID, businessID, true).Count(&count)
+	if count == 0 {
+		http.Error(w, "shift not found in this business", http.StatusBadRequest)
+		return
+	}
+	middleware.ScopedDB(r).Model(&models.User{}).Where("id IN ?", req.UserIDs).Count(&count)
+	if int(count) != len(req.UserIDs) {
+		http.Error(w, "some users were not found", http.StatusBadRequest)
+		return
+	}
+
+	createdBy := middleware.GetClaims(r).UserID
+	assignments := make([]models.ShiftAssignment, 0, len(req.UserIDs))
+	for _, userID := range req.UserIDs {
+		assignments = append(assignments, models.ShiftAssignment{
+			BusinessVerticalID: businessID,
+			UserID:             userID,
+			ShiftID:            req.ShiftID,
+			EffectiveFrom:      from,
+			EffectiveTo:        to,
+			CreatedBy:          createdBy,
+		})
+	}
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("business_vertical_id = ? AND user_id IN ? AND effective_from >= ?", businessID, req.UserIDs, from).
+			Delete(&models.ShiftAssignment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.ShiftAssignment{}).
+			Where("business_vertical_id = ? AND user_id IN ? AND effective_from < ? AND (effective_to IS NULL OR effective_to >= ?)",
+				businessID, req.UserIDs, from, from).
+			Update("effective_to", from.AddDate(0, 0, -1)).Error; err != nil {
+			return err
+		}
+		return tx.Create(&assignments).Error
+	})
+	if err != nil {
+		http.Error(w, "failed to assign shift", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, map[string]interface{}{"message": "shift assigned", "data": assignments})
+}
+
+// ==========================
+// Daily attendance handlers
+// ==========================
+
+// ListAttendanceDays lists daily attendance by date. ?from= and ?to= (YYYY-MM-DD),
+// ?site_id=, ?user_id=, ?status= and ?source= narrow it.
+// GET /api/v1/business/{businessCode}/attendance/days
+func ListAttendanceDays(w http.ResponseWriter, r *http.Request) {
+	businessID, err := getBusinessIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, limit := parsePagination(r)
+	query := middleware.ScopedDB(r).Model(&models.AttendanceDay{}).Where("business_vertical_id = ?", businessID)
+	q := r.URL.Query()
+	for _, bound := range []struct{ key, cond string }{{"from", "date >= ?"}, {"to", "date <= ?"}} {
+		if v := q.Get(bound.key); v != "" {
+			date, err := parseAttendanceDate(v)
+			if err != nil {
+				http.Error(w, bound.key+": "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			query = query.Where(bound.cond, date)
+		}
+	}
+	if siteID, ok := parseUUIDQuery(r, "site_id"); ok {
+		query = query.Where("site_id = ?", siteID)
+	}
+	if userID, ok := parseUUIDQuery(r, "user_id"); ok {
+		query = query.Where("user_id = ?", userID)
+	}
+	if v := q.Get("status"); v != "" {
+		query = query.Where("status = ?", v)
+	}
+	if v := q.Get("source"); v != "" {
+		query = query.Where("source = ?", v)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		http.Error(w, "failed to count attendance days", http.StatusInternalServerError)
+		return
+	}
+	var days []models.AttendanceDay
+	if err := query.Order("date DESC, user_id").Limit(limit).Offset((page - 1) * limit).Find(&days).Error; err != nil {
+		http.Error(w, "failed to fetch attendance days", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"total": total, "page": page, "limit": limit, "data": days})
+}
+
+// RecordAttendanceDay enters or corrects an employee's attendance for a day by hand,
+// replacing what was recorded for it. Times are HH:MM on the date.
+// POST /api/v1/business/{businessCode}/attendance/days
+func RecordAttendanceDay(w http.ResponseWriter, r *http.Request) {
+	businessID, err := getBusinessIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		UserID   uuid.UUID `json:"user_id"`
+		SiteID   uuid.UUID `json:"site_id"`
+		Date     string    `json:"date"`
+		CheckIn  string    `json:"check_in"`
+		CheckOut string    `json:"check_out"`
+		Status   string    `json:"status"`
+		Remarks  string    `json:"remarks"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	date, err := parseAttendanceDate(req.Date)
+	if err != nil {
+		http.Error(w, "date: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.CheckIn == "" && req.Status == "" {
+		http.Error(w, "a check_in or a status is required", http.StatusBadRequest)
+		return
+	}
+	var count int64
+	middleware.ScopedDB(r).Model(&models.User{}).Where("id = ?", req.UserID).Count(&count)
+	if count == 0 {
+		http.Error(w, "user not found", http.StatusBadRequest)
+		return
+	}
+	middleware.ScopedDB(r).Model(&models.Site{}).Where("id = ? AND business_vertical_id = ?", req.SiteID, businessID).Count(&count)
+	if count == 0 {
+		http.Error(w, "site not found in this business", http.StatusBadRequest)
+		return
+	}
+
+	res, err := loadShiftResolver(middleware.ScopedDB(r), businessID, date, date)
+	if err != nil {
+		http.Error(w, "failed to load shifts", http.StatusInternalServerError)
+		return
+	}
+	day, err := enteredAttendanceDay(res, businessID, req.UserID, req.SiteID, date, req.CheckIn, req.CheckOut,
+		req.Status, req.Remarks, models.AttendanceSourceManual, middleware.GetClaims(r).UserID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := saveAttendanceDay(middleware.ScopedDB(r), day); err != nil {
+		http.Error(w, "failed to record attendance", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"message": "attendance recorded", "data": day})
+}
+
+// ComputeAttendanceDays derives daily attendance from geofenced check-ins between two
+// dates, for days not entered by hand or uploaded. Days are also derived as employees
+// check out; this catches up days whose sessions were never closed or whose shifts have
+// since changed.
+// POST /api/v1/business/{businessCode}/attendance/days/compute
+func ComputeAttendanceDays(w http.ResponseWriter, r *http.Request) {
+	businessID, err := getBusinessIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		From   string     `json:"from"`
+		To     string     `json:"to"`
+		SiteID *uuid.UUID `json:"site_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	from, err := parseAttendanceDate(req.From)
+	if err != nil {
+		http.Error(w, "from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseAttendanceDate(req.To)
+	if err != nil || to.Before(from) || to.Sub(from).Hours()/24 >= attendanceDayMaxRange {
+		http.Error(w, fmt.Sprintf("to must be a date up to %d days after from", attendanceDayMaxRange-1), http.StatusBadRequest)
+		return
+	}
+
+	res, err := loadShiftResolver(middleware.ScopedDB(r), businessID, from, to)
+	if err != nil {
+		http.Error(w, "failed to load shifts", http.StatusInternalServerError)
+		return
+	}
+	// A day later on each side catches check-ins that fall on the range's dates in
+	// timezones ahead of or behind UTC
+	query := middleware.ScopedDB(r).Model(&models.AttendanceSession{}).
+		Where("business_vertical_id = ? AND check_in_at >= ? AND check_in_at < ?", businessID, from.AddDate(0, 0, -1), to.AddDate(0, 0, 2))
+	if req.SiteID != nil {
+		query = query.Where("site_id = ?", *req.SiteID)
+	}
+	var userIDs []uuid.UUID
+	if err := query.Distinct("user_id").Pluck("user_id", &userIDs).Error; err != nil {
+		http.Error(w, "failed to load attendance sessions", http.StatusInternalServerError)
+		return
+	}
+
+	recorded := 0
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		for _, userID := range userIDs {
+			day, err := deriveGeofencedDay(middleware.ScopedDB(r), res, businessID, userID, date)
+			if err != nil {
+				http.Error(w, "failed to derive attendance", http.StatusInternalServerError)
+				return
+			}
+			if day == nil || (req.SiteID != nil && day.SiteID != *req.SiteID) {
+				continue
+			}
+			if err := saveAttendanceDay(middleware.ScopedDB(r), day); err != nil {
+				http.Error(w, "failed to record attendance", http.StatusInternalServerError)
+				return
+			}
+			recorded++
+		}
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"message": "attendance computed", "days": recorded})
+}
+
+// attendanceImportRowError lists the problems with one row of an attendance upload. Row
+// is the line number in the file, counting the header as line 1.
+type attendanceImportRowError struct {
+	Row    int      `json:"row"`
+	Errors []string `json:"errors"`
+}
+
+// ImportAttendanceDays records daily attendance in bulk from a CSV, such as a site's
+// paper register keyed in. Its header names the columns: user_id or email, site_id or
+// site_code, date (YYYY-MM-DD), and optionally check_in and check_out (HH:MM), status and
+// remarks. Any failing row fails the upload with a per-row report (422) and nothing is
+// written. ?dry_run=true validates without writing.
+// POST /api/v1/business/{businessCode}/attendance/days/import
+func ImportAttendanceDays(w http.ResponseWriter, r *http.Request) {
+	businessID, err := getBusinessIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, formRecordImportMaxBytes)
+	source, err := readFormImportCSV(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dryRun := r.URL.Query().Get("dry_run") == "true" || r.FormValue("dry_run") == "true"
+
+	reader := csv.NewReader(source)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	headers, err := reader.Read()
+	if err != nil {
+		http.Error(w, "the CSV has no header row", http.StatusBadRequest)
+		return
+	}
+	columns := map[string]int{}
+	for i, h := range headers {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))] = i
+	}
+	_, hasUser := columns["user_id"]
+	_, hasEmail := columns["email"]
+	_, hasSite := columns["site_id"]
+	_, hasSiteCode := columns["site_code"]
+	_, hasDate := columns["date"]
+	if !(hasUser || hasEmail) || !(hasSite || hasSiteCode) || !hasDate {
+		http.Error(w, "the CSV needs user_id or email, site_id or site_code, and date columns", http.StatusBadRequest)
+		return
+	}
+	cell := func(cells []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(cells) {
+			return strings.TrimSpace(cells[i])
+		}
+		return ""
+	}
+
+	// Users and sites are looked up once each
+	users := map[string]uuid.UUID{}
+	sites := map[string]uuid.UUID{}
+	lookupUser := func(id, email string) (uuid.UUID, bool) {
+		key := id + "|" + strings.ToLower(email)
+		if v, ok := users[key]; ok {
+			return v, v != uuid.Nil
+		}
+		var user models.User
+		query := middleware.ScopedDB(r).Select("id")
+		if id != "" {
+			query = query.Where("id::text = ?", id)
+		} else {
+			query = query.Where("LOWER(email) = ?", strings.ToLower(email))
+		}
+		if err := query.First(&user).Error; err != nil {
+			user.ID = uuid.Nil
+		}
+		users[key] = user.ID
+		return user.ID, user.ID != uuid.Nil
+	}
+	lookupSite := func(id, code string) (uuid.UUID, bool) {
+		key := id + "|" + code
+		if v, ok := sites[key]; ok {
+			return v, v != uuid.Nil
+		}
+		var site models.Site
+		query := middleware.ScopedDB(r).Select("id").Where("business_vertical_id = ?", businessID)
+		if id != "" {
+			query = query.Where("id::text = ?", id)
+		} else {
+			query = query.Where("code = ?", code)
+		}
+		if err := query.First(&site).Error; err != nil {
+			site.ID = uuid.Nil
+		}
+		sites[key] = site.ID
+		return site.ID, site.ID != uuid.Nil
+	}
+
+	type row struct {
+		line                               int
+		userID, siteID                     uuid.UUID
+		date                               time.Time
+		checkIn, checkOut, status, remarks string
+	}
+	var rows []row
+	var rowErrors []attendanceImportRowError
+	failed, total := 0, 0
+	var minDate, maxDate time.Time
+	seen := map[string]int{}
+	for line := 2; ; line++ {
+		cells, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid CSV at line %d: %v", line, err), http.StatusBadRequest)
+			return
+		}
+		if total++; total > attendanceImportMaxRows {
+			http.Error(w, fmt.Sprintf("an upload may hold at most %d rows", attendanceImportMaxRows), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		var problems []string
+		rw := row{line: line, checkIn: cell(cells, "check_in"), checkOut: cell(cells, "check_out"),
+			status: cell(cells, "status"), remarks: cell(cells, "remarks")}
+		var ok bool
+		if rw.userID, ok = lookupUser(cell(cells, "user_id"), cell(cells, "email")); !ok {
+			problems = append(problems, "user not found")
+		}
+		if rw.siteID, ok = lookupSite(cell(cells, "site_id"), cell(cells, "site_code")); !ok {
+			problems = append(problems, "site not found in this business")
+		}
+		if rw.date, err = parseAttendanceDate(cell(cells, "date")); err != nil {
+			problems = append(problems, "date: "+err.Error())
+		} else {
+			if minDate.IsZero() || rw.date.Before(minDate) {
+				minDate = rw.date
+			}
+			if rw.date.After(maxDate) {
+				maxDate = rw.date
+			}
+		}
+		if rw.checkIn == "" && rw.status == "" {
+			problems = append(problems, "a check_in or a status is required")
+		}
+		key := rw.userID.String() + rw.date.Format("2006-01-02")
+		if first, dup := seen[key]; dup && len(problems) == 0 {
+			problems = append(problems, fmt.Sprintf("the employee's day is already on line %d", first))
+		}
+		seen[key] = line
+
+		if len(problems) > 0 {
+			failed++
+			if len(rowErrors) < formRecordImportMaxReported {
+				rowErrors = append(rowErrors, attendanceImportRowError{Row: line, Errors: problems})
+			}
+			continue
+		}
+		rows = append(rows, rw)
+	}
+
+	var days []*models.AttendanceDay
+	if len(rows) > 0 {
+		res, err := loadShiftResolver(middleware.ScopedDB(r), businessID, minDate, maxDate)
+		if err != nil {
+			http.Error(w, "failed to load shifts", http.StatusInternalServerError)
+			return
+		}
+		recordedBy := middleware.GetClaims(r).UserID
+		for _, rw := range rows {
+			day, err := enteredAttendanceDay(res, businessID, rw.userID, rw.siteID, rw.date, rw.checkIn, rw.checkOut,
+				rw.status, rw.remarks, models.AttendanceSourceBulk, recordedBy)
+			if err != nil {
+				failed++
+				if len(rowErrors) < formRecordImportMaxReported {
+					rowErrors = append(rowErrors, attendanceImportRowError{Row: rw.line, Errors: []string{err.Error()}})
+				}
+				continue
+			}
+			days = append(days, day)
+		}
+	}
+
+	report := map[string]interface{}{
+		"dry_run":     dryRun,
+		"total_rows":  total,
+		"valid_rows":  total - failed,
+		"failed_rows": failed,
+		"imported":    0,
+		"errors":      rowErrors,
+	}
+	if rowErrors == nil {
+		report["errors"] = []attendanceImportRowError{}
+	}
+	if failed > 0 {
+		sort.Slice(rowErrors, func(i, j int) bool { return rowErrors[i].Row < rowErrors[j].Row })
+		respondJSON(w, http.StatusUnprocessableEntity, report)
+		return
+	}
+	if dryRun {
+		respondJSON(w, http.StatusOK, report)
+		return
+	}
+
+	if err := middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		for _, day := range days {
+			if err := saveAttendanceDay(tx, day); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		http.Error(w, "failed to import attendance", http.StatusInternalServerError)
+		return
+	}
+
+	report["imported"] = len(days)
+	respondJSON(w, http.StatusOK, report)
+}
+
+// ==========================
+// Muster roll
+// ==========================
+
+// musterRollRow is an employee's month on the muster roll: a code for each day and the
+// month's totals
+type musterRollRow struct {
+	UserID        uuid.UUID `json:"user_id"`
+	Name          string    `json:"name"`
+	Days          []string  `json:"days"`
+	PresentDays   float64   `json:"present_days"`
+	HalfDays      int       `json:"half_days"`
+	AbsentDays    int       `json:"absent_days"`
+	LeaveDays     int       `json:"leave_days"`
+	WeeklyOffs    int       `json:"weekly_offs"`
+	LateDays      int       `json:"late_days"`
+	OvertimeHours float64   `json:"overtime_hours"`
+}
+
+// GetMusterRoll returns the monthly muster roll of the employees with attendance at a
+// site, or across the business: P, HD, A, WO or L for each day, and their totals.
+// Days without a record are weekly offs under the employee's shift, leave if approved
+// for payroll, or else absences; days still to come are left blank. ?period=YYYY-MM is
+// required; ?format=csv downloads it.
+// GET /api/v1/business/{businessCode}/attendance/muster-roll
+func GetMusterRoll(w http.ResponseWriter, r *http.Request) {
+	businessID, err := getBusinessIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	period := r.URL.Query().Get("period")
+	from, to, err := models.PayrollMonth(period)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	siteID, bySite := parseUUIDQuery(r, "site_id")
+
+	query := middleware.ScopedDB(r).Where("business_vertical_id = ? AND date >= ? AND date < ?", businessID, from, to)
+	if bySite {
+		query = query.Where("site_id = ?", siteID)
+	}
+	var days []models.AttendanceDay
+	if err := query.Find(&days).Error; err != nil {
+		http.Error(w, "failed to fetch attendance", http.StatusInternalServerError)
+		return
+	}
+	byUser := map[uuid.UUID]map[string]models.AttendanceDay{}
+	for _, d := range days {
+		if byUser[d.UserID] == nil {
+			byUser[d.UserID] = map[string]models.AttendanceDay{}
+		}
+		byUser[d.UserID][d.Date.Format("2006-01-02")] = d
+	}
+	userIDs := make([]uuid.UUID, 0, len(byUser))
+	userIDStrings := make([]string, 0, len(byUser))
+	for id := range byUser {
+		userIDs = append(userIDs, id)
+		userIDStrings = append(userIDStrings, id.String())
+	}
+
+	var users []models.User
+	var leaves []models.EmployeeLeave
+	res, err := loadShiftResolver(middleware.ScopedDB(r), businessID, from, to.AddDate(0, 0, -1))
+	if err == nil && len(userIDs) > 0 {
+		if err = middleware.ScopedDB(r).Select("id", "name").Where("id IN ?", userIDs).Find(&users).Error; err == nil {
+			err = middleware.ScopedDB(r).Where("business_vertical_id = ? AND user_id IN ? AND status = ? AND from_date < ? AND to_date >= ?",
+				businessID, userIDStrings, models.LeaveApproved, to, from).Find(&leaves).Error
+		}
+	}
+	if err != nil {
+		http.Error(w, "failed to build muster roll", http.StatusInternalServerError)
+		return
+	}
+	names := map[uuid.UUID]string{}
+	for _, u := range users {
+		names[u.ID] = u.Name
+	}
+	onLeave := map[string]bool{}
+	for _, l := range leaves {
+		for d := l.FromDate; !d.After(l.ToDate); d = d.AddDate(0, 0, 1) {
+			onLeave[l.UserID+d.Format("2006-01-02")] = true
+		}
+	}
+
+	today := calendarDate(time.Now(), attendanceLocation(nil))
+	rows := make([]musterRollRow, 0, len(userIDs))
+	for _, userID := range userIDs {
+		row := musterRollRow{UserID: userID, Name: names[userID], Days: []string{}}
+		overtime := 0
+		for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+			key := d.Format("2006-01-02")
+			status := ""
+			if day, ok := byUser[userID][key]; ok {
+				status = day.Status
+				if day.IsLate {
+					row.LateDays++
+				}
+				overtime += day.OvertimeMinutes
+			} else if !d.After(today) {
+				switch shift := res.forDay(userID, siteID, d); {
+				case onLeave[userID.String()+key]:
+					status = models.AttendanceOnLeave
+				case shift != nil && shift.IsWeeklyOff(d), shift == nil && d.Weekday() == time.Sunday:
+					status = models.AttendanceWeeklyOff
+				default:
+					status = models.AttendanceAbsent
+				}
+			}
+			if status == "" {
+				row.Days = append(row.Days, "")
+				continue
+			}
+			row.Days = append(row.Days, models.MusterCode(status))
+			row.PresentDays += models.PaidDayValue(status)
+			switch status {
+			case models.AttendanceHalfDay:
+				row.HalfDays++
+			case models.AttendanceAbsent:
+				row.AbsentDays++
+			case models.AttendanceOnLeave:
+				row.LeaveDays++
+			case models.AttendanceWeeklyOff:
+				row.WeeklyOffs++
+			}
+		}
+		row.OvertimeHours = float64(overtime*100/60) / 100
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Name < rows[j].Name })
+
+	if r.URL.Query().Get("format") != "csv" {
+		respondJSON(w, http.StatusOK, map[string]interface{}{"period": period, "days_in_month": to.AddDate(0, 0, -1).Day(), "data": rows})
+		return
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	header := []string{"Employee", "Employee ID"}
+	for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+		header = append(header, strconv.Itoa(d.Day()))
+	}
+	header = append(header, "Present", "Half days", "Absent", "Leave", "Weekly offs", "Late", "Overtime hours")
+	_ = writer.Write(header)
+	for _, row := range rows {
+		record := append([]string{row.Name, row.UserID.String()}, row.Days...)
+		record = append(record,
+			strconv.FormatFloat(row.PresentDays, 'f', -1, 64), strconv.Itoa(row.HalfDays), strconv.Itoa(row.AbsentDays),
+			strconv.Itoa(row.LeaveDays), strconv.Itoa(row.WeeklyOffs), strconv.Itoa(row.LateDays),
+			strconv.FormatFloat(row.OvertimeHours, 'f', 2, 64))
+		_ = writer.Write(record)
+	}
+	writer.Flush()
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="muster-roll-%s.csv"`, period))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
@@ -7,7 +7,6 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 	"p9e.in/ugcl/pkg/abac"
@@ -21,7 +20,7 @@ func CreateAttribute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	attributeService := abac.NewAttributeService(config.DB)
+	attributeService := abac.NewAttributeService(middleware.ScopedDB(r))
 	createdAttr, err := attributeService.CreateAttribute(attribute)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -33,6 +32,26 @@ func CreateAttribute(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(createdAttr)
 }
 
+// GetAttribute retrieves a single attribute definition
+func GetAttribute(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	attributeID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid attribute ID", http.StatusBadRequest)
+		return
+	}
+
+	attributeService := abac.NewAttributeService(middleware.ScopedDB(r))
+	attribute, err := attributeService.GetAttribute(attributeID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(attribute)
+}
+
 // UpdateAttribute updates an attribute definition
 func UpdateAttribute(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -48,7 +67,7 @@ func UpdateAttribute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	attributeService := abac.NewAttributeService(config.DB)
+	attributeService := abac.NewAttributeService(middleware.ScopedDB(r))
 	updatedAttr, err := attributeService.UpdateAttribute(attributeID, updates)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -68,7 +87,7 @@ func DeleteAttribute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	attributeService := abac.NewAttributeService(config.DB)
+	attributeService := abac.NewAttributeService(middleware.ScopedDB(r))
 	if err := attributeService.DeleteAttribute(attributeID); err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
@@ -99,7 +118,7 @@ func ListAttributes(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	attributeService := abac.NewAttributeService(config.DB)
+	attributeService := abac.NewAttributeService(middleware.ScopedDB(r))
 	attributes, err := attributeService.ListAttributes(attrType, isActive)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -142,7 +161,7 @@ func AssignUserAttribute(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid assigned by user ID", http.StatusInternalServerError)
 		return
 	}
-	attributeService := abac.NewAttributeService(config.DB)
+	attributeService := abac.NewAttributeService(middleware.ScopedDB(r))
 
 	if err := attributeService.AssignUserAttribute(userID, attributeID, assignedBy, req.Value, req.ValidUntil); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -168,7 +187,7 @@ func RemoveUserAttribute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	attributeService := abac.NewAttributeService(config.DB)
+	attributeService := abac.NewAttributeService(middleware.ScopedDB(r))
 	if err := attributeService.RemoveUserAttribute(userID, attributeID); err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
@@ -186,7 +205,7 @@ func GetUserAttributes(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	attributeService := abac.NewAttributeService(config.DB)
+	attributeService := abac.NewAttributeService(middleware.ScopedDB(r))
 	attributes, err := attributeService.GetUserAttributes(userID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -221,7 +240,7 @@ func BulkAssignUserAttributes(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid assigned by user ID", http.StatusInternalServerError)
 		return
 	}
-	attributeService := abac.NewAttributeService(config.DB)
+	attributeService := abac.NewAttributeService(middleware.ScopedDB(r))
 
 	if err := attributeService.BulkAssignUserAttributes(userID, assignedBy, req.Attributes); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -265,7 +284,7 @@ func AssignResourceAttribute(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid assigned by user ID", http.StatusInternalServerError)
 		return
 	}
-	attributeService := abac.NewAttributeService(config.DB)
+	attributeService := abac.NewAttributeService(middleware.ScopedDB(r))
 
 	if err := attributeService.AssignResourceAttribute(req.ResourceType, resourceID, attributeID, assignedBy, req.Value, req.ValidUntil); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -293,7 +312,7 @@ func RemoveResourceAttribute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	attributeService := abac.NewAttributeService(config.DB)
+	attributeService := abac.NewAttributeService(middleware.ScopedDB(r))
 	if err := attributeService.RemoveResourceAttribute(resourceType, resourceID, attributeID); err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
@@ -313,7 +332,7 @@ func GetResourceAttributes(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	attributeService := abac.NewAttributeService(config.DB)
+	attributeService := abac.NewAttributeService(middleware.ScopedDB(r))
 	attributes, err := attributeService.GetResourceAttributes(resourceType, resourceID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -339,7 +358,7 @@ func GetUserAttributeHistory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	attributeService := abac.NewAttributeService(config.DB)
+	attributeService := abac.NewAttributeService(middleware.ScopedDB(r))
 	history, err := attributeService.GetUserAttributeHistory(userID, attributeID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -349,3 +368,60 @@ func GetUserAttributeHistory(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(history)
 }
+
+// ListAttributeValues lists the active user and resource values of an attribute
+func ListAttributeValues(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	attributeID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid attribute ID", http.StatusBadRequest)
+		return
+	}
+
+	attributeService := abac.NewAttributeService(middleware.ScopedDB(r))
+	userValues, resourceValues, err := attributeService.ListAttributeValues(attributeID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"user_values":     userValues,
+		"resource_values": resourceValues,
+	})
+}
+
+// ListAttributeResolvers lists the resolvers that derive attributes during policy evaluation
+func ListAttributeResolvers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"resolvers": abac.AttributeResolvers(),
+	})
+}
+
+// GetEffectiveUserAttributes retrieves a user's assigned attributes together with the
+// resolved ones (?business_id= scopes site derived attributes to one vertical)
+func GetEffectiveUserAttributes(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID, err := uuid.Parse(vars["user_id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var businessID *uuid.UUID
+	if id, err := uuid.Parse(r.URL.Query().Get("business_id")); err == nil {
+		businessID = &id
+	}
+
+	attributeService := abac.NewAttributeService(middleware.ScopedDB(r))
+	attributes, err := attributeService.GetEffectiveUserAttributes(userID, businessID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(attributes)
+}
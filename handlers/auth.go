@@ -38,8 +38,8 @@ type adminUsersCacheStore struct {
 var adminUsersCache = &adminUsersCacheStore{entries: make(map[string]adminUsersCacheEntry)}
 var adminUsersLoadGroup singleflight.Group
 
-func adminUsersCacheKey(page, limit int) string {
-	return strconv.Itoa(page) + ":" + strconv.Itoa(limit)
+func adminUsersCacheKey(companyID uuid.UUID, page, limit int) string {
+	return companyID.String() + ":" + strconv.Itoa(page) + ":" + strconv.Itoa(limit)
 }
 
 func (c *adminUsersCacheStore) get(key string) ([]byte, bool) {
@@ -101,7 +101,11 @@ func Register(w http.ResponseWriter, r *http.Request) {
 		PasswordHash: string(hash),
 		RoleID:       req.RoleID,
 	}
-	if err := config.DB.Create(&u).Error; err != nil {
+	// Admins create users in their own company; self-registration has none yet
+	if companyID := middleware.GetCurrentCompanyID(r); companyID != uuid.Nil {
+		u.CompanyID = &companyID
+	}
+	if err := middleware.ScopedDB(r).Create(&u).Error; err != nil {
 		if utils.IsUniqueViolation(err) {
 			http.Error(w, "username already taken", http.StatusConflict)
 		} else {
@@ -191,8 +195,8 @@ func Login(w http.ResponseWriter, r *http.Request) {
 	// Keep login lookup minimal and index-friendly: avoid implicit ORDER BY from First().
 	dbLookupStart := time.Now()
 	var u models.User
-	if err := config.DB.WithContext(loginCtx).
-		Select("id", "name", "email", "phone", "password_hash", "role_id").
+	if err := middleware.ScopedDB(r).WithContext(loginCtx).
+		Select("id", "name", "email", "phone", "password_hash", "role_id", "company_id").
 		Where("phone = ?", req.Phone).
 		Take(&u).Error; err != nil {
 		http.Error(w, "invalid credentials", http.StatusUnauthorized)
@@ -207,22 +211,46 @@ func Login(w http.ResponseWriter, r *http.Request) {
 	}
 	passwordCheckDuration = time.Since(passwordCheckStart)
 
+	tokenBuildStart := time.Now()
+	out, err := issueLogin(loginCtx, r, &u)
+	if err != nil {
+		http.Error(w, "couldn't create token", http.StatusInternalServerError)
+		return
+	}
+	tokenBuildDuration = time.Since(tokenBuildStart)
+	json.NewEncoder(w).Encode(out)
+
+	totalDuration := time.Since(requestStart)
+	if shouldLogSlowLogin(totalDuration) {
+		slog.Warn("slow login request",
+			"duration_ms", totalDuration.Milliseconds(),
+			"db_lookup_ms", dbLookupDuration.Milliseconds(),
+			"password_check_ms", passwordCheckDuration.Milliseconds(),
+			"token_build_ms", tokenBuildDuration.Milliseconds(),
+		)
+	}
+}
+
+// issueLogin signs a token for a user who has proved who they are, records the login and
+// returns the login response
+func issueLogin(ctx context.Context, r *http.Request, u *models.User) (loginResp, error) {
 	// Determine role name for token
 	roleName := "user" // default
 	if u.RoleID != nil {
 		var role models.Role
-		if err := config.DB.WithContext(loginCtx).Select("name").Where("id = ?", *u.RoleID).Take(&role).Error; err == nil {
+		if err := middleware.ScopedDB(r).WithContext(ctx).Select("name").Where("id = ?", *u.RoleID).Take(&role).Error; err == nil {
 			roleName = role.Name
 		}
 	}
 
-	tokenBuildStart := time.Now()
-	token, err := middleware.GenerateToken(u.ID.String(), roleName, u.Name, u.Phone)
+	companyID := ""
+	if u.CompanyID != nil {
+		companyID = u.CompanyID.String()
+	}
+	token, err := middleware.GenerateToken(u.ID.String(), roleName, u.Name, u.Phone, companyID)
 	if err != nil {
-		http.Error(w, "couldn't create token", http.StatusInternalServerError)
-		return
+		return loginResp{}, err
 	}
-	tokenBuildDuration = time.Since(tokenBuildStart)
 	u.PasswordHash = "" // don't leak password hash
 
 	// Check if user is super admin
@@ -243,7 +271,7 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		}
 	}(loginEvent)
 
-	out := loginResp{
+	return loginResp{
 		Token: token,
 		User: userPayload{
 			ID:           u.ID,
@@ -254,18 +282,7 @@ func Login(w http.ResponseWriter, r *http.Request) {
 			Role:         roleName,
 			IsSuperAdmin: isSuperAdmin,
 		},
-	}
-	json.NewEncoder(w).Encode(out)
-
-	totalDuration := time.Since(requestStart)
-	if shouldLogSlowLogin(totalDuration) {
-		slog.Warn("slow login request",
-			"duration_ms", totalDuration.Milliseconds(),
-			"db_lookup_ms", dbLookupDuration.Milliseconds(),
-			"password_check_ms", passwordCheckDuration.Milliseconds(),
-			"token_build_ms", tokenBuildDuration.Milliseconds(),
-		)
-	}
+	}, nil
 }
 
 func clientIPFromRequest(r *http.Request) string {
@@ -340,8 +357,9 @@ func GetCurrentUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	businessRoles := []map[string]interface{}{}
+	now := time.Now()
 	for _, ubr := range user.UserBusinessRoles {
-		if ubr.IsActive && ubr.BusinessRole.ID != uuid.Nil {
+		if ubr.IsEffectiveAt(now) && ubr.BusinessRole.ID != uuid.Nil {
 			businessRoles = append(businessRoles, map[string]interface{}{
 				"role_id":       ubr.BusinessRole.ID,
 				"role_name":     ubr.BusinessRole.DisplayName,
@@ -397,7 +415,8 @@ func GetAllUsers(w http.ResponseWriter, r *http.Request) {
 		limit = 100
 	}
 	offset := (page - 1) * limit
-	cacheKey := adminUsersCacheKey(page, limit)
+	companyID := middleware.GetCurrentCompanyID(r)
+	cacheKey := adminUsersCacheKey(companyID, page, limit)
 
 	if payload, ok := adminUsersCache.get(cacheKey); ok {
 		w.Header().Set("Content-Type", "application/json")
@@ -411,12 +430,13 @@ func GetAllUsers(w http.ResponseWriter, r *http.Request) {
 		}
 
 		var users []models.User
-		if err := config.DB.
+		if err := middleware.ScopedDB(r).
 			Preload("RoleModel").
 			Preload("BusinessVertical").
 			Preload("UserBusinessRoles", "is_active = ?", true).
 			Preload("UserBusinessRoles.BusinessRole", "is_active = ?", true).
 			Preload("UserBusinessRoles.BusinessRole.BusinessVertical").
+			Scopes(models.ForCompany(companyID)).
 			Where("is_active = ?", true).
 			Limit(limit).
 			Offset(offset).
@@ -425,8 +445,9 @@ func GetAllUsers(w http.ResponseWriter, r *http.Request) {
 		}
 
 		var total int64
-		if err := config.DB.
+		if err := middleware.ScopedDB(r).
 			Model(&models.User{}).
+			Scopes(models.ForCompany(companyID)).
 			Where("is_active = ?", true).
 			Count(&total).Error; err != nil {
 			return nil, err
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"p9e.in/ugcl/middleware"
+)
+
+// GetAuthCacheStats returns hit/miss and size metrics for the in-process permission cache.
+func GetAuthCacheStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, middleware.GetUserCacheStats())
+}
+
+// InvalidateAuthCache evicts cached permission sets.
+// With ?user_id= only that user is evicted, with ?role_id= every cached holder of the
+// role (global or business) is evicted, and with neither the whole cache is cleared;
+// "evicted" counts the cached users invalidated.
+func InvalidateAuthCache(w http.ResponseWriter, r *http.Request) {
+	userID := strings.TrimSpace(r.URL.Query().Get("user_id"))
+	roleID := strings.TrimSpace(r.URL.Query().Get("role_id"))
+
+	response := map[string]interface{}{}
+	switch {
+	case userID != "":
+		if _, err := uuid.Parse(userID); err != nil {
+			http.Error(w, "invalid user_id", http.StatusBadRequest)
+			return
+		}
+		middleware.InvalidateUserCache(userID)
+		response["scope"] = "user"
+		response["user_id"] = userID
+	case roleID != "":
+		id, err := uuid.Parse(roleID)
+		if err != nil {
+			http.Error(w, "invalid role_id", http.StatusBadRequest)
+			return
+		}
+		response["scope"] = "role"
+		response["role_id"] = id
+		response["evicted"] = middleware.InvalidateUserCacheForRole(id)
+	default:
+		response["scope"] = "all"
+		response["evicted"] = middleware.InvalidateAllUserCaches()
+	}
+
+	response["stats"] = middleware.GetUserCacheStats()
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+type otpReq struct {
+	Phone string `json:"phone"`
+	Code  string `json:"code"`
+}
+
+// RequestLoginOTP texts a one-time login code to a registered phone, for field staff who
+// sign in without a password. The response is the same whether or not the number is
+// registered; a new code can be requested once a minute.
+// POST /api/v1/login/otp
+func RequestLoginOTP(w http.ResponseWriter, r *http.Request) {
+	var req otpReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Phone) == "" {
+		http.Error(w, "phone is required", http.StatusBadRequest)
+		return
+	}
+	if smsGateway() == nil {
+		http.Error(w, "login by SMS is not available", http.StatusServiceUnavailable)
+		return
+	}
+	accepted := map[string]interface{}{
+		"message":            "if the number is registered, a code has been sent",
+		"expires_in_seconds": int(models.OTPTTL.Seconds()),
+	}
+
+	var u models.User
+	if err := middleware.ScopedDB(r).
+		Select("id", "phone").
+		Where("phone = ? AND is_active = ?", strings.TrimSpace(req.Phone), true).
+		Take(&u).Error; err != nil {
+		writeJSON(w, http.StatusAccepted, accepted)
+		return
+	}
+
+	now := time.Now()
+	var recent int64
+	middleware.ScopedDB(r).Model(&models.SMSOTP{}).
+		Where("user_id = ? AND created_at > ?", u.ID, now.Add(-models.OTPResendInterval)).
+		Count(&recent)
+	if recent > 0 {
+		http.Error(w, "a code was sent recently, try again in a minute", http.StatusTooManyRequests)
+		return
+	}
+
+	otp, code, err := models.NewSMSOTP(u.ID, u.Phone, now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := middleware.ScopedDB(r).Create(otp).Error; err != nil {
+		log.Printf("❌ Failed to store login code for %s: %v", u.ID, err)
+		http.Error(w, "failed to send code", http.StatusInternalServerError)
+		return
+	}
+	text := fmt.Sprintf("%s is your UGCL login code. It expires in %d minutes. Do not share it.",
+		code, int(models.OTPTTL.Minutes()))
+	if _, err := NewSMSService().Send(r.Context(), u.ID.String(), u.Phone, models.SMSPurposeOTP, &otp.ID, text); err != nil {
+		log.Printf("❌ Failed to text login code to %s: %v", u.ID, err)
+		http.Error(w, "failed to send code", http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, accepted)
+}
+
+// VerifyLoginOTP signs in with the latest code texted to the phone and returns the same
+// response as a password login. Each code allows a few attempts and is used once.
+// POST /api/v1/login/otp/verify
+func VerifyLoginOTP(w http.ResponseWriter, r *http.Request) {
+	var req otpReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Phone) == "" || strings.TrimSpace(req.Code) == "" {
+		http.Error(w, "phone and code are required", http.StatusBadRequest)
+		return
+	}
+
+	var u models.User
+	if err := middleware.ScopedDB(r).
+		Select("id", "name", "email", "phone", "role_id", "company_id").
+		Where("phone = ? AND is_active = ?", strings.TrimSpace(req.Phone), true).
+		Take(&u).Error; err != nil {
+		http.Error(w, "invalid code", http.StatusUnauthorized)
+		return
+	}
+
+	var verifyErr error
+	err := middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		var otp models.SMSOTP
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ?", u.ID).
+			Order("created_at DESC").
+			First(&otp).Error; err != nil {
+			verifyErr = models.ErrOTPWrongCode
+			return nil
+		}
+		verifyErr = otp.Verify(strings.TrimSpace(req.Code), time.Now())
+		return tx.Model(&otp).Updates(map[string]interface{}{"attempts": otp.Attempts, "consumed_at": otp.ConsumedAt}).Error
+	})
+	if err != nil {
+		log.Printf("❌ Failed to verify login code for %s: %v", u.ID, err)
+		http.Error(w, "failed to verify code", http.StatusInternalServerError)
+		return
+	}
+	if verifyErr != nil {
+		message := "invalid code"
+		if !errors.Is(verifyErr, models.ErrOTPWrongCode) {
+			message = verifyErr.Error()
+		}
+		http.Error(w, message, http.StatusUnauthorized)
+		return
+	}
+
+	out, err := issueLogin(r.Context(), r, &u)
+	if err != nil {
+		http.Error(w, "couldn't create token", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(out)
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+type activateBreakGlassRequest struct {
+	Permissions        []string   `json:"permissions"`
+	BusinessVerticalID *uuid.UUID `json:"business_vertical_id,omitempty"`
+	DurationMinutes    int        `json:"duration_minutes"`
+	Justification      string     `json:"justification"`
+}
+
+// breakGlassMaxDuration is the longest a grant may run (BREAK_GLASS_MAX_DURATION_MINUTES, default 4h)
+func breakGlassMaxDuration() time.Duration {
+	if minutes, err := strconv.Atoi(os.Getenv("BREAK_GLASS_MAX_DURATION_MINUTES")); err == nil && minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return 4 * time.Hour
+}
+
+// ActivateBreakGlass grants the caller the requested permissions immediately for a limited
+// time. A justification is mandatory, super admins are alerted, and every request the
+// grant authorises is audited. Only one grant may be active per user.
+// POST /api/v1/break-glass
+func ActivateBreakGlass(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		http.Error(w, "invalid user ID in claims", http.StatusUnauthorized)
+		return
+	}
+
+	var req activateBreakGlassRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	req.Justification = strings.TrimSpace(req.Justification)
+	if len(req.Justification) < models.BreakGlassMinJustification {
+		http.Error(w, fmt.Sprintf("justification must be at least %d characters", models.BreakGlassMinJustification), http.StatusBadRequest)
+		return
+	}
+	duration := models.BreakGlassDefaultDuration
+	if req.DurationMinutes > 0 {
+		duration = time.Duration(req.DurationMinutes) * time.Minute
+	}
+	if max := breakGlassMaxDuration(); duration > max {
+		http.Error(w, fmt.Sprintf("duration may not exceed %d minutes", int(max.Minutes())), http.StatusBadRequest)
+		return
+	}
+	permissions, err := models.NormalizeBreakGlassPermissions(req.Permissions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var known []string
+	if err := middleware.ScopedDB(r).Model(&models.Permission{}).Where("name IN ?", permissions).Pluck("name", &known).Error; err != nil {
+		http.Error(w, "failed to validate permissions", http.StatusInternalServerError)
+		return
+	}
+	if len(known) != len(permissions) {
+		http.Error(w, "all permissions must be catalog permissions", http.StatusBadRequest)
+		return
+	}
+
+	if req.BusinessVerticalID != nil {
+		var vertical models.BusinessVertical
+		if err := middleware.ScopedDB(r).Where("id = ? AND is_active = ?", *req.BusinessVerticalID, true).First(&vertical).Error; err != nil {
+			http.Error(w, "business vertical not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	var active int64
+	if err := middleware.ScopedDB(r).Model(&models.BreakGlassGrant{}).
+		Where("user_id = ? AND status = ? AND expires_at > ?", userID, models.BreakGlassActive, time.Now()).
+		Count(&active).Error; err != nil {
+		http.Error(w, "failed to check active grants", http.StatusInternalServerError)
+		return
+	}
+	if active > 0 {
+		http.Error(w, "you already have an active break-glass grant; end it before activating another", http.StatusConflict)
+		return
+	}
+
+	now := time.Now()
+	grant := models.BreakGlassGrant{
+		UserID:             userID,
+		BusinessVerticalID: req.BusinessVerticalID,
+		Permissions:        permissions,
+		Justification:      req.Justification,
+		Status:             models.BreakGlassActive,
+		ActivatedAt:        now,
+		ExpiresAt:          now.Add(duration),
+	}
+	if err := middleware.ScopedDB(r).Create(&grant).Error; err != nil {
+		http.Error(w, "failed to activate break-glass access", http.StatusInternalServerError)
+		return
+	}
+
+	middleware.RecordBreakGlassEvent(r, &grant, userID, models.BreakGlassEventActivated, strings.Join(permissions, ","), req.Justification)
+
+	name := claims.Name
+	if name == "" {
+		name = claims.UserID
+	}
+	middleware.NotifySuperAdmins("Break-glass access activated",
+		fmt.Sprintf("%s activated %s until %s. Justification: %s", name, strings.Join(permissions, ", "), grant.ExpiresAt.Format("02 Jan 2006 15:04"), req.Justification),
+		&grant, models.NotificationPriorityCritical)
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"grant": grant})
+}
+
+// ListMyBreakGlassGrants returns the caller's break-glass grants, newest first
+// GET /api/v1/break-glass
+func ListMyBreakGlassGrants(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var grants []models.BreakGlassGrant
+	if err := middleware.ScopedDB(r).Where("user_id = ?", claims.UserID).
+		Order("created_at DESC").Limit(50).
+		Find(&grants).Error; err != nil {
+		http.Error(w, "failed to load break-glass grants", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"grants": grants})
+}
+
+// EndBreakGlassGrant ends an active grant early; the holder may end their own grant and
+// super admins may revoke anyone's. Body {"reason": "..."} is optional for the holder.
+// POST /api/v1/break-glass/{id}/end
+// POST /api/v1/admin/break-glass/{id}/revoke
+func EndBreakGlassGrant(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	actorID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		http.Error(w, "invalid user ID in claims", http.StatusUnauthorized)
+		return
+	}
+	grantID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid grant ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var grant models.BreakGlassGrant
+	if err := middleware.ScopedDB(r).First(&grant, "id = ?", grantID).Error; err != nil {
+		http.Error(w, "break-glass grant not found", http.StatusNotFound)
+		return
+	}
+	isSuperAdmin := middleware.IsSuperAdmin(actorID)
+	if grant.UserID != actorID && !isSuperAdmin {
+		http.Error(w, "break-glass grant not found", http.StatusNotFound)
+		return
+	}
+	if grant.UserID != actorID && strings.TrimSpace(req.Reason) == "" {
+		http.Error(w, "reason is required to revoke another user's grant", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	result := middleware.ScopedDB(r).Model(&models.BreakGlassGrant{}).
+		Where("id = ? AND status = ?", grant.ID, models.BreakGlassActive).
+		Updates(map[string]interface{}{
+			"status":     models.BreakGlassRevoked,
+			"ended_at":   now,
+			"ended_by":   actorID,
+			"end_reason": strings.TrimSpace(req.Reason),
+		})
+	if result.Error != nil {
+		http.Error(w, "failed to end break-glass grant", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "break-glass grant is no longer active", http.StatusConflict)
+		return
+	}
+
+	middleware.RecordBreakGlassEvent(r, &grant, actorID, models.BreakGlassEventRevoked, "", strings.TrimSpace(req.Reason))
+
+	middleware.ScopedDB(r).First(&grant, "id = ?", grant.ID)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"grant": grant})
+}
+
+// ListBreakGlassGrants lists every user's grants for super admin review
+// (?status=&user_id=&limit=)
+// GET /api/v1/admin/break-glass
+func ListBreakGlassGrants(w http.ResponseWriter, r *http.Request) {
+	query := middleware.ScopedDB(r).Preload("User").Preload("BusinessVertical")
+	if status := strings.TrimSpace(r.URL.Query().Get("status")); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if userID, err := uuid.Parse(r.URL.Query().Get("user_id")); err == nil {
+		query = query.Where("user_id = ?", userID)
+	}
+	limit := 100
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 500 {
+		limit = l
+	}
+
+	var grants []models.BreakGlassGrant
+	if err := query.Order("created_at DESC").Limit(limit).Find(&grants).Error; err != nil {
+		http.Error(w, "failed to load break-glass grants", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"grants": grants})
+}
+
+// GetBreakGlassGrant returns a grant with its full audit trail
+// GET /api/v1/admin/break-glass/{id}
+func GetBreakGlassGrant(w http.ResponseWriter, r *http.Request) {
+	grantID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid grant ID", http.StatusBadRequest)
+		return
+	}
+
+	var grant models.BreakGlassGrant
+	if err := middleware.ScopedDB(r).Preload("User").Preload("BusinessVertical").First(&grant, "id = ?", grantID).Error; err != nil {
+		http.Error(w, "break-glass grant not found", http.StatusNotFound)
+		return
+	}
+
+	var events []models.BreakGlassEvent
+	if err := middleware.ScopedDB(r).Where("grant_id = ?", grant.ID).Order("created_at").Find(&events).Error; err != nil {
+		http.Error(w, "failed to load break-glass events", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"grant": grant, "events": events})
+}
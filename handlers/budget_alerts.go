@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/budgetalert"
+)
+
+// checkBudgetAlerts evaluates a project's budget thresholds after its spend changed. A
+// failure is only logged: the change that prompted it already committed.
+func checkBudgetAlerts(db *gorm.DB, projectID uuid.UUID) {
+	if _, err := budgetalert.NewEvaluator(db).Evaluate(projectID); err != nil {
+		log.Printf("❌ Failed to evaluate budget alerts of project %s: %v", projectID, err)
+	}
+}
+
+// allocationProjectID is the project a budget allocation belongs to, directly or through
+// its task
+func allocationProjectID(db *gorm.DB, allocation models.BudgetAllocation) (uuid.UUID, bool) {
+	if allocation.ProjectID != nil {
+		return *allocation.ProjectID, true
+	}
+	if allocation.TaskID == nil {
+		return uuid.Nil, false
+	}
+	var task models.Tasks
+	if err := db.Select("project_id").First(&task, "id = ?", *allocation.TaskID).Error; err != nil {
+		return uuid.Nil, false
+	}
+	return task.ProjectID, true
+}
+
+// loadBudgetProject loads the project in the request within the caller's data scope
+func (h *BudgetHandler) loadBudgetProject(w http.ResponseWriter, r *http.Request) (*models.Project, bool) {
+	projectID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return nil, false
+	}
+	var project models.Project
+	if err := middleware.WithDataScope(r, h.db).First(&project, "id = ? AND deleted_at IS NULL", projectID).Error; err != nil {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return nil, false
+	}
+	return &project, true
+}
+
+// GetBudgetThresholds returns the project's budget thresholds. default is true while the
+// project has not set its own.
+// GET /api/v1/budget/projects/{id}/thresholds
+func (h *BudgetHandler) GetBudgetThresholds(w http.ResponseWriter, r *http.Request) {
+	project, ok := h.loadBudgetProject(w, r)
+	if !ok {
+		return
+	}
+
+	thresholds, err := budgetalert.NewEvaluator(middleware.WithDataScope(r, h.db)).Thresholds(project.ID)
+	if err != nil {
+		http.Error(w, "Failed to load thresholds", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"project_id": project.ID,
+		"thresholds": thresholds,
+		"default":    thresholds[0].ID == uuid.Nil,
+	})
+}
+
+// SetBudgetThresholds replaces the project's budget thresholds and evaluates them. An
+// empty list restores the defaults.
+// PUT /api/v1/budget/projects/{id}/thresholds
+func (h *BudgetHandler) SetBudgetThresholds(w http.ResponseWriter, r *http.Request) {
+	project, ok := h.loadBudgetProject(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Thresholds []struct {
+			Scope    string                      `json:"scope"`
+			Percent  float64                     `json:"percent"`
+			Priority models.NotificationPriority `json:"priority"`
+			IsActive *bool                       `json:"is_active"`
+		} `json:"thresholds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	claims := middleware.GetClaims(r)
+	thresholds := make([]models.BudgetThreshold, 0, len(req.Thresholds))
+	seen := map[string]bool{}
+	for _, t := range req.Thresholds {
+		switch t.Scope {
+		case models.BudgetScopeProject, models.BudgetScopeTask, models.BudgetScopeAllocation:
+		default:
+			http.Error(w, "scope must be project, task or allocation", http.StatusBadRequest)
+			return
+		}
+		if t.Percent <= 0 || t.Percent > 1000 {
+			http.Error(w, "percent must be above 0 and at most 1000", http.StatusBadRequest)
+			return
+		}
+		key := fmt.Sprintf("%s/%.2f", t.Scope, t.Percent)
+		if seen[key] {
+			http.Error(w, fmt.Sprintf("duplicate %s threshold at %.2f%%", t.Scope, t.Percent), http.StatusBadRequest)
+			return
+		}
+		seen[key] = true
+
+		threshold := models.BudgetThreshold{
+			ProjectID: project.ID,
+			Scope:     t.Scope,
+			Percent:   t.Percent,
+			Priority:  t.Priority,
+			IsActive:  t.IsActive == nil || *t.IsActive,
+			CreatedBy: claims.UserID,
+		}
+		switch threshold.Priority {
+		case "":
+			threshold.Priority = models.NotificationPriorityHigh
+		case models.NotificationPriorityLow, models.NotificationPriorityNormal, models.NotificationPriorityHigh, models.NotificationPriorityCritical:
+		default:
+			http.Error(w, "priority must be low, normal, high or critical", http.StatusBadRequest)
+			return
+		}
+		thresholds = append(thresholds, threshold)
+	}
+
+	if err := middleware.WithDataScope(r, h.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("project_id = ?", project.ID).Delete(&models.BudgetThreshold{}).Error; err != nil {
+			return err
+		}
+		if len(thresholds) == 0 {
+			return nil
+		}
+		return tx.Create(&thresholds).Error
+	}); err != nil {
+		http.Error(w, "Failed to save thresholds", http.StatusInternalServerError)
+		return
+	}
+
+	checkBudgetAlerts(middleware.WithDataScope(r, h.db), project.ID)
+
+	log.Printf("✅ Set %d budget thresholds on project %s", len(thresholds), project.ID)
+	h.GetBudgetThresholds(w, r)
+}
+
+// GetOverBudget lists what in the project has reached a budget threshold: the project,
+// tasks or allocations, with their budget, spend and the highest threshold reached,
+// most used first. The thresholds are evaluated first so the list is current.
+// ?scope= narrows it to project, task or allocation.
+// GET /api/v1/budget/projects/{id}/over-budget
+func (h *BudgetHandler) GetOverBudget(w http.ResponseWriter, r *http.Request) {
+	project, ok := h.loadBudgetProject(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := budgetalert.NewEvaluator(middleware.WithDataScope(r, h.db)).Evaluate(project.ID); err != nil {
+		log.Printf("❌ Failed to evaluate budget alerts of project %s: %v", project.ID, err)
+		http.Error(w, "Failed to evaluate budget", http.StatusInternalServerError)
+		return
+	}
+
+	query := middleware.WithDataScope(r, h.db).Where("project_id = ? AND status = ?", project.ID, models.BudgetAlertOpen)
+	if scope := r.URL.Query().Get("scope"); scope != "" {
+		query = query.Where("scope = ?", scope)
+	}
+	var alerts []models.BudgetAlert
+	if err := query.Order("used_percent DESC, threshold_percent DESC").Find(&alerts).Error; err != nil {
+		http.Error(w, "Failed to load budget alerts", http.StatusInternalServerError)
+		return
+	}
+
+	// One line per subject, at the highest threshold it reached
+	items := []models.BudgetAlert{}
+	listed := map[uuid.UUID]bool{}
+	for _, alert := range alerts {
+		if !listed[alert.SubjectID] {
+			listed[alert.SubjectID] = true
+			items = append(items, alert)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"project_id": project.ID,
+		"items":      items,
+		"count":      len(items),
+	})
+}
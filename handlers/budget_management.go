@@ -91,14 +91,14 @@ func (h *BudgetHandler) CreateBudgetAllocation(w http.ResponseWriter, r *http.Re
 	// Validate project or task exists
 	if req.ProjectID != nil {
 		var project models.Project
-		if err := h.db.First(&project, "id = ?", req.ProjectID).Error; err != nil {
+		if err := middleware.WithDataScope(r, h.db).First(&project, "id = ?", req.ProjectID).Error; err != nil {
 			http.Error(w, "Project not found", http.StatusBadRequest)
 			return
 		}
 	}
 	if req.TaskID != nil {
 		var task models.Tasks
-		if err := h.db.First(&task, "id = ?", req.TaskID).Error; err != nil {
+		if err := middleware.WithDataScope(r, h.db).First(&task, "id = ?", req.TaskID).Error; err != nil {
 			http.Error(w, "Task not found", http.StatusBadRequest)
 			return
 		}
@@ -113,11 +113,14 @@ func (h *BudgetHandler) CreateBudgetAllocation(w http.ResponseWriter, r *http.Re
 		allocationDate = *req.AllocationDate
 	}
 
-	// Set default currency
-	currency := "INR"
-	if req.Currency != "" {
-		currency = req.Currency
+	// Convert at the rate on the allocation date; project and task budgets are kept in the
+	// project's currency
+	currency, rate, err := transactionCurrency(middleware.WithDataScope(r, h.db), req.Currency, allocationDate)
+	if err != nil {
+		writeProcurementErr(w, err, "Failed to create budget allocation")
+		return
 	}
+	rolledUp := models.ConvertAmount(req.PlannedAmount, rate, projectExchangeRate(middleware.WithDataScope(r, h.db), req.ProjectID, req.TaskID))
 
 	// Create budget allocation
 	allocation := models.BudgetAllocation{
@@ -128,6 +131,7 @@ func (h *BudgetHandler) CreateBudgetAllocation(w http.ResponseWriter, r *http.Re
 		PlannedAmount:  req.PlannedAmount,
 		ActualAmount:   0,
 		Currency:       currency,
+		ExchangeRate:   rate,
 		AllocationDate: allocationDate,
 		StartDate:      req.StartDate,
 		EndDate:        req.EndDate,
@@ -137,7 +141,7 @@ func (h *BudgetHandler) CreateBudgetAllocation(w http.ResponseWriter, r *http.Re
 	}
 
 	// Start transaction
-	tx := h.db.Begin()
+	tx := middleware.WithDataScope(r, h.db).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -155,11 +159,11 @@ func (h *BudgetHandler) CreateBudgetAllocation(w http.ResponseWriter, r *http.Re
 	if req.ProjectID != nil {
 		tx.Model(&models.Project{}).
 			Where("id = ?", req.ProjectID).
-			Update("allocated_budget", gorm.Expr("allocated_budget + ?", req.PlannedAmount))
+			Update("allocated_budget", gorm.Expr("allocated_budget + ?", rolledUp))
 	} else if req.TaskID != nil {
 		tx.Model(&models.Tasks{}).
 			Where("id = ?", req.TaskID).
-			Update("allocated_budget", gorm.Expr("allocated_budget + ?", req.PlannedAmount))
+			Update("allocated_budget", gorm.Expr("allocated_budget + ?", rolledUp))
 	}
 
 	if err := tx.Commit().Error; err != nil {
@@ -182,7 +186,7 @@ func (h *BudgetHandler) GetBudgetAllocation(w http.ResponseWriter, r *http.Reque
 	allocationID := vars["id"]
 
 	var allocation models.BudgetAllocation
-	if err := h.db.
+	if err := middleware.WithDataScope(r, h.db).
 		Preload("Project").
 		Preload("Task").
 		First(&allocation, "id = ?", allocationID).Error; err != nil {
@@ -198,7 +202,7 @@ func (h *BudgetHandler) GetBudgetAllocation(w http.ResponseWriter, r *http.Reque
 func (h *BudgetHandler) ListBudgetAllocations(w http.ResponseWriter, r *http.Request) {
 	var allocations []models.BudgetAllocation
 
-	query := h.db.Preload("Project").Preload("Task")
+	query := middleware.WithDataScope(r, h.db).Preload("Project").Preload("Task")
 
 	// Apply filters
 	if projectID := r.URL.Query().Get("project_id"); projectID != "" {
@@ -238,13 +242,13 @@ func (h *BudgetHandler) UpdateBudgetAllocation(w http.ResponseWriter, r *http.Re
 	}
 
 	var allocation models.BudgetAllocation
-	if err := h.db.First(&allocation, "id = ?", allocationID).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).First(&allocation, "id = ?", allocationID).Error; err != nil {
 		http.Error(w, "Budget allocation not found", http.StatusNotFound)
 		return
 	}
 
 	// Start transaction
-	tx := h.db.Begin()
+	tx := middleware.WithDataScope(r, h.db).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -253,6 +257,7 @@ func (h *BudgetHandler) UpdateBudgetAllocation(w http.ResponseWriter, r *http.Re
 
 	oldPlannedAmount := allocation.PlannedAmount
 	oldActualAmount := allocation.ActualAmount
+	projectRate := projectExchangeRate(middleware.WithDataScope(r, h.db), allocation.ProjectID, allocation.TaskID)
 
 	// Update fields
 	if req.PlannedAmount > 0 {
@@ -276,7 +281,7 @@ func (h *BudgetHandler) UpdateBudgetAllocation(w http.ResponseWriter, r *http.Re
 
 	// Update project or task budgets if amounts changed
 	if req.PlannedAmount > 0 && oldPlannedAmount != req.PlannedAmount {
-		diff := req.PlannedAmount - oldPlannedAmount
+		diff := models.ConvertAmount(req.PlannedAmount-oldPlannedAmount, allocation.ExchangeRate, projectRate)
 		if allocation.ProjectID != nil {
 			tx.Model(&models.Project{}).
 				Where("id = ?", allocation.ProjectID).
@@ -289,7 +294,7 @@ func (h *BudgetHandler) UpdateBudgetAllocation(w http.ResponseWriter, r *http.Re
 	}
 
 	if req.ActualAmount >= 0 && oldActualAmount != req.ActualAmount {
-		diff := req.ActualAmount - oldActualAmount
+		diff := models.ConvertAmount(req.ActualAmount-oldActualAmount, allocation.ExchangeRate, projectRate)
 		if allocation.ProjectID != nil {
 			tx.Model(&models.Project{}).
 				Where("id = ?", allocation.ProjectID).
@@ -323,6 +328,10 @@ func (h *BudgetHandler) UpdateBudgetAllocation(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	if projectID, ok := allocationProjectID(middleware.WithDataScope(r, h.db), allocation); ok {
+		checkBudgetAlerts(middleware.WithDataScope(r, h.db), projectID)
+	}
+
 	log.Printf("✅ Updated budget allocation: %s", allocationID)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -343,7 +352,7 @@ func (h *BudgetHandler) ApproveBudgetAllocation(w http.ResponseWriter, r *http.R
 	}
 
 	var allocation models.BudgetAllocation
-	if err := h.db.First(&allocation, "id = ?", allocationID).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).First(&allocation, "id = ?", allocationID).Error; err != nil {
 		http.Error(w, "Budget allocation not found", http.StatusNotFound)
 		return
 	}
@@ -358,7 +367,7 @@ func (h *BudgetHandler) ApproveBudgetAllocation(w http.ResponseWriter, r *http.R
 		allocation.Notes = allocation.Notes + "\n[Approval] " + req.ApprovalComment
 	}
 
-	if err := h.db.Save(&allocation).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).Save(&allocation).Error; err != nil {
 		http.Error(w, "Failed to approve budget allocation", http.StatusInternalServerError)
 		return
 	}
@@ -377,19 +386,20 @@ func (h *BudgetHandler) GetProjectBudgetSummary(w http.ResponseWriter, r *http.R
 	projectID := vars["id"]
 
 	var project models.Project
-	if err := h.db.First(&project, "id = ?", projectID).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).First(&project, "id = ?", projectID).Error; err != nil {
 		http.Error(w, "Project not found", http.StatusNotFound)
 		return
 	}
 
-	// Get allocations by category
+	// Get allocations by category, converted to the project's currency
 	var categoryBreakdown []struct {
 		Category      string  `json:"category"`
 		PlannedAmount float64 `json:"planned_amount"`
 		ActualAmount  float64 `json:"actual_amount"`
 	}
-	h.db.Model(&models.BudgetAllocation{}).
-		Select("category, COALESCE(SUM(planned_amount), 0) as planned_amount, COALESCE(SUM(actual_amount), 0) as actual_amount").
+	projectRate := projectExchangeRate(middleware.WithDataScope(r, h.db), &project.ID, nil)
+	middleware.WithDataScope(r, h.db).Model(&models.BudgetAllocation{}).
+		Select("category, COALESCE(SUM(base_planned_amount), 0) / ? as planned_amount, COALESCE(SUM(base_actual_amount), 0) / ? as actual_amount", projectRate, projectRate).
 		Where("project_id = ?", projectID).
 		Group("category").
 		Scan(&categoryBreakdown)
@@ -401,7 +411,7 @@ func (h *BudgetHandler) GetProjectBudgetSummary(w http.ResponseWriter, r *http.R
 		AllocatedBudget float64   `json:"allocated_budget"`
 		TotalCost       float64   `json:"total_cost"`
 	}
-	h.db.Table("tasks").
+	middleware.WithDataScope(r, h.db).Table("tasks").
 		Select("tasks.id as task_id, tasks.title as task_title, tasks.allocated_budget, tasks.total_cost").
 		Where("tasks.project_id = ?", projectID).
 		Scan(&taskBudgets)
@@ -421,6 +431,10 @@ func (h *BudgetHandler) GetProjectBudgetSummary(w http.ResponseWriter, r *http.R
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"project_id":         projectID,
+		"currency":           project.Currency,
+		"base_currency":      models.BaseCurrency,
+		"base_total_budget":  project.BaseTotalBudget,
+		"base_spent_budget":  project.BaseSpentBudget,
 		"total_budget":       project.TotalBudget,
 		"allocated_budget":   project.AllocatedBudget,
 		"spent_budget":       project.SpentBudget,
@@ -440,19 +454,20 @@ func (h *BudgetHandler) GetTaskBudgetSummary(w http.ResponseWriter, r *http.Requ
 	taskID := vars["id"]
 
 	var task models.Tasks
-	if err := h.db.First(&task, "id = ?", taskID).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).First(&task, "id = ?", taskID).Error; err != nil {
 		http.Error(w, "Task not found", http.StatusNotFound)
 		return
 	}
 
-	// Get allocations by category
+	// Get allocations by category, converted to the project's currency
 	var categoryBreakdown []struct {
 		Category      string  `json:"category"`
 		PlannedAmount float64 `json:"planned_amount"`
 		ActualAmount  float64 `json:"actual_amount"`
 	}
-	h.db.Model(&models.BudgetAllocation{}).
-		Select("category, COALESCE(SUM(planned_amount), 0) as planned_amount, COALESCE(SUM(actual_amount), 0) as actual_amount").
+	projectRate := projectExchangeRate(middleware.WithDataScope(r, h.db), &task.ProjectID, nil)
+	middleware.WithDataScope(r, h.db).Model(&models.BudgetAllocation{}).
+		Select("category, COALESCE(SUM(base_planned_amount), 0) / ? as planned_amount, COALESCE(SUM(base_actual_amount), 0) / ? as actual_amount", projectRate, projectRate).
 		Where("task_id = ?", taskID).
 		Group("category").
 		Scan(&categoryBreakdown)
@@ -483,13 +498,13 @@ func (h *BudgetHandler) DeleteBudgetAllocation(w http.ResponseWriter, r *http.Re
 	allocationID := vars["id"]
 
 	var allocation models.BudgetAllocation
-	if err := h.db.First(&allocation, "id = ?", allocationID).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).First(&allocation, "id = ?", allocationID).Error; err != nil {
 		http.Error(w, "Budget allocation not found", http.StatusNotFound)
 		return
 	}
 
 	// Start transaction
-	tx := h.db.Begin()
+	tx := middleware.WithDataScope(r, h.db).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -498,12 +513,13 @@ func (h *BudgetHandler) DeleteBudgetAllocation(w http.ResponseWriter, r *http.Re
 
 	// Update project or task budget
 	if allocation.ProjectID != nil {
+		projectRate := projectExchangeRate(middleware.WithDataScope(r, h.db), allocation.ProjectID, nil)
 		tx.Model(&models.Project{}).
 			Where("id = ?", allocation.ProjectID).
-			Update("allocated_budget", gorm.Expr("allocated_budget - ?", allocation.PlannedAmount))
+			Update("allocated_budget", gorm.Expr("allocated_budget - ?", models.ConvertAmount(allocation.PlannedAmount, allocation.ExchangeRate, projectRate)))
 		tx.Model(&models.Project{}).
 			Where("id = ?", allocation.ProjectID).
-			Update("spent_budget", gorm.Expr("spent_budget - ?", allocation.ActualAmount))
+			Update("spent_budget", gorm.Expr("spent_budget - ?", models.ConvertAmount(allocation.ActualAmount, allocation.ExchangeRate, projectRate)))
 	}
 
 	if err := tx.Delete(&allocation).Error; err != nil {
@@ -517,9 +533,30 @@ func (h *BudgetHandler) DeleteBudgetAllocation(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	if projectID, ok := allocationProjectID(middleware.WithDataScope(r, h.db), allocation); ok {
+		checkBudgetAlerts(middleware.WithDataScope(r, h.db), projectID)
+	}
+
 	log.Printf("✅ Deleted budget allocation: %s", allocationID)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"message": "Budget allocation deleted successfully",
 	})
 }
+
+// projectExchangeRate returns the rate to the base currency of the project a budget rolls
+// up into, directly or through one of its tasks. Project and task budgets are kept in the
+// project's currency.
+func projectExchangeRate(db *gorm.DB, projectID, taskID *uuid.UUID) float64 {
+	var rate float64
+	if projectID != nil {
+		db.Model(&models.Project{}).Where("id = ?", *projectID).Select("exchange_rate").Scan(&rate)
+	} else if taskID != nil {
+		db.Table("projects").Joins("JOIN tasks ON tasks.project_id = projects.id").
+			Where("tasks.id = ?", *taskID).Select("projects.exchange_rate").Scan(&rate)
+	}
+	if rate <= 0 {
+		return 1
+	}
+	return rate
+}
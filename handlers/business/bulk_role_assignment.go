@@ -0,0 +1,317 @@
+package business
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/handlers"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// maxBulkRoleAssignments caps the number of users in one bulk request
+const maxBulkRoleAssignments = 500
+
+// Per-user outcomes reported by BulkAssignBusinessRole
+const (
+	bulkResultAssigned        = "assigned"
+	bulkResultReactivated     = "reactivated"
+	bulkResultAlreadyAssigned = "already_assigned"
+	bulkResultRevoked         = "revoked"
+	bulkResultNotAssigned     = "not_assigned"
+	bulkResultInvalidUserID   = "invalid_user_id"
+	bulkResultUserNotFound    = "user_not_found"
+	bulkResultSoDConflict     = "sod_conflict"
+)
+
+type bulkRoleAssignmentReq struct {
+	Action     string     `json:"action"` // assign or revoke
+	UserIDs    []string   `json:"user_ids"`
+	ValidFrom  *time.Time `json:"valid_from,omitempty"`
+	ValidUntil *time.Time `json:"valid_until,omitempty"`
+	Reason     string     `json:"reason,omitempty"`
+}
+
+type bulkRoleAssignmentResult struct {
+	UserID  string `json:"user_id"`
+	Status  string `json:"status"`
+	Changed bool   `json:"changed"`
+}
+
+// BulkAssignBusinessRole assigns a business role to, or revokes it from, a list of users
+// in one transaction. Every change is written to the role assignment audit log under a
+// shared batch ID; users that cannot be processed are reported without failing the batch.
+// POST /api/v1/business/{businessCode}/roles/{roleId}/assignments/bulk
+func BulkAssignBusinessRole(w http.ResponseWriter, r *http.Request) {
+	businessID := middleware.GetCurrentBusinessID(r)
+	if businessID == uuid.Nil {
+		http.Error(w, "invalid business identifier", http.StatusBadRequest)
+		return
+	}
+
+	roleID, err := uuid.Parse(mux.Vars(r)["roleId"])
+	if err != nil {
+		http.Error(w, "invalid role ID", http.StatusBadRequest)
+		return
+	}
+
+	var req bulkRoleAssignmentReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Action != "assign" && req.Action != "revoke" {
+		http.Error(w, "action must be assign or revoke", http.StatusBadRequest)
+		return
+	}
+	if len(req.UserIDs) == 0 {
+		http.Error(w, "user_ids is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.UserIDs) > maxBulkRoleAssignments {
+		http.Error(w, "too many users in one request", http.StatusBadRequest)
+		return
+	}
+	if req.ValidUntil != nil && (!req.ValidUntil.After(time.Now()) || (req.ValidFrom != nil && !req.ValidUntil.After(*req.ValidFrom))) {
+		http.Error(w, "valid_until must be in the future and after valid_from", http.StatusBadRequest)
+		return
+	}
+
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	actorID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		http.Error(w, "invalid user ID in claims", http.StatusUnauthorized)
+		return
+	}
+
+	var role models.BusinessRole
+	if err := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ?", roleID, businessID).First(&role).Error; err != nil {
+		http.Error(w, "role not found in this business", http.StatusNotFound)
+		return
+	}
+	if req.Action == "assign" && !role.IsActive {
+		http.Error(w, "role is inactive", http.StatusBadRequest)
+		return
+	}
+
+	var actor models.User
+	if err := middleware.ScopedDB(r).
+		Preload("RoleModel").
+		Preload("UserBusinessRoles.BusinessRole").
+		First(&actor, "id = ?", actorID).Error; err != nil {
+		http.Error(w, "current user not found", http.StatusUnauthorized)
+		return
+	}
+	if !actor.CanAssignRole(role.Level) {
+		http.Error(w, "you don't have permission to manage this role", http.StatusForbidden)
+		return
+	}
+
+	// Resolve the requested users up front; unknown and malformed IDs are reported per user
+	results := make([]bulkRoleAssignmentResult, len(req.UserIDs))
+	requested := make([]uuid.UUID, 0, len(req.UserIDs))
+	for i, raw := range req.UserIDs {
+		results[i] = bulkRoleAssignmentResult{UserID: raw}
+		if id, err := uuid.Parse(raw); err == nil {
+			requested = append(requested, id)
+		} else {
+			results[i].Status = bulkResultInvalidUserID
+		}
+	}
+
+	var existingUsers []uuid.UUID
+	if err := middleware.ScopedDB(r).Model(&models.User{}).Where("id IN ?", requested).Pluck("id", &existingUsers).Error; err != nil {
+		http.Error(w, "failed to load users", http.StatusInternalServerError)
+		return
+	}
+	known := make(map[uuid.UUID]bool, len(existingUsers))
+	for _, id := range existingUsers {
+		known[id] = true
+	}
+
+	// Users who already hold the role are left as they are, so only new grants are SoD-checked
+	holders := make(map[uuid.UUID]bool)
+	if req.Action == "assign" {
+		var holderIDs []uuid.UUID
+		if err := middleware.ScopedDB(r).Model(&models.UserBusinessRole{}).
+			Where("business_role_id = ? AND site_id IS NULL AND is_active = ? AND user_id IN ?", role.ID, true, requested).
+			Pluck("user_id", &holderIDs).Error; err != nil {
+			http.Error(w, "failed to load role holders", http.StatusInternalServerError)
+			return
+		}
+		for _, id := range holderIDs {
+			holders[id] = true
+		}
+	}
+	overrideActor, overrideReason := middleware.SoDOverrideReason(r)
+	overridden := make(map[uuid.UUID][]models.SoDRule)
+
+	batchID := uuid.New()
+	now := time.Now()
+	changedUsers := make([]uuid.UUID, 0, len(requested))
+
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		done := make(map[uuid.UUID]string)
+		for i := range results {
+			if results[i].Status != "" {
+				continue
+			}
+			userID := uuid.MustParse(results[i].UserID)
+			if status, dup := done[userID]; dup {
+				results[i].Status = status
+				continue
+			}
+			if !known[userID] {
+				results[i].Status = bulkResultUserNotFound
+				continue
+			}
+
+			if req.Action == "assign" && !holders[userID] {
+				conflicts, err := middleware.RoleAssignmentSoDConflicts(userID, businessID, role.ID, nil)
+				if err != nil {
+					return err
+				}
+				if len(conflicts) > 0 {
+					if overrideReason == "" {
+						done[userID] = bulkResultSoDConflict
+						results[i].Status = bulkResultSoDConflict
+						continue
+					}
+					overridden[userID] = conflicts
+				}
+			}
+
+			var status string
+			var err error
+			if req.Action == "assign" {
+				status, err = bulkAssignRole(tx, userID, role.ID, &actorID, req, now)
+			} else {
+				status, err = bulkRevokeRole(tx, userID, role.ID)
+			}
+			if err != nil {
+				return err
+			}
+			done[userID] = status
+			results[i].Status = status
+
+			auditAction := ""
+			switch status {
+			case bulkResultAssigned:
+				auditAction = models.RoleAuditAssigned
+			case bulkResultReactivated:
+				auditAction = models.RoleAuditReactivated
+			case bulkResultRevoked:
+				auditAction = models.RoleAuditRevoked
+			default:
+				continue
+			}
+			results[i].Changed = true
+			changedUsers = append(changedUsers, userID)
+
+			if err := tx.Create(&models.RoleAssignmentAuditLog{
+				BatchID:            &batchID,
+				BusinessVerticalID: businessID,
+				BusinessRoleID:     role.ID,
+				UserID:             userID,
+				Action:             auditAction,
+				PerformedBy:        &actorID,
+				Reason:             req.Reason,
+				PerformedAt:        now,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		http.Error(w, "bulk role assignment failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for userID, conflicts := range overridden {
+		if err := middleware.RecordSoDOverrides(conflicts, userID, overrideActor, businessID, models.SoDContextRoleAssignment, "bulk:"+batchID.String(), overrideReason); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Evict auth caches so the new permissions are reflected immediately
+	for _, userID := range changedUsers {
+		middleware.InvalidateUserCache(userID.String())
+	}
+	if len(changedUsers) > 0 {
+		handlers.InvalidateAdminUsersCache()
+		handlers.InvalidateUnifiedRolesCache()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"batch_id": batchID,
+		"action":   req.Action,
+		"role_id":  role.ID,
+		"changed":  len(changedUsers),
+		"results":  results,
+	})
+}
+
+// bulkAssignRole grants a vertical-wide role, reactivating a previous assignment if one exists
+func bulkAssignRole(tx *gorm.DB, userID, roleID uuid.UUID, actorID *uuid.UUID, req bulkRoleAssignmentReq, now time.Time) (string, error) {
+	var existing models.UserBusinessRole
+	err := tx.Where("user_id = ? AND business_role_id = ? AND site_id IS NULL", userID, roleID).
+		Order("is_active DESC, assigned_at DESC").
+		First(&existing).Error
+	if err == gorm.ErrRecordNotFound {
+		assignment := models.UserBusinessRole{
+			UserID:         userID,
+			BusinessRoleID: roleID,
+			IsActive:       true,
+			AssignedAt:     now,
+			AssignedBy:     actorID,
+			ValidFrom:      req.ValidFrom,
+			ValidUntil:     req.ValidUntil,
+		}
+		if err := tx.Create(&assignment).Error; err != nil {
+			return "", err
+		}
+		return bulkResultAssigned, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if existing.IsActive {
+		return bulkResultAlreadyAssigned, nil
+	}
+
+	if err := tx.Model(&existing).Updates(map[string]interface{}{
+		"is_active":   true,
+		"assigned_at": now,
+		"assigned_by": actorID,
+		"valid_from":  req.ValidFrom,
+		"valid_until": req.ValidUntil,
+	}).Error; err != nil {
+		return "", err
+	}
+	return bulkResultReactivated, nil
+}
+
+// bulkRevokeRole deactivates the user's vertical-wide assignments of the role
+func bulkRevokeRole(tx *gorm.DB, userID, roleID uuid.UUID) (string, error) {
+	result := tx.Model(&models.UserBusinessRole{}).
+		Where("user_id = ? AND business_role_id = ? AND site_id IS NULL AND is_active = ?", userID, roleID, true).
+		Update("is_active", false)
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return bulkResultNotAssigned, nil
+	}
+	return bulkResultRevoked, nil
+}
@@ -2,7 +2,10 @@ package business
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -11,6 +14,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
 	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/handlers"
 	"p9e.in/ugcl/middleware"
@@ -73,25 +77,28 @@ var businessVerticalsCache = &businessVerticalsResponseCache{entries: make(map[s
 var businessVerticalsLoadGroup singleflight.Group
 
 type createBusinessReq struct {
-	Name        string `json:"name"`
-	Code        string `json:"code"`
-	Description string `json:"description"`
+	Name        string     `json:"name"`
+	Code        string     `json:"code"`
+	Description string     `json:"description"`
+	CompanyID   *uuid.UUID `json:"company_id,omitempty"` // super admins only; defaults to the caller's company
 }
 
 type updateBusinessReq struct {
-	Name        *string `json:"name"`
-	Description *string `json:"description"`
-	IsActive    *bool   `json:"is_active"`
+	Name        *string    `json:"name"`
+	Description *string    `json:"description"`
+	IsActive    *bool      `json:"is_active"`
+	CompanyID   *uuid.UUID `json:"company_id,omitempty"` // super admins only: move the vertical to another company
 }
 
 type businessResponse struct {
-	ID          uuid.UUID `json:"id"`
-	Name        string    `json:"name"`
-	Code        string    `json:"code"`
-	Description string    `json:"description"`
-	IsActive    bool      `json:"is_active"`
-	UserCount   int64     `json:"user_count"`
-	RoleCount   int64     `json:"role_count"`
+	ID          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	Code        string     `json:"code"`
+	Description string     `json:"description"`
+	IsActive    bool       `json:"is_active"`
+	CompanyID   *uuid.UUID `json:"company_id,omitempty"`
+	UserCount   int64      `json:"user_count"`
+	RoleCount   int64      `json:"role_count"`
 }
 
 type createBusinessRoleReq struct {
@@ -101,6 +108,7 @@ type createBusinessRoleReq struct {
 	Level         int             `json:"level"`
 	Permissions   json.RawMessage `json:"permissions"`
 	PermissionIDs []string        `json:"permission_ids"`
+	Version       *int            `json:"version,omitempty"` // on update, the version being edited; stale versions are rejected
 }
 
 type businessRoleResponse struct {
@@ -113,6 +121,7 @@ type businessRoleResponse struct {
 	BusinessVertical   string               `json:"business_vertical_name"`
 	Permissions        []permissionResponse `json:"permissions"`
 	UserCount          int64                `json:"user_count"`
+	Version            int                  `json:"version"`
 }
 
 type assignUserRoleReq struct {
@@ -120,16 +129,26 @@ type assignUserRoleReq struct {
 	BusinessRoleID string `json:"business_role_id"`
 }
 
-func resolveRolePermissionIDs(req createBusinessRoleReq) ([]uuid.UUID, error) {
-	idSet := make(map[uuid.UUID]struct{})
+// resolveRolePermissionIDs maps the requested permissions (IDs, names, or {id,name}
+// objects) to catalog IDs. Entries that match no catalog permission are returned as
+// unknown so callers can reject the request instead of silently dropping them.
+func resolveRolePermissionIDs(req createBusinessRoleReq) ([]uuid.UUID, []string, error) {
+	idSet := make(map[uuid.UUID]string)
 	permissionNames := make(map[string]struct{})
+	var unknown []string
 
-	for _, rawID := range req.PermissionIDs {
-		parsedID, err := uuid.Parse(strings.TrimSpace(rawID))
+	addID := func(raw string) {
+		trimmed := strings.TrimSpace(raw)
+		parsedID, err := uuid.Parse(trimmed)
 		if err != nil {
-			continue
+			unknown = append(unknown, raw)
+			return
 		}
-		idSet[parsedID] = struct{}{}
+		idSet[parsedID] = trimmed
+	}
+
+	for _, rawID := range req.PermissionIDs {
+		addID(rawID)
 	}
 
 	if len(req.Permissions) > 0 {
@@ -139,8 +158,8 @@ func resolveRolePermissionIDs(req createBusinessRoleReq) ([]uuid.UUID, error) {
 		}
 		if err := json.Unmarshal(req.Permissions, &permObjects); err == nil && len(permObjects) > 0 {
 			for _, p := range permObjects {
-				if parsedID, err := uuid.Parse(strings.TrimSpace(p.ID)); err == nil {
-					idSet[parsedID] = struct{}{}
+				if strings.TrimSpace(p.ID) != "" {
+					addID(p.ID)
 					continue
 				}
 				if name := strings.TrimSpace(p.Name); name != "" {
@@ -149,14 +168,15 @@ func resolveRolePermissionIDs(req createBusinessRoleReq) ([]uuid.UUID, error) {
 			}
 		} else {
 			var permNames []string
-			if err := json.Unmarshal(req.Permissions, &permNames); err == nil {
-				for _, name := range permNames {
-					trimmed := strings.TrimSpace(name)
-					if trimmed == "" {
-						continue
-					}
-					permissionNames[trimmed] = struct{}{}
+			if err := json.Unmarshal(req.Permissions, &permNames); err != nil {
+				return nil, nil, errors.New("permissions must be a list of names or {id, name} objects")
+			}
+			for _, name := range permNames {
+				trimmed := strings.TrimSpace(name)
+				if trimmed == "" {
+					continue
 				}
+				permissionNames[trimmed] = struct{}{}
 			}
 		}
 	}
@@ -168,17 +188,22 @@ func resolveRolePermissionIDs(req createBusinessRoleReq) ([]uuid.UUID, error) {
 		}
 
 		var permissionsByName []models.Permission
-		if err := config.DB.Select("id").Where("name IN ?", names).Find(&permissionsByName).Error; err != nil {
-			return nil, err
+		if err := config.DB.Select("id", "name").Where("name IN ?", names).Find(&permissionsByName).Error; err != nil {
+			return nil, nil, err
 		}
 
 		for _, permission := range permissionsByName {
-			idSet[permission.ID] = struct{}{}
+			idSet[permission.ID] = permission.Name
+			delete(permissionNames, permission.Name)
+		}
+		for name := range permissionNames {
+			unknown = append(unknown, name)
 		}
 	}
 
 	if len(idSet) == 0 {
-		return nil, nil
+		sort.Strings(unknown)
+		return nil, unknown, nil
 	}
 
 	requestedIDs := make([]uuid.UUID, 0, len(idSet))
@@ -188,10 +213,89 @@ func resolveRolePermissionIDs(req createBusinessRoleReq) ([]uuid.UUID, error) {
 
 	var existingIDs []uuid.UUID
 	if err := config.DB.Model(&models.Permission{}).Where("id IN ?", requestedIDs).Pluck("id", &existingIDs).Error; err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	for _, id := range existingIDs {
+		delete(idSet, id)
+	}
+	for _, raw := range idSet {
+		unknown = append(unknown, raw)
+	}
+	sort.Strings(unknown)
+
+	return existingIDs, unknown, nil
+}
+
+var errRoleVersionConflict = errors.New("role version conflict")
+
+// writeUnknownPermissions rejects a role definition that names permissions outside the catalog
+func writeUnknownPermissions(w http.ResponseWriter, unknown []string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":               "unknown permissions; see GET /api/v1/permissions for the catalog",
+		"unknown_permissions": unknown,
+	})
+}
+
+// recordBusinessRoleVersion snapshots the role's current definition and permissions
+func recordBusinessRoleVersion(tx *gorm.DB, roleID uuid.UUID, changedBy *uuid.UUID) error {
+	var role models.BusinessRole
+	if err := tx.Preload("Permissions").First(&role, "id = ?", roleID).Error; err != nil {
+		return err
+	}
+	names := make([]string, len(role.Permissions))
+	for i, perm := range role.Permissions {
+		names[i] = perm.Name
+	}
+	sort.Strings(names)
+
+	return tx.Create(&models.BusinessRoleVersion{
+		BusinessRoleID: role.ID,
+		Version:        role.Version,
+		Name:           role.Name,
+		DisplayName:    role.DisplayName,
+		Description:    role.Description,
+		Level:          role.Level,
+		Permissions:    names,
+		ChangedBy:      changedBy,
+	}).Error
+}
+
+// roleChangeActor returns the requesting user's ID for version attribution
+func roleChangeActor(r *http.Request) *uuid.UUID {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		return nil
+	}
+	id, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return nil
+	}
+	return &id
+}
+
+// verticalCompanyForRequest resolves the company a vertical is created in or moved to.
+// Only super admins may name a company; everyone else gets their own.
+func verticalCompanyForRequest(w http.ResponseWriter, r *http.Request, requested *uuid.UUID) (*uuid.UUID, bool) {
+	if requested == nil || *requested == uuid.Nil {
+		if companyID := middleware.GetCurrentCompanyID(r); companyID != uuid.Nil {
+			return &companyID, true
+		}
+		return nil, true
 	}
 
-	return existingIDs, nil
+	if claims := middleware.GetClaims(r); claims == nil || claims.Role != "super_admin" {
+		http.Error(w, "only super admins can assign verticals to a company", http.StatusForbidden)
+		return nil, false
+	}
+	var count int64
+	middleware.ScopedDB(r).Model(&models.Company{}).Where("id = ? AND is_active = ?", *requested, true).Count(&count)
+	if count == 0 {
+		http.Error(w, "company not found", http.StatusBadRequest)
+		return nil, false
+	}
+	return requested, true
 }
 
 // GetAllBusinessVerticals returns all business verticals
@@ -209,7 +313,8 @@ func GetAllBusinessVerticals(w http.ResponseWriter, r *http.Request) {
 		limit = l
 	}
 
-	cacheKey := strconv.Itoa(page) + ":" + strconv.Itoa(limit)
+	companyID := middleware.GetCurrentCompanyID(r)
+	cacheKey := companyID.String() + ":" + strconv.Itoa(page) + ":" + strconv.Itoa(limit)
 	if payload, ok := businessVerticalsCache.get(cacheKey); ok {
 		w.Header().Set("Content-Type", "application/json")
 		w.Write(payload)
@@ -224,7 +329,7 @@ func GetAllBusinessVerticals(w http.ResponseWriter, r *http.Request) {
 		offset := (page - 1) * limit
 
 		var businesses []models.BusinessVertical
-		if err := config.DB.Where("is_active = ?", true).
+		if err := middleware.ScopedDB(r).Scopes(models.ForCompany(companyID)).Where("is_active = ?", true).
 			Limit(limit).
 			Offset(offset).
 			Find(&businesses).Error; err != nil {
@@ -232,7 +337,7 @@ func GetAllBusinessVerticals(w http.ResponseWriter, r *http.Request) {
 		}
 
 		var total int64
-		if err := config.DB.Model(&models.BusinessVertical{}).Where("is_active = ?", true).Count(&total).Error; err != nil {
+		if err := middleware.ScopedDB(r).Model(&models.BusinessVertical{}).Scopes(models.ForCompany(companyID)).Where("is_active = ?", true).Count(&total).Error; err != nil {
 			return nil, err
 		}
 
@@ -242,7 +347,7 @@ func GetAllBusinessVerticals(w http.ResponseWriter, r *http.Request) {
 			BusinessVerticalID uuid.UUID
 			Count              int64
 		}
-		config.DB.Model(&models.User{}).
+		middleware.ScopedDB(r).Model(&models.User{}).
 			Select("business_vertical_id, COUNT(*) as count").
 			Where("business_vertical_id IN ?", func() []uuid.UUID {
 				ids := make([]uuid.UUID, len(businesses))
@@ -264,7 +369,7 @@ func GetAllBusinessVerticals(w http.ResponseWriter, r *http.Request) {
 			BusinessVerticalID uuid.UUID
 			Count              int64
 		}
-		config.DB.Model(&models.BusinessRole{}).
+		middleware.ScopedDB(r).Model(&models.BusinessRole{}).
 			Select("business_vertical_id, COUNT(*) as count").
 			Where("business_vertical_id IN ? AND is_active = ?", func() []uuid.UUID {
 				ids := make([]uuid.UUID, len(businesses))
@@ -289,6 +394,7 @@ func GetAllBusinessVerticals(w http.ResponseWriter, r *http.Request) {
 				Code:        business.Code,
 				Description: business.Description,
 				IsActive:    business.IsActive,
+				CompanyID:   business.CompanyID,
 				UserCount:   userCounts[business.ID],
 				RoleCount:   roleCounts[business.ID],
 			}
@@ -324,6 +430,11 @@ func CreateBusinessVertical(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	companyID, ok := verticalCompanyForRequest(w, r, req.CompanyID)
+	if !ok {
+		return
+	}
+
 	defaultSettings := "{}"
 	business := models.BusinessVertical{
 		Name:        req.Name,
@@ -331,19 +442,21 @@ func CreateBusinessVertical(w http.ResponseWriter, r *http.Request) {
 		Description: req.Description,
 		IsActive:    true,
 		Settings:    &defaultSettings,
+		CompanyID:   companyID,
 	}
 
-	if err := config.DB.Create(&business).Error; err != nil {
+	if err := middleware.ScopedDB(r).Create(&business).Error; err != nil {
 		http.Error(w, "failed to create business vertical: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 	middleware.InvalidateAccessibleBusinessVerticalsCache()
 	middleware.InvalidateBusinessIdentifierCache()
+	middleware.InvalidateVerticalCompanyCache()
 	handlers.InvalidateAdminUsersCache()
 	businessVerticalsCache.invalidate()
 
 	// Create default roles for this business
-	createDefaultBusinessRoles(business.ID)
+	createDefaultBusinessRoles(business.ID, roleChangeActor(r))
 
 	response := businessResponse{
 		ID:          business.ID,
@@ -351,6 +464,7 @@ func CreateBusinessVertical(w http.ResponseWriter, r *http.Request) {
 		Code:        business.Code,
 		Description: business.Description,
 		IsActive:    business.IsActive,
+		CompanyID:   business.CompanyID,
 		UserCount:   0,
 		RoleCount:   0,
 	}
@@ -376,7 +490,7 @@ func UpdateBusinessVertical(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var business models.BusinessVertical
-	if err := config.DB.Where("id = ?", businessID).First(&business).Error; err != nil {
+	if err := middleware.ScopedDB(r).Scopes(middleware.TenantScope(r)).Where("id = ?", businessID).First(&business).Error; err != nil {
 		http.Error(w, "business vertical not found", http.StatusNotFound)
 		return
 	}
@@ -390,19 +504,27 @@ func UpdateBusinessVertical(w http.ResponseWriter, r *http.Request) {
 	if req.IsActive != nil {
 		business.IsActive = *req.IsActive
 	}
+	if req.CompanyID != nil {
+		companyID, ok := verticalCompanyForRequest(w, r, req.CompanyID)
+		if !ok {
+			return
+		}
+		business.CompanyID = companyID
+	}
 
 	if business.Name == "" {
 		http.Error(w, "business name is required", http.StatusBadRequest)
 		return
 	}
 
-	if err := config.DB.Save(&business).Error; err != nil {
+	if err := middleware.ScopedDB(r).Save(&business).Error; err != nil {
 		http.Error(w, "failed to update business vertical: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	middleware.InvalidateAccessibleBusinessVerticalsCache()
 	middleware.InvalidateBusinessIdentifierCache()
+	middleware.InvalidateVerticalCompanyCache()
 	handlers.InvalidateAdminUsersCache()
 	businessVerticalsCache.invalidate()
 
@@ -412,6 +534,7 @@ func UpdateBusinessVertical(w http.ResponseWriter, r *http.Request) {
 		Code:        business.Code,
 		Description: business.Description,
 		IsActive:    business.IsActive,
+		CompanyID:   business.CompanyID,
 		UserCount:   0,
 		RoleCount:   0,
 	}
@@ -431,7 +554,7 @@ func DeleteBusinessVertical(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var business models.BusinessVertical
-	if err := config.DB.Where("id = ?", businessID).First(&business).Error; err != nil {
+	if err := middleware.ScopedDB(r).Scopes(middleware.TenantScope(r)).Where("id = ?", businessID).First(&business).Error; err != nil {
 		http.Error(w, "business vertical not found", http.StatusNotFound)
 		return
 	}
@@ -442,13 +565,14 @@ func DeleteBusinessVertical(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := config.DB.Model(&business).Update("is_active", false).Error; err != nil {
+	if err := middleware.ScopedDB(r).Model(&business).Update("is_active", false).Error; err != nil {
 		http.Error(w, "failed to delete business vertical: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	middleware.InvalidateAccessibleBusinessVerticalsCache()
 	middleware.InvalidateBusinessIdentifierCache()
+	middleware.InvalidateVerticalCompanyCache()
 	handlers.InvalidateAdminUsersCache()
 	businessVerticalsCache.invalidate()
 
@@ -465,7 +589,7 @@ func GetBusinessRoles(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var roles []models.BusinessRole
-	if err := config.DB.Preload("Permissions").
+	if err := middleware.ScopedDB(r).Preload("Permissions").
 		Preload("BusinessVertical").
 		Where("business_vertical_id = ? AND is_active = ?", businessID, true).
 		Order("level ASC").
@@ -480,7 +604,7 @@ func GetBusinessRoles(w http.ResponseWriter, r *http.Request) {
 		BusinessRoleID uuid.UUID
 		Count          int64
 	}
-	config.DB.Model(&models.UserBusinessRole{}).
+	middleware.ScopedDB(r).Model(&models.UserBusinessRole{}).
 		Select("business_role_id, COUNT(*) as count").
 		Where("business_role_id IN ? AND is_active = ?", func() []uuid.UUID {
 			ids := make([]uuid.UUID, len(roles))
@@ -520,6 +644,7 @@ func GetBusinessRoles(w http.ResponseWriter, r *http.Request) {
 			BusinessVertical:   role.BusinessVertical.Name,
 			Permissions:        permissions,
 			UserCount:          userCountsByRole[role.ID],
+			Version:            role.Version,
 		}
 	}
 
@@ -541,6 +666,16 @@ func CreateBusinessRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	permissionIDs, unknown, err := resolveRolePermissionIDs(req)
+	if err != nil {
+		http.Error(w, "failed to resolve permissions: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(unknown) > 0 {
+		writeUnknownPermissions(w, unknown)
+		return
+	}
+
 	role := models.BusinessRole{
 		Name:               req.Name,
 		DisplayName:        req.DisplayName,
@@ -548,25 +683,31 @@ func CreateBusinessRole(w http.ResponseWriter, r *http.Request) {
 		Level:              req.Level,
 		BusinessVerticalID: businessID,
 		IsActive:           true,
+		Version:            1,
 	}
 
-	if err := config.DB.Create(&role).Error; err != nil {
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&role).Error; err != nil {
+			return err
+		}
+		for _, permissionID := range permissionIDs {
+			if err := tx.Exec("INSERT INTO business_role_permissions (business_role_id, permission_id) VALUES (?, ?)", role.ID, permissionID).Error; err != nil {
+				return err
+			}
+		}
+		if err := models.RecordRoleChange(tx, models.RoleTypeBusiness, role.ID, models.RoleChangeCreated, models.RoleChangeSourceAPI, roleChangeActor(r), nil); err != nil {
+			return err
+		}
+		return recordBusinessRoleVersion(tx, role.ID, roleChangeActor(r))
+	})
+	if err != nil {
 		http.Error(w, "failed to create role: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 	handlers.InvalidateUnifiedRolesCache()
 
-	permissionIDs, err := resolveRolePermissionIDs(req)
-	if err != nil {
-		http.Error(w, "failed to resolve permissions", http.StatusInternalServerError)
-		return
-	}
-	for _, permissionID := range permissionIDs {
-		config.DB.Exec("INSERT INTO business_role_permissions (business_role_id, permission_id) VALUES (?, ?)", role.ID, permissionID)
-	}
-
 	// Load for response
-	config.DB.Preload("Permissions").Preload("BusinessVertical").First(&role, role.ID)
+	middleware.ScopedDB(r).Preload("Permissions").Preload("BusinessVertical").First(&role, role.ID)
 
 	permissions := make([]permissionResponse, len(role.Permissions))
 	for i, perm := range role.Permissions {
@@ -589,6 +730,7 @@ func CreateBusinessRole(w http.ResponseWriter, r *http.Request) {
 		BusinessVertical:   role.BusinessVertical.Name,
 		Permissions:        permissions,
 		UserCount:          0,
+		Version:            role.Version,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -619,37 +761,75 @@ func UpdateBusinessRole(w http.ResponseWriter, r *http.Request) {
 
 	// Get existing role and verify it belongs to this business
 	var role models.BusinessRole
-	if err := config.DB.Where("id = ? AND business_vertical_id = ?", roleID, businessID).First(&role).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ?", roleID, businessID).First(&role).Error; err != nil {
 		http.Error(w, "role not found in this business", http.StatusNotFound)
 		return
 	}
 
-	// Update basic fields
-	role.Name = req.Name
-	role.DisplayName = req.DisplayName
-	role.Description = req.Description
-	role.Level = req.Level
+	if req.Version != nil && *req.Version != role.Version {
+		http.Error(w, "role was changed by someone else; reload and retry", http.StatusConflict)
+		return
+	}
 
-	if err := config.DB.Save(&role).Error; err != nil {
-		http.Error(w, "failed to update role: "+err.Error(), http.StatusInternalServerError)
+	permissionIDs, unknown, err := resolveRolePermissionIDs(req)
+	if err != nil {
+		http.Error(w, "failed to resolve permissions: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(unknown) > 0 {
+		writeUnknownPermissions(w, unknown)
 		return
 	}
 
-	// Clear existing permissions and assign new ones using direct SQL (GORM association has UUID issues)
-	config.DB.Exec("DELETE FROM business_role_permissions WHERE business_role_id = ?", role.ID)
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		before, err := models.SnapshotRole(tx, models.RoleTypeBusiness, role.ID)
+		if err != nil {
+			return err
+		}
 
-	permissionIDs, err := resolveRolePermissionIDs(req)
-	if err != nil {
-		http.Error(w, "failed to resolve permissions", http.StatusInternalServerError)
+		// Bump the version conditionally so concurrent edits cannot both succeed
+		result := tx.Model(&models.BusinessRole{}).
+			Where("id = ? AND version = ?", role.ID, role.Version).
+			Updates(map[string]interface{}{
+				"name":         req.Name,
+				"display_name": req.DisplayName,
+				"description":  req.Description,
+				"level":        req.Level,
+				"version":      role.Version + 1,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errRoleVersionConflict
+		}
+
+		// Replace permissions using direct SQL (GORM association has UUID issues)
+		if err := tx.Exec("DELETE FROM business_role_permissions WHERE business_role_id = ?", role.ID).Error; err != nil {
+			return err
+		}
+		for _, permissionID := range permissionIDs {
+			if err := tx.Exec("INSERT INTO business_role_permissions (business_role_id, permission_id) VALUES (?, ?)", role.ID, permissionID).Error; err != nil {
+				return err
+			}
+		}
+		if err := models.RecordRoleChange(tx, models.RoleTypeBusiness, role.ID, models.RoleChangeUpdated, models.RoleChangeSourceAPI, roleChangeActor(r), before); err != nil {
+			return err
+		}
+		return recordBusinessRoleVersion(tx, role.ID, roleChangeActor(r))
+	})
+	if errors.Is(err, errRoleVersionConflict) {
+		http.Error(w, "role was changed by someone else; reload and retry", http.StatusConflict)
 		return
 	}
-	for _, permissionID := range permissionIDs {
-		config.DB.Exec("INSERT INTO business_role_permissions (business_role_id, permission_id) VALUES (?, ?)", role.ID, permissionID)
+	if err != nil {
+		http.Error(w, "failed to update role: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
 
 	// Load fresh role with permissions for response
 	var updatedRole models.BusinessRole
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Preload("BusinessVertical").
 		Preload("Permissions").
 		First(&updatedRole, "id = ?", role.ID).Error; err != nil {
@@ -678,23 +858,53 @@ func UpdateBusinessRole(w http.ResponseWriter, r *http.Request) {
 		BusinessVertical:   updatedRole.BusinessVertical.Name,
 		Permissions:        permissions,
 		UserCount:          0,
+		Version:            updatedRole.Version,
 	}
 
 	// Invalidate cache for every user currently assigned this business role so
-	// updated permissions apply immediately rather than after the 30s TTL expires.
-	var affectedUserIDs []uuid.UUID
-	config.DB.Model(&models.UserBusinessRole{}).
-		Where("business_role_id = ? AND is_active = ?", role.ID, true).
-		Pluck("user_id", &affectedUserIDs)
-	for _, uid := range affectedUserIDs {
-		middleware.InvalidateUserCache(uid.String())
-	}
+	// updated permissions apply immediately rather than after the cache TTL expires.
+	middleware.InvalidateUserCacheForRole(role.ID)
 	handlers.InvalidateUnifiedRolesCache()
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// GetBusinessRoleVersions lists the recorded definitions of a business role, newest first
+// GET /api/v1/business/{businessCode}/roles/{roleId}/versions
+func GetBusinessRoleVersions(w http.ResponseWriter, r *http.Request) {
+	businessID := middleware.GetCurrentBusinessID(r)
+	if businessID == uuid.Nil {
+		http.Error(w, "invalid business identifier", http.StatusBadRequest)
+		return
+	}
+
+	roleID, err := uuid.Parse(mux.Vars(r)["roleId"])
+	if err != nil {
+		http.Error(w, "invalid role ID", http.StatusBadRequest)
+		return
+	}
+
+	var role models.BusinessRole
+	if err := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ?", roleID, businessID).First(&role).Error; err != nil {
+		http.Error(w, "role not found in this business", http.StatusNotFound)
+		return
+	}
+
+	var versions []models.BusinessRoleVersion
+	if err := middleware.ScopedDB(r).Where("business_role_id = ?", role.ID).Order("version DESC").Find(&versions).Error; err != nil {
+		http.Error(w, "failed to load role versions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"role_id":         role.ID,
+		"current_version": role.Version,
+		"versions":        versions,
+	})
+}
+
 // DeleteBusinessRole deactivates a business role within the current business context.
 func DeleteBusinessRole(w http.ResponseWriter, r *http.Request) {
 	businessID := middleware.GetCurrentBusinessID(r)
@@ -711,7 +921,7 @@ func DeleteBusinessRole(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var role models.BusinessRole
-	if err := config.DB.Where("id = ? AND business_vertical_id = ?", roleID, businessID).First(&role).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ?", roleID, businessID).First(&role).Error; err != nil {
 		http.Error(w, "role not found in this business", http.StatusNotFound)
 		return
 	}
@@ -724,7 +934,7 @@ func DeleteBusinessRole(w http.ResponseWriter, r *http.Request) {
 
 	// Refuse deletion when active users are assigned to this role.
 	var activeAssignments int64
-	config.DB.Model(&models.UserBusinessRole{}).
+	middleware.ScopedDB(r).Model(&models.UserBusinessRole{}).
 		Where("business_role_id = ? AND is_active = ?", role.ID, true).
 		Count(&activeAssignments)
 
@@ -733,11 +943,19 @@ func DeleteBusinessRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	before, err := models.SnapshotRole(middleware.ScopedDB(r), models.RoleTypeBusiness, role.ID)
+	if err != nil {
+		http.Error(w, "failed to load role: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 	role.IsActive = false
-	if err := config.DB.Save(&role).Error; err != nil {
+	if err := middleware.ScopedDB(r).Save(&role).Error; err != nil {
 		http.Error(w, "failed to delete role: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if err := models.RecordRoleChange(middleware.ScopedDB(r), models.RoleTypeBusiness, role.ID, models.RoleChangeDeleted, models.RoleChangeSourceAPI, roleChangeActor(r), before); err != nil {
+		log.Printf("failed to record history of business role %s: %v", role.ID, err)
+	}
 
 	handlers.InvalidateUnifiedRolesCache()
 	handlers.InvalidateAdminUsersCache()
@@ -774,29 +992,39 @@ func AssignUserToBusinessRole(w http.ResponseWriter, r *http.Request) {
 
 	// Verify user and role exist
 	var user models.User
-	if err := config.DB.First(&user, "id = ?", userID).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&user, "id = ?", userID).Error; err != nil {
 		http.Error(w, "user not found", http.StatusNotFound)
 		return
 	}
 
 	var role models.BusinessRole
-	if err := config.DB.Where("id = ? AND business_vertical_id = ?", roleID, businessID).First(&role).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ?", roleID, businessID).First(&role).Error; err != nil {
 		http.Error(w, "role not found in this business", http.StatusNotFound)
 		return
 	}
 
 	// Check if assignment already exists
 	var existing models.UserBusinessRole
-	if err := config.DB.Where("user_id = ? AND business_role_id = ?", userID, roleID).First(&existing).Error; err == nil {
+	if err := middleware.ScopedDB(r).Where("user_id = ? AND business_role_id = ?", userID, roleID).First(&existing).Error; err == nil {
 		if existing.IsActive {
 			http.Error(w, "user already has this role", http.StatusConflict)
 			return
 		} else {
+			if err := middleware.EnforceRoleAssignmentSoD(r, userID, businessID, roleID, nil); err != nil {
+				middleware.WriteSoDViolation(w, err)
+				return
+			}
+
 			// Reactivate existing assignment
 			existing.IsActive = true
-			config.DB.Save(&existing)
+			middleware.ScopedDB(r).Save(&existing)
 		}
 	} else {
+		if err := middleware.EnforceRoleAssignmentSoD(r, userID, businessID, roleID, nil); err != nil {
+			middleware.WriteSoDViolation(w, err)
+			return
+		}
+
 		// Create new assignment
 		currentUser := middleware.GetClaims(r)
 		assignment := models.UserBusinessRole{
@@ -808,7 +1036,7 @@ func AssignUserToBusinessRole(w http.ResponseWriter, r *http.Request) {
 			assignerID, _ := uuid.Parse(currentUser.UserID)
 			assignment.AssignedBy = &assignerID
 		}
-		config.DB.Create(&assignment)
+		middleware.ScopedDB(r).Create(&assignment)
 	}
 
 	// Evict auth cache so assigned permissions are reflected immediately.
@@ -845,7 +1073,7 @@ func GetBusinessUsers(w http.ResponseWriter, r *http.Request) {
 
 	// Get total count of unique users
 	var totalUsers int64
-	config.DB.Table("user_business_roles").
+	middleware.ScopedDB(r).Table("user_business_roles").
 		Select("DISTINCT user_id").
 		Joins("JOIN business_roles ON user_business_roles.business_role_id = business_roles.id").
 		Where("business_roles.business_vertical_id = ? AND user_business_roles.is_active = ?", businessID, true).
@@ -853,7 +1081,7 @@ func GetBusinessUsers(w http.ResponseWriter, r *http.Request) {
 
 	// Get paginated user IDs first
 	var userIDs []uuid.UUID
-	config.DB.Table("user_business_roles").
+	middleware.ScopedDB(r).Table("user_business_roles").
 		Select("DISTINCT user_business_roles.user_id").
 		Joins("JOIN business_roles ON user_business_roles.business_role_id = business_roles.id").
 		Where("business_roles.business_vertical_id = ? AND user_business_roles.is_active = ?", businessID, true).
@@ -864,7 +1092,7 @@ func GetBusinessUsers(w http.ResponseWriter, r *http.Request) {
 	// Get all roles for these users
 	var userBusinessRoles []models.UserBusinessRole
 	if len(userIDs) > 0 {
-		if err := config.DB.Preload("User").
+		if err := middleware.ScopedDB(r).Preload("User").
 			Preload("BusinessRole").
 			Joins("JOIN business_roles ON user_business_roles.business_role_id = business_roles.id").
 			Where("user_business_roles.user_id IN ? AND business_roles.business_vertical_id = ? AND user_business_roles.is_active = ?", userIDs, businessID, true).
@@ -917,7 +1145,7 @@ func GetBusinessUsers(w http.ResponseWriter, r *http.Request) {
 }
 
 // createDefaultBusinessRoles creates default roles for a new business vertical
-func createDefaultBusinessRoles(businessID uuid.UUID) {
+func createDefaultBusinessRoles(businessID uuid.UUID, createdBy *uuid.UUID) {
 	defaultRoles := []struct {
 		Name        string
 		DisplayName string
@@ -1003,6 +1231,9 @@ func createDefaultBusinessRoles(businessID uuid.UUID) {
 			}
 			config.DB.Model(&role).Association("Permissions").Append(&permission)
 		}
+		if err := models.RecordRoleChange(config.DB, models.RoleTypeBusiness, role.ID, models.RoleChangeCreated, models.RoleChangeSourceAPI, createdBy, nil); err != nil {
+			log.Printf("failed to record history of business role %s: %v", role.ID, err)
+		}
 	}
 }
 
@@ -1023,7 +1254,7 @@ func GetUserBusinessAccess(w http.ResponseWriter, r *http.Request) {
 	if user.HasPermission("admin_all") || isSuperAdmin {
 		// Super admin can access all business verticals
 		var allBusinesses []models.BusinessVertical
-		if err := config.DB.Where("is_active = ?", true).Find(&allBusinesses).Error; err != nil {
+		if err := middleware.ScopedDB(r).Where("is_active = ?", true).Find(&allBusinesses).Error; err != nil {
 			http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -1043,8 +1274,9 @@ func GetUserBusinessAccess(w http.ResponseWriter, r *http.Request) {
 		// Regular user - only businesses they have roles in
 		businessMap := make(map[uuid.UUID]map[string]interface{})
 
+		now := time.Now()
 		for _, ubr := range user.UserBusinessRoles {
-			if ubr.IsActive {
+			if ubr.IsEffectiveAt(now) {
 				businessID := ubr.BusinessRole.BusinessVerticalID
 				if _, exists := businessMap[businessID]; !exists {
 					businessMap[businessID] = map[string]interface{}{
@@ -1098,13 +1330,13 @@ func GetSuperAdminDashboard(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var user models.User
-	if err := config.DB.Preload("RoleModel.Permissions").First(&user, "id = ?", claims.UserID).Error; err != nil {
+	if err := middleware.ScopedDB(r).Preload("RoleModel.Permissions").First(&user, "id = ?", claims.UserID).Error; err != nil {
 		http.Error(w, "user not found", http.StatusUnauthorized)
 		return
 	}
 
 	// Verify super admin access
-	isSuperAdmin2 := user.RoleModel != nil && user.RoleModel.Name == "super_admin"
+	isSuperAdmin2 := user.EffectiveRole() != nil && user.EffectiveRole().Name == "super_admin"
 	if !user.HasPermission("admin_all") && !isSuperAdmin2 {
 		http.Error(w, "super admin access required", http.StatusForbidden)
 		return
@@ -1112,7 +1344,7 @@ func GetSuperAdminDashboard(w http.ResponseWriter, r *http.Request) {
 
 	// Get all business verticals with statistics
 	var businesses []models.BusinessVertical
-	if err := config.DB.Where("is_active = ?", true).Find(&businesses).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("is_active = ?", true).Find(&businesses).Error; err != nil {
 		http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -1123,7 +1355,7 @@ func GetSuperAdminDashboard(w http.ResponseWriter, r *http.Request) {
 		BusinessVerticalID uuid.UUID
 		Count              int64
 	}
-	config.DB.Table("user_business_roles").
+	middleware.ScopedDB(r).Table("user_business_roles").
 		Select("business_roles.business_vertical_id, COUNT(DISTINCT user_business_roles.user_id) as count").
 		Joins("JOIN business_roles ON user_business_roles.business_role_id = business_roles.id").
 		Where("business_roles.business_vertical_id IN ? AND user_business_roles.is_active = ?", func() []uuid.UUID {
@@ -1146,7 +1378,7 @@ func GetSuperAdminDashboard(w http.ResponseWriter, r *http.Request) {
 		BusinessVerticalID uuid.UUID
 		Count              int64
 	}
-	config.DB.Model(&models.BusinessRole{}).
+	middleware.ScopedDB(r).Model(&models.BusinessRole{}).
 		Select("business_vertical_id, COUNT(*) as count").
 		Where("business_vertical_id IN ? AND is_active = ?", func() []uuid.UUID {
 			ids := make([]uuid.UUID, len(businesses))
@@ -1185,8 +1417,8 @@ func GetSuperAdminDashboard(w http.ResponseWriter, r *http.Request) {
 
 	// Get global statistics
 	var globalUserCount, globalRoleCount int64
-	config.DB.Model(&models.User{}).Where("is_active = ?", true).Count(&globalUserCount)
-	config.DB.Model(&models.Role{}).Where("is_active = ?", true).Count(&globalRoleCount)
+	middleware.ScopedDB(r).Model(&models.User{}).Where("is_active = ?", true).Count(&globalUserCount)
+	middleware.ScopedDB(r).Model(&models.Role{}).Where("is_active = ?", true).Count(&globalRoleCount)
 
 	globalRole := ""
 	if user.RoleModel != nil {
@@ -1229,19 +1461,19 @@ func GetBusinessInfo(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var business models.BusinessVertical
-	if err := config.DB.First(&business, "id = ?", businessID).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&business, "id = ?", businessID).Error; err != nil {
 		http.Error(w, "business not found", http.StatusNotFound)
 		return
 	}
 
 	// Get business statistics
 	var userCount, roleCount int64
-	config.DB.Model(&models.UserBusinessRole{}).
+	middleware.ScopedDB(r).Model(&models.UserBusinessRole{}).
 		Joins("JOIN business_roles ON user_business_roles.business_role_id = business_roles.id").
 		Where("business_roles.business_vertical_id = ? AND user_business_roles.is_active = ?", businessID, true).
 		Count(&userCount)
 
-	config.DB.Model(&models.BusinessRole{}).
+	middleware.ScopedDB(r).Model(&models.BusinessRole{}).
 		Where("business_vertical_id = ? AND is_active = ?", businessID, true).
 		Count(&roleCount)
 
@@ -6,7 +6,6 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 )
@@ -32,7 +31,7 @@ func GetBusinessSiteReports(w http.ResponseWriter, r *http.Request) {
 
 	params.Filters["businessVerticalId"] = businessID.String()
 
-	service := models.NewReportService(config.DB, models.DprSite{})
+	service := models.NewReportService(middleware.ScopedDB(r), models.DprSite{})
 	response, err := service.GetReport(params)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -66,7 +65,7 @@ func CreateBusinessSiteReport(w http.ResponseWriter, r *http.Request) {
 		report.PhoneNumberOfInformationEnteredPerson = user.Phone
 	}
 
-	if err := config.DB.Create(&report).Error; err != nil {
+	if err := middleware.ScopedDB(r).Create(&report).Error; err != nil {
 		http.Error(w, "failed to create site report", http.StatusInternalServerError)
 		return
 	}
@@ -97,7 +96,7 @@ func GetBusinessMaterials(w http.ResponseWriter, r *http.Request) {
 
 	params.Filters["businessVerticalId"] = businessID.String()
 
-	service := models.NewReportService(config.DB, models.Material{})
+	service := models.NewReportService(middleware.ScopedDB(r), models.Material{})
 	response, err := service.GetReport(params)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -131,7 +130,7 @@ func CreateBusinessMaterial(w http.ResponseWriter, r *http.Request) {
 		item.PhoneNumber = user.Phone
 	}
 
-	if err := config.DB.Create(&item).Error; err != nil {
+	if err := middleware.ScopedDB(r).Create(&item).Error; err != nil {
 		http.Error(w, "failed to create material report", http.StatusInternalServerError)
 		return
 	}
@@ -164,27 +163,27 @@ func GetBusinessAnalytics(w http.ResponseWriter, r *http.Request) {
 	var currentMonthMaterials int64
 	var previousMonthMaterials int64
 
-	config.DB.Model(&models.DprSite{}).Where("business_vertical_id = ?", businessID).Count(&totalSiteReports)
-	config.DB.Model(&models.Material{}).Where("business_vertical_id = ?", businessID).Count(&totalMaterials)
-	config.DB.Model(&models.Site{}).Where("business_vertical_id = ? AND is_active = ?", businessID, true).Count(&activeSites)
+	middleware.ScopedDB(r).Model(&models.DprSite{}).Where("business_vertical_id = ?", businessID).Count(&totalSiteReports)
+	middleware.ScopedDB(r).Model(&models.Material{}).Where("business_vertical_id = ?", businessID).Count(&totalMaterials)
+	middleware.ScopedDB(r).Model(&models.Site{}).Where("business_vertical_id = ? AND is_active = ?", businessID, true).Count(&activeSites)
 
-	config.DB.Table("user_business_roles").
+	middleware.ScopedDB(r).Table("user_business_roles").
 		Joins("JOIN business_roles ON business_roles.id = user_business_roles.business_role_id").
 		Where("business_roles.business_vertical_id = ? AND user_business_roles.is_active = ?", businessID, true).
 		Distinct("user_business_roles.user_id").
 		Count(&activeUsers)
 
-	config.DB.Model(&models.DprSite{}).
+	middleware.ScopedDB(r).Model(&models.DprSite{}).
 		Where("business_vertical_id = ? AND created_at >= ?", businessID, startCurrentMonth).
 		Count(&currentMonthSiteReports)
-	config.DB.Model(&models.DprSite{}).
+	middleware.ScopedDB(r).Model(&models.DprSite{}).
 		Where("business_vertical_id = ? AND created_at >= ? AND created_at < ?", businessID, startPreviousMonth, startCurrentMonth).
 		Count(&previousMonthSiteReports)
 
-	config.DB.Model(&models.Material{}).
+	middleware.ScopedDB(r).Model(&models.Material{}).
 		Where("business_vertical_id = ? AND created_at >= ?", businessID, startCurrentMonth).
 		Count(&currentMonthMaterials)
-	config.DB.Model(&models.Material{}).
+	middleware.ScopedDB(r).Model(&models.Material{}).
 		Where("business_vertical_id = ? AND created_at >= ? AND created_at < ?", businessID, startPreviousMonth, startCurrentMonth).
 		Count(&previousMonthMaterials)
 
@@ -215,39 +214,6 @@ func GetBusinessAnalytics(w http.ResponseWriter, r *http.Request) {
 }
 
 // Solar Farm specific handlers
-func GetSolarGeneration(w http.ResponseWriter, r *http.Request) {
-	businessID := middleware.GetCurrentBusinessID(r)
-	
-	response := map[string]interface{}{
-		"message":     "Solar generation data",
-		"business_id": businessID,
-		"data": map[string]interface{}{
-			"current_generation": "1250 kW",
-			"daily_total":       "28.5 MWh",
-			"efficiency":        "94.2%",
-		},
-	}
-	
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
-}
-
-func GetSolarPanels(w http.ResponseWriter, r *http.Request) {
-	businessID := middleware.GetCurrentBusinessID(r)
-	
-	response := map[string]interface{}{
-		"message":     "Solar panel information",
-		"business_id": businessID,
-		"data": []map[string]interface{}{
-			{"panel_id": "SP001", "status": "active", "efficiency": "95.1%"},
-			{"panel_id": "SP002", "status": "maintenance", "efficiency": "0%"},
-		},
-	}
-	
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
-}
-
 func GetSolarMaintenance(w http.ResponseWriter, r *http.Request) {
 	businessID := middleware.GetCurrentBusinessID(r)
 	
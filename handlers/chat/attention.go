@@ -0,0 +1,95 @@
+package chat
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"p9e.in/ugcl/pkg/hooks"
+)
+
+// attentionPlugin keeps the per-participant "needs attention" read model in
+// chat_participants up to date from chat message events.
+type attentionPlugin struct{}
+
+func init() {
+	hooks.RegisterPlugin(attentionPlugin{})
+}
+
+func (attentionPlugin) Name() string { return "chat-attention" }
+
+func (attentionPlugin) Register(r *hooks.Registrar) error {
+	r.OnChatMessage(func(ctx context.Context, event hooks.ChatMessageEvent) error {
+		var userIDs []string
+		switch event.Action {
+		case hooks.ChatMessageSent:
+			if event.RequiresAck {
+				userIDs = nil // every participant now has a pending acknowledgement
+				break
+			}
+			// A reply from the sender answers their earlier mentions.
+			userIDs = append([]string{event.SenderID}, event.MentionedUserIDs...)
+		case hooks.ChatMessageAcknowledged:
+			userIDs = []string{event.ActorID}
+		case hooks.ChatMessageDeleted:
+			userIDs = nil
+		default:
+			return nil
+		}
+		return NewChatService().RefreshAttention(ctx, event.ConversationID, userIDs)
+	})
+	return nil
+}
+
+// RefreshAttention recomputes pending mentions, pending acknowledgements and the
+// needs_attention flag for the given participants of a conversation, or for every
+// active participant when userIDs is empty. It is idempotent, so replayed or
+// out-of-order events converge on the same state.
+//
+// A mention is pending until the mentioned user posts a later message in the
+// conversation; an ack-required message is pending until the user acknowledges it.
+// Messages sent before the user joined are not counted against them.
+func (s *ChatService) RefreshAttention(ctx context.Context, conversationID uuid.UUID, userIDs []string) error {
+	query := `
+		UPDATE chat_participants p SET
+			pending_mentions = counts.mentions,
+			pending_acks = counts.acks,
+			needs_attention = counts.mentions > 0 OR counts.acks > 0,
+			attention_updated_at = NOW()
+		FROM (
+			SELECT cp.id,
+				(SELECT COUNT(*) FROM chat_message_mentions mm
+					JOIN chat_messages m ON m.id = mm.message_id
+					WHERE mm.conversation_id = cp.conversation_id
+						AND mm.user_id = cp.user_id
+						AND m.deleted_at IS NULL
+						AND m.sender_id <> cp.user_id
+						AND NOT EXISTS (
+							SELECT 1 FROM chat_messages r
+							WHERE r.conversation_id = m.conversation_id
+								AND r.sender_id = cp.user_id
+								AND r.deleted_at IS NULL
+								AND r.created_at > m.created_at
+						)) AS mentions,
+				(SELECT COUNT(*) FROM chat_messages m
+					WHERE m.conversation_id = cp.conversation_id
+						AND m.requires_ack = true
+						AND m.deleted_at IS NULL
+						AND m.sender_id <> cp.user_id
+						AND m.created_at >= cp.joined_at
+						AND NOT EXISTS (
+							SELECT 1 FROM chat_message_acks a
+							WHERE a.message_id = m.id AND a.user_id = cp.user_id
+						)) AS acks
+			FROM chat_participants cp
+			WHERE cp.conversation_id = ? AND cp.left_at IS NULL`
+	args := []interface{}{conversationID}
+	if len(userIDs) > 0 {
+		query += ` AND cp.user_id IN ?`
+		args = append(args, userIDs)
+	}
+	query += `
+		) counts
+		WHERE p.id = counts.id`
+
+	return s.db.WithContext(ctx).Exec(query, args...).Error
+}
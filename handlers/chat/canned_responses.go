@@ -0,0 +1,305 @@
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// ============================================================================
+// Canned Response Operations
+// ============================================================================
+
+// ListCannedResponses lists a vertical's active canned responses, most used first
+// within each sort position.
+func (s *ChatService) ListCannedResponses(businessVerticalID uuid.UUID, category string) ([]models.ChatCannedResponse, error) {
+	query := s.db.Where("business_vertical_id = ? AND is_active = ?", businessVerticalID, true)
+	if category != "" {
+		query = query.Where("category = ?", category)
+	}
+
+	var responses []models.ChatCannedResponse
+	if err := query.Order("sort_order ASC, usage_count DESC, title ASC").Find(&responses).Error; err != nil {
+		return nil, fmt.Errorf("failed to list canned responses: %w", err)
+	}
+	return responses, nil
+}
+
+// CreateCannedResponse adds a canned response to a vertical
+func (s *ChatService) CreateCannedResponse(businessVerticalID uuid.UUID, userID string, req models.CannedResponseRequest) (*models.ChatCannedResponse, error) {
+	response := &models.ChatCannedResponse{
+		BusinessVerticalID: businessVerticalID,
+		CreatedBy:          userID,
+		IsActive:           true,
+	}
+	applyCannedResponseRequest(response, req)
+
+	if err := s.db.Create(response).Error; err != nil {
+		return nil, fmt.Errorf("failed to create canned response: %w", err)
+	}
+	return response, nil
+}
+
+// UpdateCannedResponse updates a canned response belonging to the vertical
+func (s *ChatService) UpdateCannedResponse(id, businessVerticalID uuid.UUID, userID string, req models.CannedResponseRequest) (*models.ChatCannedResponse, error) {
+	var response models.ChatCannedResponse
+	if err := s.db.Where("id = ? AND business_vertical_id = ?", id, businessVerticalID).First(&response).Error; err != nil {
+		return nil, errors.New("canned response not found")
+	}
+
+	applyCannedResponseRequest(&response, req)
+	response.UpdatedBy = userID
+
+	if err := s.db.Save(&response).Error; err != nil {
+		return nil, fmt.Errorf("failed to update canned response: %w", err)
+	}
+	return &response, nil
+}
+
+// DeleteCannedResponse removes a canned response belonging to the vertical
+func (s *ChatService) DeleteCannedResponse(id, businessVerticalID uuid.UUID) error {
+	result := s.db.Where("id = ? AND business_vertical_id = ?", id, businessVerticalID).Delete(&models.ChatCannedResponse{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete canned response: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("canned response not found")
+	}
+	return nil
+}
+
+func applyCannedResponseRequest(response *models.ChatCannedResponse, req models.CannedResponseRequest) {
+	response.Title = strings.TrimSpace(req.Title)
+	response.Content = req.Content
+	response.Category = strings.TrimSpace(req.Category)
+	if response.Category == "" {
+		response.Category = "general"
+	}
+	response.Language = req.Language
+	response.SortOrder = req.SortOrder
+	if req.IsActive != nil {
+		response.IsActive = *req.IsActive
+	}
+}
+
+// renderCannedResponse resolves the message content for a send that references a
+// canned response. Placeholders are filled from the referenced task and site, the
+// sender, and client-supplied values; record values take precedence.
+func (s *ChatService) renderCannedResponse(senderID string, req models.SendMessageRequest) (string, error) {
+	var response models.ChatCannedResponse
+	if err := s.db.Where("id = ? AND is_active = ?", *req.CannedResponseID, true).First(&response).Error; err != nil {
+		return "", errors.New("canned response not found")
+	}
+
+	values := make(map[string]string, len(req.PlaceholderValues)+6)
+	for k, v := range req.PlaceholderValues {
+		values[k] = v
+	}
+	values["date"] = time.Now().Format("2006-01-02")
+
+	var sender models.User
+	if err := s.db.Select("name").First(&sender, "id = ?", senderID).Error; err == nil {
+		values["sender_name"] = sender.Name
+	}
+
+	if req.TaskID != nil {
+		var task models.Tasks
+		if err := s.db.Preload("Project").First(&task, "id = ?", *req.TaskID).Error; err != nil {
+			return "", errors.New("task not found")
+		}
+		values["task_code"] = task.Code
+		values["task_title"] = task.Title
+		if task.Project != nil {
+			values["project_code"] = task.Project.Code
+		}
+	}
+
+	if req.SiteID != nil {
+		var site models.Site
+		if err := s.db.First(&site, "id = ? AND business_vertical_id = ?", *req.SiteID, response.BusinessVerticalID).Error; err != nil {
+			return "", errors.New("site not found")
+		}
+		values["site_name"] = site.Name
+		values["site_code"] = site.Code
+	}
+
+	content, missing := models.RenderCannedResponse(response.Content, values)
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing values for placeholders: %s", strings.Join(missing, ", "))
+	}
+	return content, nil
+}
+
+// recordCannedResponseUse bumps a canned response's usage counter
+func recordCannedResponseUse(tx *gorm.DB, id uuid.UUID, usedAt time.Time) error {
+	return tx.Model(&models.ChatCannedResponse{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"usage_count":  gorm.Expr("usage_count + 1"),
+			"last_used_at": usedAt,
+		}).Error
+}
+
+// ============================================================================
+// Canned Response Handlers
+// ============================================================================
+
+// cannedResponseVertical returns the business vertical of the request's business context
+func cannedResponseVertical(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	businessContext := middleware.GetUserBusinessContext(r)
+	if businessContext == nil {
+		http.Error(w, "business context not found", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+	businessID, ok := businessContext["business_id"].(uuid.UUID)
+	if !ok {
+		http.Error(w, "invalid business context", http.StatusInternalServerError)
+		return uuid.Nil, false
+	}
+	return businessID, true
+}
+
+// ListCannedResponses lists the active canned responses for the current business vertical
+// GET /api/v1/chat/canned-responses
+func (h *ChatHandler) ListCannedResponses(w http.ResponseWriter, r *http.Request) {
+	businessID, ok := cannedResponseVertical(w, r)
+	if !ok {
+		return
+	}
+
+	responses, err := getChatService().ListCannedResponses(businessID, r.URL.Query().Get("category"))
+	if err != nil {
+		log.Printf("❌ Error listing canned responses: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type cannedResponseDTO struct {
+		models.ChatCannedResponse
+		Placeholders []string `json:"placeholders,omitempty"`
+	}
+	result := make([]cannedResponseDTO, len(responses))
+	for i, resp := range responses {
+		result[i] = cannedResponseDTO{
+			ChatCannedResponse: resp,
+			Placeholders:       models.CannedResponsePlaceholders(resp.Content),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"canned_responses": result,
+	})
+}
+
+// CreateCannedResponse adds a canned response to the current business vertical
+// POST /api/v1/chat/canned-responses
+func (h *ChatHandler) CreateCannedResponse(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	businessID, ok := cannedResponseVertical(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.CannedResponseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Title) == "" || strings.TrimSpace(req.Content) == "" {
+		http.Error(w, "title and content are required", http.StatusBadRequest)
+		return
+	}
+
+	response, err := getChatService().CreateCannedResponse(businessID, claims.UserID, req)
+	if err != nil {
+		log.Printf("❌ Error creating canned response: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"canned_response": response,
+	})
+}
+
+// UpdateCannedResponse updates a canned response in the current business vertical
+// PUT /api/v1/chat/canned-responses/{id}
+func (h *ChatHandler) UpdateCannedResponse(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	businessID, ok := cannedResponseVertical(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid canned response ID", http.StatusBadRequest)
+		return
+	}
+
+	var req models.CannedResponseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Title) == "" || strings.TrimSpace(req.Content) == "" {
+		http.Error(w, "title and content are required", http.StatusBadRequest)
+		return
+	}
+
+	response, err := getChatService().UpdateCannedResponse(id, businessID, claims.UserID, req)
+	if err != nil {
+		log.Printf("❌ Error updating canned response: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"canned_response": response,
+	})
+}
+
+// DeleteCannedResponse removes a canned response from the current business vertical
+// DELETE /api/v1/chat/canned-responses/{id}
+func (h *ChatHandler) DeleteCannedResponse(w http.ResponseWriter, r *http.Request) {
+	businessID, ok := cannedResponseVertical(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid canned response ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := getChatService().DeleteCannedResponse(id, businessID); err != nil {
+		log.Printf("❌ Error deleting canned response: %v", err)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -162,6 +162,7 @@ func (h *ChatHandler) ListConversations(w http.ResponseWriter, r *http.Request)
 	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
 	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
 	includeArchived := r.URL.Query().Get("include_archived") == "true"
+	needsAttention := r.URL.Query().Get("needs_attention") == "true"
 
 	var convType *models.ConversationType
 	if typeParam := r.URL.Query().Get("type"); typeParam != "" {
@@ -176,7 +177,7 @@ func (h *ChatHandler) ListConversations(w http.ResponseWriter, r *http.Request)
 		pageSize = 20
 	}
 
-	conversations, totalCount, err := getChatService().ListUserConversations(claims.UserID, page, pageSize, includeArchived, convType)
+	conversations, totalCount, err := getChatService().ListUserConversations(claims.UserID, page, pageSize, includeArchived, convType, needsAttention)
 	if err != nil {
 		log.Printf("❌ Error listing conversations: %v", err)
 		http.Error(w, "failed to list conversations", http.StatusInternalServerError)
@@ -331,7 +332,7 @@ func (h *ChatHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Content == "" {
+	if req.Content == "" && req.CannedResponseID == nil {
 		http.Error(w, "content is required", http.StatusBadRequest)
 		return
 	}
@@ -515,6 +516,33 @@ func (h *ChatHandler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// AcknowledgeMessage acknowledges an ack-required message
+// POST /api/v1/chat/messages/{id}/ack
+func (h *ChatHandler) AcknowledgeMessage(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	messageID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	ack, err := getChatService().AcknowledgeMessage(messageID, claims.UserID)
+	if err != nil {
+		log.Printf("❌ Error acknowledging message: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ack)
+}
+
 // SearchMessages searches messages in a conversation
 // GET /api/v1/chat/conversations/{id}/messages/search
 func (h *ChatHandler) SearchMessages(w http.ResponseWriter, r *http.Request) {
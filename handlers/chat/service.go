@@ -8,9 +8,12 @@ import (
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/models"
 	"p9e.in/ugcl/handlers"
+	"p9e.in/ugcl/pkg/hooks"
+	"p9e.in/ugcl/pkg/transcribe"
 )
 
 // ChatService handles chat business logic
@@ -259,7 +262,7 @@ func (s *ChatService) GetDirectConversation(userID1, userID2 string) (*models.Co
 }
 
 // ListUserConversations lists conversations for a user with pagination
-func (s *ChatService) ListUserConversations(userID string, page, pageSize int, includeArchived bool, convType *models.ConversationType) ([]models.Conversation, int64, error) {
+func (s *ChatService) ListUserConversations(userID string, page, pageSize int, includeArchived bool, convType *models.ConversationType, needsAttention bool) ([]models.Conversation, int64, error) {
 	if page < 1 {
 		page = 1
 	}
@@ -283,6 +286,10 @@ func (s *ChatService) ListUserConversations(userID string, page, pageSize int, i
 		query = query.Where("chat_conversations.type = ?", *convType)
 	}
 
+	if needsAttention {
+		query = query.Where("chat_participants.needs_attention = true")
+	}
+
 	// Get total count
 	if err := query.Count(&totalCount).Error; err != nil {
 		return nil, 0, err
@@ -450,6 +457,31 @@ func (s *ChatService) SendMessage(conversationID uuid.UUID, senderID string, req
 		return nil, errors.New("user is not a participant in this conversation")
 	}
 
+	// Only conversation managers can ask every participant to acknowledge a message
+	if req.RequiresAck {
+		role, err := s.GetParticipantRole(conversationID, senderID)
+		if err != nil || (role != models.ParticipantRoleOwner && role != models.ParticipantRoleAdmin && role != models.ParticipantRoleModerator) {
+			return nil, errors.New("only owners, admins and moderators can request acknowledgement")
+		}
+	}
+
+	mentionedUserIDs, err := s.participantMentions(conversationID, senderID, req.MentionedUserIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.CannedResponseID != nil {
+		content, err := s.renderCannedResponse(senderID, req)
+		if err != nil {
+			return nil, err
+		}
+		req.Content = content
+		if req.Metadata == nil {
+			req.Metadata = map[string]interface{}{}
+		}
+		req.Metadata["canned_response_id"] = req.CannedResponseID.String()
+	}
+
 	// Set default message type
 	messageType := req.MessageType
 	if messageType == "" {
@@ -465,15 +497,36 @@ func (s *ChatService) SendMessage(conversationID uuid.UUID, senderID string, req
 		Status:         models.MessageStatusSent,
 		ReplyToID:      req.ReplyToID,
 		Metadata:       req.Metadata,
+		RequiresAck:    req.RequiresAck,
 		SentAt:         &now,
 	}
 
-	err := s.db.Transaction(func(tx *gorm.DB) error {
+	err = s.db.Transaction(func(tx *gorm.DB) error {
 		// Create message
 		if err := tx.Create(message).Error; err != nil {
 			return fmt.Errorf("failed to create message: %w", err)
 		}
 
+		// Record mentions
+		for _, mentionedID := range mentionedUserIDs {
+			mention := models.ChatMessageMention{
+				MessageID:      message.ID,
+				UserID:         mentionedID,
+				ConversationID: conversationID,
+				CreatedAt:      now,
+			}
+			if err := tx.Create(&mention).Error; err != nil {
+				return fmt.Errorf("failed to record mention: %w", err)
+			}
+			message.Mentions = append(message.Mentions, mention)
+		}
+
+		if req.CannedResponseID != nil {
+			if err := recordCannedResponseUse(tx, *req.CannedResponseID, now); err != nil {
+				return fmt.Errorf("failed to record canned response use: %w", err)
+			}
+		}
+
 		// Update conversation's last message
 		if err := tx.Model(&models.Conversation{}).
 			Where("id = ?", conversationID).
@@ -491,10 +544,74 @@ func (s *ChatService) SendMessage(conversationID uuid.UUID, senderID string, req
 		return nil, err
 	}
 
+	hooks.FireChatMessage(hooks.ChatMessageEvent{
+		Action:           hooks.ChatMessageSent,
+		MessageID:        message.ID,
+		ConversationID:   conversationID,
+		SenderID:         senderID,
+		ActorID:          senderID,
+		MentionedUserIDs: mentionedUserIDs,
+		RequiresAck:      message.RequiresAck,
+		OccurredAt:       now,
+	})
+
 	log.Printf("✅ Message %s sent to conversation %s by user %s", message.ID, conversationID, senderID)
 	return message, nil
 }
 
+// participantMentions de-duplicates mentioned user IDs and keeps only active participants
+// other than the sender.
+func (s *ChatService) participantMentions(conversationID uuid.UUID, senderID string, userIDs []string) ([]string, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+
+	var mentioned []string
+	err := s.db.Model(&models.ChatParticipant{}).
+		Where("conversation_id = ? AND user_id IN ? AND user_id <> ? AND left_at IS NULL", conversationID, userIDs, senderID).
+		Distinct().
+		Pluck("user_id", &mentioned).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve mentions: %w", err)
+	}
+	return mentioned, nil
+}
+
+// AcknowledgeMessage records that a participant has acknowledged an ack-required message.
+// Acknowledging the same message twice is a no-op.
+func (s *ChatService) AcknowledgeMessage(messageID uuid.UUID, userID string) (*models.ChatMessageAck, error) {
+	message, err := s.GetMessage(messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !message.RequiresAck {
+		return nil, errors.New("message does not require acknowledgement")
+	}
+
+	ack := &models.ChatMessageAck{
+		MessageID:      messageID,
+		UserID:         userID,
+		AcknowledgedAt: time.Now(),
+	}
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(ack).Error; err != nil {
+		return nil, fmt.Errorf("failed to acknowledge message: %w", err)
+	}
+
+	hooks.FireChatMessage(hooks.ChatMessageEvent{
+		Action:         hooks.ChatMessageAcknowledged,
+		MessageID:      messageID,
+		ConversationID: message.ConversationID,
+		SenderID:       message.SenderID,
+		ActorID:        userID,
+		RequiresAck:    true,
+		OccurredAt:     ack.AcknowledgedAt,
+	})
+
+	log.Printf("✅ Message %s acknowledged by user %s", messageID, userID)
+	return ack, nil
+}
+
 // GetMessage retrieves a message by ID
 func (s *ChatService) GetMessage(messageID uuid.UUID, userID string) (*models.ChatMessage, error) {
 	var message models.ChatMessage
@@ -503,6 +620,7 @@ func (s *ChatService) GetMessage(messageID uuid.UUID, userID string) (*models.Ch
 		Preload("Attachments").
 		Preload("Reactions").
 		Preload("ReadReceipts").
+		Preload("Mentions").
 		Where("id = ? AND deleted_at IS NULL", messageID).
 		First(&message).Error
 
@@ -567,6 +685,7 @@ func (s *ChatService) ListMessages(conversationID uuid.UUID, userID string, page
 		Preload("Attachments").
 		Preload("Reactions").
 		Preload("ReadReceipts").
+		Preload("Mentions").
 		Order("created_at DESC").
 		Offset(offset).
 		Limit(pageSize + 1). // Fetch one extra to check if there are more
@@ -639,6 +758,18 @@ func (s *ChatService) DeleteMessage(messageID uuid.UUID, userID string) error {
 		return fmt.Errorf("failed to delete message: %w", err)
 	}
 
+	if message.RequiresAck || len(message.Mentions) > 0 {
+		hooks.FireChatMessage(hooks.ChatMessageEvent{
+			Action:         hooks.ChatMessageDeleted,
+			MessageID:      messageID,
+			ConversationID: message.ConversationID,
+			SenderID:       message.SenderID,
+			ActorID:        userID,
+			RequiresAck:    message.RequiresAck,
+			OccurredAt:     now,
+		})
+	}
+
 	log.Printf("✅ Message %s deleted by user %s", messageID, userID)
 	return nil
 }
@@ -662,7 +793,10 @@ func (s *ChatService) SearchMessages(conversationID uuid.UUID, userID, query str
 
 	searchQuery := s.db.Model(&models.ChatMessage{}).
 		Where("conversation_id = ? AND deleted_at IS NULL", conversationID).
-		Where("content ILIKE ?", "%"+query+"%")
+		Where(`content ILIKE ? OR EXISTS (
+			SELECT 1 FROM chat_attachments a
+			WHERE a.message_id = chat_messages.id AND a.metadata->>'transcript' ILIKE ?
+		)`, "%"+query+"%", "%"+query+"%")
 
 	// Get total count
 	if err := searchQuery.Count(&totalCount).Error; err != nil {
@@ -673,6 +807,7 @@ func (s *ChatService) SearchMessages(conversationID uuid.UUID, userID, query str
 	offset := (page - 1) * pageSize
 	err := searchQuery.
 		Preload("Sender").
+		Preload("Attachments").
 		Order("created_at DESC").
 		Offset(offset).
 		Limit(pageSize).
@@ -1097,10 +1232,23 @@ func (s *ChatService) SendAttachment(conversationID, messageID uuid.UUID, userID
 		Metadata:     req.Metadata,
 	}
 
+	// Voice notes are transcribed in the background when a transcriber is configured
+	transcriber := DefaultTranscriber()
+	if transcriber != nil && transcribe.IsAudio(req.MimeType) {
+		if attachment.Metadata == nil {
+			attachment.Metadata = models.JSONMap{}
+		}
+		attachment.Metadata[metaTranscriptionStatus] = transcriptionPending
+	}
+
 	if err := s.db.Create(attachment).Error; err != nil {
 		return nil, fmt.Errorf("failed to create attachment: %w", err)
 	}
 
+	if transcriber != nil && transcribe.IsAudio(req.MimeType) {
+		transcriber.Enqueue(attachment.ID)
+	}
+
 	log.Printf("✅ Attachment %s added to message %s by user %s", attachment.ID, messageID, userID)
 	return attachment, nil
 }
@@ -1267,20 +1415,11 @@ func (s *ChatService) SendChatNotifications(message *models.ChatMessage, senderN
 			message.ID.String(),
 		)
 
-		notificationService.SendMobilePushToUser(
-			participant.UserID,
-			models.NotificationTypeChatMessage,
-			title,
-			body,
-			map[string]string{
-				"type":            string(models.NotificationTypeChatMessage),
-				"notification_id": notification.ID.String(),
-				"conversation_id": message.ConversationID.String(),
-				"message_id":      message.ID.String(),
-				"sender_id":       message.SenderID,
-				"action_url":      notification.ActionURL,
-			},
-		)
+		notificationService.QueueMobilePush(notification, body, map[string]string{
+			"conversation_id": message.ConversationID.String(),
+			"message_id":      message.ID.String(),
+			"sender_id":       message.SenderID,
+		})
 	}
 
 	log.Printf("✅ Sent chat notifications for message %s to %d participants", message.ID, len(participants))
@@ -0,0 +1,230 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/handlers"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/transcribe"
+)
+
+// Transcription state is kept in the attachment's metadata so it travels with the
+// attachment through the existing DTOs.
+const (
+	metaTranscript          = "transcript"
+	metaTranscriptLanguage  = "transcript_language"
+	metaTranscriptionStatus = "transcription_status"
+	metaTranscriptionError  = "transcription_error"
+	metaTranscriptionBy     = "transcription_provider"
+	metaTranscribedAt       = "transcribed_at"
+	metaLanguageHint        = "language" // optional hint sent by the client: kn, hi or en
+
+	transcriptionPending   = "pending"
+	transcriptionCompleted = "completed"
+	transcriptionFailed    = "failed"
+
+	maxVoiceNoteBytes = 25 << 20 // provider upload limit
+)
+
+var voiceNoteHTTPClient = &http.Client{Timeout: time.Minute}
+
+// Transcriber converts audio attachments to text in the background.
+// Enqueue never blocks: when the queue is full the attachment is marked failed.
+type Transcriber struct {
+	db       *gorm.DB
+	provider transcribe.Provider
+	queue    chan uuid.UUID
+	timeout  time.Duration
+
+	stopChan chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewTranscriber creates a voice note transcriber
+func NewTranscriber(db *gorm.DB, provider transcribe.Provider, queueSize int, timeout time.Duration) *Transcriber {
+	if queueSize <= 0 {
+		queueSize = 256
+	}
+	if timeout <= 0 {
+		timeout = 2 * time.Minute
+	}
+	return &Transcriber{
+		db:       db,
+		provider: provider,
+		queue:    make(chan uuid.UUID, queueSize),
+		timeout:  timeout,
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start runs the background worker and re-queues attachments left pending by a restart.
+func (t *Transcriber) Start() {
+	go func() {
+		defer close(t.done)
+		for {
+			select {
+			case <-t.stopChan:
+				return
+			case id := <-t.queue:
+				t.process(id)
+			}
+		}
+	}()
+
+	var pending []uuid.UUID
+	t.db.Model(&models.ChatAttachment{}).
+		Where("metadata->>'transcription_status' = ?", transcriptionPending).
+		Order("created_at").
+		Limit(cap(t.queue)).
+		Pluck("id", &pending)
+	for _, id := range pending {
+		t.Enqueue(id)
+	}
+
+	log.Printf("Voice note transcriber started with provider %s (%d pending)", t.provider.Name(), len(pending))
+}
+
+// Stop stops the worker after the attachment in progress, if any.
+func (t *Transcriber) Stop() {
+	t.stopOnce.Do(func() {
+		close(t.stopChan)
+		<-t.done
+	})
+}
+
+// Enqueue schedules an attachment for transcription.
+func (t *Transcriber) Enqueue(attachmentID uuid.UUID) {
+	select {
+	case t.queue <- attachmentID:
+	default:
+		t.fail(attachmentID, errors.New("transcription queue is full"))
+	}
+}
+
+func (t *Transcriber) process(attachmentID uuid.UUID) {
+	var attachment models.ChatAttachment
+	if err := t.db.First(&attachment, "id = ?", attachmentID).Error; err != nil {
+		log.Printf("⚠️ Transcription skipped, attachment %s not found: %v", attachmentID, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), t.timeout)
+	defer cancel()
+
+	audio, err := t.openAudio(ctx, &attachment)
+	if err != nil {
+		t.fail(attachmentID, err)
+		return
+	}
+	defer audio.Close()
+
+	language := ""
+	if hint, ok := attachment.Metadata[metaLanguageHint].(string); ok {
+		language = transcribe.NormalizeLanguage(hint)
+	}
+
+	result, err := t.provider.Transcribe(ctx, io.LimitReader(audio, maxVoiceNoteBytes), attachment.FileName, attachment.MimeType, language)
+	if err != nil {
+		t.fail(attachmentID, err)
+		return
+	}
+
+	if err := t.updateMetadata(attachmentID, map[string]interface{}{
+		metaTranscript:          result.Text,
+		metaTranscriptLanguage:  result.Language,
+		metaTranscriptionStatus: transcriptionCompleted,
+		metaTranscriptionBy:     t.provider.Name(),
+		metaTranscribedAt:       time.Now().UTC().Format(time.RFC3339),
+		metaTranscriptionError:  nil,
+	}); err != nil {
+		log.Printf("❌ Error storing transcript for attachment %s: %v", attachmentID, err)
+		return
+	}
+	log.Printf("✅ Voice note %s transcribed (%s, %d chars)", attachmentID, result.Language, len(result.Text))
+}
+
+// openAudio reads a voice note from the DMS document it references, falling back to its URL.
+func (t *Transcriber) openAudio(ctx context.Context, attachment *models.ChatAttachment) (io.ReadCloser, error) {
+	if attachment.DMSFileID != nil && *attachment.DMSFileID != "" {
+		var document models.Document
+		if err := t.db.Select("file_path").First(&document, "id = ?", *attachment.DMSFileID).Error; err == nil {
+			reader, _, err := handlers.OpenStoredFile(ctx, document.FilePath)
+			if err == nil {
+				return reader, nil
+			}
+			log.Printf("⚠️ Could not open DMS file for voice note %s: %v", attachment.ID, err)
+		}
+	}
+
+	if attachment.DMSFileURL == nil || *attachment.DMSFileURL == "" {
+		return nil, errors.New("voice note has no readable file")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, *attachment.DMSFileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := voiceNoteHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("voice note download failed with status %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+func (t *Transcriber) fail(attachmentID uuid.UUID, cause error) {
+	log.Printf("❌ Transcription failed for attachment %s: %v", attachmentID, cause)
+	if err := t.updateMetadata(attachmentID, map[string]interface{}{
+		metaTranscriptionStatus: transcriptionFailed,
+		metaTranscriptionError:  cause.Error(),
+	}); err != nil {
+		log.Printf("❌ Error recording transcription failure for attachment %s: %v", attachmentID, err)
+	}
+}
+
+// updateMetadata merges values into the attachment's metadata without overwriting
+// keys written by the client.
+func (t *Transcriber) updateMetadata(attachmentID uuid.UUID, values map[string]interface{}) error {
+	patch, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return t.db.Exec(
+		`UPDATE chat_attachments SET metadata = COALESCE(metadata, '{}'::jsonb) || ?::jsonb WHERE id = ?`,
+		string(patch), attachmentID,
+	).Error
+}
+
+var (
+	defaultTranscriberMu sync.RWMutex
+	defaultTranscriber   *Transcriber
+)
+
+// SetDefaultTranscriber installs the process-wide transcriber.
+func SetDefaultTranscriber(t *Transcriber) {
+	defaultTranscriberMu.Lock()
+	defaultTranscriber = t
+	defaultTranscriberMu.Unlock()
+}
+
+// DefaultTranscriber returns the process-wide transcriber, or nil when voice note
+// transcription is disabled.
+func DefaultTranscriber() *Transcriber {
+	defaultTranscriberMu.RLock()
+	defer defaultTranscriberMu.RUnlock()
+	return defaultTranscriber
+}
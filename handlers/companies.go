@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/utils"
+)
+
+type companyRequest struct {
+	Name      *string `json:"name"`
+	Code      *string `json:"code"`
+	LegalName *string `json:"legal_name"`
+	TaxID     *string `json:"tax_id"`
+	IsActive  *bool   `json:"is_active"`
+}
+
+// ListCompanies lists the legal entities (tenants) sharing the deployment
+// GET /api/v1/admin/companies
+func ListCompanies(w http.ResponseWriter, r *http.Request) {
+	var companies []models.Company
+	if err := middleware.ScopedDB(r).Order("name").Find(&companies).Error; err != nil {
+		http.Error(w, "failed to load companies", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"companies": companies})
+}
+
+// CreateCompany registers a new legal entity
+// POST /api/v1/admin/companies
+func CreateCompany(w http.ResponseWriter, r *http.Request) {
+	var req companyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Name == nil || strings.TrimSpace(*req.Name) == "" || req.Code == nil || strings.TrimSpace(*req.Code) == "" {
+		http.Error(w, "name and code are required", http.StatusBadRequest)
+		return
+	}
+
+	company := models.Company{
+		Name:     strings.TrimSpace(*req.Name),
+		Code:     strings.ToUpper(strings.TrimSpace(*req.Code)),
+		IsActive: true,
+	}
+	if req.LegalName != nil {
+		company.LegalName = strings.TrimSpace(*req.LegalName)
+	}
+	if req.TaxID != nil {
+		company.TaxID = strings.TrimSpace(*req.TaxID)
+	}
+
+	if err := middleware.ScopedDB(r).Create(&company).Error; err != nil {
+		if utils.IsUniqueViolation(err) {
+			http.Error(w, "company code already exists", http.StatusConflict)
+			return
+		}
+		http.Error(w, "failed to create company", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, company)
+}
+
+// UpdateCompany edits a company's details; the code is immutable
+// PUT /api/v1/admin/companies/{id}
+func UpdateCompany(w http.ResponseWriter, r *http.Request) {
+	companyID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid company ID", http.StatusBadRequest)
+		return
+	}
+
+	var req companyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	var company models.Company
+	if err := middleware.ScopedDB(r).First(&company, "id = ?", companyID).Error; err != nil {
+		http.Error(w, "company not found", http.StatusNotFound)
+		return
+	}
+	if req.Name != nil {
+		company.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.LegalName != nil {
+		company.LegalName = strings.TrimSpace(*req.LegalName)
+	}
+	if req.TaxID != nil {
+		company.TaxID = strings.TrimSpace(*req.TaxID)
+	}
+	if req.IsActive != nil {
+		company.IsActive = *req.IsActive
+	}
+	if company.Name == "" {
+		http.Error(w, "company name is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := middleware.ScopedDB(r).Save(&company).Error; err != nil {
+		http.Error(w, "failed to update company", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, company)
+}
+
+// AssignUsersToCompany moves users into a company. Their current tokens stop working,
+// so they sign in again under the new tenant.
+// POST /api/v1/admin/companies/{id}/users
+func AssignUsersToCompany(w http.ResponseWriter, r *http.Request) {
+	companyID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid company ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		UserIDs []uuid.UUID `json:"user_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.UserIDs) == 0 {
+		http.Error(w, "user_ids is required", http.StatusBadRequest)
+		return
+	}
+
+	var count int64
+	middleware.ScopedDB(r).Model(&models.Company{}).Where("id = ? AND is_active = ?", companyID, true).Count(&count)
+	if count == 0 {
+		http.Error(w, "company not found", http.StatusNotFound)
+		return
+	}
+
+	result := middleware.ScopedDB(r).Model(&models.User{}).Where("id IN ?", req.UserIDs).Update("company_id", companyID)
+	if result.Error != nil {
+		http.Error(w, "failed to assign users", http.StatusInternalServerError)
+		return
+	}
+	for _, userID := range req.UserIDs {
+		middleware.InvalidateUserCache(userID.String())
+	}
+	InvalidateAdminUsersCache()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"company_id": companyID, "updated": result.RowsAffected})
+}
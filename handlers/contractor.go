@@ -7,7 +7,6 @@ import (
 
 	"github.com/gorilla/mux"
 	"gorm.io/gorm/clause"
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 )
@@ -36,7 +35,7 @@ func GetAllContractorReports(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	service := models.NewReportService(config.DB, models.Contractor{})
+	service := models.NewReportService(middleware.ScopedDB(r), models.Contractor{})
 	response, err := service.GetReport(params)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -64,7 +63,7 @@ func CreateContractorReport(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r)
 	item.SiteEngineerName = user.Name
 	item.SiteEngineerPhone = user.Phone
-	config.DB.Create(&item)
+	middleware.ScopedDB(r).Create(&item)
 	json.NewEncoder(w).Encode(item)
 }
 
@@ -83,7 +82,7 @@ func GetContractorReport(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id, _ := strconv.Atoi(params["id"])
 	var item models.Contractor
-	config.DB.First(&item, id)
+	middleware.ScopedDB(r).First(&item, id)
 	json.NewEncoder(w).Encode(item)
 }
 
@@ -103,9 +102,9 @@ func UpdateContractorReport(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id, _ := strconv.Atoi(params["id"])
 	var item models.Contractor
-	config.DB.First(&item, id)
+	middleware.ScopedDB(r).First(&item, id)
 	json.NewDecoder(r.Body).Decode(&item)
-	config.DB.Save(&item)
+	middleware.ScopedDB(r).Save(&item)
 	json.NewEncoder(w).Encode(item)
 }
 
@@ -123,7 +122,7 @@ func UpdateContractorReport(w http.ResponseWriter, r *http.Request) {
 func DeleteContractorReport(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id, _ := strconv.Atoi(params["id"])
-	config.DB.Delete(&models.Contractor{}, id)
+	middleware.ScopedDB(r).Delete(&models.Contractor{}, id)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -150,7 +149,7 @@ func BatchContractors(w http.ResponseWriter, r *http.Request) {
 		batch[i].SiteEngineerName = user.Name
 		batch[i].SiteEngineerPhone = user.Phone
 	}
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Clauses(clause.OnConflict{
 			Columns:   []clause.Column{{Name: "id"}},
 			DoNothing: true,
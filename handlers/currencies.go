@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/fx"
+)
+
+// exchangeRateOn returns what one unit of the currency was worth in the base currency on
+// a date: its latest rate on or before it
+func exchangeRateOn(db *gorm.DB, code string, date time.Time) (float64, error) {
+	if code == models.BaseCurrency {
+		return 1, nil
+	}
+	var rate models.ExchangeRate
+	err := db.Where("currency_code = ? AND rate_date <= ?", code, date).Order("rate_date DESC").First(&rate).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, apiError{status: http.StatusUnprocessableEntity,
+			message: fmt.Sprintf("no %s exchange rate on or before %s", code, date.Format("2006-01-02"))}
+	}
+	return rate.Rate, err
+}
+
+// transactionCurrency resolves the currency a transaction dated on date is recorded in,
+// the base currency if none is given, and the rate it converts to the base currency at
+func transactionCurrency(db *gorm.DB, code string, date time.Time) (string, float64, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" || code == models.BaseCurrency {
+		return models.BaseCurrency, 1, nil
+	}
+	var count int64
+	if err := db.Model(&models.Currency{}).Where("code = ? AND is_active = ?", code, true).Count(&count).Error; err != nil {
+		return "", 0, err
+	}
+	if count == 0 {
+		return "", 0, apiError{status: http.StatusBadRequest, message: fmt.Sprintf("currency %q is not enabled", code)}
+	}
+	rate, err := exchangeRateOn(db, code, date)
+	return code, rate, err
+}
+
+// ==========================
+// Currency handlers
+// ==========================
+
+// ListCurrencies lists the currencies transactions may be recorded in. ?all=true includes
+// disabled ones.
+// GET /api/v1/currencies
+func ListCurrencies(w http.ResponseWriter, r *http.Request) {
+	query := middleware.ScopedDB(r).Order("code")
+	if r.URL.Query().Get("all") != "true" {
+		query = query.Where("is_active = ?", true)
+	}
+	var items []models.Currency
+	if err := query.Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch currencies", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items), "base_currency": models.BaseCurrency})
+}
+
+// SaveCurrency adds a currency or updates its name, symbol, decimal places and whether
+// transactions may be recorded in it
+// POST /api/v1/admin/currencies
+func SaveCurrency(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Code          string `json:"code"`
+		Name          string `json:"name"`
+		Symbol        string `json:"symbol"`
+		DecimalPlaces *int   `json:"decimal_places"`
+		IsActive      *bool  `json:"is_active"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	currency := models.Currency{
+		Code:          strings.ToUpper(strings.TrimSpace(req.Code)),
+		Name:          strings.TrimSpace(req.Name),
+		Symbol:        strings.TrimSpace(req.Symbol),
+		DecimalPlaces: 2,
+		IsActive:      true,
+	}
+	if req.DecimalPlaces != nil {
+		currency.DecimalPlaces = *req.DecimalPlaces
+	}
+	if req.IsActive != nil {
+		currency.IsActive = *req.IsActive
+	}
+	if !models.ValidCurrencyCode(currency.Code) || currency.Name == "" {
+		http.Error(w, "a three letter code and a name are required", http.StatusBadRequest)
+		return
+	}
+	if currency.DecimalPlaces < 0 || currency.DecimalPlaces > 4 {
+		http.Error(w, "decimal_places must be between 0 and 4", http.StatusBadRequest)
+		return
+	}
+	if currency.Code == models.BaseCurrency && !currency.IsActive {
+		http.Error(w, "the base currency cannot be disabled", http.StatusBadRequest)
+		return
+	}
+
+	if err := middleware.ScopedDB(r).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "code"}},
+		DoUpdates: clause.AssignmentColumns([]string{"name", "symbol", "decimal_places", "is_active", "updated_at"}),
+	}).Create(&currency).Error; err != nil {
+		http.Error(w, "failed to save currency", http.StatusInternalServerError)
+		return
+	}
+	// is_active defaults to true, so a disabled currency is written explicitly
+	if err := middleware.ScopedDB(r).Model(&currency).Select("is_active").Updates(map[string]interface{}{"is_active": currency.IsActive}).Error; err != nil {
+		http.Error(w, "failed to save currency", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "currency saved", "item": currency})
+}
+
+// ==========================
+// Exchange rate handlers
+// ==========================
+
+// ListExchangeRates lists recorded exchange rates, newest first. ?currency=, ?from= and
+// ?to= (YYYY-MM-DD) narrow them; 100 are returned per page.
+// GET /api/v1/exchange-rates
+func ListExchangeRates(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := middleware.ScopedDB(r).Model(&models.ExchangeRate{})
+	if v := q.Get("currency"); v != "" {
+		query = query.Where("currency_code = ?", strings.ToUpper(v))
+	}
+	for key, op := range map[string]string{"from": ">=", "to": "<="} {
+		if v := q.Get(key); v != "" {
+			date, err := parseAttendanceDate(v)
+			if err != nil {
+				http.Error(w, key+": "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			query = query.Where("rate_date "+op+" ?", date)
+		}
+	}
+	page, limit := parsePagination(r)
+	if q.Get("limit") == "" {
+		limit = 100
+	}
+
+	var total int64
+	query.Count(&total)
+	var items []models.ExchangeRate
+	if err := query.Order("rate_date DESC, currency_code").Offset((page - 1) * limit).Limit(limit).Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch exchange rates", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "total": total, "page": page, "limit": limit, "base_currency": models.BaseCurrency})
+}
+
+// SetExchangeRate records what one unit of a currency was worth in the base currency on
+// a day, replacing any rate recorded for it. Rates entered here are not overwritten by
+// the provider sync.
+// POST /api/v1/admin/exchange-rates
+func SetExchangeRate(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		CurrencyCode string  `json:"currency_code"`
+		RateDate     string  `json:"rate_date"`
+		Rate         float64 `json:"rate"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	rate := models.ExchangeRate{
+		CurrencyCode: strings.ToUpper(strings.TrimSpace(req.CurrencyCode)),
+		Rate:         req.Rate,
+		Source:       models.ExchangeRateManual,
+		CreatedBy:    middleware.GetClaims(r).UserID,
+	}
+	if req.RateDate != "" {
+		date, err := parseAttendanceDate(req.RateDate)
+		if err != nil {
+			http.Error(w, "rate_date: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		rate.RateDate = date
+	} else {
+		rate.RateDate = calendarDate(time.Now(), attendanceLocation(nil))
+	}
+	if err := rate.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var count int64
+	middleware.ScopedDB(r).Model(&models.Currency{}).Where("code = ?", rate.CurrencyCode).Count(&count)
+	if count == 0 {
+		http.Error(w, "unknown currency", http.StatusBadRequest)
+		return
+	}
+
+	if err := middleware.ScopedDB(r).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "currency_code"}, {Name: "rate_date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "source", "created_by", "updated_at"}),
+	}).Create(&rate).Error; err != nil {
+		http.Error(w, "failed to save exchange rate", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "exchange rate saved", "item": rate})
+}
+
+// SyncExchangeRates fetches today's rates for the active currencies from the configured
+// provider now, rather than waiting for the daily sync
+// POST /api/v1/admin/exchange-rates/sync
+func SyncExchangeRates(w http.ResponseWriter, r *http.Request) {
+	provider, err := fx.NewProviderFromEnv()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), time.Minute)
+	defer cancel()
+	count, err := fx.Sync(ctx, middleware.ScopedDB(r), provider)
+	if err != nil {
+		http.Error(w, "failed to sync exchange rates: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "exchange rates synced", "provider": provider.Name(), "recorded": count})
+}
+
+// ConvertCurrency converts ?amount= from ?from= to ?to= (the base currency by default) at
+// the rates on ?date= (YYYY-MM-DD, today by default)
+// GET /api/v1/currencies/convert
+func ConvertCurrency(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	amount, err := strconv.ParseFloat(q.Get("amount"), 64)
+	if err != nil {
+		http.Error(w, "amount must be a number", http.StatusBadRequest)
+		return
+	}
+	date := calendarDate(time.Now(), attendanceLocation(nil))
+	if v := q.Get("date"); v != "" {
+		if date, err = parseAttendanceDate(v); err != nil {
+			http.Error(w, "date: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	from, fromRate, err := transactionCurrency(middleware.ScopedDB(r), q.Get("from"), date)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to convert")
+		return
+	}
+	to, toRate, err := transactionCurrency(middleware.ScopedDB(r), q.Get("to"), date)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to convert")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"amount": amount, "from": from, "to": to, "date": date.Format("2006-01-02"),
+		"from_rate": fromRate, "to_rate": toRate, "converted": models.ConvertAmount(amount, fromRate, toRate),
+	})
+}
@@ -7,7 +7,6 @@ import (
 	"strconv"
 
 	"gorm.io/gorm/clause"
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 
@@ -26,7 +25,7 @@ func GetAllDairySiteReports(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	service := models.NewReportService(config.DB, models.DairySite{})
+	service := models.NewReportService(middleware.ScopedDB(r), models.DairySite{})
 	response, err := service.GetReport(params)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -43,7 +42,7 @@ func CreateDairySiteReport(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r)
 	item.SiteEngineerName = user.Name
 	item.SiteEngineerPhone = user.Phone
-	config.DB.Create(&item)
+	middleware.ScopedDB(r).Create(&item)
 	json.NewEncoder(w).Encode(item)
 }
 
@@ -51,7 +50,7 @@ func GetDairySiteReport(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id, _ := strconv.Atoi(params["id"])
 	var item models.DairySite
-	config.DB.First(&item, id)
+	middleware.ScopedDB(r).First(&item, id)
 	json.NewEncoder(w).Encode(item)
 }
 
@@ -59,16 +58,16 @@ func UpdateDairySiteReport(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id, _ := strconv.Atoi(params["id"])
 	var item models.DairySite
-	config.DB.First(&item, id)
+	middleware.ScopedDB(r).First(&item, id)
 	json.NewDecoder(r.Body).Decode(&item)
-	config.DB.Save(&item)
+	middleware.ScopedDB(r).Save(&item)
 	json.NewEncoder(w).Encode(item)
 }
 
 func DeleteDairySiteReport(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id, _ := strconv.Atoi(params["id"])
-	config.DB.Delete(&models.DairySite{}, id)
+	middleware.ScopedDB(r).Delete(&models.DairySite{}, id)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -87,7 +86,7 @@ func BatchDairySites(w http.ResponseWriter, r *http.Request) {
 		batch[i].SiteEngineerPhone = user.Phone
 	}
 	fmt.Println(&batch)
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Clauses(clause.OnConflict{
 			Columns:   []clause.Column{{Name: "id"}},
 			DoNothing: true,
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestRequestHandlersUseScopedDB fails when code serving a request reads or writes
+// through config.DB or an injected connection without binding it to the request's data
+// scope. Such a query skips the vertical and site filters, so every handler must go
+// through middleware.ScopedDB(r), middleware.WithDataScope(r, db) or a scoped... helper
+// built on them. Work deliberately crossing verticals marks the request context with
+// datascope.Unscoped instead. Goroutines are not checked: they outlive the request.
+func TestRequestHandlersUseScopedDB(t *testing.T) {
+	for _, root := range []string{".", "../routes"} {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+				return err
+			}
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, path, nil, 0)
+			if err != nil {
+				return err
+			}
+			for _, pos := range unscopedDBUses(file) {
+				t.Errorf("%s: database used without the request's data scope; use middleware.ScopedDB(r) or middleware.WithDataScope(r, db)", fset.Position(pos))
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("failed to scan %s: %v", root, err)
+		}
+	}
+}
+
+// unscopedDBUses returns where functions taking an *http.Request, and closures inside
+// them, use config.DB or a db field outside middleware.ScopedDB/WithDataScope.
+func unscopedDBUses(file *ast.File) []token.Pos {
+	var found []token.Pos
+	assigned := map[ast.Expr]bool{}
+	var visit func(root ast.Node, serving bool)
+	visit = func(root ast.Node, serving bool) {
+		ast.Inspect(root, func(n ast.Node) bool {
+			if n == nil || n == root {
+				return true
+			}
+			switch x := n.(type) {
+			case *ast.FuncDecl:
+				if x.Body != nil {
+					visit(x.Body, takesRequest(x.Type))
+				}
+				return false
+			case *ast.FuncLit:
+				visit(x.Body, serving || takesRequest(x.Type))
+				return false
+			case *ast.GoStmt:
+				return false
+			case *ast.AssignStmt:
+				for _, lhs := range x.Lhs {
+					assigned[lhs] = true
+				}
+			case *ast.CallExpr:
+				if fn, ok := x.Fun.(*ast.SelectorExpr); ok && isIdent(fn.X, "middleware") &&
+					(fn.Sel.Name == "ScopedDB" || fn.Sel.Name == "WithDataScope") {
+					return false
+				}
+			case *ast.SelectorExpr:
+				if !serving || assigned[x] {
+					return true
+				}
+				if isIdent(x.X, "config") && x.Sel.Name == "DB" {
+					found = append(found, x.Pos())
+					return false
+				}
+				if x.Sel.Name == "db" {
+					switch owner := x.X.(type) {
+					case *ast.Ident:
+						found = append(found, x.Pos())
+						return false
+					case *ast.CallExpr:
+						if !isScopedHelper(owner) {
+							found = append(found, x.Pos())
+						}
+						return false
+					}
+				}
+			}
+			return true
+		})
+	}
+	visit(file, false)
+	return found
+}
+
+// takesRequest reports whether a function has a named *http.Request parameter
+func takesRequest(fn *ast.FuncType) bool {
+	for _, param := range fn.Params.List {
+		star, ok := param.Type.(*ast.StarExpr)
+		if !ok {
+			continue
+		}
+		if sel, ok := star.X.(*ast.SelectorExpr); ok && isIdent(sel.X, "http") && sel.Sel.Name == "Request" {
+			for _, name := range param.Names {
+				if name.Name != "_" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// isScopedHelper reports whether call is a helper returning a scoped connection or
+// service, named scoped..., e.g. scopedWorkflowEngine(r)
+func isScopedHelper(call *ast.CallExpr) bool {
+	switch fn := call.Fun.(type) {
+	case *ast.Ident:
+		return strings.HasPrefix(fn.Name, "scoped")
+	case *ast.SelectorExpr:
+		return strings.HasPrefix(fn.Sel.Name, "scoped")
+	}
+	return false
+}
+
+func isIdent(expr ast.Expr, name string) bool {
+	id, ok := expr.(*ast.Ident)
+	return ok && id.Name == name
+}
+
+func TestUnscopedDBUsesFlagsForgottenScope(t *testing.T) {
+	src := `package handlers
+
+func List(w http.ResponseWriter, r *http.Request) {
+	config.DB.Find(&rows)
+	middleware.ScopedDB(r).Find(&rows)
+	middleware.WithDataScope(r, h.db).Find(&rows)
+	middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error { return h.db.Error })
+	scopedWorkflowEngine(r).db.Find(&rows)
+	getWorkflowEngine().db.Find(&rows)
+	go func() { config.DB.Create(&event) }()
+}
+
+func background() { config.DB.Find(&rows) }
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "list.go", src, 0)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var lines []int
+	for _, pos := range unscopedDBUses(file) {
+		lines = append(lines, fset.Position(pos).Line)
+	}
+	if len(lines) != 3 || lines[0] != 4 || lines[1] != 7 || lines[2] != 9 {
+		t.Errorf("flagged lines %v, want [4 7 9]", lines)
+	}
+}
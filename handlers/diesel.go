@@ -7,7 +7,6 @@ import (
 
 	"github.com/gorilla/mux"
 	"gorm.io/gorm/clause"
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 )
@@ -24,7 +23,7 @@ func GetAllDieselReports(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	service := models.NewReportService(config.DB, models.Diesel{})
+	service := models.NewReportService(middleware.ScopedDB(r), models.Diesel{})
 	response, err := service.GetReport(params)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -41,7 +40,7 @@ func CreateDieselReport(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r)
 	item.PersonFilled = user.Name
 	item.PersonPhone = user.Phone
-	config.DB.Create(&item)
+	middleware.ScopedDB(r).Create(&item)
 	json.NewEncoder(w).Encode(item)
 }
 
@@ -49,7 +48,7 @@ func GetDieselReport(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id, _ := strconv.Atoi(params["id"])
 	var item models.Diesel
-	config.DB.First(&item, id)
+	middleware.ScopedDB(r).First(&item, id)
 	json.NewEncoder(w).Encode(item)
 }
 
@@ -57,16 +56,16 @@ func UpdateDieselReport(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id, _ := strconv.Atoi(params["id"])
 	var item models.Diesel
-	config.DB.First(&item, id)
+	middleware.ScopedDB(r).First(&item, id)
 	json.NewDecoder(r.Body).Decode(&item)
-	config.DB.Save(&item)
+	middleware.ScopedDB(r).Save(&item)
 	json.NewEncoder(w).Encode(item)
 }
 
 func DeleteDieselReport(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id, _ := strconv.Atoi(params["id"])
-	config.DB.Delete(&models.Diesel{}, id)
+	middleware.ScopedDB(r).Delete(&models.Diesel{}, id)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -82,7 +81,7 @@ func BatchDiesels(w http.ResponseWriter, r *http.Request) {
 		batch[i].PersonFilled = user.Name
 		batch[i].PersonPhone = user.Phone
 	}
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Clauses(clause.OnConflict{
 			Columns:   []clause.Column{{Name: "id"}},
 			DoNothing: true,
@@ -19,6 +19,7 @@ import (
 	"github.com/gorilla/mux"
 	"gorm.io/gorm"
 	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 )
 
@@ -74,7 +75,7 @@ var splitTokenRegex = regexp.MustCompile(`[^a-z0-9]+`)
 // ListDocumentAIIntegrationsHandler returns active AI integrations that can be used by document workflows.
 func ListDocumentAIIntegrationsHandler(w http.ResponseWriter, r *http.Request) {
 	var items []models.ThirdPartyIntegration
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Where("status = ?", models.IntegrationStatusActive).
 		Order("name ASC").
 		Find(&items).Error; err != nil {
@@ -166,7 +167,7 @@ func ProcessDocumentAIHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var document models.Document
-	if err := config.DB.First(&document, "id = ?", documentID).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&document, "id = ?", documentID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			http.Error(w, "document not found", http.StatusNotFound)
 		} else {
@@ -214,7 +215,7 @@ func ProcessDocumentAIHandler(w http.ResponseWriter, r *http.Request) {
 		IPAddress: r.RemoteAddr,
 		UserAgent: r.UserAgent(),
 	}
-	config.DB.Create(&auditLog)
+	middleware.ScopedDB(r).Create(&auditLog)
 
 	resp := documentAIResponse{
 		DocumentID:    document.ID.String(),
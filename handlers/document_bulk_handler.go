@@ -13,7 +13,7 @@ import (
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
-	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 )
 
@@ -49,7 +49,7 @@ func BulkDeleteDocumentsHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Batch fetch IDs that actually exist (for accurate count + audit)
 	var documents []models.Document
-	if err := config.DB.Select("id").Where("id IN ?", req.DocumentIDs).Find(&documents).Error; err != nil {
+	if err := middleware.ScopedDB(r).Select("id").Where("id IN ?", req.DocumentIDs).Find(&documents).Error; err != nil {
 		http.Error(w, "failed to fetch documents: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -64,7 +64,7 @@ func BulkDeleteDocumentsHandler(w http.ResponseWriter, r *http.Request) {
 		validIDs[i] = d.ID
 	}
 
-	tx := config.DB.Begin()
+	tx := middleware.ScopedDB(r).Begin()
 	defer func() {
 		if rec := recover(); rec != nil {
 			tx.Rollback()
@@ -135,7 +135,7 @@ func BulkUpdateDocumentsHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Batch fetch valid document IDs
 	var documents []models.Document
-	if err := config.DB.Select("id").Where("id IN ?", req.DocumentIDs).Find(&documents).Error; err != nil {
+	if err := middleware.ScopedDB(r).Select("id").Where("id IN ?", req.DocumentIDs).Find(&documents).Error; err != nil {
 		http.Error(w, "failed to fetch documents: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -161,7 +161,7 @@ func BulkUpdateDocumentsHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	tx := config.DB.Begin()
+	tx := middleware.ScopedDB(r).Begin()
 	defer func() {
 		if rec := recover(); rec != nil {
 			tx.Rollback()
@@ -232,7 +232,7 @@ func BulkDownloadDocumentsHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Fetch documents
 	var documents []models.Document
-	if err := config.DB.Where("id IN ?", req.DocumentIDs).Find(&documents).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id IN ?", req.DocumentIDs).Find(&documents).Error; err != nil {
 		http.Error(w, "failed to fetch documents: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -293,7 +293,7 @@ func BulkDownloadDocumentsHandler(w http.ResponseWriter, r *http.Request) {
 			IPAddress:  r.RemoteAddr,
 			UserAgent:  r.UserAgent(),
 		}
-		config.DB.Create(&auditLog)
+		middleware.ScopedDB(r).Create(&auditLog)
 	}
 
 	zipWriter.Close()
@@ -322,7 +322,7 @@ func GetDocumentStatisticsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	scopedDocuments := func() *gorm.DB {
-		query := config.DB.Model(&models.Document{})
+		query := middleware.ScopedDB(r).Model(&models.Document{})
 		if businessVerticalID != "" {
 			query = query.Where("business_vertical_id = ?", businessVerticalID)
 		}
@@ -362,7 +362,7 @@ func GetDocumentStatisticsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Recent uploads
-	recentUploadsQuery := config.DB.Preload("UploadedBy").Preload("Category").Model(&models.Document{})
+	recentUploadsQuery := middleware.ScopedDB(r).Preload("UploadedBy").Preload("Category").Model(&models.Document{})
 	if businessVerticalID != "" {
 		recentUploadsQuery = recentUploadsQuery.Where("business_vertical_id = ?", businessVerticalID)
 	}
@@ -383,7 +383,7 @@ func GetDocumentStatisticsHandler(w http.ResponseWriter, r *http.Request) {
 		CategoryName string
 		DocCount     int64
 	}
-	categoryQuery := config.DB.Table("documents").
+	categoryQuery := middleware.ScopedDB(r).Table("documents").
 		Select("category_id, document_categories.name as category_name, COUNT(*) as doc_count").
 		Joins("LEFT JOIN document_categories ON documents.category_id = document_categories.id").
 		Where("documents.deleted_at IS NULL AND category_id IS NOT NULL")
@@ -434,7 +434,7 @@ func BulkAddTagsHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Batch find existing tags, then create missing ones
 	var existingTags []models.DocumentTag
-	config.DB.Where("name IN ?", req.TagNames).Find(&existingTags)
+	middleware.ScopedDB(r).Where("name IN ?", req.TagNames).Find(&existingTags)
 
 	existingByName := make(map[string]models.DocumentTag, len(existingTags))
 	for _, t := range existingTags {
@@ -448,7 +448,7 @@ func BulkAddTagsHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	if len(newTags) > 0 {
-		config.DB.CreateInBatches(newTags, 100)
+		middleware.ScopedDB(r).CreateInBatches(newTags, 100)
 		for _, t := range newTags {
 			existingByName[t.Name] = t
 		}
@@ -463,7 +463,7 @@ func BulkAddTagsHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Batch fetch documents
 	var documents []models.Document
-	config.DB.Select("id").Where("id IN ?", req.DocumentIDs).Find(&documents)
+	middleware.ScopedDB(r).Select("id").Where("id IN ?", req.DocumentIDs).Find(&documents)
 	if len(documents) == 0 {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{"message": "Tags added successfully", "updated": 0, "total": len(req.DocumentIDs)})
@@ -478,7 +478,7 @@ func BulkAddTagsHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	if len(links) > 0 {
-		config.DB.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(links, 200)
+		middleware.ScopedDB(r).Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(links, 200)
 	}
 
 	// Batch audit logs
@@ -493,7 +493,7 @@ func BulkAddTagsHandler(w http.ResponseWriter, r *http.Request) {
 			UserAgent:  r.UserAgent(),
 		}
 	}
-	config.DB.CreateInBatches(auditLogs, 100)
+	middleware.ScopedDB(r).CreateInBatches(auditLogs, 100)
 
 	updatedCount := len(documents)
 
@@ -7,7 +7,6 @@ import (
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"gorm.io/gorm"
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 )
@@ -74,13 +73,13 @@ func CreateDocumentCategoryHandler(w http.ResponseWriter, r *http.Request) {
 		category.BusinessVerticalID = &bvID
 	}
 
-	if err := config.DB.Create(&category).Error; err != nil {
+	if err := middleware.ScopedDB(r).Create(&category).Error; err != nil {
 		http.Error(w, "failed to create category: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// Load relationships
-	config.DB.Preload("Parent").Preload("BusinessVertical").First(&category, category.ID)
+	middleware.ScopedDB(r).Preload("Parent").Preload("BusinessVertical").First(&category, category.ID)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -108,7 +107,7 @@ func GetDocumentCategoriesHandler(w http.ResponseWriter, r *http.Request) {
 
 	businessVerticalID := r.URL.Query().Get("business_vertical_id")
 
-	query := config.DB.Model(&models.DocumentCategory{}).
+	query := middleware.ScopedDB(r).Model(&models.DocumentCategory{}).
 		Preload("Parent").
 		Preload("BusinessVertical").
 		Where("is_active = ?", true)
@@ -147,7 +146,7 @@ func GetDocumentCategoryHandler(w http.ResponseWriter, r *http.Request) {
 	categoryID := vars["id"]
 
 	var category models.DocumentCategory
-	if err := config.DB.Preload("Parent").Preload("BusinessVertical").
+	if err := middleware.ScopedDB(r).Preload("Parent").Preload("BusinessVertical").
 		First(&category, "id = ?", categoryID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			http.Error(w, "category not found", http.StatusNotFound)
@@ -181,7 +180,7 @@ func UpdateDocumentCategoryHandler(w http.ResponseWriter, r *http.Request) {
 	categoryID := vars["id"]
 
 	var category models.DocumentCategory
-	if err := config.DB.First(&category, "id = ?", categoryID).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&category, "id = ?", categoryID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			http.Error(w, "category not found", http.StatusNotFound)
 		} else {
@@ -226,12 +225,12 @@ func UpdateDocumentCategoryHandler(w http.ResponseWriter, r *http.Request) {
 		category.IsActive = *req.IsActive
 	}
 
-	if err := config.DB.Save(&category).Error; err != nil {
+	if err := middleware.ScopedDB(r).Save(&category).Error; err != nil {
 		http.Error(w, "failed to update category: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	config.DB.Preload("Parent").Preload("BusinessVertical").First(&category, category.ID)
+	middleware.ScopedDB(r).Preload("Parent").Preload("BusinessVertical").First(&category, category.ID)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -260,7 +259,7 @@ func DeleteDocumentCategoryHandler(w http.ResponseWriter, r *http.Request) {
 	categoryID := vars["id"]
 
 	var category models.DocumentCategory
-	if err := config.DB.First(&category, "id = ?", categoryID).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&category, "id = ?", categoryID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			http.Error(w, "category not found", http.StatusNotFound)
 		} else {
@@ -271,13 +270,13 @@ func DeleteDocumentCategoryHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Check if category has documents
 	var docCount int64
-	config.DB.Model(&models.Document{}).Where("category_id = ?", categoryID).Count(&docCount)
+	middleware.ScopedDB(r).Model(&models.Document{}).Where("category_id = ?", categoryID).Count(&docCount)
 	if docCount > 0 {
 		http.Error(w, "cannot delete category with documents", http.StatusConflict)
 		return
 	}
 
-	if err := config.DB.Delete(&category).Error; err != nil {
+	if err := middleware.ScopedDB(r).Delete(&category).Error; err != nil {
 		http.Error(w, "failed to delete category: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -306,7 +305,7 @@ func GetDocumentTagsHandler(w http.ResponseWriter, r *http.Request) {
 
 	businessVerticalID := r.URL.Query().Get("business_vertical_id")
 
-	query := config.DB.Model(&models.DocumentTag{}).Preload("BusinessVertical")
+	query := middleware.ScopedDB(r).Model(&models.DocumentTag{}).Preload("BusinessVertical")
 
 	if businessVerticalID != "" {
 		query = query.Where("business_vertical_id = ? OR business_vertical_id IS NULL", businessVerticalID)
@@ -371,7 +370,7 @@ func CreateDocumentTagHandler(w http.ResponseWriter, r *http.Request) {
 		tag.BusinessVerticalID = &bvID
 	}
 
-	if err := config.DB.Create(&tag).Error; err != nil {
+	if err := middleware.ScopedDB(r).Create(&tag).Error; err != nil {
 		http.Error(w, "failed to create tag: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -403,7 +402,7 @@ func UpdateDocumentTagHandler(w http.ResponseWriter, r *http.Request) {
 	tagID := vars["id"]
 
 	var tag models.DocumentTag
-	if err := config.DB.First(&tag, "id = ?", tagID).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&tag, "id = ?", tagID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			http.Error(w, "tag not found", http.StatusNotFound)
 		} else {
@@ -429,7 +428,7 @@ func UpdateDocumentTagHandler(w http.ResponseWriter, r *http.Request) {
 		tag.Color = req.Color
 	}
 
-	if err := config.DB.Save(&tag).Error; err != nil {
+	if err := middleware.ScopedDB(r).Save(&tag).Error; err != nil {
 		http.Error(w, "failed to update tag: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -461,7 +460,7 @@ func DeleteDocumentTagHandler(w http.ResponseWriter, r *http.Request) {
 	tagID := vars["id"]
 
 	var tag models.DocumentTag
-	if err := config.DB.First(&tag, "id = ?", tagID).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&tag, "id = ?", tagID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			http.Error(w, "tag not found", http.StatusNotFound)
 		} else {
@@ -471,9 +470,9 @@ func DeleteDocumentTagHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Remove tag associations
-	config.DB.Exec("DELETE FROM document_tag_links WHERE document_tag_id = ?", tagID)
+	middleware.ScopedDB(r).Exec("DELETE FROM document_tag_links WHERE document_tag_id = ?", tagID)
 
-	if err := config.DB.Delete(&tag).Error; err != nil {
+	if err := middleware.ScopedDB(r).Delete(&tag).Error; err != nil {
 		http.Error(w, "failed to delete tag: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -0,0 +1,411 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// maxDocumentFolderDepth bounds how deep folders nest, and how far a path walk goes
+const maxDocumentFolderDepth = 32
+
+// errInvalidDocumentLink marks a folder, site or entity a document cannot be filed under
+var errInvalidDocumentLink = errors.New("invalid document link")
+
+// documentLinks are where a document is filed within its business vertical
+type documentLinks struct {
+	FolderID   *uuid.UUID
+	SiteID     *uuid.UUID
+	EntityType string
+	EntityID   *uuid.UUID
+}
+
+func (l documentLinks) apply(document *models.Document) {
+	document.FolderID = l.FolderID
+	document.SiteID = l.SiteID
+	document.EntityType = l.EntityType
+	document.EntityID = l.EntityID
+}
+
+// parseDocumentLinks checks that the folder, site and linked entity, each optional, belong
+// to the document's business vertical and that the caller can access that vertical
+func parseDocumentLinks(r *http.Request, businessVerticalID *uuid.UUID, folderID, siteID, entityType, entityID string) (documentLinks, error) {
+	var links documentLinks
+	folderID, siteID = strings.TrimSpace(folderID), strings.TrimSpace(siteID)
+	entityType, entityID = strings.TrimSpace(entityType), strings.TrimSpace(entityID)
+	if folderID == "" && siteID == "" && entityType == "" && entityID == "" {
+		return links, nil
+	}
+	if businessVerticalID == nil {
+		return links, fmt.Errorf("%w: folder, site and entity links need a business vertical", errInvalidDocumentLink)
+	}
+	userCtx, err := middleware.NewAuthService().LoadUserContext(r)
+	if err != nil || !middleware.CanAccessBusiness(userCtx, *businessVerticalID) {
+		return links, fmt.Errorf("%w: no access to this business vertical", errInvalidDocumentLink)
+	}
+
+	if folderID != "" {
+		id, err := uuid.Parse(folderID)
+		if err != nil {
+			return links, fmt.Errorf("%w: invalid folder_id", errInvalidDocumentLink)
+		}
+		var folder models.DocumentFolder
+		if err := middleware.ScopedDB(r).Select("id").Where("id = ? AND business_vertical_id = ?", id, *businessVerticalID).Take(&folder).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return links, fmt.Errorf("%w: folder not found in this business vertical", errInvalidDocumentLink)
+			}
+			return links, err
+		}
+		links.FolderID = &id
+	}
+
+	if siteID != "" {
+		id, err := uuid.Parse(siteID)
+		if err != nil {
+			return links, fmt.Errorf("%w: invalid site_id", errInvalidDocumentLink)
+		}
+		var site models.Site
+		if err := middleware.ScopedDB(r).Select("id").Where("id = ? AND business_vertical_id = ?", id, *businessVerticalID).Take(&site).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return links, fmt.Errorf("%w: site not found in this business vertical", errInvalidDocumentLink)
+			}
+			return links, err
+		}
+		links.SiteID = &id
+	}
+
+	if entityType != "" || entityID != "" {
+		table, ok := models.DocumentEntityTables[entityType]
+		if !ok {
+			return links, fmt.Errorf("%w: entity_type must be asset, maintenance_ticket, form_submission, purchase_order or employee", errInvalidDocumentLink)
+		}
+		id, err := uuid.Parse(entityID)
+		if err != nil {
+			return links, fmt.Errorf("%w: invalid entity_id", errInvalidDocumentLink)
+		}
+		var count int64
+		if err := middleware.ScopedDB(r).Table(table).Where("id = ? AND business_vertical_id = ?", id, *businessVerticalID).Count(&count).Error; err != nil {
+			return links, err
+		}
+		if count == 0 {
+			return links, fmt.Errorf("%w: %s not found in this business vertical", errInvalidDocumentLink, entityType)
+		}
+		links.EntityType, links.EntityID = entityType, &id
+	}
+	return links, nil
+}
+
+// loadAccessibleDocumentFolder loads the {id} folder when the caller can access its vertical
+func loadAccessibleDocumentFolder(w http.ResponseWriter, r *http.Request) (*models.DocumentFolder, bool) {
+	folderID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "folder not found", http.StatusNotFound)
+		return nil, false
+	}
+	var folder models.DocumentFolder
+	if err := middleware.ScopedDB(r).First(&folder, "id = ?", folderID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "folder not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "failed to fetch folder: "+err.Error(), http.StatusInternalServerError)
+		}
+		return nil, false
+	}
+	userCtx, err := middleware.NewAuthService().LoadUserContext(r)
+	if err != nil || !middleware.CanAccessBusiness(userCtx, folder.BusinessVerticalID) {
+		http.Error(w, "folder not found", http.StatusNotFound)
+		return nil, false
+	}
+	return &folder, true
+}
+
+// documentFolderPath returns the folder's ancestors from the root down, ending with it
+func documentFolderPath(folder models.DocumentFolder) ([]models.DocumentFolder, error) {
+	path := []models.DocumentFolder{folder}
+	for folder.ParentID != nil {
+		if len(path) > maxDocumentFolderDepth {
+			return nil, errors.New("folder nesting too deep")
+		}
+		var parent models.DocumentFolder
+		if err := config.DB.First(&parent, "id = ?", *folder.ParentID).Error; err != nil {
+			return nil, err
+		}
+		path = append([]models.DocumentFolder{parent}, path...)
+		folder = parent
+	}
+	return path, nil
+}
+
+// documentFolderNameTaken reports whether a sibling folder already has the name
+func documentFolderNameTaken(folder models.DocumentFolder) (bool, error) {
+	query := config.DB.Model(&models.DocumentFolder{}).
+		Where("business_vertical_id = ? AND LOWER(name) = LOWER(?) AND id <> ?", folder.BusinessVerticalID, folder.Name, folder.ID)
+	if folder.ParentID != nil {
+		query = query.Where("parent_id = ?", *folder.ParentID)
+	} else {
+		query = query.Where("parent_id IS NULL")
+	}
+	var count int64
+	err := query.Count(&count).Error
+	return count > 0, err
+}
+
+// GetDocumentFoldersHandler lists a business vertical's folders under parent_id, or at the
+// root without one (?business_vertical_id=&parent_id=)
+func GetDocumentFoldersHandler(w http.ResponseWriter, r *http.Request) {
+	businessVerticalID, err := uuid.Parse(r.URL.Query().Get("business_vertical_id"))
+	if err != nil {
+		http.Error(w, "business_vertical_id is required", http.StatusBadRequest)
+		return
+	}
+	userCtx, err := middleware.NewAuthService().LoadUserContext(r)
+	if err != nil || !middleware.CanAccessBusiness(userCtx, businessVerticalID) {
+		http.Error(w, "access denied to this business vertical", http.StatusForbidden)
+		return
+	}
+
+	query := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessVerticalID)
+	if parentID := r.URL.Query().Get("parent_id"); parentID != "" {
+		pid, err := uuid.Parse(parentID)
+		if err != nil {
+			http.Error(w, "invalid parent_id", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("parent_id = ?", pid)
+	} else {
+		query = query.Where("parent_id IS NULL")
+	}
+
+	var folders []models.DocumentFolder
+	if err := query.Order("LOWER(name)").Find(&folders).Error; err != nil {
+		http.Error(w, "failed to fetch folders: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"folders": folders,
+		"count":   len(folders),
+	})
+}
+
+// GetDocumentFolderHandler returns a folder with its path from the root and its subfolders.
+// Its documents are listed by GET /documents?folder_id=
+func GetDocumentFolderHandler(w http.ResponseWriter, r *http.Request) {
+	folder, ok := loadAccessibleDocumentFolder(w, r)
+	if !ok {
+		return
+	}
+
+	path, err := documentFolderPath(*folder)
+	if err != nil {
+		http.Error(w, "failed to resolve folder path: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var children []models.DocumentFolder
+	if err := middleware.ScopedDB(r).Where("parent_id = ?", folder.ID).Order("LOWER(name)").Find(&children).Error; err != nil {
+		http.Error(w, "failed to fetch subfolders: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var documentCount int64
+	middleware.ScopedDB(r).Model(&models.Document{}).Where("folder_id = ?", folder.ID).Count(&documentCount)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"folder":         folder,
+		"path":           path,
+		"folders":        children,
+		"document_count": documentCount,
+	})
+}
+
+// CreateDocumentFolderHandler creates a folder at the root of a business vertical or
+// inside parent_id
+func CreateDocumentFolderHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getDocumentUserID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Name               string `json:"name"`
+		ParentID           string `json:"parent_id"`
+		BusinessVerticalID string `json:"business_vertical_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	folder := models.DocumentFolder{Name: req.Name, CreatedByID: userID}
+	if err := folder.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ParentID != "" {
+		parentID, err := uuid.Parse(req.ParentID)
+		if err != nil {
+			http.Error(w, "invalid parent_id", http.StatusBadRequest)
+			return
+		}
+		var parent models.DocumentFolder
+		if err := middleware.ScopedDB(r).First(&parent, "id = ?", parentID).Error; err != nil {
+			http.Error(w, "parent folder not found", http.StatusBadRequest)
+			return
+		}
+		path, err := documentFolderPath(parent)
+		if err != nil || len(path) >= maxDocumentFolderDepth {
+			http.Error(w, "folders cannot nest this deep", http.StatusBadRequest)
+			return
+		}
+		folder.ParentID = &parent.ID
+		folder.BusinessVerticalID = parent.BusinessVerticalID
+	} else {
+		bvID, err := uuid.Parse(req.BusinessVerticalID)
+		if err != nil {
+			http.Error(w, "business_vertical_id is required for a root folder", http.StatusBadRequest)
+			return
+		}
+		folder.BusinessVerticalID = bvID
+	}
+
+	userCtx, err := middleware.NewAuthService().LoadUserContext(r)
+	if err != nil || !middleware.CanAccessBusiness(userCtx, folder.BusinessVerticalID) {
+		http.Error(w, "access denied to this business vertical", http.StatusForbidden)
+		return
+	}
+	if taken, err := documentFolderNameTaken(folder); err != nil {
+		http.Error(w, "failed to check folder name: "+err.Error(), http.StatusInternalServerError)
+		return
+	} else if taken {
+		http.Error(w, "a folder with this name already exists here", http.StatusConflict)
+		return
+	}
+
+	if err := middleware.ScopedDB(r).Create(&folder).Error; err != nil {
+		http.Error(w, "failed to create folder: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Folder created successfully",
+		"folder":  folder,
+	})
+}
+
+// UpdateDocumentFolderHandler renames a folder or moves it under another folder of the
+// same business vertical; an empty parent_id moves it to the root
+func UpdateDocumentFolderHandler(w http.ResponseWriter, r *http.Request) {
+	folder, ok := loadAccessibleDocumentFolder(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name     *string `json:"name"`
+		ParentID *string `json:"parent_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.Name != nil {
+		folder.Name = *req.Name
+		if err := folder.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.ParentID != nil {
+		folder.ParentID = nil
+		if *req.ParentID != "" {
+			parentID, err := uuid.Parse(*req.ParentID)
+			if err != nil {
+				http.Error(w, "invalid parent_id", http.StatusBadRequest)
+				return
+			}
+			var parent models.DocumentFolder
+			if err := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ?", parentID, folder.BusinessVerticalID).First(&parent).Error; err != nil {
+				http.Error(w, "parent folder not found in this business vertical", http.StatusBadRequest)
+				return
+			}
+			path, err := documentFolderPath(parent)
+			if err != nil {
+				http.Error(w, "failed to resolve folder path: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			for _, ancestor := range path {
+				if ancestor.ID == folder.ID {
+					http.Error(w, "a folder cannot be moved into itself or its subfolders", http.StatusBadRequest)
+					return
+				}
+			}
+			if len(path) >= maxDocumentFolderDepth {
+				http.Error(w, "folders cannot nest this deep", http.StatusBadRequest)
+				return
+			}
+			folder.ParentID = &parent.ID
+		}
+	}
+
+	if taken, err := documentFolderNameTaken(*folder); err != nil {
+		http.Error(w, "failed to check folder name: "+err.Error(), http.StatusInternalServerError)
+		return
+	} else if taken {
+		http.Error(w, "a folder with this name already exists here", http.StatusConflict)
+		return
+	}
+
+	if err := middleware.ScopedDB(r).Model(folder).Updates(map[string]interface{}{
+		"name":      folder.Name,
+		"parent_id": folder.ParentID,
+	}).Error; err != nil {
+		http.Error(w, "failed to update folder: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Folder updated successfully",
+		"folder":  folder,
+	})
+}
+
+// DeleteDocumentFolderHandler deletes an empty folder
+func DeleteDocumentFolderHandler(w http.ResponseWriter, r *http.Request) {
+	folder, ok := loadAccessibleDocumentFolder(w, r)
+	if !ok {
+		return
+	}
+
+	var subfolders, documents int64
+	middleware.ScopedDB(r).Model(&models.DocumentFolder{}).Where("parent_id = ?", folder.ID).Count(&subfolders)
+	middleware.ScopedDB(r).Model(&models.Document{}).Where("folder_id = ?", folder.ID).Count(&documents)
+	if subfolders > 0 || documents > 0 {
+		http.Error(w, "folder is not empty; move or delete its subfolders and documents first", http.StatusConflict)
+		return
+	}
+
+	if err := middleware.ScopedDB(r).Delete(folder).Error; err != nil {
+		http.Error(w, "failed to delete folder: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Folder deleted successfully",
+	})
+}
@@ -31,6 +31,10 @@ type DocumentUploadRequest struct {
 	TaskID             string                 `json:"task_id"`
 	WorkflowID         string                 `json:"workflow_id"`
 	IsPublic           bool                   `json:"is_public"`
+	FolderID           string                 `json:"folder_id"`
+	SiteID             string                 `json:"site_id"`
+	EntityType         string                 `json:"entity_type"` // see models.DocumentEntityTables
+	EntityID           string                 `json:"entity_id"`
 }
 
 type DocumentContextBackfillRequest struct {
@@ -94,7 +98,7 @@ func BackfillDocumentContextLinksHandler(w http.ResponseWriter, r *http.Request)
 		req.Limit = 0
 	}
 
-	query := config.DB.Model(&models.Document{}).
+	query := middleware.ScopedDB(r).Model(&models.Document{}).
 		Where("deleted_at IS NULL").
 		Where("(project_id IS NULL AND metadata ? 'project_id') OR (task_id IS NULL AND metadata ? 'task_id')")
 
@@ -144,7 +148,7 @@ func BackfillDocumentContextLinksHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	tx := config.DB.Begin()
+	tx := middleware.ScopedDB(r).Begin()
 	defer func() {
 		if recovered := recover(); recovered != nil {
 			tx.Rollback()
@@ -271,7 +275,7 @@ func UploadDocumentHandler(w http.ResponseWriter, r *http.Request) {
 	hasScopedContext := strings.TrimSpace(req.ProjectID) != "" || strings.TrimSpace(req.TaskID) != ""
 	if !hasScopedContext {
 		var existingDoc models.Document
-		if err := config.DB.Where("file_hash = ? AND deleted_at IS NULL", fileHash).First(&existingDoc).Error; err == nil {
+		if err := middleware.ScopedDB(r).Where("file_hash = ? AND deleted_at IS NULL", fileHash).First(&existingDoc).Error; err == nil {
 			// File already exists, return existing document
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode(map[string]interface{}{
@@ -288,6 +292,22 @@ func UploadDocumentHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	document, status, err := createUploadedDocument(r, user, req, upload, fileHash)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":  "Document uploaded successfully",
+		"document": document,
+	})
+}
+
+// createUploadedDocument records a stored upload as a new document with its first
+// version, tags and audit entry. On failure it returns the HTTP status to answer with.
+func createUploadedDocument(r *http.Request, user models.User, req DocumentUploadRequest, upload *storedUpload, fileHash string) (*models.Document, int, error) {
 	ext := filepath.Ext(upload.OriginalFilename)
 	filePath := upload.Path
 	fileSize := upload.Size
@@ -341,17 +361,23 @@ func UploadDocumentHandler(w http.ResponseWriter, r *http.Request) {
 	var workflowDef *models.WorkflowDefinition
 	if workflowID != nil {
 		var selectedWorkflow models.WorkflowDefinition
-		if err := config.DB.Where("id = ? AND is_active = ?", *workflowID, true).First(&selectedWorkflow).Error; err != nil {
+		if err := middleware.ScopedDB(r).Where("id = ? AND is_active = ?", *workflowID, true).First(&selectedWorkflow).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
-				http.Error(w, "invalid or inactive workflow selected", http.StatusBadRequest)
-			} else {
-				http.Error(w, "failed to load workflow: "+err.Error(), http.StatusInternalServerError)
+				return nil, http.StatusBadRequest, errors.New("invalid or inactive workflow selected")
 			}
-			return
+			return nil, http.StatusInternalServerError, errors.New("failed to load workflow: " + err.Error())
 		}
 		workflowDef = &selectedWorkflow
 	}
 
+	links, err := parseDocumentLinks(r, businessVerticalID, req.FolderID, req.SiteID, req.EntityType, req.EntityID)
+	if err != nil {
+		if errors.Is(err, errInvalidDocumentLink) {
+			return nil, http.StatusBadRequest, err
+		}
+		return nil, http.StatusInternalServerError, errors.New("failed to check document links: " + err.Error())
+	}
+
 	initialState := resolveInitialDocumentState(workflowDef)
 	initialStatus := mapDocumentStateToStatus(initialState)
 
@@ -380,9 +406,10 @@ func UploadDocumentHandler(w http.ResponseWriter, r *http.Request) {
 		CurrentState:       initialState,
 		IsPublic:           req.IsPublic,
 	}
+	links.apply(&document)
 
 	// Start transaction
-	tx := config.DB.Begin()
+	tx := middleware.ScopedDB(r).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -392,8 +419,7 @@ func UploadDocumentHandler(w http.ResponseWriter, r *http.Request) {
 	// Create document
 	if err := tx.Create(&document).Error; err != nil {
 		tx.Rollback()
-		http.Error(w, "failed to create document: "+err.Error(), http.StatusInternalServerError)
-		return
+		return nil, http.StatusInternalServerError, errors.New("failed to create document: " + err.Error())
 	}
 
 	// Create initial version
@@ -412,8 +438,7 @@ func UploadDocumentHandler(w http.ResponseWriter, r *http.Request) {
 
 	if err := tx.Create(&version).Error; err != nil {
 		tx.Rollback()
-		http.Error(w, "failed to create version: "+err.Error(), http.StatusInternalServerError)
-		return
+		return nil, http.StatusInternalServerError, errors.New("failed to create version: " + err.Error())
 	}
 
 	// Add tags
@@ -421,7 +446,7 @@ func UploadDocumentHandler(w http.ResponseWriter, r *http.Request) {
 		var tags []models.DocumentTag
 		for _, tagName := range req.Tags {
 			var tag models.DocumentTag
-			if err := config.DB.Where("name = ?", tagName).First(&tag).Error; err == gorm.ErrRecordNotFound {
+			if err := middleware.ScopedDB(r).Where("name = ?", tagName).First(&tag).Error; err == gorm.ErrRecordNotFound {
 				// Create new tag
 				tag = models.DocumentTag{
 					Name:               tagName,
@@ -435,8 +460,7 @@ func UploadDocumentHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		if err := tx.Model(&document).Association("Tags").Append(tags); err != nil {
 			tx.Rollback()
-			http.Error(w, "failed to add tags: "+err.Error(), http.StatusInternalServerError)
-			return
+			return nil, http.StatusInternalServerError, errors.New("failed to add tags: " + err.Error())
 		}
 	}
 
@@ -453,18 +477,13 @@ func UploadDocumentHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
-		http.Error(w, "failed to commit transaction: "+err.Error(), http.StatusInternalServerError)
-		return
+		return nil, http.StatusInternalServerError, errors.New("failed to commit transaction: " + err.Error())
 	}
 
 	// Load relationships
-	config.DB.Preload("Category").Preload("Tags").Preload("UploadedBy").First(&document, document.ID)
+	middleware.ScopedDB(r).Preload("Category").Preload("Tags").Preload("UploadedBy").First(&document, document.ID)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message":  "Document uploaded successfully",
-		"document": document,
-	})
+	return &document, http.StatusOK, nil
 }
 
 // GetDocumentsHandler returns a list of documents with filtering and pagination
@@ -490,6 +509,25 @@ func GetDocumentsHandler(w http.ResponseWriter, r *http.Request) {
 	projectID := r.URL.Query().Get("project_id")
 	taskID := r.URL.Query().Get("task_id")
 	tag := r.URL.Query().Get("tag")
+	folderID := r.URL.Query().Get("folder_id") // "root" for documents outside any folder
+	siteID := r.URL.Query().Get("site_id")
+	entityType := r.URL.Query().Get("entity_type")
+	entityID := r.URL.Query().Get("entity_id")
+
+	for name, value := range map[string]string{"site_id": siteID, "entity_id": entityID} {
+		if value != "" {
+			if _, err := uuid.Parse(value); err != nil {
+				http.Error(w, "invalid "+name, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	if folderID != "" && folderID != "root" {
+		if _, err := uuid.Parse(folderID); err != nil {
+			http.Error(w, "invalid folder_id", http.StatusBadRequest)
+			return
+		}
+	}
 
 	if projectID != "" {
 		if _, err := uuid.Parse(projectID); err != nil {
@@ -508,7 +546,7 @@ func GetDocumentsHandler(w http.ResponseWriter, r *http.Request) {
 	// Build query
 	// Preload Category and Tags (small); UploadedBy is restricted to list-safe columns
 	// to avoid transferring full User rows on every page request.
-	query := config.DB.Model(&models.Document{}).
+	query := middleware.ScopedDB(r).Model(&models.Document{}).
 		Preload("Category").
 		Preload("Tags").
 		Preload("UploadedBy", func(db *gorm.DB) *gorm.DB {
@@ -545,6 +583,24 @@ func GetDocumentsHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if folderID == "root" {
+		query = query.Where("folder_id IS NULL")
+	} else if folderID != "" {
+		query = query.Where("folder_id = ?", folderID)
+	}
+
+	if siteID != "" {
+		query = query.Where("site_id = ?", siteID)
+	}
+
+	if entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+	}
+
+	if entityID != "" {
+		query = query.Where("entity_id = ?", entityID)
+	}
+
 	if search != "" {
 		query = query.Where("title ILIKE ? OR description ILIKE ? OR file_name ILIKE ?",
 			"%"+search+"%", "%"+search+"%", "%"+search+"%")
@@ -603,7 +659,7 @@ func GetDocumentHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var document models.Document
-	if err := config.DB.Preload("Category").Preload("Tags").Preload("UploadedBy").
+	if err := middleware.ScopedDB(r).Preload("Category").Preload("Tags").Preload("UploadedBy").
 		Preload("Versions").Preload("Permissions").
 		First(&document, "id = ?", documentID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -615,7 +671,7 @@ func GetDocumentHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Increment view count
-	config.DB.Model(&document).Update("view_count", gorm.Expr("view_count + 1"))
+	middleware.ScopedDB(r).Model(&document).Update("view_count", gorm.Expr("view_count + 1"))
 
 	// Log audit with user ID
 	userID := user.ID
@@ -626,7 +682,7 @@ func GetDocumentHandler(w http.ResponseWriter, r *http.Request) {
 		IPAddress:  r.RemoteAddr,
 		UserAgent:  r.UserAgent(),
 	}
-	config.DB.Create(&auditLog)
+	middleware.ScopedDB(r).Create(&auditLog)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(document)
@@ -653,7 +709,7 @@ func UpdateDocumentHandler(w http.ResponseWriter, r *http.Request) {
 	userID := user.ID
 
 	var document models.Document
-	if err := config.DB.First(&document, "id = ?", documentID).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&document, "id = ?", documentID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			http.Error(w, "document not found", http.StatusNotFound)
 		} else {
@@ -670,6 +726,11 @@ func UpdateDocumentHandler(w http.ResponseWriter, r *http.Request) {
 		Tags        []string               `json:"tags"`
 		Metadata    map[string]interface{} `json:"metadata"`
 		Status      string                 `json:"status"`
+		// Refiling: null keeps the current value, "" clears it
+		FolderID   *string `json:"folder_id"`
+		SiteID     *string `json:"site_id"`
+		EntityType *string `json:"entity_type"`
+		EntityID   *string `json:"entity_id"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -693,6 +754,33 @@ func UpdateDocumentHandler(w http.ResponseWriter, r *http.Request) {
 	if req.Metadata != nil {
 		document.Metadata = req.Metadata
 	}
+	if req.FolderID != nil || req.SiteID != nil || req.EntityType != nil || req.EntityID != nil {
+		current := func(field *string, id *uuid.UUID) string {
+			if field != nil {
+				return *field
+			}
+			if id != nil {
+				return id.String()
+			}
+			return ""
+		}
+		entityType := document.EntityType
+		if req.EntityType != nil {
+			entityType = *req.EntityType
+		}
+		links, err := parseDocumentLinks(r, document.BusinessVerticalID,
+			current(req.FolderID, document.FolderID), current(req.SiteID, document.SiteID),
+			entityType, current(req.EntityID, document.EntityID))
+		if err != nil {
+			if errors.Is(err, errInvalidDocumentLink) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			} else {
+				http.Error(w, "failed to check document links: "+err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		links.apply(&document)
+	}
 	if req.Status != "" {
 		if strings.TrimSpace(document.CurrentState) != "" {
 			http.Error(w, "status is managed by workflow for this document; use workflow transition endpoint", http.StatusBadRequest)
@@ -702,7 +790,7 @@ func UpdateDocumentHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Save changes
-	if err := config.DB.Save(&document).Error; err != nil {
+	if err := middleware.ScopedDB(r).Save(&document).Error; err != nil {
 		http.Error(w, "failed to update document: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -712,13 +800,13 @@ func UpdateDocumentHandler(w http.ResponseWriter, r *http.Request) {
 		var tags []models.DocumentTag
 		for _, tagName := range req.Tags {
 			var tag models.DocumentTag
-			if err := config.DB.Where("name = ?", tagName).First(&tag).Error; err == gorm.ErrRecordNotFound {
+			if err := middleware.ScopedDB(r).Where("name = ?", tagName).First(&tag).Error; err == gorm.ErrRecordNotFound {
 				tag = models.DocumentTag{Name: tagName}
-				config.DB.Create(&tag)
+				middleware.ScopedDB(r).Create(&tag)
 			}
 			tags = append(tags, tag)
 		}
-		config.DB.Model(&document).Association("Tags").Replace(tags)
+		middleware.ScopedDB(r).Model(&document).Association("Tags").Replace(tags)
 	}
 
 	// Log audit
@@ -730,10 +818,10 @@ func UpdateDocumentHandler(w http.ResponseWriter, r *http.Request) {
 		IPAddress:  r.RemoteAddr,
 		UserAgent:  r.UserAgent(),
 	}
-	config.DB.Create(&auditLog)
+	middleware.ScopedDB(r).Create(&auditLog)
 
 	// Reload with relationships
-	config.DB.Preload("Category").Preload("Tags").Preload("UploadedBy").First(&document, document.ID)
+	middleware.ScopedDB(r).Preload("Category").Preload("Tags").Preload("UploadedBy").First(&document, document.ID)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -763,7 +851,7 @@ func DeleteDocumentHandler(w http.ResponseWriter, r *http.Request) {
 	userID := user.ID
 
 	var document models.Document
-	if err := config.DB.First(&document, "id = ?", documentID).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&document, "id = ?", documentID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			http.Error(w, "document not found", http.StatusNotFound)
 		} else {
@@ -773,7 +861,7 @@ func DeleteDocumentHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Soft delete
-	if err := config.DB.Delete(&document).Error; err != nil {
+	if err := middleware.ScopedDB(r).Delete(&document).Error; err != nil {
 		http.Error(w, "failed to delete document: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -786,7 +874,7 @@ func DeleteDocumentHandler(w http.ResponseWriter, r *http.Request) {
 		IPAddress:  r.RemoteAddr,
 		UserAgent:  r.UserAgent(),
 	}
-	config.DB.Create(&auditLog)
+	middleware.ScopedDB(r).Create(&auditLog)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
@@ -815,7 +903,7 @@ func DownloadDocumentHandler(w http.ResponseWriter, r *http.Request) {
 	userID := user.ID
 
 	var document models.Document
-	if err := config.DB.First(&document, "id = ?", documentID).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&document, "id = ?", documentID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			http.Error(w, "document not found", http.StatusNotFound)
 		} else {
@@ -825,7 +913,7 @@ func DownloadDocumentHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Increment download count
-	config.DB.Model(&document).Update("download_count", gorm.Expr("download_count + 1"))
+	middleware.ScopedDB(r).Model(&document).Update("download_count", gorm.Expr("download_count + 1"))
 
 	// Log audit
 	auditLog := models.DocumentAuditLog{
@@ -835,7 +923,7 @@ func DownloadDocumentHandler(w http.ResponseWriter, r *http.Request) {
 		IPAddress:  r.RemoteAddr,
 		UserAgent:  r.UserAgent(),
 	}
-	config.DB.Create(&auditLog)
+	middleware.ScopedDB(r).Create(&auditLog)
 
 	if err := serveStoredFile(w, r, document.FilePath, document.FileName, document.FileType, document.FileSize); err != nil {
 		if errors.Is(err, errStoredFileNotFound) {
@@ -852,7 +940,7 @@ func GetDocumentAuditLogsHandler(w http.ResponseWriter, r *http.Request) {
 	documentID := vars["id"]
 
 	var logs []models.DocumentAuditLog
-	if err := config.DB.Preload("User").Where("document_id = ?", documentID).
+	if err := middleware.ScopedDB(r).Preload("User").Where("document_id = ?", documentID).
 		Order("created_at DESC").Find(&logs).Error; err != nil {
 		http.Error(w, "failed to fetch audit logs: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -861,33 +949,3 @@ func GetDocumentAuditLogsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(logs)
 }
-
-// SearchDocumentsHandler performs full-text search on documents
-func SearchDocumentsHandler(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query().Get("q")
-	if query == "" {
-		http.Error(w, "search query is required", http.StatusBadRequest)
-		return
-	}
-
-	// Perform search
-	var documents []models.Document
-	searchPattern := "%" + strings.ToLower(query) + "%"
-
-	if err := config.DB.Preload("Category").Preload("Tags").Preload("UploadedBy").
-		Where("LOWER(title) LIKE ? OR LOWER(description) LIKE ? OR LOWER(file_name) LIKE ? OR LOWER(metadata::text) LIKE ?",
-			searchPattern, searchPattern, searchPattern, searchPattern).
-		Order("created_at DESC").
-		Limit(50).
-		Find(&documents).Error; err != nil {
-		http.Error(w, "search failed: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"query":   query,
-		"results": documents,
-		"count":   len(documents),
-	})
-}
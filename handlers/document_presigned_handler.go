@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// maxPresignedDocumentSize matches the multipart upload limit
+const maxPresignedDocumentSize = 100 << 20
+
+// CreateDocumentUploadURLHandler hands out a presigned URL the client PUTs a document's
+// content to directly, bypassing the API; POST /documents/uploads/{id}/complete then
+// records it. Requires S3 storage.
+func CreateDocumentUploadURLHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getDocumentUserID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if !useS3Storage() {
+		http.Error(w, "presigned uploads require S3 storage; upload the file as multipart instead", http.StatusNotImplemented)
+		return
+	}
+
+	var req struct {
+		FileName string `json:"file_name"`
+		FileType string `json:"file_type"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.FileName = filepath.Base(strings.TrimSpace(req.FileName))
+	if req.FileName == "" || req.FileName == "." || req.FileName == "/" {
+		http.Error(w, "file_name is required", http.StatusBadRequest)
+		return
+	}
+	if req.FileType == "" {
+		req.FileType = "application/octet-stream"
+	}
+
+	expiry := s3URLExpiry()
+	storedName := fmt.Sprintf("%s-%s%s", time.Now().Format("20060102-150405"), uuid.New().String()[:8], filepath.Ext(req.FileName))
+	upload := models.DocumentUpload{
+		ObjectKey:    uploadObjectName("./uploads/documents", storedName),
+		FileName:     req.FileName,
+		FileType:     req.FileType,
+		UploadedByID: userID,
+		ExpiresAt:    time.Now().Add(expiry),
+	}
+	uploadURL, err := presignS3Put(r.Context(), upload.ObjectKey, expiry)
+	if err != nil {
+		http.Error(w, "failed to presign upload: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := middleware.ScopedDB(r).Create(&upload).Error; err != nil {
+		http.Error(w, "failed to create upload: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"upload":     upload,
+		"upload_url": uploadURL.String(),
+		"method":     http.MethodPut,
+		"headers":    map[string]string{"Content-Type": upload.FileType},
+	})
+}
+
+// CompleteDocumentUploadHandler records a presigned upload the client has finished as a
+// document. The body carries the same metadata as a multipart upload's "metadata" field.
+func CompleteDocumentUploadHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getDocumentUserID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	uploadID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "upload not found", http.StatusNotFound)
+		return
+	}
+
+	var req DocumentUploadRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var upload models.DocumentUpload
+	if err := middleware.ScopedDB(r).Where("id = ? AND uploaded_by_id = ?", uploadID, userID).First(&upload).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "upload not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "failed to fetch upload: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	now := time.Now()
+	if err := upload.Usable(now); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	size, _, err := statS3Object(r.Context(), upload.ObjectKey)
+	if errors.Is(err, errStoredFileNotFound) {
+		http.Error(w, "file has not been uploaded yet", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to check uploaded file: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if size > maxPresignedDocumentSize {
+		removeS3Object(r.Context(), upload.ObjectKey)
+		http.Error(w, "file exceeds the 100MB document limit", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// Claim the upload so a repeated completion cannot create a second document
+	claim := middleware.ScopedDB(r).Model(&models.DocumentUpload{}).
+		Where("id = ? AND completed_at IS NULL", upload.ID).
+		Update("completed_at", now)
+	if claim.Error != nil {
+		http.Error(w, "failed to complete upload: "+claim.Error.Error(), http.StatusInternalServerError)
+		return
+	}
+	if claim.RowsAffected == 0 {
+		http.Error(w, "upload already completed", http.StatusConflict)
+		return
+	}
+	release := func() {
+		middleware.ScopedDB(r).Model(&models.DocumentUpload{}).Where("id = ?", upload.ID).Update("completed_at", nil)
+	}
+
+	reader, _, err := openS3Object(r.Context(), upload.ObjectKey)
+	if err != nil {
+		release()
+		http.Error(w, "failed to read uploaded file: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	hasher := sha256.New()
+	_, err = io.Copy(hasher, reader)
+	reader.Close()
+	if err != nil {
+		release()
+		http.Error(w, "failed to calculate hash: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if req.Title == "" {
+		req.Title = upload.FileName
+	}
+	stored := &storedUpload{
+		OriginalFilename: upload.FileName,
+		Filename:         path.Base(upload.ObjectKey),
+		URL:              fmt.Sprintf("s3://%s/%s", getS3BucketName(), upload.ObjectKey),
+		Path:             upload.ObjectKey,
+		Size:             size,
+		MimeType:         upload.FileType,
+	}
+	user := middleware.GetUser(r)
+	document, status, err := createUploadedDocument(r, user, req, stored, hex.EncodeToString(hasher.Sum(nil)))
+	if err != nil {
+		release()
+		http.Error(w, err.Error(), status)
+		return
+	}
+	middleware.ScopedDB(r).Model(&models.DocumentUpload{}).Where("id = ?", upload.ID).Update("document_id", document.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":  "Document uploaded successfully",
+		"document": document,
+	})
+}
+
+// GetDocumentDownloadURLHandler returns a short-lived signed URL that downloads the
+// document straight from S3. Documents kept on local disk or GCS are served by the API's
+// download endpoint instead, and "signed" is false.
+func GetDocumentDownloadURLHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := getDocumentUserID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	documentID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "document not found", http.StatusNotFound)
+		return
+	}
+
+	var document models.Document
+	if err := middleware.ScopedDB(r).First(&document, "id = ?", documentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "document not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "failed to fetch document: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	response := map[string]interface{}{
+		"url":    fmt.Sprintf("/api/v1/documents/%s/download", document.ID),
+		"signed": false,
+	}
+	if _, statErr := os.Stat(document.FilePath); statErr != nil && useS3Storage() {
+		expiry := s3URLExpiry()
+		signedURL, err := presignS3Get(r.Context(), normalizeStoredObjectPath(document.FilePath), document.FileName, document.FileType, expiry)
+		if err != nil {
+			http.Error(w, "failed to sign download: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		response["url"] = signedURL.String()
+		response["signed"] = true
+		response["expires_at"] = time.Now().Add(expiry)
+	}
+
+	middleware.ScopedDB(r).Model(&document).Update("download_count", gorm.Expr("download_count + 1"))
+	middleware.ScopedDB(r).Create(&models.DocumentAuditLog{
+		DocumentID: document.ID,
+		UserID:     &userID,
+		Action:     models.DocumentAuditActionDownload,
+		Details:    models.DocumentMetadata{"signed_url": response["signed"]},
+		IPAddress:  r.RemoteAddr,
+		UserAgent:  r.UserAgent(),
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// documentSearchResult is a matching document with its rank and an excerpt of its text
+// around the matched words
+type documentSearchResult struct {
+	models.Document
+	Rank    float64 `json:"rank"`
+	Snippet string  `json:"snippet,omitempty"`
+}
+
+// SearchDocumentsHandler finds documents whose title, description or file content (the
+// PDF text layer, or OCR of scans) contains every word of ?q=, best matches first. Users
+// see documents of the verticals they belong to, their own uploads, public documents and
+// documents shared with them. Filters: business_vertical_id, category_id, folder_id,
+// project_id; pages with limit and offset.
+// GET /api/v1/documents/search?q=...
+func SearchDocumentsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	tsquery := formSearchQuery(query)
+	if tsquery == "" {
+		http.Error(w, "search query is required", http.StatusBadRequest)
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	offset := 0
+	if raw := r.URL.Query().Get("offset"); raw != "" {
+		var err error
+		if offset, err = strconv.Atoi(raw); err != nil || offset < 0 || offset > maxFormSearchOffset {
+			http.Error(w, fmt.Sprintf("offset must be between 0 and %d", maxFormSearchOffset), http.StatusBadRequest)
+			return
+		}
+	}
+
+	userCtx, err := middleware.NewAuthService().LoadUserContext(r)
+	if err != nil || userCtx == nil || userCtx.User == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	search := middleware.ScopedDB(r).Model(&models.Document{}).
+		Where("search_vector @@ to_tsquery('simple', ?)", tsquery)
+	for _, filter := range []string{"business_vertical_id", "category_id", "project_id", "folder_id"} {
+		value := r.URL.Query().Get(filter)
+		if value == "" {
+			continue
+		}
+		if filter == "folder_id" && value == "root" {
+			search = search.Where("folder_id IS NULL")
+			continue
+		}
+		if _, err := uuid.Parse(value); err != nil {
+			http.Error(w, "invalid "+filter, http.StatusBadRequest)
+			return
+		}
+		search = search.Where(filter+" = ?", value)
+	}
+	if !userCtx.IsSuperAdmin {
+		search = scopeDocumentSearch(search, userCtx.User)
+	}
+
+	var matches []struct {
+		ID         uuid.UUID
+		SearchRank float64
+		Snippet    string
+	}
+	if err := search.
+		Select(`id, ts_rank(search_vector, to_tsquery('simple', ?)) AS search_rank,
+			ts_headline('simple', coalesce(content_text, ''), to_tsquery('simple', ?), 'MaxFragments=2, MinWords=5, MaxWords=20') AS snippet`,
+			tsquery, tsquery).
+		Order("search_rank DESC, created_at DESC, id DESC").
+		Limit(limit + 1).
+		Offset(offset).
+		Scan(&matches).Error; err != nil {
+		http.Error(w, "search failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	hasMore := len(matches) > limit
+	nextOffset := 0
+	if hasMore {
+		matches = matches[:limit]
+		nextOffset = offset + limit
+	}
+
+	ids := make([]uuid.UUID, len(matches))
+	for i, match := range matches {
+		ids[i] = match.ID
+	}
+	var documents []models.Document
+	if len(ids) > 0 {
+		if err := middleware.ScopedDB(r).Omit("content_text").
+			Preload("Category").
+			Preload("Tags").
+			Preload("UploadedBy", func(db *gorm.DB) *gorm.DB {
+				return db.Select("id", "name", "email", "phone")
+			}).
+			Where("id IN ?", ids).
+			Find(&documents).Error; err != nil {
+			http.Error(w, "search failed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	byID := make(map[uuid.UUID]models.Document, len(documents))
+	for _, document := range documents {
+		byID[document.ID] = document
+	}
+	results := make([]documentSearchResult, 0, len(matches))
+	for _, match := range matches {
+		if document, ok := byID[match.ID]; ok {
+			results = append(results, documentSearchResult{Document: document, Rank: match.SearchRank, Snippet: match.Snippet})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"query":       query,
+		"results":     results,
+		"count":       len(results),
+		"limit":       limit,
+		"offset":      offset,
+		"has_more":    hasMore,
+		"next_offset": nextOffset,
+	})
+}
+
+// scopeDocumentSearch limits a document query to what user may read: documents of the
+// verticals they belong to, their own uploads, public documents, and documents shared
+// with them or one of their roles in effect now by an unexpired permission
+func scopeDocumentSearch(query *gorm.DB, user *models.User) *gorm.DB {
+	verticals := middleware.GetUserAccessibleVerticals(user.ID)
+	now := time.Now()
+	var businessRoleIDs []uuid.UUID
+	for _, ubr := range user.UserBusinessRoles {
+		if ubr.IsEffectiveAt(now) {
+			businessRoleIDs = append(businessRoleIDs, ubr.BusinessRoleID)
+		}
+	}
+
+	shared := config.DB.Model(&models.DocumentPermission{}).Select("document_id").
+		Where("access_level <> ? AND (expires_at IS NULL OR expires_at > ?)", models.DocumentAccessNone, now).
+		Where(config.DB.Where("user_id = ?", user.ID).
+			Or("role_id = ?", user.RoleID).
+			Or("business_role_id IN ?", businessRoleIDs))
+
+	return query.Where(config.DB.Where("business_vertical_id IN ?", verticals).
+		Or("uploaded_by_id = ?", user.ID).
+		Or("is_public = ?", true).
+		Or("id IN (?)", shared))
+}
+
+// canAccessDocumentVertical reports whether the caller may reach documents of the
+// business vertical
+func canAccessDocumentVertical(r *http.Request, businessVerticalID *uuid.UUID) bool {
+	if businessVerticalID == nil {
+		return true
+	}
+	userCtx, err := middleware.NewAuthService().LoadUserContext(r)
+	return err == nil && middleware.CanAccessBusiness(userCtx, *businessVerticalID)
+}
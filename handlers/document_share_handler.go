@@ -12,7 +12,7 @@ import (
 	"github.com/gorilla/mux"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
-	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 )
 
@@ -41,7 +41,7 @@ func CreateDocumentShareHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Verify document exists
 	var document models.Document
-	if err := config.DB.First(&document, "id = ?", documentID).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&document, "id = ?", documentID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			http.Error(w, "document not found", http.StatusNotFound)
 		} else {
@@ -97,7 +97,7 @@ func CreateDocumentShareHandler(w http.ResponseWriter, r *http.Request) {
 		IsActive:    true,
 	}
 
-	if err := config.DB.Create(&share).Error; err != nil {
+	if err := middleware.ScopedDB(r).Create(&share).Error; err != nil {
 		http.Error(w, "failed to create share: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -111,7 +111,7 @@ func CreateDocumentShareHandler(w http.ResponseWriter, r *http.Request) {
 		IPAddress:  r.RemoteAddr,
 		UserAgent:  r.UserAgent(),
 	}
-	config.DB.Create(&auditLog)
+	middleware.ScopedDB(r).Create(&auditLog)
 
 	// Build share URL
 	baseURL := r.Header.Get("Origin")
@@ -134,7 +134,7 @@ func GetDocumentSharesHandler(w http.ResponseWriter, r *http.Request) {
 	documentID := vars["id"]
 
 	var shares []models.DocumentShare
-	if err := config.DB.Preload("CreatedBy").Where("document_id = ?", documentID).
+	if err := middleware.ScopedDB(r).Preload("CreatedBy").Where("document_id = ?", documentID).
 		Order("created_at DESC").Find(&shares).Error; err != nil {
 		http.Error(w, "failed to fetch shares: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -150,7 +150,7 @@ func AccessSharedDocumentHandler(w http.ResponseWriter, r *http.Request) {
 	shareToken := vars["token"]
 
 	var share models.DocumentShare
-	if err := config.DB.Preload("Document").Preload("Document.Category").Preload("Document.Tags").
+	if err := middleware.ScopedDB(r).Preload("Document").Preload("Document.Category").Preload("Document.Tags").
 		First(&share, "share_token = ?", shareToken).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			http.Error(w, "share link not found", http.StatusNotFound)
@@ -197,7 +197,7 @@ func AccessSharedDocumentHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Increment access count
-	config.DB.Model(&share).Update("access_count", gorm.Expr("access_count + 1"))
+	middleware.ScopedDB(r).Model(&share).Update("access_count", gorm.Expr("access_count + 1"))
 
 	// Log access
 	auditLog := models.DocumentAuditLog{
@@ -207,7 +207,7 @@ func AccessSharedDocumentHandler(w http.ResponseWriter, r *http.Request) {
 		IPAddress:  r.RemoteAddr,
 		UserAgent:  r.UserAgent(),
 	}
-	config.DB.Create(&auditLog)
+	middleware.ScopedDB(r).Create(&auditLog)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -223,7 +223,7 @@ func DownloadSharedDocumentHandler(w http.ResponseWriter, r *http.Request) {
 	shareToken := vars["token"]
 
 	var share models.DocumentShare
-	if err := config.DB.Preload("Document").First(&share, "share_token = ?", shareToken).Error; err != nil {
+	if err := middleware.ScopedDB(r).Preload("Document").First(&share, "share_token = ?", shareToken).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			http.Error(w, "share link not found", http.StatusNotFound)
 		} else {
@@ -257,7 +257,7 @@ func DownloadSharedDocumentHandler(w http.ResponseWriter, r *http.Request) {
 		IPAddress:  r.RemoteAddr,
 		UserAgent:  r.UserAgent(),
 	}
-	config.DB.Create(&auditLog)
+	middleware.ScopedDB(r).Create(&auditLog)
 
 	if err := serveStoredFile(w, r, share.Document.FilePath, share.Document.FileName, share.Document.FileType, share.Document.FileSize); err != nil {
 		if errors.Is(err, errStoredFileNotFound) {
@@ -279,7 +279,7 @@ func RevokeDocumentShareHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var share models.DocumentShare
-	if err := config.DB.First(&share, "id = ?", shareID).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&share, "id = ?", shareID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			http.Error(w, "share not found", http.StatusNotFound)
 		} else {
@@ -290,7 +290,7 @@ func RevokeDocumentShareHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Deactivate share
 	share.IsActive = false
-	if err := config.DB.Save(&share).Error; err != nil {
+	if err := middleware.ScopedDB(r).Save(&share).Error; err != nil {
 		http.Error(w, "failed to revoke share: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -304,7 +304,7 @@ func RevokeDocumentShareHandler(w http.ResponseWriter, r *http.Request) {
 		IPAddress:  r.RemoteAddr,
 		UserAgent:  r.UserAgent(),
 	}
-	config.DB.Create(&auditLog)
+	middleware.ScopedDB(r).Create(&auditLog)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
@@ -340,7 +340,7 @@ func GrantDocumentPermissionHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Verify document exists
 	var document models.Document
-	if err := config.DB.First(&document, "id = ?", documentID).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&document, "id = ?", documentID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			http.Error(w, "document not found", http.StatusNotFound)
 		} else {
@@ -386,7 +386,7 @@ func GrantDocumentPermissionHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if err := config.DB.Create(&permission).Error; err != nil {
+	if err := middleware.ScopedDB(r).Create(&permission).Error; err != nil {
 		http.Error(w, "failed to grant permission: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -400,7 +400,7 @@ func GrantDocumentPermissionHandler(w http.ResponseWriter, r *http.Request) {
 		IPAddress:  r.RemoteAddr,
 		UserAgent:  r.UserAgent(),
 	}
-	config.DB.Create(&auditLog)
+	middleware.ScopedDB(r).Create(&auditLog)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -415,7 +415,7 @@ func GetDocumentPermissionsHandler(w http.ResponseWriter, r *http.Request) {
 	documentID := vars["id"]
 
 	var permissions []models.DocumentPermission
-	if err := config.DB.Preload("User").Preload("Role").Preload("BusinessRole").
+	if err := middleware.ScopedDB(r).Preload("User").Preload("Role").Preload("BusinessRole").
 		Where("document_id = ?", documentID).Find(&permissions).Error; err != nil {
 		http.Error(w, "failed to fetch permissions: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -436,7 +436,7 @@ func RevokeDocumentPermissionHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var permission models.DocumentPermission
-	if err := config.DB.First(&permission, "id = ?", permissionID).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&permission, "id = ?", permissionID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			http.Error(w, "permission not found", http.StatusNotFound)
 		} else {
@@ -445,7 +445,7 @@ func RevokeDocumentPermissionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := config.DB.Delete(&permission).Error; err != nil {
+	if err := middleware.ScopedDB(r).Delete(&permission).Error; err != nil {
 		http.Error(w, "failed to revoke permission: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -459,7 +459,7 @@ func RevokeDocumentPermissionHandler(w http.ResponseWriter, r *http.Request) {
 		IPAddress:  r.RemoteAddr,
 		UserAgent:  r.UserAgent(),
 	}
-	config.DB.Create(&auditLog)
+	middleware.ScopedDB(r).Create(&auditLog)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
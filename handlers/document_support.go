@@ -69,6 +69,11 @@ func normalizeStoredObjectPath(storagePath string) string {
 	return strings.TrimPrefix(strings.TrimPrefix(trimmed, "./"), "/")
 }
 
+// OpenStoredFile opens an uploaded file from local disk or the configured S3 or GCS bucket.
+func OpenStoredFile(ctx context.Context, storagePath string) (io.ReadCloser, int64, error) {
+	return openStoredFileReader(ctx, storagePath)
+}
+
 func openStoredFileReader(ctx context.Context, storagePath string) (io.ReadCloser, int64, error) {
 	if storagePath == "" {
 		return nil, 0, errStoredFileNotFound
@@ -82,6 +87,10 @@ func openStoredFileReader(ctx context.Context, storagePath string) (io.ReadClose
 		return file, info.Size(), nil
 	}
 
+	if useS3Storage() {
+		return openS3Object(ctx, normalizeStoredObjectPath(storagePath))
+	}
+
 	if !useGCSStorage() {
 		return nil, 0, errStoredFileNotFound
 	}
@@ -107,6 +116,41 @@ func openStoredFileReader(ctx context.Context, storagePath string) (io.ReadClose
 	return reader, reader.Attrs.Size, nil
 }
 
+// removeStoredFile deletes an uploaded file from local disk or the configured S3 or GCS
+// bucket. A file that is already gone is not an error.
+func removeStoredFile(ctx context.Context, storagePath string) error {
+	if storagePath == "" {
+		return nil
+	}
+
+	if info, err := os.Stat(storagePath); err == nil && !info.IsDir() {
+		return os.Remove(storagePath)
+	}
+
+	if useS3Storage() {
+		return removeS3Object(ctx, normalizeStoredObjectPath(storagePath))
+	}
+
+	if !useGCSStorage() {
+		return nil
+	}
+
+	if err := validateExpectedGCPProject(); err != nil {
+		return err
+	}
+
+	client, err := getSharedGCSClient()
+	if err != nil {
+		return fmt.Errorf("failed to get GCS client: %w", err)
+	}
+
+	err = client.Bucket(getUploadBucketName()).Object(normalizeStoredObjectPath(storagePath)).Delete(ctx)
+	if err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return fmt.Errorf("failed to remove stored object: %w", err)
+	}
+	return nil
+}
+
 func serveStoredFile(w http.ResponseWriter, r *http.Request, storagePath, fileName, fileType string, fileSize int64) error {
 	reader, actualSize, err := openStoredFileReader(r.Context(), storagePath)
 	if err != nil {
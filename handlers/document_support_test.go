@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemoveStoredFileDeletesLocalUploads(t *testing.T) {
+	t.Setenv("S3_BUCKET", "")
+	t.Setenv("USE_GCS", "")
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	t.Setenv("K_SERVICE", "")
+
+	path := filepath.Join(t.TempDir(), "site-photo.jpg")
+	if err := os.WriteFile(path, []byte("jpeg"), 0o600); err != nil {
+		t.Fatalf("failed to write upload: %v", err)
+	}
+
+	if err := removeStoredFile(context.Background(), path); err != nil {
+		t.Fatalf("removeStoredFile: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("upload still on disk: %v", err)
+	}
+	// A purge retried after a partial failure finds some files already gone
+	if err := removeStoredFile(context.Background(), path); err != nil {
+		t.Errorf("removing a missing file: %v", err)
+	}
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/jobrunner"
+	"p9e.in/ugcl/pkg/textextract"
+)
+
+const (
+	// maxDocumentTextSourceBytes caps the files read for indexing; larger ones are marked
+	// unsupported rather than pulled into memory
+	maxDocumentTextSourceBytes = int64(50 << 20)
+	// maxDocumentContentBytes caps the text kept per document, well inside the 1MB a
+	// tsvector may hold
+	maxDocumentContentBytes = 256 << 10
+	// documentTextLease is how long a claimed document stays with one indexer before
+	// another may take it over, e.g. after a crash mid-OCR
+	documentTextLease = 15 * time.Minute
+	// documentTextTimeout bounds the extraction of one document
+	documentTextTimeout = 5 * time.Minute
+	documentTextBatch   = 20
+)
+
+// DocumentTextIndexer extracts the text of newly uploaded documents and of new versions
+// for full-text search: text files and Word documents directly, PDFs from their text
+// layer, and scanned PDFs and images by OCR.
+type DocumentTextIndexer struct {
+	db        *gorm.DB
+	extractor *textextract.Extractor
+	*jobrunner.Runner
+}
+
+// NewDocumentTextIndexer creates the document text indexer
+func NewDocumentTextIndexer() *DocumentTextIndexer {
+	extractor := textextract.NewFromEnv()
+	if !extractor.CanOCR() {
+		log.Println("⚠️  tesseract not found: scanned documents will not be searchable by content")
+	}
+	x := &DocumentTextIndexer{db: config.DB, extractor: extractor}
+	x.Runner = jobrunner.New("Document text indexer", func() {
+		if n, err := x.IndexDue(time.Now()); err != nil {
+			log.Printf("Error indexing document text: %v", err)
+		} else if n > 0 {
+			log.Printf("Document text indexer: processed %d documents", n)
+		}
+	})
+	return x
+}
+
+// IndexDue claims a batch of documents waiting for text extraction, extracts their text
+// and returns how many were processed.
+func (x *DocumentTextIndexer) IndexDue(now time.Time) (int, error) {
+	// Postgres keeps microseconds; the claim time is matched when the result is saved
+	claimedAt := now.Truncate(time.Microsecond)
+	var ids []uuid.UUID
+	if err := x.db.Raw(`UPDATE documents SET text_claimed_at = ?
+		WHERE id IN (
+			SELECT id FROM documents
+			WHERE text_status = ? AND deleted_at IS NULL
+				AND (text_claimed_at IS NULL OR text_claimed_at < ?)
+			ORDER BY created_at
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id`, claimedAt, models.DocumentTextPending, claimedAt.Add(-documentTextLease), documentTextBatch).
+		Scan(&ids).Error; err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		if err := x.index(id, claimedAt); err != nil {
+			log.Printf("❌ Failed to index text of document %s: %v", id, err)
+		}
+	}
+	return len(ids), nil
+}
+
+// index extracts one claimed document's text and records the outcome, unless the document
+// was requeued meanwhile (a new version, or a reindex request)
+func (x *DocumentTextIndexer) index(documentID uuid.UUID, claimedAt time.Time) error {
+	var document models.Document
+	if err := x.db.First(&document, "id = ?", documentID).Error; err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), documentTextTimeout)
+	defer cancel()
+
+	text, method, err := x.extract(ctx, &document)
+	updates := map[string]interface{}{
+		"content_text":      text,
+		"text_status":       models.DocumentTextIndexed,
+		"text_method":       method,
+		"text_error":        "",
+		"text_claimed_at":   nil,
+		"text_extracted_at": time.Now(),
+	}
+	if err != nil {
+		updates["text_status"] = models.DocumentTextFailed
+		if errors.Is(err, textextract.ErrUnsupported) {
+			updates["text_status"] = models.DocumentTextUnsupported
+		}
+		updates["text_error"] = truncateDocumentText(err.Error(), 500)
+	}
+
+	result := x.db.Model(&models.Document{}).
+		Where("id = ? AND text_status = ? AND text_claimed_at = ?", documentID, models.DocumentTextPending, claimedAt).
+		UpdateColumns(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 && updates["text_status"] == models.DocumentTextFailed {
+		return err
+	}
+	return nil
+}
+
+// extract reads the document's file and returns its text and how it was obtained
+func (x *DocumentTextIndexer) extract(ctx context.Context, document *models.Document) (string, string, error) {
+	ext := strings.ToLower(strings.TrimPrefix(document.FileExtension, "."))
+	contentType := strings.ToLower(document.FileType)
+	textLike := isLikelyTextContent(ext, contentType)
+	if !textLike && ext != "docx" && !textextract.Supports(document.FileName, contentType) {
+		return "", "", fmt.Errorf("%w: %s", textextract.ErrUnsupported, document.FileExtension)
+	}
+	if document.FileSize > maxDocumentTextSourceBytes {
+		return "", "", fmt.Errorf("%w: file larger than %dMB", textextract.ErrUnsupported, maxDocumentTextSourceBytes>>20)
+	}
+
+	reader, _, err := openStoredFileReader(ctx, document.FilePath)
+	if err != nil {
+		return "", "", err
+	}
+	data, err := io.ReadAll(io.LimitReader(reader, maxDocumentTextSourceBytes))
+	reader.Close()
+	if err != nil {
+		return "", "", err
+	}
+
+	var text, method string
+	switch {
+	case textLike:
+		text, method = sanitizeUTF8(string(data)), "text"
+	case ext == "docx":
+		if text, err = extractDOCXText(data); err != nil {
+			return "", "", err
+		}
+		method = "docx"
+	default:
+		result, err := x.extractor.Extract(ctx, data, document.FileName, contentType)
+		if err != nil {
+			return "", "", err
+		}
+		text, method = result.Text, result.Method
+	}
+	text = strings.ToValidUTF8(strings.ReplaceAll(text, "\x00", " "), "")
+	return truncateDocumentText(strings.TrimSpace(text), maxDocumentContentBytes), method, nil
+}
+
+// truncateDocumentText cuts text to at most maxBytes without splitting a character
+func truncateDocumentText(text string, maxBytes int) string {
+	if len(text) <= maxBytes {
+		return text
+	}
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut]
+}
+
+// requeueDocumentText marks a document's text for extraction again, releasing any claim
+// so an extraction already running for the old file is discarded
+func requeueDocumentText(tx *gorm.DB, documentID uuid.UUID) error {
+	return tx.Model(&models.Document{}).Where("id = ?", documentID).UpdateColumns(map[string]interface{}{
+		"text_status":     models.DocumentTextPending,
+		"text_claimed_at": nil,
+		"text_error":      "",
+	}).Error
+}
+
+// ReindexDocumentTextHandler queues a document's text to be extracted again, e.g. after
+// OCR languages were added or a failed extraction's cause was fixed.
+// POST /api/v1/documents/{id}/reindex
+func ReindexDocumentTextHandler(w http.ResponseWriter, r *http.Request) {
+	if _, err := getDocumentUserID(r); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	documentID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "document not found", http.StatusNotFound)
+		return
+	}
+
+	var document models.Document
+	if err := middleware.ScopedDB(r).Select("id", "business_vertical_id").First(&document, "id = ?", documentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "document not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "failed to fetch document: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if !canAccessDocumentVertical(r, document.BusinessVerticalID) {
+		http.Error(w, "document not found", http.StatusNotFound)
+		return
+	}
+
+	if err := requeueDocumentText(middleware.ScopedDB(r), document.ID); err != nil {
+		http.Error(w, "failed to queue reindex: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":     "Document queued for text indexing",
+		"document_id": document.ID,
+		"text_status": models.DocumentTextPending,
+	})
+}
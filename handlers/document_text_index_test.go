@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateDocumentText(t *testing.T) {
+	cases := []struct {
+		text     string
+		maxBytes int
+		want     string
+	}{
+		{"work order", 20, "work order"},
+		{"work order", 4, "work"},
+		{"कार्य आदेश", 4, "क"}, // each Devanagari letter is 3 bytes
+		{"", 4, ""},
+	}
+	for _, c := range cases {
+		got := truncateDocumentText(c.text, c.maxBytes)
+		if got != c.want {
+			t.Errorf("truncateDocumentText(%q, %d) = %q, want %q", c.text, c.maxBytes, got, c.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("truncateDocumentText(%q, %d) split a character", c.text, c.maxBytes)
+		}
+	}
+}
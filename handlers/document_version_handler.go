@@ -12,7 +12,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"gorm.io/gorm"
-	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 )
 
@@ -22,7 +22,7 @@ func GetDocumentVersionsHandler(w http.ResponseWriter, r *http.Request) {
 	documentID := vars["id"]
 
 	var versions []models.DocumentVersion
-	if err := config.DB.Preload("CreatedBy").Where("document_id = ?", documentID).
+	if err := middleware.ScopedDB(r).Preload("CreatedBy").Where("document_id = ?", documentID).
 		Order("version_number DESC").Find(&versions).Error; err != nil {
 		http.Error(w, "failed to fetch versions: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -44,7 +44,7 @@ func CreateDocumentVersionHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Get existing document
 	var document models.Document
-	if err := config.DB.First(&document, "id = ?", documentID).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&document, "id = ?", documentID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			http.Error(w, "document not found", http.StatusNotFound)
 		} else {
@@ -93,7 +93,7 @@ func CreateDocumentVersionHandler(w http.ResponseWriter, r *http.Request) {
 	fileSize := upload.Size
 
 	// Start transaction
-	tx := config.DB.Begin()
+	tx := middleware.ScopedDB(r).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -142,6 +142,9 @@ func CreateDocumentVersionHandler(w http.ResponseWriter, r *http.Request) {
 	document.FileHash = fileHash
 	document.FileName = upload.OriginalFilename
 	document.FileExtension = ext
+	// The new file's text replaces the old one's in search once extracted
+	document.TextStatus = models.DocumentTextPending
+	document.TextClaimedAt = nil
 
 	if err := tx.Save(&document).Error; err != nil {
 		tx.Rollback()
@@ -167,7 +170,7 @@ func CreateDocumentVersionHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Load relationships
-	config.DB.Preload("CreatedBy").First(&version, version.ID)
+	middleware.ScopedDB(r).Preload("CreatedBy").First(&version, version.ID)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -189,7 +192,7 @@ func RollbackDocumentVersionHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Get document
 	var document models.Document
-	if err := config.DB.First(&document, "id = ?", documentID).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&document, "id = ?", documentID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			http.Error(w, "document not found", http.StatusNotFound)
 		} else {
@@ -200,7 +203,7 @@ func RollbackDocumentVersionHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Get target version
 	var targetVersion models.DocumentVersion
-	if err := config.DB.First(&targetVersion, "id = ? AND document_id = ?", versionID, documentID).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&targetVersion, "id = ? AND document_id = ?", versionID, documentID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			http.Error(w, "version not found", http.StatusNotFound)
 		} else {
@@ -210,7 +213,7 @@ func RollbackDocumentVersionHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Start transaction
-	tx := config.DB.Begin()
+	tx := middleware.ScopedDB(r).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -233,6 +236,8 @@ func RollbackDocumentVersionHandler(w http.ResponseWriter, r *http.Request) {
 	document.FileType = targetVersion.FileType
 	document.FileHash = targetVersion.FileHash
 	document.FileName = targetVersion.FileName
+	document.TextStatus = models.DocumentTextPending
+	document.TextClaimedAt = nil
 
 	if err := tx.Save(&document).Error; err != nil {
 		tx.Rollback()
@@ -276,7 +281,7 @@ func DownloadDocumentVersionHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var version models.DocumentVersion
-	if err := config.DB.First(&version, "id = ? AND document_id = ?", versionID, documentID).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&version, "id = ? AND document_id = ?", versionID, documentID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			http.Error(w, "version not found", http.StatusNotFound)
 		} else {
@@ -294,7 +299,7 @@ func DownloadDocumentVersionHandler(w http.ResponseWriter, r *http.Request) {
 		IPAddress:  r.RemoteAddr,
 		UserAgent:  r.UserAgent(),
 	}
-	config.DB.Create(&auditLog)
+	middleware.ScopedDB(r).Create(&auditLog)
 
 	if err := serveStoredFile(w, r, version.FilePath, version.FileName, version.FileType, version.FileSize); err != nil {
 		if errors.Is(err, errStoredFileNotFound) {
@@ -318,12 +323,12 @@ func CompareDocumentVersionsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var version1, version2 models.DocumentVersion
-	if err := config.DB.Preload("CreatedBy").First(&version1, "id = ? AND document_id = ?", version1ID, documentID).Error; err != nil {
+	if err := middleware.ScopedDB(r).Preload("CreatedBy").First(&version1, "id = ? AND document_id = ?", version1ID, documentID).Error; err != nil {
 		http.Error(w, "version1 not found", http.StatusNotFound)
 		return
 	}
 
-	if err := config.DB.Preload("CreatedBy").First(&version2, "id = ? AND document_id = ?", version2ID, documentID).Error; err != nil {
+	if err := middleware.ScopedDB(r).Preload("CreatedBy").First(&version2, "id = ? AND document_id = ?", version2ID, documentID).Error; err != nil {
 		http.Error(w, "version2 not found", http.StatusNotFound)
 		return
 	}
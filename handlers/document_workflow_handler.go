@@ -349,7 +349,7 @@ func getDocumentWorkflowHistory(documentID uuid.UUID) ([]documentWorkflowHistory
 
 func ListDocumentWorkflowsHandler(w http.ResponseWriter, r *http.Request) {
 	var workflows []models.WorkflowDefinition
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Where("is_active = ?", true).
 		Order("name ASC").
 		Find(&workflows).Error; err != nil {
@@ -384,7 +384,7 @@ func GetDocumentWorkflowHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var document models.Document
-	if err := config.DB.Preload("Workflow").First(&document, "id = ?", documentID).Error; err != nil {
+	if err := middleware.ScopedDB(r).Preload("Workflow").First(&document, "id = ?", documentID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			http.Error(w, "document not found", http.StatusNotFound)
 			return
@@ -455,7 +455,7 @@ func TransitionDocumentWorkflowHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var document models.Document
-	if err := config.DB.Preload("Workflow").First(&document, "id = ?", documentID).Error; err != nil {
+	if err := middleware.ScopedDB(r).Preload("Workflow").First(&document, "id = ?", documentID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			http.Error(w, "document not found", http.StatusNotFound)
 			return
@@ -509,7 +509,7 @@ func TransitionDocumentWorkflowHandler(w http.ResponseWriter, r *http.Request) {
 	toState := targetTransition.To
 	toStatus := mapDocumentStateToStatus(toState)
 
-	tx := config.DB.Begin()
+	tx := middleware.ScopedDB(r).Begin()
 	defer func() {
 		if rec := recover(); rec != nil {
 			tx.Rollback()
@@ -620,7 +620,7 @@ func TransitionDocumentWorkflowHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if err := config.DB.Preload("Category").Preload("Tags").Preload("UploadedBy").Preload("Workflow").First(&document, "id = ?", document.ID).Error; err != nil {
+	if err := middleware.ScopedDB(r).Preload("Category").Preload("Tags").Preload("UploadedBy").Preload("Workflow").First(&document, "id = ?", document.ID).Error; err != nil {
 		http.Error(w, "failed to fetch updated document: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -7,7 +7,6 @@ import (
 
 	"github.com/gorilla/mux"
 	"gorm.io/gorm/clause"
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 )
@@ -24,7 +23,7 @@ func GetAllSiteEngineerReports(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	service := models.NewReportService(config.DB, models.DprSite{})
+	service := models.NewReportService(middleware.ScopedDB(r), models.DprSite{})
 	response, err := service.GetReport(params)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -199,7 +198,7 @@ func CreateSiteEngineerReport(w http.ResponseWriter, r *http.Request) {
 	report.InformationEnteredBy = user.Name
 	report.PhoneNumberOfInformationEnteredPerson = user.Phone
 
-	config.DB.Create(&report)
+	middleware.ScopedDB(r).Create(&report)
 	json.NewEncoder(w).Encode(report)
 }
 
@@ -207,7 +206,7 @@ func GetSiteEngineerReport(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id, _ := strconv.Atoi(params["id"])
 	var report models.DprSite
-	config.DB.First(&report, id)
+	middleware.ScopedDB(r).First(&report, id)
 	json.NewEncoder(w).Encode(report)
 }
 
@@ -215,16 +214,16 @@ func UpdateSiteEngineerReport(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id, _ := strconv.Atoi(params["id"])
 	var report models.DprSite
-	config.DB.First(&report, id)
+	middleware.ScopedDB(r).First(&report, id)
 	json.NewDecoder(r.Body).Decode(&report)
-	config.DB.Save(&report)
+	middleware.ScopedDB(r).Save(&report)
 	json.NewEncoder(w).Encode(report)
 }
 
 func DeleteSiteEngineerReport(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id, _ := strconv.Atoi(params["id"])
-	config.DB.Delete(&models.DprSite{}, id)
+	middleware.ScopedDB(r).Delete(&models.DprSite{}, id)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -241,7 +240,7 @@ func BatchDprSites(w http.ResponseWriter, r *http.Request) {
 		batch[i].InformationEnteredBy = user.Name
 		batch[i].PhoneNumberOfInformationEnteredPerson = user.Phone
 	}
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Clauses(clause.OnConflict{
 			Columns:   []clause.Column{{Name: "id"}},
 			DoNothing: true,
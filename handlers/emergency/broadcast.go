@@ -0,0 +1,427 @@
+// Package emergency sends site-wide safety broadcasts over every available channel and
+// tracks each recipient's acknowledgment, escalating to voice calls for non-responders.
+package emergency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/handlers"
+	"p9e.in/ugcl/handlers/chat"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/alerting"
+)
+
+const (
+	defaultEscalateAfterMinutes = 5
+	defaultMaxEscalations       = 3
+	deliveryTimeout             = 20 * time.Second
+)
+
+var (
+	gatewayOnce sync.Once
+	gateway     alerting.Gateway
+)
+
+// alertGateway returns the SMS/voice gateway, or nil when none is configured
+func alertGateway() alerting.Gateway {
+	gatewayOnce.Do(func() {
+		gateway = alerting.NewGatewayFromEnv()
+		if gateway == nil {
+			log.Println("ℹ️ emergency broadcasts: ALERT_GATEWAY_URL not set, SMS and voice delivery disabled")
+		}
+	})
+	return gateway
+}
+
+// BroadcastRequest is the payload for sending an emergency broadcast
+type BroadcastRequest struct {
+	SiteID               uuid.UUID `json:"site_id"`
+	Title                string    `json:"title"`
+	Message              string    `json:"message"`
+	Severity             string    `json:"severity"` // warning or critical (default)
+	Channels             []string  `json:"channels"`
+	EscalateAfterMinutes int       `json:"escalate_after_minutes"`
+	MaxEscalations       *int      `json:"max_escalations"`
+}
+
+// Service creates broadcasts, delivers them and records acknowledgments
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService creates an emergency broadcast service
+func NewService() *Service {
+	return &Service{db: config.DB}
+}
+
+// CreateBroadcast stores the broadcast with one recipient row per user at the site and
+// starts delivery in the background.
+func (s *Service) CreateBroadcast(businessID, senderID uuid.UUID, req BroadcastRequest) (*models.EmergencyBroadcast, error) {
+	req.Title = strings.TrimSpace(req.Title)
+	req.Message = strings.TrimSpace(req.Message)
+	if req.Title == "" || req.Message == "" {
+		return nil, errors.New("title and message are required")
+	}
+	channels, err := models.NormalizeEmergencyChannels(req.Channels)
+	if err != nil {
+		return nil, err
+	}
+	severity := strings.ToLower(strings.TrimSpace(req.Severity))
+	if severity == "" {
+		severity = "critical"
+	}
+	if severity != "critical" && severity != "warning" {
+		return nil, errors.New("severity must be warning or critical")
+	}
+	escalateAfter := req.EscalateAfterMinutes
+	if escalateAfter <= 0 {
+		escalateAfter = defaultEscalateAfterMinutes
+	}
+	maxEscalations := defaultMaxEscalations
+	if req.MaxEscalations != nil {
+		if *req.MaxEscalations < 0 || *req.MaxEscalations > 10 {
+			return nil, errors.New("max_escalations must be between 0 and 10")
+		}
+		maxEscalations = *req.MaxEscalations
+	}
+
+	var site models.Site
+	if err := s.db.Where("id = ? AND business_vertical_id = ?", req.SiteID, businessID).First(&site).Error; err != nil {
+		return nil, errors.New("site not found in this business")
+	}
+
+	users, err := s.siteUsers(site.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve site users: %w", err)
+	}
+	if len(users) == 0 {
+		return nil, errors.New("no active users are assigned to this site")
+	}
+
+	now := time.Now()
+	broadcast := &models.EmergencyBroadcast{
+		BusinessVerticalID:   businessID,
+		SiteID:               site.ID,
+		Title:                req.Title,
+		Message:              req.Message,
+		Severity:             severity,
+		Channels:             channels,
+		EscalateAfterMinutes: escalateAfter,
+		MaxEscalations:       maxEscalations,
+		Status:               models.EmergencyBroadcastActive,
+		CreatedBy:            senderID,
+		CreatedAt:            now,
+	}
+
+	var nextEscalation *time.Time
+	if maxEscalations > 0 {
+		next := now.Add(time.Duration(escalateAfter) * time.Minute)
+		nextEscalation = &next
+	}
+	recipients := make([]models.EmergencyBroadcastRecipient, len(users))
+	for i, user := range users {
+		recipients[i] = models.EmergencyBroadcastRecipient{
+			UserID:           user.ID,
+			Name:             user.Name,
+			Phone:            user.Phone,
+			NextEscalationAt: nextEscalation,
+		}
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(broadcast).Error; err != nil {
+			return err
+		}
+		for i := range recipients {
+			recipients[i].BroadcastID = broadcast.ID
+		}
+		return tx.CreateInBatches(recipients, 200).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create broadcast: %w", err)
+	}
+
+	log.Printf("🚨 Emergency broadcast %s at site %s sent to %d users by %s", broadcast.ID, site.Code, len(recipients), senderID)
+
+	go s.deliver(broadcast, recipients)
+	return broadcast, nil
+}
+
+// siteUsers returns the active users with access to the site or a role scoped to it
+func (s *Service) siteUsers(siteID uuid.UUID) ([]models.User, error) {
+	siteAccess := s.db.Model(&models.UserSiteAccess{}).Select("user_id").Where("site_id = ?", siteID)
+	siteRoles := s.db.Model(&models.UserBusinessRole{}).Select("user_id").Where("site_id = ? AND is_active = ?", siteID, true)
+
+	var users []models.User
+	err := s.db.Select("id", "name", "phone").
+		Where("is_active = ? AND (id IN (?) OR id IN (?))", true, siteAccess, siteRoles).
+		Find(&users).Error
+	return users, err
+}
+
+// deliver sends the initial alert: an in-app notification and push to everyone, an SMS
+// when enabled, and an ack-required message in a broadcast chat group.
+func (s *Service) deliver(broadcast *models.EmergencyBroadcast, recipients []models.EmergencyBroadcastRecipient) {
+	if broadcast.HasChannel(models.EmergencyChannelChat) {
+		if err := s.postToChat(broadcast, recipients); err != nil {
+			log.Printf("⚠️ emergency broadcast %s: chat delivery failed: %v", broadcast.ID, err)
+		}
+	}
+
+	for i := range recipients {
+		s.alertRecipient(broadcast, &recipients[i], false)
+	}
+}
+
+// alertRecipient pushes the broadcast to one recipient and, when enabled, texts them.
+// Escalation rounds additionally place a voice call.
+func (s *Service) alertRecipient(broadcast *models.EmergencyBroadcast, recipient *models.EmergencyBroadcastRecipient, escalation bool) {
+	now := time.Now()
+	updates := map[string]interface{}{}
+	var failures []string
+
+	title := "🚨 " + broadcast.Title
+	if escalation {
+		title = fmt.Sprintf("🚨 REMINDER %d: %s", recipient.EscalationLevel, broadcast.Title)
+	}
+
+	if broadcast.HasChannel(models.EmergencyChannelPush) {
+		s.notify(broadcast, recipient.UserID, title, broadcast.Message)
+		updates["push_sent_at"] = now
+	}
+
+	gw := alertGateway()
+	ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+	defer cancel()
+
+	if broadcast.HasChannel(models.EmergencyChannelSMS) && gw != nil {
+		text := fmt.Sprintf("EMERGENCY: %s - %s. Acknowledge in the app.", broadcast.Title, broadcast.Message)
+		if err := gw.SendSMS(ctx, recipient.Phone, text); err != nil {
+			failures = append(failures, err.Error())
+		} else {
+			updates["sms_sent_at"] = now
+		}
+	}
+
+	if escalation && broadcast.HasChannel(models.EmergencyChannelVoice) && gw != nil {
+		text := fmt.Sprintf("This is an emergency alert. %s. %s. Please acknowledge in the app immediately.", broadcast.Title, broadcast.Message)
+		if err := gw.PlaceVoiceCall(ctx, recipient.Phone, text); err != nil {
+			failures = append(failures, err.Error())
+		} else {
+			updates["voice_called_at"] = now
+		}
+	}
+
+	if len(failures) > 0 {
+		updates["delivery_error"] = strings.Join(failures, "; ")
+	}
+	if len(updates) == 0 {
+		return
+	}
+	if err := s.db.Model(&models.EmergencyBroadcastRecipient{}).Where("id = ?", recipient.ID).Updates(updates).Error; err != nil {
+		log.Printf("⚠️ emergency broadcast %s: failed to record delivery to %s: %v", broadcast.ID, recipient.UserID, err)
+	}
+}
+
+// notify stores a critical in-app notification and sends it as a mobile push, bypassing
+// the user's notification preferences.
+func (s *Service) notify(broadcast *models.EmergencyBroadcast, userID uuid.UUID, title, body string) {
+	now := time.Now()
+	notification := models.Notification{
+		UserID:             userID.String(),
+		Type:               models.NotificationTypeEmergency,
+		Priority:           models.NotificationPriorityCritical,
+		Title:              title,
+		Body:               body,
+		ActionURL:          "/emergency/broadcasts/" + broadcast.ID.String(),
+		BusinessVerticalID: &broadcast.BusinessVerticalID,
+		ConversationID:     broadcast.ConversationID,
+		MessageID:          broadcast.ChatMessageID,
+		Metadata:           models.JSONMap{"broadcast_id": broadcast.ID.String(), "site_id": broadcast.SiteID.String()},
+		Status:             models.NotificationStatusSent,
+		Channel:            models.NotificationChannelMobilePush,
+		SentAt:             &now,
+	}
+	if err := s.db.Create(&notification).Error; err != nil {
+		log.Printf("⚠️ emergency broadcast %s: failed to create notification for %s: %v", broadcast.ID, userID, err)
+	}
+
+	handlers.NewNotificationService().SendMobilePushToUser(userID.String(), models.NotificationTypeEmergency, title, body, map[string]string{
+		"type":            string(models.NotificationTypeEmergency),
+		"notification_id": notification.ID.String(),
+		"broadcast_id":    broadcast.ID.String(),
+		"action_url":      notification.ActionURL,
+	})
+}
+
+// postToChat creates a group with every recipient and posts the broadcast as a message
+// that each participant must acknowledge. Acknowledging it there acknowledges the broadcast.
+func (s *Service) postToChat(broadcast *models.EmergencyBroadcast, recipients []models.EmergencyBroadcastRecipient) error {
+	chatService := chat.NewChatService()
+	sender := broadcast.CreatedBy.String()
+
+	members := make([]string, 0, len(recipients))
+	for _, r := range recipients {
+		if r.UserID != broadcast.CreatedBy {
+			members = append(members, r.UserID.String())
+		}
+	}
+	if len(members) == 0 {
+		return nil
+	}
+
+	conversation, err := chatService.CreateGroup(sender, models.CreateGroupRequest{
+		Title:           "🚨 " + broadcast.Title,
+		MemberIDs:       members,
+		MaxParticipants: len(members) + 1,
+		Metadata:        map[string]interface{}{"emergency_broadcast_id": broadcast.ID.String()},
+	})
+	if err != nil {
+		return err
+	}
+
+	message, err := chatService.SendMessage(conversation.ID, sender, models.SendMessageRequest{
+		Content:     fmt.Sprintf("🚨 %s\n\n%s", broadcast.Title, broadcast.Message),
+		RequiresAck: true,
+		Metadata:    map[string]interface{}{"emergency_broadcast_id": broadcast.ID.String()},
+	})
+	if err != nil {
+		return err
+	}
+
+	broadcast.ConversationID = &conversation.ID
+	broadcast.ChatMessageID = &message.ID
+	return s.db.Model(&models.EmergencyBroadcast{}).Where("id = ?", broadcast.ID).Updates(map[string]interface{}{
+		"conversation_id": conversation.ID,
+		"chat_message_id": message.ID,
+	}).Error
+}
+
+// Acknowledge records a recipient's acknowledgment. recordedBy is set when an admin
+// records it on the user's behalf (for example after reaching them by phone).
+// It returns false when the user had already acknowledged.
+func (s *Service) Acknowledge(broadcastID, userID uuid.UUID, channel, note string, recordedBy *uuid.UUID) (bool, error) {
+	var broadcast models.EmergencyBroadcast
+	if err := s.db.First(&broadcast, "id = ?", broadcastID).Error; err != nil {
+		return false, errors.New("broadcast not found")
+	}
+
+	var recipient models.EmergencyBroadcastRecipient
+	if err := s.db.Where("broadcast_id = ? AND user_id = ?", broadcastID, userID).First(&recipient).Error; err != nil {
+		return false, errors.New("user is not a recipient of this broadcast")
+	}
+
+	result := s.db.Model(&models.EmergencyBroadcastRecipient{}).
+		Where("id = ? AND acknowledged_at IS NULL", recipient.ID).
+		Updates(map[string]interface{}{
+			"acknowledged_at":    time.Now(),
+			"ack_channel":        channel,
+			"ack_note":           strings.TrimSpace(note),
+			"ack_recorded_by":    recordedBy,
+			"next_escalation_at": nil,
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to record acknowledgment: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	// Keep the chat group's pending acknowledgement in step
+	if channel != models.EmergencyChannelChat && broadcast.ChatMessageID != nil {
+		if _, err := chat.NewChatService().AcknowledgeMessage(*broadcast.ChatMessageID, userID.String()); err != nil {
+			log.Printf("⚠️ emergency broadcast %s: failed to acknowledge chat message for %s: %v", broadcastID, userID, err)
+		}
+	}
+	return true, nil
+}
+
+// Close stops escalation for a broadcast
+func (s *Service) Close(broadcast *models.EmergencyBroadcast, closedBy uuid.UUID) error {
+	now := time.Now()
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(broadcast).Updates(map[string]interface{}{
+			"status":    models.EmergencyBroadcastClosed,
+			"closed_at": now,
+			"closed_by": closedBy,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.EmergencyBroadcastRecipient{}).
+			Where("broadcast_id = ? AND next_escalation_at IS NOT NULL", broadcast.ID).
+			Update("next_escalation_at", nil).Error
+	})
+}
+
+// BroadcastSummary is the acknowledgment dashboard for a broadcast
+type BroadcastSummary struct {
+	Total              int            `json:"total"`
+	Acknowledged       int            `json:"acknowledged"`
+	Pending            int            `json:"pending"`
+	AcknowledgedRate   float64        `json:"acknowledged_rate"`
+	ByAckChannel       map[string]int `json:"by_ack_channel"`
+	PendingByLevel     map[int]int    `json:"pending_by_escalation_level"`
+	DeliveryFailures   int            `json:"delivery_failures"`
+	MedianAckSeconds   *float64       `json:"median_ack_seconds,omitempty"`
+	ElapsedSeconds     float64        `json:"elapsed_seconds"`
+	NextEscalationAt   *time.Time     `json:"next_escalation_at,omitempty"`
+	EscalationsPending bool           `json:"escalations_pending"`
+}
+
+// Summarize computes the acknowledgment dashboard from the recipient rows
+func Summarize(broadcast *models.EmergencyBroadcast, recipients []models.EmergencyBroadcastRecipient, now time.Time) BroadcastSummary {
+	summary := BroadcastSummary{
+		Total:          len(recipients),
+		ByAckChannel:   map[string]int{},
+		PendingByLevel: map[int]int{},
+		ElapsedSeconds: now.Sub(broadcast.CreatedAt).Seconds(),
+	}
+	if broadcast.ClosedAt != nil {
+		summary.ElapsedSeconds = broadcast.ClosedAt.Sub(broadcast.CreatedAt).Seconds()
+	}
+
+	var ackSeconds []float64
+	for _, r := range recipients {
+		if r.DeliveryError != "" {
+			summary.DeliveryFailures++
+		}
+		if r.AcknowledgedAt != nil {
+			summary.Acknowledged++
+			summary.ByAckChannel[r.AckChannel]++
+			ackSeconds = append(ackSeconds, r.AcknowledgedAt.Sub(broadcast.CreatedAt).Seconds())
+			continue
+		}
+		summary.Pending++
+		summary.PendingByLevel[r.EscalationLevel]++
+		if r.NextEscalationAt != nil {
+			summary.EscalationsPending = true
+			if summary.NextEscalationAt == nil || r.NextEscalationAt.Before(*summary.NextEscalationAt) {
+				next := *r.NextEscalationAt
+				summary.NextEscalationAt = &next
+			}
+		}
+	}
+
+	if summary.Total > 0 {
+		summary.AcknowledgedRate = float64(summary.Acknowledged) / float64(summary.Total)
+	}
+	if n := len(ackSeconds); n > 0 {
+		sort.Float64s(ackSeconds)
+		median := ackSeconds[n/2]
+		if n%2 == 0 {
+			median = (ackSeconds[n/2-1] + ackSeconds[n/2]) / 2
+		}
+		summary.MedianAckSeconds = &median
+	}
+	return summary
+}
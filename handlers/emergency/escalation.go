@@ -0,0 +1,139 @@
+package emergency
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/hooks"
+	"p9e.in/ugcl/pkg/jobrunner"
+)
+
+// ackPlugin acknowledges a broadcast when its recipient acknowledges the broadcast's
+// message in the chat group.
+type ackPlugin struct{}
+
+func init() {
+	hooks.RegisterPlugin(ackPlugin{})
+}
+
+func (ackPlugin) Name() string { return "emergency-broadcast-ack" }
+
+func (ackPlugin) Register(r *hooks.Registrar) error {
+	r.OnChatMessage(func(ctx context.Context, event hooks.ChatMessageEvent) error {
+		if event.Action != hooks.ChatMessageAcknowledged {
+			return nil
+		}
+		userID, err := uuid.Parse(event.ActorID)
+		if err != nil {
+			return nil
+		}
+
+		service := NewService()
+		var broadcast models.EmergencyBroadcast
+		if err := service.db.WithContext(ctx).Select("id").Where("chat_message_id = ?", event.MessageID).First(&broadcast).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil
+			}
+			return err
+		}
+		_, err = service.Acknowledge(broadcast.ID, userID, models.EmergencyChannelChat, "", nil)
+		return err
+	})
+	return nil
+}
+
+// Escalator re-alerts recipients who have not acknowledged an active broadcast once its
+// escalation interval has passed: a push reminder, SMS and a voice call per round. After
+// the last round the sender is told who is still unaccounted for.
+type Escalator struct {
+	service *Service
+	*jobrunner.Runner
+}
+
+// NewEscalator creates the emergency escalation job
+func NewEscalator() *Escalator {
+	e := &Escalator{service: NewService()}
+	e.Runner = jobrunner.New("Emergency escalation job", func() {
+		if n, err := e.EscalateDue(time.Now()); err != nil {
+			log.Printf("Error escalating emergency broadcasts: %v", err)
+		} else if n > 0 {
+			log.Printf("Emergency escalation: re-alerted %d unacknowledged recipients", n)
+		}
+	})
+	return e
+}
+
+// EscalateDue runs one escalation round for every unacknowledged recipient whose next
+// escalation is due and returns how many were re-alerted.
+func (e *Escalator) EscalateDue(now time.Time) (int, error) {
+	db := e.service.db
+
+	var due []models.EmergencyBroadcastRecipient
+	if err := db.
+		Joins("JOIN emergency_broadcasts b ON b.id = emergency_broadcast_recipients.broadcast_id").
+		Where("b.status = ? AND emergency_broadcast_recipients.acknowledged_at IS NULL", models.EmergencyBroadcastActive).
+		Where("emergency_broadcast_recipients.next_escalation_at <= ?", now).
+		Limit(500).
+		Find(&due).Error; err != nil {
+		return 0, err
+	}
+
+	broadcasts := make(map[uuid.UUID]*models.EmergencyBroadcast)
+	exhausted := make(map[uuid.UUID][]string)
+	escalated := 0
+
+	for i := range due {
+		recipient := &due[i]
+		broadcast, ok := broadcasts[recipient.BroadcastID]
+		if !ok {
+			broadcast = &models.EmergencyBroadcast{}
+			if err := db.First(broadcast, "id = ?", recipient.BroadcastID).Error; err != nil {
+				continue
+			}
+			broadcasts[recipient.BroadcastID] = broadcast
+		}
+
+		level := recipient.EscalationLevel + 1
+		var next *time.Time
+		if level < broadcast.MaxEscalations {
+			t := now.Add(time.Duration(broadcast.EscalateAfterMinutes) * time.Minute)
+			next = &t
+		}
+
+		// Claim the round so concurrent instances do not alert twice
+		result := db.Model(&models.EmergencyBroadcastRecipient{}).
+			Where("id = ? AND escalation_level = ? AND acknowledged_at IS NULL", recipient.ID, recipient.EscalationLevel).
+			Updates(map[string]interface{}{
+				"escalation_level":   level,
+				"next_escalation_at": next,
+			})
+		if result.Error != nil {
+			return escalated, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		recipient.EscalationLevel = level
+		e.service.alertRecipient(broadcast, recipient, true)
+		escalated++
+
+		if next == nil {
+			exhausted[broadcast.ID] = append(exhausted[broadcast.ID], recipient.Name)
+		}
+	}
+
+	for broadcastID, names := range exhausted {
+		broadcast := broadcasts[broadcastID]
+		body := fmt.Sprintf("%d recipient(s) have not acknowledged after %d reminders: %s", len(names), broadcast.MaxEscalations, strings.Join(names, ", "))
+		e.service.notify(broadcast, broadcast.CreatedBy, "🚨 Unacknowledged: "+broadcast.Title, body)
+	}
+
+	return escalated, nil
+}
@@ -0,0 +1,284 @@
+package emergency
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/handlers/httputil"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// Handler serves the emergency broadcast endpoints
+type Handler struct {
+	service *Service
+}
+
+// NewHandler creates an emergency broadcast handler
+func NewHandler() *Handler {
+	return &Handler{service: NewService()}
+}
+
+// SendBroadcast alerts every user at a site
+// POST /api/v1/business/{businessCode}/emergency/broadcasts
+func (h *Handler) SendBroadcast(w http.ResponseWriter, r *http.Request) {
+	businessID := middleware.GetCurrentBusinessID(r)
+	if businessID == uuid.Nil {
+		http.Error(w, "invalid business identifier", http.StatusBadRequest)
+		return
+	}
+	senderID, ok := httputil.ClaimsUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req BroadcastRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.SiteID == uuid.Nil {
+		http.Error(w, "site_id is required", http.StatusBadRequest)
+		return
+	}
+	if !middleware.SiteInScope(r, &req.SiteID) {
+		http.Error(w, "site is outside your access scope", http.StatusForbidden)
+		return
+	}
+
+	broadcast, err := h.service.CreateBroadcast(businessID, senderID, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusCreated, map[string]interface{}{"broadcast": broadcast})
+}
+
+// ListBroadcasts lists the business's broadcasts, newest first (?status=active|closed&site_id=)
+// GET /api/v1/business/{businessCode}/emergency/broadcasts
+func (h *Handler) ListBroadcasts(w http.ResponseWriter, r *http.Request) {
+	businessID := middleware.GetCurrentBusinessID(r)
+	if businessID == uuid.Nil {
+		http.Error(w, "invalid business identifier", http.StatusBadRequest)
+		return
+	}
+
+	query := h.service.db.Preload("Site").Where("business_vertical_id = ?", businessID)
+	if status := strings.TrimSpace(r.URL.Query().Get("status")); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if siteID, err := uuid.Parse(r.URL.Query().Get("site_id")); err == nil {
+		query = query.Where("site_id = ?", siteID)
+	}
+	if siteIDs, restricted := middleware.GetSiteScope(r); restricted {
+		query = query.Where("site_id IN ?", siteIDs)
+	}
+	limit := 50
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+
+	var broadcasts []models.EmergencyBroadcast
+	if err := query.Order("created_at DESC").Limit(limit).Find(&broadcasts).Error; err != nil {
+		http.Error(w, "failed to list broadcasts", http.StatusInternalServerError)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusOK, map[string]interface{}{"broadcasts": broadcasts})
+}
+
+// GetBroadcastDashboard returns live acknowledgment figures and per-recipient status
+// (?status=pending|acknowledged filters the recipient list). Clients poll it while a
+// broadcast is active.
+// GET /api/v1/business/{businessCode}/emergency/broadcasts/{id}
+func (h *Handler) GetBroadcastDashboard(w http.ResponseWriter, r *http.Request) {
+	broadcast, ok := h.loadBusinessBroadcast(w, r)
+	if !ok {
+		return
+	}
+
+	var recipients []models.EmergencyBroadcastRecipient
+	if err := h.service.db.Where("broadcast_id = ?", broadcast.ID).
+		Order("acknowledged_at IS NOT NULL, escalation_level DESC, name").
+		Find(&recipients).Error; err != nil {
+		http.Error(w, "failed to load recipients", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	summary := Summarize(broadcast, recipients, now)
+
+	filtered := recipients
+	switch r.URL.Query().Get("status") {
+	case "pending":
+		filtered = filtered[:0:0]
+		for _, rec := range recipients {
+			if rec.AcknowledgedAt == nil {
+				filtered = append(filtered, rec)
+			}
+		}
+	case "acknowledged":
+		filtered = filtered[:0:0]
+		for _, rec := range recipients {
+			if rec.AcknowledgedAt != nil {
+				filtered = append(filtered, rec)
+			}
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	httputil.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"broadcast":    broadcast,
+		"summary":      summary,
+		"recipients":   filtered,
+		"generated_at": now,
+	})
+}
+
+// CloseBroadcast marks the incident as handled and stops further escalation
+// POST /api/v1/business/{businessCode}/emergency/broadcasts/{id}/close
+func (h *Handler) CloseBroadcast(w http.ResponseWriter, r *http.Request) {
+	userID, ok := httputil.ClaimsUserID(w, r)
+	if !ok {
+		return
+	}
+	broadcast, ok := h.loadBusinessBroadcast(w, r)
+	if !ok {
+		return
+	}
+	if broadcast.Status == models.EmergencyBroadcastClosed {
+		http.Error(w, "broadcast is already closed", http.StatusConflict)
+		return
+	}
+
+	if err := h.service.Close(broadcast, userID); err != nil {
+		http.Error(w, "failed to close broadcast", http.StatusInternalServerError)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusOK, map[string]interface{}{"broadcast": broadcast})
+}
+
+// RecordAcknowledgment lets an admin record that a recipient was reached another way
+// (phone, in person); body {"channel": "phone", "note": "..."}.
+// POST /api/v1/business/{businessCode}/emergency/broadcasts/{id}/recipients/{userId}/acknowledge
+func (h *Handler) RecordAcknowledgment(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := httputil.ClaimsUserID(w, r)
+	if !ok {
+		return
+	}
+	broadcast, ok := h.loadBusinessBroadcast(w, r)
+	if !ok {
+		return
+	}
+	userID, err := uuid.Parse(mux.Vars(r)["userId"])
+	if err != nil {
+		http.Error(w, "invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Channel string `json:"channel"`
+		Note    string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	channel := strings.ToLower(strings.TrimSpace(req.Channel))
+	if channel == "" {
+		channel = "phone"
+	}
+	if len(channel) > 20 {
+		http.Error(w, "channel is too long", http.StatusBadRequest)
+		return
+	}
+
+	recorded, err := h.service.Acknowledge(broadcast.ID, userID, channel, req.Note, &adminID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusOK, map[string]interface{}{"acknowledged": true, "recorded": recorded})
+}
+
+// ListMyPendingBroadcasts returns the active broadcasts the current user has not acknowledged
+// GET /api/v1/emergency/broadcasts/pending
+func (h *Handler) ListMyPendingBroadcasts(w http.ResponseWriter, r *http.Request) {
+	userID, ok := httputil.ClaimsUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var broadcasts []models.EmergencyBroadcast
+	if err := h.service.db.Preload("Site").
+		Joins("JOIN emergency_broadcast_recipients ebr ON ebr.broadcast_id = emergency_broadcasts.id").
+		Where("ebr.user_id = ? AND ebr.acknowledged_at IS NULL AND emergency_broadcasts.status = ?", userID, models.EmergencyBroadcastActive).
+		Order("emergency_broadcasts.created_at DESC").
+		Find(&broadcasts).Error; err != nil {
+		http.Error(w, "failed to load broadcasts", http.StatusInternalServerError)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusOK, map[string]interface{}{"broadcasts": broadcasts})
+}
+
+// AcknowledgeBroadcast records the current user's acknowledgment; body {"note": "..."} is optional
+// POST /api/v1/emergency/broadcasts/{id}/acknowledge
+func (h *Handler) AcknowledgeBroadcast(w http.ResponseWriter, r *http.Request) {
+	userID, ok := httputil.ClaimsUserID(w, r)
+	if !ok {
+		return
+	}
+	broadcastID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid broadcast ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Note string `json:"note"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	recorded, err := h.service.Acknowledge(broadcastID, userID, "app", req.Note, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	httputil.WriteJSON(w, http.StatusOK, map[string]interface{}{"acknowledged": true, "recorded": recorded})
+}
+
+// loadBusinessBroadcast loads the {id} broadcast of the current business, honouring site scope
+func (h *Handler) loadBusinessBroadcast(w http.ResponseWriter, r *http.Request) (*models.EmergencyBroadcast, bool) {
+	businessID := middleware.GetCurrentBusinessID(r)
+	if businessID == uuid.Nil {
+		http.Error(w, "invalid business identifier", http.StatusBadRequest)
+		return nil, false
+	}
+	broadcastID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid broadcast ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	var broadcast models.EmergencyBroadcast
+	if err := h.service.db.Preload("Site").
+		Where("id = ? AND business_vertical_id = ?", broadcastID, businessID).
+		First(&broadcast).Error; err != nil {
+		http.Error(w, "broadcast not found", http.StatusNotFound)
+		return nil, false
+	}
+	if !middleware.SiteInScope(r, &broadcast.SiteID) {
+		http.Error(w, "broadcast not found", http.StatusNotFound)
+		return nil, false
+	}
+	return &broadcast, true
+}
@@ -0,0 +1,741 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// loadEmployee loads the employee in the request within the business
+func loadEmployee(db *gorm.DB, r *http.Request, businessID uuid.UUID) (*models.Employee, error) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		return nil, apiError{status: http.StatusBadRequest, message: "invalid employee id"}
+	}
+	var employee models.Employee
+	if err := db.Where("business_vertical_id = ? AND deleted_at IS NULL", businessID).First(&employee, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError{status: http.StatusNotFound, message: "employee not found"}
+		}
+		return nil, err
+	}
+	return &employee, nil
+}
+
+// employeeChecklistTasks returns the business's checklist of the kind, or the default
+func employeeChecklistTasks(db *gorm.DB, businessID uuid.UUID, kind string) ([]string, error) {
+	var template models.EmployeeChecklistTemplate
+	err := db.Where("business_vertical_id = ? AND kind = ?", businessID, kind).First(&template).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if kind == models.ChecklistOffboarding {
+			return models.DefaultOffboardingChecklist, nil
+		}
+		return models.DefaultOnboardingChecklist, nil
+	}
+	return template.Items, err
+}
+
+// ==========================
+// Employee handlers
+// ==========================
+
+// employeeRequest is the body of employee create and update requests
+type employeeRequest struct {
+	UserID             *uuid.UUID               `json:"user_id"`
+	EmployeeCode       string                   `json:"employee_code"`
+	FullName           string                   `json:"full_name"`
+	Email              string                   `json:"email"`
+	Phone              string                   `json:"phone"`
+	Designation        string                   `json:"designation"`
+	Department         string                   `json:"department"`
+	EmploymentType     string                   `json:"employment_type"`
+	DateOfJoining      string                   `json:"date_of_joining"`
+	DateOfBirth        string                   `json:"date_of_birth"`
+	ReportingManagerID *uuid.UUID               `json:"reporting_manager_id"`
+	SiteID             *uuid.UUID               `json:"site_id"`
+	CostCenterID       *uuid.UUID               `json:"cost_center_id"`
+	ProjectID          *uuid.UUID               `json:"project_id"`
+	Address            string                   `json:"address"`
+	EmergencyContacts  models.EmergencyContacts `json:"emergency_contacts"`
+	PAN                string                   `json:"pan"`
+	UAN                string                   `json:"uan"`
+	BankAccountName    string                   `json:"bank_account_name"`
+	BankAccountNumber  string                   `json:"bank_account_number"`
+	BankIFSC           string                   `json:"bank_ifsc"`
+	BankName           string                   `json:"bank_name"`
+}
+
+// apply copies the request onto the employee and validates it, along with the user
+// account, reporting manager, site, cost center and project it links to. A linked account fills in the name,
+// email and phone when they are not given.
+func (req employeeRequest) apply(e *models.Employee) error {
+	joined, err := parseAttendanceDate(req.DateOfJoining)
+	if err != nil {
+		return apiError{status: http.StatusBadRequest, message: "date_of_joining: " + err.Error()}
+	}
+	var born *time.Time
+	if req.DateOfBirth != "" {
+		date, err := parseAttendanceDate(req.DateOfBirth)
+		if err != nil {
+			return apiError{status: http.StatusBadRequest, message: "date_of_birth: " + err.Error()}
+		}
+		born = &date
+	}
+	*e = models.Employee{
+		ID: e.ID, BusinessVerticalID: e.BusinessVerticalID, Status: e.Status, ExitDate: e.ExitDate, ExitReason: e.ExitReason,
+		CreatedBy: e.CreatedBy, UpdatedBy: e.UpdatedBy, CreatedAt: e.CreatedAt,
+		UserID: req.UserID, EmployeeCode: req.EmployeeCode, FullName: req.FullName, Email: req.Email, Phone: req.Phone,
+		Designation: req.Designation, Department: req.Department, EmploymentType: req.EmploymentType,
+		DateOfJoining: joined, DateOfBirth: born, ReportingManagerID: req.ReportingManagerID, SiteID: req.SiteID,
+		CostCenterID: req.CostCenterID, ProjectID: req.ProjectID,
+		Address: strings.TrimSpace(req.Address), EmergencyContacts: req.EmergencyContacts, PAN: req.PAN, UAN: req.UAN,
+		BankAccountName: strings.TrimSpace(req.BankAccountName), BankAccountNumber: req.BankAccountNumber,
+		BankIFSC: req.BankIFSC, BankName: strings.TrimSpace(req.BankName),
+	}
+
+	var count int64
+	if e.UserID != nil {
+		var user models.User
+		if err := config.DB.Select("id", "name", "email", "phone").First(&user, "id = ?", *e.UserID).Error; err != nil {
+			return apiError{status: http.StatusBadRequest, message: "user not found"}
+		}
+		config.DB.Model(&models.Employee{}).Where("business_vertical_id = ? AND user_id = ? AND id <> ? AND deleted_at IS NULL",
+			e.BusinessVerticalID, *e.UserID, e.ID).Count(&count)
+		if count > 0 {
+			return apiError{status: http.StatusConflict, message: "the user account is already linked to another employee"}
+		}
+		if strings.TrimSpace(e.FullName) == "" {
+			e.FullName = user.Name
+		}
+		if strings.TrimSpace(e.Email) == "" {
+			e.Email = user.Email
+		}
+		if strings.TrimSpace(e.Phone) == "" {
+			e.Phone = user.Phone
+		}
+	}
+	e.Normalize()
+	if err := e.Validate(); err != nil {
+		return apiError{status: http.StatusBadRequest, message: err.Error()}
+	}
+	config.DB.Model(&models.Employee{}).Where("business_vertical_id = ? AND employee_code = ? AND id <> ? AND deleted_at IS NULL",
+		e.BusinessVerticalID, e.EmployeeCode, e.ID).Count(&count)
+	if count > 0 {
+		return apiError{status: http.StatusConflict, message: "an employee with this code already exists"}
+	}
+	if e.ReportingManagerID != nil {
+		config.DB.Model(&models.Employee{}).Where("id = ? AND business_vertical_id = ? AND deleted_at IS NULL AND status <> ?",
+			*e.ReportingManagerID, e.BusinessVerticalID, models.EmployeeExited).Count(&count)
+		if count == 0 {
+			return apiError{status: http.StatusBadRequest, message: "reporting manager not found among the business's employees"}
+		}
+	}
+	if e.SiteID != nil {
+		config.DB.Model(&models.Site{}).Where("id = ? AND business_vertical_id = ?", *e.SiteID, e.BusinessVerticalID).Count(&count)
+		if count == 0 {
+			return apiError{status: http.StatusBadRequest, message: "site not found in this business"}
+		}
+	}
+	if err := checkCostCenter(config.DB, e.BusinessVerticalID, e.CostCenterID); err != nil {
+		return err
+	}
+	if e.ProjectID != nil {
+		config.DB.Model(&models.Project{}).Where("id = ? AND business_vertical_id = ? AND deleted_at IS NULL", *e.ProjectID, e.BusinessVerticalID).Count(&count)
+		if count == 0 {
+			return apiError{status: http.StatusBadRequest, message: "project not found in this business"}
+		}
+	}
+	return nil
+}
+
+// ListEmployees lists the business's employees by name. ?status=, ?department=,
+// ?designation=, ?site_id=, ?cost_center_id=, ?project_id=, ?reporting_manager_id= and
+// ?q= (name, code or email) narrow them. Bank account numbers are masked for those who cannot update employees.
+// GET /api/v1/business/{businessCode}/employees
+func ListEmployees(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load employees")
+		return
+	}
+
+	q := r.URL.Query()
+	query := middleware.ScopedDB(r).Model(&models.Employee{}).Where("business_vertical_id = ? AND deleted_at IS NULL", businessID)
+	for _, filter := range []string{"status", "department", "designation"} {
+		if v := q.Get(filter); v != "" {
+			query = query.Where(filter+" = ?", v)
+		}
+	}
+	for _, filter := range []string{"site_id", "cost_center_id", "project_id"} {
+		if id, ok := parseUUIDQuery(r, filter); ok {
+			query = query.Where(filter+" = ?", id)
+		}
+	}
+	if managerID, ok := parseUUIDQuery(r, "reporting_manager_id"); ok {
+		query = query.Where("reporting_manager_id = ?", managerID)
+	}
+	if v := strings.TrimSpace(q.Get("q")); v != "" {
+		like := "%" + strings.ToLower(v) + "%"
+		query = query.Where("LOWER(full_name) LIKE ? OR LOWER(employee_code) LIKE ? OR LOWER(email) LIKE ?", like, like, like)
+	}
+
+	page, limit := parsePagination(r)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		http.Error(w, "failed to count employees", http.StatusInternalServerError)
+		return
+	}
+	var items []models.Employee
+	if err := query.Order("full_name").Limit(limit).Offset((page - 1) * limit).Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch employees", http.StatusInternalServerError)
+		return
+	}
+	if !hasWorkflowPermission(middleware.GetEffectivePermissions(r), "hr:update") {
+		for i := range items {
+			items[i].MaskBankAccount()
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "total": total, "page": page, "limit": limit})
+}
+
+// CreateEmployee adds an employee, onboarding, with the business's onboarding checklist
+// POST /api/v1/business/{businessCode}/employees
+func CreateEmployee(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to create employee")
+		return
+	}
+
+	var req employeeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	userID := middleware.GetClaims(r).UserID
+	employee := models.Employee{ID: uuid.New(), BusinessVerticalID: businessID, Status: models.EmployeeOnboarding, CreatedBy: userID, UpdatedBy: userID}
+	if err := req.apply(&employee); err != nil {
+		writeProcurementErr(w, err, "failed to create employee")
+		return
+	}
+	tasks, err := employeeChecklistTasks(middleware.ScopedDB(r), businessID, models.ChecklistOnboarding)
+	if err != nil {
+		http.Error(w, "failed to load the onboarding checklist", http.StatusInternalServerError)
+		return
+	}
+	checklist := models.NewChecklist(employee.ID, models.ChecklistOnboarding, tasks)
+
+	if err := middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&employee).Error; err != nil {
+			return err
+		}
+		if len(checklist) == 0 {
+			return nil
+		}
+		return tx.Create(&checklist).Error
+	}); err != nil {
+		http.Error(w, "failed to create employee", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "employee created", "item": employee, "checklist": checklist})
+}
+
+// writeEmployee writes an employee with their documents and checklists
+func writeEmployee(w http.ResponseWriter, employee *models.Employee) {
+	var documents []models.EmployeeDocument
+	var checklist []models.EmployeeChecklistItem
+	err := config.DB.Where("employee_id = ?", employee.ID).Order("created_at").Find(&documents).Error
+	if err == nil {
+		err = config.DB.Where("employee_id = ?", employee.ID).Order("kind DESC, position").Find(&checklist).Error
+	}
+	if err != nil {
+		http.Error(w, "failed to fetch the employee's file", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"item":                    employee,
+		"documents":               documents,
+		"checklist":               checklist,
+		"onboarding_outstanding":  models.ChecklistOutstanding(checklist, models.ChecklistOnboarding),
+		"offboarding_outstanding": models.ChecklistOutstanding(checklist, models.ChecklistOffboarding),
+	})
+}
+
+// GetEmployee returns an employee with their documents and checklists
+// GET /api/v1/business/{businessCode}/employees/{id}
+func GetEmployee(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load employee")
+		return
+	}
+	employee, err := loadEmployee(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load employee")
+		return
+	}
+	if !hasWorkflowPermission(middleware.GetEffectivePermissions(r), "hr:update") {
+		employee.MaskBankAccount()
+	}
+
+	writeEmployee(w, employee)
+}
+
+// GetMyEmployeeProfile returns the signed-in user's own employee record in the business
+// GET /api/v1/business/{businessCode}/my-employee-profile
+func GetMyEmployeeProfile(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load employee profile")
+		return
+	}
+	var employee models.Employee
+	if err := middleware.ScopedDB(r).Where("business_vertical_id = ? AND user_id = ? AND deleted_at IS NULL", businessID, middleware.GetClaims(r).UserID).
+		First(&employee).Error; err != nil {
+		http.Error(w, "no employee record is linked to your account in this business", http.StatusNotFound)
+		return
+	}
+
+	writeEmployee(w, &employee)
+}
+
+// UpdateEmployee changes an employee's profile. Their status moves only through the
+// lifecycle actions.
+// PUT /api/v1/business/{businessCode}/employees/{id}
+func UpdateEmployee(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update employee")
+		return
+	}
+	employee, err := loadEmployee(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load employee")
+		return
+	}
+
+	var req employeeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := req.apply(employee); err != nil {
+		writeProcurementErr(w, err, "failed to update employee")
+		return
+	}
+	employee.UpdatedBy = middleware.GetClaims(r).UserID
+	if err := middleware.ScopedDB(r).Model(employee).Select("*").Omit("id", "business_vertical_id", "status", "exit_date", "exit_reason",
+		"created_by", "created_at", "deleted_at").Updates(employee).Error; err != nil {
+		http.Error(w, "failed to update employee", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "employee updated", "item": employee})
+}
+
+// DeleteEmployee removes an employee entered by mistake. Only an employee still being
+// onboarded can be removed; others are offboarded.
+// DELETE /api/v1/business/{businessCode}/employees/{id}
+func DeleteEmployee(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to delete employee")
+		return
+	}
+	employee, err := loadEmployee(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load employee")
+		return
+	}
+	if employee.Status != models.EmployeeOnboarding {
+		http.Error(w, "only an employee being onboarded can be deleted; offboard them instead", http.StatusConflict)
+		return
+	}
+
+	if err := middleware.ScopedDB(r).Model(employee).Updates(map[string]interface{}{
+		"deleted_at": time.Now(), "updated_by": middleware.GetClaims(r).UserID,
+	}).Error; err != nil {
+		http.Error(w, "failed to delete employee", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "employee deleted"})
+}
+
+// ==========================
+// Lifecycle handlers
+// ==========================
+
+// moveEmployee changes the employee's status from the one expected, after effect runs in
+// the same transaction
+func moveEmployee(r *http.Request, employee *models.Employee, from, to string, fields map[string]interface{}, effect func(tx *gorm.DB) error) error {
+	if employee.Status != from {
+		return apiError{status: http.StatusConflict, message: "the employee is " + employee.Status + ", not " + from}
+	}
+	if fields == nil {
+		fields = map[string]interface{}{}
+	}
+	fields["status"] = to
+	fields["updated_by"] = middleware.GetClaims(r).UserID
+	return middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Employee{}).Where("id = ? AND status = ?", employee.ID, from).Updates(fields)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return apiError{status: http.StatusConflict, message: "the employee changed status; reload and try again"}
+		}
+		if effect != nil {
+			return effect(tx)
+		}
+		return nil
+	})
+}
+
+// checklistComplete refuses the move if tasks of the kind are outstanding
+func checklistComplete(tx *gorm.DB, employeeID uuid.UUID, kind string) error {
+	var items []models.EmployeeChecklistItem
+	if err := tx.Where("employee_id = ? AND kind = ?", employeeID, kind).Find(&items).Error; err != nil {
+		return err
+	}
+	if outstanding := models.ChecklistOutstanding(items, kind); len(outstanding) > 0 {
+		return apiError{status: http.StatusUnprocessableEntity, message: "the " + kind + " checklist is incomplete: " + strings.Join(outstanding, "; ")}
+	}
+	return nil
+}
+
+// ActivateEmployee completes an employee's onboarding once its checklist is done
+// POST /api/v1/business/{businessCode}/employees/{id}/activate
+func ActivateEmployee(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to activate employee")
+		return
+	}
+	employee, err := loadEmployee(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load employee")
+		return
+	}
+
+	err = moveEmployee(r, employee, models.EmployeeOnboarding, models.EmployeeActive, nil, func(tx *gorm.DB) error {
+		return checklistComplete(tx, employee.ID, models.ChecklistOnboarding)
+	})
+	if err != nil {
+		writeProcurementErr(w, err, "failed to activate employee")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "employee activated"})
+}
+
+// OffboardEmployee starts an active employee's exit on a date, with the business's
+// offboarding checklist
+// POST /api/v1/business/{businessCode}/employees/{id}/offboard
+func OffboardEmployee(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to offboard employee")
+		return
+	}
+	employee, err := loadEmployee(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load employee")
+		return
+	}
+
+	var req struct {
+		ExitDate   string `json:"exit_date"`
+		ExitReason string `json:"exit_reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	exitDate, err := parseAttendanceDate(req.ExitDate)
+	if err != nil || exitDate.Before(employee.DateOfJoining) {
+		http.Error(w, "exit_date must be a date not before the date of joining", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.ExitReason) == "" {
+		http.Error(w, "exit_reason is required", http.StatusBadRequest)
+		return
+	}
+	tasks, err := employeeChecklistTasks(middleware.ScopedDB(r), businessID, models.ChecklistOffboarding)
+	if err != nil {
+		http.Error(w, "failed to load the offboarding checklist", http.StatusInternalServerError)
+		return
+	}
+	checklist := models.NewChecklist(employee.ID, models.ChecklistOffboarding, tasks)
+
+	err = moveEmployee(r, employee, models.EmployeeActive, models.EmployeeOffboarding,
+		map[string]interface{}{"exit_date": exitDate, "exit_reason": strings.TrimSpace(req.ExitReason)},
+		func(tx *gorm.DB) error {
+			if err := tx.Where("employee_id = ? AND kind = ?", employee.ID, models.ChecklistOffboarding).
+				Delete(&models.EmployeeChecklistItem{}).Error; err != nil {
+				return err
+			}
+			if len(checklist) == 0 {
+				return nil
+			}
+			return tx.Create(&checklist).Error
+		})
+	if err != nil {
+		writeProcurementErr(w, err, "failed to offboard employee")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "employee offboarding", "checklist": checklist})
+}
+
+// ExitEmployee completes an employee's exit once the offboarding checklist is done. Their
+// user account loses its roles in the business and their salary structures stop, so
+// later payroll runs leave them out.
+// POST /api/v1/business/{businessCode}/employees/{id}/exit
+func ExitEmployee(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to exit employee")
+		return
+	}
+	employee, err := loadEmployee(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load employee")
+		return
+	}
+
+	err = moveEmployee(r, employee, models.EmployeeOffboarding, models.EmployeeExited, nil, func(tx *gorm.DB) error {
+		if err := checklistComplete(tx, employee.ID, models.ChecklistOffboarding); err != nil {
+			return err
+		}
+		if employee.UserID == nil {
+			return nil
+		}
+		if err := tx.Model(&models.UserBusinessRole{}).
+			Where("user_id = ? AND business_role_id IN (?)", *employee.UserID,
+				tx.Model(&models.BusinessRole{}).Select("id").Where("business_vertical_id = ?", businessID)).
+			Update("is_active", false).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.SalaryStructure{}).Where("business_vertical_id = ? AND user_id = ?", businessID, employee.UserID.String()).
+			Update("is_active", false).Error
+	})
+	if err != nil {
+		writeProcurementErr(w, err, "failed to exit employee")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "employee exited"})
+}
+
+// UpdateEmployeeChecklistItem ticks off an onboarding or offboarding task, or reopens it
+// PUT /api/v1/business/{businessCode}/employees/{id}/checklist/{itemId}
+func UpdateEmployeeChecklistItem(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update checklist")
+		return
+	}
+	employee, err := loadEmployee(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load employee")
+		return
+	}
+	var item models.EmployeeChecklistItem
+	if err := middleware.ScopedDB(r).Where("employee_id = ?", employee.ID).First(&item, "id = ?", mux.Vars(r)["itemId"]).Error; err != nil {
+		http.Error(w, "checklist item not found", http.StatusNotFound)
+		return
+	}
+	if employee.Status == models.EmployeeExited {
+		http.Error(w, "the employee has exited", http.StatusConflict)
+		return
+	}
+
+	var req struct {
+		Done    bool   `json:"done"`
+		Remarks string `json:"remarks"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	item.Done = req.Done
+	item.Remarks = strings.TrimSpace(req.Remarks)
+	item.DoneBy, item.DoneAt = "", nil
+	if req.Done {
+		now := time.Now()
+		item.DoneBy, item.DoneAt = middleware.GetClaims(r).UserID, &now
+	}
+	if err := middleware.ScopedDB(r).Model(&item).Select("done", "done_by", "done_at", "remarks").Updates(&item).Error; err != nil {
+		http.Error(w, "failed to update checklist", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "checklist updated", "item": item})
+}
+
+// ==========================
+// Employee document handlers
+// ==========================
+
+// AddEmployeeDocument files a DMS document of the business on an employee
+// POST /api/v1/business/{businessCode}/employees/{id}/documents
+func AddEmployeeDocument(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to add document")
+		return
+	}
+	employee, err := loadEmployee(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load employee")
+		return
+	}
+
+	var req struct {
+		DocumentType string    `json:"document_type"`
+		DocumentID   uuid.UUID `json:"document_id"`
+		ExpiresOn    string    `json:"expires_on"`
+		Remarks      string    `json:"remarks"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	doc := models.EmployeeDocument{
+		EmployeeID:   employee.ID,
+		DocumentType: strings.ToLower(strings.TrimSpace(req.DocumentType)),
+		DocumentID:   req.DocumentID,
+		Remarks:      strings.TrimSpace(req.Remarks),
+		AddedBy:      middleware.GetClaims(r).UserID,
+	}
+	if doc.DocumentType == "" {
+		http.Error(w, "document_type is required", http.StatusBadRequest)
+		return
+	}
+	if req.ExpiresOn != "" {
+		date, err := parseAttendanceDate(req.ExpiresOn)
+		if err != nil {
+			http.Error(w, "expires_on: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		doc.ExpiresOn = &date
+	}
+	var count int64
+	middleware.ScopedDB(r).Model(&models.Document{}).Where("id = ? AND business_vertical_id = ?", doc.DocumentID, businessID).Count(&count)
+	if count == 0 {
+		http.Error(w, "document not found in this business", http.StatusBadRequest)
+		return
+	}
+	if err := middleware.ScopedDB(r).Create(&doc).Error; err != nil {
+		http.Error(w, "failed to add document", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "document added", "item": doc})
+}
+
+// RemoveEmployeeDocument takes a document off an employee's file. The DMS document stays.
+// DELETE /api/v1/business/{businessCode}/employees/{id}/documents/{documentId}
+func RemoveEmployeeDocument(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to remove document")
+		return
+	}
+	employee, err := loadEmployee(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load employee")
+		return
+	}
+
+	result := middleware.ScopedDB(r).Where("employee_id = ? AND id = ?", employee.ID, mux.Vars(r)["documentId"]).Delete(&models.EmployeeDocument{})
+	if result.Error != nil {
+		http.Error(w, "failed to remove document", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "document not found on the employee's file", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "document removed"})
+}
+
+// ==========================
+// Checklist template handlers
+// ==========================
+
+// GetEmployeeChecklistTemplate returns the business's onboarding or offboarding checklist
+// GET /api/v1/business/{businessCode}/employee-checklists/{kind}
+func GetEmployeeChecklistTemplate(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load checklist")
+		return
+	}
+	kind := mux.Vars(r)["kind"]
+	if kind != models.ChecklistOnboarding && kind != models.ChecklistOffboarding {
+		http.Error(w, "kind must be onboarding or offboarding", http.StatusBadRequest)
+		return
+	}
+	tasks, err := employeeChecklistTasks(middleware.ScopedDB(r), businessID, kind)
+	if err != nil {
+		http.Error(w, "failed to load checklist", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"kind": kind, "items": tasks})
+}
+
+// UpdateEmployeeChecklistTemplate sets the business's onboarding or offboarding
+// checklist. Employees already onboarding or offboarding keep the checklist they started.
+// PUT /api/v1/business/{businessCode}/employee-checklists/{kind}
+func UpdateEmployeeChecklistTemplate(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update checklist")
+		return
+	}
+	kind := mux.Vars(r)["kind"]
+	if kind != models.ChecklistOnboarding && kind != models.ChecklistOffboarding {
+		http.Error(w, "kind must be onboarding or offboarding", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Items []string `json:"items"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	items := models.StringArray{}
+	for _, task := range req.Items {
+		if task = strings.TrimSpace(task); task != "" {
+			items = append(items, task)
+		}
+	}
+
+	template := models.EmployeeChecklistTemplate{BusinessVerticalID: businessID, Kind: kind}
+	if err := middleware.ScopedDB(r).Where(template).Assign(models.EmployeeChecklistTemplate{
+		Items: items, UpdatedBy: middleware.GetClaims(r).UserID,
+	}).FirstOrCreate(&template).Error; err != nil {
+		http.Error(w, "failed to update checklist", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "checklist updated", "item": template})
+}
@@ -0,0 +1,592 @@
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+const (
+	// maxEvidenceBundleDays bounds a single bundle; auditors export a year at a time.
+	maxEvidenceBundleDays = 366
+	// defaultEvidenceBundleMaxBytes caps attached files per bundle (override with EVIDENCE_BUNDLE_MAX_BYTES).
+	defaultEvidenceBundleMaxBytes int64 = 2 << 30
+)
+
+// Evidence bundle scopes
+const (
+	EvidenceScopeFinance   = "finance"
+	EvidenceScopePurchases = "purchases"
+	EvidenceScopeWorkflows = "workflows"
+	EvidenceScopeAudit     = "audit"
+	EvidenceScopeDocuments = "documents"
+)
+
+var evidenceScopes = []string{
+	EvidenceScopeFinance, EvidenceScopePurchases, EvidenceScopeWorkflows, EvidenceScopeAudit, EvidenceScopeDocuments,
+}
+
+// evidenceSource is one table exported into an evidence bundle. Records are selected by
+// TimeColumn within the requested range; VerticalFilter (one "?" placeholder, rows aliased
+// as t) scopes them to a business vertical. Sources without a VerticalFilter are global
+// and only exported when no vertical is requested.
+type evidenceSource struct {
+	Name           string
+	Scope          string
+	Table          string
+	TimeColumn     string
+	VerticalFilter string
+	// FileColumns are jsonb arrays of stored file paths attached to each record.
+	FileColumns []string
+	// DocumentColumn / TaskColumn link records to documents that are attached to the bundle.
+	DocumentColumn string
+	TaskColumn     string
+}
+
+var evidenceSources = []evidenceSource{
+	{Name: "bank_guarantees", Scope: EvidenceScopeFinance, Table: "bank_guarantees", TimeColumn: "created_at", VerticalFilter: "t.business_vertical_id = ?"},
+	{Name: "letters_of_credit", Scope: EvidenceScopeFinance, Table: "letters_of_credit", TimeColumn: "created_at", VerticalFilter: "t.business_vertical_id = ?"},
+	{Name: "insurance_policies", Scope: EvidenceScopeFinance, Table: "insurance_policies", TimeColumn: "created_at", VerticalFilter: "t.business_vertical_id = ?"},
+	{Name: "insurance_claims", Scope: EvidenceScopeFinance, Table: "insurance_claims", TimeColumn: "created_at", VerticalFilter: "t.business_vertical_id = ?"},
+	{Name: "finance_approval_requests", Scope: EvidenceScopeFinance, Table: "finance_approval_requests", TimeColumn: "created_at", VerticalFilter: "t.business_vertical_id = ?"},
+	{Name: "finance_approvals", Scope: EvidenceScopeFinance, Table: "finance_approvals", TimeColumn: "created_at",
+		VerticalFilter: "t.request_id IN (SELECT id FROM finance_approval_requests WHERE business_vertical_id = ?)"},
+	{Name: "payments", Scope: EvidenceScopeFinance, Table: "payments", TimeColumn: "created_at", VerticalFilter: "t.business_vertical_id = ?",
+		FileColumns: []string{"quotation_files", "kyv_files"}},
+
+	{Name: "materials", Scope: EvidenceScopePurchases, Table: "materials", TimeColumn: "created_at", VerticalFilter: "t.business_vertical_id = ?",
+		FileColumns: []string{"quotation_photos"}},
+
+	{Name: "form_submissions", Scope: EvidenceScopeWorkflows, Table: "form_submissions", TimeColumn: "created_at", VerticalFilter: "t.business_vertical_id = ?"},
+	{Name: "workflow_transitions", Scope: EvidenceScopeWorkflows, Table: "workflow_transitions", TimeColumn: "transitioned_at",
+		VerticalFilter: "t.submission_id IN (SELECT id FROM form_submissions WHERE business_vertical_id = ?)"},
+
+	{Name: "task_audit_logs", Scope: EvidenceScopeAudit, Table: "task_audit_logs", TimeColumn: "performed_at",
+		VerticalFilter: "t.task_id IN (SELECT tk.id FROM tasks tk JOIN projects p ON p.id = tk.project_id WHERE p.business_vertical_id = ?)",
+		TaskColumn:     "task_id"},
+	{Name: "document_audit_logs", Scope: EvidenceScopeAudit, Table: "document_audit_logs", TimeColumn: "created_at",
+		VerticalFilter: "t.document_id IN (SELECT id FROM documents WHERE business_vertical_id = ?)",
+		DocumentColumn: "document_id"},
+	{Name: "policy_evaluations", Scope: EvidenceScopeAudit, Table: "policy_evaluations", TimeColumn: "evaluation_time", VerticalFilter: "t.business_vertical_id = ?"},
+	{Name: "policy_change_logs", Scope: EvidenceScopeAudit, Table: "policy_change_logs", TimeColumn: "created_at"},
+	{Name: "user_login_events", Scope: EvidenceScopeAudit, Table: "user_login_events", TimeColumn: "login_at"},
+	{Name: "role_assignment_audit_logs", Scope: EvidenceScopeAudit, Table: "role_assignment_audit_logs", TimeColumn: "performed_at", VerticalFilter: "t.business_vertical_id = ?"},
+
+	{Name: "documents", Scope: EvidenceScopeDocuments, Table: "documents", TimeColumn: "created_at", VerticalFilter: "t.business_vertical_id = ?",
+		DocumentColumn: "id"},
+}
+
+// EvidenceBundleHandler exports scoped, tamper-evident evidence bundles for external auditors.
+type EvidenceBundleHandler struct {
+	db *gorm.DB
+}
+
+func NewEvidenceBundleHandler() *EvidenceBundleHandler {
+	return &EvidenceBundleHandler{db: config.DB}
+}
+
+type evidenceRequest struct {
+	From               time.Time
+	To                 time.Time
+	BusinessVerticalID *uuid.UUID
+	Scopes             []string
+	IncludeDocuments   bool
+}
+
+type evidenceManifestSource struct {
+	Name    string `json:"name"`
+	Scope   string `json:"scope"`
+	File    string `json:"file"`
+	Records int    `json:"records"`
+	SHA256  string `json:"sha256"`
+}
+
+type evidenceManifestFile struct {
+	Path       string     `json:"path"`
+	Source     string     `json:"source"`
+	Reference  string     `json:"reference"`
+	DocumentID *uuid.UUID `json:"document_id,omitempty"`
+	Size       int64      `json:"size"`
+	SHA256     string     `json:"sha256"`
+}
+
+type evidenceManifest struct {
+	GeneratedAt        time.Time                `json:"generated_at"`
+	RequestedBy        string                   `json:"requested_by"`
+	From               time.Time                `json:"from"`
+	To                 time.Time                `json:"to"`
+	BusinessVerticalID *uuid.UUID               `json:"business_vertical_id,omitempty"`
+	Scopes             []string                 `json:"scopes"`
+	Sources            []evidenceManifestSource `json:"sources"`
+	Files              []evidenceManifestFile   `json:"files"`
+	Missing            []string                 `json:"missing_files,omitempty"`
+	SkippedOverLimit   []string                 `json:"skipped_over_size_limit,omitempty"`
+}
+
+// PreviewEvidenceBundle returns how many records each source would contribute.
+// Query: from, to (RFC3339 or YYYY-MM-DD), scopes (comma separated), business_vertical_id.
+func (h *EvidenceBundleHandler) PreviewEvidenceBundle(w http.ResponseWriter, r *http.Request) {
+	req, status, err := h.parseEvidenceRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	counts := make(map[string]int64)
+	var total int64
+	for _, source := range selectedEvidenceSources(req) {
+		var count int64
+		if err := h.sourceQuery(source, req).Count(&count).Error; err != nil {
+			http.Error(w, "failed to count "+source.Name, http.StatusInternalServerError)
+			return
+		}
+		counts[source.Name] = count
+		total += count
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"from":                 req.From,
+		"to":                   req.To,
+		"business_vertical_id": req.BusinessVerticalID,
+		"scopes":               req.Scopes,
+		"records":              counts,
+		"total_records":        total,
+	})
+}
+
+// ExportEvidenceBundle builds a ZIP containing records/<source>.jsonl for every selected
+// source, the files linked to those records under files/, and a manifest.json with a
+// SHA-256 digest of every entry. Accepts the same query as PreviewEvidenceBundle plus
+// include_documents=false to export records only.
+func (h *EvidenceBundleHandler) ExportEvidenceBundle(w http.ResponseWriter, r *http.Request) {
+	req, status, err := h.parseEvidenceRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	requestedBy := ""
+	if claims := middleware.GetClaims(r); claims != nil {
+		requestedBy = claims.UserID
+	}
+
+	zipFileName := fmt.Sprintf("evidence-%s-%s-%s.zip", req.From.Format("20060102"), req.To.Format("20060102"), uuid.New().String()[:8])
+	zipFilePath := filepath.Join("./uploads/temp", zipFileName)
+	os.MkdirAll("./uploads/temp", 0755)
+
+	zipFile, err := os.Create(zipFilePath)
+	if err != nil {
+		http.Error(w, "failed to create evidence bundle", http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(zipFilePath)
+	defer zipFile.Close()
+
+	manifest := evidenceManifest{
+		GeneratedAt:        time.Now().UTC(),
+		RequestedBy:        requestedBy,
+		From:               req.From,
+		To:                 req.To,
+		BusinessVerticalID: req.BusinessVerticalID,
+		Scopes:             req.Scopes,
+		Sources:            make([]evidenceManifestSource, 0),
+		Files:              make([]evidenceManifestFile, 0),
+	}
+
+	zipWriter := zip.NewWriter(zipFile)
+	links := newEvidenceLinks()
+	for _, source := range selectedEvidenceSources(req) {
+		entry, err := h.writeSourceRecords(zipWriter, source, req, links)
+		if err != nil {
+			zipWriter.Close()
+			http.Error(w, fmt.Sprintf("failed to export %s", source.Name), http.StatusInternalServerError)
+			return
+		}
+		manifest.Sources = append(manifest.Sources, entry)
+	}
+
+	if req.IncludeDocuments {
+		h.writeLinkedFiles(r, zipWriter, links, &manifest)
+	}
+
+	manifestWriter, err := zipWriter.Create("manifest.json")
+	if err == nil {
+		encoder := json.NewEncoder(manifestWriter)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(manifest)
+	}
+	if err == nil {
+		err = zipWriter.Close()
+	}
+	if err != nil {
+		http.Error(w, "failed to finalize evidence bundle", http.StatusInternalServerError)
+		return
+	}
+	zipFile.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", zipFileName))
+	http.ServeFile(w, r, zipFilePath)
+}
+
+func (h *EvidenceBundleHandler) parseEvidenceRequest(r *http.Request) (evidenceRequest, int, error) {
+	q := r.URL.Query()
+	req := evidenceRequest{IncludeDocuments: q.Get("include_documents") != "false"}
+
+	rawFrom, rawTo := strings.TrimSpace(q.Get("from")), strings.TrimSpace(q.Get("to"))
+	if rawFrom == "" || rawTo == "" {
+		return req, http.StatusBadRequest, errors.New("from and to are required")
+	}
+	from, err := parseEvaluationTime(rawFrom)
+	if err != nil {
+		return req, http.StatusBadRequest, errors.New("invalid from: use RFC3339 or YYYY-MM-DD")
+	}
+	to, err := parseEvaluationTime(rawTo)
+	if err != nil {
+		return req, http.StatusBadRequest, errors.New("invalid to: use RFC3339 or YYYY-MM-DD")
+	}
+	if len(rawTo) == len("2006-01-02") {
+		to = to.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	if !to.After(from) {
+		return req, http.StatusBadRequest, errors.New("to must be after from")
+	}
+	if to.Sub(from) > maxEvidenceBundleDays*24*time.Hour {
+		return req, http.StatusBadRequest, fmt.Errorf("date range may not exceed %d days", maxEvidenceBundleDays)
+	}
+	req.From, req.To = from, to
+
+	if raw := strings.TrimSpace(q.Get("scopes")); raw != "" {
+		for _, scope := range strings.Split(raw, ",") {
+			scope = strings.ToLower(strings.TrimSpace(scope))
+			if !slices.Contains(evidenceScopes, scope) {
+				return req, http.StatusBadRequest, fmt.Errorf("unknown scope %q (valid: %s)", scope, strings.Join(evidenceScopes, ", "))
+			}
+			if !slices.Contains(req.Scopes, scope) {
+				req.Scopes = append(req.Scopes, scope)
+			}
+		}
+	} else {
+		req.Scopes = append([]string(nil), evidenceScopes...)
+	}
+
+	if raw := strings.TrimSpace(q.Get("business_vertical_id")); raw != "" {
+		verticalID, err := uuid.Parse(raw)
+		if err != nil {
+			return req, http.StatusBadRequest, errors.New("invalid business_vertical_id")
+		}
+		req.BusinessVerticalID = &verticalID
+	}
+	if businessContext := middleware.GetUserBusinessContext(r); businessContext != nil {
+		if businessID, ok := businessContext["business_id"].(uuid.UUID); ok && businessID != uuid.Nil {
+			if req.BusinessVerticalID != nil && *req.BusinessVerticalID != businessID {
+				return req, http.StatusForbidden, errors.New("access denied to requested business vertical")
+			}
+			req.BusinessVerticalID = &businessID
+		}
+	}
+
+	return req, http.StatusOK, nil
+}
+
+func selectedEvidenceSources(req evidenceRequest) []evidenceSource {
+	selected := make([]evidenceSource, 0, len(evidenceSources))
+	for _, source := range evidenceSources {
+		if !slices.Contains(req.Scopes, source.Scope) {
+			continue
+		}
+		if req.BusinessVerticalID != nil && source.VerticalFilter == "" {
+			continue
+		}
+		selected = append(selected, source)
+	}
+	return selected
+}
+
+func (h *EvidenceBundleHandler) sourceQuery(source evidenceSource, req evidenceRequest) *gorm.DB {
+	query := h.db.Table(source.Table+" t").
+		Where("t."+source.TimeColumn+" >= ? AND t."+source.TimeColumn+" <= ?", req.From, req.To)
+	if req.BusinessVerticalID != nil {
+		query = query.Where(source.VerticalFilter, *req.BusinessVerticalID)
+	}
+	return query
+}
+
+// writeSourceRecords streams one JSON object per line and collects the files and
+// documents the records point at.
+func (h *EvidenceBundleHandler) writeSourceRecords(zipWriter *zip.Writer, source evidenceSource, req evidenceRequest, links *evidenceLinks) (evidenceManifestSource, error) {
+	entry := evidenceManifestSource{Name: source.Name, Scope: source.Scope, File: "records/" + source.Name + ".jsonl"}
+
+	rows, err := h.sourceQuery(source, req).Select("row_to_json(t)").Order("t." + source.TimeColumn).Rows()
+	if err != nil {
+		return entry, err
+	}
+	defer rows.Close()
+
+	writer, err := zipWriter.Create(entry.File)
+	if err != nil {
+		return entry, err
+	}
+	hasher := sha256.New()
+	out := io.MultiWriter(writer, hasher)
+
+	for rows.Next() {
+		var record []byte
+		if err := rows.Scan(&record); err != nil {
+			return entry, err
+		}
+		if _, err := out.Write(append(record, '\n')); err != nil {
+			return entry, err
+		}
+		entry.Records++
+		links.collect(source, record)
+	}
+	if err := rows.Err(); err != nil {
+		return entry, err
+	}
+
+	entry.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+	return entry, nil
+}
+
+// writeLinkedFiles adds documents and record attachments to the bundle until the size cap
+// is reached. Document downloads are recorded in the document audit log.
+func (h *EvidenceBundleHandler) writeLinkedFiles(r *http.Request, zipWriter *zip.Writer, links *evidenceLinks, manifest *evidenceManifest) {
+	maxBytes := defaultEvidenceBundleMaxBytes
+	if raw := os.Getenv("EVIDENCE_BUNDLE_MAX_BYTES"); raw != "" {
+		if parsed, err := strconv.ParseInt(raw, 10, 64); err == nil && parsed > 0 {
+			maxBytes = parsed
+		}
+	}
+	var written int64
+
+	var userID *uuid.UUID
+	if claims := middleware.GetClaims(r); claims != nil {
+		if id, err := uuid.Parse(claims.UserID); err == nil {
+			userID = &id
+		}
+	}
+
+	var documents []models.Document
+	if len(links.documentIDs) > 0 || len(links.taskIDs) > 0 {
+		query := middleware.WithDataScope(r, h.db).Model(&models.Document{})
+		switch {
+		case len(links.documentIDs) > 0 && len(links.taskIDs) > 0:
+			query = query.Where("id IN ? OR task_id IN ?", links.documentIDList(), links.taskIDList())
+		case len(links.documentIDs) > 0:
+			query = query.Where("id IN ?", links.documentIDList())
+		default:
+			query = query.Where("task_id IN ?", links.taskIDList())
+		}
+		if err := query.Order("created_at").Find(&documents).Error; err != nil {
+			manifest.Missing = append(manifest.Missing, "documents: "+err.Error())
+		}
+	}
+
+	for _, doc := range documents {
+		docID := doc.ID
+		entryPath := path.Join("files", "documents", doc.ID.String(), sanitizeEvidenceName(doc.FileName))
+		size, digest, err := copyEvidenceFile(r.Context(), zipWriter, doc.FilePath, entryPath, maxBytes-written)
+		if err != nil {
+			h.noteEvidenceFileError(manifest, err, "document "+doc.ID.String())
+			continue
+		}
+		written += size
+		manifest.Files = append(manifest.Files, evidenceManifestFile{
+			Path: entryPath, Source: "documents", Reference: doc.FilePath, DocumentID: &docID, Size: size, SHA256: digest,
+		})
+		middleware.WithDataScope(r, h.db).Create(&models.DocumentAuditLog{
+			DocumentID: doc.ID,
+			UserID:     userID,
+			Action:     models.DocumentAuditActionDownload,
+			Details:    models.DocumentMetadata{"evidence_bundle": true},
+			IPAddress:  r.RemoteAddr,
+			UserAgent:  r.UserAgent(),
+		})
+	}
+
+	for i, attachment := range links.files {
+		entryPath := path.Join("files", attachment.source, fmt.Sprintf("%04d_%s", i+1, sanitizeEvidenceName(path.Base(attachment.reference))))
+		size, digest, err := copyEvidenceFile(r.Context(), zipWriter, attachment.reference, entryPath, maxBytes-written)
+		if err != nil {
+			h.noteEvidenceFileError(manifest, err, attachment.reference)
+			continue
+		}
+		written += size
+		manifest.Files = append(manifest.Files, evidenceManifestFile{
+			Path: entryPath, Source: attachment.source, Reference: attachment.reference, Size: size, SHA256: digest,
+		})
+	}
+}
+
+func (h *EvidenceBundleHandler) noteEvidenceFileError(manifest *evidenceManifest, err error, reference string) {
+	if errors.Is(err, errEvidenceSizeLimit) {
+		manifest.SkippedOverLimit = append(manifest.SkippedOverLimit, reference)
+		return
+	}
+	manifest.Missing = append(manifest.Missing, reference)
+}
+
+var errEvidenceSizeLimit = errors.New("evidence bundle size limit reached")
+
+// copyEvidenceFile copies a stored file into the bundle and returns its size and SHA-256.
+// Paths recorded as URLs or with a leading slash are retried in their normalized form.
+func copyEvidenceFile(ctx context.Context, zipWriter *zip.Writer, storagePath, entryPath string, remaining int64) (int64, string, error) {
+	reader, size, err := openStoredFileReader(ctx, storagePath)
+	if errors.Is(err, errStoredFileNotFound) {
+		if normalized := normalizeStoredObjectPath(storagePath); normalized != storagePath {
+			reader, size, err = openStoredFileReader(ctx, normalized)
+		}
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	defer reader.Close()
+
+	if size > remaining {
+		return 0, "", errEvidenceSizeLimit
+	}
+
+	writer, err := zipWriter.Create(entryPath)
+	if err != nil {
+		return 0, "", err
+	}
+	hasher := sha256.New()
+	copied, err := io.Copy(io.MultiWriter(writer, hasher), reader)
+	if err != nil {
+		return copied, "", err
+	}
+	return copied, hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+func sanitizeEvidenceName(name string) string {
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+	if name == "" || name == "." || name == "/" {
+		return "file"
+	}
+	return name
+}
+
+type evidenceAttachment struct {
+	source    string
+	reference string
+}
+
+// evidenceLinks accumulates the documents, tasks and stored files referenced by exported records.
+type evidenceLinks struct {
+	documentIDs map[uuid.UUID]struct{}
+	taskIDs     map[uuid.UUID]struct{}
+	seenFiles   map[string]struct{}
+	files       []evidenceAttachment
+}
+
+func newEvidenceLinks() *evidenceLinks {
+	return &evidenceLinks{
+		documentIDs: make(map[uuid.UUID]struct{}),
+		taskIDs:     make(map[uuid.UUID]struct{}),
+		seenFiles:   make(map[string]struct{}),
+	}
+}
+
+func (l *evidenceLinks) collect(source evidenceSource, record []byte) {
+	if source.DocumentColumn == "" && source.TaskColumn == "" && len(source.FileColumns) == 0 {
+		return
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(record, &fields); err != nil {
+		return
+	}
+
+	parseID := func(column string) (uuid.UUID, bool) {
+		var raw string
+		if column == "" || json.Unmarshal(fields[column], &raw) != nil {
+			return uuid.Nil, false
+		}
+		id, err := uuid.Parse(raw)
+		return id, err == nil
+	}
+	if id, ok := parseID(source.DocumentColumn); ok {
+		l.documentIDs[id] = struct{}{}
+	}
+	if id, ok := parseID(source.TaskColumn); ok {
+		l.taskIDs[id] = struct{}{}
+	}
+
+	for _, column := range source.FileColumns {
+		for _, reference := range evidenceFileReferences(fields[column]) {
+			if _, seen := l.seenFiles[reference]; seen {
+				continue
+			}
+			l.seenFiles[reference] = struct{}{}
+			l.files = append(l.files, evidenceAttachment{source: source.Name, reference: reference})
+		}
+	}
+}
+
+func (l *evidenceLinks) documentIDList() []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(l.documentIDs))
+	for id := range l.documentIDs {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (l *evidenceLinks) taskIDList() []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(l.taskIDs))
+	for id := range l.taskIDs {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// evidenceFileReferences reads a jsonb file list: either plain path strings or
+// objects carrying the path under url, path or file_path.
+func evidenceFileReferences(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil
+	}
+
+	references := make([]string, 0, len(items))
+	for _, item := range items {
+		var reference string
+		if err := json.Unmarshal(item, &reference); err != nil {
+			var object map[string]interface{}
+			if json.Unmarshal(item, &object) != nil {
+				continue
+			}
+			for _, key := range []string{"url", "path", "file_path"} {
+				if value, ok := object[key].(string); ok && value != "" {
+					reference = value
+					break
+				}
+			}
+		}
+		if reference = strings.TrimSpace(reference); reference != "" {
+			references = append(references, reference)
+		}
+	}
+	return references
+}
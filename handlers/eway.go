@@ -6,7 +6,6 @@ import (
 	"strconv"
 
 	"gorm.io/gorm/clause"
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 
@@ -25,7 +24,7 @@ func GetAllEways(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	service := models.NewReportService(config.DB, models.Eway{})
+	service := models.NewReportService(middleware.ScopedDB(r), models.Eway{})
 	response, err := service.GetReport(params)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -41,7 +40,7 @@ func CreateEway(w http.ResponseWriter, r *http.Request) {
 	json.NewDecoder(r.Body).Decode(&item)
 	user := middleware.GetUser(r)
 	item.EnteredBy = user.Name
-	config.DB.Create(&item)
+	middleware.ScopedDB(r).Create(&item)
 	json.NewEncoder(w).Encode(item)
 }
 
@@ -49,7 +48,7 @@ func GetEway(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id, _ := strconv.Atoi(params["id"])
 	var item models.Eway
-	config.DB.First(&item, id)
+	middleware.ScopedDB(r).First(&item, id)
 	json.NewEncoder(w).Encode(item)
 }
 
@@ -57,16 +56,16 @@ func UpdateEway(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id, _ := strconv.Atoi(params["id"])
 	var item models.Eway
-	config.DB.First(&item, id)
+	middleware.ScopedDB(r).First(&item, id)
 	json.NewDecoder(r.Body).Decode(&item)
-	config.DB.Save(&item)
+	middleware.ScopedDB(r).Save(&item)
 	json.NewEncoder(w).Encode(item)
 }
 
 func DeleteEway(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id, _ := strconv.Atoi(params["id"])
-	config.DB.Delete(&models.Eway{}, id)
+	middleware.ScopedDB(r).Delete(&models.Eway{}, id)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -83,7 +82,7 @@ func BatchEwayss(w http.ResponseWriter, r *http.Request) {
 
 	}
 
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Clauses(clause.OnConflict{
 			Columns:   []clause.Column{{Name: "id"}},
 			DoNothing: true,
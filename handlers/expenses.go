@@ -0,0 +1,825 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+func expenseClaimRecord(claim *models.ExpenseClaim) procurementRecord {
+	return procurementRecord{
+		kind: "expense_claim", table: "expense_claims", permission: "expense:create",
+		id: claim.ID, businessID: claim.BusinessVerticalID, workflowID: claim.WorkflowID,
+		state: claim.CurrentState, createdBy: claim.CreatedBy, title: "Expense claim " + claim.ClaimNumber,
+		limitType: models.ApprovalLimitExpense, amount: claim.TotalAmount,
+	}
+}
+
+// seesAllExpenseClaims reports whether the user may see every claim in the business:
+// with expense:read, or as an approver. Others see only their own.
+func seesAllExpenseClaims(r *http.Request) bool {
+	permissions := middleware.GetEffectivePermissions(r)
+	return hasWorkflowPermission(permissions, "expense:read") ||
+		hasWorkflowPermission(permissions, "workflow:l1_approve") ||
+		hasWorkflowPermission(permissions, "workflow:l2_approve")
+}
+
+// loadExpenseClaim loads the claim in the request within the business, if the user may see it
+func loadExpenseClaim(db *gorm.DB, r *http.Request, businessID uuid.UUID) (*models.ExpenseClaim, error) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		return nil, apiError{status: http.StatusBadRequest, message: "invalid claim id"}
+	}
+	query := db.Where("business_vertical_id = ? AND deleted_at IS NULL", businessID)
+	if !seesAllExpenseClaims(r) {
+		query = query.Where("claimant_id = ?", middleware.GetClaims(r).UserID)
+	}
+	var claim models.ExpenseClaim
+	if err := query.First(&claim, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError{status: http.StatusNotFound, message: "expense claim not found"}
+		}
+		return nil, err
+	}
+	return &claim, nil
+}
+
+// ==========================
+// Expense claim handlers
+// ==========================
+
+// expenseClaimRequest is the body of expense claim create and update requests
+type expenseClaimRequest struct {
+	Purpose string `json:"purpose"`
+	Items   []struct {
+		ExpenseDate        time.Time  `json:"expense_date"`
+		Category           string     `json:"category"`
+		Description        string     `json:"description"`
+		Amount             float64    `json:"amount"`
+		ProjectID          *uuid.UUID `json:"project_id"`
+		TaskID             *uuid.UUID `json:"task_id"`
+		CostCenterID       *uuid.UUID `json:"cost_center_id"`
+		ReceiptDocumentIDs []string   `json:"receipt_document_ids"`
+	} `json:"items"`
+}
+
+// claimItems validates the request's items and returns them with their total. Projects,
+// tasks and cost centers must belong to the business, and receipts must be DMS documents
+// the claimant uploaded to it. Items without a cost center take their task's or project's.
+func (req expenseClaimRequest) claimItems(businessID uuid.UUID, claimantID string) ([]models.ExpenseClaimItem, float64, error) {
+	if strings.TrimSpace(req.Purpose) == "" {
+		return nil, 0, apiError{status: http.StatusBadRequest, message: "purpose is required"}
+	}
+	if len(req.Items) == 0 {
+		return nil, 0, apiError{status: http.StatusBadRequest, message: "at least one expense is required"}
+	}
+	items := make([]models.ExpenseClaimItem, 0, len(req.Items))
+	total := 0.0
+	today := time.Now()
+	for i, in := range req.Items {
+		item := models.ExpenseClaimItem{
+			ExpenseDate:        in.ExpenseDate,
+			Category:           strings.TrimSpace(in.Category),
+			Description:        strings.TrimSpace(in.Description),
+			Amount:             math.Round(in.Amount*100) / 100,
+			ProjectID:          in.ProjectID,
+			TaskID:             in.TaskID,
+			CostCenterID:       in.CostCenterID,
+			ReceiptDocumentIDs: models.StringArray{},
+		}
+		switch {
+		case !models.ValidExpenseCategory(item.Category):
+			return nil, 0, apiError{status: http.StatusBadRequest, message: fmt.Sprintf("expense %d: unknown category %q", i+1, in.Category)}
+		case item.Description == "" || item.Amount <= 0:
+			return nil, 0, apiError{status: http.StatusBadRequest, message: fmt.Sprintf("expense %d: description and a positive amount are required", i+1)}
+		case item.ExpenseDate.IsZero() || item.ExpenseDate.After(today):
+			return nil, 0, apiError{status: http.StatusBadRequest, message: fmt.Sprintf("expense %d: expense_date is required and cannot be in the future", i+1)}
+		case item.TaskID != nil && item.ProjectID == nil:
+			return nil, 0, apiError{status: http.StatusBadRequest, message: fmt.Sprintf("expense %d: a task needs its project_id", i+1)}
+		}
+
+		var count int64
+		if item.ProjectID != nil {
+			config.DB.Model(&models.Project{}).Where("id = ? AND business_vertical_id = ?", *item.ProjectID, businessID).Count(&count)
+			if count == 0 {
+				return nil, 0, apiError{status: http.StatusBadRequest, message: fmt.Sprintf("expense %d: project not found in this business", i+1)}
+			}
+		}
+		if item.TaskID != nil {
+			config.DB.Model(&models.Tasks{}).Where("id = ? AND project_id = ?", *item.TaskID, *item.ProjectID).Count(&count)
+			if count == 0 {
+				return nil, 0, apiError{status: http.StatusBadRequest, message: fmt.Sprintf("expense %d: task not found in the project", i+1)}
+			}
+		}
+		if item.CostCenterID == nil {
+			item.CostCenterID = workCostCenter(config.DB, item.ProjectID, item.TaskID)
+		} else if err := checkCostCenter(config.DB, businessID, item.CostCenterID); err != nil {
+			return nil, 0, apiError{status: http.StatusBadRequest, message: fmt.Sprintf("expense %d: %s", i+1, err.Error())}
+		}
+		if len(in.ReceiptDocumentIDs) > 0 {
+			ids := make([]uuid.UUID, 0, len(in.ReceiptDocumentIDs))
+			for _, v := range in.ReceiptDocumentIDs {
+				id, err := uuid.Parse(v)
+				if err != nil {
+					return nil, 0, apiError{status: http.StatusBadRequest, message: fmt.Sprintf("expense %d: invalid receipt document id %q", i+1, v)}
+				}
+				ids = append(ids, id)
+				item.ReceiptDocumentIDs = append(item.ReceiptDocumentIDs, id.String())
+			}
+			config.DB.Model(&models.Document{}).
+				Where("id IN ? AND business_vertical_id = ? AND uploaded_by_id = ?", ids, businessID, claimantID).Count(&count)
+			if int(count) != len(ids) {
+				return nil, 0, apiError{status: http.StatusBadRequest, message: fmt.Sprintf("expense %d: receipts must be documents you uploaded to this business", i+1)}
+			}
+		}
+		total += item.Amount
+		items = append(items, item)
+	}
+	return items, math.Round(total*100) / 100, nil
+}
+
+// ListExpenseClaims lists expense claims: the user's own, or the whole business's for
+// holders of expense:read and approvers. ?state=, ?claimant_id=, ?project_id= and
+// ?unbatched=true (approved claims not yet in a reimbursement batch) narrow them.
+// GET /api/v1/business/{businessCode}/expense-claims
+func ListExpenseClaims(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load expense claims")
+		return
+	}
+
+	query := middleware.ScopedDB(r).Where("business_vertical_id = ? AND deleted_at IS NULL", businessID)
+	q := r.URL.Query()
+	if !seesAllExpenseClaims(r) {
+		query = query.Where("claimant_id = ?", middleware.GetClaims(r).UserID)
+	} else if v := q.Get("claimant_id"); v != "" {
+		query = query.Where("claimant_id = ?", v)
+	}
+	if v := q.Get("state"); v != "" {
+		query = query.Where("current_state = ?", v)
+	}
+	if v := q.Get("project_id"); v != "" {
+		query = query.Where("id IN (SELECT claim_id FROM expense_claim_items WHERE project_id = ?)", v)
+	}
+	if q.Get("unbatched") == "true" {
+		query = query.Where("current_state = ? AND reimbursement_batch_id IS NULL", models.ProcurementApproved)
+	}
+	var items []models.ExpenseClaim
+	if err := query.Order("created_at DESC").Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch expense claims", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// CreateExpenseClaim raises a draft expense claim for the user
+// POST /api/v1/business/{businessCode}/expense-claims
+func CreateExpenseClaim(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to create expense claim")
+		return
+	}
+
+	var req expenseClaimRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	claims := middleware.GetClaims(r)
+	items, total, err := req.claimItems(businessID, claims.UserID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to create expense claim")
+		return
+	}
+	workflowID, err := procurementWorkflowID(middleware.ScopedDB(r))
+	if err != nil {
+		writeProcurementErr(w, err, "failed to create expense claim")
+		return
+	}
+
+	claim := models.ExpenseClaim{
+		BusinessVerticalID: businessID,
+		ClaimantID:         claims.UserID,
+		ClaimantName:       claims.Name,
+		Purpose:            strings.TrimSpace(req.Purpose),
+		TotalAmount:        total,
+		WorkflowID:         workflowID,
+		CurrentState:       models.ProcurementDraft,
+		CreatedBy:          claims.UserID,
+		Items:              items,
+	}
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		// Numbered per business and month; the lock keeps concurrent claims from sharing one
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "expense_claims:"+businessID.String()).Error; err != nil {
+			return err
+		}
+		prefix := "EXP-" + time.Now().Format("200601") + "-"
+		var count int64
+		if err := tx.Model(&models.ExpenseClaim{}).
+			Where("business_vertical_id = ? AND claim_number LIKE ?", businessID, prefix+"%").Count(&count).Error; err != nil {
+			return err
+		}
+		claim.ClaimNumber = fmt.Sprintf("%s%04d", prefix, count+1)
+		return tx.Create(&claim).Error
+	})
+	if err != nil {
+		http.Error(w, "failed to create expense claim", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "expense claim created", "item": claim})
+}
+
+// GetExpenseClaim returns an expense claim with its expenses, its workflow history, the
+// actions the user may take on it and, while it can still be submitted or approved, how
+// it stands against the claimant's expense policy
+// GET /api/v1/business/{businessCode}/expense-claims/{id}
+func GetExpenseClaim(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load expense claim")
+		return
+	}
+	claim, err := loadExpenseClaim(middleware.ScopedDB(r).Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("expense_date")
+	}), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load expense claim")
+		return
+	}
+
+	extra := map[string]interface{}{}
+	if claim.CurrentState != models.ProcurementApproved && claim.CurrentState != "rejected" {
+		violations, err := expensePolicyViolations(middleware.ScopedDB(r), claim)
+		if err != nil {
+			http.Error(w, "failed to check expense policy", http.StatusInternalServerError)
+			return
+		}
+		extra["policy_violations"] = violations
+	}
+	writeProcurementDocument(w, r, expenseClaimRecord(claim), claim, extra)
+}
+
+// UpdateExpenseClaim replaces the purpose and expenses of the user's own draft claim
+// PUT /api/v1/business/{businessCode}/expense-claims/{id}
+func UpdateExpenseClaim(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update expense claim")
+		return
+	}
+	claim, err := loadExpenseClaim(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load expense claim")
+		return
+	}
+	userID := middleware.GetClaims(r).UserID
+	if claim.ClaimantID != userID {
+		http.Error(w, "only the claimant can edit an expense claim", http.StatusForbidden)
+		return
+	}
+
+	var req expenseClaimRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	items, total, err := req.claimItems(businessID, claim.ClaimantID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update expense claim")
+		return
+	}
+
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.ExpenseClaim{}).
+			Where("id = ? AND current_state = ?", claim.ID, models.ProcurementDraft).
+			Updates(map[string]interface{}{"purpose": strings.TrimSpace(req.Purpose), "total_amount": total, "updated_by": userID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return apiError{status: http.StatusConflict, message: "only a draft expense claim can be edited"}
+		}
+		if err := tx.Where("claim_id = ?", claim.ID).Delete(&models.ExpenseClaimItem{}).Error; err != nil {
+			return err
+		}
+		for i := range items {
+			items[i].ClaimID = claim.ID
+		}
+		return tx.Create(&items).Error
+	})
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update expense claim")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "expense claim updated", "items": items, "total_amount": total})
+}
+
+// TakeExpenseClaimAction takes a workflow action (submit, l1_approve, l2_approve, reject
+// or revise) on an expense claim. Only the claimant submits and revises it, and it cannot
+// be submitted while it breaks their expense policy. Its final approval posts it to the
+// ledger.
+// POST /api/v1/business/{businessCode}/expense-claims/{id}/actions/{action}
+func TakeExpenseClaimAction(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update expense claim")
+		return
+	}
+	claim, err := loadExpenseClaim(middleware.ScopedDB(r).Preload("Items"), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load expense claim")
+		return
+	}
+	action := mux.Vars(r)["action"]
+	if (action == "submit" || action == "revise") && claim.ClaimantID != middleware.GetClaims(r).UserID {
+		http.Error(w, "only the claimant can "+action+" an expense claim", http.StatusForbidden)
+		return
+	}
+	var req procurementActionRequest
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	transition, err := takeProcurementAction(r, expenseClaimRecord(claim), action, req.Comment,
+		func(tx *gorm.DB, to string) error {
+			if to == models.ProcurementApproved {
+				return postExpenseClaim(tx, claim, middleware.GetClaims(r).UserID)
+			}
+			if action != "submit" {
+				return nil
+			}
+			// Serialise the claimant's submissions so two claims cannot both fit under a monthly limit
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "expense_claimant:"+claim.ClaimantID).Error; err != nil {
+				return err
+			}
+			violations, err := expensePolicyViolations(tx, claim)
+			if err != nil {
+				return err
+			}
+			if len(violations) > 0 {
+				return apiError{status: http.StatusUnprocessableEntity, message: "the claim breaks expense policy: " + strings.Join(violations, "; ")}
+			}
+			return nil
+		})
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update expense claim")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "expense claim " + transition.ToState, "transition": transition})
+}
+
+// expensePolicyViolations checks the claim's expenses against the policies of the
+// claimant's active business roles, counting towards monthly limits what they have
+// claimed on their other claims in approval or approved
+func expensePolicyViolations(db *gorm.DB, claim *models.ExpenseClaim) ([]string, error) {
+	var policies []models.ExpensePolicy
+	now := time.Now()
+	if err := db.Where("business_vertical_id = ? AND business_role_id IN (?)", claim.BusinessVerticalID,
+		db.Table("user_business_roles").Select("business_role_id").
+			Where("user_id = ? AND is_active = ? AND (valid_from IS NULL OR valid_from <= ?) AND (valid_until IS NULL OR valid_until > ?)",
+				claim.ClaimantID, true, now, now)).
+		Find(&policies).Error; err != nil {
+		return nil, err
+	}
+	if len(policies) == 0 {
+		return []string{}, nil
+	}
+
+	var rows []struct {
+		Category string
+		Month    string
+		Amount   float64
+	}
+	if err := db.Table("expense_claim_items i").
+		Select("i.category, TO_CHAR(i.expense_date, 'YYYY-MM') AS month, SUM(i.amount) AS amount").
+		Joins("JOIN expense_claims c ON c.id = i.claim_id").
+		Where("c.business_vertical_id = ? AND c.claimant_id = ? AND c.id <> ? AND c.deleted_at IS NULL AND c.current_state NOT IN ?",
+			claim.BusinessVerticalID, claim.ClaimantID, claim.ID, []string{models.ProcurementDraft, "rejected"}).
+		Group("i.category, month").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	claimedBefore := map[string]map[string]float64{}
+	for _, row := range rows {
+		if claimedBefore[row.Category] == nil {
+			claimedBefore[row.Category] = map[string]float64{}
+		}
+		claimedBefore[row.Category][row.Month] = row.Amount
+	}
+	return models.CheckExpensePolicy(claim.Items, models.MergeExpensePolicies(policies), claimedBefore), nil
+}
+
+// ==========================
+// Expense policy handlers
+// ==========================
+
+// ListExpensePolicies lists the business's expense policies. ?business_role_id= narrows
+// them to one role.
+// GET /api/v1/business/{businessCode}/expense-policies
+func ListExpensePolicies(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load expense policies")
+		return
+	}
+
+	query := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID)
+	if v := r.URL.Query().Get("business_role_id"); v != "" {
+		query = query.Where("business_role_id = ?", v)
+	}
+	var items []models.ExpensePolicy
+	if err := query.Order("business_role_id, category").Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch expense policies", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// UpsertExpensePolicy sets the limits a business role may claim in a category, replacing
+// any it had
+// POST /api/v1/business/{businessCode}/expense-policies
+func UpsertExpensePolicy(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to save expense policy")
+		return
+	}
+
+	var req struct {
+		BusinessRoleID       uuid.UUID `json:"business_role_id"`
+		Category             string    `json:"category"`
+		PerExpenseLimit      float64   `json:"per_expense_limit"`
+		MonthlyLimit         float64   `json:"monthly_limit"`
+		ReceiptRequiredAbove float64   `json:"receipt_required_above"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !models.ValidExpenseCategory(req.Category) {
+		http.Error(w, "unknown category", http.StatusBadRequest)
+		return
+	}
+	if req.PerExpenseLimit < 0 || req.MonthlyLimit < 0 || req.ReceiptRequiredAbove < 0 {
+		http.Error(w, "limits cannot be negative", http.StatusBadRequest)
+		return
+	}
+	var count int64
+	middleware.ScopedDB(r).Model(&models.BusinessRole{}).Where("id = ? AND business_vertical_id = ?", req.BusinessRoleID, businessID).Count(&count)
+	if count == 0 {
+		http.Error(w, "business role not found in this business", http.StatusBadRequest)
+		return
+	}
+
+	userID := middleware.GetClaims(r).UserID
+	policy := models.ExpensePolicy{
+		BusinessVerticalID:   businessID,
+		BusinessRoleID:       req.BusinessRoleID,
+		Category:             req.Category,
+		PerExpenseLimit:      req.PerExpenseLimit,
+		MonthlyLimit:         req.MonthlyLimit,
+		ReceiptRequiredAbove: req.ReceiptRequiredAbove,
+		CreatedBy:            userID,
+		UpdatedBy:            userID,
+	}
+	if err := middleware.ScopedDB(r).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "business_role_id"}, {Name: "category"}},
+		DoUpdates: clause.AssignmentColumns([]string{"per_expense_limit", "monthly_limit", "receipt_required_above", "updated_by", "updated_at"}),
+	}).Create(&policy).Error; err != nil {
+		http.Error(w, "failed to save expense policy", http.StatusInternalServerError)
+		return
+	}
+	middleware.ScopedDB(r).Where("business_role_id = ? AND category = ?", policy.BusinessRoleID, policy.Category).First(&policy)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "expense policy saved", "item": policy})
+}
+
+// DeleteExpensePolicy removes an expense policy, leaving the role unlimited in its category
+// DELETE /api/v1/business/{businessCode}/expense-policies/{id}
+func DeleteExpensePolicy(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to delete expense policy")
+		return
+	}
+
+	result := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ?", mux.Vars(r)["id"], businessID).Delete(&models.ExpensePolicy{})
+	if result.Error != nil {
+		http.Error(w, "failed to delete expense policy", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "expense policy not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "expense policy deleted"})
+}
+
+// ==========================
+// Reimbursement batch handlers
+// ==========================
+
+// loadReimbursementBatch loads the batch in the request within the business
+func loadReimbursementBatch(db *gorm.DB, r *http.Request, businessID uuid.UUID) (*models.ReimbursementBatch, error) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		return nil, apiError{status: http.StatusBadRequest, message: "invalid batch id"}
+	}
+	var batch models.ReimbursementBatch
+	if err := db.Where("business_vertical_id = ?", businessID).First(&batch, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError{status: http.StatusNotFound, message: "reimbursement batch not found"}
+		}
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// ListReimbursementBatches lists the business's reimbursement batches. ?status= narrows them.
+// GET /api/v1/business/{businessCode}/reimbursement-batches
+func ListReimbursementBatches(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load reimbursement batches")
+		return
+	}
+
+	query := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID)
+	if v := r.URL.Query().Get("status"); v != "" {
+		query = query.Where("status = ?", v)
+	}
+	var items []models.ReimbursementBatch
+	if err := query.Order("created_at DESC").Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch reimbursement batches", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// CreateReimbursementBatch gathers approved expense claims not yet in a batch into a new
+// one: those in claim_ids, or all of them when it is empty
+// POST /api/v1/business/{businessCode}/reimbursement-batches
+func CreateReimbursementBatch(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to create reimbursement batch")
+		return
+	}
+
+	var req struct {
+		ClaimIDs []uuid.UUID `json:"claim_ids"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	batch := models.ReimbursementBatch{
+		BusinessVerticalID: businessID,
+		Status:             models.ReimbursementDraft,
+		CreatedBy:          middleware.GetClaims(r).UserID,
+	}
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("business_vertical_id = ? AND current_state = ? AND reimbursement_batch_id IS NULL AND deleted_at IS NULL",
+				businessID, models.ProcurementApproved)
+		if len(req.ClaimIDs) > 0 {
+			query = query.Where("id IN ?", req.ClaimIDs)
+		}
+		var claims []models.ExpenseClaim
+		if err := query.Find(&claims).Error; err != nil {
+			return err
+		}
+		if len(claims) == 0 || (len(req.ClaimIDs) > 0 && len(claims) != len(req.ClaimIDs)) {
+			return apiError{status: http.StatusConflict, message: "claims must be approved and not already in a batch"}
+		}
+
+		ids := make([]uuid.UUID, 0, len(claims))
+		for _, c := range claims {
+			ids = append(ids, c.ID)
+			batch.TotalAmount += c.TotalAmount
+		}
+		batch.ClaimCount = len(claims)
+		batch.TotalAmount = math.Round(batch.TotalAmount*100) / 100
+
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "reimbursement_batches:"+businessID.String()).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&models.ReimbursementBatch{}).Where("business_vertical_id = ?", businessID).Count(&count).Error; err != nil {
+			return err
+		}
+		batch.BatchNumber = fmt.Sprintf("RB-%s-%04d", time.Now().Format("200601"), count+1)
+		if err := tx.Create(&batch).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.ExpenseClaim{}).Where("id IN ?", ids).Update("reimbursement_batch_id", batch.ID).Error
+	})
+	if err != nil {
+		writeProcurementErr(w, err, "failed to create reimbursement batch")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "reimbursement batch created", "item": batch})
+}
+
+// GetReimbursementBatch returns a reimbursement batch with its claims
+// GET /api/v1/business/{businessCode}/reimbursement-batches/{id}
+func GetReimbursementBatch(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load reimbursement batch")
+		return
+	}
+	batch, err := loadReimbursementBatch(middleware.ScopedDB(r).Preload("Claims", func(db *gorm.DB) *gorm.DB {
+		return db.Order("claimant_name, claim_number")
+	}), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load reimbursement batch")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"item": batch})
+}
+
+// RemoveClaimFromReimbursementBatch takes a claim out of a draft batch, back to the
+// approved claims awaiting one
+// DELETE /api/v1/business/{businessCode}/reimbursement-batches/{id}/claims/{claimId}
+func RemoveClaimFromReimbursementBatch(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update reimbursement batch")
+		return
+	}
+	batch, err := loadReimbursementBatch(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load reimbursement batch")
+		return
+	}
+
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(batch, "id = ?", batch.ID).Error; err != nil {
+			return err
+		}
+		if batch.Status != models.ReimbursementDraft {
+			return apiError{status: http.StatusConflict, message: "claims can only be removed from a draft batch"}
+		}
+		var claim models.ExpenseClaim
+		if err := tx.Where("id = ? AND reimbursement_batch_id = ?", mux.Vars(r)["claimId"], batch.ID).First(&claim).Error; err != nil {
+			return apiError{status: http.StatusNotFound, message: "claim not found in this batch"}
+		}
+		if err := tx.Model(&claim).Update("reimbursement_batch_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Model(batch).Updates(map[string]interface{}{
+			"claim_count":  gorm.Expr("claim_count - 1"),
+			"total_amount": gorm.Expr("total_amount - ?", claim.TotalAmount),
+		}).Error
+	})
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update reimbursement batch")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "claim removed from batch"})
+}
+
+// ExportReimbursementBatch writes a batch's payments as CSV for finance to pay from, one
+// row per claimant, and marks the batch exported. Exporting again gives the same file.
+// POST /api/v1/business/{businessCode}/reimbursement-batches/{id}/export
+func ExportReimbursementBatch(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to export reimbursement batch")
+		return
+	}
+	batch, err := loadReimbursementBatch(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load reimbursement batch")
+		return
+	}
+	if batch.Status == models.ReimbursementDraft && batch.ClaimCount == 0 {
+		http.Error(w, "the batch has no claims", http.StatusConflict)
+		return
+	}
+
+	var rows []struct {
+		ClaimantID   string
+		ClaimantName string
+		Email        string
+		Phone        string
+		Claims       string
+		ClaimCount   int
+		Amount       float64
+	}
+	if err := middleware.ScopedDB(r).Table("expense_claims c").
+		Select(`c.claimant_id, MAX(COALESCE(u.name, c.claimant_name)) AS claimant_name, MAX(u.email) AS email, MAX(u.phone) AS phone,
+			STRING_AGG(c.claim_number, ' ' ORDER BY c.claim_number) AS claims, COUNT(*) AS claim_count, SUM(c.total_amount) AS amount`).
+		Joins("LEFT JOIN users u ON u.id::text = c.claimant_id").
+		Where("c.reimbursement_batch_id = ?", batch.ID).
+		Group("c.claimant_id").Order("claimant_name").Scan(&rows).Error; err != nil {
+		http.Error(w, "failed to load batch claims", http.StatusInternalServerError)
+		return
+	}
+
+	if batch.Status == models.ReimbursementDraft {
+		now := time.Now()
+		if err := middleware.ScopedDB(r).Model(&models.ReimbursementBatch{}).
+			Where("id = ? AND status = ?", batch.ID, models.ReimbursementDraft).
+			Updates(map[string]interface{}{"status": models.ReimbursementExported, "exported_at": now, "exported_by": middleware.GetClaims(r).UserID}).Error; err != nil {
+			http.Error(w, "failed to mark batch exported", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write([]string{"batch_number", "claimant_id", "claimant_name", "email", "phone", "claim_numbers", "claim_count", "amount"})
+	for _, row := range rows {
+		writer.Write([]string{batch.BatchNumber, row.ClaimantID, row.ClaimantName, row.Email, row.Phone, row.Claims,
+			fmt.Sprintf("%d", row.ClaimCount), fmt.Sprintf("%.2f", row.Amount)})
+	}
+	writer.Flush()
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=reimbursement_%s.csv", batch.BatchNumber))
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// PayReimbursementBatch records an exported batch as paid, with its payment reference,
+// marks its claims paid and posts the payment to the ledger
+// POST /api/v1/business/{businessCode}/reimbursement-batches/{id}/pay
+func PayReimbursementBatch(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to pay reimbursement batch")
+		return
+	}
+	batch, err := loadReimbursementBatch(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load reimbursement batch")
+		return
+	}
+
+	var req struct {
+		PaymentReference string     `json:"payment_reference"`
+		PaidAt           *time.Time `json:"paid_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.PaymentReference = strings.TrimSpace(req.PaymentReference)
+	if req.PaymentReference == "" {
+		http.Error(w, "payment_reference is required", http.StatusBadRequest)
+		return
+	}
+	paidAt := time.Now()
+	if req.PaidAt != nil {
+		paidAt = *req.PaidAt
+	}
+	userID := middleware.GetClaims(r).UserID
+
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.ReimbursementBatch{}).Where("id = ? AND status = ?", batch.ID, models.ReimbursementExported).
+			Updates(map[string]interface{}{
+				"status": models.ReimbursementPaid, "paid_at": paidAt, "paid_by": userID, "payment_reference": req.PaymentReference,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return apiError{status: http.StatusConflict, message: "only an exported batch can be paid"}
+		}
+		if err := tx.Model(&models.ExpenseClaim{}).Where("reimbursement_batch_id = ?", batch.ID).
+			Updates(map[string]interface{}{"paid_at": paidAt, "payment_reference": req.PaymentReference}).Error; err != nil {
+			return err
+		}
+		return postReimbursementBatch(tx, batch, paidAt, userID)
+	})
+	if err != nil {
+		writeProcurementErr(w, err, "failed to pay reimbursement batch")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "reimbursement batch paid"})
+}
@@ -9,7 +9,6 @@ import (
 	"github.com/gorilla/mux"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 )
@@ -96,7 +95,7 @@ func ListBankGuarantees(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var items []models.BankGuarantee
-	query := config.DB.Where("business_vertical_id = ?", businessID)
+	query := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID)
 	if status := r.URL.Query().Get("status"); status != "" {
 		query = query.Where("status = ?", status)
 	}
@@ -137,7 +136,7 @@ func CreateBankGuarantee(w http.ResponseWriter, r *http.Request) {
 		item.Currency = "INR"
 	}
 
-	tx := config.DB.Begin()
+	tx := middleware.ScopedDB(r).Begin()
 	if err := tx.Create(&item).Error; err != nil {
 		tx.Rollback()
 		http.Error(w, "failed to create bank guarantee", http.StatusInternalServerError)
@@ -181,7 +180,7 @@ func GetBankGuarantee(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var item models.BankGuarantee
-	if err := config.DB.Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item).Error; err != nil {
 		http.Error(w, "bank guarantee not found", http.StatusNotFound)
 		return
 	}
@@ -204,7 +203,7 @@ func UpdateBankGuarantee(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var item models.BankGuarantee
-	if err := config.DB.Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item).Error; err != nil {
 		http.Error(w, "bank guarantee not found", http.StatusNotFound)
 		return
 	}
@@ -220,7 +219,7 @@ func UpdateBankGuarantee(w http.ResponseWriter, r *http.Request) {
 	req.CreatedBy = item.CreatedBy
 	req.UpdatedBy = middleware.GetClaims(r).UserID
 
-	if err := config.DB.Model(&item).Updates(req).Error; err != nil {
+	if err := middleware.ScopedDB(r).Model(&item).Updates(req).Error; err != nil {
 		http.Error(w, "failed to update bank guarantee", http.StatusInternalServerError)
 		return
 	}
@@ -241,7 +240,7 @@ func ListLettersOfCredit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var items []models.LetterOfCredit
-	query := config.DB.Where("business_vertical_id = ?", businessID)
+	query := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID)
 	if status := r.URL.Query().Get("status"); status != "" {
 		query = query.Where("status = ?", status)
 	}
@@ -282,7 +281,7 @@ func CreateLetterOfCredit(w http.ResponseWriter, r *http.Request) {
 		item.Currency = "INR"
 	}
 
-	tx := config.DB.Begin()
+	tx := middleware.ScopedDB(r).Begin()
 	if err := tx.Create(&item).Error; err != nil {
 		tx.Rollback()
 		http.Error(w, "failed to create letter of credit", http.StatusInternalServerError)
@@ -326,7 +325,7 @@ func GetLetterOfCredit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var item models.LetterOfCredit
-	if err := config.DB.Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item).Error; err != nil {
 		http.Error(w, "letter of credit not found", http.StatusNotFound)
 		return
 	}
@@ -349,7 +348,7 @@ func UpdateLetterOfCredit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var item models.LetterOfCredit
-	if err := config.DB.Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item).Error; err != nil {
 		http.Error(w, "letter of credit not found", http.StatusNotFound)
 		return
 	}
@@ -365,7 +364,7 @@ func UpdateLetterOfCredit(w http.ResponseWriter, r *http.Request) {
 	req.CreatedBy = item.CreatedBy
 	req.UpdatedBy = middleware.GetClaims(r).UserID
 
-	if err := config.DB.Model(&item).Updates(req).Error; err != nil {
+	if err := middleware.ScopedDB(r).Model(&item).Updates(req).Error; err != nil {
 		http.Error(w, "failed to update letter of credit", http.StatusInternalServerError)
 		return
 	}
@@ -386,7 +385,7 @@ func ListInsurancePolicies(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var items []models.InsurancePolicy
-	query := config.DB.Where("business_vertical_id = ?", businessID)
+	query := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID)
 	if status := r.URL.Query().Get("status"); status != "" {
 		query = query.Where("status = ?", status)
 	}
@@ -427,7 +426,7 @@ func CreateInsurancePolicy(w http.ResponseWriter, r *http.Request) {
 		item.Currency = "INR"
 	}
 
-	tx := config.DB.Begin()
+	tx := middleware.ScopedDB(r).Begin()
 	if err := tx.Create(&item).Error; err != nil {
 		tx.Rollback()
 		http.Error(w, "failed to create insurance policy", http.StatusInternalServerError)
@@ -471,7 +470,7 @@ func GetInsurancePolicy(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var item models.InsurancePolicy
-	if err := config.DB.Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item).Error; err != nil {
 		http.Error(w, "insurance policy not found", http.StatusNotFound)
 		return
 	}
@@ -494,7 +493,7 @@ func UpdateInsurancePolicy(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var item models.InsurancePolicy
-	if err := config.DB.Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item).Error; err != nil {
 		http.Error(w, "insurance policy not found", http.StatusNotFound)
 		return
 	}
@@ -510,7 +509,7 @@ func UpdateInsurancePolicy(w http.ResponseWriter, r *http.Request) {
 	req.CreatedBy = item.CreatedBy
 	req.UpdatedBy = middleware.GetClaims(r).UserID
 
-	if err := config.DB.Model(&item).Updates(req).Error; err != nil {
+	if err := middleware.ScopedDB(r).Model(&item).Updates(req).Error; err != nil {
 		http.Error(w, "failed to update insurance policy", http.StatusInternalServerError)
 		return
 	}
@@ -531,7 +530,7 @@ func ListInsuranceClaims(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var items []models.InsuranceClaim
-	query := config.DB.Where("business_vertical_id = ?", businessID)
+	query := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID)
 	if status := r.URL.Query().Get("status"); status != "" {
 		query = query.Where("status = ?", status)
 	}
@@ -567,7 +566,7 @@ func CreateInsuranceClaim(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var policy models.InsurancePolicy
-	if err := config.DB.Where("id = ? AND business_vertical_id = ?", item.PolicyID, businessID).First(&policy).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ?", item.PolicyID, businessID).First(&policy).Error; err != nil {
 		http.Error(w, "policy not found in business context", http.StatusBadRequest)
 		return
 	}
@@ -578,7 +577,7 @@ func CreateInsuranceClaim(w http.ResponseWriter, r *http.Request) {
 		item.Status = "filed"
 	}
 
-	tx := config.DB.Begin()
+	tx := middleware.ScopedDB(r).Begin()
 	if err := tx.Create(&item).Error; err != nil {
 		tx.Rollback()
 		http.Error(w, "failed to create insurance claim", http.StatusInternalServerError)
@@ -622,7 +621,7 @@ func GetInsuranceClaim(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var item models.InsuranceClaim
-	if err := config.DB.Preload("Policy").Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item).Error; err != nil {
+	if err := middleware.ScopedDB(r).Preload("Policy").Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item).Error; err != nil {
 		http.Error(w, "insurance claim not found", http.StatusNotFound)
 		return
 	}
@@ -645,7 +644,7 @@ func UpdateInsuranceClaim(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var item models.InsuranceClaim
-	if err := config.DB.Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item).Error; err != nil {
 		http.Error(w, "insurance claim not found", http.StatusNotFound)
 		return
 	}
@@ -662,7 +661,7 @@ func UpdateInsuranceClaim(w http.ResponseWriter, r *http.Request) {
 	req.CreatedBy = item.CreatedBy
 	req.UpdatedBy = middleware.GetClaims(r).UserID
 
-	if err := config.DB.Model(&item).Updates(req).Error; err != nil {
+	if err := middleware.ScopedDB(r).Model(&item).Updates(req).Error; err != nil {
 		http.Error(w, "failed to update insurance claim", http.StatusInternalServerError)
 		return
 	}
@@ -692,7 +691,7 @@ func transitionBankGuaranteeStatus(w http.ResponseWriter, r *http.Request, statu
 	}
 
 	var item models.BankGuarantee
-	if err := config.DB.Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item).Error; err != nil {
 		http.Error(w, "bank guarantee not found", http.StatusNotFound)
 		return
 	}
@@ -715,7 +714,7 @@ func transitionBankGuaranteeStatus(w http.ResponseWriter, r *http.Request, statu
 		updates["claim_date"] = &now
 	}
 
-	tx := config.DB.Begin()
+	tx := middleware.ScopedDB(r).Begin()
 	approvalID := item.ApprovalRequestID
 	if status == "approved" {
 		if approvalID == nil || *approvalID == uuid.Nil {
@@ -780,7 +779,7 @@ func transitionLetterOfCreditStatus(w http.ResponseWriter, r *http.Request, stat
 	}
 
 	var item models.LetterOfCredit
-	if err := config.DB.Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item).Error; err != nil {
 		http.Error(w, "letter of credit not found", http.StatusNotFound)
 		return
 	}
@@ -796,7 +795,7 @@ func transitionLetterOfCreditStatus(w http.ResponseWriter, r *http.Request, stat
 		updates["remarks"] = req.Remarks
 	}
 
-	tx := config.DB.Begin()
+	tx := middleware.ScopedDB(r).Begin()
 	approvalID := item.ApprovalRequestID
 	if status == "issued" {
 		if approvalID == nil || *approvalID == uuid.Nil {
@@ -861,7 +860,7 @@ func RenewInsurancePolicy(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var item models.InsurancePolicy
-	if err := config.DB.Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item).Error; err != nil {
 		http.Error(w, "insurance policy not found", http.StatusNotFound)
 		return
 	}
@@ -880,7 +879,7 @@ func RenewInsurancePolicy(w http.ResponseWriter, r *http.Request) {
 		updates["renewal_date"] = req.RenewalDate
 	}
 
-	tx := config.DB.Begin()
+	tx := middleware.ScopedDB(r).Begin()
 	approvalID := item.ApprovalRequestID
 	if approvalID == nil || *approvalID == uuid.Nil {
 		newID, err := createFinanceApprovalRequest(tx, businessID, "insurance_policy", item.ID, "insurance:renew", middleware.GetClaims(r).UserID, "Insurance policy renewal action")
@@ -927,7 +926,7 @@ func transitionInsuranceClaimStatus(w http.ResponseWriter, r *http.Request, stat
 	}
 
 	var item models.InsuranceClaim
-	if err := config.DB.Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item).Error; err != nil {
 		http.Error(w, "insurance claim not found", http.StatusNotFound)
 		return
 	}
@@ -950,7 +949,7 @@ func transitionInsuranceClaimStatus(w http.ResponseWriter, r *http.Request, stat
 		updates["settled_date"] = &now
 	}
 
-	tx := config.DB.Begin()
+	tx := middleware.ScopedDB(r).Begin()
 	approvalID := item.ApprovalRequestID
 	if status == "approved" || status == "settled" {
 		if approvalID == nil || *approvalID == uuid.Nil {
@@ -0,0 +1,534 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/formvalidation"
+	"p9e.in/ugcl/pkg/jobrunner"
+)
+
+// formDraftState is the state of a submission saved before it is complete
+const formDraftState = "draft"
+
+// defaultFormDraftTTL is how long a draft may go untouched before it expires
+const defaultFormDraftTTL = 30 * 24 * time.Hour
+
+// formDraftTTL is the draft lifetime, FORM_DRAFT_TTL (a duration such as 720h) when set
+func formDraftTTL() time.Duration {
+	if raw := strings.TrimSpace(os.Getenv("FORM_DRAFT_TTL")); raw != "" {
+		if ttl, err := time.ParseDuration(raw); err == nil && ttl > 0 {
+			return ttl
+		}
+		log.Printf("⚠️  Invalid FORM_DRAFT_TTL %q, using %v", raw, defaultFormDraftTTL)
+	}
+	return defaultFormDraftTTL
+}
+
+// formSubmissionData turns stored values back into form data as submitted, keyed by field
+// name: dates and times as strings, JSON columns decoded, and resolved references
+// ({"id", "name"}) as their IDs. Stored records are keyed by column, submissions by field.
+func formSubmissionData(fields []formvalidation.Field, stored map[string]interface{}) map[string]interface{} {
+	data := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		value, ok := stored[field.Name]
+		if !ok {
+			value, ok = stored[formColumnName(field.Name)]
+		}
+		if ok {
+			data[field.Name] = submittedValue(field.Type, value)
+		}
+	}
+	return data
+}
+
+func submittedValue(fieldType string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case time.Time:
+		switch fieldType {
+		case "date":
+			return v.Format("2006-01-02")
+		case "time":
+			return v.Format("15:04:05")
+		}
+		return v.Format(time.RFC3339)
+	case [16]byte:
+		return uuid.UUID(v).String()
+	case int32:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case []byte:
+		var decoded interface{}
+		if trimmed := strings.TrimSpace(string(v)); (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) &&
+			json.Unmarshal(v, &decoded) == nil {
+			return submittedValue(fieldType, decoded)
+		}
+		return string(v)
+	case map[string]interface{}:
+		if id, ok := v["id"]; ok && len(v) == 2 {
+			if _, named := v["name"]; named {
+				return id
+			}
+		}
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = submittedValue(fieldType, item)
+		}
+		return items
+	}
+	return value
+}
+
+// validateDraftCompletion validates a draft in full, as it is about to be submitted.
+// Locations are checked against their zones here, as a draft may have been saved first.
+func validateDraftCompletion(form *models.AppForm, stored map[string]interface{}) error {
+	rules := formvalidation.FromForm(form.FormSchema, form.Steps, form.Validations)
+	data := formSubmissionData(rules.Fields, stored)
+	if recordID, ok := columnUUID(stored["id"]); ok {
+		// Section rows are stored apart from the record
+		for _, section := range formSections(form) {
+			rows, err := NewFormTableManager().sectionRows("", section, recordID)
+			if err != nil {
+				return err
+			}
+			values := make([]interface{}, len(rows))
+			for i, row := range rows {
+				values[i] = formSubmissionData(section.Field.Section.Fields, row)
+			}
+			data[section.Field.Name] = values
+		}
+	}
+	if errs := rules.Validate(data, formvalidation.Options{}); len(errs) > 0 {
+		return errs
+	}
+	errs, err := formGeofenceErrors(rules.Fields, data, false)
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// formDraftProgress tells a client where to resume a draft
+type formDraftProgress struct {
+	// Complete is set when the draft passes full validation and can be submitted
+	Complete bool `json:"complete"`
+	// ResumeStep is the index of the first step with missing or invalid fields, or of the
+	// last step when the draft is complete
+	ResumeStep int    `json:"resume_step"`
+	StepID     string `json:"step_id,omitempty"`
+	StepTitle  string `json:"step_title,omitempty"`
+	// Errors are what full validation reports, so the client can mark the fields to fill
+	Errors    formvalidation.Errors `json:"errors,omitempty"`
+	ExpiresAt time.Time             `json:"expires_at"`
+}
+
+type formStep struct {
+	ID     string                   `json:"id"`
+	Title  string                   `json:"title"`
+	Fields []map[string]interface{} `json:"fields"`
+}
+
+// draftProgress works out how far a stored draft has got through the form's steps
+func draftProgress(form *models.AppForm, stored map[string]interface{}, updatedAt time.Time) formDraftProgress {
+	progress := formDraftProgress{ExpiresAt: updatedAt.Add(formDraftTTL())}
+	var errs formvalidation.Errors
+	if err := validateDraftCompletion(form, stored); err != nil {
+		errors.As(err, &errs)
+	}
+	progress.Complete = len(errs) == 0
+	progress.Errors = errs
+
+	var steps []formStep
+	if len(form.Steps) > 0 {
+		_ = json.Unmarshal(form.Steps, &steps)
+	}
+	if len(steps) == 0 {
+		return progress
+	}
+
+	stepOf := make(map[string]int)
+	for i, step := range steps {
+		for _, field := range step.Fields {
+			name, _ := field["name"].(string)
+			if name == "" {
+				name, _ = field["id"].(string)
+			}
+			stepOf[formColumnName(name)] = i
+		}
+	}
+	progress.ResumeStep = len(steps) - 1
+	for _, fieldErr := range errs {
+		if i, ok := stepOf[formColumnName(fieldErr.Field)]; ok && i < progress.ResumeStep {
+			progress.ResumeStep = i
+		}
+	}
+	progress.StepID = steps[progress.ResumeStep].ID
+	progress.StepTitle = steps[progress.ResumeStep].Title
+	return progress
+}
+
+// formDraftResponse is a draft as returned to clients, with where to resume it
+func formDraftResponse(form *models.AppForm, record map[string]interface{}) map[string]interface{} {
+	updatedAt, _ := record["updated_at"].(time.Time)
+	progress := draftProgress(form, record, updatedAt)
+	attachFormRecordFiles(form, []map[string]interface{}{record})
+	return map[string]interface{}{"draft": formRecordJSON(record), "progress": progress}
+}
+
+// loadFormDraft loads one of the caller's own drafts of the form, answering 404 for
+// anything else
+func loadFormDraft(w http.ResponseWriter, r *http.Request, form *models.AppForm, userID string) map[string]interface{} {
+	record := loadFormRecord(w, r, form, true)
+	if record == nil {
+		return nil
+	}
+	if columnString(record["current_state"]) != formDraftState || columnString(record["created_by"]) != userID ||
+		record["deleted_at"] != nil {
+		http.Error(w, "draft not found", http.StatusNotFound)
+		return nil
+	}
+	return record
+}
+
+// ListFormDrafts lists the caller's drafts of a form, most recently changed first, each
+// with the step to resume at and when it expires
+// GET /api/v1/forms/{code}/drafts
+func ListFormDrafts(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	form := loadRecordsForm(w, r)
+	if form == nil {
+		return
+	}
+	verticals, err := formRecordVerticals(r, form, true)
+	if err != nil {
+		log.Printf("❌ Failed to resolve verticals for form %s: %v", form.Code, err)
+		http.Error(w, "failed to load drafts", http.StatusInternalServerError)
+		return
+	}
+	drafts := make([]map[string]interface{}, 0)
+	if len(verticals) == 0 {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"drafts": drafts, "count": 0})
+		return
+	}
+
+	limit, err := parseSubmissionPageSize(r.URL.Query().Get("limit"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tableManager := NewFormTableManager()
+	table, err := tableManager.qualifiedTableName("", form.DBTableName)
+	if err != nil {
+		http.Error(w, "failed to load drafts", http.StatusInternalServerError)
+		return
+	}
+	var records []map[string]interface{}
+	if err := middleware.WithDataScope(r, tableManager.db).Raw(fmt.Sprintf(
+		`SELECT * FROM %s WHERE current_state = ? AND created_by = ? AND business_vertical_id IN ? AND deleted_at IS NULL
+		ORDER BY updated_at DESC, id DESC LIMIT ?`, table),
+		formDraftState, claims.UserID, verticals, limit).Scan(&records).Error; err != nil {
+		log.Printf("❌ Failed to list drafts of %s: %v", form.Code, err)
+		http.Error(w, "failed to load drafts", http.StatusInternalServerError)
+		return
+	}
+
+	for _, record := range records {
+		siteID, hasSite := columnUUID(record["site_id"])
+		verticalID, _ := columnUUID(record["business_vertical_id"])
+		if hasSite && !middleware.InDataScope(r, verticalID, &siteID) {
+			continue
+		}
+		drafts = append(drafts, formDraftResponse(form, record))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"drafts": drafts, "count": len(drafts)})
+}
+
+// CreateFormDraft saves a new draft of a form. The body is that of CreateFormRecord; only
+// the values present are validated, and the draft stays in the draft state until it is
+// submitted.
+// POST /api/v1/forms/{code}/drafts
+func CreateFormDraft(w http.ResponseWriter, r *http.Request) {
+	createFormRecord(w, r, true)
+}
+
+// GetFormDraft returns one of the caller's drafts with the step to resume it at
+// GET /api/v1/forms/{code}/drafts/{id}
+func GetFormDraft(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	form := loadRecordsForm(w, r)
+	if form == nil {
+		return
+	}
+	record := loadFormDraft(w, r, form, claims.UserID)
+	if record == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, formDraftResponse(form, record))
+}
+
+// UpdateFormDraft saves more of a draft. Values present are validated; fields left out
+// keep their saved values.
+// PUT /api/v1/forms/{code}/drafts/{id}
+func UpdateFormDraft(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	form := loadRecordsForm(w, r)
+	if form == nil {
+		return
+	}
+	existing := loadFormDraft(w, r, form, claims.UserID)
+	if existing == nil {
+		return
+	}
+
+	var req struct {
+		FormData map[string]interface{} `json:"form_data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.FormData) == 0 {
+		http.Error(w, "form_data is required", http.StatusBadRequest)
+		return
+	}
+
+	recordID, _ := columnUUID(existing["id"])
+	engine := getWorkflowEngineDedicated()
+	if _, err := engine.UpdateSubmissionDataDedicated(form.Code, recordID, req.FormData, claims.UserID); err != nil {
+		log.Printf("❌ Error updating draft %s of %s: %v", recordID, form.Code, err)
+		if writeFormValidationError(w, err) {
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	record, err := engine.tableManager.GetFormData(form.DBTableName, recordID)
+	if err != nil {
+		http.Error(w, "failed to load updated draft", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, formDraftResponse(form, record))
+}
+
+// DiscardFormDraft deletes one of the caller's drafts
+// DELETE /api/v1/forms/{code}/drafts/{id}
+func DiscardFormDraft(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	form := loadRecordsForm(w, r)
+	if form == nil {
+		return
+	}
+	existing := loadFormDraft(w, r, form, claims.UserID)
+	if existing == nil {
+		return
+	}
+
+	recordID, _ := columnUUID(existing["id"])
+	if err := getWorkflowEngineDedicated().DeleteSubmissionDedicated(form.Code, recordID, claims.UserID); err != nil {
+		log.Printf("❌ Error discarding draft %s of %s: %v", recordID, form.Code, err)
+		http.Error(w, "failed to discard draft", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "draft discarded", "id": recordID})
+}
+
+// draftSubmitAction picks the workflow action that submits a draft: the requested one, or
+// "submit", or the only action out of the draft state. It returns "" when the workflow has
+// no transition out of the draft state.
+func draftSubmitAction(workflow *models.WorkflowDefinition, requested string) (string, error) {
+	var transitions []models.WorkflowTransitionDef
+	if err := json.Unmarshal(workflow.Transitions, &transitions); err != nil {
+		return "", fmt.Errorf("invalid workflow configuration: %w", err)
+	}
+	var actions []string
+	for _, t := range transitions {
+		if t.From == formDraftState && t.To != formDraftState {
+			actions = append(actions, t.Action)
+		}
+	}
+	switch {
+	case len(actions) == 0:
+		return "", nil
+	case requested != "":
+		if !slices.Contains(actions, requested) {
+			return "", fmt.Errorf("invalid transition: action '%s' not allowed from state '%s'", requested, formDraftState)
+		}
+		return requested, nil
+	case slices.Contains(actions, "submit"):
+		return "submit", nil
+	case len(actions) == 1:
+		return actions[0], nil
+	}
+	return "", fmt.Errorf("action is required: the workflow submits drafts with one of %s", strings.Join(actions, ", "))
+}
+
+// SubmitFormDraft submits one of the caller's drafts once it passes full validation
+// (otherwise 422 with the per-field errors). It takes the workflow's transition out of the
+// draft state, ?action or "submit" when there are several; without one the draft moves to
+// the state new submissions start in.
+// POST /api/v1/forms/{code}/drafts/{id}/submit
+func SubmitFormDraft(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	user := middleware.GetUser(r)
+	form := loadRecordsForm(w, r)
+	if form == nil {
+		return
+	}
+	existing := loadFormDraft(w, r, form, claims.UserID)
+	if existing == nil {
+		return
+	}
+	recordID, _ := columnUUID(existing["id"])
+
+	var req struct {
+		Action  string `json:"action"`
+		Comment string `json:"comment"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Action == "" {
+		req.Action = r.URL.Query().Get("action")
+	}
+
+	action := ""
+	if workflowID, ok := columnUUID(existing["workflow_id"]); ok {
+		var version *int
+		if v, ok := columnInt(existing["workflow_version"]); ok {
+			version = &v
+		}
+		workflow, err := loadPinnedWorkflow(middleware.ScopedDB(r), workflowID, version)
+		if err != nil {
+			http.Error(w, "workflow not found", http.StatusInternalServerError)
+			return
+		}
+		if action, err = draftSubmitAction(workflow, req.Action); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	userRole := ""
+	if role := user.EffectiveRole(); role != nil {
+		userRole = role.Name
+	}
+
+	engine := getWorkflowEngineDedicated()
+	var submission *FormSubmissionRecord
+	var err error
+	if action != "" {
+		if err := engine.ValidateTransitionDedicated(form.Code, recordID, action, middleware.GetEffectivePermissions(r)); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		submission, err = engine.TransitionStateDedicated(form.Code, recordID, action, claims.UserID, user.Name, userRole, req.Comment, nil)
+	} else {
+		submission, err = engine.SubmitDraftDedicated(form.Code, recordID, claims.UserID, user.Name, userRole)
+	}
+	if err != nil {
+		log.Printf("❌ Error submitting draft %s of %s: %v", recordID, form.Code, err)
+		if writeFormValidationError(w, err) {
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	triggerDedicatedFormSubmissionWebhook(submission)
+
+	record, err := engine.tableManager.GetFormData(form.DBTableName, recordID)
+	if err != nil {
+		http.Error(w, "failed to load submitted record", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"record": formRecordJSON(record)})
+}
+
+// FormDraftExpirer discards drafts in dedicated form tables that nobody has changed for
+// the draft lifetime (FORM_DRAFT_TTL, 30 days by default)
+type FormDraftExpirer struct {
+	db *gorm.DB
+	*jobrunner.Runner
+}
+
+// NewFormDraftExpirer creates the form draft expirer
+func NewFormDraftExpirer() *FormDraftExpirer {
+	e := &FormDraftExpirer{db: config.DB}
+	e.Runner = jobrunner.New("Form draft expirer", func() {
+		if n, err := e.RunDue(time.Now()); err != nil {
+			log.Printf("Error expiring form drafts: %v", err)
+		} else if n > 0 {
+			log.Printf("Form draft expirer: discarded %d drafts", n)
+		}
+	})
+	return e
+}
+
+// RunDue soft deletes the drafts last changed before now less the draft lifetime and
+// returns how many were discarded. A form whose table cannot be swept is logged and
+// skipped so the others are still swept.
+func (e *FormDraftExpirer) RunDue(now time.Time) (int, error) {
+	var tables []string
+	if err := e.db.Model(&models.AppForm{}).Where("db_table_name <> ''").Distinct().Pluck("db_table_name", &tables).Error; err != nil {
+		return 0, err
+	}
+
+	cutoff := now.Add(-formDraftTTL())
+	tableManager := &FormTableManager{db: e.db}
+	discarded := 0
+	for _, name := range tables {
+		if exists, err := tableManager.TableExists(name); err != nil || !exists {
+			continue
+		}
+		table, err := tableManager.qualifiedTableName("", name)
+		if err != nil {
+			log.Printf("⚠️  Skipping drafts of %s: %v", name, err)
+			continue
+		}
+		result := e.db.Exec(fmt.Sprintf(
+			"UPDATE %s SET deleted_at = ?, deleted_by = ?, updated_at = ? WHERE current_state = ? AND deleted_at IS NULL AND updated_at < ?", table),
+			now, workflowSystemActor.ID, now, formDraftState, cutoff)
+		if result.Error != nil {
+			log.Printf("⚠️  Failed to expire drafts of %s: %v", name, result.Error)
+			continue
+		}
+		discarded += int(result.RowsAffected)
+	}
+	return discarded, nil
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"p9e.in/ugcl/models"
+)
+
+var draftTestForm = &models.AppForm{Steps: json.RawMessage(`[
+	{"id": "site", "title": "Site", "fields": [
+		{"id": "site_name", "type": "text", "required": true},
+		{"id": "visit_date", "type": "date", "required": true}]},
+	{"id": "findings", "title": "Findings", "fields": [
+		{"id": "defects", "type": "number", "required": true},
+		{"id": "contractor", "type": "text"}]}]`)}
+
+func TestDraftProgress(t *testing.T) {
+	updated := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+
+	// Stored columns come back typed by the driver
+	stored := map[string]interface{}{"site_name": "North yard", "visit_date": updated, "defects": nil}
+	progress := draftProgress(draftTestForm, stored, updated)
+	if progress.Complete || progress.ResumeStep != 1 || progress.StepID != "findings" {
+		t.Fatalf("got %+v", progress)
+	}
+	if len(progress.Errors) != 1 || progress.Errors[0].Field != "defects" {
+		t.Errorf("got errors %v", progress.Errors)
+	}
+	if !progress.ExpiresAt.Equal(updated.Add(defaultFormDraftTTL)) {
+		t.Errorf("got expiry %v", progress.ExpiresAt)
+	}
+
+	stored = map[string]interface{}{"visit_date": "2026-10-01", "defects": "3"}
+	if progress := draftProgress(draftTestForm, stored, updated); progress.ResumeStep != 0 || progress.StepTitle != "Site" {
+		t.Errorf("got %+v", progress)
+	}
+
+	stored = map[string]interface{}{"site_name": "North yard", "visit_date": updated, "defects": int32(2)}
+	if progress := draftProgress(draftTestForm, stored, updated); !progress.Complete || progress.ResumeStep != 1 {
+		t.Errorf("got %+v", progress)
+	}
+}
+
+func TestSubmittedValue(t *testing.T) {
+	if got := submittedValue("text", map[string]interface{}{"id": "abc", "name": "Dairy"}); got != "abc" {
+		t.Errorf("resolved reference gave %v", got)
+	}
+	if got := submittedValue("multiselect", []byte(`["a","b"]`)); len(got.([]interface{})) != 2 {
+		t.Errorf("JSON column gave %v", got)
+	}
+	if got := submittedValue("time", time.Date(0, 1, 1, 14, 30, 0, 0, time.UTC)); got != "14:30:00" {
+		t.Errorf("time column gave %v", got)
+	}
+}
+
+func TestDraftSubmitAction(t *testing.T) {
+	workflow := &models.WorkflowDefinition{Transitions: json.RawMessage(`[
+		{"from": "draft", "to": "submitted", "action": "submit"},
+		{"from": "draft", "to": "cancelled", "action": "cancel"},
+		{"from": "submitted", "to": "approved", "action": "approve"}]`)}
+
+	if action, err := draftSubmitAction(workflow, ""); err != nil || action != "submit" {
+		t.Errorf("default gave %q, %v", action, err)
+	}
+	if action, err := draftSubmitAction(workflow, "cancel"); err != nil || action != "cancel" {
+		t.Errorf("requested gave %q, %v", action, err)
+	}
+	if _, err := draftSubmitAction(workflow, "approve"); err == nil {
+		t.Error("expected an error for an action not out of draft")
+	}
+
+	workflow.Transitions = json.RawMessage(`[{"from": "submitted", "to": "approved", "action": "approve"}]`)
+	if action, err := draftSubmitAction(workflow, ""); err != nil || action != "" {
+		t.Errorf("no draft transition gave %q, %v", action, err)
+	}
+}
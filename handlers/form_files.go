@@ -0,0 +1,583 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // register decoders for thumbnails
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/formvalidation"
+)
+
+const (
+	// formFileDefaultMaxSizeMB caps uploads for fields without a maxSizeMB of their own
+	formFileDefaultMaxSizeMB = 25
+	// formFileURLTTL is how long a signed file URL stays valid
+	formFileURLTTL = 15 * time.Minute
+	// formFileThumbnailSize bounds the longer side of image thumbnails
+	formFileThumbnailSize = 320
+	// formFileMaxThumbnailPixels skips thumbnails of images too large to decode safely
+	formFileMaxThumbnailPixels = 50_000_000
+)
+
+// formFileField is a file, image or camera field of a form
+type formFileField struct {
+	Name     string
+	Type     string
+	Accept   []string // MIME types (image/*), or extensions (.pdf)
+	MaxBytes int64
+}
+
+// imagesOnly reports whether the field takes only images
+func (f formFileField) imagesOnly() bool {
+	return f.Type == "image" || f.Type == "camera"
+}
+
+func isFormFileType(fieldType string) bool {
+	switch fieldType {
+	case "file", "image", "camera":
+		return true
+	}
+	return false
+}
+
+// lookupFormFileField finds the file field named name in the form's definition
+func lookupFormFileField(form *models.AppForm, name string) (formFileField, bool) {
+	defs, _ := formvalidation.FieldDefinitions(form.FormSchema, form.Steps)
+	for _, def := range defs {
+		defName, _ := def["name"].(string)
+		if defName == "" {
+			defName, _ = def["id"].(string)
+		}
+		fieldType, _ := def["type"].(string)
+		if defName == "" || formColumnName(defName) != formColumnName(name) || !isFormFileType(fieldType) {
+			continue
+		}
+
+		field := formFileField{Name: formColumnName(defName), Type: fieldType, MaxBytes: formFileDefaultMaxSizeMB << 20}
+		if maxSize, ok := def["maxSizeMB"].(float64); ok && maxSize > 0 {
+			field.MaxBytes = int64(maxSize * (1 << 20))
+		}
+		switch accept := def["accept"].(type) {
+		case string:
+			field.Accept = splitFormRecordList(accept)
+		case []interface{}:
+			for _, entry := range accept {
+				if text, ok := entry.(string); ok && text != "" {
+					field.Accept = append(field.Accept, text)
+				}
+			}
+		}
+		return field, true
+	}
+	return formFileField{}, false
+}
+
+// accepts reports whether the field takes a file with this name and MIME type
+func (f formFileField) accepts(fileName, mimeType string) bool {
+	if f.imagesOnly() && !strings.HasPrefix(mimeType, "image/") {
+		return false
+	}
+	if len(f.Accept) == 0 {
+		return true
+	}
+	ext := strings.ToLower(filepath.Ext(fileName))
+	for _, accept := range f.Accept {
+		accept = strings.ToLower(accept)
+		switch {
+		case strings.HasPrefix(accept, "."):
+			if ext == accept {
+				return true
+			}
+		case strings.HasSuffix(accept, "/*"):
+			if strings.HasPrefix(mimeType, strings.TrimSuffix(accept, "*")) {
+				return true
+			}
+		case accept == mimeType:
+			return true
+		}
+	}
+	return false
+}
+
+// formFileSigningKey signs file URLs; FORM_FILE_URL_SECRET rotates them independently of
+// the JWT secret
+func formFileSigningKey() []byte {
+	if secret := strings.TrimSpace(os.Getenv("FORM_FILE_URL_SECRET")); secret != "" {
+		return []byte(secret)
+	}
+	return []byte(config.JWTSecret)
+}
+
+func formFileSignature(fileID uuid.UUID, variant string, expires int64) string {
+	mac := hmac.New(sha256.New, formFileSigningKey())
+	fmt.Fprintf(mac, "%s:%s:%d", fileID, variant, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// formFileSignedURL returns a URL that serves the file, or its thumbnail, without a login
+// until expires. Files in the upload bucket get a bucket-signed URL when the credentials
+// can sign; everything else is served by ServeFormFileContent.
+func formFileSignedURL(file *models.FormFile, variant string, expires time.Time) string {
+	path := file.FilePath
+	if variant == "thumbnail" {
+		path = file.ThumbnailPath
+	}
+	if useGCSStorage() {
+		if _, err := os.Stat(path); err != nil {
+			if client, err := getSharedGCSClient(); err == nil {
+				signed, err := client.Bucket(getUploadBucketName()).SignedURL(normalizeStoredObjectPath(path), &storage.SignedURLOptions{
+					Scheme:  storage.SigningSchemeV4,
+					Method:  http.MethodGet,
+					Expires: expires,
+				})
+				if err == nil {
+					return signed
+				}
+			}
+		}
+	}
+
+	query := url.Values{}
+	if variant != "" {
+		query.Set("variant", variant)
+	}
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("signature", formFileSignature(file.ID, variant, expires.Unix()))
+	return fmt.Sprintf("/api/v1/form-files/%s/content?%s", file.ID, query.Encode())
+}
+
+// formFileView is a form file as returned to clients, with signed URLs
+type formFileView struct {
+	models.FormFile
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url,omitempty"`
+	URLExpiresAt time.Time `json:"url_expires_at"`
+}
+
+func newFormFileView(file models.FormFile, now time.Time) formFileView {
+	expires := now.Add(formFileURLTTL)
+	view := formFileView{FormFile: file, URL: formFileSignedURL(&file, "", expires), URLExpiresAt: expires}
+	if file.ThumbnailPath != "" {
+		view.ThumbnailURL = formFileSignedURL(&file, "thumbnail", expires)
+	}
+	return view
+}
+
+// formFileThumbnail scales an image to fit formFileThumbnailSize, averaging the source
+// pixels each thumbnail pixel covers, and encodes it as JPEG
+func formFileThumbnail(data []byte) (thumbnail []byte, width, height int, err error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if cfg.Width*cfg.Height > formFileMaxThumbnailPixels {
+		return nil, cfg.Width, cfg.Height, fmt.Errorf("image of %dx%d is too large for a thumbnail", cfg.Width, cfg.Height)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, cfg.Width, cfg.Height, err
+	}
+
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	tw, th := w, h
+	if w > formFileThumbnailSize || h > formFileThumbnailSize {
+		if w >= h {
+			tw, th = formFileThumbnailSize, max(1, h*formFileThumbnailSize/w)
+		} else {
+			tw, th = max(1, w*formFileThumbnailSize/h), formFileThumbnailSize
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := bounds.Min.Y+y*h/th, bounds.Min.Y+max((y+1)*h/th, y*h/th+1)
+		for x := 0; x < tw; x++ {
+			x0, x1 := bounds.Min.X+x*w/tw, bounds.Min.X+max((x+1)*w/tw, x*w/tw+1)
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a, n = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, w, h, err
+	}
+	return buf.Bytes(), w, h, nil
+}
+
+// UploadFormFieldFile uploads a file for a file, image or camera field of a form. The
+// multipart request carries the file as "file", and business_vertical_id or
+// business_code (either may be left out when the caller can write in only one vertical)
+// and site_id as values. The file is checked against the field's accept list and
+// maxSizeMB (default 25), stored in upload storage and recorded; images also get a
+// thumbnail. The returned file ID is the value to submit for the field.
+// POST /api/v1/forms/{code}/fields/{field}/files
+func UploadFormFieldFile(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var form models.AppForm
+	if err := middleware.ScopedDB(r).Where("code = ? AND is_active = ?", mux.Vars(r)["code"], true).First(&form).Error; err != nil {
+		http.Error(w, "form not found", http.StatusNotFound)
+		return
+	}
+	field, ok := lookupFormFileField(&form, mux.Vars(r)["field"])
+	if !ok {
+		http.Error(w, "form has no file field "+mux.Vars(r)["field"], http.StatusNotFound)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, field.MaxBytes+(1<<20))
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, "invalid upload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "missing file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	if header.Size > field.MaxBytes {
+		http.Error(w, fmt.Sprintf("file exceeds the field's %d MB limit", field.MaxBytes>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// The vertical and site come as multipart values
+	query := r.URL.Query()
+	for _, key := range []string{"business_vertical_id", "business_code"} {
+		if value := r.FormValue(key); value != "" {
+			query.Set(key, value)
+		}
+	}
+	r.URL.RawQuery = query.Encode()
+	var siteID *uuid.UUID
+	if raw := r.FormValue("site_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			http.Error(w, "invalid site_id", http.StatusBadRequest)
+			return
+		}
+		siteID = &id
+	}
+	verticals, err := formRecordVerticals(r, &form, true)
+	if err != nil {
+		log.Printf("❌ Failed to resolve verticals for form %s: %v", form.Code, err)
+		http.Error(w, "failed to upload file", http.StatusInternalServerError)
+		return
+	}
+	switch {
+	case len(verticals) == 0:
+		http.Error(w, "no permission to submit this form", http.StatusForbidden)
+		return
+	case len(verticals) > 1:
+		http.Error(w, "business_vertical_id or business_code is required", http.StatusBadRequest)
+		return
+	}
+	if !middleware.InDataScope(r, verticals[0], siteID) {
+		http.Error(w, "no access to this site", http.StatusForbidden)
+		return
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, "failed to read upload", http.StatusBadRequest)
+		return
+	}
+	mimeType := header.Header.Get("Content-Type")
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType = http.DetectContentType(data)
+	}
+	if i := strings.Index(mimeType, ";"); i >= 0 {
+		mimeType = strings.TrimSpace(mimeType[:i])
+	}
+	if !field.accepts(header.Filename, mimeType) {
+		http.Error(w, fmt.Sprintf("field %s does not accept %s files", field.Name, mimeType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	dir := fmt.Sprintf("./uploads/forms/%s", unsafeExportFileChars.ReplaceAllString(form.Code, "_"))
+	stored, err := storeUploadReader(bytes.NewReader(data), header.Filename, mimeType, dir)
+	if err != nil {
+		log.Printf("❌ Failed to store file for %s.%s: %v", form.Code, field.Name, err)
+		http.Error(w, "failed to store file", http.StatusInternalServerError)
+		return
+	}
+	hash := sha256.Sum256(data)
+	record := models.FormFile{
+		FormID:             form.ID,
+		FormCode:           form.Code,
+		FieldName:          field.Name,
+		BusinessVerticalID: verticals[0],
+		SiteID:             siteID,
+		FileName:           header.Filename,
+		FileType:           mimeType,
+		FileSize:           stored.Size,
+		FileHash:           hex.EncodeToString(hash[:]),
+		FilePath:           stored.Path,
+		UploadedBy:         claims.UserID,
+	}
+
+	if record.IsImage() {
+		thumbnail, width, height, err := formFileThumbnail(data)
+		record.Width, record.Height = width, height
+		if err != nil {
+			// The upload stands without a thumbnail
+			log.Printf("⚠️  No thumbnail for %s: %v", header.Filename, err)
+		} else if thumb, err := storeUploadReader(bytes.NewReader(thumbnail), "thumbnail.jpg", "image/jpeg", dir+"/thumbnails"); err != nil {
+			log.Printf("⚠️  Failed to store thumbnail for %s: %v", header.Filename, err)
+		} else {
+			record.ThumbnailPath = thumb.Path
+		}
+	}
+
+	if err := middleware.ScopedDB(r).Create(&record).Error; err != nil {
+		http.Error(w, "failed to record file", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"file": newFormFileView(record, time.Now())})
+}
+
+// GetFormFile returns a form file with fresh signed URLs, for callers who may read the
+// form in the file's vertical
+// GET /api/v1/forms/{code}/files/{id}
+func GetFormFile(w http.ResponseWriter, r *http.Request) {
+	if middleware.GetClaims(r) == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	vars := mux.Vars(r)
+	fileID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "invalid file ID", http.StatusBadRequest)
+		return
+	}
+	var form models.AppForm
+	if err := middleware.ScopedDB(r).Where("code = ?", vars["code"]).First(&form).Error; err != nil {
+		http.Error(w, "form not found", http.StatusNotFound)
+		return
+	}
+	var file models.FormFile
+	if err := middleware.ScopedDB(r).Where("id = ? AND form_id = ?", fileID, form.ID).First(&file).Error; err != nil {
+		http.Error(w, "file not found", http.StatusNotFound)
+		return
+	}
+	verticals, err := formRecordVerticals(r, &form, false)
+	if err != nil {
+		http.Error(w, "failed to load file", http.StatusInternalServerError)
+		return
+	}
+	if !containsVertical(verticals, file.BusinessVerticalID) || !middleware.InDataScope(r, file.BusinessVerticalID, file.SiteID) {
+		http.Error(w, "file not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"file": newFormFileView(file, time.Now())})
+}
+
+// ServeFormFileContent serves a form file, or with ?variant=thumbnail its thumbnail, to
+// holders of a signed URL. It needs no login, so URLs work in image tags and downloads.
+// GET /api/v1/form-files/{id}/content
+func ServeFormFileContent(w http.ResponseWriter, r *http.Request) {
+	fileID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid file ID", http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query()
+	variant := query.Get("variant")
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || !hmac.Equal([]byte(query.Get("signature")), []byte(formFileSignature(fileID, variant, expires))) {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+	if time.Now().Unix() > expires {
+		http.Error(w, "link has expired", http.StatusGone)
+		return
+	}
+
+	var file models.FormFile
+	if err := middleware.ScopedDB(r).First(&file, "id = ?", fileID).Error; err != nil {
+		http.Error(w, "file not found", http.StatusNotFound)
+		return
+	}
+	path, name, fileType, size := file.FilePath, file.FileName, file.FileType, file.FileSize
+	if variant == "thumbnail" {
+		path, name, fileType, size = file.ThumbnailPath, "thumbnail-"+strings.TrimSuffix(file.FileName, filepath.Ext(file.FileName))+".jpg", "image/jpeg", 0
+	}
+
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	if err := serveStoredFile(w, r, path, name, fileType, size); err != nil {
+		if err == errStoredFileNotFound {
+			http.Error(w, "file not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to serve file", http.StatusInternalServerError)
+	}
+}
+
+// formFileValues returns the file IDs that data gives the form's file fields, by field.
+// Values that are not IDs are paths stored before uploads were recorded and are left alone.
+func formFileValues(fields []formvalidation.Field, data map[string]interface{}) map[string]uuid.UUID {
+	values := make(map[string]uuid.UUID)
+	for _, field := range fields {
+		if !isFormFileType(field.Type) {
+			continue
+		}
+		value, ok := data[field.Name]
+		if !ok {
+			// Records read back from a dedicated table are keyed by column
+			value = data[formColumnName(field.Name)]
+		}
+		if id, ok := columnUUID(value); ok {
+			values[field.Name] = id
+		}
+	}
+	return values
+}
+
+// formFileReferenceErrors reports file field values naming files that were not uploaded
+// for that field of the form
+func formFileReferenceErrors(form *models.AppForm, fields []formvalidation.Field, data map[string]interface{}) (formvalidation.Errors, error) {
+	values := formFileValues(fields, data)
+	if len(values) == 0 {
+		return nil, nil
+	}
+	ids := make([]uuid.UUID, 0, len(values))
+	for _, id := range values {
+		ids = append(ids, id)
+	}
+	var files []models.FormFile
+	if err := config.DB.Select("id", "field_name").Where("id IN ? AND form_id = ?", ids, form.ID).Find(&files).Error; err != nil {
+		return nil, fmt.Errorf("failed to check uploaded files: %w", err)
+	}
+	uploaded := make(map[uuid.UUID]string, len(files))
+	for _, file := range files {
+		uploaded[file.ID] = file.FieldName
+	}
+
+	var errs formvalidation.Errors
+	for _, field := range fields {
+		id, ok := values[field.Name]
+		if !ok {
+			continue
+		}
+		if fieldName, found := uploaded[id]; !found || fieldName != formColumnName(field.Name) {
+			errs = append(errs, formvalidation.FieldError{
+				Field:   field.Name,
+				Code:    "file",
+				Message: fmt.Sprintf("%s is not a file uploaded for this field", field.Name),
+			})
+		}
+	}
+	return errs, nil
+}
+
+// linkFormFiles records recordID on the files data's file fields reference, so each
+// upload knows the record it belongs to. Failures are logged; the record stands.
+func linkFormFiles(form *models.AppForm, recordID uuid.UUID, data map[string]interface{}) {
+	rules := formvalidation.FromForm(form.FormSchema, form.Steps, nil)
+	values := formFileValues(rules.Fields, data)
+	if len(values) == 0 {
+		return
+	}
+	ids := make([]uuid.UUID, 0, len(values))
+	for _, id := range values {
+		ids = append(ids, id)
+	}
+	if err := config.DB.Model(&models.FormFile{}).
+		Where("id IN ? AND form_id = ? AND record_id IS NULL", ids, form.ID).
+		Update("record_id", recordID).Error; err != nil {
+		log.Printf("⚠️  Failed to link files to record %s: %v", recordID, err)
+	}
+}
+
+// attachFormRecordFiles adds to each record a "files" object with the files its file
+// fields reference, by field. Records must not have been through formRecordJSON yet.
+func attachFormRecordFiles(form *models.AppForm, records []map[string]interface{}) {
+	files, err := formRecordFiles(form, records)
+	if err != nil {
+		log.Printf("⚠️  Failed to load files of %s records: %v", form.Code, err)
+		return
+	}
+	for _, record := range records {
+		recordID, _ := columnUUID(record["id"])
+		if recordFiles, ok := files[recordID]; ok {
+			record["files"] = recordFiles
+		}
+	}
+}
+
+// formRecordFiles loads the files the records' file fields reference, with signed URLs,
+// keyed by record ID and then field
+func formRecordFiles(form *models.AppForm, records []map[string]interface{}) (map[uuid.UUID]map[string]formFileView, error) {
+	rules := formvalidation.FromForm(form.FormSchema, form.Steps, nil)
+	byRecord := make(map[uuid.UUID]map[string]uuid.UUID)
+	var ids []uuid.UUID
+	for _, record := range records {
+		recordID, _ := columnUUID(record["id"])
+		values := formFileValues(rules.Fields, record)
+		if len(values) == 0 {
+			continue
+		}
+		byRecord[recordID] = values
+		for _, id := range values {
+			ids = append(ids, id)
+		}
+	}
+	result := make(map[uuid.UUID]map[string]formFileView)
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	var files []models.FormFile
+	if err := config.DB.Where("id IN ? AND form_id = ?", ids, form.ID).Find(&files).Error; err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	views := make(map[uuid.UUID]formFileView, len(files))
+	for _, file := range files {
+		views[file.ID] = newFormFileView(file, now)
+	}
+	for recordID, values := range byRecord {
+		for field, id := range values {
+			if view, ok := views[id]; ok {
+				if result[recordID] == nil {
+					result[recordID] = make(map[string]formFileView)
+				}
+				result[recordID][field] = view
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/google/uuid"
+	"p9e.in/ugcl/models"
+)
+
+func TestLookupFormFileField(t *testing.T) {
+	form := &models.AppForm{FormSchema: json.RawMessage(`{"fields":[
+		{"name":"Site Photo","type":"camera","maxSizeMB":2},
+		{"name":"invoice","type":"file","accept":".pdf,image/*"},
+		{"name":"vendor","type":"text"}]}`)}
+
+	photo, ok := lookupFormFileField(form, "site_photo")
+	if !ok || photo.Name != "site_photo" || photo.MaxBytes != 2<<20 || !photo.imagesOnly() {
+		t.Fatalf("got %+v, %v", photo, ok)
+	}
+	if photo.accepts("scan.pdf", "application/pdf") || !photo.accepts("p.jpg", "image/jpeg") {
+		t.Error("camera field should take images only")
+	}
+
+	invoice, ok := lookupFormFileField(form, "invoice")
+	if !ok || invoice.MaxBytes != formFileDefaultMaxSizeMB<<20 {
+		t.Fatalf("got %+v, %v", invoice, ok)
+	}
+	for name, want := range map[string]bool{"a.PDF": true, "a.png": true, "a.docx": false} {
+		mime := "application/octet-stream"
+		if name == "a.png" {
+			mime = "image/png"
+		}
+		if got := invoice.accepts(name, mime); got != want {
+			t.Errorf("accepts(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	if _, ok := lookupFormFileField(form, "vendor"); ok {
+		t.Error("text field should not be a file field")
+	}
+}
+
+func TestFormFileSignature(t *testing.T) {
+	t.Setenv("FORM_FILE_URL_SECRET", "test-secret")
+	id := uuid.New()
+	sig := formFileSignature(id, "", 1000)
+	if sig != formFileSignature(id, "", 1000) {
+		t.Fatal("signature should be stable")
+	}
+	if sig == formFileSignature(id, "thumbnail", 1000) || sig == formFileSignature(id, "", 1001) ||
+		sig == formFileSignature(uuid.New(), "", 1000) {
+		t.Error("signature should cover the file, variant and expiry")
+	}
+}
+
+func TestFormFileThumbnail(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 1000, 500))
+	for y := 0; y < 500; y++ {
+		for x := 0; x < 1000; x++ {
+			src.Set(x, y, color.RGBA{R: 200, G: 100, B: 50, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+
+	thumb, width, height, err := formFileThumbnail(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if width != 1000 || height != 500 {
+		t.Errorf("got source size %dx%d", width, height)
+	}
+	decoded, format, err := image.Decode(bytes.NewReader(thumb))
+	if err != nil || format != "jpeg" {
+		t.Fatalf("thumbnail is not a JPEG: %v %s", err, format)
+	}
+	if b := decoded.Bounds(); b.Dx() != formFileThumbnailSize || b.Dy() != formFileThumbnailSize/2 {
+		t.Errorf("got thumbnail %dx%d", b.Dx(), b.Dy())
+	}
+
+	if _, _, _, err := formFileThumbnail([]byte("not an image")); err == nil {
+		t.Error("expected an error for non-image data")
+	}
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"gorm.io/gorm"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/formvalidation"
+)
+
+// formGeoColumnSuffix names the generated PostGIS column kept beside each geopoint field's
+// JSON column, so "reading_location" is mapped as "reading_location__geom". Like the
+// search column it is not a form field: column listings leave it out, and record
+// responses drop it.
+const formGeoColumnSuffix = "__geom"
+
+// isFormDerivedColumn reports whether a form table column is generated from the field
+// columns rather than being a field itself
+func isFormDerivedColumn(name string) bool {
+	return name == formSearchColumn || strings.HasSuffix(name, formGeoColumnSuffix)
+}
+
+// formGeoPointColumns returns the columns of the form's geopoint fields
+func formGeoPointColumns(form *models.AppForm) []string {
+	var columns []string
+	for _, field := range formvalidation.FromForm(form.FormSchema, form.Steps, nil).Fields {
+		if field.Type == "geopoint" {
+			columns = append(columns, formColumnName(field.Name))
+		}
+	}
+	return columns
+}
+
+// formGeoPointSQL is the PostGIS point of a geopoint column's {"latitude", "longitude"}
+// value, NULL when the field is empty
+func formGeoPointSQL(quotedColumn string) string {
+	return fmt.Sprintf("ST_SetSRID(ST_MakePoint((%[1]s->>'longitude')::double precision, (%[1]s->>'latitude')::double precision), 4326)", quotedColumn)
+}
+
+// ensureGeoColumns rebuilds the generated geometry columns of the form table's geopoint
+// columns, with a GiST index each, so records can be mapped and queried spatially. As with
+// the search column, existing ones are dropped and added again, so columns of fields that
+// were removed or retyped go too.
+func (ftm *FormTableManager) ensureGeoColumns(schemaName, tableName string, columns []string) error {
+	fullTableName, err := ftm.qualifiedTableName(schemaName, tableName)
+	if err != nil {
+		return err
+	}
+	if schemaName == "" {
+		schemaName = "public"
+	}
+	return ftm.db.Transaction(func(tx *gorm.DB) error {
+		var existing []string
+		if err := tx.Raw(`SELECT column_name FROM information_schema.columns WHERE table_schema = ? AND table_name = ? AND column_name LIKE ?`,
+			strings.ToLower(schemaName), strings.ToLower(tableName), `%\_\_geom`).Scan(&existing).Error; err != nil {
+			return fmt.Errorf("failed to read columns of %s: %v", tableName, err)
+		}
+		for _, name := range existing {
+			quoted, err := formIdentifier(name)
+			if err != nil {
+				continue
+			}
+			if err := tx.Exec(fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS %s", fullTableName, quoted)).Error; err != nil {
+				return fmt.Errorf("failed to drop geometry column of %s: %v", tableName, err)
+			}
+		}
+
+		columnTypes, err := ftm.withDB(tx).tableColumnTypes(schemaName, tableName)
+		if err != nil {
+			return err
+		}
+		indexPrefix := strings.ReplaceAll(strings.ReplaceAll(fullTableName, `"`, ""), ".", "_")
+		for _, column := range columns {
+			if columnTypes[column] != "jsonb" {
+				continue
+			}
+			quoted, err := formIdentifier(column)
+			if err != nil {
+				continue
+			}
+			geometry, err := formIdentifier(column + formGeoColumnSuffix)
+			if err != nil {
+				log.Printf("⚠️  Field %s of %s is too long a name for a geometry column", column, tableName)
+				continue
+			}
+			if err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s geometry(Point,4326) GENERATED ALWAYS AS (%s) STORED",
+				fullTableName, geometry, formGeoPointSQL(quoted))).Error; err != nil {
+				return fmt.Errorf("failed to add geometry column for %s to %s: %v", column, tableName, err)
+			}
+			if err := tx.Exec(fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "idx_%s_%s_geo" ON %s USING GIST (%s)`,
+				indexPrefix, column, fullTableName, geometry)).Error; err != nil {
+				return fmt.Errorf("failed to index geometry column for %s of %s: %v", column, tableName, err)
+			}
+		}
+		return nil
+	})
+}
+
+// formZoneBoundarySQL selects a zone's boundary
+const formZoneBoundarySQL = `SELECT ` + zoneBoundarySQL + ` AS boundary FROM zones WHERE id = ? AND deleted_at IS NULL`
+
+// formGeofenceErrors checks the geopoint values in data against their fields' geofences:
+// each point must fall inside its project zone's boundary, widened by the rule's
+// tolerance. A point whose zone is not given fails, except in a partial check, as drafts
+// may pick the zone later. data holds validated values.
+func formGeofenceErrors(fields []formvalidation.Field, data map[string]interface{}, partial bool) (formvalidation.Errors, error) {
+	var errs formvalidation.Errors
+	for _, field := range fields {
+		if field.Type != "geopoint" || field.Geofence == nil {
+			continue
+		}
+		point, ok := formvalidation.ParseGeoPoint(data[field.Name])
+		if !ok {
+			continue
+		}
+		label := field.Label
+		if label == "" {
+			label = field.Name
+		}
+		fail := func(format string, args ...interface{}) {
+			errs = append(errs, formvalidation.FieldError{Field: field.Name, Code: "geofence", Message: fmt.Sprintf(format, args...)})
+		}
+
+		zone := field.Geofence.ZoneID
+		if field.Geofence.ZoneField != "" {
+			zone = ""
+			if value, ok := data[field.Geofence.ZoneField]; ok && value != nil {
+				zone = fmt.Sprint(value)
+			}
+		}
+		zoneID, ok := columnUUID(zone)
+		if !ok {
+			if !partial {
+				fail("%s cannot be checked without a zone", label)
+			}
+			continue
+		}
+
+		var result []struct {
+			Bounded bool
+			Inside  *bool
+		}
+		if err := config.DB.Raw(`SELECT b.boundary IS NOT NULL AS bounded,
+				ST_DWithin(b.boundary::geography, ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography, ?) AS inside
+			FROM (`+formZoneBoundarySQL+`) b`,
+			point.Longitude, point.Latitude, field.Geofence.Tolerance, zoneID).Scan(&result).Error; err != nil {
+			return nil, fmt.Errorf("failed to check %s against zone %s: %w", field.Name, zoneID, err)
+		}
+		switch {
+		case len(result) == 0:
+			fail("zone %s for %s was not found", zoneID, label)
+		case !result[0].Bounded:
+			fail("zone %s has no boundary to check %s against", zoneID, label)
+		case result[0].Inside == nil || !*result[0].Inside:
+			fail("%s is outside the zone boundary", label)
+		}
+	}
+	return errs, nil
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"p9e.in/ugcl/pkg/formvalidation"
+)
+
+func TestIsFormDerivedColumn(t *testing.T) {
+	for name, want := range map[string]bool{
+		"search_vector":          true,
+		"reading_location__geom": true,
+		"reading_location":       false,
+		"pipe_geom":              false,
+		"current_state":          false,
+	} {
+		if got := isFormDerivedColumn(name); got != want {
+			t.Errorf("isFormDerivedColumn(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	record := formRecordJSON(map[string]interface{}{
+		"id":                     "x",
+		"search_vector":          "'pump':1",
+		"reading_location__geom": "0101000020E6100000",
+		"reading_location":       []byte(`{"latitude": 1, "longitude": 2}`),
+	})
+	if _, ok := record["reading_location__geom"]; ok {
+		t.Error("geometry column kept in the record")
+	}
+	if _, ok := record["search_vector"]; ok {
+		t.Error("search column kept in the record")
+	}
+	if _, ok := record["reading_location"].(json.RawMessage); !ok {
+		t.Errorf("location not returned as JSON: %#v", record["reading_location"])
+	}
+}
+
+func TestGeoPointColumn(t *testing.T) {
+	column, ok := formFieldColumn(map[string]interface{}{"name": "Reading Location", "type": "geopoint"})
+	if !ok || column.Name != "reading_location" || column.Type != "JSONB" {
+		t.Errorf("formFieldColumn = %+v, %v", column, ok)
+	}
+}
+
+func TestFormGeofenceErrorsWithoutZone(t *testing.T) {
+	fields := formvalidation.FromForm(json.RawMessage(`{"fields": [
+	  {"name": "zone_id", "type": "text"},
+	  {"name": "location", "label": "Location", "type": "geopoint",
+	   "geofence": {"zone_field": "zone_id"}}
+	]}`), nil, nil).Fields
+	data := map[string]interface{}{"location": map[string]interface{}{"latitude": 12.9, "longitude": 77.6}}
+
+	errs, err := formGeofenceErrors(fields, data, true)
+	if err != nil || len(errs) > 0 {
+		t.Errorf("partial check without a zone: %v, %v", errs, err)
+	}
+	errs, err = formGeofenceErrors(fields, data, false)
+	if err != nil || len(errs) != 1 || errs[0].Code != "geofence" || errs[0].Field != "location" {
+		t.Errorf("full check without a zone: %v, %v", errs, err)
+	}
+
+	data["zone_id"] = "not-a-zone"
+	if errs, _ := formGeofenceErrors(fields, data, false); len(errs) != 1 {
+		t.Errorf("invalid zone ID: %v", errs)
+	}
+}
@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+const (
+	// defaultFormAnalyticsDays is the period analytics cover when no range is given
+	defaultFormAnalyticsDays = 30
+	// maxFormAnalyticsDays caps the period of one analytics request
+	maxFormAnalyticsDays = 366
+)
+
+// formAnalyticsRange reads ?from= and ?to= (dates, both included), defaulting to the 30
+// days up to today. It returns the start of from and the start of the day after to.
+func formAnalyticsRange(rawFrom, rawTo string, today time.Time) (time.Time, time.Time, error) {
+	to := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	if rawTo != "" {
+		parsed, err := time.Parse("2006-01-02", rawTo)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("to must be a date (YYYY-MM-DD)")
+		}
+		to = parsed
+	}
+	from := to.AddDate(0, 0, -(defaultFormAnalyticsDays - 1))
+	if rawFrom != "" {
+		parsed, err := time.Parse("2006-01-02", rawFrom)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("from must be a date (YYYY-MM-DD)")
+		}
+		from = parsed
+	}
+	end := to.AddDate(0, 0, 1)
+	if !from.Before(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from must not be after to")
+	}
+	if end.Sub(from) > maxFormAnalyticsDays*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("the range may cover at most %d days", maxFormAnalyticsDays)
+	}
+	return from, end, nil
+}
+
+// formAnalyticsDays lists every day from from up to end with its count, zero for days
+// without records, so charts get an unbroken series
+func formAnalyticsDays(from, end time.Time, counts map[string]int64) []map[string]interface{} {
+	var days []map[string]interface{}
+	for day := from; day.Before(end); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		days = append(days, map[string]interface{}{"date": date, "count": counts[date]})
+	}
+	return days
+}
+
+// formAnalyticsRate is part over whole, 0 when whole is 0
+func formAnalyticsRate(part, whole int64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) / float64(whole)
+}
+
+// GetFormAnalytics summarizes a form's records created in a period (?from= and ?to=,
+// the last 30 days by default) across the verticals the caller may read: submissions per
+// day and per site, how many records are in and have passed through each workflow state,
+// how long records take from creation to ?approved_state= (default approved) and how
+// often they reach ?rejected_state= (default rejected). Drafts count towards states only.
+// GET /api/v1/forms/{code}/analytics
+func GetFormAnalytics(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	form := loadRecordsForm(w, r)
+	if form == nil {
+		return
+	}
+
+	values := r.URL.Query()
+	from, end, err := formAnalyticsRange(values.Get("from"), values.Get("to"), time.Now().UTC())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	approvedState := strings.TrimSpace(values.Get("approved_state"))
+	if approvedState == "" {
+		approvedState = "approved"
+	}
+	rejectedState := strings.TrimSpace(values.Get("rejected_state"))
+	if rejectedState == "" {
+		rejectedState = "rejected"
+	}
+
+	// State names and their order come from the form's workflow
+	var states []models.WorkflowState
+	if form.WorkflowID != nil {
+		var workflow models.WorkflowDefinition
+		if err := middleware.ScopedDB(r).First(&workflow, "id = ?", *form.WorkflowID).Error; err == nil {
+			states, _ = workflow.ParseStates()
+		}
+	}
+
+	response := map[string]interface{}{
+		"form_code":  form.Code,
+		"from":       from.Format("2006-01-02"),
+		"to":         end.AddDate(0, 0, -1).Format("2006-01-02"),
+		"totals":     map[string]int64{"records": 0, "submitted": 0},
+		"per_day":    formAnalyticsDays(from, end, nil),
+		"per_site":   []map[string]interface{}{},
+		"states":     []map[string]interface{}{},
+		"completion": map[string]interface{}{"state": approvedState, "completed": 0, "average_hours": nil, "median_hours": nil},
+		"rejection":  map[string]interface{}{"state": rejectedState, "decided": 0, "rejected": 0, "rate": 0},
+	}
+
+	tableManager := NewFormTableManager()
+	table, err := tableManager.qualifiedTableName("", form.DBTableName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	columnTypes, err := tableManager.tableColumnTypes("", form.DBTableName)
+	if err != nil {
+		log.Printf("❌ Failed to read columns of %s: %v", form.DBTableName, err)
+		http.Error(w, "failed to load analytics", http.StatusInternalServerError)
+		return
+	}
+	if len(columnTypes) == 0 {
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+	verticals, err := formRecordVerticals(r, form, false)
+	if err != nil {
+		log.Printf("❌ Failed to resolve verticals for form %s: %v", form.Code, err)
+		http.Error(w, "failed to load analytics", http.StatusInternalServerError)
+		return
+	}
+	if len(verticals) == 0 {
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+
+	// Every figure is computed over the same scoped set of records
+	base := &formRecordQuery{columnTypes: columnTypes}
+	base.scope(r, verticals, claims.UserID)
+	where := append([]string{
+		"deleted_at IS NULL",
+		"created_at >= " + base.arg(from),
+		"created_at < " + base.arg(end),
+	}, base.where...)
+	records := fmt.Sprintf("WITH records AS (SELECT id, created_at, site_id, current_state FROM %s WHERE %s) ",
+		table, strings.Join(where, " AND "))
+	query := func(dest interface{}, body string, args ...interface{}) error {
+		q := *base
+		q.args = append([]interface{}(nil), base.args...)
+		placeholders := make([]interface{}, len(args))
+		for i, arg := range args {
+			placeholders[i] = q.arg(arg)
+		}
+		return middleware.WithDataScope(r, tableManager.db).Raw(records+fmt.Sprintf(body, placeholders...), q.args...).Scan(dest).Error
+	}
+
+	var byState []struct {
+		State string
+		Count int64
+	}
+	var reached []struct {
+		State string
+		Count int64
+	}
+	var byDay []struct {
+		Day   time.Time
+		Count int64
+	}
+	var bySite []struct {
+		SiteID   *uuid.UUID
+		SiteName *string
+		Count    int64
+	}
+	var completion struct {
+		Completed    int64
+		AverageHours *float64
+		MedianHours  *float64
+	}
+	var rejection struct {
+		Decided  int64
+		Rejected int64
+	}
+	err = query(&byState, "SELECT current_state AS state, COUNT(*) AS count FROM records GROUP BY current_state")
+	if err == nil {
+		err = query(&reached, `SELECT t.to_state AS state, COUNT(DISTINCT t.submission_id) AS count
+			FROM workflow_transitions t JOIN records r ON r.id = t.submission_id GROUP BY t.to_state`)
+	}
+	if err == nil {
+		err = query(&byDay, "SELECT created_at::date AS day, COUNT(*) AS count FROM records WHERE current_state <> %s GROUP BY 1 ORDER BY 1", formDraftState)
+	}
+	if err == nil {
+		err = query(&bySite, `SELECT r.site_id, s.name AS site_name, COUNT(*) AS count
+			FROM records r LEFT JOIN sites s ON s.id = r.site_id
+			WHERE r.current_state <> %s GROUP BY r.site_id, s.name ORDER BY count DESC`, formDraftState)
+	}
+	if err == nil {
+		err = query(&completion, `SELECT COUNT(*) AS completed,
+				AVG(EXTRACT(EPOCH FROM a.approved_at - r.created_at)) / 3600 AS average_hours,
+				percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM a.approved_at - r.created_at)) / 3600 AS median_hours
+			FROM records r JOIN (
+				SELECT submission_id, MIN(transitioned_at) AS approved_at FROM workflow_transitions
+				WHERE to_state = %s GROUP BY submission_id
+			) a ON a.submission_id = r.id`, approvedState)
+	}
+	if err == nil {
+		err = query(&rejection, `SELECT COUNT(DISTINCT t.submission_id) AS decided,
+				COUNT(DISTINCT t.submission_id) FILTER (WHERE t.to_state = %s) AS rejected
+			FROM workflow_transitions t JOIN records r ON r.id = t.submission_id
+			WHERE t.to_state IN (%s, %s)`, rejectedState, approvedState, rejectedState)
+	}
+	if err != nil {
+		log.Printf("❌ Failed to compute analytics of %s: %v", form.Code, err)
+		http.Error(w, "failed to load analytics", http.StatusInternalServerError)
+		return
+	}
+
+	var total, submitted int64
+	current := make(map[string]int64, len(byState))
+	for _, row := range byState {
+		current[row.State] = row.Count
+		total += row.Count
+		if row.State != formDraftState {
+			submitted += row.Count
+		}
+	}
+	passed := make(map[string]int64, len(reached))
+	for _, row := range reached {
+		passed[row.State] = row.Count
+	}
+
+	// Workflow states in order, then any other state records are in
+	stateRows := []map[string]interface{}{}
+	listed := make(map[string]bool)
+	addState := func(code, name string) {
+		if listed[code] {
+			return
+		}
+		listed[code] = true
+		stateRows = append(stateRows, map[string]interface{}{
+			"state":   code,
+			"name":    name,
+			"current": current[code],
+			"reached": passed[code],
+		})
+	}
+	for _, state := range states {
+		addState(state.Code, state.Name)
+	}
+	for _, row := range byState {
+		addState(row.State, row.State)
+	}
+
+	perDay := make(map[string]int64, len(byDay))
+	for _, row := range byDay {
+		perDay[row.Day.Format("2006-01-02")] = row.Count
+	}
+	perSite := make([]map[string]interface{}, len(bySite))
+	for i, row := range bySite {
+		perSite[i] = map[string]interface{}{"site_id": row.SiteID, "site_name": row.SiteName, "count": row.Count}
+	}
+
+	response["totals"] = map[string]int64{"records": total, "submitted": submitted}
+	response["per_day"] = formAnalyticsDays(from, end, perDay)
+	response["per_site"] = perSite
+	response["states"] = stateRows
+	response["completion"] = map[string]interface{}{
+		"state":         approvedState,
+		"completed":     completion.Completed,
+		"average_hours": completion.AverageHours,
+		"median_hours":  completion.MedianHours,
+	}
+	response["rejection"] = map[string]interface{}{
+		"state":    rejectedState,
+		"decided":  rejection.Decided,
+		"rejected": rejection.Rejected,
+		"rate":     formAnalyticsRate(rejection.Rejected, rejection.Decided),
+	}
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormAnalyticsRange(t *testing.T) {
+	today := time.Date(2026, 3, 15, 17, 30, 0, 0, time.UTC)
+
+	from, end, err := formAnalyticsRange("", "", today)
+	if err != nil {
+		t.Fatalf("default range: %v", err)
+	}
+	if want := time.Date(2026, 2, 14, 0, 0, 0, 0, time.UTC); !from.Equal(want) {
+		t.Errorf("default from = %v, want %v", from, want)
+	}
+	if want := time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC); !end.Equal(want) {
+		t.Errorf("default end = %v, want %v", end, want)
+	}
+
+	from, end, err = formAnalyticsRange("2026-01-01", "2026-01-01", today)
+	if err != nil {
+		t.Fatalf("single day: %v", err)
+	}
+	if end.Sub(from) != 24*time.Hour {
+		t.Errorf("single day covers %v, want 24h", end.Sub(from))
+	}
+
+	for _, c := range [][2]string{
+		{"2026-01-02", "2026-01-01"},
+		{"2024-01-01", "2026-01-01"},
+		{"01/01/2026", ""},
+		{"", "tomorrow"},
+	} {
+		if _, _, err := formAnalyticsRange(c[0], c[1], today); err == nil {
+			t.Errorf("formAnalyticsRange(%q, %q) succeeded, want an error", c[0], c[1])
+		}
+	}
+}
+
+func TestFormAnalyticsDays(t *testing.T) {
+	from := time.Date(2026, 2, 27, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	days := formAnalyticsDays(from, end, map[string]int64{"2026-02-28": 4})
+
+	want := []struct {
+		date  string
+		count int64
+	}{{"2026-02-27", 0}, {"2026-02-28", 4}, {"2026-03-01", 0}}
+	if len(days) != len(want) {
+		t.Fatalf("got %d days, want %d", len(days), len(want))
+	}
+	for i, w := range want {
+		if days[i]["date"] != w.date || days[i]["count"] != w.count {
+			t.Errorf("day %d = %v, want %s with %d", i, days[i], w.date, w.count)
+		}
+	}
+
+	if got := formAnalyticsRate(1, 4); got != 0.25 {
+		t.Errorf("formAnalyticsRate(1, 4) = %v, want 0.25", got)
+	}
+	if got := formAnalyticsRate(0, 0); got != 0 {
+		t.Errorf("formAnalyticsRate(0, 0) = %v, want 0", got)
+	}
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// formRecordRow runs a query for one record and returns its columns, or nil when no
+// record matches
+func formRecordRow(db *gorm.DB, sql string, args ...interface{}) (map[string]interface{}, error) {
+	rows, err := db.Raw(sql, args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	columns, _ := rows.Columns()
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+	if err := rows.Scan(valuePtrs...); err != nil {
+		return nil, err
+	}
+	record := make(map[string]interface{}, len(columns))
+	for i, column := range columns {
+		record[column] = values[i]
+	}
+	return record, nil
+}
+
+// formAuditValue renders a scanned column value as JSON for the audit trail, nil for NULL.
+// Values read the same way before and after an update render the same, so unchanged
+// fields compare equal.
+func formAuditValue(value interface{}) json.RawMessage {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		value = v.UTC().Format(time.RFC3339Nano)
+	case [16]byte:
+		value = uuid.UUID(v).String()
+	case []byte:
+		trimmed := bytes.TrimSpace(v)
+		if (bytes.HasPrefix(trimmed, []byte("{")) || bytes.HasPrefix(trimmed, []byte("["))) && json.Valid(trimmed) {
+			var compact bytes.Buffer
+			if json.Compact(&compact, trimmed) == nil {
+				return compact.Bytes()
+			}
+		}
+		value = string(v)
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		raw, _ = json.Marshal(fmt.Sprint(value))
+	}
+	return raw
+}
+
+// formRecordChanges compares a record's fields before and after an update and returns an
+// audit entry for each field whose value changed, all sharing one change ID
+func formRecordChanges(recordID uuid.UUID, before, after map[string]interface{}, fields []string, userID string) []models.FormRecordAudit {
+	formID, _ := columnUUID(before["form_id"])
+	changeID := uuid.New()
+	now := time.Now()
+
+	var changes []models.FormRecordAudit
+	for _, field := range fields {
+		column := formColumnName(field)
+		oldValue, newValue := formAuditValue(before[column]), formAuditValue(after[column])
+		if bytes.Equal(oldValue, newValue) {
+			continue
+		}
+		changes = append(changes, models.FormRecordAudit{
+			FormID:    formID,
+			FormCode:  columnString(before["form_code"]),
+			RecordID:  recordID,
+			ChangeID:  changeID,
+			Field:     column,
+			OldValue:  oldValue,
+			NewValue:  newValue,
+			ChangedBy: userID,
+			ChangedAt: now,
+		})
+	}
+	return changes
+}
+
+// auditedUpdate runs update on a record in a transaction, reading the given fields before
+// and after it and keeping those that changed in the record's audit trail. It returns how
+// many rows the update affected.
+func (ftm *FormTableManager) auditedUpdate(fullTableName string, recordID uuid.UUID, fields []string, userID string, update func(tx *gorm.DB) *gorm.DB) (int64, error) {
+	columns := []string{"form_id", "form_code"}
+	for _, field := range fields {
+		quoted, err := formIdentifier(formColumnName(field))
+		if err != nil {
+			return 0, err
+		}
+		columns = append(columns, quoted)
+	}
+	selectFields := fmt.Sprintf("SELECT %s FROM %s WHERE id = $1", strings.Join(columns, ", "), fullTableName)
+
+	var affected int64
+	err := ftm.db.Transaction(func(tx *gorm.DB) error {
+		before, err := formRecordRow(tx, selectFields+" FOR UPDATE", recordID)
+		if err != nil {
+			return err
+		}
+		result := update(tx)
+		if result.Error != nil {
+			return result.Error
+		}
+		affected = result.RowsAffected
+		if before == nil || affected == 0 || len(fields) == 0 {
+			return nil
+		}
+		after, err := formRecordRow(tx, selectFields, recordID)
+		if err != nil {
+			return err
+		}
+		if changes := formRecordChanges(recordID, before, after, fields, userID); len(changes) > 0 {
+			return tx.Create(&changes).Error
+		}
+		return nil
+	})
+	return affected, err
+}
+
+// GetFormRecordHistory returns the field-level change history of a record, newest first,
+// paged with limit and cursor. ?field= narrows it to one field.
+// GET /api/v1/forms/{code}/records/{id}/history
+func GetFormRecordHistory(w http.ResponseWriter, r *http.Request) {
+	if middleware.GetClaims(r) == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	form := loadRecordsForm(w, r)
+	if form == nil {
+		return
+	}
+	if loadFormRecord(w, r, form, false) == nil {
+		return
+	}
+	recordID, _ := uuid.Parse(mux.Vars(r)["id"])
+
+	limit, err := parseSubmissionPageSize(r.URL.Query().Get("limit"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cursor, err := decodeSubmissionsCursor(r.URL.Query().Get("cursor"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := middleware.ScopedDB(r).Where("form_id = ? AND record_id = ?", form.ID, recordID)
+	if field := strings.TrimSpace(r.URL.Query().Get("field")); field != "" {
+		query = query.Where("field = ?", formColumnName(field))
+	}
+	if cursor != nil {
+		query = query.Where("(changed_at < ? OR (changed_at = ? AND id < ?))", cursor.Timestamp, cursor.Timestamp, cursor.ID)
+	}
+	var history []models.FormRecordAudit
+	if err := query.Order("changed_at DESC, id DESC").Limit(limit + 1).Find(&history).Error; err != nil {
+		log.Printf("❌ Failed to load history of record %s: %v", recordID, err)
+		http.Error(w, "failed to load record history", http.StatusInternalServerError)
+		return
+	}
+
+	hasMore := len(history) > limit
+	nextCursor := ""
+	if hasMore {
+		history = history[:limit]
+		last := history[len(history)-1]
+		nextCursor = encodeSubmissionsCursor(last.ChangedAt, last.ID)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"history":     history,
+		"count":       len(history),
+		"limit":       limit,
+		"has_more":    hasMore,
+		"next_cursor": nextCursor,
+	})
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestFormRecordChanges(t *testing.T) {
+	formID := uuid.New()
+	recordID := uuid.New()
+	when := time.Date(2026, 10, 1, 9, 30, 0, 0, time.UTC)
+	before := map[string]interface{}{
+		"form_id":    [16]byte(formID),
+		"form_code":  "site_visit",
+		"remarks":    "pending",
+		"visit_date": when,
+		"details":    []byte(`{"a": 1, "b": [1, 2]}`),
+		"amount":     nil,
+	}
+	after := map[string]interface{}{
+		"remarks":    "done",
+		"visit_date": when,
+		"details":    []byte(`{"a":1,"b":[1,2]}`),
+		"amount":     "12.50",
+	}
+
+	changes := formRecordChanges(recordID, before, after, []string{"Remarks", "visit_date", "details", "amount"}, "user-1")
+	if len(changes) != 2 {
+		t.Fatalf("got %d changes, want 2: %+v", len(changes), changes)
+	}
+	remarks, amount := changes[0], changes[1]
+	if remarks.Field != "remarks" || string(remarks.OldValue) != `"pending"` || string(remarks.NewValue) != `"done"` {
+		t.Errorf("remarks change = %s %s -> %s", remarks.Field, remarks.OldValue, remarks.NewValue)
+	}
+	if amount.Field != "amount" || amount.OldValue != nil || string(amount.NewValue) != `"12.50"` {
+		t.Errorf("amount change = %s %s -> %s", amount.Field, amount.OldValue, amount.NewValue)
+	}
+	for _, change := range changes {
+		if change.FormID != formID || change.FormCode != "site_visit" || change.RecordID != recordID || change.ChangedBy != "user-1" {
+			t.Errorf("change %s has wrong context: %+v", change.Field, change)
+		}
+		if change.ChangeID != changes[0].ChangeID {
+			t.Errorf("changes of one update have different change IDs")
+		}
+	}
+}
@@ -0,0 +1,517 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+const (
+	// formRecordExportSyncLimit is the most records an export streams in the request;
+	// larger exports run in the background
+	formRecordExportSyncLimit = 10000
+	// formRecordExportRetention is how long a background export stays downloadable
+	formRecordExportRetention = 7 * 24 * time.Hour
+	formRecordExportDir       = "./uploads/exports"
+	formRecordExportSheet     = "Records"
+)
+
+// formRecordBaseHeaders name the base columns of form tables in export headers
+var formRecordBaseHeaders = map[string]string{
+	"id":                   "ID",
+	"created_by":           "Created By",
+	"created_at":           "Created At",
+	"updated_by":           "Updated By",
+	"updated_at":           "Updated At",
+	"business_vertical_id": "Business Vertical",
+	"site_id":              "Site",
+	"current_state":        "State",
+	"workflow_version":     "Workflow Version",
+}
+
+// formRecordExportDefaultColumns lead every export without a projection
+var formRecordExportDefaultColumns = []string{"id", "created_at", "created_by", "current_state", "site_id"}
+
+var unsafeExportFileChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// formRecordExportColumn is an exported column with its header
+type formRecordExportColumn struct {
+	Name   string
+	Header string
+}
+
+// formRecordExportColumns lists the exported columns: the projection when the request has
+// one, or else the record's identity and state followed by the form's fields in definition
+// order (all other columns, by name, for tables inferred from data). Field columns are
+// headed by their labels.
+func formRecordExportColumns(tableManager *FormTableManager, form *models.AppForm, q *formRecordQuery) ([]formRecordExportColumn, error) {
+	fields, err := tableManager.FormColumns(form)
+	if err != nil {
+		return nil, err
+	}
+	headers := make(map[string]string, len(formRecordBaseHeaders)+len(fields))
+	for name, header := range formRecordBaseHeaders {
+		headers[name] = header
+	}
+	for _, field := range fields {
+		if field.Label != "" {
+			headers[field.Name] = field.Label
+		}
+	}
+
+	names := q.fields
+	if len(names) == 0 {
+		for _, name := range formRecordExportDefaultColumns {
+			if _, ok := q.columnTypes[name]; ok {
+				names = append(names, name)
+			}
+		}
+		for _, field := range fields {
+			if _, ok := q.columnTypes[field.Name]; ok {
+				names = append(names, field.Name)
+			}
+		}
+		if len(fields) == 0 {
+			var other []string
+			for name := range q.columnTypes {
+				if !formTableBaseColumns[name] {
+					other = append(other, name)
+				}
+			}
+			sort.Strings(other)
+			names = append(names, other...)
+		}
+	}
+
+	columns := make([]formRecordExportColumn, len(names))
+	for i, name := range names {
+		header := headers[name]
+		if header == "" {
+			header = name
+		}
+		columns[i] = formRecordExportColumn{Name: name, Header: header}
+	}
+	return columns, nil
+}
+
+// formRecordExportValue converts a scanned value for a spreadsheet cell: numbers and
+// booleans stay typed, everything else becomes text
+func formRecordExportValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case int64, int32, int, float64, float32, bool:
+		return v
+	case time.Time:
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 && v.Nanosecond() == 0 {
+			return v.Format("2006-01-02")
+		}
+		return v.Format("2006-01-02 15:04:05")
+	}
+	return formRecordText(value)
+}
+
+// csvExportCell renders a cell for CSV. Text that a spreadsheet would run as a formula is
+// prefixed with a quote; negative numbers are left alone.
+func csvExportCell(value interface{}) string {
+	text := fmt.Sprint(value)
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		if _, err := strconv.ParseFloat(text, 64); err != nil {
+			return "'" + text
+		}
+	}
+	return text
+}
+
+// formRecordSheet writes export rows in one format
+type formRecordSheet interface {
+	writeRow(values []interface{}) error
+	close() error
+}
+
+type csvRecordSheet struct {
+	writer *csv.Writer
+}
+
+func (s *csvRecordSheet) writeRow(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		record[i] = csvExportCell(value)
+	}
+	return s.writer.Write(record)
+}
+
+func (s *csvRecordSheet) close() error {
+	s.writer.Flush()
+	return s.writer.Error()
+}
+
+// xlsxRecordSheet streams rows into the workbook, so large exports are not held as cells
+type xlsxRecordSheet struct {
+	out    io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+func newXLSXRecordSheet(out io.Writer) (*xlsxRecordSheet, error) {
+	file := excelize.NewFile()
+	if err := file.SetSheetName("Sheet1", formRecordExportSheet); err != nil {
+		return nil, err
+	}
+	stream, err := file.NewStreamWriter(formRecordExportSheet)
+	if err != nil {
+		return nil, err
+	}
+	return &xlsxRecordSheet{out: out, file: file, stream: stream}, nil
+}
+
+func (s *xlsxRecordSheet) writeRow(values []interface{}) error {
+	s.row++
+	cell, _ := excelize.CoordinatesToCellName(1, s.row)
+	return s.stream.SetRow(cell, values)
+}
+
+func (s *xlsxRecordSheet) close() error {
+	if err := s.stream.Flush(); err != nil {
+		return err
+	}
+	if err := s.file.Write(s.out); err != nil {
+		return err
+	}
+	return s.file.Close()
+}
+
+func newFormRecordSheet(format string, out io.Writer) (formRecordSheet, error) {
+	if format == "xlsx" {
+		return newXLSXRecordSheet(out)
+	}
+	return &csvRecordSheet{writer: csv.NewWriter(out)}, nil
+}
+
+// writeFormRecordExport runs the export query and writes a header row and its records to
+// out, returning how many records it wrote
+func writeFormRecordExport(db *gorm.DB, out io.Writer, format string, columns []formRecordExportColumn, sql string, args []interface{}) (int, error) {
+	sheet, err := newFormRecordSheet(format, out)
+	if err != nil {
+		return 0, err
+	}
+	header := make([]interface{}, len(columns))
+	for i, column := range columns {
+		header[i] = column.Header
+	}
+	if err := sheet.writeRow(header); err != nil {
+		return 0, err
+	}
+
+	rows, err := db.Raw(sql, args...).Rows()
+	if err != nil {
+		return 0, fmt.Errorf("failed to query records: %v", err)
+	}
+	defer rows.Close()
+
+	names, _ := rows.Columns()
+	position := make(map[string]int, len(names))
+	for i, name := range names {
+		position[name] = i
+	}
+	count := 0
+	for rows.Next() {
+		values := make([]interface{}, len(names))
+		valuePtrs := make([]interface{}, len(names))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return count, fmt.Errorf("failed to scan record: %v", err)
+		}
+		row := make([]interface{}, len(columns))
+		for i, column := range columns {
+			row[i] = formRecordExportValue(values[position[column.Name]])
+		}
+		if err := sheet.writeRow(row); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+	return count, sheet.close()
+}
+
+func formRecordExportContentType(format string) string {
+	if format == "xlsx" {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv"
+}
+
+// ExportFormRecords exports a form's records as CSV (?format=csv, the default) or Excel
+// (?format=xlsx), taking the list endpoint's filters, sort and fields. Records come from
+// the verticals where the caller may both read the form and export reports. Exports of up
+// to formRecordExportSyncLimit records stream in the response; larger ones, or any with
+// ?async=true, are built in the background and answered with 202 and the export to poll.
+// GET /api/v1/forms/{code}/records/export
+func ExportFormRecords(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	form := loadRecordsForm(w, r)
+	if form == nil {
+		return
+	}
+	format := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format")))
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "xlsx" {
+		http.Error(w, "format must be csv or xlsx", http.StatusBadRequest)
+		return
+	}
+
+	tableManager := NewFormTableManager()
+	table, err := tableManager.qualifiedTableName("", form.DBTableName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	columnTypes, err := tableManager.tableColumnTypes("", form.DBTableName)
+	if err != nil {
+		log.Printf("❌ Failed to read columns of %s: %v", form.DBTableName, err)
+		http.Error(w, "failed to export records", http.StatusInternalServerError)
+		return
+	}
+	if len(columnTypes) == 0 {
+		http.Error(w, "form has no records yet", http.StatusNotFound)
+		return
+	}
+
+	// Exports cover every match, not a page
+	values := r.URL.Query()
+	values.Del("limit")
+	values.Del("cursor")
+	query, err := parseFormRecordQuery(values, columnTypes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query.limit = 0
+
+	readable, err := formRecordVerticals(r, form, false)
+	if err != nil {
+		log.Printf("❌ Failed to resolve verticals for form %s: %v", form.Code, err)
+		http.Error(w, "failed to export records", http.StatusInternalServerError)
+		return
+	}
+	var verticals []uuid.UUID
+	for _, verticalID := range readable {
+		if hasWorkflowPermission(middleware.GetEffectivePermissionsInVertical(r, verticalID), "report:export") {
+			verticals = append(verticals, verticalID)
+		}
+	}
+	if len(verticals) == 0 {
+		http.Error(w, "no permission to export records of this form", http.StatusForbidden)
+		return
+	}
+	query.scope(r, verticals, claims.UserID)
+
+	columns, err := formRecordExportColumns(tableManager, form, query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	query.fields = make([]string, len(columns))
+	for i, column := range columns {
+		query.fields[i] = column.Name
+	}
+
+	countSQL, countArgs := query.countSQL(table, formRecordExportSyncLimit+1)
+	var count int64
+	if err := middleware.WithDataScope(r, tableManager.db).Raw(countSQL, countArgs...).Scan(&count).Error; err != nil {
+		log.Printf("❌ Failed to count records of %s: %v", form.Code, err)
+		http.Error(w, "failed to export records", http.StatusInternalServerError)
+		return
+	}
+
+	fileName := fmt.Sprintf("%s-%s.%s", unsafeExportFileChars.ReplaceAllString(form.Code, "_"), time.Now().Format("20060102-150405"), format)
+	sql := query.sql(table)
+
+	if count <= formRecordExportSyncLimit && r.URL.Query().Get("async") != "true" {
+		w.Header().Set("Content-Type", formRecordExportContentType(format))
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
+		if _, err := writeFormRecordExport(middleware.WithDataScope(r, tableManager.db), w, format, columns, sql, query.args); err != nil {
+			// Headers are gone by now; the truncated file is all the client gets
+			log.Printf("❌ Failed to export records of %s: %v", form.Code, err)
+		}
+		return
+	}
+
+	export := models.FormRecordExport{
+		FormID:      form.ID,
+		FormCode:    form.Code,
+		Format:      format,
+		Status:      models.FormRecordExportPending,
+		Query:       values.Encode(),
+		RequestedBy: claims.UserID,
+		FileName:    fileName,
+	}
+	if err := middleware.ScopedDB(r).Create(&export).Error; err != nil {
+		http.Error(w, "failed to start export", http.StatusInternalServerError)
+		return
+	}
+	go runFormRecordExport(export.ID, columns, sql, query.args)
+
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"export":       export,
+		"status_url":   fmt.Sprintf("/api/v1/forms/%s/records/exports/%s", form.Code, export.ID),
+		"download_url": fmt.Sprintf("/api/v1/forms/%s/records/exports/%s/download", form.Code, export.ID),
+	})
+}
+
+// runFormRecordExport builds a background export into its file, first removing the files
+// of exports that have expired
+func runFormRecordExport(exportID uuid.UUID, columns []formRecordExportColumn, sql string, args []interface{}) {
+	db := config.DB
+	fail := func(err error) {
+		log.Printf("❌ Form record export %s failed: %v", exportID, err)
+		db.Model(&models.FormRecordExport{}).Where("id = ?", exportID).Updates(map[string]interface{}{
+			"status":        models.FormRecordExportFailed,
+			"error_message": err.Error(),
+		})
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			fail(fmt.Errorf("panic: %v", r))
+		}
+	}()
+
+	purgeExpiredFormRecordExports(db, time.Now())
+
+	var export models.FormRecordExport
+	if err := db.First(&export, "id = ?", exportID).Error; err != nil {
+		log.Printf("❌ Form record export %s not found: %v", exportID, err)
+		return
+	}
+	startedAt := time.Now()
+	db.Model(&export).Updates(map[string]interface{}{"status": models.FormRecordExportRunning, "started_at": startedAt})
+
+	if err := os.MkdirAll(formRecordExportDir, 0755); err != nil {
+		fail(err)
+		return
+	}
+	path := filepath.Join(formRecordExportDir, fmt.Sprintf("%s.%s", export.ID, export.Format))
+	file, err := os.Create(path)
+	if err != nil {
+		fail(err)
+		return
+	}
+	count, err := writeFormRecordExport(db, file, export.Format, columns, sql, args)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		fail(err)
+		return
+	}
+
+	completedAt := time.Now()
+	expiresAt := completedAt.Add(formRecordExportRetention)
+	db.Model(&export).Updates(map[string]interface{}{
+		"status":       models.FormRecordExportCompleted,
+		"row_count":    count,
+		"file_path":    path,
+		"completed_at": completedAt,
+		"expires_at":   expiresAt,
+	})
+	log.Printf("✅ Exported %d records of %s (%s)", count, export.FormCode, export.ID)
+}
+
+// purgeExpiredFormRecordExports removes the files of expired exports
+func purgeExpiredFormRecordExports(db *gorm.DB, now time.Time) {
+	var expired []models.FormRecordExport
+	if err := db.Where("expires_at < ? AND file_path <> ''", now).Find(&expired).Error; err != nil {
+		log.Printf("⚠️  Failed to list expired form record exports: %v", err)
+		return
+	}
+	for _, export := range expired {
+		if err := os.Remove(export.FilePath); err != nil && !os.IsNotExist(err) {
+			log.Printf("⚠️  Failed to remove export file %s: %v", export.FilePath, err)
+			continue
+		}
+		db.Model(&models.FormRecordExport{}).Where("id = ?", export.ID).Update("file_path", "")
+	}
+}
+
+// loadFormRecordExport loads one of the caller's exports of the form in the path
+func loadFormRecordExport(w http.ResponseWriter, r *http.Request) *models.FormRecordExport {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil
+	}
+	vars := mux.Vars(r)
+	exportID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "invalid export ID", http.StatusBadRequest)
+		return nil
+	}
+	var export models.FormRecordExport
+	if err := middleware.ScopedDB(r).Where("id = ? AND form_code = ? AND requested_by = ?", exportID, vars["code"], claims.UserID).
+		First(&export).Error; err != nil {
+		http.Error(w, "export not found", http.StatusNotFound)
+		return nil
+	}
+	return &export
+}
+
+// GetFormRecordExport returns the status of a background export
+// GET /api/v1/forms/{code}/records/exports/{id}
+func GetFormRecordExport(w http.ResponseWriter, r *http.Request) {
+	export := loadFormRecordExport(w, r)
+	if export == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"export": export})
+}
+
+// DownloadFormRecordExport serves the file of a completed background export
+// GET /api/v1/forms/{code}/records/exports/{id}/download
+func DownloadFormRecordExport(w http.ResponseWriter, r *http.Request) {
+	export := loadFormRecordExport(w, r)
+	if export == nil {
+		return
+	}
+	switch {
+	case export.Status != models.FormRecordExportCompleted:
+		writeJSON(w, http.StatusConflict, map[string]interface{}{"error": "export is not ready", "status": export.Status})
+		return
+	case export.Expired(time.Now()) || export.FilePath == "":
+		http.Error(w, "export has expired", http.StatusGone)
+		return
+	}
+
+	w.Header().Set("Content-Type", formRecordExportContentType(export.Format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", export.FileName))
+	http.ServeFile(w, r, export.FilePath)
+}
@@ -0,0 +1,375 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/formvalidation"
+)
+
+const (
+	// formRecordImportMaxBytes caps the size of an uploaded CSV
+	formRecordImportMaxBytes = 20 << 20
+	// formRecordImportMaxRows caps the rows one import may hold
+	formRecordImportMaxRows = 10000
+	// formRecordImportBatchSize is the number of rows per INSERT
+	formRecordImportBatchSize = 500
+	// formRecordImportMaxReported caps the failing rows listed in a report
+	formRecordImportMaxReported = 500
+	// formRecordImportSiteColumn is the header that sets each row's site
+	formRecordImportSiteColumn = "site_id"
+	// formRecordImportListSeparator separates the values of a multi-value field in a cell
+	formRecordImportListSeparator = ";"
+)
+
+// formImportRowError lists the problems with one CSV row. Row is the line number in the
+// file, counting the header as line 1.
+type formImportRowError struct {
+	Row    int                         `json:"row"`
+	Errors []formvalidation.FieldError `json:"errors"`
+}
+
+// formImportColumns works out the field each CSV header fills: the one mapping names, else
+// the field whose name, column name or label matches the header. site_id sets the row's
+// site, and headers mapped to "" are skipped. Headers that match nothing are an error, so
+// a misspelt column is not silently dropped.
+func formImportColumns(headers []string, fields []formvalidation.Field, mapping map[string]string) ([]string, error) {
+	byName := make(map[string]string)
+	for _, field := range fields {
+		byName[formColumnName(field.Name)] = field.Name
+		if field.Label != "" {
+			byName[strings.ToLower(strings.TrimSpace(field.Label))] = field.Name
+		}
+	}
+
+	targets := make([]string, len(headers))
+	used := make(map[string]string)
+	var unknown []string
+	for i, header := range headers {
+		header = strings.TrimSpace(header)
+		target, mapped := mapping[header]
+		if mapped && target != "" && target != formRecordImportSiteColumn {
+			name, ok := byName[formColumnName(target)]
+			if !ok {
+				return nil, fmt.Errorf("mapping for %q names unknown field %q", header, target)
+			}
+			target = name
+		}
+		if !mapped {
+			if formColumnName(header) == formRecordImportSiteColumn {
+				target = formRecordImportSiteColumn
+			} else if name, ok := byName[formColumnName(header)]; ok {
+				target = name
+			} else if name, ok := byName[strings.ToLower(header)]; ok {
+				target = name
+			} else {
+				unknown = append(unknown, header)
+				continue
+			}
+		}
+		if target != "" {
+			if other, taken := used[target]; taken {
+				return nil, fmt.Errorf("columns %q and %q both fill %s", other, header, target)
+			}
+			used[target] = header
+		}
+		targets[i] = target
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("no form field matches column %s; map or skip it with mapping",
+			strings.Join(unknown, ", "))
+	}
+	return targets, nil
+}
+
+// formImportRow turns a CSV row into form data and the site cell. Empty cells are left
+// out, the cells of multi-value fields are split on ";", and location cells are read as
+// "latitude,longitude".
+func formImportRow(cells []string, targets []string, fields map[string]formvalidation.Field) (map[string]interface{}, string) {
+	data := make(map[string]interface{})
+	site := ""
+	for i, target := range targets {
+		if target == "" || i >= len(cells) {
+			continue
+		}
+		cell := strings.TrimSpace(cells[i])
+		if cell == "" {
+			continue
+		}
+		if target == formRecordImportSiteColumn {
+			site = cell
+			continue
+		}
+		field := fields[target]
+		if field.Multiple || field.Type == "multiselect" || field.Type == "checkbox_group" {
+			var items []interface{}
+			for _, item := range strings.Split(cell, formRecordImportListSeparator) {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			data[target] = items
+			continue
+		}
+		if field.Type == "geopoint" {
+			if lat, lng, ok := strings.Cut(cell, ","); ok {
+				data[target] = map[string]interface{}{"latitude": strings.TrimSpace(lat), "longitude": strings.TrimSpace(lng)}
+				continue
+			}
+		}
+		data[target] = cell
+	}
+	return data, site
+}
+
+// readFormImportCSV returns the CSV of an import request: the "file" part of a multipart
+// upload, or else the request body
+func readFormImportCSV(r *http.Request) (io.Reader, error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			return nil, fmt.Errorf("invalid upload: %v", err)
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, errors.New("missing file")
+		}
+		return file, nil
+	}
+	return r.Body, nil
+}
+
+// ImportFormRecords imports records of a form from a CSV whose header row names the
+// fields. Columns match fields by name or label, or by ?mapping, a JSON object from header
+// to field name ("" skips a column); a site_id column sets each row's site, ?site_id the
+// site of rows without one. Every row is validated as a full submission. Any failing row
+// fails the import with a per-row report (422) and nothing is written; otherwise the rows
+// are inserted in batches in one transaction, starting in the form's initial state.
+// ?dry_run=true validates and reports without writing. Imported records do not fire
+// submission hooks or webhooks, so a backfill notifies no one.
+// POST /api/v1/forms/{code}/records/import
+func ImportFormRecords(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	form := loadRecordsForm(w, r)
+	if form == nil {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, formRecordImportMaxBytes)
+	source, err := readFormImportCSV(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Options may come as multipart values as well as the query
+	query := r.URL.Query()
+	for _, key := range []string{"business_vertical_id", "business_code", "site_id", "mapping", "dry_run"} {
+		if value := r.FormValue(key); value != "" {
+			query.Set(key, value)
+		}
+	}
+	r.URL.RawQuery = query.Encode()
+	dryRun := query.Get("dry_run") == "true"
+	mapping := map[string]string{}
+	if raw := query.Get("mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			http.Error(w, "mapping must be a JSON object of column to field", http.StatusBadRequest)
+			return
+		}
+	}
+	var defaultSite *uuid.UUID
+	if raw := query.Get("site_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			http.Error(w, "invalid site_id", http.StatusBadRequest)
+			return
+		}
+		defaultSite = &id
+	}
+
+	verticals, err := formRecordVerticals(r, form, true)
+	if err != nil {
+		log.Printf("❌ Failed to resolve verticals for form %s: %v", form.Code, err)
+		http.Error(w, "failed to import records", http.StatusInternalServerError)
+		return
+	}
+	switch {
+	case len(verticals) == 0:
+		http.Error(w, "no permission to create records of this form", http.StatusForbidden)
+		return
+	case len(verticals) > 1:
+		http.Error(w, "business_vertical_id or business_code is required", http.StatusBadRequest)
+		return
+	}
+	verticalID := verticals[0]
+
+	reader := csv.NewReader(source)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	headers, err := reader.Read()
+	if err != nil {
+		http.Error(w, "the CSV has no header row", http.StatusBadRequest)
+		return
+	}
+	if len(headers) > 0 {
+		headers[0] = strings.TrimPrefix(headers[0], "\ufeff")
+	}
+	rules := formvalidation.FromForm(form.FormSchema, form.Steps, form.Validations)
+	targets, err := formImportColumns(headers, rules.Fields, mapping)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fields := make(map[string]formvalidation.Field, len(rules.Fields))
+	for _, field := range rules.Fields {
+		fields[field.Name] = field
+	}
+
+	engine := getWorkflowEngineDedicated()
+	state := engine.initialState(form)
+	var workflowVersion interface{}
+	if form.WorkflowID != nil {
+		var version int
+		if err := middleware.ScopedDB(r).Model(&models.WorkflowDefinition{}).Where("id = ?", *form.WorkflowID).
+			Pluck("current_version", &version).Error; err == nil && version > 0 {
+			workflowVersion = version
+		}
+	}
+
+	var records []map[string]interface{}
+	var rowErrors []formImportRowError
+	failed, total := 0, 0
+	now := time.Now()
+	for line := 2; ; line++ {
+		cells, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid CSV at line %d: %v", line, err), http.StatusBadRequest)
+			return
+		}
+		if total++; total > formRecordImportMaxRows {
+			http.Error(w, fmt.Sprintf("an import may hold at most %d rows", formRecordImportMaxRows), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		data, siteCell := formImportRow(cells, targets, fields)
+		var problems []formvalidation.FieldError
+		siteID := defaultSite
+		if siteCell != "" {
+			if id, err := uuid.Parse(siteCell); err != nil {
+				problems = append(problems, formvalidation.FieldError{Field: formRecordImportSiteColumn, Code: "type", Message: "site_id must be a site ID"})
+			} else {
+				siteID = &id
+			}
+		}
+		if !middleware.InDataScope(r, verticalID, siteID) {
+			problems = append(problems, formvalidation.FieldError{Field: formRecordImportSiteColumn, Code: "scope", Message: "no access to this site"})
+		}
+		if err := validateFormSubmission(form, data, false); err != nil {
+			var validationErrs formvalidation.Errors
+			if !errors.As(err, &validationErrs) {
+				log.Printf("❌ Failed to validate import row %d of %s: %v", line, form.Code, err)
+				http.Error(w, "failed to validate records", http.StatusInternalServerError)
+				return
+			}
+			problems = append(problems, validationErrs...)
+		}
+		if len(problems) > 0 {
+			failed++
+			if len(rowErrors) < formRecordImportMaxReported {
+				rowErrors = append(rowErrors, formImportRowError{Row: line, Errors: problems})
+			}
+			continue
+		}
+		if failed > 0 {
+			// The import will fail; keep checking rows only for the report
+			continue
+		}
+
+		record := engine.ResolveFormFieldValues(form, data)
+		record["id"] = uuid.New()
+		record["form_id"] = form.ID
+		record["form_code"] = form.Code
+		record["business_vertical_id"] = verticalID
+		record["site_id"] = siteID
+		record["workflow_id"] = form.WorkflowID
+		record["workflow_version"] = workflowVersion
+		record["current_state"] = state
+		record["created_by"] = claims.UserID
+		record["created_at"] = now
+		record["updated_at"] = now
+		records = append(records, record)
+	}
+
+	report := map[string]interface{}{
+		"dry_run":     dryRun,
+		"total_rows":  total,
+		"valid_rows":  total - failed,
+		"failed_rows": failed,
+		"imported":    0,
+		"errors":      rowErrors,
+	}
+	if rowErrors == nil {
+		report["errors"] = []formImportRowError{}
+	}
+	if failed > 0 {
+		status := http.StatusUnprocessableEntity
+		if dryRun {
+			status = http.StatusOK
+		}
+		writeJSON(w, status, report)
+		return
+	}
+	if dryRun || len(records) == 0 {
+		writeJSON(w, http.StatusOK, report)
+		return
+	}
+
+	tableManager := engine.tableManager
+	exists, err := tableManager.TableExists(form.DBTableName)
+	if err != nil {
+		http.Error(w, "failed to import records", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		if err := tableManager.CreateFormTable(form); err != nil {
+			log.Printf("❌ Failed to create table for %s: %v", form.Code, err)
+			http.Error(w, "failed to import records", http.StatusInternalServerError)
+			return
+		}
+	}
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		txManager := &FormTableManager{db: tx, schemaManager: tableManager.schemaManager}
+		return txManager.InsertFormDataBatch(form.DBTableName, records, formRecordImportBatchSize)
+	})
+	if err != nil {
+		log.Printf("❌ Failed to import records of %s: %v", form.Code, err)
+		if errors.Is(err, errInvalidFormField) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "failed to import records", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("✅ Imported %d records of %s for %s", len(records), form.Code, claims.UserID)
+	report["imported"] = len(records)
+	writeJSON(w, http.StatusCreated, report)
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"p9e.in/ugcl/pkg/formvalidation"
+)
+
+var importTestRules = formvalidation.FromForm(json.RawMessage(`{"fields": [
+	{"name": "reading_date", "type": "date", "label": "Reading Date", "required": true},
+	{"name": "Flow Rate", "type": "number"},
+	{"name": "issues", "type": "multiselect", "options": ["leak", "noise"]}
+]}`), nil, nil)
+
+func TestFormImportColumns(t *testing.T) {
+	targets, err := formImportColumns([]string{"Reading Date", "flow_rate", "Site_ID", "Notes", "problems"},
+		importTestRules.Fields, map[string]string{"Notes": "", "problems": "issues"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"reading_date", "Flow Rate", "site_id", "", "issues"}
+	if strings.Join(targets, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", targets, want)
+	}
+
+	if _, err := formImportColumns([]string{"reading_date", "remarks"}, importTestRules.Fields, nil); err == nil ||
+		!strings.Contains(err.Error(), "remarks") {
+		t.Errorf("want the unknown column reported, got %v", err)
+	}
+	if _, err := formImportColumns([]string{"reading_date", "Reading Date"}, importTestRules.Fields, nil); err == nil {
+		t.Error("want an error for two columns filling one field")
+	}
+	if _, err := formImportColumns([]string{"x"}, importTestRules.Fields, map[string]string{"x": "nope"}); err == nil {
+		t.Error("want an error for a mapping to an unknown field")
+	}
+}
+
+func TestFormImportRow(t *testing.T) {
+	fields := make(map[string]formvalidation.Field)
+	for _, field := range importTestRules.Fields {
+		fields[field.Name] = field
+	}
+	targets := []string{"reading_date", "Flow Rate", "site_id", "", "issues"}
+	data, site := formImportRow([]string{"2024-03-01", " ", "7b0c", "ignored", "leak; noise"}, targets, fields)
+	if site != "7b0c" || data["reading_date"] != "2024-03-01" {
+		t.Errorf("got %v, site %q", data, site)
+	}
+	if _, ok := data["Flow Rate"]; ok {
+		t.Error("empty cells should be left out")
+	}
+	if issues, _ := data["issues"].([]interface{}); len(issues) != 2 || issues[1] != "noise" {
+		t.Errorf("got issues %v", data["issues"])
+	}
+	if errs := importTestRules.Validate(data, formvalidation.Options{}); len(errs) > 0 {
+		t.Errorf("row should validate: %v", errs)
+	}
+}
+
+func TestFormImportRowGeoPoint(t *testing.T) {
+	fields := map[string]formvalidation.Field{"location": {Name: "location", Type: "geopoint"}}
+	data, _ := formImportRow([]string{"12.97, 77.59"}, []string{"location"}, fields)
+	point, ok := formvalidation.ParseGeoPoint(data["location"])
+	if !ok || point.Latitude != 12.97 || point.Longitude != 77.59 {
+		t.Errorf("got %v", data["location"])
+	}
+}
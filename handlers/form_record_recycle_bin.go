@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/jobrunner"
+)
+
+// defaultFormRecordRetention is how long deleted records stay in the recycle bin
+const defaultFormRecordRetention = 90 * 24 * time.Hour
+
+// formRecordRetention is how long deleted records may be restored before they are purged,
+// FORM_RECORD_RETENTION (a duration such as 2160h) when set
+func formRecordRetention() time.Duration {
+	if raw := strings.TrimSpace(os.Getenv("FORM_RECORD_RETENTION")); raw != "" {
+		if retention, err := time.ParseDuration(raw); err == nil && retention > 0 {
+			return retention
+		}
+		log.Printf("⚠️  Invalid FORM_RECORD_RETENTION %q, using %v", raw, defaultFormRecordRetention)
+	}
+	return defaultFormRecordRetention
+}
+
+// loadDeletedFormRecord loads a record of the form from the recycle bin that the caller may
+// write, answering 404 otherwise
+func loadDeletedFormRecord(w http.ResponseWriter, r *http.Request, form *models.AppForm) (uuid.UUID, map[string]interface{}) {
+	recordID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid record ID", http.StatusBadRequest)
+		return uuid.Nil, nil
+	}
+	tableManager := NewFormTableManager()
+	table, err := tableManager.qualifiedTableName("", form.DBTableName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return uuid.Nil, nil
+	}
+	record, err := formRecordRow(middleware.WithDataScope(r, tableManager.db), fmt.Sprintf("SELECT * FROM %s WHERE id = $1 AND deleted_at IS NOT NULL", table), recordID)
+	if err != nil || record == nil {
+		http.Error(w, "deleted record not found", http.StatusNotFound)
+		return uuid.Nil, nil
+	}
+	if !formRecordInScope(w, r, form, record, true) {
+		return uuid.Nil, nil
+	}
+	return recordID, record
+}
+
+// ListDeletedFormRecords lists the recycle bin of a form: its deleted records in the
+// verticals where the caller may write the form, most recently deleted first, each with
+// when it will be purged. Discarded drafts are left out. Paged with limit and cursor.
+// GET /api/v1/forms/{code}/records/deleted
+func ListDeletedFormRecords(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	form := loadRecordsForm(w, r)
+	if form == nil {
+		return
+	}
+
+	limit, err := parseSubmissionPageSize(r.URL.Query().Get("limit"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cursor, err := decodeSubmissionsCursor(r.URL.Query().Get("cursor"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tableManager := NewFormTableManager()
+	table, err := tableManager.qualifiedTableName("", form.DBTableName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	columnTypes, err := tableManager.tableColumnTypes("", form.DBTableName)
+	if err != nil {
+		log.Printf("❌ Failed to read columns of %s: %v", form.DBTableName, err)
+		http.Error(w, "failed to load deleted records", http.StatusInternalServerError)
+		return
+	}
+	empty := map[string]interface{}{
+		"records":     []map[string]interface{}{},
+		"count":       0,
+		"limit":       limit,
+		"has_more":    false,
+		"next_cursor": "",
+	}
+	if len(columnTypes) == 0 {
+		writeJSON(w, http.StatusOK, empty)
+		return
+	}
+	verticals, err := formRecordVerticals(r, form, true)
+	if err != nil {
+		log.Printf("❌ Failed to resolve verticals for form %s: %v", form.Code, err)
+		http.Error(w, "failed to load deleted records", http.StatusInternalServerError)
+		return
+	}
+	if len(verticals) == 0 {
+		writeJSON(w, http.StatusOK, empty)
+		return
+	}
+
+	query := &formRecordQuery{columnTypes: columnTypes}
+	query.scope(r, verticals, claims.UserID)
+	where := append([]string{"deleted_at IS NOT NULL", fmt.Sprintf("current_state <> %s", query.arg(formDraftState))}, query.where...)
+	if cursor != nil {
+		deletedAt, id := query.arg(cursor.Timestamp), query.typedArg("id", cursor.ID.String())
+		where = append(where, fmt.Sprintf("(deleted_at < %s OR (deleted_at = %s AND id < %s))", deletedAt, deletedAt, id))
+	}
+	sql := fmt.Sprintf("SELECT * FROM %s WHERE %s ORDER BY deleted_at DESC, id DESC LIMIT %s",
+		table, strings.Join(where, " AND "), query.arg(limit+1))
+
+	var records []map[string]interface{}
+	if err := middleware.WithDataScope(r, tableManager.db).Raw(sql, query.args...).Scan(&records).Error; err != nil {
+		log.Printf("❌ Failed to list deleted records of %s: %v", form.Code, err)
+		http.Error(w, "failed to load deleted records", http.StatusInternalServerError)
+		return
+	}
+
+	hasMore := len(records) > limit
+	nextCursor := ""
+	if hasMore {
+		records = records[:limit]
+		last := records[len(records)-1]
+		deletedAt, _ := last["deleted_at"].(time.Time)
+		id, _ := columnUUID(last["id"])
+		nextCursor = encodeSubmissionsCursor(deletedAt, id)
+	}
+	retention := formRecordRetention()
+	attachFormRecordFiles(form, records)
+	for _, record := range records {
+		if deletedAt, ok := record["deleted_at"].(time.Time); ok {
+			record["purge_at"] = deletedAt.Add(retention)
+		}
+		formRecordJSON(record)
+	}
+	if records == nil {
+		records = []map[string]interface{}{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"records":     records,
+		"count":       len(records),
+		"limit":       limit,
+		"has_more":    hasMore,
+		"next_cursor": nextCursor,
+	})
+}
+
+// RestoreFormRecord brings a record back from the recycle bin
+// POST /api/v1/forms/{code}/records/deleted/{id}/restore
+func RestoreFormRecord(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	form := loadRecordsForm(w, r)
+	if form == nil {
+		return
+	}
+	recordID, record := loadDeletedFormRecord(w, r, form)
+	if record == nil {
+		return
+	}
+
+	tableManager := NewFormTableManager()
+	if err := tableManager.RestoreFormDataInSchema("", form.DBTableName, recordID, claims.UserID); err != nil {
+		if errors.Is(err, errFormRecordNotDeleted) {
+			http.Error(w, "deleted record not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("❌ Error restoring record %s of %s: %v", recordID, form.Code, err)
+		http.Error(w, "failed to restore record", http.StatusInternalServerError)
+		return
+	}
+	restored, err := tableManager.GetFormData(form.DBTableName, recordID)
+	if err != nil {
+		http.Error(w, "failed to load restored record", http.StatusInternalServerError)
+		return
+	}
+	attachFormRecordFiles(form, []map[string]interface{}{restored})
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "record restored", "record": formRecordJSON(restored)})
+}
+
+// PurgeFormRecord permanently deletes a record from the recycle bin without waiting for
+// the retention period
+// DELETE /api/v1/forms/{code}/records/deleted/{id}
+func PurgeFormRecord(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	form := loadRecordsForm(w, r)
+	if form == nil {
+		return
+	}
+	recordID, record := loadDeletedFormRecord(w, r, form)
+	if record == nil {
+		return
+	}
+
+	purged, err := NewFormTableManager().PurgeFormDataInSchema("", form.DBTableName, &recordID, time.Now())
+	if err != nil {
+		log.Printf("❌ Error purging record %s of %s: %v", recordID, form.Code, err)
+		http.Error(w, "failed to purge record", http.StatusInternalServerError)
+		return
+	}
+	if purged == 0 {
+		http.Error(w, "deleted record not found", http.StatusNotFound)
+		return
+	}
+	log.Printf("🗑️  Record %s of %s purged by %s", recordID, form.Code, claims.UserID)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "record purged", "id": recordID})
+}
+
+// FormRecordPurger permanently deletes form records that have been in the recycle bin for
+// longer than the retention period (FORM_RECORD_RETENTION, 90 days by default)
+type FormRecordPurger struct {
+	db *gorm.DB
+	*jobrunner.Runner
+}
+
+// NewFormRecordPurger creates the form record purger
+func NewFormRecordPurger() *FormRecordPurger {
+	p := &FormRecordPurger{db: config.DB}
+	p.Runner = jobrunner.New("Form record purger", func() {
+		if n, err := p.RunDue(time.Now()); err != nil {
+			log.Printf("Error purging form records: %v", err)
+		} else if n > 0 {
+			log.Printf("Form record purger: purged %d records", n)
+		}
+	})
+	return p
+}
+
+// RunDue purges the records deleted before now less the retention period and returns how
+// many were purged. A form whose table cannot be purged is logged and skipped so the
+// others are still purged.
+func (p *FormRecordPurger) RunDue(now time.Time) (int, error) {
+	var tables []string
+	if err := p.db.Model(&models.AppForm{}).Where("db_table_name <> ''").Distinct().Pluck("db_table_name", &tables).Error; err != nil {
+		return 0, err
+	}
+
+	cutoff := now.Add(-formRecordRetention())
+	tableManager := &FormTableManager{db: p.db}
+	purged := 0
+	for _, name := range tables {
+		if exists, err := tableManager.TableExists(name); err != nil || !exists {
+			continue
+		}
+		n, err := tableManager.PurgeFormDataInSchema("", name, nil, cutoff)
+		if err != nil {
+			log.Printf("⚠️  Failed to purge records of %s: %v", name, err)
+			continue
+		}
+		purged += n
+	}
+	return purged, nil
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormRecordRetention(t *testing.T) {
+	cases := map[string]time.Duration{
+		"":      defaultFormRecordRetention,
+		"720h":  720 * time.Hour,
+		"-1h":   defaultFormRecordRetention,
+		"never": defaultFormRecordRetention,
+	}
+	for raw, want := range cases {
+		t.Setenv("FORM_RECORD_RETENTION", raw)
+		if got := formRecordRetention(); got != want {
+			t.Errorf("FORM_RECORD_RETENTION=%q: got %v, want %v", raw, got, want)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+	"p9e.in/ugcl/middleware"
+)
+
+// formSearchColumn is the generated tsvector column holding the searchable text of a
+// form table's record. It is not a form field: column listings leave it out, and record
+// responses drop it.
+const formSearchColumn = "search_vector"
+
+const (
+	// maxFormSearchTerms caps the words of one search
+	maxFormSearchTerms = 10
+	// maxFormSearchOffset caps how deep search results page; ranked results past it are
+	// better reached with a narrower search
+	maxFormSearchOffset = 10000
+)
+
+// formSearchVectorSQL builds the expression of the search column from the table's text and
+// JSON form-field columns, or "" when the table has none. The 'simple' configuration is
+// used since records mix languages, codes and names that stemming would mangle; date and
+// number columns are left out as their text casts may not be used in generated columns.
+func formSearchVectorSQL(columnTypes map[string]string) string {
+	names := make([]string, 0, len(columnTypes))
+	for name := range columnTypes {
+		if !formTableBaseColumns[name] && name != formSearchColumn {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		quoted, err := formIdentifier(name)
+		if err != nil {
+			continue
+		}
+		switch columnTypes[name] {
+		case "text", "varchar", "bpchar":
+			parts = append(parts, fmt.Sprintf("to_tsvector('simple', coalesce(%s, ''))", quoted))
+		case "jsonb":
+			parts = append(parts, fmt.Sprintf(`jsonb_to_tsvector('simple', coalesce(%s, '{}'::jsonb), '["string"]')`, quoted))
+		}
+	}
+	return strings.Join(parts, " || ")
+}
+
+// hasSearchVector reports whether the form table has its search column
+func (ftm *FormTableManager) hasSearchVector(schemaName, tableName string) (bool, error) {
+	if schemaName == "" {
+		schemaName = "public"
+	}
+	var count int64
+	if err := ftm.db.Raw("SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = ? AND table_name = ? AND column_name = ?",
+		strings.ToLower(schemaName), strings.ToLower(tableName), formSearchColumn).Scan(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to read columns of %s: %v", tableName, err)
+	}
+	return count > 0, nil
+}
+
+// ensureSearchVector rebuilds the form table's search column and its GIN index from the
+// table's current columns. A generated column's expression cannot be altered, so it is
+// dropped and added again; callers do so whenever the table's fields change. Tables
+// without text fields get no search column.
+func (ftm *FormTableManager) ensureSearchVector(schemaName, tableName string) error {
+	fullTableName, err := ftm.qualifiedTableName(schemaName, tableName)
+	if err != nil {
+		return err
+	}
+	return ftm.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS %s", fullTableName, formSearchColumn)).Error; err != nil {
+			return fmt.Errorf("failed to drop search column of %s: %v", tableName, err)
+		}
+		columnTypes, err := ftm.withDB(tx).tableColumnTypes(schemaName, tableName)
+		if err != nil {
+			return err
+		}
+		expression := formSearchVectorSQL(columnTypes)
+		if expression == "" {
+			return nil
+		}
+		if err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s tsvector GENERATED ALWAYS AS (%s) STORED",
+			fullTableName, formSearchColumn, expression)).Error; err != nil {
+			return fmt.Errorf("failed to add search column to %s: %v", tableName, err)
+		}
+		indexPrefix := strings.ReplaceAll(strings.ReplaceAll(fullTableName, `"`, ""), ".", "_")
+		if err := tx.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_search ON %s USING GIN (%s)",
+			indexPrefix, fullTableName, formSearchColumn)).Error; err != nil {
+			return fmt.Errorf("failed to index search column of %s: %v", tableName, err)
+		}
+		return nil
+	})
+}
+
+// formSearchQuery turns free text into a tsquery matching records that contain every word,
+// each as a prefix so partial words still find records. Words keep only letters and
+// digits, so the result is safe to pass to to_tsquery.
+func formSearchQuery(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	seen := make(map[string]bool, len(words))
+	var terms []string
+	for _, word := range words {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		terms = append(terms, word+":*")
+		if len(terms) == maxFormSearchTerms {
+			break
+		}
+	}
+	return strings.Join(terms, " & ")
+}
+
+// SearchFormRecords finds a form's records whose text fields contain every word of ?q=,
+// across the verticals the caller may read, best matches first. It takes the list
+// filters, state, fields and mine parameters of ListFormRecords (though not its sort or
+// cursor) and pages with limit and offset.
+// GET /api/v1/forms/{code}/records/search?q=...
+func SearchFormRecords(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	form := loadRecordsForm(w, r)
+	if form == nil {
+		return
+	}
+
+	values := r.URL.Query()
+	tsquery := formSearchQuery(values.Get("q"))
+	if tsquery == "" {
+		http.Error(w, "q must contain at least one word", http.StatusBadRequest)
+		return
+	}
+	offset := 0
+	if raw := values.Get("offset"); raw != "" {
+		var err error
+		if offset, err = strconv.Atoi(raw); err != nil || offset < 0 || offset > maxFormSearchOffset {
+			http.Error(w, fmt.Sprintf("offset must be between 0 and %d", maxFormSearchOffset), http.StatusBadRequest)
+			return
+		}
+	}
+
+	tableManager := NewFormTableManager()
+	table, err := tableManager.qualifiedTableName("", form.DBTableName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	columnTypes, err := tableManager.tableColumnTypes("", form.DBTableName)
+	if err != nil {
+		log.Printf("❌ Failed to read columns of %s: %v", form.DBTableName, err)
+		http.Error(w, "failed to search records", http.StatusInternalServerError)
+		return
+	}
+	empty := map[string]interface{}{
+		"records":     []map[string]interface{}{},
+		"count":       0,
+		"offset":      offset,
+		"has_more":    false,
+		"next_offset": 0,
+	}
+	if len(columnTypes) == 0 {
+		// The table is created with the form's first record
+		writeJSON(w, http.StatusOK, empty)
+		return
+	}
+	if formSearchVectorSQL(columnTypes) == "" {
+		http.Error(w, "form has no text fields to search", http.StatusBadRequest)
+		return
+	}
+
+	// Tables created before search was added get their search column on first use
+	indexed, err := tableManager.hasSearchVector("", form.DBTableName)
+	if err == nil && !indexed {
+		log.Printf("🔧 Adding search column to %s", form.DBTableName)
+		err = tableManager.ensureSearchVector("", form.DBTableName)
+	}
+	if err != nil {
+		log.Printf("❌ Failed to prepare search of %s: %v", form.DBTableName, err)
+		http.Error(w, "failed to search records", http.StatusInternalServerError)
+		return
+	}
+
+	values.Del("sort")
+	values.Del("cursor")
+	query, err := parseFormRecordQuery(values, columnTypes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	empty["limit"] = query.limit
+
+	verticals, err := formRecordVerticals(r, form, false)
+	if err != nil {
+		log.Printf("❌ Failed to resolve verticals for form %s: %v", form.Code, err)
+		http.Error(w, "failed to search records", http.StatusInternalServerError)
+		return
+	}
+	if len(verticals) == 0 {
+		writeJSON(w, http.StatusOK, empty)
+		return
+	}
+	query.scope(r, verticals, claims.UserID)
+
+	match := fmt.Sprintf("to_tsquery('simple', %s)", query.arg(tsquery))
+	where := append([]string{"deleted_at IS NULL", fmt.Sprintf("%s @@ %s", formSearchColumn, match)}, query.where...)
+	columns := "*"
+	if len(query.fields) > 0 {
+		quoted := make([]string, len(query.fields))
+		for i, field := range query.fields {
+			quoted[i], _ = formIdentifier(field)
+		}
+		columns = strings.Join(quoted, ", ")
+	}
+	sql := fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY ts_rank(%s, %s) DESC, created_at DESC, id DESC LIMIT %s OFFSET %s",
+		columns, table, strings.Join(where, " AND "), formSearchColumn, match, query.arg(query.limit+1), query.arg(offset))
+
+	rows, err := middleware.WithDataScope(r, tableManager.db).Raw(sql, query.args...).Rows()
+	if err != nil {
+		log.Printf("❌ Failed to search records of %s: %v", form.Code, err)
+		http.Error(w, "failed to search records", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	names, _ := rows.Columns()
+	records := make([]map[string]interface{}, 0, query.limit+1)
+	for rows.Next() {
+		values := make([]interface{}, len(names))
+		valuePtrs := make([]interface{}, len(names))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			log.Printf("❌ Failed to scan record of %s: %v", form.Code, err)
+			http.Error(w, "failed to search records", http.StatusInternalServerError)
+			return
+		}
+		record := make(map[string]interface{}, len(names))
+		for i, name := range names {
+			record[name] = values[i]
+		}
+		records = append(records, record)
+	}
+
+	hasMore := len(records) > query.limit
+	nextOffset := 0
+	if hasMore {
+		records = records[:query.limit]
+		nextOffset = offset + query.limit
+	}
+	attachFormRecordFiles(form, records)
+	for _, record := range records {
+		formRecordJSON(record)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"records":     records,
+		"count":       len(records),
+		"limit":       query.limit,
+		"offset":      offset,
+		"has_more":    hasMore,
+		"next_offset": nextOffset,
+	})
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import "testing"
+
+func TestFormSearchQuery(t *testing.T) {
+	cases := map[string]string{
+		"":                      "",
+		"  !! ":                 "",
+		"Pump":                  "pump:*",
+		"pump  Station-4":       "pump:* & station:* & 4:*",
+		"pump pump":             "pump:*",
+		"o'brien & (x | !y)":    "o:* & brien:* & x:* & y:*",
+		"Übergabe Straße":       "übergabe:* & straße:*",
+		"a b c d e f g h i j k": "a:* & b:* & c:* & d:* & e:* & f:* & g:* & h:* & i:* & j:*",
+	}
+	for text, want := range cases {
+		if got := formSearchQuery(text); got != want {
+			t.Errorf("formSearchQuery(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestFormSearchVectorSQL(t *testing.T) {
+	got := formSearchVectorSQL(map[string]string{
+		"id":            "uuid",
+		"created_by":    "varchar",
+		"current_state": "varchar",
+		"search_vector": "tsvector",
+		"remarks":       "text",
+		"amount":        "numeric",
+		"visit_date":    "date",
+		"details":       "jsonb",
+		"code":          "bpchar",
+	})
+	want := `to_tsvector('simple', coalesce("code", '')) || ` +
+		`jsonb_to_tsvector('simple', coalesce("details", '{}'::jsonb), '["string"]') || ` +
+		`to_tsvector('simple', coalesce("remarks", ''))`
+	if got != want {
+		t.Errorf("formSearchVectorSQL =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := formSearchVectorSQL(map[string]string{"id": "uuid", "amount": "numeric"}); got != "" {
+		t.Errorf("formSearchVectorSQL without text columns = %q, want empty", got)
+	}
+}
@@ -0,0 +1,511 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/formvalidation"
+	"p9e.in/ugcl/utils"
+)
+
+// maxFormSyncBatch caps the changes one sync push may carry
+const maxFormSyncBatch = 100
+
+// Outcomes of a pushed change
+const (
+	formSyncCreated   = "created"
+	formSyncUpdated   = "updated"
+	formSyncDeleted   = "deleted"
+	formSyncUnchanged = "unchanged" // already applied, as when a push is retried
+	formSyncConflict  = "conflict"  // the record changed on the server since the client's copy
+	formSyncError     = "error"
+)
+
+// formSyncChange is a change an offline client queued for one record. Records are named
+// by IDs the client generates, so a change can be sent again until it is acknowledged.
+type formSyncChange struct {
+	ID uuid.UUID `json:"id"`
+	// BaseUpdatedAt is the updated_at of the server copy the change was made to; updates
+	// and deletes apply only while the record is still at that version
+	BaseUpdatedAt      *time.Time             `json:"base_updated_at,omitempty"`
+	BusinessVerticalID *uuid.UUID             `json:"business_vertical_id,omitempty"`
+	BusinessCode       string                 `json:"business_code,omitempty"`
+	SiteID             *uuid.UUID             `json:"site_id,omitempty"`
+	FormData           map[string]interface{} `json:"form_data,omitempty"`
+	Draft              bool                   `json:"draft,omitempty"` // create the record as a draft
+	Deleted            bool                   `json:"deleted,omitempty"`
+}
+
+// formSyncResult is the outcome of one pushed change, with the server's copy of the record
+// after it (or, on a conflict, the copy that won)
+type formSyncResult struct {
+	ID     uuid.UUID              `json:"id"`
+	Status string                 `json:"status"`
+	Error  string                 `json:"error,omitempty"`
+	Errors formvalidation.Errors  `json:"errors,omitempty"`
+	Record map[string]interface{} `json:"record,omitempty"`
+}
+
+// formSyncValueEqual reports whether a value a client sent equals a stored one. Numbers
+// compare by value, since stored decimals may come back as text.
+func formSyncValueEqual(sent, stored interface{}) bool {
+	a, _ := json.Marshal(sent)
+	b, _ := json.Marshal(stored)
+	if string(a) == string(b) {
+		return true
+	}
+	x, okX := formSyncNumber(sent)
+	y, okY := formSyncNumber(stored)
+	return okX && okY && x == y
+}
+
+func formSyncNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// formSyncBatch applies the changes of one push for one caller
+type formSyncBatch struct {
+	r           *http.Request
+	form        *models.AppForm
+	engine      *WorkflowEngineDedicated
+	table       string
+	tableExists bool
+	verticals   []models.BusinessVertical // where the caller may write the form
+	fields      []formvalidation.Field
+	userID      string
+}
+
+// load returns the record with the given ID, deleted or not, or nil when there is none
+func (b *formSyncBatch) load(id uuid.UUID) (map[string]interface{}, error) {
+	if !b.tableExists {
+		return nil, nil
+	}
+	return formRecordRow(b.engine.tableManager.db, fmt.Sprintf("SELECT * FROM %s WHERE id = $1", b.table), id)
+}
+
+// current returns the server's copy of a record for a result
+func (b *formSyncBatch) current(id uuid.UUID) map[string]interface{} {
+	record, err := b.load(id)
+	if err != nil || record == nil {
+		return nil
+	}
+	attachFormRecordFiles(b.form, []map[string]interface{}{record})
+	return formRecordJSON(record)
+}
+
+// writable reports whether the caller may change the record
+func (b *formSyncBatch) writable(record map[string]interface{}) bool {
+	verticalID, _ := columnUUID(record["business_vertical_id"])
+	var siteID *uuid.UUID
+	if id, ok := columnUUID(record["site_id"]); ok {
+		siteID = &id
+	}
+	for _, vertical := range b.verticals {
+		if vertical.ID == verticalID {
+			return middleware.InDataScope(b.r, verticalID, siteID)
+		}
+	}
+	return false
+}
+
+// vertical picks the vertical a new record goes in, as CreateFormRecord does
+func (b *formSyncBatch) vertical(change formSyncChange) (uuid.UUID, error) {
+	var matches []uuid.UUID
+	for _, vertical := range b.verticals {
+		if (change.BusinessVerticalID == nil || *change.BusinessVerticalID == vertical.ID) &&
+			(change.BusinessCode == "" || change.BusinessCode == vertical.Code) {
+			matches = append(matches, vertical.ID)
+		}
+	}
+	switch {
+	case len(matches) == 0:
+		return uuid.Nil, fmt.Errorf("no permission to create records of this form")
+	case len(matches) > 1:
+		return uuid.Nil, fmt.Errorf("business_vertical_id or business_code is required")
+	}
+	return matches[0], nil
+}
+
+// unchanged reports whether the record already holds every value of data
+func (b *formSyncBatch) unchanged(record map[string]interface{}, data map[string]interface{}) bool {
+	stored := formSubmissionData(b.fields, record)
+	for key, value := range data {
+		current, ok := stored[key]
+		if !ok {
+			column, found := record[formColumnName(key)]
+			if !found {
+				return false
+			}
+			current = submittedValue("", column)
+		}
+		if !formSyncValueEqual(value, current) {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *formSyncBatch) apply(change formSyncChange) formSyncResult {
+	result := formSyncResult{ID: change.ID}
+	fail := func(err error) formSyncResult {
+		result.Status = formSyncError
+		if !errors.As(err, &result.Errors) {
+			result.Error = err.Error()
+		} else {
+			result.Error = "validation failed"
+		}
+		return result
+	}
+	conflict := func() formSyncResult {
+		result.Status = formSyncConflict
+		result.Record = b.current(change.ID)
+		return result
+	}
+
+	if change.ID == uuid.Nil {
+		return fail(fmt.Errorf("id is required"))
+	}
+	existing, err := b.load(change.ID)
+	if err != nil {
+		log.Printf("❌ Failed to load record %s of %s: %v", change.ID, b.form.Code, err)
+		return fail(fmt.Errorf("failed to load record"))
+	}
+
+	if existing == nil {
+		if change.Deleted {
+			// Never created, or already purged
+			result.Status = formSyncUnchanged
+			return result
+		}
+		return b.create(change)
+	}
+	if !b.writable(existing) {
+		return fail(fmt.Errorf("record not found"))
+	}
+
+	updatedAt, _ := existing["updated_at"].(time.Time)
+	baseMatches := change.BaseUpdatedAt != nil && change.BaseUpdatedAt.Equal(updatedAt)
+	if existing["deleted_at"] != nil {
+		if change.Deleted {
+			result.Status = formSyncUnchanged
+			result.Record = b.current(change.ID)
+			return result
+		}
+		return conflict()
+	}
+
+	if change.Deleted {
+		if !baseMatches {
+			return conflict()
+		}
+		if err := b.engine.DeleteSubmissionDedicated(b.form.Code, change.ID, b.userID); err != nil {
+			log.Printf("❌ Error deleting record %s of %s: %v", change.ID, b.form.Code, err)
+			return fail(fmt.Errorf("failed to delete record"))
+		}
+		result.Status = formSyncDeleted
+		result.Record = b.current(change.ID)
+		return result
+	}
+
+	// A retried create or update finds its values already stored
+	if b.unchanged(existing, change.FormData) {
+		result.Status = formSyncUnchanged
+		result.Record = b.current(change.ID)
+		return result
+	}
+	if !baseMatches {
+		return conflict()
+	}
+	if _, err := b.engine.UpdateSubmissionDataDedicated(b.form.Code, change.ID, change.FormData, b.userID); err != nil {
+		log.Printf("❌ Error updating record %s of %s: %v", change.ID, b.form.Code, err)
+		return fail(err)
+	}
+	result.Status = formSyncUpdated
+	result.Record = b.current(change.ID)
+	return result
+}
+
+func (b *formSyncBatch) create(change formSyncChange) formSyncResult {
+	result := formSyncResult{ID: change.ID, Status: formSyncError}
+	verticalID, err := b.vertical(change)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if !middleware.InDataScope(b.r, verticalID, change.SiteID) {
+		result.Error = "no access to this site"
+		return result
+	}
+	data := change.FormData
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+
+	submission, err := b.engine.createSubmissionDedicated(b.form.Code, change.ID, verticalID, change.SiteID, data, b.userID, change.Draft)
+	b.tableExists = true // the first record creates the table
+	if err != nil {
+		log.Printf("❌ Error creating record %s of %s: %v", change.ID, b.form.Code, err)
+		switch {
+		case errors.As(err, &result.Errors):
+			result.Error = "validation failed"
+		case utils.IsUniqueViolation(err):
+			// Created by a push running alongside this one
+			result.Status = formSyncConflict
+			result.Record = b.current(change.ID)
+		case errors.Is(err, errInvalidFormField):
+			result.Error = err.Error()
+		default:
+			result.Error = "failed to create record"
+		}
+		return result
+	}
+	if !change.Draft {
+		triggerDedicatedFormSubmissionWebhook(submission)
+	}
+	result.Status = formSyncCreated
+	result.Record = b.current(change.ID)
+	return result
+}
+
+// PushFormRecordChanges applies the changes an offline client queued, in order, and
+// reports the outcome of each. A change to a record that changed on the server since the
+// client's copy is not applied and answers a conflict with the server's copy; a change
+// that was already applied answers unchanged, so pushes can safely be retried. Updates
+// follow the record rules: only drafts can be edited.
+// POST /api/v1/forms/{code}/sync
+func PushFormRecordChanges(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	form := loadRecordsForm(w, r)
+	if form == nil {
+		return
+	}
+
+	var req struct {
+		Changes []formSyncChange `json:"changes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Changes) == 0 {
+		http.Error(w, "changes are required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Changes) > maxFormSyncBatch {
+		http.Error(w, fmt.Sprintf("at most %d changes may be pushed at once", maxFormSyncBatch), http.StatusBadRequest)
+		return
+	}
+
+	engine := getWorkflowEngineDedicated()
+	table, err := engine.tableManager.qualifiedTableName("", form.DBTableName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tableExists, err := engine.tableManager.TableExists(form.DBTableName)
+	if err != nil {
+		http.Error(w, "failed to sync records", http.StatusInternalServerError)
+		return
+	}
+	verticalIDs, err := formRecordVerticals(r, form, true)
+	if err != nil {
+		log.Printf("❌ Failed to resolve verticals for form %s: %v", form.Code, err)
+		http.Error(w, "failed to sync records", http.StatusInternalServerError)
+		return
+	}
+	var verticals []models.BusinessVertical
+	if len(verticalIDs) > 0 {
+		if err := middleware.ScopedDB(r).Where("id IN ?", verticalIDs).Find(&verticals).Error; err != nil {
+			http.Error(w, "failed to sync records", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	batch := &formSyncBatch{
+		r:           r,
+		form:        form,
+		engine:      engine,
+		table:       table,
+		tableExists: tableExists,
+		verticals:   verticals,
+		fields:      formvalidation.FromForm(form.FormSchema, form.Steps, form.Validations).Fields,
+		userID:      claims.UserID,
+	}
+	results := make([]formSyncResult, len(req.Changes))
+	counts := map[string]int{}
+	for i, change := range req.Changes {
+		results[i] = batch.apply(change)
+		counts[results[i].Status]++
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"results": results,
+		"counts":  counts,
+	})
+}
+
+// PullFormRecordChanges returns the records of a form changed since the client last
+// synced, across the verticals the caller may read, oldest change first: live records in
+// full and deleted ones as tombstones. The first sync passes ?updated_since= (or nothing,
+// for every record); each page's next_cursor carries on from there and is kept for the
+// next sync. Records purged from the recycle bin leave no tombstone, so a client whose
+// last sync is older than the retention period is told to sync again from scratch.
+// Other users' drafts are left out.
+// GET /api/v1/forms/{code}/sync
+func PullFormRecordChanges(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	form := loadRecordsForm(w, r)
+	if form == nil {
+		return
+	}
+
+	values := r.URL.Query()
+	limit, err := parseSubmissionPageSize(values.Get("limit"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cursor, err := decodeSubmissionsCursor(values.Get("cursor"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var since *time.Time
+	if raw := strings.TrimSpace(values.Get("updated_since")); raw != "" && cursor == nil {
+		parsed, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			http.Error(w, "updated_since must be an RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		since = &parsed
+	}
+
+	serverTime := time.Now()
+	lastSync := since
+	if cursor != nil {
+		lastSync = &cursor.Timestamp
+	}
+	response := map[string]interface{}{
+		"records":         []map[string]interface{}{},
+		"deleted":         []map[string]interface{}{},
+		"count":           0,
+		"limit":           limit,
+		"has_more":        false,
+		"next_cursor":     values.Get("cursor"),
+		"server_time":     serverTime,
+		"resync_required": lastSync != nil && lastSync.Before(serverTime.Add(-formRecordRetention())),
+	}
+
+	tableManager := NewFormTableManager()
+	table, err := tableManager.qualifiedTableName("", form.DBTableName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	columnTypes, err := tableManager.tableColumnTypes("", form.DBTableName)
+	if err != nil {
+		log.Printf("❌ Failed to read columns of %s: %v", form.DBTableName, err)
+		http.Error(w, "failed to sync records", http.StatusInternalServerError)
+		return
+	}
+	if len(columnTypes) == 0 {
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+	verticals, err := formRecordVerticals(r, form, false)
+	if err != nil {
+		log.Printf("❌ Failed to resolve verticals for form %s: %v", form.Code, err)
+		http.Error(w, "failed to sync records", http.StatusInternalServerError)
+		return
+	}
+	if len(verticals) == 0 {
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+
+	query := &formRecordQuery{columnTypes: columnTypes}
+	query.scope(r, verticals, claims.UserID)
+	where := append([]string{fmt.Sprintf("(current_state <> %s OR created_by = %s)", query.arg(formDraftState), query.arg(claims.UserID))}, query.where...)
+	switch {
+	case cursor != nil:
+		updatedAt, id := query.arg(cursor.Timestamp), query.typedArg("id", cursor.ID.String())
+		where = append(where, fmt.Sprintf("(updated_at > %s OR (updated_at = %s AND id > %s))", updatedAt, updatedAt, id))
+	case since != nil:
+		where = append(where, fmt.Sprintf("updated_at > %s", query.arg(since.UTC())))
+	}
+	sql := fmt.Sprintf("SELECT * FROM %s WHERE %s ORDER BY updated_at, id LIMIT %s",
+		table, strings.Join(where, " AND "), query.arg(limit+1))
+
+	var rows []map[string]interface{}
+	if err := middleware.WithDataScope(r, tableManager.db).Raw(sql, query.args...).Scan(&rows).Error; err != nil {
+		log.Printf("❌ Failed to sync records of %s: %v", form.Code, err)
+		http.Error(w, "failed to sync records", http.StatusInternalServerError)
+		return
+	}
+
+	hasMore := len(rows) > limit
+	if hasMore {
+		rows = rows[:limit]
+	}
+	records := make([]map[string]interface{}, 0, len(rows))
+	deleted := make([]map[string]interface{}, 0)
+	for _, row := range rows {
+		if row["deleted_at"] != nil {
+			id, _ := columnUUID(row["id"])
+			deleted = append(deleted, map[string]interface{}{
+				"id":         id,
+				"deleted_at": row["deleted_at"],
+				"updated_at": row["updated_at"],
+			})
+			continue
+		}
+		records = append(records, row)
+	}
+	if len(rows) > 0 {
+		last := rows[len(rows)-1]
+		updatedAt, _ := last["updated_at"].(time.Time)
+		id, _ := columnUUID(last["id"])
+		response["next_cursor"] = encodeSubmissionsCursor(updatedAt, id)
+	}
+	attachFormRecordFiles(form, records)
+	for _, record := range records {
+		formRecordJSON(record)
+	}
+
+	response["records"] = records
+	response["deleted"] = deleted
+	response["count"] = len(rows)
+	response["has_more"] = hasMore
+	writeJSON(w, http.StatusOK, response)
+}
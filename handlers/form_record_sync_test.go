@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/formvalidation"
+)
+
+func TestFormSyncValueEqual(t *testing.T) {
+	cases := []struct {
+		sent, stored interface{}
+		want         bool
+	}{
+		{"pending", "pending", true},
+		{"pending", "done", false},
+		{12.5, "12.50", true},
+		{float64(3), int64(3), true},
+		{3.0, int64(4), false},
+		{[]interface{}{"a", "b"}, []interface{}{"a", "b"}, true},
+		{nil, nil, true},
+		{nil, "", false},
+	}
+	for _, c := range cases {
+		if got := formSyncValueEqual(c.sent, c.stored); got != c.want {
+			t.Errorf("formSyncValueEqual(%#v, %#v) = %v, want %v", c.sent, c.stored, got, c.want)
+		}
+	}
+}
+
+func TestFormSyncBatchVertical(t *testing.T) {
+	water, solar := uuid.New(), uuid.New()
+	batch := &formSyncBatch{verticals: []models.BusinessVertical{
+		{ID: water, Code: "WATER"},
+		{ID: solar, Code: "SOLAR"},
+	}}
+
+	if _, err := batch.vertical(formSyncChange{}); err == nil {
+		t.Error("a change naming no vertical should be ambiguous with two writable verticals")
+	}
+	if got, err := batch.vertical(formSyncChange{BusinessCode: "SOLAR"}); err != nil || got != solar {
+		t.Errorf("vertical by code = %v, %v; want %v", got, err, solar)
+	}
+	if got, err := batch.vertical(formSyncChange{BusinessVerticalID: &water}); err != nil || got != water {
+		t.Errorf("vertical by ID = %v, %v; want %v", got, err, water)
+	}
+	other := uuid.New()
+	if _, err := batch.vertical(formSyncChange{BusinessVerticalID: &other}); err == nil {
+		t.Error("a vertical the caller cannot write should be refused")
+	}
+}
+
+func TestFormSyncBatchUnchanged(t *testing.T) {
+	batch := &formSyncBatch{fields: []formvalidation.Field{
+		{Name: "Remarks", Type: "text"},
+		{Name: "amount", Type: "number"},
+	}}
+	record := map[string]interface{}{"remarks": "checked", "amount": "12.50"}
+
+	if !batch.unchanged(record, map[string]interface{}{"Remarks": "checked", "amount": 12.5}) {
+		t.Error("values already stored should be unchanged")
+	}
+	if batch.unchanged(record, map[string]interface{}{"amount": 13.0}) {
+		t.Error("a new amount should be a change")
+	}
+	if batch.unchanged(record, map[string]interface{}{"unknown": "x"}) {
+		t.Error("a value for a field the record lacks should be a change")
+	}
+}
@@ -0,0 +1,814 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/utils"
+)
+
+// maxFormRecordInValues caps the values of one in filter
+const maxFormRecordInValues = 100
+
+// formRecordComparisons maps filter operators to SQL comparisons; like and in are built
+// separately
+var formRecordComparisons = map[string]string{
+	"eq":  "=",
+	"ne":  "<>",
+	"gt":  ">",
+	"gte": ">=",
+	"lt":  "<",
+	"lte": "<=",
+}
+
+// formRecordFilterParam matches filter[field] and filter[field][op]
+var formRecordFilterParam = regexp.MustCompile(`^filter\[([^\]]+)\](?:\[([a-z]+)\])?$`)
+
+// formRecordBaseFields are returned with every projection, so clients can always identify,
+// scope and page through records
+var formRecordBaseFields = []string{"id", "created_at", "current_state", "business_vertical_id", "site_id"}
+
+// tableColumnTypes returns the columns of a form table with their type names
+func (ftm *FormTableManager) tableColumnTypes(schemaName, tableName string) (map[string]string, error) {
+	if schemaName == "" {
+		schemaName = "public"
+	}
+	var rows []struct {
+		ColumnName string
+		UdtName    string
+	}
+	if err := ftm.db.Raw("SELECT column_name, udt_name FROM information_schema.columns WHERE table_schema = ? AND table_name = ?",
+		strings.ToLower(schemaName), strings.ToLower(tableName)).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read columns of %s: %v", tableName, err)
+	}
+	types := make(map[string]string, len(rows))
+	for _, row := range rows {
+		if !isFormDerivedColumn(row.ColumnName) {
+			types[row.ColumnName] = row.UdtName
+		}
+	}
+	return types, nil
+}
+
+// formRecordCursor marks the last record of a page by its sort value and ID. The value is
+// kept as text and cast back to the column's type, so cursors work for any sort column.
+type formRecordCursor struct {
+	Sort  string    `json:"s"`
+	Desc  bool      `json:"d"`
+	Value *string   `json:"v"` // nil when the record's sort value is NULL
+	ID    uuid.UUID `json:"id"`
+}
+
+func (c formRecordCursor) encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeFormRecordCursor(raw string) (*formRecordCursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	var cursor formRecordCursor
+	if err := json.Unmarshal(decoded, &cursor); err != nil {
+		return nil, err
+	}
+	if cursor.Sort == "" || cursor.ID == uuid.Nil {
+		return nil, fmt.Errorf("incomplete cursor")
+	}
+	return &cursor, nil
+}
+
+// formRecordQuery is a parsed record list request over one form table. Every column it
+// names has been checked against the table, and every value is a bound parameter.
+type formRecordQuery struct {
+	columnTypes map[string]string
+	sort        string
+	desc        bool
+	fields      []string // projection; empty selects every column
+	limit       int
+	cursor      *formRecordCursor
+	where       []string
+	args        []interface{}
+}
+
+// arg binds value and returns its placeholder
+func (q *formRecordQuery) arg(value interface{}) string {
+	q.args = append(q.args, value)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+// typedArg binds a text value cast to the column's type. Binding text and casting in SQL
+// lets query string values compare against numeric, date and uuid columns while the
+// column itself stays uncast, so its indexes still apply.
+func (q *formRecordQuery) typedArg(column, value string) string {
+	return fmt.Sprintf("CAST(%s::text AS %s)", q.arg(value), q.columnTypes[column])
+}
+
+// column validates name as a column of the table and returns it quoted
+func (q *formRecordQuery) column(name string) (string, string, error) {
+	column := formColumnName(strings.TrimSpace(name))
+	if _, ok := q.columnTypes[column]; !ok {
+		return "", "", fmt.Errorf("unknown field %q", name)
+	}
+	quoted, err := formIdentifier(column)
+	if err != nil {
+		return "", "", fmt.Errorf("unknown field %q", name)
+	}
+	if !lookupIdentifierPattern.MatchString(q.columnTypes[column]) {
+		return "", "", fmt.Errorf("field %q cannot be filtered", name)
+	}
+	return column, quoted, nil
+}
+
+// whereIn restricts column to one of values
+func (q *formRecordQuery) whereIn(column string, values []string) {
+	if len(values) == 0 {
+		q.where = append(q.where, "FALSE")
+		return
+	}
+	quoted, _ := formIdentifier(column)
+	placeholders := make([]string, len(values))
+	for i, value := range values {
+		placeholders[i] = q.typedArg(column, value)
+	}
+	q.where = append(q.where, fmt.Sprintf("%s IN (%s)", quoted, strings.Join(placeholders, ", ")))
+}
+
+// parseFormRecordQuery reads the list parameters: limit, cursor, sort (field, or -field for
+// descending; default -created_at), fields (a comma-separated projection), state (a
+// comma-separated list of workflow states) and filter[field] or filter[field][op] with op
+// one of eq, ne, gt, gte, lt, lte, like and in (comma-separated).
+func parseFormRecordQuery(values url.Values, columnTypes map[string]string) (*formRecordQuery, error) {
+	q := &formRecordQuery{columnTypes: columnTypes}
+
+	limit, err := parseSubmissionPageSize(values.Get("limit"))
+	if err != nil {
+		return nil, err
+	}
+	q.limit = limit
+
+	sortParam := strings.TrimSpace(values.Get("sort"))
+	if sortParam == "" {
+		sortParam = "-created_at"
+	}
+	if strings.HasPrefix(sortParam, "-") {
+		q.desc = true
+		sortParam = sortParam[1:]
+	}
+	if q.sort, _, err = q.column(sortParam); err != nil {
+		return nil, fmt.Errorf("invalid sort: %v", err)
+	}
+
+	if raw := strings.TrimSpace(values.Get("fields")); raw != "" {
+		seen := make(map[string]bool)
+		add := func(column string) {
+			if _, ok := columnTypes[column]; ok && !seen[column] {
+				seen[column] = true
+				q.fields = append(q.fields, column)
+			}
+		}
+		for _, column := range formRecordBaseFields {
+			add(column)
+		}
+		add(q.sort)
+		for _, name := range strings.Split(raw, ",") {
+			if strings.TrimSpace(name) == "" {
+				continue
+			}
+			column, _, err := q.column(name)
+			if err != nil {
+				return nil, fmt.Errorf("invalid fields: %v", err)
+			}
+			add(column)
+		}
+	}
+
+	if raw := strings.TrimSpace(values.Get("state")); raw != "" {
+		q.whereIn("current_state", splitFormRecordList(raw))
+	}
+
+	// Filters in a stable order, so equal requests build equal SQL
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		if strings.HasPrefix(key, "filter[") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		match := formRecordFilterParam.FindStringSubmatch(key)
+		if match == nil {
+			return nil, fmt.Errorf("invalid filter %q", key)
+		}
+		column, quoted, err := q.column(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter: %v", err)
+		}
+		op := match[2]
+		if op == "" {
+			op = "eq"
+		}
+		for _, value := range values[key] {
+			switch op {
+			case "like":
+				if !strings.Contains(value, "%") {
+					value = "%" + value + "%"
+				}
+				q.where = append(q.where, fmt.Sprintf("%s::text ILIKE %s", quoted, q.arg(value)))
+			case "in":
+				list := splitFormRecordList(value)
+				if len(list) > maxFormRecordInValues {
+					return nil, fmt.Errorf("filter %q has more than %d values", key, maxFormRecordInValues)
+				}
+				q.whereIn(column, list)
+			default:
+				comparison, ok := formRecordComparisons[op]
+				if !ok {
+					return nil, fmt.Errorf("unknown filter operator %q", op)
+				}
+				q.where = append(q.where, fmt.Sprintf("%s %s %s", quoted, comparison, q.typedArg(column, value)))
+			}
+		}
+	}
+
+	if raw := strings.TrimSpace(values.Get("cursor")); raw != "" {
+		cursor, err := decodeFormRecordCursor(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		if cursor.Sort != q.sort || cursor.Desc != q.desc {
+			return nil, fmt.Errorf("cursor does not match the sort")
+		}
+		q.cursor = cursor
+	}
+
+	return q, nil
+}
+
+func splitFormRecordList(raw string) []string {
+	var list []string
+	for _, value := range strings.Split(raw, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// sql builds the page query. Pages are ordered by the sort column, NULLs sorting as the
+// largest values like PostgreSQL's default so plain indexes serve both directions, with
+// the ID breaking ties. They continue after the cursor by keyset rather than offset, so
+// later pages cost the same as the first. One extra row is fetched to tell whether more
+// follow.
+func (q *formRecordQuery) sql(table string) string {
+	where := append([]string{"deleted_at IS NULL"}, q.where...)
+
+	direction, after := "ASC", ">"
+	if q.desc {
+		direction, after = "DESC", "<"
+	}
+	sortColumn, _ := formIdentifier(q.sort)
+
+	if q.cursor != nil {
+		idArg := q.typedArg("id", q.cursor.ID.String())
+		switch {
+		case q.sort == "id":
+			where = append(where, fmt.Sprintf("id %s %s", after, idArg))
+		case q.cursor.Value == nil && q.desc:
+			// NULLs came first; the non-NULL values all follow
+			where = append(where, fmt.Sprintf("((%s IS NULL AND id < %s) OR %s IS NOT NULL)", sortColumn, idArg, sortColumn))
+		case q.cursor.Value == nil:
+			where = append(where, fmt.Sprintf("(%s IS NULL AND id > %s)", sortColumn, idArg))
+		default:
+			value := q.typedArg(q.sort, *q.cursor.Value)
+			keyset := fmt.Sprintf("%s %s %s OR (%s = %s AND id %s %s)", sortColumn, after, value, sortColumn, value, after, idArg)
+			if !q.desc {
+				// NULLs come last
+				keyset += fmt.Sprintf(" OR %s IS NULL", sortColumn)
+			}
+			where = append(where, "("+keyset+")")
+		}
+	}
+
+	columns := "*"
+	if len(q.fields) > 0 {
+		quoted := make([]string, len(q.fields))
+		for i, field := range q.fields {
+			quoted[i], _ = formIdentifier(field)
+		}
+		columns = strings.Join(quoted, ", ")
+	}
+
+	order := fmt.Sprintf("%s %s, id %s", sortColumn, direction, direction)
+	if q.sort == "id" {
+		order = "id " + direction
+	}
+
+	sql := fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY %s",
+		columns, table, strings.Join(where, " AND "), order)
+	if q.limit > 0 {
+		sql += " LIMIT " + q.arg(q.limit+1)
+	}
+	return sql
+}
+
+// countSQL counts the matching records up to limit, which stays cheap on large tables.
+// It binds its own arguments, leaving q's for sql.
+func (q *formRecordQuery) countSQL(table string, limit int) (string, []interface{}) {
+	c := *q
+	c.args = append([]interface{}(nil), q.args...)
+	where := strings.Join(append([]string{"deleted_at IS NULL"}, c.where...), " AND ")
+	sql := fmt.Sprintf("SELECT COUNT(*) FROM (SELECT 1 FROM %s WHERE %s LIMIT %s) AS capped", table, where, c.arg(limit))
+	return sql, c.args
+}
+
+// scope restricts q to records in the given verticals and the caller's sites, and with
+// ?mine=true to the caller's own
+func (q *formRecordQuery) scope(r *http.Request, verticals []uuid.UUID, userID string) {
+	verticalIDs := make([]string, len(verticals))
+	for i, id := range verticals {
+		verticalIDs[i] = id.String()
+	}
+	q.whereIn("business_vertical_id", verticalIDs)
+
+	if siteIDs, restricted := middleware.GetSiteScope(r); restricted {
+		sites := make([]string, len(siteIDs))
+		for i, id := range siteIDs {
+			sites[i] = id.String()
+		}
+		q.whereIn("site_id", sites)
+	}
+	if r.URL.Query().Get("mine") == "true" {
+		q.whereIn("created_by", []string{userID})
+	}
+}
+
+// nextCursor marks the last record of a page
+func (q *formRecordQuery) nextCursor(last map[string]interface{}) string {
+	id, _ := columnUUID(last["id"])
+	cursor := formRecordCursor{Sort: q.sort, Desc: q.desc, ID: id}
+	if value := last[q.sort]; value != nil {
+		text := formRecordText(value)
+		cursor.Value = &text
+	}
+	return cursor.encode()
+}
+
+// formRecordText renders a scanned column value as text PostgreSQL casts back to the
+// column's type
+func formRecordText(value interface{}) string {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case [16]byte:
+		return uuid.UUID(v).String()
+	}
+	return columnString(value)
+}
+
+// formRecordJSON makes a scanned record JSON-friendly: UUIDs as text and JSON columns
+// as JSON rather than bytes. The search and geometry columns are dropped.
+func formRecordJSON(record map[string]interface{}) map[string]interface{} {
+	for key, value := range record {
+		if isFormDerivedColumn(key) {
+			delete(record, key)
+			continue
+		}
+		switch v := value.(type) {
+		case [16]byte:
+			record[key] = uuid.UUID(v)
+		case []byte:
+			trimmed := strings.TrimSpace(string(v))
+			if (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) && json.Valid(v) {
+				record[key] = json.RawMessage(v)
+			} else {
+				record[key] = string(v)
+			}
+		}
+	}
+	return record
+}
+
+// loadRecordsForm loads the active form named in the path, answering 404 when it is
+// missing or keeps no dedicated table
+func loadRecordsForm(w http.ResponseWriter, r *http.Request) *models.AppForm {
+	var form models.AppForm
+	if err := middleware.ScopedDB(r).Where("code = ? AND is_active = ?", mux.Vars(r)["code"], true).First(&form).Error; err != nil {
+		http.Error(w, "form not found", http.StatusNotFound)
+		return nil
+	}
+	if form.DBTableName == "" {
+		http.Error(w, "form does not keep records in a dedicated table", http.StatusNotFound)
+		return nil
+	}
+	return &form
+}
+
+// formRecordVerticals returns the verticals in the caller's data scope where the form is
+// offered and the caller holds permission: the form's required permission to write, or
+// also its read permission to read. ?business_vertical_id= or ?business_code= narrows
+// to one vertical.
+func formRecordVerticals(r *http.Request, form *models.AppForm, write bool) ([]uuid.UUID, error) {
+	scoped, err := middleware.DataScopeVerticals(r)
+	if err != nil || len(scoped) == 0 {
+		return nil, err
+	}
+
+	query := middleware.ScopedDB(r).Where("id IN ?", scoped)
+	if raw := r.URL.Query().Get("business_vertical_id"); raw != "" {
+		query = query.Where("id = ?", raw)
+	}
+	if code := r.URL.Query().Get("business_code"); code != "" {
+		query = query.Where("code = ?", code)
+	}
+	var verticals []models.BusinessVertical
+	if err := query.Find(&verticals).Error; err != nil {
+		return nil, err
+	}
+
+	var allowed []uuid.UUID
+	for _, vertical := range verticals {
+		if len(form.AccessibleVerticals) > 0 && !form.IsAccessibleInVertical(vertical.Code) {
+			continue
+		}
+		if !isPublicFormPermission(form.RequiredPermission) {
+			permissions := middleware.GetEffectivePermissionsInVertical(r, vertical.ID)
+			if !hasWorkflowPermission(permissions, form.RequiredPermission) &&
+				(write || !hasWorkflowPermission(permissions, deriveReadPermission(form.RequiredPermission))) {
+				continue
+			}
+		}
+		allowed = append(allowed, vertical.ID)
+	}
+	return allowed, nil
+}
+
+func containsVertical(verticals []uuid.UUID, id uuid.UUID) bool {
+	for _, vertical := range verticals {
+		if vertical == id {
+			return true
+		}
+	}
+	return false
+}
+
+// loadFormRecord loads a record of the form the caller may see, answering 404 otherwise
+// (including when it lies outside their scope, so its existence is not revealed)
+func loadFormRecord(w http.ResponseWriter, r *http.Request, form *models.AppForm, write bool) map[string]interface{} {
+	recordID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid record ID", http.StatusBadRequest)
+		return nil
+	}
+	record, err := NewFormTableManager().GetFormData(form.DBTableName, recordID)
+	if err != nil {
+		http.Error(w, "record not found", http.StatusNotFound)
+		return nil
+	}
+
+	if !formRecordInScope(w, r, form, record, write) {
+		return nil
+	}
+	return record
+}
+
+// formRecordInScope reports whether the caller may see the record, answering 404 when
+// they may not and 500 when their scope cannot be resolved
+func formRecordInScope(w http.ResponseWriter, r *http.Request, form *models.AppForm, record map[string]interface{}, write bool) bool {
+	verticalID, _ := columnUUID(record["business_vertical_id"])
+	var siteID *uuid.UUID
+	if id, ok := columnUUID(record["site_id"]); ok {
+		siteID = &id
+	}
+	verticals, err := formRecordVerticals(r, form, write)
+	if err != nil {
+		log.Printf("❌ Failed to resolve verticals for form %s: %v", form.Code, err)
+		http.Error(w, "failed to load record", http.StatusInternalServerError)
+		return false
+	}
+	if !containsVertical(verticals, verticalID) || !middleware.InDataScope(r, verticalID, siteID) {
+		http.Error(w, "record not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// ListFormRecords lists a form's records across the verticals the caller may read, with
+// filters, sorting, field projection and cursor pagination (see parseFormRecordQuery).
+// ?mine=true keeps the caller's own records. Pages carry no total count, which would
+// scan the whole table; has_more and next_cursor drive infinite scrolling instead.
+// GET /api/v1/forms/{code}/records
+func ListFormRecords(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	form := loadRecordsForm(w, r)
+	if form == nil {
+		return
+	}
+
+	tableManager := NewFormTableManager()
+	table, err := tableManager.qualifiedTableName("", form.DBTableName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	columnTypes, err := tableManager.tableColumnTypes("", form.DBTableName)
+	if err != nil {
+		log.Printf("❌ Failed to read columns of %s: %v", form.DBTableName, err)
+		http.Error(w, "failed to load records", http.StatusInternalServerError)
+		return
+	}
+	empty := map[string]interface{}{
+		"records":     []map[string]interface{}{},
+		"count":       0,
+		"has_more":    false,
+		"next_cursor": "",
+	}
+	if len(columnTypes) == 0 {
+		// The table is created with the form's first record
+		writeJSON(w, http.StatusOK, empty)
+		return
+	}
+
+	query, err := parseFormRecordQuery(r.URL.Query(), columnTypes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	empty["limit"] = query.limit
+
+	verticals, err := formRecordVerticals(r, form, false)
+	if err != nil {
+		log.Printf("❌ Failed to resolve verticals for form %s: %v", form.Code, err)
+		http.Error(w, "failed to load records", http.StatusInternalServerError)
+		return
+	}
+	if len(verticals) == 0 {
+		writeJSON(w, http.StatusOK, empty)
+		return
+	}
+	query.scope(r, verticals, claims.UserID)
+
+	rows, err := middleware.WithDataScope(r, tableManager.db).Raw(query.sql(table), query.args...).Rows()
+	if err != nil {
+		log.Printf("❌ Failed to list records of %s: %v", form.Code, err)
+		http.Error(w, "failed to load records", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	columns, _ := rows.Columns()
+	records := make([]map[string]interface{}, 0, query.limit+1)
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		valuePtrs := make([]interface{}, len(columns))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			log.Printf("❌ Failed to scan record of %s: %v", form.Code, err)
+			http.Error(w, "failed to load records", http.StatusInternalServerError)
+			return
+		}
+		record := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			record[column] = values[i]
+		}
+		records = append(records, record)
+	}
+
+	hasMore := len(records) > query.limit
+	nextCursor := ""
+	if hasMore {
+		records = records[:query.limit]
+		nextCursor = query.nextCursor(records[len(records)-1])
+	}
+	attachFormRecordFiles(form, records)
+	for _, record := range records {
+		formRecordJSON(record)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"records":     records,
+		"count":       len(records),
+		"limit":       query.limit,
+		"has_more":    hasMore,
+		"next_cursor": nextCursor,
+	})
+}
+
+// GetFormRecord returns one record of a form
+// GET /api/v1/forms/{code}/records/{id}
+func GetFormRecord(w http.ResponseWriter, r *http.Request) {
+	if middleware.GetClaims(r) == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	form := loadRecordsForm(w, r)
+	if form == nil {
+		return
+	}
+	record := loadFormRecord(w, r, form, false)
+	if record == nil {
+		return
+	}
+	attachFormRecordFiles(form, []map[string]interface{}{record})
+	formRecordJSON(record)
+	if err := attachFormRecordSections(form, record); err != nil {
+		log.Printf("❌ Failed to load sections of record %v: %v", record["id"], err)
+		http.Error(w, "failed to load record", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"record": record})
+}
+
+// CreateFormRecord creates a record of a form in the vertical given by business_vertical_id
+// or business_code (either may be left out when the caller can write in only one). The
+// record goes through the same validation, workflow and hooks as dedicated submissions.
+// Clients may choose the record's ID; one that is taken answers 409.
+// POST /api/v1/forms/{code}/records
+func CreateFormRecord(w http.ResponseWriter, r *http.Request) {
+	createFormRecord(w, r, false)
+}
+
+// createFormRecord creates a record from the request, as a draft when draft is set
+func createFormRecord(w http.ResponseWriter, r *http.Request, draft bool) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	form := loadRecordsForm(w, r)
+	if form == nil {
+		return
+	}
+
+	var req struct {
+		ID                 uuid.UUID              `json:"id,omitempty"` // chosen by offline clients; generated when left out
+		BusinessVerticalID *uuid.UUID             `json:"business_vertical_id,omitempty"`
+		BusinessCode       string                 `json:"business_code,omitempty"`
+		SiteID             *uuid.UUID             `json:"site_id,omitempty"`
+		FormData           map[string]interface{} `json:"form_data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.FormData == nil {
+		req.FormData = map[string]interface{}{}
+	}
+
+	// The vertical may come in the body as well as the query
+	query := r.URL.Query()
+	if req.BusinessVerticalID != nil {
+		query.Set("business_vertical_id", req.BusinessVerticalID.String())
+	}
+	if req.BusinessCode != "" {
+		query.Set("business_code", req.BusinessCode)
+	}
+	r.URL.RawQuery = query.Encode()
+
+	verticals, err := formRecordVerticals(r, form, true)
+	if err != nil {
+		log.Printf("❌ Failed to resolve verticals for form %s: %v", form.Code, err)
+		http.Error(w, "failed to create record", http.StatusInternalServerError)
+		return
+	}
+	switch {
+	case len(verticals) == 0:
+		http.Error(w, "no permission to create records of this form", http.StatusForbidden)
+		return
+	case len(verticals) > 1:
+		http.Error(w, "business_vertical_id or business_code is required", http.StatusBadRequest)
+		return
+	}
+	if !middleware.InDataScope(r, verticals[0], req.SiteID) {
+		http.Error(w, "no access to this site", http.StatusForbidden)
+		return
+	}
+
+	engine := getWorkflowEngineDedicated()
+	submission, err := engine.createSubmissionDedicated(form.Code, req.ID, verticals[0], req.SiteID, req.FormData, claims.UserID, draft)
+	if err != nil {
+		log.Printf("❌ Error creating record of %s: %v", form.Code, err)
+		if writeFormValidationError(w, err) {
+			return
+		}
+		if utils.IsUniqueViolation(err) {
+			http.Error(w, "a record with this ID already exists", http.StatusConflict)
+			return
+		}
+		if errors.Is(err, errInvalidFormField) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "failed to create record", http.StatusInternalServerError)
+		return
+	}
+	if !draft {
+		triggerDedicatedFormSubmissionWebhook(submission)
+	}
+
+	record, err := engine.tableManager.GetFormData(form.DBTableName, submission.ID)
+	if err != nil {
+		http.Error(w, "failed to load created record", http.StatusInternalServerError)
+		return
+	}
+	if draft {
+		writeJSON(w, http.StatusCreated, formDraftResponse(form, record))
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"record": formRecordJSON(record)})
+}
+
+// UpdateFormRecord updates the data of a draft record
+// PUT /api/v1/forms/{code}/records/{id}
+func UpdateFormRecord(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	form := loadRecordsForm(w, r)
+	if form == nil {
+		return
+	}
+	existing := loadFormRecord(w, r, form, true)
+	if existing == nil {
+		return
+	}
+
+	var req struct {
+		FormData map[string]interface{} `json:"form_data"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.FormData) == 0 {
+		http.Error(w, "form_data is required", http.StatusBadRequest)
+		return
+	}
+
+	recordID, _ := columnUUID(existing["id"])
+	engine := getWorkflowEngineDedicated()
+	if _, err := engine.UpdateSubmissionDataDedicated(form.Code, recordID, req.FormData, claims.UserID); err != nil {
+		log.Printf("❌ Error updating record %s of %s: %v", recordID, form.Code, err)
+		if writeFormValidationError(w, err) {
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	record, err := engine.tableManager.GetFormData(form.DBTableName, recordID)
+	if err != nil {
+		http.Error(w, "failed to load updated record", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"record": formRecordJSON(record)})
+}
+
+// DeleteFormRecord soft deletes a record
+// DELETE /api/v1/forms/{code}/records/{id}
+func DeleteFormRecord(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	form := loadRecordsForm(w, r)
+	if form == nil {
+		return
+	}
+	existing := loadFormRecord(w, r, form, true)
+	if existing == nil {
+		return
+	}
+
+	recordID, _ := columnUUID(existing["id"])
+	if err := getWorkflowEngineDedicated().DeleteSubmissionDedicated(form.Code, recordID, claims.UserID); err != nil {
+		log.Printf("❌ Error deleting record %s of %s: %v", recordID, form.Code, err)
+		http.Error(w, "failed to delete record", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "record deleted", "id": recordID})
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/formvalidation"
+)
+
+// errDestructiveFormSchemaChange is returned when a form change would drop columns and the
+// caller did not allow it
+var errDestructiveFormSchemaChange = errors.New("this change removes fields and would drop their columns with all recorded data; resend with ?allow_destructive=true to apply it")
+
+// formTableBaseColumns are the columns every dedicated form table has; form fields never
+// add, retype or drop them
+var formTableBaseColumns = map[string]bool{
+	"id": true, "created_by": true, "created_at": true,
+	"updated_by": true, "updated_at": true, "deleted_by": true, "deleted_at": true,
+	"business_vertical_id": true, "site_id": true,
+	"workflow_id": true, "workflow_version": true, "current_state": true,
+	"form_id": true, "form_code": true,
+}
+
+// formFields returns the form's field definitions, read from the form schema or else from
+// the steps, as table creation does
+func (ftm *FormTableManager) formFields(form *models.AppForm) ([]map[string]interface{}, error) {
+	if len(form.FormSchema) > 0 && string(form.FormSchema) != "{}" {
+		var formSchema struct {
+			Fields []map[string]interface{} `json:"fields"`
+		}
+		if err := json.Unmarshal(form.FormSchema, &formSchema); err != nil {
+			return nil, fmt.Errorf("failed to parse form schema: %v", err)
+		}
+		return formSchema.Fields, nil
+	}
+	if len(form.Steps) > 0 && string(form.Steps) != "[]" {
+		fields, err := ftm.ExtractFieldsFromSteps(form.Steps)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract fields from steps: %v", err)
+		}
+		return fields, nil
+	}
+	return nil, nil
+}
+
+// FormColumns returns the columns the form's fields give its dedicated table
+func (ftm *FormTableManager) FormColumns(form *models.AppForm) ([]models.FormColumn, error) {
+	fields, err := ftm.formFields(form)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]models.FormColumn, 0, len(fields))
+	for _, field := range fields {
+		if field["type"] == formvalidation.SectionType {
+			// Repeatable sections have tables of their own
+			continue
+		}
+		column, ok := formFieldColumn(field)
+		if !ok {
+			if name, _ := field["name"].(string); name != "" {
+				return nil, fmt.Errorf("%w: %q is not a valid column name", errInvalidFormField, name)
+			}
+			continue
+		}
+		if formTableBaseColumns[column.Name] {
+			continue
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// MigrateFormTable applies diff, computed from the form's columns before an update, to the
+// form's dedicated table within tx and records the result as the form's next schema
+// version. Added fields become nullable columns, since existing rows have no value for
+// them. A retyped field's column is renamed to keep its data and a new column of the new
+// type takes the field's name; values are copied over only when the new type is TEXT, the
+// one conversion that cannot fail. Removed fields drop their columns, which needs
+// allowDestructive. Forms whose table does not exist yet only get the version recorded.
+func (ftm *FormTableManager) MigrateFormTable(tx *gorm.DB, form *models.AppForm, schemaName string, from []models.FormColumn, diff models.FormSchemaDiff, allowDestructive bool, userID string) (models.FormSchemaDiff, error) {
+	if diff.Empty() {
+		return diff, nil
+	}
+	if diff.Destructive() && !allowDestructive {
+		return diff, errDestructiveFormSchemaChange
+	}
+	if form.SchemaVersion < 1 {
+		form.SchemaVersion = 1
+	}
+
+	exists, err := ftm.TableExistsInSchema(schemaName, form.DBTableName)
+	if err != nil {
+		return diff, fmt.Errorf("failed to check form table: %v", err)
+	}
+	if exists {
+		fullTableName, err := ftm.qualifiedTableName(schemaName, form.DBTableName)
+		if err != nil {
+			return diff, err
+		}
+		existing, err := ftm.tableColumns(schemaName, form.DBTableName)
+		if err != nil {
+			return diff, err
+		}
+		// Field names were validated when the columns were derived
+		quote := func(name string) string {
+			quoted, _ := formIdentifier(name)
+			return quoted
+		}
+
+		// The search column depends on the field columns and is rebuilt from them below, as
+		// are the geometry columns of location fields
+		statements := []string{fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS %s", fullTableName, formSearchColumn)}
+		for _, c := range diff.Added {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", fullTableName, quote(c.Name), c.Type))
+		}
+		retyped := make([]models.FormColumnChange, len(diff.Retyped))
+		for i, c := range diff.Retyped {
+			if existing[c.Name] {
+				c.ArchivedAs = models.ArchivedFormColumnName(c.Name, form.SchemaVersion)
+				statements = append(statements,
+					fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s", fullTableName, quote(c.Name), quote(c.ArchivedAs)),
+					fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", fullTableName, quote(c.Name), c.To))
+				if strings.EqualFold(c.To, "TEXT") {
+					statements = append(statements, fmt.Sprintf("UPDATE %s SET %s = %s::text", fullTableName, quote(c.Name), quote(c.ArchivedAs)))
+				}
+			} else {
+				statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", fullTableName, quote(c.Name), c.To))
+			}
+			retyped[i] = c
+		}
+		diff.Retyped = retyped
+		for _, c := range diff.Removed {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS %s", fullTableName, quote(c.Name)))
+		}
+
+		for _, statement := range statements {
+			log.Printf("🔧 %s", statement)
+			if err := tx.Exec(statement).Error; err != nil {
+				return diff, fmt.Errorf("failed to alter form table: %v", err)
+			}
+		}
+		if err := ftm.withDB(tx).ensureSearchVector(schemaName, form.DBTableName); err != nil {
+			return diff, err
+		}
+		if err := ftm.withDB(tx).ensureGeoColumns(schemaName, form.DBTableName, formGeoPointColumns(form)); err != nil {
+			return diff, err
+		}
+		if err := ftm.withDB(tx).ensureSectionTables(schemaName, form); err != nil {
+			return diff, err
+		}
+	}
+
+	// Forms edited before versioning have no record of the columns they started from
+	var recorded int64
+	if err := tx.Model(&models.FormSchemaVersion{}).
+		Where("form_id = ? AND version = ?", form.ID, form.SchemaVersion).
+		Count(&recorded).Error; err != nil {
+		return diff, err
+	}
+	if recorded == 0 {
+		previousColumns, _ := json.Marshal(from)
+		if err := tx.Create(&models.FormSchemaVersion{
+			FormID:    form.ID,
+			Version:   form.SchemaVersion,
+			Columns:   previousColumns,
+			CreatedBy: form.CreatedBy,
+		}).Error; err != nil {
+			return diff, fmt.Errorf("failed to record form schema version: %v", err)
+		}
+	}
+
+	columns, err := ftm.FormColumns(form)
+	if err != nil {
+		return diff, err
+	}
+	columnsJSON, _ := json.Marshal(columns)
+	changesJSON, _ := json.Marshal(diff)
+	version := models.FormSchemaVersion{
+		FormID:      form.ID,
+		Version:     form.SchemaVersion + 1,
+		Columns:     columnsJSON,
+		Changes:     changesJSON,
+		Destructive: diff.Destructive(),
+		CreatedBy:   userID,
+	}
+	if err := tx.Create(&version).Error; err != nil {
+		return diff, fmt.Errorf("failed to record form schema version: %v", err)
+	}
+	form.SchemaVersion = version.Version
+	return diff, nil
+}
+
+// ListFormSchemaVersions returns the schema history of a form's dedicated table, newest first
+// GET /api/v1/admin/app-forms/{formCode}/schema-versions
+func ListFormSchemaVersions(w http.ResponseWriter, r *http.Request) {
+	var form models.AppForm
+	if err := middleware.ScopedDB(r).Where("code = ?", mux.Vars(r)["formCode"]).First(&form).Error; err != nil {
+		http.Error(w, "form not found", http.StatusNotFound)
+		return
+	}
+
+	var versions []models.FormSchemaVersion
+	if err := middleware.ScopedDB(r).Where("form_id = ?", form.ID).Order("version DESC").Find(&versions).Error; err != nil {
+		http.Error(w, "failed to load schema versions", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"form_code":      form.Code,
+		"schema_version": form.SchemaVersion,
+		"versions":       versions,
+	})
+}
@@ -0,0 +1,693 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/formvalidation"
+)
+
+// formSectionBaseColumns are the columns every section table has besides the section's fields
+var formSectionBaseColumns = map[string]bool{
+	"id": true, "parent_id": true, "position": true,
+	"created_by": true, "created_at": true, "updated_by": true, "updated_at": true,
+	"deleted_by": true, "deleted_at": true,
+}
+
+// formSection is a repeatable section of a form, such as the pipe segments of an
+// inspection. Its rows live in a child table named after the form table and the field
+// ("water_inspection__segments"), each row pointing at its record.
+type formSection struct {
+	Field   formvalidation.Field
+	Column  string
+	Table   string
+	Columns []models.FormColumn
+}
+
+// formSections returns the form's repeatable sections with the columns of their fields
+func formSections(form *models.AppForm) []formSection {
+	var sections []formSection
+	defs, _ := formvalidation.FieldDefinitions(form.FormSchema, form.Steps)
+	for _, field := range formvalidation.FromForm(form.FormSchema, form.Steps, nil).Fields {
+		if field.Section == nil {
+			continue
+		}
+		column := formColumnName(field.Name)
+		section := formSection{Field: field, Column: column, Table: form.DBTableName + "__" + column}
+		for _, def := range defs {
+			if def["name"] != field.Name && def["id"] != field.Name {
+				continue
+			}
+			subFields, _ := def["fields"].([]interface{})
+			for _, raw := range subFields {
+				subDef, ok := raw.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if _, named := subDef["name"].(string); !named {
+					// Step fields are named by id
+					subDef = map[string]interface{}{"name": subDef["id"], "type": subDef["type"], "max_length": subDef["max_length"]}
+				}
+				subColumn, ok := formFieldColumn(subDef)
+				if !ok || formSectionBaseColumns[subColumn.Name] {
+					continue
+				}
+				section.Columns = append(section.Columns, subColumn)
+			}
+			break
+		}
+		sections = append(sections, section)
+	}
+	return sections
+}
+
+// findFormSection returns the form's section with the given field or column name
+func findFormSection(form *models.AppForm, name string) (formSection, bool) {
+	for _, section := range formSections(form) {
+		if section.Field.Name == name || section.Column == name {
+			return section, true
+		}
+	}
+	return formSection{}, false
+}
+
+// takeFormSectionRows removes the rows of the form's sections from data, which keeps the
+// record's own fields, and returns them by section field. A section sent as null is
+// returned with no rows, clearing it.
+func takeFormSectionRows(form *models.AppForm, data map[string]interface{}) map[string][]interface{} {
+	var taken map[string][]interface{}
+	for _, section := range formSections(form) {
+		value, ok := data[section.Field.Name]
+		if !ok {
+			continue
+		}
+		delete(data, section.Field.Name)
+		if taken == nil {
+			taken = make(map[string][]interface{})
+		}
+		rows, _ := value.([]interface{})
+		taken[section.Field.Name] = rows
+	}
+	return taken
+}
+
+// ensureSectionTable creates the section's table when it is missing, or adds columns for
+// fields added to the section since. Rows are deleted with their record by the foreign
+// key; soft deletes are cascaded by SoftDeleteFormDataInSchema. Fields are not required
+// at the column level, as drafts may leave them empty.
+func (ftm *FormTableManager) ensureSectionTable(schemaName, parentTable string, section formSection) error {
+	parent, err := ftm.qualifiedTableName(schemaName, parentTable)
+	if err != nil {
+		return err
+	}
+	table, err := ftm.qualifiedTableName(schemaName, section.Table)
+	if err != nil {
+		return fmt.Errorf("section %s: %v", section.Field.Name, err)
+	}
+	exists, err := ftm.TableExistsInSchema(schemaName, strings.ToLower(section.Table))
+	if err != nil {
+		return fmt.Errorf("failed to check section table: %v", err)
+	}
+
+	if !exists {
+		columns := []string{
+			"id UUID PRIMARY KEY DEFAULT gen_random_uuid()",
+			fmt.Sprintf("parent_id UUID NOT NULL REFERENCES %s(id) ON DELETE CASCADE", parent),
+			"position INTEGER NOT NULL DEFAULT 0",
+			"created_by VARCHAR(255) NOT NULL",
+			"created_at TIMESTAMP NOT NULL DEFAULT NOW()",
+			"updated_by VARCHAR(255)",
+			"updated_at TIMESTAMP DEFAULT NOW()",
+			"deleted_by VARCHAR(255)",
+			"deleted_at TIMESTAMP",
+		}
+		for _, column := range section.Columns {
+			quoted, _ := formIdentifier(column.Name)
+			columns = append(columns, quoted+" "+column.Type)
+		}
+		if err := ftm.db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n  %s\n)", table, strings.Join(columns, ",\n  "))).Error; err != nil {
+			return fmt.Errorf("failed to create section table %s: %v", section.Table, err)
+		}
+		indexPrefix := strings.ReplaceAll(strings.ReplaceAll(table, `"`, ""), ".", "_")
+		if err := ftm.db.Exec(fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "idx_%s_parent" ON %s (parent_id, position) WHERE deleted_at IS NULL`,
+			indexPrefix, table)).Error; err != nil {
+			return fmt.Errorf("failed to index section table %s: %v", section.Table, err)
+		}
+		log.Printf("✅ Created section table: %s", section.Table)
+		return nil
+	}
+
+	existing, err := ftm.tableColumns(schemaName, section.Table)
+	if err != nil {
+		return err
+	}
+	for _, column := range section.Columns {
+		if existing[column.Name] {
+			continue
+		}
+		quoted, _ := formIdentifier(column.Name)
+		statement := fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", table, quoted, column.Type)
+		log.Printf("🔧 %s", statement)
+		if err := ftm.db.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to alter section table %s: %v", section.Table, err)
+		}
+	}
+	return nil
+}
+
+// ensureSectionTables creates or updates the tables of the form's sections
+func (ftm *FormTableManager) ensureSectionTables(schemaName string, form *models.AppForm) error {
+	for _, section := range formSections(form) {
+		if err := ftm.ensureSectionTable(schemaName, form.DBTableName, section); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sectionTables returns the quoted names of the section tables of a form table
+func (ftm *FormTableManager) sectionTables(schemaName, tableName string) ([]string, error) {
+	schema := schemaName
+	if schema == "" {
+		schema = "public"
+	}
+	pattern := strings.ReplaceAll(strings.ToLower(tableName), "_", `\_`) + `\_\_%`
+	var names []string
+	if err := ftm.db.Raw("SELECT table_name FROM information_schema.tables WHERE table_schema = ? AND table_name LIKE ?",
+		strings.ToLower(schema), pattern).Scan(&names).Error; err != nil {
+		return nil, fmt.Errorf("failed to find section tables of %s: %v", tableName, err)
+	}
+	tables := make([]string, 0, len(names))
+	for _, name := range names {
+		table, err := ftm.qualifiedTableName(schemaName, name)
+		if err != nil {
+			continue
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+// deleteSectionRows soft deletes the live section rows of a record, stamping them with
+// the record's own deletion time so restoring the record brings them back
+func (ftm *FormTableManager) deleteSectionRows(schemaName, tableName string, recordID uuid.UUID, deletedAt time.Time, userID string) error {
+	tables, err := ftm.sectionTables(schemaName, tableName)
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		if err := ftm.db.Exec(fmt.Sprintf("UPDATE %s SET deleted_at = $1, deleted_by = $2, updated_at = $1 WHERE parent_id = $3 AND deleted_at IS NULL", table),
+			deletedAt, userID, recordID).Error; err != nil {
+			return fmt.Errorf("failed to delete section rows: %v", err)
+		}
+	}
+	return nil
+}
+
+// restoreSectionRows brings back the section rows deleted along with a record that is
+// about to be restored. Rows deleted on their own before the record stay deleted.
+func (ftm *FormTableManager) restoreSectionRows(schemaName, tableName string, recordID uuid.UUID, userID string) error {
+	parent, err := ftm.qualifiedTableName(schemaName, tableName)
+	if err != nil {
+		return err
+	}
+	tables, err := ftm.sectionTables(schemaName, tableName)
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		if err := ftm.db.Exec(fmt.Sprintf(
+			"UPDATE %s SET deleted_at = NULL, deleted_by = NULL, updated_at = $1, updated_by = $2 WHERE parent_id = $3 AND deleted_at = (SELECT deleted_at FROM %s WHERE id = $3)",
+			table, parent), time.Now(), userID, recordID).Error; err != nil {
+			return fmt.Errorf("failed to restore section rows: %v", err)
+		}
+	}
+	return nil
+}
+
+// sectionRows returns the live rows of a record's section in order
+func (ftm *FormTableManager) sectionRows(schemaName string, section formSection, recordID uuid.UUID) ([]map[string]interface{}, error) {
+	exists, err := ftm.TableExistsInSchema(schemaName, strings.ToLower(section.Table))
+	if err != nil || !exists {
+		return nil, err
+	}
+	table, err := ftm.qualifiedTableName(schemaName, section.Table)
+	if err != nil {
+		return nil, err
+	}
+	var rows []map[string]interface{}
+	if err := ftm.db.Raw(fmt.Sprintf("SELECT * FROM %s WHERE parent_id = $1 AND deleted_at IS NULL ORDER BY position, created_at, id", table),
+		recordID).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to load rows of %s: %v", section.Field.Name, err)
+	}
+	return rows, nil
+}
+
+// sectionRow returns a live row of a record's section, or nil when there is none
+func (ftm *FormTableManager) sectionRow(schemaName string, section formSection, recordID, rowID uuid.UUID) (map[string]interface{}, error) {
+	exists, err := ftm.TableExistsInSchema(schemaName, strings.ToLower(section.Table))
+	if err != nil || !exists {
+		return nil, err
+	}
+	table, err := ftm.qualifiedTableName(schemaName, section.Table)
+	if err != nil {
+		return nil, err
+	}
+	return formRecordRow(ftm.db, fmt.Sprintf("SELECT * FROM %s WHERE id = $1 AND parent_id = $2 AND deleted_at IS NULL", table), rowID, recordID)
+}
+
+// sectionRowData keeps the keys of a row's data that are section fields, so base columns
+// cannot be set through it
+func sectionRowData(row map[string]interface{}) map[string]interface{} {
+	data := make(map[string]interface{}, len(row))
+	for key, value := range row {
+		if !formSectionBaseColumns[formColumnName(key)] {
+			data[key] = value
+		}
+	}
+	return data
+}
+
+// insertSectionRow adds a row to a record's section and returns its ID
+func (ftm *FormTableManager) insertSectionRow(schemaName string, section formSection, recordID uuid.UUID, position int, row map[string]interface{}, userID string) (uuid.UUID, error) {
+	table, err := ftm.qualifiedTableName(schemaName, section.Table)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	data := sectionRowData(row)
+	now := time.Now()
+	data["parent_id"] = recordID
+	data["position"] = position
+	data["created_by"] = userID
+	data["created_at"] = now
+	data["updated_at"] = now
+
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	quoted, err := ftm.formDataColumns(schemaName, section.Table, keys)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	columns := make([]string, len(keys))
+	placeholders := make([]string, len(keys))
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		columns[i] = quoted[key]
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		values[i] = data[key]
+	}
+
+	var rowID uuid.UUID
+	if err := ftm.db.Raw(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING id", table, strings.Join(columns, ", "), strings.Join(placeholders, ", ")),
+		values...).Row().Scan(&rowID); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to insert %s row: %w", section.Field.Name, err)
+	}
+	return rowID, nil
+}
+
+// updateSectionRow changes the fields of a section row given in row, and its position
+// when one is given
+func (ftm *FormTableManager) updateSectionRow(schemaName string, section formSection, rowID uuid.UUID, position *int, row map[string]interface{}, userID string) error {
+	table, err := ftm.qualifiedTableName(schemaName, section.Table)
+	if err != nil {
+		return err
+	}
+	data := sectionRowData(row)
+	if position != nil {
+		data["position"] = *position
+	}
+	data["updated_by"] = userID
+	data["updated_at"] = time.Now()
+
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	quoted, err := ftm.formDataColumns(schemaName, section.Table, keys)
+	if err != nil {
+		return err
+	}
+	setClauses := make([]string, len(keys))
+	values := make([]interface{}, 0, len(keys)+1)
+	for i, key := range keys {
+		setClauses[i] = fmt.Sprintf("%s = $%d", quoted[key], i+1)
+		values = append(values, data[key])
+	}
+	values = append(values, rowID)
+	if err := ftm.db.Exec(fmt.Sprintf("UPDATE %s SET %s WHERE id = $%d AND deleted_at IS NULL", table, strings.Join(setClauses, ", "), len(values)),
+		values...).Error; err != nil {
+		return fmt.Errorf("failed to update %s row: %w", section.Field.Name, err)
+	}
+	return nil
+}
+
+// replaceSectionRows replaces the rows of each of the record's sections given in rows, in
+// the order given. The rows replaced are soft deleted.
+func (ftm *FormTableManager) replaceSectionRows(schemaName string, form *models.AppForm, recordID uuid.UUID, rows map[string][]interface{}, userID string) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for _, section := range formSections(form) {
+		sectionRows, ok := rows[section.Field.Name]
+		if !ok {
+			continue
+		}
+		if err := ftm.ensureSectionTable(schemaName, form.DBTableName, section); err != nil {
+			return err
+		}
+		table, err := ftm.qualifiedTableName(schemaName, section.Table)
+		if err != nil {
+			return err
+		}
+		if err := ftm.db.Exec(fmt.Sprintf("UPDATE %s SET deleted_at = $1, deleted_by = $2, updated_at = $1 WHERE parent_id = $3 AND deleted_at IS NULL", table),
+			now, userID, recordID).Error; err != nil {
+			return fmt.Errorf("failed to replace %s rows: %v", section.Field.Name, err)
+		}
+		for i, raw := range sectionRows {
+			row, _ := raw.(map[string]interface{})
+			if _, err := ftm.insertSectionRow(schemaName, section, recordID, i, row, userID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// touchFormRecord marks a record as changed when its section rows change, so clients
+// syncing changes pick the record up again
+func (ftm *FormTableManager) touchFormRecord(schemaName, tableName string, recordID uuid.UUID, userID string) error {
+	table, err := ftm.qualifiedTableName(schemaName, tableName)
+	if err != nil {
+		return err
+	}
+	return ftm.db.Exec(fmt.Sprintf("UPDATE %s SET updated_at = $1, updated_by = $2 WHERE id = $3", table), time.Now(), userID, recordID).Error
+}
+
+// attachFormRecordSections adds each of the form's sections to the record, keyed by the
+// section's column, as the list of its rows. The record should have been through
+// formRecordJSON already.
+func attachFormRecordSections(form *models.AppForm, record map[string]interface{}) error {
+	sections := formSections(form)
+	if len(sections) == 0 {
+		return nil
+	}
+	recordID, _ := columnUUID(record["id"])
+	if id, ok := record["id"].(uuid.UUID); ok {
+		recordID = id
+	}
+	tableManager := NewFormTableManager()
+	for _, section := range sections {
+		rows, err := tableManager.sectionRows("", section, recordID)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			formRecordJSON(row)
+		}
+		if rows == nil {
+			rows = []map[string]interface{}{}
+		}
+		record[section.Column] = rows
+	}
+	return nil
+}
+
+// loadFormRecordSection loads the form, the section and the record named in the path,
+// answering 404 when one is missing or the record is outside the caller's scope. Changing
+// rows needs write access and a draft record, as updating the record does.
+func loadFormRecordSection(w http.ResponseWriter, r *http.Request, write bool) (*models.AppForm, *formSection, uuid.UUID) {
+	form := loadRecordsForm(w, r)
+	if form == nil {
+		return nil, nil, uuid.Nil
+	}
+	section, ok := findFormSection(form, mux.Vars(r)["section"])
+	if !ok {
+		http.Error(w, "section not found", http.StatusNotFound)
+		return nil, nil, uuid.Nil
+	}
+	record := loadFormRecord(w, r, form, write)
+	if record == nil {
+		return nil, nil, uuid.Nil
+	}
+	if state := columnString(record["current_state"]); write && state != formDraftState {
+		http.Error(w, fmt.Sprintf("cannot change %s of a record in state '%s' - only draft records can be edited", section.Field.Name, state), http.StatusBadRequest)
+		return nil, nil, uuid.Nil
+	}
+	recordID, _ := columnUUID(record["id"])
+	return form, &section, recordID
+}
+
+// writeFormSectionError answers a failed row change
+func writeFormSectionError(w http.ResponseWriter, err error, action string) {
+	if writeFormValidationError(w, err) {
+		return
+	}
+	if errors.Is(err, errInvalidFormField) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("❌ Failed to %s section row: %v", action, err)
+	http.Error(w, fmt.Sprintf("failed to %s row", action), http.StatusInternalServerError)
+}
+
+// formSectionRowRequest is the body of a row change: the row's field values and,
+// optionally, its position among the section's rows
+type formSectionRowRequest struct {
+	Position *int                   `json:"position,omitempty"`
+	Data     map[string]interface{} `json:"data"`
+}
+
+// ListFormSectionRows returns the rows of a record's repeatable section in order
+// GET /api/v1/forms/{code}/records/{id}/sections/{section}
+func ListFormSectionRows(w http.ResponseWriter, r *http.Request) {
+	if middleware.GetClaims(r) == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	_, section, recordID := loadFormRecordSection(w, r, false)
+	if section == nil {
+		return
+	}
+	rows, err := NewFormTableManager().sectionRows("", *section, recordID)
+	if err != nil {
+		log.Printf("❌ Failed to list %s rows of %s: %v", section.Field.Name, recordID, err)
+		http.Error(w, "failed to load rows", http.StatusInternalServerError)
+		return
+	}
+	for _, row := range rows {
+		formRecordJSON(row)
+	}
+	if rows == nil {
+		rows = []map[string]interface{}{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"section": section.Field.Name, "rows": rows, "count": len(rows)})
+}
+
+// GetFormSectionRow returns one row of a record's repeatable section
+// GET /api/v1/forms/{code}/records/{id}/sections/{section}/{row}
+func GetFormSectionRow(w http.ResponseWriter, r *http.Request) {
+	if middleware.GetClaims(r) == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	_, section, recordID := loadFormRecordSection(w, r, false)
+	if section == nil {
+		return
+	}
+	rowID, err := uuid.Parse(mux.Vars(r)["row"])
+	if err != nil {
+		http.Error(w, "invalid row ID", http.StatusBadRequest)
+		return
+	}
+	row, err := NewFormTableManager().sectionRow("", *section, recordID, rowID)
+	if err != nil || row == nil {
+		http.Error(w, "row not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"row": formRecordJSON(row)})
+}
+
+// CreateFormSectionRow adds a row to a draft record's repeatable section, after the last
+// row unless a position is given. The row is validated as a draft's values are; the
+// section's minimum number of rows is checked when the draft is submitted.
+// POST /api/v1/forms/{code}/records/{id}/sections/{section}
+func CreateFormSectionRow(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	form, section, recordID := loadFormRecordSection(w, r, true)
+	if section == nil {
+		return
+	}
+	var req formSectionRowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Data == nil {
+		req.Data = map[string]interface{}{}
+	}
+	if errs := section.Field.Section.Validate(req.Data, formvalidation.Options{Partial: true}); len(errs) > 0 {
+		writeFormValidationError(w, errs)
+		return
+	}
+
+	tableManager := NewFormTableManager()
+	var rowID uuid.UUID
+	err := middleware.WithDataScope(r, tableManager.db).Transaction(func(tx *gorm.DB) error {
+		tm := tableManager.withDB(tx)
+		if err := tm.ensureSectionTable("", form.DBTableName, *section); err != nil {
+			return err
+		}
+		table, err := tm.qualifiedTableName("", section.Table)
+		if err != nil {
+			return err
+		}
+		var stats struct {
+			Count int64
+			Last  int
+		}
+		if err := tx.Raw(fmt.Sprintf("SELECT COUNT(*) AS count, COALESCE(MAX(position), -1) AS last FROM %s WHERE parent_id = $1 AND deleted_at IS NULL", table),
+			recordID).Scan(&stats).Error; err != nil {
+			return err
+		}
+		if limit := section.Field.Max; limit != nil && float64(stats.Count) >= *limit {
+			return formvalidation.Errors{{Field: section.Field.Name, Code: "max", Message: fmt.Sprintf("%s allows at most %v rows", section.Field.Name, *limit)}}
+		}
+		position := stats.Last + 1
+		if req.Position != nil {
+			position = *req.Position
+		}
+		if rowID, err = tm.insertSectionRow("", *section, recordID, position, req.Data, claims.UserID); err != nil {
+			return err
+		}
+		return tm.touchFormRecord("", form.DBTableName, recordID, claims.UserID)
+	})
+	if err != nil {
+		writeFormSectionError(w, err, "create")
+		return
+	}
+
+	row, err := tableManager.sectionRow("", *section, recordID, rowID)
+	if err != nil || row == nil {
+		http.Error(w, "failed to load created row", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"row": formRecordJSON(row)})
+}
+
+// UpdateFormSectionRow changes the given fields of a row of a draft record's repeatable
+// section, and its position when one is given
+// PUT /api/v1/forms/{code}/records/{id}/sections/{section}/{row}
+func UpdateFormSectionRow(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	form, section, recordID := loadFormRecordSection(w, r, true)
+	if section == nil {
+		return
+	}
+	rowID, err := uuid.Parse(mux.Vars(r)["row"])
+	if err != nil {
+		http.Error(w, "invalid row ID", http.StatusBadRequest)
+		return
+	}
+	var req formSectionRowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (len(req.Data) == 0 && req.Position == nil) {
+		http.Error(w, "data or position is required", http.StatusBadRequest)
+		return
+	}
+	if errs := section.Field.Section.Validate(req.Data, formvalidation.Options{Partial: true}); len(errs) > 0 {
+		writeFormValidationError(w, errs)
+		return
+	}
+
+	tableManager := NewFormTableManager()
+	if row, err := tableManager.sectionRow("", *section, recordID, rowID); err != nil || row == nil {
+		http.Error(w, "row not found", http.StatusNotFound)
+		return
+	}
+	err = middleware.WithDataScope(r, tableManager.db).Transaction(func(tx *gorm.DB) error {
+		tm := tableManager.withDB(tx)
+		if err := tm.ensureSectionTable("", form.DBTableName, *section); err != nil {
+			return err
+		}
+		if err := tm.updateSectionRow("", *section, rowID, req.Position, req.Data, claims.UserID); err != nil {
+			return err
+		}
+		return tm.touchFormRecord("", form.DBTableName, recordID, claims.UserID)
+	})
+	if err != nil {
+		writeFormSectionError(w, err, "update")
+		return
+	}
+
+	row, err := tableManager.sectionRow("", *section, recordID, rowID)
+	if err != nil || row == nil {
+		http.Error(w, "failed to load updated row", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"row": formRecordJSON(row)})
+}
+
+// DeleteFormSectionRow soft deletes a row of a draft record's repeatable section
+// DELETE /api/v1/forms/{code}/records/{id}/sections/{section}/{row}
+func DeleteFormSectionRow(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	form, section, recordID := loadFormRecordSection(w, r, true)
+	if section == nil {
+		return
+	}
+	rowID, err := uuid.Parse(mux.Vars(r)["row"])
+	if err != nil {
+		http.Error(w, "invalid row ID", http.StatusBadRequest)
+		return
+	}
+
+	tableManager := NewFormTableManager()
+	if row, err := tableManager.sectionRow("", *section, recordID, rowID); err != nil || row == nil {
+		http.Error(w, "row not found", http.StatusNotFound)
+		return
+	}
+	table, err := tableManager.qualifiedTableName("", section.Table)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	err = middleware.WithDataScope(r, tableManager.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(fmt.Sprintf("UPDATE %s SET deleted_at = $1, deleted_by = $2, updated_at = $1 WHERE id = $3 AND deleted_at IS NULL", table),
+			time.Now(), claims.UserID, rowID).Error; err != nil {
+			return err
+		}
+		return tableManager.withDB(tx).touchFormRecord("", form.DBTableName, recordID, claims.UserID)
+	})
+	if err != nil {
+		writeFormSectionError(w, err, "delete")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "row deleted", "id": rowID})
+}
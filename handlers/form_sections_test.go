@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"p9e.in/ugcl/models"
+)
+
+func sectionTestForm() *models.AppForm {
+	return &models.AppForm{
+		DBTableName: "water_inspection",
+		FormSchema: json.RawMessage(`{"fields": [
+		  {"name": "inspector", "type": "text"},
+		  {"name": "Segments", "type": "repeater", "min": 1, "max": 5, "fields": [
+		    {"name": "length", "type": "decimal", "required": true},
+		    {"name": "material", "type": "select"},
+		    {"name": "position", "type": "number"}
+		  ]}
+		]}`),
+	}
+}
+
+func TestFormSections(t *testing.T) {
+	sections := formSections(sectionTestForm())
+	if len(sections) != 1 {
+		t.Fatalf("got %d sections, want 1", len(sections))
+	}
+	section := sections[0]
+	if section.Column != "segments" || section.Table != "water_inspection__segments" {
+		t.Errorf("section = %s in %s", section.Column, section.Table)
+	}
+	// position is a base column of section tables
+	if len(section.Columns) != 2 || section.Columns[0].Name != "length" || section.Columns[1].Name != "material" {
+		t.Errorf("section columns = %+v", section.Columns)
+	}
+	if _, ok := findFormSection(sectionTestForm(), "segments"); !ok {
+		t.Error("section not found by column")
+	}
+	if _, ok := findFormSection(sectionTestForm(), "inspector"); ok {
+		t.Error("plain field found as a section")
+	}
+
+	if _, ok := formFieldColumn(map[string]interface{}{"name": "segments", "type": "repeater"}); ok {
+		t.Error("repeater given a column of the form table")
+	}
+}
+
+func TestTakeFormSectionRows(t *testing.T) {
+	data := map[string]interface{}{
+		"inspector": "A",
+		"Segments":  []interface{}{map[string]interface{}{"length": 2.5}},
+	}
+	rows := takeFormSectionRows(sectionTestForm(), data)
+	if _, ok := data["Segments"]; ok {
+		t.Error("section left in the record's data")
+	}
+	if data["inspector"] != "A" || len(rows["Segments"]) != 1 {
+		t.Errorf("data = %v, rows = %v", data, rows)
+	}
+
+	if rows := takeFormSectionRows(sectionTestForm(), map[string]interface{}{"inspector": "A"}); rows != nil {
+		t.Errorf("rows taken from data without sections: %v", rows)
+	}
+	rows = takeFormSectionRows(sectionTestForm(), map[string]interface{}{"Segments": nil})
+	if cleared, ok := rows["Segments"]; !ok || len(cleared) != 0 {
+		t.Errorf("null section = %v, want no rows", rows)
+	}
+
+	row := sectionRowData(map[string]interface{}{"length": 1, "parent_id": "x", "ID": "y"})
+	if len(row) != 1 || row["length"] != 1 {
+		t.Errorf("sectionRowData = %v", row)
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 )
@@ -27,7 +26,7 @@ func CreateFormTableHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Get the form
 	var form models.AppForm
-	if err := config.DB.Where("code = ?", formCode).First(&form).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("code = ?", formCode).First(&form).Error; err != nil {
 		log.Printf("❌ Form not found: %s", formCode)
 		http.Error(w, "form not found", http.StatusNotFound)
 		return
@@ -92,7 +91,7 @@ func CheckFormTableStatus(w http.ResponseWriter, r *http.Request) {
 
 	// Get the form
 	var form models.AppForm
-	if err := config.DB.Where("code = ?", formCode).First(&form).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("code = ?", formCode).First(&form).Error; err != nil {
 		http.Error(w, "form not found", http.StatusNotFound)
 		return
 	}
@@ -135,7 +134,7 @@ func DropFormTableHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Get the form
 	var form models.AppForm
-	if err := config.DB.Where("code = ?", formCode).First(&form).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("code = ?", formCode).First(&form).Error; err != nil {
 		http.Error(w, "form not found", http.StatusNotFound)
 		return
 	}
@@ -183,7 +182,7 @@ func BulkCreateFormTablesHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Get all forms with table names
 	var forms []models.AppForm
-	if err := config.DB.Where("table_name IS NOT NULL AND table_name != ''").Find(&forms).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("table_name IS NOT NULL AND table_name != ''").Find(&forms).Error; err != nil {
 		http.Error(w, "failed to fetch forms", http.StatusInternalServerError)
 		return
 	}
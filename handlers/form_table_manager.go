@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"time"
 
@@ -11,6 +14,7 @@ import (
 	"gorm.io/gorm"
 	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/formvalidation"
 )
 
 // FormTableManager handles dynamic table creation and data management for forms
@@ -27,6 +31,104 @@ func NewFormTableManager() *FormTableManager {
 	}
 }
 
+// withDB returns a copy of the manager that runs its queries on db, such as a transaction
+func (ftm *FormTableManager) withDB(db *gorm.DB) *FormTableManager {
+	return &FormTableManager{db: db, schemaManager: ftm.schemaManager}
+}
+
+// errInvalidFormField is returned when form data names a field the form's table has no
+// column for, or a name that is not a plain identifier
+var errInvalidFormField = errors.New("invalid form field")
+
+// errFormRecordNotDeleted is returned when restoring a record that is not in the recycle bin
+var errFormRecordNotDeleted = errors.New("record is not deleted")
+
+// formIdentifier validates name as a plain SQL identifier and quotes it. Tables and columns
+// are created unquoted, which PostgreSQL folds to lower case, so the quoted name is
+// lower-cased to keep naming the same objects.
+func formIdentifier(name string) (string, error) {
+	if len(name) > 63 || !lookupIdentifierPattern.MatchString(name) {
+		return "", fmt.Errorf("invalid SQL identifier %q", name)
+	}
+	return `"` + strings.ToLower(name) + `"`, nil
+}
+
+// formColumnName turns a form field name into its column name
+func formColumnName(name string) string {
+	name = strings.ToLower(name)
+	name = strings.ReplaceAll(name, " ", "_")
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+// qualifiedTableName validates and quotes the schema-qualified name of a form table
+func (ftm *FormTableManager) qualifiedTableName(schemaName, tableName string) (string, error) {
+	table, err := formIdentifier(tableName)
+	if err != nil {
+		return "", fmt.Errorf("invalid form table name: %v", err)
+	}
+	if schemaName == "" || schemaName == "public" {
+		return table, nil
+	}
+	schema, err := formIdentifier(schemaName)
+	if err != nil {
+		return "", fmt.Errorf("invalid form table schema: %v", err)
+	}
+	return schema + "." + table, nil
+}
+
+// tableColumns returns the columns the form table has. The table's columns are those its
+// form's schema gave it, through creation and later migrations, so they are the allowlist
+// for names taken from form data.
+func (ftm *FormTableManager) tableColumns(schemaName, tableName string) (map[string]bool, error) {
+	if schemaName == "" {
+		schemaName = "public"
+	}
+	var names []string
+	if err := ftm.db.Raw("SELECT column_name FROM information_schema.columns WHERE table_schema = ? AND table_name = ?",
+		strings.ToLower(schemaName), strings.ToLower(tableName)).Scan(&names).Error; err != nil {
+		return nil, fmt.Errorf("failed to read columns of %s: %v", tableName, err)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("form table %s does not exist", tableName)
+	}
+	columns := make(map[string]bool, len(names))
+	for _, name := range names {
+		if !isFormDerivedColumn(name) {
+			columns[name] = true
+		}
+	}
+	return columns, nil
+}
+
+// formDataColumns maps each key to the quoted column it names in the form table, rejecting
+// keys the table has no column for
+func (ftm *FormTableManager) formDataColumns(schemaName, tableName string, keys []string) (map[string]string, error) {
+	columns, err := ftm.tableColumns(schemaName, tableName)
+	if err != nil {
+		return nil, err
+	}
+	quoted := make(map[string]string, len(keys))
+	var unknown []string
+	for _, key := range keys {
+		name := formColumnName(key)
+		if !columns[name] {
+			unknown = append(unknown, key)
+			continue
+		}
+		column, err := formIdentifier(name)
+		if err != nil {
+			unknown = append(unknown, key)
+			continue
+		}
+		quoted[key] = column
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("%w: form table %s has no field %s", errInvalidFormField, tableName, strings.Join(unknown, ", "))
+	}
+	return quoted, nil
+}
+
 // BaseFormFields represents the standard fields all form tables must have
 type BaseFormFields struct {
 	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
@@ -65,6 +167,9 @@ func (ftm *FormTableManager) CreateFormTableInSchemaWithSchema(form *models.AppF
 	if form.DBTableName == "" {
 		return fmt.Errorf("form %s has no table name defined", form.Code)
 	}
+	if _, err := formIdentifier(form.DBTableName); err != nil {
+		return fmt.Errorf("form %s has an invalid table name: %v", form.Code, err)
+	}
 
 	// Ensure schema exists
 	if schemaName != "" && schemaName != "public" {
@@ -115,6 +220,17 @@ func (ftm *FormTableManager) CreateFormTableInSchemaWithSchema(form *models.AppF
 		return fmt.Errorf("failed to create table: %v", err)
 	}
 
+	if err := ftm.ensureSearchVector(schemaName, form.DBTableName); err != nil {
+		// Searching adds the column later
+		log.Printf("⚠️  Failed to add search column to %s: %v", form.DBTableName, err)
+	}
+	if err := ftm.ensureGeoColumns(schemaName, form.DBTableName, formGeoPointColumns(form)); err != nil {
+		log.Printf("⚠️  Failed to add geometry columns to %s: %v", form.DBTableName, err)
+	}
+	if err := ftm.ensureSectionTables(schemaName, form); err != nil {
+		return err
+	}
+
 	log.Printf("✅ Successfully created table: %s in schema: %s", form.DBTableName, schemaName)
 	return nil
 }
@@ -138,6 +254,7 @@ func (ftm *FormTableManager) buildCreateTableSQLInSchema(schemaName, tableName s
 		"business_vertical_id UUID NOT NULL REFERENCES public.business_verticals(id)",
 		"site_id UUID REFERENCES public.sites(id)",
 		"workflow_id UUID REFERENCES public.workflow_definitions(id)",
+		"workflow_version INTEGER",
 		"current_state VARCHAR(50) NOT NULL DEFAULT 'draft'",
 		"form_id UUID NOT NULL REFERENCES public.app_forms(id)",
 		"form_code VARCHAR(50) NOT NULL",
@@ -180,7 +297,7 @@ func (ftm *FormTableManager) buildCreateTableSQLInSchema(schemaName, tableName s
 		}
 	}
 
-	log.Printf("📊 Total columns: %d (base: 13, custom: %d)", len(columns), len(columns)-13)
+	log.Printf("📊 Total columns: %d (base: 14, custom: %d)", len(columns), len(columns)-14)
 
 	// Create table with schema-qualified name
 	sql := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n  %s\n);", fullTableName, strings.Join(columns, ",\n  "))
@@ -192,6 +309,10 @@ func (ftm *FormTableManager) buildCreateTableSQLInSchema(schemaName, tableName s
 	sql += fmt.Sprintf("\nCREATE INDEX IF NOT EXISTS idx_%s_state ON %s(current_state);", indexPrefix, fullTableName)
 	sql += fmt.Sprintf("\nCREATE INDEX IF NOT EXISTS idx_%s_form ON %s(form_id);", indexPrefix, fullTableName)
 	sql += fmt.Sprintf("\nCREATE INDEX IF NOT EXISTS idx_%s_deleted ON %s(deleted_at);", indexPrefix, fullTableName)
+	// Keyset pagination of record lists walks this index, newest first
+	sql += fmt.Sprintf("\nCREATE INDEX IF NOT EXISTS idx_%s_listing ON %s(business_vertical_id, created_at DESC, id DESC) WHERE deleted_at IS NULL;", indexPrefix, fullTableName)
+	// Offline clients pull the records changed since their last sync in this order
+	sql += fmt.Sprintf("\nCREATE INDEX IF NOT EXISTS idx_%s_sync ON %s(updated_at, id);", indexPrefix, fullTableName)
 
 	return sql
 }
@@ -245,6 +366,9 @@ func (ftm *FormTableManager) CreateFormTableWithSchema(form *models.AppForm, inf
 	if form.DBTableName == "" {
 		return fmt.Errorf("form %s has no table name defined", form.Code)
 	}
+	if _, err := formIdentifier(form.DBTableName); err != nil {
+		return fmt.Errorf("form %s has an invalid table name: %v", form.Code, err)
+	}
 
 	log.Printf("📊 Creating dedicated table: %s for form: %s", form.DBTableName, form.Code)
 
@@ -281,6 +405,17 @@ func (ftm *FormTableManager) CreateFormTableWithSchema(form *models.AppForm, inf
 		return fmt.Errorf("failed to create table: %v", err)
 	}
 
+	if err := ftm.ensureSearchVector("", form.DBTableName); err != nil {
+		// Searching adds the column later
+		log.Printf("⚠️  Failed to add search column to %s: %v", form.DBTableName, err)
+	}
+	if err := ftm.ensureGeoColumns("", form.DBTableName, formGeoPointColumns(form)); err != nil {
+		log.Printf("⚠️  Failed to add geometry columns to %s: %v", form.DBTableName, err)
+	}
+	if err := ftm.ensureSectionTables("", form); err != nil {
+		return err
+	}
+
 	log.Printf("✅ Successfully created table: %s", form.DBTableName)
 	return nil
 }
@@ -301,6 +436,7 @@ func (ftm *FormTableManager) buildCreateTableSQL(tableName string, formSchema ma
 		"business_vertical_id UUID NOT NULL REFERENCES business_verticals(id)",
 		"site_id UUID REFERENCES sites(id)",
 		"workflow_id UUID REFERENCES workflow_definitions(id)",
+		"workflow_version INTEGER",
 		"current_state VARCHAR(50) NOT NULL DEFAULT 'draft'",
 		"form_id UUID NOT NULL REFERENCES app_forms(id)",
 		"form_code VARCHAR(50) NOT NULL",
@@ -344,7 +480,7 @@ func (ftm *FormTableManager) buildCreateTableSQL(tableName string, formSchema ma
 		}
 	}
 
-	log.Printf("📊 Total columns: %d (base: 13, custom: %d)", len(columns), len(columns)-13)
+	log.Printf("📊 Total columns: %d (base: 14, custom: %d)", len(columns), len(columns)-14)
 
 	// Create indexes
 	sql := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n  %s\n);", tableName, strings.Join(columns, ",\n  "))
@@ -355,24 +491,48 @@ func (ftm *FormTableManager) buildCreateTableSQL(tableName string, formSchema ma
 	sql += fmt.Sprintf("\nCREATE INDEX IF NOT EXISTS idx_%s_state ON %s(current_state);", tableName, tableName)
 	sql += fmt.Sprintf("\nCREATE INDEX IF NOT EXISTS idx_%s_form ON %s(form_id);", tableName, tableName)
 	sql += fmt.Sprintf("\nCREATE INDEX IF NOT EXISTS idx_%s_deleted ON %s(deleted_at);", tableName, tableName)
+	sql += fmt.Sprintf("\nCREATE INDEX IF NOT EXISTS idx_%s_listing ON %s(business_vertical_id, created_at DESC, id DESC) WHERE deleted_at IS NULL;", tableName, tableName)
+	// Offline clients pull the records changed since their last sync in this order
+	sql += fmt.Sprintf("\nCREATE INDEX IF NOT EXISTS idx_%s_sync ON %s(updated_at, id);", tableName, tableName)
 
 	return sql
 }
 
 // getColumnDefinition converts form field definition to SQL column definition
 func (ftm *FormTableManager) getColumnDefinition(field map[string]interface{}) string {
+	column, ok := formFieldColumn(field)
+	if !ok {
+		return ""
+	}
+
+	definition := fmt.Sprintf("%s %s", column.Name, column.Type)
+
+	if column.Required {
+		definition += " NOT NULL"
+	}
+
+	return definition
+}
+
+// formFieldColumn maps a form field definition to its column name and SQL type
+func formFieldColumn(field map[string]interface{}) (models.FormColumn, bool) {
 	name, ok := field["name"].(string)
 	if !ok || name == "" {
-		return ""
+		return models.FormColumn{}, false
 	}
 
 	// Sanitize column name
-	name = strings.ToLower(name)
-	name = strings.ReplaceAll(name, " ", "_")
-	name = strings.ReplaceAll(name, "-", "_")
+	name = formColumnName(name)
+	if len(name) > 63 || !lookupIdentifierPattern.MatchString(name) {
+		return models.FormColumn{}, false
+	}
 
 	fieldType, _ := field["type"].(string)
 	required, _ := field["required"].(bool)
+	if fieldType == formvalidation.SectionType {
+		// Repeatable sections are kept in their own tables
+		return models.FormColumn{}, false
+	}
 
 	var sqlType string
 	switch fieldType {
@@ -402,17 +562,14 @@ func (ftm *FormTableManager) getColumnDefinition(field map[string]interface{}) s
 		sqlType = "VARCHAR(500)" // Store file path
 	case "json", "object":
 		sqlType = "JSONB"
+	case "geopoint":
+		sqlType = "JSONB" // {"latitude", "longitude"}; its PostGIS point is generated beside it
 	default:
 		sqlType = "TEXT"
 	}
 
-	column := fmt.Sprintf("%s %s", name, sqlType)
-
-	if required {
-		column += " NOT NULL"
-	}
-
-	return column
+	label, _ := field["label"].(string)
+	return models.FormColumn{Name: name, Type: sqlType, Required: required, Label: label}, true
 }
 
 // InsertFormData inserts form submission data into the dedicated table
@@ -444,10 +601,16 @@ func (ftm *FormTableManager) InsertFormDataInSchema(
 	userID string,
 ) (uuid.UUID, error) {
 	// Get full table name
-	fullTableName := ftm.schemaManager.GetFullTableName(schemaName, tableName)
+	fullTableName, err := ftm.qualifiedTableName(schemaName, tableName)
+	if err != nil {
+		return uuid.Nil, err
+	}
 
-	// Add base fields to form data
-	recordID := uuid.New()
+	// Add base fields to form data; a client may have named the record already
+	recordID, ok := formData["id"].(uuid.UUID)
+	if !ok || recordID == uuid.Nil {
+		recordID = uuid.New()
+	}
 	formData["id"] = recordID
 	formData["form_id"] = formID
 	formData["form_code"] = formCode
@@ -455,20 +618,38 @@ func (ftm *FormTableManager) InsertFormDataInSchema(
 	formData["site_id"] = siteID
 	formData["workflow_id"] = workflowID
 	formData["current_state"] = initialState
+	if workflowID != nil {
+		// Pin the record to the workflow version it starts under
+		var version int
+		if err := ftm.db.Model(&models.WorkflowDefinition{}).Where("id = ?", *workflowID).
+			Pluck("current_version", &version).Error; err == nil && version > 0 {
+			formData["workflow_version"] = version
+		}
+	}
 	formData["created_by"] = userID
 	formData["created_at"] = time.Now()
 	formData["updated_at"] = time.Now()
 
-	// Build INSERT SQL dynamically
+	// Build INSERT SQL dynamically, naming only columns the table has
+	keys := make([]string, 0, len(formData))
+	for key := range formData {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	quoted, err := ftm.formDataColumns(schemaName, tableName, keys)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
 	var columns []string
 	var placeholders []string
 	var values []interface{}
 	i := 1
 
-	for col, val := range formData {
-		columns = append(columns, col)
+	for _, key := range keys {
+		columns = append(columns, quoted[key])
 		placeholders = append(placeholders, fmt.Sprintf("$%d", i))
-		values = append(values, val)
+		values = append(values, formData[key])
 		i++
 	}
 
@@ -480,15 +661,75 @@ func (ftm *FormTableManager) InsertFormDataInSchema(
 	)
 
 	var returnedID uuid.UUID
-	err := ftm.db.Raw(sql, values...).Row().Scan(&returnedID)
+	err = ftm.db.Raw(sql, values...).Row().Scan(&returnedID)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("failed to insert form data: %v", err)
+		return uuid.Nil, fmt.Errorf("failed to insert form data: %w", err)
 	}
 
 	log.Printf("✅ Inserted record %s into table %s", returnedID, fullTableName)
 	return returnedID, nil
 }
 
+// maxInsertParameters keeps a multi-row INSERT under PostgreSQL's bind parameter limit
+const maxInsertParameters = 65535
+
+// InsertFormDataBatch inserts records, each holding its base fields and form data, with
+// multi-row INSERTs of up to batchSize rows. A column some records leave out is NULL in
+// them. Run it on a transaction to make the batches all-or-nothing.
+func (ftm *FormTableManager) InsertFormDataBatch(tableName string, records []map[string]interface{}, batchSize int) error {
+	if len(records) == 0 {
+		return nil
+	}
+	fullTableName, err := ftm.qualifiedTableName("", tableName)
+	if err != nil {
+		return err
+	}
+
+	keySet := make(map[string]bool)
+	for _, record := range records {
+		for key := range record {
+			keySet[key] = true
+		}
+	}
+	keys := make([]string, 0, len(keySet))
+	for key := range keySet {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	quoted, err := ftm.formDataColumns("", tableName, keys)
+	if err != nil {
+		return err
+	}
+	columns := make([]string, len(keys))
+	for i, key := range keys {
+		columns[i] = quoted[key]
+	}
+
+	if limit := maxInsertParameters / len(keys); batchSize <= 0 || batchSize > limit {
+		batchSize = limit
+	}
+	for start := 0; start < len(records); start += batchSize {
+		batch := records[start:min(start+batchSize, len(records))]
+		rows := make([]string, 0, len(batch))
+		values := make([]interface{}, 0, len(batch)*len(keys))
+		for _, record := range batch {
+			placeholders := make([]string, len(keys))
+			for i, key := range keys {
+				values = append(values, record[key])
+				placeholders[i] = fmt.Sprintf("$%d", len(values))
+			}
+			rows = append(rows, "("+strings.Join(placeholders, ", ")+")")
+		}
+		sql := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", fullTableName, strings.Join(columns, ", "), strings.Join(rows, ", "))
+		if err := ftm.db.Exec(sql, values...).Error; err != nil {
+			return fmt.Errorf("failed to insert rows %d-%d: %v", start+1, start+len(batch), err)
+		}
+	}
+
+	log.Printf("✅ Inserted %d records into table %s", len(records), fullTableName)
+	return nil
+}
+
 // UpdateFormData updates form submission data in the dedicated table
 func (ftm *FormTableManager) UpdateFormData(
 	tableName string,
@@ -508,24 +749,37 @@ func (ftm *FormTableManager) UpdateFormDataInSchema(
 	userID string,
 ) error {
 	// Get full table name
-	fullTableName := ftm.schemaManager.GetFullTableName(schemaName, tableName)
+	fullTableName, err := ftm.qualifiedTableName(schemaName, tableName)
+	if err != nil {
+		return err
+	}
 
 	// Add update metadata
 	formData["updated_by"] = userID
 	formData["updated_at"] = time.Now()
 
-	// Build UPDATE SQL dynamically
+	// Build UPDATE SQL dynamically, naming only columns the table has
+	keys := make([]string, 0, len(formData))
+	for key := range formData {
+		// Skip read-only fields
+		if key == "id" || key == "created_by" || key == "created_at" {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	quoted, err := ftm.formDataColumns(schemaName, tableName, keys)
+	if err != nil {
+		return err
+	}
+
 	var setClauses []string
 	var values []interface{}
 	i := 1
 
-	for col, val := range formData {
-		// Skip read-only fields
-		if col == "id" || col == "created_by" || col == "created_at" {
-			continue
-		}
-		setClauses = append(setClauses, fmt.Sprintf("%s = $%d", col, i))
-		values = append(values, val)
+	for _, key := range keys {
+		setClauses = append(setClauses, fmt.Sprintf("%s = $%d", quoted[key], i))
+		values = append(values, formData[key])
 		i++
 	}
 
@@ -540,8 +794,16 @@ func (ftm *FormTableManager) UpdateFormDataInSchema(
 		whereClause,
 	)
 
-	err := ftm.db.Exec(sql, values...).Error
-	if err != nil {
+	// Changes to the fields are kept in the record's audit trail
+	var audited []string
+	for _, key := range keys {
+		if key != "updated_by" && key != "updated_at" {
+			audited = append(audited, key)
+		}
+	}
+	if _, err := ftm.auditedUpdate(fullTableName, recordID, audited, userID, func(tx *gorm.DB) *gorm.DB {
+		return tx.Exec(sql, values...)
+	}); err != nil {
 		return fmt.Errorf("failed to update form data: %v", err)
 	}
 
@@ -557,7 +819,10 @@ func (ftm *FormTableManager) GetFormData(tableName string, recordID uuid.UUID) (
 // GetFormDataInSchema retrieves form submission data from the dedicated table within a specific schema
 func (ftm *FormTableManager) GetFormDataInSchema(schemaName string, tableName string, recordID uuid.UUID) (map[string]interface{}, error) {
 	// Get full table name
-	fullTableName := ftm.schemaManager.GetFullTableName(schemaName, tableName)
+	fullTableName, err := ftm.qualifiedTableName(schemaName, tableName)
+	if err != nil {
+		return nil, err
+	}
 
 	sql := fmt.Sprintf("SELECT * FROM %s WHERE id = $1 AND deleted_at IS NULL", fullTableName)
 
@@ -585,7 +850,9 @@ func (ftm *FormTableManager) GetFormDataInSchema(schemaName string, tableName st
 
 	result = make(map[string]interface{})
 	for i, col := range columns {
-		result[col] = values[i]
+		if !isFormDerivedColumn(col) {
+			result[col] = values[i]
+		}
 	}
 
 	return result, nil
@@ -608,7 +875,14 @@ func (ftm *FormTableManager) GetFormDataListInSchema(
 	filters map[string]interface{},
 ) ([]map[string]interface{}, error) {
 	// Get full table name
-	fullTableName := ftm.schemaManager.GetFullTableName(schemaName, tableName)
+	fullTableName, err := ftm.qualifiedTableName(schemaName, tableName)
+	if err != nil {
+		return nil, err
+	}
+	filterColumns, err := ftm.filterColumns(schemaName, tableName, filters)
+	if err != nil {
+		return nil, err
+	}
 
 	// Build WHERE clause
 	var whereClauses []string
@@ -621,9 +895,10 @@ func (ftm *FormTableManager) GetFormDataListInSchema(
 
 	whereClauses = append(whereClauses, "deleted_at IS NULL")
 
-	for key, val := range filters {
-		whereClauses = append(whereClauses, fmt.Sprintf("%s = $%d", key, i))
-		values = append(values, val)
+	for _, key := range filterColumns.keys {
+		clause, value := filterClause(filterColumns.quoted[key], filters[key], i)
+		whereClauses = append(whereClauses, clause)
+		values = append(values, value)
 		i++
 	}
 
@@ -655,7 +930,9 @@ func (ftm *FormTableManager) GetFormDataListInSchema(
 
 		result := make(map[string]interface{})
 		for i, col := range columns {
-			result[col] = values[i]
+			if !isFormDerivedColumn(col) {
+				result[col] = values[i]
+			}
 		}
 		results = append(results, result)
 	}
@@ -663,6 +940,42 @@ func (ftm *FormTableManager) GetFormDataListInSchema(
 	return results, nil
 }
 
+// formFilterColumns holds filter keys in a stable order with their quoted columns
+type formFilterColumns struct {
+	keys   []string
+	quoted map[string]string
+}
+
+// filterColumns validates filter keys against the form table's columns
+func (ftm *FormTableManager) filterColumns(schemaName, tableName string, filters map[string]interface{}) (formFilterColumns, error) {
+	keys := make([]string, 0, len(filters))
+	for key := range filters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) == 0 {
+		return formFilterColumns{}, nil
+	}
+	quoted, err := ftm.formDataColumns(schemaName, tableName, keys)
+	if err != nil {
+		return formFilterColumns{}, err
+	}
+	return formFilterColumns{keys: keys, quoted: quoted}, nil
+}
+
+// filterClause builds "key = $n", or "key = ANY($n)" when val is a list of UUIDs
+// (used for site-scoped users who may see several sites).
+func filterClause(key string, val interface{}, placeholder int) (string, interface{}) {
+	if ids, ok := val.([]uuid.UUID); ok {
+		values := make([]string, len(ids))
+		for i, id := range ids {
+			values[i] = id.String()
+		}
+		return fmt.Sprintf("%s = ANY($%d::uuid[])", key, placeholder), values
+	}
+	return fmt.Sprintf("%s = $%d", key, placeholder), val
+}
+
 // GetFormDataListPage retrieves paginated form submissions from a dedicated table.
 func (ftm *FormTableManager) GetFormDataListPage(
 	tableName string,
@@ -687,7 +1000,14 @@ func (ftm *FormTableManager) GetFormDataListPageInSchema(
 		limit = defaultSubmissionPageSize
 	}
 
-	fullTableName := ftm.schemaManager.GetFullTableName(schemaName, tableName)
+	fullTableName, err := ftm.qualifiedTableName(schemaName, tableName)
+	if err != nil {
+		return nil, err
+	}
+	filterColumns, err := ftm.filterColumns(schemaName, tableName, filters)
+	if err != nil {
+		return nil, err
+	}
 
 	var whereClauses []string
 	var values []interface{}
@@ -699,12 +1019,10 @@ func (ftm *FormTableManager) GetFormDataListPageInSchema(
 
 	whereClauses = append(whereClauses, "deleted_at IS NULL")
 
-	for key, val := range filters {
-		if !lookupIdentifierPattern.MatchString(key) {
-			return nil, fmt.Errorf("invalid filter key: %s", key)
-		}
-		whereClauses = append(whereClauses, fmt.Sprintf("%s = $%d", key, idx))
-		values = append(values, val)
+	for _, key := range filterColumns.keys {
+		clause, value := filterClause(filterColumns.quoted[key], filters[key], idx)
+		whereClauses = append(whereClauses, clause)
+		values = append(values, value)
 		idx++
 	}
 
@@ -744,7 +1062,9 @@ func (ftm *FormTableManager) GetFormDataListPageInSchema(
 
 		result := make(map[string]interface{}, len(columns))
 		for i, col := range columns {
-			result[col] = rowValues[i]
+			if !isFormDerivedColumn(col) {
+				result[col] = rowValues[i]
+			}
 		}
 		results = append(results, result)
 	}
@@ -760,15 +1080,27 @@ func (ftm *FormTableManager) SoftDeleteFormData(tableName string, recordID uuid.
 // SoftDeleteFormDataInSchema soft deletes a record in the dedicated table within a specific schema
 func (ftm *FormTableManager) SoftDeleteFormDataInSchema(schemaName string, tableName string, recordID uuid.UUID, userID string) error {
 	// Get full table name
-	fullTableName := ftm.schemaManager.GetFullTableName(schemaName, tableName)
+	fullTableName, err := ftm.qualifiedTableName(schemaName, tableName)
+	if err != nil {
+		return err
+	}
 
 	sql := fmt.Sprintf(
-		"UPDATE %s SET deleted_at = $1, deleted_by = $2 WHERE id = $3 AND deleted_at IS NULL",
+		"UPDATE %s SET deleted_at = $1, deleted_by = $2, updated_at = $1 WHERE id = $3 AND deleted_at IS NULL",
 		fullTableName,
 	)
 
-	err := ftm.db.Exec(sql, time.Now(), userID, recordID).Error
-	if err != nil {
+	now := time.Now()
+	if _, err := ftm.auditedUpdate(fullTableName, recordID, []string{"deleted_at"}, userID, func(tx *gorm.DB) *gorm.DB {
+		result := tx.Exec(sql, now, userID, recordID)
+		if result.Error == nil && result.RowsAffected > 0 {
+			// The record's section rows go with it
+			if err := ftm.withDB(tx).deleteSectionRows(schemaName, tableName, recordID, now, userID); err != nil {
+				result.AddError(err)
+			}
+		}
+		return result
+	}); err != nil {
 		return fmt.Errorf("failed to delete form data: %v", err)
 	}
 
@@ -776,6 +1108,94 @@ func (ftm *FormTableManager) SoftDeleteFormDataInSchema(schemaName string, table
 	return nil
 }
 
+// RestoreFormDataInSchema brings a soft-deleted record back, answering errFormRecordNotDeleted
+// when the record is missing or not deleted
+func (ftm *FormTableManager) RestoreFormDataInSchema(schemaName string, tableName string, recordID uuid.UUID, userID string) error {
+	fullTableName, err := ftm.qualifiedTableName(schemaName, tableName)
+	if err != nil {
+		return err
+	}
+
+	sql := fmt.Sprintf(
+		"UPDATE %s SET deleted_at = NULL, deleted_by = NULL, updated_at = $1, updated_by = $2 WHERE id = $3 AND deleted_at IS NOT NULL",
+		fullTableName,
+	)
+	restored, err := ftm.auditedUpdate(fullTableName, recordID, []string{"deleted_at"}, userID, func(tx *gorm.DB) *gorm.DB {
+		// Section rows are matched on the record's deletion time, so restore them first
+		if err := ftm.withDB(tx).restoreSectionRows(schemaName, tableName, recordID, userID); err != nil {
+			tx.AddError(err)
+			return tx
+		}
+		return tx.Exec(sql, time.Now(), userID, recordID)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to restore form data: %v", err)
+	}
+	if restored == 0 {
+		return errFormRecordNotDeleted
+	}
+
+	log.Printf("✅ Restored record %s in table %s", recordID, fullTableName)
+	return nil
+}
+
+// PurgeFormDataInSchema permanently deletes the records soft-deleted before cutoff, or
+// with recordID set only that record when it was deleted before cutoff, together with
+// their files, both the rows and the stored objects. Their audit trail is kept on
+// purpose: it records who changed what and must outlive the records it describes. It
+// returns how many records were purged.
+func (ftm *FormTableManager) PurgeFormDataInSchema(schemaName string, tableName string, recordID *uuid.UUID, cutoff time.Time) (int, error) {
+	fullTableName, err := ftm.qualifiedTableName(schemaName, tableName)
+	if err != nil {
+		return 0, err
+	}
+
+	sql := fmt.Sprintf("DELETE FROM %s WHERE deleted_at IS NOT NULL AND deleted_at < $1", fullTableName)
+	args := []interface{}{cutoff}
+	if recordID != nil {
+		sql += " AND id = $2"
+		args = append(args, *recordID)
+	}
+	sql += " RETURNING id"
+
+	var purged []uuid.UUID
+	var files []models.FormFile
+	err = ftm.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw(sql, args...).Scan(&purged).Error; err != nil {
+			return err
+		}
+		if len(purged) == 0 {
+			return nil
+		}
+		if err := tx.Unscoped().Select("id", "file_path", "thumbnail_path").
+			Where("record_id IN ?", purged).Find(&files).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("record_id IN ?", purged).Delete(&models.FormFile{}).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge form data: %v", err)
+	}
+
+	// Stored objects go once the rows are gone for good; one left behind is only logged
+	ctx := context.Background()
+	for _, file := range files {
+		for _, path := range []string{file.FilePath, file.ThumbnailPath} {
+			if path == "" {
+				continue
+			}
+			if err := removeStoredFile(ctx, path); err != nil {
+				log.Printf("⚠️  Failed to remove stored file %s of purged form file %s: %v", path, file.ID, err)
+			}
+		}
+	}
+
+	if len(purged) > 0 {
+		log.Printf("✅ Purged %d records and %d files from table %s", len(purged), len(files), fullTableName)
+	}
+	return len(purged), nil
+}
+
 // UpdateWorkflowState updates only the workflow state of a record
 func (ftm *FormTableManager) UpdateWorkflowState(tableName string, recordID uuid.UUID, newState string, userID string) error {
 	return ftm.UpdateWorkflowStateInSchema("", tableName, recordID, newState, userID)
@@ -784,14 +1204,17 @@ func (ftm *FormTableManager) UpdateWorkflowState(tableName string, recordID uuid
 // UpdateWorkflowStateInSchema updates only the workflow state of a record within a specific schema
 func (ftm *FormTableManager) UpdateWorkflowStateInSchema(schemaName string, tableName string, recordID uuid.UUID, newState string, userID string) error {
 	// Get full table name
-	fullTableName := ftm.schemaManager.GetFullTableName(schemaName, tableName)
+	fullTableName, err := ftm.qualifiedTableName(schemaName, tableName)
+	if err != nil {
+		return err
+	}
 
 	sql := fmt.Sprintf(
 		"UPDATE %s SET current_state = $1, updated_by = $2, updated_at = $3 WHERE id = $4",
 		fullTableName,
 	)
 
-	err := ftm.db.Exec(sql, newState, userID, time.Now(), recordID).Error
+	err = ftm.db.Exec(sql, newState, userID, time.Now(), recordID).Error
 	if err != nil {
 		return fmt.Errorf("failed to update workflow state: %v", err)
 	}
@@ -808,11 +1231,24 @@ func (ftm *FormTableManager) DropFormTable(tableName string) error {
 // DropFormTableInSchema drops a form's dedicated table within a specific schema (use with caution!)
 func (ftm *FormTableManager) DropFormTableInSchema(schemaName string, tableName string) error {
 	// Get full table name
-	fullTableName := ftm.schemaManager.GetFullTableName(schemaName, tableName)
+	fullTableName, err := ftm.qualifiedTableName(schemaName, tableName)
+	if err != nil {
+		return err
+	}
+
+	sections, err := ftm.sectionTables(schemaName, tableName)
+	if err != nil {
+		return err
+	}
+	for _, section := range sections {
+		if err := ftm.db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", section)).Error; err != nil {
+			return fmt.Errorf("failed to drop section table: %v", err)
+		}
+	}
 
 	sql := fmt.Sprintf("DROP TABLE IF EXISTS %s CASCADE", fullTableName)
 
-	err := ftm.db.Exec(sql).Error
+	err = ftm.db.Exec(sql).Error
 	if err != nil {
 		return fmt.Errorf("failed to drop table: %v", err)
 	}
@@ -831,7 +1267,11 @@ func (ftm *FormTableManager) TableExistsInSchema(schemaName string, tableName st
 	if schemaName == "" {
 		schemaName = "public"
 	}
-	return ftm.schemaManager.TableExistsInSchema(schemaName, tableName)
+	// Asked of ftm.db rather than the schema manager, so it sees tables a transaction made
+	var exists bool
+	err := ftm.db.Raw("SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_schema = $1 AND table_name = $2)",
+		schemaName, tableName).Scan(&exists).Error
+	return exists, err
 }
 
 // InferSchemaFromData infers form schema from the submitted data
@@ -841,14 +1281,7 @@ func (ftm *FormTableManager) InferSchemaFromData(formData map[string]interface{}
 
 	for fieldName, value := range formData {
 		// Skip base fields that are always present
-		baseFields := map[string]bool{
-			"id": true, "created_by": true, "created_at": true,
-			"updated_by": true, "updated_at": true, "deleted_by": true, "deleted_at": true,
-			"business_vertical_id": true, "site_id": true,
-			"workflow_id": true, "current_state": true,
-			"form_id": true, "form_code": true,
-		}
-		if baseFields[fieldName] {
+		if formTableBaseColumns[fieldName] {
 			continue
 		}
 
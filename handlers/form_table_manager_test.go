@@ -0,0 +1,54 @@
+package handlers
+
+import "testing"
+
+func TestFormIdentifier(t *testing.T) {
+	valid := map[string]string{
+		"amount":      `"amount"`,
+		"Site_Name":   `"site_name"`,
+		"_internal_1": `"_internal_1"`,
+	}
+	for name, want := range valid {
+		got, err := formIdentifier(name)
+		if err != nil || got != want {
+			t.Errorf("formIdentifier(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+
+	for _, name := range []string{
+		"",
+		"1amount",
+		"amount; DROP TABLE users",
+		`amount"`,
+		"amount--",
+		"public.users",
+		"name with space",
+		string(make([]byte, 64)),
+	} {
+		if got, err := formIdentifier(name); err == nil {
+			t.Errorf("formIdentifier(%q) = %q; want an error", name, got)
+		}
+	}
+}
+
+func TestQualifiedTableName(t *testing.T) {
+	ftm := &FormTableManager{}
+	if got, err := ftm.qualifiedTableName("", "purchase_orders"); err != nil || got != `"purchase_orders"` {
+		t.Errorf("public table: got %q, %v", got, err)
+	}
+	if got, err := ftm.qualifiedTableName("procurement", "purchase_orders"); err != nil || got != `"procurement"."purchase_orders"` {
+		t.Errorf("schema table: got %q, %v", got, err)
+	}
+	if _, err := ftm.qualifiedTableName("procurement; --", "purchase_orders"); err == nil {
+		t.Error("invalid schema name: want an error")
+	}
+}
+
+func TestFormFieldColumnRejectsUnsafeNames(t *testing.T) {
+	if column, ok := formFieldColumn(map[string]interface{}{"name": "Due Date", "type": "date"}); !ok || column.Name != "due_date" || column.Type != "DATE" {
+		t.Errorf("got %+v, %v; want due_date DATE", column, ok)
+	}
+	if column, ok := formFieldColumn(map[string]interface{}{"name": "x TEXT); DROP TABLE users; --", "type": "text"}); ok {
+		t.Errorf("got %+v; want the field rejected", column)
+	}
+}
@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// formTemplateDefinition exports a form's definition, naming its workflow by code
+func formTemplateDefinition(form *models.AppForm) (models.FormTemplateDefinition, error) {
+	var workflowCode string
+	if form.WorkflowID != nil {
+		var workflow models.WorkflowDefinition
+		if err := config.DB.Select("code").First(&workflow, "id = ?", *form.WorkflowID).Error; err != nil {
+			return models.FormTemplateDefinition{}, fmt.Errorf("workflow of form %s not found: %w", form.Code, err)
+		}
+		workflowCode = workflow.Code
+	}
+	return models.NewFormTemplateDefinition(form, workflowCode), nil
+}
+
+// formInstanceRequest names what a form created from a template gets of its own: its
+// code, module (whose schema holds the new table), verticals and table
+type formInstanceRequest struct {
+	Code          string     `json:"code"`
+	Title         string     `json:"title,omitempty"` // defaults to the template's
+	ModuleID      *uuid.UUID `json:"module_id,omitempty"`
+	ModuleCode    string     `json:"module_code,omitempty"`
+	VerticalCodes []string   `json:"vertical_codes"`
+	TableName     string     `json:"table_name,omitempty"` // defaults to one derived from code
+	Route         string     `json:"route,omitempty"`      // defaults to /form/{code}
+	DisplayOrder  int        `json:"display_order,omitempty"`
+}
+
+// errFormInstanceConflict is returned when the new form's code or table is taken
+var errFormInstanceConflict = errors.New("already in use")
+
+// newFormFromDefinition builds the form req asks for from a template definition. It
+// answers errInvalidFormField for a request that cannot be met and
+// errFormInstanceConflict when the code or table is taken.
+func newFormFromDefinition(definition models.FormTemplateDefinition, req formInstanceRequest, userID string) (*models.AppForm, *models.Module, error) {
+	if err := definition.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("%w: template %v", errInvalidFormField, err)
+	}
+	code := strings.TrimSpace(req.Code)
+	if code == "" || len(code) > 50 {
+		return nil, nil, fmt.Errorf("%w: code is required and may have at most 50 characters", errInvalidFormField)
+	}
+
+	var module models.Module
+	switch {
+	case req.ModuleID != nil:
+		if err := config.DB.First(&module, "id = ?", *req.ModuleID).Error; err != nil {
+			return nil, nil, fmt.Errorf("%w: module not found", errInvalidFormField)
+		}
+	case req.ModuleCode != "":
+		if err := config.DB.First(&module, "code = ?", req.ModuleCode).Error; err != nil {
+			return nil, nil, fmt.Errorf("%w: module %s not found", errInvalidFormField, req.ModuleCode)
+		}
+	default:
+		return nil, nil, fmt.Errorf("%w: module_id or module_code is required", errInvalidFormField)
+	}
+
+	if len(req.VerticalCodes) > 0 {
+		var found []string
+		if err := config.DB.Model(&models.BusinessVertical{}).Where("code IN ?", req.VerticalCodes).Pluck("code", &found).Error; err != nil {
+			return nil, nil, err
+		}
+		known := make(map[string]bool, len(found))
+		for _, c := range found {
+			known[c] = true
+		}
+		for _, c := range req.VerticalCodes {
+			if !known[c] {
+				return nil, nil, fmt.Errorf("%w: business vertical %s not found", errInvalidFormField, c)
+			}
+		}
+	}
+
+	form := &models.AppForm{
+		Code:                code,
+		ModuleID:            module.ID,
+		Route:               req.Route,
+		DisplayOrder:        req.DisplayOrder,
+		AccessibleVerticals: req.VerticalCodes,
+		DBTableName:         req.TableName,
+		IsActive:            true,
+		CreatedBy:           userID,
+	}
+	definition.Apply(form)
+	if title := strings.TrimSpace(req.Title); title != "" {
+		form.Title = title
+	}
+	if form.Route == "" {
+		form.Route = "/form/" + code
+	}
+	if form.DBTableName == "" {
+		form.DBTableName = generateTableName(code)
+	}
+	if _, err := formIdentifier(form.DBTableName); err != nil {
+		return nil, nil, fmt.Errorf("%w: table_name %v", errInvalidFormField, err)
+	}
+
+	// Workflows are shared, so the new form follows the same one
+	if definition.WorkflowCode != "" {
+		var workflow models.WorkflowDefinition
+		if err := config.DB.First(&workflow, "code = ? AND is_active = ?", definition.WorkflowCode, true).Error; err != nil {
+			return nil, nil, fmt.Errorf("%w: workflow %s not found", errInvalidFormField, definition.WorkflowCode)
+		}
+		form.WorkflowID = &workflow.ID
+	}
+
+	var taken int64
+	if err := config.DB.Model(&models.AppForm{}).Where("code = ?", code).Count(&taken).Error; err != nil {
+		return nil, nil, err
+	}
+	if taken > 0 {
+		return nil, nil, fmt.Errorf("form code %s is %w", code, errFormInstanceConflict)
+	}
+	// Another form of a module in the same schema may have the table, created or not
+	if err := config.DB.Model(&models.AppForm{}).
+		Joins("JOIN modules ON modules.id = app_forms.module_id").
+		Where("app_forms.db_table_name = ? AND COALESCE(modules.schema_name, '') = ?", form.DBTableName, module.SchemaName).
+		Count(&taken).Error; err != nil {
+		return nil, nil, err
+	}
+	if taken == 0 && module.SchemaName != "" {
+		exists, err := NewFormTableManager().TableExistsInSchema(module.SchemaName, form.DBTableName)
+		if err != nil {
+			return nil, nil, err
+		}
+		if exists {
+			taken = 1
+		}
+	}
+	if taken > 0 {
+		return nil, nil, fmt.Errorf("table %s is %w", form.DBTableName, errFormInstanceConflict)
+	}
+	return form, &module, nil
+}
+
+// instantiateFormDefinition creates the form the request body asks for from a template
+// definition and answers with it
+func instantiateFormDefinition(w http.ResponseWriter, r *http.Request, definition models.FormTemplateDefinition, source string) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req formInstanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	form, module, err := newFormFromDefinition(definition, req, claims.UserID)
+	switch {
+	case errors.Is(err, errInvalidFormField):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, errFormInstanceConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("❌ Error preparing form from %s: %v", source, err)
+		http.Error(w, "failed to create form", http.StatusInternalServerError)
+		return
+	}
+
+	tableCreated, err := createAppForm(form, module)
+	if err != nil {
+		log.Printf("❌ Error creating form from %s: %v", source, err)
+		http.Error(w, "failed to create form", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("✅ Created form %s from %s", form.Code, source)
+	invalidateFormsCache()
+
+	form.Module = module
+	response := map[string]interface{}{
+		"message":       "form created successfully",
+		"form":          form.ToDTO(),
+		"source":        source,
+		"table_name":    form.DBTableName,
+		"table_created": tableCreated,
+	}
+	if module.SchemaName != "" {
+		response["schema_name"] = module.SchemaName
+	}
+	writeJSON(w, http.StatusCreated, response)
+}
+
+// ExportFormTemplate returns a form's definition as a template, to save in the library or
+// to instantiate in another environment
+// GET /api/v1/admin/app-forms/{formCode}/template
+func ExportFormTemplate(w http.ResponseWriter, r *http.Request) {
+	var form models.AppForm
+	if err := middleware.ScopedDB(r).Where("code = ?", mux.Vars(r)["formCode"]).First(&form).Error; err != nil {
+		http.Error(w, "form not found", http.StatusNotFound)
+		return
+	}
+	definition, err := formTemplateDefinition(&form)
+	if err != nil {
+		log.Printf("❌ Error exporting form %s: %v", form.Code, err)
+		http.Error(w, "failed to export form", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"source_form_code": form.Code, "definition": definition})
+}
+
+// CloneForm creates a copy of a form, with its own table, in the module and verticals the
+// body names
+// POST /api/v1/admin/app-forms/{formCode}/clone
+func CloneForm(w http.ResponseWriter, r *http.Request) {
+	var form models.AppForm
+	if err := middleware.ScopedDB(r).Where("code = ?", mux.Vars(r)["formCode"]).First(&form).Error; err != nil {
+		http.Error(w, "form not found", http.StatusNotFound)
+		return
+	}
+	definition, err := formTemplateDefinition(&form)
+	if err != nil {
+		log.Printf("❌ Error exporting form %s: %v", form.Code, err)
+		http.Error(w, "failed to clone form", http.StatusInternalServerError)
+		return
+	}
+	instantiateFormDefinition(w, r, definition, "form "+form.Code)
+}
+
+// CreateFormTemplate adds a template to the library, exported from the form named by
+// form_code or given as definition
+// POST /api/v1/admin/form-templates
+func CreateFormTemplate(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req struct {
+		Code        string                         `json:"code"`
+		Name        string                         `json:"name"`
+		Description string                         `json:"description,omitempty"`
+		FormCode    string                         `json:"form_code,omitempty"`
+		Definition  *models.FormTemplateDefinition `json:"definition,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Code = strings.TrimSpace(req.Code)
+	if req.Code == "" || len(req.Code) > 50 || strings.TrimSpace(req.Name) == "" {
+		http.Error(w, "code (at most 50 characters) and name are required", http.StatusBadRequest)
+		return
+	}
+
+	template := models.FormTemplate{
+		Code:        req.Code,
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		CreatedBy:   claims.UserID,
+	}
+	switch {
+	case req.FormCode != "":
+		var form models.AppForm
+		if err := middleware.ScopedDB(r).Where("code = ?", req.FormCode).First(&form).Error; err != nil {
+			http.Error(w, "form not found", http.StatusBadRequest)
+			return
+		}
+		definition, err := formTemplateDefinition(&form)
+		if err != nil {
+			log.Printf("❌ Error exporting form %s: %v", form.Code, err)
+			http.Error(w, "failed to export form", http.StatusInternalServerError)
+			return
+		}
+		template.Definition = definition
+		template.SourceFormCode = form.Code
+	case req.Definition != nil:
+		template.Definition = *req.Definition
+	default:
+		http.Error(w, "form_code or definition is required", http.StatusBadRequest)
+		return
+	}
+	if err := template.Definition.Validate(); err != nil {
+		http.Error(w, "invalid definition: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var taken int64
+	if err := middleware.ScopedDB(r).Model(&models.FormTemplate{}).Where("code = ?", template.Code).Count(&taken).Error; err != nil {
+		http.Error(w, "failed to create template", http.StatusInternalServerError)
+		return
+	}
+	if taken > 0 {
+		http.Error(w, "template code already exists", http.StatusConflict)
+		return
+	}
+	if err := middleware.ScopedDB(r).Create(&template).Error; err != nil {
+		log.Printf("❌ Error creating form template %s: %v", template.Code, err)
+		http.Error(w, "failed to create template", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, template)
+}
+
+// ListFormTemplates lists the template library, without the definitions
+// GET /api/v1/admin/form-templates
+func ListFormTemplates(w http.ResponseWriter, r *http.Request) {
+	var templates []models.FormTemplate
+	if err := middleware.ScopedDB(r).Omit("definition").Order("name").Find(&templates).Error; err != nil {
+		http.Error(w, "failed to list templates", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"templates": templates, "count": len(templates)})
+}
+
+// loadFormTemplate loads the template named in the path, answering 404 when it is missing
+func loadFormTemplate(w http.ResponseWriter, r *http.Request) *models.FormTemplate {
+	id, err := uuid.Parse(mux.Vars(r)["templateId"])
+	if err != nil {
+		http.Error(w, "invalid template ID", http.StatusBadRequest)
+		return nil
+	}
+	var template models.FormTemplate
+	if err := middleware.ScopedDB(r).First(&template, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "template not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "failed to load template", http.StatusInternalServerError)
+		}
+		return nil
+	}
+	return &template
+}
+
+// GetFormTemplate returns a template with its definition
+// GET /api/v1/admin/form-templates/{templateId}
+func GetFormTemplate(w http.ResponseWriter, r *http.Request) {
+	if template := loadFormTemplate(w, r); template != nil {
+		writeJSON(w, http.StatusOK, template)
+	}
+}
+
+// DeleteFormTemplate removes a template from the library. Forms created from it are kept.
+// DELETE /api/v1/admin/form-templates/{templateId}
+func DeleteFormTemplate(w http.ResponseWriter, r *http.Request) {
+	template := loadFormTemplate(w, r)
+	if template == nil {
+		return
+	}
+	if err := middleware.ScopedDB(r).Delete(template).Error; err != nil {
+		http.Error(w, "failed to delete template", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "template deleted", "id": template.ID})
+}
+
+// InstantiateFormTemplate creates a form from a template, with its own table, in the
+// module and verticals the body names
+// POST /api/v1/admin/form-templates/{templateId}/instantiate
+func InstantiateFormTemplate(w http.ResponseWriter, r *http.Request) {
+	if template := loadFormTemplate(w, r); template != nil {
+		instantiateFormDefinition(w, r, template.Definition, "template "+template.Code)
+	}
+}
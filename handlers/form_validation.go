@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/formvalidation"
+)
+
+// validateFormSubmission checks data against the form's field definitions and cross-field
+// rules, coercing values in place. Drafts are validated partially: only the values present
+// are checked, so they can be saved incomplete. File fields must reference files uploaded
+// for them, and geofenced location fields must fall inside their zone. A failure is a
+// formvalidation.Errors.
+func validateFormSubmission(form *models.AppForm, data map[string]interface{}, draft bool) error {
+	rules := formvalidation.FromForm(form.FormSchema, form.Steps, form.Validations)
+	for _, err := range rules.Invalid {
+		log.Printf("⚠️  Form %s has a validation rule that is not enforced: %v", form.Code, err)
+	}
+	errs := rules.Validate(data, formvalidation.Options{Partial: draft})
+	fileErrs, err := formFileReferenceErrors(form, rules.Fields, data)
+	if err != nil {
+		return err
+	}
+	if errs = append(errs, fileErrs...); len(errs) > 0 {
+		return errs
+	}
+	geofenceErrs, err := formGeofenceErrors(rules.Fields, data, draft)
+	if err != nil {
+		return err
+	}
+	if len(geofenceErrs) > 0 {
+		return geofenceErrs
+	}
+	return nil
+}
+
+// validateFormSubmissionJSON is validateFormSubmission for JSON form data, returning the
+// data with coerced values
+func validateFormSubmissionJSON(form *models.AppForm, formData json.RawMessage, draft bool) (json.RawMessage, error) {
+	data := map[string]interface{}{}
+	if len(formData) > 0 && string(formData) != "null" {
+		if err := json.Unmarshal(formData, &data); err != nil {
+			return nil, fmt.Errorf("invalid form data: %w", err)
+		}
+	}
+	if err := validateFormSubmission(form, data, draft); err != nil {
+		return nil, err
+	}
+	if len(formData) == 0 || string(formData) == "null" {
+		return formData, nil
+	}
+	return json.Marshal(data)
+}
+
+// writeFormValidationError answers 422 with the per-field errors when err carries them
+func writeFormValidationError(w http.ResponseWriter, err error) bool {
+	var validationErrs formvalidation.Errors
+	if !errors.As(err, &validationErrs) {
+		return false
+	}
+	writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+		"error":  "validation failed",
+		"errors": validationErrs,
+	})
+	return true
+}
+
+// GetFormRules returns a form's visibility rules, formulas and cross-field rules, so
+// clients can show, hide and compute fields live the way the server does on submission
+// GET /api/v1/forms/{code}/rules
+func GetFormRules(w http.ResponseWriter, r *http.Request) {
+	if middleware.GetClaims(r) == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var form models.AppForm
+	if err := middleware.ScopedDB(r).Where("code = ? AND is_active = ?", mux.Vars(r)["code"], true).First(&form).Error; err != nil {
+		http.Error(w, "form not found", http.StatusNotFound)
+		return
+	}
+
+	rules := formvalidation.FromForm(form.FormSchema, form.Steps, form.Validations)
+	crossField := rules.CrossField
+	if crossField == nil {
+		crossField = []formvalidation.CrossFieldRule{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"form_code":   form.Code,
+		"fields":      rules.Logic(),
+		"cross_field": crossField,
+	})
+}
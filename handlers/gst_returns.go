@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// gstBilledRABillStatuses are the RA bill statuses reported as outward supplies
+var gstBilledRABillStatuses = []string{"approved", "paid"}
+
+// normalizeGSTCode trims and upper-cases a GSTIN or state code
+func normalizeGSTCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// checkGSTClient refuses a malformed client GSTIN or place of supply
+func checkGSTClient(gstin, placeOfSupply string) error {
+	if gstin != "" && !models.ValidGSTIN(gstin) {
+		return apiError{status: http.StatusBadRequest, message: "client_gstin is not a valid GSTIN"}
+	}
+	if placeOfSupply != "" && !models.ValidGSTStateCode(placeOfSupply) {
+		return apiError{status: http.StatusBadRequest, message: "place_of_supply must be a two digit GST state code"}
+	}
+	return nil
+}
+
+// businessGSTIN returns the GSTIN the business files under: the tax ID of its company
+func businessGSTIN(db *gorm.DB, businessID uuid.UUID) (string, error) {
+	var business models.BusinessVertical
+	if err := db.Select("id, company_id").First(&business, "id = ?", businessID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", apiError{status: http.StatusNotFound, message: "business not found"}
+		}
+		return "", err
+	}
+	var company models.Company
+	if business.CompanyID != nil {
+		if err := db.Select("tax_id").First(&company, "id = ?", *business.CompanyID).Error; err != nil &&
+			!errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", err
+		}
+	}
+	gstin := normalizeGSTCode(company.TaxID)
+	if !models.ValidGSTIN(gstin) {
+		return "", apiError{status: http.StatusUnprocessableEntity, message: "the business's company has no valid GSTIN as its tax ID"}
+	}
+	return gstin, nil
+}
+
+// gstOutwardSupplies returns the business's RA bills approved in [from, to) as supplies in
+// the base currency. The place of supply is the project's, else the client's state, else
+// the business's own.
+func gstOutwardSupplies(db *gorm.DB, businessID uuid.UUID, gstin string, from, to time.Time) ([]models.GSTSupply, error) {
+	var bills []struct {
+		BillNumber    string
+		BillDate      time.Time
+		ClientName    string
+		ClientGSTIN   string
+		PlaceOfSupply string
+		TaxableValue  float64
+		TaxAmount     float64
+	}
+	if err := db.Table("ra_bills rb").
+		Select(`rb.bill_number, CAST(COALESCE(rb.approved_at, rb.period_end, rb.created_at) AS date) AS bill_date,
+			p.client_name, p.client_gstin, p.place_of_supply,
+			rb.gross_amount * p.exchange_rate AS taxable_value, rb.tax_amount * p.exchange_rate AS tax_amount`).
+		Joins("JOIN projects p ON p.id = rb.project_id").
+		Where("p.business_vertical_id = ? AND p.deleted_at IS NULL AND rb.deleted_at IS NULL AND rb.status IN ?",
+			businessID, gstBilledRABillStatuses).
+		Where("CAST(COALESCE(rb.approved_at, rb.period_end, rb.created_at) AS date) >= ? AND CAST(COALESCE(rb.approved_at, rb.period_end, rb.created_at) AS date) < ?", from, to).
+		Order("bill_date, rb.bill_number").Scan(&bills).Error; err != nil {
+		return nil, fmt.Errorf("load RA bills: %w", err)
+	}
+
+	supplierState := models.GSTStateCode(gstin)
+	supplies := make([]models.GSTSupply, 0, len(bills))
+	for _, b := range bills {
+		s := models.GSTSupply{
+			PartyName:     b.ClientName,
+			InvoiceNumber: b.BillNumber,
+			InvoiceDate:   b.BillDate,
+			PlaceOfSupply: b.PlaceOfSupply,
+			TaxableValue:  models.Round2(b.TaxableValue),
+			TaxAmount:     models.Round2(b.TaxAmount),
+		}
+		if models.ValidGSTIN(b.ClientGSTIN) {
+			s.PartyGSTIN = b.ClientGSTIN
+		}
+		if s.PlaceOfSupply == "" {
+			s.PlaceOfSupply = models.GSTStateCode(s.PartyGSTIN)
+		}
+		if s.PlaceOfSupply == "" {
+			s.PlaceOfSupply = supplierState
+		}
+		s.IGST, s.CGST, s.SGST = models.SplitGST(s.TaxAmount, supplierState, s.PlaceOfSupply)
+		supplies = append(supplies, s)
+	}
+	return supplies, nil
+}
+
+// gstInwardSupplies returns the business's approved vendor invoices dated in [from, to)
+// as supplies in the base currency. Tax from a vendor in another state is IGST.
+func gstInwardSupplies(db *gorm.DB, businessID uuid.UUID, gstin string, from, to time.Time) ([]models.GSTSupply, error) {
+	var invoices []struct {
+		InvoiceNumber string
+		InvoiceDate   time.Time
+		VendorName    string
+		VendorGSTIN   string
+		TaxableValue  float64
+		TaxAmount     float64
+	}
+	if err := db.Table("vendor_invoices vi").
+		Select(`vi.invoice_number, vi.invoice_date, po.vendor_name, po.vendor_gstin,
+			vi.sub_total * vi.exchange_rate AS taxable_value, vi.tax_amount * vi.exchange_rate AS tax_amount`).
+		Joins("JOIN purchase_orders po ON po.id = vi.purchase_order_id").
+		Where("vi.business_vertical_id = ? AND vi.current_state = ? AND vi.invoice_date >= ? AND vi.invoice_date < ?",
+			businessID, models.ProcurementApproved, from, to).
+		Order("vi.invoice_date, vi.invoice_number").Scan(&invoices).Error; err != nil {
+		return nil, fmt.Errorf("load vendor invoices: %w", err)
+	}
+
+	supplierState := models.GSTStateCode(gstin)
+	supplies := make([]models.GSTSupply, 0, len(invoices))
+	for _, inv := range invoices {
+		s := models.GSTSupply{
+			PartyName:     inv.VendorName,
+			InvoiceNumber: inv.InvoiceNumber,
+			InvoiceDate:   inv.InvoiceDate,
+			PlaceOfSupply: supplierState,
+			TaxableValue:  models.Round2(inv.TaxableValue),
+			TaxAmount:     models.Round2(inv.TaxAmount),
+		}
+		if vendorGSTIN := normalizeGSTCode(inv.VendorGSTIN); models.ValidGSTIN(vendorGSTIN) {
+			s.PartyGSTIN = vendorGSTIN
+		}
+		s.IGST, s.CGST, s.SGST = models.SplitGST(s.TaxAmount, supplierState, models.GSTStateCode(s.PartyGSTIN))
+		supplies = append(supplies, s)
+	}
+	return supplies, nil
+}
+
+// gstReturnRequest reads the business, its GSTIN, the ?period=YYYY-MM and the
+// ?format=json|xlsx of a return request
+func gstReturnRequest(r *http.Request) (businessID uuid.UUID, gstin string, from, to time.Time, format string, err error) {
+	if businessID, err = procurementBusinessID(r); err != nil {
+		return
+	}
+	if from, to, err = models.PayrollMonth(r.URL.Query().Get("period")); err != nil {
+		err = apiError{status: http.StatusBadRequest, message: "period: " + err.Error()}
+		return
+	}
+	format = strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format")))
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "xlsx" {
+		err = apiError{status: http.StatusBadRequest, message: "format must be json or xlsx"}
+		return
+	}
+	gstin, err = businessGSTIN(middleware.ScopedDB(r), businessID)
+	return
+}
+
+// writeGSTWorkbook sends a workbook of sheets, each a header row and data rows
+func writeGSTWorkbook(w http.ResponseWriter, filename string, sheets []string, rows map[string][][]interface{}) {
+	file := excelize.NewFile()
+	defer file.Close()
+	for i, sheet := range sheets {
+		if i == 0 {
+			file.SetSheetName("Sheet1", sheet)
+		} else if _, err := file.NewSheet(sheet); err != nil {
+			http.Error(w, "failed to build workbook", http.StatusInternalServerError)
+			return
+		}
+		for n, row := range rows[sheet] {
+			cell, _ := excelize.CoordinatesToCellName(1, n+1)
+			if err := file.SetSheetRow(sheet, cell, &row); err != nil {
+				http.Error(w, "failed to build workbook", http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	_ = file.Write(w)
+}
+
+// GetGSTR1 exports the business's outward supplies for ?period=YYYY-MM as a GSTR-1:
+// the government JSON schema (?format=json, the default) or an Excel workbook
+// (?format=xlsx). Approved RA bills are the outward supplies, valued in the base
+// currency; bills to clients with a GSTIN are reported as B2B invoices.
+func GetGSTR1(w http.ResponseWriter, r *http.Request) {
+	businessID, gstin, from, to, format, err := gstReturnRequest(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to build GSTR-1")
+		return
+	}
+	supplies, err := gstOutwardSupplies(middleware.ScopedDB(r), businessID, gstin, from, to)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to build GSTR-1")
+		return
+	}
+	ret := models.BuildGSTR1(gstin, from, supplies)
+	filename := fmt.Sprintf("GSTR1_%s_%s.%s", gstin, ret.Period, format)
+	if format == "json" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		writeJSON(w, http.StatusOK, ret)
+		return
+	}
+
+	b2b := [][]interface{}{{"GSTIN of Recipient", "Receiver Name", "Invoice Number", "Invoice Date", "Invoice Value",
+		"Place Of Supply", "Reverse Charge", "Invoice Type", "Rate", "Taxable Value", "IGST", "CGST", "SGST", "Cess"}}
+	for _, s := range supplies {
+		if s.PartyGSTIN == "" {
+			continue
+		}
+		b2b = append(b2b, []interface{}{s.PartyGSTIN, s.PartyName, s.InvoiceNumber, s.InvoiceDate.Format("02-Jan-2006"),
+			models.Round2(s.TaxableValue + s.TaxAmount), s.PlaceOfSupply, "N", "Regular", s.Rate(),
+			s.TaxableValue, s.IGST, s.CGST, s.SGST, 0})
+	}
+	b2cs := [][]interface{}{{"Type", "Place Of Supply", "Rate", "Taxable Value", "IGST", "CGST", "SGST", "Cess"}}
+	for _, row := range ret.B2CS {
+		b2cs = append(b2cs, []interface{}{row.Type, row.PlaceOfSupply, row.Rate, row.TaxableValue, row.IGST, row.CGST, row.SGST, row.Cess})
+	}
+	writeGSTWorkbook(w, filename, []string{"b2b", "b2cs"}, map[string][][]interface{}{"b2b": b2b, "b2cs": b2cs})
+}
+
+// GetGSTR3B exports the business's GSTR-3B summary for ?period=YYYY-MM: outward supplies
+// from approved RA bills and input tax credit from approved vendor invoices, as the
+// government JSON schema (?format=json, the default) or an Excel workbook (?format=xlsx)
+// with the inward register behind the credit.
+func GetGSTR3B(w http.ResponseWriter, r *http.Request) {
+	businessID, gstin, from, to, format, err := gstReturnRequest(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to build GSTR-3B")
+		return
+	}
+	outward, err := gstOutwardSupplies(middleware.ScopedDB(r), businessID, gstin, from, to)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to build GSTR-3B")
+		return
+	}
+	inward, err := gstInwardSupplies(middleware.ScopedDB(r), businessID, gstin, from, to)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to build GSTR-3B")
+		return
+	}
+	ret := models.BuildGSTR3B(gstin, from, outward, inward)
+	filename := fmt.Sprintf("GSTR3B_%s_%s.%s", gstin, ret.Period, format)
+	if format == "json" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		writeJSON(w, http.StatusOK, ret)
+		return
+	}
+
+	out := ret.SupDetails.Outward
+	avl, inelg := ret.ITCEligible.Available[0], ret.ITCEligible.Ineligible[0]
+	summary := [][]interface{}{
+		{"Section", "Description", "Taxable Value", "IGST", "CGST", "SGST", "Cess"},
+		{"3.1(a)", "Outward taxable supplies", out.TaxableValue, out.IGST, out.CGST, out.SGST, out.Cess},
+		{"4(A)(5)", "All other ITC", "", avl.IGST, avl.CGST, avl.SGST, avl.Cess},
+		{"4(D)(2)", "Ineligible ITC - others", "", inelg.IGST, inelg.CGST, inelg.SGST, inelg.Cess},
+		{"4(C)", "Net ITC available", "", ret.ITCEligible.Net.IGST, ret.ITCEligible.Net.CGST, ret.ITCEligible.Net.SGST, ret.ITCEligible.Net.Cess},
+	}
+	register := [][]interface{}{{"GSTIN of Supplier", "Supplier Name", "Invoice Number", "Invoice Date", "Taxable Value",
+		"IGST", "CGST", "SGST", "ITC Eligible"}}
+	for _, s := range inward {
+		eligible := "Yes"
+		if s.PartyGSTIN == "" {
+			eligible = "No"
+		}
+		register = append(register, []interface{}{s.PartyGSTIN, s.PartyName, s.InvoiceNumber, s.InvoiceDate.Format("02-Jan-2006"),
+			s.TaxableValue, s.IGST, s.CGST, s.SGST, eligible})
+	}
+	writeGSTWorkbook(w, filename, []string{"summary", "inward"}, map[string][][]interface{}{"summary": summary, "inward": register})
+}
@@ -0,0 +1,33 @@
+// Package httputil holds the response and request helpers shared by the handler packages.
+package httputil
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"p9e.in/ugcl/middleware"
+)
+
+// WriteJSON writes payload as a JSON response with the status
+func WriteJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(payload)
+}
+
+// ClaimsUserID returns the ID of the user the request's token was issued to. It answers
+// 401 and returns false when the request carries no valid claims.
+func ClaimsUserID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return uuid.Nil, false
+	}
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		http.Error(w, "invalid user ID in claims", http.StatusUnauthorized)
+		return uuid.Nil, false
+	}
+	return userID, true
+}
@@ -16,7 +16,6 @@ import (
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"gorm.io/datatypes"
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 )
@@ -119,7 +118,7 @@ func IntegrationFormCatalog(w http.ResponseWriter, r *http.Request) {
 	allowedCodes := allowedThirdPartyFormCodes()
 	allowAll := len(allowedCodes) == 0
 
-	query := config.DB.Model(&models.AppForm{}).
+	query := middleware.ScopedDB(r).Model(&models.AppForm{}).
 		Select("code, title").
 		Where("is_active = ?", true)
 
@@ -345,7 +344,7 @@ func isAllowedDropdownTarget(r *http.Request, targetURL *url.URL) bool {
 	if err == nil {
 		needle := strings.ReplaceAll(normalized, "\"", "\\\"")
 		var count int64
-		if dbErr := config.DB.Model(&models.ThirdPartyIntegration{}).
+		if dbErr := middleware.ScopedDB(r).Model(&models.ThirdPartyIntegration{}).
 			Where("status = ?", models.IntegrationStatusActive).
 			Where("allowed_urls @> ?", datatypes.JSON([]byte("[\""+needle+"\"]"))).
 			Count(&count).Error; dbErr == nil && count > 0 {
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// Zone statuses in a KMZ export, from the zone's tasks
+const (
+	kmzZonePending    = "pending"
+	kmzZoneInProgress = "in-progress"
+	kmzZoneCompleted  = "completed"
+)
+
+// kmzStyles colours zones and nodes by status. KML colours are aabbggrr.
+var kmzStyles = []kmlStyle{
+	zoneStyle(kmzZonePending, "7f9e9e9e", "ff757575"),
+	zoneStyle(kmzZoneInProgress, "7f00a5ff", "ff0080ff"),
+	zoneStyle(kmzZoneCompleted, "7f00c853", "ff00a040"),
+	nodeStyle(models.NodeStatusAvailable, "ff9e9e9e"),
+	nodeStyle(models.NodeStatusAllocated, "fff39621"),
+	nodeStyle(models.NodeStatusInProgress, "ff00a5ff"),
+	nodeStyle(models.NodeStatusCompleted, "ff00c853"),
+}
+
+// kmzZoneStatsSQL sums each zone's open and closed tasks
+const kmzZoneStatsSQL = `SELECT zone_id,
+	COUNT(*) AS tasks,
+	COUNT(*) FILTER (WHERE status = 'completed') AS completed,
+	COUNT(*) FILTER (WHERE status = 'in-progress' OR progress > 0) AS started,
+	COALESCE(AVG(progress), 0) AS progress
+	FROM tasks
+	WHERE project_id = ? AND zone_id IS NOT NULL AND deleted_at IS NULL AND status <> 'cancelled'
+	GROUP BY zone_id`
+
+type kmlDocument struct {
+	XMLName xml.Name    `xml:"kml"`
+	XMLNS   string      `xml:"xmlns,attr"`
+	Name    string      `xml:"Document>name"`
+	Styles  []kmlStyle  `xml:"Document>Style"`
+	Folders []kmlFolder `xml:"Document>Folder"`
+}
+
+type kmlStyle struct {
+	ID        string        `xml:"id,attr"`
+	Icon      *kmlIconStyle `xml:"IconStyle,omitempty"`
+	LineColor string        `xml:"LineStyle>color,omitempty"`
+	LineWidth float64       `xml:"LineStyle>width,omitempty"`
+	PolyColor string        `xml:"PolyStyle>color,omitempty"`
+}
+
+type kmlIconStyle struct {
+	Color string  `xml:"color"`
+	Scale float64 `xml:"scale"`
+	Href  string  `xml:"Icon>href"`
+}
+
+type kmlFolder struct {
+	Name       string         `xml:"name"`
+	Placemarks []kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlPlacemark struct {
+	ID          string          `xml:"id,attr,omitempty"`
+	Name        string          `xml:"name"`
+	Description string          `xml:"description,omitempty"`
+	StyleURL    string          `xml:"styleUrl"`
+	Extended    kmlExtendedData `xml:"ExtendedData"`
+	Geometry    string          `xml:",innerxml"`
+}
+
+type kmlExtendedData struct {
+	Data []kmlData `xml:"Data"`
+}
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+func zoneStyle(status, fill, line string) kmlStyle {
+	return kmlStyle{ID: "zone-" + status, LineColor: line, LineWidth: 2, PolyColor: fill}
+}
+
+func nodeStyle(status, color string) kmlStyle {
+	return kmlStyle{ID: "node-" + status, Icon: &kmlIconStyle{
+		Color: color,
+		Scale: 0.9,
+		Href:  "http://maps.google.com/mapfiles/kml/shapes/shaded_dot.png",
+	}}
+}
+
+// kmzZoneStats counts a zone's tasks, cancelled ones aside
+type kmzZoneStats struct {
+	ZoneID    uuid.UUID
+	Tasks     int
+	Completed int
+	Started   int
+	Progress  float64
+}
+
+// status is the zone's status from its tasks: completed once they all are, in progress
+// once any has started, else pending
+func (s kmzZoneStats) status() string {
+	switch {
+	case s.Tasks > 0 && s.Completed == s.Tasks:
+		return kmzZoneCompleted
+	case s.Completed > 0 || s.Started > 0:
+		return kmzZoneInProgress
+	}
+	return kmzZonePending
+}
+
+// buildKMZ zips a KML document into a KMZ
+func buildKMZ(doc kmlDocument) ([]byte, error) {
+	doc.XMLNS = "http://www.opengis.net/kml/2.2"
+	kml, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	file, err := archive.Create("doc.kml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Write(append([]byte(xml.Header), kml...)); err != nil {
+		return nil, err
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var kmzFileNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// ExportProjectKMZ builds a KMZ of the project as it stands, for Google Earth: a folder of
+// its zones, styled green when all their tasks are completed, amber once work has started
+// and grey while pending, and a folder of its nodes styled by node status.
+// GET /api/v1/projects/{id}/export.kmz
+func (h *ProjectHandler) ExportProjectKMZ(w http.ResponseWriter, r *http.Request) {
+	projectID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+	var project models.Project
+	if err := h.scopedDB(r).First(&project, "id = ? AND deleted_at IS NULL", projectID).Error; err != nil {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+
+	var zones []struct {
+		ID          uuid.UUID
+		Name        string
+		Code        string
+		Description string
+		KML         string
+	}
+	if err := middleware.WithDataScope(r, h.db).Model(&models.Zone{}).
+		Select("id, name, code, description, ST_AsKML(geometry) AS kml").
+		Where("project_id = ? AND deleted_at IS NULL AND geometry IS NOT NULL", project.ID).
+		Order("code, name").Scan(&zones).Error; err != nil {
+		http.Error(w, "Failed to load zones", http.StatusInternalServerError)
+		return
+	}
+	var stats []kmzZoneStats
+	if err := middleware.WithDataScope(r, h.db).Raw(kmzZoneStatsSQL, project.ID).Scan(&stats).Error; err != nil {
+		http.Error(w, "Failed to load zone progress", http.StatusInternalServerError)
+		return
+	}
+	zoneStats := make(map[uuid.UUID]kmzZoneStats, len(stats))
+	for _, s := range stats {
+		zoneStats[s.ZoneID] = s
+	}
+	var nodes []models.Node
+	if err := middleware.WithDataScope(r, h.db).Select("id", "name", "code", "node_type", "latitude", "longitude", "elevation", "status").
+		Where("project_id = ? AND deleted_at IS NULL", project.ID).
+		Order("code, name").Find(&nodes).Error; err != nil {
+		http.Error(w, "Failed to load nodes", http.StatusInternalServerError)
+		return
+	}
+
+	zoneFolder := kmlFolder{Name: "Zones"}
+	for _, zone := range zones {
+		stat := zoneStats[zone.ID]
+		status := stat.status()
+		zoneFolder.Placemarks = append(zoneFolder.Placemarks, kmlPlacemark{
+			ID:          zone.ID.String(),
+			Name:        zone.Name,
+			Description: zone.Description,
+			StyleURL:    "#zone-" + status,
+			Extended: kmlExtendedData{Data: []kmlData{
+				{Name: "code", Value: zone.Code},
+				{Name: "status", Value: status},
+				{Name: "tasks", Value: fmt.Sprintf("%d", stat.Tasks)},
+				{Name: "completed_tasks", Value: fmt.Sprintf("%d", stat.Completed)},
+				{Name: "progress", Value: fmt.Sprintf("%.1f", stat.Progress)},
+			}},
+			Geometry: zone.KML,
+		})
+	}
+
+	nodeFolder := kmlFolder{Name: "Nodes"}
+	for _, node := range nodes {
+		status := node.Status
+		if status == "" {
+			status = models.NodeStatusAvailable
+		}
+		nodeFolder.Placemarks = append(nodeFolder.Placemarks, kmlPlacemark{
+			ID:       node.ID.String(),
+			Name:     node.Name,
+			StyleURL: "#node-" + status,
+			Extended: kmlExtendedData{Data: []kmlData{
+				{Name: "code", Value: node.Code},
+				{Name: "node_type", Value: node.NodeType},
+				{Name: "status", Value: status},
+			}},
+			Geometry: fmt.Sprintf("<Point><coordinates>%.8f,%.8f,%.2f</coordinates></Point>", node.Longitude, node.Latitude, node.Elevation),
+		})
+	}
+
+	kmz, err := buildKMZ(kmlDocument{
+		Name:    project.Name,
+		Styles:  kmzStyles,
+		Folders: []kmlFolder{zoneFolder, nodeFolder},
+	})
+	if err != nil {
+		http.Error(w, "Failed to build KMZ", http.StatusInternalServerError)
+		return
+	}
+
+	fileName := strings.Trim(kmzFileNameUnsafe.ReplaceAllString(project.Code, "_"), "_")
+	if fileName == "" {
+		fileName = project.ID.String()
+	}
+	w.Header().Set("Content-Type", "application/vnd.google-earth.kmz")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName+".kmz"))
+	w.WriteHeader(http.StatusOK)
+	w.Write(kmz)
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestKMZZoneStatus(t *testing.T) {
+	cases := []struct {
+		stats kmzZoneStats
+		want  string
+	}{
+		{kmzZoneStats{}, kmzZonePending},
+		{kmzZoneStats{Tasks: 3}, kmzZonePending},
+		{kmzZoneStats{Tasks: 3, Started: 1}, kmzZoneInProgress},
+		{kmzZoneStats{Tasks: 3, Completed: 1}, kmzZoneInProgress},
+		{kmzZoneStats{Tasks: 3, Completed: 3, Started: 3}, kmzZoneCompleted},
+	}
+	for _, c := range cases {
+		if got := c.stats.status(); got != c.want {
+			t.Errorf("status(%+v) = %s, want %s", c.stats, got, c.want)
+		}
+	}
+}
+
+func TestBuildKMZ(t *testing.T) {
+	kmz, err := buildKMZ(kmlDocument{
+		Name:   "Solar & Farm",
+		Styles: kmzStyles,
+		Folders: []kmlFolder{{Name: "Zones", Placemarks: []kmlPlacemark{{
+			Name:     "Block A",
+			StyleURL: "#zone-completed",
+			Extended: kmlExtendedData{Data: []kmlData{{Name: "status", Value: "completed"}}},
+			Geometry: "<Polygon><outerBoundaryIs><LinearRing><coordinates>1,2 3,4 5,6 1,2</coordinates></LinearRing></outerBoundaryIs></Polygon>",
+		}}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(kmz), int64(len(kmz)))
+	if err != nil || len(archive.File) != 1 || archive.File[0].Name != "doc.kml" {
+		t.Fatalf("KMZ should hold only doc.kml: %v", err)
+	}
+	file, err := archive.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(file)
+
+	// The export must read back through the importer
+	parsed, err := NewKMZParser().ParseKML(data)
+	if err != nil {
+		t.Fatalf("ParseKML: %v\n%s", err, data)
+	}
+	if len(parsed.Documents) != 1 || len(parsed.Documents[0].Folders) != 1 {
+		t.Fatalf("parsed = %+v, want one document with one folder", parsed)
+	}
+	placemark := parsed.Documents[0].Folders[0].Placemarks[0]
+	if placemark.Polygon == nil || placemark.StyleUrl != "#zone-completed" {
+		t.Errorf("placemark = %+v, want the styled polygon", placemark)
+	}
+	for _, want := range []string{"<name>Solar &amp; Farm</name>", `<Style id="zone-completed">`, "<color>7f00c853</color>"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("KML lacks %s:\n%s", want, data)
+		}
+	}
+}
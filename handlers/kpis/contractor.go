@@ -6,15 +6,15 @@ import (
 	"net/http"
 	"time"
 
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/helper"
+	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 	"p9e.in/ugcl/models/kpis"
 )
 
 func GetContractorKPIs(w http.ResponseWriter, r *http.Request) {
 	var contractors []models.Contractor
-	db := config.DB // or your db getter
+	db := middleware.ScopedDB(r) // or your db getter
 
 	// Optional: add date filtering via query params if needed
 
@@ -5,13 +5,13 @@ import (
 	"net/http"
 	"time"
 
-	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 	"p9e.in/ugcl/models/kpis"
 )
 
 func GetDairyKPIs(w http.ResponseWriter, r *http.Request) {
-	db := config.DB // however you get your DB
+	db := middleware.ScopedDB(r) // however you get your DB
 	var sites []models.DairySite
 	if err := db.Find(&sites).Error; err != nil {
 		http.Error(w, err.Error(), 500)
@@ -6,15 +6,15 @@ import (
 	"sort"
 	"time"
 
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/helper"
+	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 	"p9e.in/ugcl/models/kpis"
 )
 
 func GetDieselKPIs(w http.ResponseWriter, r *http.Request) {
 	var diesels []models.Diesel
-	db := config.DB
+	db := middleware.ScopedDB(r)
 	if err := db.Find(&diesels).Error; err != nil {
 		http.Error(w, err.Error(), 500)
 		return
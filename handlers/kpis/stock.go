@@ -7,15 +7,15 @@ import (
 	"sort"
 	"time"
 
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/helper"
+	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 	"p9e.in/ugcl/models/kpis"
 )
 
 func GetStockKPIs(w http.ResponseWriter, r *http.Request) {
 	var stocks []models.Stock
-	db := config.DB // however you get your DB
+	db := middleware.ScopedDB(r) // however you get your DB
 	if err := db.Find(&stocks).Error; err != nil {
 		http.Error(w, err.Error(), 500)
 		return
@@ -0,0 +1,1039 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+func leaveApplicationRecord(app *models.LeaveApplication) procurementRecord {
+	return procurementRecord{
+		kind: "leave_application", table: "leave_applications", permission: "leave:apply",
+		id: app.ID, businessID: app.BusinessVerticalID, workflowID: app.WorkflowID,
+		state: app.CurrentState, createdBy: app.CreatedBy,
+		title: fmt.Sprintf("Leave %s to %s", app.FromDate.Format("2006-01-02"), app.ToDate.Format("2006-01-02")),
+	}
+}
+
+// seesAllLeave reports whether the user may see everyone's leave in the business: with
+// leave:manage, or as a leave approver. Others see only their own.
+func seesAllLeave(r *http.Request) bool {
+	permissions := middleware.GetEffectivePermissions(r)
+	return hasWorkflowPermission(permissions, "leave:manage") || hasWorkflowPermission(permissions, "workflow:approve")
+}
+
+// leaveWorkflowID returns the approval workflow new leave applications follow
+func leaveWorkflowID(db *gorm.DB) (*uuid.UUID, error) {
+	var workflow models.WorkflowDefinition
+	if err := db.Select("id").Where("code = ? AND is_active = ?", models.StandardApprovalWorkflowCode, true).
+		First(&workflow).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError{status: http.StatusInternalServerError, message: "the standard approval workflow is not configured"}
+		}
+		return nil, err
+	}
+	return &workflow.ID, nil
+}
+
+// leaveBalance returns the employee's balance of the leave type for the year, locked for
+// update and accrued through the month given (YYYY-MM). A year's balance opens with what
+// the previous year carries forward.
+func leaveBalance(tx *gorm.DB, businessID uuid.UUID, userID string, leaveType models.LeaveType, year int, through string) (*models.LeaveBalance, error) {
+	find := func(year int, lock bool) (*models.LeaveBalance, error) {
+		query := tx.Where("business_vertical_id = ? AND user_id = ? AND leave_type_id = ? AND year = ?", businessID, userID, leaveType.ID, year)
+		if lock {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+		}
+		var b models.LeaveBalance
+		if err := query.First(&b).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		return &b, nil
+	}
+
+	balance, err := find(year, true)
+	if err != nil {
+		return nil, err
+	}
+	if balance == nil {
+		opening := 0.0
+		previous, err := find(year-1, true)
+		if err != nil {
+			return nil, err
+		}
+		if previous != nil {
+			if _, err := previous.Accrue(leaveType, fmt.Sprintf("%d-12", year-1)); err != nil {
+				return nil, err
+			}
+			if err := tx.Model(previous).Select("accrued", "accrued_through").Updates(previous).Error; err != nil {
+				return nil, err
+			}
+			opening = previous.CarryForward(leaveType)
+		}
+		created := models.LeaveBalance{BusinessVerticalID: businessID, UserID: userID, LeaveTypeID: leaveType.ID, Year: year, Opening: opening}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&created).Error; err != nil {
+			return nil, err
+		}
+		if balance, err = find(year, true); err != nil {
+			return nil, err
+		}
+		if balance == nil {
+			return nil, errors.New("failed to open leave balance")
+		}
+	}
+
+	accruedThrough := balance.AccruedThrough
+	if _, err := balance.Accrue(leaveType, through); err != nil {
+		return nil, err
+	}
+	if balance.AccruedThrough != accruedThrough {
+		if err := tx.Model(balance).Select("accrued", "accrued_through").Updates(balance).Error; err != nil {
+			return nil, err
+		}
+	}
+	return balance, nil
+}
+
+// pendingLeaveDays sums the days of the employee's leave of the type awaiting approval in
+// the year, other than the application given
+func pendingLeaveDays(db *gorm.DB, userID string, leaveTypeID uuid.UUID, year int, except uuid.UUID) (float64, error) {
+	var pending float64
+	err := db.Model(&models.LeaveApplication{}).Select("COALESCE(SUM(days), 0)").
+		Where("user_id = ? AND leave_type_id = ? AND current_state = ? AND EXTRACT(YEAR FROM from_date) = ? AND id <> ?",
+			userID, leaveTypeID, "submitted", year, except).
+		Scan(&pending).Error
+	return pending, err
+}
+
+// currentMonth returns this month as YYYY-MM
+func currentMonth() string {
+	return time.Now().Format("2006-01")
+}
+
+// ==========================
+// Leave type handlers
+// ==========================
+
+// ListLeaveTypes lists the business's leave types. ?active=true narrows them to the ones
+// open for applications.
+// GET /api/v1/business/{businessCode}/leave-types
+func ListLeaveTypes(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load leave types")
+		return
+	}
+
+	query := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID)
+	if r.URL.Query().Get("active") == "true" {
+		query = query.Where("is_active = ?", true)
+	}
+	var items []models.LeaveType
+	if err := query.Order("code").Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch leave types", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// leaveTypeRequest is the body of leave type create and update requests
+type leaveTypeRequest struct {
+	Code            string  `json:"code"`
+	Name            string  `json:"name"`
+	Paid            *bool   `json:"paid"`
+	AccrualMethod   string  `json:"accrual_method"`
+	AccrualDays     float64 `json:"accrual_days"`
+	MaxBalance      float64 `json:"max_balance"`
+	CarryForwardMax float64 `json:"carry_forward_max"`
+	AllowHalfDay    *bool   `json:"allow_half_day"`
+	AllowNegative   bool    `json:"allow_negative"`
+	MinNoticeDays   int     `json:"min_notice_days"`
+	IsActive        *bool   `json:"is_active"`
+}
+
+// apply copies the request onto the leave type, leaving flags that are not given as they
+// were, and validates the result
+func (req leaveTypeRequest) apply(t *models.LeaveType) error {
+	t.Code = strings.ToUpper(strings.TrimSpace(req.Code))
+	t.Name = strings.TrimSpace(req.Name)
+	t.AccrualMethod = strings.TrimSpace(req.AccrualMethod)
+	if t.AccrualMethod == "" {
+		t.AccrualMethod = models.LeaveAccrualMonthly
+	}
+	t.AccrualDays = req.AccrualDays
+	t.MaxBalance = req.MaxBalance
+	t.CarryForwardMax = req.CarryForwardMax
+	t.AllowNegative = req.AllowNegative
+	t.MinNoticeDays = req.MinNoticeDays
+	for _, flag := range []struct {
+		from *bool
+		to   *bool
+	}{{req.Paid, &t.Paid}, {req.AllowHalfDay, &t.AllowHalfDay}, {req.IsActive, &t.IsActive}} {
+		if flag.from != nil {
+			*flag.to = *flag.from
+		}
+	}
+	if err := t.Validate(); err != nil {
+		return apiError{status: http.StatusBadRequest, message: err.Error()}
+	}
+	return nil
+}
+
+// leaveTypeFlags are the columns Create would fill with their defaults when false
+var leaveTypeFlags = []string{"paid", "allow_half_day", "allow_negative", "is_active"}
+
+// CreateLeaveType adds a leave type with its accrual policy
+// POST /api/v1/business/{businessCode}/leave-types
+func CreateLeaveType(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to create leave type")
+		return
+	}
+
+	var req leaveTypeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	userID := middleware.GetClaims(r).UserID
+	leaveType := models.LeaveType{
+		BusinessVerticalID: businessID,
+		Paid:               true,
+		AllowHalfDay:       true,
+		IsActive:           true,
+		CreatedBy:          userID,
+		UpdatedBy:          userID,
+	}
+	if err := req.apply(&leaveType); err != nil {
+		writeProcurementErr(w, err, "failed to create leave type")
+		return
+	}
+	var count int64
+	middleware.ScopedDB(r).Model(&models.LeaveType{}).Where("business_vertical_id = ? AND code = ?", businessID, leaveType.Code).Count(&count)
+	if count > 0 {
+		http.Error(w, "a leave type with this code already exists", http.StatusConflict)
+		return
+	}
+
+	if err := middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&leaveType).Error; err != nil {
+			return err
+		}
+		return tx.Model(&leaveType).Select(leaveTypeFlags).Updates(&leaveType).Error
+	}); err != nil {
+		http.Error(w, "failed to create leave type", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "leave type created", "item": leaveType})
+}
+
+// UpdateLeaveType changes a leave type's policy. Balances already accrued stay as they are.
+// PUT /api/v1/business/{businessCode}/leave-types/{id}
+func UpdateLeaveType(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update leave type")
+		return
+	}
+	var leaveType models.LeaveType
+	if err := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID).First(&leaveType, "id = ?", mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "leave type not found", http.StatusNotFound)
+		return
+	}
+
+	var req leaveTypeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := req.apply(&leaveType); err != nil {
+		writeProcurementErr(w, err, "failed to update leave type")
+		return
+	}
+	var count int64
+	middleware.ScopedDB(r).Model(&models.LeaveType{}).Where("business_vertical_id = ? AND code = ? AND id <> ?", businessID, leaveType.Code, leaveType.ID).Count(&count)
+	if count > 0 {
+		http.Error(w, "a leave type with this code already exists", http.StatusConflict)
+		return
+	}
+	leaveType.UpdatedBy = middleware.GetClaims(r).UserID
+	columns := append([]string{"code", "name", "accrual_method", "accrual_days", "max_balance", "carry_forward_max",
+		"min_notice_days", "updated_by"}, leaveTypeFlags...)
+	if err := middleware.ScopedDB(r).Model(&leaveType).Select(columns).Updates(&leaveType).Error; err != nil {
+		http.Error(w, "failed to update leave type", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "leave type updated", "item": leaveType})
+}
+
+// ==========================
+// Leave balance handlers
+// ==========================
+
+// leaveBalanceView is a balance with the days awaiting approval against it
+type leaveBalanceView struct {
+	models.LeaveBalance
+	LeaveTypeCode string  `json:"leave_type_code"`
+	LeaveTypeName string  `json:"leave_type_name"`
+	Pending       float64 `json:"pending"`
+	Available     float64 `json:"available"`
+}
+
+// ListLeaveBalances returns an employee's balances for the year, accrued to date, for
+// each active leave type held to a balance. Employees see their own; ?user_id= picks
+// another's for HR and approvers. ?year= defaults to this year.
+// GET /api/v1/business/{businessCode}/leave/balances
+func ListLeaveBalances(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load leave balances")
+		return
+	}
+	userID := middleware.GetClaims(r).UserID
+	if v := r.URL.Query().Get("user_id"); v != "" && v != userID {
+		if !seesAllLeave(r) {
+			http.Error(w, "insufficient permissions: requires 'leave:manage' to see others' balances", http.StatusForbidden)
+			return
+		}
+		userID = v
+	}
+	year := time.Now().Year()
+	if v := r.URL.Query().Get("year"); v != "" {
+		if _, err := fmt.Sscanf(v, "%d", &year); err != nil {
+			http.Error(w, "year must be a number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var types []models.LeaveType
+	if err := middleware.ScopedDB(r).Where("business_vertical_id = ? AND is_active = ? AND accrual_method <> ?",
+		businessID, true, models.LeaveAccrualNone).Order("code").Find(&types).Error; err != nil {
+		http.Error(w, "failed to fetch leave types", http.StatusInternalServerError)
+		return
+	}
+	items := []leaveBalanceView{}
+	if err := middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		for _, t := range types {
+			balance, err := leaveBalance(tx, businessID, userID, t, year, currentMonth())
+			if err != nil {
+				return err
+			}
+			pending, err := pendingLeaveDays(tx, userID, t.ID, year, uuid.Nil)
+			if err != nil {
+				return err
+			}
+			items = append(items, leaveBalanceView{
+				LeaveBalance: *balance, LeaveTypeCode: t.Code, LeaveTypeName: t.Name,
+				Pending: pending, Available: balance.Available() - pending,
+			})
+		}
+		return nil
+	}); err != nil {
+		writeProcurementErr(w, err, "failed to load leave balances")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"user_id": userID, "year": year, "items": items})
+}
+
+// AdjustLeaveBalance adds days to an employee's balance, or takes them off with a
+// negative number, as when opening balances are brought over from another system
+// POST /api/v1/business/{businessCode}/leave/balances/adjust
+func AdjustLeaveBalance(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to adjust leave balance")
+		return
+	}
+
+	var req struct {
+		UserID      string    `json:"user_id"`
+		LeaveTypeID uuid.UUID `json:"leave_type_id"`
+		Year        int       `json:"year"`
+		Days        float64   `json:"days"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Days == 0 || req.Year < 2000 {
+		http.Error(w, "user_id, leave_type_id, year and non-zero days are required", http.StatusBadRequest)
+		return
+	}
+	var count int64
+	middleware.ScopedDB(r).Model(&models.User{}).Where("id = ?", req.UserID).Count(&count)
+	if count == 0 {
+		http.Error(w, "user not found", http.StatusBadRequest)
+		return
+	}
+	var leaveType models.LeaveType
+	if err := middleware.ScopedDB(r).Where("business_vertical_id = ? AND accrual_method <> ?", businessID, models.LeaveAccrualNone).
+		First(&leaveType, "id = ?", req.LeaveTypeID).Error; err != nil {
+		http.Error(w, "leave type not found, or not held to a balance", http.StatusBadRequest)
+		return
+	}
+
+	var balance *models.LeaveBalance
+	if err := middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if balance, err = leaveBalance(tx, businessID, req.UserID, leaveType, req.Year, currentMonth()); err != nil {
+			return err
+		}
+		balance.Adjusted += req.Days
+		return tx.Model(balance).Update("adjusted", balance.Adjusted).Error
+	}); err != nil {
+		writeProcurementErr(w, err, "failed to adjust leave balance")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "leave balance adjusted", "item": balance, "available": balance.Available()})
+}
+
+// RunLeaveAccrual credits leave through a month to every employee on the business's
+// payroll, that is with an active salary structure, for each active leave type that
+// accrues. Months already credited are skipped, so it can be run again safely.
+// POST /api/v1/business/{businessCode}/leave/accruals
+func RunLeaveAccrual(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to accrue leave")
+		return
+	}
+
+	var req struct {
+		Period string `json:"period"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	month, _, err := models.PayrollMonth(req.Period)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var types []models.LeaveType
+	if err := middleware.ScopedDB(r).Where("business_vertical_id = ? AND is_active = ? AND accrual_method <> ?",
+		businessID, true, models.LeaveAccrualNone).Find(&types).Error; err != nil {
+		http.Error(w, "failed to fetch leave types", http.StatusInternalServerError)
+		return
+	}
+	var userIDs []string
+	if err := middleware.ScopedDB(r).Model(&models.SalaryStructure{}).Where("business_vertical_id = ? AND is_active = ?", businessID, true).
+		Distinct("user_id").Pluck("user_id", &userIDs).Error; err != nil {
+		http.Error(w, "failed to fetch employees", http.StatusInternalServerError)
+		return
+	}
+
+	credited := 0.0
+	if err := middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		for _, userID := range userIDs {
+			for _, t := range types {
+				balance, err := leaveBalance(tx, businessID, userID, t, month.Year(), req.Period)
+				if err != nil {
+					return err
+				}
+				credited += balance.Accrued
+			}
+		}
+		return nil
+	}); err != nil {
+		writeProcurementErr(w, err, "failed to accrue leave")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "leave accrued", "period": req.Period, "employees": len(userIDs), "leave_types": len(types), "accrued_to_date": credited,
+	})
+}
+
+// ==========================
+// Leave application handlers
+// ==========================
+
+// loadLeaveApplication loads the application in the request within the business, if the
+// user may see it
+func loadLeaveApplication(db *gorm.DB, r *http.Request, businessID uuid.UUID) (*models.LeaveApplication, error) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		return nil, apiError{status: http.StatusBadRequest, message: "invalid leave application id"}
+	}
+	query := db.Preload("LeaveType").Where("business_vertical_id = ?", businessID)
+	if !seesAllLeave(r) {
+		query = query.Where("user_id = ?", middleware.GetClaims(r).UserID)
+	}
+	var app models.LeaveApplication
+	if err := query.First(&app, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError{status: http.StatusNotFound, message: "leave application not found"}
+		}
+		return nil, err
+	}
+	return &app, nil
+}
+
+// leaveApplicationRequest is the body of leave application create and update requests
+type leaveApplicationRequest struct {
+	LeaveTypeID uuid.UUID `json:"leave_type_id"`
+	FromDate    string    `json:"from_date"`
+	ToDate      string    `json:"to_date"`
+	HalfDay     bool      `json:"half_day"`
+	Reason      string    `json:"reason"`
+}
+
+// apply copies the request onto the application, checking it against its leave type
+func (req leaveApplicationRequest) apply(app *models.LeaveApplication, businessID uuid.UUID) error {
+	var leaveType models.LeaveType
+	if err := config.DB.Where("business_vertical_id = ? AND is_active = ?", businessID, true).
+		First(&leaveType, "id = ?", req.LeaveTypeID).Error; err != nil {
+		return apiError{status: http.StatusBadRequest, message: "leave type not found"}
+	}
+	from, err := parseAttendanceDate(req.FromDate)
+	if err != nil {
+		return apiError{status: http.StatusBadRequest, message: "from_date: " + err.Error()}
+	}
+	to := from
+	if req.ToDate != "" {
+		if to, err = parseAttendanceDate(req.ToDate); err != nil || to.Before(from) {
+			return apiError{status: http.StatusBadRequest, message: "to_date must be a date not before from_date"}
+		}
+	}
+	switch {
+	case to.Year() != from.Year():
+		return apiError{status: http.StatusBadRequest, message: "leave cannot span two years; apply for each year separately"}
+	case req.HalfDay && !to.Equal(from):
+		return apiError{status: http.StatusBadRequest, message: "a half day must be a single day"}
+	case req.HalfDay && !leaveType.AllowHalfDay:
+		return apiError{status: http.StatusBadRequest, message: leaveType.Name + " cannot be taken as a half day"}
+	}
+	if notice := leaveType.MinNoticeDays; notice > 0 {
+		today := calendarDate(time.Now(), attendanceLocation(nil))
+		if from.Before(today.AddDate(0, 0, notice)) {
+			return apiError{status: http.StatusBadRequest, message: fmt.Sprintf("%s needs %d days' notice", leaveType.Name, notice)}
+		}
+	}
+	days := models.LeaveDays(from, to, req.HalfDay)
+	if days == 0 {
+		return apiError{status: http.StatusBadRequest, message: "the leave covers no working days"}
+	}
+
+	app.LeaveTypeID = leaveType.ID
+	app.LeaveType = &leaveType
+	app.FromDate, app.ToDate = from, to
+	app.HalfDay = req.HalfDay
+	app.Days = days
+	app.Reason = strings.TrimSpace(req.Reason)
+	return nil
+}
+
+// ListLeaveApplications lists leave applications, latest first: the user's own, or every
+// employee's for HR and approvers. ?user_id=, ?state=, ?leave_type_id=, and ?from= and
+// ?to= (YYYY-MM-DD, leave overlapping them) narrow them.
+// GET /api/v1/business/{businessCode}/leave/applications
+func ListLeaveApplications(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load leave applications")
+		return
+	}
+
+	q := r.URL.Query()
+	query := middleware.ScopedDB(r).Preload("LeaveType").Where("business_vertical_id = ?", businessID)
+	if !seesAllLeave(r) {
+		query = query.Where("user_id = ?", middleware.GetClaims(r).UserID)
+	} else if v := q.Get("user_id"); v != "" {
+		query = query.Where("user_id = ?", v)
+	}
+	if v := q.Get("state"); v != "" {
+		query = query.Where("current_state = ?", v)
+	}
+	if v := q.Get("leave_type_id"); v != "" {
+		query = query.Where("leave_type_id = ?", v)
+	}
+	for _, bound := range []struct{ key, cond string }{{"from", "to_date >= ?"}, {"to", "from_date <= ?"}} {
+		if v := q.Get(bound.key); v != "" {
+			date, err := parseAttendanceDate(v)
+			if err != nil {
+				http.Error(w, bound.key+": "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			query = query.Where(bound.cond, date)
+		}
+	}
+	var items []models.LeaveApplication
+	if err := query.Order("from_date DESC, created_at DESC").Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch leave applications", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// CreateLeaveApplication drafts the user's application for leave; submitting it sends it
+// for approval
+// POST /api/v1/business/{businessCode}/leave/applications
+func CreateLeaveApplication(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to apply for leave")
+		return
+	}
+
+	var req leaveApplicationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	workflowID, err := leaveWorkflowID(middleware.ScopedDB(r))
+	if err != nil {
+		writeProcurementErr(w, err, "failed to apply for leave")
+		return
+	}
+	claims := middleware.GetClaims(r)
+	app := models.LeaveApplication{
+		BusinessVerticalID: businessID,
+		UserID:             claims.UserID,
+		EmployeeName:       claims.Name,
+		WorkflowID:         workflowID,
+		CurrentState:       "draft",
+		CreatedBy:          claims.UserID,
+		UpdatedBy:          claims.UserID,
+	}
+	if err := req.apply(&app, businessID); err != nil {
+		writeProcurementErr(w, err, "failed to apply for leave")
+		return
+	}
+	leaveType := app.LeaveType
+	app.LeaveType = nil
+	if err := middleware.ScopedDB(r).Create(&app).Error; err != nil {
+		http.Error(w, "failed to apply for leave", http.StatusInternalServerError)
+		return
+	}
+	app.LeaveType = leaveType
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "leave application drafted", "item": app})
+}
+
+// GetLeaveApplication returns a leave application with its workflow history and the
+// actions the user may take on it
+// GET /api/v1/business/{businessCode}/leave/applications/{id}
+func GetLeaveApplication(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load leave application")
+		return
+	}
+	app, err := loadLeaveApplication(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load leave application")
+		return
+	}
+
+	writeProcurementDocument(w, r, leaveApplicationRecord(app), app, nil)
+}
+
+// UpdateLeaveApplication changes a draft leave application. Only the applicant can.
+// PUT /api/v1/business/{businessCode}/leave/applications/{id}
+func UpdateLeaveApplication(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update leave application")
+		return
+	}
+	app, err := loadLeaveApplication(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load leave application")
+		return
+	}
+	if app.UserID != middleware.GetClaims(r).UserID {
+		http.Error(w, "only the applicant can change a leave application", http.StatusForbidden)
+		return
+	}
+	if app.CurrentState != "draft" {
+		http.Error(w, "only a draft leave application can be changed", http.StatusConflict)
+		return
+	}
+
+	var req leaveApplicationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := req.apply(app, businessID); err != nil {
+		writeProcurementErr(w, err, "failed to update leave application")
+		return
+	}
+	app.UpdatedBy = app.UserID
+	if err := middleware.ScopedDB(r).Model(app).Select("leave_type_id", "from_date", "to_date", "half_day", "days", "reason", "updated_by").
+		Updates(&models.LeaveApplication{LeaveTypeID: app.LeaveTypeID, FromDate: app.FromDate, ToDate: app.ToDate,
+			HalfDay: app.HalfDay, Days: app.Days, Reason: app.Reason, UpdatedBy: app.UpdatedBy}).Error; err != nil {
+		http.Error(w, "failed to update leave application", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "leave application updated", "item": app})
+}
+
+// checkLeaveAvailable refuses the application if it overlaps the employee's other leave,
+// or, for leave held to a balance, if the balance less what awaits approval would not
+// cover it
+func checkLeaveAvailable(tx *gorm.DB, app *models.LeaveApplication) error {
+	var count int64
+	if err := tx.Model(&models.LeaveApplication{}).
+		Where("user_id = ? AND id <> ? AND current_state IN ? AND from_date <= ? AND to_date >= ?",
+			app.UserID, app.ID, []string{"submitted", "approved"}, app.ToDate, app.FromDate).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		err := tx.Model(&models.EmployeeLeave{}).
+			Where("user_id = ? AND status = ? AND from_date <= ? AND to_date >= ?", app.UserID, models.LeaveApproved, app.ToDate, app.FromDate).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+	}
+	if count > 0 {
+		return apiError{status: http.StatusConflict, message: "the leave overlaps other leave applied for or approved"}
+	}
+
+	leaveType := app.LeaveType
+	if !leaveType.TracksBalance() || leaveType.AllowNegative {
+		return nil
+	}
+	balance, err := leaveBalance(tx, app.BusinessVerticalID, app.UserID, *leaveType, app.FromDate.Year(), currentMonth())
+	if err != nil {
+		return err
+	}
+	pending, err := pendingLeaveDays(tx, app.UserID, app.LeaveTypeID, app.FromDate.Year(), app.ID)
+	if err != nil {
+		return err
+	}
+	if available := balance.Available() - pending; available < app.Days {
+		return apiError{status: http.StatusUnprocessableEntity,
+			message: fmt.Sprintf("%s balance is %.1f days after pending applications; %.1f applied for", leaveType.Name, available, app.Days)}
+	}
+	return nil
+}
+
+// grantLeave records approved leave as EmployeeLeave for payroll, takes it from the
+// balance, and marks the employee on leave for the days with no attendance recorded
+func grantLeave(tx *gorm.DB, app *models.LeaveApplication, approverID string) error {
+	if period, err := lockedPayrollPeriod(tx, app.BusinessVerticalID, app.FromDate, app.ToDate); err != nil {
+		return err
+	} else if period != "" {
+		return apiError{status: http.StatusConflict, message: "payroll for " + period + " has gone for approval; revise it before approving leave"}
+	}
+	leaveType := app.LeaveType
+	if leaveType.TracksBalance() {
+		balance, err := leaveBalance(tx, app.BusinessVerticalID, app.UserID, *leaveType, app.FromDate.Year(), currentMonth())
+		if err != nil {
+			return err
+		}
+		if !leaveType.AllowNegative && balance.Available() < app.Days {
+			return apiError{status: http.StatusUnprocessableEntity, message: fmt.Sprintf("%s balance is only %.1f days", leaveType.Name, balance.Available())}
+		}
+		if err := tx.Model(balance).Update("used", balance.Used+app.Days).Error; err != nil {
+			return err
+		}
+	}
+
+	leave := models.EmployeeLeave{
+		BusinessVerticalID: app.BusinessVerticalID,
+		UserID:             app.UserID,
+		LeaveType:          leaveType.Code,
+		FromDate:           app.FromDate,
+		ToDate:             app.ToDate,
+		Paid:               leaveType.Paid,
+		HalfDay:            app.HalfDay,
+		Status:             models.LeaveApproved,
+		Reason:             app.Reason,
+		ApplicationID:      &app.ID,
+		RecordedBy:         approverID,
+	}
+	if err := tx.Create(&leave).Error; err != nil {
+		return err
+	}
+	// Create would record unpaid leave as paid, the column's default
+	if err := tx.Model(&leave).Update("paid", leave.Paid).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(app).Update("leave_id", leave.ID).Error; err != nil {
+		return err
+	}
+	if app.HalfDay {
+		return nil
+	}
+
+	userID, err := uuid.Parse(app.UserID)
+	if err != nil {
+		return nil
+	}
+	var siteID uuid.UUID
+	tx.Model(&models.AttendanceDay{}).Select("site_id").Where("user_id = ?", userID).Order("date DESC").Limit(1).Scan(&siteID)
+	if siteID == uuid.Nil {
+		return nil
+	}
+	days := []models.AttendanceDay{}
+	for d := app.FromDate; !d.After(app.ToDate); d = d.AddDate(0, 0, 1) {
+		if d.Weekday() == time.Sunday {
+			continue
+		}
+		days = append(days, models.AttendanceDay{
+			BusinessVerticalID: app.BusinessVerticalID, SiteID: siteID, UserID: userID, Date: d,
+			Source: models.AttendanceSourceLeave, Status: models.AttendanceOnLeave, Remarks: leaveType.Name, RecordedBy: approverID,
+		})
+	}
+	if len(days) == 0 {
+		return nil
+	}
+	// Days already recorded, as when the employee checked in, keep their record
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&days).Error
+}
+
+// TakeLeaveApplicationAction takes a workflow action (submit, approve, reject or revise)
+// on a leave application. Only the applicant submits and revises it, and it cannot be
+// submitted over other leave or beyond their balance. Approval records the leave for
+// payroll and attendance.
+// POST /api/v1/business/{businessCode}/leave/applications/{id}/actions/{action}
+func TakeLeaveApplicationAction(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update leave application")
+		return
+	}
+	app, err := loadLeaveApplication(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load leave application")
+		return
+	}
+	action := mux.Vars(r)["action"]
+	claims := middleware.GetClaims(r)
+	if (action == "submit" || action == "revise") && app.UserID != claims.UserID {
+		http.Error(w, "only the applicant can "+action+" a leave application", http.StatusForbidden)
+		return
+	}
+	var req procurementActionRequest
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	transition, err := takeProcurementAction(r, leaveApplicationRecord(app), action, req.Comment,
+		func(tx *gorm.DB, to string) error {
+			if action != "submit" && to != "approved" {
+				return nil
+			}
+			// Serialise the employee's applications so two cannot both fit in one balance
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "leave_applicant:"+app.UserID).Error; err != nil {
+				return err
+			}
+			if action == "submit" {
+				return checkLeaveAvailable(tx, app)
+			}
+			return grantLeave(tx, app, claims.UserID)
+		})
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update leave application")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "leave application " + transition.ToState, "transition": transition})
+}
+
+// CancelLeaveApplication withdraws a leave application. The applicant can withdraw it
+// until the leave starts; HR can cancel it at any time. Cancelling approved leave returns
+// its days to the balance and clears it from attendance, unless its month's payroll has
+// gone for approval.
+// POST /api/v1/business/{businessCode}/leave/applications/{id}/cancel
+func CancelLeaveApplication(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to cancel leave application")
+		return
+	}
+	app, err := loadLeaveApplication(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load leave application")
+		return
+	}
+	claims := middleware.GetClaims(r)
+	manages := hasWorkflowPermission(middleware.GetEffectivePermissions(r), "leave:manage")
+	switch {
+	case app.CurrentState == "rejected" || app.CurrentState == models.LeaveApplicationCancelled:
+		http.Error(w, "the leave application is already closed", http.StatusConflict)
+		return
+	case app.UserID != claims.UserID && !manages:
+		http.Error(w, "only the applicant or HR can cancel a leave application", http.StatusForbidden)
+		return
+	case !manages && app.CurrentState == "approved" && !calendarDate(time.Now(), attendanceLocation(nil)).Before(app.FromDate):
+		http.Error(w, "leave that has started can only be cancelled by HR", http.StatusForbidden)
+		return
+	}
+	var req procurementActionRequest
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	transition := models.WorkflowTransition{
+		SubmissionID:   app.ID,
+		FromState:      app.CurrentState,
+		ToState:        models.LeaveApplicationCancelled,
+		Action:         "cancel",
+		ActorID:        claims.UserID,
+		ActorName:      claims.Name,
+		ActorRole:      claims.Role,
+		Comment:        strings.TrimSpace(req.Comment),
+		Metadata:       json.RawMessage(`{}`),
+		TransitionedAt: time.Now(),
+	}
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.LeaveApplication{}).Where("id = ? AND current_state = ?", app.ID, app.CurrentState).
+			Updates(map[string]interface{}{"current_state": models.LeaveApplicationCancelled, "updated_by": claims.UserID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return apiError{status: http.StatusConflict, message: "the leave application changed state; reload and try again"}
+		}
+		if app.CurrentState == "approved" {
+			if period, err := lockedPayrollPeriod(tx, businessID, app.FromDate, app.ToDate); err != nil {
+				return err
+			} else if period != "" {
+				return apiError{status: http.StatusConflict, message: "payroll for " + period + " has gone for approval; revise it before cancelling leave"}
+			}
+			if app.LeaveID != nil {
+				if err := tx.Model(&models.EmployeeLeave{}).Where("id = ?", *app.LeaveID).Update("status", models.LeaveCancelled).Error; err != nil {
+					return err
+				}
+			}
+			if app.LeaveType.TracksBalance() {
+				if err := tx.Model(&models.LeaveBalance{}).
+					Where("user_id = ? AND leave_type_id = ? AND year = ?", app.UserID, app.LeaveTypeID, app.FromDate.Year()).
+					Update("used", gorm.Expr("used - ?", app.Days)).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Where("user_id = ? AND source = ? AND date BETWEEN ? AND ?", app.UserID, models.AttendanceSourceLeave, app.FromDate, app.ToDate).
+				Delete(&models.AttendanceDay{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&transition).Error
+	})
+	if err != nil {
+		writeProcurementErr(w, err, "failed to cancel leave application")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "leave application cancelled", "transition": transition})
+}
+
+// ==========================
+// Leave calendar
+// ==========================
+
+// leaveCalendarEntry is one employee's leave on a calendar day
+type leaveCalendarEntry struct {
+	ApplicationID uuid.UUID `json:"application_id"`
+	UserID        string    `json:"user_id"`
+	EmployeeName  string    `json:"employee_name"`
+	LeaveType     string    `json:"leave_type"`
+	HalfDay       bool      `json:"half_day"`
+	State         string    `json:"state"`
+}
+
+// GetLeaveCalendar shows, day by day, who in a team is on leave between two dates. The
+// team is the people with access to a site (?site_id=) or holding a business role
+// (?role_id=), or the whole business without either. Leave awaiting approval is included
+// unless ?approved_only=true. ?from= and ?to= (YYYY-MM-DD) default to this month.
+// GET /api/v1/business/{businessCode}/leave/calendar
+func GetLeaveCalendar(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load leave calendar")
+		return
+	}
+	q := r.URL.Query()
+	today := calendarDate(time.Now(), attendanceLocation(nil))
+	from := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, -1)
+	if v := q.Get("from"); v != "" {
+		if from, err = parseAttendanceDate(v); err != nil {
+			http.Error(w, "from: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		to = from.AddDate(0, 1, -1)
+	}
+	if v := q.Get("to"); v != "" {
+		if to, err = parseAttendanceDate(v); err != nil {
+			http.Error(w, "to: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if to.Before(from) || to.Sub(from).Hours()/24 >= attendanceDayMaxRange {
+		http.Error(w, fmt.Sprintf("to must be a date up to %d days after from", attendanceDayMaxRange-1), http.StatusBadRequest)
+		return
+	}
+
+	states := []string{"submitted", "approved"}
+	if q.Get("approved_only") == "true" {
+		states = []string{"approved"}
+	}
+	query := middleware.ScopedDB(r).Preload("LeaveType").
+		Where("business_vertical_id = ? AND current_state IN ? AND from_date <= ? AND to_date >= ?", businessID, states, to, from)
+	if siteID, ok := parseUUIDQuery(r, "site_id"); ok {
+		query = query.Where("user_id IN (?)", middleware.ScopedDB(r).Table("user_site_accesses").Select("user_id::text").Where("site_id = ?", siteID))
+	}
+	if roleID, ok := parseUUIDQuery(r, "role_id"); ok {
+		query = query.Where("user_id IN (?)", middleware.ScopedDB(r).Model(&models.UserBusinessRole{}).Select("user_id::text").
+			Where("business_role_id = ? AND is_active = ?", roleID, true))
+	}
+	var apps []models.LeaveApplication
+	if err := query.Order("employee_name").Find(&apps).Error; err != nil {
+		http.Error(w, "failed to fetch leave", http.StatusInternalServerError)
+		return
+	}
+
+	type calendarDay struct {
+		Date    string               `json:"date"`
+		OnLeave []leaveCalendarEntry `json:"on_leave"`
+	}
+	days := []calendarDay{}
+	employees := map[string]bool{}
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		day := calendarDay{Date: d.Format("2006-01-02"), OnLeave: []leaveCalendarEntry{}}
+		if d.Weekday() != time.Sunday {
+			for _, app := range apps {
+				if d.Before(app.FromDate) || d.After(app.ToDate) {
+					continue
+				}
+				entry := leaveCalendarEntry{ApplicationID: app.ID, UserID: app.UserID, EmployeeName: app.EmployeeName, HalfDay: app.HalfDay, State: app.CurrentState}
+				if app.LeaveType != nil {
+					entry.LeaveType = app.LeaveType.Code
+				}
+				day.OnLeave = append(day.OnLeave, entry)
+				employees[app.UserID] = true
+			}
+		}
+		days = append(days, day)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"from": from.Format("2006-01-02"), "to": to.Format("2006-01-02"), "employees_on_leave": len(employees), "days": days,
+	})
+}
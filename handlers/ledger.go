@@ -0,0 +1,1207 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// ledgerReportMaxRange caps the days an account ledger covers in one request
+const ledgerReportMaxRange = 366
+
+// lockedLedgerPeriod refuses a posting dated in a month whose books are locked
+func lockedLedgerPeriod(db *gorm.DB, businessID uuid.UUID, date time.Time) error {
+	var count int64
+	if err := db.Model(&models.LedgerPeriodLock{}).
+		Where("business_vertical_id = ? AND period = ?", businessID, date.Format("2006-01")).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return apiError{status: http.StatusConflict, message: "the books for " + date.Format("2006-01") + " are locked"}
+	}
+	return nil
+}
+
+// ledgerAccountByCode returns the business's account with the code, adding it from the
+// default chart if the business does not have it yet
+func ledgerAccountByCode(tx *gorm.DB, businessID uuid.UUID, code string) (*models.LedgerAccount, error) {
+	var account models.LedgerAccount
+	err := tx.Where("business_vertical_id = ? AND code = ?", businessID, code).First(&account).Error
+	if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return &account, err
+	}
+	for _, def := range models.DefaultChartOfAccounts() {
+		if def.Code == code {
+			def.BusinessVerticalID = businessID
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&def).Error; err != nil {
+				return nil, err
+			}
+			return &account, tx.Where("business_vertical_id = ? AND code = ?", businessID, code).First(&account).Error
+		}
+	}
+	return nil, fmt.Errorf("no default ledger account with code %s", code)
+}
+
+// numberJournalEntry numbers an entry per business and month of its date
+func numberJournalEntry(tx *gorm.DB, entry *models.JournalEntry) error {
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "journal_entries:"+entry.BusinessVerticalID.String()).Error; err != nil {
+		return err
+	}
+	prefix := "JV-" + entry.EntryDate.Format("200601") + "-"
+	var count int64
+	if err := tx.Model(&models.JournalEntry{}).
+		Where("business_vertical_id = ? AND entry_number LIKE ?", entry.BusinessVerticalID, prefix+"%").Count(&count).Error; err != nil {
+		return err
+	}
+	entry.EntryNumber = fmt.Sprintf("%s%04d", prefix, count+1)
+	return nil
+}
+
+// checkJournalLines balances the entry's lines and checks that their accounts and cost
+// centers are active in the business and their projects belong to it
+func checkJournalLines(db *gorm.DB, entry *models.JournalEntry) error {
+	lines, total, err := models.BalanceJournal(entry.Lines)
+	if err != nil {
+		return apiError{status: http.StatusBadRequest, message: err.Error()}
+	}
+	accounts, costCenters, projects := map[uuid.UUID]bool{}, map[uuid.UUID]bool{}, map[uuid.UUID]bool{}
+	for _, line := range lines {
+		accounts[line.AccountID] = true
+		if line.CostCenterID != nil {
+			costCenters[*line.CostCenterID] = true
+		}
+		if line.ProjectID != nil {
+			projects[*line.ProjectID] = true
+		}
+	}
+	for _, check := range []struct {
+		model   interface{}
+		ids     map[uuid.UUID]bool
+		active  bool
+		message string
+	}{
+		{&models.LedgerAccount{}, accounts, true, "the entry posts to accounts that are not active in this business"},
+		{&models.CostCenter{}, costCenters, true, "the entry uses cost centers that are not active in this business"},
+		{&models.Project{}, projects, false, "the entry uses projects that are not in this business"},
+	} {
+		if len(check.ids) == 0 {
+			continue
+		}
+		ids := make([]uuid.UUID, 0, len(check.ids))
+		for id := range check.ids {
+			ids = append(ids, id)
+		}
+		query := db.Model(check.model).Where("business_vertical_id = ? AND id IN ?", entry.BusinessVerticalID, ids)
+		if check.active {
+			query = query.Where("is_active = ?", true)
+		}
+		var count int64
+		if err := query.Count(&count).Error; err != nil {
+			return err
+		}
+		if int(count) != len(ids) {
+			return apiError{status: http.StatusBadRequest, message: check.message}
+		}
+	}
+	entry.Lines, entry.TotalAmount = lines, total
+	return nil
+}
+
+// ledgerPosting is a line of an automatic posting, against an account by its code
+type ledgerPosting struct {
+	code         string
+	debit        float64
+	credit       float64
+	costCenterID *uuid.UUID
+	projectID    *uuid.UUID
+	narration    string
+}
+
+// postToLedger posts an automatic journal entry for a payroll run, invoice, claim or
+// batch, within the transaction that approves or pays it. A posting into a locked month
+// fails, and with it the approval.
+func postToLedger(tx *gorm.DB, businessID uuid.UUID, source string, sourceID uuid.UUID, date time.Time, narration, userID string, postings []ledgerPosting) error {
+	if err := lockedLedgerPeriod(tx, businessID, date); err != nil {
+		return err
+	}
+	now := time.Now()
+	entry := models.JournalEntry{
+		BusinessVerticalID: businessID,
+		EntryDate:          date,
+		Narration:          narration,
+		Source:             source,
+		SourceID:           &sourceID,
+		Status:             models.JournalPosted,
+		PostedAt:           &now,
+		PostedBy:           userID,
+		CreatedBy:          userID,
+	}
+	for _, p := range postings {
+		account, err := ledgerAccountByCode(tx, businessID, p.code)
+		if err != nil {
+			return err
+		}
+		entry.Lines = append(entry.Lines, models.JournalLine{
+			AccountID: account.ID, Debit: p.debit, Credit: p.credit,
+			CostCenterID: p.costCenterID, ProjectID: p.projectID, Narration: p.narration,
+		})
+	}
+	lines, total, err := models.BalanceJournal(entry.Lines)
+	if err != nil {
+		return err
+	}
+	entry.Lines, entry.TotalAmount = lines, total
+	if err := numberJournalEntry(tx, &entry); err != nil {
+		return err
+	}
+	return tx.Create(&entry).Error
+}
+
+// siteCostCenter returns the cost center purchases for the site post to, if it has one
+func siteCostCenter(tx *gorm.DB, businessID, siteID uuid.UUID) *uuid.UUID {
+	var center models.CostCenter
+	if err := tx.Select("id").Where("business_vertical_id = ? AND site_id = ? AND is_active = ?", businessID, siteID, true).
+		First(&center).Error; err != nil {
+		return nil
+	}
+	return &center.ID
+}
+
+// checkCostCenter refuses a cost center that is not one of the business's active ones
+func checkCostCenter(db *gorm.DB, businessID uuid.UUID, id *uuid.UUID) error {
+	if id == nil {
+		return nil
+	}
+	var count int64
+	if err := db.Model(&models.CostCenter{}).Where("id = ? AND business_vertical_id = ? AND is_active = ?", *id, businessID, true).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return apiError{status: http.StatusBadRequest, message: "cost center not found among the business's active cost centers"}
+	}
+	return nil
+}
+
+// checkProjectCostCenter refuses a cost center that is not an active one of the project's
+// business
+func checkProjectCostCenter(db *gorm.DB, projectID uuid.UUID, id *uuid.UUID) error {
+	if id == nil {
+		return nil
+	}
+	var project models.Project
+	if err := db.Select("business_vertical_id").First(&project, "id = ?", projectID).Error; err != nil {
+		return err
+	}
+	return checkCostCenter(db, project.BusinessVerticalID, id)
+}
+
+// workCostCenter returns the cost center costs of a task, or of a project when no task is
+// given, are reported by: the task's own, else its project's
+func workCostCenter(db *gorm.DB, projectID, taskID *uuid.UUID) *uuid.UUID {
+	var center struct{ ID *uuid.UUID }
+	if taskID != nil {
+		db.Model(&models.Tasks{}).Select("cost_center_id AS id").Where("id = ?", *taskID).Scan(&center)
+		if center.ID != nil {
+			return center.ID
+		}
+	}
+	if projectID != nil {
+		db.Model(&models.Project{}).Select("cost_center_id AS id").Where("id = ?", *projectID).Scan(&center)
+	}
+	return center.ID
+}
+
+// postPayrollRun books an approved run's salaries: gross pay and employer contributions
+// as expenses against each employee's cost center and project, net pay owed to employees
+// and deductions and contributions owed to the authorities, on the last day of the month
+func postPayrollRun(tx *gorm.DB, run *models.PayrollRun, userID string) error {
+	_, to, err := models.PayrollMonth(run.Period)
+	if err != nil {
+		return err
+	}
+	var payslips []models.Payslip
+	if err := tx.Where("run_id = ?", run.ID).Order("employee_name").Find(&payslips).Error; err != nil {
+		return err
+	}
+
+	type tag struct{ costCenterID, projectID uuid.UUID }
+	var order []tag
+	gross, contributions := map[tag]float64{}, map[tag]float64{}
+	for _, p := range payslips {
+		var t tag
+		if p.CostCenterID != nil {
+			t.costCenterID = *p.CostCenterID
+		}
+		if p.ProjectID != nil {
+			t.projectID = *p.ProjectID
+		}
+		if _, ok := gross[t]; !ok {
+			order = append(order, t)
+		}
+		gross[t] += p.GrossEarnings
+		for _, c := range p.EmployerContributions {
+			contributions[t] += c.Amount
+		}
+	}
+	postings := make([]ledgerPosting, 0, 2*len(order)+2)
+	for _, t := range order {
+		var costCenterID, projectID *uuid.UUID
+		if t.costCenterID != uuid.Nil {
+			id := t.costCenterID
+			costCenterID = &id
+		}
+		if t.projectID != uuid.Nil {
+			id := t.projectID
+			projectID = &id
+		}
+		postings = append(postings,
+			ledgerPosting{code: models.AccountCodeSalaries, debit: gross[t], costCenterID: costCenterID, projectID: projectID},
+			ledgerPosting{code: models.AccountCodeEmployerContributions, debit: contributions[t], costCenterID: costCenterID, projectID: projectID},
+		)
+	}
+	postings = append(postings,
+		ledgerPosting{code: models.AccountCodeSalariesPayable, credit: run.TotalNetPay},
+		ledgerPosting{code: models.AccountCodeStatutoryPayable, credit: run.TotalDeductions + run.TotalEmployerContributions},
+	)
+	return postToLedger(tx, run.BusinessVerticalID, models.JournalPayroll, run.ID, to, "Payroll for "+run.Period, userID, postings)
+}
+
+// postVendorInvoice books an approved invoice's materials against the order's cost center
+// (its site's if it has none) and project, and its GST as input credit, owed to the
+// vendor, on the invoice date
+func postVendorInvoice(tx *gorm.DB, invoice *models.VendorInvoice, po *models.PurchaseOrder, userID string) error {
+	costCenterID := po.CostCenterID
+	if costCenterID == nil {
+		costCenterID = siteCostCenter(tx, invoice.BusinessVerticalID, po.SiteID)
+	}
+	// The ledger is kept in the base currency; materials take any rounding so it balances
+	total := models.ToBase(invoice.TotalAmount, invoice.ExchangeRate)
+	tax := models.ToBase(invoice.TaxAmount, invoice.ExchangeRate)
+	return postToLedger(tx, invoice.BusinessVerticalID, models.JournalPurchase, invoice.ID, invoice.InvoiceDate,
+		fmt.Sprintf("Invoice %s from %s against %s", invoice.InvoiceNumber, po.VendorName, po.OrderNumber), userID, []ledgerPosting{
+			{code: models.AccountCodeMaterials, debit: models.ToBase(total-tax, 1), costCenterID: costCenterID, projectID: po.ProjectID},
+			{code: models.AccountCodeInputTax, debit: tax},
+			{code: models.AccountCodeTradePayables, credit: total},
+		})
+}
+
+// postVendorPayment books a payment against a vendor invoice as settling what is owed to
+// the vendor, at the rate the invoice was booked at
+func postVendorPayment(tx *gorm.DB, invoice *models.VendorInvoice, payment *models.VendorInvoicePayment, userID string) error {
+	amount := models.ToBase(payment.Amount, invoice.ExchangeRate)
+	return postToLedger(tx, invoice.BusinessVerticalID, models.JournalVendorPayment, payment.ID, payment.PaidOn,
+		"Payment "+payment.Reference+" against invoice "+invoice.InvoiceNumber, userID, []ledgerPosting{
+			{code: models.AccountCodeTradePayables, debit: amount},
+			{code: models.AccountCodeBank, credit: amount},
+		})
+}
+
+// postExpenseClaim books an approved claim's expenses against their cost centers and
+// projects, owed to the claimant, on the day it is approved
+func postExpenseClaim(tx *gorm.DB, claim *models.ExpenseClaim, userID string) error {
+	postings := make([]ledgerPosting, 0, len(claim.Items)+1)
+	for _, item := range claim.Items {
+		postings = append(postings, ledgerPosting{
+			code: models.AccountCodeEmployeeExpenses, debit: item.Amount, costCenterID: item.CostCenterID, projectID: item.ProjectID,
+			narration: item.Category + ": " + item.Description,
+		})
+	}
+	postings = append(postings, ledgerPosting{code: models.AccountCodeReimbursementsDue, credit: claim.TotalAmount, narration: claim.ClaimantName})
+	return postToLedger(tx, claim.BusinessVerticalID, models.JournalExpense, claim.ID, calendarDate(time.Now(), attendanceLocation(nil)),
+		"Expense claim "+claim.ClaimNumber+" by "+claim.ClaimantName, userID, postings)
+}
+
+// postReimbursementBatch books a paid batch as settling the reimbursements owed
+func postReimbursementBatch(tx *gorm.DB, batch *models.ReimbursementBatch, paidAt time.Time, userID string) error {
+	return postToLedger(tx, batch.BusinessVerticalID, models.JournalReimbursement, batch.ID, calendarDate(paidAt, attendanceLocation(nil)),
+		"Reimbursement batch "+batch.BatchNumber+" paid", userID, []ledgerPosting{
+			{code: models.AccountCodeReimbursementsDue, debit: batch.TotalAmount},
+			{code: models.AccountCodeBank, credit: batch.TotalAmount},
+		})
+}
+
+// ==========================
+// Chart of accounts handlers
+// ==========================
+
+// ListLedgerAccounts lists the business's chart of accounts by code. ?type= and
+// ?active=true narrow it.
+// GET /api/v1/business/{businessCode}/ledger/accounts
+func ListLedgerAccounts(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load accounts")
+		return
+	}
+
+	query := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID)
+	if v := r.URL.Query().Get("type"); v != "" {
+		query = query.Where("type = ?", v)
+	}
+	if r.URL.Query().Get("active") == "true" {
+		query = query.Where("is_active = ?", true)
+	}
+	var items []models.LedgerAccount
+	if err := query.Order("code").Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch accounts", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// InstallDefaultChartOfAccounts adds the default accounts the business does not have
+// POST /api/v1/business/{businessCode}/ledger/accounts/defaults
+func InstallDefaultChartOfAccounts(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to add accounts")
+		return
+	}
+
+	userID := middleware.GetClaims(r).UserID
+	accounts := models.DefaultChartOfAccounts()
+	for i := range accounts {
+		accounts[i].BusinessVerticalID = businessID
+		accounts[i].CreatedBy = userID
+	}
+	result := middleware.ScopedDB(r).Clauses(clause.OnConflict{DoNothing: true}).Create(&accounts)
+	if result.Error != nil {
+		http.Error(w, "failed to add accounts", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "default accounts added", "added": result.RowsAffected})
+}
+
+// ledgerAccountRequest is the body of account create and update requests
+type ledgerAccountRequest struct {
+	Code        string     `json:"code"`
+	Name        string     `json:"name"`
+	Type        string     `json:"type"`
+	ParentID    *uuid.UUID `json:"parent_id"`
+	Description string     `json:"description"`
+	IsActive    *bool      `json:"is_active"`
+}
+
+// saveLedgerAccount validates the request onto the account and saves it
+func saveLedgerAccount(w http.ResponseWriter, r *http.Request, businessID uuid.UUID, account *models.LedgerAccount, status int) {
+	var req ledgerAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	userID := middleware.GetClaims(r).UserID
+	oldType := account.Type
+	account.Code = strings.TrimSpace(req.Code)
+	account.Name = strings.TrimSpace(req.Name)
+	account.Type = strings.ToLower(strings.TrimSpace(req.Type))
+	account.ParentID = req.ParentID
+	account.Description = strings.TrimSpace(req.Description)
+	if req.IsActive != nil {
+		account.IsActive = *req.IsActive
+	}
+	account.UpdatedBy = userID
+	if err := account.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var count int64
+	middleware.ScopedDB(r).Model(&models.LedgerAccount{}).Where("business_vertical_id = ? AND code = ? AND id <> ?", businessID, account.Code, account.ID).Count(&count)
+	if count > 0 {
+		http.Error(w, "an account with this code already exists", http.StatusConflict)
+		return
+	}
+	if account.ParentID != nil {
+		var parent models.LedgerAccount
+		if err := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID).First(&parent, "id = ?", *account.ParentID).Error; err != nil {
+			http.Error(w, "parent account not found", http.StatusBadRequest)
+			return
+		}
+		if parent.Type != account.Type {
+			http.Error(w, "an account must be of its parent's type", http.StatusBadRequest)
+			return
+		}
+	}
+	if oldType != "" && oldType != account.Type {
+		middleware.ScopedDB(r).Model(&models.JournalLine{}).Where("account_id = ?", account.ID).Count(&count)
+		if count > 0 {
+			http.Error(w, "the account has postings; its type cannot change", http.StatusConflict)
+			return
+		}
+	}
+
+	if status == http.StatusCreated {
+		account.CreatedBy = userID
+		// Create would skip a false is_active in favour of the column default
+		err := middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(account).Error; err != nil {
+				return err
+			}
+			return tx.Model(account).Select("is_active").Updates(account).Error
+		})
+		if err != nil {
+			http.Error(w, "failed to create account", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, status, map[string]interface{}{"message": "account created", "item": account})
+		return
+	}
+	if err := middleware.ScopedDB(r).Model(account).Select("code", "name", "type", "parent_id", "description", "is_active", "updated_by").
+		Updates(account).Error; err != nil {
+		http.Error(w, "failed to update account", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, status, map[string]interface{}{"message": "account updated", "item": account})
+}
+
+// CreateLedgerAccount adds an account to the business's chart
+// POST /api/v1/business/{businessCode}/ledger/accounts
+func CreateLedgerAccount(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to create account")
+		return
+	}
+	saveLedgerAccount(w, r, businessID, &models.LedgerAccount{ID: uuid.New(), BusinessVerticalID: businessID, IsActive: true}, http.StatusCreated)
+}
+
+// UpdateLedgerAccount changes an account. Its type is fixed once anything posts to it.
+// PUT /api/v1/business/{businessCode}/ledger/accounts/{id}
+func UpdateLedgerAccount(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update account")
+		return
+	}
+	var account models.LedgerAccount
+	if err := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID).First(&account, "id = ?", mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "account not found", http.StatusNotFound)
+		return
+	}
+	saveLedgerAccount(w, r, businessID, &account, http.StatusOK)
+}
+
+// ==========================
+// Cost center handlers
+// ==========================
+
+// ListCostCenters lists the business's cost centers by code
+// GET /api/v1/business/{businessCode}/ledger/cost-centers
+func ListCostCenters(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load cost centers")
+		return
+	}
+	var items []models.CostCenter
+	if err := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID).Order("code").Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch cost centers", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// saveCostCenter validates the request onto the cost center and saves it
+func saveCostCenter(w http.ResponseWriter, r *http.Request, businessID uuid.UUID, center *models.CostCenter, status int) {
+	var req struct {
+		Code     string     `json:"code"`
+		Name     string     `json:"name"`
+		SiteID   *uuid.UUID `json:"site_id"`
+		IsActive *bool      `json:"is_active"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	userID := middleware.GetClaims(r).UserID
+	center.Code = strings.ToUpper(strings.TrimSpace(req.Code))
+	center.Name = strings.TrimSpace(req.Name)
+	center.SiteID = req.SiteID
+	if req.IsActive != nil {
+		center.IsActive = *req.IsActive
+	}
+	center.UpdatedBy = userID
+	if center.Code == "" || center.Name == "" {
+		http.Error(w, "code and name are required", http.StatusBadRequest)
+		return
+	}
+
+	var count int64
+	middleware.ScopedDB(r).Model(&models.CostCenter{}).Where("business_vertical_id = ? AND code = ? AND id <> ?", businessID, center.Code, center.ID).Count(&count)
+	if count > 0 {
+		http.Error(w, "a cost center with this code already exists", http.StatusConflict)
+		return
+	}
+	if center.SiteID != nil {
+		middleware.ScopedDB(r).Model(&models.Site{}).Where("id = ? AND business_vertical_id = ?", *center.SiteID, businessID).Count(&count)
+		if count == 0 {
+			http.Error(w, "site not found in this business", http.StatusBadRequest)
+			return
+		}
+		middleware.ScopedDB(r).Model(&models.CostCenter{}).Where("site_id = ? AND is_active = ? AND id <> ?", *center.SiteID, true, center.ID).Count(&count)
+		if count > 0 && center.IsActive {
+			http.Error(w, "the site already has an active cost center", http.StatusConflict)
+			return
+		}
+	}
+
+	if status == http.StatusCreated {
+		center.CreatedBy = userID
+		err := middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(center).Error; err != nil {
+				return err
+			}
+			return tx.Model(center).Select("is_active").Updates(center).Error
+		})
+		if err != nil {
+			http.Error(w, "failed to create cost center", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, status, map[string]interface{}{"message": "cost center created", "item": center})
+		return
+	}
+	if err := middleware.ScopedDB(r).Model(center).Select("code", "name", "site_id", "is_active", "updated_by").Updates(center).Error; err != nil {
+		http.Error(w, "failed to update cost center", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, status, map[string]interface{}{"message": "cost center updated", "item": center})
+}
+
+// CreateCostCenter adds a cost center, optionally for a site
+// POST /api/v1/business/{businessCode}/ledger/cost-centers
+func CreateCostCenter(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to create cost center")
+		return
+	}
+	saveCostCenter(w, r, businessID, &models.CostCenter{ID: uuid.New(), BusinessVerticalID: businessID, IsActive: true}, http.StatusCreated)
+}
+
+// UpdateCostCenter changes a cost center
+// PUT /api/v1/business/{businessCode}/ledger/cost-centers/{id}
+func UpdateCostCenter(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update cost center")
+		return
+	}
+	var center models.CostCenter
+	if err := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID).First(&center, "id = ?", mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "cost center not found", http.StatusNotFound)
+		return
+	}
+	saveCostCenter(w, r, businessID, &center, http.StatusOK)
+}
+
+// ==========================
+// Journal entry handlers
+// ==========================
+
+// loadJournalEntry loads the journal entry in the request within the business, with its lines
+func loadJournalEntry(db *gorm.DB, r *http.Request, businessID uuid.UUID) (*models.JournalEntry, error) {
+	var entry models.JournalEntry
+	err := db.Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("debit DESC, created_at") }).Preload("Lines.Account").
+		Where("business_vertical_id = ?", businessID).First(&entry, "id = ?", mux.Vars(r)["id"]).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, apiError{status: http.StatusNotFound, message: "journal entry not found"}
+	}
+	return &entry, err
+}
+
+// ListJournalEntries lists the business's journal entries, newest first. ?from= and ?to=
+// (YYYY-MM-DD), ?source=, ?status= and ?account_id= narrow them.
+// GET /api/v1/business/{businessCode}/ledger/journal-entries
+func ListJournalEntries(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load journal entries")
+		return
+	}
+
+	q := r.URL.Query()
+	query := middleware.ScopedDB(r).Model(&models.JournalEntry{}).Where("business_vertical_id = ?", businessID)
+	for _, bound := range []struct{ param, clause string }{{"from", "entry_date >= ?"}, {"to", "entry_date <= ?"}} {
+		if v := q.Get(bound.param); v != "" {
+			date, err := parseAttendanceDate(v)
+			if err != nil {
+				http.Error(w, bound.param+": "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			query = query.Where(bound.clause, date)
+		}
+	}
+	for _, filter := range []string{"source", "status"} {
+		if v := q.Get(filter); v != "" {
+			query = query.Where(filter+" = ?", v)
+		}
+	}
+	if accountID, ok := parseUUIDQuery(r, "account_id"); ok {
+		query = query.Where("id IN (?)", middleware.ScopedDB(r).Model(&models.JournalLine{}).Select("entry_id").Where("account_id = ?", accountID))
+	}
+
+	page, limit := parsePagination(r)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		http.Error(w, "failed to count journal entries", http.StatusInternalServerError)
+		return
+	}
+	var items []models.JournalEntry
+	if err := query.Order("entry_date DESC, entry_number DESC").Limit(limit).Offset((page - 1) * limit).Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch journal entries", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "total": total, "page": page, "limit": limit})
+}
+
+// journalEntryRequest is the body of manual journal entry create and update requests
+type journalEntryRequest struct {
+	EntryDate string               `json:"entry_date"`
+	Narration string               `json:"narration"`
+	Lines     []models.JournalLine `json:"lines"`
+}
+
+// apply copies the request onto a draft entry and checks its lines
+func (req journalEntryRequest) apply(entry *models.JournalEntry) error {
+	date, err := parseAttendanceDate(req.EntryDate)
+	if err != nil {
+		return apiError{status: http.StatusBadRequest, message: "entry_date: " + err.Error()}
+	}
+	entry.EntryDate = date
+	entry.Narration = strings.TrimSpace(req.Narration)
+	if entry.Narration == "" {
+		return apiError{status: http.StatusBadRequest, message: "narration is required"}
+	}
+	entry.Lines = make([]models.JournalLine, 0, len(req.Lines))
+	for _, line := range req.Lines {
+		entry.Lines = append(entry.Lines, models.JournalLine{
+			AccountID: line.AccountID, Debit: line.Debit, Credit: line.Credit,
+			CostCenterID: line.CostCenterID, ProjectID: line.ProjectID, Narration: strings.TrimSpace(line.Narration),
+		})
+	}
+	return checkJournalLines(config.DB, entry)
+}
+
+// CreateJournalEntry drafts a manual journal entry. It reaches the ledger once posted.
+// POST /api/v1/business/{businessCode}/ledger/journal-entries
+func CreateJournalEntry(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to create journal entry")
+		return
+	}
+
+	var req journalEntryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	userID := middleware.GetClaims(r).UserID
+	entry := models.JournalEntry{
+		BusinessVerticalID: businessID,
+		Source:             models.JournalManual,
+		Status:             models.JournalDraft,
+		CreatedBy:          userID,
+		UpdatedBy:          userID,
+	}
+	if err := req.apply(&entry); err != nil {
+		writeProcurementErr(w, err, "failed to create journal entry")
+		return
+	}
+	if err := middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		if err := numberJournalEntry(tx, &entry); err != nil {
+			return err
+		}
+		return tx.Create(&entry).Error
+	}); err != nil {
+		http.Error(w, "failed to create journal entry", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "journal entry drafted", "item": entry})
+}
+
+// GetJournalEntry returns a journal entry with its lines and their accounts
+// GET /api/v1/business/{businessCode}/ledger/journal-entries/{id}
+func GetJournalEntry(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load journal entry")
+		return
+	}
+	entry, err := loadJournalEntry(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load journal entry")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"item": entry})
+}
+
+// UpdateJournalEntry replaces a draft entry's date, narration and lines
+// PUT /api/v1/business/{businessCode}/ledger/journal-entries/{id}
+func UpdateJournalEntry(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update journal entry")
+		return
+	}
+	entry, err := loadJournalEntry(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load journal entry")
+		return
+	}
+	if entry.Status != models.JournalDraft {
+		http.Error(w, "only a draft entry can be changed; reverse a posted one", http.StatusConflict)
+		return
+	}
+
+	var req journalEntryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := req.apply(entry); err != nil {
+		writeProcurementErr(w, err, "failed to update journal entry")
+		return
+	}
+	entry.UpdatedBy = middleware.GetClaims(r).UserID
+	for i := range entry.Lines {
+		entry.Lines[i].EntryID = entry.ID
+	}
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.JournalEntry{}).Where("id = ? AND status = ?", entry.ID, models.JournalDraft).
+			Updates(map[string]interface{}{
+				"entry_date": entry.EntryDate, "narration": entry.Narration, "total_amount": entry.TotalAmount, "updated_by": entry.UpdatedBy,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return apiError{status: http.StatusConflict, message: "the entry has been posted"}
+		}
+		if err := tx.Where("entry_id = ?", entry.ID).Delete(&models.JournalLine{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&entry.Lines).Error
+	})
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update journal entry")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "journal entry updated", "item": entry})
+}
+
+// DeleteJournalEntry discards a draft entry
+// DELETE /api/v1/business/{businessCode}/ledger/journal-entries/{id}
+func DeleteJournalEntry(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to delete journal entry")
+		return
+	}
+	entry, err := loadJournalEntry(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load journal entry")
+		return
+	}
+
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND status = ?", entry.ID, models.JournalDraft).Delete(&models.JournalEntry{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return apiError{status: http.StatusConflict, message: "only a draft entry can be deleted; reverse a posted one"}
+		}
+		return tx.Where("entry_id = ?", entry.ID).Delete(&models.JournalLine{}).Error
+	})
+	if err != nil {
+		writeProcurementErr(w, err, "failed to delete journal entry")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "journal entry deleted"})
+}
+
+// PostJournalEntry posts a draft entry to the ledger. It must be posted by someone other
+// than who drafted it, into an unlocked month.
+// POST /api/v1/business/{businessCode}/ledger/journal-entries/{id}/post
+func PostJournalEntry(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to post journal entry")
+		return
+	}
+	entry, err := loadJournalEntry(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load journal entry")
+		return
+	}
+	userID := middleware.GetClaims(r).UserID
+	if entry.CreatedBy == userID {
+		http.Error(w, "an entry must be posted by someone other than who drafted it", http.StatusForbidden)
+		return
+	}
+
+	now := time.Now()
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		if err := lockedLedgerPeriod(tx, businessID, entry.EntryDate); err != nil {
+			return err
+		}
+		// Accounts may have been deactivated since the entry was drafted
+		if err := checkJournalLines(tx, entry); err != nil {
+			return err
+		}
+		result := tx.Model(&models.JournalEntry{}).Where("id = ? AND status = ?", entry.ID, models.JournalDraft).
+			Updates(map[string]interface{}{"status": models.JournalPosted, "posted_at": now, "posted_by": userID, "updated_by": userID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return apiError{status: http.StatusConflict, message: "only a draft entry can be posted"}
+		}
+		return nil
+	})
+	if err != nil {
+		writeProcurementErr(w, err, "failed to post journal entry")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "journal entry posted"})
+}
+
+// ReverseJournalEntry undoes a posted entry with a posted entry of the opposite lines,
+// dated ?date= or today. Automatic postings are reversed the same way.
+// POST /api/v1/business/{businessCode}/ledger/journal-entries/{id}/reverse
+func ReverseJournalEntry(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to reverse journal entry")
+		return
+	}
+	entry, err := loadJournalEntry(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load journal entry")
+		return
+	}
+
+	var req struct {
+		Date      string `json:"date"`
+		Narration string `json:"narration"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	date := calendarDate(time.Now(), attendanceLocation(nil))
+	if req.Date != "" {
+		if date, err = parseAttendanceDate(req.Date); err != nil {
+			http.Error(w, "date: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if date.Before(entry.EntryDate) {
+		http.Error(w, "a reversal cannot be dated before the entry it reverses", http.StatusBadRequest)
+		return
+	}
+	narration := strings.TrimSpace(req.Narration)
+	if narration == "" {
+		narration = "Reversal of " + entry.EntryNumber
+	}
+
+	userID := middleware.GetClaims(r).UserID
+	now := time.Now()
+	reversal := models.JournalEntry{
+		BusinessVerticalID: businessID,
+		EntryDate:          date,
+		Narration:          narration,
+		Source:             models.JournalReversal,
+		SourceID:           &entry.ID,
+		Status:             models.JournalPosted,
+		TotalAmount:        entry.TotalAmount,
+		PostedAt:           &now,
+		PostedBy:           userID,
+		CreatedBy:          userID,
+		Lines:              models.ReverseLines(entry.Lines),
+	}
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		if err := lockedLedgerPeriod(tx, businessID, date); err != nil {
+			return err
+		}
+		if err := numberJournalEntry(tx, &reversal); err != nil {
+			return err
+		}
+		if err := tx.Create(&reversal).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&models.JournalEntry{}).Where("id = ? AND status = ?", entry.ID, models.JournalPosted).
+			Updates(map[string]interface{}{"status": models.JournalReversed, "reversed_by_id": reversal.ID, "updated_by": userID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return apiError{status: http.StatusConflict, message: "only a posted entry can be reversed"}
+		}
+		return nil
+	})
+	if err != nil {
+		writeProcurementErr(w, err, "failed to reverse journal entry")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "journal entry reversed", "item": reversal})
+}
+
+// ==========================
+// Period lock handlers
+// ==========================
+
+// ListLedgerPeriodLocks lists the business's locked months, latest first
+// GET /api/v1/business/{businessCode}/ledger/period-locks
+func ListLedgerPeriodLocks(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load period locks")
+		return
+	}
+	var items []models.LedgerPeriodLock
+	if err := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID).Order("period DESC").Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch period locks", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// LockLedgerPeriod closes a month's books. Draft entries dated in it stay drafts until
+// it is unlocked.
+// POST /api/v1/business/{businessCode}/ledger/period-locks
+func LockLedgerPeriod(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to lock period")
+		return
+	}
+
+	var req struct {
+		Period  string `json:"period"`
+		Remarks string `json:"remarks"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if _, _, err := models.PayrollMonth(req.Period); err != nil {
+		http.Error(w, "period must be a YYYY-MM month", http.StatusBadRequest)
+		return
+	}
+	lock := models.LedgerPeriodLock{
+		BusinessVerticalID: businessID,
+		Period:             req.Period,
+		Remarks:            strings.TrimSpace(req.Remarks),
+		LockedBy:           middleware.GetClaims(r).UserID,
+		LockedAt:           time.Now(),
+	}
+	result := middleware.ScopedDB(r).Clauses(clause.OnConflict{DoNothing: true}).Create(&lock)
+	if result.Error != nil {
+		http.Error(w, "failed to lock period", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "the period is already locked", http.StatusConflict)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "period locked", "item": lock})
+}
+
+// UnlockLedgerPeriod reopens a month's books
+// DELETE /api/v1/business/{businessCode}/ledger/period-locks/{period}
+func UnlockLedgerPeriod(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to unlock period")
+		return
+	}
+
+	result := middleware.ScopedDB(r).Where("business_vertical_id = ? AND period = ?", businessID, mux.Vars(r)["period"]).Delete(&models.LedgerPeriodLock{})
+	if result.Error != nil {
+		http.Error(w, "failed to unlock period", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "the period is not locked", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "period unlocked"})
+}
+
+// ==========================
+// Ledger report handlers
+// ==========================
+
+// postedLines selects the posted lines of the business's entries, narrowed to the
+// ?cost_center_id= and ?project_id= of the request. Reversed entries stay in, offset by
+// their reversals.
+func postedLines(r *http.Request, businessID uuid.UUID) *gorm.DB {
+	query := middleware.ScopedDB(r).Table("journal_lines jl").
+		Joins("JOIN journal_entries je ON je.id = jl.entry_id").
+		Where("je.business_vertical_id = ? AND je.status IN ?", businessID, []string{models.JournalPosted, models.JournalReversed})
+	if id, ok := parseUUIDQuery(r, "cost_center_id"); ok {
+		query = query.Where("jl.cost_center_id = ?", id)
+	}
+	if id, ok := parseUUIDQuery(r, "project_id"); ok {
+		query = query.Where("jl.project_id = ?", id)
+	}
+	return query
+}
+
+// GetTrialBalance returns each account's debits, credits and balance from ?from= to ?to=
+// (YYYY-MM-DD; from the start of the books to today by default), for ?cost_center_id=
+// or ?project_id= if given, with the totals
+// GET /api/v1/business/{businessCode}/ledger/trial-balance
+func GetTrialBalance(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load trial balance")
+		return
+	}
+
+	query := postedLines(r, businessID).Joins("JOIN ledger_accounts la ON la.id = jl.account_id")
+	to := calendarDate(time.Now(), attendanceLocation(nil))
+	if v := r.URL.Query().Get("to"); v != "" {
+		if to, err = parseAttendanceDate(v); err != nil {
+			http.Error(w, "to: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	query = query.Where("je.entry_date <= ?", to)
+	if v := r.URL.Query().Get("from"); v != "" {
+		from, err := parseAttendanceDate(v)
+		if err != nil {
+			http.Error(w, "from: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		query = query.Where("je.entry_date >= ?", from)
+	}
+
+	var rows []models.AccountBalance
+	if err := query.Select("la.id AS account_id, la.code, la.name, la.type, SUM(jl.debit) AS debit, SUM(jl.credit) AS credit").
+		Group("la.id, la.code, la.name, la.type").Order("la.code").Scan(&rows).Error; err != nil {
+		http.Error(w, "failed to compute trial balance", http.StatusInternalServerError)
+		return
+	}
+	var debits, credits float64
+	for i := range rows {
+		rows[i].Settle()
+		debits += rows[i].Debit
+		credits += rows[i].Credit
+	}
+	debits = math.Round(debits*100) / 100
+	credits = math.Round(credits*100) / 100
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"to":           to.Format("2006-01-02"),
+		"items":        rows,
+		"total_debit":  debits,
+		"total_credit": credits,
+		"balanced":     debits == credits,
+	})
+}
+
+// ledgerLine is a line of an account ledger, with the balance after it
+type ledgerLine struct {
+	EntryID      uuid.UUID  `json:"entry_id"`
+	EntryNumber  string     `json:"entry_number"`
+	EntryDate    time.Time  `json:"entry_date"`
+	Source       string     `json:"source"`
+	Narration    string     `json:"narration"`
+	Debit        float64    `json:"debit"`
+	Credit       float64    `json:"credit"`
+	CostCenterID *uuid.UUID `json:"cost_center_id,omitempty"`
+	ProjectID    *uuid.UUID `json:"project_id,omitempty"`
+	Balance      float64    `json:"balance"`
+}
+
+// GetAccountLedger returns an account's postings from ?from= to ?to= (YYYY-MM-DD, at most
+// ledgerReportMaxRange days), with its opening balance and the running balance, for
+// ?cost_center_id= or ?project_id= if given
+// GET /api/v1/business/{businessCode}/ledger/accounts/{id}/ledger
+func GetAccountLedger(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load ledger")
+		return
+	}
+	var account models.LedgerAccount
+	if err := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID).First(&account, "id = ?", mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "account not found", http.StatusNotFound)
+		return
+	}
+	from, err := parseAttendanceDate(r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, "from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseAttendanceDate(r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, "to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if to.Before(from) || to.Sub(from).Hours()/24 >= ledgerReportMaxRange {
+		http.Error(w, fmt.Sprintf("to must be on or after from and at most %d days later", ledgerReportMaxRange), http.StatusBadRequest)
+		return
+	}
+
+	opening := models.AccountBalance{Type: account.Type}
+	if err := postedLines(r, businessID).Where("jl.account_id = ? AND je.entry_date < ?", account.ID, from).
+		Select("COALESCE(SUM(jl.debit), 0) AS debit, COALESCE(SUM(jl.credit), 0) AS credit").Scan(&opening).Error; err != nil {
+		http.Error(w, "failed to compute opening balance", http.StatusInternalServerError)
+		return
+	}
+	opening.Settle()
+
+	var lines []ledgerLine
+	if err := postedLines(r, businessID).Where("jl.account_id = ? AND je.entry_date BETWEEN ? AND ?", account.ID, from, to).
+		Select("je.id AS entry_id, je.entry_number, je.entry_date, je.source, COALESCE(NULLIF(jl.narration, ''), je.narration) AS narration, " +
+			"jl.debit, jl.credit, jl.cost_center_id, jl.project_id").
+		Order("je.entry_date, je.entry_number").Scan(&lines).Error; err != nil {
+		http.Error(w, "failed to fetch ledger", http.StatusInternalServerError)
+		return
+	}
+	closing := opening
+	for i := range lines {
+		closing.Debit += lines[i].Debit
+		closing.Credit += lines[i].Credit
+		running := models.AccountBalance{Type: account.Type, Debit: closing.Debit, Credit: closing.Credit}
+		running.Settle()
+		lines[i].Balance = running.Balance
+	}
+	closing.Settle()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"account":         account,
+		"from":            from.Format("2006-01-02"),
+		"to":              to.Format("2006-01-02"),
+		"opening_balance": opening.Balance,
+		"items":           lines,
+		"closing_balance": closing.Balance,
+	})
+}
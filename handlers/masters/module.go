@@ -5,10 +5,10 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/handlers"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
@@ -41,7 +41,7 @@ func GetModules(w http.ResponseWriter, r *http.Request) {
 	showAll := r.URL.Query().Get("all") == "true"
 
 	var modules []models.Module
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Where("is_active = ?", true).
 		Order("display_order ASC, name ASC").
 		Find(&modules).Error; err != nil {
@@ -54,7 +54,7 @@ func GetModules(w http.ResponseWriter, r *http.Request) {
 	if isSuperAdmin && showAll {
 		// Get all business verticals
 		var verticals []models.BusinessVertical
-		config.DB.Where("is_active = ?", true).Order("name ASC").Find(&verticals)
+		middleware.ScopedDB(r).Where("is_active = ?", true).Order("name ASC").Find(&verticals)
 
 		// Group modules by vertical
 		verticalModules := make(map[string][]models.Module)
@@ -137,8 +137,9 @@ func GetModules(w http.ResponseWriter, r *http.Request) {
 // getUserVerticalCodes returns the list of vertical codes the user has access to
 func getUserVerticalCodes(user *models.User) []string {
 	verticalMap := make(map[string]bool)
+	now := time.Now()
 	for _, ubr := range user.UserBusinessRoles {
-		if ubr.IsActive && ubr.BusinessRole.BusinessVerticalID != uuid.Nil && ubr.BusinessRole.BusinessVertical.IsActive {
+		if ubr.IsEffectiveAt(now) && ubr.BusinessRole.BusinessVerticalID != uuid.Nil && ubr.BusinessRole.BusinessVertical.IsActive {
 			verticalMap[ubr.BusinessRole.BusinessVertical.Code] = true
 		}
 	}
@@ -187,7 +188,7 @@ func CreateModule(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create module record in database
-	if err := config.DB.Create(&module).Error; err != nil {
+	if err := middleware.ScopedDB(r).Create(&module).Error; err != nil {
 		// Attempt to clean up the schema if module creation fails
 		_ = schemaManager.DropSchema(schemaName, true)
 		log.Printf("Failed to create module %s: %v", module.Code, err)
@@ -223,7 +224,7 @@ func UpdateModule(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var existing models.Module
-	if err := config.DB.First(&existing, "id = ?", id).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&existing, "id = ?", id).Error; err != nil {
 		http.Error(w, "module not found", http.StatusNotFound)
 		return
 	}
@@ -241,7 +242,7 @@ func UpdateModule(w http.ResponseWriter, r *http.Request) {
 	existing.DisplayOrder = input.DisplayOrder
 	existing.IsActive = input.IsActive
 
-	if err := config.DB.Save(&existing).Error; err != nil {
+	if err := middleware.ScopedDB(r).Save(&existing).Error; err != nil {
 		log.Printf("Failed to update module %s: %v", id, err)
 		http.Error(w, "failed to update module", http.StatusInternalServerError)
 		return
@@ -272,12 +273,12 @@ func DeleteModule(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var existing models.Module
-	if err := config.DB.First(&existing, "id = ?", id).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&existing, "id = ?", id).Error; err != nil {
 		http.Error(w, "module not found", http.StatusNotFound)
 		return
 	}
 
-	if err := config.DB.Delete(&existing).Error; err != nil {
+	if err := middleware.ScopedDB(r).Delete(&existing).Error; err != nil {
 		log.Printf("Failed to delete module %s: %v", id, err)
 		http.Error(w, "failed to delete module", http.StatusInternalServerError)
 		return
@@ -11,7 +11,6 @@ import (
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"golang.org/x/sync/singleflight"
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 	"p9e.in/ugcl/utils"
@@ -93,7 +92,7 @@ func GetAllSites(w http.ResponseWriter, r *http.Request) {
 
 		// Get total count of all active sites
 		var total int64
-		if err := config.DB.Model(&models.Site{}).Where("sites.is_active = ?", true).Count(&total).Error; err != nil {
+		if err := middleware.ScopedDB(r).Model(&models.Site{}).Where("sites.is_active = ?", true).Count(&total).Error; err != nil {
 			return nil, err
 		}
 
@@ -105,7 +104,7 @@ func GetAllSites(w http.ResponseWriter, r *http.Request) {
 		}
 
 		var sites []SiteWithBusinessVertical
-		err := config.DB.Table("sites").
+		err := middleware.ScopedDB(r).Table("sites").
 			Select("sites.*, business_verticals.name as business_vertical_name, business_verticals.code as business_vertical_code").
 			Joins("LEFT JOIN business_verticals ON sites.business_vertical_id = business_verticals.id").
 			Where("sites.is_active = ?", true).
@@ -144,7 +143,7 @@ func GetSiteByID(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	siteID := vars["siteId"]
 	var site models.Site
-	if err := config.DB.Where("id = ? AND is_active = ?", siteID, true).First(&site).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ? AND is_active = ?", siteID, true).First(&site).Error; err != nil {
 		http.Error(w, "site not found", http.StatusNotFound)
 		return
 	}
@@ -181,7 +180,7 @@ func CreateSite(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := config.DB.Create(&site).Error; err != nil {
+	if err := middleware.ScopedDB(r).Create(&site).Error; err != nil {
 		http.Error(w, fmt.Sprintf("failed to create site: %s", err.Error()), http.StatusInternalServerError)
 		return
 	}
@@ -199,7 +198,7 @@ func UpdateSite(w http.ResponseWriter, r *http.Request) {
 
 	// Check if site exists
 	var existingSite models.Site
-	if err := config.DB.Where("id = ? AND is_active = ?", siteID, true).First(&existingSite).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ? AND is_active = ?", siteID, true).First(&existingSite).Error; err != nil {
 		http.Error(w, "site not found", http.StatusNotFound)
 		return
 	}
@@ -240,7 +239,7 @@ func UpdateSite(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Save updates
-	if err := config.DB.Save(&existingSite).Error; err != nil {
+	if err := middleware.ScopedDB(r).Save(&existingSite).Error; err != nil {
 		http.Error(w, fmt.Sprintf("failed to update site: %s", err.Error()), http.StatusInternalServerError)
 		return
 	}
@@ -279,13 +278,20 @@ func GetBusinessSites(w http.ResponseWriter, r *http.Request) {
 	}
 	offset := (page - 1) * limit
 
+	query := middleware.ScopedDB(r).Model(&models.Site{}).Where("business_vertical_id = ? AND is_active = ?", businessID, true)
+
+	// Users whose roles are limited to specific sites only see those sites
+	if siteIDs, restricted := middleware.GetSiteScope(r); restricted {
+		query = query.Where("id IN ?", siteIDs)
+	}
+
 	// Get total count for this business
 	var total int64
-	config.DB.Model(&models.Site{}).Where("business_vertical_id = ? AND is_active = ?", businessID, true).Count(&total)
+	query.Count(&total)
 
 	// Get paginated sites for this business
 	var sites []models.Site
-	if err := config.DB.Where("business_vertical_id = ? AND is_active = ?", businessID, true).
+	if err := query.
 		Limit(limit).
 		Offset(offset).
 		Find(&sites).Error; err != nil {
@@ -337,7 +343,7 @@ func GetUserSites(w http.ResponseWriter, r *http.Request) {
 
 	// Get total count
 	var total int64
-	config.DB.Table("user_site_accesses").
+	middleware.ScopedDB(r).Table("user_site_accesses").
 		Joins("JOIN sites ON sites.id = user_site_accesses.site_id").
 		Where("user_site_accesses.user_id = ? AND sites.business_vertical_id = ? AND sites.is_active = ?",
 			user.ID, businessID, true).
@@ -353,7 +359,7 @@ func GetUserSites(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var result []SiteWithAccess
-	err := config.DB.Table("sites").
+	err := middleware.ScopedDB(r).Table("sites").
 		Select("sites.*, user_site_accesses.can_read, user_site_accesses.can_create, user_site_accesses.can_update, user_site_accesses.can_delete").
 		Joins("JOIN user_site_accesses ON user_site_accesses.site_id = sites.id").
 		Where("user_site_accesses.user_id = ? AND sites.business_vertical_id = ? AND sites.is_active = ?",
@@ -424,7 +430,7 @@ func GetUserSiteAccessByUserID(w http.ResponseWriter, r *http.Request) {
 	}
 	offset := (page - 1) * limit
 
-	baseQuery := config.DB.Model(&models.UserSiteAccess{}).
+	baseQuery := middleware.ScopedDB(r).Model(&models.UserSiteAccess{}).
 		Joins("JOIN sites ON sites.id = user_site_accesses.site_id").
 		Where("user_site_accesses.user_id = ? AND sites.business_vertical_id = ? AND sites.is_active = ?", userID, businessID, true)
 
@@ -435,7 +441,7 @@ func GetUserSiteAccessByUserID(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var accesses []models.UserSiteAccess
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Preload("Site").
 		Joins("JOIN sites ON sites.id = user_site_accesses.site_id").
 		Where("user_site_accesses.user_id = ? AND sites.business_vertical_id = ? AND sites.is_active = ?", userID, businessID, true).
@@ -519,14 +525,14 @@ func AssignUserSiteAccess(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var site models.Site
-	if err := config.DB.Where("id = ? AND business_vertical_id = ?", req.SiteID, businessID).First(&site).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ?", req.SiteID, businessID).First(&site).Error; err != nil {
 		http.Error(w, "site not found or does not belong to this business", http.StatusNotFound)
 		return
 	}
 
 	// Check if access already exists
 	var existing models.UserSiteAccess
-	err = config.DB.Where("user_id = ? AND site_id = ?", req.UserID, req.SiteID).First(&existing).Error
+	err = middleware.ScopedDB(r).Where("user_id = ? AND site_id = ?", req.UserID, req.SiteID).First(&existing).Error
 
 	if err != nil {
 		// Create new access
@@ -540,7 +546,7 @@ func AssignUserSiteAccess(w http.ResponseWriter, r *http.Request) {
 			AssignedBy: &currentUserID,
 		}
 
-		if err := config.DB.Create(&access).Error; err != nil {
+		if err := middleware.ScopedDB(r).Create(&access).Error; err != nil {
 			http.Error(w, "failed to create site access", http.StatusInternalServerError)
 			return
 		}
@@ -555,7 +561,7 @@ func AssignUserSiteAccess(w http.ResponseWriter, r *http.Request) {
 		existing.CanDelete = req.CanDelete
 		existing.AssignedBy = &currentUserID
 
-		if err := config.DB.Save(&existing).Error; err != nil {
+		if err := middleware.ScopedDB(r).Save(&existing).Error; err != nil {
 			http.Error(w, "failed to update site access", http.StatusInternalServerError)
 			return
 		}
@@ -570,7 +576,7 @@ func RevokeUserSiteAccess(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	accessID := vars["accessId"]
 
-	if err := config.DB.Delete(&models.UserSiteAccess{}, "id = ?", accessID).Error; err != nil {
+	if err := middleware.ScopedDB(r).Delete(&models.UserSiteAccess{}, "id = ?", accessID).Error; err != nil {
 		http.Error(w, "failed to revoke site access", http.StatusInternalServerError)
 		return
 	}
@@ -600,7 +606,7 @@ func GetSiteUsers(w http.ResponseWriter, r *http.Request) {
 
 	// Get total count
 	var total int64
-	config.DB.Table("user_site_accesses").
+	middleware.ScopedDB(r).Table("user_site_accesses").
 		Where("user_site_accesses.site_id = ?", siteID).
 		Count(&total)
 
@@ -615,7 +621,7 @@ func GetSiteUsers(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var users []UserAccess
-	err := config.DB.Table("user_site_accesses").
+	err := middleware.ScopedDB(r).Table("user_site_accesses").
 		Select("users.id as user_id, users.name, users.phone, user_site_accesses.can_read, user_site_accesses.can_create, user_site_accesses.can_update, user_site_accesses.can_delete").
 		Joins("JOIN users ON users.id = user_site_accesses.user_id").
 		Where("user_site_accesses.site_id = ?", siteID).
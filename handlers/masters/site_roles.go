@@ -0,0 +1,230 @@
+package masters
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// SiteRoleAssignment is a business role granted to a user for one site only
+type SiteRoleAssignment struct {
+	ID               uuid.UUID  `json:"id"`
+	UserID           uuid.UUID  `json:"userId"`
+	UserName         string     `json:"userName"`
+	BusinessRoleID   uuid.UUID  `json:"businessRoleId"`
+	BusinessRoleName string     `json:"businessRoleName"`
+	SiteID           uuid.UUID  `json:"siteId"`
+	AssignedAt       time.Time  `json:"assignedAt"`
+	AssignedBy       *uuid.UUID `json:"assignedBy,omitempty"`
+	ValidFrom        *time.Time `json:"validFrom,omitempty"`
+	ValidUntil       *time.Time `json:"validUntil,omitempty"`
+}
+
+// AssignSiteRoleRequest represents the request body for a site-scoped role assignment
+type AssignSiteRoleRequest struct {
+	UserID         uuid.UUID  `json:"userId"`
+	BusinessRoleID uuid.UUID  `json:"businessRoleId"`
+	ValidFrom      *time.Time `json:"validFrom,omitempty"`
+	ValidUntil     *time.Time `json:"validUntil,omitempty"`
+}
+
+// siteInBusiness loads the {siteId} site and checks it belongs to the active business vertical
+func siteInBusiness(w http.ResponseWriter, r *http.Request) (*models.Site, bool) {
+	businessContext := middleware.GetUserBusinessContext(r)
+	if businessContext == nil {
+		http.Error(w, "business context not found", http.StatusBadRequest)
+		return nil, false
+	}
+
+	businessID, ok := businessContext["business_id"].(uuid.UUID)
+	if !ok {
+		http.Error(w, "invalid business context", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	siteID, err := uuid.Parse(mux.Vars(r)["siteId"])
+	if err != nil {
+		http.Error(w, "invalid site ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	var site models.Site
+	if err := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ?", siteID, businessID).First(&site).Error; err != nil {
+		http.Error(w, "site not found or does not belong to this business", http.StatusNotFound)
+		return nil, false
+	}
+	return &site, true
+}
+
+// GetSiteRoles lists the active role assignments scoped to a site
+func GetSiteRoles(w http.ResponseWriter, r *http.Request) {
+	site, ok := siteInBusiness(w, r)
+	if !ok {
+		return
+	}
+
+	var assignments []models.UserBusinessRole
+	if err := middleware.ScopedDB(r).
+		Preload("User").
+		Preload("BusinessRole").
+		Where("site_id = ? AND is_active = ?", site.ID, true).
+		Order("assigned_at DESC").
+		Find(&assignments).Error; err != nil {
+		http.Error(w, "failed to fetch site roles", http.StatusInternalServerError)
+		return
+	}
+
+	result := make([]SiteRoleAssignment, 0, len(assignments))
+	for _, a := range assignments {
+		result = append(result, toSiteRoleAssignment(a))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": result,
+	})
+}
+
+// AssignSiteRole grants a business role to a user for a single site.
+// The role must belong to the site's business vertical and be assignable by the caller.
+func AssignSiteRole(w http.ResponseWriter, r *http.Request) {
+	site, ok := siteInBusiness(w, r)
+	if !ok {
+		return
+	}
+
+	var req AssignSiteRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.ValidUntil != nil && (!req.ValidUntil.After(time.Now()) || (req.ValidFrom != nil && !req.ValidUntil.After(*req.ValidFrom))) {
+		http.Error(w, "validUntil must be in the future and after validFrom", http.StatusBadRequest)
+		return
+	}
+
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "user claims not found", http.StatusUnauthorized)
+		return
+	}
+	currentUserID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		http.Error(w, "invalid user ID in claims", http.StatusInternalServerError)
+		return
+	}
+
+	var businessRole models.BusinessRole
+	if err := middleware.ScopedDB(r).
+		Where("id = ? AND business_vertical_id = ? AND is_active = ?", req.BusinessRoleID, site.BusinessVerticalID, true).
+		First(&businessRole).Error; err != nil {
+		http.Error(w, "business role not found in this business", http.StatusNotFound)
+		return
+	}
+
+	var currentUser models.User
+	if err := middleware.ScopedDB(r).
+		Preload("RoleModel").
+		Preload("UserBusinessRoles.BusinessRole").
+		First(&currentUser, "id = ?", currentUserID).Error; err != nil {
+		http.Error(w, "current user not found", http.StatusNotFound)
+		return
+	}
+	if !currentUser.CanAssignRole(businessRole.Level) {
+		http.Error(w, "you don't have permission to assign this role", http.StatusForbidden)
+		return
+	}
+
+	var targetUser models.User
+	if err := middleware.ScopedDB(r).First(&targetUser, "id = ?", req.UserID).Error; err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+
+	// One role per user per site: re-assigning replaces the role and its validity window
+	var assignment models.UserBusinessRole
+	err = middleware.ScopedDB(r).Where("user_id = ? AND site_id = ? AND is_active = ?", req.UserID, site.ID, true).First(&assignment).Error
+	if err != nil {
+		assignment = models.UserBusinessRole{
+			ID:     uuid.New(),
+			UserID: req.UserID,
+			SiteID: &site.ID,
+		}
+	}
+
+	var replacing *uuid.UUID
+	if err == nil {
+		replacing = &assignment.ID
+	}
+	if err := middleware.EnforceRoleAssignmentSoD(r, req.UserID, site.BusinessVerticalID, businessRole.ID, replacing); err != nil {
+		middleware.WriteSoDViolation(w, err)
+		return
+	}
+
+	assignment.BusinessRoleID = businessRole.ID
+	assignment.IsActive = true
+	assignment.AssignedAt = time.Now()
+	assignment.AssignedBy = &currentUserID
+	assignment.ValidFrom = req.ValidFrom
+	assignment.ValidUntil = req.ValidUntil
+
+	if err := middleware.ScopedDB(r).Omit("User", "BusinessRole", "Site").Save(&assignment).Error; err != nil {
+		http.Error(w, "failed to assign site role", http.StatusInternalServerError)
+		return
+	}
+
+	middleware.InvalidateUserCache(req.UserID.String())
+
+	assignment.User = targetUser
+	assignment.BusinessRole = businessRole
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(toSiteRoleAssignment(assignment))
+}
+
+// RevokeSiteRole deactivates a site-scoped role assignment
+func RevokeSiteRole(w http.ResponseWriter, r *http.Request) {
+	site, ok := siteInBusiness(w, r)
+	if !ok {
+		return
+	}
+
+	var assignment models.UserBusinessRole
+	if err := middleware.ScopedDB(r).
+		Where("id = ? AND site_id = ?", mux.Vars(r)["assignmentId"], site.ID).
+		First(&assignment).Error; err != nil {
+		http.Error(w, "site role assignment not found", http.StatusNotFound)
+		return
+	}
+
+	if err := middleware.ScopedDB(r).Model(&assignment).Update("is_active", false).Error; err != nil {
+		http.Error(w, "failed to revoke site role", http.StatusInternalServerError)
+		return
+	}
+
+	middleware.InvalidateUserCache(assignment.UserID.String())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func toSiteRoleAssignment(a models.UserBusinessRole) SiteRoleAssignment {
+	out := SiteRoleAssignment{
+		ID:               a.ID,
+		UserID:           a.UserID,
+		UserName:         a.User.Name,
+		BusinessRoleID:   a.BusinessRoleID,
+		BusinessRoleName: a.BusinessRole.DisplayName,
+		AssignedAt:       a.AssignedAt,
+		AssignedBy:       a.AssignedBy,
+		ValidFrom:        a.ValidFrom,
+		ValidUntil:       a.ValidUntil,
+	}
+	if a.SiteID != nil {
+		out.SiteID = *a.SiteID
+	}
+	return out
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// materialVarianceTolerancePercent is how far issues may exceed theoretical consumption
+// before the material is flagged, unless the request gives its own ?tolerance=
+const materialVarianceTolerancePercent = 2.0
+
+// UpsertBOQMaterialNorm sets how much of a material one unit of a BOQ item consumes,
+// replacing the item's existing norm for that material
+// POST /api/v1/projects/{id}/boq-items/{itemId}/material-norms
+func (h *ProjectPhase1Handler) UpsertBOQMaterialNorm(w http.ResponseWriter, r *http.Request) {
+	project, claims, err := h.requireProjectScope(r)
+	if err != nil {
+		h.writeErr(w, err)
+		return
+	}
+	var item models.BOQItem
+	if err := middleware.WithDataScope(r, h.db).First(&item, "id = ? AND project_id = ?", mux.Vars(r)["itemId"], project.ID).Error; err != nil {
+		http.Error(w, "BOQ item not found", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		MaterialCode    string  `json:"material_code"`
+		UOM             string  `json:"uom"`
+		QuantityPerUnit float64 `json:"quantity_per_unit"`
+		WastagePercent  float64 `json:"wastage_percent"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	req.MaterialCode = strings.TrimSpace(req.MaterialCode)
+	req.UOM = strings.TrimSpace(req.UOM)
+	if req.MaterialCode == "" || req.UOM == "" || req.QuantityPerUnit <= 0 {
+		http.Error(w, "material_code, uom and a positive quantity_per_unit are required", http.StatusBadRequest)
+		return
+	}
+	if req.WastagePercent < 0 || req.WastagePercent > 100 {
+		http.Error(w, "wastage_percent must be between 0 and 100", http.StatusBadRequest)
+		return
+	}
+
+	var norm models.BOQMaterialNorm
+	err = middleware.WithDataScope(r, h.db).Where("boq_item_id = ? AND material_code = ? AND deleted_at IS NULL", item.ID, req.MaterialCode).First(&norm).Error
+	status := http.StatusOK
+	if err != nil {
+		norm = models.BOQMaterialNorm{BOQItemID: item.ID, MaterialCode: req.MaterialCode, CreatedBy: claims.UserID}
+		status = http.StatusCreated
+	} else {
+		norm.UpdatedBy = claims.UserID
+	}
+	norm.UOM = req.UOM
+	norm.QuantityPerUnit = req.QuantityPerUnit
+	norm.WastagePercent = req.WastagePercent
+	if err := middleware.WithDataScope(r, h.db).Save(&norm).Error; err != nil {
+		http.Error(w, "failed to save material norm", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, status, map[string]interface{}{"material_norm": norm})
+}
+
+// ListBOQMaterialNorms lists the material norms of a project's BOQ items. ?boq_item_id=
+// narrows them to one item.
+// GET /api/v1/projects/{id}/material-norms
+func (h *ProjectPhase1Handler) ListBOQMaterialNorms(w http.ResponseWriter, r *http.Request) {
+	project, _, err := h.requireProjectScope(r)
+	if err != nil {
+		h.writeErr(w, err)
+		return
+	}
+
+	query := middleware.WithDataScope(r, h.db).Preload("BOQItem").
+		Joins("JOIN boq_items ON boq_items.id = boq_material_norms.boq_item_id").
+		Where("boq_items.project_id = ? AND boq_material_norms.deleted_at IS NULL", project.ID)
+	if boqItemID := r.URL.Query().Get("boq_item_id"); boqItemID != "" {
+		query = query.Where("boq_material_norms.boq_item_id = ?", boqItemID)
+	}
+	var norms []models.BOQMaterialNorm
+	if err := query.Order("boq_items.code, boq_material_norms.material_code").Find(&norms).Error; err != nil {
+		http.Error(w, "failed to list material norms", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{"material_norms": norms, "count": len(norms)})
+}
+
+// DeleteBOQMaterialNorm removes a material norm from a project's BOQ item
+// DELETE /api/v1/projects/{id}/material-norms/{normId}
+func (h *ProjectPhase1Handler) DeleteBOQMaterialNorm(w http.ResponseWriter, r *http.Request) {
+	project, _, err := h.requireProjectScope(r)
+	if err != nil {
+		h.writeErr(w, err)
+		return
+	}
+
+	result := middleware.WithDataScope(r, h.db).Model(&models.BOQMaterialNorm{}).
+		Where("id = ? AND deleted_at IS NULL AND boq_item_id IN (SELECT id FROM boq_items WHERE project_id = ?)", mux.Vars(r)["normId"], project.ID).
+		Update("deleted_at", time.Now())
+	if result.Error != nil {
+		http.Error(w, "failed to delete material norm", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "material norm not found", http.StatusNotFound)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{"message": "material norm deleted"})
+}
+
+// GetMaterialReconciliation compares the materials issued to a project from site stock
+// with the theoretical consumption of the work executed, taken from approved MB entries
+// and the BOQ items' material norms, and flags materials consumed in excess for audit.
+// ?task_id= narrows it to one task's issues and measurements, ?from= and ?to=
+// (YYYY-MM-DD) to a period, and ?tolerance= sets the excess allowed, in percent.
+// GET /api/v1/projects/{id}/material-reconciliation
+func (h *ProjectPhase1Handler) GetMaterialReconciliation(w http.ResponseWriter, r *http.Request) {
+	project, _, err := h.requireProjectScope(r)
+	if err != nil {
+		h.writeErr(w, err)
+		return
+	}
+
+	q := r.URL.Query()
+	tolerance := materialVarianceTolerancePercent
+	if v := q.Get("tolerance"); v != "" {
+		tolerance, err = strconv.ParseFloat(v, 64)
+		if err != nil || tolerance < 0 {
+			http.Error(w, "tolerance must be a non-negative percentage", http.StatusBadRequest)
+			return
+		}
+	}
+	var taskID *uuid.UUID
+	if v := q.Get("task_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "invalid task_id", http.StatusBadRequest)
+			return
+		}
+		taskID = &id
+	}
+	from, to, err := vendorReportPeriod(r)
+	if err != nil {
+		h.writeErr(w, err)
+		return
+	}
+
+	// What the executed work should have consumed
+	measured := middleware.WithDataScope(r, h.db).Model(&models.MBEntry{}).Select("boq_item_id, SUM(measured_qty) AS quantity").
+		Where("project_id = ? AND status = ?", project.ID, models.MBStatusApproved)
+	// What was issued to it, net of anything returned
+	issuedQuery := middleware.WithDataScope(r, h.db).Model(&models.StockLedgerEntry{}).
+		Select(`material_code, uom, SUM(quantity_out - quantity_in) AS quantity,
+			SUM(CASE WHEN quantity_out > 0 THEN value ELSE -value END) AS value`).
+		Where("project_id = ?", project.ID)
+	if taskID != nil {
+		measured = measured.Where("task_id = ?", *taskID)
+		issuedQuery = issuedQuery.Where("task_id = ?", *taskID)
+	}
+	if from != nil {
+		measured = measured.Where("measurement_date >= ?", *from)
+		issuedQuery = issuedQuery.Where("entry_date >= ?", *from)
+	}
+	if to != nil {
+		measured = measured.Where("measurement_date < ?", to.AddDate(0, 0, 1))
+		issuedQuery = issuedQuery.Where("entry_date <= ?", *to)
+	}
+
+	var executedRows []struct {
+		BOQItemID uuid.UUID
+		Quantity  float64
+	}
+	if err := measured.Group("boq_item_id").Scan(&executedRows).Error; err != nil {
+		http.Error(w, "failed to sum executed quantities", http.StatusInternalServerError)
+		return
+	}
+	executed := make(map[uuid.UUID]float64, len(executedRows))
+	for _, row := range executedRows {
+		executed[row.BOQItemID] = row.Quantity
+	}
+	var issued []models.MaterialIssued
+	if err := issuedQuery.Group("material_code, uom").Scan(&issued).Error; err != nil {
+		http.Error(w, "failed to sum material issues", http.StatusInternalServerError)
+		return
+	}
+	var items []models.BOQItem
+	if err := middleware.WithDataScope(r, h.db).Where("project_id = ?", project.ID).Find(&items).Error; err != nil {
+		http.Error(w, "failed to load BOQ items", http.StatusInternalServerError)
+		return
+	}
+	var norms []models.BOQMaterialNorm
+	if err := middleware.WithDataScope(r, h.db).Joins("JOIN boq_items ON boq_items.id = boq_material_norms.boq_item_id").
+		Where("boq_items.project_id = ? AND boq_material_norms.deleted_at IS NULL", project.ID).
+		Find(&norms).Error; err != nil {
+		http.Error(w, "failed to load material norms", http.StatusInternalServerError)
+		return
+	}
+
+	lines := models.ReconcileMaterials(issued, items, executed, norms, tolerance)
+	excessCount, excessValue := 0, 0.0
+	for _, l := range lines {
+		if l.Excess {
+			excessCount++
+			excessValue += l.ExcessValue
+		}
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"project_id":        project.ID,
+		"task_id":           taskID,
+		"from":              from,
+		"to":                to,
+		"tolerance_percent": tolerance,
+		"lines":             lines,
+		"excess_count":      excessCount,
+		"excess_value":      math.Round(excessValue*100) / 100,
+	})
+}
@@ -0,0 +1,418 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// mbEntryRequest is the body of MB entry create and update requests
+type mbEntryRequest struct {
+	BOQItemID       uuid.UUID           `json:"boq_item_id"`
+	TaskID          *uuid.UUID          `json:"task_id"`
+	EntryNumber     string              `json:"entry_number"`
+	MeasurementDate *time.Time          `json:"measurement_date"`
+	Description     string              `json:"description"`
+	Dimensions      models.MBDimensions `json:"dimensions"`
+	MeasuredQty     float64             `json:"measured_qty"`
+	Rate            float64             `json:"rate"`
+	Amount          float64             `json:"amount"`
+	LocationRef     string              `json:"location_ref"`
+	Remarks         string              `json:"remarks"`
+}
+
+// applyMBEntryRequest sets an MB entry's measurement from a request, checking its BOQ
+// item and task belong to the project and calculating its quantity and amount
+func (h *ProjectPhase1Handler) applyMBEntryRequest(projectID uuid.UUID, entry *models.MBEntry, req mbEntryRequest) error {
+	boqItemID := req.BOQItemID
+	if boqItemID == uuid.Nil {
+		boqItemID = entry.BOQItemID
+	}
+	var boq models.BOQItem
+	if err := h.db.First(&boq, "id = ? AND project_id = ?", boqItemID, projectID).Error; err != nil {
+		return apiError{status: http.StatusBadRequest, message: "BOQ item not found"}
+	}
+	if req.TaskID != nil {
+		var count int64
+		h.db.Model(&models.Tasks{}).Where("id = ? AND project_id = ? AND deleted_at IS NULL", *req.TaskID, projectID).Count(&count)
+		if count == 0 {
+			return apiError{status: http.StatusBadRequest, message: "task not found in this project"}
+		}
+	}
+
+	quantity := req.MeasuredQty
+	if len(req.Dimensions) > 0 {
+		quantity = req.Dimensions.Calculate()
+	}
+	if quantity <= 0 {
+		return apiError{status: http.StatusBadRequest, message: "measured_qty, or the total of the dimensions, must be positive"}
+	}
+
+	rate := req.Rate
+	if rate == 0 {
+		rate = boq.UnitRate
+	}
+	amount := req.Amount
+	if amount == 0 {
+		amount = math.Round(quantity*rate*100) / 100
+	}
+
+	entry.BOQItemID = boq.ID
+	entry.TaskID = req.TaskID
+	if number := strings.TrimSpace(req.EntryNumber); number != "" {
+		entry.EntryNumber = number
+	}
+	if req.MeasurementDate != nil {
+		entry.MeasurementDate = req.MeasurementDate.UTC()
+	}
+	entry.Description = strings.TrimSpace(req.Description)
+	entry.Dimensions = req.Dimensions
+	if entry.Dimensions == nil {
+		entry.Dimensions = models.MBDimensions{}
+	}
+	entry.MeasuredQty = quantity
+	entry.Rate = rate
+	entry.Amount = amount
+	entry.LocationRef = strings.TrimSpace(req.LocationRef)
+	entry.Remarks = req.Remarks
+	return nil
+}
+
+// loadMBEntry loads the MB entry in the request from the project
+func (h *ProjectPhase1Handler) loadMBEntry(r *http.Request, projectID uuid.UUID) (*models.MBEntry, error) {
+	entryID, err := uuid.Parse(mux.Vars(r)["entryId"])
+	if err != nil {
+		return nil, apiError{status: http.StatusBadRequest, message: "invalid entryId"}
+	}
+	var entry models.MBEntry
+	if err := middleware.WithDataScope(r, h.db).First(&entry, "id = ? AND project_id = ?", entryID, projectID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError{status: http.StatusNotFound, message: "MB entry not found"}
+		}
+		return nil, apiError{status: http.StatusInternalServerError, message: "failed to load MB entry"}
+	}
+	return &entry, nil
+}
+
+// GetMBEntry returns an MB entry with its BOQ item and task
+// GET /api/v1/projects/{id}/mb-entries/{entryId}
+func (h *ProjectPhase1Handler) GetMBEntry(w http.ResponseWriter, r *http.Request) {
+	project, _, err := h.requireProjectScope(r)
+	if err != nil {
+		h.writeErr(w, err)
+		return
+	}
+	entry, err := h.loadMBEntry(r, project.ID)
+	if err != nil {
+		h.writeErr(w, err)
+		return
+	}
+	if err := middleware.WithDataScope(r, h.db).Preload("BOQItem").Preload("Task").First(entry, "id = ?", entry.ID).Error; err != nil {
+		http.Error(w, "failed to load MB entry", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{"mb_entry": entry})
+}
+
+// UpdateMBEntry corrects a prepared or rejected MB entry's measurement. A rejected entry
+// goes back to prepared for checking again.
+// PUT /api/v1/projects/{id}/mb-entries/{entryId}
+func (h *ProjectPhase1Handler) UpdateMBEntry(w http.ResponseWriter, r *http.Request) {
+	project, _, err := h.requireProjectScope(r)
+	if err != nil {
+		h.writeErr(w, err)
+		return
+	}
+	entry, err := h.loadMBEntry(r, project.ID)
+	if err != nil {
+		h.writeErr(w, err)
+		return
+	}
+	if !models.MBEditable(entry.Status) {
+		http.Error(w, fmt.Sprintf("a %s MB entry cannot be edited", entry.Status), http.StatusConflict)
+		return
+	}
+
+	var req mbEntryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := h.applyMBEntryRequest(project.ID, entry, req); err != nil {
+		h.writeErr(w, err)
+		return
+	}
+	entry.Status = models.MBStatusPrepared
+	entry.CheckedBy, entry.CheckedAt = "", nil
+	entry.ReviewRemarks = ""
+
+	result := middleware.WithDataScope(r, h.db).Model(entry).Where("status IN ?", []string{models.MBStatusPrepared, models.MBStatusRejected}).
+		Select("boq_item_id", "task_id", "entry_number", "measurement_date", "description", "dimensions",
+			"measured_qty", "rate", "amount", "location_ref", "remarks", "status", "checked_by", "checked_at", "review_remarks").
+		Updates(entry)
+	if result.Error != nil {
+		http.Error(w, "failed to update MB entry", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "MB entry changed status; reload and try again", http.StatusConflict)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{"mb_entry": entry})
+}
+
+// CheckMBEntry records that a second engineer checked a prepared MB entry. Whoever
+// recorded the entry cannot check it.
+// POST /api/v1/projects/{id}/mb-entries/{entryId}/check
+func (h *ProjectPhase1Handler) CheckMBEntry(w http.ResponseWriter, r *http.Request) {
+	h.transitionMBEntry(w, r, models.MBStatusChecked)
+}
+
+// ApproveMBEntry approves a checked MB entry, adding its quantity to its BOQ item's
+// executed quantity and making it billable. Whoever checked the entry cannot approve it.
+// POST /api/v1/projects/{id}/mb-entries/{entryId}/approve
+func (h *ProjectPhase1Handler) ApproveMBEntry(w http.ResponseWriter, r *http.Request) {
+	h.transitionMBEntry(w, r, models.MBStatusApproved)
+}
+
+// RejectMBEntry sends a prepared or checked MB entry back for correction, with remarks
+// POST /api/v1/projects/{id}/mb-entries/{entryId}/reject
+func (h *ProjectPhase1Handler) RejectMBEntry(w http.ResponseWriter, r *http.Request) {
+	h.transitionMBEntry(w, r, models.MBStatusRejected)
+}
+
+func (h *ProjectPhase1Handler) transitionMBEntry(w http.ResponseWriter, r *http.Request, next string) {
+	project, claims, err := h.requireProjectScope(r)
+	if err != nil {
+		h.writeErr(w, err)
+		return
+	}
+	entry, err := h.loadMBEntry(r, project.ID)
+	if err != nil {
+		h.writeErr(w, err)
+		return
+	}
+
+	var req struct {
+		Remarks string `json:"remarks"`
+	}
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+	req.Remarks = strings.TrimSpace(req.Remarks)
+
+	if !models.CanTransitionMB(entry.Status, next) {
+		http.Error(w, fmt.Sprintf("invalid status transition %s -> %s", entry.Status, next), http.StatusConflict)
+		return
+	}
+	switch {
+	case next == models.MBStatusChecked && entry.RecordedBy == claims.UserID:
+		http.Error(w, "an MB entry must be checked by someone other than who recorded it", http.StatusForbidden)
+		return
+	case next == models.MBStatusApproved && entry.CheckedBy == claims.UserID:
+		http.Error(w, "an MB entry must be approved by someone other than who checked it", http.StatusForbidden)
+		return
+	case next == models.MBStatusRejected && req.Remarks == "":
+		http.Error(w, "remarks are required to reject an MB entry", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now().UTC()
+	updates := map[string]interface{}{"status": next}
+	if req.Remarks != "" || next == models.MBStatusRejected {
+		updates["review_remarks"] = req.Remarks
+	}
+	switch next {
+	case models.MBStatusChecked:
+		updates["checked_by"] = claims.UserID
+		updates["checked_at"] = now
+	case models.MBStatusApproved:
+		updates["approved_by"] = claims.UserID
+		updates["approved_at"] = now
+	}
+
+	err = middleware.WithDataScope(r, h.db).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.MBEntry{}).Where("id = ? AND status = ?", entry.ID, entry.Status).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return apiError{status: http.StatusConflict, message: "MB entry changed status; reload and try again"}
+		}
+		if next != models.MBStatusApproved {
+			return nil
+		}
+		return tx.Model(&models.BOQItem{}).Where("id = ?", entry.BOQItemID).Updates(map[string]interface{}{
+			"executed_quantity": gorm.Expr("executed_quantity + ?", entry.MeasuredQty),
+			"updated_by":        claims.UserID,
+		}).Error
+	})
+	if err != nil {
+		if _, ok := err.(apiError); ok {
+			h.writeErr(w, err)
+			return
+		}
+		http.Error(w, "failed to update MB entry status", http.StatusInternalServerError)
+		return
+	}
+
+	if err := middleware.WithDataScope(r, h.db).First(entry, "id = ?", entry.ID).Error; err != nil {
+		http.Error(w, "failed to load MB entry", http.StatusInternalServerError)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{"mb_entry": entry})
+}
+
+// GenerateRABill raises a draft running account bill from the project's approved MB
+// entries not billed yet, one line per entry, optionally narrowed to a measurement period
+// and BOQ items. Retention is a percentage of the gross. The response abstracts the bill
+// by BOQ item: quantities billed before it, on it and up to date.
+// POST /api/v1/projects/{id}/ra-bills/generate
+func (h *ProjectPhase1Handler) GenerateRABill(w http.ResponseWriter, r *http.Request) {
+	project, claims, err := h.requireProjectScope(r)
+	if err != nil {
+		h.writeErr(w, err)
+		return
+	}
+
+	var req struct {
+		BillNumber       string      `json:"bill_number"`
+		PeriodStart      *time.Time  `json:"period_start"`
+		PeriodEnd        *time.Time  `json:"period_end"`
+		BOQItemIDs       []uuid.UUID `json:"boq_item_ids"`
+		DeductionsAmount float64     `json:"deductions_amount"`
+		RetentionPercent float64     `json:"retention_percent"`
+		TaxAmount        float64     `json:"tax_amount"`
+		Notes            string      `json:"notes"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	req.BillNumber = strings.TrimSpace(req.BillNumber)
+	if req.BillNumber == "" {
+		http.Error(w, "bill_number is required", http.StatusBadRequest)
+		return
+	}
+	if req.RetentionPercent < 0 || req.RetentionPercent > 100 {
+		http.Error(w, "retention_percent must be between 0 and 100", http.StatusBadRequest)
+		return
+	}
+	if req.PeriodStart != nil && req.PeriodEnd != nil && req.PeriodEnd.Before(*req.PeriodStart) {
+		http.Error(w, "period_end must not be before period_start", http.StatusBadRequest)
+		return
+	}
+
+	var count int64
+	middleware.WithDataScope(r, h.db).Model(&models.RABill{}).Where("project_id = ? AND bill_number = ?", project.ID, req.BillNumber).Count(&count)
+	if count > 0 {
+		http.Error(w, "bill_number is already used on this project", http.StatusConflict)
+		return
+	}
+
+	var bill models.RABill
+	var abstract []models.RABillAbstractLine
+	err = middleware.WithDataScope(r, h.db).Transaction(func(tx *gorm.DB) error {
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("project_id = ? AND status = ? AND ra_bill_id IS NULL", project.ID, models.MBStatusApproved)
+		if req.PeriodStart != nil {
+			query = query.Where("measurement_date >= ?", *req.PeriodStart)
+		}
+		if req.PeriodEnd != nil {
+			query = query.Where("measurement_date <= ?", *req.PeriodEnd)
+		}
+		if len(req.BOQItemIDs) > 0 {
+			query = query.Where("boq_item_id IN ?", req.BOQItemIDs)
+		}
+		var entries []models.MBEntry
+		if err := query.Order("measurement_date, entry_number").Find(&entries).Error; err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return apiError{status: http.StatusConflict, message: "no approved, unbilled MB entries to bill"}
+		}
+
+		itemIDs := make([]uuid.UUID, 0, len(entries))
+		entryIDs := make([]uuid.UUID, 0, len(entries))
+		for _, entry := range entries {
+			itemIDs = append(itemIDs, entry.BOQItemID)
+			entryIDs = append(entryIDs, entry.ID)
+		}
+		var boqItems []models.BOQItem
+		if err := tx.Where("id IN ?", itemIDs).Find(&boqItems).Error; err != nil {
+			return err
+		}
+		items := make(map[uuid.UUID]models.BOQItem, len(boqItems))
+		for _, item := range boqItems {
+			items[item.ID] = item
+		}
+
+		var lines []models.RABillLine
+		var gross float64
+		lines, abstract, gross = models.NewRABillLines(entries, items)
+		retention := math.Round(gross*req.RetentionPercent) / 100
+		bill = models.RABill{
+			ProjectID:        project.ID,
+			BillNumber:       req.BillNumber,
+			PeriodStart:      req.PeriodStart,
+			PeriodEnd:        req.PeriodEnd,
+			GrossAmount:      gross,
+			DeductionsAmount: req.DeductionsAmount,
+			RetentionAmount:  retention,
+			TaxAmount:        req.TaxAmount,
+			NetAmount:        gross - req.DeductionsAmount - retention + req.TaxAmount,
+			Status:           "draft",
+			Notes:            req.Notes,
+			CreatedBy:        claims.UserID,
+		}
+		if err := tx.Create(&bill).Error; err != nil {
+			return err
+		}
+		for i := range lines {
+			lines[i].RABillID = bill.ID
+		}
+		if err := tx.Create(&lines).Error; err != nil {
+			return err
+		}
+		bill.Lines = lines
+
+		if err := tx.Model(&models.MBEntry{}).Where("id IN ?", entryIDs).Update("ra_bill_id", bill.ID).Error; err != nil {
+			return err
+		}
+		for _, line := range abstract {
+			if err := tx.Model(&models.BOQItem{}).Where("id = ?", line.BOQItemID).Updates(map[string]interface{}{
+				"billed_quantity": gorm.Expr("billed_quantity + ?", line.ThisBillQuantity),
+				"updated_by":      claims.UserID,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if _, ok := err.(apiError); ok {
+			h.writeErr(w, err)
+			return
+		}
+		http.Error(w, "failed to generate RA bill", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusCreated, map[string]interface{}{"ra_bill": bill, "abstract": abstract})
+}
@@ -6,7 +6,6 @@ import (
 	"strconv"
 
 	"gorm.io/gorm/clause"
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 
@@ -25,7 +24,7 @@ func GetAllMNRReports(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	service := models.NewReportService(config.DB, models.Mnr{})
+	service := models.NewReportService(middleware.ScopedDB(r), models.Mnr{})
 	response, err := service.GetReport(params)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -42,7 +41,7 @@ func CreateMNRReport(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r)
 	item.AttendanceTakenBy = user.Name
 	item.AttendancePhone = user.Phone
-	config.DB.Create(&item)
+	middleware.ScopedDB(r).Create(&item)
 	json.NewEncoder(w).Encode(item)
 }
 
@@ -50,7 +49,7 @@ func GetMNRReport(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id, _ := strconv.Atoi(params["id"])
 	var item models.Mnr
-	config.DB.First(&item, id)
+	middleware.ScopedDB(r).First(&item, id)
 	json.NewEncoder(w).Encode(item)
 }
 
@@ -58,16 +57,16 @@ func UpdateMNRReport(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id, _ := strconv.Atoi(params["id"])
 	var item models.Mnr
-	config.DB.First(&item, id)
+	middleware.ScopedDB(r).First(&item, id)
 	json.NewDecoder(r.Body).Decode(&item)
-	config.DB.Save(&item)
+	middleware.ScopedDB(r).Save(&item)
 	json.NewEncoder(w).Encode(item)
 }
 
 func DeleteMNRReport(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id, _ := strconv.Atoi(params["id"])
-	config.DB.Delete(&models.Mnr{}, id)
+	middleware.ScopedDB(r).Delete(&models.Mnr{}, id)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -84,7 +83,7 @@ func BatchMnrs(w http.ResponseWriter, r *http.Request) {
 		batch[i].AttendancePhone = user.Phone
 	}
 
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Clauses(clause.OnConflict{
 			Columns:   []clause.Column{{Name: "id"}},
 			DoNothing: true,
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"p9e.in/ugcl/middleware"
+)
+
+// GetMyPermissions returns the current user's resolved permissions in the active
+// business context, each attributed to the role, site scope or policy it comes from.
+// Clients use it to hide actions the user cannot perform.
+func GetMyPermissions(w http.ResponseWriter, r *http.Request) {
+	authService := middleware.NewAuthService()
+	userCtx, err := authService.LoadUserContext(r)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(authService.ResolveEffectivePermissions(r, userCtx))
+}
@@ -7,7 +7,6 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 )
@@ -18,7 +17,7 @@ type NotificationAdminHandler struct{}
 // GetAllNotificationRules retrieves all notification rules
 func (h *NotificationAdminHandler) GetAllNotificationRules(w http.ResponseWriter, r *http.Request) {
 	// Parse query parameters for filtering
-	query := config.DB.Model(&models.NotificationRule{})
+	query := middleware.ScopedDB(r).Model(&models.NotificationRule{})
 
 	// Filter by workflow ID
 	if workflowID := r.URL.Query().Get("workflow_id"); workflowID != "" {
@@ -56,7 +55,7 @@ func (h *NotificationAdminHandler) GetNotificationRule(w http.ResponseWriter, r
 	}
 
 	var rule models.NotificationRule
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Preload("Recipients").
 		First(&rule, "id = ?", ruleID).Error; err != nil {
 		http.Error(w, "Notification rule not found", http.StatusNotFound)
@@ -145,7 +144,7 @@ func (h *NotificationAdminHandler) CreateNotificationRule(w http.ResponseWriter,
 	}
 
 	// Start transaction
-	tx := config.DB.Begin()
+	tx := middleware.ScopedDB(r).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -176,7 +175,7 @@ func (h *NotificationAdminHandler) CreateNotificationRule(w http.ResponseWriter,
 	}
 
 	// Load rule with recipients
-	config.DB.Preload("Recipients").First(&rule, rule.ID)
+	middleware.ScopedDB(r).Preload("Recipients").First(&rule, rule.ID)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -197,7 +196,7 @@ func (h *NotificationAdminHandler) UpdateNotificationRule(w http.ResponseWriter,
 
 	// Get existing rule
 	var rule models.NotificationRule
-	if err := config.DB.First(&rule, "id = ?", ruleID).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&rule, "id = ?", ruleID).Error; err != nil {
 		http.Error(w, "Notification rule not found", http.StatusNotFound)
 		return
 	}
@@ -244,7 +243,7 @@ func (h *NotificationAdminHandler) UpdateNotificationRule(w http.ResponseWriter,
 	}
 
 	// Start transaction
-	tx := config.DB.Begin()
+	tx := middleware.ScopedDB(r).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -283,7 +282,7 @@ func (h *NotificationAdminHandler) UpdateNotificationRule(w http.ResponseWriter,
 	}
 
 	// Load rule with recipients
-	config.DB.Preload("Recipients").First(&rule, rule.ID)
+	middleware.ScopedDB(r).Preload("Recipients").First(&rule, rule.ID)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -303,13 +302,13 @@ func (h *NotificationAdminHandler) DeleteNotificationRule(w http.ResponseWriter,
 
 	// Check if rule exists
 	var rule models.NotificationRule
-	if err := config.DB.First(&rule, "id = ?", ruleID).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&rule, "id = ?", ruleID).Error; err != nil {
 		http.Error(w, "Notification rule not found", http.StatusNotFound)
 		return
 	}
 
 	// Start transaction
-	tx := config.DB.Begin()
+	tx := middleware.ScopedDB(r).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -354,7 +353,7 @@ func (h *NotificationAdminHandler) ToggleNotificationRule(w http.ResponseWriter,
 
 	// Get existing rule
 	var rule models.NotificationRule
-	if err := config.DB.First(&rule, "id = ?", ruleID).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&rule, "id = ?", ruleID).Error; err != nil {
 		http.Error(w, "Notification rule not found", http.StatusNotFound)
 		return
 	}
@@ -363,7 +362,7 @@ func (h *NotificationAdminHandler) ToggleNotificationRule(w http.ResponseWriter,
 	rule.IsActive = !rule.IsActive
 	rule.UpdatedAt = time.Now()
 
-	if err := config.DB.Save(&rule).Error; err != nil {
+	if err := middleware.ScopedDB(r).Save(&rule).Error; err != nil {
 		http.Error(w, "Failed to update notification rule", http.StatusInternalServerError)
 		return
 	}
@@ -387,15 +386,15 @@ func (h *NotificationAdminHandler) GetNotificationStats(w http.ResponseWriter, r
 	var stats Stats
 
 	// Count rules
-	config.DB.Model(&models.NotificationRule{}).Count(&stats.TotalRules)
-	config.DB.Model(&models.NotificationRule{}).Where("is_active = ?", true).Count(&stats.ActiveRules)
+	middleware.ScopedDB(r).Model(&models.NotificationRule{}).Count(&stats.TotalRules)
+	middleware.ScopedDB(r).Model(&models.NotificationRule{}).Where("is_active = ?", true).Count(&stats.ActiveRules)
 
 	// Count notifications
-	config.DB.Model(&models.Notification{}).Count(&stats.TotalNotifications)
-	config.DB.Model(&models.Notification{}).Where("status = ?", models.NotificationStatusPending).Count(&stats.PendingCount)
-	config.DB.Model(&models.Notification{}).Where("status = ?", models.NotificationStatusSent).Count(&stats.SentCount)
-	config.DB.Model(&models.Notification{}).Where("read_at IS NOT NULL").Count(&stats.ReadCount)
-	config.DB.Model(&models.Notification{}).Where("status = ?", models.NotificationStatusFailed).Count(&stats.FailedCount)
+	middleware.ScopedDB(r).Model(&models.Notification{}).Count(&stats.TotalNotifications)
+	middleware.ScopedDB(r).Model(&models.Notification{}).Where("status = ?", models.NotificationStatusPending).Count(&stats.PendingCount)
+	middleware.ScopedDB(r).Model(&models.Notification{}).Where("status = ?", models.NotificationStatusSent).Count(&stats.SentCount)
+	middleware.ScopedDB(r).Model(&models.Notification{}).Where("read_at IS NOT NULL").Count(&stats.ReadCount)
+	middleware.ScopedDB(r).Model(&models.Notification{}).Where("status = ?", models.NotificationStatusFailed).Count(&stats.FailedCount)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNotificationDateRange(t *testing.T) {
+	ist := attendanceLocation(nil)
+
+	from, to, err := notificationDateRange(httptest.NewRequest("GET", "/notifications?from=2026-10-01&to=2026-10-15", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !from.Equal(time.Date(2026, 10, 1, 0, 0, 0, 0, ist)) || !to.Equal(time.Date(2026, 10, 16, 0, 0, 0, 0, ist)) {
+		t.Errorf("date range = %v to %v, want 1 Oct to the end of 15 Oct IST", from, to)
+	}
+
+	_, to, err = notificationDateRange(httptest.NewRequest("GET", "/notifications?to=2026-10-15T09:30:00Z", nil))
+	if err != nil || !to.Equal(time.Date(2026, 10, 15, 9, 30, 0, 0, time.UTC)) {
+		t.Errorf("to = %v, %v; want the exact time", to, err)
+	}
+
+	if from, to, err := notificationDateRange(httptest.NewRequest("GET", "/notifications", nil)); err != nil || from != nil || to != nil {
+		t.Errorf("no range = %v, %v, %v", from, to, err)
+	}
+	for _, query := range []string{"from=yesterday", "from=2026-10-15&to=2026-10-14"} {
+		if _, _, err := notificationDateRange(httptest.NewRequest("GET", "/notifications?"+query, nil)); err == nil {
+			t.Errorf("%s accepted", query)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/jobrunner"
+)
+
+// smsRetryLease is how long a claimed SMS retry stays invisible to other workers
+const smsRetryLease = 2 * time.Minute
+
+// recordDeliveryAttempt adds one try at a push or text to the delivery audit
+func recordDeliveryAttempt(db *gorm.DB, attempt models.NotificationDeliveryAttempt) {
+	attempt.DurationMS = time.Since(attempt.StartedAt).Milliseconds()
+	if err := db.Create(&attempt).Error; err != nil {
+		log.Printf("⚠️ delivery audit: failed to record %s attempt %d of %s: %v", attempt.Channel, attempt.Attempt, attempt.DeliveryID, err)
+	}
+}
+
+// SMSRetryWorker resends texts whose last attempt failed transiently once their backoff
+// has passed. Mobile pushes are retried by the PushDeliveryWorker.
+type SMSRetryWorker struct {
+	db  *gorm.DB
+	sms *SMSService
+	*jobrunner.Runner
+}
+
+// NewSMSRetryWorker creates the SMS retry worker
+func NewSMSRetryWorker() *SMSRetryWorker {
+	w := &SMSRetryWorker{db: config.DB, sms: NewSMSService()}
+	w.Runner = jobrunner.New("SMS retry worker", func() {
+		if n, err := w.RetryDue(time.Now()); err != nil {
+			log.Printf("Error retrying SMS: %v", err)
+		} else if n > 0 {
+			log.Printf("SMS retry worker: resent %d texts", n)
+		}
+	})
+	return w
+}
+
+// RetryDue resends every queued text whose retry is due and returns how many the
+// provider accepted. Nothing is claimed while SMS is not configured.
+func (w *SMSRetryWorker) RetryDue(now time.Time) (int, error) {
+	if smsGateway() == nil {
+		return 0, nil
+	}
+
+	var due []models.SMSMessage
+	err := w.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.SMSQueued, now).
+			Order("next_attempt_at ASC").
+			Limit(100).
+			Find(&due).Error; err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return nil
+		}
+		ids := make([]uuid.UUID, len(due))
+		for i := range due {
+			ids[i] = due[i].ID
+		}
+		return tx.Model(&models.SMSMessage{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(smsRetryLease)).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for i := range due {
+		if err := w.sms.resend(&due[i]); err != nil {
+			continue
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// ListNotificationDeliveries lists queued pushes or texts, newest first
+// (?channel=mobile_push|sms&status=failed&user_id=&notification_id=&limit=)
+// GET /api/v1/admin/notification-deliveries
+func (h *NotificationAdminHandler) ListNotificationDeliveries(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit := 50
+	if l, err := strconv.Atoi(q.Get("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+
+	var query *gorm.DB
+	var deliveries interface{}
+	switch models.NotificationChannel(q.Get("channel")) {
+	case models.NotificationChannelMobilePush, "":
+		query = middleware.ScopedDB(r).Model(&models.PushDelivery{})
+		if notificationID, err := uuid.Parse(q.Get("notification_id")); err == nil {
+			query = query.Where("notification_id = ?", notificationID)
+		}
+		deliveries = &[]models.PushDelivery{}
+	case models.NotificationChannelSMS:
+		query = middleware.ScopedDB(r).Model(&models.SMSMessage{})
+		if notificationID, err := uuid.Parse(q.Get("notification_id")); err == nil {
+			query = query.Where("reference_id = ?", notificationID)
+		}
+		deliveries = &[]models.SMSMessage{}
+	default:
+		http.Error(w, "channel must be mobile_push or sms", http.StatusBadRequest)
+		return
+	}
+	if status := q.Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if userID := q.Get("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	if err := query.Order("created_at DESC").Limit(limit).Find(deliveries).Error; err != nil {
+		http.Error(w, "Failed to fetch deliveries", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"deliveries": deliveries,
+	})
+}
+
+// ListDeliveryAttempts lists delivery attempts, newest first
+// (?channel=&status=failed&transient=true&user_id=&notification_id=&limit=)
+// GET /api/v1/admin/notification-deliveries/attempts
+func (h *NotificationAdminHandler) ListDeliveryAttempts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := middleware.ScopedDB(r).Model(&models.NotificationDeliveryAttempt{})
+	if channel := q.Get("channel"); channel != "" {
+		query = query.Where("channel = ?", channel)
+	}
+	if status := q.Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if transient, err := strconv.ParseBool(q.Get("transient")); err == nil {
+		query = query.Where("transient = ?", transient)
+	}
+	if userID := q.Get("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if notificationID, err := uuid.Parse(q.Get("notification_id")); err == nil {
+		query = query.Where("notification_id = ?", notificationID)
+	}
+	limit := 100
+	if l, err := strconv.Atoi(q.Get("limit")); err == nil && l > 0 && l <= 500 {
+		limit = l
+	}
+
+	var attempts []models.NotificationDeliveryAttempt
+	if err := query.Order("created_at DESC").Limit(limit).Find(&attempts).Error; err != nil {
+		http.Error(w, "Failed to fetch delivery attempts", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"attempts": attempts,
+		"count":    len(attempts),
+	})
+}
+
+// GetNotificationDelivery returns a queued push or text with its attempts
+// GET /api/v1/admin/notification-deliveries/{channel}/{id}
+func (h *NotificationAdminHandler) GetNotificationDelivery(w http.ResponseWriter, r *http.Request) {
+	channel, deliveryID, delivery, ok := loadNotificationDelivery(w, r)
+	if !ok {
+		return
+	}
+
+	var attempts []models.NotificationDeliveryAttempt
+	if err := middleware.ScopedDB(r).Where("channel = ? AND delivery_id = ?", channel, deliveryID).
+		Order("created_at ASC").
+		Find(&attempts).Error; err != nil {
+		http.Error(w, "Failed to fetch delivery attempts", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"delivery": delivery,
+		"attempts": attempts,
+	})
+}
+
+// RequeueNotificationDelivery puts a failed push or text back in the queue for a fresh
+// set of attempts, e.g. after a provider outage or a credentials fix
+// POST /api/v1/admin/notification-deliveries/{channel}/{id}/requeue
+func (h *NotificationAdminHandler) RequeueNotificationDelivery(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	channel, deliveryID, delivery, ok := loadNotificationDelivery(w, r)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	audit := models.NotificationDeliveryAttempt{
+		Channel:    channel,
+		DeliveryID: deliveryID,
+		Status:     models.DeliveryAttemptRequeued,
+		StartedAt:  now,
+	}
+	if adminID, err := uuid.Parse(claims.UserID); err == nil {
+		audit.RequeuedBy = &adminID
+	}
+
+	var updates map[string]interface{}
+	switch d := delivery.(type) {
+	case *models.PushDelivery:
+		if err := d.Requeue(now); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		audit.NotificationID, audit.UserID = &d.NotificationID, d.UserID
+		updates = map[string]interface{}{
+			"status": d.Status, "attempts": d.Attempts, "next_attempt_at": d.NextAttemptAt, "last_error": d.LastError,
+		}
+	case *models.SMSMessage:
+		if err := d.Requeue(now); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		audit.UserID = d.UserID
+		updates = map[string]interface{}{
+			"status": d.Status, "attempts": d.Attempts, "next_attempt_at": d.NextAttemptAt, "error": d.Error,
+			"sent_at": nil, "delivered_at": nil,
+		}
+	}
+
+	if err := middleware.ScopedDB(r).Model(delivery).Updates(updates).Error; err != nil {
+		http.Error(w, "Failed to requeue delivery", http.StatusInternalServerError)
+		return
+	}
+	recordDeliveryAttempt(middleware.ScopedDB(r), audit)
+	if channel == models.NotificationChannelMobilePush {
+		select {
+		case pushKick <- struct{}{}:
+		default:
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"delivery": delivery,
+	})
+}
+
+// loadNotificationDelivery loads the {channel} delivery {id}: a *models.PushDelivery or
+// a *models.SMSMessage
+func loadNotificationDelivery(w http.ResponseWriter, r *http.Request) (models.NotificationChannel, uuid.UUID, interface{}, bool) {
+	vars := mux.Vars(r)
+	deliveryID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid delivery ID", http.StatusBadRequest)
+		return "", uuid.Nil, nil, false
+	}
+
+	channel := models.NotificationChannel(vars["channel"])
+	var delivery interface{}
+	switch channel {
+	case models.NotificationChannelMobilePush:
+		delivery = &models.PushDelivery{}
+	case models.NotificationChannelSMS:
+		delivery = &models.SMSMessage{}
+	default:
+		http.Error(w, "channel must be mobile_push or sms", http.StatusBadRequest)
+		return "", uuid.Nil, nil, false
+	}
+	if err := middleware.ScopedDB(r).First(delivery, "id = ?", deliveryID).Error; err != nil {
+		http.Error(w, "Delivery not found", http.StatusNotFound)
+		return "", uuid.Nil, nil, false
+	}
+	return channel, deliveryID, delivery, true
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/jobrunner"
+)
+
+// holdForDigest marks the notification for the recipient's digest when they batch its
+// type, and reports whether it was held
+func (ns *NotificationService) holdForDigest(notification *models.Notification) bool {
+	var prefs models.NotificationPreference
+	if err := ns.db.Where("user_id = ?", notification.UserID).First(&prefs).Error; err != nil {
+		return false
+	}
+	frequency := prefs.DigestFrequencyFor(notification.Type, notification.Priority)
+	if frequency == "" {
+		return false
+	}
+	if err := ns.db.Model(&models.Notification{}).
+		Where("id = ?", notification.ID).
+		Update("digest_frequency", frequency).Error; err != nil {
+		log.Printf("⚠️ digest: failed to hold notification %s, pushing it instead: %v", notification.ID, err)
+		return false
+	}
+	notification.DigestFrequency = frequency
+	return true
+}
+
+// DigestScheduler sends each user's hourly and daily digests once they fall due: one
+// summary notification, pushed to their phones, in place of the notifications it batches.
+// Notifications the user read in the meantime are left out of the summary.
+type DigestScheduler struct {
+	db *gorm.DB
+	ns *NotificationService
+	*jobrunner.Runner
+}
+
+// NewDigestScheduler creates the digest scheduler
+func NewDigestScheduler() *DigestScheduler {
+	s := &DigestScheduler{db: config.DB, ns: NewNotificationService()}
+	s.Runner = jobrunner.New("Notification digest scheduler", func() {
+		if n, err := s.SendDue(time.Now()); err != nil {
+			log.Printf("Error sending notification digests: %v", err)
+		} else if n > 0 {
+			log.Printf("Notification digest scheduler: sent %d digests", n)
+		}
+	})
+	return s
+}
+
+// SendDue composes every digest that is due and returns how many summaries were sent.
+// Digest times are read in the business timezone.
+func (s *DigestScheduler) SendDue(now time.Time) (int, error) {
+	var batches []struct {
+		UserID          string
+		DigestFrequency string
+		Oldest          time.Time
+	}
+	if err := s.db.Model(&models.Notification{}).
+		Select("user_id, digest_frequency, MIN(created_at) AS oldest").
+		Where("digest_frequency <> '' AND digest_id IS NULL").
+		Group("user_id, digest_frequency").
+		Scan(&batches).Error; err != nil {
+		return 0, err
+	}
+
+	loc := attendanceLocation(nil)
+	sent := 0
+	for _, batch := range batches {
+		var prefs models.NotificationPreference
+		s.db.Where("user_id = ?", batch.UserID).First(&prefs)
+		if now.Before(prefs.DigestDueAt(batch.DigestFrequency, batch.Oldest.In(loc))) {
+			continue
+		}
+		summary, err := s.compose(batch.UserID, batch.DigestFrequency, now)
+		if err != nil {
+			log.Printf("❌ Failed to compose %s digest for %s: %v", batch.DigestFrequency, batch.UserID, err)
+			continue
+		}
+		if summary != nil {
+			s.ns.QueueMobilePush(summary, summary.Body, nil)
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// compose batches the user's held notifications of the frequency into a digest and
+// returns its summary notification, or nil when the user had read them all
+func (s *DigestScheduler) compose(userID, frequency string, now time.Time) (*models.Notification, error) {
+	var summary *models.Notification
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var items []models.Notification
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("user_id = ? AND digest_frequency = ? AND digest_id IS NULL AND created_at <= ?", userID, frequency, now).
+			Order("created_at DESC").
+			Find(&items).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+
+		ids := make([]uuid.UUID, len(items))
+		var unread []models.Notification
+		for i, n := range items {
+			ids[i] = n.ID
+			if n.ReadAt == nil && n.ArchivedAt == nil {
+				unread = append(unread, n)
+			}
+		}
+		digest := models.NotificationDigest{
+			ID:          uuid.New(),
+			UserID:      userID,
+			Frequency:   frequency,
+			PeriodStart: items[len(items)-1].CreatedAt,
+			PeriodEnd:   now,
+			ItemCount:   len(items),
+			UnreadCount: len(unread),
+		}
+
+		if len(unread) > 0 {
+			title, body := models.DigestSummary(unread, frequency)
+			sentAt := now
+			summary = &models.Notification{
+				UserID:    userID,
+				Type:      models.NotificationTypeDigest,
+				Priority:  models.NotificationPriorityNormal,
+				Title:     title,
+				Body:      body,
+				ActionURL: "/notifications?unread=true",
+				Metadata:  models.JSONMap{"digest_id": digest.ID.String(), "frequency": frequency, "count": len(unread)},
+				Status:    models.NotificationStatusSent,
+				Channel:   models.NotificationChannelInApp,
+				SentAt:    &sentAt,
+			}
+			if err := tx.Create(summary).Error; err != nil {
+				return err
+			}
+			digest.NotificationID = &summary.ID
+		}
+		if err := tx.Create(&digest).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Notification{}).Where("id IN ?", ids).Update("digest_id", digest.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
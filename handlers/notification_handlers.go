@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -25,7 +27,9 @@ func getNotificationService() *NotificationService {
 	return notificationService
 }
 
-// GetNotifications retrieves notifications for the current user
+// GetNotifications retrieves notifications for the current user, newest first. Filters:
+// ?unread=true, ?read=, ?type=, ?priority=, ?status=, ?form_code=, ?from= and ?to= (RFC3339
+// or YYYY-MM-DD, to inclusive of the day) and ?archived=true for the archived ones.
 // GET /api/v1/notifications
 func (h *NotificationHandler) GetNotifications(w http.ResponseWriter, r *http.Request) {
 	claims := middleware.GetClaims(r)
@@ -49,6 +53,23 @@ func (h *NotificationHandler) GetNotifications(w http.ResponseWriter, r *http.Re
 	if read := r.URL.Query().Get("read"); read != "" {
 		filters["read"] = read == "true"
 	}
+	if r.URL.Query().Get("unread") == "true" {
+		filters["read"] = false
+	}
+	if r.URL.Query().Get("archived") == "true" {
+		filters["archived"] = true
+	}
+	from, to, err := notificationDateRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if from != nil {
+		filters["from"] = *from
+	}
+	if to != nil {
+		filters["to"] = *to
+	}
 	if formCode := r.URL.Query().Get("form_code"); formCode != "" {
 		filters["form_code"] = formCode
 	}
@@ -113,7 +134,7 @@ func (h *NotificationHandler) GetNotification(w http.ResponseWriter, r *http.Req
 	}
 
 	var notification models.Notification
-	if err := getNotificationService().db.First(&notification, id).Error; err != nil {
+	if err := middleware.WithDataScope(r, getNotificationService().db).First(&notification, id).Error; err != nil {
 		http.Error(w, "notification not found", http.StatusNotFound)
 		return
 	}
@@ -149,7 +170,7 @@ func (h *NotificationHandler) MarkNotificationAsRead(w http.ResponseWriter, r *h
 	}
 
 	var notification models.Notification
-	if err := getNotificationService().db.First(&notification, id).Error; err != nil {
+	if err := middleware.WithDataScope(r, getNotificationService().db).First(&notification, id).Error; err != nil {
 		http.Error(w, "notification not found", http.StatusNotFound)
 		return
 	}
@@ -162,7 +183,7 @@ func (h *NotificationHandler) MarkNotificationAsRead(w http.ResponseWriter, r *h
 
 	// Mark as read
 	notification.MarkAsRead()
-	if err := getNotificationService().db.Save(&notification).Error; err != nil {
+	if err := middleware.WithDataScope(r, getNotificationService().db).Save(&notification).Error; err != nil {
 		log.Printf("❌ Error marking notification as read: %v", err)
 		http.Error(w, "failed to mark as read", http.StatusInternalServerError)
 		return
@@ -175,7 +196,9 @@ func (h *NotificationHandler) MarkNotificationAsRead(w http.ResponseWriter, r *h
 	})
 }
 
-// MarkAllNotificationsAsRead marks all notifications as read for the current user
+// MarkAllNotificationsAsRead marks all notifications as read for the current user.
+// ?type= limits it to one type and ?before= (RFC3339) to those created earlier, so a
+// client can clear what it has shown without touching newer arrivals.
 // PATCH /api/v1/notifications/read-all
 func (h *NotificationHandler) MarkAllNotificationsAsRead(w http.ResponseWriter, r *http.Request) {
 	claims := middleware.GetClaims(r)
@@ -184,12 +207,19 @@ func (h *NotificationHandler) MarkAllNotificationsAsRead(w http.ResponseWriter,
 		return
 	}
 
-	result := getNotificationService().db.Model(&models.Notification{}).
-		Where("user_id = ? AND read_at IS NULL", claims.UserID).
-		Update("read_at", "NOW()")
+	var before *time.Time
+	if raw := r.URL.Query().Get("before"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			http.Error(w, "before must be an RFC3339 time", http.StatusBadRequest)
+			return
+		}
+		before = &parsed
+	}
 
-	if result.Error != nil {
-		log.Printf("❌ Error marking all notifications as read: %v", result.Error)
+	count, err := getNotificationService().MarkAllAsRead(claims.UserID, r.URL.Query().Get("type"), before)
+	if err != nil {
+		log.Printf("❌ Error marking all notifications as read: %v", err)
 		http.Error(w, "failed to mark all as read", http.StatusInternalServerError)
 		return
 	}
@@ -197,7 +227,61 @@ func (h *NotificationHandler) MarkAllNotificationsAsRead(w http.ResponseWriter,
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"message": "all notifications marked as read",
-		"count":   result.RowsAffected,
+		"count":   count,
+	})
+}
+
+// ArchiveNotification moves a notification out of the list, marking it read
+// PATCH /api/v1/notifications/:id/archive
+func (h *NotificationHandler) ArchiveNotification(w http.ResponseWriter, r *http.Request) {
+	h.setArchived(w, r, true)
+}
+
+// UnarchiveNotification returns an archived notification to the list
+// PATCH /api/v1/notifications/:id/unarchive
+func (h *NotificationHandler) UnarchiveNotification(w http.ResponseWriter, r *http.Request) {
+	h.setArchived(w, r, false)
+}
+
+func (h *NotificationHandler) setArchived(w http.ResponseWriter, r *http.Request, archived bool) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid notification ID", http.StatusBadRequest)
+		return
+	}
+
+	var notification models.Notification
+	if err := middleware.WithDataScope(r, getNotificationService().db).First(&notification, id).Error; err != nil {
+		http.Error(w, "notification not found", http.StatusNotFound)
+		return
+	}
+
+	// Verify ownership
+	if notification.UserID != claims.UserID {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	if archived {
+		notification.Archive()
+	} else {
+		notification.Unarchive()
+	}
+	if err := middleware.WithDataScope(r, getNotificationService().db).Model(&notification).Select("read_at", "archived_at", "status").Updates(&notification).Error; err != nil {
+		log.Printf("❌ Error archiving notification: %v", err)
+		http.Error(w, "failed to update notification", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"notification": notification.ToDTO(),
 	})
 }
 
@@ -220,7 +304,7 @@ func (h *NotificationHandler) DeleteNotification(w http.ResponseWriter, r *http.
 	}
 
 	var notification models.Notification
-	if err := getNotificationService().db.First(&notification, id).Error; err != nil {
+	if err := middleware.WithDataScope(r, getNotificationService().db).First(&notification, id).Error; err != nil {
 		http.Error(w, "notification not found", http.StatusNotFound)
 		return
 	}
@@ -231,7 +315,7 @@ func (h *NotificationHandler) DeleteNotification(w http.ResponseWriter, r *http.
 		return
 	}
 
-	if err := getNotificationService().db.Delete(&notification).Error; err != nil {
+	if err := middleware.WithDataScope(r, getNotificationService().db).Delete(&notification).Error; err != nil {
 		log.Printf("❌ Error deleting notification: %v", err)
 		http.Error(w, "failed to delete notification", http.StatusInternalServerError)
 		return
@@ -262,6 +346,62 @@ func (h *NotificationHandler) GetUnreadCount(w http.ResponseWriter, r *http.Requ
 	})
 }
 
+// GetNotificationBadge returns the unread counts for the app's badges: the total, the
+// urgent (high and critical) ones and per type. It is cheap enough to poll.
+// GET /api/v1/notifications/badge
+func (h *NotificationHandler) GetNotificationBadge(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	badge, err := getNotificationService().GetBadgeCounts(claims.UserID)
+	if err != nil {
+		log.Printf("❌ Error getting notification badge: %v", err)
+		http.Error(w, "failed to get notification badge", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(badge)
+}
+
+// notificationDateRange reads ?from= and ?to= as RFC3339 times or dates in the business
+// timezone. A date given as to includes the whole day.
+func notificationDateRange(r *http.Request) (from, to *time.Time, err error) {
+	parse := func(key string) (*time.Time, bool, error) {
+		raw := strings.TrimSpace(r.URL.Query().Get(key))
+		if raw == "" {
+			return nil, false, nil
+		}
+		if ts, err := time.Parse(time.RFC3339, raw); err == nil {
+			return &ts, false, nil
+		}
+		day, err := time.ParseInLocation("2006-01-02", raw, attendanceLocation(nil))
+		if err != nil {
+			return nil, false, fmt.Errorf("%s must be an RFC3339 time or a YYYY-MM-DD date", key)
+		}
+		return &day, true, nil
+	}
+
+	if from, _, err = parse("from"); err != nil {
+		return nil, nil, err
+	}
+	var wholeDay bool
+	if to, wholeDay, err = parse("to"); err != nil {
+		return nil, nil, err
+	}
+	if to != nil && wholeDay {
+		end := to.AddDate(0, 0, 1)
+		to = &end
+	}
+	if from != nil && to != nil && !from.Before(*to) {
+		return nil, nil, fmt.Errorf("from must be before to")
+	}
+	return from, to, nil
+}
+
 // GetNotificationPreferences returns user's notification preferences
 // GET /api/v1/notifications/preferences
 func (h *NotificationHandler) GetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
@@ -272,18 +412,19 @@ func (h *NotificationHandler) GetNotificationPreferences(w http.ResponseWriter,
 	}
 
 	var prefs models.NotificationPreference
-	if err := getNotificationService().db.Where("user_id = ?", claims.UserID).First(&prefs).Error; err != nil {
+	if err := middleware.WithDataScope(r, getNotificationService().db).Where("user_id = ?", claims.UserID).First(&prefs).Error; err != nil {
 		// Create default preferences if not found
 		prefs = models.NotificationPreference{
-			UserID:           claims.UserID,
-			EnableInApp:      true,
-			EnableEmail:      true,
-			EnableSMS:        false,
-			EnableWebPush:    true,
-			EnableMobilePush: true,
-			DisabledTypes:    []string{},
+			UserID:                  claims.UserID,
+			EnableInApp:             true,
+			EnableEmail:             true,
+			EnableSMS:               false,
+			EnableWebPush:           true,
+			EnableMobilePush:        true,
+			DisabledTypes:           []string{},
+			MobilePushDisabledTypes: []string{},
 		}
-		getNotificationService().db.Create(&prefs)
+		middleware.WithDataScope(r, getNotificationService().db).Create(&prefs)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -302,27 +443,46 @@ func (h *NotificationHandler) UpdateNotificationPreferences(w http.ResponseWrite
 	}
 
 	var req struct {
-		EnableInApp       *bool    `json:"enable_in_app"`
-		EnableEmail       *bool    `json:"enable_email"`
-		EnableSMS         *bool    `json:"enable_sms"`
-		EnableWebPush     *bool    `json:"enable_web_push"`
-		EnableMobilePush  *bool    `json:"enable_mobile_push"`
-		DisabledTypes     []string `json:"disabled_types"`
-		QuietHoursEnabled *bool    `json:"quiet_hours_enabled"`
-		QuietHoursStart   *string  `json:"quiet_hours_start"`
-		QuietHoursEnd     *string  `json:"quiet_hours_end"`
-		DigestEnabled     *bool    `json:"digest_enabled"`
-		DigestFrequency   *string  `json:"digest_frequency"`
+		EnableInApp       *bool             `json:"enable_in_app"`
+		EnableEmail       *bool             `json:"enable_email"`
+		EnableSMS         *bool             `json:"enable_sms"`
+		EnableWebPush     *bool             `json:"enable_web_push"`
+		EnableMobilePush  *bool             `json:"enable_mobile_push"`
+		DisabledTypes     []string          `json:"disabled_types"`
+		PushDisabledTypes []string          `json:"mobile_push_disabled_types"`
+		QuietHoursEnabled *bool             `json:"quiet_hours_enabled"`
+		QuietHoursStart   *string           `json:"quiet_hours_start"`
+		QuietHoursEnd     *string           `json:"quiet_hours_end"`
+		DigestEnabled     *bool             `json:"digest_enabled"`
+		DigestFrequency   *string           `json:"digest_frequency"`
+		DigestTypes       map[string]string `json:"digest_types"`
+		DigestTime        *string           `json:"digest_time"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid request body", http.StatusBadRequest)
 		return
 	}
+	if req.DigestFrequency != nil && *req.DigestFrequency != "" && !models.ValidDigestFrequency(*req.DigestFrequency) {
+		http.Error(w, "digest_frequency must be hourly or daily", http.StatusBadRequest)
+		return
+	}
+	for notifType, frequency := range req.DigestTypes {
+		if frequency != "" && !models.ValidDigestFrequency(frequency) {
+			http.Error(w, fmt.Sprintf("digest_types.%s must be hourly, daily or empty", notifType), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.DigestTime != nil && *req.DigestTime != "" {
+		if _, err := time.Parse("15:04", *req.DigestTime); err != nil {
+			http.Error(w, "digest_time must be HH:MM", http.StatusBadRequest)
+			return
+		}
+	}
 
 	// Get or create preferences
 	var prefs models.NotificationPreference
-	if err := getNotificationService().db.Where("user_id = ?", claims.UserID).First(&prefs).Error; err != nil {
+	if err := middleware.WithDataScope(r, getNotificationService().db).Where("user_id = ?", claims.UserID).First(&prefs).Error; err != nil {
 		prefs = models.NotificationPreference{UserID: claims.UserID}
 	}
 
@@ -345,6 +505,9 @@ func (h *NotificationHandler) UpdateNotificationPreferences(w http.ResponseWrite
 	if req.DisabledTypes != nil {
 		prefs.DisabledTypes = req.DisabledTypes
 	}
+	if req.PushDisabledTypes != nil {
+		prefs.MobilePushDisabledTypes = req.PushDisabledTypes
+	}
 	if req.QuietHoursEnabled != nil {
 		prefs.QuietHoursEnabled = *req.QuietHoursEnabled
 	}
@@ -360,9 +523,18 @@ func (h *NotificationHandler) UpdateNotificationPreferences(w http.ResponseWrite
 	if req.DigestFrequency != nil {
 		prefs.DigestFrequency = *req.DigestFrequency
 	}
+	if req.DigestTypes != nil {
+		prefs.DigestTypes = models.JSONMap{}
+		for notifType, frequency := range req.DigestTypes {
+			prefs.DigestTypes[notifType] = frequency
+		}
+	}
+	if req.DigestTime != nil {
+		prefs.DigestTime = *req.DigestTime
+	}
 
 	// Save
-	if err := getNotificationService().db.Save(&prefs).Error; err != nil {
+	if err := middleware.WithDataScope(r, getNotificationService().db).Save(&prefs).Error; err != nil {
 		log.Printf("❌ Error saving preferences: %v", err)
 		http.Error(w, "failed to save preferences", http.StatusInternalServerError)
 		return
@@ -189,22 +189,19 @@ func (ns *NotificationService) CountActiveMobilePushTokens(userID string) (int64
 }
 
 func (ns *NotificationService) isMobilePushEnabled(userID string, notifType models.NotificationType) bool {
-	var prefs models.NotificationPreference
-	if err := ns.db.Where("user_id = ?", userID).First(&prefs).Error; err != nil {
+	// Safety alerts cannot be muted
+	if notifType == models.NotificationTypeEmergency {
 		return true
 	}
 
-	if !prefs.EnableMobilePush {
-		return false
-	}
-
-	for _, disabledType := range prefs.DisabledTypes {
-		if disabledType == string(notifType) {
-			return false
-		}
+	var prefs models.NotificationPreference
+	if err := ns.db.Where("user_id = ?", userID).First(&prefs).Error; err != nil {
+		return true
 	}
 
-	return true
+	// Pushes sent straight away cannot wait out quiet hours
+	_, ok := prefs.MobilePushAt(notifType, models.NotificationPriorityCritical, time.Now())
+	return ok
 }
 
 func (ns *NotificationService) markTokenInactive(token string) {
@@ -291,10 +288,7 @@ func (ns *NotificationService) SendMobilePushToUser(
 			continue
 		}
 
-		errText := strings.ToLower(r.Error.Error())
-		if strings.Contains(errText, "registration-token-not-registered") ||
-			strings.Contains(errText, "invalid registration token") ||
-			strings.Contains(errText, "invalid-argument") {
+		if isInvalidPushToken(r.Error) {
 			ns.markTokenInactive(tokens[i])
 		}
 	}
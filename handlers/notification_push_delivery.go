@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/jobrunner"
+)
+
+// pushDeliveryLease is how long a claimed push stays invisible to other workers
+const pushDeliveryLease = 2 * time.Minute
+
+// pushKick wakes the delivery worker when pushes are queued, so they go out without
+// waiting for its next tick
+var pushKick = make(chan struct{}, 1)
+
+// isInvalidPushToken reports whether FCM rejected a device token for good: the app was
+// uninstalled, the token expired or belongs to another project
+func isInvalidPushToken(err error) bool {
+	return messaging.IsUnregistered(err) || messaging.IsInvalidArgument(err) || messaging.IsSenderIDMismatch(err)
+}
+
+// QueueMobilePush queues a stored notification to be pushed to the recipient's phones by
+// the delivery worker. body is the push text, which may be shorter than the
+// notification's. The type, notification id and action URL are added to data.
+// Notifications the recipient batches into a digest are held for it instead.
+func (ns *NotificationService) QueueMobilePush(notification *models.Notification, body string, data map[string]string) {
+	if ns.holdForDigest(notification) {
+		return
+	}
+
+	payload := models.JSONMap{
+		"type":            string(notification.Type),
+		"notification_id": notification.ID.String(),
+		"action_url":      notification.ActionURL,
+	}
+	for k, v := range data {
+		payload[k] = v
+	}
+	priority := notification.Priority
+	if priority == "" {
+		priority = models.NotificationPriorityNormal
+	}
+
+	delivery := models.PushDelivery{
+		NotificationID: notification.ID,
+		UserID:         notification.UserID,
+		Type:           notification.Type,
+		Priority:       priority,
+		Title:          notification.Title,
+		Body:           body,
+		Data:           payload,
+		Status:         models.PushDeliveryPending,
+		MaxAttempts:    models.DefaultPushMaxAttempts,
+		NextAttemptAt:  time.Now(),
+	}
+	if err := ns.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&delivery).Error; err != nil {
+		log.Printf("⚠️ mobile push: failed to queue notification %s: %v", notification.ID, err)
+		return
+	}
+	select {
+	case pushKick <- struct{}{}:
+	default:
+	}
+}
+
+// PushDeliveryWorker pushes queued notifications to the recipients' registered phones,
+// honouring their push preferences and quiet hours, retrying failed sends with backoff
+// and deactivating tokens FCM rejects.
+type PushDeliveryWorker struct {
+	db *gorm.DB
+	ns *NotificationService
+	*jobrunner.Runner
+}
+
+// NewPushDeliveryWorker creates the mobile push delivery worker
+func NewPushDeliveryWorker() *PushDeliveryWorker {
+	w := &PushDeliveryWorker{db: config.DB, ns: NewNotificationService()}
+	w.Runner = jobrunner.New("Push delivery worker", func() {
+		if n, err := w.DeliverDue(time.Now()); err != nil {
+			log.Printf("Error delivering mobile pushes: %v", err)
+		} else if n > 0 {
+			log.Printf("Push delivery worker: sent %d pushes", n)
+		}
+	}).WakeOn(pushKick)
+	return w
+}
+
+// DeliverDue sends every pending push whose next attempt is due and returns how many
+// reached at least one device. Nothing is claimed while FCM is not configured.
+func (w *PushDeliveryWorker) DeliverDue(now time.Time) (int, error) {
+	client, err := w.ns.getFirebaseMessagingClient()
+	if err != nil {
+		mobilePushUnavailableLog.Do(func() {
+			log.Printf("ℹ️ mobile push unavailable: %v", err)
+		})
+		return 0, nil
+	}
+
+	var due []models.PushDelivery
+	err = w.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.PushDeliveryPending, now).
+			Order("next_attempt_at ASC").
+			Limit(200).
+			Find(&due).Error; err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return nil
+		}
+		ids := make([]uuid.UUID, len(due))
+		for i := range due {
+			ids[i] = due[i].ID
+		}
+		return tx.Model(&models.PushDelivery{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(pushDeliveryLease)).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for i := range due {
+		updates := w.deliver(client, &due[i], now)
+		if due[i].Status == models.PushDeliverySent {
+			sent++
+		}
+		if err := w.db.Model(&due[i]).Updates(updates).Error; err != nil {
+			log.Printf("❌ Failed to update push delivery %s: %v", due[i].ID, err)
+		}
+	}
+	return sent, nil
+}
+
+// deliver sends one push and returns the delivery's changes
+func (w *PushDeliveryWorker) deliver(client *messaging.Client, d *models.PushDelivery, now time.Time) map[string]interface{} {
+	started := time.Now()
+	skip := func(reason string) map[string]interface{} {
+		d.Status = models.PushDeliverySkipped
+		w.recordAttempt(d, d.Attempts+1, models.DeliveryAttemptSkipped, false, reason, started)
+		return map[string]interface{}{"status": d.Status, "last_error": reason}
+	}
+
+	// quiet hours are kept in the business timezone
+	var prefs models.NotificationPreference
+	if err := w.db.Where("user_id = ?", d.UserID).First(&prefs).Error; err == nil {
+		at, ok := prefs.MobilePushAt(d.Type, d.Priority, now.In(attendanceLocation(nil)))
+		if !ok {
+			return skip("switched off in the user's preferences")
+		}
+		if at.After(now) {
+			return map[string]interface{}{"next_attempt_at": at}
+		}
+	}
+
+	var tokens []string
+	if err := w.db.Model(&models.MobilePushToken{}).
+		Where("user_id = ? AND is_active = ?", d.UserID, true).
+		Pluck("token", &tokens).Error; err != nil {
+		return w.retry(d, now, started, fmt.Errorf("failed to load device tokens: %w", err))
+	}
+	if len(tokens) == 0 {
+		return skip("no registered device")
+	}
+
+	android := &messaging.AndroidConfig{Priority: "normal"}
+	if d.Priority == models.NotificationPriorityHigh || d.Priority == models.NotificationPriorityCritical {
+		android.Priority = "high"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 12*time.Second)
+	defer cancel()
+	resp, err := client.SendEachForMulticast(ctx, &messaging.MulticastMessage{
+		Tokens:       tokens,
+		Notification: &messaging.Notification{Title: d.Title, Body: d.Body},
+		Data:         d.StringData(),
+		Android:      android,
+		APNS: &messaging.APNSConfig{
+			Payload: &messaging.APNSPayload{Aps: &messaging.Aps{Sound: "default", ContentAvailable: true}},
+		},
+	})
+	if err != nil {
+		return w.retry(d, now, started, err)
+	}
+
+	var lastErr error
+	invalid := 0
+	for i, r := range resp.Responses {
+		if r.Success || i >= len(tokens) {
+			continue
+		}
+		if isInvalidPushToken(r.Error) {
+			invalid++
+			w.ns.markTokenInactive(tokens[i])
+			continue
+		}
+		lastErr = r.Error
+	}
+	switch {
+	case resp.SuccessCount > 0:
+		sentAt := time.Now()
+		d.Status = models.PushDeliverySent
+		d.Attempts++
+		w.recordAttempt(d, d.Attempts, models.DeliveryAttemptSucceeded, false, "", started)
+		return map[string]interface{}{
+			"status": d.Status, "attempts": d.Attempts, "devices_sent": resp.SuccessCount, "sent_at": sentAt, "last_error": "",
+		}
+	case invalid == len(tokens):
+		return skip("every device token was rejected and has been deactivated")
+	case lastErr == nil:
+		lastErr = errors.New("no device accepted the push")
+	}
+	return w.retry(d, now, started, lastErr)
+}
+
+// isPermanentPushError reports whether FCM refused the push itself in a way retrying
+// cannot fix: a malformed message or credentials that do not match the project. These
+// need the configuration fixed and the push requeued.
+func isPermanentPushError(err error) bool {
+	return messaging.IsInvalidArgument(err) || messaging.IsSenderIDMismatch(err) ||
+		messaging.IsMismatchedCredential(err) || messaging.IsThirdPartyAuthError(err)
+}
+
+// retry counts a failed attempt and schedules the next one, or gives up after the last
+// or when the failure is permanent
+func (w *PushDeliveryWorker) retry(d *models.PushDelivery, now, started time.Time, err error) map[string]interface{} {
+	d.Attempts++
+	transient := !isPermanentPushError(err)
+	log.Printf("⚠️ mobile push %s to %s attempt %d failed: %v", d.ID, d.UserID, d.Attempts, err)
+	w.recordAttempt(d, d.Attempts, models.DeliveryAttemptFailed, transient, err.Error(), started)
+	updates := map[string]interface{}{"attempts": d.Attempts, "last_error": err.Error()}
+	if !transient || d.Attempts >= d.MaxAttempts {
+		d.Status = models.PushDeliveryFailed
+		updates["status"] = d.Status
+	} else {
+		updates["next_attempt_at"] = now.Add(models.PushRetryDelay(d.Attempts))
+	}
+	return updates
+}
+
+// recordAttempt adds one try at the push to the delivery audit
+func (w *PushDeliveryWorker) recordAttempt(d *models.PushDelivery, attempt int, status string, transient bool, reason string, started time.Time) {
+	notificationID := d.NotificationID
+	recordDeliveryAttempt(w.db, models.NotificationDeliveryAttempt{
+		Channel:        models.NotificationChannelMobilePush,
+		DeliveryID:     d.ID,
+		NotificationID: &notificationID,
+		UserID:         d.UserID,
+		Attempt:        attempt,
+		Status:         status,
+		Transient:      transient,
+		Error:          reason,
+		Provider:       "fcm",
+		StartedAt:      started,
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"time"
 
+	"p9e.in/ugcl/handlers/httputil"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 )
@@ -56,11 +57,8 @@ type mobilePushTokenDTO struct {
 	UserID       string  `json:"user_id,omitempty"`
 }
 
-func writeJSON(w http.ResponseWriter, status int, payload interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	_ = json.NewEncoder(w).Encode(payload)
-}
+// writeJSON writes payload as a JSON response with the status
+var writeJSON = httputil.WriteJSON
 
 func parseBoolQuery(value string) bool {
 	parsed, err := strconv.ParseBool(strings.TrimSpace(value))
@@ -7,6 +7,7 @@ import (
 	"log"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -45,6 +46,10 @@ type NotificationContext struct {
 	WorkflowID         string
 	TransitionID       string
 	BusinessVerticalID string
+	// Summary is the record's key fields (the form's summary_fields) as "Label: value"
+	// lines; it is appended to bodies whose template does not place it
+	Summary       string
+	SummaryFields []NotificationSummaryField
 }
 
 // ProcessTransitionNotifications processes notifications for a workflow transition
@@ -103,6 +108,18 @@ func (ns *NotificationService) processNotification(
 	if err != nil {
 		return fmt.Errorf("failed to render body template: %w", err)
 	}
+	pushBody := body
+	if context.Summary != "" && !strings.Contains(notifConfig.BodyTemplate, ".Summary") {
+		body = strings.TrimRight(body, "\n") + "\n\n" + context.Summary
+	}
+	var metadata models.JSONMap
+	if len(context.SummaryFields) > 0 {
+		metadata = models.JSONMap{
+			"form_title": context.FormTitle,
+			"state":      context.CurrentState,
+			"summary":    context.SummaryFields,
+		}
+	}
 
 	// Determine priority
 	priority := models.NotificationPriorityNormal
@@ -135,6 +152,7 @@ func (ns *NotificationService) processNotification(
 			TransitionID:       &transition.ID,
 			FormCode:           submission.FormCode,
 			BusinessVerticalID: &submission.BusinessVerticalID,
+			Metadata:           metadata,
 			Status:             models.NotificationStatusPending,
 			Channel:            models.NotificationChannel(channel),
 		}
@@ -151,11 +169,7 @@ func (ns *NotificationService) processNotification(
 		notification.MarkAsSent()
 		ns.db.Save(&notification)
 
-		pushData := map[string]string{
-			"type":            string(notification.Type),
-			"notification_id": notification.ID.String(),
-			"action_url":      notification.ActionURL,
-		}
+		pushData := map[string]string{}
 		if notification.ConversationID != nil {
 			pushData["conversation_id"] = notification.ConversationID.String()
 		}
@@ -163,13 +177,8 @@ func (ns *NotificationService) processNotification(
 			pushData["message_id"] = notification.MessageID.String()
 		}
 
-		ns.SendMobilePushToUser(
-			recipientID,
-			notification.Type,
-			title,
-			body,
-			pushData,
-		)
+		// Pushes stay short; the summary is in the notification they open
+		ns.QueueMobilePush(&notification, pushBody, pushData)
 	}
 
 	return nil
@@ -684,6 +693,8 @@ func (ns *NotificationService) buildNotificationContext(
 		}
 	}
 
+	summary := formRecordSummary(submission.Form, formData)
+
 	return NotificationContext{
 		FormTitle:          formTitle,
 		FormCode:           submission.FormCode,
@@ -702,6 +713,8 @@ func (ns *NotificationService) buildNotificationContext(
 		WorkflowID:         submission.WorkflowID.String(),
 		TransitionID:       transition.ID.String(),
 		BusinessVerticalID: submission.BusinessVerticalID.String(),
+		Summary:            renderSummary(summary),
+		SummaryFields:      summary,
 	}
 }
 
@@ -732,6 +745,18 @@ func (ns *NotificationService) GetNotificationsForUser(
 	if formCode, ok := filters["form_code"].(string); ok && formCode != "" {
 		query = query.Where("form_code = ?", formCode)
 	}
+	if from, ok := filters["from"].(time.Time); ok {
+		query = query.Where("created_at >= ?", from)
+	}
+	if to, ok := filters["to"].(time.Time); ok {
+		query = query.Where("created_at < ?", to)
+	}
+	// Archived notifications are listed only when asked for
+	if archived, ok := filters["archived"].(bool); ok && archived {
+		query = query.Where("archived_at IS NOT NULL")
+	} else {
+		query = query.Where("archived_at IS NULL")
+	}
 
 	// Pagination
 	if limit, ok := filters["limit"].(int); ok && limit > 0 {
@@ -756,9 +781,61 @@ func (ns *NotificationService) GetNotificationsForUser(
 func (ns *NotificationService) GetUnreadCount(userID string) (int64, error) {
 	var count int64
 	if err := ns.db.Model(&models.Notification{}).
-		Where("user_id = ? AND read_at IS NULL", userID).
+		Where("user_id = ? AND read_at IS NULL AND archived_at IS NULL", userID).
 		Count(&count).Error; err != nil {
 		return 0, err
 	}
 	return count, nil
 }
+
+// NotificationBadge holds the counts shown on the app's notification badges
+type NotificationBadge struct {
+	Unread int64                             `json:"unread"`
+	Urgent int64                             `json:"urgent"` // unread high and critical
+	ByType map[models.NotificationType]int64 `json:"by_type"`
+}
+
+// GetBadgeCounts counts a user's unread notifications in total, urgent and per type,
+// leaving out archived ones, in one query
+func (ns *NotificationService) GetBadgeCounts(userID string) (NotificationBadge, error) {
+	var rows []struct {
+		Type     models.NotificationType
+		Priority models.NotificationPriority
+		Count    int64
+	}
+	if err := ns.db.Model(&models.Notification{}).
+		Select("type, priority, COUNT(*) AS count").
+		Where("user_id = ? AND read_at IS NULL AND archived_at IS NULL", userID).
+		Group("type, priority").
+		Scan(&rows).Error; err != nil {
+		return NotificationBadge{}, err
+	}
+
+	badge := NotificationBadge{ByType: map[models.NotificationType]int64{}}
+	for _, row := range rows {
+		badge.Unread += row.Count
+		badge.ByType[row.Type] += row.Count
+		if row.Priority == models.NotificationPriorityHigh || row.Priority == models.NotificationPriorityCritical {
+			badge.Urgent += row.Count
+		}
+	}
+	return badge, nil
+}
+
+// MarkAllAsRead marks a user's unread notifications read, optionally only those of one
+// type or created before a time, and returns how many changed
+func (ns *NotificationService) MarkAllAsRead(userID, notifType string, before *time.Time) (int64, error) {
+	query := ns.db.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL AND archived_at IS NULL", userID)
+	if notifType != "" {
+		query = query.Where("type = ?", notifType)
+	}
+	if before != nil {
+		query = query.Where("created_at < ?", *before)
+	}
+	result := query.Updates(map[string]interface{}{
+		"read_at": time.Now(),
+		"status":  models.NotificationStatusRead,
+	})
+	return result.RowsAffected, result.Error
+}
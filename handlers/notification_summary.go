@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/formvalidation"
+)
+
+// maxSummaryValueLength caps one value of a record summary, so long text fields do not
+// swamp the notification
+const maxSummaryValueLength = 200
+
+// NotificationSummaryField is one key field of a record in a notification summary
+type NotificationSummaryField struct {
+	Field string `json:"field"`
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// formRecordSummary picks the form's summary fields out of a record's data, labelled as
+// the form labels them. Fields without a value are left out.
+func formRecordSummary(form *models.AppForm, data map[string]interface{}) []NotificationSummaryField {
+	if form == nil {
+		return nil
+	}
+	names := form.SummaryFields()
+	if len(names) == 0 {
+		return nil
+	}
+	labels := make(map[string]string)
+	for _, field := range formvalidation.FromForm(form.FormSchema, form.Steps, form.Validations).Fields {
+		labels[field.Name] = field.Label
+		labels[formColumnName(field.Name)] = field.Label
+	}
+
+	var summary []NotificationSummaryField
+	for _, name := range names {
+		value, ok := data[name]
+		if !ok {
+			value = data[formColumnName(name)]
+		}
+		text := summaryValue(value)
+		if text == "" {
+			continue
+		}
+		if len(text) > maxSummaryValueLength {
+			text = strings.TrimSpace(text[:maxSummaryValueLength]) + "…"
+		}
+		label := labels[name]
+		if label == "" {
+			label = name
+		}
+		summary = append(summary, NotificationSummaryField{Field: name, Label: label, Value: text})
+	}
+	return summary
+}
+
+// summaryValue renders a form value for people: resolved references by name, lists
+// joined and yes/no for booleans
+func summaryValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		// Timestamps arrive as text once record data has been through JSON
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return summaryValue(t)
+		}
+		return strings.TrimSpace(v)
+	case bool:
+		if v {
+			return "Yes"
+		}
+		return "No"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		if v.Hour() == 0 && v.Minute() == 0 && v.Second() == 0 {
+			return v.Format("2006-01-02")
+		}
+		return v.Format("2006-01-02 15:04")
+	case []byte:
+		var decoded interface{}
+		if json.Unmarshal(v, &decoded) == nil {
+			return summaryValue(decoded)
+		}
+		return strings.TrimSpace(string(v))
+	case map[string]interface{}:
+		for _, key := range []string{"name", "label", "title"} {
+			if name, ok := v[key].(string); ok && name != "" {
+				return name
+			}
+		}
+		raw, _ := json.Marshal(v)
+		return string(raw)
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			if text := summaryValue(item); text != "" {
+				items = append(items, text)
+			}
+		}
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprint(value)
+}
+
+// renderSummary lays a record summary out as "Label: value" lines
+func renderSummary(summary []NotificationSummaryField) string {
+	lines := make([]string, len(summary))
+	for i, field := range summary {
+		lines[i] = field.Label + ": " + field.Value
+	}
+	return strings.Join(lines, "\n")
+}
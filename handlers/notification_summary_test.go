@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"p9e.in/ugcl/models"
+)
+
+func TestFormRecordSummary(t *testing.T) {
+	form := &models.AppForm{FormSchema: json.RawMessage(`{
+		"summary_fields": ["Vendor Name", "amount", "due_date", "remarks", "items", "urgent"],
+		"fields": [
+			{"name": "Vendor Name", "label": "Vendor", "type": "select"},
+			{"name": "amount", "label": "Amount (INR)", "type": "number"},
+			{"name": "due_date", "label": "Due", "type": "date"},
+			{"name": "remarks", "label": "Remarks", "type": "textarea"},
+			{"name": "items", "type": "multiselect"},
+			{"name": "urgent", "label": "Urgent", "type": "checkbox"}
+		]
+	}`)}
+	data := map[string]interface{}{
+		"vendor_name": map[string]interface{}{"id": "v-1", "name": "Acme Pumps"},
+		"amount":      125000.5,
+		"due_date":    "2026-11-01T00:00:00Z",
+		"remarks":     "",
+		"items":       []interface{}{"Pipes", "Valves"},
+		"urgent":      true,
+	}
+
+	want := []NotificationSummaryField{
+		{Field: "Vendor Name", Label: "Vendor", Value: "Acme Pumps"},
+		{Field: "amount", Label: "Amount (INR)", Value: "125000.5"},
+		{Field: "due_date", Label: "Due", Value: "2026-11-01"},
+		{Field: "items", Label: "items", Value: "Pipes, Valves"},
+		{Field: "urgent", Label: "Urgent", Value: "Yes"},
+	}
+	got := formRecordSummary(form, data)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("formRecordSummary =\n%+v\nwant\n%+v", got, want)
+	}
+	if text := renderSummary(got); text != "Vendor: Acme Pumps\nAmount (INR): 125000.5\nDue: 2026-11-01\nitems: Pipes, Valves\nUrgent: Yes" {
+		t.Errorf("renderSummary = %q", text)
+	}
+
+	if summary := formRecordSummary(&models.AppForm{FormSchema: json.RawMessage(`{}`)}, data); summary != nil {
+		t.Errorf("a form without summary_fields should have no summary, got %+v", summary)
+	}
+}
@@ -7,7 +7,6 @@ import (
 
 	"github.com/gorilla/mux"
 	"gorm.io/gorm/clause"
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 )
@@ -24,7 +23,7 @@ func GetAllPaintingReports(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	service := models.NewReportService(config.DB, models.Painting{})
+	service := models.NewReportService(middleware.ScopedDB(r), models.Painting{})
 	response, err := service.GetReport(params)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -41,7 +40,7 @@ func CreatePaintingReport(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r)
 	item.SiteEngineerName = user.Name
 	item.PhoneNumber = user.Phone
-	config.DB.Create(&item)
+	middleware.ScopedDB(r).Create(&item)
 	json.NewEncoder(w).Encode(item)
 }
 
@@ -49,7 +48,7 @@ func GetPaintingReport(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id, _ := strconv.Atoi(params["id"])
 	var item models.Painting
-	config.DB.First(&item, id)
+	middleware.ScopedDB(r).First(&item, id)
 	json.NewEncoder(w).Encode(item)
 }
 
@@ -57,16 +56,16 @@ func UpdatePaintingReport(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id, _ := strconv.Atoi(params["id"])
 	var item models.Painting
-	config.DB.First(&item, id)
+	middleware.ScopedDB(r).First(&item, id)
 	json.NewDecoder(r.Body).Decode(&item)
-	config.DB.Save(&item)
+	middleware.ScopedDB(r).Save(&item)
 	json.NewEncoder(w).Encode(item)
 }
 
 func DeletePaintingReport(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id, _ := strconv.Atoi(params["id"])
-	config.DB.Delete(&models.Painting{}, id)
+	middleware.ScopedDB(r).Delete(&models.Painting{}, id)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -81,7 +80,7 @@ func BatchPaintings(w http.ResponseWriter, r *http.Request) {
 		batch[i].SiteEngineerName = user.Name
 		batch[i].PhoneNumber = user.Phone
 	}
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Clauses(clause.OnConflict{
 			Columns:   []clause.Column{{Name: "id"}},
 			DoNothing: true,
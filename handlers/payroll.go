@@ -0,0 +1,911 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+func payrollRunRecord(run *models.PayrollRun) procurementRecord {
+	return procurementRecord{
+		kind: "payroll_run", table: "payroll_runs", permission: "payroll:generate",
+		id: run.ID, businessID: run.BusinessVerticalID, workflowID: run.WorkflowID,
+		state: run.CurrentState, createdBy: run.CreatedBy, title: "Payroll " + run.Period,
+	}
+}
+
+// seesPayroll reports whether the user may see payroll runs and their payslips: as one
+// who drafts them or approves them
+func seesPayroll(r *http.Request) bool {
+	permissions := middleware.GetEffectivePermissions(r)
+	return hasWorkflowPermission(permissions, "payroll:generate") || hasWorkflowPermission(permissions, "payroll:approve")
+}
+
+// payrollWorkflowID returns the approval workflow new payroll runs follow
+func payrollWorkflowID(db *gorm.DB) (*uuid.UUID, error) {
+	var workflow models.WorkflowDefinition
+	if err := db.Select("id").Where("code = ? AND is_active = ?", models.PayrollWorkflowCode, true).
+		First(&workflow).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError{status: http.StatusInternalServerError, message: "the payroll approval workflow is not configured"}
+		}
+		return nil, err
+	}
+	return &workflow.ID, nil
+}
+
+// payrollRules returns the business's statutory rules, or the defaults if it has set none
+func payrollRules(db *gorm.DB, businessID uuid.UUID) (models.PayrollStatutoryRules, error) {
+	var rules models.PayrollStatutoryRules
+	err := db.Where("business_vertical_id = ?", businessID).First(&rules).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.DefaultPayrollStatutoryRules(businessID), nil
+	}
+	return rules, err
+}
+
+// lockedPayrollPeriod returns the month, if any, between from and to (inclusive) whose
+// payroll run has gone for approval, so its attendance and leave can no longer change
+func lockedPayrollPeriod(db *gorm.DB, businessID uuid.UUID, from, to time.Time) (string, error) {
+	periods := []string{}
+	for m := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC); !m.After(to); m = m.AddDate(0, 1, 0) {
+		periods = append(periods, m.Format("2006-01"))
+	}
+	var run models.PayrollRun
+	err := db.Select("period").Where("business_vertical_id = ? AND period IN ? AND current_state NOT IN ?",
+		businessID, periods, []string{models.PayrollDraft, "rejected"}).First(&run).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	return run.Period, err
+}
+
+// ==========================
+// Statutory rule handlers
+// ==========================
+
+// GetPayrollStatutoryRules returns the business's PF, ESI, professional tax and TDS rules,
+// or the defaults it would be paid under if it has set none
+// GET /api/v1/business/{businessCode}/payroll/statutory-rules
+func GetPayrollStatutoryRules(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load statutory rules")
+		return
+	}
+	rules, err := payrollRules(middleware.ScopedDB(r), businessID)
+	if err != nil {
+		http.Error(w, "failed to load statutory rules", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"item": rules, "is_default": rules.ID == uuid.Nil})
+}
+
+// UpdatePayrollStatutoryRules replaces the business's statutory rules. Fields left out
+// keep their current values.
+// PUT /api/v1/business/{businessCode}/payroll/statutory-rules
+func UpdatePayrollStatutoryRules(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to save statutory rules")
+		return
+	}
+	rules, err := payrollRules(middleware.ScopedDB(r), businessID)
+	if err != nil {
+		http.Error(w, "failed to load statutory rules", http.StatusInternalServerError)
+		return
+	}
+	id := rules.ID
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := rules.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rules.ID, rules.BusinessVerticalID = id, businessID
+	rules.UpdatedBy = middleware.GetClaims(r).UserID
+
+	if err := middleware.ScopedDB(r).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "business_vertical_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"pf_employee_percent", "pf_employer_percent", "pf_wage_ceiling",
+			"esi_employee_percent", "esi_employer_percent", "esi_gross_ceiling", "pt_slabs",
+			"tds_standard_deduction", "tds_slabs", "tds_rebate_limit", "tds_cess_percent",
+			"updated_by", "updated_at",
+		}),
+	}).Create(&rules).Error; err != nil {
+		http.Error(w, "failed to save statutory rules", http.StatusInternalServerError)
+		return
+	}
+	middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID).First(&rules)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "statutory rules saved", "item": rules})
+}
+
+// ==========================
+// Salary structure handlers
+// ==========================
+
+// salaryStructureRequest is the body of salary structure create and update requests
+type salaryStructureRequest struct {
+	UserID        string                  `json:"user_id"`
+	EmployeeName  string                  `json:"employee_name"`
+	EmployeeCode  string                  `json:"employee_code"`
+	EffectiveFrom time.Time               `json:"effective_from"`
+	Components    models.SalaryComponents `json:"components"`
+	PFApplicable  *bool                   `json:"pf_applicable"`
+	ESIApplicable *bool                   `json:"esi_applicable"`
+	PTApplicable  *bool                   `json:"pt_applicable"`
+	TDSApplicable *bool                   `json:"tds_applicable"`
+	IsActive      *bool                   `json:"is_active"`
+	OvertimeRate  float64                 `json:"overtime_hourly_rate"`
+}
+
+// apply copies the request onto the structure, leaving statutory flags that are not
+// given as they were, and validates the result
+func (req salaryStructureRequest) apply(s *models.SalaryStructure) error {
+	s.EmployeeName = strings.TrimSpace(req.EmployeeName)
+	s.EmployeeCode = strings.TrimSpace(req.EmployeeCode)
+	s.EffectiveFrom = req.EffectiveFrom
+	s.Components = req.Components
+	s.OvertimeHourlyRate = req.OvertimeRate
+	for _, flag := range []struct {
+		from *bool
+		to   *bool
+	}{
+		{req.PFApplicable, &s.PFApplicable}, {req.ESIApplicable, &s.ESIApplicable},
+		{req.PTApplicable, &s.PTApplicable}, {req.TDSApplicable, &s.TDSApplicable},
+		{req.IsActive, &s.IsActive},
+	} {
+		if flag.from != nil {
+			*flag.to = *flag.from
+		}
+	}
+	if err := s.Validate(); err != nil {
+		return apiError{status: http.StatusBadRequest, message: err.Error()}
+	}
+	return nil
+}
+
+// ListSalaryStructures lists the business's salary structures by employee, newest
+// first. ?user_id= and ?active=true narrow them.
+// GET /api/v1/business/{businessCode}/salary-structures
+func ListSalaryStructures(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load salary structures")
+		return
+	}
+
+	query := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID)
+	if v := r.URL.Query().Get("user_id"); v != "" {
+		query = query.Where("user_id = ?", v)
+	}
+	if r.URL.Query().Get("active") == "true" {
+		query = query.Where("is_active = ?", true)
+	}
+	var items []models.SalaryStructure
+	if err := query.Order("employee_name, effective_from DESC").Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch salary structures", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// CreateSalaryStructure sets an employee's pay from a date. Their earlier structures stay
+// in place for the months before it.
+// POST /api/v1/business/{businessCode}/salary-structures
+func CreateSalaryStructure(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to create salary structure")
+		return
+	}
+
+	var req salaryStructureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	var user models.User
+	if err := middleware.ScopedDB(r).Select("id", "name").First(&user, "id = ?", req.UserID).Error; err != nil {
+		http.Error(w, "user not found", http.StatusBadRequest)
+		return
+	}
+	// The employee record linked to the account, if any, supplies the name and code
+	var employee models.Employee
+	if middleware.ScopedDB(r).Where("business_vertical_id = ? AND user_id = ? AND deleted_at IS NULL", businessID, user.ID).
+		First(&employee).Error == nil {
+		if strings.TrimSpace(req.EmployeeName) == "" {
+			req.EmployeeName = employee.FullName
+		}
+		if strings.TrimSpace(req.EmployeeCode) == "" {
+			req.EmployeeCode = employee.EmployeeCode
+		}
+	}
+	if strings.TrimSpace(req.EmployeeName) == "" {
+		req.EmployeeName = user.Name
+	}
+
+	userID := middleware.GetClaims(r).UserID
+	structure := models.SalaryStructure{
+		BusinessVerticalID: businessID,
+		UserID:             user.ID.String(),
+		PFApplicable:       true,
+		ESIApplicable:      true,
+		PTApplicable:       true,
+		TDSApplicable:      true,
+		IsActive:           true,
+		CreatedBy:          userID,
+		UpdatedBy:          userID,
+	}
+	if err := req.apply(&structure); err != nil {
+		writeProcurementErr(w, err, "failed to create salary structure")
+		return
+	}
+	// Create would skip false flags in favour of the column defaults
+	if err := middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&structure).Error; err != nil {
+			return err
+		}
+		return tx.Model(&structure).Select("pf_applicable", "esi_applicable", "pt_applicable", "tds_applicable", "is_active").
+			Updates(&structure).Error
+	}); err != nil {
+		http.Error(w, "failed to create salary structure", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "salary structure created", "item": structure})
+}
+
+// UpdateSalaryStructure corrects a salary structure. Payslips already computed from it
+// keep their amounts until their run is recomputed.
+// PUT /api/v1/business/{businessCode}/salary-structures/{id}
+func UpdateSalaryStructure(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update salary structure")
+		return
+	}
+	var structure models.SalaryStructure
+	if err := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID).First(&structure, "id = ?", mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "salary structure not found", http.StatusNotFound)
+		return
+	}
+
+	var req salaryStructureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.EmployeeName) == "" {
+		req.EmployeeName = structure.EmployeeName
+	}
+	if err := req.apply(&structure); err != nil {
+		writeProcurementErr(w, err, "failed to update salary structure")
+		return
+	}
+	structure.UpdatedBy = middleware.GetClaims(r).UserID
+	if err := middleware.ScopedDB(r).Model(&structure).Select(
+		"employee_name", "employee_code", "effective_from", "components", "pf_applicable", "esi_applicable",
+		"pt_applicable", "tds_applicable", "is_active", "overtime_hourly_rate", "updated_by",
+	).Updates(&structure).Error; err != nil {
+		http.Error(w, "failed to update salary structure", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "salary structure updated", "item": structure})
+}
+
+// ==========================
+// Leave handlers
+// ==========================
+
+// ListEmployeeLeaves lists leave recorded in the business, latest first. ?user_id=,
+// ?status= and ?period=YYYY-MM narrow it.
+// GET /api/v1/business/{businessCode}/employee-leaves
+func ListEmployeeLeaves(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load leave")
+		return
+	}
+
+	query := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID)
+	q := r.URL.Query()
+	if v := q.Get("user_id"); v != "" {
+		query = query.Where("user_id = ?", v)
+	}
+	if v := q.Get("status"); v != "" {
+		query = query.Where("status = ?", v)
+	}
+	if v := q.Get("period"); v != "" {
+		from, to, err := models.PayrollMonth(v)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query = query.Where("from_date < ? AND to_date >= ?", to, from)
+	}
+	var items []models.EmployeeLeave
+	if err := query.Order("from_date DESC").Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch leave", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// RecordEmployeeLeave records approved leave for an employee. Leave cannot be recorded in
+// a month whose payroll has gone for approval.
+// POST /api/v1/business/{businessCode}/employee-leaves
+func RecordEmployeeLeave(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to record leave")
+		return
+	}
+
+	var req struct {
+		UserID    string    `json:"user_id"`
+		LeaveType string    `json:"leave_type"`
+		FromDate  time.Time `json:"from_date"`
+		ToDate    time.Time `json:"to_date"`
+		Paid      *bool     `json:"paid"`
+		Reason    string    `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	leave := models.EmployeeLeave{
+		BusinessVerticalID: businessID,
+		UserID:             strings.TrimSpace(req.UserID),
+		LeaveType:          strings.TrimSpace(req.LeaveType),
+		FromDate:           req.FromDate,
+		ToDate:             req.ToDate,
+		Paid:               req.Paid == nil || *req.Paid,
+		Status:             models.LeaveApproved,
+		Reason:             strings.TrimSpace(req.Reason),
+		RecordedBy:         middleware.GetClaims(r).UserID,
+	}
+	if leave.LeaveType == "" || leave.FromDate.IsZero() || leave.ToDate.Before(leave.FromDate) {
+		http.Error(w, "leave_type, from_date and a to_date not before it are required", http.StatusBadRequest)
+		return
+	}
+	var count int64
+	middleware.ScopedDB(r).Model(&models.User{}).Where("id = ?", leave.UserID).Count(&count)
+	if count == 0 {
+		http.Error(w, "user not found", http.StatusBadRequest)
+		return
+	}
+	if period, err := lockedPayrollPeriod(middleware.ScopedDB(r), businessID, leave.FromDate, leave.ToDate); err != nil {
+		http.Error(w, "failed to check payroll", http.StatusInternalServerError)
+		return
+	} else if period != "" {
+		http.Error(w, "payroll for "+period+" has gone for approval; revise it before recording leave", http.StatusConflict)
+		return
+	}
+
+	if err := middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&leave).Error; err != nil {
+			return err
+		}
+		// Create would record unpaid leave as paid, the column's default
+		return tx.Model(&leave).Update("paid", leave.Paid).Error
+	}); err != nil {
+		http.Error(w, "failed to record leave", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "leave recorded", "item": leave})
+}
+
+// CancelEmployeeLeave cancels recorded leave, unless its month's payroll has gone for
+// approval
+// POST /api/v1/business/{businessCode}/employee-leaves/{id}/cancel
+func CancelEmployeeLeave(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to cancel leave")
+		return
+	}
+	var leave models.EmployeeLeave
+	if err := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID).First(&leave, "id = ?", mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "leave not found", http.StatusNotFound)
+		return
+	}
+	if leave.Status != models.LeaveApproved {
+		http.Error(w, "leave is already cancelled", http.StatusConflict)
+		return
+	}
+	if leave.ApplicationID != nil {
+		http.Error(w, "the leave was applied for; cancel its leave application instead", http.StatusConflict)
+		return
+	}
+	if period, err := lockedPayrollPeriod(middleware.ScopedDB(r), businessID, leave.FromDate, leave.ToDate); err != nil {
+		http.Error(w, "failed to check payroll", http.StatusInternalServerError)
+		return
+	} else if period != "" {
+		http.Error(w, "payroll for "+period+" has gone for approval; revise it before cancelling leave", http.StatusConflict)
+		return
+	}
+
+	if err := middleware.ScopedDB(r).Model(&leave).Updates(map[string]interface{}{"status": models.LeaveCancelled}).Error; err != nil {
+		http.Error(w, "failed to cancel leave", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "leave cancelled", "item": leave})
+}
+
+// ==========================
+// Payroll run handlers
+// ==========================
+
+// loadPayrollRun loads the payroll run in the request within the business, if the user
+// may see payroll
+func loadPayrollRun(db *gorm.DB, r *http.Request, businessID uuid.UUID) (*models.PayrollRun, error) {
+	if !seesPayroll(r) {
+		return nil, apiError{status: http.StatusForbidden, message: "insufficient permissions: requires 'payroll:generate' or 'payroll:approve'"}
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		return nil, apiError{status: http.StatusBadRequest, message: "invalid payroll run id"}
+	}
+	var run models.PayrollRun
+	if err := db.Where("business_vertical_id = ?", businessID).First(&run, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError{status: http.StatusNotFound, message: "payroll run not found"}
+		}
+		return nil, err
+	}
+	return &run, nil
+}
+
+// computePayrollRun replaces the run's payslips with ones worked out from the salary
+// structures in force at the end of its month, and the month's attendance and leave.
+// Days present and overtime come from the employee's daily attendance; for employees
+// with no days recorded in the month, days present are the working days they checked in
+// on without the check-in being rejected. Each payslip is tagged with the cost center
+// (their site's if they have none) and project of the employee record linked to the user.
+func computePayrollRun(tx *gorm.DB, run *models.PayrollRun) error {
+	from, to, err := models.PayrollMonth(run.Period)
+	if err != nil {
+		return apiError{status: http.StatusBadRequest, message: err.Error()}
+	}
+	rules, err := payrollRules(tx, run.BusinessVerticalID)
+	if err != nil {
+		return err
+	}
+
+	// Each employee's latest structure taking effect by the end of the month, if still active
+	var latest []models.SalaryStructure
+	if err := tx.Raw(`SELECT DISTINCT ON (user_id) * FROM salary_structures
+		WHERE business_vertical_id = ? AND effective_from < ?
+		ORDER BY user_id, effective_from DESC, created_at DESC`, run.BusinessVerticalID, to).Scan(&latest).Error; err != nil {
+		return err
+	}
+
+	var presence []struct {
+		UserID string
+		Days   float64
+	}
+	if err := tx.Raw(`SELECT user_id::text AS user_id, COUNT(DISTINCT DATE(check_in_at)) AS days
+		FROM attendance_sessions
+		WHERE business_vertical_id = ? AND check_in_at >= ? AND check_in_at < ?
+		AND validation_status <> ? AND deleted_at IS NULL AND EXTRACT(DOW FROM check_in_at) <> 0
+		GROUP BY user_id`, run.BusinessVerticalID, from, to, models.AttendanceValidationRejected).Scan(&presence).Error; err != nil {
+		return err
+	}
+	present := map[string]float64{}
+	for _, p := range presence {
+		present[p.UserID] = p.Days
+	}
+
+	var daily []struct {
+		UserID          string
+		Days            float64
+		OvertimeMinutes float64
+	}
+	if err := tx.Raw(`SELECT user_id::text AS user_id,
+		SUM(CASE status WHEN ? THEN 1 WHEN ? THEN 0.5 ELSE 0 END) AS days,
+		SUM(overtime_minutes) AS overtime_minutes
+		FROM attendance_days
+		WHERE business_vertical_id = ? AND date >= ? AND date < ?
+		GROUP BY user_id`, models.AttendancePresent, models.AttendanceHalfDay, run.BusinessVerticalID, from, to).
+		Scan(&daily).Error; err != nil {
+		return err
+	}
+	overtime := map[string]float64{}
+	for _, d := range daily {
+		present[d.UserID] = d.Days
+		overtime[d.UserID] = math.Round(d.OvertimeMinutes/60*100) / 100
+	}
+
+	var leaves []models.EmployeeLeave
+	if err := tx.Where("business_vertical_id = ? AND status = ? AND from_date < ? AND to_date >= ?",
+		run.BusinessVerticalID, models.LeaveApproved, to, from).Find(&leaves).Error; err != nil {
+		return err
+	}
+	leaveByUser := map[string][]models.EmployeeLeave{}
+	for _, l := range leaves {
+		leaveByUser[l.UserID] = append(leaveByUser[l.UserID], l)
+	}
+
+	var employees []struct {
+		UserID       string
+		SiteID       *uuid.UUID
+		CostCenterID *uuid.UUID
+		ProjectID    *uuid.UUID
+	}
+	if err := tx.Model(&models.Employee{}).Select("user_id::text AS user_id, site_id, cost_center_id, project_id").
+		Where("business_vertical_id = ? AND user_id IS NOT NULL AND deleted_at IS NULL", run.BusinessVerticalID).
+		Scan(&employees).Error; err != nil {
+		return err
+	}
+	type payrollTags struct{ costCenterID, projectID *uuid.UUID }
+	tagsByUser := make(map[string]payrollTags, len(employees))
+	for _, e := range employees {
+		tags := payrollTags{costCenterID: e.CostCenterID, projectID: e.ProjectID}
+		if tags.costCenterID == nil && e.SiteID != nil {
+			tags.costCenterID = siteCostCenter(tx, run.BusinessVerticalID, *e.SiteID)
+		}
+		tagsByUser[e.UserID] = tags
+	}
+
+	if err := tx.Where("run_id = ?", run.ID).Delete(&models.Payslip{}).Error; err != nil {
+		return err
+	}
+	run.EmployeeCount, run.TotalGross, run.TotalDeductions, run.TotalNetPay, run.TotalEmployerContributions = 0, 0, 0, 0, 0
+	payslips := []models.Payslip{}
+	for _, s := range latest {
+		if !s.IsActive {
+			continue
+		}
+		paid, unpaid := models.LeaveDaysBetween(leaveByUser[s.UserID], from, to)
+		p := models.ComputePayslip(s, models.PayrollAttendance{
+			WorkingDays:     run.WorkingDays,
+			PresentDays:     present[s.UserID],
+			PaidLeaveDays:   paid,
+			UnpaidLeaveDays: unpaid,
+			OvertimeHours:   overtime[s.UserID],
+		}, rules)
+		p.RunID = run.ID
+		p.Period = run.Period
+		p.CostCenterID, p.ProjectID = tagsByUser[s.UserID].costCenterID, tagsByUser[s.UserID].projectID
+		payslips = append(payslips, p)
+
+		run.EmployeeCount++
+		run.TotalGross += p.GrossEarnings
+		run.TotalDeductions += p.TotalDeductions
+		run.TotalNetPay += p.NetPay
+		for _, c := range p.EmployerContributions {
+			run.TotalEmployerContributions += c.Amount
+		}
+	}
+	if len(payslips) > 0 {
+		if err := tx.Create(&payslips).Error; err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	run.ComputedAt = &now
+	run.Payslips = payslips
+	return tx.Model(&models.PayrollRun{}).Where("id = ?", run.ID).Updates(map[string]interface{}{
+		"employee_count":               run.EmployeeCount,
+		"total_gross":                  run.TotalGross,
+		"total_deductions":             run.TotalDeductions,
+		"total_net_pay":                run.TotalNetPay,
+		"total_employer_contributions": run.TotalEmployerContributions,
+		"computed_at":                  now,
+		"updated_by":                   run.UpdatedBy,
+	}).Error
+}
+
+// ListPayrollRuns lists the business's payroll runs, latest month first. ?state= and
+// ?year= narrow them.
+// GET /api/v1/business/{businessCode}/payroll-runs
+func ListPayrollRuns(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load payroll runs")
+		return
+	}
+	if !seesPayroll(r) {
+		http.Error(w, "insufficient permissions: requires 'payroll:generate' or 'payroll:approve'", http.StatusForbidden)
+		return
+	}
+
+	query := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID)
+	if v := r.URL.Query().Get("state"); v != "" {
+		query = query.Where("current_state = ?", v)
+	}
+	if v := r.URL.Query().Get("year"); v != "" {
+		query = query.Where("period LIKE ?", v+"-%")
+	}
+	var items []models.PayrollRun
+	if err := query.Order("period DESC").Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch payroll runs", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// CreatePayrollRun drafts the payroll for a month and computes its payslips. Working days
+// default to the month's days other than Sundays.
+// POST /api/v1/business/{businessCode}/payroll-runs
+func CreatePayrollRun(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to create payroll run")
+		return
+	}
+
+	var req struct {
+		Period      string `json:"period"`
+		WorkingDays int    `json:"working_days"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	from, to, err := models.PayrollMonth(req.Period)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.WorkingDays == 0 {
+		req.WorkingDays = models.WorkingDaysBetween(from, to)
+	}
+	if req.WorkingDays < 0 || req.WorkingDays > int(to.Sub(from).Hours()/24) {
+		http.Error(w, "working_days must be within the month", http.StatusBadRequest)
+		return
+	}
+	workflowID, err := payrollWorkflowID(middleware.ScopedDB(r))
+	if err != nil {
+		writeProcurementErr(w, err, "failed to create payroll run")
+		return
+	}
+
+	userID := middleware.GetClaims(r).UserID
+	run := models.PayrollRun{
+		BusinessVerticalID: businessID,
+		Period:             req.Period,
+		WorkingDays:        req.WorkingDays,
+		WorkflowID:         workflowID,
+		CurrentState:       models.PayrollDraft,
+		CreatedBy:          userID,
+		UpdatedBy:          userID,
+	}
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.PayrollRun{}).Where("business_vertical_id = ? AND period = ?", businessID, req.Period).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return apiError{status: http.StatusConflict, message: "a payroll run for " + req.Period + " already exists"}
+		}
+		if err := tx.Create(&run).Error; err != nil {
+			return err
+		}
+		return computePayrollRun(tx, &run)
+	})
+	if err != nil {
+		writeProcurementErr(w, err, "failed to create payroll run")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "payroll run created", "item": run})
+}
+
+// GetPayrollRun returns a payroll run with its payslips, its workflow history and the
+// actions the user may take on it
+// GET /api/v1/business/{businessCode}/payroll-runs/{id}
+func GetPayrollRun(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load payroll run")
+		return
+	}
+	run, err := loadPayrollRun(middleware.ScopedDB(r).Preload("Payslips", func(db *gorm.DB) *gorm.DB {
+		return db.Order("employee_name")
+	}), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load payroll run")
+		return
+	}
+
+	writeProcurementDocument(w, r, payrollRunRecord(run), run, nil)
+}
+
+// RecomputePayrollRun recomputes a draft run's payslips from the current salary
+// structures, attendance, leave and statutory rules. working_days may be changed too.
+// POST /api/v1/business/{businessCode}/payroll-runs/{id}/recompute
+func RecomputePayrollRun(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to recompute payroll run")
+		return
+	}
+	run, err := loadPayrollRun(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load payroll run")
+		return
+	}
+	var req struct {
+		WorkingDays int `json:"working_days"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	if req.WorkingDays != 0 {
+		from, to, _ := models.PayrollMonth(run.Period)
+		if req.WorkingDays < 0 || req.WorkingDays > int(to.Sub(from).Hours()/24) {
+			http.Error(w, "working_days must be within the month", http.StatusBadRequest)
+			return
+		}
+		run.WorkingDays = req.WorkingDays
+	}
+	run.UpdatedBy = middleware.GetClaims(r).UserID
+
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.PayrollRun{}).Where("id = ? AND current_state = ?", run.ID, models.PayrollDraft).
+			Update("working_days", run.WorkingDays)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return apiError{status: http.StatusConflict, message: "only a draft payroll run can be recomputed"}
+		}
+		return computePayrollRun(tx, run)
+	})
+	if err != nil {
+		writeProcurementErr(w, err, "failed to recompute payroll run")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "payroll run recomputed", "item": run})
+}
+
+// TakePayrollRunAction takes a workflow action (submit, approve, reject or revise) on a
+// payroll run. Approval needs payroll:approve and someone other than who drafted the run,
+// and posts the run's salaries to the ledger.
+// POST /api/v1/business/{businessCode}/payroll-runs/{id}/actions/{action}
+func TakePayrollRunAction(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update payroll run")
+		return
+	}
+	run, err := loadPayrollRun(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load payroll run")
+		return
+	}
+	action := mux.Vars(r)["action"]
+	if action == "submit" && run.EmployeeCount == 0 {
+		http.Error(w, "the payroll run has no payslips", http.StatusUnprocessableEntity)
+		return
+	}
+	var req procurementActionRequest
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	transition, err := takeProcurementAction(r, payrollRunRecord(run), action, req.Comment, func(tx *gorm.DB, to string) error {
+		if to != models.PayrollApproved {
+			return nil
+		}
+		return postPayrollRun(tx, run, middleware.GetClaims(r).UserID)
+	})
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update payroll run")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "payroll run " + transition.ToState, "transition": transition})
+}
+
+// ==========================
+// Payslip handlers
+// ==========================
+
+// writePayslipPDF renders the payslip and writes it as a download
+func writePayslipPDF(w http.ResponseWriter, payslip *models.Payslip, approved bool) {
+	var business models.BusinessVertical
+	if err := config.DB.First(&business, "id = ?", payslip.BusinessVerticalID).Error; err != nil {
+		http.Error(w, "failed to load business", http.StatusInternalServerError)
+		return
+	}
+
+	pdf := renderPayslipPDF(payslip, business.Name, approved)
+	name := payslip.EmployeeCode
+	if name == "" {
+		name = payslip.EmployeeName
+	}
+	filename := strings.NewReplacer("/", "-", "\\", "-", `"`, "", " ", "_").Replace(fmt.Sprintf("payslip-%s-%s", payslip.Period, name)) + ".pdf"
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(pdf)
+}
+
+// DownloadPayslipPDF renders a payslip in a payroll run as a PDF. Payslips of runs not
+// yet approved are marked as drafts.
+// GET /api/v1/business/{businessCode}/payroll-runs/{id}/payslips/{payslipId}/pdf
+func DownloadPayslipPDF(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load payslip")
+		return
+	}
+	run, err := loadPayrollRun(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load payroll run")
+		return
+	}
+	var payslip models.Payslip
+	if err := middleware.ScopedDB(r).Where("run_id = ?", run.ID).First(&payslip, "id = ?", mux.Vars(r)["payslipId"]).Error; err != nil {
+		http.Error(w, "payslip not found", http.StatusNotFound)
+		return
+	}
+
+	writePayslipPDF(w, &payslip, run.CurrentState == models.PayrollApproved)
+}
+
+// ListMyPayslips lists the user's payslips in the business from approved payroll runs,
+// latest first
+// GET /api/v1/business/{businessCode}/my-payslips
+func ListMyPayslips(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load payslips")
+		return
+	}
+
+	var items []models.Payslip
+	if err := middleware.ScopedDB(r).Where("business_vertical_id = ? AND user_id = ?", businessID, middleware.GetClaims(r).UserID).
+		Where("run_id IN (SELECT id FROM payroll_runs WHERE current_state = ?)", models.PayrollApproved).
+		Order("period DESC").Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch payslips", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// DownloadMyPayslipPDF renders one of the user's approved payslips as a PDF
+// GET /api/v1/business/{businessCode}/my-payslips/{id}/pdf
+func DownloadMyPayslipPDF(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load payslip")
+		return
+	}
+
+	var payslip models.Payslip
+	if err := middleware.ScopedDB(r).Where("business_vertical_id = ? AND user_id = ?", businessID, middleware.GetClaims(r).UserID).
+		Where("run_id IN (SELECT id FROM payroll_runs WHERE current_state = ?)", models.PayrollApproved).
+		First(&payslip, "id = ?", mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "payslip not found", http.StatusNotFound)
+		return
+	}
+
+	writePayslipPDF(w, &payslip, true)
+}
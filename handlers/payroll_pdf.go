@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"fmt"
+
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/pdfdoc"
+)
+
+// renderPayslipPDF lays a payslip out as a one-page A4 document: the employee and the
+// month's attendance, earnings and deductions side by side, the net pay and the
+// employer's statutory contributions. Payslips of runs not yet approved are marked as
+// drafts.
+func renderPayslipPDF(p *models.Payslip, businessName string, approved bool) []byte {
+	doc := pdfdoc.New()
+	doc.SetTitle("Payslip " + p.Period + " " + p.EmployeeName)
+	doc.AddPage()
+
+	month := p.Period
+	if from, _, err := models.PayrollMonth(p.Period); err == nil {
+		month = from.Format("January 2006")
+	}
+	doc.Text(40, 50, 14, true, businessName)
+	doc.TextRight(poMarginRight, 50, 14, true, "PAYSLIP")
+	doc.TextRight(poMarginRight, 64, 9, false, month)
+	if !approved {
+		doc.TextRight(poMarginRight, 76, 9, true, "DRAFT - NOT APPROVED")
+	}
+	doc.Line(40, 84, poMarginRight, 84)
+
+	doc.Text(40, 100, 9, false, "Employee: "+p.EmployeeName)
+	if p.EmployeeCode != "" {
+		doc.Text(40, 112, 9, false, "Employee code: "+p.EmployeeCode)
+	}
+	doc.Text(310, 100, 9, false, fmt.Sprintf("Working days: %d", p.WorkingDays))
+	doc.Text(310, 112, 9, false, fmt.Sprintf("Present: %s   Paid leave: %s", formatQuantity(p.PresentDays), formatQuantity(p.PaidLeaveDays)))
+	doc.Text(310, 124, 9, false, fmt.Sprintf("Paid days: %s   Loss of pay: %s", formatQuantity(p.PaidDays), formatQuantity(p.LOPDays)))
+
+	// Earnings on the left, deductions on the right
+	y := 150.0
+	doc.Line(40, y-10, poMarginRight, y-10)
+	doc.Text(40, y, 9, true, "Earnings")
+	doc.TextRight(290, y, 9, true, "Amount")
+	doc.Text(310, y, 9, true, "Deductions")
+	doc.TextRight(poMarginRight, y, 9, true, "Amount")
+	doc.Line(40, y+5, poMarginRight, y+5)
+	rows := len(p.Earnings)
+	if len(p.Deductions) > rows {
+		rows = len(p.Deductions)
+	}
+	for i := 0; i < rows; i++ {
+		y += 14
+		if i < len(p.Earnings) {
+			doc.Text(40, y, 9, false, p.Earnings[i].Name)
+			doc.TextRight(290, y, 9, false, formatAmount(p.Earnings[i].Amount))
+		}
+		if i < len(p.Deductions) {
+			doc.Text(310, y, 9, false, p.Deductions[i].Name)
+			doc.TextRight(poMarginRight, y, 9, false, formatAmount(p.Deductions[i].Amount))
+		}
+	}
+	y += 10
+	doc.Line(40, y, poMarginRight, y)
+	y += 14
+	doc.Text(40, y, 9, true, "Gross earnings")
+	doc.TextRight(290, y, 9, true, formatAmount(p.GrossEarnings))
+	doc.Text(310, y, 9, true, "Total deductions")
+	doc.TextRight(poMarginRight, y, 9, true, formatAmount(p.TotalDeductions))
+
+	y += 28
+	doc.Rect(40, y-14, poMarginRight-40, 22)
+	doc.Text(48, y, 11, true, "Net pay")
+	doc.TextRight(poMarginRight-8, y, 11, true, formatAmount(p.NetPay))
+
+	if len(p.EmployerContributions) > 0 {
+		y += 34
+		doc.Text(40, y, 9, true, "Employer contributions (not part of net pay)")
+		for _, c := range p.EmployerContributions {
+			y += 14
+			doc.Text(40, y, 9, false, c.Name)
+			doc.TextRight(290, y, 9, false, formatAmount(c.Amount))
+		}
+	}
+
+	doc.Text(40, 815, 7, false, "This is a computer-generated payslip and does not need a signature.")
+	return doc.Bytes()
+}
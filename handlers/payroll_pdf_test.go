@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"bytes"
+	"testing"
+
+	"p9e.in/ugcl/models"
+)
+
+func TestRenderPayslipPDF(t *testing.T) {
+	p := &models.Payslip{
+		EmployeeName: "Ravi Kumar",
+		EmployeeCode: "UG-0142",
+		Period:       "2026-10",
+		WorkingDays:  27,
+		PresentDays:  25,
+		PaidDays:     25,
+		LOPDays:      2,
+		Earnings: models.PayslipLines{
+			{Code: "BASIC", Name: "Basic", Amount: 18518.52},
+			{Code: "HRA", Name: "House rent allowance", Amount: 7407.41},
+		},
+		Deductions:            models.PayslipLines{{Code: "PF", Name: "Provident fund", Amount: 1800}, {Code: "PT", Name: "Professional tax", Amount: 200}},
+		EmployerContributions: models.PayslipLines{{Code: "PF", Name: "Provident fund (employer)", Amount: 1800}},
+		GrossEarnings:         25925.93,
+		TotalDeductions:       2000,
+		NetPay:                23925.93,
+	}
+
+	out := renderPayslipPDF(p, "Head Office", false)
+	if !bytes.HasPrefix(out, []byte("%PDF-")) {
+		t.Fatal("not a PDF")
+	}
+	for _, want := range []string{"(October 2026)", "(DRAFT - NOT APPROVED)", "(23,925.93)", "(Employee code: UG-0142)"} {
+		if !bytes.Contains(out, []byte(want)) {
+			t.Errorf("PDF lacks %s", want)
+		}
+	}
+	if bytes.Contains(renderPayslipPDF(p, "Head Office", true), []byte("DRAFT")) {
+		t.Error("approved payslip marked as a draft")
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// PermissionCatalogResource groups the catalog permissions of one resource
+type PermissionCatalogResource struct {
+	Resource    string               `json:"resource"`
+	Actions     []string             `json:"actions"`
+	Permissions []PermissionResponse `json:"permissions"`
+}
+
+// GetPermissionCatalog returns every permission a role may be built from, grouped by
+// resource and sorted by action. Role create/update requests are validated against it.
+// GET /api/v1/permissions (?resource=)
+func GetPermissionCatalog(w http.ResponseWriter, r *http.Request) {
+	query := middleware.ScopedDB(r).Model(&models.Permission{})
+	if resource := strings.TrimSpace(r.URL.Query().Get("resource")); resource != "" {
+		query = query.Where("resource = ?", resource)
+	}
+
+	var permissions []models.Permission
+	if err := query.Find(&permissions).Error; err != nil {
+		http.Error(w, "failed to load permissions", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"resources": groupPermissionCatalog(permissions),
+		"total":     len(permissions),
+	})
+}
+
+// groupPermissionCatalog groups permissions by resource. Permissions without a resource
+// fall back to the prefix of a "resource:action" name, then to "general".
+func groupPermissionCatalog(permissions []models.Permission) []PermissionCatalogResource {
+	byResource := make(map[string]*PermissionCatalogResource)
+	for _, perm := range permissions {
+		resource := strings.TrimSpace(perm.Resource)
+		if resource == "" {
+			if i := strings.Index(perm.Name, ":"); i > 0 {
+				resource = perm.Name[:i]
+			} else {
+				resource = "general"
+			}
+		}
+		group, ok := byResource[resource]
+		if !ok {
+			group = &PermissionCatalogResource{Resource: resource}
+			byResource[resource] = group
+		}
+		group.Permissions = append(group.Permissions, PermissionResponse{
+			ID:          perm.ID,
+			Name:        perm.Name,
+			Description: perm.Description,
+			Resource:    resource,
+			Action:      perm.Action,
+		})
+	}
+
+	groups := make([]PermissionCatalogResource, 0, len(byResource))
+	for _, group := range byResource {
+		sort.Slice(group.Permissions, func(i, j int) bool {
+			a, b := group.Permissions[i], group.Permissions[j]
+			if a.Action != b.Action {
+				return a.Action < b.Action
+			}
+			return a.Name < b.Name
+		})
+		seen := make(map[string]bool)
+		for _, perm := range group.Permissions {
+			if perm.Action != "" && !seen[perm.Action] {
+				seen[perm.Action] = true
+				group.Actions = append(group.Actions, perm.Action)
+			}
+		}
+		groups = append(groups, *group)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Resource < groups[j].Resource })
+	return groups
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+type permissionDenyRequest struct {
+	Permission         string     `json:"permission"`
+	RoleID             *uuid.UUID `json:"role_id,omitempty"`
+	BusinessRoleID     *uuid.UUID `json:"business_role_id,omitempty"`
+	UserID             *uuid.UUID `json:"user_id,omitempty"`
+	BusinessVerticalID *uuid.UUID `json:"business_vertical_id,omitempty"`
+	Reason             string     `json:"reason"`
+}
+
+// ListPermissionDenies lists deny entries (?user_id=&role_id=&business_role_id=). Business
+// routes return the entries of the vertical: its roles' denies and user denies scoped to it.
+// GET /api/v1/admin/permission-denies
+// GET /api/v1/business/{businessCode}/permission-denies
+func ListPermissionDenies(w http.ResponseWriter, r *http.Request) {
+	query := middleware.ScopedDB(r).Order("created_at DESC")
+	if businessID := middleware.GetCurrentBusinessID(r); businessID != uuid.Nil {
+		query = query.Where(
+			"business_vertical_id = ? OR business_role_id IN (SELECT id FROM business_roles WHERE business_vertical_id = ?)",
+			businessID, businessID)
+	}
+	for _, column := range []string{"user_id", "role_id", "business_role_id"} {
+		if id, err := uuid.Parse(r.URL.Query().Get(column)); err == nil {
+			query = query.Where(column+" = ?", id)
+		}
+	}
+
+	var denies []models.PermissionDeny
+	if err := query.Find(&denies).Error; err != nil {
+		http.Error(w, "failed to load permission denies", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"denies": denies})
+}
+
+// CreatePermissionDeny withholds a permission from a role, business role or user. Denies
+// override allows, e.g. grant Manager broadly and deny "payroll:*" to one user. Business
+// routes may only deny business roles of the vertical and users within the vertical.
+// POST /api/v1/admin/permission-denies
+// POST /api/v1/business/{businessCode}/permission-denies
+func CreatePermissionDeny(w http.ResponseWriter, r *http.Request) {
+	var req permissionDenyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	deny := models.PermissionDeny{
+		Permission:         req.Permission,
+		RoleID:             req.RoleID,
+		BusinessRoleID:     req.BusinessRoleID,
+		UserID:             req.UserID,
+		BusinessVerticalID: req.BusinessVerticalID,
+		Reason:             strings.TrimSpace(req.Reason),
+	}
+
+	if businessID := middleware.GetCurrentBusinessID(r); businessID != uuid.Nil {
+		if deny.RoleID != nil {
+			http.Error(w, "global role denies are managed by platform admins", http.StatusForbidden)
+			return
+		}
+		if deny.UserID != nil {
+			deny.BusinessVerticalID = &businessID
+		}
+		if deny.BusinessRoleID != nil {
+			var count int64
+			middleware.ScopedDB(r).Model(&models.BusinessRole{}).
+				Where("id = ? AND business_vertical_id = ?", *deny.BusinessRoleID, businessID).
+				Count(&count)
+			if count == 0 {
+				http.Error(w, "role not found in this business", http.StatusNotFound)
+				return
+			}
+		}
+	}
+
+	if err := deny.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !strings.Contains(deny.Permission, "*") {
+		var count int64
+		if err := middleware.ScopedDB(r).Model(&models.Permission{}).Where("name = ?", deny.Permission).Count(&count).Error; err != nil {
+			http.Error(w, "failed to validate permission", http.StatusInternalServerError)
+			return
+		}
+		if count == 0 {
+			http.Error(w, "permission must be a catalog permission or a pattern such as payroll:*", http.StatusBadRequest)
+			return
+		}
+	}
+	if deny.UserID != nil {
+		var count int64
+		middleware.ScopedDB(r).Model(&models.User{}).Where("id = ?", *deny.UserID).Count(&count)
+		if count == 0 {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+	}
+	if deny.RoleID != nil {
+		var count int64
+		middleware.ScopedDB(r).Model(&models.Role{}).Where("id = ?", *deny.RoleID).Count(&count)
+		if count == 0 {
+			http.Error(w, "role not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	if claims := middleware.GetClaims(r); claims != nil {
+		if id, err := uuid.Parse(claims.UserID); err == nil {
+			deny.CreatedBy = &id
+		}
+	}
+	if err := middleware.ScopedDB(r).Create(&deny).Error; err != nil {
+		http.Error(w, "failed to create permission deny", http.StatusInternalServerError)
+		return
+	}
+
+	middleware.InvalidatePermissionDenyCache()
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"deny": deny})
+}
+
+// DeletePermissionDeny lifts a deny entry
+// DELETE /api/v1/admin/permission-denies/{id}
+// DELETE /api/v1/business/{businessCode}/permission-denies/{id}
+func DeletePermissionDeny(w http.ResponseWriter, r *http.Request) {
+	denyID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid deny ID", http.StatusBadRequest)
+		return
+	}
+
+	query := middleware.ScopedDB(r).Where("id = ?", denyID)
+	if businessID := middleware.GetCurrentBusinessID(r); businessID != uuid.Nil {
+		query = query.Where(
+			"business_vertical_id = ? OR business_role_id IN (SELECT id FROM business_roles WHERE business_vertical_id = ?)",
+			businessID, businessID)
+	}
+	result := query.Delete(&models.PermissionDeny{})
+	if result.Error != nil {
+		http.Error(w, "failed to delete permission deny", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "permission deny not found", http.StatusNotFound)
+		return
+	}
+
+	middleware.InvalidatePermissionDenyCache()
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"net/http"
+
+	"p9e.in/ugcl/pkg/hooks"
+)
+
+// GetPluginHooks returns loaded plugins and per-hook execution metrics.
+func GetPluginHooks(w http.ResponseWriter, r *http.Request) {
+	registry := hooks.Default()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"plugins": registry.Plugins(),
+		"metrics": registry.Metrics(),
+	})
+}
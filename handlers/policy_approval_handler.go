@@ -7,7 +7,6 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 	"p9e.in/ugcl/pkg/abac"
@@ -40,7 +39,7 @@ func CreateApprovalRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	approvalService := abac.NewApprovalService(config.DB)
+	approvalService := abac.NewApprovalService(middleware.ScopedDB(r))
 	request, err := approvalService.CreateApprovalRequest(policyID, req.RequestType, userID, req.Notes, req.ChangesProposed)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -73,7 +72,7 @@ func ApproveRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	approvalService := abac.NewApprovalService(config.DB)
+	approvalService := abac.NewApprovalService(middleware.ScopedDB(r))
 	request, err := approvalService.ApproveRequest(requestID, approverID, req.Comments)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -108,7 +107,7 @@ func RejectRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	approvalService := abac.NewApprovalService(config.DB)
+	approvalService := abac.NewApprovalService(middleware.ScopedDB(r))
 	request, err := approvalService.RejectRequest(requestID, approverID, req.Comments)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -138,7 +137,7 @@ func GetPendingApprovals(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	approvalService := abac.NewApprovalService(config.DB)
+	approvalService := abac.NewApprovalService(middleware.ScopedDB(r))
 	requests, total, err := approvalService.GetPendingApprovals(limit, offset)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -168,8 +167,8 @@ func GetMyPendingApprovals(w http.ResponseWriter, r *http.Request) {
 	// Get user roles
 	user := middleware.GetUser(r)
 	userRoles := []string{}
-	if user.RoleModel != nil {
-		userRoles = append(userRoles, user.RoleModel.Name)
+	if role := user.EffectiveRole(); role != nil {
+		userRoles = append(userRoles, role.Name)
 	}
 
 	limitStr := r.URL.Query().Get("limit")
@@ -189,7 +188,7 @@ func GetMyPendingApprovals(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	approvalService := abac.NewApprovalService(config.DB)
+	approvalService := abac.NewApprovalService(middleware.ScopedDB(r))
 	requests, total, err := approvalService.GetUserPendingApprovals(userID, userRoles, limit, offset)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -216,7 +215,7 @@ func GetApprovalRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	approvalService := abac.NewApprovalService(config.DB)
+	approvalService := abac.NewApprovalService(middleware.ScopedDB(r))
 	request, err := approvalService.GetApprovalRequest(requestID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
@@ -236,7 +235,7 @@ func GetPolicyVersions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	approvalService := abac.NewApprovalService(config.DB)
+	approvalService := abac.NewApprovalService(middleware.ScopedDB(r))
 	versions, err := approvalService.GetPolicyVersions(policyID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -273,7 +272,7 @@ func GetPolicyChangeLogs(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	approvalService := abac.NewApprovalService(config.DB)
+	approvalService := abac.NewApprovalService(middleware.ScopedDB(r))
 	logs, total, err := approvalService.GetPolicyChangeLogs(policyID, limit, offset)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -299,7 +298,7 @@ func CreateWorkflow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	approvalService := abac.NewApprovalService(config.DB)
+	approvalService := abac.NewApprovalService(middleware.ScopedDB(r))
 	if err := approvalService.CreateWorkflow(&workflow); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -312,7 +311,7 @@ func CreateWorkflow(w http.ResponseWriter, r *http.Request) {
 
 // GetWorkflows gets all approval workflows
 func GetWorkflows(w http.ResponseWriter, r *http.Request) {
-	approvalService := abac.NewApprovalService(config.DB)
+	approvalService := abac.NewApprovalService(middleware.ScopedDB(r))
 	workflows, err := approvalService.GetWorkflows()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
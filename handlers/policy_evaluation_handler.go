@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/abac"
+)
+
+// maxPolicyEvaluationExportRows caps a single CSV export; narrow the date range for more.
+const maxPolicyEvaluationExportRows = 100000
+
+// policyEvaluationQuery applies the shared audit log filters:
+// user_id, policy_id, business_vertical_id, source, effect, action, resource_type, path, from, to.
+func policyEvaluationQuery(r *http.Request) (*gorm.DB, error) {
+	q := r.URL.Query()
+	query := middleware.ScopedDB(r).Model(&models.PolicyEvaluation{})
+
+	for _, param := range []string{"user_id", "policy_id", "business_vertical_id"} {
+		raw := strings.TrimSpace(q.Get(param))
+		if raw == "" {
+			continue
+		}
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s", param)
+		}
+		query = query.Where(param+" = ?", id)
+	}
+
+	if source := strings.ToLower(strings.TrimSpace(q.Get("source"))); source != "" {
+		if source != models.PolicyEvaluationSourceRBAC && source != models.PolicyEvaluationSourceABAC {
+			return nil, fmt.Errorf("source must be rbac or abac")
+		}
+		query = query.Where("source = ?", source)
+	}
+	if effect := strings.ToUpper(strings.TrimSpace(q.Get("effect"))); effect != "" {
+		if effect != string(models.PolicyEffectAllow) && effect != string(models.PolicyEffectDeny) {
+			return nil, fmt.Errorf("effect must be ALLOW or DENY")
+		}
+		query = query.Where("effect = ?", effect)
+	}
+	if action := strings.TrimSpace(q.Get("action")); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if resourceType := strings.TrimSpace(q.Get("resource_type")); resourceType != "" {
+		query = query.Where("resource_type = ?", resourceType)
+	}
+	if path := strings.TrimSpace(q.Get("path")); path != "" {
+		query = query.Where("request_path LIKE ?", strings.ReplaceAll(path, "%", `\%`)+"%")
+	}
+
+	for _, bound := range []struct{ param, op string }{{"from", ">="}, {"to", "<="}} {
+		raw := strings.TrimSpace(q.Get(bound.param))
+		if raw == "" {
+			continue
+		}
+		ts, err := parseEvaluationTime(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: use RFC3339 or YYYY-MM-DD", bound.param)
+		}
+		if bound.param == "to" && len(raw) == len("2006-01-02") {
+			ts = ts.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		query = query.Where("evaluation_time "+bound.op+" ?", ts)
+	}
+
+	return query, nil
+}
+
+func parseEvaluationTime(raw string) (time.Time, error) {
+	if ts, err := time.Parse(time.RFC3339, raw); err == nil {
+		return ts, nil
+	}
+	return time.Parse("2006-01-02", raw)
+}
+
+// ListPolicyEvaluationLog returns recorded RBAC and ABAC decisions, newest first.
+func ListPolicyEvaluationLog(w http.ResponseWriter, r *http.Request) {
+	query, err := policyEvaluationQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := 50
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if limit > 500 {
+		limit = 500
+	}
+	offset := 0
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o > 0 {
+		offset = o
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		http.Error(w, "failed to count policy evaluations", http.StatusInternalServerError)
+		return
+	}
+
+	var evaluations []models.PolicyEvaluation
+	if err := query.Order("evaluation_time DESC").Limit(limit).Offset(offset).Find(&evaluations).Error; err != nil {
+		http.Error(w, "failed to load policy evaluations", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"evaluations": evaluations,
+		"total":       total,
+		"limit":       limit,
+		"offset":      offset,
+	})
+}
+
+// ExportPolicyEvaluationLog streams matching decisions as CSV.
+func ExportPolicyEvaluationLog(w http.ResponseWriter, r *http.Request) {
+	query, err := policyEvaluationQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := query.Order("evaluation_time DESC").Limit(maxPolicyEvaluationExportRows).Rows()
+	if err != nil {
+		http.Error(w, "failed to export policy evaluations", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	filename := fmt.Sprintf("policy_evaluations_%s.csv", time.Now().Format("20060102_150405"))
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{
+		"evaluation_time", "source", "user_id", "business_vertical_id", "action", "resource_type", "resource_id",
+		"effect", "policy_id", "policy_name", "reason", "matched_policies", "latency_us", "ip_address", "request_path",
+	})
+
+	optionalID := func(id *uuid.UUID) string {
+		if id == nil {
+			return ""
+		}
+		return id.String()
+	}
+
+	for rows.Next() {
+		var evaluation models.PolicyEvaluation
+		if err := middleware.ScopedDB(r).ScanRows(rows, &evaluation); err != nil {
+			break
+		}
+		matched, _ := json.Marshal(evaluation.MatchedConditions)
+		writer.Write([]string{
+			evaluation.EvaluationTime.Format(time.RFC3339Nano),
+			evaluation.Source,
+			evaluation.UserID.String(),
+			optionalID(evaluation.BusinessVerticalID),
+			evaluation.Action,
+			evaluation.ResourceType,
+			optionalID(evaluation.ResourceID),
+			string(evaluation.Effect),
+			optionalID(evaluation.PolicyID),
+			evaluation.PolicyName,
+			evaluation.Reason,
+			string(matched),
+			strconv.FormatInt(evaluation.LatencyMicros, 10),
+			evaluation.IPAddress,
+			evaluation.RequestPath,
+		})
+	}
+	writer.Flush()
+}
+
+// GetPolicyEvaluationLogSettings returns the recorder's sampling configuration and counters.
+func GetPolicyEvaluationLogSettings(w http.ResponseWriter, r *http.Request) {
+	recorder := abac.DefaultRecorder()
+	if recorder == nil {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"enabled": false, "message": "policy evaluation logging is not running"})
+		return
+	}
+	writeJSON(w, http.StatusOK, recorder.Stats())
+}
+
+// UpdatePolicyEvaluationLogSettings changes sampling at runtime. The change lasts until
+// restart; set POLICY_EVAL_* environment variables to make it permanent.
+func UpdatePolicyEvaluationLogSettings(w http.ResponseWriter, r *http.Request) {
+	recorder := abac.DefaultRecorder()
+	if recorder == nil {
+		http.Error(w, "policy evaluation logging is not running", http.StatusConflict)
+		return
+	}
+
+	var req struct {
+		Enabled   *bool    `json:"enabled"`
+		AllowRate *float64 `json:"allow_rate"`
+		DenyRate  *float64 `json:"deny_rate"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	sampling := recorder.Sampling()
+	if req.Enabled != nil {
+		sampling.Enabled = *req.Enabled
+	}
+	if req.AllowRate != nil {
+		if *req.AllowRate < 0 || *req.AllowRate > 1 {
+			http.Error(w, "allow_rate must be between 0 and 1", http.StatusBadRequest)
+			return
+		}
+		sampling.AllowRate = *req.AllowRate
+	}
+	if req.DenyRate != nil {
+		if *req.DenyRate < 0 || *req.DenyRate > 1 {
+			http.Error(w, "deny_rate must be between 0 and 1", http.StatusBadRequest)
+			return
+		}
+		sampling.DenyRate = *req.DenyRate
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"sampling": recorder.SetSampling(sampling)})
+}
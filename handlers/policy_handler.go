@@ -10,7 +10,6 @@ import (
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"golang.org/x/sync/singleflight"
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 	"p9e.in/ugcl/pkg/abac"
@@ -69,6 +68,7 @@ func (c *policyResponseCache) invalidate() {
 func invalidatePolicyCaches() {
 	policyListCache.invalidate()
 	policyStatsCache.invalidate()
+	abac.InvalidatePolicyCache()
 }
 
 // CreatePolicy creates a new policy
@@ -89,7 +89,7 @@ func CreatePolicy(w http.ResponseWriter, r *http.Request) {
 	policy.CreatedBy = userID
 
 	// Create policy
-	policyService := abac.NewPolicyService(config.DB)
+	policyService := abac.NewPolicyService(middleware.ScopedDB(r))
 	createdPolicy, err := policyService.CreatePolicy(policy)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -127,7 +127,7 @@ func UpdatePolicy(w http.ResponseWriter, r *http.Request) {
 	updates.UpdatedBy = &userID
 
 	// Update policy
-	policyService := abac.NewPolicyService(config.DB)
+	policyService := abac.NewPolicyService(middleware.ScopedDB(r))
 	updatedPolicy, err := policyService.UpdatePolicy(policyID, updates)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -139,6 +139,31 @@ func UpdatePolicy(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(updatedPolicy)
 }
 
+// ValidatePolicy dry-runs a policy without saving it. The body holds the policy, the
+// optional ID of the stored policy it would update, and an optional evaluation request
+// to test the policy against.
+func ValidatePolicy(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PolicyID *uuid.UUID            `json:"policy_id"`
+		Policy   models.Policy         `json:"policy"`
+		Request  *models.PolicyRequest `json:"request"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	policyService := abac.NewPolicyService(middleware.ScopedDB(r))
+	result, err := policyService.DryRunPolicy(req.PolicyID, req.Policy, req.Request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
 // DeletePolicy deletes a policy
 func DeletePolicy(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -148,7 +173,7 @@ func DeletePolicy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	policyService := abac.NewPolicyService(config.DB)
+	policyService := abac.NewPolicyService(middleware.ScopedDB(r))
 	if err := policyService.DeletePolicy(policyID); err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
@@ -167,7 +192,7 @@ func GetPolicy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	policyService := abac.NewPolicyService(config.DB)
+	policyService := abac.NewPolicyService(middleware.ScopedDB(r))
 	policy, err := policyService.GetPolicy(policyID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusNotFound)
@@ -213,7 +238,7 @@ func ListPolicies(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	policyService := abac.NewPolicyService(config.DB)
+	policyService := abac.NewPolicyService(middleware.ScopedDB(r))
 	statusKey := ""
 	if status != nil {
 		statusKey = string(*status)
@@ -278,7 +303,7 @@ func ActivatePolicy(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid user ID", http.StatusUnauthorized)
 		return
 	}
-	policyService := abac.NewPolicyService(config.DB)
+	policyService := abac.NewPolicyService(middleware.ScopedDB(r))
 	if err := policyService.ActivatePolicy(policyID, userID); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -304,7 +329,7 @@ func DeactivatePolicy(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid user ID", http.StatusUnauthorized)
 		return
 	}
-	policyService := abac.NewPolicyService(config.DB)
+	policyService := abac.NewPolicyService(middleware.ScopedDB(r))
 	if err := policyService.DeactivatePolicy(policyID, userID); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -330,7 +355,7 @@ func TestPolicy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	policyService := abac.NewPolicyService(config.DB)
+	policyService := abac.NewPolicyService(middleware.ScopedDB(r))
 	decision, err := policyService.TestPolicy(policyID, req)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -364,7 +389,7 @@ func ClonePolicy(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid user ID", http.StatusUnauthorized)
 		return
 	}
-	policyService := abac.NewPolicyService(config.DB)
+	policyService := abac.NewPolicyService(middleware.ScopedDB(r))
 	clonedPolicy, err := policyService.ClonePolicy(policyID, userID, req.NewName)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -403,7 +428,7 @@ func GetPolicyEvaluations(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	policyService := abac.NewPolicyService(config.DB)
+	policyService := abac.NewPolicyService(middleware.ScopedDB(r))
 	evaluations, total, err := policyService.GetPolicyEvaluations(policyID, limit, offset)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -423,7 +448,7 @@ func GetPolicyEvaluations(w http.ResponseWriter, r *http.Request) {
 
 // GetPolicyStatistics returns policy statistics
 func GetPolicyStatistics(w http.ResponseWriter, r *http.Request) {
-	policyService := abac.NewPolicyService(config.DB)
+	policyService := abac.NewPolicyService(middleware.ScopedDB(r))
 	const cacheKey = "all"
 
 	if payload, ok := policyStatsCache.get(cacheKey); ok {
@@ -466,7 +491,7 @@ func EvaluatePolicyRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	policyEngine := abac.NewPolicyEngine(config.DB)
+	policyEngine := abac.NewPolicyEngine(middleware.ScopedDB(r))
 	decision, err := policyEngine.EvaluateRequest(req)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/portfolio"
+)
+
+// PortfolioHandler exposes the cross-project portfolio dashboard and its weekly trends.
+type PortfolioHandler struct {
+	db *gorm.DB
+}
+
+func NewPortfolioHandler() *PortfolioHandler {
+	return &PortfolioHandler{db: config.DB}
+}
+
+type portfolioVerticalSummary struct {
+	BusinessVerticalID   uuid.UUID `json:"business_vertical_id"`
+	BusinessVerticalCode string    `json:"business_vertical_code,omitempty"`
+	BusinessVerticalName string    `json:"business_vertical_name,omitempty"`
+	ProjectCount         int       `json:"project_count"`
+	AverageRiskScore     float64   `json:"average_risk_score"`
+	HighRiskProjects     int       `json:"high_risk_projects"`
+	CriticalIncidents    int       `json:"critical_incidents"`
+	TotalBudget          float64   `json:"total_budget"`
+	SpentBudget          float64   `json:"spent_budget"` // in the base currency
+}
+
+// GetPortfolioDashboard returns every open project ranked by risk score.
+// Filters: business_vertical_id, status, risk_level, include_closed=true, limit.
+func (h *PortfolioHandler) GetPortfolioDashboard(w http.ResponseWriter, r *http.Request) {
+	filter, ok := h.portfolioFilter(w, r)
+	if !ok {
+		return
+	}
+	filter.Status = strings.TrimSpace(r.URL.Query().Get("status"))
+	filter.IncludeClosed = r.URL.Query().Get("include_closed") == "true"
+
+	results, err := portfolio.NewService(middleware.WithDataScope(r, h.db)).Evaluate(filter, time.Now())
+	if err != nil {
+		http.Error(w, "failed to evaluate portfolio", http.StatusInternalServerError)
+		return
+	}
+
+	if level := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("risk_level"))); level != "" {
+		filtered := results[:0]
+		for _, project := range results {
+			if project.RiskLevel == level {
+				filtered = append(filtered, project)
+			}
+		}
+		results = filtered
+	}
+
+	byLevel := map[string]int{
+		models.PortfolioRiskLow:      0,
+		models.PortfolioRiskMedium:   0,
+		models.PortfolioRiskHigh:     0,
+		models.PortfolioRiskCritical: 0,
+	}
+	verticals := make(map[uuid.UUID]*portfolioVerticalSummary)
+	verticalOrder := make([]uuid.UUID, 0)
+	var totalRisk, totalBudget, totalSpent float64
+	var totalIncidents int
+	for _, project := range results {
+		byLevel[project.RiskLevel]++
+		totalRisk += project.RiskScore
+		totalBudget += project.BaseTotalBudget
+		totalSpent += project.BaseSpentBudget
+		totalIncidents += project.CriticalIncidents
+
+		summary, exists := verticals[project.BusinessVerticalID]
+		if !exists {
+			summary = &portfolioVerticalSummary{
+				BusinessVerticalID:   project.BusinessVerticalID,
+				BusinessVerticalCode: project.BusinessVerticalCode,
+				BusinessVerticalName: project.BusinessVerticalName,
+			}
+			verticals[project.BusinessVerticalID] = summary
+			verticalOrder = append(verticalOrder, project.BusinessVerticalID)
+		}
+		summary.ProjectCount++
+		summary.AverageRiskScore += project.RiskScore
+		summary.CriticalIncidents += project.CriticalIncidents
+		summary.TotalBudget += project.BaseTotalBudget
+		summary.SpentBudget += project.BaseSpentBudget
+		if project.RiskLevel == models.PortfolioRiskHigh || project.RiskLevel == models.PortfolioRiskCritical {
+			summary.HighRiskProjects++
+		}
+	}
+
+	byVertical := make([]portfolioVerticalSummary, 0, len(verticalOrder))
+	for _, id := range verticalOrder {
+		summary := verticals[id]
+		summary.AverageRiskScore = models.Round2(summary.AverageRiskScore / float64(summary.ProjectCount))
+		byVertical = append(byVertical, *summary)
+	}
+
+	averageRisk := 0.0
+	if len(results) > 0 {
+		averageRisk = models.Round2(totalRisk / float64(len(results)))
+	}
+
+	projectCount := len(results)
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit > 0 && limit < len(results) {
+		results = results[:limit]
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"projects": results,
+		"summary": map[string]interface{}{
+			"project_count":      projectCount,
+			"average_risk_score": averageRisk,
+			"by_risk_level":      byLevel,
+			"critical_incidents": totalIncidents,
+			"total_budget":       models.Round2(totalBudget),
+			"spent_budget":       models.Round2(totalSpent),
+			"currency":           models.BaseCurrency,
+		},
+		"by_vertical":  byVertical,
+		"week_start":   portfolio.WeekStart(time.Now()).Format("2006-01-02"),
+		"generated_at": time.Now(),
+	})
+}
+
+type portfolioTrendPoint struct {
+	WeekStart             time.Time `json:"week_start"`
+	ProjectCount          int       `json:"project_count"`
+	AverageRiskScore      float64   `json:"average_risk_score"`
+	MaxRiskScore          float64   `json:"max_risk_score"`
+	AverageScheduleVar    float64   `json:"average_schedule_variance"`
+	AverageBudgetVariance float64   `json:"average_budget_variance"`
+	CriticalIncidents     int       `json:"critical_incidents"`
+	HighRiskProjects      int       `json:"high_risk_projects"`
+}
+
+// GetPortfolioTrends returns weekly portfolio aggregates from stored snapshots.
+// Pass project_id to also get that project's weekly snapshot series.
+func (h *PortfolioHandler) GetPortfolioTrends(w http.ResponseWriter, r *http.Request) {
+	filter, ok := h.portfolioFilter(w, r)
+	if !ok {
+		return
+	}
+
+	weeks := 12
+	if raw := r.URL.Query().Get("weeks"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > 104 {
+			http.Error(w, "weeks must be between 1 and 104", http.StatusBadRequest)
+			return
+		}
+		weeks = parsed
+	}
+	since := portfolio.WeekStart(time.Now()).AddDate(0, 0, -7*(weeks-1))
+
+	query := middleware.WithDataScope(r, h.db).Model(&models.PortfolioSnapshot{}).
+		Select(`week_start, COUNT(*) AS project_count, ROUND(AVG(risk_score), 2) AS average_risk_score,
+			MAX(risk_score) AS max_risk_score, ROUND(AVG(schedule_variance), 2) AS average_schedule_var,
+			ROUND(AVG(budget_variance), 2) AS average_budget_variance, SUM(critical_incidents) AS critical_incidents,
+			COUNT(*) FILTER (WHERE risk_level IN ('high', 'critical')) AS high_risk_projects`).
+		Where("week_start >= ?", since).
+		Group("week_start").
+		Order("week_start ASC")
+	if filter.BusinessVerticalID != nil {
+		query = query.Where("business_vertical_id = ?", *filter.BusinessVerticalID)
+	}
+	query = query.Scopes(models.ForCompanyVerticals(filter.CompanyID, "business_vertical_id"))
+
+	var points []portfolioTrendPoint
+	if err := query.Scan(&points).Error; err != nil {
+		http.Error(w, "failed to load portfolio trends", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{"weeks": weeks, "since": since.Format("2006-01-02"), "trend": points}
+
+	if raw := strings.TrimSpace(r.URL.Query().Get("project_id")); raw != "" {
+		projectID, err := uuid.Parse(raw)
+		if err != nil {
+			http.Error(w, "invalid project_id", http.StatusBadRequest)
+			return
+		}
+		projectQuery := middleware.WithDataScope(r, h.db).Where("project_id = ? AND week_start >= ?", projectID, since).Order("week_start ASC")
+		if filter.BusinessVerticalID != nil {
+			projectQuery = projectQuery.Where("business_vertical_id = ?", *filter.BusinessVerticalID)
+		}
+		projectQuery = projectQuery.Scopes(models.ForCompanyVerticals(filter.CompanyID, "business_vertical_id"))
+		var snapshots []models.PortfolioSnapshot
+		if err := projectQuery.Find(&snapshots).Error; err != nil {
+			http.Error(w, "failed to load project snapshots", http.StatusInternalServerError)
+			return
+		}
+		response["project_snapshots"] = snapshots
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// GetPortfolioProfitability returns each project's cost, budget and billed value from
+// from to to (YYYY-MM-DD, both required) with its margin, totalled by business vertical,
+// in the base currency. Closed projects are included. Filters: business_vertical_id,
+// status. format=csv downloads the project rows.
+func (h *PortfolioHandler) GetPortfolioProfitability(w http.ResponseWriter, r *http.Request) {
+	filter, ok := h.portfolioFilter(w, r)
+	if !ok {
+		return
+	}
+	filter.Status = strings.TrimSpace(r.URL.Query().Get("status"))
+	filter.IncludeClosed = true
+
+	from, err := parseAttendanceDate(r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, "from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseAttendanceDate(r.URL.Query().Get("to"))
+	if err != nil {
+		http.Error(w, "to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if to.Before(from) {
+		http.Error(w, "to must be on or after from", http.StatusBadRequest)
+		return
+	}
+
+	projects, byVertical, err := portfolio.NewService(middleware.WithDataScope(r, h.db)).Profitability(filter, from, to)
+	if err != nil {
+		http.Error(w, "failed to compute profitability", http.StatusInternalServerError)
+		return
+	}
+	var budget, cost, billed float64
+	for _, v := range byVertical {
+		budget += v.Budget
+		cost += v.Cost
+		billed += v.Billed
+	}
+	budget, cost, billed = models.Round2(budget), models.Round2(cost), models.Round2(billed)
+
+	if r.URL.Query().Get("format") == "csv" {
+		var buf bytes.Buffer
+		writer := csv.NewWriter(&buf)
+		_ = writer.Write([]string{"Vertical", "Project code", "Project", "Status", "Budget", "Cost", "Billed", "Margin", "Margin %", "Budget used %"})
+		percent := func(v *float64) string {
+			if v == nil {
+				return ""
+			}
+			return strconv.FormatFloat(*v, 'f', 2, 64)
+		}
+		for _, p := range projects {
+			_ = writer.Write([]string{
+				p.BusinessVerticalCode, p.Code, p.Name, p.Status,
+				strconv.FormatFloat(p.Budget, 'f', 2, 64), strconv.FormatFloat(p.Cost, 'f', 2, 64),
+				strconv.FormatFloat(p.Billed, 'f', 2, 64), strconv.FormatFloat(p.Margin, 'f', 2, 64),
+				percent(p.MarginPercent), percent(p.BudgetUsedPercent),
+			})
+		}
+		writer.Flush()
+
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="profitability-%s-%s.csv"`,
+			from.Format("2006-01-02"), to.Format("2006-01-02")))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(buf.Bytes())
+		return
+	}
+
+	summary := struct {
+		ProjectCount int     `json:"project_count"`
+		Budget       float64 `json:"budget"`
+		Cost         float64 `json:"cost"`
+		Billed       float64 `json:"billed"`
+		portfolio.Figures
+	}{len(projects), budget, cost, billed, portfolio.Settle(budget, cost, billed)}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"from":         from.Format("2006-01-02"),
+		"to":           to.Format("2006-01-02"),
+		"currency":     models.BaseCurrency,
+		"projects":     projects,
+		"by_vertical":  byVertical,
+		"summary":      summary,
+		"generated_at": time.Now(),
+	})
+}
+
+// TakePortfolioSnapshot stores (or refreshes) this week's snapshot immediately.
+func (h *PortfolioHandler) TakePortfolioSnapshot(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	count, err := portfolio.NewService(middleware.WithDataScope(r, h.db)).Snapshot(now)
+	if err != nil {
+		http.Error(w, "failed to take portfolio snapshot", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"week_start": portfolio.WeekStart(now).Format("2006-01-02"),
+		"projects":   count,
+		"message":    "portfolio snapshot stored",
+	})
+}
+
+// portfolioFilter reads business_vertical_id and pins business-scoped callers to their own vertical
+// and every caller to their company.
+func (h *PortfolioHandler) portfolioFilter(w http.ResponseWriter, r *http.Request) (portfolio.Filter, bool) {
+	filter := portfolio.Filter{CompanyID: middleware.GetCurrentCompanyID(r)}
+	if raw := strings.TrimSpace(r.URL.Query().Get("business_vertical_id")); raw != "" {
+		verticalID, err := uuid.Parse(raw)
+		if err != nil {
+			http.Error(w, "invalid business_vertical_id", http.StatusBadRequest)
+			return filter, false
+		}
+		filter.BusinessVerticalID = &verticalID
+	}
+
+	if businessContext := middleware.GetUserBusinessContext(r); businessContext != nil {
+		if businessID, ok := businessContext["business_id"].(uuid.UUID); ok && businessID != uuid.Nil {
+			if filter.BusinessVerticalID != nil && *filter.BusinessVerticalID != businessID {
+				http.Error(w, "access denied to requested business vertical", http.StatusForbidden)
+				return filter, false
+			}
+			filter.BusinessVerticalID = &businessID
+		}
+	}
+
+	return filter, true
+}
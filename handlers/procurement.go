@@ -0,0 +1,1457 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/hooks"
+)
+
+// procurementBusinessID returns the business in the request, or an apiError
+func procurementBusinessID(r *http.Request) (uuid.UUID, error) {
+	businessID := middleware.GetCurrentBusinessID(r)
+	if businessID == uuid.Nil {
+		return uuid.Nil, apiError{status: http.StatusBadRequest, message: "business ID required"}
+	}
+	return businessID, nil
+}
+
+// loadRequisition loads the requisition in the request within the business
+func loadRequisition(db *gorm.DB, r *http.Request, businessID uuid.UUID) (*models.PurchaseRequisition, error) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		return nil, apiError{status: http.StatusBadRequest, message: "invalid requisition id"}
+	}
+	var pr models.PurchaseRequisition
+	if err := db.Where("business_vertical_id = ? AND deleted_at IS NULL", businessID).First(&pr, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError{status: http.StatusNotFound, message: "requisition not found"}
+		}
+		return nil, err
+	}
+	return &pr, nil
+}
+
+// loadPurchaseOrder loads the purchase order in the request within the business
+func loadPurchaseOrder(db *gorm.DB, r *http.Request, businessID uuid.UUID) (*models.PurchaseOrder, error) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		return nil, apiError{status: http.StatusBadRequest, message: "invalid purchase order id"}
+	}
+	var po models.PurchaseOrder
+	if err := db.Where("business_vertical_id = ? AND deleted_at IS NULL", businessID).First(&po, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError{status: http.StatusNotFound, message: "purchase order not found"}
+		}
+		return nil, err
+	}
+	return &po, nil
+}
+
+// loadVendorInvoice loads the vendor invoice in the request within the business
+func loadVendorInvoice(db *gorm.DB, r *http.Request, businessID uuid.UUID) (*models.VendorInvoice, error) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		return nil, apiError{status: http.StatusBadRequest, message: "invalid invoice id"}
+	}
+	var invoice models.VendorInvoice
+	if err := db.Where("business_vertical_id = ?", businessID).First(&invoice, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError{status: http.StatusNotFound, message: "invoice not found"}
+		}
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+func requisitionRecord(pr *models.PurchaseRequisition) procurementRecord {
+	return procurementRecord{
+		kind: "purchase_requisition", table: "purchase_requisitions", permission: "purchase:create",
+		id: pr.ID, businessID: pr.BusinessVerticalID, workflowID: pr.WorkflowID,
+		state: pr.CurrentState, createdBy: pr.CreatedBy, title: "Requisition " + pr.RequisitionNumber,
+		limitType: models.ApprovalLimitPurchase, amount: pr.EstimatedValue(),
+	}
+}
+
+func purchaseOrderRecord(po *models.PurchaseOrder) procurementRecord {
+	return procurementRecord{
+		kind: "purchase_order", table: "purchase_orders", permission: "purchase:create",
+		id: po.ID, businessID: po.BusinessVerticalID, workflowID: po.WorkflowID,
+		state: po.CurrentState, createdBy: po.CreatedBy, title: "Purchase order " + po.OrderNumber,
+		limitType: models.ApprovalLimitPurchase, amount: models.ToBase(po.TotalAmount, po.ExchangeRate),
+	}
+}
+
+func vendorInvoiceRecord(invoice *models.VendorInvoice) procurementRecord {
+	return procurementRecord{
+		kind: "vendor_invoice", table: "vendor_invoices", permission: "finance:create",
+		id: invoice.ID, businessID: invoice.BusinessVerticalID, workflowID: invoice.WorkflowID,
+		state: invoice.CurrentState, createdBy: invoice.CreatedBy, title: "Invoice " + invoice.InvoiceNumber,
+		limitType: models.ApprovalLimitInvoice, amount: models.ToBase(invoice.TotalAmount, invoice.ExchangeRate),
+	}
+}
+
+// writeProcurementDocument writes a document with its workflow history and the actions
+// the user may take on it
+func writeProcurementDocument(w http.ResponseWriter, r *http.Request, rec procurementRecord, item interface{}, extra map[string]interface{}) {
+	history, err := procurementHistory(rec.id)
+	if err != nil {
+		http.Error(w, "failed to fetch workflow history", http.StatusInternalServerError)
+		return
+	}
+	response := map[string]interface{}{
+		"item":              item,
+		"history":           history,
+		"available_actions": procurementActions(r, rec),
+	}
+	for key, value := range extra {
+		response[key] = value
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// procurementActionRequest is the body of workflow action requests
+type procurementActionRequest struct {
+	Comment string `json:"comment"`
+}
+
+// ==========================
+// Purchase requisition handlers
+// ==========================
+
+// requisitionRequest is the body of requisition create and update requests
+type requisitionRequest struct {
+	SiteID            uuid.UUID  `json:"site_id"`
+	ProjectID         *uuid.UUID `json:"project_id"`
+	RequisitionNumber string     `json:"requisition_number"`
+	Purpose           string     `json:"purpose"`
+	RequiredBy        *time.Time `json:"required_by"`
+	Priority          string     `json:"priority"`
+	Lines             []struct {
+		MaterialCode  string  `json:"material_code"`
+		Description   string  `json:"description"`
+		UOM           string  `json:"uom"`
+		Quantity      float64 `json:"quantity"`
+		EstimatedRate float64 `json:"estimated_rate"`
+		Remarks       string  `json:"remarks"`
+	} `json:"lines"`
+}
+
+// requisitionLines validates a request's lines
+func (req requisitionRequest) requisitionLines() ([]models.PurchaseRequisitionLine, error) {
+	if len(req.Lines) == 0 {
+		return nil, apiError{status: http.StatusBadRequest, message: "a requisition needs at least one line"}
+	}
+	lines := make([]models.PurchaseRequisitionLine, 0, len(req.Lines))
+	for _, l := range req.Lines {
+		code := strings.TrimSpace(l.MaterialCode)
+		if code == "" || strings.TrimSpace(l.Description) == "" || strings.TrimSpace(l.UOM) == "" || l.Quantity <= 0 || l.EstimatedRate < 0 {
+			return nil, apiError{status: http.StatusBadRequest, message: "each line needs a material_code, description, uom and positive quantity"}
+		}
+		lines = append(lines, models.PurchaseRequisitionLine{
+			MaterialCode:  code,
+			Description:   strings.TrimSpace(l.Description),
+			UOM:           strings.TrimSpace(l.UOM),
+			Quantity:      l.Quantity,
+			EstimatedRate: l.EstimatedRate,
+			Remarks:       l.Remarks,
+		})
+	}
+	return lines, nil
+}
+
+// ListPurchaseRequisitions lists the business's requisitions. ?site_id=, ?project_id= and
+// ?state= narrow them.
+// GET /api/v1/business/{businessCode}/purchase-requisitions
+func ListPurchaseRequisitions(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load requisitions")
+		return
+	}
+
+	query := middleware.ScopedDB(r).Preload("Site").Where("business_vertical_id = ? AND deleted_at IS NULL", businessID)
+	q := r.URL.Query()
+	if v := q.Get("site_id"); v != "" {
+		query = query.Where("site_id = ?", v)
+	}
+	if v := q.Get("project_id"); v != "" {
+		query = query.Where("project_id = ?", v)
+	}
+	if v := q.Get("state"); v != "" {
+		query = query.Where("current_state = ?", v)
+	}
+	var items []models.PurchaseRequisition
+	if err := query.Order("created_at DESC").Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch requisitions", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// CreatePurchaseRequisition raises a draft requisition for materials at a site
+// POST /api/v1/business/{businessCode}/purchase-requisitions
+func CreatePurchaseRequisition(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to create requisition")
+		return
+	}
+
+	var req requisitionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.RequisitionNumber = strings.TrimSpace(req.RequisitionNumber)
+	if req.SiteID == uuid.Nil || req.RequisitionNumber == "" {
+		http.Error(w, "site_id and requisition_number are required", http.StatusBadRequest)
+		return
+	}
+	lines, err := req.requisitionLines()
+	if err != nil {
+		writeProcurementErr(w, err, "failed to create requisition")
+		return
+	}
+
+	var count int64
+	middleware.ScopedDB(r).Model(&models.Site{}).Where("id = ? AND business_vertical_id = ?", req.SiteID, businessID).Count(&count)
+	if count == 0 {
+		http.Error(w, "site not found in this business", http.StatusBadRequest)
+		return
+	}
+	middleware.ScopedDB(r).Model(&models.PurchaseRequisition{}).
+		Where("business_vertical_id = ? AND requisition_number = ? AND deleted_at IS NULL", businessID, req.RequisitionNumber).Count(&count)
+	if count > 0 {
+		http.Error(w, "requisition_number is already used", http.StatusConflict)
+		return
+	}
+	workflowID, err := procurementWorkflowID(middleware.ScopedDB(r))
+	if err != nil {
+		writeProcurementErr(w, err, "failed to create requisition")
+		return
+	}
+
+	pr := models.PurchaseRequisition{
+		BusinessVerticalID: businessID,
+		SiteID:             req.SiteID,
+		ProjectID:          req.ProjectID,
+		RequisitionNumber:  req.RequisitionNumber,
+		Purpose:            req.Purpose,
+		RequiredBy:         req.RequiredBy,
+		Priority:           strings.TrimSpace(req.Priority),
+		WorkflowID:         workflowID,
+		CurrentState:       models.ProcurementDraft,
+		CreatedBy:          middleware.GetClaims(r).UserID,
+		Lines:              lines,
+	}
+	if pr.Priority == "" {
+		pr.Priority = "normal"
+	}
+	if err := middleware.ScopedDB(r).Create(&pr).Error; err != nil {
+		http.Error(w, "failed to create requisition", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "requisition created", "item": pr})
+}
+
+// GetPurchaseRequisition returns a requisition with its lines, the purchase orders raised
+// from it and its workflow history
+// GET /api/v1/business/{businessCode}/purchase-requisitions/{id}
+func GetPurchaseRequisition(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load requisition")
+		return
+	}
+	pr, err := loadRequisition(middleware.ScopedDB(r).Preload("Site").Preload("Lines", func(db *gorm.DB) *gorm.DB {
+		return db.Order("material_code")
+	}), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load requisition")
+		return
+	}
+
+	var orders []models.PurchaseOrder
+	if err := middleware.ScopedDB(r).Where("requisition_id = ? AND deleted_at IS NULL", pr.ID).Order("created_at").Find(&orders).Error; err != nil {
+		http.Error(w, "failed to load purchase orders", http.StatusInternalServerError)
+		return
+	}
+
+	writeProcurementDocument(w, r, requisitionRecord(pr), pr, map[string]interface{}{"purchase_orders": orders})
+}
+
+// UpdatePurchaseRequisition replaces a draft requisition's details and lines
+// PUT /api/v1/business/{businessCode}/purchase-requisitions/{id}
+func UpdatePurchaseRequisition(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update requisition")
+		return
+	}
+	pr, err := loadRequisition(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load requisition")
+		return
+	}
+
+	var req requisitionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	lines, err := req.requisitionLines()
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update requisition")
+		return
+	}
+
+	updates := map[string]interface{}{
+		"purpose":     req.Purpose,
+		"required_by": req.RequiredBy,
+		"updated_by":  middleware.GetClaims(r).UserID,
+	}
+	if p := strings.TrimSpace(req.Priority); p != "" {
+		updates["priority"] = p
+	}
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.PurchaseRequisition{}).
+			Where("id = ? AND current_state = ?", pr.ID, models.ProcurementDraft).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return apiError{status: http.StatusConflict, message: "only a draft requisition can be edited"}
+		}
+		if err := tx.Where("requisition_id = ?", pr.ID).Delete(&models.PurchaseRequisitionLine{}).Error; err != nil {
+			return err
+		}
+		for i := range lines {
+			lines[i].RequisitionID = pr.ID
+		}
+		return tx.Create(&lines).Error
+	})
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update requisition")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "requisition updated", "lines": lines})
+}
+
+// TakePurchaseRequisitionAction takes a workflow action (submit, l1_approve, l2_approve,
+// reject or revise) on a requisition
+// POST /api/v1/business/{businessCode}/purchase-requisitions/{id}/actions/{action}
+func TakePurchaseRequisitionAction(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update requisition")
+		return
+	}
+	pr, err := loadRequisition(middleware.ScopedDB(r).Preload("Lines"), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load requisition")
+		return
+	}
+	var req procurementActionRequest
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	transition, err := takeProcurementAction(r, requisitionRecord(pr), mux.Vars(r)["action"], req.Comment, nil)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update requisition")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "requisition " + transition.ToState, "transition": transition})
+}
+
+// ==========================
+// Purchase order handlers
+// ==========================
+
+// purchaseOrderVendorRequest carries a purchase order's vendor, delivery, terms and the
+// cost center its invoices are booked to
+type purchaseOrderVendorRequest struct {
+	VendorID        uuid.UUID  `json:"vendor_id"`
+	CostCenterID    *uuid.UUID `json:"cost_center_id"`
+	DeliveryAddress string     `json:"delivery_address"`
+	DeliveryDate    *time.Time `json:"delivery_date"`
+	PaymentTerms    string     `json:"payment_terms"`
+	Terms           string     `json:"terms"`
+}
+
+// apply puts the vendor, delivery, terms and cost center on the order. The vendor's
+// details are copied as they stand, and its usual payment terms apply unless the request
+// gives others. Without a cost center the order takes its project's.
+func (req purchaseOrderVendorRequest) apply(po *models.PurchaseOrder, vendor *models.Vendor) {
+	po.VendorID = &vendor.ID
+	po.VendorName = vendor.Name
+	po.VendorGSTIN = vendor.GSTIN
+	po.VendorAddress = vendor.Address
+	po.VendorContact = vendor.ContactName
+	po.VendorPhone = vendor.Phone
+	po.VendorEmail = vendor.Email
+	po.DeliveryAddress = req.DeliveryAddress
+	po.DeliveryDate = req.DeliveryDate
+	po.PaymentTerms = req.PaymentTerms
+	if strings.TrimSpace(po.PaymentTerms) == "" {
+		po.PaymentTerms = vendor.PaymentTerms
+	}
+	po.Terms = req.Terms
+	po.CostCenterID = req.CostCenterID
+	if po.CostCenterID == nil {
+		po.CostCenterID = workCostCenter(config.DB, po.ProjectID, nil)
+	}
+}
+
+// loadOrderVendor loads an active vendor of the business that may supply the site
+func loadOrderVendor(businessID, vendorID, siteID uuid.UUID) (*models.Vendor, error) {
+	if vendorID == uuid.Nil {
+		return nil, apiError{status: http.StatusBadRequest, message: "vendor_id is required"}
+	}
+	var vendor models.Vendor
+	if err := config.DB.Where("business_vertical_id = ? AND deleted_at IS NULL", businessID).
+		First(&vendor, "id = ?", vendorID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError{status: http.StatusBadRequest, message: "vendor not found in this business"}
+		}
+		return nil, err
+	}
+	if !vendor.IsActive {
+		return nil, apiError{status: http.StatusConflict, message: fmt.Sprintf("vendor %s is inactive", vendor.Code)}
+	}
+	if !vendor.AppliesToSite(siteID) {
+		return nil, apiError{status: http.StatusConflict, message: fmt.Sprintf("vendor %s does not supply this site", vendor.Code)}
+	}
+	return &vendor, nil
+}
+
+// ConvertRequisitionToPurchaseOrder raises a draft purchase order on a vendor for some or
+// all of an approved requisition's outstanding quantities, at the vendor's rates
+// POST /api/v1/business/{businessCode}/purchase-requisitions/{id}/purchase-orders
+func ConvertRequisitionToPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to create purchase order")
+		return
+	}
+	pr, err := loadRequisition(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load requisition")
+		return
+	}
+
+	var req struct {
+		purchaseOrderVendorRequest
+		OrderNumber string     `json:"order_number"`
+		OrderDate   *time.Time `json:"order_date"`
+		Currency    string     `json:"currency"`
+		Lines       []struct {
+			RequisitionLineID uuid.UUID `json:"requisition_line_id"`
+			Quantity          float64   `json:"quantity"`
+			Rate              float64   `json:"rate"`
+			TaxPercent        float64   `json:"tax_percent"`
+		} `json:"lines"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.OrderNumber = strings.TrimSpace(req.OrderNumber)
+	if req.OrderNumber == "" {
+		http.Error(w, "order_number is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Lines) == 0 {
+		http.Error(w, "a purchase order needs at least one line", http.StatusBadRequest)
+		return
+	}
+	vendor, err := loadOrderVendor(businessID, req.VendorID, pr.SiteID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load vendor")
+		return
+	}
+	if err := checkCostCenter(middleware.ScopedDB(r), businessID, req.CostCenterID); err != nil {
+		writeProcurementErr(w, err, "failed to create purchase order")
+		return
+	}
+
+	var count int64
+	middleware.ScopedDB(r).Model(&models.PurchaseOrder{}).
+		Where("business_vertical_id = ? AND order_number = ? AND deleted_at IS NULL", businessID, req.OrderNumber).Count(&count)
+	if count > 0 {
+		http.Error(w, "order_number is already used", http.StatusConflict)
+		return
+	}
+	workflowID, err := procurementWorkflowID(middleware.ScopedDB(r))
+	if err != nil {
+		writeProcurementErr(w, err, "failed to create purchase order")
+		return
+	}
+
+	po := models.PurchaseOrder{
+		BusinessVerticalID: businessID,
+		SiteID:             pr.SiteID,
+		RequisitionID:      &pr.ID,
+		ProjectID:          pr.ProjectID,
+		OrderNumber:        req.OrderNumber,
+		OrderDate:          time.Now().UTC(),
+		WorkflowID:         workflowID,
+		CurrentState:       models.ProcurementDraft,
+		CreatedBy:          middleware.GetClaims(r).UserID,
+	}
+	if req.OrderDate != nil {
+		po.OrderDate = *req.OrderDate
+	}
+	if po.Currency, po.ExchangeRate, err = transactionCurrency(middleware.ScopedDB(r), req.Currency, po.OrderDate); err != nil {
+		writeProcurementErr(w, err, "failed to create purchase order")
+		return
+	}
+	req.apply(&po, vendor)
+
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		// Lock the requisition so concurrent orders cannot take the same quantity twice
+		var locked models.PurchaseRequisition
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, "id = ?", pr.ID).Error; err != nil {
+			return err
+		}
+		if locked.CurrentState != models.ProcurementApproved {
+			return apiError{status: http.StatusConflict, message: "only an approved requisition can be ordered"}
+		}
+		var prLines []models.PurchaseRequisitionLine
+		if err := tx.Where("requisition_id = ?", pr.ID).Find(&prLines).Error; err != nil {
+			return err
+		}
+		byID := make(map[uuid.UUID]*models.PurchaseRequisitionLine, len(prLines))
+		for i := range prLines {
+			byID[prLines[i].ID] = &prLines[i]
+		}
+
+		for _, l := range req.Lines {
+			prLine, ok := byID[l.RequisitionLineID]
+			if !ok {
+				return apiError{status: http.StatusBadRequest, message: fmt.Sprintf("line %s is not on the requisition", l.RequisitionLineID)}
+			}
+			if l.Quantity <= 0 || l.Rate < 0 || l.TaxPercent < 0 {
+				return apiError{status: http.StatusBadRequest, message: "each line needs a positive quantity and a rate"}
+			}
+			if l.Quantity > prLine.RemainingQuantity()+1e-9 {
+				return apiError{status: http.StatusConflict, message: fmt.Sprintf("%s: %.4f %s exceeds the %.4f still to be ordered",
+					prLine.MaterialCode, l.Quantity, prLine.UOM, prLine.RemainingQuantity())}
+			}
+			prLine.OrderedQuantity += l.Quantity
+
+			lineID := prLine.ID
+			line := models.PurchaseOrderLine{
+				RequisitionLineID: &lineID,
+				MaterialCode:      prLine.MaterialCode,
+				Description:       prLine.Description,
+				UOM:               prLine.UOM,
+				Quantity:          l.Quantity,
+				Rate:              l.Rate,
+				TaxPercent:        l.TaxPercent,
+			}
+			line.Price()
+			po.Lines = append(po.Lines, line)
+		}
+		po.Total()
+
+		if err := tx.Create(&po).Error; err != nil {
+			return err
+		}
+		for _, l := range req.Lines {
+			if err := tx.Model(&models.PurchaseRequisitionLine{}).Where("id = ?", l.RequisitionLineID).
+				Update("ordered_quantity", gorm.Expr("ordered_quantity + ?", l.Quantity)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		writeProcurementErr(w, err, "failed to create purchase order")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "purchase order created", "item": po})
+}
+
+// ListPurchaseOrders lists the business's purchase orders. ?site_id=, ?requisition_id=,
+// ?vendor_id=, ?vendor= (part of its name) and ?state= narrow them.
+// GET /api/v1/business/{businessCode}/purchase-orders
+func ListPurchaseOrders(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load purchase orders")
+		return
+	}
+
+	query := middleware.ScopedDB(r).Where("business_vertical_id = ? AND deleted_at IS NULL", businessID)
+	q := r.URL.Query()
+	if v := q.Get("site_id"); v != "" {
+		query = query.Where("site_id = ?", v)
+	}
+	if v := q.Get("requisition_id"); v != "" {
+		query = query.Where("requisition_id = ?", v)
+	}
+	if v := q.Get("vendor_id"); v != "" {
+		query = query.Where("vendor_id = ?", v)
+	}
+	if v := strings.TrimSpace(q.Get("vendor")); v != "" {
+		query = query.Where("vendor_name ILIKE ?", "%"+v+"%")
+	}
+	if v := q.Get("state"); v != "" {
+		query = query.Where("current_state = ?", v)
+	}
+	var items []models.PurchaseOrder
+	if err := query.Order("created_at DESC").Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch purchase orders", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// GetPurchaseOrder returns a purchase order with its lines, GRNs, invoices and workflow
+// history
+// GET /api/v1/business/{businessCode}/purchase-orders/{id}
+func GetPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load purchase order")
+		return
+	}
+	po, err := loadPurchaseOrder(middleware.ScopedDB(r).Preload("Site").Preload("Lines", func(db *gorm.DB) *gorm.DB {
+		return db.Order("material_code")
+	}), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load purchase order")
+		return
+	}
+
+	var grns []models.GoodsReceiptNote
+	if err := middleware.ScopedDB(r).Where("purchase_order_id = ?", po.ID).Order("received_date, created_at").Find(&grns).Error; err != nil {
+		http.Error(w, "failed to load GRNs", http.StatusInternalServerError)
+		return
+	}
+	var invoices []models.VendorInvoice
+	if err := middleware.ScopedDB(r).Where("purchase_order_id = ?", po.ID).Order("invoice_date, created_at").Find(&invoices).Error; err != nil {
+		http.Error(w, "failed to load invoices", http.StatusInternalServerError)
+		return
+	}
+
+	writeProcurementDocument(w, r, purchaseOrderRecord(po), po, map[string]interface{}{"grns": grns, "invoices": invoices})
+}
+
+// UpdatePurchaseOrder changes a draft purchase order's vendor, delivery, terms and cost
+// center, and its lines' rates and tax
+// PUT /api/v1/business/{businessCode}/purchase-orders/{id}
+func UpdatePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update purchase order")
+		return
+	}
+	po, err := loadPurchaseOrder(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load purchase order")
+		return
+	}
+
+	var req struct {
+		purchaseOrderVendorRequest
+		Lines []struct {
+			ID         uuid.UUID `json:"id"`
+			Rate       float64   `json:"rate"`
+			TaxPercent float64   `json:"tax_percent"`
+		} `json:"lines"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	vendor, err := loadOrderVendor(businessID, req.VendorID, po.SiteID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load vendor")
+		return
+	}
+	if err := checkCostCenter(middleware.ScopedDB(r), businessID, req.CostCenterID); err != nil {
+		writeProcurementErr(w, err, "failed to update purchase order")
+		return
+	}
+
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		var locked models.PurchaseOrder
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Lines").First(&locked, "id = ?", po.ID).Error; err != nil {
+			return err
+		}
+		if locked.CurrentState != models.ProcurementDraft {
+			return apiError{status: http.StatusConflict, message: "only a draft purchase order can be edited"}
+		}
+		req.apply(&locked, vendor)
+		locked.UpdatedBy = middleware.GetClaims(r).UserID
+
+		byID := make(map[uuid.UUID]int, len(locked.Lines))
+		for i, line := range locked.Lines {
+			byID[line.ID] = i
+		}
+		for _, l := range req.Lines {
+			i, ok := byID[l.ID]
+			if !ok {
+				return apiError{status: http.StatusBadRequest, message: fmt.Sprintf("line %s is not on the purchase order", l.ID)}
+			}
+			if l.Rate < 0 || l.TaxPercent < 0 {
+				return apiError{status: http.StatusBadRequest, message: "rate and tax_percent must not be negative"}
+			}
+			locked.Lines[i].Rate = l.Rate
+			locked.Lines[i].TaxPercent = l.TaxPercent
+			locked.Lines[i].Price()
+			if err := tx.Model(&locked.Lines[i]).Select("rate", "tax_percent", "amount", "tax_amount").
+				Updates(&locked.Lines[i]).Error; err != nil {
+				return err
+			}
+		}
+		locked.Total()
+		*po = locked
+		return tx.Omit(clause.Associations).Save(&locked).Error
+	})
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update purchase order")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "purchase order updated", "item": po})
+}
+
+// TakePurchaseOrderAction takes a workflow action (submit, l1_approve, l2_approve, reject
+// or revise) on a purchase order
+// POST /api/v1/business/{businessCode}/purchase-orders/{id}/actions/{action}
+func TakePurchaseOrderAction(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update purchase order")
+		return
+	}
+	po, err := loadPurchaseOrder(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load purchase order")
+		return
+	}
+	var req procurementActionRequest
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	transition, err := takeProcurementAction(r, purchaseOrderRecord(po), mux.Vars(r)["action"], req.Comment, nil)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update purchase order")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "purchase order " + transition.ToState, "transition": transition})
+}
+
+// DownloadPurchaseOrderPDF renders a purchase order as a PDF. Orders not yet fully
+// approved are marked as drafts.
+// GET /api/v1/business/{businessCode}/purchase-orders/{id}/pdf
+func DownloadPurchaseOrderPDF(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load purchase order")
+		return
+	}
+	po, err := loadPurchaseOrder(middleware.ScopedDB(r).Preload("Site").Preload("Requisition").Preload("Lines", func(db *gorm.DB) *gorm.DB {
+		return db.Order("material_code")
+	}), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load purchase order")
+		return
+	}
+	var business models.BusinessVertical
+	if err := middleware.ScopedDB(r).First(&business, "id = ?", businessID).Error; err != nil {
+		http.Error(w, "failed to load business", http.StatusInternalServerError)
+		return
+	}
+
+	pdf := renderPurchaseOrderPDF(po, business.Name)
+	filename := strings.NewReplacer("/", "-", "\\", "-", `"`, "").Replace(po.OrderNumber) + ".pdf"
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(pdf)
+}
+
+// ==========================
+// Goods receipt handlers
+// ==========================
+
+// CreateGoodsReceiptNote records materials received against an approved purchase order
+// and posts the accepted quantities into the site's stock ledger
+// POST /api/v1/business/{businessCode}/purchase-orders/{id}/grns
+func CreateGoodsReceiptNote(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to record GRN")
+		return
+	}
+	po, err := loadPurchaseOrder(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load purchase order")
+		return
+	}
+
+	var req struct {
+		GRNNumber     string          `json:"grn_number"`
+		ReceivedDate  *time.Time      `json:"received_date"`
+		ChallanNumber string          `json:"challan_number"`
+		VehicleNumber string          `json:"vehicle_number"`
+		Remarks       string          `json:"remarks"`
+		Lines         models.GRNLines `json:"lines"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.GRNNumber = strings.TrimSpace(req.GRNNumber)
+	if req.GRNNumber == "" {
+		http.Error(w, "grn_number is required", http.StatusBadRequest)
+		return
+	}
+	var count int64
+	middleware.ScopedDB(r).Model(&models.GoodsReceiptNote{}).
+		Where("business_vertical_id = ? AND grn_number = ?", businessID, req.GRNNumber).Count(&count)
+	if count > 0 {
+		http.Error(w, "grn_number is already used", http.StatusConflict)
+		return
+	}
+
+	userID := middleware.GetClaims(r).UserID
+	grn := models.GoodsReceiptNote{
+		BusinessVerticalID: businessID,
+		PurchaseOrderID:    po.ID,
+		SiteID:             po.SiteID,
+		VendorID:           po.VendorID,
+		GRNNumber:          req.GRNNumber,
+		ReceivedDate:       time.Now().UTC(),
+		ChallanNumber:      strings.TrimSpace(req.ChallanNumber),
+		VehicleNumber:      strings.TrimSpace(req.VehicleNumber),
+		Remarks:            req.Remarks,
+		ReceivedBy:         userID,
+	}
+	if req.ReceivedDate != nil {
+		grn.ReceivedDate = *req.ReceivedDate
+	}
+
+	var entries []models.StockLedgerEntry
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		// Lock the order so concurrent receipts cannot both take its last quantities
+		var locked models.PurchaseOrder
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, "id = ?", po.ID).Error; err != nil {
+			return err
+		}
+		if locked.CurrentState != models.ProcurementApproved {
+			return apiError{status: http.StatusConflict, message: "goods can only be received against an approved purchase order"}
+		}
+		var orderLines []models.PurchaseOrderLine
+		if err := tx.Where("purchase_order_id = ?", po.ID).Find(&orderLines).Error; err != nil {
+			return err
+		}
+		lines, value, err := models.ReceiveLines(orderLines, req.Lines)
+		if err != nil {
+			return apiError{status: http.StatusBadRequest, message: err.Error()}
+		}
+		grn.Lines = lines
+		grn.AcceptedValue = value
+		if err := tx.Create(&grn).Error; err != nil {
+			return err
+		}
+
+		for _, line := range lines {
+			if line.AcceptedQuantity == 0 {
+				continue
+			}
+			if err := tx.Model(&models.PurchaseOrderLine{}).Where("id = ?", line.PurchaseOrderLineID).
+				Update("received_quantity", gorm.Expr("received_quantity + ?", line.AcceptedQuantity)).Error; err != nil {
+				return err
+			}
+			entries = append(entries, models.StockLedgerEntry{
+				BusinessVerticalID: businessID,
+				SiteID:             grn.SiteID,
+				MaterialCode:       line.MaterialCode,
+				Description:        line.Description,
+				UOM:                line.UOM,
+				EntryDate:          grn.ReceivedDate,
+				QuantityIn:         line.AcceptedQuantity,
+				Rate:               line.Rate,
+				Value:              math.Round(line.AcceptedQuantity*line.Rate*100) / 100,
+				ReferenceType:      models.StockReferenceGRN,
+				ReferenceID:        grn.ID,
+				ReferenceNumber:    grn.GRNNumber,
+				CreatedBy:          userID,
+			})
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		return tx.Create(&entries).Error
+	})
+	if err != nil {
+		writeProcurementErr(w, err, "failed to record GRN")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "GRN recorded", "item": grn, "stock_entries": entries})
+}
+
+// ListGoodsReceiptNotes lists the business's GRNs. ?purchase_order_id=, ?vendor_id= and
+// ?site_id= narrow them.
+// GET /api/v1/business/{businessCode}/grns
+func ListGoodsReceiptNotes(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load GRNs")
+		return
+	}
+
+	query := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID)
+	q := r.URL.Query()
+	if v := q.Get("purchase_order_id"); v != "" {
+		query = query.Where("purchase_order_id = ?", v)
+	}
+	if v := q.Get("vendor_id"); v != "" {
+		query = query.Where("vendor_id = ?", v)
+	}
+	if v := q.Get("site_id"); v != "" {
+		query = query.Where("site_id = ?", v)
+	}
+	var items []models.GoodsReceiptNote
+	if err := query.Order("received_date DESC, created_at DESC").Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch GRNs", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// ListStockLedger lists stock movements, newest first. ?site_id=, ?material_code=,
+// ?project_id=, ?from= and ?to= (YYYY-MM-DD) narrow them.
+// GET /api/v1/business/{businessCode}/stock-ledger
+func ListStockLedger(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load stock ledger")
+		return
+	}
+
+	query := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID)
+	q := r.URL.Query()
+	if v := q.Get("site_id"); v != "" {
+		query = query.Where("site_id = ?", v)
+	}
+	if v := q.Get("material_code"); v != "" {
+		query = query.Where("material_code = ?", v)
+	}
+	if v := q.Get("project_id"); v != "" {
+		query = query.Where("project_id = ?", v)
+	}
+	for param, cond := range map[string]string{"from": "entry_date >= ?", "to": "entry_date <= ?"} {
+		if v := q.Get(param); v != "" {
+			day, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				http.Error(w, param+" must be YYYY-MM-DD", http.StatusBadRequest)
+				return
+			}
+			query = query.Where(cond, day)
+		}
+	}
+	var items []models.StockLedgerEntry
+	if err := query.Order("entry_date DESC, created_at DESC").Limit(1000).Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch stock ledger", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// stockBalance is what the ledger holds of a material at a site
+type stockBalance struct {
+	SiteID       uuid.UUID `json:"site_id"`
+	MaterialCode string    `json:"material_code"`
+	UOM          string    `json:"uom"`
+	QuantityIn   float64   `json:"quantity_in"`
+	QuantityOut  float64   `json:"quantity_out"`
+	Balance      float64   `json:"balance"`
+	ValueIn      float64   `json:"value_in"`
+}
+
+// GetStockBalances sums the stock ledger into each site's balance of each material.
+// ?site_id= narrows it to one site.
+// GET /api/v1/business/{businessCode}/stock-ledger/balances
+func GetStockBalances(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load stock balances")
+		return
+	}
+
+	query := middleware.ScopedDB(r).Model(&models.StockLedgerEntry{}).
+		Select(`site_id, material_code, uom,
+			SUM(quantity_in) AS quantity_in, SUM(quantity_out) AS quantity_out,
+			SUM(quantity_in - quantity_out) AS balance, SUM(CASE WHEN quantity_in > 0 THEN value ELSE 0 END) AS value_in`).
+		Where("business_vertical_id = ?", businessID)
+	if v := r.URL.Query().Get("site_id"); v != "" {
+		query = query.Where("site_id = ?", v)
+	}
+	var items []stockBalance
+	if err := query.Group("site_id, material_code, uom").Order("site_id, material_code").Scan(&items).Error; err != nil {
+		http.Error(w, "failed to compute stock balances", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// IssueStock issues materials from a site's stock to a project's work, and to one of its
+// tasks if given, at the site's average receipt rate. Nothing is issued beyond the
+// site's balance.
+// POST /api/v1/business/{businessCode}/stock-ledger/issues
+func IssueStock(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to issue stock")
+		return
+	}
+
+	var req struct {
+		SiteID          uuid.UUID  `json:"site_id"`
+		ProjectID       uuid.UUID  `json:"project_id"`
+		TaskID          *uuid.UUID `json:"task_id"`
+		EntryDate       *time.Time `json:"entry_date"`
+		ReferenceNumber string     `json:"reference_number"`
+		Lines           []struct {
+			MaterialCode string  `json:"material_code"`
+			Quantity     float64 `json:"quantity"`
+		} `json:"lines"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.ReferenceNumber = strings.TrimSpace(req.ReferenceNumber)
+	if req.SiteID == uuid.Nil || req.ProjectID == uuid.Nil || req.ReferenceNumber == "" || len(req.Lines) == 0 {
+		http.Error(w, "site_id, project_id, reference_number and lines are required", http.StatusBadRequest)
+		return
+	}
+
+	var count int64
+	middleware.ScopedDB(r).Model(&models.Site{}).Where("id = ? AND business_vertical_id = ?", req.SiteID, businessID).Count(&count)
+	if count == 0 {
+		http.Error(w, "site not found in this business", http.StatusBadRequest)
+		return
+	}
+	middleware.ScopedDB(r).Model(&models.Project{}).Where("id = ? AND business_vertical_id = ?", req.ProjectID, businessID).Count(&count)
+	if count == 0 {
+		http.Error(w, "project not found in this business", http.StatusBadRequest)
+		return
+	}
+	if req.TaskID != nil {
+		middleware.ScopedDB(r).Model(&models.Tasks{}).Where("id = ? AND project_id = ? AND deleted_at IS NULL", *req.TaskID, req.ProjectID).Count(&count)
+		if count == 0 {
+			http.Error(w, "task not found in this project", http.StatusBadRequest)
+			return
+		}
+	}
+
+	userID := middleware.GetClaims(r).UserID
+	issueID := uuid.New()
+	entryDate := time.Now().UTC()
+	if req.EntryDate != nil {
+		entryDate = *req.EntryDate
+	}
+	var entries []models.StockLedgerEntry
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		for _, l := range req.Lines {
+			code := strings.TrimSpace(l.MaterialCode)
+			if code == "" || l.Quantity <= 0 {
+				return apiError{status: http.StatusBadRequest, message: "each line needs a material_code and a positive quantity"}
+			}
+			// Lock the material's movements at the site so concurrent issues cannot overdraw it
+			var movements []models.StockLedgerEntry
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("business_vertical_id = ? AND site_id = ? AND material_code = ?", businessID, req.SiteID, code).
+				Find(&movements).Error; err != nil {
+				return err
+			}
+			var in, out, valueIn float64
+			var last models.StockLedgerEntry
+			for _, m := range movements {
+				in += m.QuantityIn
+				out += m.QuantityOut
+				if m.QuantityIn > 0 {
+					valueIn += m.Value
+					last = m
+				}
+			}
+			balance := math.Round((in-out)*10000) / 10000
+			if l.Quantity > balance+1e-9 {
+				return apiError{status: http.StatusConflict, message: fmt.Sprintf("%s: %.4f exceeds the %.4f in stock at the site", code, l.Quantity, balance)}
+			}
+			rate := math.Round(valueIn/in*100) / 100
+			projectID := req.ProjectID
+			entries = append(entries, models.StockLedgerEntry{
+				BusinessVerticalID: businessID,
+				SiteID:             req.SiteID,
+				MaterialCode:       code,
+				ProjectID:          &projectID,
+				TaskID:             req.TaskID,
+				Description:        last.Description,
+				UOM:                last.UOM,
+				EntryDate:          entryDate,
+				QuantityOut:        l.Quantity,
+				Rate:               rate,
+				Value:              math.Round(l.Quantity*rate*100) / 100,
+				ReferenceType:      models.StockReferenceIssue,
+				ReferenceID:        issueID,
+				ReferenceNumber:    req.ReferenceNumber,
+				CreatedBy:          userID,
+			})
+		}
+		return tx.Create(&entries).Error
+	})
+	if err != nil {
+		writeProcurementErr(w, err, "failed to issue stock")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "stock issued", "items": entries})
+}
+
+// ==========================
+// Vendor invoice handlers
+// ==========================
+
+// matchVendorInvoice three-way matches the invoice's lines against the order's lines as
+// they stand, within the business's tolerance, and records the result on it. Exceptions
+// finance accepted stay accepted while they are unchanged, and a disputed invoice stays
+// disputed until it matches.
+func matchVendorInvoice(tx *gorm.DB, invoice *models.VendorInvoice, orderLines []models.PurchaseOrderLine) error {
+	tolerance, err := invoiceMatchTolerance(tx, invoice.BusinessVerticalID)
+	if err != nil {
+		return err
+	}
+	lines, sub, tax, exceptions, err := models.MatchInvoice(orderLines, invoice.Lines, tolerance)
+	if err != nil {
+		return apiError{status: http.StatusBadRequest, message: err.Error()}
+	}
+	now := time.Now().UTC()
+	previous, accepted := invoice.MatchStatus, invoice.MatchExceptions
+	invoice.Lines = lines
+	invoice.SubTotal = sub
+	invoice.TaxAmount = tax
+	invoice.TotalAmount = math.Round((sub+tax)*100) / 100
+	invoice.MatchExceptions = models.StringArray(exceptions)
+	invoice.MatchStatus = models.InvoiceMatched
+	if len(exceptions) > 0 {
+		invoice.MatchStatus = models.InvoiceMismatch
+		if previous == models.InvoiceDisputed ||
+			previous == models.InvoiceExceptionAccepted && strings.Join(accepted, "\n") == strings.Join(exceptions, "\n") {
+			invoice.MatchStatus = previous
+		}
+	}
+	invoice.MatchedAt = &now
+	if invoice.ID == uuid.Nil {
+		return nil
+	}
+	return tx.Model(&models.VendorInvoice{}).Where("id = ?", invoice.ID).Updates(map[string]interface{}{
+		"lines":            invoice.Lines,
+		"sub_total":        invoice.SubTotal,
+		"tax_amount":       invoice.TaxAmount,
+		"total_amount":     invoice.TotalAmount,
+		"match_status":     invoice.MatchStatus,
+		"match_exceptions": invoice.MatchExceptions,
+		"matched_at":       invoice.MatchedAt,
+	}).Error
+}
+
+// fireInvoiceMatched tells plugins how a vendor invoice matched
+func fireInvoiceMatched(invoice *models.VendorInvoice, po *models.PurchaseOrder, userID string) {
+	hooks.FireInvoiceMatched(hooks.InvoiceMatchedEvent{
+		InvoiceID:          invoice.ID,
+		InvoiceNumber:      invoice.InvoiceNumber,
+		PurchaseOrderID:    po.ID,
+		OrderNumber:        po.OrderNumber,
+		BusinessVerticalID: invoice.BusinessVerticalID,
+		VendorName:         po.VendorName,
+		TotalAmount:        invoice.TotalAmount,
+		Status:             invoice.MatchStatus,
+		Exceptions:         invoice.MatchExceptions,
+		MatchedBy:          userID,
+		MatchedAt:          *invoice.MatchedAt,
+	})
+}
+
+// CreateVendorInvoice records a vendor's invoice against an approved purchase order and
+// three-way matches it against the order and its GRNs. It falls due on the due_date
+// given, or after the vendor's credit period.
+// POST /api/v1/business/{businessCode}/purchase-orders/{id}/invoices
+func CreateVendorInvoice(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to record invoice")
+		return
+	}
+	po, err := loadPurchaseOrder(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load purchase order")
+		return
+	}
+	if po.CurrentState != models.ProcurementApproved {
+		http.Error(w, "invoices can only be recorded against an approved purchase order", http.StatusConflict)
+		return
+	}
+
+	var req struct {
+		InvoiceNumber string              `json:"invoice_number"`
+		InvoiceDate   time.Time           `json:"invoice_date"`
+		DueDate       *time.Time          `json:"due_date"`
+		Remarks       string              `json:"remarks"`
+		Lines         models.InvoiceLines `json:"lines"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.InvoiceNumber = strings.TrimSpace(req.InvoiceNumber)
+	if req.InvoiceNumber == "" || req.InvoiceDate.IsZero() {
+		http.Error(w, "invoice_number and invoice_date are required", http.StatusBadRequest)
+		return
+	}
+
+	// A vendor's invoice number identifies one bill, whichever order it names
+	var count int64
+	duplicates := middleware.ScopedDB(r).Model(&models.VendorInvoice{}).
+		Joins("JOIN purchase_orders po ON po.id = vendor_invoices.purchase_order_id").
+		Where("vendor_invoices.business_vertical_id = ? AND vendor_invoices.invoice_number = ?", businessID, req.InvoiceNumber)
+	if po.VendorID != nil {
+		duplicates = duplicates.Where("po.vendor_id = ?", *po.VendorID)
+	} else {
+		duplicates = duplicates.Where("po.vendor_name = ?", po.VendorName)
+	}
+	duplicates.Count(&count)
+	if count > 0 {
+		http.Error(w, "this vendor's invoice is already recorded", http.StatusConflict)
+		return
+	}
+	workflowID, err := procurementWorkflowID(middleware.ScopedDB(r))
+	if err != nil {
+		writeProcurementErr(w, err, "failed to record invoice")
+		return
+	}
+
+	dueDate := req.DueDate
+	if dueDate == nil {
+		if dueDate, err = invoiceDueDate(middleware.ScopedDB(r), po, req.InvoiceDate); err != nil {
+			http.Error(w, "failed to work out the due date", http.StatusInternalServerError)
+			return
+		}
+	} else if dueDate.Before(req.InvoiceDate) {
+		http.Error(w, "due_date cannot be before the invoice date", http.StatusBadRequest)
+		return
+	}
+
+	// The invoice is billed in the order's currency, converted at the invoice date's rate
+	rate, err := exchangeRateOn(middleware.ScopedDB(r), po.Currency, req.InvoiceDate)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to record invoice")
+		return
+	}
+
+	userID := middleware.GetClaims(r).UserID
+	invoice := models.VendorInvoice{
+		BusinessVerticalID: businessID,
+		PurchaseOrderID:    po.ID,
+		InvoiceNumber:      req.InvoiceNumber,
+		InvoiceDate:        req.InvoiceDate,
+		Currency:           po.Currency,
+		ExchangeRate:       rate,
+		DueDate:            dueDate,
+		PaymentStatus:      models.InvoiceUnpaid,
+		Lines:              req.Lines,
+		Remarks:            req.Remarks,
+		WorkflowID:         workflowID,
+		CurrentState:       models.ProcurementDraft,
+		CreatedBy:          userID,
+	}
+	var orderLines []models.PurchaseOrderLine
+	if err := middleware.ScopedDB(r).Where("purchase_order_id = ?", po.ID).Find(&orderLines).Error; err != nil {
+		http.Error(w, "failed to load purchase order lines", http.StatusInternalServerError)
+		return
+	}
+	if err := matchVendorInvoice(middleware.ScopedDB(r), &invoice, orderLines); err != nil {
+		writeProcurementErr(w, err, "failed to match invoice")
+		return
+	}
+	if err := middleware.ScopedDB(r).Create(&invoice).Error; err != nil {
+		http.Error(w, "failed to record invoice", http.StatusInternalServerError)
+		return
+	}
+	fireInvoiceMatched(&invoice, po, userID)
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "invoice recorded", "item": invoice})
+}
+
+// ListVendorInvoices lists the business's vendor invoices. ?purchase_order_id=,
+// ?match_status=, ?state=, ?payment_status= and ?overdue=true narrow them.
+// GET /api/v1/business/{businessCode}/vendor-invoices
+func ListVendorInvoices(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load invoices")
+		return
+	}
+
+	query := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID)
+	q := r.URL.Query()
+	if v := q.Get("purchase_order_id"); v != "" {
+		query = query.Where("purchase_order_id = ?", v)
+	}
+	if v := q.Get("match_status"); v != "" {
+		query = query.Where("match_status = ?", v)
+	}
+	if v := q.Get("state"); v != "" {
+		query = query.Where("current_state = ?", v)
+	}
+	if v := q.Get("payment_status"); v != "" {
+		query = query.Where("payment_status = ?", v)
+	}
+	if q.Get("overdue") == "true" {
+		query = query.Where("current_state = ? AND payment_status <> ? AND due_date < ?",
+			models.ProcurementApproved, models.InvoicePaid, calendarDate(time.Now(), attendanceLocation(nil)))
+	}
+	var items []models.VendorInvoice
+	if err := query.Order("invoice_date DESC, created_at DESC").Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch invoices", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// GetVendorInvoice returns a vendor invoice with its purchase order and workflow history
+// GET /api/v1/business/{businessCode}/vendor-invoices/{id}
+func GetVendorInvoice(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load invoice")
+		return
+	}
+	invoice, err := loadVendorInvoice(middleware.ScopedDB(r).Preload("PurchaseOrder"), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load invoice")
+		return
+	}
+
+	writeProcurementDocument(w, r, vendorInvoiceRecord(invoice), invoice, nil)
+}
+
+// RematchVendorInvoice matches an invoice again, as after more goods are received
+// POST /api/v1/business/{businessCode}/vendor-invoices/{id}/match
+func RematchVendorInvoice(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to match invoice")
+		return
+	}
+	invoice, err := loadVendorInvoice(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load invoice")
+		return
+	}
+	if invoice.CurrentState == models.ProcurementApproved {
+		http.Error(w, "an approved invoice is not matched again", http.StatusConflict)
+		return
+	}
+
+	var po models.PurchaseOrder
+	if err := middleware.ScopedDB(r).Preload("Lines").First(&po, "id = ?", invoice.PurchaseOrderID).Error; err != nil {
+		http.Error(w, "failed to load purchase order", http.StatusInternalServerError)
+		return
+	}
+	if err := matchVendorInvoice(middleware.ScopedDB(r), invoice, po.Lines); err != nil {
+		writeProcurementErr(w, err, "failed to match invoice")
+		return
+	}
+	fireInvoiceMatched(invoice, &po, middleware.GetClaims(r).UserID)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "invoice " + invoice.MatchStatus, "item": invoice})
+}
+
+// TakeVendorInvoiceAction takes a workflow action on a vendor invoice. Moving it forward
+// matches it again under a lock on its order and is refused unless it matches or its
+// exceptions were accepted; its final
+// approval counts its quantities as invoiced on the order and posts it to the ledger.
+// POST /api/v1/business/{businessCode}/vendor-invoices/{id}/actions/{action}
+func TakeVendorInvoiceAction(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update invoice")
+		return
+	}
+	invoice, err := loadVendorInvoice(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load invoice")
+		return
+	}
+	var req procurementActionRequest
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	transition, err := takeProcurementAction(r, vendorInvoiceRecord(invoice), mux.Vars(r)["action"], req.Comment,
+		func(tx *gorm.DB, to string) error {
+			if to == models.ProcurementDraft || to == "rejected" {
+				return nil
+			}
+			var po models.PurchaseOrder
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				First(&po, "id = ?", invoice.PurchaseOrderID).Error; err != nil {
+				return err
+			}
+			var orderLines []models.PurchaseOrderLine
+			if err := tx.Where("purchase_order_id = ?", invoice.PurchaseOrderID).Find(&orderLines).Error; err != nil {
+				return err
+			}
+			if err := matchVendorInvoice(tx, invoice, orderLines); err != nil {
+				return err
+			}
+			if invoice.MatchStatus != models.InvoiceMatched && invoice.MatchStatus != models.InvoiceExceptionAccepted {
+				return apiError{status: http.StatusConflict, message: "the invoice does not match its purchase order and receipts: " +
+					strings.Join(invoice.MatchExceptions, "; ")}
+			}
+			if to != models.ProcurementApproved {
+				return nil
+			}
+			for _, line := range invoice.Lines {
+				if err := tx.Model(&models.PurchaseOrderLine{}).Where("id = ?", line.PurchaseOrderLineID).
+					Update("invoiced_quantity", gorm.Expr("invoiced_quantity + ?", line.Quantity)).Error; err != nil {
+					return err
+				}
+			}
+			return postVendorInvoice(tx, invoice, &po, middleware.GetClaims(r).UserID)
+		})
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update invoice")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "invoice " + transition.ToState, "transition": transition})
+}
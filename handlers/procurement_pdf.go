@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/pdfdoc"
+)
+
+// poColumn is a column of the purchase order's line table: its heading, its left edge,
+// and whether its values are right-aligned to the next column's edge
+type poColumn struct {
+	heading string
+	x       float64
+	right   bool
+}
+
+var poColumns = []poColumn{
+	{"#", 40, false},
+	{"Material", 58, false},
+	{"Description", 120, false},
+	{"UOM", 300, false},
+	{"Qty", 330, true},
+	{"Rate", 385, true},
+	{"Tax %", 445, true},
+	{"Amount", 480, true},
+}
+
+const (
+	poMarginRight = 555.0
+	poPageBottom  = 790.0
+)
+
+// renderPurchaseOrderPDF lays a purchase order out as a printable A4 document: the
+// buyer and order header, the vendor and delivery blocks, the priced lines and totals,
+// and the terms. Orders not yet fully approved are marked as drafts.
+func renderPurchaseOrderPDF(po *models.PurchaseOrder, businessName string) []byte {
+	doc := pdfdoc.New()
+	doc.SetTitle("Purchase Order " + po.OrderNumber)
+	doc.AddPage()
+	y := poPageHeader(doc, po, businessName)
+
+	// Vendor and delivery blocks side by side
+	doc.Text(40, y, 9, true, "Vendor")
+	doc.Text(310, y, 9, true, "Deliver to")
+	vendor := []string{po.VendorName}
+	if po.VendorGSTIN != "" {
+		vendor = append(vendor, "GSTIN: "+po.VendorGSTIN)
+	}
+	if strings.TrimSpace(po.VendorAddress) != "" {
+		vendor = append(vendor, pdfdoc.Wrap(po.VendorAddress, 9, 250)...)
+	}
+	if contact := strings.TrimSpace(strings.Join(nonEmpty(po.VendorContact, po.VendorPhone, po.VendorEmail), ", ")); contact != "" {
+		vendor = append(vendor, pdfdoc.Wrap(contact, 9, 250)...)
+	}
+	delivery := []string{}
+	if po.Site != nil {
+		delivery = append(delivery, po.Site.Name)
+	}
+	if strings.TrimSpace(po.DeliveryAddress) != "" {
+		delivery = append(delivery, pdfdoc.Wrap(po.DeliveryAddress, 9, 245)...)
+	}
+	if po.DeliveryDate != nil {
+		delivery = append(delivery, "By "+po.DeliveryDate.Format("02 Jan 2006"))
+	}
+	rows := int(math.Max(float64(len(vendor)), float64(len(delivery))))
+	for i := 0; i < rows; i++ {
+		y += 12
+		if i < len(vendor) {
+			doc.Text(40, y, 9, false, vendor[i])
+		}
+		if i < len(delivery) {
+			doc.Text(310, y, 9, false, delivery[i])
+		}
+	}
+	y += 22
+
+	// Lines, carrying over to new pages with the headings repeated
+	y = poTableHeader(doc, y)
+	for i, line := range po.Lines {
+		description := pdfdoc.Wrap(line.Description, 8, 175)
+		height := float64(len(description)) * 10
+		if y+height > poPageBottom {
+			doc.AddPage()
+			y = poTableHeader(doc, poPageHeader(doc, po, businessName))
+		}
+		values := []string{
+			fmt.Sprint(i + 1),
+			line.MaterialCode,
+			"",
+			line.UOM,
+			formatQuantity(line.Quantity),
+			formatAmount(line.Rate),
+			formatAmount(line.TaxPercent),
+			formatAmount(line.Amount),
+		}
+		for c, col := range poColumns {
+			if col.right {
+				doc.TextRight(poColumnEnd(c)-4, y, 8, false, values[c])
+			} else if values[c] != "" {
+				doc.Text(col.x, y, 8, false, values[c])
+			}
+		}
+		for j, text := range description {
+			doc.Text(poColumns[2].x, y+float64(j)*10, 8, false, text)
+		}
+		y += height + 4
+	}
+	doc.Line(40, y-6, poMarginRight, y-6)
+
+	// Totals
+	if y+50 > poPageBottom {
+		doc.AddPage()
+		y = poPageHeader(doc, po, businessName)
+	}
+	for _, total := range []struct {
+		label  string
+		amount float64
+	}{{"Sub-total", po.SubTotal}, {"Tax", po.TaxAmount}, {"Total", po.TotalAmount}} {
+		y += 6
+		bold := total.label == "Total"
+		doc.TextRight(440, y, 9, bold, total.label)
+		doc.TextRight(poMarginRight-4, y, 9, bold, formatAmount(total.amount))
+		y += 8
+	}
+	y += 14
+
+	// Terms
+	for _, section := range []struct{ heading, text string }{
+		{"Payment terms", po.PaymentTerms},
+		{"Terms and conditions", po.Terms},
+	} {
+		if strings.TrimSpace(section.text) == "" {
+			continue
+		}
+		lines := pdfdoc.Wrap(section.text, 8, poMarginRight-40)
+		if y+12+float64(len(lines))*10 > poPageBottom {
+			doc.AddPage()
+			y = poPageHeader(doc, po, businessName)
+		}
+		doc.Text(40, y, 9, true, section.heading)
+		for _, text := range lines {
+			y += 10
+			doc.Text(40, y, 8, false, text)
+		}
+		y += 18
+	}
+
+	return doc.Bytes()
+}
+
+// poPageHeader draws the buyer, title and order details at the top of the current page,
+// and the page number at its foot, and returns where the page's content starts
+func poPageHeader(doc *pdfdoc.Document, po *models.PurchaseOrder, businessName string) float64 {
+	doc.Text(40, 815, 7, false, fmt.Sprintf("%s - page %d", po.OrderNumber, doc.PageCount()))
+	doc.Text(40, 50, 14, true, businessName)
+	doc.TextRight(poMarginRight, 50, 14, true, "PURCHASE ORDER")
+	if po.CurrentState != models.ProcurementApproved {
+		doc.TextRight(poMarginRight, 64, 9, true, "DRAFT - NOT APPROVED")
+	}
+	doc.Line(40, 72, poMarginRight, 72)
+
+	doc.Text(40, 88, 9, false, "PO number: "+po.OrderNumber)
+	doc.Text(40, 100, 9, false, "Date: "+po.OrderDate.Format("02 Jan 2006"))
+	if po.Requisition != nil {
+		doc.Text(310, 88, 9, false, "Requisition: "+po.Requisition.RequisitionNumber)
+	}
+	doc.Text(310, 100, 9, false, "Status: "+po.CurrentState)
+	return 124
+}
+
+// poTableHeader draws the line table's headings at y and returns where its rows start
+func poTableHeader(doc *pdfdoc.Document, y float64) float64 {
+	doc.Line(40, y-10, poMarginRight, y-10)
+	for c, col := range poColumns {
+		if col.right {
+			doc.TextRight(poColumnEnd(c)-4, y, 8, true, col.heading)
+		} else {
+			doc.Text(col.x, y, 8, true, col.heading)
+		}
+	}
+	doc.Line(40, y+5, poMarginRight, y+5)
+	return y + 18
+}
+
+// poColumnEnd returns the right edge of column c
+func poColumnEnd(c int) float64 {
+	if c+1 < len(poColumns) {
+		return poColumns[c+1].x
+	}
+	return poMarginRight
+}
+
+// formatAmount formats v with two decimals and Indian digit grouping, as 12,34,567.50
+func formatAmount(v float64) string {
+	s := fmt.Sprintf("%.2f", math.Abs(v))
+	whole, frac := s[:len(s)-3], s[len(s)-3:]
+	if len(whole) > 3 {
+		head, tail := whole[:len(whole)-3], whole[len(whole)-3:]
+		var groups []string
+		for len(head) > 2 {
+			groups = append([]string{head[len(head)-2:]}, groups...)
+			head = head[:len(head)-2]
+		}
+		if head != "" {
+			groups = append([]string{head}, groups...)
+		}
+		whole = strings.Join(groups, ",") + "," + tail
+	}
+	if v < 0 {
+		whole = "-" + whole
+	}
+	return whole + frac
+}
+
+// formatQuantity formats a quantity without trailing zeros
+func formatQuantity(v float64) string {
+	s := strings.TrimRight(fmt.Sprintf("%.4f", v), "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// nonEmpty returns the values that are not blank
+func nonEmpty(values ...string) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
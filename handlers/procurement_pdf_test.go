@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"p9e.in/ugcl/models"
+)
+
+func TestFormatAmount(t *testing.T) {
+	cases := map[float64]string{
+		0:          "0.00",
+		950.5:      "950.50",
+		1234567.5:  "12,34,567.50",
+		-123456.78: "-1,23,456.78",
+	}
+	for v, want := range cases {
+		if got := formatAmount(v); got != want {
+			t.Errorf("formatAmount(%v) = %q, want %q", v, got, want)
+		}
+	}
+}
+
+func TestRenderPurchaseOrderPDFPaginatesLines(t *testing.T) {
+	po := &models.PurchaseOrder{
+		OrderNumber:  "PO/2026/118",
+		OrderDate:    time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC),
+		VendorName:   "Deccan Pipes Pvt Ltd",
+		CurrentState: models.ProcurementDraft,
+		Terms:        "Delivery within 21 days of the order.",
+	}
+	for i := 0; i < 80; i++ {
+		line := models.PurchaseOrderLine{MaterialCode: fmt.Sprintf("DI-%03d", i), Description: "DI K9 pipe", UOM: "m", Quantity: 6, Rate: 2450, TaxPercent: 18}
+		line.Price()
+		po.Lines = append(po.Lines, line)
+	}
+	po.Total()
+
+	out := renderPurchaseOrderPDF(po, "Water Works")
+	if !bytes.HasPrefix(out, []byte("%PDF-")) {
+		t.Fatal("not a PDF")
+	}
+	for _, want := range []string{"/Count 2", "(DRAFT - NOT APPROVED)", "(PO/2026/118 - page 2)", "(13,87,680.00)"} {
+		if !bytes.Contains(out, []byte(want)) {
+			t.Errorf("PDF lacks %s", want)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/hooks"
+)
+
+// procurementRecord is a requisition, purchase order or vendor invoice taking an action
+// in the approval workflow
+type procurementRecord struct {
+	kind       string // recorded as the form code of its transitions, e.g. purchase_order
+	table      string
+	permission string // needed for the workflow's actions that name no permission
+	id         uuid.UUID
+	businessID uuid.UUID
+	workflowID *uuid.UUID
+	state      string
+	createdBy  string
+	title      string
+	limitType  string  // the approval limits its final approval is held to, if any
+	amount     float64 // checked against them
+}
+
+// writeProcurementErr writes an apiError or a separation-of-duties refusal, or a 500 for
+// anything else
+func writeProcurementErr(w http.ResponseWriter, err error, fallback string) {
+	var violation *middleware.SoDViolationError
+	if errors.As(err, &violation) {
+		middleware.WriteSoDViolation(w, err)
+		return
+	}
+	writeSubcontractErr(w, err, fallback)
+}
+
+// procurementWorkflowID returns the approval workflow new procurement documents follow
+func procurementWorkflowID(db *gorm.DB) (*uuid.UUID, error) {
+	var workflow models.WorkflowDefinition
+	if err := db.Select("id").Where("code = ? AND is_active = ?", models.ProcurementWorkflowCode, true).
+		First(&workflow).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError{status: http.StatusInternalServerError, message: "the procurement approval workflow is not configured"}
+		}
+		return nil, err
+	}
+	return &workflow.ID, nil
+}
+
+// loadProcurementWorkflow loads the workflow a document follows, falling back to the
+// current procurement workflow for documents that predate it
+func loadProcurementWorkflow(db *gorm.DB, workflowID *uuid.UUID) (*models.WorkflowDefinition, error) {
+	var workflow models.WorkflowDefinition
+	query := db.Where("code = ?", models.ProcurementWorkflowCode)
+	if workflowID != nil {
+		query = db.Where("id = ?", *workflowID)
+	}
+	if err := query.First(&workflow).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError{status: http.StatusInternalServerError, message: "the procurement approval workflow is not configured"}
+		}
+		return nil, err
+	}
+	return &workflow, nil
+}
+
+// procurementActions lists the actions the user may take on the document from its state
+func procurementActions(r *http.Request, rec procurementRecord) []models.WorkflowAction {
+	workflow, err := loadProcurementWorkflow(middleware.ScopedDB(r), rec.workflowID)
+	if err != nil {
+		return []models.WorkflowAction{}
+	}
+	transitions, err := workflow.ParseTransitions()
+	if err != nil {
+		return []models.WorkflowAction{}
+	}
+	permissions := middleware.GetEffectivePermissions(r)
+	actions := []models.WorkflowAction{}
+	for _, t := range transitions {
+		if t.From != rec.state {
+			continue
+		}
+		permission := t.RequiredPermissionCode()
+		if permission == "" {
+			permission = rec.permission
+		}
+		if !hasWorkflowPermission(permissions, permission) {
+			continue
+		}
+		actions = append(actions, models.WorkflowAction{
+			Action:          t.Action,
+			Label:           t.Label,
+			ToState:         t.To,
+			RequiresComment: t.RequiresComment || t.To == "rejected",
+		})
+	}
+	return actions
+}
+
+// procurementHistory returns the workflow transitions a document has taken
+func procurementHistory(id uuid.UUID) ([]models.WorkflowTransition, error) {
+	var history []models.WorkflowTransition
+	err := config.DB.Where("submission_id = ?", id).Order("transitioned_at").Find(&history).Error
+	return history, err
+}
+
+// takeProcurementAction moves a procurement document along its approval workflow. Actions
+// the workflow guards with a permission are approvals: they need that permission, cannot
+// be taken by whoever raised the document, and one person cannot approve it at two
+// levels. The final approval is also held to the approver's financial approval limit.
+// Other actions, such as submit and revise, need rec.permission. effect runs in the
+// transaction after the state changes and may refuse the action.
+func takeProcurementAction(r *http.Request, rec procurementRecord, action, comment string, effect func(tx *gorm.DB, to string) error) (*models.WorkflowTransition, error) {
+	workflow, err := loadProcurementWorkflow(middleware.ScopedDB(r), rec.workflowID)
+	if err != nil {
+		return nil, err
+	}
+	def, err := workflow.FindTransition(rec.state, action)
+	if err != nil {
+		return nil, apiError{status: http.StatusConflict, message: err.Error()}
+	}
+	comment = strings.TrimSpace(comment)
+	if (def.RequiresComment || def.To == "rejected") && comment == "" {
+		return nil, apiError{status: http.StatusBadRequest, message: "a comment is required for this action"}
+	}
+
+	claims := middleware.GetClaims(r)
+	permission := def.RequiredPermissionCode()
+	approval := permission != ""
+	if !approval {
+		permission = rec.permission
+	}
+	if !hasWorkflowPermission(middleware.GetEffectivePermissions(r), permission) {
+		return nil, apiError{status: http.StatusForbidden, message: fmt.Sprintf("insufficient permissions: requires '%s'", permission)}
+	}
+	if approval {
+		if rec.createdBy == claims.UserID {
+			return nil, apiError{status: http.StatusForbidden, message: "a document must be approved by someone other than who raised it"}
+		}
+		var last models.WorkflowTransition
+		if err := middleware.ScopedDB(r).Where("submission_id = ?", rec.id).Order("transitioned_at DESC").
+			First(&last).Error; err == nil && last.ActorID == claims.UserID && last.Action != "submit" {
+			return nil, apiError{status: http.StatusForbidden, message: "the same person cannot approve a document at two levels"}
+		}
+		reference := rec.kind + ":" + rec.id.String() + ":" + action
+		if err := middleware.EnforceApprovalSoD(r, rec.businessID, permission, reference); err != nil {
+			return nil, err
+		}
+		if def.To == models.ProcurementApproved && rec.limitType != "" {
+			if err := checkApprovalLimit(middleware.ScopedDB(r), rec, claims); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	transition := models.WorkflowTransition{
+		SubmissionID:   rec.id,
+		FromState:      rec.state,
+		ToState:        def.To,
+		Action:         action,
+		ActorID:        claims.UserID,
+		ActorName:      claims.Name,
+		ActorRole:      claims.Role,
+		Comment:        comment,
+		Metadata:       json.RawMessage(`{}`),
+		TransitionedAt: time.Now(),
+	}
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		result := tx.Table(rec.table).Where("id = ? AND current_state = ?", rec.id, rec.state).
+			Updates(map[string]interface{}{"current_state": def.To, "updated_by": claims.UserID, "updated_at": time.Now()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return apiError{status: http.StatusConflict, message: "the document changed state; reload and try again"}
+		}
+		if effect != nil {
+			if err := effect(tx, def.To); err != nil {
+				return err
+			}
+		}
+		return tx.Create(&transition).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	notifyProcurementTransition(rec, workflow, def, &transition)
+	hooks.FireWorkflowTransition(hooks.WorkflowTransitionEvent{
+		FormCode:           rec.kind,
+		SubmissionID:       rec.id,
+		TableName:          rec.table,
+		BusinessVerticalID: rec.businessID,
+		FromState:          rec.state,
+		ToState:            def.To,
+		Action:             action,
+		ActorID:            claims.UserID,
+		ActorName:          claims.Name,
+		ActorRole:          claims.Role,
+		Comment:            comment,
+		TransitionedAt:     transition.TransitionedAt,
+	})
+	return &transition, nil
+}
+
+// notifyProcurementTransition sends the transition's notifications, with the document
+// standing in for the form submission the workflow's templates are written for
+func notifyProcurementTransition(rec procurementRecord, workflow *models.WorkflowDefinition, def *models.WorkflowTransitionDef, transition *models.WorkflowTransition) {
+	if len(def.Notifications) == 0 {
+		return
+	}
+	formData, _ := json.Marshal(map[string]interface{}{"id": rec.id.String(), "number": rec.title, "current_state": def.To})
+	submission := models.FormSubmission{
+		ID:                 rec.id,
+		FormCode:           rec.kind,
+		WorkflowID:         &workflow.ID,
+		CurrentState:       def.To,
+		FormData:           formData,
+		SubmittedBy:        rec.createdBy,
+		BusinessVerticalID: rec.businessID,
+		Form:               &models.AppForm{Title: rec.title},
+		Workflow:           workflow,
+	}
+	if err := NewNotificationService().ProcessTransitionNotifications(&submission, transition, workflow, def, transition.ActorName); err != nil {
+		log.Printf("⚠️ failed to process %s workflow notifications: %v", rec.kind, err)
+	}
+}
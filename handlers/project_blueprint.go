@@ -0,0 +1,677 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// ProjectBlueprintHandler manages project blueprints and instantiates projects from them.
+type ProjectBlueprintHandler struct {
+	db *gorm.DB
+}
+
+func NewProjectBlueprintHandler() *ProjectBlueprintHandler {
+	return &ProjectBlueprintHandler{db: config.DB}
+}
+
+// blueprintUserContext loads the caller for the business vertical checks, replaced in tests
+var blueprintUserContext = func(r *http.Request) (*middleware.UserContext, error) {
+	return middleware.NewAuthService().LoadUserContext(r)
+}
+
+// scopedDB binds the handler's connection to the caller's data scope, so projects and
+// blueprints of verticals the caller cannot access are neither read nor written
+func (h *ProjectBlueprintHandler) scopedDB(r *http.Request) *gorm.DB {
+	return middleware.WithDataScope(r, h.db)
+}
+
+type projectBlueprintRequest struct {
+	Code                string               `json:"code"`
+	Name                string               `json:"name"`
+	Description         string               `json:"description"`
+	BusinessVerticalID  *uuid.UUID           `json:"business_vertical_id"`
+	WorkflowID          *uuid.UUID           `json:"workflow_id"`
+	Currency            string               `json:"currency"`
+	DefaultDurationDays int                  `json:"default_duration_days"`
+	Spec                models.BlueprintSpec `json:"spec"`
+	IsActive            *bool                `json:"is_active"`
+}
+
+func (h *ProjectBlueprintHandler) CreateBlueprint(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req projectBlueprintRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	blueprint := models.ProjectBlueprint{
+		Code:                strings.TrimSpace(req.Code),
+		Name:                strings.TrimSpace(req.Name),
+		Description:         req.Description,
+		BusinessVerticalID:  req.BusinessVerticalID,
+		WorkflowID:          req.WorkflowID,
+		Currency:            req.Currency,
+		DefaultDurationDays: req.DefaultDurationDays,
+		Spec:                req.Spec,
+		Version:             1,
+		IsActive:            req.IsActive == nil || *req.IsActive,
+		CreatedBy:           claims.UserID,
+	}
+	if blueprint.Code == "" || blueprint.Name == "" {
+		http.Error(w, "code and name are required", http.StatusBadRequest)
+		return
+	}
+	if blueprint.Currency == "" {
+		blueprint.Currency = "INR"
+	}
+	if err := h.validateBlueprint(&blueprint); err != nil {
+		writeBlueprintErr(w, err)
+		return
+	}
+	if blueprint.BusinessVerticalID != nil && !canUseBlueprintVertical(r, *blueprint.BusinessVerticalID) {
+		http.Error(w, "cannot create blueprints for this business vertical", http.StatusForbidden)
+		return
+	}
+
+	if err := h.scopedDB(r).Create(&blueprint).Error; err != nil {
+		log.Printf("❌ Failed to create project blueprint: %v", err)
+		http.Error(w, "failed to create blueprint", middleware.DataScopeStatus(err))
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"blueprint": blueprint})
+}
+
+// ListBlueprints returns active blueprints usable by the caller's vertical.
+// Pass include_inactive=true to list retired blueprints as well.
+func (h *ProjectBlueprintHandler) ListBlueprints(w http.ResponseWriter, r *http.Request) {
+	query := h.scopedQuery(r).Order("name ASC")
+	if r.URL.Query().Get("include_inactive") != "true" {
+		query = query.Where("is_active = ?", true)
+	}
+	if raw := strings.TrimSpace(r.URL.Query().Get("business_vertical_id")); raw != "" {
+		verticalID, err := uuid.Parse(raw)
+		if err != nil {
+			http.Error(w, "invalid business_vertical_id", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("business_vertical_id IS NULL OR business_vertical_id = ?", verticalID)
+	}
+
+	var blueprints []models.ProjectBlueprint
+	if err := query.Find(&blueprints).Error; err != nil {
+		http.Error(w, "failed to list blueprints", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"blueprints": blueprints, "count": len(blueprints)})
+}
+
+func (h *ProjectBlueprintHandler) GetBlueprint(w http.ResponseWriter, r *http.Request) {
+	blueprint, err := h.loadBlueprint(r)
+	if err != nil {
+		writeBlueprintErr(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"blueprint": blueprint})
+}
+
+// UpdateBlueprint replaces a blueprint's definition and bumps its version.
+// Projects already created from the blueprint are not affected.
+func (h *ProjectBlueprintHandler) UpdateBlueprint(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	blueprint, err := h.loadBlueprint(r)
+	if err != nil {
+		writeBlueprintErr(w, err)
+		return
+	}
+
+	var req projectBlueprintRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if name := strings.TrimSpace(req.Name); name != "" {
+		blueprint.Name = name
+	}
+	if req.Currency != "" {
+		blueprint.Currency = req.Currency
+	}
+	if req.IsActive != nil {
+		blueprint.IsActive = *req.IsActive
+	}
+	blueprint.Description = req.Description
+	blueprint.BusinessVerticalID = req.BusinessVerticalID
+	blueprint.WorkflowID = req.WorkflowID
+	blueprint.DefaultDurationDays = req.DefaultDurationDays
+	blueprint.Spec = req.Spec
+	blueprint.Version++
+	blueprint.UpdatedBy = claims.UserID
+
+	if err := h.validateBlueprint(blueprint); err != nil {
+		writeBlueprintErr(w, err)
+		return
+	}
+	if blueprint.BusinessVerticalID != nil && !canUseBlueprintVertical(r, *blueprint.BusinessVerticalID) {
+		http.Error(w, "cannot move blueprints to this business vertical", http.StatusForbidden)
+		return
+	}
+
+	if err := h.scopedDB(r).Select("*").Omit("ID", "Code", "CreatedBy", "CreatedAt", "BusinessVertical", "Workflow").
+		Updates(blueprint).Error; err != nil {
+		http.Error(w, "failed to update blueprint", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"blueprint": blueprint})
+}
+
+func (h *ProjectBlueprintHandler) DeleteBlueprint(w http.ResponseWriter, r *http.Request) {
+	blueprint, err := h.loadBlueprint(r)
+	if err != nil {
+		writeBlueprintErr(w, err)
+		return
+	}
+
+	now := time.Now()
+	if err := h.scopedDB(r).Model(blueprint).Updates(map[string]interface{}{"deleted_at": now, "is_active": false}).Error; err != nil {
+		http.Error(w, "failed to delete blueprint", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "blueprint deleted"})
+}
+
+// CaptureBlueprint snapshots an existing project's zones, WBS nodes and BOQ items
+// into a new blueprint so a proven setup can be reused.
+func (h *ProjectBlueprintHandler) CaptureBlueprint(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	projectID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid project id", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Code                 string                    `json:"code"`
+		Name                 string                    `json:"name"`
+		Description          string                    `json:"description"`
+		Channels             []models.BlueprintChannel `json:"channels"`
+		ShareAcrossVerticals bool                      `json:"share_across_verticals"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	req.Code = strings.TrimSpace(req.Code)
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Code == "" || req.Name == "" {
+		http.Error(w, "code and name are required", http.StatusBadRequest)
+		return
+	}
+
+	db := h.scopedDB(r)
+	query := db.Where("id = ? AND deleted_at IS NULL", projectID)
+	if businessID := blueprintBusinessScope(r); businessID != uuid.Nil {
+		query = query.Where("business_vertical_id = ?", businessID)
+	}
+	var project models.Project
+	if err := query.First(&project).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "project not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to load project", http.StatusInternalServerError)
+		return
+	}
+
+	var zones []models.Zone
+	var nodes []models.WBSNode
+	var items []models.BOQItem
+	if err := db.Select("id", "name", "code", "label", "description").
+		Where("project_id = ? AND deleted_at IS NULL", project.ID).Order("code ASC").Find(&zones).Error; err != nil {
+		http.Error(w, "failed to load zones", http.StatusInternalServerError)
+		return
+	}
+	if err := db.Where("project_id = ? AND deleted_at IS NULL", project.ID).Order("sort_order ASC, code ASC").Find(&nodes).Error; err != nil {
+		http.Error(w, "failed to load WBS nodes", http.StatusInternalServerError)
+		return
+	}
+	if err := db.Where("project_id = ? AND deleted_at IS NULL", project.ID).Order("code ASC").Find(&items).Error; err != nil {
+		http.Error(w, "failed to load BOQ items", http.StatusInternalServerError)
+		return
+	}
+
+	spec := models.BlueprintSpec{Channels: req.Channels}
+	for _, zone := range zones {
+		spec.Zones = append(spec.Zones, models.BlueprintZone{Code: zone.Code, Name: zone.Name, Label: zone.Label, Description: zone.Description})
+	}
+
+	codesByID := make(map[uuid.UUID]string, len(nodes))
+	for _, node := range nodes {
+		codesByID[node.ID] = node.Code
+	}
+	for _, node := range nodes {
+		task := models.BlueprintTaskTemplate{
+			Code:        node.Code,
+			Name:        node.Name,
+			Description: node.Description,
+			NodeType:    node.NodeType,
+			SortOrder:   node.SortOrder,
+			Weightage:   node.Weightage,
+		}
+		if node.ParentID != nil {
+			task.ParentCode = codesByID[*node.ParentID]
+		}
+		if project.StartDate != nil && node.PlannedStartDate != nil {
+			task.StartOffsetDays = daysBetween(*project.StartDate, *node.PlannedStartDate)
+			if node.PlannedEndDate != nil {
+				task.DurationDays = daysBetween(*node.PlannedStartDate, *node.PlannedEndDate)
+			}
+		}
+		spec.TaskTemplates = append(spec.TaskTemplates, task)
+	}
+
+	for _, item := range items {
+		line := models.BlueprintBOQItem{
+			Code:            item.Code,
+			Description:     item.Description,
+			UOM:             item.UOM,
+			PlannedQuantity: item.PlannedQuantity,
+			UnitRate:        item.UnitRate,
+		}
+		if item.WBSNodeID != nil {
+			line.WBSCode = codesByID[*item.WBSNodeID]
+		}
+		spec.BOQItems = append(spec.BOQItems, line)
+	}
+
+	blueprint := models.ProjectBlueprint{
+		Code:        req.Code,
+		Name:        req.Name,
+		Description: req.Description,
+		WorkflowID:  project.WorkflowID,
+		Currency:    project.Currency,
+		Spec:        spec,
+		Version:     1,
+		IsActive:    true,
+		CreatedBy:   claims.UserID,
+	}
+	if !req.ShareAcrossVerticals {
+		blueprint.BusinessVerticalID = &project.BusinessVerticalID
+	}
+	if project.StartDate != nil && project.EndDate != nil {
+		blueprint.DefaultDurationDays = daysBetween(*project.StartDate, *project.EndDate)
+	}
+	if err := blueprint.Spec.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("project cannot be captured: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err := db.Create(&blueprint).Error; err != nil {
+		log.Printf("❌ Failed to capture blueprint from project %s: %v", project.ID, err)
+		http.Error(w, "failed to create blueprint", middleware.DataScopeStatus(err))
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"blueprint": blueprint})
+}
+
+// CreateProjectFromBlueprint creates a project and instantiates the blueprint's zones,
+// WBS nodes, BOQ skeleton and chat channels in a single transaction.
+func (h *ProjectBlueprintHandler) CreateProjectFromBlueprint(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	blueprint, err := h.loadBlueprint(r)
+	if err != nil {
+		writeBlueprintErr(w, err)
+		return
+	}
+	if !blueprint.IsActive {
+		http.Error(w, "blueprint is inactive", http.StatusConflict)
+		return
+	}
+
+	var req struct {
+		Code               string     `json:"code"`
+		Name               string     `json:"name"`
+		Description        string     `json:"description"`
+		BusinessVerticalID uuid.UUID  `json:"business_vertical_id"`
+		StartDate          *time.Time `json:"start_date"`
+		EndDate            *time.Time `json:"end_date"`
+		TotalBudget        float64    `json:"total_budget"`
+		Currency           string     `json:"currency"`
+		WorkflowID         *uuid.UUID `json:"workflow_id"`
+		ChannelMemberIDs   []string   `json:"channel_member_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	req.Code = strings.TrimSpace(req.Code)
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Code == "" || req.Name == "" {
+		http.Error(w, "code and name are required", http.StatusBadRequest)
+		return
+	}
+
+	if req.BusinessVerticalID == uuid.Nil {
+		if blueprint.BusinessVerticalID != nil {
+			req.BusinessVerticalID = *blueprint.BusinessVerticalID
+		} else {
+			req.BusinessVerticalID = blueprintBusinessScope(r)
+		}
+	}
+	if req.BusinessVerticalID == uuid.Nil {
+		http.Error(w, "business_vertical_id is required", http.StatusBadRequest)
+		return
+	}
+	if blueprint.BusinessVerticalID != nil && *blueprint.BusinessVerticalID != req.BusinessVerticalID {
+		http.Error(w, "blueprint is not available for this business vertical", http.StatusForbidden)
+		return
+	}
+	if scope := blueprintBusinessScope(r); scope != uuid.Nil && scope != req.BusinessVerticalID {
+		http.Error(w, "cannot create projects outside your business vertical", http.StatusForbidden)
+		return
+	}
+	if !canUseBlueprintVertical(r, req.BusinessVerticalID) {
+		http.Error(w, "cannot create projects in this business vertical", http.StatusForbidden)
+		return
+	}
+
+	if req.EndDate == nil && req.StartDate != nil && blueprint.DefaultDurationDays > 0 {
+		end := req.StartDate.AddDate(0, 0, blueprint.DefaultDurationDays)
+		req.EndDate = &end
+	}
+	if req.Currency == "" {
+		req.Currency = blueprint.Currency
+	}
+	if req.WorkflowID == nil {
+		req.WorkflowID = blueprint.WorkflowID
+	}
+	currency, rate, err := transactionCurrency(h.scopedDB(r), req.Currency, time.Now())
+	if err != nil {
+		writeProcurementErr(w, err, "failed to create project")
+		return
+	}
+
+	project := models.Project{
+		Code:               req.Code,
+		Name:               req.Name,
+		Description:        req.Description,
+		BusinessVerticalID: req.BusinessVerticalID,
+		StartDate:          req.StartDate,
+		EndDate:            req.EndDate,
+		TotalBudget:        req.TotalBudget,
+		Currency:           currency,
+		ExchangeRate:       rate,
+		Status:             "draft",
+		WorkflowID:         req.WorkflowID,
+		BlueprintID:        &blueprint.ID,
+		CreatedBy:          claims.UserID,
+	}
+
+	var (
+		zones    []models.Zone
+		nodes    []models.WBSNode
+		items    []models.BOQItem
+		channels []models.Conversation
+	)
+
+	err = h.scopedDB(r).Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Model(&models.Project{}).Where("code = ?", project.Code).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return apiError{status: http.StatusConflict, message: "project code already exists"}
+		}
+
+		if err := tx.Create(&project).Error; err != nil {
+			return fmt.Errorf("create project: %w", err)
+		}
+
+		for _, tmpl := range blueprint.Spec.Zones {
+			zone := models.Zone{
+				ProjectID:   project.ID,
+				Name:        tmpl.Name,
+				Code:        tmpl.Code,
+				Label:       tmpl.Label,
+				Description: tmpl.Description,
+			}
+			if err := tx.Omit("Geometry", "Centroid").Create(&zone).Error; err != nil {
+				return fmt.Errorf("create zone %s: %w", tmpl.Name, err)
+			}
+			zones = append(zones, zone)
+		}
+
+		nodeIDs := make(map[string]uuid.UUID, len(blueprint.Spec.TaskTemplates))
+		for _, tmpl := range blueprint.Spec.OrderedTaskTemplates() {
+			nodeType := tmpl.NodeType
+			if nodeType == "" {
+				nodeType = "activity"
+			}
+			node := models.WBSNode{
+				ProjectID:   project.ID,
+				Code:        tmpl.Code,
+				Name:        tmpl.Name,
+				Description: tmpl.Description,
+				NodeType:    nodeType,
+				SortOrder:   tmpl.SortOrder,
+				Weightage:   tmpl.Weightage,
+				CreatedBy:   claims.UserID,
+			}
+			if tmpl.ParentCode != "" {
+				parentID := nodeIDs[tmpl.ParentCode]
+				node.ParentID = &parentID
+			}
+			if project.StartDate != nil {
+				start := project.StartDate.AddDate(0, 0, tmpl.StartOffsetDays)
+				end := start.AddDate(0, 0, tmpl.DurationDays)
+				node.PlannedStartDate = &start
+				node.PlannedEndDate = &end
+			}
+			if err := tx.Create(&node).Error; err != nil {
+				return fmt.Errorf("create WBS node %s: %w", tmpl.Code, err)
+			}
+			nodeIDs[node.Code] = node.ID
+			nodes = append(nodes, node)
+		}
+
+		for _, tmpl := range blueprint.Spec.BOQItems {
+			item := models.BOQItem{
+				ProjectID:       project.ID,
+				Code:            tmpl.Code,
+				Description:     tmpl.Description,
+				UOM:             tmpl.UOM,
+				PlannedQuantity: tmpl.PlannedQuantity,
+				UnitRate:        tmpl.UnitRate,
+				PlannedAmount:   tmpl.PlannedQuantity * tmpl.UnitRate,
+				Status:          "planned",
+				CreatedBy:       claims.UserID,
+			}
+			if tmpl.WBSCode != "" {
+				nodeID := nodeIDs[tmpl.WBSCode]
+				item.WBSNodeID = &nodeID
+			}
+			if err := tx.Create(&item).Error; err != nil {
+				return fmt.Errorf("create BOQ item %s: %w", tmpl.Code, err)
+			}
+			items = append(items, item)
+		}
+
+		now := time.Now()
+		for _, tmpl := range blueprint.Spec.Channels {
+			title := fmt.Sprintf("%s - %s", project.Code, tmpl.Title)
+			conversation := models.Conversation{
+				Type:      models.ConversationTypeChannel,
+				Title:     &title,
+				Metadata:  models.JSONMap{"project_id": project.ID.String(), "blueprint_id": blueprint.ID.String()},
+				CreatedBy: claims.UserID,
+			}
+			if tmpl.Description != "" {
+				description := tmpl.Description
+				conversation.Description = &description
+			}
+			if err := tx.Create(&conversation).Error; err != nil {
+				return fmt.Errorf("create channel %s: %w", tmpl.Title, err)
+			}
+
+			members := []string{claims.UserID}
+			members = append(members, tmpl.MemberIDs...)
+			members = append(members, req.ChannelMemberIDs...)
+			seen := make(map[string]bool, len(members))
+			for i, userID := range members {
+				userID = strings.TrimSpace(userID)
+				if userID == "" || seen[userID] {
+					continue
+				}
+				seen[userID] = true
+				role := models.ParticipantRoleMember
+				if i == 0 {
+					role = models.ParticipantRoleOwner
+				}
+				participant := models.ChatParticipant{
+					ConversationID: conversation.ID,
+					UserID:         userID,
+					Role:           role,
+					JoinedAt:       now,
+				}
+				if err := tx.Create(&participant).Error; err != nil {
+					return fmt.Errorf("add participant %s to channel %s: %w", userID, tmpl.Title, err)
+				}
+			}
+			channels = append(channels, conversation)
+		}
+
+		return nil
+	})
+	if err != nil {
+		if ae, ok := err.(apiError); ok {
+			http.Error(w, ae.message, ae.status)
+			return
+		}
+		log.Printf("❌ Failed to create project from blueprint %s: %v", blueprint.Code, err)
+		http.Error(w, "failed to create project from blueprint", middleware.DataScopeStatus(err))
+		return
+	}
+
+	log.Printf("✅ Created project %s from blueprint %s (v%d)", project.Code, blueprint.Code, blueprint.Version)
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"project":           project,
+		"blueprint_id":      blueprint.ID,
+		"blueprint_version": blueprint.Version,
+		"zones":             zones,
+		"wbs_nodes":         nodes,
+		"boq_items":         items,
+		"channels":          channels,
+	})
+}
+
+func (h *ProjectBlueprintHandler) validateBlueprint(blueprint *models.ProjectBlueprint) error {
+	if blueprint.DefaultDurationDays < 0 {
+		return apiError{status: http.StatusBadRequest, message: "default_duration_days cannot be negative"}
+	}
+	if err := blueprint.Spec.Validate(); err != nil {
+		return apiError{status: http.StatusBadRequest, message: err.Error()}
+	}
+	if blueprint.WorkflowID != nil {
+		var count int64
+		if err := h.db.Model(&models.WorkflowDefinition{}).Where("id = ?", *blueprint.WorkflowID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return apiError{status: http.StatusBadRequest, message: "workflow not found"}
+		}
+	}
+	return nil
+}
+
+func (h *ProjectBlueprintHandler) scopedQuery(r *http.Request) *gorm.DB {
+	query := h.scopedDB(r).Model(&models.ProjectBlueprint{}).Where("deleted_at IS NULL")
+	if businessID := blueprintBusinessScope(r); businessID != uuid.Nil {
+		query = query.Where("business_vertical_id IS NULL OR business_vertical_id = ?", businessID)
+	}
+	return query
+}
+
+func (h *ProjectBlueprintHandler) loadBlueprint(r *http.Request) (*models.ProjectBlueprint, error) {
+	blueprintID, err := uuid.Parse(mux.Vars(r)["blueprintId"])
+	if err != nil {
+		return nil, apiError{status: http.StatusBadRequest, message: "invalid blueprint id"}
+	}
+
+	var blueprint models.ProjectBlueprint
+	if err := h.scopedQuery(r).Where("id = ?", blueprintID).First(&blueprint).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError{status: http.StatusNotFound, message: "blueprint not found"}
+		}
+		return nil, apiError{status: http.StatusInternalServerError, message: "failed to load blueprint"}
+	}
+	return &blueprint, nil
+}
+
+// canUseBlueprintVertical reports whether the caller may create projects and blueprints
+// in the business vertical
+func canUseBlueprintVertical(r *http.Request, verticalID uuid.UUID) bool {
+	userCtx, err := blueprintUserContext(r)
+	return err == nil && middleware.CanAccessBusiness(userCtx, verticalID)
+}
+
+// blueprintBusinessScope returns the caller's business vertical when the request is
+// business-scoped, or uuid.Nil for global requests.
+func blueprintBusinessScope(r *http.Request) uuid.UUID {
+	if userCtx, err := blueprintUserContext(r); err == nil && userCtx.BusinessContext != nil {
+		return userCtx.BusinessContext.BusinessID
+	}
+	return uuid.Nil
+}
+
+func writeBlueprintErr(w http.ResponseWriter, err error) {
+	if ae, ok := err.(apiError); ok {
+		http.Error(w, ae.message, ae.status)
+		return
+	}
+	http.Error(w, "internal server error", http.StatusInternalServerError)
+}
+
+func daysBetween(from, to time.Time) int {
+	days := int(to.Sub(from).Hours() / 24)
+	if days < 0 {
+		return 0
+	}
+	return days
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/logger"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/datascope"
+)
+
+// recordedStatement is a statement blueprintTestDB received
+type recordedStatement struct {
+	table string
+	sql   string
+	vars  []interface{}
+}
+
+// blueprintTestDB is a connection that never reaches a server: queries load the given
+// blueprint or find nothing, and every statement is recorded.
+func blueprintTestDB(t *testing.T, blueprint *models.ProjectBlueprint) (*gorm.DB, *[]recordedStatement) {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 user=x dbname=x sslmode=disable"}),
+		&gorm.Config{DisableAutomaticPing: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := db.Use(datascope.Plugin{}); err != nil {
+		t.Fatalf("failed to register data scoping: %v", err)
+	}
+
+	var statements []recordedStatement
+	db.Callback().Query().Replace("gorm:query", func(tx *gorm.DB) {
+		callbacks.BuildQuerySQL(tx)
+		statements = append(statements, recordedStatement{tx.Statement.Table, tx.Statement.SQL.String(), tx.Statement.Vars})
+		if dest, ok := tx.Statement.Dest.(*models.ProjectBlueprint); ok && blueprint != nil {
+			*dest = *blueprint
+			return
+		}
+		tx.AddError(gorm.ErrRecordNotFound)
+	})
+	db.Callback().Create().Replace("gorm:create", func(tx *gorm.DB) {
+		statements = append(statements, recordedStatement{table: tx.Statement.Table})
+	})
+	return db, &statements
+}
+
+// blueprintRequest is an authenticated request whose data scope is the vertical
+func blueprintRequest(t *testing.T, target, body string, vars map[string]string, verticalID uuid.UUID) *http.Request {
+	t.Helper()
+	token, err := middleware.GenerateToken(uuid.NewString(), "user", "Planner", "9999999999", "")
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+	ctx := datascope.WithScope(context.Background(), &datascope.Scope{VerticalIDs: []uuid.UUID{verticalID}})
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body)).WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return mux.SetURLVars(req, vars)
+}
+
+func TestCreateProjectFromBlueprintRefusesInaccessibleVertical(t *testing.T) {
+	ownVertical, otherVertical := uuid.New(), uuid.New()
+	blueprint := &models.ProjectBlueprint{ID: uuid.New(), Code: "SOLAR-5MW", Name: "5MW solar farm", IsActive: true, Currency: "INR"}
+	db, statements := blueprintTestDB(t, blueprint)
+
+	restore := blueprintUserContext
+	defer func() { blueprintUserContext = restore }()
+	blueprintUserContext = func(r *http.Request) (*middleware.UserContext, error) {
+		return &middleware.UserContext{User: &models.User{UserBusinessRoles: []models.UserBusinessRole{{
+			IsActive:     true,
+			BusinessRole: models.BusinessRole{ID: uuid.New(), BusinessVerticalID: ownVertical},
+		}}}}, nil
+	}
+
+	handler := &ProjectBlueprintHandler{db: db}
+	body := `{"code":"PRJ-9","name":"Other vertical farm","business_vertical_id":"` + otherVertical.String() + `"}`
+	req := blueprintRequest(t, "/project-blueprints/x/projects", body, map[string]string{"blueprintId": blueprint.ID.String()}, ownVertical)
+	rr := httptest.NewRecorder()
+	middleware.JWTMiddleware(http.HandlerFunc(handler.CreateProjectFromBlueprint)).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d: %s", rr.Code, http.StatusForbidden, rr.Body.String())
+	}
+	for _, stmt := range *statements {
+		if stmt.table == "projects" {
+			t.Errorf("a project was written in a vertical the caller cannot access")
+		}
+	}
+}
+
+func TestCaptureBlueprintOnlyReadsProjectsInScope(t *testing.T) {
+	ownVertical := uuid.New()
+	db, statements := blueprintTestDB(t, nil)
+
+	restore := blueprintUserContext
+	defer func() { blueprintUserContext = restore }()
+	blueprintUserContext = func(r *http.Request) (*middleware.UserContext, error) {
+		return &middleware.UserContext{User: &models.User{}}, nil
+	}
+
+	handler := &ProjectBlueprintHandler{db: db}
+	body := `{"code":"CAPTURED","name":"Captured layout"}`
+	req := blueprintRequest(t, "/projects/x/blueprint", body, map[string]string{"id": uuid.NewString()}, ownVertical)
+	rr := httptest.NewRecorder()
+	middleware.JWTMiddleware(http.HandlerFunc(handler.CaptureBlueprint)).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want %d: %s", rr.Code, http.StatusNotFound, rr.Body.String())
+	}
+	if len(*statements) != 1 || (*statements)[0].table != "projects" {
+		t.Fatalf("expected only the project lookup, got %d statements", len(*statements))
+	}
+	lookup := (*statements)[0]
+	if !strings.Contains(lookup.sql, `"projects"."business_vertical_id"`) {
+		t.Errorf("project lookup is not limited to the caller's verticals:\n%s", lookup.sql)
+	}
+	scoped := false
+	for _, v := range lookup.vars {
+		if v == ownVertical {
+			scoped = true
+		}
+	}
+	if !scoped {
+		t.Errorf("project lookup vars %v do not name the caller's vertical %s", lookup.vars, ownVertical)
+	}
+}
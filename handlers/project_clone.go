@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// cloneZoneSQL copies a zone, geometry included, into another project
+const cloneZoneSQL = `INSERT INTO zones (id, project_id, name, code, description, label, geometry, centroid, area, geojson, properties, created_at, updated_at)
+	SELECT @id, @project, name, code, description, label, geometry, centroid, area, geojson, properties, @now, @now
+	FROM zones WHERE id = @source`
+
+// cloneZoneNodesSQL copies a zone's nodes into its copy, as available again since the
+// copy has no tasks yet
+const cloneZoneNodesSQL = `INSERT INTO nodes (id, zone_id, project_id, name, code, description, label, node_type, location, latitude, longitude, elevation, geojson, properties, status, created_at, updated_at)
+	SELECT gen_random_uuid(), @zone, @project, name, code, description, label, node_type, location, latitude, longitude, elevation, geojson, properties, 'available', @now, @now
+	FROM nodes WHERE zone_id = @source AND deleted_at IS NULL`
+
+// cloneProjectRequest is the body of a project clone. Name defaults to the source's name
+// with " (copy)"; budget and workflow default to the source's.
+type cloneProjectRequest struct {
+	Code        string     `json:"code"`
+	Name        string     `json:"name"`
+	Description *string    `json:"description"`
+	StartDate   *time.Time `json:"start_date"`
+	EndDate     *time.Time `json:"end_date"`
+	TotalBudget *float64   `json:"total_budget"`
+	WorkflowID  *uuid.UUID `json:"workflow_id"`
+}
+
+// shiftDate moves t by offset, keeping nil as nil
+func shiftDate(t *time.Time, offset time.Duration) *time.Time {
+	if t == nil {
+		return nil
+	}
+	shifted := t.Add(offset)
+	return &shifted
+}
+
+// CloneProject copies a project's layout into a new project with a new code, so a proven
+// layout such as a solar farm can be repeated: its zones and nodes with their geometry,
+// its budget allocation plan and its workflow. Nothing that records work done is copied:
+// no tasks, actual dates, spent amounts, progress or approvals. A new start_date shifts
+// the end date and the allocations' dates by the same amount.
+// POST /api/v1/projects/{id}/clone
+func (h *ProjectHandler) CloneProject(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	sourceID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	var req cloneProjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	req.Code = strings.TrimSpace(req.Code)
+	if req.Code == "" {
+		http.Error(w, "Code is required", http.StatusBadRequest)
+		return
+	}
+
+	var source models.Project
+	if err := h.scopedDB(r).First(&source, "id = ? AND deleted_at IS NULL", sourceID).Error; err != nil {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+
+	project := models.Project{
+		Code:               req.Code,
+		Name:               strings.TrimSpace(req.Name),
+		Description:        source.Description,
+		BusinessVerticalID: source.BusinessVerticalID,
+		KMZFileName:        source.KMZFileName,
+		KMZFilePath:        source.KMZFilePath,
+		KMZUploadedAt:      source.KMZUploadedAt,
+		GeoJSONData:        source.GeoJSONData,
+		StartDate:          source.StartDate,
+		EndDate:            source.EndDate,
+		TotalBudget:        source.TotalBudget,
+		AllocatedBudget:    source.AllocatedBudget,
+		Currency:           source.Currency,
+		ExchangeRate:       source.ExchangeRate,
+		CostCenterID:       source.CostCenterID,
+		Status:             "draft",
+		WorkflowID:         source.WorkflowID,
+		BlueprintID:        source.BlueprintID,
+		CreatedBy:          claims.UserID,
+	}
+	if project.Name == "" {
+		project.Name = source.Name + " (copy)"
+	}
+	if req.Description != nil {
+		project.Description = *req.Description
+	}
+	if req.TotalBudget != nil {
+		project.TotalBudget = *req.TotalBudget
+	}
+	if req.WorkflowID != nil {
+		project.WorkflowID = req.WorkflowID
+	}
+	var offset time.Duration
+	if req.StartDate != nil {
+		if source.StartDate != nil {
+			offset = req.StartDate.Sub(*source.StartDate)
+		}
+		project.StartDate = req.StartDate
+		project.EndDate = shiftDate(source.EndDate, offset)
+	}
+	if req.EndDate != nil {
+		project.EndDate = req.EndDate
+	}
+
+	var zones []models.Zone
+	if err := middleware.WithDataScope(r, h.db).Select("id", "name").Where("project_id = ? AND deleted_at IS NULL", source.ID).Order("code ASC").Find(&zones).Error; err != nil {
+		http.Error(w, "Failed to load zones", http.StatusInternalServerError)
+		return
+	}
+	var allocations []models.BudgetAllocation
+	if err := middleware.WithDataScope(r, h.db).Where("project_id = ? AND task_id IS NULL AND deleted_at IS NULL AND status <> ?", source.ID, "cancelled").
+		Order("created_at ASC").Find(&allocations).Error; err != nil {
+		http.Error(w, "Failed to load budget allocations", http.StatusInternalServerError)
+		return
+	}
+
+	var nodeCount int64
+	clonedAllocations := make([]models.BudgetAllocation, 0, len(allocations))
+	err = middleware.WithDataScope(r, h.db).Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Model(&models.Project{}).Where("code = ?", project.Code).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return apiError{status: http.StatusConflict, message: "project code already exists"}
+		}
+		if project.WorkflowID != nil {
+			var count int64
+			if err := tx.Model(&models.WorkflowDefinition{}).Where("id = ?", *project.WorkflowID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				return apiError{status: http.StatusBadRequest, message: "workflow not found"}
+			}
+		}
+
+		if err := tx.Create(&project).Error; err != nil {
+			return fmt.Errorf("create project: %w", err)
+		}
+
+		now := time.Now()
+		for _, zone := range zones {
+			zoneID := uuid.New()
+			args := map[string]interface{}{"id": zoneID, "zone": zoneID, "project": project.ID, "source": zone.ID, "now": now}
+			if err := tx.Exec(cloneZoneSQL, args).Error; err != nil {
+				return fmt.Errorf("copy zone %s: %w", zone.Name, err)
+			}
+			result := tx.Exec(cloneZoneNodesSQL, args)
+			if result.Error != nil {
+				return fmt.Errorf("copy nodes of zone %s: %w", zone.Name, result.Error)
+			}
+			nodeCount += result.RowsAffected
+		}
+
+		for _, allocation := range allocations {
+			clone := models.BudgetAllocation{
+				ProjectID:      &project.ID,
+				Category:       allocation.Category,
+				Description:    allocation.Description,
+				PlannedAmount:  allocation.PlannedAmount,
+				Currency:       allocation.Currency,
+				ExchangeRate:   allocation.ExchangeRate,
+				AllocationDate: now,
+				StartDate:      shiftDate(allocation.StartDate, offset),
+				EndDate:        shiftDate(allocation.EndDate, offset),
+				Status:         "allocated",
+				Notes:          allocation.Notes,
+				CreatedBy:      claims.UserID,
+			}
+			if err := tx.Create(&clone).Error; err != nil {
+				return fmt.Errorf("copy budget allocation %s: %w", allocation.Category, err)
+			}
+			clonedAllocations = append(clonedAllocations, clone)
+		}
+		return nil
+	})
+	if err != nil {
+		if ae, ok := err.(apiError); ok {
+			http.Error(w, ae.message, ae.status)
+			return
+		}
+		log.Printf("❌ Failed to clone project %s: %v", source.ID, err)
+		http.Error(w, "Failed to clone project", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("✅ Cloned project %s into %s (ID: %s)", source.Code, project.Code, project.ID)
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"message":            "Project cloned successfully",
+		"project":            project,
+		"source_project_id":  source.ID,
+		"zones_count":        len(zones),
+		"nodes_count":        nodeCount,
+		"budget_allocations": clonedAllocations,
+	})
+}
@@ -45,6 +45,11 @@ func NewProjectHandler() *ProjectHandler {
 	}
 }
 
+// scopedDB limits queries to the projects of the caller's verticals and sites
+func (h *ProjectHandler) scopedDB(r *http.Request) *gorm.DB {
+	return middleware.WithDataScope(r, h.db)
+}
+
 // CreateProjectRequest represents the request to create a project
 type CreateProjectRequest struct {
 	Code               string     `json:"code"`
@@ -55,17 +60,27 @@ type CreateProjectRequest struct {
 	EndDate            *time.Time `json:"end_date"`
 	TotalBudget        float64    `json:"total_budget"`
 	Currency           string     `json:"currency"`
+	CostCenterID       *uuid.UUID `json:"cost_center_id"`
+	ClientName         string     `json:"client_name"`
+	ClientGSTIN        string     `json:"client_gstin"`
+	PlaceOfSupply      string     `json:"place_of_supply"`
 }
 
 // UpdateProjectRequest represents the request to update a project
 type UpdateProjectRequest struct {
-	Name        string     `json:"name"`
-	Description string     `json:"description"`
-	StartDate   *time.Time `json:"start_date"`
-	EndDate     *time.Time `json:"end_date"`
-	TotalBudget float64    `json:"total_budget"`
-	Status      string     `json:"status"`
-	Progress    float64    `json:"progress"`
+	Name         string     `json:"name"`
+	Description  string     `json:"description"`
+	StartDate    *time.Time `json:"start_date"`
+	EndDate      *time.Time `json:"end_date"`
+	TotalBudget  float64    `json:"total_budget"`
+	Status       string     `json:"status"`
+	Progress     float64    `json:"progress"`
+	CostCenterID *uuid.UUID `json:"cost_center_id"`
+
+	// Client details; an empty string clears them
+	ClientName    *string `json:"client_name"`
+	ClientGSTIN   *string `json:"client_gstin"`
+	PlaceOfSupply *string `json:"place_of_supply"`
 }
 
 // CreateProject creates a new project
@@ -86,6 +101,22 @@ func (h *ProjectHandler) CreateProject(w http.ResponseWriter, r *http.Request) {
 	claims := middleware.GetClaims(r)
 	userID := claims.UserID
 
+	// The budget converts to the base currency at today's rate
+	currency, rate, err := transactionCurrency(middleware.ScopedDB(r), req.Currency, time.Now())
+	if err != nil {
+		writeProcurementErr(w, err, "Failed to create project")
+		return
+	}
+	if err := checkCostCenter(middleware.ScopedDB(r), req.BusinessVerticalID, req.CostCenterID); err != nil {
+		writeProcurementErr(w, err, "Failed to create project")
+		return
+	}
+	req.ClientGSTIN, req.PlaceOfSupply = normalizeGSTCode(req.ClientGSTIN), normalizeGSTCode(req.PlaceOfSupply)
+	if err := checkGSTClient(req.ClientGSTIN, req.PlaceOfSupply); err != nil {
+		writeProcurementErr(w, err, "Failed to create project")
+		return
+	}
+
 	// Create project
 	project := models.Project{
 		Code:               req.Code,
@@ -95,19 +126,20 @@ func (h *ProjectHandler) CreateProject(w http.ResponseWriter, r *http.Request) {
 		StartDate:          req.StartDate,
 		EndDate:            req.EndDate,
 		TotalBudget:        req.TotalBudget,
-		Currency:           req.Currency,
+		Currency:           currency,
+		ExchangeRate:       rate,
+		CostCenterID:       req.CostCenterID,
+		ClientName:         strings.TrimSpace(req.ClientName),
+		ClientGSTIN:        req.ClientGSTIN,
+		PlaceOfSupply:      req.PlaceOfSupply,
 		Status:             "draft",
 		Progress:           0,
 		CreatedBy:          userID,
 	}
 
-	if project.Currency == "" {
-		project.Currency = "INR"
-	}
-
-	if err := h.db.Create(&project).Error; err != nil {
+	if err := h.scopedDB(r).Create(&project).Error; err != nil {
 		log.Printf("❌ Failed to create project: %v", err)
-		http.Error(w, "Failed to create project", http.StatusInternalServerError)
+		http.Error(w, "Failed to create project", middleware.DataScopeStatus(err))
 		return
 	}
 
@@ -126,7 +158,7 @@ func (h *ProjectHandler) UploadKMZ(w http.ResponseWriter, r *http.Request) {
 
 	// Get project
 	var project models.Project
-	if err := h.db.First(&project, "id = ?", projectID).Error; err != nil {
+	if err := h.scopedDB(r).First(&project, "id = ?", projectID).Error; err != nil {
 		http.Error(w, "Project not found", http.StatusNotFound)
 		return
 	}
@@ -161,7 +193,7 @@ func (h *ProjectHandler) UploadKMZ(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Start transaction
-	tx := h.db.Begin()
+	tx := h.scopedDB(r).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -309,7 +341,7 @@ func (h *ProjectHandler) GetProject(w http.ResponseWriter, r *http.Request) {
 	projectID := vars["id"]
 
 	var project models.Project
-	if err := h.db.
+	if err := h.scopedDB(r).
 		Preload("BusinessVertical").
 		Preload("Zones").
 		Preload("Tasks").
@@ -326,7 +358,7 @@ func (h *ProjectHandler) GetProject(w http.ResponseWriter, r *http.Request) {
 func (h *ProjectHandler) ListProjects(w http.ResponseWriter, r *http.Request) {
 	var projects []models.Project
 
-	query := h.db.Preload("BusinessVertical")
+	query := h.scopedDB(r).Preload("BusinessVertical")
 
 	// Apply filters
 	if status := r.URL.Query().Get("status"); status != "" {
@@ -360,7 +392,7 @@ func (h *ProjectHandler) UpdateProject(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var project models.Project
-	if err := h.db.First(&project, "id = ?", projectID).Error; err != nil {
+	if err := h.scopedDB(r).First(&project, "id = ?", projectID).Error; err != nil {
 		http.Error(w, "Project not found", http.StatusNotFound)
 		return
 	}
@@ -391,10 +423,30 @@ func (h *ProjectHandler) UpdateProject(w http.ResponseWriter, r *http.Request) {
 	if req.Progress >= 0 {
 		project.Progress = req.Progress
 	}
+	if req.CostCenterID != nil {
+		if err := checkCostCenter(middleware.ScopedDB(r), project.BusinessVerticalID, req.CostCenterID); err != nil {
+			writeProcurementErr(w, err, "Failed to update project")
+			return
+		}
+		project.CostCenterID = req.CostCenterID
+	}
+	if req.ClientName != nil {
+		project.ClientName = strings.TrimSpace(*req.ClientName)
+	}
+	if req.ClientGSTIN != nil {
+		project.ClientGSTIN = normalizeGSTCode(*req.ClientGSTIN)
+	}
+	if req.PlaceOfSupply != nil {
+		project.PlaceOfSupply = normalizeGSTCode(*req.PlaceOfSupply)
+	}
+	if err := checkGSTClient(project.ClientGSTIN, project.PlaceOfSupply); err != nil {
+		writeProcurementErr(w, err, "Failed to update project")
+		return
+	}
 
 	project.UpdatedBy = userID
 
-	if err := h.db.Save(&project).Error; err != nil {
+	if err := h.scopedDB(r).Save(&project).Error; err != nil {
 		http.Error(w, "Failed to update project", http.StatusInternalServerError)
 		return
 	}
@@ -413,12 +465,12 @@ func (h *ProjectHandler) DeleteProject(w http.ResponseWriter, r *http.Request) {
 	projectID := vars["id"]
 
 	var project models.Project
-	if err := h.db.First(&project, "id = ?", projectID).Error; err != nil {
+	if err := h.scopedDB(r).First(&project, "id = ?", projectID).Error; err != nil {
 		http.Error(w, "Project not found", http.StatusNotFound)
 		return
 	}
 
-	if err := h.db.Delete(&project).Error; err != nil {
+	if err := h.scopedDB(r).Delete(&project).Error; err != nil {
 		http.Error(w, "Failed to delete project", http.StatusInternalServerError)
 		return
 	}
@@ -436,7 +488,7 @@ func (h *ProjectHandler) GetProjectZones(w http.ResponseWriter, r *http.Request)
 	projectID := vars["id"]
 
 	var zones []models.Zone
-	if err := h.db.Where("project_id = ?", projectID).Find(&zones).Error; err != nil {
+	if err := h.scopedDB(r).Where("project_id = ?", projectID).Find(&zones).Error; err != nil {
 		http.Error(w, "Failed to fetch zones", http.StatusInternalServerError)
 		return
 	}
@@ -454,7 +506,7 @@ func (h *ProjectHandler) GetProjectNodes(w http.ResponseWriter, r *http.Request)
 	projectID := vars["id"]
 
 	var nodes []models.Node
-	query := h.db.Where("project_id = ?", projectID)
+	query := h.scopedDB(r).Where("project_id = ?", projectID)
 
 	// Filter by node type
 	if nodeType := r.URL.Query().Get("node_type"); nodeType != "" {
@@ -484,7 +536,7 @@ func (h *ProjectHandler) GetProjectGeoJSON(w http.ResponseWriter, r *http.Reques
 	projectID := vars["id"]
 
 	var project models.Project
-	if err := h.db.First(&project, "id = ?", projectID).Error; err != nil {
+	if err := h.scopedDB(r).First(&project, "id = ?", projectID).Error; err != nil {
 		http.Error(w, "Project not found", http.StatusNotFound)
 		return
 	}
@@ -511,24 +563,24 @@ func (h *ProjectHandler) GetProjectStats(w http.ResponseWriter, r *http.Request)
 	projectID := vars["id"]
 
 	var project models.Project
-	if err := h.db.First(&project, "id = ?", projectID).Error; err != nil {
+	if err := h.scopedDB(r).First(&project, "id = ?", projectID).Error; err != nil {
 		http.Error(w, "Project not found", http.StatusNotFound)
 		return
 	}
 
 	// Count zones
 	var zoneCount int64
-	h.db.Model(&models.Zone{}).Where("project_id = ?", projectID).Count(&zoneCount)
+	h.scopedDB(r).Model(&models.Zone{}).Where("project_id = ?", projectID).Count(&zoneCount)
 
 	// Count nodes by type
 	var totalNodes int64
-	h.db.Model(&models.Node{}).Where("project_id = ?", projectID).Count(&totalNodes)
+	h.scopedDB(r).Model(&models.Node{}).Where("project_id = ?", projectID).Count(&totalNodes)
 
 	var nodeStats []struct {
 		NodeType string
 		Count    int64
 	}
-	h.db.Model(&models.Node{}).
+	h.scopedDB(r).Model(&models.Node{}).
 		Select("node_type, count(*) as count").
 		Where("project_id = ?", projectID).
 		Group("node_type").
@@ -536,13 +588,13 @@ func (h *ProjectHandler) GetProjectStats(w http.ResponseWriter, r *http.Request)
 
 	// Count tasks by status
 	var totalTasks int64
-	h.db.Model(&models.Tasks{}).Where("project_id = ?", projectID).Count(&totalTasks)
+	h.scopedDB(r).Model(&models.Tasks{}).Where("project_id = ?", projectID).Count(&totalTasks)
 
 	var taskStats []struct {
 		Status string
 		Count  int64
 	}
-	h.db.Model(&models.Tasks{}).
+	h.scopedDB(r).Model(&models.Tasks{}).
 		Select("status, count(*) as count").
 		Where("project_id = ?", projectID).
 		Group("status").
@@ -561,13 +613,14 @@ func (h *ProjectHandler) GetProjectStats(w http.ResponseWriter, r *http.Request)
 		completionPercentage = (float64(completedTasks) / float64(totalTasks)) * 100
 	}
 
-	// Budget stats
+	// Budget stats, in the project's currency
 	var budgetStats struct {
 		TotalAllocated float64
 		TotalSpent     float64
 	}
-	h.db.Model(&models.BudgetAllocation{}).
-		Select("COALESCE(SUM(planned_amount), 0) as total_allocated, COALESCE(SUM(actual_amount), 0) as total_spent").
+	projectRate := projectExchangeRate(h.scopedDB(r), &project.ID, nil)
+	h.scopedDB(r).Model(&models.BudgetAllocation{}).
+		Select("COALESCE(SUM(base_planned_amount), 0) / ? as total_allocated, COALESCE(SUM(base_actual_amount), 0) / ? as total_spent", projectRate, projectRate).
 		Where("project_id = ?", projectID).
 		Scan(&budgetStats)
 
@@ -577,6 +630,7 @@ func (h *ProjectHandler) GetProjectStats(w http.ResponseWriter, r *http.Request)
 		"total_zones":      zoneCount,
 		"total_nodes":      totalNodes,
 		"total_tasks":      totalTasks,
+		"currency":         project.Currency,
 		"total_budget":     project.TotalBudget,
 		"allocated_budget": budgetStats.TotalAllocated,
 		"spent_budget":     budgetStats.TotalSpent,
@@ -78,7 +78,7 @@ func (h *ProjectPhase1Handler) CreateWBSNode(w http.ResponseWriter, r *http.Requ
 		CreatedBy:        claims.UserID,
 	}
 
-	if err := h.db.Create(&node).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).Create(&node).Error; err != nil {
 		http.Error(w, "failed to create WBS node", http.StatusInternalServerError)
 		return
 	}
@@ -93,7 +93,7 @@ func (h *ProjectPhase1Handler) ListWBSNodes(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	query := h.db.Where("project_id = ?", project.ID).Order("sort_order ASC, code ASC")
+	query := middleware.WithDataScope(r, h.db).Where("project_id = ?", project.ID).Order("sort_order ASC, code ASC")
 	if parentID := r.URL.Query().Get("parent_id"); parentID != "" {
 		query = query.Where("parent_id = ?", parentID)
 	}
@@ -144,7 +144,7 @@ func (h *ProjectPhase1Handler) CreateTaskDependency(w http.ResponseWriter, r *ht
 	}
 
 	var count int64
-	if err := h.db.Model(&models.Tasks{}).Where("project_id = ? AND id IN ?", project.ID, []uuid.UUID{req.PredecessorTaskID, req.SuccessorTaskID}).Count(&count).Error; err != nil || count != 2 {
+	if err := middleware.WithDataScope(r, h.db).Model(&models.Tasks{}).Where("project_id = ? AND id IN ?", project.ID, []uuid.UUID{req.PredecessorTaskID, req.SuccessorTaskID}).Count(&count).Error; err != nil || count != 2 {
 		http.Error(w, "tasks must belong to the same project", http.StatusBadRequest)
 		return
 	}
@@ -160,7 +160,23 @@ func (h *ProjectPhase1Handler) CreateTaskDependency(w http.ResponseWriter, r *ht
 		CreatedBy:         claims.UserID,
 	}
 
-	if err := h.db.Create(&dep).Error; err != nil {
+	var existing []models.TaskDependency
+	if err := middleware.WithDataScope(r, h.db).Where("project_id = ? AND is_active = ?", project.ID, true).Find(&existing).Error; err != nil {
+		http.Error(w, "failed to load task dependencies", http.StatusInternalServerError)
+		return
+	}
+	for _, other := range existing {
+		if other.PredecessorTaskID == dep.PredecessorTaskID && other.SuccessorTaskID == dep.SuccessorTaskID {
+			http.Error(w, "tasks already have a dependency", http.StatusConflict)
+			return
+		}
+	}
+	if models.DependencyCreatesCycle(existing, dep) {
+		http.Error(w, "dependency would create a cycle", http.StatusConflict)
+		return
+	}
+
+	if err := middleware.WithDataScope(r, h.db).Create(&dep).Error; err != nil {
 		http.Error(w, "failed to create task dependency", http.StatusInternalServerError)
 		return
 	}
@@ -175,7 +191,7 @@ func (h *ProjectPhase1Handler) ListTaskDependencies(w http.ResponseWriter, r *ht
 		return
 	}
 
-	query := h.db.Where("project_id = ?", project.ID).Order("created_at DESC")
+	query := middleware.WithDataScope(r, h.db).Where("project_id = ?", project.ID).Order("created_at DESC")
 	if taskID := r.URL.Query().Get("task_id"); taskID != "" {
 		query = query.Where("predecessor_task_id = ? OR successor_task_id = ?", taskID, taskID)
 	}
@@ -189,6 +205,126 @@ func (h *ProjectPhase1Handler) ListTaskDependencies(w http.ResponseWriter, r *ht
 	h.writeJSON(w, http.StatusOK, map[string]interface{}{"task_dependencies": deps, "count": len(deps)})
 }
 
+// GetProjectSchedule computes the project's critical path schedule from its tasks'
+// planned starts and durations and their active dependencies: each task's earliest and
+// latest start and finish, its slack, and the critical path.
+func (h *ProjectPhase1Handler) GetProjectSchedule(w http.ResponseWriter, r *http.Request) {
+	project, _, err := h.requireProjectScope(r)
+	if err != nil {
+		h.writeErr(w, err)
+		return
+	}
+
+	var tasks []models.Tasks
+	if err := middleware.WithDataScope(r, h.db).Where("project_id = ? AND deleted_at IS NULL AND status <> ?", project.ID, "cancelled").Find(&tasks).Error; err != nil {
+		http.Error(w, "failed to load tasks", http.StatusInternalServerError)
+		return
+	}
+	var deps []models.TaskDependency
+	if err := middleware.WithDataScope(r, h.db).Where("project_id = ? AND is_active = ?", project.ID, true).Find(&deps).Error; err != nil {
+		http.Error(w, "failed to load task dependencies", http.StatusInternalServerError)
+		return
+	}
+
+	scheduleTasks := make([]models.ScheduleTask, len(tasks))
+	for i, task := range tasks {
+		scheduleTasks[i] = models.NewScheduleTask(task)
+	}
+	schedule, err := models.ScheduleTasks(scheduleTasks, deps)
+	if errors.Is(err, models.ErrDependencyCycle) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to compute schedule", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{"project_id": project.ID, "schedule": schedule})
+}
+
+// GetProjectGantt returns the project's tasks laid out for a Gantt chart: each task's
+// baseline and actual dates with how far behind it is, its active assignees, and the
+// dependencies between the tasks returned. ?zone_id= and ?assignee= (a user ID) narrow
+// the tasks.
+// GET /api/v1/projects/{id}/gantt
+func (h *ProjectPhase1Handler) GetProjectGantt(w http.ResponseWriter, r *http.Request) {
+	project, _, err := h.requireProjectScope(r)
+	if err != nil {
+		h.writeErr(w, err)
+		return
+	}
+
+	query := middleware.WithDataScope(r, h.db).Where("tasks.project_id = ? AND tasks.deleted_at IS NULL", project.ID)
+	if raw := r.URL.Query().Get("zone_id"); raw != "" {
+		zoneID, err := uuid.Parse(raw)
+		if err != nil {
+			http.Error(w, "invalid zone_id", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("tasks.zone_id = ?", zoneID)
+	}
+	if assignee := strings.TrimSpace(r.URL.Query().Get("assignee")); assignee != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM task_assignments ta WHERE ta.task_id = tasks.id AND ta.user_id = ? AND ta.is_active = ?)", assignee, true)
+	}
+	var tasks []models.Tasks
+	if err := query.Order("COALESCE(tasks.planned_start_date, tasks.start_date), tasks.code").Find(&tasks).Error; err != nil {
+		http.Error(w, "failed to load tasks", http.StatusInternalServerError)
+		return
+	}
+
+	ids := make([]uuid.UUID, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+	var assignments []models.TaskAssignment
+	var deps []models.TaskDependency
+	if len(ids) > 0 {
+		if err := middleware.WithDataScope(r, h.db).Where("task_id IN ? AND is_active = ?", ids, true).Order("assigned_at").Find(&assignments).Error; err != nil {
+			http.Error(w, "failed to load task assignments", http.StatusInternalServerError)
+			return
+		}
+		if err := middleware.WithDataScope(r, h.db).Where("project_id = ? AND is_active = ?", project.ID, true).Find(&deps).Error; err != nil {
+			http.Error(w, "failed to load task dependencies", http.StatusInternalServerError)
+			return
+		}
+	}
+	assignees := map[uuid.UUID][]models.GanttAssignee{}
+	for _, assignment := range assignments {
+		assignees[assignment.TaskID] = append(assignees[assignment.TaskID], models.GanttAssignee{
+			UserID:   assignment.UserID,
+			UserName: assignment.UserName,
+			Role:     assignment.Role,
+		})
+	}
+
+	now := time.Now()
+	gantt := make([]models.GanttTask, len(tasks))
+	var start, end *time.Time
+	for i, task := range tasks {
+		gantt[i] = models.NewGanttTask(task, now)
+		if list, ok := assignees[task.ID]; ok {
+			gantt[i].Assignees = list
+		}
+		for _, bar := range []models.GanttBar{gantt[i].Baseline, gantt[i].Actual} {
+			if bar.Start != nil && (start == nil || bar.Start.Before(*start)) {
+				start = bar.Start
+			}
+			if bar.End != nil && (end == nil || bar.End.After(*end)) {
+				end = bar.End
+			}
+		}
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"project_id": project.ID,
+		"start":      start,
+		"end":        end,
+		"tasks":      gantt,
+		"links":      models.NewGanttLinks(gantt, deps),
+	})
+}
+
 func (h *ProjectPhase1Handler) CreateBOQItem(w http.ResponseWriter, r *http.Request) {
 	project, claims, err := h.requireProjectScope(r)
 	if err != nil {
@@ -236,7 +372,7 @@ func (h *ProjectPhase1Handler) CreateBOQItem(w http.ResponseWriter, r *http.Requ
 		CreatedBy:       claims.UserID,
 	}
 
-	if err := h.db.Create(&item).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).Create(&item).Error; err != nil {
 		http.Error(w, "failed to create BOQ item", http.StatusInternalServerError)
 		return
 	}
@@ -251,7 +387,7 @@ func (h *ProjectPhase1Handler) ListBOQItems(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	query := h.db.Where("project_id = ?", project.ID).Order("code ASC")
+	query := middleware.WithDataScope(r, h.db).Where("project_id = ?", project.ID).Order("code ASC")
 	if status := r.URL.Query().Get("status"); status != "" {
 		query = query.Where("status = ?", strings.ToLower(status))
 	}
@@ -265,6 +401,9 @@ func (h *ProjectPhase1Handler) ListBOQItems(w http.ResponseWriter, r *http.Reque
 	h.writeJSON(w, http.StatusOK, map[string]interface{}{"boq_items": items, "count": len(items)})
 }
 
+// CreateMBEntry records a measurement against a BOQ item, optionally for one of the
+// project's tasks. Its quantity is the total of its dimension lines when it has any. The
+// entry starts prepared and counts towards the item's executed quantity once approved.
 func (h *ProjectPhase1Handler) CreateMBEntry(w http.ResponseWriter, r *http.Request) {
 	project, claims, err := h.requireProjectScope(r)
 	if err != nil {
@@ -272,78 +411,30 @@ func (h *ProjectPhase1Handler) CreateMBEntry(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	var req struct {
-		BOQItemID       uuid.UUID  `json:"boq_item_id"`
-		EntryNumber     string     `json:"entry_number"`
-		MeasurementDate *time.Time `json:"measurement_date"`
-		MeasuredQty     float64    `json:"measured_qty"`
-		Rate            float64    `json:"rate"`
-		Amount          float64    `json:"amount"`
-		LocationRef     string     `json:"location_ref"`
-		Remarks         string     `json:"remarks"`
-	}
+	var req mbEntryRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid JSON", http.StatusBadRequest)
 		return
 	}
-	if req.BOQItemID == uuid.Nil || strings.TrimSpace(req.EntryNumber) == "" || req.MeasuredQty <= 0 {
-		http.Error(w, "boq_item_id, entry_number and positive measured_qty are required", http.StatusBadRequest)
-		return
-	}
-
-	var boq models.BOQItem
-	if err := h.db.First(&boq, "id = ? AND project_id = ?", req.BOQItemID, project.ID).Error; err != nil {
-		http.Error(w, "BOQ item not found", http.StatusBadRequest)
+	if req.BOQItemID == uuid.Nil || strings.TrimSpace(req.EntryNumber) == "" {
+		http.Error(w, "boq_item_id and entry_number are required", http.StatusBadRequest)
 		return
 	}
 
-	measureDate := time.Now().UTC()
-	if req.MeasurementDate != nil {
-		measureDate = req.MeasurementDate.UTC()
-	}
-
-	rate := req.Rate
-	if rate == 0 {
-		rate = boq.UnitRate
-	}
-	amount := req.Amount
-	if amount == 0 {
-		amount = req.MeasuredQty * rate
-	}
-
 	entry := models.MBEntry{
 		ProjectID:       project.ID,
-		BOQItemID:       boq.ID,
 		EntryNumber:     strings.TrimSpace(req.EntryNumber),
-		MeasurementDate: measureDate,
-		MeasuredQty:     req.MeasuredQty,
-		Rate:            rate,
-		Amount:          amount,
-		LocationRef:     strings.TrimSpace(req.LocationRef),
-		Remarks:         req.Remarks,
+		MeasurementDate: time.Now().UTC(),
+		Status:          models.MBStatusPrepared,
 		RecordedBy:      claims.UserID,
 	}
-
-	tx := h.db.Begin()
-	if err := tx.Create(&entry).Error; err != nil {
-		tx.Rollback()
-		http.Error(w, "failed to create MB entry", http.StatusInternalServerError)
-		return
-	}
-
-	if err := tx.Model(&models.BOQItem{}).
-		Where("id = ?", boq.ID).
-		Updates(map[string]interface{}{
-			"executed_quantity": gorm.Expr("executed_quantity + ?", req.MeasuredQty),
-			"updated_by":        claims.UserID,
-		}).Error; err != nil {
-		tx.Rollback()
-		http.Error(w, "failed to update BOQ quantity", http.StatusInternalServerError)
+	if err := h.applyMBEntryRequest(project.ID, &entry, req); err != nil {
+		h.writeErr(w, err)
 		return
 	}
 
-	if err := tx.Commit().Error; err != nil {
-		http.Error(w, "failed to commit MB entry", http.StatusInternalServerError)
+	if err := middleware.WithDataScope(r, h.db).Create(&entry).Error; err != nil {
+		http.Error(w, "failed to create MB entry", http.StatusInternalServerError)
 		return
 	}
 
@@ -357,10 +448,19 @@ func (h *ProjectPhase1Handler) ListMBEntries(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	query := h.db.Where("project_id = ?", project.ID).Order("measurement_date DESC")
+	query := middleware.WithDataScope(r, h.db).Where("project_id = ?", project.ID).Order("measurement_date DESC")
 	if boqItemID := r.URL.Query().Get("boq_item_id"); boqItemID != "" {
 		query = query.Where("boq_item_id = ?", boqItemID)
 	}
+	if taskID := r.URL.Query().Get("task_id"); taskID != "" {
+		query = query.Where("task_id = ?", taskID)
+	}
+	if status := r.URL.Query().Get("status"); status != "" {
+		query = query.Where("status = ?", strings.ToLower(status))
+	}
+	if r.URL.Query().Get("unbilled") == "true" {
+		query = query.Where("ra_bill_id IS NULL")
+	}
 
 	var entries []models.MBEntry
 	if err := query.Find(&entries).Error; err != nil {
@@ -420,7 +520,7 @@ func (h *ProjectPhase1Handler) CreateRABill(w http.ResponseWriter, r *http.Reque
 		CreatedBy:        claims.UserID,
 	}
 
-	if err := h.db.Create(&bill).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).Create(&bill).Error; err != nil {
 		http.Error(w, "failed to create RA bill", http.StatusInternalServerError)
 		return
 	}
@@ -435,7 +535,7 @@ func (h *ProjectPhase1Handler) ListRABills(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	query := h.db.Where("project_id = ?", project.ID).Order("created_at DESC")
+	query := middleware.WithDataScope(r, h.db).Where("project_id = ?", project.ID).Order("created_at DESC")
 	if status := r.URL.Query().Get("status"); status != "" {
 		query = query.Where("status = ?", strings.ToLower(status))
 	}
@@ -463,7 +563,7 @@ func (h *ProjectPhase1Handler) GetRABill(w http.ResponseWriter, r *http.Request)
 	}
 
 	var bill models.RABill
-	if err := h.db.Preload("Lines").First(&bill, "id = ? AND project_id = ?", billID, project.ID).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).Preload("Lines").First(&bill, "id = ? AND project_id = ?", billID, project.ID).Error; err != nil {
 		http.Error(w, "RA bill not found", http.StatusNotFound)
 		return
 	}
@@ -503,7 +603,7 @@ func (h *ProjectPhase1Handler) AddRABillLine(w http.ResponseWriter, r *http.Requ
 	}
 
 	var bill models.RABill
-	if err := h.db.First(&bill, "id = ? AND project_id = ?", billID, project.ID).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).First(&bill, "id = ? AND project_id = ?", billID, project.ID).Error; err != nil {
 		http.Error(w, "RA bill not found", http.StatusNotFound)
 		return
 	}
@@ -513,11 +613,23 @@ func (h *ProjectPhase1Handler) AddRABillLine(w http.ResponseWriter, r *http.Requ
 	}
 
 	var boq models.BOQItem
-	if err := h.db.First(&boq, "id = ? AND project_id = ?", req.BOQItemID, project.ID).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).First(&boq, "id = ? AND project_id = ?", req.BOQItemID, project.ID).Error; err != nil {
 		http.Error(w, "BOQ item not found", http.StatusBadRequest)
 		return
 	}
 
+	if req.MBEntryID != nil {
+		var entry models.MBEntry
+		if err := middleware.WithDataScope(r, h.db).First(&entry, "id = ? AND project_id = ?", *req.MBEntryID, project.ID).Error; err != nil {
+			http.Error(w, "MB entry not found", http.StatusBadRequest)
+			return
+		}
+		if entry.BOQItemID != boq.ID {
+			http.Error(w, "MB entry is for another BOQ item", http.StatusBadRequest)
+			return
+		}
+	}
+
 	rate := req.Rate
 	if rate == 0 {
 		rate = boq.UnitRate
@@ -537,7 +649,23 @@ func (h *ProjectPhase1Handler) AddRABillLine(w http.ResponseWriter, r *http.Requ
 		LineRemark: req.Remark,
 	}
 
-	tx := h.db.Begin()
+	tx := middleware.WithDataScope(r, h.db).Begin()
+	if req.MBEntryID != nil {
+		// Claim the entry for this bill; it must be approved and not billed yet
+		result := tx.Model(&models.MBEntry{}).
+			Where("id = ? AND status = ? AND ra_bill_id IS NULL", *req.MBEntryID, models.MBStatusApproved).
+			Update("ra_bill_id", bill.ID)
+		if result.Error != nil {
+			tx.Rollback()
+			http.Error(w, "failed to bill MB entry", http.StatusInternalServerError)
+			return
+		}
+		if result.RowsAffected == 0 {
+			tx.Rollback()
+			http.Error(w, "only approved, unbilled MB entries can be billed", http.StatusConflict)
+			return
+		}
+	}
 	if err := tx.Create(&line).Error; err != nil {
 		tx.Rollback()
 		http.Error(w, "failed to add RA bill line", http.StatusInternalServerError)
@@ -611,7 +739,7 @@ func (h *ProjectPhase1Handler) transitionRABillStatus(w http.ResponseWriter, r *
 	}
 
 	var bill models.RABill
-	if err := h.db.First(&bill, "id = ? AND project_id = ?", billID, project.ID).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).First(&bill, "id = ? AND project_id = ?", billID, project.ID).Error; err != nil {
 		http.Error(w, "RA bill not found", http.StatusNotFound)
 		return
 	}
@@ -638,12 +766,12 @@ func (h *ProjectPhase1Handler) transitionRABillStatus(w http.ResponseWriter, r *
 		updates["payment_reference"] = strings.TrimSpace(r.URL.Query().Get("payment_reference"))
 	}
 
-	if err := h.db.Model(&bill).Updates(updates).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).Model(&bill).Updates(updates).Error; err != nil {
 		http.Error(w, "failed to update RA bill status", http.StatusInternalServerError)
 		return
 	}
 
-	if err := h.db.First(&bill, "id = ?", bill.ID).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).First(&bill, "id = ?", bill.ID).Error; err != nil {
 		http.Error(w, "failed to load RA bill", http.StatusInternalServerError)
 		return
 	}
@@ -676,7 +804,7 @@ func (h *ProjectPhase1Handler) requireProjectScope(r *http.Request) (*models.Pro
 		return nil, nil, apiError{status: http.StatusUnauthorized, message: "unauthorized"}
 	}
 
-	query := h.db.Model(&models.Project{}).Where("id = ?", projectID)
+	query := middleware.WithDataScope(r, h.db).Model(&models.Project{}).Where("id = ?", projectID)
 	if businessContext := middleware.GetUserBusinessContext(r); businessContext != nil {
 		if businessID, ok := businessContext["business_id"].(uuid.UUID); ok && businessID != uuid.Nil {
 			query = query.Where("business_vertical_id = ?", businessID)
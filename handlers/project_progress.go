@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/progress"
+)
+
+// rollupProjectProgress refreshes a project's progress and spent budget after one of
+// its tasks changed, then checks the new spend against the project's budget thresholds.
+// A failure is only logged: the task change already committed and the nightly
+// reconciliation catches the project up.
+func rollupProjectProgress(db *gorm.DB, projectID uuid.UUID) {
+	if _, err := progress.NewService(db).Rollup(projectID); err != nil {
+		log.Printf("❌ Failed to roll up progress of project %s: %v", projectID, err)
+		return
+	}
+	checkBudgetAlerts(db, projectID)
+}
+
+// GetProjectProgress returns the project's progress rolled up from its tasks: overall
+// progress weighted by task budget, completion per zone, and actual costs against the
+// budget.
+// GET /api/v1/projects/{id}/progress
+func (h *ProjectHandler) GetProjectProgress(w http.ResponseWriter, r *http.Request) {
+	projectID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	var project models.Project
+	if err := h.scopedDB(r).Select("id").First(&project, "id = ?", projectID).Error; err != nil {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+
+	breakdown, err := progress.NewService(middleware.WithDataScope(r, h.db)).Breakdown(projectID)
+	if err != nil {
+		log.Printf("❌ Failed to compute progress of project %s: %v", projectID, err)
+		http.Error(w, "Failed to compute project progress", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, breakdown)
+}
@@ -80,7 +80,7 @@ func (h *ProjectRoleHandler) CreateRole(w http.ResponseWriter, r *http.Request)
 		CreatedBy:    claims.UserID,
 	}
 
-	if err := h.db.Create(&role).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).Create(&role).Error; err != nil {
 		log.Printf("❌ Failed to create role: %v", err)
 		http.Error(w, "Failed to create role", http.StatusInternalServerError)
 		return
@@ -101,7 +101,7 @@ func (h *ProjectRoleHandler) GetRole(w http.ResponseWriter, r *http.Request) {
 	roleID := vars["id"]
 
 	var role models.ProjectRole
-	if err := h.db.Preload("ParentRole").First(&role, "id = ?", roleID).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).Preload("ParentRole").First(&role, "id = ?", roleID).Error; err != nil {
 		http.Error(w, "Role not found", http.StatusNotFound)
 		return
 	}
@@ -114,7 +114,7 @@ func (h *ProjectRoleHandler) GetRole(w http.ResponseWriter, r *http.Request) {
 func (h *ProjectRoleHandler) ListRoles(w http.ResponseWriter, r *http.Request) {
 	var roles []models.ProjectRole
 
-	query := h.db.Preload("ParentRole")
+	query := middleware.WithDataScope(r, h.db).Preload("ParentRole")
 
 	// Apply filters
 	if isActive := r.URL.Query().Get("is_active"); isActive != "" {
@@ -148,7 +148,7 @@ func (h *ProjectRoleHandler) UpdateRole(w http.ResponseWriter, r *http.Request)
 	}
 
 	var role models.ProjectRole
-	if err := h.db.First(&role, "id = ?", roleID).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).First(&role, "id = ?", roleID).Error; err != nil {
 		http.Error(w, "Role not found", http.StatusNotFound)
 		return
 	}
@@ -176,7 +176,7 @@ func (h *ProjectRoleHandler) UpdateRole(w http.ResponseWriter, r *http.Request)
 		role.IsActive = *req.IsActive
 	}
 
-	if err := h.db.Save(&role).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).Save(&role).Error; err != nil {
 		http.Error(w, "Failed to update role", http.StatusInternalServerError)
 		return
 	}
@@ -195,7 +195,7 @@ func (h *ProjectRoleHandler) DeleteRole(w http.ResponseWriter, r *http.Request)
 	roleID := vars["id"]
 
 	var role models.ProjectRole
-	if err := h.db.First(&role, "id = ?", roleID).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).First(&role, "id = ?", roleID).Error; err != nil {
 		http.Error(w, "Role not found", http.StatusNotFound)
 		return
 	}
@@ -208,7 +208,7 @@ func (h *ProjectRoleHandler) DeleteRole(w http.ResponseWriter, r *http.Request)
 
 	// Check if role is assigned to any users
 	var count int64
-	h.db.Model(&models.UserProjectRole{}).Where("role_id = ? AND is_active = ?", roleID, true).Count(&count)
+	middleware.WithDataScope(r, h.db).Model(&models.UserProjectRole{}).Where("role_id = ? AND is_active = ?", roleID, true).Count(&count)
 	if count > 0 {
 		http.Error(w, "Cannot delete role that is assigned to users", http.StatusBadRequest)
 		return
@@ -216,7 +216,7 @@ func (h *ProjectRoleHandler) DeleteRole(w http.ResponseWriter, r *http.Request)
 
 	// Soft delete
 	role.IsActive = false
-	if err := h.db.Save(&role).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).Save(&role).Error; err != nil {
 		http.Error(w, "Failed to delete role", http.StatusInternalServerError)
 		return
 	}
@@ -238,14 +238,14 @@ func (h *ProjectRoleHandler) AssignRoleToUser(w http.ResponseWriter, r *http.Req
 
 	// Verify project exists
 	var project models.Project
-	if err := h.db.First(&project, "id = ?", req.ProjectID).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).First(&project, "id = ?", req.ProjectID).Error; err != nil {
 		http.Error(w, "Project not found", http.StatusBadRequest)
 		return
 	}
 
 	// Verify role exists and is active
 	var role models.ProjectRole
-	if err := h.db.First(&role, "id = ? AND is_active = ?", req.RoleID, true).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).First(&role, "id = ? AND is_active = ?", req.RoleID, true).Error; err != nil {
 		http.Error(w, "Role not found or inactive", http.StatusBadRequest)
 		return
 	}
@@ -254,7 +254,7 @@ func (h *ProjectRoleHandler) AssignRoleToUser(w http.ResponseWriter, r *http.Req
 	claims := middleware.GetClaims(r)
 
 	// Start transaction
-	tx := h.db.Begin()
+	tx := middleware.WithDataScope(r, h.db).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -307,14 +307,14 @@ func (h *ProjectRoleHandler) RevokeRoleFromUser(w http.ResponseWriter, r *http.R
 	assignmentID := vars["id"]
 
 	var assignment models.UserProjectRole
-	if err := h.db.First(&assignment, "id = ?", assignmentID).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).First(&assignment, "id = ?", assignmentID).Error; err != nil {
 		http.Error(w, "Role assignment not found", http.StatusNotFound)
 		return
 	}
 
 	// Deactivate the assignment
 	assignment.IsActive = false
-	if err := h.db.Save(&assignment).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).Save(&assignment).Error; err != nil {
 		http.Error(w, "Failed to revoke role", http.StatusInternalServerError)
 		return
 	}
@@ -337,7 +337,7 @@ func (h *ProjectRoleHandler) GetUserProjectRoles(w http.ResponseWriter, r *http.
 	}
 
 	var assignments []models.UserProjectRole
-	query := h.db.Preload("Project").Preload("Role")
+	query := middleware.WithDataScope(r, h.db).Preload("Project").Preload("Role")
 
 	if err := query.Where("user_id = ? AND project_id = ? AND is_active = ?", userID, projectID, true).
 		Find(&assignments).Error; err != nil {
@@ -358,7 +358,7 @@ func (h *ProjectRoleHandler) GetProjectUsers(w http.ResponseWriter, r *http.Requ
 	projectID := vars["id"]
 
 	var assignments []models.UserProjectRole
-	if err := h.db.Preload("Role").
+	if err := middleware.WithDataScope(r, h.db).Preload("Role").
 		Where("project_id = ? AND is_active = ?", projectID, true).
 		Find(&assignments).Error; err != nil {
 		http.Error(w, "Failed to fetch project users", http.StatusInternalServerError)
@@ -385,7 +385,7 @@ func (h *ProjectRoleHandler) CheckUserPermission(w http.ResponseWriter, r *http.
 
 	// Get user's active role for the project
 	var assignment models.UserProjectRole
-	if err := h.db.Preload("Role").
+	if err := middleware.WithDataScope(r, h.db).Preload("Role").
 		Where("user_id = ? AND project_id = ? AND is_active = ?", userID, projectID, true).
 		First(&assignment).Error; err != nil {
 		w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/models"
+)
+
+// zoneBoundarySQL is a zone's boundary: its PostGIS geometry, or else the GeoJSON the KMZ
+// upload stored, which is a Feature or a bare geometry. Zones without either have none.
+const zoneBoundarySQL = `COALESCE(geometry, CASE
+		WHEN jsonb_typeof(geojson->'geometry') = 'object' THEN ST_SetSRID(ST_GeomFromGeoJSON((geojson->'geometry')::text), 4326)
+		WHEN geojson->'coordinates' IS NOT NULL THEN ST_SetSRID(ST_GeomFromGeoJSON(geojson::text), 4326)
+	END)`
+
+const (
+	// defaultNearbyRadius is how far, in meters, nearby searches look by default
+	defaultNearbyRadius = 500
+	// maxNearbyRadius caps the radius of nearby searches
+	maxNearbyRadius = 50000
+	// defaultNearbyLimit is how many nodes nearby searches return by default
+	defaultNearbyLimit = 20
+	// maxNearbyLimit caps the nodes one nearby search returns
+	maxNearbyLimit = 100
+)
+
+// spatialPoint reads the ?lat= and ?lng= of a spatial query
+func spatialPoint(values url.Values) (float64, float64, error) {
+	lat, err := strconv.ParseFloat(values.Get("lat"), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return 0, 0, fmt.Errorf("lat must be a latitude between -90 and 90")
+	}
+	lng, err := strconv.ParseFloat(values.Get("lng"), 64)
+	if err != nil || lng < -180 || lng > 180 {
+		return 0, 0, fmt.Errorf("lng must be a longitude between -180 and 180")
+	}
+	return lat, lng, nil
+}
+
+// spatialParam reads an optional positive number parameter, capped at max
+func spatialParam(values url.Values, name string, fallback, max float64) (float64, error) {
+	raw := values.Get(name)
+	if raw == "" {
+		return fallback, nil
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil || value <= 0 || value > max {
+		return 0, fmt.Errorf("%s must be a number above 0 and at most %v", name, max)
+	}
+	return value, nil
+}
+
+// nearbyNode is a node with its distance from the point searched around
+type nearbyNode struct {
+	models.Node
+	DistanceMeters float64 `gorm:"->;column:distance_meters" json:"distance_meters"`
+}
+
+// GetNearbyNodes returns the project's nodes within ?radius= meters (500 by default) of
+// ?lat= and ?lng=, nearest first, so the mobile map can show the closest start and stop
+// nodes. ?node_type= and ?status= narrow the nodes; ?limit= caps them (20 by default).
+// GET /api/v1/projects/{id}/nodes/near
+func (h *ProjectHandler) GetNearbyNodes(w http.ResponseWriter, r *http.Request) {
+	projectID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+	values := r.URL.Query()
+	lat, lng, err := spatialPoint(values)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	radius, err := spatialParam(values, "radius", defaultNearbyRadius, maxNearbyRadius)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, err := spatialParam(values, "limit", defaultNearbyLimit, maxNearbyLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var project models.Project
+	if err := h.scopedDB(r).Select("id").First(&project, "id = ?", projectID).Error; err != nil {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+
+	point := "ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography"
+	query := h.scopedDB(r).
+		Select("nodes.*, ST_Distance(nodes.location::geography, "+point+") AS distance_meters", lng, lat).
+		Where("nodes.project_id = ? AND nodes.deleted_at IS NULL", projectID).
+		Where("ST_DWithin(nodes.location::geography, "+point+", ?)", lng, lat, radius)
+	if nodeType := values.Get("node_type"); nodeType != "" {
+		query = query.Where("nodes.node_type = ?", nodeType)
+	}
+	if status := values.Get("status"); status != "" {
+		query = query.Where("nodes.status = ?", status)
+	}
+
+	nodes := []nearbyNode{}
+	if err := query.Order("distance_meters").Limit(int(limit)).Find(&nodes).Error; err != nil {
+		log.Printf("❌ Failed to find nodes near %f,%f in project %s: %v", lat, lng, projectID, err)
+		http.Error(w, "Failed to fetch nodes", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"nodes":  nodes,
+		"count":  len(nodes),
+		"radius": radius,
+	})
+}
+
+// GetZonesContaining returns the zones, of the projects the caller can see, whose boundary
+// contains ?lat= and ?lng=, optionally within ?project_id=. With ?zone_id= it answers
+// whether the point is inside that zone, so the app can check a worker is in the zone they
+// were assigned.
+// GET /api/v1/zones/contains
+func (h *ProjectHandler) GetZonesContaining(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	lat, lng, err := spatialPoint(values)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := h.scopedDB(r).Model(&models.Zone{}).
+		Where("zones.deleted_at IS NULL").
+		Where("ST_Intersects("+zoneBoundarySQL+", ST_SetSRID(ST_MakePoint(?, ?), 4326))", lng, lat)
+	if raw := values.Get("project_id"); raw != "" {
+		projectID, err := uuid.Parse(raw)
+		if err != nil {
+			http.Error(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("zones.project_id = ?", projectID)
+	}
+
+	var zoneID *uuid.UUID
+	if raw := values.Get("zone_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			http.Error(w, "Invalid zone ID", http.StatusBadRequest)
+			return
+		}
+		var zone models.Zone
+		if err := h.scopedDB(r).Select("id").First(&zone, "id = ? AND deleted_at IS NULL", id).Error; err != nil {
+			http.Error(w, "Zone not found", http.StatusNotFound)
+			return
+		}
+		zoneID = &id
+	}
+
+	zones := []models.Zone{}
+	if err := query.Omit("geometry", "centroid").Find(&zones).Error; err != nil {
+		log.Printf("❌ Failed to find zones containing %f,%f: %v", lat, lng, err)
+		http.Error(w, "Failed to fetch zones", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"zones": zones,
+		"count": len(zones),
+	}
+	if zoneID != nil {
+		inside := false
+		for _, zone := range zones {
+			if zone.ID == *zoneID {
+				inside = true
+				break
+			}
+		}
+		response["zone_id"] = *zoneID
+		response["inside"] = inside
+	}
+	writeJSON(w, http.StatusOK, response)
+}
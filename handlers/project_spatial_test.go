@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestSpatialPoint(t *testing.T) {
+	lat, lng, err := spatialPoint(url.Values{"lat": {"12.97"}, "lng": {"77.59"}})
+	if err != nil || lat != 12.97 || lng != 77.59 {
+		t.Errorf("spatialPoint = %v, %v, %v", lat, lng, err)
+	}
+	for _, values := range []url.Values{
+		{},
+		{"lat": {"12.97"}},
+		{"lat": {"91"}, "lng": {"77.59"}},
+		{"lat": {"12.97"}, "lng": {"-181"}},
+		{"lat": {"north"}, "lng": {"77.59"}},
+	} {
+		if _, _, err := spatialPoint(values); err == nil {
+			t.Errorf("spatialPoint(%v) succeeded, want an error", values)
+		}
+	}
+}
+
+func TestSpatialParam(t *testing.T) {
+	if radius, err := spatialParam(url.Values{}, "radius", defaultNearbyRadius, maxNearbyRadius); err != nil || radius != defaultNearbyRadius {
+		t.Errorf("default radius = %v, %v", radius, err)
+	}
+	if radius, err := spatialParam(url.Values{"radius": {"1500"}}, "radius", defaultNearbyRadius, maxNearbyRadius); err != nil || radius != 1500 {
+		t.Errorf("radius = %v, %v", radius, err)
+	}
+	for _, raw := range []string{"0", "-5", "50001", "far"} {
+		if _, err := spatialParam(url.Values{"radius": {raw}}, "radius", defaultNearbyRadius, maxNearbyRadius); err == nil {
+			t.Errorf("radius %q accepted", raw)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+const (
+	// maxTileZoom is the deepest zoom level tiles are served for
+	maxTileZoom = 22
+	// tileExtent is the size of a vector tile's coordinate space
+	tileExtent = 4096
+	// tileBuffer is how far, in tile units, geometries are kept past a tile's edge so
+	// lines and polygons join up across tiles
+	tileBuffer = 64
+)
+
+// tileCoords reads the {z}/{x}/{y} of a tile request, checking x and y are on the grid of
+// zoom level z
+func tileCoords(vars map[string]string) (int, int, int, error) {
+	z, err := strconv.Atoi(vars["z"])
+	if err != nil || z < 0 || z > maxTileZoom {
+		return 0, 0, 0, fmt.Errorf("z must be a zoom level from 0 to %d", maxTileZoom)
+	}
+	size := 1 << z
+	x, err := strconv.Atoi(vars["x"])
+	if err != nil || x < 0 || x >= size {
+		return 0, 0, 0, fmt.Errorf("x must be from 0 to %d at zoom %d", size-1, z)
+	}
+	y, err := strconv.Atoi(vars["y"])
+	if err != nil || y < 0 || y >= size {
+		return 0, 0, 0, fmt.Errorf("y must be from 0 to %d at zoom %d", size-1, z)
+	}
+	return z, x, y, nil
+}
+
+// projectTileZonesSQL selects a project's zones with their boundaries, in WGS 84
+const projectTileZonesSQL = `SELECT id, name, code, label, ` + zoneBoundarySQL + ` AS boundary
+	FROM zones WHERE project_id = @project AND deleted_at IS NULL`
+
+// projectMVTSQL builds a Mapbox vector tile with a "zones" and a "nodes" layer, clipped to
+// the tile
+var projectMVTSQL = fmt.Sprintf(`WITH bounds AS (
+		SELECT ST_TileEnvelope(@z, @x, @y) AS tile, ST_Transform(ST_TileEnvelope(@z, @x, @y), 4326) AS area
+	),
+	zone_features AS (
+		SELECT z.id::text AS id, z.name, z.code, z.label,
+			ST_AsMVTGeom(ST_Transform(z.boundary, 3857), b.tile, %[1]d, %[2]d, true) AS geom
+		FROM (`+projectTileZonesSQL+`) z, bounds b
+		WHERE z.boundary IS NOT NULL AND ST_Intersects(z.boundary, b.area)
+	),
+	node_features AS (
+		SELECT n.id::text AS id, n.zone_id::text AS zone_id, n.name, n.code, n.label, n.node_type, n.status,
+			ST_AsMVTGeom(ST_Transform(n.location, 3857), b.tile, %[1]d, %[2]d, true) AS geom
+		FROM nodes n, bounds b
+		WHERE n.project_id = @project AND n.deleted_at IS NULL AND ST_Intersects(n.location, b.area)
+	)
+	SELECT
+		COALESCE((SELECT ST_AsMVT(f, 'zones', %[1]d, 'geom') FROM zone_features f WHERE f.geom IS NOT NULL), '') ||
+		COALESCE((SELECT ST_AsMVT(f, 'nodes', %[1]d, 'geom') FROM node_features f WHERE f.geom IS NOT NULL), '') AS tile`,
+	tileExtent, tileBuffer)
+
+// projectGeoJSONTileSQL builds the same features as a GeoJSON FeatureCollection, with zone
+// boundaries clipped to the tile
+const projectGeoJSONTileSQL = `WITH bounds AS (
+		SELECT ST_Transform(ST_TileEnvelope(@z, @x, @y), 4326) AS area
+	),
+	features AS (
+		SELECT jsonb_build_object(
+			'type', 'Feature',
+			'id', z.id,
+			'geometry', ST_AsGeoJSON(ST_Intersection(z.boundary, b.area))::jsonb,
+			'properties', jsonb_build_object('layer', 'zones', 'name', z.name, 'code', z.code, 'label', z.label)
+		) AS feature
+		FROM (` + projectTileZonesSQL + `) z, bounds b
+		WHERE z.boundary IS NOT NULL AND ST_Intersects(z.boundary, b.area)
+		UNION ALL
+		SELECT jsonb_build_object(
+			'type', 'Feature',
+			'id', n.id,
+			'geometry', ST_AsGeoJSON(n.location)::jsonb,
+			'properties', jsonb_build_object('layer', 'nodes', 'zone_id', n.zone_id, 'name', n.name, 'code', n.code,
+				'label', n.label, 'node_type', n.node_type, 'status', n.status)
+		)
+		FROM nodes n, bounds b
+		WHERE n.project_id = @project AND n.deleted_at IS NULL AND ST_Intersects(n.location, b.area)
+	)
+	SELECT jsonb_build_object('type', 'FeatureCollection', 'features', COALESCE(jsonb_agg(feature), '[]'::jsonb))
+	FROM features`
+
+// GetProjectTile returns one map tile of a project's zones and nodes, so maps load only
+// the part of a large project in view instead of its whole GeoJSON. Tiles are Mapbox
+// vector tiles with a "zones" and a "nodes" layer; ?format=geojson returns a
+// FeatureCollection clipped to the tile instead, each feature's layer in its properties.
+// Tiles with nothing in them answer 204.
+// GET /api/v1/projects/{id}/tiles/{z}/{x}/{y}
+func (h *ProjectHandler) GetProjectTile(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	projectID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+	z, x, y, err := tileCoords(vars)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "mvt" && format != "geojson" {
+		http.Error(w, "format must be mvt or geojson", http.StatusBadRequest)
+		return
+	}
+
+	// The tile queries are raw SQL, which the data scope cannot filter
+	var project models.Project
+	if err := h.scopedDB(r).Select("id").First(&project, "id = ?", projectID).Error; err != nil {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+
+	args := map[string]interface{}{"project": projectID, "z": z, "x": x, "y": y}
+	w.Header().Set("Cache-Control", "private, max-age=60")
+	if format == "geojson" {
+		var collection []byte
+		if err := middleware.WithDataScope(r, h.db).Raw(projectGeoJSONTileSQL, args).Row().Scan(&collection); err != nil {
+			log.Printf("❌ Failed to build GeoJSON tile %d/%d/%d of project %s: %v", z, x, y, projectID, err)
+			http.Error(w, "Failed to build tile", http.StatusInternalServerError)
+			return
+		}
+		var parsed struct {
+			Features []json.RawMessage `json:"features"`
+		}
+		if err := json.Unmarshal(collection, &parsed); err == nil && len(parsed.Features) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/geo+json")
+		w.Write(collection)
+		return
+	}
+
+	var tile []byte
+	if err := middleware.WithDataScope(r, h.db).Raw(projectMVTSQL, args).Row().Scan(&tile); err != nil {
+		log.Printf("❌ Failed to build vector tile %d/%d/%d of project %s: %v", z, x, y, projectID, err)
+		http.Error(w, "Failed to build tile", http.StatusInternalServerError)
+		return
+	}
+	if len(tile) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.mapbox-vector-tile")
+	w.Write(tile)
+}
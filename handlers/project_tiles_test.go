@@ -0,0 +1,24 @@
+package handlers
+
+import "testing"
+
+func TestTileCoords(t *testing.T) {
+	z, x, y, err := tileCoords(map[string]string{"z": "14", "x": "11700", "y": "7600"})
+	if err != nil || z != 14 || x != 11700 || y != 7600 {
+		t.Errorf("tileCoords = %d/%d/%d, %v", z, x, y, err)
+	}
+	if _, _, _, err := tileCoords(map[string]string{"z": "0", "x": "0", "y": "0"}); err != nil {
+		t.Errorf("world tile: %v", err)
+	}
+	for _, vars := range []map[string]string{
+		{"z": "23", "x": "0", "y": "0"},
+		{"z": "-1", "x": "0", "y": "0"},
+		{"z": "2", "x": "4", "y": "0"},
+		{"z": "2", "x": "0", "y": "-1"},
+		{"z": "2", "x": "a", "y": "0"},
+	} {
+		if _, _, _, err := tileCoords(vars); err == nil {
+			t.Errorf("tileCoords(%v) succeeded, want an error", vars)
+		}
+	}
+}
@@ -203,7 +203,7 @@ func (h *ProjectWorkflowHandler) SubmitTaskForApproval(w http.ResponseWriter, r
 
 	// Get task
 	var task models.Tasks
-	if err := h.db.Preload("Project").First(&task, "id = ?", taskID).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).Preload("Project").First(&task, "id = ?", taskID).Error; err != nil {
 		http.Error(w, "Task not found", http.StatusNotFound)
 		return
 	}
@@ -264,7 +264,7 @@ func (h *ProjectWorkflowHandler) SubmitTaskForApproval(w http.ResponseWriter, r
 		// Link submission to task
 		task.FormSubmissionID = &submission.ID
 		task.CurrentState = submission.CurrentState
-		h.db.Save(&task)
+		middleware.WithDataScope(r, h.db).Save(&task)
 	}
 
 	// Transition to submitted state
@@ -288,7 +288,7 @@ func (h *ProjectWorkflowHandler) SubmitTaskForApproval(w http.ResponseWriter, r
 
 	// Update task state
 	task.CurrentState = submission.CurrentState
-	h.db.Save(&task)
+	middleware.WithDataScope(r, h.db).Save(&task)
 
 	log.Printf("✅ Task submitted for approval: %s", taskID)
 	w.Header().Set("Content-Type", "application/json")
@@ -314,7 +314,7 @@ func (h *ProjectWorkflowHandler) ApproveTask(w http.ResponseWriter, r *http.Requ
 
 	// Get task
 	var task models.Tasks
-	if err := h.db.First(&task, "id = ?", taskID).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).First(&task, "id = ?", taskID).Error; err != nil {
 		http.Error(w, "Task not found", http.StatusNotFound)
 		return
 	}
@@ -362,7 +362,7 @@ func (h *ProjectWorkflowHandler) ApproveTask(w http.ResponseWriter, r *http.Requ
 	if task.Status == "pending" || task.Status == "assigned" {
 		task.Status = "in-progress"
 	}
-	h.db.Save(&task)
+	middleware.WithDataScope(r, h.db).Save(&task)
 
 	log.Printf("✅ Task approved: %s by %s", taskID, user.Name)
 	w.Header().Set("Content-Type", "application/json")
@@ -388,7 +388,7 @@ func (h *ProjectWorkflowHandler) RejectTask(w http.ResponseWriter, r *http.Reque
 
 	// Get task
 	var task models.Tasks
-	if err := h.db.First(&task, "id = ?", taskID).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).First(&task, "id = ?", taskID).Error; err != nil {
 		http.Error(w, "Task not found", http.StatusNotFound)
 		return
 	}
@@ -423,7 +423,7 @@ func (h *ProjectWorkflowHandler) RejectTask(w http.ResponseWriter, r *http.Reque
 
 	// Update task state
 	task.CurrentState = submission.CurrentState
-	h.db.Save(&task)
+	middleware.WithDataScope(r, h.db).Save(&task)
 
 	log.Printf("⚠️  Task rejected: %s by %s", taskID, user.Name)
 	w.Header().Set("Content-Type", "application/json")
@@ -448,7 +448,7 @@ func (h *ProjectWorkflowHandler) CompleteTask(w http.ResponseWriter, r *http.Req
 
 	// Get task
 	var task models.Tasks
-	if err := h.db.First(&task, "id = ?", taskID).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).First(&task, "id = ?", taskID).Error; err != nil {
 		http.Error(w, "Task not found", http.StatusNotFound)
 		return
 	}
@@ -488,10 +488,19 @@ func (h *ProjectWorkflowHandler) CompleteTask(w http.ResponseWriter, r *http.Req
 		task.CurrentState = submission.CurrentState
 	}
 
-	// Update task status
+	// Update task status and complete its nodes
 	task.Progress = 100
 	task.UpdatedBy = claims.UserID
-	h.db.Save(&task)
+	if err := middleware.WithDataScope(r, h.db).Transaction(func(tx *gorm.DB) error {
+		if err := moveTaskNodes(tx, task, models.NodeStatusCompleted); err != nil {
+			return err
+		}
+		return tx.Save(&task).Error
+	}); err != nil {
+		writeTaskNodeErr(w, err)
+		return
+	}
+	rollupProjectProgress(middleware.WithDataScope(r, h.db), task.ProjectID)
 
 	log.Printf("✅ Task marked as completed: %s", taskID)
 	w.Header().Set("Content-Type", "application/json")
@@ -508,7 +517,7 @@ func (h *ProjectWorkflowHandler) GetTaskWorkflowHistory(w http.ResponseWriter, r
 
 	// Get task
 	var task models.Tasks
-	if err := h.db.First(&task, "id = ?", taskID).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).First(&task, "id = ?", taskID).Error; err != nil {
 		http.Error(w, "Task not found", http.StatusNotFound)
 		return
 	}
@@ -543,7 +552,7 @@ func (h *ProjectWorkflowHandler) GetAvailableTaskActions(w http.ResponseWriter,
 
 	// Get task
 	var task models.Tasks
-	if err := h.db.Preload("Workflow").First(&task, "id = ?", taskID).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).Preload("Workflow").First(&task, "id = ?", taskID).Error; err != nil {
 		http.Error(w, "Task not found", http.StatusNotFound)
 		return
 	}
@@ -642,7 +651,7 @@ func (h *ProjectWorkflowHandler) CreateTaskApprovalWorkflow(w http.ResponseWrite
 		IsActive:     true,
 	}
 
-	if err := h.db.Create(&workflow).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).Create(&workflow).Error; err != nil {
 		http.Error(w, "Failed to create workflow", http.StatusInternalServerError)
 		return
 	}
@@ -671,14 +680,14 @@ func (h *ProjectWorkflowHandler) AssignWorkflowToTask(w http.ResponseWriter, r *
 
 	// Verify workflow exists
 	var workflow models.WorkflowDefinition
-	if err := h.db.First(&workflow, "id = ? AND is_active = ?", req.WorkflowID, true).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).First(&workflow, "id = ? AND is_active = ?", req.WorkflowID, true).Error; err != nil {
 		http.Error(w, "Workflow not found or inactive", http.StatusBadRequest)
 		return
 	}
 
 	// Get task
 	var task models.Tasks
-	if err := h.db.First(&task, "id = ?", taskID).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).First(&task, "id = ?", taskID).Error; err != nil {
 		http.Error(w, "Task not found", http.StatusNotFound)
 		return
 	}
@@ -687,7 +696,7 @@ func (h *ProjectWorkflowHandler) AssignWorkflowToTask(w http.ResponseWriter, r *
 	task.WorkflowID = &req.WorkflowID
 	task.CurrentState = workflow.InitialState
 
-	if err := h.db.Save(&task).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).Save(&task).Error; err != nil {
 		http.Error(w, "Failed to assign workflow", http.StatusInternalServerError)
 		return
 	}
@@ -0,0 +1,430 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/jobrunner"
+)
+
+// errReminderEntityNotFound is returned when a reminder's entity is not in the business
+var errReminderEntityNotFound = errors.New("entity not found in this business")
+
+// reminderEntity looks up what a reminder is about within a business: where it opens in
+// the app, and whether it is closed so reminding about it is pointless
+type reminderEntity func(db *gorm.DB, id, businessID uuid.UUID) (actionURL string, closed bool, err error)
+
+var reminderEntities = map[string]reminderEntity{
+	models.ReminderEntityTask: func(db *gorm.DB, id, businessID uuid.UUID) (string, bool, error) {
+		var task models.Tasks
+		if err := db.Select("tasks.id", "tasks.status").
+			Joins("JOIN projects ON projects.id = tasks.project_id").
+			Where("tasks.id = ? AND projects.business_vertical_id = ? AND tasks.deleted_at IS NULL", id, businessID).
+			Take(&task).Error; err != nil {
+			return "", false, err
+		}
+		return fmt.Sprintf("/project-tasks/%s", id), task.Status == "completed" || task.Status == "cancelled", nil
+	},
+	models.ReminderEntityApproval: func(db *gorm.DB, id, businessID uuid.UUID) (string, bool, error) {
+		var submission models.FormSubmission
+		if err := db.Preload("Workflow").
+			Where("id = ? AND business_vertical_id = ?", id, businessID).
+			Take(&submission).Error; err != nil {
+			return "", false, err
+		}
+		closed := false
+		if submission.Workflow != nil {
+			if states, err := submission.Workflow.ParseStates(); err == nil {
+				for _, s := range states {
+					if s.Code == submission.CurrentState {
+						closed = s.IsFinal
+					}
+				}
+			}
+		}
+		return fmt.Sprintf("/forms/%s/submissions/%s", submission.FormCode, id), closed, nil
+	},
+	models.ReminderEntityMaintenance: func(db *gorm.DB, id, businessID uuid.UUID) (string, bool, error) {
+		var task models.MaintenanceTask
+		if err := db.Select("id", "status").
+			Where("id = ? AND business_vertical_id = ?", id, businessID).
+			Take(&task).Error; err != nil {
+			return "", false, err
+		}
+		return fmt.Sprintf("/maintenance-tasks/%s", id), task.Status == models.MaintenanceDone || task.Status == models.MaintenanceCancelled, nil
+	},
+}
+
+// lookupReminderEntity resolves the reminder's entity, reporting one that is gone as not found
+func lookupReminderEntity(db *gorm.DB, reminder *models.Reminder) (string, bool, error) {
+	actionURL, closed, err := reminderEntities[reminder.EntityType](db, reminder.EntityID, reminder.BusinessVerticalID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", false, errReminderEntityNotFound
+	}
+	return actionURL, closed, err
+}
+
+// canManageReminders reports whether the caller may set and cancel reminders for others
+func canManageReminders(r *http.Request) bool {
+	authService := middleware.NewAuthService()
+	userCtx, err := authService.LoadUserContext(r)
+	return err == nil && authService.HasBusinessPermission(userCtx, "reminder:manage")
+}
+
+type createReminderRequest struct {
+	EntityType string                      `json:"entity_type"`
+	EntityID   uuid.UUID                   `json:"entity_id"`
+	Title      string                      `json:"title"`
+	Message    string                      `json:"message"`
+	Priority   models.NotificationPriority `json:"priority"`
+	UserID     *uuid.UUID                  `json:"user_id,omitempty"` // defaults to the caller
+	RoleID     *uuid.UUID                  `json:"role_id,omitempty"` // a business role instead of a user
+	Channels   []string                    `json:"channels,omitempty"`
+	RemindAt   time.Time                   `json:"remind_at"`
+	Frequency  string                      `json:"frequency,omitempty"`
+	Interval   int                         `json:"interval,omitempty"`
+	Until      *time.Time                  `json:"until,omitempty"`
+}
+
+// CreateReminder schedules a reminder about a task, approval or maintenance ticket, once or
+// recurring. Anyone may remind themselves; reminding another user or a role takes
+// reminder:manage.
+// POST /api/v1/business/{businessCode}/reminders
+func CreateReminder(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	callerID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		http.Error(w, "invalid user ID in claims", http.StatusUnauthorized)
+		return
+	}
+	businessID := middleware.GetCurrentBusinessID(r)
+	if businessID == uuid.Nil {
+		http.Error(w, "invalid business identifier", http.StatusBadRequest)
+		return
+	}
+
+	var req createReminderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	reminder := models.Reminder{
+		BusinessVerticalID: businessID,
+		EntityType:         strings.TrimSpace(req.EntityType),
+		EntityID:           req.EntityID,
+		Title:              req.Title,
+		Message:            strings.TrimSpace(req.Message),
+		Priority:           req.Priority,
+		UserID:             req.UserID,
+		RoleID:             req.RoleID,
+		Channels:           req.Channels,
+		RemindAt:           req.RemindAt,
+		Frequency:          strings.TrimSpace(req.Frequency),
+		Interval:           req.Interval,
+		Until:              req.Until,
+		CreatedBy:          callerID,
+	}
+	if reminder.UserID == nil && reminder.RoleID == nil {
+		reminder.UserID = &callerID
+	}
+	if err := reminder.Validate(time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if (reminder.UserID == nil || *reminder.UserID != callerID) && !canManageReminders(r) {
+		http.Error(w, "reminding another user or a role requires reminder:manage", http.StatusForbidden)
+		return
+	}
+
+	if reminder.UserID != nil && *reminder.UserID != callerID {
+		var members int64
+		if err := middleware.ScopedDB(r).Model(&models.UserBusinessRole{}).
+			Joins("JOIN business_roles ON business_roles.id = user_business_roles.business_role_id").
+			Joins("JOIN users ON users.id = user_business_roles.user_id").
+			Where("user_business_roles.user_id = ? AND user_business_roles.is_active = ? AND business_roles.business_vertical_id = ? AND users.is_active = ?",
+				*reminder.UserID, true, businessID, true).
+			Count(&members).Error; err != nil {
+			http.Error(w, "failed to check user", http.StatusInternalServerError)
+			return
+		}
+		if members == 0 {
+			http.Error(w, "user not found in this business", http.StatusNotFound)
+			return
+		}
+	}
+	if reminder.RoleID != nil {
+		var role models.BusinessRole
+		if err := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ?", *reminder.RoleID, businessID).First(&role).Error; err != nil {
+			http.Error(w, "role not found in this business", http.StatusNotFound)
+			return
+		}
+	}
+	_, closed, err := lookupReminderEntity(middleware.ScopedDB(r), &reminder)
+	if errors.Is(err, errReminderEntityNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to load entity", http.StatusInternalServerError)
+		return
+	}
+	if closed {
+		http.Error(w, reminder.EntityType+" is already closed", http.StatusConflict)
+		return
+	}
+
+	if err := middleware.ScopedDB(r).Create(&reminder).Error; err != nil {
+		http.Error(w, "failed to create reminder", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"reminder": reminder})
+}
+
+// ListReminders lists the business's reminders the caller set or receives, or all of them
+// for holders of reminder:manage (?entity_type=&entity_id=&status=&user_id=)
+// GET /api/v1/business/{businessCode}/reminders
+func ListReminders(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	businessID := middleware.GetCurrentBusinessID(r)
+	if businessID == uuid.Nil {
+		http.Error(w, "invalid business identifier", http.StatusBadRequest)
+		return
+	}
+
+	q := r.URL.Query()
+	query := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID)
+	if !canManageReminders(r) {
+		query = query.Where("created_by = ? OR user_id = ?", claims.UserID, claims.UserID)
+	} else if userID, err := uuid.Parse(q.Get("user_id")); err == nil {
+		query = query.Where("user_id = ?", userID)
+	}
+	if entityType := strings.TrimSpace(q.Get("entity_type")); entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+	}
+	if entityID, err := uuid.Parse(q.Get("entity_id")); err == nil {
+		query = query.Where("entity_id = ?", entityID)
+	}
+	if status := strings.TrimSpace(q.Get("status")); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var reminders []models.Reminder
+	if err := query.Order("next_run_at IS NULL, next_run_at, created_at DESC").Limit(200).Find(&reminders).Error; err != nil {
+		http.Error(w, "failed to load reminders", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"reminders": reminders})
+}
+
+// CancelReminder stops a scheduled reminder; its creator, its recipient and holders of
+// reminder:manage may cancel it
+// POST /api/v1/business/{businessCode}/reminders/{id}/cancel
+func CancelReminder(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	callerID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		http.Error(w, "invalid user ID in claims", http.StatusUnauthorized)
+		return
+	}
+	reminderID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid reminder ID", http.StatusBadRequest)
+		return
+	}
+
+	var reminder models.Reminder
+	if err := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ?", reminderID, middleware.GetCurrentBusinessID(r)).
+		First(&reminder).Error; err != nil {
+		http.Error(w, "reminder not found", http.StatusNotFound)
+		return
+	}
+	own := reminder.CreatedBy == callerID || (reminder.UserID != nil && *reminder.UserID == callerID)
+	if !own && !canManageReminders(r) {
+		http.Error(w, "reminder not found", http.StatusNotFound)
+		return
+	}
+
+	if err := reminder.Cancel(callerID, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err := middleware.ScopedDB(r).Model(&reminder).Updates(map[string]interface{}{
+		"status":       reminder.Status,
+		"next_run_at":  nil,
+		"cancelled_at": reminder.CancelledAt,
+		"cancelled_by": reminder.CancelledBy,
+	}).Error; err != nil {
+		http.Error(w, "failed to cancel reminder", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"reminder": reminder})
+}
+
+// ReminderScheduler fires reminders as they fall due: a notification to the user or to each
+// current holder of the role, pushed and texted on the reminder's channels. Reminders whose
+// entity has been closed or removed complete without firing.
+type ReminderScheduler struct {
+	db  *gorm.DB
+	ns  *NotificationService
+	sms *SMSService
+	*jobrunner.Runner
+}
+
+// NewReminderScheduler creates the reminder scheduler
+func NewReminderScheduler() *ReminderScheduler {
+	s := &ReminderScheduler{db: config.DB, ns: NewNotificationService(), sms: NewSMSService()}
+	s.Runner = jobrunner.New("Reminder scheduler", func() {
+		if n, err := s.SendDue(time.Now()); err != nil {
+			log.Printf("Error sending reminders: %v", err)
+		} else if n > 0 {
+			log.Printf("Reminder scheduler: fired %d reminders", n)
+		}
+	})
+	return s
+}
+
+// SendDue fires every scheduled reminder whose next run has come and returns how many
+// fired. Recurrences are counted in the business timezone.
+func (s *ReminderScheduler) SendDue(now time.Time) (int, error) {
+	var due []uuid.UUID
+	if err := s.db.Model(&models.Reminder{}).
+		Where("status = ? AND next_run_at <= ?", models.ReminderScheduled, now).
+		Order("next_run_at").
+		Limit(500).
+		Pluck("id", &due).Error; err != nil {
+		return 0, err
+	}
+
+	fired := 0
+	for _, id := range due {
+		ok, err := s.fire(id, now)
+		if err != nil {
+			log.Printf("❌ Failed to fire reminder %s: %v", id, err)
+			continue
+		}
+		if ok {
+			fired++
+		}
+	}
+	return fired, nil
+}
+
+// fire notifies the reminder's recipients and schedules its next run
+func (s *ReminderScheduler) fire(reminderID uuid.UUID, now time.Time) (bool, error) {
+	var reminder models.Reminder
+	var notifications []models.Notification
+	phones := map[string]string{}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("id = ? AND status = ? AND next_run_at <= ?", reminderID, models.ReminderScheduled, now).
+			First(&reminder).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		actionURL, closed, err := lookupReminderEntity(tx, &reminder)
+		switch {
+		case errors.Is(err, errReminderEntityNotFound) || closed:
+			log.Printf("ℹ️ reminder %s: %s %s is closed or gone, completing it", reminder.ID, reminder.EntityType, reminder.EntityID)
+			return tx.Model(&reminder).Updates(map[string]interface{}{"status": models.ReminderCompleted, "next_run_at": nil}).Error
+		case err != nil:
+			return err
+		}
+
+		recipients := tx.Select("id", "phone").Where("is_active = ?", true)
+		if reminder.UserID != nil {
+			recipients = recipients.Where("id = ?", *reminder.UserID)
+		} else {
+			recipients = recipients.Where("id IN (?)", tx.Model(&models.UserBusinessRole{}).Select("user_id").
+				Where("business_role_id = ? AND is_active = ? AND (valid_from IS NULL OR valid_from <= ?) AND (valid_until IS NULL OR valid_until > ?)",
+					*reminder.RoleID, true, now, now))
+		}
+		var users []models.User
+		if err := recipients.Find(&users).Error; err != nil {
+			return err
+		}
+
+		body := reminder.Message
+		if body == "" {
+			body = fmt.Sprintf("Reminder about %s", strings.ReplaceAll(reminder.EntityType, "_", " "))
+		}
+		for _, user := range users {
+			phones[user.ID.String()] = user.Phone
+			notifications = append(notifications, models.Notification{
+				UserID:             user.ID.String(),
+				Type:               models.NotificationTypeReminder,
+				Priority:           reminder.Priority,
+				Title:              reminder.Title,
+				Body:               body,
+				ActionURL:          actionURL,
+				BusinessVerticalID: &reminder.BusinessVerticalID,
+				Metadata: models.JSONMap{
+					"reminder_id": reminder.ID.String(),
+					"entity_type": reminder.EntityType,
+					"entity_id":   reminder.EntityID.String(),
+				},
+				Status:  models.NotificationStatusSent,
+				Channel: models.NotificationChannelInApp,
+				SentAt:  &now,
+			})
+		}
+		if len(notifications) > 0 {
+			if err := tx.Create(&notifications).Error; err != nil {
+				return err
+			}
+		}
+
+		reminder.Advance(now, attendanceLocation(nil))
+		return tx.Model(&reminder).Updates(map[string]interface{}{
+			"status":       reminder.Status,
+			"next_run_at":  reminder.NextRunAt,
+			"last_sent_at": reminder.LastSentAt,
+			"occurrences":  reminder.Occurrences,
+		}).Error
+	})
+	if err != nil || len(notifications) == 0 {
+		return false, err
+	}
+
+	for i := range notifications {
+		n := &notifications[i]
+		if reminder.HasChannel(models.NotificationChannelMobilePush) {
+			s.ns.QueueMobilePush(n, n.Body, map[string]string{"reminder_id": reminder.ID.String()})
+		}
+		if reminder.HasChannel(models.NotificationChannelSMS) && phones[n.UserID] != "" {
+			text := fmt.Sprintf("UGCL reminder: %s. %s", reminder.Title, n.Body)
+			if _, err := s.sms.Send(context.Background(), n.UserID, phones[n.UserID], models.SMSPurposeReminder, &n.ID, text); err != nil {
+				log.Printf("⚠️ reminder %s: failed to text %s: %v", reminder.ID, n.UserID, err)
+			}
+		}
+	}
+	return true, nil
+}
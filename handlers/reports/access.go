@@ -43,8 +43,8 @@ func canViewReport(r *http.Request, report *models.ReportDefinition) bool {
 	// Rule 4: role-based access
 	if len(report.AllowedRoles) > 0 {
 		userRole := ""
-		if userCtx.User.RoleModel != nil {
-			userRole = userCtx.User.RoleModel.Name
+		if role := userCtx.User.EffectiveRole(); role != nil {
+			userRole = role.Name
 		}
 		for _, r := range report.AllowedRoles {
 			if r == userRole {
@@ -9,7 +9,6 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/xuri/excelize/v2"
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 )
@@ -22,7 +21,7 @@ func ExportReportToExcel(w http.ResponseWriter, r *http.Request) {
 
 	// Get report definition
 	var report models.ReportDefinition
-	if err := config.DB.Where("id = ? AND deleted_at IS NULL", reportID).First(&report).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ? AND deleted_at IS NULL", reportID).First(&report).Error; err != nil {
 		http.Error(w, "Report not found", http.StatusNotFound)
 		return
 	}
@@ -72,7 +71,7 @@ func ExportReportToCSV(w http.ResponseWriter, r *http.Request) {
 
 	// Get report definition
 	var report models.ReportDefinition
-	if err := config.DB.Where("id = ? AND deleted_at IS NULL", reportID).First(&report).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ? AND deleted_at IS NULL", reportID).First(&report).Error; err != nil {
 		http.Error(w, "Report not found", http.StatusNotFound)
 		return
 	}
@@ -115,7 +114,7 @@ func ExportReportToPDF(w http.ResponseWriter, r *http.Request) {
 
 	// Get report definition
 	var report models.ReportDefinition
-	if err := config.DB.Where("id = ? AND deleted_at IS NULL", reportID).First(&report).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ? AND deleted_at IS NULL", reportID).First(&report).Error; err != nil {
 		http.Error(w, "Report not found", http.StatusNotFound)
 		return
 	}
@@ -499,7 +499,7 @@ func CreateReportDefinition(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := ensureReportViewsForDataSources(config.DB, req.DataSources); err != nil {
+	if err := ensureReportViewsForDataSources(middleware.ScopedDB(r), req.DataSources); err != nil {
 		http.Error(w, fmt.Sprintf("invalid report data_sources: %v", err), http.StatusBadRequest)
 		return
 	}
@@ -535,7 +535,7 @@ func CreateReportDefinition(w http.ResponseWriter, r *http.Request) {
 		CreatedBy:          claims.UserID,
 	}
 
-	if err := config.DB.Create(report).Error; err != nil {
+	if err := middleware.ScopedDB(r).Create(report).Error; err != nil {
 		http.Error(w, fmt.Sprintf("Failed to create report: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -559,7 +559,7 @@ func GetReportDefinitions(w http.ResponseWriter, r *http.Request) {
 		"business_vertical_id, is_public, allowed_roles, created_by, is_active, " +
 		"is_favorite, deleted_at, created_at, updated_at"
 
-	query := config.DB.Select(aclCols).Where("deleted_at IS NULL")
+	query := middleware.ScopedDB(r).Select(aclCols).Where("deleted_at IS NULL")
 
 	if businessVerticalID != "" {
 		query = query.Where("business_vertical_id = ?", businessVerticalID)
@@ -600,7 +600,7 @@ func GetReportDefinition(w http.ResponseWriter, r *http.Request) {
 	reportID := vars["id"]
 
 	var report models.ReportDefinition
-	if err := config.DB.Where("id = ? AND deleted_at IS NULL", reportID).First(&report).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ? AND deleted_at IS NULL", reportID).First(&report).Error; err != nil {
 		http.Error(w, "Report not found", http.StatusNotFound)
 		return
 	}
@@ -621,7 +621,7 @@ func UpdateReportDefinition(w http.ResponseWriter, r *http.Request) {
 	claims := middleware.GetClaims(r)
 
 	var report models.ReportDefinition
-	if err := config.DB.Where("id = ? AND deleted_at IS NULL", reportID).First(&report).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ? AND deleted_at IS NULL", reportID).First(&report).Error; err != nil {
 		http.Error(w, "Report not found", http.StatusNotFound)
 		return
 	}
@@ -641,7 +641,7 @@ func UpdateReportDefinition(w http.ResponseWriter, r *http.Request) {
 	req["updated_by"] = claims.UserID
 	req["updated_at"] = time.Now()
 
-	tx := config.DB.Begin()
+	tx := middleware.ScopedDB(r).Begin()
 	if tx.Error != nil {
 		http.Error(w, "Failed to update report", http.StatusInternalServerError)
 		return
@@ -683,7 +683,7 @@ func DeleteReportDefinition(w http.ResponseWriter, r *http.Request) {
 	reportID := vars["id"]
 
 	var report models.ReportDefinition
-	if err := config.DB.Where("id = ?", reportID).First(&report).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ?", reportID).First(&report).Error; err != nil {
 		http.Error(w, "Report not found", http.StatusNotFound)
 		return
 	}
@@ -694,7 +694,7 @@ func DeleteReportDefinition(w http.ResponseWriter, r *http.Request) {
 	}
 
 	now := time.Now()
-	if err := config.DB.Model(&report).Update("deleted_at", now).Error; err != nil {
+	if err := middleware.ScopedDB(r).Model(&report).Update("deleted_at", now).Error; err != nil {
 		http.Error(w, "Failed to delete report", http.StatusInternalServerError)
 		return
 	}
@@ -710,7 +710,7 @@ func ExecuteReport(w http.ResponseWriter, r *http.Request) {
 	claims := middleware.GetClaims(r)
 
 	var report models.ReportDefinition
-	if err := config.DB.Where("id = ? AND deleted_at IS NULL", reportID).First(&report).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ? AND deleted_at IS NULL", reportID).First(&report).Error; err != nil {
 		http.Error(w, "Report not found", http.StatusNotFound)
 		return
 	}
@@ -726,7 +726,7 @@ func ExecuteReport(w http.ResponseWriter, r *http.Request) {
 	}
 	json.NewDecoder(r.Body).Decode(&req)
 
-	if err := ensureReportViewsForDataSources(config.DB, report.DataSources); err != nil {
+	if err := ensureReportViewsForDataSources(middleware.ScopedDB(r), report.DataSources); err != nil {
 		http.Error(w, fmt.Sprintf("failed to sync report views: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -761,7 +761,7 @@ func GetSubmissionWorkflowHistory(w http.ResponseWriter, r *http.Request) {
 
 	// Fetch full transition history ordered chronologically.
 	var transitions []models.WorkflowTransition
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Where("submission_id = ?", submissionID).
 		Order("transitioned_at ASC").
 		Find(&transitions).Error; err != nil {
@@ -771,7 +771,7 @@ func GetSubmissionWorkflowHistory(w http.ResponseWriter, r *http.Request) {
 
 	// Also fetch the current submission state for the header.
 	var submission models.FormSubmission
-	submissionFound := config.DB.
+	submissionFound := middleware.ScopedDB(r).
 		Select("id", "form_code", "current_state", "submitted_by", "submitted_at").
 		First(&submission, "id = ? AND deleted_at IS NULL", submissionID).Error == nil
 
@@ -924,7 +924,7 @@ func GetFormTableFields(w http.ResponseWriter, r *http.Request) {
 	var formFields []map[string]interface{}
 	var formTitle string
 
-	dbErr := config.DB.
+	dbErr := middleware.ScopedDB(r).
 		Where("is_active = ? AND (LOWER(db_table_name) = LOWER(?) OR LOWER(code) = LOWER(?))", true, tableName, tableName).
 		Select("id", "code", "title", "form_schema", "steps", "core_fields").
 		First(&form).Error
@@ -1221,7 +1221,7 @@ func GetAvailableFormTables(w http.ResponseWriter, r *http.Request) {
 		verticalToken = strings.TrimSpace(r.URL.Query().Get("vertical_id"))
 	}
 
-	query := config.DB.Model(&models.AppForm{}).Where("is_active = ?", true)
+	query := middleware.ScopedDB(r).Model(&models.AppForm{}).Where("is_active = ?", true)
 
 	if moduleID != "" {
 		moduleUUID, err := uuid.Parse(moduleID)
@@ -1240,7 +1240,7 @@ func GetAvailableFormTables(w http.ResponseWriter, r *http.Request) {
 
 		if verticalUUID, err := uuid.Parse(verticalToken); err == nil {
 			var matched models.BusinessVertical
-			if err := config.DB.Select("id", "code").Where("id = ?", verticalUUID).First(&matched).Error; err == nil {
+			if err := middleware.ScopedDB(r).Select("id", "code").Where("id = ?", verticalUUID).First(&matched).Error; err == nil {
 				candidateTokens[matched.ID.String()] = struct{}{}
 				if strings.TrimSpace(matched.Code) != "" {
 					candidateTokens[matched.Code] = struct{}{}
@@ -1249,7 +1249,7 @@ func GetAvailableFormTables(w http.ResponseWriter, r *http.Request) {
 			}
 		} else {
 			var matched []models.BusinessVertical
-			if err := config.DB.Select("id", "code").Where("LOWER(code) = LOWER(?)", verticalToken).Find(&matched).Error; err == nil {
+			if err := middleware.ScopedDB(r).Select("id", "code").Where("LOWER(code) = LOWER(?)", verticalToken).Find(&matched).Error; err == nil {
 				for _, v := range matched {
 					candidateTokens[v.ID.String()] = struct{}{}
 					if strings.TrimSpace(v.Code) != "" {
@@ -1324,7 +1324,7 @@ func CloneReport(w http.ResponseWriter, r *http.Request) {
 	claims := middleware.GetClaims(r)
 
 	var originalReport models.ReportDefinition
-	if err := config.DB.Where("id = ? AND deleted_at IS NULL", reportID).First(&originalReport).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ? AND deleted_at IS NULL", reportID).First(&originalReport).Error; err != nil {
 		http.Error(w, "Report not found", http.StatusNotFound)
 		return
 	}
@@ -1355,7 +1355,7 @@ func CloneReport(w http.ResponseWriter, r *http.Request) {
 		CreatedBy:          claims.UserID,
 	}
 
-	if err := config.DB.Create(&newReport).Error; err != nil {
+	if err := middleware.ScopedDB(r).Create(&newReport).Error; err != nil {
 		http.Error(w, "Failed to clone report", http.StatusInternalServerError)
 		return
 	}
@@ -1373,13 +1373,13 @@ func ToggleFavoriteReport(w http.ResponseWriter, r *http.Request) {
 	reportID := vars["id"]
 
 	var report models.ReportDefinition
-	if err := config.DB.Where("id = ?", reportID).First(&report).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ?", reportID).First(&report).Error; err != nil {
 		http.Error(w, "Report not found", http.StatusNotFound)
 		return
 	}
 
 	report.IsFavorite = !report.IsFavorite
-	if err := config.DB.Save(&report).Error; err != nil {
+	if err := middleware.ScopedDB(r).Save(&report).Error; err != nil {
 		http.Error(w, "Failed to update favorite status", http.StatusInternalServerError)
 		return
 	}
@@ -1404,7 +1404,7 @@ func GetReportExecutionHistory(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var executions []models.ReportExecution
-	if err := config.DB.Where("report_id = ?", reportID).
+	if err := middleware.ScopedDB(r).Where("report_id = ?", reportID).
 		Order("started_at DESC").
 		Limit(limit).
 		Find(&executions).Error; err != nil {
@@ -1454,7 +1454,7 @@ func CreateDashboard(w http.ResponseWriter, r *http.Request) {
 		CreatedBy:          claims.UserID,
 	}
 
-	if err := config.DB.Create(dashboard).Error; err != nil {
+	if err := middleware.ScopedDB(r).Create(dashboard).Error; err != nil {
 		http.Error(w, "Failed to create dashboard", http.StatusInternalServerError)
 		return
 	}
@@ -1480,7 +1480,7 @@ func GetDashboards(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := config.DB.Preload("Widgets").Preload("Widgets.Report").Where("deleted_at IS NULL")
+	query := middleware.ScopedDB(r).Preload("Widgets").Preload("Widgets.Report").Where("deleted_at IS NULL")
 
 	if businessVerticalID != "" {
 		query = query.Where("business_vertical_id = ?", businessVerticalID)
@@ -1512,7 +1512,7 @@ func GetDashboard(w http.ResponseWriter, r *http.Request) {
 	dashboardID := vars["id"]
 
 	var dashboard models.Dashboard
-	if err := config.DB.Preload("Widgets").Preload("Widgets.Report").
+	if err := middleware.ScopedDB(r).Preload("Widgets").Preload("Widgets.Report").
 		Where("id = ? AND deleted_at IS NULL", dashboardID).
 		First(&dashboard).Error; err != nil {
 		http.Error(w, "Dashboard not found", http.StatusNotFound)
@@ -1539,7 +1539,7 @@ func ExecuteDashboard(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	var dashboard models.Dashboard
-	if err := config.DB.WithContext(execCtx).Preload("Widgets").Preload("Widgets.Report").
+	if err := middleware.ScopedDB(r).WithContext(execCtx).Preload("Widgets").Preload("Widgets.Report").
 		Where("id = ? AND deleted_at IS NULL", dashboardID).
 		First(&dashboard).Error; err != nil {
 		http.Error(w, "Dashboard not found", http.StatusNotFound)
@@ -1659,7 +1659,7 @@ func DeleteDashboard(w http.ResponseWriter, r *http.Request) {
 	dashboardID := vars["id"]
 
 	var dashboard models.Dashboard
-	if err := config.DB.Where("id = ? AND deleted_at IS NULL", dashboardID).First(&dashboard).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ? AND deleted_at IS NULL", dashboardID).First(&dashboard).Error; err != nil {
 		http.Error(w, "Dashboard not found", http.StatusNotFound)
 		return
 	}
@@ -1670,7 +1670,7 @@ func DeleteDashboard(w http.ResponseWriter, r *http.Request) {
 	}
 
 	now := time.Now()
-	tx := config.DB.Begin()
+	tx := middleware.ScopedDB(r).Begin()
 	if tx.Error != nil {
 		http.Error(w, "Failed to delete dashboard", http.StatusInternalServerError)
 		return
@@ -1724,7 +1724,7 @@ func AddWidgetToDashboard(w http.ResponseWriter, r *http.Request) {
 		RefreshRate: req.RefreshRate,
 	}
 
-	if err := config.DB.Create(widget).Error; err != nil {
+	if err := middleware.ScopedDB(r).Create(widget).Error; err != nil {
 		http.Error(w, "Failed to add widget", http.StatusInternalServerError)
 		return
 	}
@@ -1741,7 +1741,7 @@ func RemoveWidgetFromDashboard(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	widgetID := vars["widget_id"]
 
-	if err := config.DB.Delete(&models.ReportWidget{}, "id = ?", widgetID).Error; err != nil {
+	if err := middleware.ScopedDB(r).Delete(&models.ReportWidget{}, "id = ?", widgetID).Error; err != nil {
 		http.Error(w, "Failed to remove widget", http.StatusInternalServerError)
 		return
 	}
@@ -1892,7 +1892,7 @@ func CreateReportTemplate(w http.ResponseWriter, r *http.Request) {
 		}
 
 		var report models.ReportDefinition
-		if err := config.DB.Where("id = ?", req.ReportID).First(&report).Error; err != nil {
+		if err := middleware.ScopedDB(r).Where("id = ?", req.ReportID).First(&report).Error; err != nil {
 			http.Error(w, "Report not found", http.StatusNotFound)
 			return
 		}
@@ -1923,7 +1923,7 @@ func CreateReportTemplate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var existing models.ReportTemplate
-	if err := config.DB.Where("code = ?", finalCode).First(&existing).Error; err == nil {
+	if err := middleware.ScopedDB(r).Where("code = ?", finalCode).First(&existing).Error; err == nil {
 		finalCode = fmt.Sprintf("%s_%d", finalCode, time.Now().Unix())
 	} else if err != nil && err != gorm.ErrRecordNotFound {
 		http.Error(w, "Failed to validate template code", http.StatusInternalServerError)
@@ -1940,7 +1940,7 @@ func CreateReportTemplate(w http.ResponseWriter, r *http.Request) {
 		IsActive:    true,
 	}
 
-	if err := config.DB.Create(template).Error; err != nil {
+	if err := middleware.ScopedDB(r).Create(template).Error; err != nil {
 		http.Error(w, "Failed to create template", http.StatusInternalServerError)
 		return
 	}
@@ -1958,7 +1958,7 @@ func UpdateReportTemplate(w http.ResponseWriter, r *http.Request) {
 	templateID := vars["template_id"]
 
 	var template models.ReportTemplate
-	if err := config.DB.Where("id = ?", templateID).First(&template).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ?", templateID).First(&template).Error; err != nil {
 		http.Error(w, "Template not found", http.StatusNotFound)
 		return
 	}
@@ -1998,7 +1998,7 @@ func UpdateReportTemplate(w http.ResponseWriter, r *http.Request) {
 		nextCode := normalizeTemplateCode(*req.Code)
 		if nextCode != "" && nextCode != template.Code {
 			var existing models.ReportTemplate
-			err := config.DB.Where("code = ?", nextCode).First(&existing).Error
+			err := middleware.ScopedDB(r).Where("code = ?", nextCode).First(&existing).Error
 			if err == nil && existing.ID != template.ID {
 				http.Error(w, "Template code already exists", http.StatusConflict)
 				return
@@ -2025,7 +2025,7 @@ func UpdateReportTemplate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := config.DB.Save(&template).Error; err != nil {
+	if err := middleware.ScopedDB(r).Save(&template).Error; err != nil {
 		http.Error(w, "Failed to update template", http.StatusInternalServerError)
 		return
 	}
@@ -2046,7 +2046,7 @@ func GetReportTemplates(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := config.DB.Where("is_active = ?", true)
+	query := middleware.ScopedDB(r).Where("is_active = ?", true)
 
 	if category != "" {
 		query = query.Where("category = ?", category)
@@ -2072,7 +2072,7 @@ func CreateReportFromTemplate(w http.ResponseWriter, r *http.Request) {
 	claims := middleware.GetClaims(r)
 
 	var template models.ReportTemplate
-	if err := config.DB.Where("id = ?", templateID).First(&template).Error; err != nil {
+	if err := middleware.ScopedDB(r).Where("id = ?", templateID).First(&template).Error; err != nil {
 		http.Error(w, "Template not found", http.StatusNotFound)
 		return
 	}
@@ -2123,13 +2123,13 @@ func CreateReportFromTemplate(w http.ResponseWriter, r *http.Request) {
 		report.Fields, _ = json.Marshal(fields)
 	}
 
-	if err := config.DB.Create(report).Error; err != nil {
+	if err := middleware.ScopedDB(r).Create(report).Error; err != nil {
 		http.Error(w, "Failed to create report from template", http.StatusInternalServerError)
 		return
 	}
 
 	// Increment usage count
-	config.DB.Model(&template).UpdateColumn("usage_count", template.UsageCount+1)
+	middleware.ScopedDB(r).Model(&template).UpdateColumn("usage_count", template.UsageCount+1)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -2142,7 +2142,7 @@ func CreateReportFromTemplate(w http.ResponseWriter, r *http.Request) {
 // Requires report:read permission (enforced at route level). Does NOT require manage_roles.
 func GetReportAvailableRoles(w http.ResponseWriter, r *http.Request) {
 	var roles []models.Role
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Where("is_active = ? AND is_global = ?", true, true).
 		Select("id", "name", "description").
 		Order("name ASC").
@@ -2,21 +2,37 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"gorm.io/gorm"
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 )
 
 // AssignBusinessRoleRequest represents the request to assign a business role to a user
 type AssignBusinessRoleRequest struct {
-	BusinessRoleID string `json:"business_role_id"`
-	AssignedBy     string `json:"assigned_by"`
+	BusinessRoleID string     `json:"business_role_id"`
+	AssignedBy     string     `json:"assigned_by"`
+	ValidFrom      *time.Time `json:"valid_from,omitempty"`  // optional start of a time-bound grant
+	ValidUntil     *time.Time `json:"valid_until,omitempty"` // optional expiry, e.g. for temporary contractor access
+}
+
+// validateRoleWindow checks the optional validity window of a role grant.
+func validateRoleWindow(from, until *time.Time) error {
+	if until == nil {
+		return nil
+	}
+	if !until.After(time.Now()) {
+		return errors.New("valid_until must be in the future")
+	}
+	if from != nil && !until.After(*from) {
+		return errors.New("valid_until must be after valid_from")
+	}
+	return nil
 }
 
 // AssignBusinessRole - POST /api/users/:id/roles/assign
@@ -34,6 +50,10 @@ func AssignBusinessRole(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
+	if err := validateRoleWindow(req.ValidFrom, req.ValidUntil); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Get current user from context
 	claims := middleware.GetClaims(r)
@@ -44,7 +64,7 @@ func AssignBusinessRole(w http.ResponseWriter, r *http.Request) {
 
 	// Load current user with all roles
 	var currentUser models.User
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Preload("RoleModel").
 		Preload("UserBusinessRoles.BusinessRole").
 		First(&currentUser, "id = ?", claims.UserID).Error; err != nil {
@@ -60,7 +80,7 @@ func AssignBusinessRole(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var businessRole models.BusinessRole
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Preload("BusinessVertical").
 		First(&businessRole, "id = ?", businessRoleID).Error; err != nil {
 		http.Error(w, "Business role not found", http.StatusNotFound)
@@ -82,18 +102,18 @@ func AssignBusinessRole(w http.ResponseWriter, r *http.Request) {
 
 	// Ensure target user exists before any assignment work.
 	var targetUser models.User
-	if err := config.DB.First(&targetUser, "id = ?", targetUserID).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&targetUser, "id = ?", targetUserID).Error; err != nil {
 		http.Error(w, "Target user not found", http.StatusNotFound)
 		return
 	}
 
 	assignedByID, _ := uuid.Parse(claims.UserID)
 
-	// Check if user already has a role in this vertical
+	// Check if user already has a vertical-wide role; site-scoped roles are managed per site
 	var existingRole models.UserBusinessRole
-	err = config.DB.
+	err = middleware.ScopedDB(r).
 		Joins("JOIN business_roles ON business_roles.id = user_business_roles.business_role_id").
-		Where("user_business_roles.user_id = ? AND business_roles.business_vertical_id = ? AND user_business_roles.is_active = ?",
+		Where("user_business_roles.user_id = ? AND business_roles.business_vertical_id = ? AND user_business_roles.is_active = ? AND user_business_roles.site_id IS NULL",
 			targetUserID, businessRole.BusinessVerticalID, true).
 		First(&existingRole).Error
 
@@ -105,12 +125,22 @@ func AssignBusinessRole(w http.ResponseWriter, r *http.Request) {
 	if err == nil {
 		// If the same role is already assigned, treat as success (idempotent behavior).
 		if existingRole.BusinessRoleID == businessRoleID {
-			tx := config.DB.Begin()
+			tx := middleware.ScopedDB(r).Begin()
 			if tx.Error != nil {
 				http.Error(w, "Failed to start transaction: "+tx.Error.Error(), http.StatusInternalServerError)
 				return
 			}
 
+			// Re-assigning the same role replaces its validity window (e.g. to extend access).
+			if err := tx.Model(&existingRole).Updates(map[string]interface{}{
+				"valid_from":  req.ValidFrom,
+				"valid_until": req.ValidUntil,
+			}).Error; err != nil {
+				tx.Rollback()
+				http.Error(w, "Failed to update role validity: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+
 			// Keep the user's primary business vertical aligned with the assigned business role.
 			updateResult := tx.Model(&models.User{}).
 				Where("id = ?", targetUserID).
@@ -131,6 +161,8 @@ func AssignBusinessRole(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
+			middleware.InvalidateUserCache(userID)
+
 			response := map[string]interface{}{
 				"success": true,
 				"message": "Role already assigned for this vertical",
@@ -141,8 +173,13 @@ func AssignBusinessRole(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		if err := middleware.EnforceRoleAssignmentSoD(r, targetUserID, businessRole.BusinessVerticalID, businessRoleID, &existingRole.ID); err != nil {
+			middleware.WriteSoDViolation(w, err)
+			return
+		}
+
 		// Replace existing role assignment in the same vertical.
-		tx := config.DB.Begin()
+		tx := middleware.ScopedDB(r).Begin()
 		if tx.Error != nil {
 			http.Error(w, "Failed to start transaction: "+tx.Error.Error(), http.StatusInternalServerError)
 			return
@@ -152,6 +189,8 @@ func AssignBusinessRole(w http.ResponseWriter, r *http.Request) {
 		existingRole.AssignedAt = time.Now()
 		existingRole.AssignedBy = &assignedByID
 		existingRole.IsActive = true
+		existingRole.ValidFrom = req.ValidFrom
+		existingRole.ValidUntil = req.ValidUntil
 
 		if saveErr := tx.Save(&existingRole).Error; saveErr != nil {
 			tx.Rollback()
@@ -178,7 +217,7 @@ func AssignBusinessRole(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		config.DB.
+		middleware.ScopedDB(r).
 			Preload("BusinessRole.BusinessVertical").
 			Preload("User").
 			First(&existingRole, "id = ?", existingRole.ID)
@@ -199,6 +238,11 @@ func AssignBusinessRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := middleware.EnforceRoleAssignmentSoD(r, targetUserID, businessRole.BusinessVerticalID, businessRoleID, nil); err != nil {
+		middleware.WriteSoDViolation(w, err)
+		return
+	}
+
 	// Create user business role assignment
 	userBusinessRole := models.UserBusinessRole{
 		UserID:         targetUserID,
@@ -206,9 +250,11 @@ func AssignBusinessRole(w http.ResponseWriter, r *http.Request) {
 		IsActive:       true,
 		AssignedAt:     time.Now(),
 		AssignedBy:     &assignedByID,
+		ValidFrom:      req.ValidFrom,
+		ValidUntil:     req.ValidUntil,
 	}
 
-	tx := config.DB.Begin()
+	tx := middleware.ScopedDB(r).Begin()
 	if tx.Error != nil {
 		http.Error(w, "Failed to start transaction: "+tx.Error.Error(), http.StatusInternalServerError)
 		return
@@ -241,7 +287,7 @@ func AssignBusinessRole(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Load the created record with relationships
-	config.DB.
+	middleware.ScopedDB(r).
 		Preload("BusinessRole.BusinessVertical").
 		Preload("User").
 		First(&userBusinessRole, "id = ?", userBusinessRole.ID)
@@ -279,7 +325,7 @@ func RemoveBusinessRole(w http.ResponseWriter, r *http.Request) {
 
 	// Load current user with all roles
 	var currentUser models.User
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Preload("RoleModel").
 		Preload("UserBusinessRoles.BusinessRole").
 		First(&currentUser, "id = ?", claims.UserID).Error; err != nil {
@@ -302,7 +348,7 @@ func RemoveBusinessRole(w http.ResponseWriter, r *http.Request) {
 
 	// Load the user business role
 	var userBusinessRole models.UserBusinessRole
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Preload("BusinessRole").
 		Where("id = ? AND user_id = ?", userBusinessRoleID, targetUserID).
 		First(&userBusinessRole).Error; err != nil {
@@ -317,7 +363,7 @@ func RemoveBusinessRole(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Delete the role assignment
-	if err := config.DB.Delete(&userBusinessRole).Error; err != nil {
+	if err := middleware.ScopedDB(r).Delete(&userBusinessRole).Error; err != nil {
 		http.Error(w, "Failed to remove role: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -351,7 +397,7 @@ func GetUserRoles(w http.ResponseWriter, r *http.Request) {
 
 	// Load user with business roles
 	var user models.User
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Preload("UserBusinessRoles.BusinessRole.BusinessVertical").
 		Preload("UserBusinessRoles.BusinessRole.Permissions").
 		First(&user, "id = ?", targetUserID).Error; err != nil {
@@ -361,8 +407,9 @@ func GetUserRoles(w http.ResponseWriter, r *http.Request) {
 
 	// Build business roles response
 	businessRoles := []map[string]interface{}{}
+	now := time.Now()
 	for _, ubr := range user.UserBusinessRoles {
-		if ubr.IsActive && ubr.BusinessRole.ID != uuid.Nil {
+		if ubr.IsEffectiveAt(now) && ubr.BusinessRole.ID != uuid.Nil {
 			businessRoles = append(businessRoles, map[string]interface{}{
 				"id":            ubr.ID,
 				"role_id":       ubr.BusinessRole.ID,
@@ -403,7 +450,7 @@ func GetAssignableRoles(w http.ResponseWriter, r *http.Request) {
 
 	// Load current user with all roles
 	var currentUser models.User
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Preload("RoleModel").
 		Preload("UserBusinessRoles.BusinessRole").
 		First(&currentUser, "id = ?", claims.UserID).Error; err != nil {
@@ -423,7 +470,7 @@ func GetAssignableRoles(w http.ResponseWriter, r *http.Request) {
 
 	// Load all business roles for this vertical
 	var businessRoles []models.BusinessRole
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Where("business_vertical_id = ? AND is_active = ?", businessVerticalID, true).
 		Order("level ASC").
 		Find(&businessRoles).Error; err != nil {
@@ -470,7 +517,7 @@ func GetVerticalRoles(w http.ResponseWriter, r *http.Request) {
 
 	// Load all business roles for this vertical
 	var businessRoles []models.BusinessRole
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Preload("Permissions").
 		Where("business_vertical_id = ? AND is_active = ?", businessVerticalID, true).
 		Order("level ASC").
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// recordRoleChange writes a role history entry for an API change. History is best
+// effort here: the change itself has already been applied.
+func recordRoleChange(r *http.Request, roleType string, roleID uuid.UUID, action string, before *models.RoleSnapshot) {
+	var changedBy *uuid.UUID
+	if claims := middleware.GetClaims(r); claims != nil {
+		if id, err := uuid.Parse(claims.UserID); err == nil {
+			changedBy = &id
+		}
+	}
+	if err := models.RecordRoleChange(middleware.ScopedDB(r), roleType, roleID, action, models.RoleChangeSourceAPI, changedBy, before); err != nil {
+		log.Printf("failed to record history of %s %s: %v", roleType, roleID, err)
+	}
+}
+
+// GetRoleHistory returns the change history of a global or business role, newest
+// first, with who changed it and the field and permission diff of each version.
+// Business routes only serve roles of the current vertical. (?limit=&before_version=)
+// GET /api/v1/roles/{id}/history
+// GET /api/v1/business/{businessCode}/roles/{roleId}/history
+func GetRoleHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	raw := vars["id"]
+	if raw == "" {
+		raw = vars["roleId"]
+	}
+	roleID, err := uuid.Parse(raw)
+	if err != nil {
+		http.Error(w, "invalid role ID", http.StatusBadRequest)
+		return
+	}
+
+	query := middleware.ScopedDB(r).Where("role_id = ?", roleID)
+	if businessID := middleware.GetCurrentBusinessID(r); businessID != uuid.Nil {
+		query = query.Where("role_type = ? AND business_vertical_id = ?", models.RoleTypeBusiness, businessID)
+	}
+	if before, err := strconv.Atoi(r.URL.Query().Get("before_version")); err == nil && before > 0 {
+		query = query.Where("version < ?", before)
+	}
+	limit := 50
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 200 {
+		limit = l
+	}
+
+	var history []models.RoleChange
+	if err := query.Order("version DESC").Limit(limit).Find(&history).Error; err != nil {
+		http.Error(w, "failed to load role history", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"role_id": roleID, "history": history})
+}
@@ -10,7 +10,6 @@ import (
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"golang.org/x/sync/singleflight"
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 )
@@ -102,6 +101,7 @@ func InvalidateUnifiedRolesCache() {
 type createRoleReq struct {
 	Name        string   `json:"name"`
 	Description string   `json:"description"`
+	IsReadOnly  bool     `json:"is_read_only"`
 	Permissions []string `json:"permissions"`
 }
 
@@ -110,6 +110,7 @@ type roleResponse struct {
 	Name        string               `json:"name"`
 	Description string               `json:"description"`
 	IsActive    bool                 `json:"is_active"`
+	IsReadOnly  bool                 `json:"is_read_only"`
 	Permissions []PermissionResponse `json:"permissions"`
 }
 
@@ -138,7 +139,7 @@ func GetAllRoles(w http.ResponseWriter, r *http.Request) {
 	offset := (page - 1) * limit
 
 	var roles []models.Role
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Preload("Permissions").
 		Where("is_active = ?", true).
 		Limit(limit).
@@ -149,7 +150,7 @@ func GetAllRoles(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var total int64
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Model(&models.Role{}).
 		Where("is_active = ?", true).
 		Count(&total).Error; err != nil {
@@ -204,7 +205,7 @@ func GetAllPermissions(w http.ResponseWriter, r *http.Request) {
 		}
 
 		var permissions []models.Permission
-		if err := config.DB.Find(&permissions).Error; err != nil {
+		if err := middleware.ScopedDB(r).Find(&permissions).Error; err != nil {
 			return nil, err
 		}
 
@@ -242,7 +243,7 @@ func CreatePermission(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid JSON", http.StatusBadRequest)
 		return
 	}
-	if err := config.DB.Create(&perm).Error; err != nil {
+	if err := middleware.ScopedDB(r).Create(&perm).Error; err != nil {
 		http.Error(w, "failed to create permission: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -264,9 +265,10 @@ func CreateRole(w http.ResponseWriter, r *http.Request) {
 		Name:        req.Name,
 		Description: req.Description,
 		IsActive:    true,
+		IsReadOnly:  req.IsReadOnly,
 	}
 
-	if err := config.DB.Create(&role).Error; err != nil {
+	if err := middleware.ScopedDB(r).Create(&role).Error; err != nil {
 		http.Error(w, "failed to create role: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -275,14 +277,15 @@ func CreateRole(w http.ResponseWriter, r *http.Request) {
 	// Assign permissions
 	for _, permName := range req.Permissions {
 		var permission models.Permission
-		if err := config.DB.Where("name = ?", permName).First(&permission).Error; err != nil {
+		if err := middleware.ScopedDB(r).Where("name = ?", permName).First(&permission).Error; err != nil {
 			continue // Skip invalid permissions
 		}
-		config.DB.Model(&role).Association("Permissions").Append(&permission)
+		middleware.ScopedDB(r).Model(&role).Association("Permissions").Append(&permission)
 	}
+	recordRoleChange(r, models.RoleTypeGlobal, role.ID, models.RoleChangeCreated, nil)
 
 	// Load permissions for response
-	config.DB.Preload("Permissions").First(&role, role.ID)
+	middleware.ScopedDB(r).Preload("Permissions").First(&role, role.ID)
 
 	// Convert to response format
 	permissions := make([]PermissionResponse, len(role.Permissions))
@@ -301,6 +304,7 @@ func CreateRole(w http.ResponseWriter, r *http.Request) {
 		Name:        role.Name,
 		Description: role.Description,
 		IsActive:    role.IsActive,
+		IsReadOnly:  role.IsReadOnly,
 		Permissions: permissions,
 	}
 
@@ -328,34 +332,41 @@ func UpdateRole(w http.ResponseWriter, r *http.Request) {
 
 	// Get existing role
 	var role models.Role
-	if err := config.DB.Preload("Permissions").First(&role, "id = ?", id).Error; err != nil {
+	if err := middleware.ScopedDB(r).Preload("Permissions").First(&role, "id = ?", id).Error; err != nil {
 		http.Error(w, "role not found", http.StatusNotFound)
 		return
 	}
+	before, err := models.SnapshotRole(middleware.ScopedDB(r), models.RoleTypeGlobal, role.ID)
+	if err != nil {
+		http.Error(w, "failed to load role: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	// Update basic fields
 	role.Name = req.Name
 	role.Description = req.Description
+	role.IsReadOnly = req.IsReadOnly
 
-	if err := config.DB.Save(&role).Error; err != nil {
+	if err := middleware.ScopedDB(r).Save(&role).Error; err != nil {
 		http.Error(w, "failed to update role: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// Clear existing permissions
-	config.DB.Model(&role).Association("Permissions").Clear()
+	middleware.ScopedDB(r).Model(&role).Association("Permissions").Clear()
 
 	// Assign new permissions
 	for _, permName := range req.Permissions {
 		var permission models.Permission
-		if err := config.DB.Where("name = ?", permName).First(&permission).Error; err != nil {
+		if err := middleware.ScopedDB(r).Where("name = ?", permName).First(&permission).Error; err != nil {
 			continue // Skip invalid permissions
 		}
-		config.DB.Model(&role).Association("Permissions").Append(&permission)
+		middleware.ScopedDB(r).Model(&role).Association("Permissions").Append(&permission)
 	}
+	recordRoleChange(r, models.RoleTypeGlobal, role.ID, models.RoleChangeUpdated, before)
 
 	// Reload with permissions
-	config.DB.Preload("Permissions").First(&role, role.ID)
+	middleware.ScopedDB(r).Preload("Permissions").First(&role, role.ID)
 
 	// Convert to response format
 	permissions := make([]PermissionResponse, len(role.Permissions))
@@ -374,16 +385,13 @@ func UpdateRole(w http.ResponseWriter, r *http.Request) {
 		Name:        role.Name,
 		Description: role.Description,
 		IsActive:    role.IsActive,
+		IsReadOnly:  role.IsReadOnly,
 		Permissions: permissions,
 	}
 
 	// Invalidate cache for every user assigned this global role so updated permissions
-	// apply immediately rather than after the 30s TTL expires.
-	var affectedUserIDs []uuid.UUID
-	config.DB.Model(&models.User{}).Where("role_id = ?", id).Pluck("id", &affectedUserIDs)
-	for _, uid := range affectedUserIDs {
-		middleware.InvalidateUserCache(uid.String())
-	}
+	// apply immediately rather than after the cache TTL expires.
+	middleware.InvalidateUserCacheForRole(id)
 	InvalidateAdminUsersCache()
 	InvalidateUnifiedRolesCache()
 
@@ -403,25 +411,32 @@ func DeleteRole(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var role models.Role
-	if err := config.DB.First(&role, "id = ?", id).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&role, "id = ?", id).Error; err != nil {
 		http.Error(w, "role not found", http.StatusNotFound)
 		return
 	}
 
 	// Check if any users are using this role
 	var userCount int64
-	config.DB.Model(&models.User{}).Where("role_id = ?", id).Count(&userCount)
+	middleware.ScopedDB(r).Model(&models.User{}).Where("role_id = ?", id).Count(&userCount)
 	if userCount > 0 {
 		http.Error(w, "cannot delete role: users are assigned to this role", http.StatusBadRequest)
 		return
 	}
 
+	before, err := models.SnapshotRole(middleware.ScopedDB(r), models.RoleTypeGlobal, role.ID)
+	if err != nil {
+		http.Error(w, "failed to load role: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	// Soft delete
 	role.IsActive = false
-	if err := config.DB.Save(&role).Error; err != nil {
+	if err := middleware.ScopedDB(r).Save(&role).Error; err != nil {
 		http.Error(w, "failed to delete role: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	recordRoleChange(r, models.RoleTypeGlobal, role.ID, models.RoleChangeDeleted, before)
 	InvalidateAdminUsersCache()
 	InvalidateUnifiedRolesCache()
 
@@ -437,6 +452,7 @@ type UnifiedRoleResponse struct {
 	Level              int                   `json:"level"`
 	IsActive           bool                  `json:"is_active"`
 	IsGlobal           bool                  `json:"is_global"`
+	IsReadOnly         bool                  `json:"is_read_only"`
 	BusinessVerticalID *uuid.UUID            `json:"business_vertical_id,omitempty"`
 	BusinessVertical   *BusinessVerticalInfo `json:"business_vertical,omitempty"`
 	Permissions        []PermissionResponse  `json:"permissions"`
@@ -473,7 +489,7 @@ func GetAllRolesUnified(w http.ResponseWriter, r *http.Request) {
 
 		// 1. Fetch Global Roles
 		var globalRoles []models.Role
-		if err := config.DB.Preload("Permissions").
+		if err := middleware.ScopedDB(r).Preload("Permissions").
 			Where("is_active = ?", true).
 			Order("level ASC").
 			Find(&globalRoles).Error; err != nil {
@@ -492,7 +508,7 @@ func GetAllRolesUnified(w http.ResponseWriter, r *http.Request) {
 				RoleID uuid.UUID
 				Count  int64
 			}
-			config.DB.Model(&models.User{}).
+			middleware.ScopedDB(r).Model(&models.User{}).
 				Select("role_id, COUNT(*) as count").
 				Where("role_id IN ?", globalRoleIDs).
 				Group("role_id").
@@ -524,6 +540,7 @@ func GetAllRolesUnified(w http.ResponseWriter, r *http.Request) {
 				Level:              role.Level,
 				IsActive:           role.IsActive,
 				IsGlobal:           true,
+				IsReadOnly:         role.IsReadOnly,
 				BusinessVerticalID: nil,
 				BusinessVertical:   nil,
 				Permissions:        permissions,
@@ -533,7 +550,7 @@ func GetAllRolesUnified(w http.ResponseWriter, r *http.Request) {
 
 		// 2. Fetch Business Roles (if requested)
 		if includeBusiness {
-			query := config.DB.Preload("Permissions").
+			query := middleware.ScopedDB(r).Preload("Permissions").
 				Preload("BusinessVertical").
 				Where("is_active = ?", true)
 
@@ -561,7 +578,7 @@ func GetAllRolesUnified(w http.ResponseWriter, r *http.Request) {
 					BusinessRoleID uuid.UUID
 					Count          int64
 				}
-				config.DB.Model(&models.UserBusinessRole{}).
+				middleware.ScopedDB(r).Model(&models.UserBusinessRole{}).
 					Select("business_role_id, COUNT(*) as count").
 					Where("business_role_id IN ? AND is_active = ?", roleIDs, true).
 					Group("business_role_id").
@@ -599,6 +616,7 @@ func GetAllRolesUnified(w http.ResponseWriter, r *http.Request) {
 					Level:              role.Level,
 					IsActive:           role.IsActive,
 					IsGlobal:           false,
+					IsReadOnly:         role.IsReadOnly,
 					BusinessVerticalID: &role.BusinessVerticalID,
 					BusinessVertical:   verticalInfo,
 					Permissions:        permissions,
@@ -0,0 +1,642 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/hooks"
+)
+
+const (
+	sensorTokenPrefix       = "snsr_"
+	maxSensorBodyBytes      = 16 << 10
+	maxSensorReadingsPerReq = 50
+	// Devices buffer readings while offline; older readings are rejected.
+	maxSensorReadingAge   = 7 * 24 * time.Hour
+	maxSensorClockSkew    = 5 * time.Minute
+	defaultSensorRPM      = 6
+	defaultSensorRegister = 5
+)
+
+// SensorDeviceHandler ingests readings from token-authenticated field sensors and
+// manages their registration approval queue.
+type SensorDeviceHandler struct {
+	db              *gorm.DB
+	readingLimiter  *keyedRateLimiter
+	registerLimiter *keyedRateLimiter
+	defaultRPM      int
+	registerRPM     int
+}
+
+func NewSensorDeviceHandler() *SensorDeviceHandler {
+	return &SensorDeviceHandler{
+		db:              config.DB,
+		readingLimiter:  newKeyedRateLimiter(),
+		registerLimiter: newKeyedRateLimiter(),
+		defaultRPM:      envPositiveInt("SENSOR_RATE_LIMIT_PER_MINUTE", defaultSensorRPM),
+		registerRPM:     envPositiveInt("SENSOR_REGISTER_RATE_LIMIT_PER_MINUTE", defaultSensorRegister),
+	}
+}
+
+type sensorRegisterInput struct {
+	DeviceKey       string                  `json:"device_key"`
+	DeviceType      models.SensorDeviceType `json:"device_type"`
+	SiteCode        string                  `json:"site_code"`
+	Name            string                  `json:"name"`
+	FirmwareVersion string                  `json:"firmware_version"`
+}
+
+type sensorReadingInput struct {
+	Metric     string     `json:"metric"`
+	Value      *float64   `json:"value"`
+	Unit       string     `json:"unit"`
+	RecordedAt *time.Time `json:"recorded_at"`
+}
+
+type sensorReadingBatchInput struct {
+	Readings []sensorReadingInput `json:"readings"`
+}
+
+// RegisterSensorDevice lets a device announce itself. It is created pending with a token
+// that only works once a site engineer approves it; the token is returned once, here.
+// A pending device may register again to receive a fresh token.
+// POST /api/v1/sensors/register
+func (h *SensorDeviceHandler) RegisterSensorDevice(w http.ResponseWriter, r *http.Request) {
+	clientIP := clientIPFromRequest(r)
+	if !h.registerLimiter.allow("ip:"+clientIP, h.registerRPM, time.Now()) {
+		http.Error(w, "too many registration attempts", http.StatusTooManyRequests)
+		return
+	}
+
+	var input sensorRegisterInput
+	if err := decodeStrictJSON(w, r, &input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	input.DeviceKey = strings.TrimSpace(input.DeviceKey)
+	if input.DeviceKey == "" || len(input.DeviceKey) > 100 {
+		http.Error(w, "device_key is required (max 100 characters)", http.StatusBadRequest)
+		return
+	}
+	if !models.ValidSensorDeviceType(input.DeviceType) {
+		http.Error(w, "device_type must be rain_gauge, water_level, solar_inverter, pyranometer, energy_meter, flow_meter or pump", http.StatusBadRequest)
+		return
+	}
+
+	var site models.Site
+	if err := middleware.WithDataScope(r, h.db).Where("code = ? AND is_active = ?", strings.TrimSpace(input.SiteCode), true).First(&site).Error; err != nil {
+		http.Error(w, "unknown site_code", http.StatusBadRequest)
+		return
+	}
+
+	token, tokenHash, err := newSensorToken()
+	if err != nil {
+		http.Error(w, "failed to issue device token", http.StatusInternalServerError)
+		return
+	}
+
+	var device models.SensorDevice
+	err = middleware.WithDataScope(r, h.db).Where("device_key = ?", input.DeviceKey).First(&device).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		device = models.SensorDevice{
+			DeviceKey:          input.DeviceKey,
+			Name:               strings.TrimSpace(input.Name),
+			DeviceType:         input.DeviceType,
+			BusinessVerticalID: site.BusinessVerticalID,
+			SiteID:             &site.ID,
+			Status:             models.SensorDevicePending,
+			TokenHash:          tokenHash,
+			TokenPrefix:        token[:12],
+			FirmwareVersion:    strings.TrimSpace(input.FirmwareVersion),
+			RegisteredFromIP:   clientIP,
+		}
+		if err := middleware.WithDataScope(r, h.db).Create(&device).Error; err != nil {
+			http.Error(w, "failed to register device", http.StatusInternalServerError)
+			return
+		}
+	case err != nil:
+		http.Error(w, "failed to register device", http.StatusInternalServerError)
+		return
+	case device.Status != models.SensorDevicePending:
+		http.Error(w, "device is already registered; ask a site engineer to rotate its token", http.StatusConflict)
+		return
+	default:
+		if err := middleware.WithDataScope(r, h.db).Model(&device).Updates(map[string]interface{}{
+			"name":                 strings.TrimSpace(input.Name),
+			"device_type":          input.DeviceType,
+			"business_vertical_id": site.BusinessVerticalID,
+			"site_id":              site.ID,
+			"token_hash":           tokenHash,
+			"token_prefix":         token[:12],
+			"firmware_version":     strings.TrimSpace(input.FirmwareVersion),
+			"registered_from_ip":   clientIP,
+		}).Error; err != nil {
+			http.Error(w, "failed to register device", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"device_id": device.ID,
+		"status":    models.SensorDevicePending,
+		"token":     token,
+	})
+}
+
+// IngestSensorReadings accepts a batch of readings from an approved device. The device
+// authenticates with "Authorization: Bearer <token>" or X-Device-Token. The batch is
+// all-or-nothing: any reading outside the device type's schema rejects the request.
+// POST /api/v1/sensors/readings
+func (h *SensorDeviceHandler) IngestSensorReadings(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	device, ok := h.authenticateDevice(w, r, "", now)
+	if !ok {
+		return
+	}
+
+	var batch sensorReadingBatchInput
+	if err := decodeStrictJSON(w, r, &batch); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(batch.Readings) == 0 || len(batch.Readings) > maxSensorReadingsPerReq {
+		http.Error(w, fmt.Sprintf("readings must contain 1 to %d entries", maxSensorReadingsPerReq), http.StatusBadRequest)
+		return
+	}
+
+	readings := make([]models.SensorReading, 0, len(batch.Readings))
+	for i, in := range batch.Readings {
+		if in.Value == nil {
+			http.Error(w, fmt.Sprintf("readings[%d]: value is required", i), http.StatusUnprocessableEntity)
+			return
+		}
+		unit, err := models.ValidateSensorReading(device.DeviceType, in.Metric, *in.Value, in.Unit)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("readings[%d]: %v", i, err), http.StatusUnprocessableEntity)
+			return
+		}
+		recordedAt := now
+		if in.RecordedAt != nil {
+			recordedAt = *in.RecordedAt
+			if recordedAt.After(now.Add(maxSensorClockSkew)) || recordedAt.Before(now.Add(-maxSensorReadingAge)) {
+				http.Error(w, fmt.Sprintf("readings[%d]: recorded_at is out of range", i), http.StatusUnprocessableEntity)
+				return
+			}
+		}
+		readings = append(readings, models.SensorReading{
+			DeviceID:           device.ID,
+			BusinessVerticalID: device.BusinessVerticalID,
+			SiteID:             device.SiteID,
+			Metric:             in.Metric,
+			Value:              *in.Value,
+			Unit:               unit,
+			RecordedAt:         recordedAt.UTC(),
+			ReceivedAt:         now,
+		})
+	}
+
+	err := middleware.WithDataScope(r, h.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&readings).Error; err != nil {
+			return err
+		}
+		return tx.Model(device).UpdateColumns(map[string]interface{}{"last_seen_at": now, "offline_alerted_at": nil}).Error
+	})
+	if err != nil {
+		http.Error(w, "failed to store readings", http.StatusInternalServerError)
+		return
+	}
+
+	for _, reading := range readings {
+		hooks.FireTelemetryReading(hooks.TelemetryReadingEvent{
+			DeviceID:           device.ID.String(),
+			DeviceType:         string(device.DeviceType),
+			BusinessVerticalID: device.BusinessVerticalID,
+			SiteID:             device.SiteID,
+			Metric:             reading.Metric,
+			Value:              reading.Value,
+			Unit:               reading.Unit,
+			RecordedAt:         reading.RecordedAt,
+		})
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{"accepted": len(readings)})
+}
+
+type sensorHeartbeatInput struct {
+	FirmwareVersion *string `json:"firmware_version"`
+	SignalStrength  *int    `json:"signal_strength"`
+}
+
+// SendSensorHeartbeat records that an approved device is alive, with its firmware version
+// and signal strength if it reports them, and tells it how often to check in. The device
+// authenticates as for readings; an empty body is a bare heartbeat.
+// POST /api/v1/sensors/heartbeat
+func (h *SensorDeviceHandler) SendSensorHeartbeat(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	device, ok := h.authenticateDevice(w, r, "heartbeat:", now)
+	if !ok {
+		return
+	}
+
+	var input sensorHeartbeatInput
+	if r.ContentLength != 0 {
+		if err := decodeStrictJSON(w, r, &input); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	updates := map[string]interface{}{
+		"last_seen_at":       now,
+		"last_heartbeat_at":  now,
+		"offline_alerted_at": nil,
+	}
+	if input.FirmwareVersion != nil {
+		firmware := strings.TrimSpace(*input.FirmwareVersion)
+		if len(firmware) > 50 {
+			http.Error(w, "firmware_version is longer than 50 characters", http.StatusBadRequest)
+			return
+		}
+		updates["firmware_version"] = firmware
+	}
+	if input.SignalStrength != nil {
+		updates["signal_strength"] = *input.SignalStrength
+	}
+	if err := middleware.WithDataScope(r, h.db).Model(device).UpdateColumns(updates).Error; err != nil {
+		http.Error(w, "failed to record heartbeat", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"device_id":                  device.ID,
+		"status":                     device.Status,
+		"heartbeat_interval_seconds": int(device.HeartbeatInterval().Seconds()),
+	})
+}
+
+// authenticateDevice loads the approved device whose token the request carries and
+// applies its rate limit, kept per limiterPrefix so heartbeats do not use up readings
+func (h *SensorDeviceHandler) authenticateDevice(w http.ResponseWriter, r *http.Request, limiterPrefix string, now time.Time) (*models.SensorDevice, bool) {
+	token := sensorTokenFromRequest(r)
+	if token == "" {
+		http.Error(w, "missing device token", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	var device models.SensorDevice
+	if err := middleware.WithDataScope(r, h.db).Where("token_hash = ?", hashSensorToken(token)).First(&device).Error; err != nil {
+		http.Error(w, "invalid device token", http.StatusUnauthorized)
+		return nil, false
+	}
+	if device.Status != models.SensorDeviceApproved {
+		http.Error(w, "device is "+string(device.Status), http.StatusForbidden)
+		return nil, false
+	}
+
+	limit := device.RateLimitPerMinute
+	if limit <= 0 {
+		limit = h.defaultRPM
+	}
+	if !h.readingLimiter.allow(limiterPrefix+device.ID.String(), limit, now) {
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+		return nil, false
+	}
+	return &device, true
+}
+
+// ListSensorDevices lists the business's sensor devices; ?status=pending gives the approval
+// queue, ?device_type= one kind of equipment and ?offline=true the approved devices that
+// have stopped reporting.
+// GET /api/v1/business/{businessCode}/sensors/devices
+func (h *SensorDeviceHandler) ListSensorDevices(w http.ResponseWriter, r *http.Request) {
+	businessID := middleware.GetCurrentBusinessID(r)
+	if businessID == uuid.Nil {
+		http.Error(w, "invalid business identifier", http.StatusBadRequest)
+		return
+	}
+
+	query := middleware.WithDataScope(r, h.db).Preload("Site").Where("business_vertical_id = ?", businessID)
+	if status := strings.TrimSpace(r.URL.Query().Get("status")); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if deviceType := strings.TrimSpace(r.URL.Query().Get("device_type")); deviceType != "" {
+		query = query.Where("device_type = ?", deviceType)
+	}
+	if siteIDs, restricted := middleware.GetSiteScope(r); restricted {
+		query = query.Where("site_id IN ?", siteIDs)
+	}
+
+	var devices []models.SensorDevice
+	if err := query.Order("created_at DESC").Find(&devices).Error; err != nil {
+		http.Error(w, "failed to list sensor devices", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	offlineOnly := parseBoolQuery(r.URL.Query().Get("offline"))
+	listed := devices[:0]
+	for _, device := range devices {
+		device.Offline = device.IsOffline(now)
+		if !offlineOnly || device.Offline {
+			listed = append(listed, device)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"devices": listed})
+}
+
+type sensorReviewInput struct {
+	SiteID                   *uuid.UUID `json:"site_id"`
+	Name                     *string    `json:"name"`
+	RateLimitPerMinute       *int       `json:"rate_limit_per_minute"`
+	HeartbeatIntervalMinutes *int       `json:"heartbeat_interval_minutes"`
+	Reason                   string     `json:"reason"`
+}
+
+// ApproveSensorDevice activates a pending or disabled device, optionally correcting its
+// site, name, rate limit and heartbeat interval.
+// POST /api/v1/business/{businessCode}/sensors/devices/{deviceId}/approve
+func (h *SensorDeviceHandler) ApproveSensorDevice(w http.ResponseWriter, r *http.Request) {
+	h.reviewSensorDevice(w, r, models.SensorDeviceApproved)
+}
+
+// RejectSensorDevice rejects a device from the approval queue.
+// POST /api/v1/business/{businessCode}/sensors/devices/{deviceId}/reject
+func (h *SensorDeviceHandler) RejectSensorDevice(w http.ResponseWriter, r *http.Request) {
+	h.reviewSensorDevice(w, r, models.SensorDeviceRejected)
+}
+
+// DisableSensorDevice stops an approved device from posting readings.
+// POST /api/v1/business/{businessCode}/sensors/devices/{deviceId}/disable
+func (h *SensorDeviceHandler) DisableSensorDevice(w http.ResponseWriter, r *http.Request) {
+	h.reviewSensorDevice(w, r, models.SensorDeviceDisabled)
+}
+
+func (h *SensorDeviceHandler) reviewSensorDevice(w http.ResponseWriter, r *http.Request, status models.SensorDeviceStatus) {
+	device, ok := h.loadBusinessDevice(w, r)
+	if !ok {
+		return
+	}
+
+	var input sensorReviewInput
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSensorBodyBytes)).Decode(&input); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	switch status {
+	case models.SensorDeviceApproved:
+		if device.Status == models.SensorDeviceRejected {
+			http.Error(w, "rejected devices must register again", http.StatusConflict)
+			return
+		}
+	case models.SensorDeviceRejected:
+		if device.Status != models.SensorDevicePending {
+			http.Error(w, "only pending devices can be rejected", http.StatusConflict)
+			return
+		}
+	case models.SensorDeviceDisabled:
+		if device.Status != models.SensorDeviceApproved {
+			http.Error(w, "only approved devices can be disabled", http.StatusConflict)
+			return
+		}
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":           status,
+		"reviewed_at":      now,
+		"rejection_reason": strings.TrimSpace(input.Reason),
+	}
+	if claims := middleware.GetClaims(r); claims != nil {
+		if reviewerID, err := uuid.Parse(claims.UserID); err == nil {
+			updates["reviewed_by"] = reviewerID
+		}
+	}
+	if status == models.SensorDeviceApproved {
+		if input.SiteID != nil {
+			var site models.Site
+			if err := middleware.WithDataScope(r, h.db).Where("id = ? AND business_vertical_id = ?", *input.SiteID, device.BusinessVerticalID).First(&site).Error; err != nil {
+				http.Error(w, "site not found in this business", http.StatusBadRequest)
+				return
+			}
+			if !middleware.SiteInScope(r, input.SiteID) {
+				http.Error(w, "site is outside your access scope", http.StatusForbidden)
+				return
+			}
+			updates["site_id"] = site.ID
+		}
+		if input.Name != nil {
+			updates["name"] = strings.TrimSpace(*input.Name)
+		}
+		if input.RateLimitPerMinute != nil && *input.RateLimitPerMinute >= 0 {
+			updates["rate_limit_per_minute"] = *input.RateLimitPerMinute
+		}
+		if input.HeartbeatIntervalMinutes != nil && *input.HeartbeatIntervalMinutes >= 0 {
+			updates["heartbeat_interval_minutes"] = *input.HeartbeatIntervalMinutes
+		}
+		// Offline counts again from the approval
+		updates["offline_alerted_at"] = nil
+	}
+
+	if err := middleware.WithDataScope(r, h.db).Model(device).Updates(updates).Error; err != nil {
+		http.Error(w, "failed to update device", http.StatusInternalServerError)
+		return
+	}
+	middleware.WithDataScope(r, h.db).Preload("Site").First(device, "id = ?", device.ID)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"device": device})
+}
+
+// RotateSensorDeviceToken issues a new token for a device and invalidates the old one.
+// The new token is returned once.
+// POST /api/v1/business/{businessCode}/sensors/devices/{deviceId}/rotate-token
+func (h *SensorDeviceHandler) RotateSensorDeviceToken(w http.ResponseWriter, r *http.Request) {
+	device, ok := h.loadBusinessDevice(w, r)
+	if !ok {
+		return
+	}
+
+	token, tokenHash, err := newSensorToken()
+	if err != nil {
+		http.Error(w, "failed to issue device token", http.StatusInternalServerError)
+		return
+	}
+	if err := middleware.WithDataScope(r, h.db).Model(device).Updates(map[string]interface{}{
+		"token_hash":   tokenHash,
+		"token_prefix": token[:12],
+	}).Error; err != nil {
+		http.Error(w, "failed to rotate device token", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"device_id": device.ID,
+		"status":    device.Status,
+		"token":     token,
+	})
+}
+
+// ListSensorReadings returns a device's readings (?from=&to=&metric=&limit=).
+// GET /api/v1/business/{businessCode}/sensors/devices/{deviceId}/readings
+func (h *SensorDeviceHandler) ListSensorReadings(w http.ResponseWriter, r *http.Request) {
+	device, ok := h.loadBusinessDevice(w, r)
+	if !ok {
+		return
+	}
+
+	query := middleware.WithDataScope(r, h.db).Where("device_id = ?", device.ID)
+	q := r.URL.Query()
+	if from, err := time.Parse(time.RFC3339, q.Get("from")); err == nil {
+		query = query.Where("recorded_at >= ?", from)
+	}
+	if to, err := time.Parse(time.RFC3339, q.Get("to")); err == nil {
+		query = query.Where("recorded_at < ?", to)
+	}
+	if metric := strings.TrimSpace(q.Get("metric")); metric != "" {
+		query = query.Where("metric = ?", metric)
+	}
+	limit := 500
+	if l, err := strconv.Atoi(q.Get("limit")); err == nil && l > 0 && l <= 5000 {
+		limit = l
+	}
+
+	var readings []models.SensorReading
+	if err := query.Order("recorded_at DESC").Limit(limit).Find(&readings).Error; err != nil {
+		http.Error(w, "failed to list readings", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"device_id": device.ID, "readings": readings})
+}
+
+// loadBusinessDevice loads the {deviceId} device of the current business, honouring site scope
+func (h *SensorDeviceHandler) loadBusinessDevice(w http.ResponseWriter, r *http.Request) (*models.SensorDevice, bool) {
+	businessID := middleware.GetCurrentBusinessID(r)
+	if businessID == uuid.Nil {
+		http.Error(w, "invalid business identifier", http.StatusBadRequest)
+		return nil, false
+	}
+	deviceID, err := uuid.Parse(mux.Vars(r)["deviceId"])
+	if err != nil {
+		http.Error(w, "invalid device ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	var device models.SensorDevice
+	if err := middleware.WithDataScope(r, h.db).Where("id = ? AND business_vertical_id = ?", deviceID, businessID).First(&device).Error; err != nil {
+		http.Error(w, "device not found", http.StatusNotFound)
+		return nil, false
+	}
+	if !middleware.SiteInScope(r, device.SiteID) {
+		http.Error(w, "device not found", http.StatusNotFound)
+		return nil, false
+	}
+	return &device, true
+}
+
+// decodeStrictJSON decodes a small request body, rejecting unknown fields and trailing data
+func decodeStrictJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSensorBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid payload: %v", err)
+	}
+	if decoder.More() {
+		return errors.New("invalid payload: unexpected data after JSON object")
+	}
+	return nil
+}
+
+func sensorTokenFromRequest(r *http.Request) string {
+	if token := strings.TrimSpace(r.Header.Get("X-Device-Token")); token != "" {
+		return token
+	}
+	auth := strings.TrimSpace(r.Header.Get("Authorization"))
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+func newSensorToken() (token, tokenHash string, err error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = sensorTokenPrefix + hex.EncodeToString(buf)
+	return token, hashSensorToken(token), nil
+}
+
+func hashSensorToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func envPositiveInt(key string, fallback int) int {
+	if value, err := strconv.Atoi(strings.TrimSpace(os.Getenv(key))); err == nil && value > 0 {
+		return value
+	}
+	return fallback
+}
+
+// keyedRateLimiter keeps one token bucket per key (device or client IP). Idle buckets
+// are pruned lazily.
+type keyedRateLimiter struct {
+	mu        sync.Mutex
+	entries   map[string]*keyedRateEntry
+	lastPrune time.Time
+}
+
+type keyedRateEntry struct {
+	limiter  *rate.Limiter
+	perMin   int
+	lastSeen time.Time
+}
+
+func newKeyedRateLimiter() *keyedRateLimiter {
+	return &keyedRateLimiter{entries: make(map[string]*keyedRateEntry)}
+}
+
+// allow reports whether key may make another request at perMinute requests per minute,
+// with a burst of the same size so a device can flush a small backlog.
+func (l *keyedRateLimiter) allow(key string, perMinute int, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastPrune) > 10*time.Minute {
+		for k, entry := range l.entries {
+			if now.Sub(entry.lastSeen) > 10*time.Minute {
+				delete(l.entries, k)
+			}
+		}
+		l.lastPrune = now
+	}
+
+	entry, ok := l.entries[key]
+	if !ok || entry.perMin != perMinute {
+		entry = &keyedRateEntry{
+			limiter: rate.NewLimiter(rate.Limit(float64(perMinute)/60), perMinute),
+			perMin:  perMinute,
+		}
+		l.entries[key] = entry
+	}
+	entry.lastSeen = now
+	return entry.limiter.AllowN(now, 1)
+}
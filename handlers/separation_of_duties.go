@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+type sodRuleRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	PermissionA string `json:"permission_a"`
+	PermissionB string `json:"permission_b"`
+	IsActive    *bool  `json:"is_active"`
+}
+
+// sodRuleScope returns the vertical rules are managed for: the current business on
+// business routes, nil (global rules) on admin routes.
+func sodRuleScope(r *http.Request) *uuid.UUID {
+	if businessID := middleware.GetCurrentBusinessID(r); businessID != uuid.Nil {
+		return &businessID
+	}
+	return nil
+}
+
+// ListSoDRules lists the SoD rules in scope. Business routes also return the global
+// rules that apply to the vertical; those can only be changed by platform admins.
+// GET /api/v1/business/{businessCode}/sod-rules
+// GET /api/v1/admin/sod-rules
+func ListSoDRules(w http.ResponseWriter, r *http.Request) {
+	query := middleware.ScopedDB(r).Order("name")
+	if scope := sodRuleScope(r); scope != nil {
+		query = query.Where("business_vertical_id = ? OR business_vertical_id IS NULL", *scope)
+	} else {
+		query = query.Where("business_vertical_id IS NULL")
+	}
+
+	var rules []models.SoDRule
+	if err := query.Find(&rules).Error; err != nil {
+		http.Error(w, "failed to load SoD rules", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"rules": rules})
+}
+
+// CreateSoDRule adds a rule forbidding one user from holding both permissions
+// POST /api/v1/business/{businessCode}/sod-rules
+// POST /api/v1/admin/sod-rules
+func CreateSoDRule(w http.ResponseWriter, r *http.Request) {
+	var req sodRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	rule := models.SoDRule{BusinessVerticalID: sodRuleScope(r), IsActive: true}
+	if !applySoDRuleRequest(w, &rule, req) {
+		return
+	}
+	if claims := middleware.GetClaims(r); claims != nil {
+		if id, err := uuid.Parse(claims.UserID); err == nil {
+			rule.CreatedBy = &id
+		}
+	}
+
+	if err := middleware.ScopedDB(r).Create(&rule).Error; err != nil {
+		http.Error(w, "failed to create SoD rule", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"rule": rule})
+}
+
+// UpdateSoDRule edits or (de)activates a rule in scope
+// PUT /api/v1/business/{businessCode}/sod-rules/{id}
+// PUT /api/v1/admin/sod-rules/{id}
+func UpdateSoDRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := loadScopedSoDRule(w, r)
+	if !ok {
+		return
+	}
+
+	var req sodRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		req.Name = rule.Name
+	}
+	if req.PermissionA == "" {
+		req.PermissionA = rule.PermissionA
+	}
+	if req.PermissionB == "" {
+		req.PermissionB = rule.PermissionB
+	}
+	if !applySoDRuleRequest(w, rule, req) {
+		return
+	}
+
+	if err := middleware.ScopedDB(r).Save(rule).Error; err != nil {
+		http.Error(w, "failed to update SoD rule", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"rule": rule})
+}
+
+// DeleteSoDRule removes a rule in scope; its override history is kept
+// DELETE /api/v1/business/{businessCode}/sod-rules/{id}
+// DELETE /api/v1/admin/sod-rules/{id}
+func DeleteSoDRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := loadScopedSoDRule(w, r)
+	if !ok {
+		return
+	}
+	if err := middleware.ScopedDB(r).Delete(rule).Error; err != nil {
+		http.Error(w, "failed to delete SoD rule", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListSoDOverrides returns the audit trail of super admin overrides, newest first
+// (?user_id=&rule_id=&context=&limit=)
+// GET /api/v1/business/{businessCode}/sod-overrides
+// GET /api/v1/admin/sod-overrides
+func ListSoDOverrides(w http.ResponseWriter, r *http.Request) {
+	query := middleware.ScopedDB(r).Preload("Rule")
+	if scope := sodRuleScope(r); scope != nil {
+		query = query.Where("business_vertical_id = ?", *scope)
+	}
+	if userID, err := uuid.Parse(r.URL.Query().Get("user_id")); err == nil {
+		query = query.Where("user_id = ?", userID)
+	}
+	if ruleID, err := uuid.Parse(r.URL.Query().Get("rule_id")); err == nil {
+		query = query.Where("rule_id = ?", ruleID)
+	}
+	if context := r.URL.Query().Get("context"); context != "" {
+		query = query.Where("context = ?", context)
+	}
+	limit := 100
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 500 {
+		limit = l
+	}
+
+	var overrides []models.SoDOverride
+	if err := query.Order("created_at DESC").Limit(limit).Find(&overrides).Error; err != nil {
+		http.Error(w, "failed to load SoD overrides", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"overrides": overrides})
+}
+
+// applySoDRuleRequest validates req and copies it onto rule; both permissions must be
+// distinct entries of the permission catalog.
+func applySoDRuleRequest(w http.ResponseWriter, rule *models.SoDRule, req sodRuleRequest) bool {
+	req.Name = strings.TrimSpace(req.Name)
+	req.PermissionA = strings.TrimSpace(req.PermissionA)
+	req.PermissionB = strings.TrimSpace(req.PermissionB)
+	if req.Name == "" || req.PermissionA == "" || req.PermissionB == "" {
+		http.Error(w, "name, permission_a and permission_b are required", http.StatusBadRequest)
+		return false
+	}
+	if req.PermissionA == req.PermissionB {
+		http.Error(w, "permission_a and permission_b must differ", http.StatusBadRequest)
+		return false
+	}
+
+	var known int64
+	if err := config.DB.Model(&models.Permission{}).
+		Where("name IN ?", []string{req.PermissionA, req.PermissionB}).
+		Count(&known).Error; err != nil {
+		http.Error(w, "failed to validate permissions", http.StatusInternalServerError)
+		return false
+	}
+	if known != 2 {
+		http.Error(w, "permission_a and permission_b must be catalog permissions", http.StatusBadRequest)
+		return false
+	}
+
+	rule.Name = req.Name
+	rule.Description = req.Description
+	rule.PermissionA = req.PermissionA
+	rule.PermissionB = req.PermissionB
+	if req.IsActive != nil {
+		rule.IsActive = *req.IsActive
+	}
+	return true
+}
+
+// loadScopedSoDRule loads the {id} rule; business routes may only touch their own rules
+func loadScopedSoDRule(w http.ResponseWriter, r *http.Request) (*models.SoDRule, bool) {
+	ruleID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid rule ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	query := middleware.ScopedDB(r).Where("id = ?", ruleID)
+	if scope := sodRuleScope(r); scope != nil {
+		query = query.Where("business_vertical_id = ?", *scope)
+	} else {
+		query = query.Where("business_vertical_id IS NULL")
+	}
+
+	var rule models.SoDRule
+	if err := query.First(&rule).Error; err != nil {
+		http.Error(w, "SoD rule not found", http.StatusNotFound)
+		return nil, false
+	}
+	return &rule, true
+}
@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/sms"
+)
+
+// smsSendTimeout bounds one provider call
+const smsSendTimeout = 20 * time.Second
+
+// errSMSUnavailable is returned when no SMS provider is configured
+var errSMSUnavailable = errors.New("sms is not configured")
+
+var (
+	smsRouterOnce sync.Once
+	smsRouter     *sms.Router
+)
+
+// smsGateway returns the SMS router, or nil when no provider is configured
+func smsGateway() *sms.Router {
+	smsRouterOnce.Do(func() {
+		router, err := sms.NewRouterFromEnv()
+		switch {
+		case err != nil:
+			log.Printf("❌ sms: %v, SMS delivery disabled", err)
+		case router == nil:
+			log.Println("ℹ️ sms: no provider configured, SMS delivery disabled")
+		}
+		smsRouter = router
+	})
+	return smsRouter
+}
+
+// SMSService texts users through the configured provider and keeps the delivery log
+type SMSService struct {
+	db *gorm.DB
+}
+
+// NewSMSService creates the SMS service
+func NewSMSService() *SMSService {
+	return &SMSService{db: config.DB}
+}
+
+// Send texts a phone number, cut to two segments, and logs the message with its provider
+// status. The text is left out of the log for one-time codes.
+func (s *SMSService) Send(ctx context.Context, userID, phone, purpose string, referenceID *uuid.UUID, text string) (*models.SMSMessage, error) {
+	router := smsGateway()
+	if router == nil {
+		return nil, errSMSUnavailable
+	}
+	text = truncateSMS(text)
+	provider, msg, err := router.Route(phone, purpose, text)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &models.SMSMessage{
+		UserID:      userID,
+		To:          msg.To,
+		Purpose:     purpose,
+		ReferenceID: referenceID,
+		Body:        text,
+		Provider:    provider.Name(),
+		SenderID:    msg.SenderID,
+		Status:      models.SMSQueued,
+	}
+	if purpose == models.SMSPurposeOTP {
+		record.Body = ""
+	}
+	if err := s.db.Create(record).Error; err != nil {
+		return nil, err
+	}
+
+	return record, s.attempt(ctx, record, provider, msg)
+}
+
+// attempt sends a logged text once, records the attempt in the delivery audit and
+// updates the log: sent, queued for a retry after a transient failure, or failed.
+func (s *SMSService) attempt(ctx context.Context, record *models.SMSMessage, provider sms.Provider, msg sms.Message) error {
+	started := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, smsSendTimeout)
+	defer cancel()
+	record.Attempts++
+	providerID, sendErr := provider.Send(ctx, msg)
+
+	audit := models.NotificationDeliveryAttempt{
+		Channel:    models.NotificationChannelSMS,
+		DeliveryID: record.ID,
+		UserID:     record.UserID,
+		Attempt:    record.Attempts,
+		Provider:   provider.Name(),
+		StartedAt:  started,
+	}
+	if record.Purpose == models.SMSPurposeApprovalReminder {
+		audit.NotificationID = record.ReferenceID // reminders are about a notification
+	}
+	updates := map[string]interface{}{"attempts": record.Attempts}
+	if sendErr != nil {
+		audit.Status, audit.Error, audit.Transient = models.DeliveryAttemptFailed, sendErr.Error(), sms.IsTransient(sendErr)
+		if record.FailAttempt(sendErr.Error(), audit.Transient, time.Now()) {
+			log.Printf("⚠️ sms %s to %s attempt %d failed, retrying at %v: %v", record.ID, record.To, record.Attempts, record.NextAttemptAt, sendErr)
+		}
+		updates["status"], updates["error"], updates["next_attempt_at"] = record.Status, record.Error, record.NextAttemptAt
+	} else {
+		audit.Status = models.DeliveryAttemptSucceeded
+		record.ProviderMessageID = providerID
+		record.NextAttemptAt = nil
+		record.Error = ""
+		record.ApplyReport(models.SMSSent, "", time.Now())
+		updates["status"], updates["provider_message_id"], updates["sent_at"] = record.Status, providerID, record.SentAt
+		updates["error"], updates["next_attempt_at"] = "", nil
+	}
+	recordDeliveryAttempt(s.db, audit)
+	if err := s.db.Model(record).Updates(updates).Error; err != nil {
+		log.Printf("❌ Failed to update SMS %s: %v", record.ID, err)
+	}
+	return sendErr
+}
+
+// resend sends a queued retry of a logged text again through the currently routed provider
+func (s *SMSService) resend(record *models.SMSMessage) error {
+	router := smsGateway()
+	if router == nil {
+		return errSMSUnavailable
+	}
+	provider, msg, err := router.Route(record.To, record.Purpose, record.Body)
+	if err != nil {
+		record.Attempts++
+		record.FailAttempt(err.Error(), false, time.Now())
+		return s.db.Model(record).Updates(map[string]interface{}{
+			"attempts": record.Attempts, "status": record.Status, "error": record.Error, "next_attempt_at": nil,
+		}).Error
+	}
+	if provider.Name() != record.Provider || msg.SenderID != record.SenderID {
+		record.Provider, record.SenderID = provider.Name(), msg.SenderID
+		s.db.Model(record).Updates(map[string]interface{}{"provider": record.Provider, "sender_id": record.SenderID})
+	}
+	return s.attempt(context.Background(), record, provider, msg)
+}
+
+// SendToUserWithoutApp texts a user about a notification of the type when SMS is how
+// they are reached: they opted into SMS, or have no phone registered for mobile pushes.
+// Users who switched the type off are skipped, and each user gets one text per purpose
+// and reference. It reports whether a text was sent.
+func (s *SMSService) SendToUserWithoutApp(ctx context.Context, userID string, notifType models.NotificationType, purpose string, referenceID uuid.UUID, text string) (bool, error) {
+	if smsGateway() == nil {
+		return false, nil
+	}
+
+	var prefs models.NotificationPreference
+	optedIn := false
+	if err := s.db.Where("user_id = ?", userID).First(&prefs).Error; err == nil {
+		for _, disabled := range prefs.DisabledTypes {
+			if disabled == string(notifType) {
+				return false, nil
+			}
+		}
+		optedIn = prefs.EnableSMS
+	}
+	if !optedIn {
+		var devices int64
+		if err := s.db.Model(&models.MobilePushToken{}).
+			Where("user_id = ? AND is_active = ?", userID, true).
+			Count(&devices).Error; err != nil {
+			return false, err
+		}
+		if devices > 0 {
+			return false, nil
+		}
+	}
+
+	var already int64
+	if err := s.db.Model(&models.SMSMessage{}).
+		Where("purpose = ? AND reference_id = ? AND user_id = ? AND status <> ?", purpose, referenceID, userID, models.SMSFailed).
+		Count(&already).Error; err != nil {
+		return false, err
+	}
+	if already > 0 {
+		return false, nil
+	}
+
+	var user models.User
+	if err := s.db.Select("id", "phone").Where("id = ? AND is_active = ?", userID, true).Take(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if _, err := s.Send(ctx, userID, user.Phone, purpose, &referenceID, text); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// truncateSMS keeps a text within two SMS segments
+func truncateSMS(text string) string {
+	const max = 306
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max-3]) + "..."
+}
+
+// HandleSMSCallback records delivery statuses posted by an SMS provider. Each provider
+// authenticates its own callbacks: Twilio by request signature, MSG91 by the callback
+// token in the URL.
+// POST /api/v1/sms/callbacks/{provider}
+func HandleSMSCallback(w http.ResponseWriter, r *http.Request) {
+	router := smsGateway()
+	var provider sms.Provider
+	if router != nil {
+		provider = router.Provider(mux.Vars(r)["provider"])
+	}
+	if provider == nil {
+		http.Error(w, "unknown provider", http.StatusNotFound)
+		return
+	}
+
+	reports, err := provider.ParseCallback(r)
+	if errors.Is(err, sms.ErrUnauthenticatedCallback) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	for _, report := range reports {
+		q := middleware.ScopedDB(r).Where("provider = ? AND provider_message_id = ?", provider.Name(), report.ProviderMessageID)
+		if report.To != "" && report.To != "+" {
+			q = q.Where("to_number = ?", report.To)
+		}
+		var messages []models.SMSMessage
+		if err := q.Find(&messages).Error; err != nil {
+			log.Printf("❌ Failed to load SMS %s for a delivery report: %v", report.ProviderMessageID, err)
+			http.Error(w, "failed to record delivery report", http.StatusInternalServerError)
+			return
+		}
+		for i := range messages {
+			m := &messages[i]
+			if !m.ApplyReport(report.Status, report.Error, now) {
+				continue
+			}
+			if err := middleware.ScopedDB(r).Model(m).Updates(map[string]interface{}{
+				"status": m.Status, "error": m.Error, "sent_at": m.SentAt, "delivered_at": m.DeliveredAt,
+			}).Error; err != nil {
+				log.Printf("❌ Failed to update SMS %s: %v", m.ID, err)
+			}
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/hooks"
+)
+
+const (
+	maxSolarBodyBytes        = 1 << 20
+	maxSolarReadingsPerReq   = 1000
+	maxSolarReadingAge       = 90 * 24 * time.Hour // inverter logs backfilled after an outage
+	solarPartitionLockKey    = 7368201             // serialises monthly partition creation
+	solarWriteGenerationPerm = "solar:write_generation"
+)
+
+// solarGenerationInput is one inverter reading. The inverter is named by its registered
+// device ID or device key.
+type solarGenerationInput struct {
+	DeviceID       *uuid.UUID `json:"device_id"`
+	DeviceKey      string     `json:"device_key"`
+	RecordedAt     *time.Time `json:"recorded_at"`
+	EnergyKWh      *float64   `json:"energy_kwh"`
+	PowerKW        *float64   `json:"power_kw"`
+	DCVoltage      *float64   `json:"dc_voltage"`
+	InverterTempC  *float64   `json:"inverter_temp_c"`
+	InverterStatus string     `json:"inverter_status"`
+}
+
+// IngestSolarGeneration stores inverter readings for a site: one reading, or a batch as
+// {"readings": [...]}. Every reading must come from an approved solar inverter registered
+// at the site. Readings already stored for the same device and timestamp are skipped, so
+// a batch can be retried safely; the rest of the batch is all-or-nothing.
+// POST /api/v1/solar/sites/{siteId}/generation
+func IngestSolarGeneration(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	siteID, err := uuid.Parse(mux.Vars(r)["siteId"])
+	if err != nil {
+		http.Error(w, "invalid site ID", http.StatusBadRequest)
+		return
+	}
+
+	db := middleware.ScopedDB(r)
+	var site models.Site
+	if err := db.Where("id = ? AND is_active = ?", siteID, true).First(&site).Error; err != nil ||
+		!middleware.InDataScope(r, site.BusinessVerticalID, &site.ID) {
+		http.Error(w, "site not found", http.StatusNotFound)
+		return
+	}
+	if !middleware.HasPermissionInVertical(userID, solarWriteGenerationPerm, site.BusinessVerticalID) {
+		http.Error(w, "missing permission "+solarWriteGenerationPerm, http.StatusForbidden)
+		return
+	}
+
+	var body struct {
+		solarGenerationInput
+		Readings []solarGenerationInput `json:"readings"`
+	}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSolarBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&body); err != nil {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	inputs := body.Readings
+	if len(inputs) == 0 {
+		inputs = []solarGenerationInput{body.solarGenerationInput}
+	}
+	if len(inputs) > maxSolarReadingsPerReq {
+		http.Error(w, fmt.Sprintf("readings must contain at most %d entries", maxSolarReadingsPerReq), http.StatusBadRequest)
+		return
+	}
+
+	var devices []models.SensorDevice
+	if err := db.Where("site_id = ? AND device_type = ? AND status = ?",
+		site.ID, models.SensorDeviceSolarInverter, models.SensorDeviceApproved).Find(&devices).Error; err != nil {
+		http.Error(w, "failed to load devices", http.StatusInternalServerError)
+		return
+	}
+	byID := make(map[uuid.UUID]*models.SensorDevice, len(devices))
+	byKey := make(map[string]*models.SensorDevice, len(devices))
+	for i := range devices {
+		byID[devices[i].ID] = &devices[i]
+		byKey[devices[i].DeviceKey] = &devices[i]
+	}
+
+	now := time.Now()
+	readings := make([]models.SolarGenerationReading, 0, len(inputs))
+	seen := map[uuid.UUID]bool{}
+	for i, in := range inputs {
+		var device *models.SensorDevice
+		if in.DeviceID != nil {
+			device = byID[*in.DeviceID]
+		} else {
+			device = byKey[strings.TrimSpace(in.DeviceKey)]
+		}
+		if device == nil {
+			http.Error(w, fmt.Sprintf("readings[%d]: device is not an approved solar inverter at this site", i), http.StatusUnprocessableEntity)
+			return
+		}
+		if in.EnergyKWh == nil {
+			http.Error(w, fmt.Sprintf("readings[%d]: energy_kwh is required", i), http.StatusUnprocessableEntity)
+			return
+		}
+		reading := models.SolarGenerationReading{
+			DeviceID:           device.ID,
+			BusinessVerticalID: site.BusinessVerticalID,
+			SiteID:             site.ID,
+			EnergyKWh:          *in.EnergyKWh,
+			PowerKW:            in.PowerKW,
+			DCVoltage:          in.DCVoltage,
+			InverterTempC:      in.InverterTempC,
+			InverterStatus:     strings.TrimSpace(in.InverterStatus),
+			ReceivedAt:         now,
+			ReceivedBy:         claims.UserID,
+		}
+		if in.RecordedAt != nil {
+			reading.RecordedAt = in.RecordedAt.UTC()
+			if reading.RecordedAt.After(now.Add(maxSensorClockSkew)) || reading.RecordedAt.Before(now.Add(-maxSolarReadingAge)) {
+				http.Error(w, fmt.Sprintf("readings[%d]: recorded_at is out of range", i), http.StatusUnprocessableEntity)
+				return
+			}
+		}
+		if err := reading.Validate(); err != nil {
+			http.Error(w, fmt.Sprintf("readings[%d]: %v", i, err), http.StatusUnprocessableEntity)
+			return
+		}
+		readings = append(readings, reading)
+		seen[device.ID] = true
+	}
+
+	var stored int64
+	err = db.Transaction(func(tx *gorm.DB) error {
+		months := map[string]bool{}
+		for _, reading := range readings {
+			name, _, _ := models.SolarGenerationPartition(reading.RecordedAt)
+			if months[name] {
+				continue
+			}
+			if len(months) == 0 {
+				if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", solarPartitionLockKey).Error; err != nil {
+					return err
+				}
+			}
+			months[name] = true
+			if err := models.EnsureSolarGenerationPartition(tx, reading.RecordedAt); err != nil {
+				return err
+			}
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&readings, 200)
+		if result.Error != nil {
+			return result.Error
+		}
+		stored = result.RowsAffected
+		deviceIDs := make([]uuid.UUID, 0, len(seen))
+		for id := range seen {
+			deviceIDs = append(deviceIDs, id)
+		}
+		return tx.Model(&models.SensorDevice{}).Where("id IN ?", deviceIDs).UpdateColumns(map[string]interface{}{"last_seen_at": now, "offline_alerted_at": nil}).Error
+	})
+	if err != nil {
+		http.Error(w, "failed to store readings", http.StatusInternalServerError)
+		return
+	}
+
+	for _, reading := range readings {
+		for metric, value := range reading.Telemetry() {
+			unit, _ := models.TelemetryMetricUnit(models.SensorDeviceSolarInverter, metric)
+			hooks.FireTelemetryReading(hooks.TelemetryReadingEvent{
+				DeviceID:           reading.DeviceID.String(),
+				DeviceType:         string(models.SensorDeviceSolarInverter),
+				BusinessVerticalID: reading.BusinessVerticalID,
+				SiteID:             &site.ID,
+				Metric:             metric,
+				Value:              value,
+				Unit:               unit,
+				RecordedAt:         reading.RecordedAt,
+				Attributes:         map[string]interface{}{"inverter_status": reading.InverterStatus},
+			})
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"received":   len(readings),
+		"stored":     stored,
+		"duplicates": int64(len(readings)) - stored,
+	})
+}
+
+// solarGenerationDay is a site's generation on one day
+type solarGenerationDay struct {
+	SiteID      uuid.UUID `json:"site_id"`
+	Day         time.Time `json:"day"`
+	EnergyKWh   float64   `gorm:"column:energy_kwh" json:"energy_kwh"`
+	PeakPowerKW *float64  `json:"peak_power_kw,omitempty"`
+	Readings    int       `json:"readings"`
+	Devices     int       `json:"devices"`
+}
+
+// GetSolarGeneration returns the business's generation per site and day (UTC) from
+// ?from= to ?to= (YYYY-MM-DD, inclusive; the last seven days by default), optionally
+// for one ?site_id= or ?device_id=.
+// GET /api/v1/business/{businessCode}/solar/generation
+func GetSolarGeneration(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load generation")
+		return
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from, to := today.AddDate(0, 0, -6), today
+	q := r.URL.Query()
+	if v := q.Get("from"); v != "" {
+		if from, err = time.Parse("2006-01-02", v); err != nil {
+			http.Error(w, "from must be YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if to, err = time.Parse("2006-01-02", v); err != nil {
+			http.Error(w, "to must be YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+	}
+	if to.Before(from) {
+		http.Error(w, "to must not be before from", http.StatusBadRequest)
+		return
+	}
+
+	query := middleware.ScopedDB(r).Table("solar_generation_readings").
+		Where("business_vertical_id = ? AND recorded_at >= ? AND recorded_at < ?", businessID, from, to.AddDate(0, 0, 1))
+	if siteIDs, restricted := middleware.GetSiteScope(r); restricted {
+		query = query.Where("site_id IN ?", siteIDs)
+	}
+	for _, key := range []string{"site_id", "device_id"} {
+		if id, ok := parseUUIDQuery(r, key); ok {
+			query = query.Where(key+" = ?", id)
+		}
+	}
+
+	var days []solarGenerationDay
+	if err := query.Select(`site_id, date_trunc('day', recorded_at AT TIME ZONE 'UTC') AS day,
+			SUM(energy_kwh) AS energy_kwh, MAX(power_kw) AS peak_power_kw,
+			COUNT(*) AS readings, COUNT(DISTINCT device_id) AS devices`).
+		Group("site_id, day").Order("day, site_id").Scan(&days).Error; err != nil {
+		http.Error(w, "failed to load generation", http.StatusInternalServerError)
+		return
+	}
+	var total float64
+	for i := range days {
+		days[i].EnergyKWh = math.Round(days[i].EnergyKWh*1000) / 1000
+		total += days[i].EnergyKWh
+	}
+	if days == nil {
+		days = []solarGenerationDay{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"from":             from.Format("2006-01-02"),
+		"to":               to.Format("2006-01-02"),
+		"days":             days,
+		"total_energy_kwh": math.Round(total*1000) / 1000,
+	})
+}
@@ -0,0 +1,629 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+const (
+	maxSolarPanelsPerReq    = 500
+	defaultSolarHeatmapDays = 90
+)
+
+// loadSolarSite loads the active site with the id in the request's key within the
+// business and the caller's site scope
+func loadSolarSite(r *http.Request, key string, businessID uuid.UUID) (*models.Site, error) {
+	siteID, err := uuid.Parse(mux.Vars(r)[key])
+	if err != nil {
+		return nil, apiError{status: http.StatusBadRequest, message: "invalid site ID"}
+	}
+	var site models.Site
+	if err := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ? AND is_active = ?", siteID, businessID, true).
+		First(&site).Error; err != nil || !middleware.SiteInScope(r, &site.ID) {
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		return nil, apiError{status: http.StatusNotFound, message: "site not found"}
+	}
+	return &site, nil
+}
+
+// loadSolarString loads the string with the id in the request's key within the business
+// and the caller's site scope
+func loadSolarString(r *http.Request, key string, businessID uuid.UUID) (*models.SolarString, error) {
+	id, err := uuid.Parse(mux.Vars(r)[key])
+	if err != nil {
+		return nil, apiError{status: http.StatusBadRequest, message: "invalid string ID"}
+	}
+	var str models.SolarString
+	if err := middleware.ScopedDB(r).Preload("Block").Where("business_vertical_id = ?", businessID).
+		First(&str, "id = ?", id).Error; err != nil || !middleware.SiteInScope(r, &str.SiteID) {
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		return nil, apiError{status: http.StatusNotFound, message: "string not found"}
+	}
+	return &str, nil
+}
+
+// ListSolarBlocks lists a site's blocks with their strings, in layout order.
+// GET /api/v1/business/{businessCode}/solar/sites/{siteId}/blocks
+func ListSolarBlocks(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load blocks")
+		return
+	}
+	site, err := loadSolarSite(r, "siteId", businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load blocks")
+		return
+	}
+
+	var blocks []models.SolarBlock
+	if err := middleware.ScopedDB(r).Preload("Strings", func(db *gorm.DB) *gorm.DB { return db.Order("code") }).
+		Where("site_id = ?", site.ID).Order("code").Find(&blocks).Error; err != nil {
+		http.Error(w, "failed to load blocks", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"site_id": site.ID, "items": blocks, "count": len(blocks)})
+}
+
+// CreateSolarBlock adds a block to a site's plant, optionally wired to one of the site's
+// solar inverters.
+// POST /api/v1/business/{businessCode}/solar/sites/{siteId}/blocks
+func CreateSolarBlock(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to create block")
+		return
+	}
+	site, err := loadSolarSite(r, "siteId", businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to create block")
+		return
+	}
+
+	var req struct {
+		Code             string     `json:"code"`
+		Name             string     `json:"name"`
+		InverterDeviceID *uuid.UUID `json:"inverter_device_id"`
+		LayoutX          float64    `json:"layout_x"`
+		LayoutY          float64    `json:"layout_y"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Code = strings.TrimSpace(req.Code)
+	if req.Code == "" {
+		http.Error(w, "code is required", http.StatusBadRequest)
+		return
+	}
+
+	db := middleware.ScopedDB(r)
+	var count int64
+	if req.InverterDeviceID != nil {
+		db.Model(&models.SensorDevice{}).Where("id = ? AND site_id = ? AND device_type = ?",
+			*req.InverterDeviceID, site.ID, models.SensorDeviceSolarInverter).Count(&count)
+		if count == 0 {
+			http.Error(w, "inverter_device_id must be a solar inverter at this site", http.StatusUnprocessableEntity)
+			return
+		}
+	}
+	db.Model(&models.SolarBlock{}).Where("site_id = ? AND code = ?", site.ID, req.Code).Count(&count)
+	if count > 0 {
+		http.Error(w, "a block with this code already exists at the site", http.StatusConflict)
+		return
+	}
+
+	block := models.SolarBlock{
+		BusinessVerticalID: businessID,
+		SiteID:             site.ID,
+		Code:               req.Code,
+		Name:               strings.TrimSpace(req.Name),
+		InverterDeviceID:   req.InverterDeviceID,
+		LayoutX:            req.LayoutX,
+		LayoutY:            req.LayoutY,
+		CreatedBy:          middleware.GetClaims(r).UserID,
+	}
+	if err := db.Create(&block).Error; err != nil {
+		http.Error(w, "failed to create block", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "block created", "item": block})
+}
+
+// CreateSolarString adds a string to a block.
+// POST /api/v1/business/{businessCode}/solar/blocks/{blockId}/strings
+func CreateSolarString(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to create string")
+		return
+	}
+	blockID, err := uuid.Parse(mux.Vars(r)["blockId"])
+	if err != nil {
+		http.Error(w, "invalid block ID", http.StatusBadRequest)
+		return
+	}
+	db := middleware.ScopedDB(r)
+	var block models.SolarBlock
+	if err := db.Where("business_vertical_id = ?", businessID).First(&block, "id = ?", blockID).Error; err != nil ||
+		!middleware.SiteInScope(r, &block.SiteID) {
+		http.Error(w, "block not found", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Code          string  `json:"code"`
+		PanelCount    int     `json:"panel_count"`
+		RatedCurrentA float64 `json:"rated_current_a"`
+		LayoutX       float64 `json:"layout_x"`
+		LayoutY       float64 `json:"layout_y"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Code = strings.TrimSpace(req.Code)
+	if req.Code == "" {
+		http.Error(w, "code is required", http.StatusBadRequest)
+		return
+	}
+	if req.PanelCount < 0 || req.RatedCurrentA < 0 || req.RatedCurrentA > 100 {
+		http.Error(w, "panel_count must not be negative and rated_current_a must be within 0..100", http.StatusUnprocessableEntity)
+		return
+	}
+
+	var count int64
+	db.Model(&models.SolarString{}).Where("block_id = ? AND code = ?", block.ID, req.Code).Count(&count)
+	if count > 0 {
+		http.Error(w, "a string with this code already exists in the block", http.StatusConflict)
+		return
+	}
+
+	str := models.SolarString{
+		BusinessVerticalID: businessID,
+		SiteID:             block.SiteID,
+		BlockID:            block.ID,
+		Code:               req.Code,
+		PanelCount:         req.PanelCount,
+		RatedCurrentA:      req.RatedCurrentA,
+		LayoutX:            req.LayoutX,
+		LayoutY:            req.LayoutY,
+		Status:             models.SolarEquipmentActive,
+		CreatedBy:          middleware.GetClaims(r).UserID,
+	}
+	if err := db.Create(&str).Error; err != nil {
+		http.Error(w, "failed to create string", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "string created", "item": str})
+}
+
+// solarPanelRequest is one panel to register
+type solarPanelRequest struct {
+	Serial       string     `json:"serial"`
+	Position     int        `json:"position"`
+	Manufacturer string     `json:"manufacturer"`
+	ModelNumber  string     `json:"model_number"`
+	RatedPowerW  float64    `json:"rated_power_w"`
+	LayoutX      float64    `json:"layout_x"`
+	LayoutY      float64    `json:"layout_y"`
+	InstalledOn  *time.Time `json:"installed_on"`
+}
+
+// RegisterSolarPanels registers the panels of a string as {"panels": [...]}. Serials are
+// unique within the business; the batch is all-or-nothing. The string's panel count
+// becomes the number of its active panels.
+// POST /api/v1/business/{businessCode}/solar/strings/{stringId}/panels
+func RegisterSolarPanels(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to register panels")
+		return
+	}
+	str, err := loadSolarString(r, "stringId", businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to register panels")
+		return
+	}
+
+	var req struct {
+		Panels []solarPanelRequest `json:"panels"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Panels) == 0 || len(req.Panels) > maxSolarPanelsPerReq {
+		http.Error(w, fmt.Sprintf("panels must contain 1 to %d entries", maxSolarPanelsPerReq), http.StatusBadRequest)
+		return
+	}
+
+	userID := middleware.GetClaims(r).UserID
+	panels := make([]models.SolarPanel, 0, len(req.Panels))
+	serials := make([]string, 0, len(req.Panels))
+	seen := map[string]bool{}
+	for i, p := range req.Panels {
+		serial := strings.TrimSpace(p.Serial)
+		if serial == "" || p.Position < 1 {
+			http.Error(w, fmt.Sprintf("panels[%d]: serial and a position from 1 are required", i), http.StatusUnprocessableEntity)
+			return
+		}
+		if seen[serial] {
+			http.Error(w, fmt.Sprintf("panels[%d]: serial %s is repeated", i, serial), http.StatusUnprocessableEntity)
+			return
+		}
+		seen[serial] = true
+		serials = append(serials, serial)
+		panels = append(panels, models.SolarPanel{
+			BusinessVerticalID: businessID,
+			SiteID:             str.SiteID,
+			StringID:           str.ID,
+			Serial:             serial,
+			Position:           p.Position,
+			Manufacturer:       strings.TrimSpace(p.Manufacturer),
+			ModelNumber:        strings.TrimSpace(p.ModelNumber),
+			RatedPowerW:        p.RatedPowerW,
+			LayoutX:            p.LayoutX,
+			LayoutY:            p.LayoutY,
+			InstalledOn:        p.InstalledOn,
+			Status:             models.SolarEquipmentActive,
+			CreatedBy:          userID,
+		})
+	}
+
+	var existing []string
+	if err := middleware.ScopedDB(r).Model(&models.SolarPanel{}).
+		Where("business_vertical_id = ? AND serial IN ?", businessID, serials).Pluck("serial", &existing).Error; err != nil {
+		http.Error(w, "failed to check serials", http.StatusInternalServerError)
+		return
+	}
+	if len(existing) > 0 {
+		http.Error(w, "panels already registered: "+strings.Join(existing, ", "), http.StatusConflict)
+		return
+	}
+
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(&panels, 100).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.SolarString{}).Where("id = ?", str.ID).
+			UpdateColumn("panel_count", tx.Model(&models.SolarPanel{}).Select("COUNT(*)").
+				Where("string_id = ? AND status = ?", str.ID, models.SolarEquipmentActive)).Error
+	})
+	if err != nil {
+		http.Error(w, "failed to register panels", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "panels registered", "items": panels, "count": len(panels)})
+}
+
+// ListSolarPanels lists the business's panels by string and position. ?site_id=,
+// ?string_id= and ?q= (part of the serial) narrow them.
+// GET /api/v1/business/{businessCode}/solar/panels
+func ListSolarPanels(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load panels")
+		return
+	}
+
+	query := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID)
+	if siteIDs, restricted := middleware.GetSiteScope(r); restricted {
+		query = query.Where("site_id IN ?", siteIDs)
+	}
+	for _, key := range []string{"site_id", "string_id"} {
+		if id, ok := parseUUIDQuery(r, key); ok {
+			query = query.Where(key+" = ?", id)
+		}
+	}
+	if v := strings.TrimSpace(r.URL.Query().Get("q")); v != "" {
+		query = query.Where("serial ILIKE ?", "%"+v+"%")
+	}
+	var items []models.SolarPanel
+	if err := query.Order("string_id, position").Find(&items).Error; err != nil {
+		http.Error(w, "failed to load panels", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// LogSolarStringFault records a fault found on a string, or one of its panels, in an
+// inspection. Without an expected current one is derived from the string's rated current
+// and the irradiance at the time of measurement.
+// POST /api/v1/business/{businessCode}/solar/strings/{stringId}/faults
+func LogSolarStringFault(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to log fault")
+		return
+	}
+	str, err := loadSolarString(r, "stringId", businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to log fault")
+		return
+	}
+
+	var req struct {
+		PanelID          *uuid.UUID `json:"panel_id"`
+		FaultType        string     `json:"fault_type"`
+		Severity         string     `json:"severity"`
+		MeasuredCurrentA *float64   `json:"measured_current_a"`
+		ExpectedCurrentA *float64   `json:"expected_current_a"`
+		IrradianceWM2    *float64   `json:"irradiance_wm2"`
+		Notes            string     `json:"notes"`
+		InspectedAt      *time.Time `json:"inspected_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !models.ValidSolarFaultType(req.FaultType) {
+		http.Error(w, "unknown fault_type", http.StatusBadRequest)
+		return
+	}
+	if req.Severity == "" {
+		req.Severity = models.SolarFaultMedium
+	}
+	if !models.ValidSolarFaultSeverity(req.Severity) {
+		http.Error(w, "severity must be low, medium, high or critical", http.StatusBadRequest)
+		return
+	}
+	for name, v := range map[string]*float64{
+		"measured_current_a": req.MeasuredCurrentA, "expected_current_a": req.ExpectedCurrentA, "irradiance_wm2": req.IrradianceWM2,
+	} {
+		if v != nil && (*v < 0 || *v > 2000) {
+			http.Error(w, name+" is out of range", http.StatusUnprocessableEntity)
+			return
+		}
+	}
+	now := time.Now()
+	inspectedAt := now
+	if req.InspectedAt != nil {
+		if req.InspectedAt.After(now.Add(maxSensorClockSkew)) {
+			http.Error(w, "inspected_at must not be in the future", http.StatusUnprocessableEntity)
+			return
+		}
+		inspectedAt = *req.InspectedAt
+	}
+	if req.PanelID != nil {
+		var count int64
+		middleware.ScopedDB(r).Model(&models.SolarPanel{}).Where("id = ? AND string_id = ?", *req.PanelID, str.ID).Count(&count)
+		if count == 0 {
+			http.Error(w, "panel_id must be a panel of this string", http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	fault := models.SolarStringFault{
+		BusinessVerticalID: businessID,
+		SiteID:             str.SiteID,
+		StringID:           str.ID,
+		PanelID:            req.PanelID,
+		FaultType:          req.FaultType,
+		Severity:           req.Severity,
+		MeasuredCurrentA:   req.MeasuredCurrentA,
+		ExpectedCurrentA:   req.ExpectedCurrentA,
+		IrradianceWM2:      req.IrradianceWM2,
+		Notes:              strings.TrimSpace(req.Notes),
+		InspectedAt:        inspectedAt,
+		ReportedBy:         middleware.GetClaims(r).UserID,
+		Status:             models.SolarFaultOpen,
+	}
+	if fault.ExpectedCurrentA == nil {
+		fault.ExpectedCurrentA = str.ExpectedCurrent(req.IrradianceWM2)
+	}
+	if err := middleware.ScopedDB(r).Create(&fault).Error; err != nil {
+		http.Error(w, "failed to log fault", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"message":             "fault logged",
+		"item":                fault,
+		"performance_percent": fault.PerformancePercent(),
+	})
+}
+
+// ListSolarStringFaults lists the business's string faults, latest inspection first.
+// ?site_id=, ?string_id=, ?status= and ?severity= narrow them.
+// GET /api/v1/business/{businessCode}/solar/faults
+func ListSolarStringFaults(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load faults")
+		return
+	}
+
+	query := middleware.ScopedDB(r).Preload("String.Block").Preload("Panel").Where("business_vertical_id = ?", businessID)
+	if siteIDs, restricted := middleware.GetSiteScope(r); restricted {
+		query = query.Where("site_id IN ?", siteIDs)
+	}
+	for _, key := range []string{"site_id", "string_id"} {
+		if id, ok := parseUUIDQuery(r, key); ok {
+			query = query.Where(key+" = ?", id)
+		}
+	}
+	for _, key := range []string{"status", "severity"} {
+		if v := r.URL.Query().Get(key); v != "" {
+			query = query.Where(key+" = ?", v)
+		}
+	}
+	var items []models.SolarStringFault
+	if err := query.Order("inspected_at DESC").Limit(500).Find(&items).Error; err != nil {
+		http.Error(w, "failed to load faults", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// ResolveSolarStringFault closes an open fault after repair.
+// POST /api/v1/business/{businessCode}/solar/faults/{id}/resolve
+func ResolveSolarStringFault(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to resolve fault")
+		return
+	}
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid fault ID", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		Notes string `json:"notes"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	db := middleware.ScopedDB(r)
+	var fault models.SolarStringFault
+	if err := db.Where("business_vertical_id = ?", businessID).First(&fault, "id = ?", id).Error; err != nil ||
+		!middleware.SiteInScope(r, &fault.SiteID) {
+		http.Error(w, "fault not found", http.StatusNotFound)
+		return
+	}
+	if fault.Status != models.SolarFaultOpen {
+		http.Error(w, "fault is already resolved", http.StatusConflict)
+		return
+	}
+
+	now := time.Now()
+	fault.Status = models.SolarFaultResolved
+	fault.ResolvedAt = &now
+	fault.ResolvedBy = middleware.GetClaims(r).UserID
+	fault.ResolutionNotes = strings.TrimSpace(req.Notes)
+	result := db.Model(&models.SolarStringFault{}).Where("id = ? AND status = ?", fault.ID, models.SolarFaultOpen).
+		Updates(map[string]interface{}{
+			"status": fault.Status, "resolved_at": now, "resolved_by": fault.ResolvedBy, "resolution_notes": fault.ResolutionNotes,
+		})
+	if result.Error != nil {
+		http.Error(w, "failed to resolve fault", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "fault is already resolved", http.StatusConflict)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "fault resolved", "item": fault})
+}
+
+// solarHeatmapCell is one string on the site layout with its inspection health
+type solarHeatmapCell struct {
+	StringID   uuid.UUID `json:"string_id"`
+	Code       string    `json:"code"`
+	BlockID    uuid.UUID `json:"block_id"`
+	BlockCode  string    `json:"block_code"`
+	LayoutX    float64   `json:"layout_x"`
+	LayoutY    float64   `json:"layout_y"`
+	PanelCount int       `json:"panel_count"`
+	models.SolarStringHealth
+}
+
+// GetSolarStringHeatmap returns every active string of a site at its layout position with
+// its open faults and the performance measured at its latest inspection, flagging the
+// underperforming ones. ?threshold= sets the performance percentage below which a string
+// underperforms (90 by default); ?days= how far back inspections count (90 by default),
+// though open faults always do.
+// GET /api/v1/business/{businessCode}/solar/sites/{siteId}/heatmap
+func GetSolarStringHeatmap(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load heatmap")
+		return
+	}
+	site, err := loadSolarSite(r, "siteId", businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load heatmap")
+		return
+	}
+
+	threshold, days := models.DefaultSolarUnderperformanceThreshold, defaultSolarHeatmapDays
+	q := r.URL.Query()
+	if v := q.Get("threshold"); v != "" {
+		if threshold, err = strconv.ParseFloat(v, 64); err != nil || threshold <= 0 || threshold > 100 {
+			http.Error(w, "threshold must be a percentage within 0..100", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("days"); v != "" {
+		if days, err = strconv.Atoi(v); err != nil || days < 1 || days > 730 {
+			http.Error(w, "days must be within 1..730", http.StatusBadRequest)
+			return
+		}
+	}
+
+	db := middleware.ScopedDB(r)
+	var strs []models.SolarString
+	if err := db.Preload("Block").Where("site_id = ? AND status = ?", site.ID, models.SolarEquipmentActive).
+		Order("block_id, code").Find(&strs).Error; err != nil {
+		http.Error(w, "failed to load strings", http.StatusInternalServerError)
+		return
+	}
+	var faults []models.SolarStringFault
+	if err := db.Where("site_id = ? AND (status = ? OR inspected_at >= ?)",
+		site.ID, models.SolarFaultOpen, time.Now().AddDate(0, 0, -days)).Find(&faults).Error; err != nil {
+		http.Error(w, "failed to load faults", http.StatusInternalServerError)
+		return
+	}
+	byString := map[uuid.UUID][]models.SolarStringFault{}
+	for _, f := range faults {
+		byString[f.StringID] = append(byString[f.StringID], f)
+	}
+
+	cells := make([]solarHeatmapCell, 0, len(strs))
+	underperforming := 0
+	for _, s := range strs {
+		cell := solarHeatmapCell{
+			StringID:          s.ID,
+			Code:              s.Code,
+			BlockID:           s.BlockID,
+			LayoutX:           s.LayoutX,
+			LayoutY:           s.LayoutY,
+			PanelCount:        s.PanelCount,
+			SolarStringHealth: models.SolarStringHealthOf(byString[s.ID], threshold),
+		}
+		if s.Block != nil {
+			cell.BlockCode = s.Block.Code
+		}
+		if cell.Underperforming {
+			underperforming++
+		}
+		cells = append(cells, cell)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"site_id":           site.ID,
+		"threshold_percent": threshold,
+		"days":              days,
+		"strings":           cells,
+		"count":             len(cells),
+		"underperforming":   underperforming,
+	})
+}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/solar"
+)
+
+// maxSolarPerformanceDays bounds the period of one performance report
+const maxSolarPerformanceDays = 366
+
+// solarPlantInput is the capacity configuration of a site's plant
+type solarPlantInput struct {
+	DCCapacityKWp      float64                        `json:"dc_capacity_kwp"`
+	ACCapacityKW       float64                        `json:"ac_capacity_kw"`
+	InverterCapacities models.SolarInverterCapacities `json:"inverter_capacities"`
+	Timezone           string                         `json:"timezone"`
+	DaylightStartHour  *int                           `json:"daylight_start_hour"`
+	DaylightEndHour    *int                           `json:"daylight_end_hour"`
+	CommissionedOn     string                         `json:"commissioned_on"` // YYYY-MM-DD
+}
+
+// GetSolarPlants lists the business's configured plants with their capacities.
+// GET /api/v1/business/{businessCode}/solar/plants
+func GetSolarPlants(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load plants")
+		return
+	}
+
+	query := middleware.ScopedDB(r).Preload("Site").Where("business_vertical_id = ?", businessID)
+	if siteIDs, restricted := middleware.GetSiteScope(r); restricted {
+		query = query.Where("site_id IN ?", siteIDs)
+	}
+	var plants []models.SolarPlant
+	if err := query.Order("created_at").Find(&plants).Error; err != nil {
+		http.Error(w, "failed to load plants", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"plants": plants, "count": len(plants)})
+}
+
+// SaveSolarPlant creates or replaces the capacity configuration of the plant at a site.
+// Inverter capacities are keyed by the device IDs of solar inverters at the site.
+// PUT /api/v1/business/{businessCode}/solar/plants/{siteId}
+func SaveSolarPlant(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to save plant")
+		return
+	}
+	siteID, err := uuid.Parse(mux.Vars(r)["siteId"])
+	if err != nil {
+		http.Error(w, "invalid site ID", http.StatusBadRequest)
+		return
+	}
+
+	db := middleware.ScopedDB(r)
+	var site models.Site
+	if err := db.Where("id = ? AND business_vertical_id = ?", siteID, businessID).First(&site).Error; err != nil ||
+		!middleware.SiteInScope(r, &site.ID) {
+		http.Error(w, "site not found", http.StatusNotFound)
+		return
+	}
+
+	var in solarPlantInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var plant models.SolarPlant
+	if err := db.Where("site_id = ?", site.ID).First(&plant).Error; err != nil {
+		plant = models.SolarPlant{BusinessVerticalID: businessID, SiteID: site.ID}
+	}
+	plant.DCCapacityKWp = in.DCCapacityKWp
+	plant.ACCapacityKW = in.ACCapacityKW
+	plant.InverterCapacities = in.InverterCapacities
+	if plant.InverterCapacities == nil {
+		plant.InverterCapacities = models.SolarInverterCapacities{}
+	}
+	plant.Timezone = strings.TrimSpace(in.Timezone)
+	if plant.Timezone == "" {
+		plant.Timezone = models.DefaultSolarTimezone
+	}
+	plant.DaylightStartHour, plant.DaylightEndHour = 6, 18
+	if in.DaylightStartHour != nil {
+		plant.DaylightStartHour = *in.DaylightStartHour
+	}
+	if in.DaylightEndHour != nil {
+		plant.DaylightEndHour = *in.DaylightEndHour
+	}
+	plant.CommissionedOn = nil
+	if in.CommissionedOn != "" {
+		date, err := parseAttendanceDate(in.CommissionedOn)
+		if err != nil {
+			http.Error(w, "commissioned_on: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		plant.CommissionedOn = &date
+	}
+	if err := plant.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if len(plant.InverterCapacities) > 0 {
+		ids := make([]string, 0, len(plant.InverterCapacities))
+		for id := range plant.InverterCapacities {
+			ids = append(ids, id)
+		}
+		var count int64
+		if err := db.Model(&models.SensorDevice{}).
+			Where("id IN ? AND site_id = ? AND device_type = ?", ids, site.ID, models.SensorDeviceSolarInverter).
+			Count(&count).Error; err != nil {
+			http.Error(w, "failed to check inverters", http.StatusInternalServerError)
+			return
+		}
+		if int(count) != len(ids) {
+			http.Error(w, "inverter_capacities must only name solar inverters at this site", http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	if claims := middleware.GetClaims(r); claims != nil {
+		plant.UpdatedBy = claims.UserID
+	}
+	if err := db.Save(&plant).Error; err != nil {
+		http.Error(w, "failed to save plant", http.StatusInternalServerError)
+		return
+	}
+	plant.Site = &site
+
+	writeJSON(w, http.StatusOK, plant)
+}
+
+// GetSolarPerformance returns the performance ratio, capacity utilisation factor,
+// specific yield and downtime of the business's configured plants from ?from= to ?to=
+// (YYYY-MM-DD, inclusive; the last 30 days by default), per day or with
+// ?granularity=month per month, and per plant or with ?by=inverter per inverter.
+// Filters: site_id, device_id. format=csv downloads the rows.
+// GET /api/v1/business/{businessCode}/solar/performance
+func GetSolarPerformance(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to compute performance")
+		return
+	}
+
+	now := time.Now()
+	today := calendarDate(now, attendanceLocation(nil))
+	query := solar.Query{BusinessVerticalID: businessID, From: today.AddDate(0, 0, -29), To: today}
+	q := r.URL.Query()
+	if v := q.Get("from"); v != "" {
+		if query.From, err = parseAttendanceDate(v); err != nil {
+			http.Error(w, "from: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if query.To, err = parseAttendanceDate(v); err != nil {
+			http.Error(w, "to: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if query.To.Before(query.From) {
+		http.Error(w, "to must be on or after from", http.StatusBadRequest)
+		return
+	}
+	if query.To.Sub(query.From) >= maxSolarPerformanceDays*24*time.Hour {
+		http.Error(w, fmt.Sprintf("the period must be at most %d days", maxSolarPerformanceDays), http.StatusBadRequest)
+		return
+	}
+	switch q.Get("granularity") {
+	case "", "day":
+	case "month":
+		query.Monthly = true
+	default:
+		http.Error(w, "granularity must be day or month", http.StatusBadRequest)
+		return
+	}
+	switch q.Get("by") {
+	case "", "site":
+	case "inverter":
+		query.ByInverter = true
+	default:
+		http.Error(w, "by must be site or inverter", http.StatusBadRequest)
+		return
+	}
+
+	if siteIDs, restricted := middleware.GetSiteScope(r); restricted {
+		query.SiteIDs = siteIDs
+	}
+	if siteID, ok := parseUUIDQuery(r, "site_id"); ok {
+		if query.SiteIDs != nil && !middleware.SiteInScope(r, &siteID) {
+			query.SiteIDs = []uuid.UUID{}
+		} else {
+			query.SiteIDs = []uuid.UUID{siteID}
+		}
+	}
+	if deviceID, ok := parseUUIDQuery(r, "device_id"); ok {
+		query.DeviceID = &deviceID
+		query.ByInverter = true
+	}
+
+	rows, err := solar.NewService(middleware.ScopedDB(r)).Performance(query, now)
+	if err != nil {
+		http.Error(w, "failed to compute performance", http.StatusInternalServerError)
+		return
+	}
+
+	if q.Get("format") == "csv" {
+		var buf bytes.Buffer
+		writer := csv.NewWriter(&buf)
+		_ = writer.Write([]string{"Period", "Site", "Inverter", "DC capacity (kWp)", "AC capacity (kW)", "Energy (kWh)",
+			"Irradiation (kWh/m2)", "Specific yield (kWh/kWp)", "CUF %", "PR %", "Downtime (h)", "Availability %"})
+		optional := func(v *float64) string {
+			if v == nil {
+				return ""
+			}
+			return strconv.FormatFloat(*v, 'f', -1, 64)
+		}
+		for _, row := range rows {
+			_ = writer.Write([]string{
+				row.Period, row.SiteName, row.DeviceName,
+				strconv.FormatFloat(row.DCCapacityKWp, 'f', -1, 64), strconv.FormatFloat(row.ACCapacityKW, 'f', -1, 64),
+				strconv.FormatFloat(row.EnergyKWh, 'f', -1, 64), optional(row.IrradiationKWhM2),
+				optional(row.SpecificYield), optional(row.CUFPercent), optional(row.PRPercent),
+				strconv.FormatFloat(row.DowntimeHours, 'f', -1, 64), optional(row.AvailabilityPercent),
+			})
+		}
+		writer.Flush()
+
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="solar-performance-%s-%s.csv"`,
+			query.From.Format("2006-01-02"), query.To.Format("2006-01-02")))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(buf.Bytes())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"from":         query.From.Format("2006-01-02"),
+		"to":           query.To.Format("2006-01-02"),
+		"rows":         rows,
+		"count":        len(rows),
+		"generated_at": now,
+	})
+}
@@ -7,7 +7,6 @@ import (
 
 	"github.com/gorilla/mux"
 	"gorm.io/gorm/clause"
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 )
@@ -24,7 +23,7 @@ func GetAllStockReports(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	service := models.NewReportService(config.DB, models.Stock{})
+	service := models.NewReportService(middleware.ScopedDB(r), models.Stock{})
 	response, err := service.GetReport(params)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -41,7 +40,10 @@ func CreateStockReport(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r)
 	item.YardInchargeName = user.Name
 	item.YardInchargePhone = user.Phone
-	config.DB.Create(&item)
+	if err := middleware.ScopedDB(r).Create(&item).Error; err != nil {
+		http.Error(w, err.Error(), middleware.DataScopeStatus(err))
+		return
+	}
 	json.NewEncoder(w).Encode(item)
 }
 
@@ -49,7 +51,7 @@ func GetStockReport(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id, _ := strconv.Atoi(params["id"])
 	var item models.Stock
-	config.DB.First(&item, id)
+	middleware.ScopedDB(r).First(&item, id)
 	json.NewEncoder(w).Encode(item)
 }
 
@@ -57,16 +59,20 @@ func UpdateStockReport(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id, _ := strconv.Atoi(params["id"])
 	var item models.Stock
-	config.DB.First(&item, id)
+	db := middleware.ScopedDB(r)
+	db.First(&item, id)
 	json.NewDecoder(r.Body).Decode(&item)
-	config.DB.Save(&item)
+	if err := db.Save(&item).Error; err != nil {
+		http.Error(w, err.Error(), middleware.DataScopeStatus(err))
+		return
+	}
 	json.NewEncoder(w).Encode(item)
 }
 
 func DeleteStockReport(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id, _ := strconv.Atoi(params["id"])
-	config.DB.Delete(&models.Stock{}, id)
+	middleware.ScopedDB(r).Delete(&models.Stock{}, id)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -81,13 +87,13 @@ func BatchStocks(w http.ResponseWriter, r *http.Request) {
 		batch[i].YardInchargeName = user.Name
 		batch[i].YardInchargePhone = user.Phone
 	}
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Clauses(clause.OnConflict{
 			Columns:   []clause.Column{{Name: "id"}},
 			DoNothing: true,
 		}).
 		Create(&batch).Error; err != nil {
-		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "db error: "+err.Error(), middleware.DataScopeStatus(err))
 		return
 	}
 
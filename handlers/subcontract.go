@@ -0,0 +1,942 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// subcontractorPortalSQL matches subcontractors a user is a portal user of
+const subcontractorPortalSQL = "EXISTS (SELECT 1 FROM jsonb_array_elements_text(portal_user_ids) u WHERE u = ?)"
+
+// subcontractScope is what subcontracting records a user may view in a business: all of
+// them with subcontract:read, else only their own subcontractors' with
+// subcontract:read_own
+type subcontractScope struct {
+	businessID       uuid.UUID
+	all              bool
+	subcontractorIDs []uuid.UUID
+}
+
+// loadSubcontractScope resolves the business and what of it the user may view
+func loadSubcontractScope(r *http.Request) (*subcontractScope, error) {
+	businessID := middleware.GetCurrentBusinessID(r)
+	if businessID == uuid.Nil {
+		return nil, apiError{status: http.StatusBadRequest, message: "business ID required"}
+	}
+	scope := &subcontractScope{businessID: businessID}
+	if middleware.HasBusinessPermissionInContext(r, "subcontract:read") {
+		scope.all = true
+		return scope, nil
+	}
+	if !middleware.HasBusinessPermissionInContext(r, "subcontract:read_own") {
+		return nil, apiError{status: http.StatusForbidden, message: "insufficient permissions for subcontracting"}
+	}
+	if err := middleware.ScopedDB(r).Model(&models.Subcontractor{}).
+		Where("business_vertical_id = ? AND deleted_at IS NULL", businessID).
+		Where(subcontractorPortalSQL, middleware.GetClaims(r).UserID).
+		Pluck("id", &scope.subcontractorIDs).Error; err != nil {
+		return nil, apiError{status: http.StatusInternalServerError, message: "failed to load subcontractor access"}
+	}
+	return scope, nil
+}
+
+// filter narrows a query on a table with subcontractor_id to what the scope may view
+func (s *subcontractScope) filter(query *gorm.DB, column string) *gorm.DB {
+	query = query.Where("business_vertical_id = ?", s.businessID)
+	if s.all {
+		return query
+	}
+	if len(s.subcontractorIDs) == 0 {
+		return query.Where("1 = 0")
+	}
+	return query.Where(column+" IN ?", s.subcontractorIDs)
+}
+
+// writeSubcontractErr writes an apiError, or a 500 for anything else
+func writeSubcontractErr(w http.ResponseWriter, err error, fallback string) {
+	var ae apiError
+	if errors.As(err, &ae) {
+		http.Error(w, ae.message, ae.status)
+		return
+	}
+	http.Error(w, fallback, http.StatusInternalServerError)
+}
+
+// loadWorkOrder loads the work order in the request within the scope
+func loadWorkOrder(db *gorm.DB, r *http.Request, scope *subcontractScope) (*models.WorkOrder, error) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		return nil, apiError{status: http.StatusBadRequest, message: "invalid work order id"}
+	}
+	var wo models.WorkOrder
+	if err := scope.filter(db, "subcontractor_id").
+		First(&wo, "id = ? AND deleted_at IS NULL", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError{status: http.StatusNotFound, message: "work order not found"}
+		}
+		return nil, err
+	}
+	return &wo, nil
+}
+
+// ==========================
+// Subcontractor handlers
+// ==========================
+
+// subcontractorRequest is the body of subcontractor create and update requests
+type subcontractorRequest struct {
+	Code          string   `json:"code"`
+	Name          string   `json:"name"`
+	GSTIN         string   `json:"gstin"`
+	PAN           string   `json:"pan"`
+	ContactName   string   `json:"contact_name"`
+	Phone         string   `json:"phone"`
+	Email         string   `json:"email"`
+	Address       string   `json:"address"`
+	PortalUserIDs []string `json:"portal_user_ids"`
+	IsActive      *bool    `json:"is_active"`
+}
+
+// ListSubcontractors lists the subcontractors the user may view
+// GET /api/v1/business/{businessCode}/subcontractors
+func ListSubcontractors(w http.ResponseWriter, r *http.Request) {
+	scope, err := loadSubcontractScope(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load subcontractors")
+		return
+	}
+
+	query := scope.filter(middleware.ScopedDB(r), "id").Where("deleted_at IS NULL")
+	if r.URL.Query().Get("active") == "true" {
+		query = query.Where("is_active = ?", true)
+	}
+	var items []models.Subcontractor
+	if err := query.Order("name").Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch subcontractors", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// CreateSubcontractor registers a subcontractor in the business
+// POST /api/v1/business/{businessCode}/subcontractors
+func CreateSubcontractor(w http.ResponseWriter, r *http.Request) {
+	businessID := middleware.GetCurrentBusinessID(r)
+	if businessID == uuid.Nil {
+		http.Error(w, "business ID required", http.StatusBadRequest)
+		return
+	}
+
+	var req subcontractorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Code = strings.TrimSpace(req.Code)
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Code == "" || req.Name == "" {
+		http.Error(w, "code and name are required", http.StatusBadRequest)
+		return
+	}
+
+	var count int64
+	middleware.ScopedDB(r).Model(&models.Subcontractor{}).
+		Where("business_vertical_id = ? AND code = ? AND deleted_at IS NULL", businessID, req.Code).Count(&count)
+	if count > 0 {
+		http.Error(w, "a subcontractor with this code already exists", http.StatusConflict)
+		return
+	}
+
+	item := models.Subcontractor{
+		BusinessVerticalID: businessID,
+		Code:               req.Code,
+		Name:               req.Name,
+		GSTIN:              strings.TrimSpace(req.GSTIN),
+		PAN:                strings.TrimSpace(req.PAN),
+		ContactName:        req.ContactName,
+		Phone:              req.Phone,
+		Email:              req.Email,
+		Address:            req.Address,
+		PortalUserIDs:      models.StringArray(req.PortalUserIDs),
+		IsActive:           req.IsActive == nil || *req.IsActive,
+		CreatedBy:          middleware.GetClaims(r).UserID,
+	}
+	if item.PortalUserIDs == nil {
+		item.PortalUserIDs = models.StringArray{}
+	}
+	if err := middleware.ScopedDB(r).Create(&item).Error; err != nil {
+		http.Error(w, "failed to create subcontractor", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "subcontractor created", "item": item})
+}
+
+// UpdateSubcontractor updates a subcontractor's details and portal users
+// PUT /api/v1/business/{businessCode}/subcontractors/{id}
+func UpdateSubcontractor(w http.ResponseWriter, r *http.Request) {
+	businessID := middleware.GetCurrentBusinessID(r)
+	if businessID == uuid.Nil {
+		http.Error(w, "business ID required", http.StatusBadRequest)
+		return
+	}
+	id, err := parseFinanceUUIDParam(r, "id")
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	var item models.Subcontractor
+	if err := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ? AND deleted_at IS NULL", id, businessID).First(&item).Error; err != nil {
+		http.Error(w, "subcontractor not found", http.StatusNotFound)
+		return
+	}
+
+	var req subcontractorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	updates := map[string]interface{}{"updated_by": middleware.GetClaims(r).UserID}
+	if name := strings.TrimSpace(req.Name); name != "" {
+		updates["name"] = name
+	}
+	for column, value := range map[string]string{
+		"gstin": req.GSTIN, "pan": req.PAN, "contact_name": req.ContactName,
+		"phone": req.Phone, "email": req.Email, "address": req.Address,
+	} {
+		if value != "" {
+			updates[column] = strings.TrimSpace(value)
+		}
+	}
+	if req.PortalUserIDs != nil {
+		updates["portal_user_ids"] = models.StringArray(req.PortalUserIDs)
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+	}
+
+	if err := middleware.ScopedDB(r).Model(&item).Updates(updates).Error; err != nil {
+		http.Error(w, "failed to update subcontractor", http.StatusInternalServerError)
+		return
+	}
+	middleware.ScopedDB(r).First(&item, "id = ?", item.ID)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "subcontractor updated", "item": item})
+}
+
+// ==========================
+// Work order handlers
+// ==========================
+
+// ListWorkOrders lists the work orders the user may view. ?subcontractor_id=,
+// ?project_id= and ?status= narrow them.
+// GET /api/v1/business/{businessCode}/work-orders
+func ListWorkOrders(w http.ResponseWriter, r *http.Request) {
+	scope, err := loadSubcontractScope(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load work orders")
+		return
+	}
+
+	query := scope.filter(middleware.ScopedDB(r).Preload("Subcontractor"), "subcontractor_id").Where("deleted_at IS NULL")
+	q := r.URL.Query()
+	if v := q.Get("subcontractor_id"); v != "" {
+		query = query.Where("subcontractor_id = ?", v)
+	}
+	if v := q.Get("project_id"); v != "" {
+		query = query.Where("project_id = ?", v)
+	}
+	if v := q.Get("status"); v != "" {
+		query = query.Where("status = ?", v)
+	}
+
+	var items []models.WorkOrder
+	if err := query.Order("created_at DESC").Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch work orders", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// CreateWorkOrder drafts a work order to a subcontractor with its lines of work and rates
+// POST /api/v1/business/{businessCode}/work-orders
+func CreateWorkOrder(w http.ResponseWriter, r *http.Request) {
+	businessID := middleware.GetCurrentBusinessID(r)
+	if businessID == uuid.Nil {
+		http.Error(w, "business ID required", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		SubcontractorID        uuid.UUID  `json:"subcontractor_id"`
+		ProjectID              *uuid.UUID `json:"project_id"`
+		OrderNumber            string     `json:"order_number"`
+		Title                  string     `json:"title"`
+		Scope                  string     `json:"scope"`
+		ValidFrom              time.Time  `json:"valid_from"`
+		ValidUntil             time.Time  `json:"valid_until"`
+		RetentionPercent       float64    `json:"retention_percent"`
+		AdvanceAmount          float64    `json:"advance_amount"`
+		AdvanceRecoveryPercent float64    `json:"advance_recovery_percent"`
+		Terms                  string     `json:"terms"`
+		Lines                  []struct {
+			BOQItemID   *uuid.UUID `json:"boq_item_id"`
+			Code        string     `json:"code"`
+			Description string     `json:"description"`
+			UOM         string     `json:"uom"`
+			Quantity    float64    `json:"quantity"`
+			Rate        float64    `json:"rate"`
+		} `json:"lines"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.OrderNumber = strings.TrimSpace(req.OrderNumber)
+	req.Title = strings.TrimSpace(req.Title)
+	switch {
+	case req.SubcontractorID == uuid.Nil || req.OrderNumber == "" || req.Title == "":
+		http.Error(w, "subcontractor_id, order_number and title are required", http.StatusBadRequest)
+		return
+	case req.ValidFrom.IsZero() || req.ValidUntil.IsZero() || req.ValidUntil.Before(req.ValidFrom):
+		http.Error(w, "valid_from and valid_until are required, valid_until not before valid_from", http.StatusBadRequest)
+		return
+	case req.RetentionPercent < 0 || req.RetentionPercent > 100 || req.AdvanceRecoveryPercent < 0 || req.AdvanceRecoveryPercent > 100:
+		http.Error(w, "retention_percent and advance_recovery_percent must be between 0 and 100", http.StatusBadRequest)
+		return
+	case req.AdvanceAmount < 0:
+		http.Error(w, "advance_amount must not be negative", http.StatusBadRequest)
+		return
+	case len(req.Lines) == 0:
+		http.Error(w, "a work order needs at least one line", http.StatusBadRequest)
+		return
+	}
+
+	var subcontractor models.Subcontractor
+	if err := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ? AND deleted_at IS NULL", req.SubcontractorID, businessID).
+		First(&subcontractor).Error; err != nil {
+		http.Error(w, "subcontractor not found", http.StatusBadRequest)
+		return
+	}
+	if !subcontractor.IsActive {
+		http.Error(w, "subcontractor is inactive", http.StatusConflict)
+		return
+	}
+	if req.ProjectID != nil {
+		var count int64
+		middleware.ScopedDB(r).Model(&models.Project{}).Where("id = ? AND deleted_at IS NULL", *req.ProjectID).Count(&count)
+		if count == 0 {
+			http.Error(w, "project not found", http.StatusBadRequest)
+			return
+		}
+	}
+
+	wo := models.WorkOrder{
+		BusinessVerticalID:     businessID,
+		SubcontractorID:        subcontractor.ID,
+		ProjectID:              req.ProjectID,
+		OrderNumber:            req.OrderNumber,
+		Title:                  req.Title,
+		Scope:                  req.Scope,
+		ValidFrom:              req.ValidFrom,
+		ValidUntil:             req.ValidUntil,
+		RetentionPercent:       req.RetentionPercent,
+		AdvanceAmount:          req.AdvanceAmount,
+		AdvanceRecoveryPercent: req.AdvanceRecoveryPercent,
+		Status:                 models.WorkOrderDraft,
+		Terms:                  req.Terms,
+		CreatedBy:              middleware.GetClaims(r).UserID,
+	}
+	codes := map[string]bool{}
+	for _, l := range req.Lines {
+		code := strings.TrimSpace(l.Code)
+		if code == "" || strings.TrimSpace(l.Description) == "" || strings.TrimSpace(l.UOM) == "" || l.Quantity <= 0 || l.Rate < 0 {
+			http.Error(w, "each line needs a code, description, uom, positive quantity and a rate", http.StatusBadRequest)
+			return
+		}
+		if codes[code] {
+			http.Error(w, fmt.Sprintf("duplicate line code %s", code), http.StatusBadRequest)
+			return
+		}
+		codes[code] = true
+		amount := math.Round(l.Quantity*l.Rate*100) / 100
+		wo.Lines = append(wo.Lines, models.WorkOrderLine{
+			BOQItemID:   l.BOQItemID,
+			Code:        code,
+			Description: strings.TrimSpace(l.Description),
+			UOM:         strings.TrimSpace(l.UOM),
+			Quantity:    l.Quantity,
+			Rate:        l.Rate,
+			Amount:      amount,
+		})
+		wo.ContractValue += amount
+	}
+	wo.ContractValue = math.Round(wo.ContractValue*100) / 100
+	if wo.AdvanceAmount > wo.ContractValue {
+		http.Error(w, "advance_amount must not exceed the contract value", http.StatusBadRequest)
+		return
+	}
+
+	var count int64
+	middleware.ScopedDB(r).Model(&models.WorkOrder{}).
+		Where("business_vertical_id = ? AND order_number = ? AND deleted_at IS NULL", businessID, wo.OrderNumber).Count(&count)
+	if count > 0 {
+		http.Error(w, "order_number is already used", http.StatusConflict)
+		return
+	}
+
+	if err := middleware.ScopedDB(r).Create(&wo).Error; err != nil {
+		http.Error(w, "failed to create work order", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "work order created", "item": wo})
+}
+
+// GetWorkOrder returns a work order with its subcontractor and lines
+// GET /api/v1/business/{businessCode}/work-orders/{id}
+func GetWorkOrder(w http.ResponseWriter, r *http.Request) {
+	scope, err := loadSubcontractScope(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load work order")
+		return
+	}
+	wo, err := loadWorkOrder(middleware.ScopedDB(r).Preload("Subcontractor").Preload("Lines", func(db *gorm.DB) *gorm.DB {
+		return db.Order("code")
+	}), r, scope)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load work order")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, wo)
+}
+
+// IssueWorkOrder issues a draft work order to its subcontractor. Whoever drafted it
+// cannot issue it.
+// POST /api/v1/business/{businessCode}/work-orders/{id}/issue
+func IssueWorkOrder(w http.ResponseWriter, r *http.Request) {
+	transitionWorkOrder(w, r, models.WorkOrderDraft, models.WorkOrderIssued)
+}
+
+// CloseWorkOrder closes an issued work order once its certified work is billed
+// POST /api/v1/business/{businessCode}/work-orders/{id}/close
+func CloseWorkOrder(w http.ResponseWriter, r *http.Request) {
+	transitionWorkOrder(w, r, models.WorkOrderIssued, models.WorkOrderClosed)
+}
+
+// CancelWorkOrder cancels a work order still in draft
+// POST /api/v1/business/{businessCode}/work-orders/{id}/cancel
+func CancelWorkOrder(w http.ResponseWriter, r *http.Request) {
+	transitionWorkOrder(w, r, models.WorkOrderDraft, models.WorkOrderCancelled)
+}
+
+func transitionWorkOrder(w http.ResponseWriter, r *http.Request, from, to string) {
+	scope, err := loadSubcontractScope(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load work order")
+		return
+	}
+	wo, err := loadWorkOrder(middleware.ScopedDB(r), r, scope)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load work order")
+		return
+	}
+	if wo.Status != from {
+		http.Error(w, fmt.Sprintf("invalid status transition %s -> %s", wo.Status, to), http.StatusConflict)
+		return
+	}
+
+	userID := middleware.GetClaims(r).UserID
+	updates := map[string]interface{}{"status": to, "updated_by": userID}
+	switch to {
+	case models.WorkOrderIssued:
+		if wo.CreatedBy == userID {
+			http.Error(w, "a work order must be issued by someone other than who drafted it", http.StatusForbidden)
+			return
+		}
+		updates["issued_by"] = userID
+		updates["issued_at"] = time.Now().UTC()
+	case models.WorkOrderClosed:
+		var open int64
+		middleware.ScopedDB(r).Model(&models.WorkOrderCertificate{}).
+			Where("work_order_id = ? AND (status = ? OR (status = ? AND bill_id IS NULL))", wo.ID, models.CertificatePending, models.CertificateCertified).
+			Count(&open)
+		if open > 0 {
+			http.Error(w, "the work order has pending or unbilled certificates", http.StatusConflict)
+			return
+		}
+	}
+
+	result := middleware.ScopedDB(r).Model(&models.WorkOrder{}).Where("id = ? AND status = ?", wo.ID, from).Updates(updates)
+	if result.Error != nil {
+		http.Error(w, "failed to update work order status", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "work order changed status; reload and try again", http.StatusConflict)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "work order " + to, "status": to})
+}
+
+// ==========================
+// Progress certificate handlers
+// ==========================
+
+// ListWorkOrderCertificates lists a work order's progress certificates
+// GET /api/v1/business/{businessCode}/work-orders/{id}/certificates
+func ListWorkOrderCertificates(w http.ResponseWriter, r *http.Request) {
+	scope, err := loadSubcontractScope(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load certificates")
+		return
+	}
+	wo, err := loadWorkOrder(middleware.ScopedDB(r), r, scope)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load work order")
+		return
+	}
+
+	query := middleware.ScopedDB(r).Where("work_order_id = ?", wo.ID)
+	if status := r.URL.Query().Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var items []models.WorkOrderCertificate
+	if err := query.Order("period_end DESC, created_at DESC").Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch certificates", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// CreateWorkOrderCertificate records work done against an issued work order over a
+// period, for certification. The period must fall within the order's validity.
+// POST /api/v1/business/{businessCode}/work-orders/{id}/certificates
+func CreateWorkOrderCertificate(w http.ResponseWriter, r *http.Request) {
+	scope, err := loadSubcontractScope(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to create certificate")
+		return
+	}
+	wo, err := loadWorkOrder(middleware.ScopedDB(r).Preload("Lines"), r, scope)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load work order")
+		return
+	}
+	if wo.Status != models.WorkOrderIssued {
+		http.Error(w, "work can only be certified against an issued work order", http.StatusConflict)
+		return
+	}
+
+	var req struct {
+		CertificateNumber string                  `json:"certificate_number"`
+		PeriodStart       time.Time               `json:"period_start"`
+		PeriodEnd         time.Time               `json:"period_end"`
+		Lines             models.CertificateLines `json:"lines"`
+		Remarks           string                  `json:"remarks"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.CertificateNumber = strings.TrimSpace(req.CertificateNumber)
+	if req.CertificateNumber == "" || req.PeriodStart.IsZero() || req.PeriodEnd.IsZero() || req.PeriodEnd.Before(req.PeriodStart) {
+		http.Error(w, "certificate_number, period_start and period_end are required, period_end not before period_start", http.StatusBadRequest)
+		return
+	}
+	if !wo.ValidOn(req.PeriodStart) || !wo.ValidOn(req.PeriodEnd) {
+		http.Error(w, fmt.Sprintf("the period must fall within the work order's validity, %s to %s",
+			wo.ValidFrom.Format("2006-01-02"), wo.ValidUntil.Format("2006-01-02")), http.StatusBadRequest)
+		return
+	}
+
+	lines, amount, err := models.CertifyLines(wo.Lines, req.Lines)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cert := models.WorkOrderCertificate{
+		WorkOrderID:       wo.ID,
+		CertificateNumber: req.CertificateNumber,
+		PeriodStart:       req.PeriodStart,
+		PeriodEnd:         req.PeriodEnd,
+		Lines:             lines,
+		Amount:            amount,
+		Status:            models.CertificatePending,
+		Remarks:           req.Remarks,
+		PreparedBy:        middleware.GetClaims(r).UserID,
+	}
+	if err := middleware.ScopedDB(r).Create(&cert).Error; err != nil {
+		http.Error(w, "failed to create certificate", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "certificate created", "item": cert})
+}
+
+// CertifyWorkOrderCertificate certifies a pending certificate, adding its quantities to
+// the work order's lines. Whoever prepared it cannot certify it.
+// POST /api/v1/business/{businessCode}/work-orders/{id}/certificates/{certId}/certify
+func CertifyWorkOrderCertificate(w http.ResponseWriter, r *http.Request) {
+	transitionWorkOrderCertificate(w, r, models.CertificateCertified)
+}
+
+// RejectWorkOrderCertificate rejects a pending certificate, with remarks
+// POST /api/v1/business/{businessCode}/work-orders/{id}/certificates/{certId}/reject
+func RejectWorkOrderCertificate(w http.ResponseWriter, r *http.Request) {
+	transitionWorkOrderCertificate(w, r, models.CertificateRejected)
+}
+
+func transitionWorkOrderCertificate(w http.ResponseWriter, r *http.Request, to string) {
+	scope, err := loadSubcontractScope(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load certificate")
+		return
+	}
+	wo, err := loadWorkOrder(middleware.ScopedDB(r), r, scope)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load work order")
+		return
+	}
+	certID, err := parseFinanceUUIDParam(r, "certId")
+	if err != nil {
+		http.Error(w, "invalid certificate id", http.StatusBadRequest)
+		return
+	}
+
+	var req financeActionRequest
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	req.Remarks = strings.TrimSpace(req.Remarks)
+	if to == models.CertificateRejected && req.Remarks == "" {
+		http.Error(w, "remarks are required to reject a certificate", http.StatusBadRequest)
+		return
+	}
+
+	userID := middleware.GetClaims(r).UserID
+	var cert models.WorkOrderCertificate
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&cert, "id = ? AND work_order_id = ?", certID, wo.ID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return apiError{status: http.StatusNotFound, message: "certificate not found"}
+			}
+			return err
+		}
+		if cert.Status != models.CertificatePending {
+			return apiError{status: http.StatusConflict, message: fmt.Sprintf("invalid status transition %s -> %s", cert.Status, to)}
+		}
+
+		updates := map[string]interface{}{"status": to}
+		if req.Remarks != "" {
+			updates["remarks"] = req.Remarks
+		}
+		if to == models.CertificateCertified {
+			if cert.PreparedBy == userID {
+				return apiError{status: http.StatusForbidden, message: "a certificate must be certified by someone other than who prepared it"}
+			}
+			// Check the quantities against the lines as certified now, not as prepared
+			var lines []models.WorkOrderLine
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("work_order_id = ?", wo.ID).Find(&lines).Error; err != nil {
+				return err
+			}
+			if _, _, err := models.CertifyLines(lines, cert.Lines); err != nil {
+				return apiError{status: http.StatusConflict, message: err.Error()}
+			}
+			for _, line := range cert.Lines {
+				if err := tx.Model(&models.WorkOrderLine{}).Where("id = ?", line.WorkOrderLineID).
+					Update("certified_quantity", gorm.Expr("certified_quantity + ?", line.Quantity)).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Model(&models.WorkOrder{}).Where("id = ?", wo.ID).
+				Update("certified_amount", gorm.Expr("certified_amount + ?", cert.Amount)).Error; err != nil {
+				return err
+			}
+			now := time.Now().UTC()
+			updates["certified_by"] = userID
+			updates["certified_at"] = now
+		}
+		return tx.Model(&cert).Updates(updates).Error
+	})
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to update certificate status")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "certificate " + to, "item": cert})
+}
+
+// ==========================
+// Subcontract bill handlers
+// ==========================
+
+// GenerateSubcontractBill bills a work order's certified certificates not billed yet,
+// holding retention and recovering the advance at the order's percentages
+// POST /api/v1/business/{businessCode}/work-orders/{id}/bills
+func GenerateSubcontractBill(w http.ResponseWriter, r *http.Request) {
+	scope, err := loadSubcontractScope(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to generate bill")
+		return
+	}
+	wo, err := loadWorkOrder(middleware.ScopedDB(r), r, scope)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load work order")
+		return
+	}
+
+	var req struct {
+		BillNumber       string      `json:"bill_number"`
+		CertificateIDs   []uuid.UUID `json:"certificate_ids"`
+		DeductionsAmount float64     `json:"deductions_amount"`
+		TaxAmount        float64     `json:"tax_amount"`
+		Remarks          string      `json:"remarks"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.BillNumber = strings.TrimSpace(req.BillNumber)
+	if req.BillNumber == "" {
+		http.Error(w, "bill_number is required", http.StatusBadRequest)
+		return
+	}
+	if req.DeductionsAmount < 0 || req.TaxAmount < 0 {
+		http.Error(w, "deductions_amount and tax_amount must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	var count int64
+	middleware.ScopedDB(r).Model(&models.SubcontractBill{}).
+		Where("business_vertical_id = ? AND bill_number = ?", wo.BusinessVerticalID, req.BillNumber).Count(&count)
+	if count > 0 {
+		http.Error(w, "bill_number is already used", http.StatusConflict)
+		return
+	}
+
+	var bill models.SubcontractBill
+	var certificates []models.WorkOrderCertificate
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		// Lock the order so concurrent bills recover the advance once
+		var locked models.WorkOrder
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, "id = ?", wo.ID).Error; err != nil {
+			return err
+		}
+		if locked.Status != models.WorkOrderIssued {
+			return apiError{status: http.StatusConflict, message: "only an issued work order can be billed"}
+		}
+
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("work_order_id = ? AND status = ? AND bill_id IS NULL", wo.ID, models.CertificateCertified)
+		if len(req.CertificateIDs) > 0 {
+			query = query.Where("id IN ?", req.CertificateIDs)
+		}
+		if err := query.Order("period_end").Find(&certificates).Error; err != nil {
+			return err
+		}
+		if len(certificates) == 0 {
+			return apiError{status: http.StatusConflict, message: "no certified, unbilled certificates to bill"}
+		}
+
+		var gross float64
+		certIDs := make([]uuid.UUID, 0, len(certificates))
+		for _, cert := range certificates {
+			gross += cert.Amount
+			certIDs = append(certIDs, cert.ID)
+		}
+		bill = models.NewSubcontractBill(locked, math.Round(gross*100)/100, req.DeductionsAmount, req.TaxAmount)
+		bill.BillNumber = req.BillNumber
+		bill.Remarks = req.Remarks
+		bill.CreatedBy = middleware.GetClaims(r).UserID
+		if err := tx.Create(&bill).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.WorkOrderCertificate{}).Where("id IN ?", certIDs).Update("bill_id", bill.ID).Error; err != nil {
+			return err
+		}
+		return tx.Model(&locked).Updates(map[string]interface{}{
+			"billed_amount":     gorm.Expr("billed_amount + ?", bill.GrossAmount),
+			"retention_held":    gorm.Expr("retention_held + ?", bill.RetentionAmount),
+			"advance_recovered": gorm.Expr("advance_recovered + ?", bill.AdvanceRecovery),
+		}).Error
+	})
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to generate bill")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "bill generated", "item": bill, "certificates": certificates})
+}
+
+// ListSubcontractBills lists the bills the user may view. ?work_order_id=,
+// ?subcontractor_id= and ?status= narrow them.
+// GET /api/v1/business/{businessCode}/subcontract-bills
+func ListSubcontractBills(w http.ResponseWriter, r *http.Request) {
+	scope, err := loadSubcontractScope(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load bills")
+		return
+	}
+
+	query := scope.filter(middleware.ScopedDB(r), "subcontractor_id")
+	q := r.URL.Query()
+	if v := q.Get("work_order_id"); v != "" {
+		query = query.Where("work_order_id = ?", v)
+	}
+	if v := q.Get("subcontractor_id"); v != "" {
+		query = query.Where("subcontractor_id = ?", v)
+	}
+	if v := q.Get("status"); v != "" {
+		query = query.Where("status = ?", v)
+	}
+	var items []models.SubcontractBill
+	if err := query.Order("created_at DESC").Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch bills", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// loadSubcontractBill loads the bill in the request within the scope
+func loadSubcontractBill(r *http.Request, scope *subcontractScope) (*models.SubcontractBill, error) {
+	id, err := parseFinanceUUIDParam(r, "billId")
+	if err != nil {
+		return nil, apiError{status: http.StatusBadRequest, message: "invalid bill id"}
+	}
+	var bill models.SubcontractBill
+	if err := scope.filter(middleware.ScopedDB(r), "subcontractor_id").First(&bill, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError{status: http.StatusNotFound, message: "bill not found"}
+		}
+		return nil, err
+	}
+	return &bill, nil
+}
+
+// GetSubcontractBill returns a bill with its work order and the certificates it bills
+// GET /api/v1/business/{businessCode}/subcontract-bills/{billId}
+func GetSubcontractBill(w http.ResponseWriter, r *http.Request) {
+	scope, err := loadSubcontractScope(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load bill")
+		return
+	}
+	bill, err := loadSubcontractBill(r, scope)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load bill")
+		return
+	}
+	middleware.ScopedDB(r).Preload("WorkOrder").First(bill, "id = ?", bill.ID)
+
+	var certificates []models.WorkOrderCertificate
+	if err := middleware.ScopedDB(r).Where("bill_id = ?", bill.ID).Order("period_end").Find(&certificates).Error; err != nil {
+		http.Error(w, "failed to load certificates", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"item": bill, "certificates": certificates})
+}
+
+// SubmitSubcontractBill submits a draft or rejected bill for approval
+// POST /api/v1/business/{businessCode}/subcontract-bills/{billId}/submit
+func SubmitSubcontractBill(w http.ResponseWriter, r *http.Request) {
+	transitionSubcontractBill(w, r, "submitted")
+}
+
+// ApproveSubcontractBill approves a submitted bill. Whoever raised it cannot approve it.
+// POST /api/v1/business/{businessCode}/subcontract-bills/{billId}/approve
+func ApproveSubcontractBill(w http.ResponseWriter, r *http.Request) {
+	transitionSubcontractBill(w, r, "approved")
+}
+
+// RejectSubcontractBill sends a submitted bill back, with remarks
+// POST /api/v1/business/{businessCode}/subcontract-bills/{billId}/reject
+func RejectSubcontractBill(w http.ResponseWriter, r *http.Request) {
+	transitionSubcontractBill(w, r, "rejected")
+}
+
+// PaySubcontractBill marks an approved bill paid, with ?payment_reference=
+// POST /api/v1/business/{businessCode}/subcontract-bills/{billId}/pay
+func PaySubcontractBill(w http.ResponseWriter, r *http.Request) {
+	transitionSubcontractBill(w, r, "paid")
+}
+
+func transitionSubcontractBill(w http.ResponseWriter, r *http.Request, next string) {
+	scope, err := loadSubcontractScope(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load bill")
+		return
+	}
+	bill, err := loadSubcontractBill(r, scope)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load bill")
+		return
+	}
+
+	var req financeActionRequest
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	req.Remarks = strings.TrimSpace(req.Remarks)
+
+	if !isValidBillTransition(bill.Status, next) {
+		http.Error(w, fmt.Sprintf("invalid status transition %s -> %s", bill.Status, next), http.StatusConflict)
+		return
+	}
+	userID := middleware.GetClaims(r).UserID
+	if next == "approved" && bill.CreatedBy == userID {
+		http.Error(w, "a bill must be approved by someone other than who raised it", http.StatusForbidden)
+		return
+	}
+	if next == "rejected" && req.Remarks == "" {
+		http.Error(w, "remarks are required to reject a bill", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now().UTC()
+	updates := map[string]interface{}{"status": next, "updated_by": userID}
+	if req.Remarks != "" {
+		updates["remarks"] = req.Remarks
+	}
+	switch next {
+	case "submitted":
+		updates["submitted_by"] = userID
+		updates["submitted_at"] = now
+	case "approved":
+		updates["approved_by"] = userID
+		updates["approved_at"] = now
+	case "paid":
+		updates["payment_reference"] = strings.TrimSpace(r.URL.Query().Get("payment_reference"))
+	}
+
+	result := middleware.ScopedDB(r).Model(&models.SubcontractBill{}).Where("id = ? AND status = ?", bill.ID, bill.Status).Updates(updates)
+	if result.Error != nil {
+		http.Error(w, "failed to update bill status", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "bill changed status; reload and try again", http.StatusConflict)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "bill " + next, "status": next})
+}
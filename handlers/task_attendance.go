@@ -0,0 +1,439 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/utils"
+)
+
+// defaultTaskZoneToleranceMeters is how far outside its zone a task check-in still counts
+// as inside, allowing for GPS drift at the zone's edge
+const defaultTaskZoneToleranceMeters = 50.0
+
+// taskZoneDistanceSQL measures, in meters, from a point to a zone's boundary; 0 inside it
+const taskZoneDistanceSQL = `SELECT ST_Distance(` + zoneBoundarySQL + `::geography, ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography)
+	FROM zones WHERE id = ? AND deleted_at IS NULL`
+
+type taskAttendanceResponse struct {
+	Attendance models.TaskAttendance             `json:"attendance"`
+	Session    models.AttendanceSession          `json:"session"`
+	Validation *utils.AttendanceValidationResult `json:"validation,omitempty"`
+}
+
+type taskAttendanceWorker struct {
+	UserID     uuid.UUID `json:"userId"`
+	UserName   string    `json:"userName,omitempty"`
+	CheckIns   int       `json:"checkIns"`
+	Hours      float64   `json:"hours"`
+	Flagged    int       `json:"flagged"`
+	CheckedIn  bool      `json:"checkedIn"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+}
+
+// loadAttendanceTask loads a task of a project in the current business
+func loadAttendanceTask(businessID uuid.UUID, taskID string) (models.Tasks, error) {
+	var task models.Tasks
+	err := config.DB.Joins("JOIN projects ON projects.id = tasks.project_id").
+		Where("tasks.id = ? AND tasks.deleted_at IS NULL AND projects.business_vertical_id = ?", taskID, businessID).
+		First(&task).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return task, apiError{status: http.StatusNotFound, message: "task not found"}
+	}
+	return task, err
+}
+
+// validateTaskAttendanceRequest checks a check-in or check-out point against the task's
+// zone, or against the task's own location when its zone has no boundary
+func validateTaskAttendanceRequest(task models.Tasks, req attendanceCommandRequest) (*utils.AttendanceValidationResult, time.Time, error) {
+	capturedAt := time.Now().UTC()
+	if req.CapturedAt != nil && !req.CapturedAt.IsZero() {
+		capturedAt = req.CapturedAt.UTC()
+	}
+	if req.Latitude < -90 || req.Latitude > 90 || req.Longitude < -180 || req.Longitude > 180 {
+		return nil, time.Time{}, apiError{status: http.StatusBadRequest, message: "latitude or longitude is out of range"}
+	}
+
+	input := utils.TaskAttendanceInput{
+		AccuracyMeters: req.Accuracy,
+		Integrity: utils.DeviceIntegritySnapshot{
+			IsMockLocation:   req.IsMockLocation,
+			IsGPSEnabled:     req.IsGPSEnabled,
+			ClockSkewSeconds: req.ClockSkewSeconds,
+		},
+	}
+	var distance sql.NullFloat64
+	if task.ZoneID != nil {
+		err := config.DB.Raw(taskZoneDistanceSQL, req.Longitude, req.Latitude, *task.ZoneID).Row().Scan(&distance)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, time.Time{}, err
+		}
+	}
+	if distance.Valid {
+		input.Method = "task_zone"
+		input.DistanceMeters = distance.Float64
+		input.ToleranceMeters = defaultTaskZoneToleranceMeters
+	} else {
+		input.Method = "task_location"
+		input.DistanceMeters = utils.HaversineDistanceMeters(task.Latitude, task.Longitude, req.Latitude, req.Longitude)
+		input.ToleranceMeters = utils.DefaultAttendanceRadiusMeters
+	}
+	if req.Policy != nil {
+		input.Policy = utils.AttendanceValidationPolicy{
+			MaxAccuracyMeters:   req.Policy.MaxAccuracyMeters,
+			MaxClockSkewSeconds: req.Policy.MaxClockSkewSeconds,
+			StrictEnforcement:   req.Policy.StrictEnforcement,
+		}
+		if req.Policy.AllowedRadiusMeters > 0 {
+			input.ToleranceMeters = req.Policy.AllowedRadiusMeters
+		}
+	}
+	return utils.ValidateTaskAttendance(input), capturedAt, nil
+}
+
+func writeTaskAttendanceErr(w http.ResponseWriter, err error) {
+	if ae, ok := err.(apiError); ok {
+		http.Error(w, ae.message, ae.status)
+		return
+	}
+	handleAttendanceError(w, err)
+}
+
+// CheckInTaskAttendance checks the worker in to a task they are actively assigned to, at
+// a point inside the task's zone plus tolerance. The check-in feeds the HR attendance
+// ledger: it joins the worker's active attendance session, or opens one at siteId.
+// POST /api/v1/business/{businessCode}/attendance/tasks/{taskId}/check-in
+func CheckInTaskAttendance(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	businessID, err := getBusinessIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req attendanceCommandRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.DeviceID == "" {
+		http.Error(w, "deviceId is required", http.StatusBadRequest)
+		return
+	}
+
+	task, err := loadAttendanceTask(businessID, mux.Vars(r)["taskId"])
+	if err != nil {
+		writeTaskAttendanceErr(w, err)
+		return
+	}
+	var assignment models.TaskAssignment
+	if err := middleware.ScopedDB(r).Where("task_id = ? AND user_id = ? AND is_active = ?", task.ID, user.ID.String(), true).
+		First(&assignment).Error; err != nil {
+		http.Error(w, "user is not assigned to this task", http.StatusForbidden)
+		return
+	}
+	var checkedIn int64
+	middleware.ScopedDB(r).Model(&models.TaskAttendance{}).
+		Where("user_id = ? AND status = ?", user.ID, models.TaskAttendanceStatusActive).
+		Count(&checkedIn)
+	if checkedIn > 0 {
+		http.Error(w, "user is already checked in to a task", http.StatusConflict)
+		return
+	}
+
+	validation, capturedAt, err := validateTaskAttendanceRequest(task, req)
+	if err != nil {
+		writeTaskAttendanceErr(w, err)
+		return
+	}
+	if validation.ValidationStatus == models.AttendanceValidationRejected {
+		writeValidationRejection(w, validation)
+		return
+	}
+	anomalyFlags, err := utils.SerializeStringArray(validation.AnomalyFlags)
+	if err != nil {
+		http.Error(w, "failed to serialize anomalies", http.StatusInternalServerError)
+		return
+	}
+	metadata, err := marshalMetadata(req.Metadata)
+	if err != nil {
+		http.Error(w, "failed to serialize metadata", http.StatusBadRequest)
+		return
+	}
+
+	attendance := models.TaskAttendance{
+		TaskID:             task.ID,
+		AssignmentID:       assignment.ID,
+		ProjectID:          task.ProjectID,
+		ZoneID:             task.ZoneID,
+		UserID:             user.ID,
+		UserName:           user.Name,
+		BusinessVerticalID: businessID,
+		Status:             models.TaskAttendanceStatusActive,
+		CheckInAt:          capturedAt,
+		CheckInLatitude:    req.Latitude,
+		CheckInLongitude:   req.Longitude,
+		CheckInAccuracy:    req.Accuracy,
+		CheckInDistanceM:   *validation.DistanceFromSiteM,
+		DeviceID:           req.DeviceID,
+		ValidationMethod:   validation.ValidationMethod,
+		ValidationStatus:   validation.ValidationStatus,
+		AnomalyFlags:       anomalyFlags,
+	}
+
+	var event *models.AttendanceEvent
+	session, err := loadActiveSession(user.ID, nil)
+	switch {
+	case err == nil:
+		if session.BusinessVerticalID != businessID {
+			http.Error(w, "active attendance session belongs to another business", http.StatusConflict)
+			return
+		}
+	case err.Error() == "active attendance session not found":
+		if req.SiteID == uuid.Nil {
+			http.Error(w, "siteId is required to open an attendance session", http.StatusBadRequest)
+			return
+		}
+		site, err := loadAccessibleSite(r, user, businessID, req.SiteID)
+		if err != nil {
+			handleAttendanceError(w, err)
+			return
+		}
+		session = models.AttendanceSession{
+			UserID:             user.ID,
+			SiteID:             site.ID,
+			BusinessVerticalID: businessID,
+			Status:             models.AttendanceSessionStatusActive,
+			CheckInAt:          capturedAt,
+			LastSeenAt:         capturedAt,
+			CheckInLatitude:    req.Latitude,
+			CheckInLongitude:   req.Longitude,
+			CheckInAccuracy:    req.Accuracy,
+			LastLatitude:       req.Latitude,
+			LastLongitude:      req.Longitude,
+			LastAccuracy:       req.Accuracy,
+			DeviceID:           req.DeviceID,
+			ValidationMethod:   validation.ValidationMethod,
+			ValidationStatus:   validation.ValidationStatus,
+			ValidationReason:   stringPtr(validation.ValidationReason),
+			AnomalyFlags:       anomalyFlags,
+			Metadata:           metadata,
+		}
+		e := buildAttendanceEvent(uuid.Nil, user.ID, site.ID, businessID, models.AttendanceEventTypeCheckIn, req, capturedAt, validation, anomalyFlags, metadata)
+		event = &e
+		attendance.OpenedSession = true
+	default:
+		http.Error(w, "failed to load attendance session", http.StatusInternalServerError)
+		return
+	}
+
+	if err := middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		if attendance.OpenedSession {
+			if err := tx.Create(&session).Error; err != nil {
+				return err
+			}
+			event.SessionID = session.ID
+			if err := tx.Create(event).Error; err != nil {
+				return err
+			}
+		}
+		attendance.AttendanceSessionID = session.ID
+		return tx.Create(&attendance).Error
+	}); err != nil {
+		http.Error(w, "failed to check in to task", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, taskAttendanceResponse{Attendance: attendance, Session: session, Validation: validation})
+}
+
+// CheckOutTaskAttendance checks the worker out of the task they are checked in to, again
+// at a point inside the task's zone. When the check-in opened the worker's attendance
+// session, the check-out closes it too.
+// POST /api/v1/business/{businessCode}/attendance/tasks/{taskId}/check-out
+func CheckOutTaskAttendance(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	businessID, err := getBusinessIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req attendanceCommandRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.DeviceID == "" {
+		http.Error(w, "deviceId is required", http.StatusBadRequest)
+		return
+	}
+
+	task, err := loadAttendanceTask(businessID, mux.Vars(r)["taskId"])
+	if err != nil {
+		writeTaskAttendanceErr(w, err)
+		return
+	}
+	var attendance models.TaskAttendance
+	if err := middleware.ScopedDB(r).Where("task_id = ? AND user_id = ? AND status = ?", task.ID, user.ID, models.TaskAttendanceStatusActive).
+		First(&attendance).Error; err != nil {
+		http.Error(w, "user is not checked in to this task", http.StatusNotFound)
+		return
+	}
+
+	validation, capturedAt, err := validateTaskAttendanceRequest(task, req)
+	if err != nil {
+		writeTaskAttendanceErr(w, err)
+		return
+	}
+	if validation.ValidationStatus == models.AttendanceValidationRejected {
+		writeValidationRejection(w, validation)
+		return
+	}
+	anomalyFlags, err := utils.SerializeStringArray(validation.AnomalyFlags)
+	if err != nil {
+		http.Error(w, "failed to serialize anomalies", http.StatusInternalServerError)
+		return
+	}
+	metadata, err := marshalMetadata(req.Metadata)
+	if err != nil {
+		http.Error(w, "failed to serialize metadata", http.StatusBadRequest)
+		return
+	}
+
+	attendance.Status = models.TaskAttendanceStatusCompleted
+	attendance.CheckOutAt = &capturedAt
+	attendance.CheckOutLatitude = floatPtr(req.Latitude)
+	attendance.CheckOutLongitude = floatPtr(req.Longitude)
+	attendance.CheckOutAccuracy = floatPtr(req.Accuracy)
+	attendance.CheckOutDistanceM = validation.DistanceFromSiteM
+	if validation.ValidationStatus == models.AttendanceValidationFlagged {
+		attendance.ValidationStatus = validation.ValidationStatus
+	}
+
+	var session models.AttendanceSession
+	if err := middleware.ScopedDB(r).First(&session, "id = ?", attendance.AttendanceSessionID).Error; err != nil {
+		http.Error(w, "failed to load attendance session", http.StatusInternalServerError)
+		return
+	}
+	var event *models.AttendanceEvent
+	if attendance.OpenedSession && session.Status == models.AttendanceSessionStatusActive {
+		e := buildAttendanceEvent(session.ID, user.ID, session.SiteID, businessID, models.AttendanceEventTypeCheckOut, req, capturedAt, validation, anomalyFlags, metadata)
+		event = &e
+		session.Status = models.AttendanceSessionStatusCompleted
+		session.CheckOutAt = &capturedAt
+		session.LastSeenAt = capturedAt
+		session.LastLatitude = req.Latitude
+		session.LastLongitude = req.Longitude
+		session.LastAccuracy = req.Accuracy
+		session.CheckOutLatitude = floatPtr(req.Latitude)
+		session.CheckOutLongitude = floatPtr(req.Longitude)
+		session.CheckOutAccuracy = floatPtr(req.Accuracy)
+		session.ValidationMethod = validation.ValidationMethod
+		session.ValidationStatus = validation.ValidationStatus
+		session.ValidationReason = stringPtr(validation.ValidationReason)
+		session.AnomalyFlags = anomalyFlags
+	}
+
+	if err := middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		if event != nil {
+			if err := tx.Create(event).Error; err != nil {
+				return err
+			}
+			if err := tx.Save(&session).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(&attendance).Error
+	}); err != nil {
+		http.Error(w, "failed to check out of task", http.StatusInternalServerError)
+		return
+	}
+	if event != nil {
+		recordGeofencedAttendanceDay(session)
+	}
+
+	respondJSON(w, http.StatusOK, taskAttendanceResponse{Attendance: attendance, Session: session, Validation: validation})
+}
+
+// GetTaskAttendanceReport lists who checked in to a task and for how long, with hours
+// per worker; workers still checked in are counted up to now. ?from= and ?to= (RFC 3339)
+// narrow it to check-ins in that window.
+// GET /api/v1/business/{businessCode}/attendance/tasks/{taskId}/report
+func GetTaskAttendanceReport(w http.ResponseWriter, r *http.Request) {
+	businessID, err := getBusinessIDFromContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	task, err := loadAttendanceTask(businessID, mux.Vars(r)["taskId"])
+	if err != nil {
+		writeTaskAttendanceErr(w, err)
+		return
+	}
+
+	query := middleware.ScopedDB(r).Where("task_id = ?", task.ID)
+	if from, ok := parseTimeQuery(r, "from"); ok {
+		query = query.Where("check_in_at >= ?", from)
+	}
+	if to, ok := parseTimeQuery(r, "to"); ok {
+		query = query.Where("check_in_at <= ?", to)
+	}
+	var attendances []models.TaskAttendance
+	if err := query.Order("check_in_at ASC").Find(&attendances).Error; err != nil {
+		http.Error(w, "failed to load task attendance", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	byUser := map[uuid.UUID]*taskAttendanceWorker{}
+	var totalHours float64
+	for _, attendance := range attendances {
+		worker := byUser[attendance.UserID]
+		if worker == nil {
+			worker = &taskAttendanceWorker{UserID: attendance.UserID, UserName: attendance.UserName}
+			byUser[attendance.UserID] = worker
+		}
+		hours := attendance.Hours(now)
+		worker.CheckIns++
+		worker.Hours += hours
+		totalHours += hours
+		if attendance.ValidationStatus == models.AttendanceValidationFlagged {
+			worker.Flagged++
+		}
+		if attendance.Status == models.TaskAttendanceStatusActive {
+			worker.CheckedIn = true
+		}
+		lastSeen := attendance.CheckInAt
+		if attendance.CheckOutAt != nil {
+			lastSeen = *attendance.CheckOutAt
+		}
+		if lastSeen.After(worker.LastSeenAt) {
+			worker.LastSeenAt = lastSeen
+		}
+	}
+	workers := make([]taskAttendanceWorker, 0, len(byUser))
+	for _, worker := range byUser {
+		worker.Hours = math.Round(worker.Hours*100) / 100
+		workers = append(workers, *worker)
+	}
+	sort.Slice(workers, func(i, j int) bool { return workers[i].Hours > workers[j].Hours })
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"taskId":     task.ID,
+		"totalHours": math.Round(totalHours*100) / 100,
+		"workers":    workers,
+		"data":       attendances,
+	})
+}
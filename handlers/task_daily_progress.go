@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// dailyProgressRequest is the body of a daily progress entry. Progress is only used for
+// tasks without a planned quantity.
+type dailyProgressRequest struct {
+	ReportDate   string              `json:"report_date"` // YYYY-MM-DD, today by default
+	SiteID       *uuid.UUID          `json:"site_id"`
+	QuantityDone float64             `json:"quantity_done"`
+	Progress     *float64            `json:"progress"`
+	Manpower     models.DPRManpower  `json:"manpower"`
+	Machinery    models.DPRMachinery `json:"machinery"`
+	MaterialCost float64             `json:"material_cost"`
+	Photos       []string            `json:"photos"`
+	Weather      string              `json:"weather"`
+	Remarks      string              `json:"remarks"`
+}
+
+// validate checks the entry reports something and nothing negative
+func (req dailyProgressRequest) validate() error {
+	if req.QuantityDone < 0 || req.MaterialCost < 0 {
+		return errors.New("quantity_done and material_cost must not be negative")
+	}
+	if req.Progress != nil && (*req.Progress < 0 || *req.Progress > 100) {
+		return errors.New("progress must be between 0 and 100")
+	}
+	for _, line := range req.Manpower {
+		if line.Count < 0 || line.DayRate < 0 || line.Hours < 0 {
+			return fmt.Errorf("manpower %q must not have negative count, hours or rate", line.Category)
+		}
+	}
+	for _, line := range req.Machinery {
+		if line.Hours < 0 || line.Hours > 24 || line.HourlyRate < 0 {
+			return fmt.Errorf("machinery %q must run 0 to 24 hours at a non-negative rate", line.Equipment)
+		}
+	}
+	if req.QuantityDone == 0 && req.Progress == nil && len(req.Manpower) == 0 && len(req.Machinery) == 0 && req.MaterialCost == 0 {
+		return errors.New("report a quantity, progress, manpower, machinery or material cost")
+	}
+	return nil
+}
+
+// parseDPRDate parses a YYYY-MM-DD date, or returns today's when raw is empty
+func parseDPRDate(raw string) (time.Time, error) {
+	if raw == "" {
+		now := time.Now().UTC()
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC), nil
+	}
+	return time.Parse("2006-01-02", raw)
+}
+
+// CreateTaskDailyProgress records a day's progress on a task and applies it: the quantity
+// done is added to the task's executed quantity and moves its progress, the manpower,
+// machinery and material costs are added to its actual costs, and a task not yet started
+// is put in progress. The project's progress is rolled up afterwards.
+// POST /api/v1/projects/{id}/tasks/{taskId}/daily-progress
+func (h *ProjectPhase1Handler) CreateTaskDailyProgress(w http.ResponseWriter, r *http.Request) {
+	project, claims, err := h.requireProjectScope(r)
+	if err != nil {
+		h.writeErr(w, err)
+		return
+	}
+	taskID, err := uuid.Parse(mux.Vars(r)["taskId"])
+	if err != nil {
+		http.Error(w, "invalid task id", http.StatusBadRequest)
+		return
+	}
+
+	var req dailyProgressRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reportDate, err := parseDPRDate(req.ReportDate)
+	if err != nil {
+		http.Error(w, "invalid report_date, use YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	if reportDate.After(time.Now().UTC()) {
+		http.Error(w, "report_date cannot be in the future", http.StatusBadRequest)
+		return
+	}
+	if req.SiteID != nil {
+		var count int64
+		if err := middleware.WithDataScope(r, h.db).Model(&models.Site{}).Where("id = ? AND business_vertical_id = ?", *req.SiteID, project.BusinessVerticalID).Count(&count).Error; err != nil || count == 0 {
+			http.Error(w, "site not found in the project's business vertical", http.StatusBadRequest)
+			return
+		}
+	}
+	user := middleware.GetUser(r)
+
+	entry := models.TaskDailyProgress{
+		ProjectID:      project.ID,
+		SiteID:         req.SiteID,
+		ReportDate:     reportDate,
+		QuantityDone:   req.QuantityDone,
+		Manpower:       req.Manpower,
+		Machinery:      req.Machinery,
+		MaterialCost:   req.MaterialCost,
+		Photos:         models.StringArray(req.Photos),
+		Weather:        strings.TrimSpace(req.Weather),
+		Remarks:        req.Remarks,
+		ReportedBy:     claims.UserID,
+		ReportedByName: user.Name,
+	}
+	entry.Tally()
+
+	var task models.Tasks
+	err = middleware.WithDataScope(r, h.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&task, "id = ? AND project_id = ? AND deleted_at IS NULL", taskID, project.ID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return apiError{status: http.StatusNotFound, message: "task not found"}
+			}
+			return err
+		}
+		if task.Status == "completed" || task.Status == "cancelled" {
+			return apiError{status: http.StatusConflict, message: fmt.Sprintf("task is %s", task.Status)}
+		}
+
+		entry.TaskID = task.ID
+		entry.UOM = task.QuantityUOM
+		entry.ProgressBefore = task.Progress
+		entry.ProgressAfter = models.DailyProgressAfter(task, req.QuantityDone, req.Progress)
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+
+		oldProgress := task.Progress
+		task.ExecutedQuantity += entry.QuantityDone
+		task.Progress = entry.ProgressAfter
+		task.LaborCost += entry.LaborCost
+		task.EquipmentCost += entry.EquipmentCost
+		task.MaterialCost += entry.MaterialCost
+		task.TotalCost = task.LaborCost + task.MaterialCost + task.EquipmentCost + task.OtherCost
+		task.UpdatedBy = claims.UserID
+		if task.ActualStartDate == nil {
+			started := reportDate
+			task.ActualStartDate = &started
+		}
+		if task.Status == "pending" || task.Status == "assigned" {
+			task.Status = "in-progress"
+			if err := moveTaskNodes(tx, task, models.NodeStatusInProgress); err != nil {
+				return err
+			}
+		}
+		if err := tx.Save(&task).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.TaskAuditLog{
+			TaskID:          task.ID,
+			Action:          "progress_reported",
+			Field:           "progress",
+			OldValue:        fmt.Sprintf("%.2f", oldProgress),
+			NewValue:        fmt.Sprintf("%.2f", task.Progress),
+			Comment:         fmt.Sprintf("Daily progress for %s", reportDate.Format("2006-01-02")),
+			PerformedBy:     claims.UserID,
+			PerformedByName: user.Name,
+			PerformedAt:     time.Now(),
+		}).Error
+	})
+	if err != nil {
+		var conflict *models.NodeConflictError
+		var transition *models.NodeTransitionError
+		switch {
+		case errors.As(err, &conflict), errors.As(err, &transition):
+			writeTaskNodeErr(w, err)
+		default:
+			if _, ok := err.(apiError); !ok {
+				log.Printf("❌ Failed to record daily progress for task %s: %v", taskID, err)
+			}
+			h.writeErr(w, err)
+		}
+		return
+	}
+
+	rollupProjectProgress(middleware.WithDataScope(r, h.db), project.ID)
+
+	h.writeJSON(w, http.StatusCreated, map[string]interface{}{"daily_progress": entry, "task": task})
+}
+
+// ListTaskDailyProgress lists a task's daily progress entries, latest first, narrowed by
+// ?from= and ?to= (YYYY-MM-DD)
+// GET /api/v1/projects/{id}/tasks/{taskId}/daily-progress
+func (h *ProjectPhase1Handler) ListTaskDailyProgress(w http.ResponseWriter, r *http.Request) {
+	project, _, err := h.requireProjectScope(r)
+	if err != nil {
+		h.writeErr(w, err)
+		return
+	}
+
+	query := middleware.WithDataScope(r, h.db).Where("project_id = ? AND task_id = ? AND deleted_at IS NULL", project.ID, mux.Vars(r)["taskId"])
+	query, err = dprDateRange(r, query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var entries []models.TaskDailyProgress
+	if err := query.Order("report_date DESC, created_at DESC").Find(&entries).Error; err != nil {
+		http.Error(w, "failed to load daily progress", http.StatusInternalServerError)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{"daily_progress": entries, "count": len(entries)})
+}
+
+// GetProjectDPR returns the project's daily progress report for management: per day and
+// site, the entries' manpower, machinery, costs and photos, and the quantity each task
+// did. ?date= gives one day; ?from= and ?to= a range, the last 7 days by default.
+// ?site_id= narrows it to a site.
+// GET /api/v1/projects/{id}/dpr
+func (h *ProjectPhase1Handler) GetProjectDPR(w http.ResponseWriter, r *http.Request) {
+	project, _, err := h.requireProjectScope(r)
+	if err != nil {
+		h.writeErr(w, err)
+		return
+	}
+
+	query := middleware.WithDataScope(r, h.db).Where("project_id = ? AND deleted_at IS NULL", project.ID)
+	q := r.URL.Query()
+	switch {
+	case q.Get("date") != "":
+		date, err := time.Parse("2006-01-02", q.Get("date"))
+		if err != nil {
+			http.Error(w, "invalid date, use YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("report_date = ?", date)
+	case q.Get("from") == "" && q.Get("to") == "":
+		today, _ := parseDPRDate("")
+		query = query.Where("report_date > ?", today.AddDate(0, 0, -7))
+	default:
+		if query, err = dprDateRange(r, query); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if raw := q.Get("site_id"); raw != "" {
+		siteID, err := uuid.Parse(raw)
+		if err != nil {
+			http.Error(w, "invalid site_id", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("site_id = ?", siteID)
+	}
+
+	var entries []models.TaskDailyProgress
+	if err := query.Find(&entries).Error; err != nil {
+		http.Error(w, "failed to load daily progress", http.StatusInternalServerError)
+		return
+	}
+
+	taskIDs := []uuid.UUID{}
+	siteIDs := []uuid.UUID{}
+	for _, entry := range entries {
+		taskIDs = append(taskIDs, entry.TaskID)
+		if entry.SiteID != nil {
+			siteIDs = append(siteIDs, *entry.SiteID)
+		}
+	}
+	tasks := map[uuid.UUID]models.Tasks{}
+	if len(taskIDs) > 0 {
+		var rows []models.Tasks
+		if err := middleware.WithDataScope(r, h.db).Select("id", "code", "title").Where("id IN ?", taskIDs).Find(&rows).Error; err != nil {
+			http.Error(w, "failed to load tasks", http.StatusInternalServerError)
+			return
+		}
+		for _, task := range rows {
+			tasks[task.ID] = task
+		}
+	}
+	siteNames := map[uuid.UUID]string{}
+	if len(siteIDs) > 0 {
+		var sites []models.Site
+		if err := middleware.WithDataScope(r, h.db).Select("id", "name").Where("id IN ?", siteIDs).Find(&sites).Error; err != nil {
+			http.Error(w, "failed to load sites", http.StatusInternalServerError)
+			return
+		}
+		for _, site := range sites {
+			siteNames[site.ID] = site.Name
+		}
+	}
+
+	report := models.NewDPRReport(entries, tasks)
+	for i := range report {
+		if report[i].SiteID != nil {
+			report[i].SiteName = siteNames[*report[i].SiteID]
+		}
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{"project_id": project.ID, "report": report})
+}
+
+// dprDateRange narrows a daily progress query to ?from= and ?to=
+func dprDateRange(r *http.Request, query *gorm.DB) (*gorm.DB, error) {
+	for param, cond := range map[string]string{"from": "report_date >= ?", "to": "report_date <= ?"} {
+		raw := r.URL.Query().Get(param)
+		if raw == "" {
+			continue
+		}
+		date, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s, use YYYY-MM-DD", param)
+		}
+		query = query.Where(cond, date)
+	}
+	return query, nil
+}
@@ -44,8 +44,11 @@ type CreateTaskRequest struct {
 	PlannedStartDate *time.Time             `json:"planned_start_date"`
 	PlannedEndDate   *time.Time             `json:"planned_end_date"`
 	AllocatedBudget  float64                `json:"allocated_budget"`
+	PlannedQuantity  float64                `json:"planned_quantity"`
+	QuantityUOM      string                 `json:"quantity_uom"`
 	Priority         string                 `json:"priority"`
 	WorkflowID       *uuid.UUID             `json:"workflow_id"`
+	CostCenterID     *uuid.UUID             `json:"cost_center_id"`
 	Metadata         map[string]interface{} `json:"metadata"`
 }
 
@@ -56,6 +59,8 @@ type UpdateTaskRequest struct {
 	PlannedStartDate *time.Time `json:"planned_start_date"`
 	PlannedEndDate   *time.Time `json:"planned_end_date"`
 	AllocatedBudget  *float64   `json:"allocated_budget"`
+	PlannedQuantity  *float64   `json:"planned_quantity"`
+	QuantityUOM      *string    `json:"quantity_uom"`
 	Status           *string    `json:"status"`
 	Progress         *float64   `json:"progress"`
 	Priority         *string    `json:"priority"`
@@ -63,6 +68,7 @@ type UpdateTaskRequest struct {
 	MaterialCost     *float64   `json:"material_cost"`
 	EquipmentCost    *float64   `json:"equipment_cost"`
 	OtherCost        *float64   `json:"other_cost"`
+	CostCenterID     *uuid.UUID `json:"cost_center_id"`
 }
 
 // AssignTaskRequest represents the request to assign users to a task
@@ -107,14 +113,18 @@ func (h *TaskHandler) CreateTask(w http.ResponseWriter, r *http.Request) {
 
 	// Validate nodes exist and belong to project
 	var startNode, stopNode models.Node
-	if err := h.db.First(&startNode, "id = ? AND project_id = ?", req.StartNodeID, req.ProjectID).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).First(&startNode, "id = ? AND project_id = ?", req.StartNodeID, req.ProjectID).Error; err != nil {
 		http.Error(w, "Invalid start node", http.StatusBadRequest)
 		return
 	}
-	if err := h.db.First(&stopNode, "id = ? AND project_id = ?", req.StopNodeID, req.ProjectID).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).First(&stopNode, "id = ? AND project_id = ?", req.StopNodeID, req.ProjectID).Error; err != nil {
 		http.Error(w, "Invalid stop node", http.StatusBadRequest)
 		return
 	}
+	if err := checkProjectCostCenter(middleware.WithDataScope(r, h.db), req.ProjectID, req.CostCenterID); err != nil {
+		writeProcurementErr(w, err, "failed to check cost center")
+		return
+	}
 
 	// Get user from context
 	claims := middleware.GetClaims(r)
@@ -200,8 +210,11 @@ func (h *TaskHandler) CreateTask(w http.ResponseWriter, r *http.Request) {
 		PlannedStartDate:       req.PlannedStartDate,
 		PlannedEndDate:         req.PlannedEndDate,
 		AllocatedBudget:        req.AllocatedBudget,
+		PlannedQuantity:        req.PlannedQuantity,
+		QuantityUOM:            strings.TrimSpace(req.QuantityUOM),
 		Priority:               req.Priority,
 		WorkflowID:             req.WorkflowID,
+		CostCenterID:           req.CostCenterID,
 		Status:                 "pending",
 		Progress:               0,
 		Metadata:               json.RawMessage(metadataJSON),
@@ -214,7 +227,7 @@ func (h *TaskHandler) CreateTask(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Start transaction
-	tx := h.db.Begin()
+	tx := middleware.WithDataScope(r, h.db).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -228,8 +241,12 @@ func (h *TaskHandler) CreateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Update node statuses to allocated
-	tx.Model(&models.Node{}).Where("id IN ?", []uuid.UUID{req.StartNodeID, req.StopNodeID}).Update("status", "allocated")
+	// Allocate the nodes to the task
+	if err := moveTaskNodes(tx, task, models.NodeStatusAllocated); err != nil {
+		tx.Rollback()
+		writeTaskNodeErr(w, err)
+		return
+	}
 
 	// Create audit log
 	auditLog := models.TaskAuditLog{
@@ -246,6 +263,8 @@ func (h *TaskHandler) CreateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	rollupProjectProgress(middleware.WithDataScope(r, h.db), task.ProjectID)
+
 	log.Printf("✅ Created task: %s (ID: %s)", task.Title, task.ID)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -273,7 +292,7 @@ func (h *TaskHandler) AssignTask(w http.ResponseWriter, r *http.Request) {
 
 	// Get task
 	var task models.Tasks
-	if err := h.db.First(&task, "id = ?", taskID).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).First(&task, "id = ?", taskID).Error; err != nil {
 		http.Error(w, "Task not found", http.StatusNotFound)
 		return
 	}
@@ -283,7 +302,7 @@ func (h *TaskHandler) AssignTask(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r)
 
 	// Start transaction
-	tx := h.db.Begin()
+	tx := middleware.WithDataScope(r, h.db).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -380,7 +399,7 @@ func (h *TaskHandler) UpdateTaskStatus(w http.ResponseWriter, r *http.Request) {
 
 	// Get task
 	var task models.Tasks
-	if err := h.db.First(&task, "id = ?", taskID).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).First(&task, "id = ?", taskID).Error; err != nil {
 		http.Error(w, "Task not found", http.StatusNotFound)
 		return
 	}
@@ -392,7 +411,7 @@ func (h *TaskHandler) UpdateTaskStatus(w http.ResponseWriter, r *http.Request) {
 	oldStatus := task.Status
 
 	// Start transaction
-	tx := h.db.Begin()
+	tx := middleware.WithDataScope(r, h.db).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -425,10 +444,10 @@ func (h *TaskHandler) UpdateTaskStatus(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update node statuses
-	if req.Status == "in-progress" {
-		tx.Model(&models.Node{}).Where("id IN ?", []uuid.UUID{task.StartNodeID, task.StopNodeID}).Update("status", "in-progress")
-	} else if req.Status == "completed" {
-		tx.Model(&models.Node{}).Where("id IN ?", []uuid.UUID{task.StartNodeID, task.StopNodeID}).Update("status", "completed")
+	if err := moveTaskNodes(tx, task, models.NodeStatusForTask(req.Status)); err != nil {
+		tx.Rollback()
+		writeTaskNodeErr(w, err)
+		return
 	}
 
 	// Create audit log
@@ -450,6 +469,8 @@ func (h *TaskHandler) UpdateTaskStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	rollupProjectProgress(middleware.WithDataScope(r, h.db), task.ProjectID)
+
 	log.Printf("✅ Updated task status: %s -> %s (Task: %s)", oldStatus, req.Status, taskID)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -464,7 +485,7 @@ func (h *TaskHandler) GetTask(w http.ResponseWriter, r *http.Request) {
 	taskID := vars["id"]
 
 	var task models.Tasks
-	if err := h.db.
+	if err := middleware.WithDataScope(r, h.db).
 		Preload("Project").
 		Preload("Zone").
 		Preload("StartNode").
@@ -487,7 +508,7 @@ func (h *TaskHandler) ListTasks(w http.ResponseWriter, r *http.Request) {
 
 	projectID := r.URL.Query().Get("project_id")
 
-	query := h.db.Model(&models.Tasks{})
+	query := middleware.WithDataScope(r, h.db).Model(&models.Tasks{})
 
 	// Apply filters
 	if projectID != "" {
@@ -533,7 +554,7 @@ func (h *TaskHandler) UpdateTask(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var task models.Tasks
-	if err := h.db.First(&task, "id = ?", taskID).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).First(&task, "id = ?", taskID).Error; err != nil {
 		http.Error(w, "Task not found", http.StatusNotFound)
 		return
 	}
@@ -566,6 +587,12 @@ func (h *TaskHandler) UpdateTask(w http.ResponseWriter, r *http.Request) {
 	if req.AllocatedBudget != nil {
 		task.AllocatedBudget = *req.AllocatedBudget
 	}
+	if req.PlannedQuantity != nil {
+		task.PlannedQuantity = *req.PlannedQuantity
+	}
+	if req.QuantityUOM != nil {
+		task.QuantityUOM = strings.TrimSpace(*req.QuantityUOM)
+	}
 	if req.Priority != nil {
 		task.Priority = *req.Priority
 	}
@@ -587,11 +614,18 @@ func (h *TaskHandler) UpdateTask(w http.ResponseWriter, r *http.Request) {
 		task.OtherCost = *req.OtherCost
 	}
 	task.TotalCost = task.LaborCost + task.MaterialCost + task.EquipmentCost + task.OtherCost
+	if req.CostCenterID != nil {
+		if err := checkProjectCostCenter(middleware.WithDataScope(r, h.db), task.ProjectID, req.CostCenterID); err != nil {
+			writeProcurementErr(w, err, "failed to check cost center")
+			return
+		}
+		task.CostCenterID = req.CostCenterID
+	}
 
 	task.UpdatedBy = claims.UserID
 
 	// Start transaction
-	tx := h.db.Begin()
+	tx := middleware.WithDataScope(r, h.db).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -614,6 +648,8 @@ func (h *TaskHandler) UpdateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	rollupProjectProgress(middleware.WithDataScope(r, h.db), task.ProjectID)
+
 	log.Printf("✅ Updated task: %s", taskID)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -628,7 +664,7 @@ func (h *TaskHandler) GetTaskAuditLog(w http.ResponseWriter, r *http.Request) {
 	taskID := vars["id"]
 
 	var auditLogs []models.TaskAuditLog
-	if err := h.db.Where("task_id = ?", taskID).Order("performed_at DESC").Find(&auditLogs).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).Where("task_id = ?", taskID).Order("performed_at DESC").Find(&auditLogs).Error; err != nil {
 		http.Error(w, "Failed to fetch audit logs", http.StatusInternalServerError)
 		return
 	}
@@ -640,55 +676,76 @@ func (h *TaskHandler) GetTaskAuditLog(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// AddTaskComment adds a comment to a task
+// taskCommentRequest is the body of a new or edited task comment
+type taskCommentRequest struct {
+	Comment          string     `json:"comment"`
+	CommentType      string     `json:"comment_type"`
+	ParentID         *uuid.UUID `json:"parent_id"`
+	MentionedUserIDs []string   `json:"mentioned_user_ids"`
+}
+
+// AddTaskComment adds a comment to a task. Users in mentioned_user_ids are notified of
+// the mention; the task's other active assignees are notified of the comment.
 func (h *TaskHandler) AddTaskComment(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	taskID := vars["id"]
 
-	var req struct {
-		Comment     string     `json:"comment"`
-		CommentType string     `json:"comment_type"`
-		ParentID    *uuid.UUID `json:"parent_id"`
-	}
+	var req taskCommentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
-	if req.Comment == "" {
+	if strings.TrimSpace(req.Comment) == "" {
 		http.Error(w, "Comment is required", http.StatusBadRequest)
 		return
 	}
 
 	// Verify task exists
-	var task models.Task
-	if err := h.db.First(&task, "id = ?", taskID).Error; err != nil {
+	var task models.Tasks
+	if err := middleware.WithDataScope(r, h.db).First(&task, "id = ? AND deleted_at IS NULL", taskID).Error; err != nil {
 		http.Error(w, "Task not found", http.StatusNotFound)
 		return
 	}
+	if req.ParentID != nil {
+		var parent models.TaskComment
+		if err := middleware.WithDataScope(r, h.db).First(&parent, "id = ? AND task_id = ? AND deleted_at IS NULL", *req.ParentID, task.ID).Error; err != nil {
+			http.Error(w, "Parent comment not found on this task", http.StatusBadRequest)
+			return
+		}
+	}
 
 	// Get user from context
 	claims := middleware.GetClaims(r)
 	user := middleware.GetUser(r)
 
+	mentions, err := h.taskMentions(claims.UserID, req.MentionedUserIDs)
+	if err != nil {
+		http.Error(w, "Failed to resolve mentions", http.StatusInternalServerError)
+		return
+	}
+
 	comment := models.TaskComment{
-		TaskID:      task.ID,
-		Comment:     req.Comment,
-		CommentType: req.CommentType,
-		AuthorID:    claims.UserID,
-		AuthorName:  user.Name,
-		ParentID:    req.ParentID,
+		TaskID:           task.ID,
+		Comment:          req.Comment,
+		CommentType:      req.CommentType,
+		AuthorID:         claims.UserID,
+		AuthorName:       user.Name,
+		ParentID:         req.ParentID,
+		MentionedUserIDs: models.StringArray(mentions),
 	}
 
 	if comment.CommentType == "" {
 		comment.CommentType = "general"
 	}
 
-	if err := h.db.Create(&comment).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).Create(&comment).Error; err != nil {
 		http.Error(w, "Failed to add comment", http.StatusInternalServerError)
 		return
 	}
 
+	h.notifyTaskComment(task, comment, mentions, user.Name)
+
 	log.Printf("✅ Added comment to task: %s", taskID)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -704,7 +761,7 @@ func (h *TaskHandler) GetTaskComments(w http.ResponseWriter, r *http.Request) {
 	taskID := vars["id"]
 
 	var comments []models.TaskComment
-	if err := h.db.Where("task_id = ?", taskID).Order("created_at DESC").Find(&comments).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).Where("task_id = ? AND deleted_at IS NULL", taskID).Order("created_at DESC").Find(&comments).Error; err != nil {
 		http.Error(w, "Failed to fetch comments", http.StatusInternalServerError)
 		return
 	}
@@ -716,6 +773,203 @@ func (h *TaskHandler) GetTaskComments(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// loadOwnTaskComment loads a comment on the task for its author to change
+func (h *TaskHandler) loadOwnTaskComment(w http.ResponseWriter, r *http.Request) (*models.TaskComment, bool) {
+	vars := mux.Vars(r)
+	var comment models.TaskComment
+	if err := middleware.WithDataScope(r, h.db).First(&comment, "id = ? AND task_id = ? AND deleted_at IS NULL", vars["commentId"], vars["id"]).Error; err != nil {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return nil, false
+	}
+	if comment.AuthorID != middleware.GetClaims(r).UserID {
+		http.Error(w, "Only the author can change a comment", http.StatusForbidden)
+		return nil, false
+	}
+	return &comment, true
+}
+
+// UpdateTaskComment edits a comment. Only its author may edit it; users newly mentioned
+// by the edit are notified.
+func (h *TaskHandler) UpdateTaskComment(w http.ResponseWriter, r *http.Request) {
+	comment, ok := h.loadOwnTaskComment(w, r)
+	if !ok {
+		return
+	}
+
+	var req taskCommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Comment) == "" {
+		http.Error(w, "Comment is required", http.StatusBadRequest)
+		return
+	}
+
+	mentions, err := h.taskMentions(comment.AuthorID, req.MentionedUserIDs)
+	if err != nil {
+		http.Error(w, "Failed to resolve mentions", http.StatusInternalServerError)
+		return
+	}
+	previous := make(map[string]bool, len(comment.MentionedUserIDs))
+	for _, userID := range comment.MentionedUserIDs {
+		previous[userID] = true
+	}
+	var added []string
+	for _, userID := range mentions {
+		if !previous[userID] {
+			added = append(added, userID)
+		}
+	}
+
+	now := time.Now()
+	comment.Comment = req.Comment
+	if req.CommentType != "" {
+		comment.CommentType = req.CommentType
+	}
+	comment.MentionedUserIDs = models.StringArray(mentions)
+	comment.IsEdited = true
+	comment.EditedAt = &now
+	if err := middleware.WithDataScope(r, h.db).Save(comment).Error; err != nil {
+		http.Error(w, "Failed to update comment", http.StatusInternalServerError)
+		return
+	}
+
+	if len(added) > 0 {
+		var task models.Tasks
+		if err := middleware.WithDataScope(r, h.db).First(&task, "id = ?", comment.TaskID).Error; err == nil {
+			notifyTaskUsers(middleware.WithDataScope(r, h.db), task, added, comment.AuthorID, models.NotificationTypeTaskMention, models.NotificationPriorityHigh,
+				"You were mentioned on a task",
+				fmt.Sprintf("%s mentioned you on task %s: %s", comment.AuthorName, task.Title, comment.Comment),
+				models.JSONMap{"comment_id": comment.ID.String()})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Comment updated successfully",
+		"comment": comment,
+	})
+}
+
+// DeleteTaskComment removes a comment. Only its author may remove it; replies stay.
+func (h *TaskHandler) DeleteTaskComment(w http.ResponseWriter, r *http.Request) {
+	comment, ok := h.loadOwnTaskComment(w, r)
+	if !ok {
+		return
+	}
+
+	if err := middleware.WithDataScope(r, h.db).Model(comment).Update("deleted_at", time.Now()).Error; err != nil {
+		http.Error(w, "Failed to delete comment", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// taskMentions keeps the mentioned user IDs that belong to existing users, once each and
+// without the author
+func (h *TaskHandler) taskMentions(authorID string, userIDs []string) ([]string, error) {
+	var ids []uuid.UUID
+	seen := map[uuid.UUID]bool{}
+	for _, raw := range userIDs {
+		id, err := uuid.Parse(strings.TrimSpace(raw))
+		if err != nil || seen[id] || id.String() == authorID {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return []string{}, nil
+	}
+
+	var found []uuid.UUID
+	if err := h.db.Model(&models.User{}).Where("id IN ?", ids).Pluck("id", &found).Error; err != nil {
+		return nil, err
+	}
+	exists := make(map[uuid.UUID]bool, len(found))
+	for _, id := range found {
+		exists[id] = true
+	}
+	mentions := []string{}
+	for _, id := range ids {
+		if exists[id] {
+			mentions = append(mentions, id.String())
+		}
+	}
+	return mentions, nil
+}
+
+// taskAssigneeIDs lists the users actively assigned to a task
+func (h *TaskHandler) taskAssigneeIDs(taskID uuid.UUID) []string {
+	var userIDs []string
+	if err := h.db.Model(&models.TaskAssignment{}).
+		Where("task_id = ? AND is_active = ?", taskID, true).
+		Distinct().Pluck("user_id", &userIDs).Error; err != nil {
+		log.Printf("⚠️  Failed to load assignees of task %s: %v", taskID, err)
+	}
+	return userIDs
+}
+
+// notifyTaskComment tells the mentioned users they were mentioned and the other
+// assignees that the task has a new comment
+func (h *TaskHandler) notifyTaskComment(task models.Tasks, comment models.TaskComment, mentions []string, authorName string) {
+	metadata := models.JSONMap{"comment_id": comment.ID.String()}
+	notifyTaskUsers(h.db, task, mentions, comment.AuthorID, models.NotificationTypeTaskMention, models.NotificationPriorityHigh,
+		"You were mentioned on a task",
+		fmt.Sprintf("%s mentioned you on task %s: %s", authorName, task.Title, comment.Comment),
+		metadata)
+
+	mentioned := make(map[string]bool, len(mentions))
+	for _, userID := range mentions {
+		mentioned[userID] = true
+	}
+	var assignees []string
+	for _, userID := range h.taskAssigneeIDs(task.ID) {
+		if !mentioned[userID] {
+			assignees = append(assignees, userID)
+		}
+	}
+	notifyTaskUsers(h.db, task, assignees, comment.AuthorID, models.NotificationTypeTaskComment, models.NotificationPriorityNormal,
+		"New comment on your task",
+		fmt.Sprintf("%s commented on task %s: %s", authorName, task.Title, comment.Comment),
+		metadata)
+}
+
+// notifyTaskUsers sends each recipient other than the actor an in-app notification
+// about a task
+func notifyTaskUsers(db *gorm.DB, task models.Tasks, recipients []string, actorID string, notificationType models.NotificationType, priority models.NotificationPriority, title, body string, metadata models.JSONMap) {
+	now := time.Now()
+	for _, userID := range recipients {
+		if userID == "" || userID == actorID {
+			continue
+		}
+		recipientMetadata := models.JSONMap{
+			"task_id":    task.ID.String(),
+			"project_id": task.ProjectID.String(),
+		}
+		for k, v := range metadata {
+			recipientMetadata[k] = v
+		}
+		notification := &models.Notification{
+			UserID:    userID,
+			Type:      notificationType,
+			Priority:  priority,
+			Title:     title,
+			Body:      body,
+			ActionURL: fmt.Sprintf("/project-tasks/%s", task.ID),
+			Status:    models.NotificationStatusSent,
+			Channel:   models.NotificationChannelInApp,
+			SentAt:    &now,
+			Metadata:  recipientMetadata,
+		}
+		if err := db.Create(notification).Error; err != nil {
+			log.Printf("⚠️  Failed to notify %s about task %s: %v", userID, task.ID, err)
+		}
+	}
+}
+
 // AddTaskAttachment uploads and links an attachment to a task
 func (h *TaskHandler) AddTaskAttachment(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -728,7 +982,7 @@ func (h *TaskHandler) AddTaskAttachment(w http.ResponseWriter, r *http.Request)
 	}
 
 	var task models.Tasks
-	if err := h.db.First(&task, "id = ?", taskID).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).First(&task, "id = ?", taskID).Error; err != nil {
 		http.Error(w, "Task not found", http.StatusNotFound)
 		return
 	}
@@ -763,11 +1017,16 @@ func (h *TaskHandler) AddTaskAttachment(w http.ResponseWriter, r *http.Request)
 		UploadedByName: user.Name,
 	}
 
-	if err := h.db.Create(&attachment).Error; err != nil {
+	if err := middleware.WithDataScope(r, h.db).Create(&attachment).Error; err != nil {
 		http.Error(w, "Failed to save attachment metadata", http.StatusInternalServerError)
 		return
 	}
 
+	notifyTaskUsers(middleware.WithDataScope(r, h.db), task, h.taskAssigneeIDs(task.ID), claims.UserID, models.NotificationTypeTaskAttachment, models.NotificationPriorityNormal,
+		"New attachment on your task",
+		fmt.Sprintf("%s attached %s to task %s", user.Name, attachment.FileName, task.Title),
+		models.JSONMap{"attachment_id": attachment.ID.String()})
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -788,7 +1047,7 @@ func (h *TaskHandler) GetTaskAttachments(w http.ResponseWriter, r *http.Request)
 	}
 
 	var attachments []models.TaskAttachment
-	if err := h.db.
+	if err := middleware.WithDataScope(r, h.db).
 		Where("task_id = ? AND deleted_at IS NULL", taskID).
 		Order("created_at DESC").
 		Find(&attachments).Error; err != nil {
@@ -803,6 +1062,87 @@ func (h *TaskHandler) GetTaskAttachments(w http.ResponseWriter, r *http.Request)
 	})
 }
 
+// loadTaskAttachment loads an attachment of the task in the request
+func (h *TaskHandler) loadTaskAttachment(w http.ResponseWriter, r *http.Request) (*models.TaskAttachment, bool) {
+	vars := mux.Vars(r)
+	var attachment models.TaskAttachment
+	if err := middleware.WithDataScope(r, h.db).First(&attachment, "id = ? AND task_id = ? AND deleted_at IS NULL", vars["attachmentId"], vars["id"]).Error; err != nil {
+		http.Error(w, "Attachment not found", http.StatusNotFound)
+		return nil, false
+	}
+	return &attachment, true
+}
+
+// GetTaskAttachment retrieves one attachment of a task
+func (h *TaskHandler) GetTaskAttachment(w http.ResponseWriter, r *http.Request) {
+	attachment, ok := h.loadTaskAttachment(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(attachment)
+}
+
+// UpdateTaskAttachment changes an attachment's description or type; the file itself is
+// replaced by uploading a new attachment
+func (h *TaskHandler) UpdateTaskAttachment(w http.ResponseWriter, r *http.Request) {
+	attachment, ok := h.loadTaskAttachment(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Description    *string `json:"description"`
+		AttachmentType *string `json:"attachment_type"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Description != nil {
+		attachment.Description = *req.Description
+	}
+	if req.AttachmentType != nil {
+		switch *req.AttachmentType {
+		case "document", "image", "video", "other":
+			attachment.AttachmentType = *req.AttachmentType
+		default:
+			http.Error(w, "attachment_type must be document, image, video or other", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := middleware.WithDataScope(r, h.db).Model(attachment).Updates(map[string]interface{}{
+		"description":     attachment.Description,
+		"attachment_type": attachment.AttachmentType,
+	}).Error; err != nil {
+		http.Error(w, "Failed to update attachment", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":    "Attachment updated successfully",
+		"attachment": attachment,
+	})
+}
+
+// DeleteTaskAttachment removes an attachment from a task
+func (h *TaskHandler) DeleteTaskAttachment(w http.ResponseWriter, r *http.Request) {
+	attachment, ok := h.loadTaskAttachment(w, r)
+	if !ok {
+		return
+	}
+
+	if err := middleware.WithDataScope(r, h.db).Model(attachment).Update("deleted_at", time.Now()).Error; err != nil {
+		http.Error(w, "Failed to delete attachment", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func inferAttachmentType(mimeType string) string {
 	if strings.HasPrefix(mimeType, "image/") {
 		return "image"
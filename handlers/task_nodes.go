@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"p9e.in/ugcl/models"
+)
+
+// moveTaskNodes moves a task's start and stop nodes to status, locking them first. It
+// fails with a *models.NodeTransitionError when a node's status does not lead there, and,
+// when the task is allocating or working the nodes, with a *models.NodeConflictError when
+// another open task holds one of them.
+func moveTaskNodes(tx *gorm.DB, task models.Tasks, status string) error {
+	if status == "" {
+		return nil
+	}
+	ids := []uuid.UUID{task.StartNodeID}
+	if task.StopNodeID != task.StartNodeID {
+		ids = append(ids, task.StopNodeID)
+	}
+
+	var nodes []models.Node
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", ids).Find(&nodes).Error; err != nil {
+		return err
+	}
+
+	if status == models.NodeStatusAllocated || status == models.NodeStatusInProgress {
+		var holder models.Tasks
+		err := tx.Select("id", "code", "start_node_id", "stop_node_id").
+			Where("(start_node_id IN ? OR stop_node_id IN ?) AND id <> ?", ids, ids, task.ID).
+			Where("deleted_at IS NULL AND status NOT IN ?", []string{"completed", "cancelled"}).
+			First(&holder).Error
+		if err == nil {
+			conflict := &models.NodeConflictError{NodeID: holder.StartNodeID, TaskID: holder.ID, TaskCode: holder.Code}
+			if holder.StartNodeID != task.StartNodeID && holder.StartNodeID != task.StopNodeID {
+				conflict.NodeID = holder.StopNodeID
+			}
+			for _, node := range nodes {
+				if node.ID == conflict.NodeID {
+					conflict.NodeName = node.Name
+				}
+			}
+			return conflict
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+	}
+
+	for _, node := range nodes {
+		if !models.CanTransitionNode(node.Status, status) {
+			return &models.NodeTransitionError{NodeID: node.ID, NodeName: node.Name, From: node.Status, To: status}
+		}
+	}
+	return tx.Model(&models.Node{}).Where("id IN ?", ids).Update("status", status).Error
+}
+
+// writeTaskNodeErr responds to a moveTaskNodes failure, with 409 when a node conflict or
+// transition caused it
+func writeTaskNodeErr(w http.ResponseWriter, err error) {
+	var conflict *models.NodeConflictError
+	var transition *models.NodeTransitionError
+	switch {
+	case errors.As(err, &conflict), errors.As(err, &transition):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Failed to update node status", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// workloadSpanSQL is the days an assignment covers: its own dates, else its task's
+// planned dates, else the task's start and end
+const workloadSpanSQL = `ta.id AS assignment_id, ta.task_id, t.project_id, ta.user_id, ta.user_name,
+	COALESCE(ta.start_date, t.planned_start_date, t.start_date) AS start,
+	COALESCE(ta.end_date, t.planned_end_date, t.end_date) AS "end"`
+
+// GetAssignmentWorkload returns, per user and week, how many active tasks each user is
+// assigned to, the man-days those assignments plan and how many of them overlap, so a
+// coordinator can see who has room before assigning more. The weeks run from ?from= to
+// ?to= (YYYY-MM-DD), by default the four weeks from today; ?user_id= and ?project_id=
+// narrow the assignments. Only projects in the caller's data scope count.
+// GET /api/v1/assignments/workload
+func (h *TaskHandler) GetAssignmentWorkload(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 27)
+	for name, target := range map[string]*time.Time{"from": &from, "to": &to} {
+		if raw := query.Get(name); raw != "" {
+			parsed, err := time.Parse("2006-01-02", raw)
+			if err != nil {
+				http.Error(w, "Invalid "+name+" date, use YYYY-MM-DD", http.StatusBadRequest)
+				return
+			}
+			*target = parsed
+		}
+	}
+	if to.Before(from) {
+		http.Error(w, "to must not be before from", http.StatusBadRequest)
+		return
+	}
+	if to.Sub(from) > 366*24*time.Hour {
+		http.Error(w, "The range may span at most a year", http.StatusBadRequest)
+		return
+	}
+
+	projects := middleware.WithDataScope(r, h.db).Model(&models.Project{}).Where("deleted_at IS NULL")
+	if raw := query.Get("project_id"); raw != "" {
+		projectID, err := uuid.Parse(raw)
+		if err != nil {
+			http.Error(w, "Invalid project_id", http.StatusBadRequest)
+			return
+		}
+		projects = projects.Where("id = ?", projectID)
+	}
+	var projectIDs []uuid.UUID
+	if err := projects.Pluck("id", &projectIDs).Error; err != nil {
+		http.Error(w, "Failed to load projects", http.StatusInternalServerError)
+		return
+	}
+
+	assignments := []models.WorkloadAssignment{}
+	if len(projectIDs) > 0 {
+		q := middleware.WithDataScope(r, h.db).Table("task_assignments ta").
+			Select(workloadSpanSQL).
+			Joins("JOIN tasks t ON t.id = ta.task_id").
+			Where("ta.is_active = ? AND ta.deleted_at IS NULL", true).
+			Where("t.deleted_at IS NULL AND t.status NOT IN ?", []string{"completed", "cancelled"}).
+			Where("t.project_id IN ?", projectIDs).
+			Where("COALESCE(ta.start_date, t.planned_start_date, t.start_date) < ?", to.AddDate(0, 0, 7)).
+			Where("COALESCE(ta.end_date, t.planned_end_date, t.end_date) >= ?", from.AddDate(0, 0, -7))
+		if userID := strings.TrimSpace(query.Get("user_id")); userID != "" {
+			q = q.Where("ta.user_id = ?", userID)
+		}
+		if err := q.Scan(&assignments).Error; err != nil {
+			http.Error(w, "Failed to load assignments", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	workload := models.NewWorkload(assignments, from, to)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"from":     from.Format("2006-01-02"),
+		"to":       to.Format("2006-01-02"),
+		"workload": workload,
+		"count":    len(workload),
+	})
+}
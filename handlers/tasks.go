@@ -7,7 +7,6 @@ import (
 
 	"github.com/gorilla/mux"
 	"gorm.io/gorm/clause"
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 )
@@ -25,7 +24,7 @@ func GetAllTasks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	service := models.NewReportService(config.DB, models.Task{})
+	service := models.NewReportService(middleware.ScopedDB(r), models.Task{})
 	response, err := service.GetReport(params)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -46,7 +45,7 @@ func CreateTask(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r)
 	item.SiteEngineerName = user.Name
 	item.SiteEngineerPhone = user.Phone
-	config.DB.Create(&item)
+	middleware.ScopedDB(r).Create(&item)
 	json.NewEncoder(w).Encode(item)
 }
 
@@ -55,7 +54,7 @@ func GetTask(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id := params["id"]
 	var item models.Task
-	if result := config.DB.First(&item, "id = ?", id); result.Error != nil {
+	if result := middleware.ScopedDB(r).First(&item, "id = ?", id); result.Error != nil {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
@@ -68,7 +67,7 @@ func UpdateTask(w http.ResponseWriter, r *http.Request) {
 	// id := params["id"]
 	id, _ := strconv.Atoi(params["id"])
 	var item models.Task
-	if result := config.DB.First(&item, "id = ?", id); result.Error != nil {
+	if result := middleware.ScopedDB(r).First(&item, "id = ?", id); result.Error != nil {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
@@ -77,7 +76,7 @@ func UpdateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// item.ID, _ = models.ParseUUID(id) // ensure correct id
-	config.DB.Save(&item)
+	middleware.ScopedDB(r).Save(&item)
 	json.NewEncoder(w).Encode(item)
 }
 
@@ -85,7 +84,7 @@ func UpdateTask(w http.ResponseWriter, r *http.Request) {
 func DeleteTask(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id := params["id"]
-	config.DB.Delete(&models.Task{}, "id = ?", id)
+	middleware.ScopedDB(r).Delete(&models.Task{}, "id = ?", id)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -107,7 +106,7 @@ func BatchTasks(w http.ResponseWriter, r *http.Request) {
 	// 		batch[i].WorkAssignedBy = &assigned
 	// 	}
 	// }
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Clauses(clause.OnConflict{
 			Columns:   []clause.Column{{Name: "id"}},
 			DoNothing: true,
@@ -16,7 +16,6 @@ import (
 	"github.com/gorilla/mux"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/datatypes"
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 )
@@ -241,7 +240,7 @@ func ListIntegrations(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var items []models.ThirdPartyIntegration
-	if err := config.DB.Order("created_at DESC").Find(&items).Error; err != nil {
+	if err := middleware.ScopedDB(r).Order("created_at DESC").Find(&items).Error; err != nil {
 		http.Error(w, "failed to list integrations", http.StatusInternalServerError)
 		return
 	}
@@ -273,7 +272,7 @@ func GetIntegration(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var item models.ThirdPartyIntegration
-	if err := config.DB.First(&item, "id = ?", id).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&item, "id = ?", id).Error; err != nil {
 		http.Error(w, "integration not found", http.StatusNotFound)
 		return
 	}
@@ -382,7 +381,7 @@ func CreateIntegration(w http.ResponseWriter, r *http.Request) {
 		CreatedBy:    creatorID,
 	}
 
-	if err := config.DB.Create(&item).Error; err != nil {
+	if err := middleware.ScopedDB(r).Create(&item).Error; err != nil {
 		http.Error(w, "failed to create integration", http.StatusInternalServerError)
 		return
 	}
@@ -407,7 +406,7 @@ func UpdateIntegration(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var item models.ThirdPartyIntegration
-	if err := config.DB.First(&item, "id = ?", id).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&item, "id = ?", id).Error; err != nil {
 		http.Error(w, "integration not found", http.StatusNotFound)
 		return
 	}
@@ -507,7 +506,7 @@ func UpdateIntegration(w http.ResponseWriter, r *http.Request) {
 		item.DataScopes = datatypes.JSONSlice[string](dataScopes)
 	}
 
-	if err := config.DB.Save(&item).Error; err != nil {
+	if err := middleware.ScopedDB(r).Save(&item).Error; err != nil {
 		http.Error(w, "failed to update integration", http.StatusInternalServerError)
 		return
 	}
@@ -530,7 +529,7 @@ func DeleteIntegration(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := config.DB.Delete(&models.ThirdPartyIntegration{}, "id = ?", id).Error; err != nil {
+	if err := middleware.ScopedDB(r).Delete(&models.ThirdPartyIntegration{}, "id = ?", id).Error; err != nil {
 		http.Error(w, "failed to delete integration", http.StatusInternalServerError)
 		return
 	}
@@ -553,7 +552,7 @@ func RegenerateIntegrationKey(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var item models.ThirdPartyIntegration
-	if err := config.DB.First(&item, "id = ?", id).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&item, "id = ?", id).Error; err != nil {
 		http.Error(w, "integration not found", http.StatusNotFound)
 		return
 	}
@@ -569,7 +568,7 @@ func RegenerateIntegrationKey(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := config.DB.Model(&item).Updates(map[string]interface{}{
+	if err := middleware.ScopedDB(r).Model(&item).Updates(map[string]interface{}{
 		"api_key_hash":   keyHash,
 		"api_key_prefix": plainKey[:12],
 	}).Error; err != nil {
@@ -602,7 +601,7 @@ func ProxyIntegrationDropdown(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var item models.ThirdPartyIntegration
-	if err := config.DB.First(&item, "id = ? AND status = ?", id, models.IntegrationStatusActive).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&item, "id = ? AND status = ?", id, models.IntegrationStatusActive).Error; err != nil {
 		http.Error(w, "integration not found or inactive", http.StatusNotFound)
 		return
 	}
@@ -114,6 +114,12 @@ func validateExpectedGCPProject() error {
 	return nil
 }
 
+// uploadObjectName is the bucket key for storedName uploaded under localDir
+func uploadObjectName(localDir, storedName string) string {
+	prefix := strings.TrimPrefix(filepath.ToSlash(localDir), "./")
+	return filepath.ToSlash(filepath.Join(prefix, storedName))
+}
+
 func storeUploadedFile(r *http.Request, fieldName, localDir string) (*storedUpload, error) {
 	if err := r.ParseMultipartForm(50 << 20); err != nil {
 		return nil, fmt.Errorf("bad multipart form: %w", err)
@@ -125,10 +131,35 @@ func storeUploadedFile(r *http.Request, fieldName, localDir string) (*storedUplo
 	}
 	defer file.Close()
 
+	return storeUploadReader(file, header.Filename, header.Header.Get("Content-Type"), localDir)
+}
+
+// storeUploadReader stores the content of file, named originalName, under localDir on
+// local disk or under the same prefix in the upload bucket.
+func storeUploadReader(file io.Reader, originalName, mimeType, localDir string) (*storedUpload, error) {
 	timestamp := time.Now().Format("20060102-150405")
-	ext := filepath.Ext(header.Filename)
+	ext := filepath.Ext(originalName)
 	storedName := fmt.Sprintf("%s-%s%s", timestamp, uuid.New().String()[:8], ext)
-	mimeType := header.Header.Get("Content-Type")
+
+	if useS3Storage() {
+		ctx, cancel := context.WithTimeout(context.Background(), gcsUploadTimeout())
+		defer cancel()
+
+		objectName := uploadObjectName(localDir, storedName)
+		written, err := putS3Object(ctx, objectName, mimeType, file)
+		if err != nil {
+			return nil, err
+		}
+
+		return &storedUpload{
+			OriginalFilename: originalName,
+			Filename:         storedName,
+			URL:              fmt.Sprintf("s3://%s/%s", getS3BucketName(), objectName),
+			Path:             objectName,
+			Size:             written,
+			MimeType:         mimeType,
+		}, nil
+	}
 
 	if useGCSStorage() {
 		if err := validateExpectedGCPProject(); err != nil {
@@ -145,8 +176,7 @@ func storeUploadedFile(r *http.Request, fieldName, localDir string) (*storedUplo
 
 		uploadBucket := getUploadBucketName()
 
-		prefix := strings.TrimPrefix(filepath.ToSlash(localDir), "./")
-		objectName := filepath.ToSlash(filepath.Join(prefix, storedName))
+		objectName := uploadObjectName(localDir, storedName)
 
 		writer := client.Bucket(uploadBucket).Object(objectName).NewWriter(ctx)
 		writer.ContentType = mimeType
@@ -160,7 +190,7 @@ func storeUploadedFile(r *http.Request, fieldName, localDir string) (*storedUplo
 		}
 
 		return &storedUpload{
-			OriginalFilename: header.Filename,
+			OriginalFilename: originalName,
 			Filename:         storedName,
 			URL:              fmt.Sprintf("https://storage.googleapis.com/%s/%s", uploadBucket, objectName),
 			Path:             objectName,
@@ -188,7 +218,7 @@ func storeUploadedFile(r *http.Request, fieldName, localDir string) (*storedUplo
 	publicPath := "/" + strings.TrimPrefix(filepath.ToSlash(fullPath), "./")
 
 	return &storedUpload{
-		OriginalFilename: header.Filename,
+		OriginalFilename: originalName,
 		Filename:         storedName,
 		URL:              publicPath,
 		Path:             fullPath,
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3-compatible storage (AWS S3 or MinIO) is used for uploads when S3_BUCKET is set:
+//
+//	S3_ENDPOINT           host[:port] of the API, default s3.amazonaws.com
+//	S3_PUBLIC_ENDPOINT    host[:port] clients reach, when it differs (e.g. MinIO behind a proxy)
+//	S3_REGION             bucket region
+//	S3_ACCESS_KEY_ID      with S3_SECRET_ACCESS_KEY; the AWS environment or instance role otherwise
+//	S3_USE_SSL            "false" for plain HTTP
+//	S3_URL_EXPIRY         lifetime of presigned URLs, default 15m
+var (
+	s3ClientOnce sync.Once
+	sharedS3     *minio.Client
+	sharedS3Sign *minio.Client
+	sharedS3Err  error
+)
+
+func useS3Storage() bool {
+	return getS3BucketName() != ""
+}
+
+func getS3BucketName() string {
+	return strings.TrimSpace(os.Getenv("S3_BUCKET"))
+}
+
+// s3URLExpiry is how long presigned upload and download URLs stay valid
+func s3URLExpiry() time.Duration {
+	if s := strings.TrimSpace(os.Getenv("S3_URL_EXPIRY")); s != "" {
+		if d, err := time.ParseDuration(s); err == nil && d > 0 && d <= 7*24*time.Hour {
+			return d
+		}
+		log.Printf("[S3] invalid S3_URL_EXPIRY %q, using default 15m", s)
+	}
+	return 15 * time.Minute
+}
+
+// getSharedS3Clients returns the client for API calls and the one whose host is baked
+// into presigned URLs, creating them on the first call.
+func getSharedS3Clients() (*minio.Client, *minio.Client, error) {
+	s3ClientOnce.Do(func() {
+		endpoint := strings.TrimSpace(os.Getenv("S3_ENDPOINT"))
+		if endpoint == "" {
+			endpoint = "s3.amazonaws.com"
+		}
+		region := strings.TrimSpace(os.Getenv("S3_REGION"))
+		secure := !strings.EqualFold(strings.TrimSpace(os.Getenv("S3_USE_SSL")), "false")
+
+		creds := credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.IAM{},
+		})
+		if key := strings.TrimSpace(os.Getenv("S3_ACCESS_KEY_ID")); key != "" {
+			creds = credentials.NewStaticV4(key, os.Getenv("S3_SECRET_ACCESS_KEY"), "")
+		}
+
+		sharedS3, sharedS3Err = minio.New(endpoint, &minio.Options{Creds: creds, Secure: secure, Region: region})
+		if sharedS3Err != nil {
+			log.Printf("[S3] failed to create shared client: %v", sharedS3Err)
+			s3ClientOnce = sync.Once{}
+			return
+		}
+		sharedS3Sign = sharedS3
+
+		// Presigning is offline, but the signature covers the host the client will call
+		if public := strings.TrimSpace(os.Getenv("S3_PUBLIC_ENDPOINT")); public != "" && public != endpoint {
+			sharedS3Sign, sharedS3Err = minio.New(public, &minio.Options{Creds: creds, Secure: secure, Region: region})
+			if sharedS3Err != nil {
+				log.Printf("[S3] failed to create presigning client: %v", sharedS3Err)
+				s3ClientOnce = sync.Once{}
+			}
+		}
+	})
+	return sharedS3, sharedS3Sign, sharedS3Err
+}
+
+// putS3Object uploads file to objectName in the S3 bucket and returns its size
+func putS3Object(ctx context.Context, objectName, mimeType string, file io.Reader) (int64, error) {
+	client, _, err := getSharedS3Clients()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get S3 client: %w", err)
+	}
+	info, err := client.PutObject(ctx, getS3BucketName(), objectName, file, -1, minio.PutObjectOptions{ContentType: mimeType})
+	if err != nil {
+		return 0, fmt.Errorf("failed to upload to S3: %w", err)
+	}
+	return info.Size, nil
+}
+
+// openS3Object opens objectName in the S3 bucket, returning errStoredFileNotFound when
+// there is no such object
+func openS3Object(ctx context.Context, objectName string) (io.ReadCloser, int64, error) {
+	client, _, err := getSharedS3Clients()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get S3 client: %w", err)
+	}
+	object, err := client.GetObject(ctx, getS3BucketName(), objectName, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open stored object: %w", err)
+	}
+	info, err := object.Stat()
+	if err != nil {
+		object.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, 0, errStoredFileNotFound
+		}
+		return nil, 0, fmt.Errorf("failed to open stored object: %w", err)
+	}
+	return object, info.Size, nil
+}
+
+// statS3Object returns the size and content type of objectName, or errStoredFileNotFound
+func statS3Object(ctx context.Context, objectName string) (int64, string, error) {
+	client, _, err := getSharedS3Clients()
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to get S3 client: %w", err)
+	}
+	info, err := client.StatObject(ctx, getS3BucketName(), objectName, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return 0, "", errStoredFileNotFound
+		}
+		return 0, "", err
+	}
+	return info.Size, info.ContentType, nil
+}
+
+// removeS3Object deletes objectName from the S3 bucket
+func removeS3Object(ctx context.Context, objectName string) error {
+	client, _, err := getSharedS3Clients()
+	if err != nil {
+		return fmt.Errorf("failed to get S3 client: %w", err)
+	}
+	return client.RemoveObject(ctx, getS3BucketName(), objectName, minio.RemoveObjectOptions{})
+}
+
+// presignS3Put returns a URL the client can PUT objectName's content to until it expires
+func presignS3Put(ctx context.Context, objectName string, expiry time.Duration) (*url.URL, error) {
+	_, signer, err := getSharedS3Clients()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get S3 client: %w", err)
+	}
+	return signer.PresignedPutObject(ctx, getS3BucketName(), objectName, expiry)
+}
+
+// presignS3Get returns a URL that downloads objectName as fileName until it expires
+func presignS3Get(ctx context.Context, objectName, fileName, fileType string, expiry time.Duration) (*url.URL, error) {
+	_, signer, err := getSharedS3Clients()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get S3 client: %w", err)
+	}
+	params := url.Values{}
+	if fileName != "" {
+		params.Set("response-content-disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	}
+	if fileType != "" {
+		params.Set("response-content-type", fileType)
+	}
+	return signer.PresignedGetObject(ctx, getS3BucketName(), objectName, expiry, params)
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/metering"
+)
+
+// UsageBillingHandler exposes per-vertical usage metering and chargeback reports.
+type UsageBillingHandler struct {
+	db *gorm.DB
+}
+
+func NewUsageBillingHandler() *UsageBillingHandler {
+	return &UsageBillingHandler{db: config.DB}
+}
+
+// GetUsageAggregates returns raw monthly aggregates, optionally filtered by period range and vertical.
+func (h *UsageBillingHandler) GetUsageAggregates(w http.ResponseWriter, r *http.Request) {
+	query := middleware.WithDataScope(r, h.db).Model(&models.UsageAggregate{}).
+		Scopes(middleware.TenantVerticalScope(r, "business_vertical_id")).
+		Order("period DESC, business_vertical_id, metric")
+
+	if from := strings.TrimSpace(r.URL.Query().Get("from")); from != "" {
+		if _, _, err := metering.ParsePeriod(from); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query = query.Where("period >= ?", from)
+	}
+	if to := strings.TrimSpace(r.URL.Query().Get("to")); to != "" {
+		if _, _, err := metering.ParsePeriod(to); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query = query.Where("period <= ?", to)
+	}
+	if raw := strings.TrimSpace(r.URL.Query().Get("business_vertical_id")); raw != "" {
+		verticalID, err := uuid.Parse(raw)
+		if err != nil {
+			http.Error(w, "invalid business_vertical_id", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("business_vertical_id = ?", verticalID)
+	}
+	if metric := strings.TrimSpace(r.URL.Query().Get("metric")); metric != "" {
+		query = query.Where("metric = ?", metric)
+	}
+
+	var aggregates []models.UsageAggregate
+	if err := query.Find(&aggregates).Error; err != nil {
+		http.Error(w, "failed to load usage aggregates", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"aggregates": aggregates, "count": len(aggregates)})
+}
+
+// RefreshUsageAggregates flushes buffered counters and recomputes derived metrics for a period.
+func (h *UsageBillingHandler) RefreshUsageAggregates(w http.ResponseWriter, r *http.Request) {
+	period := billingPeriodFromRequest(r)
+	if _, _, err := metering.ParsePeriod(period); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if meter := metering.Default(); meter != nil {
+		if err := meter.Flush(); err != nil {
+			http.Error(w, "failed to flush usage counters", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := metering.NewAggregator(middleware.WithDataScope(r, h.db)).AggregatePeriod(period); err != nil {
+		http.Error(w, "failed to aggregate usage", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"period": period, "message": "usage aggregates refreshed"})
+}
+
+// GetBillingReport returns the priced chargeback report for a period.
+// Pass format=csv to download the report as CSV.
+func (h *UsageBillingHandler) GetBillingReport(w http.ResponseWriter, r *http.Request) {
+	period := billingPeriodFromRequest(r)
+	if _, _, err := metering.ParsePeriod(period); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var verticalID *uuid.UUID
+	if raw := strings.TrimSpace(r.URL.Query().Get("business_vertical_id")); raw != "" {
+		parsed, err := uuid.Parse(raw)
+		if err != nil {
+			http.Error(w, "invalid business_vertical_id", http.StatusBadRequest)
+			return
+		}
+		verticalID = &parsed
+	}
+
+	report, err := metering.NewAggregator(middleware.WithDataScope(r, h.db)).BuildBillingReport(period, verticalID, middleware.GetCurrentCompanyID(r), metering.RatesFromEnv())
+	if err != nil {
+		http.Error(w, "failed to build billing report", http.StatusInternalServerError)
+		return
+	}
+
+	if strings.EqualFold(r.URL.Query().Get("format"), "csv") {
+		h.writeBillingCSV(w, report)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+func (h *UsageBillingHandler) writeBillingCSV(w http.ResponseWriter, report *metering.BillingReport) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write([]string{"period", "business_vertical_code", "business_vertical_name", "metric", "quantity", "billable_quantity", "billable_unit", "unit_rate", "amount"})
+
+	formatFloat := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	for _, vertical := range report.Verticals {
+		for _, line := range vertical.Lines {
+			writer.Write([]string{
+				report.Period,
+				line.BusinessVerticalCode,
+				line.BusinessVerticalName,
+				string(line.Metric),
+				formatFloat(line.Quantity),
+				formatFloat(line.BillableQuantity),
+				line.BillableUnit,
+				formatFloat(line.UnitRate),
+				formatFloat(line.Amount),
+			})
+		}
+		writer.Write([]string{report.Period, vertical.BusinessVerticalCode, vertical.BusinessVerticalName, "total", "", "", "", "", formatFloat(vertical.Total)})
+	}
+	writer.Write([]string{report.Period, "", "", "grand_total", "", "", "", "", formatFloat(report.GrandTotal)})
+	writer.Flush()
+
+	filename := fmt.Sprintf("usage_billing_%s.csv", report.Period)
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// billingPeriodFromRequest reads ?period=YYYY-MM, defaulting to the current month.
+func billingPeriodFromRequest(r *http.Request) string {
+	if period := strings.TrimSpace(r.URL.Query().Get("period")); period != "" {
+		return period
+	}
+	return models.UsagePeriod(time.Now())
+}
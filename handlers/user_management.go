@@ -3,11 +3,11 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"golang.org/x/crypto/bcrypt"
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 )
@@ -19,6 +19,8 @@ type adminUserBusinessRoleOut struct {
 	BusinessVerticalID uuid.UUID   `json:"business_vertical_id"`
 	BusinessVertical   string      `json:"business_vertical_name"`
 	AssignedAt         interface{} `json:"assigned_at,omitempty"`
+	ValidFrom          *time.Time  `json:"valid_from,omitempty"`
+	ValidUntil         *time.Time  `json:"valid_until,omitempty"`
 }
 
 type adminUserOut struct {
@@ -28,6 +30,8 @@ type adminUserOut struct {
 	Phone                string                     `json:"phone"`
 	RoleID               *uuid.UUID                 `json:"role_id,omitempty"`
 	GlobalRole           string                     `json:"global_role,omitempty"`
+	RoleValidFrom        *time.Time                 `json:"role_valid_from,omitempty"`
+	RoleValidUntil       *time.Time                 `json:"role_valid_until,omitempty"`
 	BusinessVerticalID   *uuid.UUID                 `json:"business_vertical_id,omitempty"`
 	BusinessVerticalName string                     `json:"business_vertical_name,omitempty"`
 	IsActive             bool                       `json:"is_active"`
@@ -60,6 +64,8 @@ func buildAdminUserResponse(user models.User) adminUserOut {
 			BusinessVerticalID: assignment.BusinessRole.BusinessVerticalID,
 			BusinessVertical:   assignment.BusinessRole.BusinessVertical.Name,
 			AssignedAt:         assignment.AssignedAt,
+			ValidFrom:          assignment.ValidFrom,
+			ValidUntil:         assignment.ValidUntil,
 		})
 	}
 
@@ -70,6 +76,8 @@ func buildAdminUserResponse(user models.User) adminUserOut {
 		Phone:                user.Phone,
 		RoleID:               user.RoleID,
 		GlobalRole:           globalRoleName,
+		RoleValidFrom:        user.RoleValidFrom,
+		RoleValidUntil:       user.RoleValidUntil,
 		BusinessVerticalID:   user.BusinessVerticalID,
 		BusinessVerticalName: businessVerticalName,
 		IsActive:             user.IsActive,
@@ -80,13 +88,15 @@ func buildAdminUserResponse(user models.User) adminUserOut {
 }
 
 type updateUserReq struct {
-	Name               string  `json:"name"`
-	Email              string  `json:"email"`
-	Phone              string  `json:"phone"`
-	Role               string  `json:"role"`
-	RoleID             *string `json:"role_id"`
-	BusinessVerticalID *string `json:"business_vertical_id"`
-	IsActive           *bool   `json:"is_active"`
+	Name               string     `json:"name"`
+	Email              string     `json:"email"`
+	Phone              string     `json:"phone"`
+	Role               string     `json:"role"`
+	RoleID             *string    `json:"role_id"`
+	RoleValidFrom      *time.Time `json:"role_valid_from"`  // only applied together with role_id
+	RoleValidUntil     *time.Time `json:"role_valid_until"` // only applied together with role_id
+	BusinessVerticalID *string    `json:"business_vertical_id"`
+	IsActive           *bool      `json:"is_active"`
 }
 
 // UpdateUser allows admins to update user information
@@ -109,7 +119,7 @@ func UpdateUser(w http.ResponseWriter, r *http.Request) {
 
 	// Get existing user
 	var user models.User
-	if err := config.DB.First(&user, "id = ?", id).Error; err != nil {
+	if err := middleware.ScopedDB(r).Scopes(middleware.TenantScope(r)).First(&user, "id = ?", id).Error; err != nil {
 		http.Error(w, "user not found", http.StatusNotFound)
 		return
 	}
@@ -136,10 +146,15 @@ func UpdateUser(w http.ResponseWriter, r *http.Request) {
 		updateMap["phone"] = req.Phone
 	}
 
-	// Update global role if provided
+	// Update global role if provided; the validity window is replaced along with it
 	if req.RoleID != nil {
+		updateMap["role_valid_from"] = req.RoleValidFrom
+		updateMap["role_valid_until"] = req.RoleValidUntil
+		updateMap["role_assigned_by"] = nil
 		if *req.RoleID == "" {
 			updateMap["role_id"] = nil
+			updateMap["role_valid_from"] = nil
+			updateMap["role_valid_until"] = nil
 		} else {
 			roleID, err := uuid.Parse(*req.RoleID)
 			if err != nil {
@@ -148,11 +163,21 @@ func UpdateUser(w http.ResponseWriter, r *http.Request) {
 			}
 
 			var role models.Role
-			if err := config.DB.First(&role, "id = ? AND is_active = ?", roleID, true).Error; err != nil {
+			if err := middleware.ScopedDB(r).First(&role, "id = ? AND is_active = ?", roleID, true).Error; err != nil {
 				http.Error(w, "role not found", http.StatusBadRequest)
 				return
 			}
 
+			if err := validateRoleWindow(req.RoleValidFrom, req.RoleValidUntil); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if claims := middleware.GetClaims(r); claims != nil {
+				if assignedBy, err := uuid.Parse(claims.UserID); err == nil {
+					updateMap["role_assigned_by"] = assignedBy
+				}
+			}
+
 			updateMap["role_id"] = roleID
 		}
 	}
@@ -169,7 +194,7 @@ func UpdateUser(w http.ResponseWriter, r *http.Request) {
 			}
 
 			var businessVertical models.BusinessVertical
-			if err := config.DB.First(&businessVertical, "id = ? AND is_active = ?", businessVerticalID, true).Error; err != nil {
+			if err := middleware.ScopedDB(r).Scopes(middleware.TenantScope(r)).First(&businessVertical, "id = ? AND is_active = ?", businessVerticalID, true).Error; err != nil {
 				http.Error(w, "business vertical not found", http.StatusBadRequest)
 				return
 			}
@@ -184,7 +209,7 @@ func UpdateUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Use Updates() instead of Save() to explicitly persist pointer fields
-	if err := config.DB.Model(&user).Updates(updateMap).Error; err != nil {
+	if err := middleware.ScopedDB(r).Model(&user).Updates(updateMap).Error; err != nil {
 		http.Error(w, "failed to update user: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -192,7 +217,7 @@ func UpdateUser(w http.ResponseWriter, r *http.Request) {
 	// Verify critical role/vertical fields actually persisted when requested.
 	if req.RoleID != nil || req.BusinessVerticalID != nil {
 		var persisted models.User
-		if err := config.DB.Select("id", "role_id", "business_vertical_id").First(&persisted, "id = ?", user.ID).Error; err != nil {
+		if err := middleware.ScopedDB(r).Select("id", "role_id", "business_vertical_id").First(&persisted, "id = ?", user.ID).Error; err != nil {
 			http.Error(w, "failed to verify user update: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -238,7 +263,7 @@ func UpdateUser(w http.ResponseWriter, r *http.Request) {
 	middleware.InvalidateUserCache(userID)
 	InvalidateAdminUsersCache()
 
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Preload("RoleModel").
 		Preload("BusinessVertical").
 		Preload("UserBusinessRoles.BusinessRole.BusinessVertical").
@@ -265,7 +290,7 @@ func DeleteUser(w http.ResponseWriter, r *http.Request) {
 
 	// Check if user exists
 	var user models.User
-	if err := config.DB.First(&user, "id = ?", id).Error; err != nil {
+	if err := middleware.ScopedDB(r).Scopes(middleware.TenantScope(r)).First(&user, "id = ?", id).Error; err != nil {
 		http.Error(w, "user not found", http.StatusNotFound)
 		return
 	}
@@ -279,7 +304,7 @@ func DeleteUser(w http.ResponseWriter, r *http.Request) {
 
 	// Soft delete (set IsActive to false)
 	user.IsActive = false
-	if err := config.DB.Save(&user).Error; err != nil {
+	if err := middleware.ScopedDB(r).Save(&user).Error; err != nil {
 		http.Error(w, "failed to delete user: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -308,7 +333,7 @@ func ChangePassword(w http.ResponseWriter, r *http.Request) {
 	// Get current user
 	claims := middleware.GetClaims(r)
 	var user models.User
-	if err := config.DB.First(&user, "id = ?", claims.UserID).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&user, "id = ?", claims.UserID).Error; err != nil {
 		http.Error(w, "user not found", http.StatusNotFound)
 		return
 	}
@@ -328,7 +353,7 @@ func ChangePassword(w http.ResponseWriter, r *http.Request) {
 
 	// Update password
 	user.PasswordHash = string(hash)
-	if err := config.DB.Save(&user).Error; err != nil {
+	if err := middleware.ScopedDB(r).Save(&user).Error; err != nil {
 		http.Error(w, "failed to update password: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -350,10 +375,11 @@ func GetbyID(w http.ResponseWriter, r *http.Request) {
 
 	// Get user
 	var user models.User
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Preload("RoleModel").
 		Preload("BusinessVertical").
 		Preload("UserBusinessRoles.BusinessRole.BusinessVertical").
+		Scopes(middleware.TenantScope(r)).
 		First(&user, "id = ?", id).Error; err != nil {
 		http.Error(w, "user not found", http.StatusNotFound)
 		return
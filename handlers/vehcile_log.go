@@ -6,7 +6,6 @@ import (
 
 	"github.com/gorilla/mux"
 	"gorm.io/gorm/clause"
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 )
@@ -23,7 +22,7 @@ func GetAllVehicleLogs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	service := models.NewReportService(config.DB, models.VehicleLog{})
+	service := models.NewReportService(middleware.ScopedDB(r), models.VehicleLog{})
 	response, err := service.GetReport(params)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -44,7 +43,7 @@ func CreateVehicleLog(w http.ResponseWriter, r *http.Request) {
 	// If you want to save user info, uncomment and set fields as needed
 	item.SiteEngineerName = user.Name
 	item.SiteEngineerPhone = user.Phone
-	config.DB.Create(&item)
+	middleware.ScopedDB(r).Create(&item)
 	json.NewEncoder(w).Encode(item)
 }
 
@@ -52,7 +51,7 @@ func GetVehicleLog(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id := params["id"]
 	var item models.VehicleLog
-	if err := config.DB.First(&item, "id = ?", id).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&item, "id = ?", id).Error; err != nil {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
@@ -63,7 +62,7 @@ func UpdateVehicleLog(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id := params["id"]
 	var item models.VehicleLog
-	if err := config.DB.First(&item, "id = ?", id).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&item, "id = ?", id).Error; err != nil {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
@@ -71,14 +70,14 @@ func UpdateVehicleLog(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
 		return
 	}
-	config.DB.Save(&item)
+	middleware.ScopedDB(r).Save(&item)
 	json.NewEncoder(w).Encode(item)
 }
 
 func DeleteVehicleLog(w http.ResponseWriter, r *http.Request) {
 	params := mux.Vars(r)
 	id := params["id"]
-	if err := config.DB.Delete(&models.VehicleLog{}, "id = ?", id).Error; err != nil {
+	if err := middleware.ScopedDB(r).Delete(&models.VehicleLog{}, "id = ?", id).Error; err != nil {
 		http.Error(w, "db error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -98,7 +97,7 @@ func BatchVehicleLogs(w http.ResponseWriter, r *http.Request) {
 		batch[i].SiteEngineerName = user.Name
 		batch[i].SiteEngineerPhone = user.Phone
 	}
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Clauses(clause.OnConflict{
 			Columns:   []clause.Column{{Name: "id"}},
 			DoNothing: true,
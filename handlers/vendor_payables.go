@@ -0,0 +1,513 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// invoiceMatchTolerance returns the business's invoice tolerance, or the default if it has
+// set none
+func invoiceMatchTolerance(db *gorm.DB, businessID uuid.UUID) (models.InvoiceMatchTolerance, error) {
+	var tolerance models.InvoiceMatchTolerance
+	err := db.Where("business_vertical_id = ?", businessID).First(&tolerance).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.DefaultInvoiceMatchTolerance(businessID), nil
+	}
+	return tolerance, err
+}
+
+// invoiceDueDate returns when an invoice dated on the order's vendor's invoice falls due:
+// after the vendor's credit period, or the business's if the vendor has none
+func invoiceDueDate(db *gorm.DB, po *models.PurchaseOrder, invoiceDate time.Time) (*time.Time, error) {
+	days := 0
+	if po.VendorID != nil {
+		var vendor models.Vendor
+		if err := db.Select("credit_days").First(&vendor, "id = ?", *po.VendorID).Error; err == nil {
+			days = vendor.CreditDays
+		}
+	}
+	if days == 0 {
+		tolerance, err := invoiceMatchTolerance(db, po.BusinessVerticalID)
+		if err != nil {
+			return nil, err
+		}
+		days = tolerance.CreditDays
+	}
+	due := invoiceDate.AddDate(0, 0, days)
+	return &due, nil
+}
+
+// ==========================
+// Match tolerance handlers
+// ==========================
+
+// GetInvoiceMatchTolerance returns the business's invoice matching tolerance and default
+// credit period, or the defaults it matches with if it has set none
+// GET /api/v1/business/{businessCode}/invoice-match-tolerance
+func GetInvoiceMatchTolerance(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load tolerance")
+		return
+	}
+	tolerance, err := invoiceMatchTolerance(middleware.ScopedDB(r), businessID)
+	if err != nil {
+		http.Error(w, "failed to load tolerance", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"item": tolerance, "is_default": tolerance.ID == uuid.Nil})
+}
+
+// UpdateInvoiceMatchTolerance replaces the business's invoice matching tolerance. Fields
+// left out keep their current values. Invoices are matched with it from their next match.
+// PUT /api/v1/business/{businessCode}/invoice-match-tolerance
+func UpdateInvoiceMatchTolerance(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to save tolerance")
+		return
+	}
+	tolerance, err := invoiceMatchTolerance(middleware.ScopedDB(r), businessID)
+	if err != nil {
+		http.Error(w, "failed to load tolerance", http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&tolerance); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := tolerance.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tolerance.BusinessVerticalID = businessID
+	tolerance.UpdatedBy = middleware.GetClaims(r).UserID
+
+	// Zero values are written explicitly; an insert alone would take the column defaults
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.InvoiceMatchTolerance{BusinessVerticalID: businessID}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.InvoiceMatchTolerance{}).Where("business_vertical_id = ?", businessID).
+			Select("rate_percent", "rate_amount", "quantity_percent", "credit_days", "updated_by").Updates(&tolerance).Error
+	})
+	if err != nil {
+		http.Error(w, "failed to save tolerance", http.StatusInternalServerError)
+		return
+	}
+	middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID).First(&tolerance)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "tolerance saved", "item": tolerance})
+}
+
+// ==========================
+// Match exception handlers
+// ==========================
+
+// ResolveVendorInvoiceExceptions settles a mismatched invoice's exceptions: "accept" lets
+// it go through approval as it stands, "dispute" holds it until it matches, as after a
+// credit note or more receipts. Either needs a comment, and exceptions are accepted by
+// someone other than who recorded the invoice. The decision shows in its workflow history.
+// POST /api/v1/business/{businessCode}/vendor-invoices/{id}/exceptions
+func ResolveVendorInvoiceExceptions(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to resolve exceptions")
+		return
+	}
+	invoice, err := loadVendorInvoice(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load invoice")
+		return
+	}
+
+	var req struct {
+		Resolution string `json:"resolution"`
+		Comment    string `json:"comment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Comment = strings.TrimSpace(req.Comment)
+	if req.Comment == "" {
+		http.Error(w, "a comment is required", http.StatusBadRequest)
+		return
+	}
+	status := map[string]string{"accept": models.InvoiceExceptionAccepted, "dispute": models.InvoiceDisputed}[req.Resolution]
+	if status == "" {
+		http.Error(w, "resolution must be accept or dispute", http.StatusBadRequest)
+		return
+	}
+	if invoice.MatchStatus != models.InvoiceMismatch && invoice.MatchStatus != models.InvoiceDisputed || invoice.MatchStatus == status {
+		http.Error(w, "the invoice has no open exceptions to "+req.Resolution, http.StatusConflict)
+		return
+	}
+	if invoice.CurrentState != models.ProcurementDraft && invoice.CurrentState != "rejected" {
+		http.Error(w, "exceptions are resolved before the invoice goes for approval", http.StatusConflict)
+		return
+	}
+	claims := middleware.GetClaims(r)
+	if status == models.InvoiceExceptionAccepted && invoice.CreatedBy == claims.UserID {
+		http.Error(w, "exceptions must be accepted by someone other than who recorded the invoice", http.StatusForbidden)
+		return
+	}
+
+	now := time.Now()
+	transition := models.WorkflowTransition{
+		SubmissionID:   invoice.ID,
+		FromState:      invoice.CurrentState,
+		ToState:        invoice.CurrentState,
+		Action:         req.Resolution + "_exceptions",
+		ActorID:        claims.UserID,
+		ActorName:      claims.Name,
+		ActorRole:      claims.Role,
+		Comment:        req.Comment,
+		Metadata:       json.RawMessage(`{}`),
+		TransitionedAt: now,
+	}
+	if exceptions, err := json.Marshal(map[string]interface{}{"exceptions": invoice.MatchExceptions}); err == nil {
+		transition.Metadata = exceptions
+	}
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.VendorInvoice{}).Where("id = ? AND match_status = ? AND current_state = ?", invoice.ID, invoice.MatchStatus, invoice.CurrentState).
+			Updates(map[string]interface{}{
+				"match_status": status, "exception_comment": req.Comment, "exception_by": claims.UserID, "exception_at": now, "updated_by": claims.UserID,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return apiError{status: http.StatusConflict, message: "the invoice changed; reload and try again"}
+		}
+		return tx.Create(&transition).Error
+	})
+	if err != nil {
+		writeProcurementErr(w, err, "failed to resolve exceptions")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "invoice " + status, "transition": transition})
+}
+
+// ==========================
+// Payment handlers
+// ==========================
+
+// ListVendorInvoicePayments lists the payments made against an invoice
+// GET /api/v1/business/{businessCode}/vendor-invoices/{id}/payments
+func ListVendorInvoicePayments(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load payments")
+		return
+	}
+	invoice, err := loadVendorInvoice(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load invoice")
+		return
+	}
+	var items []models.VendorInvoicePayment
+	if err := middleware.ScopedDB(r).Where("invoice_id = ?", invoice.ID).Order("paid_on, created_at").Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch payments", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"items": items, "count": len(items), "total_amount": invoice.TotalAmount, "paid_amount": invoice.PaidAmount,
+		"outstanding": invoice.Outstanding(), "payment_status": invoice.PaymentStatus,
+	})
+}
+
+// RecordVendorInvoicePayment records a payment against an approved invoice, up to what
+// is outstanding on it, and posts it to the ledger
+// POST /api/v1/business/{businessCode}/vendor-invoices/{id}/payments
+func RecordVendorInvoicePayment(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to record payment")
+		return
+	}
+	invoice, err := loadVendorInvoice(middleware.ScopedDB(r), r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load invoice")
+		return
+	}
+	if invoice.CurrentState != models.ProcurementApproved {
+		http.Error(w, "payments can only be recorded against an approved invoice", http.StatusConflict)
+		return
+	}
+
+	var req struct {
+		Amount    float64 `json:"amount"`
+		PaidOn    string  `json:"paid_on"`
+		Mode      string  `json:"mode"`
+		Reference string  `json:"reference"`
+		Remarks   string  `json:"remarks"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	paidOn, err := parseAttendanceDate(req.PaidOn)
+	if err != nil {
+		http.Error(w, "paid_on: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	userID := middleware.GetClaims(r).UserID
+	payment := models.VendorInvoicePayment{
+		BusinessVerticalID: businessID,
+		InvoiceID:          invoice.ID,
+		Amount:             math.Round(req.Amount*100) / 100,
+		PaidOn:             paidOn,
+		Mode:               strings.ToLower(strings.TrimSpace(req.Mode)),
+		Reference:          strings.TrimSpace(req.Reference),
+		Remarks:            strings.TrimSpace(req.Remarks),
+		RecordedBy:         userID,
+	}
+	if payment.Amount <= 0 || payment.Reference == "" {
+		http.Error(w, "a positive amount and a reference are required", http.StatusBadRequest)
+		return
+	}
+
+	err = middleware.ScopedDB(r).Transaction(func(tx *gorm.DB) error {
+		var locked models.VendorInvoice
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, "id = ?", invoice.ID).Error; err != nil {
+			return err
+		}
+		outstanding := locked.Outstanding()
+		if payment.Amount > outstanding {
+			return apiError{status: http.StatusUnprocessableEntity, message: fmt.Sprintf("only %.2f is outstanding on the invoice", outstanding)}
+		}
+		if err := tx.Create(&payment).Error; err != nil {
+			return err
+		}
+		locked.PaidAmount = math.Round((locked.PaidAmount+payment.Amount)*100) / 100
+		locked.PaymentStatus = models.InvoicePartlyPaid
+		if locked.Outstanding() <= 0 {
+			locked.PaymentStatus = models.InvoicePaid
+		}
+		if err := tx.Model(&locked).Updates(map[string]interface{}{
+			"paid_amount": locked.PaidAmount, "payment_status": locked.PaymentStatus, "updated_by": userID,
+		}).Error; err != nil {
+			return err
+		}
+		*invoice = locked
+		return postVendorPayment(tx, invoice, &payment, userID)
+	})
+	if err != nil {
+		writeProcurementErr(w, err, "failed to record payment")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"message": "payment recorded", "item": payment, "outstanding": invoice.Outstanding(), "payment_status": invoice.PaymentStatus,
+	})
+}
+
+// ==========================
+// Payables report handlers
+// ==========================
+
+// payable is an approved invoice with money still owed on it
+type payable struct {
+	InvoiceID     uuid.UUID  `json:"invoice_id"`
+	InvoiceNumber string     `json:"invoice_number"`
+	InvoiceDate   time.Time  `json:"invoice_date"`
+	DueDate       *time.Time `json:"due_date"`
+	VendorID      *uuid.UUID `json:"vendor_id,omitempty"`
+	VendorName    string     `json:"vendor_name"`
+	OrderNumber   string     `json:"order_number"`
+	Currency      string     `json:"currency"`
+	ExchangeRate  float64    `json:"exchange_rate"`
+	TotalAmount   float64    `json:"total_amount"` // in the invoice's currency
+	PaidAmount    float64    `json:"paid_amount"`
+	Outstanding   float64    `json:"outstanding"` // in the base currency
+	DaysOverdue   int        `json:"days_overdue"`
+	Bucket        string     `json:"bucket"`
+}
+
+// loadPayables returns the business's approved, unpaid invoices as of a date, aged
+func loadPayables(businessID uuid.UUID, asOf time.Time, query func(*gorm.DB) *gorm.DB) ([]payable, error) {
+	db := config.DB.Table("vendor_invoices vi").
+		Joins("JOIN purchase_orders po ON po.id = vi.purchase_order_id").
+		Where("vi.business_vertical_id = ? AND vi.current_state = ? AND vi.payment_status <> ? AND vi.invoice_date <= ?",
+			businessID, models.ProcurementApproved, models.InvoicePaid, asOf)
+	if query != nil {
+		db = query(db)
+	}
+	var rows []payable
+	if err := db.Select(`vi.id AS invoice_id, vi.invoice_number, vi.invoice_date, vi.due_date, po.vendor_id, po.vendor_name,
+		po.order_number, vi.currency, vi.exchange_rate, vi.total_amount, vi.paid_amount,
+		ROUND((vi.total_amount - vi.paid_amount) * vi.exchange_rate, 2) AS outstanding`).
+		Order("vi.due_date, vi.invoice_date").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		due := rows[i].InvoiceDate
+		if rows[i].DueDate != nil {
+			due = *rows[i].DueDate
+		}
+		rows[i].Bucket, rows[i].DaysOverdue = models.AgingBucket(due, asOf)
+	}
+	return rows, nil
+}
+
+// ListPayablesDue lists approved invoices with money owed that are overdue or fall due
+// within ?within= days (7 by default), soonest first
+// GET /api/v1/business/{businessCode}/vendor-invoices/due
+func ListPayablesDue(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load payables")
+		return
+	}
+	within := 7
+	if v := r.URL.Query().Get("within"); v != "" {
+		if within, err = strconv.Atoi(v); err != nil || within < 0 || within > 365 {
+			http.Error(w, "within must be between 0 and 365 days", http.StatusBadRequest)
+			return
+		}
+	}
+
+	today := calendarDate(time.Now(), attendanceLocation(nil))
+	rows, err := loadPayables(businessID, today, func(db *gorm.DB) *gorm.DB {
+		return db.Where("vi.due_date <= ?", today.AddDate(0, 0, within))
+	})
+	if err != nil {
+		http.Error(w, "failed to fetch payables", http.StatusInternalServerError)
+		return
+	}
+	var overdue, dueSoon float64
+	for _, row := range rows {
+		if row.DaysOverdue > 0 {
+			overdue += row.Outstanding
+		} else {
+			dueSoon += row.Outstanding
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"items": rows, "count": len(rows), "within_days": within, "currency": models.BaseCurrency,
+		"overdue_amount": math.Round(overdue*100) / 100, "due_amount": math.Round(dueSoon*100) / 100,
+	})
+}
+
+// agingRow is a vendor's outstanding invoices by aging bucket
+type agingRow struct {
+	VendorID    *uuid.UUID         `json:"vendor_id,omitempty"`
+	VendorName  string             `json:"vendor_name"`
+	Buckets     map[string]float64 `json:"buckets"`
+	Outstanding float64            `json:"outstanding"`
+	Invoices    int                `json:"invoices"`
+}
+
+// GetPayablesAging returns what the business owes each vendor on approved invoices as of
+// ?as_of= (YYYY-MM-DD, today by default), by days past due: not yet due, 1-30, 31-60,
+// 61-90 and over 90. ?format=csv downloads it.
+// GET /api/v1/business/{businessCode}/vendor-invoices/aging
+func GetPayablesAging(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load aging")
+		return
+	}
+	asOf := calendarDate(time.Now(), attendanceLocation(nil))
+	if v := r.URL.Query().Get("as_of"); v != "" {
+		if asOf, err = parseAttendanceDate(v); err != nil {
+			http.Error(w, "as_of: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Payments made after the date still count as owed on it
+	rows, err := loadPayables(businessID, asOf, nil)
+	if err == nil {
+		var later []struct {
+			InvoiceID uuid.UUID
+			Amount    float64
+		}
+		err = middleware.ScopedDB(r).Model(&models.VendorInvoicePayment{}).Select("invoice_id, SUM(amount) AS amount").
+			Where("business_vertical_id = ? AND paid_on > ?", businessID, asOf).Group("invoice_id").Scan(&later).Error
+		laterPaid := map[uuid.UUID]float64{}
+		for _, p := range later {
+			laterPaid[p.InvoiceID] = p.Amount
+		}
+		for i := range rows {
+			rows[i].Outstanding = math.Round((rows[i].Outstanding+models.ToBase(laterPaid[rows[i].InvoiceID], rows[i].ExchangeRate))*100) / 100
+		}
+	}
+	if err != nil {
+		http.Error(w, "failed to fetch payables", http.StatusInternalServerError)
+		return
+	}
+
+	byVendor := map[string]*agingRow{}
+	totals := map[string]float64{}
+	for _, row := range rows {
+		key := row.VendorName
+		if row.VendorID != nil {
+			key = row.VendorID.String()
+		}
+		v, ok := byVendor[key]
+		if !ok {
+			v = &agingRow{VendorID: row.VendorID, VendorName: row.VendorName, Buckets: map[string]float64{}}
+			for _, b := range models.AgingBuckets {
+				v.Buckets[b] = 0
+			}
+			byVendor[key] = v
+		}
+		v.Buckets[row.Bucket] = math.Round((v.Buckets[row.Bucket]+row.Outstanding)*100) / 100
+		v.Outstanding = math.Round((v.Outstanding+row.Outstanding)*100) / 100
+		v.Invoices++
+		totals[row.Bucket] = math.Round((totals[row.Bucket]+row.Outstanding)*100) / 100
+	}
+	vendors := make([]agingRow, 0, len(byVendor))
+	for _, v := range byVendor {
+		vendors = append(vendors, *v)
+	}
+	sort.Slice(vendors, func(i, j int) bool { return vendors[i].Outstanding > vendors[j].Outstanding })
+
+	if r.URL.Query().Get("format") != "csv" {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"as_of": asOf.Format("2006-01-02"), "currency": models.BaseCurrency, "buckets": models.AgingBuckets,
+			"vendors": vendors, "totals": totals, "invoices": rows,
+		})
+		return
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	_ = writer.Write([]string{"Vendor", "Invoices", "Not due", "1-30", "31-60", "61-90", "Over 90", "Outstanding"})
+	for _, v := range vendors {
+		record := []string{v.VendorName, strconv.Itoa(v.Invoices)}
+		for _, b := range models.AgingBuckets {
+			record = append(record, strconv.FormatFloat(v.Buckets[b], 'f', 2, 64))
+		}
+		_ = writer.Write(append(record, strconv.FormatFloat(v.Outstanding, 'f', 2, 64)))
+	}
+	writer.Flush()
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="payables-aging-%s.csv"`, asOf.Format("2006-01-02")))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
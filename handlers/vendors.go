@@ -0,0 +1,497 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// vendorRequest is the body of vendor create and update requests
+type vendorRequest struct {
+	Code              string   `json:"code"`
+	Name              string   `json:"name"`
+	GSTIN             string   `json:"gstin"`
+	PAN               string   `json:"pan"`
+	Categories        []string `json:"categories"`
+	ApplicableSiteIDs []string `json:"applicable_site_ids"`
+	ContactName       string   `json:"contact_name"`
+	Phone             string   `json:"phone"`
+	Email             string   `json:"email"`
+	Address           string   `json:"address"`
+	BankAccountName   string   `json:"bank_account_name"`
+	BankAccountNumber string   `json:"bank_account_number"`
+	BankIFSC          string   `json:"bank_ifsc"`
+	BankName          string   `json:"bank_name"`
+	BankBranch        string   `json:"bank_branch"`
+	PaymentTerms      string   `json:"payment_terms"`
+	CreditDays        *int     `json:"credit_days"`
+	Remarks           string   `json:"remarks"`
+	IsActive          *bool    `json:"is_active"`
+}
+
+// apply copies the request onto the vendor. On update, blank fields and omitted lists
+// leave the vendor's values as they are.
+func (req vendorRequest) apply(v *models.Vendor) {
+	for field, value := range map[*string]string{
+		&v.Name: req.Name, &v.GSTIN: req.GSTIN, &v.PAN: req.PAN,
+		&v.ContactName: req.ContactName, &v.Phone: req.Phone, &v.Email: req.Email, &v.Address: req.Address,
+		&v.BankAccountName: req.BankAccountName, &v.BankAccountNumber: req.BankAccountNumber,
+		&v.BankIFSC: req.BankIFSC, &v.BankName: req.BankName, &v.BankBranch: req.BankBranch,
+		&v.PaymentTerms: req.PaymentTerms, &v.Remarks: req.Remarks,
+	} {
+		if strings.TrimSpace(value) != "" {
+			*field = strings.TrimSpace(value)
+		}
+	}
+	if req.Categories != nil {
+		v.Categories = models.StringArray{}
+		for _, c := range req.Categories {
+			if c = strings.TrimSpace(c); c != "" {
+				v.Categories = append(v.Categories, c)
+			}
+		}
+	}
+	if req.ApplicableSiteIDs != nil {
+		v.ApplicableSiteIDs = models.StringArray(req.ApplicableSiteIDs)
+	}
+	if req.CreditDays != nil {
+		v.CreditDays = *req.CreditDays
+	}
+	if req.IsActive != nil {
+		v.IsActive = *req.IsActive
+	}
+	v.Normalize()
+}
+
+// canViewVendorBank reports whether the user may see vendors' full bank account numbers
+func canViewVendorBank(r *http.Request) bool {
+	permissions := middleware.GetEffectivePermissions(r)
+	return hasWorkflowPermission(permissions, "finance:read") || hasWorkflowPermission(permissions, "purchase:update")
+}
+
+// maskVendorBank hides all but the last four digits of the vendor's bank account number
+func maskVendorBank(v *models.Vendor) {
+	if n := len(v.BankAccountNumber); n > 4 {
+		v.BankAccountNumber = strings.Repeat("X", n-4) + v.BankAccountNumber[n-4:]
+	}
+}
+
+// loadVendor loads the vendor in the request within the business
+func loadVendor(r *http.Request, businessID uuid.UUID) (*models.Vendor, error) {
+	id, err := parseFinanceUUIDParam(r, "id")
+	if err != nil {
+		return nil, apiError{status: http.StatusBadRequest, message: "invalid vendor id"}
+	}
+	var vendor models.Vendor
+	if err := middleware.ScopedDB(r).Where("business_vertical_id = ? AND deleted_at IS NULL", businessID).First(&vendor, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError{status: http.StatusNotFound, message: "vendor not found"}
+		}
+		return nil, err
+	}
+	return &vendor, nil
+}
+
+// checkVendorSites checks the vendor's applicable sites belong to the business
+func checkVendorSites(businessID uuid.UUID, v *models.Vendor) error {
+	if len(v.ApplicableSiteIDs) == 0 {
+		return nil
+	}
+	var count int64
+	config.DB.Model(&models.Site{}).Where("business_vertical_id = ? AND id IN ?", businessID, []string(v.ApplicableSiteIDs)).Count(&count)
+	if int(count) != len(v.ApplicableSiteIDs) {
+		return apiError{status: http.StatusBadRequest, message: "applicable_site_ids must be sites of this business"}
+	}
+	return nil
+}
+
+// ListVendors lists the business's vendors. ?active=true, ?category=, ?site_id= (vendors
+// that may supply the site) and ?q= (part of the code, name or GSTIN) narrow them.
+// GET /api/v1/business/{businessCode}/vendors
+func ListVendors(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load vendors")
+		return
+	}
+
+	query := middleware.ScopedDB(r).Where("business_vertical_id = ? AND deleted_at IS NULL", businessID)
+	q := r.URL.Query()
+	if q.Get("active") == "true" {
+		query = query.Where("is_active = ?", true)
+	}
+	if v := strings.TrimSpace(q.Get("category")); v != "" {
+		query = query.Where("categories @> ?::jsonb", `["`+strings.ReplaceAll(v, `"`, "")+`"]`)
+	}
+	if v := q.Get("site_id"); v != "" {
+		query = query.Where("(applicable_site_ids = '[]'::jsonb OR applicable_site_ids @> ?::jsonb)", `["`+strings.ReplaceAll(v, `"`, "")+`"]`)
+	}
+	if v := strings.TrimSpace(q.Get("q")); v != "" {
+		like := "%" + v + "%"
+		query = query.Where("(code ILIKE ? OR name ILIKE ? OR gstin ILIKE ?)", like, like, like)
+	}
+	var items []models.Vendor
+	if err := query.Order("name").Find(&items).Error; err != nil {
+		http.Error(w, "failed to fetch vendors", http.StatusInternalServerError)
+		return
+	}
+	if !canViewVendorBank(r) {
+		for i := range items {
+			maskVendorBank(&items[i])
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// CreateVendor registers a vendor in the business
+// POST /api/v1/business/{businessCode}/vendors
+func CreateVendor(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to create vendor")
+		return
+	}
+
+	var req vendorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	vendor := models.Vendor{
+		BusinessVerticalID: businessID,
+		Code:               strings.TrimSpace(req.Code),
+		IsActive:           true,
+		CreatedBy:          middleware.GetClaims(r).UserID,
+	}
+	req.apply(&vendor)
+	if err := vendor.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkVendorSites(businessID, &vendor); err != nil {
+		writeProcurementErr(w, err, "failed to create vendor")
+		return
+	}
+
+	var count int64
+	middleware.ScopedDB(r).Model(&models.Vendor{}).
+		Where("business_vertical_id = ? AND code = ? AND deleted_at IS NULL", businessID, vendor.Code).Count(&count)
+	if count > 0 {
+		http.Error(w, "a vendor with this code already exists", http.StatusConflict)
+		return
+	}
+	if vendor.GSTIN != "" {
+		middleware.ScopedDB(r).Model(&models.Vendor{}).
+			Where("business_vertical_id = ? AND gstin = ? AND deleted_at IS NULL", businessID, vendor.GSTIN).Count(&count)
+		if count > 0 {
+			http.Error(w, "a vendor with this GSTIN already exists", http.StatusConflict)
+			return
+		}
+	}
+	if err := middleware.ScopedDB(r).Create(&vendor).Error; err != nil {
+		http.Error(w, "failed to create vendor", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "vendor created", "item": vendor})
+}
+
+// GetVendor returns a vendor with its delivery performance to date
+// GET /api/v1/business/{businessCode}/vendors/{id}
+func GetVendor(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load vendor")
+		return
+	}
+	vendor, err := loadVendor(r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load vendor")
+		return
+	}
+	performance, err := vendorPerformance(businessID, []models.Vendor{*vendor}, nil, nil)
+	if err != nil {
+		http.Error(w, "failed to compute vendor performance", http.StatusInternalServerError)
+		return
+	}
+	if !canViewVendorBank(r) {
+		maskVendorBank(vendor)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"item": vendor, "performance": performance[0]})
+}
+
+// UpdateVendor updates a vendor's details. Its code cannot change, as orders refer to it.
+// PUT /api/v1/business/{businessCode}/vendors/{id}
+func UpdateVendor(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update vendor")
+		return
+	}
+	vendor, err := loadVendor(r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load vendor")
+		return
+	}
+
+	var req vendorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.apply(vendor)
+	if err := vendor.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkVendorSites(businessID, vendor); err != nil {
+		writeProcurementErr(w, err, "failed to update vendor")
+		return
+	}
+	if vendor.GSTIN != "" {
+		var count int64
+		middleware.ScopedDB(r).Model(&models.Vendor{}).
+			Where("business_vertical_id = ? AND gstin = ? AND id <> ? AND deleted_at IS NULL", businessID, vendor.GSTIN, vendor.ID).Count(&count)
+		if count > 0 {
+			http.Error(w, "a vendor with this GSTIN already exists", http.StatusConflict)
+			return
+		}
+	}
+	vendor.UpdatedBy = middleware.GetClaims(r).UserID
+	if err := middleware.ScopedDB(r).Save(vendor).Error; err != nil {
+		http.Error(w, "failed to update vendor", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "vendor updated", "item": vendor})
+}
+
+// ==========================
+// Vendor reports
+// ==========================
+
+// vendorReportPeriod reads ?from= and ?to= (YYYY-MM-DD), either of which may be omitted
+func vendorReportPeriod(r *http.Request) (from, to *time.Time, err error) {
+	for param, dst := range map[string]**time.Time{"from": &from, "to": &to} {
+		if v := r.URL.Query().Get(param); v != "" {
+			day, perr := time.Parse("2006-01-02", v)
+			if perr != nil {
+				return nil, nil, apiError{status: http.StatusBadRequest, message: param + " must be YYYY-MM-DD"}
+			}
+			*dst = &day
+		}
+	}
+	return from, to, nil
+}
+
+// vendorPerformance works out each vendor's delivery performance from its approved orders
+// and the GRNs received against them in the period
+func vendorPerformance(businessID uuid.UUID, vendors []models.Vendor, from, to *time.Time) ([]models.VendorPerformance, error) {
+	ids := make([]uuid.UUID, 0, len(vendors))
+	for _, v := range vendors {
+		ids = append(ids, v.ID)
+	}
+	results := make([]models.VendorPerformance, 0, len(vendors))
+	if len(ids) == 0 {
+		return results, nil
+	}
+
+	orderQuery := config.DB.Where("business_vertical_id = ? AND vendor_id IN ? AND current_state = ? AND deleted_at IS NULL",
+		businessID, ids, models.ProcurementApproved)
+	grnQuery := config.DB.Where("business_vertical_id = ? AND vendor_id IN ?", businessID, ids)
+	if from != nil {
+		orderQuery = orderQuery.Where("order_date >= ?", *from)
+		grnQuery = grnQuery.Where("received_date >= ?", *from)
+	}
+	if to != nil {
+		orderQuery = orderQuery.Where("order_date <= ?", *to)
+		grnQuery = grnQuery.Where("received_date <= ?", *to)
+	}
+	var orders []models.PurchaseOrder
+	if err := orderQuery.Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	var grns []models.GoodsReceiptNote
+	if err := grnQuery.Find(&grns).Error; err != nil {
+		return nil, err
+	}
+
+	// Receipts are measured against the promised date of their own order, whenever it was raised
+	promised := map[uuid.UUID]*time.Time{}
+	orderIDs := make([]uuid.UUID, 0, len(grns))
+	for _, grn := range grns {
+		orderIDs = append(orderIDs, grn.PurchaseOrderID)
+	}
+	if len(orderIDs) > 0 {
+		var receiptOrders []models.PurchaseOrder
+		if err := config.DB.Select("id", "delivery_date").Where("id IN ?", orderIDs).Find(&receiptOrders).Error; err != nil {
+			return nil, err
+		}
+		for _, po := range receiptOrders {
+			promised[po.ID] = po.DeliveryDate
+		}
+	}
+
+	deliveries := map[uuid.UUID][]models.VendorDelivery{}
+	for _, grn := range grns {
+		d := models.VendorDelivery{
+			PurchaseOrderID: grn.PurchaseOrderID,
+			DeliveryDate:    promised[grn.PurchaseOrderID],
+			ReceivedDate:    grn.ReceivedDate,
+			AcceptedValue:   grn.AcceptedValue,
+		}
+		for _, line := range grn.Lines {
+			d.ReceivedQuantity += line.ReceivedQuantity
+			d.RejectedQuantity += line.RejectedQuantity
+		}
+		deliveries[*grn.VendorID] = append(deliveries[*grn.VendorID], d)
+	}
+	ordered := map[uuid.UUID][2]float64{}
+	for _, po := range orders {
+		sum := ordered[*po.VendorID]
+		ordered[*po.VendorID] = [2]float64{sum[0] + 1, sum[1] + po.TotalAmount}
+	}
+
+	for _, v := range vendors {
+		p := models.SummarizeVendorDeliveries(deliveries[v.ID])
+		p.VendorID, p.VendorCode, p.VendorName = v.ID, v.Code, v.Name
+		p.Orders = int(ordered[v.ID][0])
+		p.OrderedValue = ordered[v.ID][1]
+		results = append(results, p)
+	}
+	return results, nil
+}
+
+// GetVendorPerformance reports a vendor's delivery performance: receipts on time and late
+// against the orders' delivery dates, delays and rejections. ?from= and ?to= (YYYY-MM-DD)
+// narrow it to a period.
+// GET /api/v1/business/{businessCode}/vendors/{id}/performance
+func GetVendorPerformance(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load vendor performance")
+		return
+	}
+	vendor, err := loadVendor(r, businessID)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load vendor")
+		return
+	}
+	from, to, err := vendorReportPeriod(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load vendor performance")
+		return
+	}
+	performance, err := vendorPerformance(businessID, []models.Vendor{*vendor}, from, to)
+	if err != nil {
+		http.Error(w, "failed to compute vendor performance", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"performance": performance[0], "from": from, "to": to})
+}
+
+// ListVendorPerformance ranks the business's vendors by on-time delivery, then by
+// rejections. ?category=, ?from= and ?to= (YYYY-MM-DD) narrow it.
+// GET /api/v1/business/{businessCode}/vendors/performance
+func ListVendorPerformance(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load vendor performance")
+		return
+	}
+	from, to, err := vendorReportPeriod(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load vendor performance")
+		return
+	}
+
+	query := middleware.ScopedDB(r).Where("business_vertical_id = ? AND deleted_at IS NULL", businessID)
+	if v := strings.TrimSpace(r.URL.Query().Get("category")); v != "" {
+		query = query.Where("categories @> ?::jsonb", `["`+strings.ReplaceAll(v, `"`, "")+`"]`)
+	}
+	var vendors []models.Vendor
+	if err := query.Order("name").Find(&vendors).Error; err != nil {
+		http.Error(w, "failed to fetch vendors", http.StatusInternalServerError)
+		return
+	}
+	items, err := vendorPerformance(businessID, vendors, from, to)
+	if err != nil {
+		http.Error(w, "failed to compute vendor performance", http.StatusInternalServerError)
+		return
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if (items[i].Receipts == 0) != (items[j].Receipts == 0) {
+			return items[i].Receipts > 0
+		}
+		if items[i].OnTimePercent != items[j].OnTimePercent {
+			return items[i].OnTimePercent > items[j].OnTimePercent
+		}
+		return items[i].RejectionPercent < items[j].RejectionPercent
+	})
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items), "from": from, "to": to})
+}
+
+// GetVendorPriceHistory lists the rates materials were ordered at on approved purchase
+// orders, with each material's range, weighted average and latest rate, so a new order's
+// rates can be weighed against them. ?material_code=, ?vendor_id=, ?site_id=, ?from= and
+// ?to= (YYYY-MM-DD) narrow it.
+// GET /api/v1/business/{businessCode}/vendors/price-history
+func GetVendorPriceHistory(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load price history")
+		return
+	}
+	from, to, err := vendorReportPeriod(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load price history")
+		return
+	}
+
+	query := middleware.ScopedDB(r).Table("purchase_order_lines pol").
+		Select(`pol.material_code, pol.uom, po.vendor_id, po.vendor_name, po.order_number, po.order_date,
+			pol.quantity, pol.rate`).
+		Joins("JOIN purchase_orders po ON po.id = pol.purchase_order_id").
+		Where("po.business_vertical_id = ? AND po.current_state = ? AND po.vendor_id IS NOT NULL AND po.deleted_at IS NULL",
+			businessID, models.ProcurementApproved)
+	q := r.URL.Query()
+	if v := q.Get("material_code"); v != "" {
+		query = query.Where("pol.material_code = ?", v)
+	}
+	if v := q.Get("vendor_id"); v != "" {
+		query = query.Where("po.vendor_id = ?", v)
+	}
+	if v := q.Get("site_id"); v != "" {
+		query = query.Where("po.site_id = ?", v)
+	}
+	if from != nil {
+		query = query.Where("po.order_date >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("po.order_date <= ?", *to)
+	}
+	var points []models.VendorPricePoint
+	if err := query.Order("pol.material_code, po.order_date DESC").Limit(2000).Scan(&points).Error; err != nil {
+		http.Error(w, "failed to fetch price history", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"materials": models.SummarizePriceHistory(points),
+		"items":     points,
+		"count":     len(points),
+	})
+}
@@ -7,7 +7,6 @@ import (
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"gorm.io/gorm/clause"
-	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
 )
@@ -33,7 +32,7 @@ func GetAllWaterTankerReports(w http.ResponseWriter, r *http.Request) {
 	// If site context exists, filter by accessible sites
 	if siteContext != nil && len(siteContext.AccessibleSiteIDs) > 0 {
 		// Optimized: Select only names instead of loading full site objects
-		config.DB.Model(&models.Site{}).
+		middleware.ScopedDB(r).Model(&models.Site{}).
 			Select("name").
 			Where("id IN ?", siteContext.AccessibleSiteIDs).
 			Pluck("name", &accessibleSiteNames)
@@ -60,13 +59,13 @@ func GetAllWaterTankerReports(w http.ResponseWriter, r *http.Request) {
 		// For IN queries, we'll need to handle this differently
 
 		// Build custom query with site filtering
-		jsonToDB, err := models.BuildJSONtoDBColumnMap(config.DB, models.Water{})
+		jsonToDB, err := models.BuildJSONtoDBColumnMap(middleware.ScopedDB(r), models.Water{})
 		if err != nil {
 			http.Error(w, "failed to build column mapping", http.StatusInternalServerError)
 			return
 		}
 
-		query := config.DB.Model(&models.Water{}).
+		query := middleware.ScopedDB(r).Model(&models.Water{}).
 			Where("business_vertical_id = ?", businessID).
 			Where("site_name IN ?", accessibleSiteNames)
 
@@ -120,7 +119,7 @@ func GetAllWaterTankerReports(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// No site filtering - use original service method
-	service := models.NewReportService(config.DB, models.Water{})
+	service := models.NewReportService(middleware.ScopedDB(r), models.Water{})
 	response, err := service.GetReport(params)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -155,7 +154,7 @@ func CreateWaterTankerReport(w http.ResponseWriter, r *http.Request) {
 	if siteContext != nil {
 		// Find the site by name
 		var site models.Site
-		if err := config.DB.Where("name = ? AND business_vertical_id = ?", item.SiteName, item.BusinessVerticalID).First(&site).Error; err != nil {
+		if err := middleware.ScopedDB(r).Where("name = ? AND business_vertical_id = ?", item.SiteName, item.BusinessVerticalID).First(&site).Error; err != nil {
 			http.Error(w, "site not found", http.StatusNotFound)
 			return
 		}
@@ -184,7 +183,7 @@ func CreateWaterTankerReport(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if err := config.DB.Create(&item).Error; err != nil {
+	if err := middleware.ScopedDB(r).Create(&item).Error; err != nil {
 		http.Error(w, "failed to create record", http.StatusInternalServerError)
 		return
 	}
@@ -211,7 +210,7 @@ func GetWaterTankerReport(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var item models.Water
-	result := config.DB.Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item)
+	result := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item)
 	if result.Error != nil {
 		http.Error(w, "record not found", http.StatusNotFound)
 		return
@@ -222,7 +221,7 @@ func GetWaterTankerReport(w http.ResponseWriter, r *http.Request) {
 	if siteContext != nil {
 		// Find the site
 		var site models.Site
-		if err := config.DB.Where("name = ? AND business_vertical_id = ?", item.SiteName, businessID).First(&site).Error; err != nil {
+		if err := middleware.ScopedDB(r).Where("name = ? AND business_vertical_id = ?", item.SiteName, businessID).First(&site).Error; err != nil {
 			http.Error(w, "site not found", http.StatusNotFound)
 			return
 		}
@@ -264,7 +263,7 @@ func UpdateWaterTankerReport(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var item models.Water
-	result := config.DB.Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item)
+	result := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item)
 	if result.Error != nil {
 		http.Error(w, "record not found", http.StatusNotFound)
 		return
@@ -275,7 +274,7 @@ func UpdateWaterTankerReport(w http.ResponseWriter, r *http.Request) {
 	if siteContext != nil {
 		// Find the site
 		var site models.Site
-		if err := config.DB.Where("name = ? AND business_vertical_id = ?", item.SiteName, businessID).First(&site).Error; err != nil {
+		if err := middleware.ScopedDB(r).Where("name = ? AND business_vertical_id = ?", item.SiteName, businessID).First(&site).Error; err != nil {
 			http.Error(w, "site not found", http.StatusNotFound)
 			return
 		}
@@ -305,7 +304,7 @@ func UpdateWaterTankerReport(w http.ResponseWriter, r *http.Request) {
 	// Ensure business_vertical_id cannot be changed
 	item.BusinessVerticalID = businessID
 
-	if err := config.DB.Save(&item).Error; err != nil {
+	if err := middleware.ScopedDB(r).Save(&item).Error; err != nil {
 		http.Error(w, "failed to update record", http.StatusInternalServerError)
 		return
 	}
@@ -336,14 +335,14 @@ func DeleteWaterTankerReport(w http.ResponseWriter, r *http.Request) {
 	if siteContext != nil {
 		// Get existing record to check site
 		var item models.Water
-		if err := config.DB.Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item).Error; err != nil {
+		if err := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ?", id, businessID).First(&item).Error; err != nil {
 			http.Error(w, "record not found", http.StatusNotFound)
 			return
 		}
 
 		// Find the site
 		var site models.Site
-		if err := config.DB.Where("name = ? AND business_vertical_id = ?", item.SiteName, businessID).First(&site).Error; err != nil {
+		if err := middleware.ScopedDB(r).Where("name = ? AND business_vertical_id = ?", item.SiteName, businessID).First(&site).Error; err != nil {
 			http.Error(w, "site not found", http.StatusNotFound)
 			return
 		}
@@ -365,7 +364,7 @@ func DeleteWaterTankerReport(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	result := config.DB.Where("id = ? AND business_vertical_id = ?", id, businessID).Delete(&models.Water{})
+	result := middleware.ScopedDB(r).Where("id = ? AND business_vertical_id = ?", id, businessID).Delete(&models.Water{})
 	if result.Error != nil {
 		http.Error(w, "failed to delete record", http.StatusInternalServerError)
 		return
@@ -400,7 +399,7 @@ func BatchWaterReports(w http.ResponseWriter, r *http.Request) {
 	if siteContext != nil {
 		// Build map of accessible site names for quick lookup
 		var sites []models.Site
-		if err := config.DB.Where("id IN ?", siteContext.AccessibleSiteIDs).Find(&sites).Error; err != nil {
+		if err := middleware.ScopedDB(r).Where("id IN ?", siteContext.AccessibleSiteIDs).Find(&sites).Error; err != nil {
 			http.Error(w, "failed to fetch accessible sites", http.StatusInternalServerError)
 			return
 		}
@@ -431,7 +430,7 @@ func BatchWaterReports(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Clauses(clause.OnConflict{
 			Columns:   []clause.Column{{Name: "id"}},
 			DoNothing: true,
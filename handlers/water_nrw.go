@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/nrw"
+)
+
+// waterZoneRequest is the body of zone create and update requests
+type waterZoneRequest struct {
+	SiteID              uuid.UUID   `json:"site_id"`
+	Code                string      `json:"code"`
+	Name                string      `json:"name"`
+	SupplyMeterIDs      []uuid.UUID `json:"supply_meter_ids"`
+	ConsumptionMeterIDs []uuid.UUID `json:"consumption_meter_ids"`
+	LossThresholdPct    *float64    `json:"loss_threshold_percent"`
+	MinSupplyM3         *float64    `json:"min_supply_m3"`
+	IsActive            *bool       `json:"is_active"`
+}
+
+// checkZoneMeters returns an apiError unless every meter is a flow meter of the business
+// at the zone's site, and returns their ids as stored
+func checkZoneMeters(businessID, siteID uuid.UUID, ids []uuid.UUID, field string) (models.StringArray, error) {
+	meters := models.StringArray{}
+	for _, id := range ids {
+		if err := checkSiteDevice(businessID, siteID, &id, models.SensorDeviceFlowMeter, field); err != nil {
+			return nil, err
+		}
+		meters = append(meters, id.String())
+	}
+	return meters, nil
+}
+
+// ListWaterZones lists the business's metered distribution zones. ?site_id= narrows them.
+// GET /api/v1/business/{businessCode}/water/zones
+func ListWaterZones(w http.ResponseWriter, r *http.Request) {
+	businessID, err := assetBusinessID(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load zones")
+		return
+	}
+	query := middleware.ScopedDB(r).Preload("Site").Where("business_vertical_id = ?", businessID)
+	if siteIDs, restricted := middleware.GetSiteScope(r); restricted {
+		query = query.Where("site_id IN ?", siteIDs)
+	}
+	if id, ok := parseUUIDQuery(r, "site_id"); ok {
+		query = query.Where("site_id = ?", id)
+	}
+	var items []models.WaterZone
+	if err := query.Order("code").Find(&items).Error; err != nil {
+		http.Error(w, "failed to load zones", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// CreateWaterZone registers a distribution zone with its supply and consumption flow
+// meters and the loss above which it raises an alert, by default 20%
+// POST /api/v1/business/{businessCode}/water/zones
+func CreateWaterZone(w http.ResponseWriter, r *http.Request) {
+	businessID, err := assetBusinessID(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to create zone")
+		return
+	}
+	var req waterZoneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := checkBusinessSite(businessID, &req.SiteID); err != nil || !middleware.SiteInScope(r, &req.SiteID) {
+		http.Error(w, "site not found in this business", http.StatusBadRequest)
+		return
+	}
+
+	zone := models.WaterZone{
+		BusinessVerticalID: businessID,
+		SiteID:             req.SiteID,
+		Code:               strings.TrimSpace(req.Code),
+		Name:               strings.TrimSpace(req.Name),
+		LossThresholdPct:   models.DefaultWaterLossThresholdPct,
+		IsActive:           true,
+		UpdatedBy:          middleware.GetClaims(r).UserID,
+	}
+	if req.LossThresholdPct != nil {
+		zone.LossThresholdPct = *req.LossThresholdPct
+	}
+	if req.MinSupplyM3 != nil {
+		zone.MinSupplyM3 = *req.MinSupplyM3
+	}
+	if zone.SupplyMeterIDs, err = checkZoneMeters(businessID, zone.SiteID, req.SupplyMeterIDs, "supply_meter_ids"); err != nil {
+		writeSubcontractErr(w, err, "failed to create zone")
+		return
+	}
+	if zone.ConsumptionMeterIDs, err = checkZoneMeters(businessID, zone.SiteID, req.ConsumptionMeterIDs, "consumption_meter_ids"); err != nil {
+		writeSubcontractErr(w, err, "failed to create zone")
+		return
+	}
+	if err := zone.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var count int64
+	middleware.ScopedDB(r).Model(&models.WaterZone{}).Where("business_vertical_id = ? AND code = ?", businessID, zone.Code).Count(&count)
+	if count > 0 {
+		http.Error(w, "a zone with this code already exists", http.StatusConflict)
+		return
+	}
+	if err := middleware.ScopedDB(r).Create(&zone).Error; err != nil {
+		http.Error(w, "failed to create zone", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "zone created", "item": zone})
+}
+
+// UpdateWaterZone changes a zone's name, meters, threshold or whether it is balanced.
+// Balances already worked out keep the threshold they were judged by.
+// PUT /api/v1/business/{businessCode}/water/zones/{id}
+func UpdateWaterZone(w http.ResponseWriter, r *http.Request) {
+	businessID, err := assetBusinessID(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to update zone")
+		return
+	}
+	var zone models.WaterZone
+	if err := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID).First(&zone, "id = ?", mux.Vars(r)["id"]).Error; err != nil ||
+		!middleware.SiteInScope(r, &zone.SiteID) {
+		http.Error(w, "zone not found", http.StatusNotFound)
+		return
+	}
+	var req waterZoneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if v := strings.TrimSpace(req.Name); v != "" {
+		zone.Name = v
+	}
+	if req.SupplyMeterIDs != nil {
+		if zone.SupplyMeterIDs, err = checkZoneMeters(businessID, zone.SiteID, req.SupplyMeterIDs, "supply_meter_ids"); err != nil {
+			writeSubcontractErr(w, err, "failed to update zone")
+			return
+		}
+	}
+	if req.ConsumptionMeterIDs != nil {
+		if zone.ConsumptionMeterIDs, err = checkZoneMeters(businessID, zone.SiteID, req.ConsumptionMeterIDs, "consumption_meter_ids"); err != nil {
+			writeSubcontractErr(w, err, "failed to update zone")
+			return
+		}
+	}
+	if req.LossThresholdPct != nil {
+		zone.LossThresholdPct = *req.LossThresholdPct
+	}
+	if req.MinSupplyM3 != nil {
+		zone.MinSupplyM3 = *req.MinSupplyM3
+	}
+	if req.IsActive != nil {
+		zone.IsActive = *req.IsActive
+	}
+	zone.UpdatedBy = middleware.GetClaims(r).UserID
+	if err := zone.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := middleware.ScopedDB(r).Select("name", "supply_meter_ids", "consumption_meter_ids", "loss_threshold_pct", "min_supply_m3", "is_active", "updated_by").
+		Updates(&zone).Error; err != nil {
+		http.Error(w, "failed to update zone", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "zone updated", "item": zone})
+}
+
+// ComputeWaterLosses balances the business's active zones, or ?zone_id=, for the days
+// ?from= to ?to= (YYYY-MM-DD, by default yesterday) now, as the daily job does, raising
+// alerts for the days over threshold not yet alerted
+// POST /api/v1/business/{businessCode}/water/nrw/compute
+func ComputeWaterLosses(w http.ResponseWriter, r *http.Request) {
+	businessID, err := assetBusinessID(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to compute losses")
+		return
+	}
+	fromDay, toDay, err := vendorReportPeriod(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to compute losses")
+		return
+	}
+	today := calendarDate(time.Now(), nrw.Location())
+	from, to := today.AddDate(0, 0, -1), today.AddDate(0, 0, -1)
+	if toDay != nil {
+		to = *toDay
+	}
+	if fromDay != nil {
+		from = *fromDay
+		if toDay == nil {
+			to = from
+		}
+	}
+	switch {
+	case to.Before(from):
+		http.Error(w, "to must not be before from", http.StatusBadRequest)
+		return
+	case !to.Before(today):
+		http.Error(w, "only days that have ended can be balanced", http.StatusBadRequest)
+		return
+	case to.Sub(from) >= maxPumpSyncDays*24*time.Hour:
+		http.Error(w, fmt.Sprintf("at most %d days can be balanced at once", maxPumpSyncDays), http.StatusBadRequest)
+		return
+	}
+
+	query := middleware.ScopedDB(r).Model(&models.WaterZone{}).Where("business_vertical_id = ? AND is_active = ?", businessID, true)
+	if siteIDs, restricted := middleware.GetSiteScope(r); restricted {
+		query = query.Where("site_id IN ?", siteIDs)
+	}
+	if id, ok := parseUUIDQuery(r, "zone_id"); ok {
+		query = query.Where("id = ?", id)
+	}
+	zoneIDs := []uuid.UUID{}
+	if err := query.Pluck("id", &zoneIDs).Error; err != nil {
+		http.Error(w, "failed to load zones", http.StatusInternalServerError)
+		return
+	}
+	alerts := 0
+	if len(zoneIDs) > 0 {
+		if alerts, err = nrw.NewEstimator(middleware.ScopedDB(r)).EstimateDays(zoneIDs, from, to); err != nil {
+			http.Error(w, "failed to compute losses", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": fmt.Sprintf("%d zones balanced", len(zoneIDs)),
+		"from":    from.Format("2006-01-02"),
+		"to":      to.Format("2006-01-02"),
+		"zones":   len(zoneIDs),
+		"alerts":  alerts,
+	})
+}
+
+// waterZoneLossTrend is one zone's losses over a period
+type waterZoneLossTrend struct {
+	Zone    models.WaterZone             `json:"zone"`
+	Trend   []models.WaterLossTrendPoint `json:"trend"`
+	Overall models.WaterLossTrendPoint   `json:"overall"`
+}
+
+// GetWaterLossTrends returns each zone's supply, consumption and loss per day, or with
+// ?granularity=month per month, and over the period, with the days its loss exceeded its
+// threshold. ?site_id= and ?zone_id= narrow the zones; ?from=/?to= (YYYY-MM-DD) set the
+// period, by default the last 90 days. format=csv downloads the trends.
+// GET /api/v1/business/{businessCode}/water/nrw/losses
+func GetWaterLossTrends(w http.ResponseWriter, r *http.Request) {
+	businessID, err := assetBusinessID(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load losses")
+		return
+	}
+	q := r.URL.Query()
+	monthly := false
+	switch q.Get("granularity") {
+	case "", "day":
+	case "month":
+		monthly = true
+	default:
+		http.Error(w, "granularity must be day or month", http.StatusBadRequest)
+		return
+	}
+	from, to, err := pumpReportPeriod(r, 90)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load losses")
+		return
+	}
+
+	zoneQuery := middleware.ScopedDB(r).Preload("Site").Where("business_vertical_id = ?", businessID)
+	if siteIDs, restricted := middleware.GetSiteScope(r); restricted {
+		zoneQuery = zoneQuery.Where("site_id IN ?", siteIDs)
+	}
+	if id, ok := parseUUIDQuery(r, "site_id"); ok {
+		zoneQuery = zoneQuery.Where("site_id = ?", id)
+	}
+	if id, ok := parseUUIDQuery(r, "zone_id"); ok {
+		zoneQuery = zoneQuery.Where("id = ?", id)
+	}
+	var zones []models.WaterZone
+	if err := zoneQuery.Order("code").Find(&zones).Error; err != nil {
+		http.Error(w, "failed to load zones", http.StatusInternalServerError)
+		return
+	}
+	zoneIDs := make([]uuid.UUID, 0, len(zones))
+	for _, z := range zones {
+		zoneIDs = append(zoneIDs, z.ID)
+	}
+	var losses []models.WaterZoneLoss
+	if len(zoneIDs) > 0 {
+		if err := middleware.ScopedDB(r).Where("zone_id IN ? AND loss_date BETWEEN ? AND ?", zoneIDs, from, to).
+			Order("loss_date").Find(&losses).Error; err != nil {
+			http.Error(w, "failed to load losses", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	byZone := map[uuid.UUID][]models.WaterZoneLoss{}
+	for _, l := range losses {
+		byZone[l.ZoneID] = append(byZone[l.ZoneID], l)
+	}
+	items := make([]waterZoneLossTrend, 0, len(zones))
+	for _, zone := range zones {
+		var periods []string
+		byPeriod := map[string][]models.WaterZoneLoss{}
+		for _, l := range byZone[zone.ID] {
+			period := l.LossDate.Format("2006-01-02")
+			if monthly {
+				period = period[:7]
+			}
+			if _, ok := byPeriod[period]; !ok {
+				periods = append(periods, period)
+			}
+			byPeriod[period] = append(byPeriod[period], l)
+		}
+		sort.Strings(periods)
+		item := waterZoneLossTrend{Zone: zone, Trend: make([]models.WaterLossTrendPoint, 0, len(periods)), Overall: models.SummarizeWaterLoss("", byZone[zone.ID])}
+		for _, period := range periods {
+			item.Trend = append(item.Trend, models.SummarizeWaterLoss(period, byPeriod[period]))
+		}
+		items = append(items, item)
+	}
+
+	if q.Get("format") == "csv" {
+		var buf bytes.Buffer
+		writer := csv.NewWriter(&buf)
+		_ = writer.Write([]string{"Zone", "Name", "Period", "Days", "Supply m3", "Consumption m3", "Loss m3", "Loss %", "Days Exceeded", "Incomplete Days"})
+		format := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+		for _, item := range items {
+			for _, p := range item.Trend {
+				lossPct := ""
+				if p.LossPct != nil {
+					lossPct = format(*p.LossPct)
+				}
+				_ = writer.Write([]string{
+					item.Zone.Code, item.Zone.Name, p.Period, strconv.Itoa(p.Days), format(p.SupplyM3), format(p.ConsumptionM3),
+					format(p.LossM3), lossPct, strconv.Itoa(p.DaysExceeded), strconv.Itoa(p.IncompleteDays),
+				})
+			}
+		}
+		writer.Flush()
+
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="water-losses-%s-%s.csv"`,
+			from.Format("2006-01-02"), to.Format("2006-01-02")))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(buf.Bytes())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"from":    from.Format("2006-01-02"),
+		"to":      to.Format("2006-01-02"),
+		"items":   items,
+		"count":   len(items),
+		"overall": models.SummarizeWaterLoss("", losses),
+	})
+}
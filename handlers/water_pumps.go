@@ -0,0 +1,694 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// maxPumpSyncDays is the most days of device readings one sync turns into logs
+const maxPumpSyncDays = 31
+
+// loadWaterPump loads the configuration of the pump asset in the request, with the asset
+func loadWaterPump(db *gorm.DB, r *http.Request, businessID uuid.UUID) (*models.WaterPump, error) {
+	asset, err := loadAsset(db, r, "assetId", businessID)
+	if err != nil {
+		return nil, err
+	}
+	var pump models.WaterPump
+	if err := db.Where("asset_id = ?", asset.ID).First(&pump).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apiError{status: http.StatusNotFound, message: "the asset is not configured as a water pump"}
+		}
+		return nil, err
+	}
+	if !middleware.SiteInScope(r, &pump.SiteID) {
+		return nil, apiError{status: http.StatusNotFound, message: "asset not found"}
+	}
+	pump.Asset = asset
+	return &pump, nil
+}
+
+// checkSiteDevice returns an apiError unless the device is of the type and reports from
+// the site
+func checkSiteDevice(businessID, siteID uuid.UUID, deviceID *uuid.UUID, deviceType models.SensorDeviceType, field string) error {
+	if deviceID == nil {
+		return nil
+	}
+	var device models.SensorDevice
+	if err := config.DB.Where("business_vertical_id = ?", businessID).First(&device, "id = ?", *deviceID).Error; err != nil {
+		return apiError{status: http.StatusBadRequest, message: field + " not found in this business"}
+	}
+	if device.DeviceType != deviceType {
+		return apiError{status: http.StatusBadRequest, message: fmt.Sprintf("%s must be a %s device", field, deviceType)}
+	}
+	if device.SiteID != nil && *device.SiteID != siteID {
+		return apiError{status: http.StatusBadRequest, message: field + " is at another site"}
+	}
+	return nil
+}
+
+// ListWaterPumps lists the configured water pumps with their assets. ?site_id= narrows
+// them.
+// GET /api/v1/business/{businessCode}/water/pumps
+func ListWaterPumps(w http.ResponseWriter, r *http.Request) {
+	businessID, err := assetBusinessID(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load pumps")
+		return
+	}
+	query := middleware.ScopedDB(r).Preload("Asset").Where("business_vertical_id = ?", businessID)
+	if siteIDs, restricted := middleware.GetSiteScope(r); restricted {
+		query = query.Where("site_id IN ?", siteIDs)
+	}
+	if id, ok := parseUUIDQuery(r, "site_id"); ok {
+		query = query.Where("site_id = ?", id)
+	}
+	var items []models.WaterPump
+	if err := query.Order("created_at").Find(&items).Error; err != nil {
+		http.Error(w, "failed to load pumps", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// SaveWaterPump configures a water asset at a site as a pump: the devices reporting its
+// running state, energy and flow, its ratings and the daily windows it is scheduled to run
+// in. It replaces any earlier configuration.
+// PUT /api/v1/business/{businessCode}/water/pumps/{assetId}
+func SaveWaterPump(w http.ResponseWriter, r *http.Request) {
+	businessID, err := assetBusinessID(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to save pump")
+		return
+	}
+	db := middleware.ScopedDB(r)
+	asset, err := loadAsset(db, r, "assetId", businessID)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to save pump")
+		return
+	}
+	if asset.Domain != models.AssetDomainWater || asset.SiteID == nil {
+		http.Error(w, "only a water asset placed at a site can be configured as a pump", http.StatusBadRequest)
+		return
+	}
+	if !middleware.SiteInScope(r, asset.SiteID) {
+		http.Error(w, "asset not found", http.StatusNotFound)
+		return
+	}
+	var req struct {
+		PumpDeviceID        *uuid.UUID `json:"pump_device_id"`
+		EnergyMeterDeviceID *uuid.UUID `json:"energy_meter_device_id"`
+		FlowMeterDeviceID   *uuid.UUID `json:"flow_meter_device_id"`
+		RatedPowerKW        float64    `json:"rated_power_kw"`
+		RatedFlowM3H        float64    `json:"rated_flow_m3h"`
+		ScheduleWindows     []string   `json:"schedule_windows"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.RatedPowerKW < 0 || req.RatedFlowM3H < 0 {
+		http.Error(w, "ratings must not be negative", http.StatusBadRequest)
+		return
+	}
+	for _, device := range []struct {
+		id    *uuid.UUID
+		kind  models.SensorDeviceType
+		field string
+	}{
+		{req.PumpDeviceID, models.SensorDevicePump, "pump_device_id"},
+		{req.EnergyMeterDeviceID, models.SensorDeviceEnergyMeter, "energy_meter_device_id"},
+		{req.FlowMeterDeviceID, models.SensorDeviceFlowMeter, "flow_meter_device_id"},
+	} {
+		if err := checkSiteDevice(businessID, *asset.SiteID, device.id, device.kind, device.field); err != nil {
+			writeSubcontractErr(w, err, "failed to save pump")
+			return
+		}
+	}
+
+	pump := models.WaterPump{AssetID: asset.ID}
+	if err := db.Where("asset_id = ?", asset.ID).First(&pump).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "failed to load pump", http.StatusInternalServerError)
+		return
+	}
+	pump.BusinessVerticalID = businessID
+	pump.SiteID = *asset.SiteID
+	pump.PumpDeviceID = req.PumpDeviceID
+	pump.EnergyMeterDeviceID = req.EnergyMeterDeviceID
+	pump.FlowMeterDeviceID = req.FlowMeterDeviceID
+	pump.RatedPowerKW = req.RatedPowerKW
+	pump.RatedFlowM3H = req.RatedFlowM3H
+	pump.ScheduleWindows = models.StringArray{}
+	for _, window := range req.ScheduleWindows {
+		pump.ScheduleWindows = append(pump.ScheduleWindows, strings.TrimSpace(window))
+	}
+	pump.UpdatedBy = middleware.GetClaims(r).UserID
+	if _, err := pump.ScheduledHours(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := db.Save(&pump).Error; err != nil {
+		http.Error(w, "failed to save pump", http.StatusInternalServerError)
+		return
+	}
+	pump.Asset = asset
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "pump saved", "item": pump})
+}
+
+// savePumpLog stores the day's log, replacing the pump's log for the date. A log worked
+// out from devices never replaces one entered by hand.
+func savePumpLog(db *gorm.DB, log *models.PumpDailyLog) error {
+	conflict := clause.OnConflict{
+		Columns: []clause.Column{{Name: "asset_id"}, {Name: "log_date"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"business_vertical_id", "site_id", "run_hours", "energy_kwh", "volume_m3", "source", "remarks", "recorded_by", "updated_at",
+		}),
+	}
+	if log.Source == models.PumpLogDevice {
+		conflict.Where = clause.Where{Exprs: []clause.Expression{
+			clause.Eq{Column: clause.Column{Table: "pump_daily_logs", Name: "source"}, Value: models.PumpLogDevice},
+		}}
+	}
+	return db.Clauses(conflict).Create(log).Error
+}
+
+// LogPumpOperation records by hand how long a pump ran on a day and the energy and water
+// it used, replacing any log for the day
+// POST /api/v1/business/{businessCode}/water/pumps/{assetId}/logs
+func LogPumpOperation(w http.ResponseWriter, r *http.Request) {
+	businessID, err := assetBusinessID(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to log pump operation")
+		return
+	}
+	db := middleware.ScopedDB(r)
+	pump, err := loadWaterPump(db, r, businessID)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to log pump operation")
+		return
+	}
+	var req struct {
+		LogDate   string   `json:"log_date"`
+		RunHours  float64  `json:"run_hours"`
+		EnergyKWh float64  `json:"energy_kwh"`
+		VolumeM3  *float64 `json:"volume_m3"`
+		Remarks   string   `json:"remarks"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	date, err := parseAttendanceDate(req.LogDate)
+	if err != nil {
+		http.Error(w, "log_date: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if date.After(calendarDate(time.Now(), attendanceLocation(nil))) {
+		http.Error(w, "log_date cannot be in the future", http.StatusBadRequest)
+		return
+	}
+
+	entry := models.PumpDailyLog{
+		BusinessVerticalID: businessID,
+		SiteID:             pump.SiteID,
+		AssetID:            pump.AssetID,
+		LogDate:            date,
+		RunHours:           req.RunHours,
+		EnergyKWh:          req.EnergyKWh,
+		VolumeM3:           req.VolumeM3,
+		Source:             models.PumpLogManual,
+		Remarks:            req.Remarks,
+		RecordedBy:         middleware.GetClaims(r).UserID,
+	}
+	if err := entry.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := savePumpLog(db, &entry); err != nil {
+		http.Error(w, "failed to log pump operation", http.StatusInternalServerError)
+		return
+	}
+	db.Where("asset_id = ? AND log_date = ?", entry.AssetID, entry.LogDate).First(&entry)
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "pump operation logged", "item": entry})
+}
+
+// pumpReadings loads a device's readings of the metric over [from, to), led by its last
+// reading before from so that a state or register carries over from the day before
+func pumpReadings(db *gorm.DB, deviceID uuid.UUID, metric string, from, to time.Time) ([]models.PumpReading, error) {
+	var readings []models.PumpReading
+	var previous models.SensorReading
+	err := db.Where("device_id = ? AND metric = ? AND recorded_at < ? AND recorded_at >= ?", deviceID, metric, from, from.AddDate(0, 0, -1)).
+		Order("recorded_at DESC").First(&previous).Error
+	switch {
+	case err == nil:
+		readings = append(readings, models.PumpReading{At: previous.RecordedAt, Value: previous.Value})
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	}
+	var day []models.SensorReading
+	if err := db.Where("device_id = ? AND metric = ? AND recorded_at >= ? AND recorded_at < ?", deviceID, metric, from, to).
+		Order("recorded_at").Find(&day).Error; err != nil {
+		return nil, err
+	}
+	for _, reading := range day {
+		readings = append(readings, models.PumpReading{At: reading.RecordedAt, Value: reading.Value})
+	}
+	return readings, nil
+}
+
+// devicePumpLog works out the pump's log for the date from its devices' readings, or
+// returns nil when its pump device reported nothing that day
+func devicePumpLog(db *gorm.DB, pump *models.WaterPump, device *models.SensorDevice, date time.Time, loc *time.Location, recordedBy string) (*models.PumpDailyLog, error) {
+	from := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+	to := from.AddDate(0, 0, 1)
+	running, err := pumpReadings(db, device.ID, "running", from, to)
+	if err != nil {
+		return nil, err
+	}
+	if len(running) == 0 || running[len(running)-1].At.Before(from) {
+		return nil, nil
+	}
+
+	entry := &models.PumpDailyLog{
+		BusinessVerticalID: pump.BusinessVerticalID,
+		SiteID:             pump.SiteID,
+		AssetID:            pump.AssetID,
+		LogDate:            date,
+		RunHours:           models.PumpRunHours(running, from, to, 2*device.HeartbeatInterval()),
+		Source:             models.PumpLogDevice,
+		RecordedBy:         recordedBy,
+	}
+	if pump.EnergyMeterDeviceID != nil {
+		energy, err := pumpReadings(db, *pump.EnergyMeterDeviceID, "energy", from, to)
+		if err != nil {
+			return nil, err
+		}
+		entry.EnergyKWh = models.RegisterDelta(energy)
+	}
+	if pump.FlowMeterDeviceID != nil {
+		volume, err := pumpReadings(db, *pump.FlowMeterDeviceID, "total_volume", from, to)
+		if err != nil {
+			return nil, err
+		}
+		if len(volume) > 1 {
+			v := models.RegisterDelta(volume)
+			entry.VolumeM3 = &v
+		}
+	}
+	return entry, nil
+}
+
+// SyncPumpLogs works out the pump's daily logs from its devices' readings for the days
+// from from to to (YYYY-MM-DD, by default yesterday), without replacing logs entered by
+// hand. Run hours come from the pump device's running state; energy and volume from how
+// far the energy and flow meters' registers advanced.
+// POST /api/v1/business/{businessCode}/water/pumps/{assetId}/logs/sync
+func SyncPumpLogs(w http.ResponseWriter, r *http.Request) {
+	businessID, err := assetBusinessID(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to sync pump logs")
+		return
+	}
+	db := middleware.ScopedDB(r)
+	pump, err := loadWaterPump(db, r, businessID)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to sync pump logs")
+		return
+	}
+	if pump.PumpDeviceID == nil {
+		http.Error(w, "the pump has no pump device to work out its run hours from", http.StatusBadRequest)
+		return
+	}
+	var device models.SensorDevice
+	if err := db.First(&device, "id = ?", *pump.PumpDeviceID).Error; err != nil {
+		http.Error(w, "failed to load pump device", http.StatusInternalServerError)
+		return
+	}
+	var req struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	loc := attendanceLocation(nil)
+	today := calendarDate(time.Now(), loc)
+	from, to := today.AddDate(0, 0, -1), today.AddDate(0, 0, -1)
+	for _, field := range []struct {
+		value string
+		dst   *time.Time
+		name  string
+	}{{req.From, &from, "from"}, {req.To, &to, "to"}} {
+		if field.value == "" {
+			continue
+		}
+		date, err := parseAttendanceDate(field.value)
+		if err != nil {
+			http.Error(w, field.name+": "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		*field.dst = date
+	}
+	if req.To == "" && req.From != "" {
+		to = from
+	}
+	switch {
+	case to.Before(from):
+		http.Error(w, "to must not be before from", http.StatusBadRequest)
+		return
+	case !to.Before(today):
+		http.Error(w, "only days that have ended can be synced", http.StatusBadRequest)
+		return
+	case to.Sub(from) >= maxPumpSyncDays*24*time.Hour:
+		http.Error(w, fmt.Sprintf("at most %d days can be synced at once", maxPumpSyncDays), http.StatusBadRequest)
+		return
+	}
+
+	userID := middleware.GetClaims(r).UserID
+	var noData []string
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		entry, err := devicePumpLog(db, pump, &device, date, loc, userID)
+		if err != nil {
+			http.Error(w, "failed to load device readings", http.StatusInternalServerError)
+			return
+		}
+		if entry == nil {
+			noData = append(noData, date.Format("2006-01-02"))
+			continue
+		}
+		if err := savePumpLog(db, entry); err != nil {
+			http.Error(w, "failed to save pump log", http.StatusInternalServerError)
+			return
+		}
+	}
+	var items []models.PumpDailyLog
+	if err := db.Where("asset_id = ? AND log_date BETWEEN ? AND ?", pump.AssetID, from, to).Order("log_date").Find(&items).Error; err != nil {
+		http.Error(w, "failed to load pump logs", http.StatusInternalServerError)
+		return
+	}
+	synced := 0
+	for _, item := range items {
+		if item.Source == models.PumpLogDevice {
+			synced++
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message":      fmt.Sprintf("%d days synced from devices", synced),
+		"items":        items,
+		"no_data_days": noData,
+	})
+}
+
+// pumpLogQuery returns the business's pumps and their logs over the days, narrowed by
+// ?site_id= and ?asset_id= and the user's site scope
+func pumpLogQuery(r *http.Request, businessID uuid.UUID, from, to time.Time) ([]models.WaterPump, []models.PumpDailyLog, error) {
+	pumpQuery := middleware.ScopedDB(r).Preload("Asset").Where("business_vertical_id = ?", businessID)
+	if siteIDs, restricted := middleware.GetSiteScope(r); restricted {
+		pumpQuery = pumpQuery.Where("site_id IN ?", siteIDs)
+	}
+	for _, key := range []string{"site_id", "asset_id"} {
+		if id, ok := parseUUIDQuery(r, key); ok {
+			pumpQuery = pumpQuery.Where(key+" = ?", id)
+		}
+	}
+	var pumps []models.WaterPump
+	if err := pumpQuery.Order("created_at").Find(&pumps).Error; err != nil {
+		return nil, nil, err
+	}
+	if len(pumps) == 0 {
+		return pumps, nil, nil
+	}
+	assetIDs := make([]uuid.UUID, 0, len(pumps))
+	for _, p := range pumps {
+		assetIDs = append(assetIDs, p.AssetID)
+	}
+	var logs []models.PumpDailyLog
+	if err := middleware.ScopedDB(r).Where("asset_id IN ? AND log_date BETWEEN ? AND ?", assetIDs, from, to).
+		Order("log_date").Find(&logs).Error; err != nil {
+		return nil, nil, err
+	}
+	return pumps, logs, nil
+}
+
+// pumpReportPeriod reads ?from= and ?to= (YYYY-MM-DD) as a range of days ending by default
+// today and starting by default days before the end
+func pumpReportPeriod(r *http.Request, days int) (time.Time, time.Time, error) {
+	fromDay, toDay, err := vendorReportPeriod(r)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	to := calendarDate(time.Now(), attendanceLocation(nil))
+	if toDay != nil {
+		to = *toDay
+	}
+	from := to.AddDate(0, 0, 1-days)
+	if fromDay != nil {
+		from = *fromDay
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, apiError{status: http.StatusBadRequest, message: "to must not be before from"}
+	}
+	if to.Sub(from) > 366*24*time.Hour {
+		return time.Time{}, time.Time{}, apiError{status: http.StatusBadRequest, message: "the period must not exceed 366 days"}
+	}
+	return from, to, nil
+}
+
+// pumpScheduleRow compares a day's log with the pump's schedule
+type pumpScheduleRow struct {
+	models.PumpDailyLog
+	ScheduledHours float64 `json:"scheduled_hours"`
+	DeviationHours float64 `json:"deviation_hours"` // run hours over (+) or under (-) the schedule
+}
+
+// pumpScheduleSummary compares a pump's logs over a period with its schedule
+type pumpScheduleSummary struct {
+	AssetID          uuid.UUID `json:"asset_id"`
+	AssetTag         string    `json:"asset_tag"`
+	Name             string    `json:"name"`
+	SiteID           uuid.UUID `json:"site_id"`
+	ScheduledPerDay  float64   `json:"scheduled_hours_per_day"`
+	DaysLogged       int       `json:"days_logged"`
+	RunHours         float64   `json:"run_hours"`
+	ScheduledHours   float64   `json:"scheduled_hours"` // over the days logged
+	DeviationHours   float64   `json:"deviation_hours"`
+	AdherencePercent *float64  `json:"adherence_percent,omitempty"` // run hours over scheduled hours
+	EnergyKWh        float64   `json:"energy_kwh"`
+}
+
+// ListPumpLogs lists the pumps' daily logs with the hours each was scheduled to run that
+// day, and per pump the run hours against the schedule over the days logged. Schedules
+// are the pumps' current ones. ?site_id= and ?asset_id= narrow the pumps; ?from=/?to=
+// (YYYY-MM-DD) set the period, by default the last 30 days.
+// GET /api/v1/business/{businessCode}/water/pumps/logs
+func ListPumpLogs(w http.ResponseWriter, r *http.Request) {
+	businessID, err := assetBusinessID(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load pump logs")
+		return
+	}
+	from, to, err := pumpReportPeriod(r, 30)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load pump logs")
+		return
+	}
+	pumps, logs, err := pumpLogQuery(r, businessID, from, to)
+	if err != nil {
+		http.Error(w, "failed to load pump logs", http.StatusInternalServerError)
+		return
+	}
+
+	summaries := make([]pumpScheduleSummary, 0, len(pumps))
+	byAsset := make(map[uuid.UUID]int, len(pumps))
+	for _, p := range pumps {
+		scheduled, _ := p.ScheduledHours()
+		summary := pumpScheduleSummary{AssetID: p.AssetID, SiteID: p.SiteID, ScheduledPerDay: scheduled}
+		if p.Asset != nil {
+			summary.AssetTag, summary.Name = p.Asset.AssetTag, p.Asset.Name
+		}
+		byAsset[p.AssetID] = len(summaries)
+		summaries = append(summaries, summary)
+	}
+	items := make([]pumpScheduleRow, 0, len(logs))
+	for _, l := range logs {
+		summary := &summaries[byAsset[l.AssetID]]
+		items = append(items, pumpScheduleRow{
+			PumpDailyLog:   l,
+			ScheduledHours: summary.ScheduledPerDay,
+			DeviationHours: models.Round2(l.RunHours - summary.ScheduledPerDay),
+		})
+		summary.DaysLogged++
+		summary.RunHours += l.RunHours
+		summary.ScheduledHours += summary.ScheduledPerDay
+		summary.EnergyKWh += l.EnergyKWh
+	}
+	for i := range summaries {
+		s := &summaries[i]
+		s.RunHours, s.ScheduledHours, s.EnergyKWh = models.Round2(s.RunHours), models.Round2(s.ScheduledHours), models.Round2(s.EnergyKWh)
+		s.DeviationHours = models.Round2(s.RunHours - s.ScheduledHours)
+		if s.ScheduledHours > 0 {
+			v := models.Round2(s.RunHours / s.ScheduledHours * 100)
+			s.AdherencePercent = &v
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"from":  from.Format("2006-01-02"),
+		"to":    to.Format("2006-01-02"),
+		"items": items,
+		"count": len(items),
+		"pumps": summaries,
+	})
+}
+
+// pumpEfficiencyTrend is one pump's efficiency over a period
+type pumpEfficiencyTrend struct {
+	AssetID              uuid.UUID                    `json:"asset_id"`
+	AssetTag             string                       `json:"asset_tag"`
+	Name                 string                       `json:"name"`
+	SiteID               uuid.UUID                    `json:"site_id"`
+	RatedPowerKW         float64                      `json:"rated_power_kw"`
+	Trend                []models.PumpEfficiencyPoint `json:"trend"`
+	SpecificEnergyChange *float64                     `json:"specific_energy_change_percent,omitempty"`
+	Degrading            bool                         `json:"degrading"`
+}
+
+// GetPumpEfficiencyTrends returns each pump's run hours, energy, volume, average power,
+// flow and specific energy (kWh/m3) per week, or with ?granularity=month per month, and
+// flags the pumps whose specific energy has risen by more than ?threshold= percent (10 by
+// default) over the period as degrading. ?site_id= and ?asset_id= narrow the pumps and
+// ?degrading=true keeps only the degrading ones; ?from=/?to= (YYYY-MM-DD) set the period,
+// by default the last 180 days. format=csv downloads the trends.
+// GET /api/v1/business/{businessCode}/water/pumps/efficiency
+func GetPumpEfficiencyTrends(w http.ResponseWriter, r *http.Request) {
+	businessID, err := assetBusinessID(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load pump efficiency")
+		return
+	}
+	q := r.URL.Query()
+	monthly := false
+	switch q.Get("granularity") {
+	case "", "week":
+	case "month":
+		monthly = true
+	default:
+		http.Error(w, "granularity must be week or month", http.StatusBadRequest)
+		return
+	}
+	threshold := models.DefaultPumpDegradationPct
+	if v := q.Get("threshold"); v != "" {
+		if threshold, err = strconv.ParseFloat(v, 64); err != nil || threshold <= 0 {
+			http.Error(w, "threshold must be a positive percentage", http.StatusBadRequest)
+			return
+		}
+	}
+	from, to, err := pumpReportPeriod(r, 180)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load pump efficiency")
+		return
+	}
+	pumps, logs, err := pumpLogQuery(r, businessID, from, to)
+	if err != nil {
+		http.Error(w, "failed to load pump logs", http.StatusInternalServerError)
+		return
+	}
+
+	logsByAsset := map[uuid.UUID][]models.PumpDailyLog{}
+	for _, l := range logs {
+		logsByAsset[l.AssetID] = append(logsByAsset[l.AssetID], l)
+	}
+	onlyDegrading := parseBoolQuery(q.Get("degrading"))
+	items := make([]pumpEfficiencyTrend, 0, len(pumps))
+	for _, p := range pumps {
+		var periods []string
+		points := map[string]*models.PumpEfficiencyPoint{}
+		for _, l := range logsByAsset[p.AssetID] {
+			period := l.LogDate.Format("2006-01")
+			if !monthly {
+				// weeks start on Monday
+				period = l.LogDate.AddDate(0, 0, -(int(l.LogDate.Weekday())+6)%7).Format("2006-01-02")
+			}
+			if points[period] == nil {
+				points[period] = &models.PumpEfficiencyPoint{Period: period}
+				periods = append(periods, period)
+			}
+			points[period].Add(l)
+		}
+		sort.Strings(periods)
+		item := pumpEfficiencyTrend{AssetID: p.AssetID, SiteID: p.SiteID, RatedPowerKW: p.RatedPowerKW, Trend: []models.PumpEfficiencyPoint{}}
+		if p.Asset != nil {
+			item.AssetTag, item.Name = p.Asset.AssetTag, p.Asset.Name
+		}
+		for _, period := range periods {
+			points[period].Finish(p.RatedPowerKW)
+			item.Trend = append(item.Trend, *points[period])
+		}
+		item.SpecificEnergyChange, item.Degrading = models.PumpDegradation(item.Trend, threshold)
+		if onlyDegrading && !item.Degrading {
+			continue
+		}
+		items = append(items, item)
+	}
+
+	if q.Get("format") == "csv" {
+		var buf bytes.Buffer
+		writer := csv.NewWriter(&buf)
+		_ = writer.Write([]string{"Asset Tag", "Pump", "Period", "Run Hours", "Energy kWh", "Volume m3",
+			"Average kW", "Flow m3/h", "Specific Energy kWh/m3", "Load %", "Degrading"})
+		format := func(v *float64) string {
+			if v == nil {
+				return ""
+			}
+			return strconv.FormatFloat(*v, 'f', -1, 64)
+		}
+		for _, item := range items {
+			for _, p := range item.Trend {
+				_ = writer.Write([]string{
+					item.AssetTag, item.Name, p.Period, format(&p.RunHours), format(&p.EnergyKWh), format(&p.VolumeM3),
+					format(p.AveragePowerKW), format(p.FlowM3H), format(p.SpecificEnergy), format(p.LoadPct),
+					strconv.FormatBool(item.Degrading),
+				})
+			}
+		}
+		writer.Flush()
+
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="pump-efficiency-%s-%s.csv"`,
+			from.Format("2006-01-02"), to.Format("2006-01-02")))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(buf.Bytes())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"from":              from.Format("2006-01-02"),
+		"to":                to.Format("2006-01-02"),
+		"threshold_percent": threshold,
+		"items":             items,
+		"count":             len(items),
+	})
+}
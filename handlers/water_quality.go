@@ -0,0 +1,628 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+const waterQualityPermission = "water:quality_control"
+
+// sitePermissionHoldersSQL lists the users holding a permission in a vertical through a
+// role that covers the whole vertical or the given site
+const sitePermissionHoldersSQL = `SELECT DISTINCT ubr.user_id
+	FROM user_business_roles ubr
+	JOIN business_roles br ON br.id = ubr.business_role_id
+	JOIN business_role_permissions brp ON brp.business_role_id = br.id
+	JOIN permissions p ON p.id = brp.permission_id
+	WHERE br.business_vertical_id = ? AND br.is_active AND ubr.is_active
+	AND (ubr.valid_from IS NULL OR ubr.valid_from <= NOW())
+	AND (ubr.valid_until IS NULL OR ubr.valid_until > NOW())
+	AND (ubr.site_id IS NULL OR ubr.site_id = ?)
+	AND p.name = ?`
+
+// waterParameterRequest is the body of parameter create and update requests
+type waterParameterRequest struct {
+	Code     string   `json:"code"`
+	Name     string   `json:"name"`
+	Category string   `json:"category"`
+	Unit     string   `json:"unit"`
+	MinLimit *float64 `json:"min_limit"`
+	MaxLimit *float64 `json:"max_limit"`
+	IsActive *bool    `json:"is_active"`
+}
+
+// ListWaterQualityParameters lists the business's test parameters with their limits.
+// ?active=true leaves out retired ones.
+// GET /api/v1/business/{businessCode}/water/quality/parameters
+func ListWaterQualityParameters(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load parameters")
+		return
+	}
+	query := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID)
+	if parseBoolQuery(r.URL.Query().Get("active")) {
+		query = query.Where("is_active = ?", true)
+	}
+	var items []models.WaterQualityParameter
+	if err := query.Order("category, code").Find(&items).Error; err != nil {
+		http.Error(w, "failed to load parameters", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// CreateWaterQualityParameter adds a test parameter with its limits
+// POST /api/v1/business/{businessCode}/water/quality/parameters
+func CreateWaterQualityParameter(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to create parameter")
+		return
+	}
+	var req waterParameterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	parameter := models.WaterQualityParameter{
+		BusinessVerticalID: businessID,
+		Code:               strings.ToLower(strings.TrimSpace(req.Code)),
+		Name:               strings.TrimSpace(req.Name),
+		Category:           req.Category,
+		Unit:               strings.TrimSpace(req.Unit),
+		MinLimit:           req.MinLimit,
+		MaxLimit:           req.MaxLimit,
+		IsActive:           true,
+		UpdatedBy:          middleware.GetClaims(r).UserID,
+	}
+	if err := parameter.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var count int64
+	middleware.ScopedDB(r).Model(&models.WaterQualityParameter{}).Where("business_vertical_id = ? AND code = ?", businessID, parameter.Code).Count(&count)
+	if count > 0 {
+		http.Error(w, "a parameter with this code already exists", http.StatusConflict)
+		return
+	}
+	if err := middleware.ScopedDB(r).Create(&parameter).Error; err != nil {
+		http.Error(w, "failed to create parameter", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "parameter created", "item": parameter})
+}
+
+// UpdateWaterQualityParameter changes a parameter's name, unit, limits or whether it is in
+// use. Results already recorded keep the limits they were judged by.
+// PUT /api/v1/business/{businessCode}/water/quality/parameters/{id}
+func UpdateWaterQualityParameter(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to update parameter")
+		return
+	}
+	var parameter models.WaterQualityParameter
+	if err := middleware.ScopedDB(r).Where("business_vertical_id = ?", businessID).First(&parameter, "id = ?", mux.Vars(r)["id"]).Error; err != nil {
+		http.Error(w, "parameter not found", http.StatusNotFound)
+		return
+	}
+	var req waterParameterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if v := strings.TrimSpace(req.Name); v != "" {
+		parameter.Name = v
+	}
+	if req.Category != "" {
+		parameter.Category = req.Category
+	}
+	parameter.Unit = strings.TrimSpace(req.Unit)
+	parameter.MinLimit, parameter.MaxLimit = req.MinLimit, req.MaxLimit
+	if req.IsActive != nil {
+		parameter.IsActive = *req.IsActive
+	}
+	parameter.UpdatedBy = middleware.GetClaims(r).UserID
+	if err := parameter.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := middleware.ScopedDB(r).Select("name", "category", "unit", "min_limit", "max_limit", "is_active", "updated_by").
+		Updates(&parameter).Error; err != nil {
+		http.Error(w, "failed to update parameter", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "parameter updated", "item": parameter})
+}
+
+// ListWaterSamplingPoints lists the business's sampling points. ?site_id=, ?zone= and
+// ?point_type= narrow them.
+// GET /api/v1/business/{businessCode}/water/quality/sampling-points
+func ListWaterSamplingPoints(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load sampling points")
+		return
+	}
+	query := middleware.ScopedDB(r).Preload("Site").Where("business_vertical_id = ?", businessID)
+	if siteIDs, restricted := middleware.GetSiteScope(r); restricted {
+		query = query.Where("site_id IN ?", siteIDs)
+	}
+	if id, ok := parseUUIDQuery(r, "site_id"); ok {
+		query = query.Where("site_id = ?", id)
+	}
+	for _, key := range []string{"zone", "point_type"} {
+		if v := r.URL.Query().Get(key); v != "" {
+			query = query.Where(key+" = ?", v)
+		}
+	}
+	var items []models.WaterSamplingPoint
+	if err := query.Order("code").Find(&items).Error; err != nil {
+		http.Error(w, "failed to load sampling points", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// CreateWaterSamplingPoint registers a sampling point at one of the business's sites
+// POST /api/v1/business/{businessCode}/water/quality/sampling-points
+func CreateWaterSamplingPoint(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to create sampling point")
+		return
+	}
+	var req struct {
+		SiteID    uuid.UUID `json:"site_id"`
+		Zone      string    `json:"zone"`
+		Code      string    `json:"code"`
+		Name      string    `json:"name"`
+		PointType string    `json:"point_type"`
+		Latitude  *float64  `json:"latitude"`
+		Longitude *float64  `json:"longitude"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Code, req.Name = strings.TrimSpace(req.Code), strings.TrimSpace(req.Name)
+	if req.Code == "" || req.Name == "" {
+		http.Error(w, "code and name are required", http.StatusBadRequest)
+		return
+	}
+	if !models.ValidWaterPointType(req.PointType) {
+		http.Error(w, "point_type must be source, treatment_plant, reservoir, distribution or consumer_tap", http.StatusBadRequest)
+		return
+	}
+	if err := checkBusinessSite(businessID, &req.SiteID); err != nil || !middleware.SiteInScope(r, &req.SiteID) {
+		http.Error(w, "site not found in this business", http.StatusBadRequest)
+		return
+	}
+	var count int64
+	middleware.ScopedDB(r).Model(&models.WaterSamplingPoint{}).Where("business_vertical_id = ? AND code = ?", businessID, req.Code).Count(&count)
+	if count > 0 {
+		http.Error(w, "a sampling point with this code already exists", http.StatusConflict)
+		return
+	}
+
+	point := models.WaterSamplingPoint{
+		BusinessVerticalID: businessID,
+		SiteID:             req.SiteID,
+		Zone:               strings.TrimSpace(req.Zone),
+		Code:               req.Code,
+		Name:               req.Name,
+		PointType:          req.PointType,
+		Latitude:           req.Latitude,
+		Longitude:          req.Longitude,
+		IsActive:           true,
+		CreatedBy:          middleware.GetClaims(r).UserID,
+	}
+	if err := middleware.ScopedDB(r).Create(&point).Error; err != nil {
+		http.Error(w, "failed to create sampling point", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "sampling point created", "item": point})
+}
+
+// RecordWaterQualityTest records the results of testing a sample, each judged against
+// its parameter's limits. A test with any result out of limits opens a non-conformance
+// and alerts the site's quality control staff.
+// POST /api/v1/business/{businessCode}/water/quality/tests
+func RecordWaterQualityTest(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to record test")
+		return
+	}
+	var req struct {
+		SamplingPointID uuid.UUID  `json:"sampling_point_id"`
+		SampledAt       *time.Time `json:"sampled_at"`
+		SampledBy       string     `json:"sampled_by"`
+		Laboratory      string     `json:"laboratory"`
+		Remarks         string     `json:"remarks"`
+		Results         []struct {
+			Parameter string  `json:"parameter"` // code
+			Value     float64 `json:"value"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Results) == 0 {
+		http.Error(w, "results are required", http.StatusBadRequest)
+		return
+	}
+
+	db := middleware.ScopedDB(r)
+	var point models.WaterSamplingPoint
+	if err := db.Preload("Site").Where("business_vertical_id = ? AND is_active = ?", businessID, true).
+		First(&point, "id = ?", req.SamplingPointID).Error; err != nil || !middleware.SiteInScope(r, &point.SiteID) {
+		http.Error(w, "sampling point not found", http.StatusBadRequest)
+		return
+	}
+	var parameters []models.WaterQualityParameter
+	if err := db.Where("business_vertical_id = ? AND is_active = ?", businessID, true).Find(&parameters).Error; err != nil {
+		http.Error(w, "failed to load parameters", http.StatusInternalServerError)
+		return
+	}
+	byCode := make(map[string]models.WaterQualityParameter, len(parameters))
+	for _, p := range parameters {
+		byCode[p.Code] = p
+	}
+
+	now := time.Now()
+	userID := middleware.GetClaims(r).UserID
+	test := models.WaterQualityTest{
+		BusinessVerticalID: businessID,
+		SiteID:             point.SiteID,
+		SamplingPointID:    point.ID,
+		SampledAt:          now,
+		SampledBy:          strings.TrimSpace(req.SampledBy),
+		Laboratory:         strings.TrimSpace(req.Laboratory),
+		Remarks:            req.Remarks,
+		RecordedBy:         userID,
+	}
+	if test.SampledBy == "" {
+		test.SampledBy = userID
+	}
+	if req.SampledAt != nil {
+		if req.SampledAt.After(now) {
+			http.Error(w, "sampled_at cannot be in the future", http.StatusBadRequest)
+			return
+		}
+		test.SampledAt = *req.SampledAt
+	}
+	seen := map[string]bool{}
+	for i, in := range req.Results {
+		code := strings.ToLower(strings.TrimSpace(in.Parameter))
+		parameter, ok := byCode[code]
+		if !ok {
+			http.Error(w, fmt.Sprintf("results[%d]: %q is not an active parameter", i, in.Parameter), http.StatusBadRequest)
+			return
+		}
+		if seen[code] {
+			http.Error(w, fmt.Sprintf("results[%d]: %s is repeated", i, code), http.StatusBadRequest)
+			return
+		}
+		seen[code] = true
+		result := models.NewWaterQualityResult(parameter, in.Value)
+		if !result.WithinLimits {
+			test.NonConforming = true
+		}
+		test.Results = append(test.Results, result)
+	}
+	if test.NonConforming {
+		test.NonConformanceStatus = models.WaterNonConformanceOpen
+	}
+
+	if err := db.Create(&test).Error; err != nil {
+		http.Error(w, "failed to record test", http.StatusInternalServerError)
+		return
+	}
+	if test.NonConforming {
+		notifyWaterNonConformance(&test, &point, byCode)
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"message": "test recorded", "item": test})
+}
+
+// notifyWaterNonConformance alerts the holders of water:quality_control at the test's site
+// of the results out of limits
+func notifyWaterNonConformance(test *models.WaterQualityTest, point *models.WaterSamplingPoint, parameters map[string]models.WaterQualityParameter) {
+	var userIDs []string
+	if err := config.DB.Raw(sitePermissionHoldersSQL, test.BusinessVerticalID, test.SiteID, waterQualityPermission).
+		Scan(&userIDs).Error; err != nil {
+		log.Printf("Error loading quality control staff of site %s: %v", test.SiteID, err)
+		return
+	}
+
+	var exceeded []string
+	priority := models.NotificationPriorityHigh
+	for _, result := range test.Results {
+		if result.WithinLimits {
+			continue
+		}
+		parameter := parameters[result.ParameterCode]
+		exceeded = append(exceeded, fmt.Sprintf("%s %s (limit %s)",
+			result.ParameterName, strconv.FormatFloat(result.Value, 'f', -1, 64), parameter.Limits()))
+		if parameter.Category == models.WaterParameterBacteriological {
+			priority = models.NotificationPriorityCritical
+		}
+	}
+	where := point.Name
+	if point.Site != nil {
+		where += ", " + point.Site.Name
+	}
+	now := time.Now()
+	for _, userID := range userIDs {
+		notification := &models.Notification{
+			UserID:             userID,
+			Type:               models.NotificationTypeSystemAlert,
+			Priority:           priority,
+			Title:              "Water quality non-conformance",
+			Body:               fmt.Sprintf("Sample from %s on %s: %s.", where, test.SampledAt.Format("02 Jan 2006 15:04"), strings.Join(exceeded, "; ")),
+			ActionURL:          fmt.Sprintf("/water/quality/tests/%s", test.ID),
+			BusinessVerticalID: &test.BusinessVerticalID,
+			Status:             models.NotificationStatusSent,
+			Channel:            models.NotificationChannelInApp,
+			SentAt:             &now,
+			Metadata: models.JSONMap{
+				"water_quality_test_id": test.ID.String(),
+				"sampling_point_id":     point.ID.String(),
+			},
+		}
+		if err := config.DB.Create(notification).Error; err != nil {
+			log.Printf("Error notifying %s of water quality test %s: %v", userID, test.ID, err)
+		}
+	}
+}
+
+// ListWaterQualityTests lists tests with their results, latest sample first. ?site_id=,
+// ?sampling_point_id=, ?non_conforming=true, ?nc_status= and ?from=/?to= (YYYY-MM-DD)
+// narrow them.
+// GET /api/v1/business/{businessCode}/water/quality/tests
+func ListWaterQualityTests(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load tests")
+		return
+	}
+	fromDay, toDay, err := vendorReportPeriod(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load tests")
+		return
+	}
+
+	query := middleware.ScopedDB(r).Preload("SamplingPoint").Preload("Results").Where("business_vertical_id = ?", businessID)
+	if siteIDs, restricted := middleware.GetSiteScope(r); restricted {
+		query = query.Where("site_id IN ?", siteIDs)
+	}
+	for _, key := range []string{"site_id", "sampling_point_id"} {
+		if id, ok := parseUUIDQuery(r, key); ok {
+			query = query.Where(key+" = ?", id)
+		}
+	}
+	q := r.URL.Query()
+	if parseBoolQuery(q.Get("non_conforming")) {
+		query = query.Where("non_conforming = ?", true)
+	}
+	if v := q.Get("nc_status"); v != "" {
+		query = query.Where("non_conformance_status = ?", v)
+	}
+	if fromDay != nil {
+		query = query.Where("sampled_at >= ?", *fromDay)
+	}
+	if toDay != nil {
+		query = query.Where("sampled_at < ?", toDay.AddDate(0, 0, 1))
+	}
+	var items []models.WaterQualityTest
+	if err := query.Order("sampled_at DESC").Limit(500).Find(&items).Error; err != nil {
+		http.Error(w, "failed to load tests", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+// CloseWaterNonConformance closes a test's open non-conformance with the corrective
+// action taken
+// POST /api/v1/business/{businessCode}/water/quality/tests/{id}/close
+func CloseWaterNonConformance(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to close non-conformance")
+		return
+	}
+	var req struct {
+		CorrectiveAction string `json:"corrective_action"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.CorrectiveAction) == "" {
+		http.Error(w, "corrective_action is required", http.StatusBadRequest)
+		return
+	}
+
+	db := middleware.ScopedDB(r)
+	var test models.WaterQualityTest
+	if err := db.Where("business_vertical_id = ?", businessID).First(&test, "id = ?", mux.Vars(r)["id"]).Error; err != nil ||
+		!middleware.SiteInScope(r, &test.SiteID) {
+		http.Error(w, "test not found", http.StatusNotFound)
+		return
+	}
+	now := time.Now()
+	result := db.Model(&models.WaterQualityTest{}).
+		Where("id = ? AND non_conformance_status = ?", test.ID, models.WaterNonConformanceOpen).
+		Updates(map[string]interface{}{
+			"non_conformance_status": models.WaterNonConformanceClosed,
+			"corrective_action":      strings.TrimSpace(req.CorrectiveAction),
+			"closed_at":              now,
+			"closed_by":              middleware.GetClaims(r).UserID,
+		})
+	if result.Error != nil {
+		http.Error(w, "failed to close non-conformance", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "the test has no open non-conformance", http.StatusConflict)
+		return
+	}
+	db.Preload("Results").First(&test, "id = ?", test.ID)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "non-conformance closed", "item": test})
+}
+
+// GetWaterQualityTrends returns one parameter's results per day, or with
+// ?granularity=month per month, with their range, average and compliance with the limits,
+// for regulators. ?parameter= (code) is required; ?site_id=, ?sampling_point_id= and
+// ?zone= narrow the samples; ?from=/?to= (YYYY-MM-DD) set the period, by default the last
+// 90 days. format=csv downloads the trend.
+// GET /api/v1/business/{businessCode}/water/quality/trends
+func GetWaterQualityTrends(w http.ResponseWriter, r *http.Request) {
+	businessID, err := procurementBusinessID(r)
+	if err != nil {
+		writeProcurementErr(w, err, "failed to load trends")
+		return
+	}
+	q := r.URL.Query()
+	code := strings.ToLower(strings.TrimSpace(q.Get("parameter")))
+	var parameter models.WaterQualityParameter
+	if err := middleware.ScopedDB(r).Where("business_vertical_id = ? AND code = ?", businessID, code).First(&parameter).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "parameter not found", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "failed to load parameter", http.StatusInternalServerError)
+		return
+	}
+	monthly := false
+	switch q.Get("granularity") {
+	case "", "day":
+	case "month":
+		monthly = true
+	default:
+		http.Error(w, "granularity must be day or month", http.StatusBadRequest)
+		return
+	}
+	fromDay, toDay, err := vendorReportPeriod(r)
+	if err != nil {
+		writeSubcontractErr(w, err, "failed to load trends")
+		return
+	}
+	loc := attendanceLocation(nil)
+	to := calendarDate(time.Now(), loc).AddDate(0, 0, 1)
+	if toDay != nil {
+		to = toDay.AddDate(0, 0, 1)
+	}
+	from := to.AddDate(0, 0, -90)
+	if fromDay != nil {
+		from = *fromDay
+	}
+	if !to.After(from) {
+		http.Error(w, "to must not be before from", http.StatusBadRequest)
+		return
+	}
+
+	query := middleware.ScopedDB(r).Model(&models.WaterQualityResult{}).
+		Select("water_quality_results.*, water_quality_tests.sampled_at").
+		Joins("JOIN water_quality_tests ON water_quality_tests.id = water_quality_results.test_id").
+		Where("water_quality_tests.business_vertical_id = ? AND water_quality_results.parameter_id = ?", businessID, parameter.ID).
+		Where("water_quality_tests.sampled_at >= ? AND water_quality_tests.sampled_at < ?",
+			time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc), time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, loc))
+	if siteIDs, restricted := middleware.GetSiteScope(r); restricted {
+		query = query.Where("water_quality_tests.site_id IN ?", siteIDs)
+	}
+	for _, key := range []string{"site_id", "sampling_point_id"} {
+		if id, ok := parseUUIDQuery(r, key); ok {
+			query = query.Where("water_quality_tests."+key+" = ?", id)
+		}
+	}
+	if zone := q.Get("zone"); zone != "" {
+		query = query.Where("water_quality_tests.sampling_point_id IN (?)",
+			middleware.ScopedDB(r).Model(&models.WaterSamplingPoint{}).Select("id").Where("business_vertical_id = ? AND zone = ?", businessID, zone))
+	}
+	var rows []struct {
+		models.WaterQualityResult
+		SampledAt time.Time
+	}
+	if err := query.Order("water_quality_tests.sampled_at").Scan(&rows).Error; err != nil {
+		http.Error(w, "failed to load results", http.StatusInternalServerError)
+		return
+	}
+
+	var periods []string
+	byPeriod := map[string][]models.WaterQualityResult{}
+	var all []models.WaterQualityResult
+	for _, row := range rows {
+		period := row.SampledAt.In(loc).Format("2006-01-02")
+		if monthly {
+			period = period[:7]
+		}
+		if _, ok := byPeriod[period]; !ok {
+			periods = append(periods, period)
+		}
+		byPeriod[period] = append(byPeriod[period], row.WaterQualityResult)
+		all = append(all, row.WaterQualityResult)
+	}
+	trend := make([]models.WaterQualityTrendPoint, 0, len(periods))
+	for _, period := range periods {
+		trend = append(trend, models.SummarizeWaterQuality(period, byPeriod[period]))
+	}
+
+	if q.Get("format") == "csv" {
+		var buf bytes.Buffer
+		writer := csv.NewWriter(&buf)
+		_ = writer.Write([]string{"Period", "Parameter", "Unit", "Limits", "Samples", "Min", "Max", "Average", "Exceedances", "Compliance %"})
+		format := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+		for _, p := range trend {
+			_ = writer.Write([]string{
+				p.Period, parameter.Name, parameter.Unit, parameter.Limits(), strconv.Itoa(p.Samples),
+				format(p.Min), format(p.Max), format(p.Average), strconv.Itoa(p.Exceedances), format(p.CompliancePct),
+			})
+		}
+		writer.Flush()
+
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="water-quality-%s-%s-%s.csv"`,
+			parameter.Code, from.Format("2006-01-02"), to.AddDate(0, 0, -1).Format("2006-01-02")))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(buf.Bytes())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"parameter": parameter,
+		"limits":    parameter.Limits(),
+		"from":      from.Format("2006-01-02"),
+		"to":        to.AddDate(0, 0, -1).Format("2006-01-02"),
+		"trend":     trend,
+		"overall":   models.SummarizeWaterQuality("", all),
+	})
+}
@@ -0,0 +1,421 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/hooks"
+	"p9e.in/ugcl/pkg/jobrunner"
+	"p9e.in/ugcl/utils"
+)
+
+const workflowActionWebhookResourceType = "WorkflowTransition"
+
+// workflowActionEvent is the transition as its actions see it. Deferred actions store it
+// in the outbox so they are delivered with the record as it was when the transition fired.
+type workflowActionEvent struct {
+	hooks.WorkflowTransitionEvent
+	TransitionID uuid.UUID              `json:"transition_id"`
+	FormID       uuid.UUID              `json:"form_id"`
+	WorkflowID   uuid.UUID              `json:"workflow_id"`
+	SiteID       *uuid.UUID             `json:"site_id,omitempty"`
+	SubmittedBy  string                 `json:"submitted_by"`
+	FormData     map[string]interface{} `json:"form_data"`
+}
+
+// recordFieldSetter writes one field of the transitioning record inside tx
+type recordFieldSetter func(tx *gorm.DB, field string, value interface{}) error
+
+// applyTransitionActions runs a transition's actions inside its transaction: field updates
+// and task assignments are applied, notifications and webhooks are queued in the outbox.
+// Any error rolls the transition back. It returns the number of queued actions.
+func applyTransitionActions(tx *gorm.DB, actions []models.TransitionAction, event *workflowActionEvent, setField recordFieldSetter) (int, error) {
+	if event.FormData == nil {
+		event.FormData = map[string]interface{}{}
+	}
+
+	queued := 0
+	for i, action := range actions {
+		if err := action.Validate(); err != nil {
+			return 0, fmt.Errorf("transition action %d: %w", i+1, err)
+		}
+
+		var err error
+		switch {
+		case action.IsDeferred():
+			err = queueTransitionAction(tx, action, event)
+			queued++
+		case action.Type == models.TransitionActionSetField:
+			err = applySetFieldAction(tx, action, event, setField)
+		case action.Type == models.TransitionActionAssignTask:
+			err = applyAssignTaskAction(tx, action, event)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("transition action %d (%s): %w", i+1, action.Type, err)
+		}
+	}
+	return queued, nil
+}
+
+func queueTransitionAction(tx *gorm.DB, action models.TransitionAction, event *workflowActionEvent) error {
+	actionJSON, err := json.Marshal(action)
+	if err != nil {
+		return err
+	}
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	entry := models.WorkflowActionOutbox{
+		TransitionID:  event.TransitionID,
+		SubmissionID:  event.SubmissionID,
+		ActionType:    action.Type,
+		Action:        actionJSON,
+		Event:         eventJSON,
+		Status:        models.WorkflowActionPending,
+		MaxAttempts:   utils.DefaultWebhookConfig().DefaultMaxRetries,
+		NextAttemptAt: time.Now(),
+	}
+	return tx.Create(&entry).Error
+}
+
+func applySetFieldAction(tx *gorm.DB, action models.TransitionAction, event *workflowActionEvent, setField recordFieldSetter) error {
+	value := action.Value
+	if action.ValueField != "" {
+		fieldValue, ok := event.FormData[action.ValueField]
+		if !ok {
+			return fmt.Errorf("record has no field '%s'", action.ValueField)
+		}
+		value = fieldValue
+	}
+
+	var delta float64
+	if action.Operation == "increment" || action.Operation == "decrement" {
+		n, ok := actionNumber(value)
+		if !ok {
+			return fmt.Errorf("%s needs a numeric value, got %v", action.Operation, value)
+		}
+		delta = n
+		if action.Operation == "decrement" {
+			delta = -n
+		}
+	}
+
+	if action.Target == models.TransitionActionTargetBudgetAllocation {
+		allocationID, err := uuid.Parse(fmt.Sprint(event.FormData[action.TargetIDField]))
+		if err != nil {
+			return fmt.Errorf("record field '%s' does not hold a budget allocation ID", action.TargetIDField)
+		}
+
+		var update interface{} = value
+		if action.Operation == "increment" || action.Operation == "decrement" {
+			update = gorm.Expr(action.Field+" + ?", delta)
+		}
+		// Only allocations of projects (or their tasks) in the record's own vertical
+		result := tx.Model(&models.BudgetAllocation{}).
+			Where("id = ? AND deleted_at IS NULL", allocationID).
+			Where(`(project_id IN (SELECT id FROM projects WHERE business_vertical_id = ?)
+				OR task_id IN (SELECT t.id FROM tasks t JOIN projects p ON p.id = t.project_id WHERE p.business_vertical_id = ?))`,
+				event.BusinessVerticalID, event.BusinessVerticalID).
+			Update(action.Field, update)
+		if result.Error != nil {
+			return fmt.Errorf("failed to update budget allocation: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("budget allocation %s not found", allocationID)
+		}
+		return nil
+	}
+
+	if action.Operation == "increment" || action.Operation == "decrement" {
+		current, _ := actionNumber(event.FormData[action.Field])
+		value = current + delta
+	}
+	if err := setField(tx, action.Field, value); err != nil {
+		return err
+	}
+	event.FormData[action.Field] = value
+	return nil
+}
+
+func applyAssignTaskAction(tx *gorm.DB, action models.TransitionAction, event *workflowActionEvent) error {
+	taskID, err := uuid.Parse(fmt.Sprint(event.FormData[action.TaskIDField]))
+	if err != nil {
+		return fmt.Errorf("record field '%s' does not hold a task ID", action.TaskIDField)
+	}
+	assigneeID := action.Assignee
+	if action.AssigneeField != "" {
+		assigneeID = fmt.Sprint(event.FormData[action.AssigneeField])
+	}
+	var assignee models.User
+	if err := tx.Select("id", "name").First(&assignee, "id = ?", assigneeID).Error; err != nil {
+		return fmt.Errorf("assignee %s not found", assigneeID)
+	}
+
+	var count int64
+	if err := tx.Table("tasks").
+		Joins("JOIN projects ON projects.id = tasks.project_id").
+		Where("tasks.id = ? AND projects.business_vertical_id = ?", taskID, event.BusinessVerticalID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("task %s not found", taskID)
+	}
+
+	role := action.Role
+	if role == "" {
+		role = "worker"
+	}
+
+	// Re-running the transition must not stack duplicate assignments
+	var existing int64
+	if err := tx.Model(&models.TaskAssignment{}).
+		Where("task_id = ? AND user_id = ? AND role = ? AND is_active = ? AND deleted_at IS NULL", taskID, assignee.ID.String(), role, true).
+		Count(&existing).Error; err != nil {
+		return err
+	}
+	if existing > 0 {
+		return nil
+	}
+
+	assignment := models.TaskAssignment{
+		TaskID:     taskID,
+		UserID:     assignee.ID.String(),
+		UserName:   assignee.Name,
+		UserType:   "employee",
+		Role:       role,
+		AssignedBy: event.ActorID,
+		AssignedAt: time.Now(),
+		Status:     "active",
+		IsActive:   true,
+		Notes:      fmt.Sprintf("Assigned by workflow action '%s' on %s", event.Action, event.FormCode),
+	}
+	return tx.Create(&assignment).Error
+}
+
+func actionNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case json.Number:
+		n, err := v.Float64()
+		return n, err == nil
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+		return n, err == nil
+	case []byte:
+		n, err := strconv.ParseFloat(string(v), 64)
+		return n, err == nil
+	case nil:
+		return 0, true
+	}
+	return 0, false
+}
+
+// dispatchQueuedWorkflowActions delivers freshly queued actions right away instead of
+// waiting for the dispatcher's next tick.
+func dispatchQueuedWorkflowActions(queued int) {
+	if queued == 0 {
+		return
+	}
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("❌ Workflow action dispatch panicked: %v", r)
+			}
+		}()
+		if _, err := NewWorkflowActionDispatcher().DispatchDue(time.Now()); err != nil {
+			log.Printf("⚠️  Failed to dispatch workflow actions: %v", err)
+		}
+	}()
+}
+
+// WorkflowActionDispatcher delivers queued transition notifications and webhooks, retrying
+// failures with backoff until an action runs out of attempts.
+type WorkflowActionDispatcher struct {
+	db *gorm.DB
+	*jobrunner.Runner
+}
+
+// NewWorkflowActionDispatcher creates the workflow action outbox dispatcher
+func NewWorkflowActionDispatcher() *WorkflowActionDispatcher {
+	d := &WorkflowActionDispatcher{db: config.DB}
+	d.Runner = jobrunner.New("Workflow action dispatcher", func() {
+		if n, err := d.DispatchDue(time.Now()); err != nil {
+			log.Printf("Error dispatching workflow actions: %v", err)
+		} else if n > 0 {
+			log.Printf("Workflow action dispatcher: delivered %d actions", n)
+		}
+	})
+	return d
+}
+
+// workflowActionLease is how long a claimed action stays invisible to other dispatchers
+const workflowActionLease = 2 * time.Minute
+
+// DispatchDue delivers every pending action whose next attempt is due and returns how
+// many were delivered.
+func (d *WorkflowActionDispatcher) DispatchDue(now time.Time) (int, error) {
+	// Claim a batch by pushing its next attempt past the lease, so concurrent dispatchers
+	// (the ticker and post-commit dispatches) never deliver the same action twice
+	var due []models.WorkflowActionOutbox
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.WorkflowActionPending, now).
+			Order("next_attempt_at ASC").
+			Limit(100).
+			Find(&due).Error; err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return nil
+		}
+		ids := make([]uuid.UUID, len(due))
+		for i := range due {
+			ids[i] = due[i].ID
+		}
+		return tx.Model(&models.WorkflowActionOutbox{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(workflowActionLease)).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for i := range due {
+		entry := &due[i]
+		entry.Attempts++
+		updates := map[string]interface{}{"attempts": entry.Attempts}
+
+		if err := d.deliver(entry); err != nil {
+			log.Printf("⚠️  Workflow action %s (%s) attempt %d failed: %v", entry.ID, entry.ActionType, entry.Attempts, err)
+			updates["last_error"] = err.Error()
+			if entry.Attempts >= entry.MaxAttempts {
+				updates["status"] = models.WorkflowActionFailed
+			} else {
+				updates["next_attempt_at"] = *utils.CalculateNextRetry(entry.Attempts, utils.DefaultWebhookConfig())
+			}
+		} else {
+			delivered++
+			updates["status"] = models.WorkflowActionDelivered
+			updates["delivered_at"] = time.Now()
+			updates["last_error"] = ""
+		}
+
+		if err := d.db.Model(entry).Updates(updates).Error; err != nil {
+			log.Printf("❌ Failed to update workflow action %s: %v", entry.ID, err)
+		}
+	}
+	return delivered, nil
+}
+
+func (d *WorkflowActionDispatcher) deliver(entry *models.WorkflowActionOutbox) error {
+	var action models.TransitionAction
+	if err := json.Unmarshal(entry.Action, &action); err != nil {
+		return fmt.Errorf("invalid action: %w", err)
+	}
+	var event workflowActionEvent
+	if err := json.Unmarshal(entry.Event, &event); err != nil {
+		return fmt.Errorf("invalid event: %w", err)
+	}
+
+	switch action.Type {
+	case models.TransitionActionNotify:
+		return d.deliverNotification(action, &event)
+	case models.TransitionActionWebhook:
+		return d.deliverWebhook(action, &event, entry.Attempts)
+	}
+	return fmt.Errorf("action type '%s' is not delivered through the outbox", action.Type)
+}
+
+func (d *WorkflowActionDispatcher) deliverNotification(action models.TransitionAction, event *workflowActionEvent) error {
+	var workflow models.WorkflowDefinition
+	if err := d.db.First(&workflow, "id = ?", event.WorkflowID).Error; err != nil {
+		return fmt.Errorf("workflow not found: %w", err)
+	}
+	var transition models.WorkflowTransition
+	if err := d.db.First(&transition, "id = ?", event.TransitionID).Error; err != nil {
+		return fmt.Errorf("transition not found: %w", err)
+	}
+	var form models.AppForm
+	if err := d.db.First(&form, "id = ?", event.FormID).Error; err != nil {
+		return fmt.Errorf("form not found: %w", err)
+	}
+
+	formData, _ := json.Marshal(event.FormData)
+	submission := &models.FormSubmission{
+		ID:                 event.SubmissionID,
+		FormCode:           event.FormCode,
+		FormID:             event.FormID,
+		BusinessVerticalID: event.BusinessVerticalID,
+		SiteID:             event.SiteID,
+		WorkflowID:         &workflow.ID,
+		CurrentState:       event.ToState,
+		SubmittedBy:        event.SubmittedBy,
+		FormData:           formData,
+		Form:               &form,
+		Workflow:           &workflow,
+	}
+
+	ns := NewNotificationService()
+	context := ns.buildNotificationContext(submission, &transition, event.ActorName)
+	return ns.processNotification(submission, &transition, &workflow, *action.Notification, context)
+}
+
+func (d *WorkflowActionDispatcher) deliverWebhook(action models.TransitionAction, event *workflowActionEvent, attempt int) error {
+	var data map[string]interface{}
+	eventJSON, _ := json.Marshal(event)
+	if err := json.Unmarshal(eventJSON, &data); err != nil {
+		return err
+	}
+
+	// Without a URL the event goes to the vertical's webhook subscriptions, which keep
+	// their own delivery records and retries
+	if action.URL == "" {
+		return utils.NewWebhookService(d.db).TriggerWebhook(
+			models.EventWorkflowTransition,
+			workflowActionWebhookResourceType,
+			event.SubmissionID.String(),
+			event.BusinessVerticalID,
+			data,
+		)
+	}
+
+	deliveryConfig := utils.DefaultWebhookConfig()
+	resp, err := utils.SendWebhook(&utils.WebhookDeliveryRequest{
+		URL:        action.URL,
+		Payload:    models.NewWebhookPayload(models.EventWorkflowTransition, workflowActionWebhookResourceType, event.SubmissionID.String(), event.BusinessVerticalID, data),
+		Secret:     action.Secret,
+		Headers:    action.Headers,
+		Timeout:    deliveryConfig.DefaultTimeout,
+		Attempt:    attempt,
+		MaxRetries: deliveryConfig.DefaultMaxRetries,
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if !utils.IsSuccessStatusCode(resp.StatusCode) {
+		return fmt.Errorf("webhook returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
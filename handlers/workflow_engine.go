@@ -9,10 +9,12 @@ import (
 
 	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/hooks"
 	"p9e.in/ugcl/utils"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // WorkflowEngine handles workflow state transitions
@@ -62,6 +64,11 @@ func (we *WorkflowEngine) CreateSubmission(
 		initialState = workflowDef.InitialState
 	}
 
+	formData, err := validateFormSubmissionJSON(&form, formData, initialState == "draft")
+	if err != nil {
+		return nil, err
+	}
+
 	// Resolve reference field UUIDs into readable display objects where supported
 	// so downstream reporting can show human-friendly values.
 	enhancedFormData := formData
@@ -92,12 +99,31 @@ func (we *WorkflowEngine) CreateSubmission(
 		LastModifiedAt:     time.Now(),
 		Version:            1,
 	}
+	if workflowDef != nil {
+		submission.WorkflowVersion = &workflowDef.CurrentVersion
+	}
 
 	if err := we.db.Create(submission).Error; err != nil {
 		return nil, fmt.Errorf("failed to create submission: %w", err)
 	}
 
 	log.Printf("✅ Created form submission: %s (state: %s)", submission.ID, submission.CurrentState)
+
+	var hookData map[string]interface{}
+	_ = json.Unmarshal(submission.FormData, &hookData)
+	linkFormFiles(&form, submission.ID, hookData)
+	hooks.FireFormSubmitted(hooks.FormSubmittedEvent{
+		FormCode:           formCode,
+		FormID:             form.ID,
+		SubmissionID:       submission.ID,
+		BusinessVerticalID: businessVerticalID,
+		SiteID:             siteID,
+		State:              submission.CurrentState,
+		FormData:           hookData,
+		SubmittedBy:        userID,
+		SubmittedAt:        submission.SubmittedAt,
+	})
+
 	return submission, nil
 }
 
@@ -110,12 +136,30 @@ func (we *WorkflowEngine) TransitionState(
 	actorRole string,
 	comment string,
 	metadata map[string]interface{},
+) (*models.FormSubmission, error) {
+	return we.TransitionStateOnBehalf(submissionID, action, actorID, actorName, actorRole, comment, metadata, nil)
+}
+
+// TransitionStateOnBehalf performs a workflow state transition; a non-nil delegation
+// records the decision as taken on behalf of its delegator.
+func (we *WorkflowEngine) TransitionStateOnBehalf(
+	submissionID uuid.UUID,
+	action string,
+	actorID string,
+	actorName string,
+	actorRole string,
+	comment string,
+	metadata map[string]interface{},
+	delegation *models.ApprovalDelegation,
 ) (*models.FormSubmission, error) {
 	// Get the submission with its workflow
 	var submission models.FormSubmission
 	if err := we.db.Preload("Form").Preload("Workflow").First(&submission, "id = ?", submissionID).Error; err != nil {
 		return nil, fmt.Errorf("submission not found: %w", err)
 	}
+	if err := pinSubmissionWorkflow(we.db, &submission); err != nil {
+		return nil, err
+	}
 
 	// Get workflow definition
 	if submission.Workflow == nil {
@@ -146,6 +190,19 @@ func (we *WorkflowEngine) TransitionState(
 		return nil, errors.New("comment is required for this action")
 	}
 
+	// Drafts were validated partially; leaving the draft state needs the full form, except
+	// for timed transitions such as abandoning a stale draft
+	if submission.CurrentState == formDraftState && targetTransition.To != formDraftState &&
+		actorID != workflowSystemActor.ID && submission.Form != nil {
+		var data map[string]interface{}
+		if err := json.Unmarshal(submission.FormData, &data); err != nil {
+			return nil, fmt.Errorf("invalid form data: %w", err)
+		}
+		if err := validateDraftCompletion(submission.Form, data); err != nil {
+			return nil, err
+		}
+	}
+
 	// Store previous state
 	previousState := submission.CurrentState
 
@@ -157,6 +214,21 @@ func (we *WorkflowEngine) TransitionState(
 		}
 	}()
 
+	// Lock the submission so concurrent transitions (or the timer scheduler) cannot both
+	// leave the same state
+	var current string
+	if err := tx.Model(&models.FormSubmission{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", submissionID).
+		Pluck("current_state", &current).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to lock submission: %w", err)
+	}
+	if current != previousState {
+		tx.Rollback()
+		return nil, errWorkflowStateChanged
+	}
+
 	// Update submission state
 	submission.CurrentState = targetTransition.To
 	submission.LastModifiedBy = actorID
@@ -183,11 +255,57 @@ func (we *WorkflowEngine) TransitionState(
 		TransitionedAt: time.Now(),
 	}
 
+	if err := recordDelegatedDecision(tx, &transition, delegation); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to record delegation use: %w", err)
+	}
 	if err := tx.Create(&transition).Error; err != nil {
 		tx.Rollback()
 		return nil, fmt.Errorf("failed to create transition record: %w", err)
 	}
 
+	// Run the transition's actions in the same transaction
+	var formData map[string]interface{}
+	_ = json.Unmarshal(submission.FormData, &formData)
+	workflowID := uuid.Nil
+	if submission.WorkflowID != nil {
+		workflowID = *submission.WorkflowID
+	}
+	event := workflowActionEvent{
+		WorkflowTransitionEvent: hooks.WorkflowTransitionEvent{
+			FormCode:           submission.FormCode,
+			SubmissionID:       submissionID,
+			BusinessVerticalID: submission.BusinessVerticalID,
+			FromState:          previousState,
+			ToState:            targetTransition.To,
+			Action:             action,
+			ActorID:            actorID,
+			ActorName:          actorName,
+			ActorRole:          actorRole,
+			Comment:            comment,
+			Metadata:           metadata,
+			TransitionedAt:     transition.TransitionedAt,
+		},
+		TransitionID: transition.ID,
+		FormID:       submission.FormID,
+		WorkflowID:   workflowID,
+		SiteID:       submission.SiteID,
+		SubmittedBy:  submission.SubmittedBy,
+		FormData:     formData,
+	}
+	queued, err := applyTransitionActions(tx, targetTransition.Actions, &event, func(tx *gorm.DB, field string, value interface{}) error {
+		valueJSON, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.FormSubmission{}).Where("id = ?", submissionID).
+			Update("form_data", gorm.Expr("jsonb_set(COALESCE(form_data, '{}'::jsonb), ?::text[], ?::jsonb)", "{"+field+"}", string(valueJSON))).Error
+	})
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
@@ -195,10 +313,15 @@ func (we *WorkflowEngine) TransitionState(
 
 	log.Printf("✅ Transitioned submission %s: %s -> %s (action: %s, actor: %s)",
 		submissionID, previousState, targetTransition.To, action, actorName)
+	dispatchQueuedWorkflowActions(queued)
+	notifyDelegatedDecision(delegation, &transition, submission.FormCode)
 
 	// Process notifications (after transaction commit)
 	// Reload submission with relationships for notification context
 	we.db.Preload("Form").Preload("Workflow").Preload("BusinessVertical").First(&submission, submissionID)
+	if err := pinSubmissionWorkflow(we.db, &submission); err != nil {
+		log.Printf("⚠️  %v", err)
+	}
 
 	notifService := NewNotificationService()
 	if err := notifService.ProcessTransitionNotifications(&submission, &transition, submission.Workflow, targetTransition, actorName); err != nil {
@@ -206,6 +329,8 @@ func (we *WorkflowEngine) TransitionState(
 		// Don't fail the transition if notifications fail
 	}
 
+	hooks.FireWorkflowTransition(event.WorkflowTransitionEvent)
+
 	return &submission, nil
 }
 
@@ -229,9 +354,14 @@ func (we *WorkflowEngine) UpdateSubmissionData(
 
 	// Resolve reference field UUIDs into readable display objects where supported.
 	enhancedFormData := formData
+	var form models.AppForm
 	if len(formData) > 0 && string(formData) != "null" {
-		var form models.AppForm
 		if err := we.db.Where("id = ?", submission.FormID).First(&form).Error; err == nil {
+			validated, err := validateFormSubmissionJSON(&form, formData, true)
+			if err != nil {
+				return nil, err
+			}
+			formData, enhancedFormData = validated, validated
 			var formDataMap map[string]interface{}
 			if err := json.Unmarshal(formData, &formDataMap); err == nil {
 				resolvedMap := NewWorkflowEngineDedicated().ResolveFormFieldValues(&form, formDataMap)
@@ -255,6 +385,12 @@ func (we *WorkflowEngine) UpdateSubmissionData(
 	}
 
 	log.Printf("✅ Updated submission data: %s", submissionID)
+	if form.ID != uuid.Nil {
+		var data map[string]interface{}
+		if err := json.Unmarshal(formData, &data); err == nil {
+			linkFormFiles(&form, submissionID, data)
+		}
+	}
 	return &submission, nil
 }
 
@@ -271,6 +407,9 @@ func (we *WorkflowEngine) GetSubmission(submissionID uuid.UUID) (*models.FormSub
 		First(&submission, "id = ?", submissionID).Error; err != nil {
 		return nil, fmt.Errorf("submission not found: %w", err)
 	}
+	if err := pinSubmissionWorkflow(we.db, &submission); err != nil {
+		return nil, err
+	}
 
 	return &submission, nil
 }
@@ -294,6 +433,8 @@ func (we *WorkflowEngine) GetSubmissionsByForm(
 	// Apply site filter if provided
 	if siteID, ok := filters["site_id"].(uuid.UUID); ok {
 		query = query.Where("site_id = ?", siteID)
+	} else if siteIDs, ok := filters["site_id"].([]uuid.UUID); ok {
+		query = query.Where("site_id IN ?", siteIDs)
 	}
 
 	// Apply user filter if provided
@@ -305,6 +446,7 @@ func (we *WorkflowEngine) GetSubmissionsByForm(
 	if err := query.Order("submitted_at DESC").Find(&submissions).Error; err != nil {
 		return nil, fmt.Errorf("failed to fetch submissions: %w", err)
 	}
+	pinSubmissionWorkflows(we.db, submissions)
 
 	return submissions, nil
 }
@@ -332,6 +474,8 @@ func (we *WorkflowEngine) GetSubmissionsByFormPage(
 
 	if siteID, ok := filters["site_id"].(uuid.UUID); ok {
 		query = query.Where("site_id = ?", siteID)
+	} else if siteIDs, ok := filters["site_id"].([]uuid.UUID); ok {
+		query = query.Where("site_id IN ?", siteIDs)
 	}
 
 	if userID, ok := filters["submitted_by"].(string); ok && userID != "" {
@@ -346,6 +490,7 @@ func (we *WorkflowEngine) GetSubmissionsByFormPage(
 	if err := query.Order("submitted_at DESC, id DESC").Limit(limit).Find(&submissions).Error; err != nil {
 		return nil, fmt.Errorf("failed to fetch submissions: %w", err)
 	}
+	pinSubmissionWorkflows(we.db, submissions)
 
 	return submissions, nil
 }
@@ -373,6 +518,9 @@ func (we *WorkflowEngine) ValidateTransition(
 	if err := we.db.Preload("Workflow").First(&submission, "id = ?", submissionID).Error; err != nil {
 		return fmt.Errorf("submission not found: %w", err)
 	}
+	if err := pinSubmissionWorkflow(we.db, &submission); err != nil {
+		return err
+	}
 
 	if submission.Workflow == nil {
 		return errors.New("no workflow defined for this form")
@@ -388,16 +536,16 @@ func (we *WorkflowEngine) ValidateTransition(
 	for _, t := range transitions {
 		if t.From == submission.CurrentState && t.Action == action {
 			// Check permission if required
-			if t.Permission != "" {
+			if t.RequiredPermissionCode() != "" {
 				hasPermission := false
 				for _, perm := range userPermissions {
-					if perm == "admin_all" || perm == "*:*:*" || utils.MatchesPermission(perm, t.Permission) {
+					if perm == "admin_all" || perm == "*:*:*" || utils.MatchesPermission(perm, t.RequiredPermissionCode()) {
 						hasPermission = true
 						break
 					}
 				}
 				if !hasPermission {
-					return fmt.Errorf("insufficient permissions: requires '%s'", t.Permission)
+					return fmt.Errorf("insufficient permissions: requires '%s'", t.RequiredPermissionCode())
 				}
 			}
 			return nil // Valid transition
@@ -407,6 +555,33 @@ func (we *WorkflowEngine) ValidateTransition(
 	return fmt.Errorf("invalid transition: action '%s' not allowed from state '%s'", action, submission.CurrentState)
 }
 
+// TransitionPermission returns the permission required by the transition action
+// from the submission's current state, or "" when it requires none, and the business
+// vertical the submission belongs to.
+func (we *WorkflowEngine) TransitionPermission(submissionID uuid.UUID, action string) (string, uuid.UUID, error) {
+	var submission models.FormSubmission
+	if err := we.db.Preload("Workflow").First(&submission, "id = ?", submissionID).Error; err != nil {
+		return "", uuid.Nil, fmt.Errorf("submission not found: %w", err)
+	}
+	if err := pinSubmissionWorkflow(we.db, &submission); err != nil {
+		return "", uuid.Nil, err
+	}
+	if submission.Workflow == nil {
+		return "", submission.BusinessVerticalID, nil
+	}
+
+	var transitions []models.WorkflowTransitionDef
+	if err := json.Unmarshal(submission.Workflow.Transitions, &transitions); err != nil {
+		return "", uuid.Nil, fmt.Errorf("invalid workflow configuration: %w", err)
+	}
+	for _, t := range transitions {
+		if t.From == submission.CurrentState && t.Action == action {
+			return t.RequiredPermissionCode(), submission.BusinessVerticalID, nil
+		}
+	}
+	return "", submission.BusinessVerticalID, nil
+}
+
 // GetWorkflowStats returns statistics about submissions in different states
 func (we *WorkflowEngine) GetWorkflowStats(formCode string, businessVerticalID uuid.UUID) (map[string]int64, error) {
 	type StateCount struct {
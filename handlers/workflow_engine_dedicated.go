@@ -11,6 +11,7 @@ import (
 
 	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/hooks"
 	"p9e.in/ugcl/utils"
 
 	"github.com/google/uuid"
@@ -41,6 +42,7 @@ type FormSubmissionRecord struct {
 	BusinessVerticalID uuid.UUID                  `json:"business_vertical_id"`
 	SiteID             *uuid.UUID                 `json:"site_id,omitempty"`
 	WorkflowID         *uuid.UUID                 `json:"workflow_id,omitempty"`
+	WorkflowVersion    *int                       `json:"workflow_version,omitempty"`
 	CurrentState       string                     `json:"current_state"`
 	CreatedBy          string                     `json:"created_by"`
 	CreatedAt          time.Time                  `json:"created_at"`
@@ -60,6 +62,34 @@ func (we *WorkflowEngineDedicated) CreateSubmissionDedicated(
 	siteID *uuid.UUID,
 	formData map[string]interface{},
 	userID string,
+) (*FormSubmissionRecord, error) {
+	return we.createSubmissionDedicated(formCode, uuid.Nil, businessVerticalID, siteID, formData, userID, false)
+}
+
+// CreateDraftDedicated saves a partly filled submission in the draft state, whatever
+// state the form's workflow starts in. Only the values present are validated; the rest
+// are checked when the draft is submitted.
+func (we *WorkflowEngineDedicated) CreateDraftDedicated(
+	formCode string,
+	businessVerticalID uuid.UUID,
+	siteID *uuid.UUID,
+	formData map[string]interface{},
+	userID string,
+) (*FormSubmissionRecord, error) {
+	return we.createSubmissionDedicated(formCode, uuid.Nil, businessVerticalID, siteID, formData, userID, true)
+}
+
+// createSubmissionDedicated creates a submission, or a draft when draft is set. A non-nil
+// recordID is used as the record's ID, so offline clients can name records they create;
+// an ID that is taken fails with a unique violation.
+func (we *WorkflowEngineDedicated) createSubmissionDedicated(
+	formCode string,
+	recordID uuid.UUID,
+	businessVerticalID uuid.UUID,
+	siteID *uuid.UUID,
+	formData map[string]interface{},
+	userID string,
+	draft bool,
 ) (*FormSubmissionRecord, error) {
 	// Get the form definition
 	var form models.AppForm
@@ -126,17 +156,85 @@ func (we *WorkflowEngineDedicated) CreateSubmissionDedicated(
 		}
 	}
 
-	// Get workflow definition if specified
+	// Determine initial state
+	initialState := we.initialState(&form)
+	if draft {
+		initialState = formDraftState
+	}
+
+	if err := validateFormSubmission(&form, formData, initialState == formDraftState); err != nil {
+		return nil, err
+	}
+
+	// Resolve reference field values (UUIDs to display names)
+	enhancedFormData := we.ResolveFormFieldValues(&form, formData)
+	if recordID != uuid.Nil {
+		enhancedFormData["id"] = recordID
+	}
+
+	// Insert data into dedicated table, with the rows of its sections in their own tables
+	sectionRows := takeFormSectionRows(&form, enhancedFormData)
+	err = we.tableManager.db.Transaction(func(tx *gorm.DB) error {
+		tableManager := we.tableManager.withDB(tx)
+		recordID, err = tableManager.InsertFormData(
+			form.DBTableName,
+			form.ID,
+			formCode,
+			businessVerticalID,
+			siteID,
+			form.WorkflowID,
+			initialState,
+			enhancedFormData,
+			userID,
+		)
+		if err != nil {
+			return err
+		}
+		return tableManager.replaceSectionRows("", &form, recordID, sectionRows, userID)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create submission: %w", err)
+	}
+	for name, rows := range sectionRows {
+		enhancedFormData[name] = rows
+	}
+
+	log.Printf("✅ Created form submission in %s: %s (state: %s)", form.DBTableName, recordID, initialState)
+	linkFormFiles(&form, recordID, formData)
+	if draft {
+		// Drafts saved on purpose are announced when they are submitted
+		return we.GetSubmissionDedicated(form.DBTableName, recordID)
+	}
+
+	hooks.FireFormSubmitted(hooks.FormSubmittedEvent{
+		FormCode:           formCode,
+		FormID:             form.ID,
+		SubmissionID:       recordID,
+		TableName:          form.DBTableName,
+		BusinessVerticalID: businessVerticalID,
+		SiteID:             siteID,
+		State:              initialState,
+		FormData:           enhancedFormData,
+		SubmittedBy:        userID,
+		SubmittedAt:        time.Now(),
+	})
+
+	// Retrieve and return the created record
+	return we.GetSubmissionDedicated(form.DBTableName, recordID)
+}
+
+// initialState returns the state new submissions of the form start in: its active
+// workflow's initial state, else the form's own, else draft
+func (we *WorkflowEngineDedicated) initialState(form *models.AppForm) string {
 	var workflowDef *models.WorkflowDefinition
 	if form.WorkflowID != nil {
 		workflowDef = &models.WorkflowDefinition{}
 		if err := we.db.First(workflowDef, "id = ? AND is_active = ?", form.WorkflowID, true).Error; err != nil {
-			log.Printf("⚠️  Workflow not found for form %s: %v", formCode, err)
+			log.Printf("⚠️  Workflow not found for form %s: %v", form.Code, err)
 			workflowDef = nil
 		}
 	}
 
-	// Determine initial state
 	initialState := form.InitialState
 	if initialState == "" {
 		initialState = "draft"
@@ -144,30 +242,7 @@ func (we *WorkflowEngineDedicated) CreateSubmissionDedicated(
 	if workflowDef != nil && workflowDef.InitialState != "" {
 		initialState = workflowDef.InitialState
 	}
-
-	// Resolve reference field values (UUIDs to display names)
-	enhancedFormData := we.ResolveFormFieldValues(&form, formData)
-
-	// Insert data into dedicated table
-	recordID, err := we.tableManager.InsertFormData(
-		form.DBTableName,
-		form.ID,
-		formCode,
-		businessVerticalID,
-		siteID,
-		form.WorkflowID,
-		initialState,
-		enhancedFormData,
-		userID,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create submission: %w", err)
-	}
-
-	log.Printf("✅ Created form submission in %s: %s (state: %s)", form.DBTableName, recordID, initialState)
-
-	// Retrieve and return the created record
-	return we.GetSubmissionDedicated(form.DBTableName, recordID)
+	return initialState
 }
 
 // ResolveFormFieldValues enhances form data by resolving reference fields to display names
@@ -399,6 +474,22 @@ func (we *WorkflowEngineDedicated) TransitionStateDedicated(
 	actorRole string,
 	comment string,
 	metadata map[string]interface{},
+) (*FormSubmissionRecord, error) {
+	return we.TransitionStateDedicatedOnBehalf(formCode, recordID, action, actorID, actorName, actorRole, comment, metadata, nil)
+}
+
+// TransitionStateDedicatedOnBehalf performs a workflow state transition on a dedicated
+// table record; a non-nil delegation records the decision as taken on behalf of its delegator.
+func (we *WorkflowEngineDedicated) TransitionStateDedicatedOnBehalf(
+	formCode string,
+	recordID uuid.UUID,
+	action string,
+	actorID string,
+	actorName string,
+	actorRole string,
+	comment string,
+	metadata map[string]interface{},
+	delegation *models.ApprovalDelegation,
 ) (*FormSubmissionRecord, error) {
 	// Get the form definition
 	var form models.AppForm
@@ -421,8 +512,8 @@ func (we *WorkflowEngineDedicated) TransitionStateDedicated(
 		return nil, errors.New("no workflow defined for this form")
 	}
 
-	var workflowDef models.WorkflowDefinition
-	if err := we.db.First(&workflowDef, "id = ?", record.WorkflowID).Error; err != nil {
+	workflowDef, err := loadPinnedWorkflow(we.db, *record.WorkflowID, record.WorkflowVersion)
+	if err != nil {
 		return nil, fmt.Errorf("workflow not found: %w", err)
 	}
 
@@ -450,6 +541,14 @@ func (we *WorkflowEngineDedicated) TransitionStateDedicated(
 		return nil, errors.New("comment is required for this action")
 	}
 
+	// Drafts were validated partially; leaving the draft state needs the full form, except
+	// for timed transitions such as abandoning a stale draft
+	if record.CurrentState == formDraftState && targetTransition.To != formDraftState && actorID != workflowSystemActor.ID {
+		if err := validateDraftCompletion(&form, record.FormData); err != nil {
+			return nil, err
+		}
+	}
+
 	// Store previous state
 	previousState := record.CurrentState
 
@@ -462,7 +561,8 @@ func (we *WorkflowEngineDedicated) TransitionStateDedicated(
 	}()
 
 	// Update workflow state in the dedicated table
-	if err := we.tableManager.UpdateWorkflowState(form.DBTableName, recordID, targetTransition.To, actorID); err != nil {
+	tableManager := &FormTableManager{db: tx, schemaManager: we.tableManager.schemaManager}
+	if err := tableManager.UpdateWorkflowState(form.DBTableName, recordID, targetTransition.To, actorID); err != nil {
 		tx.Rollback()
 		return nil, fmt.Errorf("failed to update submission state: %w", err)
 	}
@@ -482,11 +582,48 @@ func (we *WorkflowEngineDedicated) TransitionStateDedicated(
 		TransitionedAt: time.Now(),
 	}
 
+	if err := recordDelegatedDecision(tx, &transition, delegation); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to record delegation use: %w", err)
+	}
 	if err := tx.Create(&transition).Error; err != nil {
 		tx.Rollback()
 		return nil, fmt.Errorf("failed to create transition record: %w", err)
 	}
 
+	// Run the transition's actions in the same transaction
+	event := workflowActionEvent{
+		WorkflowTransitionEvent: hooks.WorkflowTransitionEvent{
+			FormCode:           formCode,
+			SubmissionID:       recordID,
+			TableName:          form.DBTableName,
+			BusinessVerticalID: record.BusinessVerticalID,
+			FromState:          previousState,
+			ToState:            targetTransition.To,
+			Action:             action,
+			ActorID:            actorID,
+			ActorName:          actorName,
+			ActorRole:          actorRole,
+			Comment:            comment,
+			Metadata:           metadata,
+			TransitionedAt:     transition.TransitionedAt,
+		},
+		TransitionID: transition.ID,
+		FormID:       form.ID,
+		WorkflowID:   workflowDef.ID,
+		SiteID:       record.SiteID,
+		SubmittedBy:  record.CreatedBy,
+		FormData:     record.FormData,
+	}
+	queued, err := applyTransitionActions(tx, targetTransition.Actions, &event, func(tx *gorm.DB, field string, value interface{}) error {
+		tm := &FormTableManager{db: tx, schemaManager: we.tableManager.schemaManager}
+		return tm.UpdateFormData(form.DBTableName, recordID, map[string]interface{}{field: value}, actorID)
+	})
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
@@ -494,27 +631,35 @@ func (we *WorkflowEngineDedicated) TransitionStateDedicated(
 
 	log.Printf("✅ Transitioned submission %s in %s: %s -> %s (action: %s, actor: %s)",
 		recordID, form.DBTableName, previousState, targetTransition.To, action, actorName)
+	dispatchQueuedWorkflowActions(queued)
+	notifyDelegatedDecision(delegation, &transition, formCode)
 
 	// Process notifications (if configured)
 	// Note: You may need to adapt notification processing for dedicated tables
 	notifService := NewNotificationService()
 	// Create a temporary FormSubmission-like structure for notification processing
+	formData, _ := json.Marshal(event.FormData)
 	tempSubmission := &models.FormSubmission{
 		ID:                 recordID,
 		FormCode:           formCode,
 		FormID:             form.ID,
 		BusinessVerticalID: record.BusinessVerticalID,
+		SiteID:             record.SiteID,
+		WorkflowID:         &workflowDef.ID,
 		CurrentState:       targetTransition.To,
 		SubmittedBy:        record.CreatedBy,
+		FormData:           formData,
 		Form:               &form,
-		Workflow:           &workflowDef,
+		Workflow:           workflowDef,
 	}
 
-	if err := notifService.ProcessTransitionNotifications(tempSubmission, &transition, &workflowDef, targetTransition, actorName); err != nil {
+	if err := notifService.ProcessTransitionNotifications(tempSubmission, &transition, workflowDef, targetTransition, actorName); err != nil {
 		log.Printf("⚠️  Failed to process notifications: %v", err)
 		// Don't fail the transition if notifications fail
 	}
 
+	hooks.FireWorkflowTransition(event.WorkflowTransitionEvent)
+
 	// Retrieve and return updated record
 	return we.GetSubmissionDedicated(form.DBTableName, recordID)
 }
@@ -547,12 +692,25 @@ func (we *WorkflowEngineDedicated) UpdateSubmissionDataDedicated(
 		return nil, fmt.Errorf("cannot update submission in state '%s' - only draft submissions can be edited", record.CurrentState)
 	}
 
-	// Update data in dedicated table
-	if err := we.tableManager.UpdateFormData(form.DBTableName, recordID, formData, userID); err != nil {
+	if err := validateFormSubmission(&form, formData, true); err != nil {
+		return nil, err
+	}
+
+	// Update data in dedicated table; sections sent are replaced with the rows given
+	sectionRows := takeFormSectionRows(&form, formData)
+	err = we.tableManager.db.Transaction(func(tx *gorm.DB) error {
+		tableManager := we.tableManager.withDB(tx)
+		if err := tableManager.UpdateFormData(form.DBTableName, recordID, formData, userID); err != nil {
+			return err
+		}
+		return tableManager.replaceSectionRows("", &form, recordID, sectionRows, userID)
+	})
+	if err != nil {
 		return nil, fmt.Errorf("failed to update submission: %w", err)
 	}
 
 	log.Printf("✅ Updated submission data in %s: %s", form.DBTableName, recordID)
+	linkFormFiles(&form, recordID, formData)
 
 	// Retrieve and return updated record
 	return we.GetSubmissionDedicated(form.DBTableName, recordID)
@@ -580,8 +738,17 @@ func (we *WorkflowEngineDedicated) GetSubmissionDedicated(tableName string, reco
 	if formCode, ok := data["form_code"].(string); ok {
 		record.FormCode = formCode
 	}
-	if bizID, ok := data["business_vertical_id"].([]byte); ok {
-		record.BusinessVerticalID, _ = uuid.FromBytes(bizID)
+	if bizID, ok := columnUUID(data["business_vertical_id"]); ok {
+		record.BusinessVerticalID = bizID
+	}
+	if siteID, ok := columnUUID(data["site_id"]); ok {
+		record.SiteID = &siteID
+	}
+	if workflowID, ok := columnUUID(data["workflow_id"]); ok {
+		record.WorkflowID = &workflowID
+	}
+	if version, ok := columnInt(data["workflow_version"]); ok {
+		record.WorkflowVersion = &version
 	}
 	if state, ok := data["current_state"].(string); ok {
 		record.CurrentState = state
@@ -596,7 +763,7 @@ func (we *WorkflowEngineDedicated) GetSubmissionDedicated(tableName string, reco
 	// Store all other fields as form data
 	baseFields := map[string]bool{
 		"id": true, "form_id": true, "form_code": true, "business_vertical_id": true,
-		"site_id": true, "workflow_id": true, "current_state": true,
+		"site_id": true, "workflow_id": true, "workflow_version": true, "current_state": true,
 		"created_by": true, "created_at": true, "updated_by": true, "updated_at": true,
 		"deleted_by": true, "deleted_at": true,
 	}
@@ -653,7 +820,7 @@ func (we *WorkflowEngineDedicated) GetSubmissionsByFormDedicated(
 		// Store other fields as form data
 		baseFields := map[string]bool{
 			"id": true, "form_id": true, "form_code": true, "business_vertical_id": true,
-			"site_id": true, "workflow_id": true, "current_state": true,
+			"site_id": true, "workflow_id": true, "workflow_version": true, "current_state": true,
 			"created_by": true, "created_at": true, "updated_by": true, "updated_at": true,
 			"deleted_by": true, "deleted_at": true,
 		}
@@ -715,7 +882,7 @@ func (we *WorkflowEngineDedicated) GetSubmissionsByFormDedicatedPage(
 
 		baseFields := map[string]bool{
 			"id": true, "form_id": true, "form_code": true, "business_vertical_id": true,
-			"site_id": true, "workflow_id": true, "current_state": true,
+			"site_id": true, "workflow_id": true, "workflow_version": true, "current_state": true,
 			"created_by": true, "created_at": true, "updated_by": true, "updated_at": true,
 			"deleted_by": true, "deleted_at": true,
 		}
@@ -757,6 +924,87 @@ func (we *WorkflowEngineDedicated) DeleteSubmissionDedicated(
 	return nil
 }
 
+// SubmitDraftDedicated submits a draft whose workflow has no transition out of the draft
+// state. Once the draft passes full validation it moves to the state new submissions start
+// in and is announced as submitted.
+func (we *WorkflowEngineDedicated) SubmitDraftDedicated(
+	formCode string,
+	recordID uuid.UUID,
+	actorID string,
+	actorName string,
+	actorRole string,
+) (*FormSubmissionRecord, error) {
+	var form models.AppForm
+	if err := we.db.Where("code = ? AND is_active = ?", formCode, true).First(&form).Error; err != nil {
+		return nil, fmt.Errorf("form not found: %w", err)
+	}
+	if form.DBTableName == "" {
+		return nil, fmt.Errorf("form %s does not have a dedicated table configured", formCode)
+	}
+
+	record, err := we.GetSubmissionDedicated(form.DBTableName, recordID)
+	if err != nil {
+		return nil, fmt.Errorf("submission not found: %w", err)
+	}
+	if record.CurrentState != formDraftState {
+		return nil, fmt.Errorf("cannot submit submission in state '%s' - it is not a draft", record.CurrentState)
+	}
+	if err := validateDraftCompletion(&form, record.FormData); err != nil {
+		return nil, err
+	}
+
+	state := form.InitialState
+	if record.WorkflowID != nil {
+		if workflowDef, err := loadPinnedWorkflow(we.db, *record.WorkflowID, record.WorkflowVersion); err == nil && workflowDef.InitialState != "" {
+			state = workflowDef.InitialState
+		}
+	}
+	if state == "" || state == formDraftState {
+		return nil, errors.New("the form's workflow has no state to submit drafts into")
+	}
+
+	tx := we.db.Begin()
+	tableManager := &FormTableManager{db: tx, schemaManager: we.tableManager.schemaManager}
+	if err := tableManager.UpdateWorkflowState(form.DBTableName, recordID, state, actorID); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to update submission state: %w", err)
+	}
+	transition := models.WorkflowTransition{
+		SubmissionID:   recordID,
+		FromState:      formDraftState,
+		ToState:        state,
+		Action:         "submit",
+		ActorID:        actorID,
+		ActorName:      actorName,
+		ActorRole:      actorRole,
+		TransitionedAt: time.Now(),
+	}
+	if err := tx.Create(&transition).Error; err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to create transition record: %w", err)
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	log.Printf("✅ Submitted draft %s in %s (state: %s)", recordID, form.DBTableName, state)
+
+	hooks.FireFormSubmitted(hooks.FormSubmittedEvent{
+		FormCode:           formCode,
+		FormID:             form.ID,
+		SubmissionID:       recordID,
+		TableName:          form.DBTableName,
+		BusinessVerticalID: record.BusinessVerticalID,
+		SiteID:             record.SiteID,
+		State:              state,
+		FormData:           record.FormData,
+		SubmittedBy:        record.CreatedBy,
+		SubmittedAt:        transition.TransitionedAt,
+	})
+
+	return we.GetSubmissionDedicated(form.DBTableName, recordID)
+}
+
 // GetWorkflowHistoryDedicated retrieves the complete transition history (from workflow_transitions)
 func (we *WorkflowEngineDedicated) GetWorkflowHistoryDedicated(recordID uuid.UUID) ([]models.WorkflowTransition, error) {
 	var transitions []models.WorkflowTransition
@@ -797,8 +1045,8 @@ func (we *WorkflowEngineDedicated) ValidateTransitionDedicated(
 		return errors.New("no workflow defined for this form")
 	}
 
-	var workflowDef models.WorkflowDefinition
-	if err := we.db.First(&workflowDef, "id = ?", record.WorkflowID).Error; err != nil {
+	workflowDef, err := loadPinnedWorkflow(we.db, *record.WorkflowID, record.WorkflowVersion)
+	if err != nil {
 		return fmt.Errorf("workflow not found: %w", err)
 	}
 
@@ -812,16 +1060,16 @@ func (we *WorkflowEngineDedicated) ValidateTransitionDedicated(
 	for _, t := range transitions {
 		if t.From == record.CurrentState && t.Action == action {
 			// Check permission if required
-			if t.Permission != "" {
+			if t.RequiredPermissionCode() != "" {
 				hasPermission := false
 				for _, perm := range userPermissions {
-					if perm == "admin_all" || perm == "*:*:*" || utils.MatchesPermission(perm, t.Permission) {
+					if perm == "admin_all" || perm == "*:*:*" || utils.MatchesPermission(perm, t.RequiredPermissionCode()) {
 						hasPermission = true
 						break
 					}
 				}
 				if !hasPermission {
-					return fmt.Errorf("insufficient permissions: requires '%s'", t.Permission)
+					return fmt.Errorf("insufficient permissions: requires '%s'", t.RequiredPermissionCode())
 				}
 			}
 			return nil // Valid transition
@@ -830,3 +1078,37 @@ func (we *WorkflowEngineDedicated) ValidateTransitionDedicated(
 
 	return fmt.Errorf("invalid transition: action '%s' not allowed from state '%s'", action, record.CurrentState)
 }
+
+// TransitionPermissionDedicated returns the permission required by the transition action
+// from the record's current state, or "" when it requires none, and the business vertical
+// the record belongs to.
+func (we *WorkflowEngineDedicated) TransitionPermissionDedicated(formCode string, recordID uuid.UUID, action string) (string, uuid.UUID, error) {
+	var form models.AppForm
+	if err := we.db.Where("code = ? AND is_active = ?", formCode, true).First(&form).Error; err != nil {
+		return "", uuid.Nil, fmt.Errorf("form not found: %w", err)
+	}
+	if form.DBTableName == "" {
+		return "", uuid.Nil, fmt.Errorf("form %s does not have a dedicated table configured", formCode)
+	}
+
+	record, err := we.GetSubmissionDedicated(form.DBTableName, recordID)
+	if err != nil {
+		return "", uuid.Nil, fmt.Errorf("submission not found: %w", err)
+	}
+	if record.BusinessVerticalID == uuid.Nil {
+		return "", uuid.Nil, fmt.Errorf("submission %s has no business vertical", recordID)
+	}
+	if record.WorkflowID == nil {
+		return "", record.BusinessVerticalID, nil
+	}
+
+	workflowDef, err := loadPinnedWorkflow(we.db, *record.WorkflowID, record.WorkflowVersion)
+	if err != nil {
+		return "", uuid.Nil, fmt.Errorf("workflow not found: %w", err)
+	}
+	transition, err := workflowDef.FindTransition(record.CurrentState, action)
+	if err != nil {
+		return "", record.BusinessVerticalID, nil
+	}
+	return transition.RequiredPermissionCode(), record.BusinessVerticalID, nil
+}
@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
@@ -13,6 +15,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
 	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/middleware"
 	"p9e.in/ugcl/models"
@@ -70,6 +73,12 @@ func getWorkflowEngine() *WorkflowEngine {
 	return workflowEngine
 }
 
+// scopedWorkflowEngine returns the workflow engine bound to the request's data scope, so
+// submissions outside the caller's verticals and sites are never loaded or changed.
+func scopedWorkflowEngine(r *http.Request) *WorkflowEngine {
+	return &WorkflowEngine{db: middleware.WithDataScope(r, getWorkflowEngine().db)}
+}
+
 // SubmitFormRequest represents the request body for form submission
 type SubmitFormRequest struct {
 	FormData  json.RawMessage `json:"form_data"`
@@ -199,6 +208,11 @@ func CreateFormSubmission(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !middleware.SiteInScope(r, req.SiteID) {
+		http.Error(w, "no access to this site", http.StatusForbidden)
+		return
+	}
+
 	normalizedFormData, latitude, longitude, err := normalizeSubmissionPayload(req.FormData, req.Latitude, req.Longitude)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -208,7 +222,7 @@ func CreateFormSubmission(w http.ResponseWriter, r *http.Request) {
 	log.Printf("📝 Creating form submission: %s for business: %s, user: %s", formCode, businessCode, claims.UserID)
 
 	// Create submission
-	submission, err := getWorkflowEngine().CreateSubmission(
+	submission, err := scopedWorkflowEngine(r).CreateSubmission(
 		formCode,
 		businessID,
 		req.SiteID,
@@ -219,6 +233,9 @@ func CreateFormSubmission(w http.ResponseWriter, r *http.Request) {
 	)
 	if err != nil {
 		log.Printf("❌ Error creating submission: %v", err)
+		if writeFormValidationError(w, err) {
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -269,6 +286,9 @@ func GetFormSubmissions(w http.ResponseWriter, r *http.Request) {
 			filters["site_id"] = id
 		}
 	}
+	if !applySiteScope(w, r, filters) {
+		return
+	}
 	if r.URL.Query().Get("my_submissions") == "true" {
 		filters["submitted_by"] = claims.UserID
 	}
@@ -302,9 +322,9 @@ func GetFormSubmissions(w http.ResponseWriter, r *http.Request) {
 	var submissions []models.FormSubmission
 	var err error
 	if usePagination {
-		submissions, err = getWorkflowEngine().GetSubmissionsByFormPage(formCode, businessID, filters, pageSize+1, cursor)
+		submissions, err = scopedWorkflowEngine(r).GetSubmissionsByFormPage(formCode, businessID, filters, pageSize+1, cursor)
 	} else {
-		submissions, err = getWorkflowEngine().GetSubmissionsByForm(formCode, businessID, filters)
+		submissions, err = scopedWorkflowEngine(r).GetSubmissionsByForm(formCode, businessID, filters)
 	}
 	if err != nil {
 		log.Printf("❌ Error fetching submissions: %v", err)
@@ -375,7 +395,7 @@ func GetFormSubmission(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	submission, err := getWorkflowEngine().GetSubmission(submissionID)
+	submission, err := scopedWorkflowEngine(r).GetSubmission(submissionID)
 	if err != nil {
 		log.Printf("❌ Error fetching submission: %v", err)
 		http.Error(w, "submission not found", http.StatusNotFound)
@@ -390,7 +410,7 @@ func GetFormSubmission(w http.ResponseWriter, r *http.Request) {
 	}
 
 	businessID, ok := context["business_id"].(uuid.UUID)
-	if !ok || submission.BusinessVerticalID != businessID {
+	if !ok || submission.BusinessVerticalID != businessID || !middleware.SiteInScope(r, submission.SiteID) {
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
@@ -450,7 +470,7 @@ func GetResolvedFormSubmission(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	submission, err := getWorkflowEngine().GetSubmission(submissionID)
+	submission, err := scopedWorkflowEngine(r).GetSubmission(submissionID)
 	if err != nil {
 		log.Printf("Error fetching submission: %v", err)
 		http.Error(w, "submission not found", http.StatusNotFound)
@@ -621,9 +641,12 @@ func UpdateFormSubmission(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	submission, err := getWorkflowEngine().UpdateSubmissionData(submissionID, normalizedFormData, latitude, longitude, claims.UserID)
+	submission, err := scopedWorkflowEngine(r).UpdateSubmissionData(submissionID, normalizedFormData, latitude, longitude, claims.UserID)
 	if err != nil {
 		log.Printf("❌ Error updating submission: %v", err)
+		if writeFormValidationError(w, err) {
+			return
+		}
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -664,22 +687,47 @@ func TransitionFormSubmission(w http.ResponseWriter, r *http.Request) {
 
 	// Use merged global + business-context permissions for transition authorization.
 	userPermissions := middleware.GetEffectivePermissions(r)
+	businessID := middleware.GetCurrentBusinessID(r)
+
+	// Users without the permission may still decide under an approval delegation
+	permission, submissionBusinessID, permissionErr := scopedWorkflowEngine(r).TransitionPermission(submissionID, req.Action)
+	delegation, err := workflowDelegationFor(claims.UserID, userPermissions, businessID, permission)
+	if err != nil {
+		http.Error(w, "failed to check approval delegations", http.StatusInternalServerError)
+		return
+	}
+	if delegation != nil {
+		userPermissions = append(userPermissions, permission)
+	}
 
 	// Validate transition
-	if err := getWorkflowEngine().ValidateTransition(submissionID, req.Action, userPermissions); err != nil {
+	if err := scopedWorkflowEngine(r).ValidateTransition(submissionID, req.Action, userPermissions); err != nil {
 		log.Printf("❌ Transition validation failed: %v", err)
 		http.Error(w, err.Error(), http.StatusForbidden)
 		return
 	}
 
+	// Approvals guarded by a separation-of-duties rule are refused to users holding the
+	// conflicting permission in the submission's vertical
+	if permissionErr != nil {
+		http.Error(w, permissionErr.Error(), http.StatusInternalServerError)
+		return
+	}
+	if permission != "" {
+		if err := middleware.EnforceApprovalSoD(r, submissionBusinessID, permission, "form_submission:"+submissionID.String()+":"+req.Action); err != nil {
+			middleware.WriteSoDViolation(w, err)
+			return
+		}
+	}
+
 	// Get user role name
 	userRole := ""
-	if user.RoleModel != nil {
-		userRole = user.RoleModel.Name
+	if role := user.EffectiveRole(); role != nil {
+		userRole = role.Name
 	}
 
 	// Perform transition
-	submission, err := getWorkflowEngine().TransitionState(
+	submission, err := scopedWorkflowEngine(r).TransitionStateOnBehalf(
 		submissionID,
 		req.Action,
 		claims.UserID,
@@ -687,9 +735,13 @@ func TransitionFormSubmission(w http.ResponseWriter, r *http.Request) {
 		userRole,
 		req.Comment,
 		req.Metadata,
+		delegation,
 	)
 	if err != nil {
 		log.Printf("❌ Error transitioning submission: %v", err)
+		if writeFormValidationError(w, err) {
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -722,7 +774,7 @@ func GetWorkflowHistory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	history, err := getWorkflowEngine().GetWorkflowHistory(submissionID)
+	history, err := scopedWorkflowEngine(r).GetWorkflowHistory(submissionID)
 	if err != nil {
 		log.Printf("❌ Error fetching history: %v", err)
 		http.Error(w, "failed to fetch history", http.StatusInternalServerError)
@@ -760,7 +812,7 @@ func GetWorkflowStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	stats, err := getWorkflowEngine().GetWorkflowStats(formCode, businessID)
+	stats, err := scopedWorkflowEngine(r).GetWorkflowStats(formCode, businessID)
 	if err != nil {
 		log.Printf("❌ Error fetching stats: %v", err)
 		http.Error(w, "failed to fetch stats", http.StatusInternalServerError)
@@ -803,11 +855,28 @@ func CreateWorkflowDefinition(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "workflow name is required", http.StatusBadRequest)
 		return
 	}
+	if err := workflow.ValidateActions(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := workflow.ValidateTimers(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	log.Printf("📝 Creating workflow: code=%s, name=%s, states=%d bytes, transitions=%d bytes",
 		workflow.Code, workflow.Name, len(workflow.States), len(workflow.Transitions))
 
-	if err := getWorkflowEngine().db.Create(&workflow).Error; err != nil {
+	// The first version is snapshotted with the definition so instances can be pinned to it
+	workflow.CurrentVersion = 1
+	err := scopedWorkflowEngine(r).db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&workflow).Error; err != nil {
+			return err
+		}
+		snapshot := workflow.Snapshot(claims.UserID)
+		return tx.Create(&snapshot).Error
+	})
+	if err != nil {
 		log.Printf("❌ Error creating workflow in DB: %v", err)
 		http.Error(w, "failed to create workflow: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -846,7 +915,7 @@ func GetAllWorkflows(w http.ResponseWriter, r *http.Request) {
 		}
 
 		var workflows []models.WorkflowDefinition
-		if err := getWorkflowEngine().db.Find(&workflows).Error; err != nil {
+		if err := scopedWorkflowEngine(r).db.Find(&workflows).Error; err != nil {
 			return nil, err
 		}
 
@@ -881,18 +950,34 @@ func UpdateWorkflowDefinition(w http.ResponseWriter, r *http.Request) {
 	workflowIdStr := vars["workflowId"]
 	// Fetch the existing workflow definition
 	var workflow models.WorkflowDefinition
-	if err := getWorkflowEngine().db.First(&workflow, "id = ?", workflowIdStr).Error; err != nil {
+	if err := scopedWorkflowEngine(r).db.First(&workflow, "id = ?", workflowIdStr).Error; err != nil {
 		http.Error(w, "failed to fetch workflow", http.StatusInternalServerError)
 		return
 	}
 
+	previous := workflow
+
 	// Update the workflow definition with the new data
 	if err := json.NewDecoder(r.Body).Decode(&workflow); err != nil {
 		http.Error(w, "invalid request body", http.StatusBadRequest)
 		return
 	}
+	workflow.ID = previous.ID
+	if err := workflow.ValidateActions(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := workflow.ValidateTimers(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	if err := getWorkflowEngine().db.Save(&workflow).Error; err != nil {
+	// Structural edits become a new version; in-flight instances stay on theirs
+	if err := saveWorkflowDefinition(scopedWorkflowEngine(r).db, &previous, &workflow, claims.UserID); err != nil {
+		if errors.Is(err, errWorkflowVersionConflict) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		http.Error(w, "failed to update workflow", http.StatusInternalServerError)
 		return
 	}
@@ -916,7 +1001,7 @@ func DeleteWorkflowDefinition(w http.ResponseWriter, r *http.Request) {
 	workflowIdStr := vars["workflowId"]
 
 	// Delete the workflow definition
-	if err := getWorkflowEngine().db.Delete(&models.WorkflowDefinition{}, "id = ?", workflowIdStr).Error; err != nil {
+	if err := scopedWorkflowEngine(r).db.Delete(&models.WorkflowDefinition{}, "id = ?", workflowIdStr).Error; err != nil {
 		http.Error(w, "failed to delete workflow", http.StatusInternalServerError)
 		return
 	}
@@ -924,3 +1009,203 @@ func DeleteWorkflowDefinition(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// applySiteScope limits a submission list to the sites a site-scoped user may see. It
+// writes a 403 and returns false when the requested site is outside that scope.
+func applySiteScope(w http.ResponseWriter, r *http.Request, filters map[string]interface{}) bool {
+	siteIDs, restricted := middleware.GetSiteScope(r)
+	if !restricted {
+		return true
+	}
+	if siteID, ok := filters["site_id"].(uuid.UUID); ok {
+		if !middleware.SiteInScope(r, &siteID) {
+			http.Error(w, "no access to this site", http.StatusForbidden)
+			return false
+		}
+		return true
+	}
+	filters["site_id"] = siteIDs
+	return true
+}
+
+// loadScopedWorkflowInstance loads a workflow instance and hides it (404) when it lies
+// outside the caller's data scope. It writes the error response and returns nil on failure.
+func loadScopedWorkflowInstance(w http.ResponseWriter, r *http.Request) *WorkflowInstance {
+	instanceID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid instance ID", http.StatusBadRequest)
+		return nil
+	}
+
+	instance, err := NewWorkflowRuntime().LoadInstance(instanceID)
+	if errors.Is(err, errWorkflowInstanceNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return nil
+	}
+	if err != nil {
+		log.Printf("❌ Error loading workflow instance %s: %v", instanceID, err)
+		http.Error(w, "failed to load workflow instance", http.StatusInternalServerError)
+		return nil
+	}
+	if !middleware.InDataScope(r, instance.BusinessVerticalID, instance.SiteID) {
+		http.Error(w, errWorkflowInstanceNotFound.Error(), http.StatusNotFound)
+		return nil
+	}
+	return instance
+}
+
+// GetWorkflowInstance returns a workflow instance's state, available actions and history
+// GET /api/v1/workflow/instances/{id}
+func GetWorkflowInstance(w http.ResponseWriter, r *http.Request) {
+	instance := loadScopedWorkflowInstance(w, r)
+	if instance == nil {
+		return
+	}
+
+	history, err := NewWorkflowRuntime().History(instance.ID)
+	if err != nil {
+		log.Printf("❌ Error fetching history: %v", err)
+		http.Error(w, "failed to fetch history", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"instance": instance,
+		"history":  history,
+	})
+}
+
+// GetWorkflowInstanceHistory returns the audit trail of a workflow instance: every state
+// change with who made it, when, the comment and how long the instance spent in the state
+// it left. Instances may be dedicated-table records or form submissions.
+// GET /api/v1/workflow/instances/{id}/history
+func GetWorkflowInstanceHistory(w http.ResponseWriter, r *http.Request) {
+	instanceID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "invalid instance ID", http.StatusBadRequest)
+		return
+	}
+
+	runtime := NewWorkflowRuntime()
+	summary := map[string]interface{}{"id": instanceID}
+	var createdAt time.Time
+	instance, err := runtime.LoadInstance(instanceID)
+	switch {
+	case err == nil:
+		if !middleware.InDataScope(r, instance.BusinessVerticalID, instance.SiteID) {
+			http.Error(w, errWorkflowInstanceNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		summary["form_code"] = instance.FormCode
+		summary["current_state"] = instance.CurrentState
+		summary["created_by"] = instance.CreatedBy
+		createdAt = instance.CreatedAt
+	case errors.Is(err, errWorkflowInstanceNotFound):
+		var submission models.FormSubmission
+		if err := middleware.WithDataScope(r, runtime.db).First(&submission, "id = ?", instanceID).Error; err != nil ||
+			!middleware.InDataScope(r, submission.BusinessVerticalID, submission.SiteID) {
+			http.Error(w, errWorkflowInstanceNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		summary["form_code"] = submission.FormCode
+		summary["current_state"] = submission.CurrentState
+		summary["created_by"] = submission.SubmittedBy
+		createdAt = submission.SubmittedAt
+	default:
+		log.Printf("❌ Error loading workflow instance %s: %v", instanceID, err)
+		http.Error(w, "failed to load workflow instance", http.StatusInternalServerError)
+		return
+	}
+
+	history, err := runtime.History(instanceID)
+	if err != nil {
+		log.Printf("❌ Error fetching history: %v", err)
+		http.Error(w, "failed to fetch history", http.StatusInternalServerError)
+		return
+	}
+	timeline := models.BuildWorkflowTimeline(history, createdAt)
+
+	summary["created_at"] = createdAt
+	enteredAt := createdAt
+	if len(timeline) > 0 {
+		enteredAt = timeline[len(timeline)-1].TransitionedAt
+	}
+	if !enteredAt.IsZero() {
+		summary["time_in_current_state_seconds"] = int64(time.Since(enteredAt).Seconds())
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"instance": summary,
+		"history":  timeline,
+		"count":    len(timeline),
+	})
+}
+
+// ExecuteWorkflowAction moves a workflow instance along the transition named by action
+// POST /api/v1/workflow/instances/{id}/actions/{action}
+func ExecuteWorkflowAction(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	user := middleware.GetUser(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// The body is optional: the action comes from the path
+	var req TransitionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	action := mux.Vars(r)["action"]
+
+	instance := loadScopedWorkflowInstance(w, r)
+	if instance == nil {
+		return
+	}
+
+	// Approvals guarded by a separation-of-duties rule are refused to users holding the
+	// conflicting permission in the instance's vertical
+	if transitionDef, err := instance.Workflow.FindTransition(instance.CurrentState, action); err == nil {
+		if permission := transitionDef.RequiredPermissionCode(); permission != "" {
+			reference := "workflow_instance:" + instance.ID.String() + ":" + action
+			if err := middleware.EnforceApprovalSoD(r, instance.BusinessVerticalID, permission, reference); err != nil {
+				middleware.WriteSoDViolation(w, err)
+				return
+			}
+		}
+	}
+
+	actor := WorkflowActor{
+		ID:          claims.UserID,
+		Name:        user.Name,
+		Permissions: middleware.GetEffectivePermissionsInVertical(r, instance.BusinessVerticalID),
+	}
+	if role := user.EffectiveRole(); role != nil {
+		actor.Role = role.Name
+	}
+
+	transition, err := NewWorkflowRuntime().ExecuteAction(instance, action, actor, req.Comment, req.Metadata)
+	if err != nil {
+		log.Printf("❌ Workflow action %s on %s failed: %v", action, instance.ID, err)
+		switch {
+		case errors.Is(err, errWorkflowActionForbidden):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, errWorkflowCommentRequired):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, errWorkflowActionInvalid), errors.Is(err, errWorkflowStateChanged):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, "failed to execute workflow action", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":    "transition successful",
+		"instance":   instance,
+		"transition": transition,
+	})
+}
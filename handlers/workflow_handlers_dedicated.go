@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strings"
@@ -21,6 +22,14 @@ func getWorkflowEngineDedicated() *WorkflowEngineDedicated {
 	return workflowEngineDedicated
 }
 
+// Lookups of TransitionFormSubmissionDedicated, replaced in tests
+var (
+	dedicatedTransitionPermission = func(formCode string, recordID uuid.UUID, action string) (string, uuid.UUID, error) {
+		return getWorkflowEngineDedicated().TransitionPermissionDedicated(formCode, recordID, action)
+	}
+	enforceApprovalSoD = middleware.EnforceApprovalSoD
+)
+
 // CreateFormSubmissionDedicated creates a new form submission in dedicated table
 // POST /api/v1/business/{businessCode}/forms/{formCode}/submissions/dedicated
 func CreateFormSubmissionDedicated(w http.ResponseWriter, r *http.Request) {
@@ -58,6 +67,11 @@ func CreateFormSubmissionDedicated(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !middleware.SiteInScope(r, req.SiteID) {
+		http.Error(w, "no access to this site", http.StatusForbidden)
+		return
+	}
+
 	log.Printf("📝 Creating form submission in dedicated table: %s for business: %s, user: %s", formCode, businessCode, claims.UserID)
 
 	// Create submission in dedicated table
@@ -70,6 +84,13 @@ func CreateFormSubmissionDedicated(w http.ResponseWriter, r *http.Request) {
 	)
 	if err != nil {
 		log.Printf("❌ Error creating submission: %v", err)
+		if writeFormValidationError(w, err) {
+			return
+		}
+		if errors.Is(err, errInvalidFormField) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -120,6 +141,9 @@ func GetFormSubmissionsDedicated(w http.ResponseWriter, r *http.Request) {
 			filters["site_id"] = id
 		}
 	}
+	if !applySiteScope(w, r, filters) {
+		return
+	}
 	if r.URL.Query().Get("my_submissions") == "true" {
 		filters["created_by"] = claims.UserID
 	}
@@ -228,7 +252,7 @@ func GetFormSubmissionDedicated(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Verify business context
-	if record.BusinessVerticalID != businessID {
+	if record.BusinessVerticalID != businessID || !middleware.SiteInScope(r, record.SiteID) {
 		http.Error(w, "forbidden", http.StatusForbidden)
 		return
 	}
@@ -274,6 +298,9 @@ func UpdateFormSubmissionDedicated(w http.ResponseWriter, r *http.Request) {
 	record, err := getWorkflowEngineDedicated().UpdateSubmissionDataDedicated(formCode, submissionID, req.FormData, claims.UserID)
 	if err != nil {
 		log.Printf("❌ Error updating submission: %v", err)
+		if writeFormValidationError(w, err) {
+			return
+		}
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -291,7 +318,6 @@ func UpdateFormSubmissionDedicated(w http.ResponseWriter, r *http.Request) {
 // POST /api/v1/business/{businessCode}/forms/{formCode}/submissions/dedicated/{submissionId}/transition
 func TransitionFormSubmissionDedicated(w http.ResponseWriter, r *http.Request) {
 	claims := middleware.GetClaims(r)
-	user := middleware.GetUser(r)
 	if claims == nil {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
@@ -313,9 +339,34 @@ func TransitionFormSubmissionDedicated(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	permission, businessID, err := dedicatedTransitionPermission(formCode, submissionID, req.Action)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	// Approvals guarded by a separation-of-duties rule are refused to users holding the
+	// conflicting permission in the record's vertical, delegated or not
+	if permission != "" {
+		if err := enforceApprovalSoD(r, businessID, permission, "form_record:"+formCode+":"+submissionID.String()+":"+req.Action); err != nil {
+			middleware.WriteSoDViolation(w, err)
+			return
+		}
+	}
+
 	// Use merged global + business-context permissions for transition authorization.
 	userPermissions := middleware.GetEffectivePermissions(r)
 
+	// Users without the permission may still decide under an approval delegation
+	delegation, err := workflowDelegationFor(claims.UserID, userPermissions, middleware.GetCurrentBusinessID(r), permission)
+	if err != nil {
+		http.Error(w, "failed to check approval delegations", http.StatusInternalServerError)
+		return
+	}
+	if delegation != nil {
+		userPermissions = append(userPermissions, permission)
+	}
+
 	// Validate transition
 	if err := getWorkflowEngineDedicated().ValidateTransitionDedicated(formCode, submissionID, req.Action, userPermissions); err != nil {
 		log.Printf("❌ Transition validation failed: %v", err)
@@ -324,13 +375,14 @@ func TransitionFormSubmissionDedicated(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get user role name
+	user := middleware.GetUser(r)
 	userRole := ""
-	if user.RoleModel != nil {
-		userRole = user.RoleModel.Name
+	if role := user.EffectiveRole(); role != nil {
+		userRole = role.Name
 	}
 
 	// Perform transition
-	record, err := getWorkflowEngineDedicated().TransitionStateDedicated(
+	record, err := getWorkflowEngineDedicated().TransitionStateDedicatedOnBehalf(
 		formCode,
 		submissionID,
 		req.Action,
@@ -339,9 +391,13 @@ func TransitionFormSubmissionDedicated(w http.ResponseWriter, r *http.Request) {
 		userRole,
 		req.Comment,
 		req.Metadata,
+		delegation,
 	)
 	if err != nil {
 		log.Printf("❌ Error transitioning submission: %v", err)
+		if writeFormValidationError(w, err) {
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
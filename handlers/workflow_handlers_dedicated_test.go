@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+func TestTransitionFormSubmissionDedicatedRefusesSoDConflict(t *testing.T) {
+	recordID := uuid.New()
+	verticalID := uuid.New()
+
+	restorePermission, restoreSoD := dedicatedTransitionPermission, enforceApprovalSoD
+	defer func() { dedicatedTransitionPermission, enforceApprovalSoD = restorePermission, restoreSoD }()
+
+	dedicatedTransitionPermission = func(formCode string, id uuid.UUID, action string) (string, uuid.UUID, error) {
+		if formCode != "purchase_request" || id != recordID || action != "approve" {
+			t.Fatalf("unexpected lookup %s %s %s", formCode, id, action)
+		}
+		return "purchase:approve", verticalID, nil
+	}
+	var checkedVertical uuid.UUID
+	var checkedPermission string
+	enforceApprovalSoD = func(r *http.Request, businessID uuid.UUID, permission, reference string) error {
+		checkedVertical, checkedPermission = businessID, permission
+		return &middleware.SoDViolationError{Conflicts: []models.SoDRule{{
+			Name:        "Raise and approve purchases",
+			PermissionA: "purchase:create",
+			PermissionB: "purchase:approve",
+		}}}
+	}
+
+	token, err := middleware.GenerateToken(uuid.NewString(), "user", "Requester", "9999999999", "")
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/transition", strings.NewReader(`{"action":"approve"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req = mux.SetURLVars(req, map[string]string{"formCode": "purchase_request", "submissionId": recordID.String()})
+	rr := httptest.NewRecorder()
+
+	// With no database behind the engine, reaching validation or the transition would panic
+	middleware.JWTMiddleware(http.HandlerFunc(TransitionFormSubmissionDedicated)).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d: %s", rr.Code, http.StatusConflict, rr.Body.String())
+	}
+	if checkedVertical != verticalID || checkedPermission != "purchase:approve" {
+		t.Errorf("SoD checked %s in %s, want purchase:approve in the record's vertical %s", checkedPermission, checkedVertical, verticalID)
+	}
+	if !strings.Contains(rr.Body.String(), "separation of duties violation") {
+		t.Errorf("body = %s, want the SoD violation", rr.Body.String())
+	}
+}
@@ -0,0 +1,423 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+const (
+	defaultWorkflowInboxLimit = 50
+	maxWorkflowInboxLimit     = 200
+)
+
+// workflowInboxItem is a record waiting on an action the caller may take
+type workflowInboxItem struct {
+	Type               string                  `json:"type"` // form, task
+	ID                 uuid.UUID               `json:"id"`
+	FormCode           string                  `json:"form_code,omitempty"`
+	Title              string                  `json:"title,omitempty"`
+	BusinessVerticalID uuid.UUID               `json:"business_vertical_id"`
+	SiteID             *uuid.UUID              `json:"site_id,omitempty"`
+	WorkflowID         uuid.UUID               `json:"workflow_id"`
+	WorkflowVersion    int                     `json:"workflow_version,omitempty"` // tasks are not pinned
+	CurrentState       string                  `json:"current_state"`
+	CreatedBy          string                  `json:"created_by"`
+	CreatedAt          time.Time               `json:"created_at"`
+	Actions            []models.WorkflowAction `json:"actions"`
+	Delegated          bool                    `json:"delegated,omitempty"` // actionable only under an approval delegation
+}
+
+// workflowInboxRow is the scanned shape of an inbox item
+type workflowInboxRow struct {
+	ID                 uuid.UUID
+	FormCode           string
+	Title              string
+	BusinessVerticalID uuid.UUID
+	SiteID             *uuid.UUID
+	WorkflowID         uuid.UUID
+	WorkflowVersion    int
+	CurrentState       string
+	CreatedBy          string
+	CreatedAt          time.Time
+}
+
+func (row workflowInboxRow) item(itemType string) workflowInboxItem {
+	return workflowInboxItem{
+		Type:               itemType,
+		ID:                 row.ID,
+		FormCode:           row.FormCode,
+		Title:              row.Title,
+		BusinessVerticalID: row.BusinessVerticalID,
+		SiteID:             row.SiteID,
+		WorkflowID:         row.WorkflowID,
+		WorkflowVersion:    row.WorkflowVersion,
+		CurrentState:       row.CurrentState,
+		CreatedBy:          row.CreatedBy,
+		CreatedAt:          row.CreatedAt,
+	}
+}
+
+// inboxStates holds, for one vertical and workflow version, the actions the caller may take
+// from each state
+type inboxStates struct {
+	verticalID uuid.UUID
+	workflowID uuid.UUID
+	version    int
+	current    bool
+	actions    map[string][]models.WorkflowAction
+	delegated  map[string]bool
+}
+
+func (s *inboxStates) stateList() []string {
+	states := make([]string, 0, len(s.actions))
+	for state := range s.actions {
+		states = append(states, state)
+	}
+	sort.Strings(states)
+	return states
+}
+
+func inboxKey(verticalID, workflowID uuid.UUID, version int) string {
+	return fmt.Sprintf("%s/%s@%d", verticalID, workflowID, version)
+}
+
+// GetWorkflowInbox lists the records across form submissions, dedicated form tables (which
+// hold purchases and the other form-based documents) and project tasks that sit in a state
+// the caller can move on, oldest first, with counts per form and type for badges. Only
+// transitions that require a permission count; those open to anyone are not approvals.
+// ?form_code= narrows to one form, ?type=form|task to one type, ?limit= caps the items
+// (default 50, max 200) and ?counts_only=true skips them.
+// GET /api/v1/workflow/inbox
+func GetWorkflowInbox(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	limit := defaultWorkflowInboxLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		if parsed, err := strconv.Atoi(raw); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+	if limit > maxWorkflowInboxLimit {
+		limit = maxWorkflowInboxLimit
+	}
+	countsOnly := strings.EqualFold(r.URL.Query().Get("counts_only"), "true")
+	formCode := strings.TrimSpace(r.URL.Query().Get("form_code"))
+	itemType := strings.TrimSpace(r.URL.Query().Get("type"))
+
+	targets, err := workflowInboxTargets(r, claims.UserID)
+	if err != nil {
+		log.Printf("❌ Failed to resolve workflow inbox for %s: %v", claims.UserID, err)
+		http.Error(w, "failed to load workflow inbox", http.StatusInternalServerError)
+		return
+	}
+
+	byForm := map[string]int64{}
+	byType := map[string]int64{"form": 0, "task": 0}
+	var items []workflowInboxItem
+
+	if len(targets) > 0 && itemType != "task" {
+		siteIDs, siteRestricted := middleware.GetSiteScope(r)
+		tables, err := workflowInboxTables(targets)
+		if err != nil {
+			log.Printf("❌ Failed to list workflow inbox tables: %v", err)
+			http.Error(w, "failed to load workflow inbox", http.StatusInternalServerError)
+			return
+		}
+		for _, table := range tables {
+			query := func() *gorm.DB {
+				q := middleware.ScopedDB(r).Table(table.fullName + " AS i").Where("i.deleted_at IS NULL")
+				q = whereInboxStates(q, targets, "i.business_vertical_id", "COALESCE(i.workflow_version, 1)", "i.workflow_id", "i.current_state", false)
+				if siteRestricted {
+					q = q.Where("i.site_id IN ?", siteIDs)
+				}
+				if formCode != "" {
+					q = q.Where("i.form_code = ?", formCode)
+				}
+				if table.submissions {
+					// Task approvals run on submissions; they are listed as tasks
+					q = q.Where("i.id NOT IN (SELECT form_submission_id FROM tasks WHERE form_submission_id IS NOT NULL)")
+				}
+				return q
+			}
+
+			var counts []struct {
+				FormCode string
+				Count    int64
+			}
+			if err := query().Select("i.form_code, COUNT(*) AS count").Group("i.form_code").Scan(&counts).Error; err != nil {
+				log.Printf("❌ Failed to count workflow inbox in %s: %v", table.fullName, err)
+				http.Error(w, "failed to load workflow inbox", http.StatusInternalServerError)
+				return
+			}
+			for _, c := range counts {
+				byForm[c.FormCode] += c.Count
+				byType["form"] += c.Count
+			}
+			if countsOnly {
+				continue
+			}
+
+			var rows []workflowInboxRow
+			if err := query().
+				Select(fmt.Sprintf("i.id, i.form_code, i.business_vertical_id, i.site_id, i.workflow_id, "+
+					"COALESCE(i.workflow_version, 1) AS workflow_version, i.current_state, i.%s AS created_by, i.%s AS created_at",
+					table.createdByColumn, table.createdAtColumn)).
+				Order("created_at ASC").Limit(limit).Scan(&rows).Error; err != nil {
+				log.Printf("❌ Failed to load workflow inbox from %s: %v", table.fullName, err)
+				http.Error(w, "failed to load workflow inbox", http.StatusInternalServerError)
+				return
+			}
+			for _, row := range rows {
+				items = append(items, row.item("form"))
+			}
+		}
+	}
+
+	if len(targets) > 0 && formCode == "" && itemType != "form" {
+		query := func() *gorm.DB {
+			q := middleware.ScopedDB(r).Table("tasks AS t").Joins("JOIN projects p ON p.id = t.project_id").Where("t.deleted_at IS NULL")
+			return whereInboxStates(q, targets, "p.business_vertical_id", "", "t.workflow_id", "t.current_state", true)
+		}
+		var taskCount int64
+		if err := query().Count(&taskCount).Error; err != nil {
+			log.Printf("❌ Failed to count workflow inbox tasks: %v", err)
+			http.Error(w, "failed to load workflow inbox", http.StatusInternalServerError)
+			return
+		}
+		byType["task"] = taskCount
+
+		if !countsOnly && taskCount > 0 {
+			var rows []workflowInboxRow
+			if err := query().
+				Select("t.id, t.title, p.business_vertical_id, t.workflow_id, t.current_state, t.created_by, t.created_at").
+				Order("t.created_at ASC").Limit(limit).Scan(&rows).Error; err != nil {
+				log.Printf("❌ Failed to load workflow inbox tasks: %v", err)
+				http.Error(w, "failed to load workflow inbox", http.StatusInternalServerError)
+				return
+			}
+			for _, row := range rows {
+				items = append(items, row.item("task"))
+			}
+		}
+	}
+
+	response := map[string]interface{}{
+		"total": byType["form"] + byType["task"],
+		"counts": map[string]interface{}{
+			"by_form": byForm,
+			"by_type": byType,
+		},
+	}
+	if !countsOnly {
+		sort.SliceStable(items, func(i, j int) bool { return items[i].CreatedAt.Before(items[j].CreatedAt) })
+		if len(items) > limit {
+			items = items[:limit]
+		}
+		for i := range items {
+			item := &items[i]
+			if target, ok := targets[inboxKey(item.BusinessVerticalID, item.WorkflowID, item.WorkflowVersion)]; ok {
+				item.Actions = target.actions[item.CurrentState]
+				item.Delegated = target.delegated[item.CurrentState]
+			}
+		}
+		if items == nil {
+			items = []workflowInboxItem{}
+		}
+		response["items"] = items
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// workflowInboxTargets works out, per vertical in the caller's scope and per version of each
+// active workflow, the states the caller can act on: those with an outgoing transition whose
+// permission the caller holds there, or may use under an approval delegation. Each current
+// version is also keyed under version 0 for tasks, which are not pinned to versions.
+func workflowInboxTargets(r *http.Request, userID string) (map[string]*inboxStates, error) {
+	verticals, err := middleware.DataScopeVerticals(r)
+	if err != nil || len(verticals) == 0 {
+		return nil, err
+	}
+
+	var workflows []models.WorkflowDefinition
+	if err := middleware.ScopedDB(r).Where("is_active = ?", true).Find(&workflows).Error; err != nil {
+		return nil, err
+	}
+	var definitions []*models.WorkflowDefinition
+	current := make(map[*models.WorkflowDefinition]bool)
+	for i := range workflows {
+		versions, err := workflowAllVersions(middleware.ScopedDB(r), &workflows[i])
+		if err != nil {
+			return nil, err
+		}
+		current[versions[0]] = true
+		definitions = append(definitions, versions...)
+	}
+
+	targets := make(map[string]*inboxStates)
+	for _, verticalID := range verticals {
+		permissions := middleware.GetEffectivePermissionsInVertical(r, verticalID)
+		delegatedPermission := make(map[string]bool)
+		allowed := func(permission string) (ok, delegated bool) {
+			if hasWorkflowPermission(permissions, permission) {
+				return true, false
+			}
+			if cached, seen := delegatedPermission[permission]; seen {
+				return cached, cached
+			}
+			delegation, err := workflowDelegationFor(userID, permissions, verticalID, permission)
+			delegatedPermission[permission] = err == nil && delegation != nil
+			return delegatedPermission[permission], delegatedPermission[permission]
+		}
+
+		for _, workflow := range definitions {
+			transitions, err := workflow.ParseTransitions()
+			if err != nil {
+				continue
+			}
+			target := &inboxStates{
+				verticalID: verticalID,
+				workflowID: workflow.ID,
+				version:    workflow.CurrentVersion,
+				current:    current[workflow],
+				actions:    make(map[string][]models.WorkflowAction),
+				delegated:  make(map[string]bool),
+			}
+			for _, t := range transitions {
+				permission := t.RequiredPermissionCode()
+				if permission == "" {
+					continue
+				}
+				ok, delegated := allowed(permission)
+				if !ok {
+					continue
+				}
+				label := t.Label
+				if label == "" {
+					label = t.Action
+				}
+				target.actions[t.From] = append(target.actions[t.From], models.WorkflowAction{
+					Action:          t.Action,
+					Label:           label,
+					ToState:         t.To,
+					RequiresComment: t.RequiresComment,
+					Permission:      permission,
+				})
+				if _, seen := target.delegated[t.From]; !seen || !delegated {
+					target.delegated[t.From] = delegated
+				}
+			}
+			if len(target.actions) == 0 {
+				continue
+			}
+			targets[inboxKey(verticalID, workflow.ID, workflow.CurrentVersion)] = target
+			if target.current {
+				targets[inboxKey(verticalID, workflow.ID, 0)] = target
+			}
+		}
+	}
+	return targets, nil
+}
+
+// whereInboxStates restricts q to rows in an actionable state of their vertical and workflow
+// version. Tasks have no version column and match the current versions only.
+func whereInboxStates(q *gorm.DB, targets map[string]*inboxStates, verticalColumn, versionColumn, workflowColumn, stateColumn string, currentOnly bool) *gorm.DB {
+	var conditions []string
+	var args []interface{}
+	keys := make([]string, 0, len(targets))
+	for key := range targets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		target := targets[key]
+		if strings.HasSuffix(key, "@0") {
+			continue // alias of a current version
+		}
+		if currentOnly {
+			if !target.current {
+				continue
+			}
+			conditions = append(conditions, fmt.Sprintf("(%s = ? AND %s = ? AND %s IN ?)", verticalColumn, workflowColumn, stateColumn))
+			args = append(args, target.verticalID, target.workflowID, target.stateList())
+			continue
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s = ? AND %s = ? AND %s = ? AND %s IN ?)", verticalColumn, workflowColumn, versionColumn, stateColumn))
+		args = append(args, target.verticalID, target.workflowID, target.version, target.stateList())
+	}
+	if len(conditions) == 0 {
+		return q.Where("1 = 0")
+	}
+	return q.Where(strings.Join(conditions, " OR "), args...)
+}
+
+type workflowInboxTable struct {
+	fullName        string
+	submissions     bool
+	createdByColumn string
+	createdAtColumn string
+}
+
+// workflowInboxTables lists form_submissions and the existing dedicated tables of the forms
+// whose workflow appears in targets
+func workflowInboxTables(targets map[string]*inboxStates) ([]workflowInboxTable, error) {
+	tables := []workflowInboxTable{{
+		fullName:        models.FormSubmission{}.TableName(),
+		submissions:     true,
+		createdByColumn: "submitted_by",
+		createdAtColumn: "submitted_at",
+	}}
+
+	workflowIDs := make(map[uuid.UUID]bool)
+	for _, target := range targets {
+		workflowIDs[target.workflowID] = true
+	}
+	ids := make([]uuid.UUID, 0, len(workflowIDs))
+	for id := range workflowIDs {
+		ids = append(ids, id)
+	}
+
+	var forms []models.AppForm
+	if err := config.DB.Preload("Module").
+		Where("workflow_id IN ? AND db_table_name <> ''", ids).
+		Order("code").Find(&forms).Error; err != nil {
+		return nil, err
+	}
+	tableManager := NewFormTableManager()
+	seen := make(map[string]bool)
+	for _, form := range forms {
+		schemaName := ""
+		if form.Module != nil {
+			schemaName = form.Module.SchemaName
+		}
+		fullName := tableManager.schemaManager.GetFullTableName(schemaName, form.DBTableName)
+		if seen[fullName] {
+			continue
+		}
+		seen[fullName] = true
+		if exists, err := tableManager.TableExistsInSchema(schemaName, form.DBTableName); err != nil || !exists {
+			continue
+		}
+		tables = append(tables, workflowInboxTable{
+			fullName:        fullName,
+			createdByColumn: "created_by",
+			createdAtColumn: "created_at",
+		})
+	}
+	return tables, nil
+}
@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/hooks"
+	"p9e.in/ugcl/utils"
+)
+
+var (
+	errWorkflowInstanceNotFound = errors.New("workflow instance not found")
+	errWorkflowActionForbidden  = errors.New("insufficient permissions")
+	errWorkflowActionInvalid    = errors.New("invalid workflow action")
+	errWorkflowStateChanged     = errors.New("workflow instance state changed concurrently")
+	errWorkflowCommentRequired  = errors.New("comment is required for this action")
+)
+
+// WorkflowRuntime executes WorkflowDefinition transitions for any record held in a form's
+// dedicated table. Such a record is a workflow instance: its form names the workflow and
+// the record's current_state column holds its state.
+type WorkflowRuntime struct {
+	db           *gorm.DB
+	tableManager *FormTableManager
+}
+
+// NewWorkflowRuntime creates a workflow runtime over the form tables
+func NewWorkflowRuntime() *WorkflowRuntime {
+	return &WorkflowRuntime{
+		db:           config.DB,
+		tableManager: NewFormTableManager(),
+	}
+}
+
+// WorkflowInstance is a dedicated-table record together with its form and workflow
+type WorkflowInstance struct {
+	ID                 uuid.UUID                  `json:"id"`
+	FormCode           string                     `json:"form_code"`
+	TableName          string                     `json:"table_name"`
+	SchemaName         string                     `json:"schema_name,omitempty"`
+	BusinessVerticalID uuid.UUID                  `json:"business_vertical_id"`
+	SiteID             *uuid.UUID                 `json:"site_id,omitempty"`
+	CurrentState       string                     `json:"current_state"`
+	CreatedBy          string                     `json:"created_by"`
+	CreatedAt          time.Time                  `json:"created_at"`
+	AvailableActions   []models.WorkflowAction    `json:"available_actions"`
+	Workflow           *models.WorkflowDefinition `json:"workflow"`
+	Form               *models.AppForm            `json:"-"`
+	Data               map[string]interface{}     `json:"-"`
+}
+
+// WorkflowActor identifies who executes an action and what they may do
+type WorkflowActor struct {
+	ID          string
+	Name        string
+	Role        string
+	Permissions []string
+}
+
+// LoadInstance finds the record with the given ID among the dedicated tables of active
+// forms that have a workflow.
+func (rt *WorkflowRuntime) LoadInstance(id uuid.UUID) (*WorkflowInstance, error) {
+	var forms []models.AppForm
+	if err := rt.db.Preload("Module").
+		Where("is_active = ? AND workflow_id IS NOT NULL AND db_table_name <> ''", true).
+		Find(&forms).Error; err != nil {
+		return nil, fmt.Errorf("failed to load forms: %w", err)
+	}
+
+	for i := range forms {
+		form := &forms[i]
+		// Form tables live in their module's schema when it has one
+		schemaName := ""
+		if form.Module != nil {
+			schemaName = form.Module.SchemaName
+		}
+		data, err := rt.tableManager.GetFormDataInSchema(schemaName, form.DBTableName, id)
+		if err != nil {
+			continue // not in this table (or the table is not created yet)
+		}
+
+		instance := &WorkflowInstance{
+			ID:         id,
+			FormCode:   form.Code,
+			TableName:  form.DBTableName,
+			SchemaName: schemaName,
+			Form:       form,
+			Data:       data,
+		}
+		instance.BusinessVerticalID, _ = columnUUID(data["business_vertical_id"])
+		if siteID, ok := columnUUID(data["site_id"]); ok {
+			instance.SiteID = &siteID
+		}
+		instance.CurrentState = columnString(data["current_state"])
+		instance.CreatedBy = columnString(data["created_by"])
+		instance.CreatedAt, _ = data["created_at"].(time.Time)
+
+		// The record keeps the workflow it was created under (older rows fall back to the form's)
+		workflowID := *form.WorkflowID
+		if recordWorkflowID, ok := columnUUID(data["workflow_id"]); ok {
+			workflowID = recordWorkflowID
+		}
+		// and runs under the version it is pinned to
+		var version *int
+		if pinned, ok := columnInt(data["workflow_version"]); ok {
+			version = &pinned
+		}
+		workflow, err := loadPinnedWorkflow(rt.db, workflowID, version)
+		if err != nil {
+			return nil, fmt.Errorf("workflow not found: %w", err)
+		}
+		instance.Workflow = workflow
+
+		submission := models.FormSubmission{CurrentState: instance.CurrentState}
+		instance.AvailableActions, _ = submission.GetAvailableActions(workflow)
+		return instance, nil
+	}
+
+	return nil, errWorkflowInstanceNotFound
+}
+
+// ExecuteAction validates action against the instance's workflow states and transitions
+// and the actor's permissions, then moves the record to the target state and records the
+// transition in one transaction.
+func (rt *WorkflowRuntime) ExecuteAction(
+	instance *WorkflowInstance,
+	action string,
+	actor WorkflowActor,
+	comment string,
+	metadata map[string]interface{},
+) (*models.WorkflowTransition, error) {
+	transitionDef, err := instance.Workflow.FindTransition(instance.CurrentState, action)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errWorkflowActionInvalid, err)
+	}
+	// Without the permission the actor may still decide on behalf of a delegator who has it
+	required := transitionDef.RequiredPermissionCode()
+	delegation, err := workflowDelegationFor(actor.ID, actor.Permissions, instance.BusinessVerticalID, required)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check approval delegations: %w", err)
+	}
+	if required != "" && delegation == nil && !hasWorkflowPermission(actor.Permissions, required) {
+		return nil, fmt.Errorf("%w: requires '%s'", errWorkflowActionForbidden, required)
+	}
+	if transitionDef.RequiresComment && comment == "" {
+		return nil, errWorkflowCommentRequired
+	}
+
+	metadataJSON, _ := json.Marshal(metadata)
+	transition := models.WorkflowTransition{
+		SubmissionID:   instance.ID,
+		FromState:      instance.CurrentState,
+		ToState:        transitionDef.To,
+		Action:         action,
+		ActorID:        actor.ID,
+		ActorName:      actor.Name,
+		ActorRole:      actor.Role,
+		Comment:        comment,
+		Metadata:       metadataJSON,
+		TransitionedAt: time.Now(),
+	}
+
+	event := workflowActionEvent{
+		WorkflowTransitionEvent: hooks.WorkflowTransitionEvent{
+			FormCode:           instance.FormCode,
+			SubmissionID:       instance.ID,
+			TableName:          instance.TableName,
+			BusinessVerticalID: instance.BusinessVerticalID,
+			FromState:          transition.FromState,
+			ToState:            transition.ToState,
+			Action:             action,
+			ActorID:            actor.ID,
+			ActorName:          actor.Name,
+			ActorRole:          actor.Role,
+			Comment:            comment,
+			Metadata:           metadata,
+			TransitionedAt:     transition.TransitionedAt,
+		},
+		FormID:      instance.Form.ID,
+		WorkflowID:  instance.Workflow.ID,
+		SiteID:      instance.SiteID,
+		SubmittedBy: instance.CreatedBy,
+		FormData:    instance.Data,
+	}
+
+	queued := 0
+	err = rt.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the record so concurrent actions cannot both leave the same state
+		fullTableName := rt.tableManager.schemaManager.GetFullTableName(instance.SchemaName, instance.TableName)
+		var current string
+		if err := tx.Table(fullTableName).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND deleted_at IS NULL", instance.ID).
+			Pluck("current_state", &current).Error; err != nil {
+			return fmt.Errorf("failed to lock workflow instance: %w", err)
+		}
+		if current != instance.CurrentState {
+			return errWorkflowStateChanged
+		}
+
+		tableManager := &FormTableManager{db: tx, schemaManager: rt.tableManager.schemaManager}
+		if err := tableManager.UpdateWorkflowStateInSchema(instance.SchemaName, instance.TableName, instance.ID, transitionDef.To, actor.ID); err != nil {
+			return err
+		}
+		if err := recordDelegatedDecision(tx, &transition, delegation); err != nil {
+			return fmt.Errorf("failed to record delegation use: %w", err)
+		}
+		if err := tx.Create(&transition).Error; err != nil {
+			return fmt.Errorf("failed to create transition record: %w", err)
+		}
+
+		event.TransitionID = transition.ID
+		var err error
+		queued, err = applyTransitionActions(tx, transitionDef.Actions, &event, func(tx *gorm.DB, field string, value interface{}) error {
+			tm := &FormTableManager{db: tx, schemaManager: rt.tableManager.schemaManager}
+			return tm.UpdateFormDataInSchema(instance.SchemaName, instance.TableName, instance.ID, map[string]interface{}{field: value}, actor.ID)
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("✅ Workflow instance %s in %s: %s -> %s (action: %s, actor: %s)",
+		instance.ID, instance.TableName, transition.FromState, transition.ToState, action, actor.Name)
+	dispatchQueuedWorkflowActions(queued)
+	notifyDelegatedDecision(delegation, &transition, instance.FormCode)
+
+	notifService := NewNotificationService()
+	formData, _ := json.Marshal(event.FormData)
+	submission := &models.FormSubmission{
+		ID:                 instance.ID,
+		FormCode:           instance.FormCode,
+		FormID:             instance.Form.ID,
+		BusinessVerticalID: instance.BusinessVerticalID,
+		SiteID:             instance.SiteID,
+		WorkflowID:         &instance.Workflow.ID,
+		CurrentState:       transition.ToState,
+		SubmittedBy:        instance.CreatedBy,
+		FormData:           formData,
+		Form:               instance.Form,
+		Workflow:           instance.Workflow,
+	}
+	if err := notifService.ProcessTransitionNotifications(submission, &transition, instance.Workflow, transitionDef, actor.Name); err != nil {
+		log.Printf("⚠️  Failed to process notifications: %v", err)
+	}
+
+	hooks.FireWorkflowTransition(event.WorkflowTransitionEvent)
+
+	instance.CurrentState = transition.ToState
+	next := models.FormSubmission{CurrentState: instance.CurrentState}
+	instance.AvailableActions, _ = next.GetAvailableActions(instance.Workflow)
+	return &transition, nil
+}
+
+// History returns the instance's transitions, oldest first
+func (rt *WorkflowRuntime) History(instanceID uuid.UUID) ([]models.WorkflowTransition, error) {
+	var transitions []models.WorkflowTransition
+	if err := rt.db.
+		Where("submission_id = ?", instanceID).
+		Order("transitioned_at ASC").
+		Find(&transitions).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch workflow history: %w", err)
+	}
+	return transitions, nil
+}
+
+func hasWorkflowPermission(userPermissions []string, required string) bool {
+	for _, perm := range userPermissions {
+		if perm == "admin_all" || perm == "*:*:*" || utils.MatchesPermission(perm, required) {
+			return true
+		}
+	}
+	return false
+}
+
+// columnUUID reads a uuid column scanned into interface{}, which drivers return as text
+// or as raw bytes.
+func columnUUID(value interface{}) (uuid.UUID, bool) {
+	switch v := value.(type) {
+	case [16]byte:
+		return uuid.UUID(v), true
+	case []byte:
+		if len(v) == 16 {
+			id, err := uuid.FromBytes(v)
+			return id, err == nil
+		}
+		id, err := uuid.ParseBytes(v)
+		return id, err == nil
+	case string:
+		id, err := uuid.Parse(v)
+		return id, err == nil
+	}
+	return uuid.Nil, false
+}
+
+func columnString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case nil:
+		return ""
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// columnInt reads an integer column scanned into interface{}
+func columnInt(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case int64:
+		return int(v), true
+	case int32:
+		return int(v), true
+	case int:
+		return v, true
+	}
+	return 0, false
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/jobrunner"
+)
+
+// workflowSystemActor is the actor recorded for transitions the scheduler takes
+var workflowSystemActor = WorkflowActor{
+	ID:          "system",
+	Name:        "System",
+	Role:        "system",
+	Permissions: []string{"*:*:*"},
+}
+
+// workflowTimerBatch caps how many instances one timer fires per run
+const workflowTimerBatch = 100
+
+// WorkflowTimerScheduler takes transitions that declare a timer once an instance has been
+// in the transition's from-state for the timer's duration.
+type WorkflowTimerScheduler struct {
+	db *gorm.DB
+	*jobrunner.Runner
+}
+
+// NewWorkflowTimerScheduler creates the workflow timer scheduler
+func NewWorkflowTimerScheduler() *WorkflowTimerScheduler {
+	s := &WorkflowTimerScheduler{db: config.DB}
+	s.Runner = jobrunner.New("Workflow timer scheduler", func() {
+		if n, err := s.RunDue(time.Now()); err != nil {
+			log.Printf("Error running workflow timers: %v", err)
+		} else if n > 0 {
+			log.Printf("Workflow timer scheduler: took %d automatic transitions", n)
+		}
+	})
+	return s
+}
+
+// RunDue takes every timed transition that is due and returns how many were taken. Each
+// version of a workflow is scanned with its own timers, since instances run under the
+// version they are pinned to.
+func (s *WorkflowTimerScheduler) RunDue(now time.Time) (int, error) {
+	var workflows []models.WorkflowDefinition
+	if err := s.db.Where("is_active = ?", true).Find(&workflows).Error; err != nil {
+		return 0, err
+	}
+
+	taken := 0
+	for i := range workflows {
+		versions, err := workflowAllVersions(s.db, &workflows[i])
+		if err != nil {
+			log.Printf("⚠️  Failed to load versions of workflow %s: %v", workflows[i].Code, err)
+			continue
+		}
+		for _, workflow := range versions {
+			transitions, err := workflow.ParseTransitions()
+			if err != nil {
+				continue
+			}
+			for _, t := range transitions {
+				if t.Timer == nil {
+					continue
+				}
+				after, err := t.Timer.Duration()
+				if err != nil {
+					continue
+				}
+				taken += s.fireTimer(workflow, t, after, now)
+			}
+		}
+	}
+	return taken, nil
+}
+
+// fireTimer takes the timed transition for instances of the workflow version that entered
+// its from-state before now minus after, in form_submissions and in the dedicated tables of
+// the forms using the workflow
+func (s *WorkflowTimerScheduler) fireTimer(workflow *models.WorkflowDefinition, t models.WorkflowTransitionDef, after time.Duration, now time.Time) int {
+	cutoff := now.Add(-after)
+	comment := fmt.Sprintf("Automatically taken after %s in '%s'", t.Timer.After, t.From)
+	metadata := map[string]interface{}{"automatic": true, "timer": t.Timer.After}
+	taken := 0
+
+	submissionIDs, err := s.dueInstances("", models.FormSubmission{}.TableName(), "submitted_at", workflow, t, cutoff, now)
+	if err != nil {
+		log.Printf("⚠️  Failed to find due '%s' timers of workflow %s: %v", t.Action, workflow.Code, err)
+	}
+	for _, id := range submissionIDs {
+		if _, err := getWorkflowEngine().TransitionState(id, t.Action, workflowSystemActor.ID, workflowSystemActor.Name,
+			workflowSystemActor.Role, comment, metadata); err != nil {
+			log.Printf("⚠️  Timer '%s' on submission %s failed: %v", t.Action, id, err)
+			s.recordFailure(id, t.Action, err, now)
+			continue
+		}
+		s.clearFailure(id, t.Action)
+		taken++
+	}
+
+	var forms []models.AppForm
+	if err := s.db.Preload("Module").Where("workflow_id = ? AND db_table_name <> ''", workflow.ID).Find(&forms).Error; err != nil {
+		log.Printf("⚠️  Failed to load forms of workflow %s: %v", workflow.Code, err)
+		return taken
+	}
+	runtime := NewWorkflowRuntime()
+	for _, form := range forms {
+		schemaName := ""
+		if form.Module != nil {
+			schemaName = form.Module.SchemaName
+		}
+		if exists, err := runtime.tableManager.TableExistsInSchema(schemaName, form.DBTableName); err != nil || !exists {
+			continue
+		}
+		recordIDs, err := s.dueInstances(schemaName, form.DBTableName, "created_at", workflow, t, cutoff, now)
+		if err != nil {
+			log.Printf("⚠️  Failed to find due '%s' timers in %s: %v", t.Action, form.DBTableName, err)
+			continue
+		}
+		for _, id := range recordIDs {
+			instance, err := runtime.LoadInstance(id)
+			if err != nil {
+				log.Printf("⚠️  Timer '%s' could not load instance %s: %v", t.Action, id, err)
+				s.recordFailure(id, t.Action, err, now)
+				continue
+			}
+			if _, err := runtime.ExecuteAction(instance, t.Action, workflowSystemActor, comment, metadata); err != nil {
+				log.Printf("⚠️  Timer '%s' on instance %s failed: %v", t.Action, id, err)
+				s.recordFailure(id, t.Action, err, now)
+				continue
+			}
+			s.clearFailure(id, t.Action)
+			taken++
+		}
+	}
+	return taken
+}
+
+// dueInstances returns instances of the workflow version in the timed transition's
+// from-state that entered it, by their latest transition or else their creation, no later
+// than cutoff, longest waiting first. Instances whose last attempt at the timer failed are
+// left out until their retry is due.
+func (s *WorkflowTimerScheduler) dueInstances(schemaName, table, createdColumn string, workflow *models.WorkflowDefinition, t models.WorkflowTransitionDef, cutoff, now time.Time) ([]uuid.UUID, error) {
+	fullTableName := NewSchemaManager().GetFullTableName(schemaName, table)
+	enteredAt := fmt.Sprintf("COALESCE((SELECT MAX(wt.transitioned_at) FROM workflow_transitions wt WHERE wt.submission_id = i.id), i.%s)", createdColumn)
+	var ids []uuid.UUID
+	err := s.db.Table(fullTableName+" AS i").
+		Where("i.workflow_id = ? AND COALESCE(i.workflow_version, 1) = ? AND i.current_state = ? AND i.deleted_at IS NULL",
+			workflow.ID, workflow.CurrentVersion, t.From).
+		Where(enteredAt+" <= ?", cutoff).
+		Where("NOT EXISTS (SELECT 1 FROM workflow_timer_failures f WHERE f.instance_id = i.id AND f.action = ? AND f.next_retry_at > ?)", t.Action, now).
+		Order(enteredAt+", i.id").
+		Limit(workflowTimerBatch).
+		Pluck("i.id", &ids).Error
+	return ids, err
+}
+
+// recordFailure counts a failed attempt at the timer on the instance and backs it off
+func (s *WorkflowTimerScheduler) recordFailure(instanceID uuid.UUID, action string, cause error, now time.Time) {
+	failure := models.WorkflowTimerFailure{InstanceID: instanceID, Action: action}
+	if err := s.db.Where("instance_id = ? AND action = ?", instanceID, action).FirstOrInit(&failure).Error; err != nil {
+		log.Printf("⚠️  Failed to load timer failures of instance %s: %v", instanceID, err)
+		return
+	}
+	failure.RecordAttempt(cause, now)
+	if err := s.db.Save(&failure).Error; err != nil {
+		log.Printf("⚠️  Failed to record timer failure of instance %s: %v", instanceID, err)
+	}
+}
+
+// clearFailure forgets earlier failures of the timer once it has been taken
+func (s *WorkflowTimerScheduler) clearFailure(instanceID uuid.UUID, action string) {
+	if err := s.db.Where("instance_id = ? AND action = ?", instanceID, action).Delete(&models.WorkflowTimerFailure{}).Error; err != nil {
+		log.Printf("⚠️  Failed to clear timer failures of instance %s: %v", instanceID, err)
+	}
+}
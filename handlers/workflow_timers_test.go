@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/models"
+)
+
+func TestDueInstancesOrdersByDueTimeAndSkipsBackedOff(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 user=x dbname=x sslmode=disable"}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("failed to open dry-run database: %v", err)
+	}
+	var query string
+	db.Callback().Query().After("gorm:query").Register("capture", func(tx *gorm.DB) {
+		query = tx.Statement.SQL.String()
+	})
+
+	scheduler := &WorkflowTimerScheduler{db: db}
+	workflow := &models.WorkflowDefinition{ID: uuid.New(), CurrentVersion: 1}
+	now := time.Now()
+	transition := models.WorkflowTransitionDef{From: "submitted", To: "escalated", Action: "escalate"}
+	if _, err := scheduler.dueInstances("", "form_submissions", "submitted_at", workflow, transition, now.Add(-time.Hour), now); err != nil {
+		t.Fatalf("dueInstances: %v", err)
+	}
+
+	enteredAt := "COALESCE((SELECT MAX(wt.transitioned_at) FROM workflow_transitions wt WHERE wt.submission_id = i.id), i.submitted_at)"
+	if !strings.Contains(query, "ORDER BY "+enteredAt+", i.id") {
+		t.Errorf("query does not order by the time the instance became due:\n%s", query)
+	}
+	if !strings.Contains(query, "NOT EXISTS (SELECT 1 FROM workflow_timer_failures f") {
+		t.Errorf("query does not skip instances backing off after a failure:\n%s", query)
+	}
+}
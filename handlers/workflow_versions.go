@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+)
+
+// loadPinnedWorkflow returns the workflow definition as it stood at version; a nil version
+// or the current one returns the definition itself.
+func loadPinnedWorkflow(db *gorm.DB, workflowID uuid.UUID, version *int) (*models.WorkflowDefinition, error) {
+	var workflow models.WorkflowDefinition
+	if err := db.First(&workflow, "id = ?", workflowID).Error; err != nil {
+		return nil, err
+	}
+	return pinWorkflowVersion(db, &workflow, version)
+}
+
+func pinWorkflowVersion(db *gorm.DB, workflow *models.WorkflowDefinition, version *int) (*models.WorkflowDefinition, error) {
+	if version == nil || *version == workflow.CurrentVersion {
+		return workflow, nil
+	}
+	var snapshot models.WorkflowDefinitionVersion
+	if err := db.Where("workflow_id = ? AND version_number = ?", workflow.ID, *version).First(&snapshot).Error; err != nil {
+		return nil, fmt.Errorf("workflow %s version %d not found: %w", workflow.Code, *version, err)
+	}
+	return workflow.AtVersion(&snapshot), nil
+}
+
+// workflowAllVersions returns the definition at each of its versions, current first
+func workflowAllVersions(db *gorm.DB, workflow *models.WorkflowDefinition) ([]*models.WorkflowDefinition, error) {
+	var snapshots []models.WorkflowDefinitionVersion
+	if err := db.Where("workflow_id = ? AND version_number <> ?", workflow.ID, workflow.CurrentVersion).
+		Order("version_number DESC").Find(&snapshots).Error; err != nil {
+		return nil, err
+	}
+	versions := []*models.WorkflowDefinition{workflow}
+	for i := range snapshots {
+		versions = append(versions, workflow.AtVersion(&snapshots[i]))
+	}
+	return versions, nil
+}
+
+// pinSubmissionWorkflow swaps the submission's preloaded workflow for the version the
+// submission is pinned to
+func pinSubmissionWorkflow(db *gorm.DB, submission *models.FormSubmission) error {
+	if submission.Workflow == nil {
+		return nil
+	}
+	pinned, err := pinWorkflowVersion(db, submission.Workflow, submission.WorkflowVersion)
+	if err != nil {
+		return err
+	}
+	submission.Workflow = pinned
+	return nil
+}
+
+// pinSubmissionWorkflows pins every submission of a list, loading each version once
+func pinSubmissionWorkflows(db *gorm.DB, submissions []models.FormSubmission) {
+	pinned := make(map[string]*models.WorkflowDefinition)
+	for i := range submissions {
+		submission := &submissions[i]
+		if submission.Workflow == nil || submission.WorkflowVersion == nil {
+			continue
+		}
+		key := fmt.Sprintf("%s@%d", submission.Workflow.ID, *submission.WorkflowVersion)
+		if workflow, ok := pinned[key]; ok {
+			submission.Workflow = workflow
+			continue
+		}
+		if err := pinSubmissionWorkflow(db, submission); err != nil {
+			log.Printf("⚠️  Failed to pin submission %s to its workflow version: %v", submission.ID, err)
+			continue
+		}
+		pinned[key] = submission.Workflow
+	}
+}
+
+// ListWorkflowVersions returns the snapshots of a workflow definition, newest first
+// GET /api/v1/admin/workflows/{workflowId}/versions
+func ListWorkflowVersions(w http.ResponseWriter, r *http.Request) {
+	workflowID, err := uuid.Parse(mux.Vars(r)["workflowId"])
+	if err != nil {
+		http.Error(w, "invalid workflow ID", http.StatusBadRequest)
+		return
+	}
+
+	var workflow models.WorkflowDefinition
+	if err := scopedWorkflowEngine(r).db.First(&workflow, "id = ?", workflowID).Error; err != nil {
+		http.Error(w, "workflow not found", http.StatusNotFound)
+		return
+	}
+
+	var versions []models.WorkflowDefinitionVersion
+	if err := scopedWorkflowEngine(r).db.Where("workflow_id = ?", workflowID).
+		Order("version_number DESC").Find(&versions).Error; err != nil {
+		http.Error(w, "failed to load workflow versions", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"workflow_id":     workflow.ID,
+		"current_version": workflow.CurrentVersion,
+		"versions":        versions,
+	})
+}
+
+type workflowMigrationRequest struct {
+	FromVersion *int              `json:"from_version,omitempty"` // default: every older version
+	ToVersion   *int              `json:"to_version,omitempty"`   // default: the current version
+	StateMap    map[string]string `json:"state_map,omitempty"`    // old state -> new state
+	DryRun      bool              `json:"dry_run"`
+}
+
+// workflowMigrationGroup is the set of instances of one form table sharing a version and state
+type workflowMigrationGroup struct {
+	FormCode    string `json:"form_code,omitempty"`
+	Table       string `json:"table"`
+	FromVersion int    `json:"from_version"`
+	FromState   string `json:"from_state"`
+	ToState     string `json:"to_state,omitempty"`
+	Count       int64  `json:"count"`
+	Error       string `json:"error,omitempty"`
+
+	schemaName string
+}
+
+type workflowMigrationReport struct {
+	WorkflowID uuid.UUID                `json:"workflow_id"`
+	ToVersion  int                      `json:"to_version"`
+	DryRun     bool                     `json:"dry_run"`
+	Instances  int64                    `json:"instances"`
+	Changed    int64                    `json:"state_changes"`
+	Unmapped   int64                    `json:"unmapped"`
+	Migrated   int64                    `json:"migrated"`
+	Groups     []workflowMigrationGroup `json:"groups"`
+}
+
+// MigrateWorkflowInstances moves in-flight instances of a workflow onto another version of
+// it, mapping states that no longer exist through state_map. With dry_run it only reports
+// what would change; a real run is refused while any instance's state cannot be mapped.
+// POST /api/v1/admin/workflows/{workflowId}/migrate
+func MigrateWorkflowInstances(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	workflowID, err := uuid.Parse(mux.Vars(r)["workflowId"])
+	if err != nil {
+		http.Error(w, "invalid workflow ID", http.StatusBadRequest)
+		return
+	}
+
+	var req workflowMigrationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	db := scopedWorkflowEngine(r).db
+	var workflow models.WorkflowDefinition
+	if err := db.First(&workflow, "id = ?", workflowID).Error; err != nil {
+		http.Error(w, "workflow not found", http.StatusNotFound)
+		return
+	}
+	toVersion := workflow.CurrentVersion
+	if req.ToVersion != nil {
+		toVersion = *req.ToVersion
+	}
+	target, err := pinWorkflowVersion(db, &workflow, &toVersion)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	groups, err := workflowMigrationGroups(db, workflowID, toVersion, req.FromVersion)
+	if err != nil {
+		log.Printf("❌ Failed to collect instances of workflow %s: %v", workflowID, err)
+		http.Error(w, "failed to collect workflow instances", http.StatusInternalServerError)
+		return
+	}
+
+	report := workflowMigrationReport{WorkflowID: workflowID, ToVersion: toVersion, DryRun: req.DryRun, Groups: groups}
+	for i := range report.Groups {
+		group := &report.Groups[i]
+		report.Instances += group.Count
+		toState, err := target.MigrateState(group.FromState, req.StateMap)
+		if err != nil {
+			group.Error = err.Error()
+			report.Unmapped += group.Count
+			continue
+		}
+		group.ToState = toState
+		if toState != group.FromState {
+			report.Changed += group.Count
+		}
+	}
+
+	if req.DryRun {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"report": report})
+		return
+	}
+	if report.Unmapped > 0 {
+		writeJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":  "some instances are in states the target version does not define; add them to state_map",
+			"report": report,
+		})
+		return
+	}
+
+	actorName := middleware.GetUser(r).Name
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, group := range report.Groups {
+			migrated, err := migrateWorkflowGroup(tx, workflowID, toVersion, group, claims.UserID, actorName)
+			if err != nil {
+				return err
+			}
+			report.Migrated += migrated
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("❌ Failed to migrate instances of workflow %s: %v", workflowID, err)
+		http.Error(w, "failed to migrate workflow instances", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("✅ Migrated %d instance(s) of workflow %s to version %d", report.Migrated, workflow.Code, toVersion)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"report": report})
+}
+
+// workflowMigrationGroups counts the workflow's live instances not on toVersion, by table,
+// version and state. Instances live in form_submissions and in the dedicated tables of the
+// forms using the workflow.
+func workflowMigrationGroups(db *gorm.DB, workflowID uuid.UUID, toVersion int, fromVersion *int) ([]workflowMigrationGroup, error) {
+	var groups []workflowMigrationGroup
+
+	collect := func(formCode, schemaName, table string) error {
+		query := db.Table(NewSchemaManager().GetFullTableName(schemaName, table)).
+			Select("COALESCE(workflow_version, 1) AS from_version, current_state AS from_state, COUNT(*) AS count").
+			Where("workflow_id = ? AND deleted_at IS NULL AND COALESCE(workflow_version, 1) <> ?", workflowID, toVersion)
+		if fromVersion != nil {
+			query = query.Where("COALESCE(workflow_version, 1) = ?", *fromVersion)
+		}
+		var rows []workflowMigrationGroup
+		if err := query.Group("1, 2").Order("1, 2").Scan(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			row.FormCode = formCode
+			row.Table = table
+			row.schemaName = schemaName
+			groups = append(groups, row)
+		}
+		return nil
+	}
+
+	if err := collect("", "", models.FormSubmission{}.TableName()); err != nil {
+		return nil, err
+	}
+
+	var forms []models.AppForm
+	if err := db.Preload("Module").Where("workflow_id = ? AND db_table_name <> ''", workflowID).Find(&forms).Error; err != nil {
+		return nil, err
+	}
+	tableManager := NewFormTableManager()
+	for _, form := range forms {
+		schemaName := ""
+		if form.Module != nil {
+			schemaName = form.Module.SchemaName
+		}
+		if exists, err := tableManager.TableExistsInSchema(schemaName, form.DBTableName); err != nil || !exists {
+			continue
+		}
+		if err := collect(form.Code, schemaName, form.DBTableName); err != nil {
+			return nil, err
+		}
+	}
+	return groups, nil
+}
+
+// migrateWorkflowGroup moves one group onto toVersion and records a transition for every
+// instance whose state changes. Instances that moved on since the report was built are left
+// for the next run.
+func migrateWorkflowGroup(tx *gorm.DB, workflowID uuid.UUID, toVersion int, group workflowMigrationGroup, actorID, actorName string) (int64, error) {
+	fullTableName := NewSchemaManager().GetFullTableName(group.schemaName, group.Table)
+	modifiedColumn := "updated_by"
+	if group.Table == (models.FormSubmission{}).TableName() {
+		modifiedColumn = "last_modified_by"
+	}
+
+	var ids []uuid.UUID
+	if err := tx.Raw(fmt.Sprintf(
+		"UPDATE %s SET current_state = ?, workflow_version = ?, %s = ?, updated_at = ? "+
+			"WHERE workflow_id = ? AND deleted_at IS NULL AND COALESCE(workflow_version, 1) = ? AND current_state = ? RETURNING id",
+		fullTableName, modifiedColumn),
+		group.ToState, toVersion, actorID, time.Now(), workflowID, group.FromVersion, group.FromState).
+		Scan(&ids).Error; err != nil {
+		return 0, fmt.Errorf("failed to migrate %s: %w", group.Table, err)
+	}
+	if group.ToState == group.FromState || len(ids) == 0 {
+		return int64(len(ids)), nil
+	}
+
+	metadata, _ := json.Marshal(map[string]interface{}{"from_version": group.FromVersion, "to_version": toVersion})
+	now := time.Now()
+	transitions := make([]models.WorkflowTransition, 0, len(ids))
+	for _, id := range ids {
+		transitions = append(transitions, models.WorkflowTransition{
+			SubmissionID:   id,
+			FromState:      group.FromState,
+			ToState:        group.ToState,
+			Action:         "migrate_version",
+			ActorID:        actorID,
+			ActorName:      actorName,
+			Comment:        fmt.Sprintf("Migrated from workflow version %d to %d", group.FromVersion, toVersion),
+			Metadata:       metadata,
+			TransitionedAt: now,
+		})
+	}
+	if err := tx.CreateInBatches(&transitions, 500).Error; err != nil {
+		return 0, fmt.Errorf("failed to record migration transitions: %w", err)
+	}
+	return int64(len(ids)), nil
+}
+
+// errWorkflowVersionConflict is returned when a definition was edited concurrently
+var errWorkflowVersionConflict = errors.New("workflow definition was modified concurrently")
+
+// saveWorkflowDefinition saves an edited definition. When its initial state, states or
+// transitions changed it becomes a new version, snapshotted alongside, so instances pinned
+// to earlier versions keep their rules.
+func saveWorkflowDefinition(db *gorm.DB, previous, workflow *models.WorkflowDefinition, editedBy string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		workflow.CurrentVersion = previous.CurrentVersion
+		if !previous.StructureChanged(workflow) {
+			return tx.Save(workflow).Error
+		}
+
+		// Definitions created before versioning may lack the snapshot they are pinned to
+		var existing int64
+		if err := tx.Model(&models.WorkflowDefinitionVersion{}).
+			Where("workflow_id = ? AND version_number = ?", previous.ID, previous.CurrentVersion).
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing == 0 {
+			snapshot := previous.Snapshot("")
+			if err := tx.Create(&snapshot).Error; err != nil {
+				return err
+			}
+		}
+
+		workflow.CurrentVersion = previous.CurrentVersion + 1
+		result := tx.Model(&models.WorkflowDefinition{}).
+			Where("id = ? AND current_version = ?", previous.ID, previous.CurrentVersion).
+			Update("current_version", workflow.CurrentVersion)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errWorkflowVersionConflict
+		}
+		if err := tx.Save(workflow).Error; err != nil {
+			return err
+		}
+		snapshot := workflow.Snapshot(editedBy)
+		return tx.Create(&snapshot).Error
+	})
+}
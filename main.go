@@ -18,8 +18,26 @@ import (
 
 	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/handlers"
+	"p9e.in/ugcl/handlers/announcements"
+	"p9e.in/ugcl/handlers/chat"
+	"p9e.in/ugcl/handlers/emergency"
 	"p9e.in/ugcl/handlers/reports"
 	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/pkg/abac"
+	"p9e.in/ugcl/pkg/breakglass"
+	"p9e.in/ugcl/pkg/devicehealth"
+	"p9e.in/ugcl/pkg/fx"
+	"p9e.in/ugcl/pkg/hooks"
+	"p9e.in/ugcl/pkg/jobrunner"
+	"p9e.in/ugcl/pkg/maintenance"
+	"p9e.in/ugcl/pkg/metering"
+	"p9e.in/ugcl/pkg/nrw"
+	"p9e.in/ugcl/pkg/portfolio"
+	"p9e.in/ugcl/pkg/progress"
+	"p9e.in/ugcl/pkg/roleexpiry"
+	"p9e.in/ugcl/pkg/telemetry"
+	"p9e.in/ugcl/pkg/transcribe"
+	_ "p9e.in/ugcl/plugins"
 	"p9e.in/ugcl/routes"
 )
 
@@ -88,8 +106,217 @@ func main() {
 	// config.SeedWorkflows()
 	// config.SeedFinanceModulesAndForms()
 
+	// Load vertical plugins before serving so their hooks see every event.
+	for _, plugin := range hooks.LoadPlugins() {
+		if !plugin.Loaded {
+			slog.Error("plugin failed to load", "plugin", plugin.Name, "error", plugin.Error)
+		}
+	}
+
 	handler := routes.RegisterRoutes()
 
+	// Usage metering buffers API call counters in memory; flush them periodically
+	// and refresh derived metrics (storage, messages, active users) for the month.
+	if jobrunner.Enabled("USAGE_METERING_ENABLED", true) {
+		meter := metering.NewMeter(config.DB)
+		metering.SetDefault(meter)
+		meter.Start(
+			getDurationFromEnv("USAGE_METERING_FLUSH_INTERVAL", time.Minute),
+			getDurationFromEnv("USAGE_METERING_AGGREGATE_INTERVAL", time.Hour),
+		)
+		defer meter.Stop()
+	}
+
+	// Record RBAC and ABAC decisions to policy_evaluations. The recorder always runs so
+	// sampling can be switched on at runtime even when POLICY_EVAL_LOG_ENABLED=false.
+	evaluationRecorder := abac.NewEvaluationRecorder(
+		config.DB,
+		abac.SamplingFromEnv(),
+		getIntFromEnv("POLICY_EVAL_QUEUE_SIZE", 4096),
+		getDurationFromEnv("POLICY_EVAL_FLUSH_INTERVAL", 2*time.Second),
+	)
+	abac.SetDefaultRecorder(evaluationRecorder)
+	evaluationRecorder.Start()
+	defer evaluationRecorder.Stop()
+
+	// Store a weekly portfolio risk snapshot so leadership can compare weeks.
+	if jobrunner.Enabled("PORTFOLIO_SNAPSHOT_ENABLED", true) {
+		snapshotter := portfolio.NewSnapshotter(config.DB)
+		snapshotter.Start(getDurationFromEnv("PORTFOLIO_SNAPSHOT_CHECK_INTERVAL", time.Hour))
+		defer snapshotter.Stop()
+	}
+
+	// Reconcile every project's progress and spent budget with its tasks each night,
+	// catching changes made outside the task handlers that roll them up.
+	if jobrunner.Enabled("PROJECT_PROGRESS_RECONCILE_ENABLED", true) {
+		progressReconciler := progress.NewReconciler(config.DB)
+		progressReconciler.Start(getDurationFromEnv("PROJECT_PROGRESS_RECONCILE_INTERVAL", 24*time.Hour))
+		defer progressReconciler.Stop()
+	}
+
+	// Deactivate time-bound role assignments once they expire and notify the assigner.
+	if jobrunner.Enabled("ROLE_EXPIRY_ENABLED", true) {
+		roleExpirer := roleexpiry.NewExpirer(config.DB)
+		roleExpirer.Start(getDurationFromEnv("ROLE_EXPIRY_CHECK_INTERVAL", 24*time.Hour))
+		defer roleExpirer.Stop()
+	}
+
+	// Close break-glass grants once their time box ends.
+	if jobrunner.Enabled("BREAK_GLASS_EXPIRY_ENABLED", true) {
+		breakGlassExpirer := breakglass.NewExpirer(config.DB)
+		breakGlassExpirer.Start(getDurationFromEnv("BREAK_GLASS_EXPIRY_CHECK_INTERVAL", time.Minute))
+		defer breakGlassExpirer.Stop()
+	}
+
+	// Raise preventive maintenance tasks as asset maintenance schedules come due.
+	if jobrunner.Enabled("MAINTENANCE_SCHEDULER_ENABLED", true) {
+		maintenanceScheduler := maintenance.NewScheduler(config.DB)
+		maintenanceScheduler.Start(getDurationFromEnv("MAINTENANCE_SCHEDULER_INTERVAL", time.Hour))
+		defer maintenanceScheduler.Stop()
+	}
+
+	// Alert site engineers when approved field devices stop sending readings or heartbeats.
+	if jobrunner.Enabled("DEVICE_HEALTH_MONITOR_ENABLED", true) {
+		deviceMonitor := devicehealth.NewMonitor(config.DB)
+		deviceMonitor.Start(getDurationFromEnv("DEVICE_HEALTH_CHECK_INTERVAL", 5*time.Minute))
+		defer deviceMonitor.Stop()
+	}
+
+	// Balance water zones' supply against consumption each day and alert water admins to
+	// losses over threshold.
+	if jobrunner.Enabled("NRW_ESTIMATOR_ENABLED", true) {
+		nrwEstimator := nrw.NewEstimator(config.DB)
+		nrwEstimator.Start(getDurationFromEnv("NRW_ESTIMATOR_INTERVAL", time.Hour))
+		defer nrwEstimator.Stop()
+	}
+
+	// Fetch the day's exchange rates for the active currencies. It calls out to the rate
+	// provider, so it only runs when asked for.
+	if jobrunner.Enabled("EXCHANGE_RATE_SYNC_ENABLED", false) {
+		if provider, err := fx.NewProviderFromEnv(); err != nil {
+			slog.Error("exchange rate sync not started", "error", err)
+		} else {
+			rateSyncer := fx.NewSyncer(config.DB, provider)
+			rateSyncer.Start(getDurationFromEnv("EXCHANGE_RATE_SYNC_INTERVAL", 24*time.Hour))
+			defer rateSyncer.Stop()
+		}
+	}
+
+	// Re-alert site users who have not acknowledged an emergency broadcast.
+	if jobrunner.Enabled("EMERGENCY_ESCALATION_ENABLED", true) {
+		escalator := emergency.NewEscalator()
+		escalator.Start(getDurationFromEnv("EMERGENCY_ESCALATION_CHECK_INTERVAL", 30*time.Second))
+		defer escalator.Stop()
+	}
+
+	// Deliver queued workflow transition notifications and webhooks, retrying failures.
+	if jobrunner.Enabled("WORKFLOW_ACTION_DISPATCH_ENABLED", true) {
+		actionDispatcher := handlers.NewWorkflowActionDispatcher()
+		actionDispatcher.Start(getDurationFromEnv("WORKFLOW_ACTION_DISPATCH_INTERVAL", 30*time.Second))
+		defer actionDispatcher.Stop()
+	}
+
+	// Push queued chat, approval and alarm notifications to users' phones, retrying failures.
+	if jobrunner.Enabled("PUSH_DELIVERY_ENABLED", true) {
+		pushWorker := handlers.NewPushDeliveryWorker()
+		pushWorker.Start(getDurationFromEnv("PUSH_DELIVERY_INTERVAL", 30*time.Second))
+		defer pushWorker.Stop()
+	}
+
+	// Resend texts whose last attempt failed transiently.
+	if jobrunner.Enabled("SMS_RETRY_ENABLED", true) {
+		smsRetries := handlers.NewSMSRetryWorker()
+		smsRetries.Start(getDurationFromEnv("SMS_RETRY_INTERVAL", time.Minute))
+		defer smsRetries.Stop()
+	}
+
+	// Send users' hourly and daily digests of the notifications they batch.
+	if jobrunner.Enabled("NOTIFICATION_DIGEST_ENABLED", true) {
+		digests := handlers.NewDigestScheduler()
+		digests.Start(getDurationFromEnv("NOTIFICATION_DIGEST_INTERVAL", 5*time.Minute))
+		defer digests.Stop()
+	}
+
+	// Publish scheduled announcements once their window opens.
+	if jobrunner.Enabled("ANNOUNCEMENT_PUBLISHER_ENABLED", true) {
+		announcementPublisher := announcements.NewPublisher()
+		announcementPublisher.Start(getDurationFromEnv("ANNOUNCEMENT_PUBLISHER_INTERVAL", time.Minute))
+		defer announcementPublisher.Stop()
+	}
+
+	// Fire reminders set on tasks, approvals and maintenance tickets as they fall due.
+	if jobrunner.Enabled("REMINDERS_ENABLED", true) {
+		reminderScheduler := handlers.NewReminderScheduler()
+		reminderScheduler.Start(getDurationFromEnv("REMINDERS_INTERVAL", time.Minute))
+		defer reminderScheduler.Stop()
+	}
+
+	// Extract the text of uploaded documents (PDF text layer, OCR of scans) for full-text search.
+	if jobrunner.Enabled("DOCUMENT_TEXT_INDEX_ENABLED", true) {
+		documentTextIndexer := handlers.NewDocumentTextIndexer()
+		documentTextIndexer.Start(getDurationFromEnv("DOCUMENT_TEXT_INDEX_INTERVAL", 30*time.Second))
+		defer documentTextIndexer.Stop()
+	}
+
+	// Text approvals that have waited too long to approvers reached by SMS rather than the app.
+	if jobrunner.Enabled("APPROVAL_SMS_REMINDERS_ENABLED", true) {
+		reminders := handlers.NewApprovalReminderJob(getDurationFromEnv("APPROVAL_SMS_REMINDER_AFTER", 4*time.Hour))
+		reminders.Start(getDurationFromEnv("APPROVAL_SMS_REMINDER_INTERVAL", 15*time.Minute))
+		defer reminders.Stop()
+	}
+
+	// Take workflow transitions that declare a timer once instances have waited long enough.
+	if jobrunner.Enabled("WORKFLOW_TIMERS_ENABLED", true) {
+		timerScheduler := handlers.NewWorkflowTimerScheduler()
+		timerScheduler.Start(getDurationFromEnv("WORKFLOW_TIMERS_INTERVAL", 5*time.Minute))
+		defer timerScheduler.Stop()
+	}
+
+	// Discard form drafts nobody has touched for FORM_DRAFT_TTL.
+	if jobrunner.Enabled("FORM_DRAFT_EXPIRY_ENABLED", true) {
+		draftExpirer := handlers.NewFormDraftExpirer()
+		draftExpirer.Start(getDurationFromEnv("FORM_DRAFT_EXPIRY_INTERVAL", time.Hour))
+		defer draftExpirer.Stop()
+	}
+
+	// Purge form records left in the recycle bin longer than FORM_RECORD_RETENTION.
+	if jobrunner.Enabled("FORM_RECORD_PURGE_ENABLED", true) {
+		recordPurger := handlers.NewFormRecordPurger()
+		recordPurger.Start(getDurationFromEnv("FORM_RECORD_PURGE_INTERVAL", 24*time.Hour))
+		defer recordPurger.Stop()
+	}
+
+	// Mobile feature telemetry is soft-launched: ingestion stays off until
+	// MOBILE_TELEMETRY_ENABLED=true, and clients get an accepted-but-discarded response.
+	if jobrunner.Enabled("MOBILE_TELEMETRY_ENABLED", false) {
+		telemetryCollector := telemetry.NewCollector(
+			config.DB,
+			getIntFromEnv("MOBILE_TELEMETRY_QUEUE_SIZE", 8192),
+			getDurationFromEnv("MOBILE_TELEMETRY_FLUSH_INTERVAL", 5*time.Second),
+		)
+		telemetry.SetDefault(telemetryCollector)
+		telemetryCollector.Start()
+		defer telemetryCollector.Stop()
+	}
+
+	// Voice notes are transcribed only when a speech-to-text provider is configured.
+	if jobrunner.Enabled("VOICE_TRANSCRIPTION_ENABLED", false) {
+		provider, err := transcribe.NewProviderFromEnv()
+		if err != nil {
+			slog.Error("voice note transcription not started", "error", err)
+		} else {
+			transcriber := chat.NewTranscriber(
+				config.DB,
+				provider,
+				getIntFromEnv("VOICE_TRANSCRIPTION_QUEUE_SIZE", 256),
+				getDurationFromEnv("VOICE_TRANSCRIPTION_TIMEOUT", 2*time.Minute),
+			)
+			chat.SetDefaultTranscriber(transcriber)
+			transcriber.Start()
+			defer transcriber.Stop()
+		}
+	}
+
 	// Prewarm authorization caches in background to reduce first-hit latency after restarts.
 	prewarmUsers := 1
 	if raw := os.Getenv("AUTH_CACHE_PREWARM_USERS"); raw != "" {
@@ -102,9 +329,7 @@ func main() {
 	})
 
 	// Auto-sync report views for active forms so report execution never depends on manual setup.
-	if strings.EqualFold(strings.TrimSpace(os.Getenv("REPORT_VIEW_AUTOSYNC_ON_STARTUP")), "false") {
-		slog.Info("report view autosync disabled", "env", "REPORT_VIEW_AUTOSYNC_ON_STARTUP")
-	} else {
+	if jobrunner.Enabled("REPORT_VIEW_AUTOSYNC_ON_STARTUP", true) {
 		safeGo("report-view-autosync", func() {
 			reportViewAutosyncOnce.Do(func() {
 				synced, err := reports.EnsureAllActiveFormReportViews(config.DB)
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/google/uuid"
 	"p9e.in/ugcl/config"
@@ -11,12 +12,20 @@ import (
 	"p9e.in/ugcl/pkg/abac"
 )
 
+// abacOnPermissionsEnabled controls whether RequirePermission also consults ABAC
+// policies targeting the permission. Set ABAC_ENFORCE_ON_PERMISSIONS=false to disable.
+var abacOnPermissionsEnabled = getEnvAsBool("ABAC_ENFORCE_ON_PERMISSIONS", true)
+
 // RequireABACPolicy evaluates ABAC policies for authorization
 // This middleware should be used AFTER RequirePermission for hybrid RBAC+ABAC
 func RequireABACPolicy(action string, resourceType string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			claims := GetClaims(r)
+			if claims == nil {
+				handleAuthError(w, ErrUnauthorized)
+				return
+			}
 
 			// Super admin bypass
 			if claims.Role == "super_admin" {
@@ -40,38 +49,12 @@ func RequireABACPolicy(action string, resourceType string) func(http.Handler) ht
 				}
 			}
 
-			// Build policy request
-			policyReq := models.PolicyRequest{
-				UserID:             userID,
-				Action:             action,
-				ResourceType:       resourceType,
-				ResourceID:         resourceID,
-				UserAttributes:     make(map[string]string),
-				ResourceAttributes: make(map[string]string),
-				Environment:        make(map[string]string),
-			}
-
-			// Get user attributes
-			attributeService := abac.NewAttributeService(config.DB)
-			userAttrs, err := attributeService.GetUserAttributes(userID)
-			if err == nil {
-				policyReq.UserAttributes = userAttrs
-			}
-
-			// Add user role to attributes
-			policyReq.UserAttributes["user.role"] = claims.Role
-
-			// Get resource attributes if resource ID is provided
-			if resourceID != nil {
-				resourceAttrs, err := attributeService.GetResourceAttributes(resourceType, *resourceID)
-				if err == nil {
-					policyReq.ResourceAttributes = resourceAttrs
-				}
+			var businessID *uuid.UUID
+			if id := getBusinessIDFromRequest(r); id != uuid.Nil {
+				businessID = &id
 			}
 
-			// Add environment attributes
-			policyReq.Environment["environment.ip_address"] = getClientIP(r)
-			policyReq.Environment["environment.user_agent"] = r.UserAgent()
+			policyReq := buildPolicyRequest(r, userID, claims.Role, action, resourceType, resourceID, businessID)
 
 			// Evaluate policies
 			policyEngine := abac.NewPolicyEngine(config.DB)
@@ -83,13 +66,7 @@ func RequireABACPolicy(action string, resourceType string) func(http.Handler) ht
 
 			// Check decision
 			if !decision.Allowed {
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusForbidden)
-				json.NewEncoder(w).Encode(map[string]interface{}{
-					"error":  "Access denied by policy",
-					"reason": decision.Reason,
-					"effect": decision.Effect,
-				})
+				writePolicyDenied(w, decision)
 				return
 			}
 
@@ -99,25 +76,14 @@ func RequireABACPolicy(action string, resourceType string) func(http.Handler) ht
 	}
 }
 
-// RequireHybridAuth combines RBAC and ABAC authorization
-// First checks RBAC permission, then evaluates ABAC policies
-func RequireHybridAuth(permission string, action string, resourceType string) func(http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		// Chain RBAC and ABAC middlewares
-		rbacHandler := RequirePermission(permission)(next)
-		abacHandler := RequireABACPolicy(action, resourceType)(rbacHandler)
-		return abacHandler
-	}
-}
-
-// CheckPolicyDecision is a helper function to check policy decision for programmatic use
-func CheckPolicyDecision(userID uuid.UUID, action string, resourceType string, resourceID *uuid.UUID) (*models.PolicyDecision, error) {
-	// Build policy request
+// buildPolicyRequest assembles user, resource and environment attributes for evaluation.
+func buildPolicyRequest(r *http.Request, userID uuid.UUID, role string, action string, resourceType string, resourceID *uuid.UUID, businessID *uuid.UUID) models.PolicyRequest {
 	policyReq := models.PolicyRequest{
 		UserID:             userID,
 		Action:             action,
 		ResourceType:       resourceType,
 		ResourceID:         resourceID,
+		BusinessVerticalID: businessID,
 		UserAttributes:     make(map[string]string),
 		ResourceAttributes: make(map[string]string),
 		Environment:        make(map[string]string),
@@ -126,18 +92,118 @@ func CheckPolicyDecision(userID uuid.UUID, action string, resourceType string, r
 	// Get user attributes
 	attributeService := abac.NewAttributeService(config.DB)
 	userAttrs, err := attributeService.GetUserAttributes(userID)
-	if err == nil {
+	if err == nil && userAttrs != nil {
 		policyReq.UserAttributes = userAttrs
 	}
 
+	// Add user role to attributes
+	policyReq.UserAttributes["user.role"] = role
+
 	// Get resource attributes if resource ID is provided
 	if resourceID != nil {
 		resourceAttrs, err := attributeService.GetResourceAttributes(resourceType, *resourceID)
-		if err == nil {
+		if err == nil && resourceAttrs != nil {
 			policyReq.ResourceAttributes = resourceAttrs
 		}
 	}
 
+	// Add environment attributes
+	if r != nil {
+		policyReq.Environment["environment.ip_address"] = getClientIP(r)
+		policyReq.Environment["environment.user_agent"] = r.UserAgent()
+		policyReq.Environment["environment.request_path"] = r.URL.Path
+	}
+
+	return policyReq
+}
+
+func writePolicyDenied(w http.ResponseWriter, decision *models.PolicyDecision) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  "Access denied by policy",
+		"reason": decision.Reason,
+		"effect": decision.Effect,
+	})
+}
+
+// enforcePermissionPolicies runs ABAC policies targeting an RBAC permission after the
+// permission check has passed. Policies can only narrow access here: a matching DENY
+// policy rejects the request (deny-overrides), while no match leaves the RBAC grant intact.
+// It returns false when the request was rejected and a response has been written.
+func enforcePermissionPolicies(w http.ResponseWriter, r *http.Request, userCtx *UserContext, permission string) bool {
+	if userCtx.IsSuperAdmin || !abacOnPermissionsEnabled {
+		return true
+	}
+
+	resourceType := permissionResourceType(permission)
+	var businessID *uuid.UUID
+	if userCtx.BusinessContext != nil {
+		businessID = &userCtx.BusinessContext.BusinessID
+	}
+
+	policyEngine := abac.NewPolicyEngine(config.DB)
+	applicable, err := policyEngine.HasApplicablePolicies(permission, resourceType, businessID)
+	if err != nil {
+		handleAuthError(w, &AuthError{Code: http.StatusInternalServerError, Message: "policy evaluation failed"})
+		return false
+	}
+	if !applicable {
+		return true
+	}
+
+	userID, err := uuid.Parse(userCtx.Claims.UserID)
+	if err != nil {
+		handleAuthError(w, ErrInvalidUserID)
+		return false
+	}
+
+	role := userCtx.Claims.Role
+	if userCtx.User != nil {
+		if effective := userCtx.User.EffectiveRole(); effective != nil {
+			role = effective.Name
+		}
+	}
+
+	policyReq := buildPolicyRequest(r, userID, role, permission, resourceType, nil, businessID)
+	decision, err := policyEngine.EvaluateRequest(policyReq)
+	if err != nil {
+		handleAuthError(w, &AuthError{Code: http.StatusInternalServerError, Message: "policy evaluation failed"})
+		return false
+	}
+
+	if !decision.Allowed && len(decision.MatchedPolicies) > 0 {
+		writePolicyDenied(w, decision)
+		return false
+	}
+	return true
+}
+
+// permissionResourceType derives the ABAC resource type from a permission name,
+// e.g. "project:create" -> "project". Legacy names like "read_users" have none.
+func permissionResourceType(permission string) string {
+	if idx := strings.Index(permission, ":"); idx > 0 {
+		return permission[:idx]
+	}
+	return ""
+}
+
+// RequireHybridAuth combines RBAC and ABAC authorization
+// First checks RBAC permission, then evaluates ABAC policies
+func RequireHybridAuth(permission string, action string, resourceType string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		// Chain RBAC and ABAC middlewares
+		rbacHandler := RequirePermission(permission)(next)
+		abacHandler := RequireABACPolicy(action, resourceType)(rbacHandler)
+		return abacHandler
+	}
+}
+
+// CheckPolicyDecision is a helper function to check policy decision for programmatic use
+func CheckPolicyDecision(userID uuid.UUID, action string, resourceType string, resourceID *uuid.UUID) (*models.PolicyDecision, error) {
+	policyReq := buildPolicyRequest(nil, userID, "", action, resourceType, resourceID, nil)
+	delete(policyReq.UserAttributes, "user.role")
+
 	// Evaluate policies
 	policyEngine := abac.NewPolicyEngine(config.DB)
 	return policyEngine.EvaluateRequest(policyReq)
@@ -108,12 +108,18 @@ func CanAccessBusiness(userCtx *UserContext, businessID uuid.UUID) bool {
 		return true
 	}
 
+	// Roles never reach across companies
+	if !sameCompany(userCtx.User, businessID) {
+		return false
+	}
+
 	if userCtx.User.BusinessVerticalID != nil && *userCtx.User.BusinessVerticalID == businessID {
 		return true
 	}
 
+	now := time.Now()
 	for _, ubr := range userCtx.User.UserBusinessRoles {
-		if ubr.IsActive && ubr.BusinessRole.BusinessVerticalID == businessID {
+		if ubr.IsEffectiveAt(now) && ubr.BusinessRole.BusinessVerticalID == businessID {
 			return true
 		}
 	}
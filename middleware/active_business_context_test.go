@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"p9e.in/ugcl/models"
+)
+
+func TestCanAccessBusinessHonoursRoleValidity(t *testing.T) {
+	businessID := uuid.New()
+	now := time.Now()
+	yesterday, tomorrow := now.Add(-24*time.Hour), now.Add(24*time.Hour)
+
+	cases := []struct {
+		name string
+		ubr  models.UserBusinessRole
+		want bool
+	}{
+		{"open-ended", models.UserBusinessRole{IsActive: true}, true},
+		{"inside window", models.UserBusinessRole{IsActive: true, ValidFrom: &yesterday, ValidUntil: &tomorrow}, true},
+		{"not yet valid", models.UserBusinessRole{IsActive: true, ValidFrom: &tomorrow}, false},
+		{"expired", models.UserBusinessRole{IsActive: true, ValidUntil: &yesterday}, false},
+		{"revoked", models.UserBusinessRole{IsActive: false}, false},
+	}
+	for _, c := range cases {
+		c.ubr.BusinessRole = models.BusinessRole{ID: uuid.New(), BusinessVerticalID: businessID}
+		userCtx := &UserContext{User: &models.User{UserBusinessRoles: []models.UserBusinessRole{c.ubr}}}
+		if got := CanAccessBusiness(userCtx, businessID); got != c.want {
+			t.Errorf("%s: CanAccessBusiness = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
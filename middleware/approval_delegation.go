@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/utils"
+)
+
+// FindApprovalDelegation returns an active delegation that lets delegateID take a decision
+// requiring permission in the vertical, or nil when there is none. The delegator must still
+// hold the permission there through their own roles (super-admin bypass and break-glass
+// grants are not delegated) and must not be denied it. Delegations do not chain.
+func FindApprovalDelegation(delegateID, businessID uuid.UUID, permission string) (*models.ApprovalDelegation, error) {
+	now := time.Now()
+	var delegations []models.ApprovalDelegation
+	if err := config.DB.
+		Where("delegate_id = ? AND status = ? AND starts_at <= ? AND ends_at > ?", delegateID, models.ApprovalDelegationActive, now, now).
+		Where("(business_vertical_id IS NULL OR business_vertical_id = ?)", businessID).
+		Order("created_at ASC").
+		Find(&delegations).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range delegations {
+		delegation := &delegations[i]
+		if !delegation.Covers(permission, businessID, now) {
+			continue
+		}
+		delegator, err := loadUserWithAuthGraph(delegation.DelegatorID)
+		if err != nil || !delegator.IsActive {
+			continue
+		}
+		if delegatorHolds(&delegator, businessID, permission, now) {
+			delegation.Delegator = &delegator
+			return delegation, nil
+		}
+	}
+	return nil, nil
+}
+
+func delegatorHolds(delegator *models.User, businessID uuid.UUID, permission string, now time.Time) bool {
+	businessCtx := &BusinessContext{BusinessID: businessID}
+	for _, ubr := range delegator.UserBusinessRoles {
+		if ubr.IsEffectiveAt(now) && ubr.BusinessRole.BusinessVerticalID == businessID {
+			businessCtx.BusinessRoles = append(businessCtx.BusinessRoles, ubr)
+		}
+	}
+	if models.DeniesPermission(denyPermissionNames(applicableDenies(delegator, businessCtx)), permission) {
+		return false
+	}
+
+	for _, held := range userPermissionsInVertical(delegator, businessID, nil) {
+		if utils.MatchesPermission(held, permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordApprovalDelegationUse counts a decision taken under the delegation
+func RecordApprovalDelegationUse(tx *gorm.DB, delegationID uuid.UUID) error {
+	return tx.Model(&models.ApprovalDelegation{}).
+		Where("id = ?", delegationID).
+		Updates(map[string]interface{}{
+			"usage_count":  gorm.Expr("usage_count + 1"),
+			"last_used_at": time.Now(),
+		}).Error
+}
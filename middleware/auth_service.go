@@ -99,6 +99,10 @@ type UserContext struct {
 	globalPermSet     map[string]struct{}
 	BusinessContext   *BusinessContext
 	SiteContext       *SiteAccessContext
+
+	// DeniedPermissions are permission patterns withheld by deny entries; they override
+	// every allow (deny-overrides).
+	DeniedPermissions []string
 }
 
 // BusinessContext contains business-specific authorization info
@@ -108,6 +112,11 @@ type BusinessContext struct {
 	Permissions     []string
 	permissionSet   map[string]struct{}
 	IsBusinessAdmin bool
+
+	// Site scoping: permissions from roles covering the whole vertical, and from roles
+	// limited to a single site. Permissions above is the union of both.
+	verticalPermissions []string
+	sitePermissions     map[uuid.UUID][]string
 }
 
 // LoadUserContext loads complete user context from request.
@@ -134,7 +143,7 @@ func (s *AuthService) LoadUserContext(r *http.Request) (*UserContext, error) {
 	if !cached {
 		// Deduplicate concurrent misses for the same user to avoid DB stampedes.
 		loaded, loadErr, _ := userContextLoadGroup.Do(claims.UserID, func() (interface{}, error) {
-			if cachedUser, cachedPermissions, ok := userCache.getAuthData(claims.UserID); ok {
+			if cachedUser, cachedPermissions, ok := userCache.peekAuthData(claims.UserID); ok {
 				return authLoadResult{user: cachedUser, globalPermissions: cachedPermissions}, nil
 			}
 
@@ -150,7 +159,7 @@ func (s *AuthService) LoadUserContext(r *http.Request) (*UserContext, error) {
 			}
 
 			userCache.set(claims.UserID, freshUser)
-			cachedUser, cachedPermissions, ok := userCache.getAuthData(claims.UserID)
+			cachedUser, cachedPermissions, ok := userCache.peekAuthData(claims.UserID)
 			if !ok {
 				return nil, ErrUserNotFound
 			}
@@ -164,6 +173,17 @@ func (s *AuthService) LoadUserContext(r *http.Request) (*UserContext, error) {
 		globalPermissions = resolved.globalPermissions
 	}
 
+	// Permissions cached with the user are dropped once the global role's validity
+	// window has closed (or before it opens), even if the cache entry is still fresh.
+	if user.EffectiveRole() == nil {
+		globalPermissions = nil
+	}
+
+	// A token issued before the user moved to another company must not keep the old tenant
+	if claims.CompanyID != "" && (user.CompanyID == nil || user.CompanyID.String() != claims.CompanyID) {
+		return nil, ErrUnauthorized
+	}
+
 	ctx := &UserContext{
 		User:         user,
 		Claims:       claims,
@@ -184,12 +204,16 @@ func (s *AuthService) LoadUserContext(r *http.Request) (*UserContext, error) {
 		return nil, resolveErr
 	}
 
+	if !ctx.IsSuperAdmin {
+		ctx.DeniedPermissions = denyPermissionNames(applicableDenies(user, ctx.BusinessContext))
+	}
+
 	return ctx, nil
 }
 
 // IsSuperAdmin checks if user has super admin role
 func (s *AuthService) IsSuperAdmin(user models.User) bool {
-	if user.RoleModel != nil && user.RoleModel.Name == "super_admin" {
+	if role := user.EffectiveRole(); role != nil && role.Name == "super_admin" {
 		return true
 	}
 	return user.HasPermission("admin_all")
@@ -198,8 +222,8 @@ func (s *AuthService) IsSuperAdmin(user models.User) bool {
 // GetGlobalPermissions returns all global permissions for user
 func (s *AuthService) GetGlobalPermissions(user models.User) []string {
 	permissions := make([]string, 0)
-	if user.RoleModel != nil {
-		for _, perm := range user.RoleModel.Permissions {
+	if role := user.EffectiveRole(); role != nil {
+		for _, perm := range role.Permissions {
 			permissions = append(permissions, perm.Name)
 		}
 	}
@@ -223,13 +247,22 @@ func (s *AuthService) LoadBusinessContext(user models.User, businessID uuid.UUID
 		return ctx
 	}
 
-	// Load business-specific roles
+	// Load business-specific roles, skipping grants outside their validity window
+	now := time.Now()
 	for _, ubr := range user.UserBusinessRoles {
-		if ubr.BusinessRole.BusinessVerticalID == businessID && ubr.IsActive {
+		if ubr.BusinessRole.BusinessVerticalID == businessID && ubr.IsEffectiveAt(now) {
 			ctx.BusinessRoles = append(ctx.BusinessRoles, ubr)
 			for _, perm := range ubr.BusinessRole.Permissions {
 				ctx.Permissions = append(ctx.Permissions, perm.Name)
 				ctx.permissionSet[perm.Name] = struct{}{}
+				if ubr.SiteID != nil {
+					if ctx.sitePermissions == nil {
+						ctx.sitePermissions = make(map[uuid.UUID][]string)
+					}
+					ctx.sitePermissions[*ubr.SiteID] = append(ctx.sitePermissions[*ubr.SiteID], perm.Name)
+					continue
+				}
+				ctx.verticalPermissions = append(ctx.verticalPermissions, perm.Name)
 				if perm.Name == "business_admin" {
 					ctx.IsBusinessAdmin = true
 				}
@@ -245,6 +278,9 @@ func (s *AuthService) HasPermission(ctx *UserContext, permission string) bool {
 	if ctx.IsSuperAdmin {
 		return true
 	}
+	if ctx.isDenied(permission) {
+		return false
+	}
 
 	if _, ok := ctx.globalPermSet[permission]; ok {
 		return true
@@ -269,12 +305,15 @@ func (s *AuthService) HasAnyPermission(ctx *UserContext, permissions []string) b
 	}
 
 	for _, permission := range permissions {
-		if _, ok := ctx.globalPermSet[permission]; ok {
+		if _, ok := ctx.globalPermSet[permission]; ok && !ctx.isDenied(permission) {
 			return true
 		}
 	}
 
 	for _, permission := range permissions {
+		if ctx.isDenied(permission) {
+			continue
+		}
 		for _, userPerm := range ctx.GlobalPermissions {
 			if userPerm == permission || !strings.Contains(userPerm, "*") {
 				continue
@@ -294,7 +333,7 @@ func (s *AuthService) HasBusinessPermission(ctx *UserContext, permission string)
 		return true
 	}
 
-	if ctx.BusinessContext == nil {
+	if ctx.BusinessContext == nil || ctx.isDenied(permission) {
 		return false
 	}
 
@@ -385,9 +424,10 @@ func (s *AuthService) GetAccessibleBusinessVerticals(user models.User) []uuid.UU
 		return nil
 	}
 
+	now := time.Now()
 	verticalMap := make(map[uuid.UUID]bool)
 	for _, ubr := range user.UserBusinessRoles {
-		if ubr.IsActive && ubr.BusinessRole.ID != uuid.Nil {
+		if ubr.IsEffectiveAt(now) && ubr.BusinessRole.ID != uuid.Nil && sameCompany(&user, ubr.BusinessRole.BusinessVerticalID) {
 			verticalMap[ubr.BusinessRole.BusinessVerticalID] = true
 		}
 	}
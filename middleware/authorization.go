@@ -3,7 +3,9 @@ package middleware
 import (
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"p9e.in/ugcl/config"
 	"p9e.in/ugcl/models"
 )
@@ -28,15 +30,27 @@ func Authorize(opts ...AuthOption) func(http.Handler) http.Handler {
 
 			// Check global permissions
 			if config.Permission != "" {
-				if !authService.HasPermission(userCtx, config.Permission) {
+				started := time.Now()
+				allowed := authService.HasPermission(userCtx, config.Permission)
+				if !allowed {
+					allowed = breakGlassAllows(r, userCtx, config.Permission, nil)
+				}
+				recordRBACDecision(r, userCtx, config.Permission, allowed, rbacReason(userCtx, allowed), started)
+				if !allowed {
 					handleAuthError(w, ErrForbidden)
 					return
 				}
+				if !enforcePermissionPolicies(w, r, userCtx, config.Permission) {
+					return
+				}
 			}
 
 			// Check any of permissions
 			if len(config.AnyPermissions) > 0 {
-				if !authService.HasAnyPermission(userCtx, config.AnyPermissions) {
+				started := time.Now()
+				allowed := authService.HasAnyPermission(userCtx, config.AnyPermissions)
+				recordRBACDecision(r, userCtx, strings.Join(config.AnyPermissions, "|"), allowed, rbacReason(userCtx, allowed), started)
+				if !allowed {
 					handleAuthError(w, ErrForbidden)
 					return
 				}
@@ -48,13 +62,26 @@ func Authorize(opts ...AuthOption) func(http.Handler) http.Handler {
 					handleAuthError(w, ErrBusinessNotSpecified)
 					return
 				}
-				if !authService.HasBusinessPermission(userCtx, config.BusinessPermission) {
+				started := time.Now()
+				allowed := authService.HasBusinessPermission(userCtx, config.BusinessPermission)
+				// Requests aimed at one site only count roles that cover that site
+				if siteID := RequestSiteID(r); allowed && siteID != nil {
+					allowed = authService.HasBusinessPermissionAtSite(userCtx, config.BusinessPermission, *siteID)
+				}
+				if !allowed {
+					allowed = breakGlassAllows(r, userCtx, config.BusinessPermission, &userCtx.BusinessContext.BusinessID)
+				}
+				recordRBACDecision(r, userCtx, config.BusinessPermission, allowed, rbacReason(userCtx, allowed), started)
+				if !allowed {
 					handleAuthError(w, &AuthError{
 						Code:    http.StatusForbidden,
 						Message: "insufficient permissions for this business vertical",
 					})
 					return
 				}
+				if !enforcePermissionPolicies(w, r, userCtx, config.BusinessPermission) {
+					return
+				}
 			}
 
 			// Check business access (any access)
@@ -237,7 +264,9 @@ func GetBusinessPermissions(r *http.Request) []string {
 }
 
 // GetEffectivePermissions returns de-duplicated permissions from both
-// global role and active business context, preserving first-seen order.
+// global role and active business context, preserving first-seen order,
+// plus those of active break-glass grants for that context.
+// Permissions withheld by deny entries are left out.
 func GetEffectivePermissions(r *http.Request) []string {
 	userCtx, err := authService.LoadUserContext(r)
 	if err != nil {
@@ -255,6 +284,9 @@ func GetEffectivePermissions(r *http.Request) []string {
 			if _, exists := seen[p]; exists {
 				continue
 			}
+			if userCtx.isDenied(p) {
+				continue
+			}
 			seen[p] = struct{}{}
 			permissions = append(permissions, p)
 		}
@@ -270,7 +302,36 @@ func GetEffectivePermissions(r *http.Request) []string {
 		appendUnique([]string{"admin_all", "*:*:*"})
 	}
 
-	return permissions
+	var businessID *uuid.UUID
+	if userCtx.BusinessContext != nil {
+		businessID = &userCtx.BusinessContext.BusinessID
+	}
+	return withBreakGlass(r, userCtx, permissions, businessID, time.Now())
+}
+
+// GetEffectivePermissionsInVertical returns the caller's permissions for a record owned
+// by businessID, which need not be the request's business context: their global role
+// plus their effective business roles and break-glass grants in that vertical, with
+// denies applied.
+func GetEffectivePermissionsInVertical(r *http.Request, businessID uuid.UUID) []string {
+	userCtx, err := authService.LoadUserContext(r)
+	if err != nil {
+		return []string{}
+	}
+
+	var permissions []string
+	seen := make(map[string]struct{})
+	for _, p := range userPermissionsInVertical(userCtx.User, businessID, nil) {
+		if _, exists := seen[p]; exists || p == "" || userCtx.isDenied(p) {
+			continue
+		}
+		seen[p] = struct{}{}
+		permissions = append(permissions, p)
+	}
+	if userCtx.IsSuperAdmin {
+		permissions = append(permissions, "admin_all", "*:*:*")
+	}
+	return withBreakGlass(r, userCtx, permissions, &businessID, time.Now())
 }
 
 // GetUserBusinessContext returns user's business context (for backward compatibility)
@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/models"
+)
+
+// Break-glass lookups and audit writes, replaced in tests
+var (
+	activeBreakGlassGrants = func(userID uuid.UUID, now time.Time) ([]models.BreakGlassGrant, error) {
+		var grants []models.BreakGlassGrant
+		err := config.DB.
+			Where("user_id = ? AND status = ? AND expires_at > ?", userID, models.BreakGlassActive, now).
+			Find(&grants).Error
+		return grants, err
+	}
+	recordBreakGlassUse = recordBreakGlassUseInDB
+)
+
+// breakGlassAllows is consulted after a permission check fails. It reports whether an
+// active break-glass grant of the user covers permission, and audits the use. Deny
+// entries still win over break-glass grants.
+func breakGlassAllows(r *http.Request, userCtx *UserContext, permission string, businessID *uuid.UUID) bool {
+	if userCtx == nil || userCtx.User == nil || userCtx.IsSuperAdmin || userCtx.isDenied(permission) {
+		return false
+	}
+
+	now := time.Now()
+	grants, err := activeBreakGlassGrants(userCtx.User.ID, now)
+	if err != nil {
+		log.Printf("break-glass lookup failed for user %s: %v", userCtx.User.ID, err)
+		return false
+	}
+
+	for i := range grants {
+		grant := &grants[i]
+		if !grant.Covers(permission, businessID, now) {
+			continue
+		}
+		recordBreakGlassUse(r, userCtx.User, grant, permission, now)
+		return true
+	}
+	return false
+}
+
+// withBreakGlass adds to permissions those the user's active global break-glass grants
+// cover, and those their grants in businessID's vertical cover when it is not nil, so
+// checks made against the effective permission set, such as workflow transitions,
+// honour the grants too. Each permission a grant adds is audited as a use of the grant;
+// denies still win.
+func withBreakGlass(r *http.Request, userCtx *UserContext, permissions []string, businessID *uuid.UUID, now time.Time) []string {
+	if userCtx == nil || userCtx.User == nil || userCtx.IsSuperAdmin {
+		return permissions
+	}
+
+	grants, err := activeBreakGlassGrants(userCtx.User.ID, now)
+	if err != nil {
+		log.Printf("break-glass lookup failed for user %s: %v", userCtx.User.ID, err)
+		return permissions
+	}
+
+	held := make(map[string]struct{}, len(permissions))
+	for _, p := range permissions {
+		held[p] = struct{}{}
+	}
+	for i := range grants {
+		grant := &grants[i]
+		for _, p := range grant.Permissions {
+			if _, exists := held[p]; exists || p == "" || userCtx.isDenied(p) {
+				continue
+			}
+			if !grant.Covers(p, nil, now) && (businessID == nil || !grant.Covers(p, businessID, now)) {
+				continue
+			}
+			held[p] = struct{}{}
+			permissions = append(permissions, p)
+			recordBreakGlassUse(r, userCtx.User, grant, p, now)
+		}
+	}
+	return permissions
+}
+
+// recordBreakGlassUseInDB logs the request a grant authorised. The first use of a grant
+// is also announced to the super admins.
+func recordBreakGlassUseInDB(r *http.Request, user *models.User, grant *models.BreakGlassGrant, permission string, now time.Time) {
+	if err := config.DB.Model(&models.BreakGlassGrant{}).
+		Where("id = ?", grant.ID).
+		Updates(map[string]interface{}{
+			"usage_count":  gorm.Expr("usage_count + 1"),
+			"last_used_at": now,
+		}).Error; err != nil {
+		log.Printf("break-glass usage update failed for grant %s: %v", grant.ID, err)
+	}
+	RecordBreakGlassEvent(r, grant, user.ID, models.BreakGlassEventUsed, permission, "")
+
+	if grant.UsageCount == 0 {
+		NotifySuperAdmins("Break-glass access used",
+			fmt.Sprintf("%s used break-glass permission %s: %s %s", user.Name, permission, r.Method, r.URL.Path),
+			grant, models.NotificationPriorityHigh)
+	}
+	grant.UsageCount++
+}
+
+// RecordBreakGlassEvent appends an entry to a grant's audit trail. r may be nil for
+// events raised by background jobs.
+func RecordBreakGlassEvent(r *http.Request, grant *models.BreakGlassGrant, actorID uuid.UUID, event, permission, details string) {
+	entry := models.BreakGlassEvent{
+		GrantID:    grant.ID,
+		UserID:     grant.UserID,
+		ActorID:    actorID,
+		Event:      event,
+		Permission: permission,
+		Details:    details,
+	}
+	if r != nil {
+		entry.Method = r.Method
+		entry.Path = r.URL.Path
+		if len(entry.Path) > 500 {
+			entry.Path = entry.Path[:500]
+		}
+		entry.IPAddress = clientIP(r)
+		entry.UserAgent = r.UserAgent()
+		if len(entry.UserAgent) > 255 {
+			entry.UserAgent = entry.UserAgent[:255]
+		}
+	}
+	if err := config.DB.Create(&entry).Error; err != nil {
+		log.Printf("break-glass audit write failed for grant %s (%s): %v", grant.ID, event, err)
+	}
+}
+
+// NotifySuperAdmins sends an in-app alert about a break-glass grant to every user whose
+// global role is super_admin.
+func NotifySuperAdmins(title, body string, grant *models.BreakGlassGrant, priority models.NotificationPriority) {
+	var adminIDs []uuid.UUID
+	if err := config.DB.Model(&models.User{}).
+		Joins("JOIN roles ON roles.id = users.role_id").
+		Where("roles.name = ? AND (users.role_valid_until IS NULL OR users.role_valid_until > ?)", "super_admin", time.Now()).
+		Pluck("users.id", &adminIDs).Error; err != nil {
+		log.Printf("failed to load super admins for break-glass alert: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, adminID := range adminIDs {
+		notification := &models.Notification{
+			UserID:             adminID.String(),
+			Type:               models.NotificationTypeSystemAlert,
+			Priority:           priority,
+			Title:              title,
+			Body:               body,
+			ActionURL:          fmt.Sprintf("/admin/break-glass/%s", grant.ID),
+			BusinessVerticalID: grant.BusinessVerticalID,
+			Status:             models.NotificationStatusSent,
+			Channel:            models.NotificationChannelInApp,
+			SentAt:             &now,
+			Metadata: models.JSONMap{
+				"break_glass_grant_id": grant.ID.String(),
+				"user_id":              grant.UserID.String(),
+			},
+		}
+		if err := config.DB.Create(notification).Error; err != nil {
+			log.Printf("failed to notify super admin %s about break-glass grant %s: %v", adminID, grant.ID, err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"p9e.in/ugcl/models"
+)
+
+func TestBreakGlassGrantEnablesWorkflowApprovalUntilExpiry(t *testing.T) {
+	workflow := models.WorkflowDefinition{
+		States:      json.RawMessage(`[{"code":"submitted"},{"code":"approved","is_final":true}]`),
+		Transitions: json.RawMessage(`[{"from":"submitted","to":"approved","action":"approve","permission":"finance:approve"}]`),
+	}
+	transition, err := workflow.FindTransition("submitted", "approve")
+	if err != nil {
+		t.Fatalf("FindTransition: %v", err)
+	}
+	required := transition.RequiredPermissionCode()
+
+	activated := time.Now()
+	grant := models.BreakGlassGrant{
+		ID:          uuid.New(),
+		Permissions: []string{required},
+		Status:      models.BreakGlassActive,
+		ActivatedAt: activated,
+		ExpiresAt:   activated.Add(time.Hour),
+	}
+
+	restoreGrants, restoreUse := activeBreakGlassGrants, recordBreakGlassUse
+	defer func() { activeBreakGlassGrants, recordBreakGlassUse = restoreGrants, restoreUse }()
+	activeBreakGlassGrants = func(uuid.UUID, time.Time) ([]models.BreakGlassGrant, error) {
+		return []models.BreakGlassGrant{grant}, nil
+	}
+	var used []string
+	recordBreakGlassUse = func(_ *http.Request, _ *models.User, g *models.BreakGlassGrant, permission string, _ time.Time) {
+		if g.ID != grant.ID {
+			t.Errorf("use recorded against grant %s, want %s", g.ID, grant.ID)
+		}
+		used = append(used, permission)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/purchase-orders/x/actions/approve", nil)
+	userCtx := &UserContext{User: &models.User{ID: uuid.New()}}
+	held := []string{"finance:read"}
+
+	cases := []struct {
+		name string
+		at   time.Time
+		want bool
+	}{
+		{"active grant", activated.Add(30 * time.Minute), true},
+		{"expired grant", activated.Add(2 * time.Hour), false},
+	}
+	for _, c := range cases {
+		used = nil
+		permissions := withBreakGlass(r, userCtx, append([]string(nil), held...), nil, c.at)
+		if got := permissionListMatches(permissions, required); got != c.want {
+			t.Errorf("%s: may approve = %v, want %v (permissions %v)", c.name, got, c.want, permissions)
+		}
+		if c.want != (len(used) == 1) {
+			t.Errorf("%s: recorded uses %v", c.name, used)
+		}
+	}
+
+	userCtx.DeniedPermissions = []string{required}
+	if permissions := withBreakGlass(r, userCtx, held, nil, activated); permissionListMatches(permissions, required) {
+		t.Error("a break-glass grant overrode a deny")
+	}
+}
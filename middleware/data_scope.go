@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/datascope"
+)
+
+// AllVerticalsPermission lets a global role read and write every vertical of its company
+// through ScopedDB, e.g. head office admins.
+const AllVerticalsPermission = "data:all_verticals"
+
+// DataScopeMiddleware attaches the caller's row-level data scope (allowed business
+// verticals and sites) to the request context, so queries run through ScopedDB are
+// filtered even when a handler forgets its WHERE clause. It must run after JWTMiddleware.
+func DataScopeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if GetClaims(r) == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(datascope.WithScope(r.Context(), buildDataScope(r))))
+	})
+}
+
+// ScopedDB returns config.DB bound to the request's data scope. Handlers use it instead
+// of config.DB; a handlers test fails on request code that queries config.DB directly.
+func ScopedDB(r *http.Request) *gorm.DB {
+	return WithDataScope(r, config.DB)
+}
+
+// WithDataScope binds db (e.g. a handler's injected connection) to the request's data scope
+func WithDataScope(r *http.Request, db *gorm.DB) *gorm.DB {
+	ctx := r.Context()
+	if _, ok := datascope.FromContext(ctx); !ok && GetClaims(r) != nil {
+		ctx = datascope.WithScope(ctx, buildDataScope(r))
+	}
+	return db.WithContext(ctx)
+}
+
+// DataScopeStatus maps a database error to an HTTP status: 403 for writes outside the
+// caller's data scope, 500 otherwise.
+func DataScopeStatus(err error) int {
+	if errors.Is(err, datascope.ErrOutOfScope) {
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// InDataScope reports whether a record of the vertical (and site, if any) is inside the
+// caller's data scope. Handlers reading records with raw SQL, which ScopedDB cannot
+// filter, check each record with it.
+func InDataScope(r *http.Request, verticalID uuid.UUID, siteID *uuid.UUID) bool {
+	scope, ok := datascope.FromContext(r.Context())
+	if !ok {
+		if GetClaims(r) == nil {
+			return true
+		}
+		scope = buildDataScope(r)
+	}
+	companyOf := func(id uuid.UUID) uuid.UUID {
+		var vertical models.BusinessVertical
+		if err := config.DB.Select("company_id").First(&vertical, "id = ?", id).Error; err != nil || vertical.CompanyID == nil {
+			return uuid.Nil
+		}
+		return *vertical.CompanyID
+	}
+	if !scope.AllowsVertical(verticalID, companyOf) {
+		return false
+	}
+	if scope.SiteRestricted && (siteID == nil || !scope.AllowsSite(*siteID)) {
+		return false
+	}
+	return true
+}
+
+// DataScopeVerticals lists the active verticals inside the caller's data scope, for
+// handlers that aggregate across verticals. Site restrictions still apply per record.
+func DataScopeVerticals(r *http.Request) ([]uuid.UUID, error) {
+	scope, ok := datascope.FromContext(r.Context())
+	if !ok {
+		scope = buildDataScope(r)
+	}
+	query := config.DB.Model(&models.BusinessVertical{}).Where("is_active = ?", true)
+	if scope.AllVerticals {
+		if scope.CompanyID != uuid.Nil {
+			query = query.Where("company_id = ?", scope.CompanyID)
+		}
+	} else {
+		if len(scope.VerticalIDs) == 0 {
+			return nil, nil
+		}
+		query = query.Where("id IN ?", scope.VerticalIDs)
+	}
+	var ids []uuid.UUID
+	if err := query.Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// buildDataScope derives the scope from the caller's assignments: super admins and
+// holders of data:all_verticals see their company's verticals, everyone else only the
+// verticals they hold an effective role in (plus their primary vertical), narrowed to
+// the vertical the request names. Site-restricted users see only their sites' rows.
+func buildDataScope(r *http.Request) *datascope.Scope {
+	userCtx, err := authService.LoadUserContext(r)
+	if err != nil {
+		return &datascope.Scope{} // matches nothing
+	}
+
+	scope := &datascope.Scope{CompanyID: GetCurrentCompanyID(r)}
+	if userCtx.IsSuperAdmin || authService.HasPermission(userCtx, AllVerticalsPermission) {
+		scope.AllVerticals = true
+		return scope
+	}
+
+	allowed := authService.GetAccessibleBusinessVerticals(*userCtx.User)
+	if primary := userCtx.User.BusinessVerticalID; primary != nil && sameCompany(userCtx.User, *primary) {
+		allowed = appendUniqueID(allowed, *primary)
+	}
+	scope.VerticalIDs = allowed
+
+	if requested := getBusinessIDFromRequest(r); requested != uuid.Nil {
+		scope.VerticalIDs = nil
+		for _, id := range allowed {
+			if id == requested {
+				scope.VerticalIDs = []uuid.UUID{requested}
+			}
+		}
+	}
+
+	if bc := userCtx.BusinessContext; bc.SiteRestricted() {
+		scope.SiteRestricted = true
+		scope.SiteIDs = bc.ScopedSiteIDs()
+	}
+	return scope
+}
+
+func appendUniqueID(ids []uuid.UUID, id uuid.UUID) []uuid.UUID {
+	for _, existing := range ids {
+		if existing == id {
+			return ids
+		}
+	}
+	return append(ids, id)
+}
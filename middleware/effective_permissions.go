@@ -0,0 +1,258 @@
+package middleware
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/abac"
+)
+
+// Permission source types reported by ResolveEffectivePermissions
+const (
+	PermissionSourceGlobalRole   = "global_role"
+	PermissionSourceBusinessRole = "business_role"
+	PermissionSourceSiteRole     = "site_role"
+	PermissionSourceABACPolicy   = "abac_policy"
+	PermissionSourceSuperAdmin   = "super_admin"
+	PermissionSourceDenyRule     = "deny_rule"
+)
+
+// PermissionSource explains where a permission comes from, or which policy withholds it
+type PermissionSource struct {
+	Type               string     `json:"type"`
+	ID                 *uuid.UUID `json:"id,omitempty"`
+	Name               string     `json:"name"`
+	BusinessVerticalID *uuid.UUID `json:"business_vertical_id,omitempty"`
+	SiteID             *uuid.UUID `json:"site_id,omitempty"`
+	ValidUntil         *time.Time `json:"valid_until,omitempty"`
+}
+
+// EffectivePermission is one resolved permission with its attribution.
+// SiteIDs is set when the permission only holds at specific sites.
+type EffectivePermission struct {
+	Permission string             `json:"permission"`
+	Allowed    bool               `json:"allowed"`
+	Sources    []PermissionSource `json:"sources"`
+	SiteIDs    []uuid.UUID        `json:"site_ids,omitempty"`
+	DeniedBy   []PermissionSource `json:"denied_by,omitempty"`
+}
+
+// EffectivePermissionSet is the fully resolved permission set for a user in the
+// active business context.
+type EffectivePermissionSet struct {
+	UserID             uuid.UUID             `json:"user_id"`
+	BusinessVerticalID *uuid.UUID            `json:"business_vertical_id,omitempty"`
+	IsSuperAdmin       bool                  `json:"is_super_admin"`
+	IsBusinessAdmin    bool                  `json:"is_business_admin"`
+	SiteRestricted     bool                  `json:"site_restricted"`
+	Allowed            []string              `json:"allowed"`
+	Permissions        []EffectivePermission `json:"permissions"`
+	ResolvedAt         time.Time             `json:"resolved_at"`
+}
+
+// ResolveEffectivePermissions combines the global role, business roles, site-scoped roles
+// and ABAC policies matching the user's attributes into one attributed permission set.
+// ABAC policies are only considered when they name concrete permissions in their actions.
+// Deny entries and DENY policies are reported in DeniedBy and withhold the permission.
+func (s *AuthService) ResolveEffectivePermissions(r *http.Request, userCtx *UserContext) *EffectivePermissionSet {
+	now := time.Now()
+	user := userCtx.User
+	set := &EffectivePermissionSet{
+		UserID:       user.ID,
+		IsSuperAdmin: userCtx.IsSuperAdmin,
+		ResolvedAt:   now,
+	}
+
+	byName := make(map[string]*EffectivePermission)
+	siteOnly := make(map[string]bool)
+	add := func(permission string, source PermissionSource) *EffectivePermission {
+		entry, ok := byName[permission]
+		if !ok {
+			entry = &EffectivePermission{Permission: permission}
+			byName[permission] = entry
+			siteOnly[permission] = true
+		}
+		entry.Sources = append(entry.Sources, source)
+		if source.SiteID == nil {
+			siteOnly[permission] = false
+		} else {
+			entry.SiteIDs = append(entry.SiteIDs, *source.SiteID)
+		}
+		return entry
+	}
+
+	if userCtx.IsSuperAdmin {
+		add("*:*:*", PermissionSource{Type: PermissionSourceSuperAdmin, Name: "super_admin"})
+	}
+
+	if role := user.EffectiveRole(); role != nil {
+		for _, perm := range role.Permissions {
+			add(perm.Name, PermissionSource{
+				Type:       PermissionSourceGlobalRole,
+				ID:         &role.ID,
+				Name:       role.Name,
+				ValidUntil: user.RoleValidUntil,
+			})
+		}
+	}
+
+	var businessID *uuid.UUID
+	if bc := userCtx.BusinessContext; bc != nil {
+		businessID = &bc.BusinessID
+		set.BusinessVerticalID = businessID
+		set.IsBusinessAdmin = bc.IsBusinessAdmin
+		set.SiteRestricted = bc.SiteRestricted()
+
+		for _, ubr := range bc.BusinessRoles {
+			sourceType := PermissionSourceBusinessRole
+			if ubr.SiteID != nil {
+				sourceType = PermissionSourceSiteRole
+			}
+			roleID := ubr.BusinessRole.ID
+			verticalID := ubr.BusinessRole.BusinessVerticalID
+			for _, perm := range ubr.BusinessRole.Permissions {
+				add(perm.Name, PermissionSource{
+					Type:               sourceType,
+					ID:                 &roleID,
+					Name:               ubr.BusinessRole.Name,
+					BusinessVerticalID: &verticalID,
+					SiteID:             ubr.SiteID,
+					ValidUntil:         ubr.ValidUntil,
+				})
+			}
+		}
+	}
+
+	if !userCtx.IsSuperAdmin {
+		role := userCtx.Claims.Role
+		if effective := user.EffectiveRole(); effective != nil {
+			role = effective.Name
+		}
+		policyReq := buildPolicyRequest(r, user.ID, role, "", "", nil, businessID)
+		policies, err := abac.NewPolicyEngine(config.DB).MatchingUserPolicies(policyReq)
+		if err == nil {
+			applyPolicyAttribution(policies, byName, add)
+		}
+	}
+
+	if !userCtx.IsSuperAdmin {
+		for _, deny := range applicableDenies(user, userCtx.BusinessContext) {
+			id := deny.ID
+			for name, entry := range byName {
+				if models.DeniesPermission([]string{deny.Permission}, name) {
+					entry.DeniedBy = append(entry.DeniedBy, PermissionSource{
+						Type:               PermissionSourceDenyRule,
+						ID:                 &id,
+						Name:               deny.Permission,
+						BusinessVerticalID: deny.BusinessVerticalID,
+					})
+				}
+			}
+		}
+	}
+
+	set.Permissions = make([]EffectivePermission, 0, len(byName))
+	set.Allowed = make([]string, 0, len(byName))
+	for name, entry := range byName {
+		if !siteOnly[name] {
+			entry.SiteIDs = nil
+		}
+		entry.Allowed = len(entry.Sources) > 0 && len(entry.DeniedBy) == 0
+		if entry.Allowed {
+			set.Allowed = append(set.Allowed, name)
+		}
+		set.Permissions = append(set.Permissions, *entry)
+	}
+	sort.Strings(set.Allowed)
+	sort.Slice(set.Permissions, func(i, j int) bool {
+		return set.Permissions[i].Permission < set.Permissions[j].Permission
+	})
+
+	return set
+}
+
+// applyPolicyAttribution records matching ALLOW policies as grants of the concrete
+// permissions they list, and matching DENY policies against every held permission
+// their actions cover (deny-overrides, as in enforcePermissionPolicies).
+func applyPolicyAttribution(policies []models.Policy, byName map[string]*EffectivePermission, add func(string, PermissionSource) *EffectivePermission) {
+	var denies []models.Policy
+	for _, policy := range policies {
+		if policy.Effect == models.PolicyEffectDeny {
+			denies = append(denies, policy)
+			continue
+		}
+		if policy.Effect != models.PolicyEffectAllow {
+			continue
+		}
+		for _, action := range policyActions(policy) {
+			if strings.Contains(action, "*") || !strings.Contains(action, ":") {
+				continue
+			}
+			add(action, policySource(policy))
+		}
+	}
+
+	for _, policy := range denies {
+		actions := policyActions(policy)
+		for name, entry := range byName {
+			if !policyCoversResource(policy, permissionResourceType(name)) {
+				continue
+			}
+			if len(actions) == 0 || permissionListMatches(actions, name) || actionPrefixMatches(actions, name) {
+				entry.DeniedBy = append(entry.DeniedBy, policySource(policy))
+			}
+		}
+	}
+}
+
+func policyActions(policy models.Policy) []string {
+	actions := make([]string, 0, len(policy.Actions))
+	for _, a := range policy.Actions {
+		if s, ok := a.(string); ok && s != "" {
+			actions = append(actions, s)
+		}
+	}
+	return actions
+}
+
+// actionPrefixMatches mirrors the policy engine's "project:*" style action matching
+func actionPrefixMatches(actions []string, permission string) bool {
+	for _, action := range actions {
+		if action == "*" {
+			return true
+		}
+		if strings.HasSuffix(action, "*") && strings.HasPrefix(permission, strings.TrimSuffix(action, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// policyCoversResource mirrors the policy engine's resource filter
+func policyCoversResource(policy models.Policy, resourceType string) bool {
+	if len(policy.Resources) == 0 {
+		return true
+	}
+	for _, r := range policy.Resources {
+		if s, ok := r.(string); ok && (s == "*" || s == resourceType) {
+			return true
+		}
+	}
+	return false
+}
+
+func policySource(policy models.Policy) PermissionSource {
+	id := policy.ID
+	return PermissionSource{
+		Type:               PermissionSourceABACPolicy,
+		ID:                 &id,
+		Name:               policy.Name,
+		BusinessVerticalID: policy.BusinessVerticalID,
+		ValidUntil:         policy.ValidUntil,
+	}
+}
@@ -37,3 +37,8 @@ func GinSecurityMiddleware() gin.HandlerFunc {
 func GinJWTMiddleware() gin.HandlerFunc {
 	return AdaptHTTPMiddleware(JWTMiddleware)
 }
+
+// GinReadOnlyRoleMiddleware blocks writes from read-only roles on Gin routes.
+func GinReadOnlyRoleMiddleware() gin.HandlerFunc {
+	return AdaptHTTPMiddleware(ReadOnlyRoleMiddleware)
+}
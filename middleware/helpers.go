@@ -241,7 +241,7 @@ func loadUserWithAuthGraph(userID uuid.UUID) (models.User, error) {
 	}
 
 	loaded, err, _ := userContextLoadGroup.Do(cacheKey, func() (interface{}, error) {
-		if cachedUser, ok := userCache.get(cacheKey); ok {
+		if cachedUser, ok := userCache.peek(cacheKey); ok {
 			return cachedUser, nil
 		}
 
@@ -257,7 +257,7 @@ func loadUserWithAuthGraph(userID uuid.UUID) (models.User, error) {
 		}
 
 		userCache.set(cacheKey, freshUser)
-		cachedUser, ok := userCache.get(cacheKey)
+		cachedUser, ok := userCache.peek(cacheKey)
 		if !ok {
 			return nil, ErrUserNotFound
 		}
@@ -38,6 +38,8 @@ type Claims struct {
 	Name   string `json:"name"`
 	Phone  string `json:"phone"`
 	Role   string `json:"role"`
+	// CompanyID is the tenant the user belongs to; empty for users not yet assigned one
+	CompanyID string `json:"companyId,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -57,12 +59,13 @@ type thirdPartyRequestContext struct {
 }
 
 // GenerateToken creates a signed JWT valid for 24 h
-func GenerateToken(userID, role, name, phone string) (string, error) {
+func GenerateToken(userID, role, name, phone, companyID string) (string, error) {
 	claims := Claims{
-		UserID: userID,
-		Name:   name,
-		Phone:  phone,
-		Role:   role,
+		UserID:    userID,
+		Name:      name,
+		Phone:     phone,
+		Role:      role,
+		CompanyID: companyID,
 
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
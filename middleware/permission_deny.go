@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/models"
+)
+
+// permissionDenyCacheTTL bounds how stale the deny snapshot can get across instances;
+// writes on this instance invalidate it immediately.
+const permissionDenyCacheTTL = 5 * time.Minute
+
+// permissionDenyCacheStore holds every deny entry. The table is small and read on each
+// authorization, so one snapshot indexed by target is cheaper than per-user queries.
+type permissionDenyCacheStore struct {
+	mu             sync.Mutex
+	loaded         bool
+	expiresAt      time.Time
+	byRole         map[uuid.UUID][]models.PermissionDeny
+	byBusinessRole map[uuid.UUID][]models.PermissionDeny
+	byUser         map[uuid.UUID][]models.PermissionDeny
+}
+
+var permissionDenyCache = &permissionDenyCacheStore{}
+
+func (c *permissionDenyCacheStore) snapshot() (*permissionDenyCacheStore, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.loaded && time.Now().Before(c.expiresAt) {
+		return c, nil
+	}
+
+	var denies []models.PermissionDeny
+	if err := config.DB.Find(&denies).Error; err != nil {
+		return nil, err
+	}
+	c.byRole = make(map[uuid.UUID][]models.PermissionDeny)
+	c.byBusinessRole = make(map[uuid.UUID][]models.PermissionDeny)
+	c.byUser = make(map[uuid.UUID][]models.PermissionDeny)
+	for _, deny := range denies {
+		switch {
+		case deny.RoleID != nil:
+			c.byRole[*deny.RoleID] = append(c.byRole[*deny.RoleID], deny)
+		case deny.BusinessRoleID != nil:
+			c.byBusinessRole[*deny.BusinessRoleID] = append(c.byBusinessRole[*deny.BusinessRoleID], deny)
+		case deny.UserID != nil:
+			c.byUser[*deny.UserID] = append(c.byUser[*deny.UserID], deny)
+		}
+	}
+	c.loaded = true
+	c.expiresAt = time.Now().Add(permissionDenyCacheTTL)
+	return c, nil
+}
+
+// InvalidatePermissionDenyCache reloads deny entries on the next authorization.
+func InvalidatePermissionDenyCache() {
+	permissionDenyCache.mu.Lock()
+	permissionDenyCache.loaded = false
+	permissionDenyCache.mu.Unlock()
+}
+
+// applicableDenies returns the deny entries that apply to the user: their own (everywhere
+// or in businessCtx's vertical), their global role's, and those of the business roles
+// active in businessCtx.
+func applicableDenies(user *models.User, businessCtx *BusinessContext) []models.PermissionDeny {
+	cache, err := permissionDenyCache.snapshot()
+	if err != nil {
+		log.Printf("failed to load permission denies: %v", err)
+		return nil
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	var denies []models.PermissionDeny
+	for _, deny := range cache.byUser[user.ID] {
+		if deny.BusinessVerticalID == nil || (businessCtx != nil && *deny.BusinessVerticalID == businessCtx.BusinessID) {
+			denies = append(denies, deny)
+		}
+	}
+	if role := user.EffectiveRole(); role != nil {
+		denies = append(denies, cache.byRole[role.ID]...)
+	}
+	if businessCtx != nil {
+		for _, ubr := range businessCtx.BusinessRoles {
+			denies = append(denies, cache.byBusinessRole[ubr.BusinessRoleID]...)
+		}
+	}
+	return denies
+}
+
+func denyPermissionNames(denies []models.PermissionDeny) []string {
+	names := make([]string, 0, len(denies))
+	for _, deny := range denies {
+		names = append(names, deny.Permission)
+	}
+	return names
+}
+
+// isDenied reports whether a deny entry withholds permission. Super admins are exempt.
+func (ctx *UserContext) isDenied(permission string) bool {
+	return !ctx.IsSuperAdmin && models.DeniesPermission(ctx.DeniedPermissions, permission)
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/abac"
+)
+
+// recordRBACDecision queues a role-based permission decision on the policy evaluation
+// recorder. Sampling is checked first so discarded decisions cost almost nothing.
+func recordRBACDecision(r *http.Request, userCtx *UserContext, action string, allowed bool, reason string, started time.Time) {
+	recorder := abac.DefaultRecorder()
+	if recorder == nil || userCtx == nil || userCtx.Claims == nil || !recorder.Sample(allowed) {
+		return
+	}
+
+	userID, err := uuid.Parse(userCtx.Claims.UserID)
+	if err != nil {
+		return
+	}
+
+	effect := models.PolicyEffectDeny
+	if allowed {
+		effect = models.PolicyEffectAllow
+	}
+	if len(action) > 100 {
+		action = action[:100]
+	}
+
+	duration := time.Since(started)
+	evaluation := models.PolicyEvaluation{
+		Source:             models.PolicyEvaluationSourceRBAC,
+		UserID:             userID,
+		ResourceType:       permissionResourceType(action),
+		Action:             action,
+		Effect:             effect,
+		Reason:             reason,
+		Context:            models.JSONMap{"role": userCtx.Claims.Role, "method": r.Method},
+		EvaluationTime:     time.Now(),
+		IPAddress:          getClientIP(r),
+		UserAgent:          r.UserAgent(),
+		RequestPath:        r.URL.Path,
+		EvaluationDuration: int(duration.Milliseconds()),
+		LatencyMicros:      duration.Microseconds(),
+	}
+	if userCtx.BusinessContext != nil {
+		businessID := userCtx.BusinessContext.BusinessID
+		evaluation.BusinessVerticalID = &businessID
+	}
+
+	// Sampling already happened above; bypass the recorder's second roll.
+	recorder.Enqueue(evaluation)
+}
+
+func rbacReason(userCtx *UserContext, allowed bool) string {
+	switch {
+	case userCtx.IsSuperAdmin:
+		return "super admin"
+	case allowed:
+		return "permission granted by role"
+	default:
+		return "permission not granted"
+	}
+}
@@ -57,7 +57,8 @@ func IsSuperAdmin(userID uuid.UUID) bool {
 		return false
 	}
 
-	return user.RoleModel != nil && user.RoleModel.Name == "super_admin"
+	role := user.EffectiveRole()
+	return role != nil && role.Name == "super_admin"
 }
 
 // // HasPermissionInVertical checks if user has a specific permission in a business vertical
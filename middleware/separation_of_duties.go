@@ -0,0 +1,231 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/models"
+)
+
+// SoDOverrideHeader carries a super admin's reason for overriding a separation-of-duties
+// rule. Requests without it are rejected when they would violate a rule.
+const SoDOverrideHeader = "X-SoD-Override-Reason"
+
+// SoDViolationError reports the separation-of-duties rules an action would violate
+type SoDViolationError struct {
+	Conflicts       []models.SoDRule
+	OverrideAllowed bool // the actor is a super admin and may retry with an override reason
+}
+
+func (e *SoDViolationError) Error() string {
+	names := make([]string, len(e.Conflicts))
+	for i, rule := range e.Conflicts {
+		names[i] = fmt.Sprintf("%s (%s / %s)", rule.Name, rule.PermissionA, rule.PermissionB)
+	}
+	return "separation of duties violation: " + strings.Join(names, ", ")
+}
+
+// WriteSoDViolation writes err as a 409 listing the violated rules, or as a 500 when it
+// is not a SoD violation.
+func WriteSoDViolation(w http.ResponseWriter, err error) {
+	var violation *SoDViolationError
+	if !errors.As(err, &violation) {
+		http.Error(w, "separation of duties check failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	payload := map[string]interface{}{
+		"error":     violation.Error(),
+		"conflicts": violation.Conflicts,
+	}
+	if violation.OverrideAllowed {
+		payload["override"] = "retry with the " + SoDOverrideHeader + " header to override and record a reason"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(payload)
+}
+
+// SoDRulesForVertical returns the active rules that apply in a vertical, including global ones
+func SoDRulesForVertical(businessID uuid.UUID) ([]models.SoDRule, error) {
+	var rules []models.SoDRule
+	err := config.DB.
+		Where("is_active = ? AND (business_vertical_id IS NULL OR business_vertical_id = ?)", true, businessID).
+		Find(&rules).Error
+	return rules, err
+}
+
+// UserPermissionsInVertical returns the permissions a user currently holds in a vertical:
+// their global role plus every effective business role in the vertical, site-scoped included.
+func UserPermissionsInVertical(userID, businessID uuid.UUID) ([]string, error) {
+	user, err := loadUserWithAuthGraph(userID)
+	if err != nil {
+		return nil, err
+	}
+	return userPermissionsInVertical(&user, businessID, nil), nil
+}
+
+// userPermissionsInVertical skips the assignment being replaced, if any
+func userPermissionsInVertical(user *models.User, businessID uuid.UUID, replacing *uuid.UUID) []string {
+	var held []string
+	if role := user.EffectiveRole(); role != nil {
+		for _, perm := range role.Permissions {
+			held = append(held, perm.Name)
+		}
+	}
+	now := time.Now()
+	for _, ubr := range user.UserBusinessRoles {
+		if !ubr.IsEffectiveAt(now) || ubr.BusinessRole.BusinessVerticalID != businessID {
+			continue
+		}
+		if replacing != nil && ubr.ID == *replacing {
+			continue
+		}
+		for _, perm := range ubr.BusinessRole.Permissions {
+			held = append(held, perm.Name)
+		}
+	}
+	return held
+}
+
+// RoleAssignmentSoDConflicts returns the rules the user would violate after being granted
+// roleID in the vertical. replacing names an existing assignment the new one supersedes.
+func RoleAssignmentSoDConflicts(userID, businessID, roleID uuid.UUID, replacing *uuid.UUID) ([]models.SoDRule, error) {
+	rules, err := SoDRulesForVertical(businessID)
+	if err != nil || len(rules) == 0 {
+		return nil, err
+	}
+
+	var granted []string
+	if err := config.DB.Model(&models.Permission{}).
+		Joins("JOIN business_role_permissions brp ON brp.permission_id = permissions.id").
+		Where("brp.business_role_id = ?", roleID).
+		Pluck("permissions.name", &granted).Error; err != nil {
+		return nil, err
+	}
+
+	user, err := loadUserWithAuthGraph(userID)
+	if err != nil {
+		return nil, err
+	}
+	held := userPermissionsInVertical(&user, businessID, replacing)
+	return models.FindSoDConflicts(rules, append(held, granted...)), nil
+}
+
+// EnforceRoleAssignmentSoD checks that granting roleID to userID in the vertical violates
+// no SoD rule. A super admin may override by sending SoDOverrideHeader; the override is
+// recorded per rule. It returns a *SoDViolationError when the assignment must be refused.
+func EnforceRoleAssignmentSoD(r *http.Request, userID, businessID, roleID uuid.UUID, replacing *uuid.UUID) error {
+	conflicts, err := RoleAssignmentSoDConflicts(userID, businessID, roleID, replacing)
+	if err != nil {
+		return err
+	}
+	return resolveSoDConflicts(r, conflicts, userID, businessID, models.SoDContextRoleAssignment, "business_role:"+roleID.String())
+}
+
+// EnforceApprovalSoD checks that the current user may act with permission (typically an
+// approval) in the vertical without holding the other side of a SoD rule. Overrides work
+// as in EnforceRoleAssignmentSoD.
+func EnforceApprovalSoD(r *http.Request, businessID uuid.UUID, permission, reference string) error {
+	claims := GetClaims(r)
+	if claims == nil {
+		return errors.New("unauthorized")
+	}
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return err
+	}
+
+	rules, err := SoDRulesForVertical(businessID)
+	if err != nil || len(rules) == 0 {
+		return err
+	}
+	held, err := UserPermissionsInVertical(userID, businessID)
+	if err != nil {
+		return err
+	}
+	conflicts := models.FindApprovalSoDConflicts(rules, held, permission)
+	return resolveSoDConflicts(r, conflicts, userID, businessID, models.SoDContextApproval, reference)
+}
+
+// RequireSoDClearance guards an approval route: the caller must not hold the opposing
+// permission of any SoD rule covering permission in the current business.
+func RequireSoDClearance(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			businessID := GetCurrentBusinessID(r)
+			if businessID == uuid.Nil {
+				http.Error(w, "invalid business identifier", http.StatusBadRequest)
+				return
+			}
+			if err := EnforceApprovalSoD(r, businessID, permission, r.Method+" "+r.URL.Path); err != nil {
+				WriteSoDViolation(w, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// resolveSoDConflicts allows the action when there are no conflicts or a super admin
+// supplied an override reason, in which case one SoDOverride per rule is recorded.
+func resolveSoDConflicts(r *http.Request, conflicts []models.SoDRule, userID, businessID uuid.UUID, context, reference string) error {
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	actorID, isSuperAdmin := sodActor(r)
+	reason := strings.TrimSpace(r.Header.Get(SoDOverrideHeader))
+	if !isSuperAdmin || reason == "" {
+		return &SoDViolationError{Conflicts: conflicts, OverrideAllowed: isSuperAdmin}
+	}
+	return RecordSoDOverrides(conflicts, userID, actorID, businessID, context, reference, reason)
+}
+
+// RecordSoDOverrides writes the audit entries for a super admin override
+func RecordSoDOverrides(conflicts []models.SoDRule, userID, actorID, businessID uuid.UUID, context, reference, reason string) error {
+	overrides := make([]models.SoDOverride, len(conflicts))
+	for i, rule := range conflicts {
+		overrides[i] = models.SoDOverride{
+			RuleID:             rule.ID,
+			BusinessVerticalID: &businessID,
+			UserID:             userID,
+			ActorID:            actorID,
+			Context:            context,
+			Reference:          reference,
+			Reason:             reason,
+		}
+	}
+	if err := config.DB.Create(&overrides).Error; err != nil {
+		return fmt.Errorf("failed to record SoD override: %w", err)
+	}
+	return nil
+}
+
+// SoDOverrideReason returns the override reason on the request when the caller is a
+// super admin, or "" otherwise.
+func SoDOverrideReason(r *http.Request) (actorID uuid.UUID, reason string) {
+	actorID, isSuperAdmin := sodActor(r)
+	if !isSuperAdmin {
+		return uuid.Nil, ""
+	}
+	return actorID, strings.TrimSpace(r.Header.Get(SoDOverrideHeader))
+}
+
+func sodActor(r *http.Request) (uuid.UUID, bool) {
+	claims := GetClaims(r)
+	if claims == nil {
+		return uuid.Nil, false
+	}
+	actorID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return actorID, IsSuperAdmin(actorID)
+}
@@ -3,6 +3,7 @@ package middleware
 import (
 	"context"
 	"net/http"
+	"strings"
 
 	"github.com/google/uuid"
 	"p9e.in/ugcl/config"
@@ -65,12 +66,6 @@ func RequireSiteAccess() func(http.Handler) http.Handler {
 				return
 			}
 
-			// Check if user has access to at least one site
-			if len(siteAccess) == 0 {
-				http.Error(w, "no site access granted", http.StatusForbidden)
-				return
-			}
-
 			// Build site access context
 			siteIDs := make([]uuid.UUID, 0, len(siteAccess))
 			sitePerms := make(map[uuid.UUID]SitePerm)
@@ -85,6 +80,27 @@ func RequireSiteAccess() func(http.Handler) http.Handler {
 				}
 			}
 
+			// Site-scoped business roles also grant access to their site
+			if userCtx, err := authService.LoadUserContext(r); err == nil && userCtx.BusinessContext != nil {
+				for siteID, perms := range userCtx.BusinessContext.sitePermissions {
+					perm, exists := sitePerms[siteID]
+					if !exists {
+						siteIDs = append(siteIDs, siteID)
+					}
+					perm.CanRead = true
+					perm.CanCreate = perm.CanCreate || permissionListHasAction(perms, "create")
+					perm.CanUpdate = perm.CanUpdate || permissionListHasAction(perms, "update")
+					perm.CanDelete = perm.CanDelete || permissionListHasAction(perms, "delete")
+					sitePerms[siteID] = perm
+				}
+			}
+
+			// Check if user has access to at least one site
+			if len(siteIDs) == 0 {
+				http.Error(w, "no site access granted", http.StatusForbidden)
+				return
+			}
+
 			siteAccessCtx := SiteAccessContext{
 				AccessibleSiteIDs: siteIDs,
 				SitePermissions:   sitePerms,
@@ -186,3 +202,15 @@ func CanDeleteInSite(r *http.Request, siteID uuid.UUID) bool {
 	}
 	return false
 }
+
+// permissionListHasAction reports whether any permission grants the action, e.g.
+// "inventory:create" or "*:*" for "create".
+func permissionListHasAction(perms []string, action string) bool {
+	for _, perm := range perms {
+		parts := strings.Split(perm, ":")
+		if last := parts[len(parts)-1]; last == action || last == "*" {
+			return true
+		}
+	}
+	return false
+}
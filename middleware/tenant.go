@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/config"
+	"p9e.in/ugcl/models"
+)
+
+// CompanyHeader lets super admins act within one company (tenant) of the deployment
+const CompanyHeader = "X-Company-ID"
+
+const verticalCompanyCacheTTL = 15 * time.Minute
+
+// verticalCompanyCacheStore maps business vertical IDs to their company; it is read on
+// every business access check.
+type verticalCompanyCacheStore struct {
+	mu        sync.Mutex
+	companies map[uuid.UUID]uuid.UUID
+	expiresAt time.Time
+}
+
+var verticalCompanyCache = &verticalCompanyCacheStore{}
+
+// InvalidateVerticalCompanyCache reloads the vertical to company mapping on next use.
+func InvalidateVerticalCompanyCache() {
+	verticalCompanyCache.mu.Lock()
+	verticalCompanyCache.companies = nil
+	verticalCompanyCache.mu.Unlock()
+}
+
+// VerticalCompanyID returns the company owning a business vertical, or uuid.Nil when the
+// vertical is unknown or not assigned to a company.
+func VerticalCompanyID(businessID uuid.UUID) uuid.UUID {
+	c := verticalCompanyCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.companies == nil || time.Now().After(c.expiresAt) {
+		var verticals []models.BusinessVertical
+		if err := config.DB.Select("id", "company_id").Find(&verticals).Error; err != nil {
+			log.Printf("failed to load vertical companies: %v", err)
+			return uuid.Nil
+		}
+		c.companies = make(map[uuid.UUID]uuid.UUID, len(verticals))
+		for _, v := range verticals {
+			if v.CompanyID != nil {
+				c.companies[v.ID] = *v.CompanyID
+			}
+		}
+		c.expiresAt = time.Now().Add(verticalCompanyCacheTTL)
+	}
+	return c.companies[businessID]
+}
+
+// GetCurrentCompanyID returns the company the request acts within: the one in the token,
+// or for super admins the company selected with the X-Company-ID header. uuid.Nil means
+// platform-wide (super admins without a selection, users not yet assigned a company).
+func GetCurrentCompanyID(r *http.Request) uuid.UUID {
+	claims := GetClaims(r)
+	if claims == nil {
+		return uuid.Nil
+	}
+	if claims.Role == "super_admin" {
+		if id, err := uuid.Parse(strings.TrimSpace(r.Header.Get(CompanyHeader))); err == nil {
+			return id
+		}
+		return uuid.Nil
+	}
+	if id, err := uuid.Parse(claims.CompanyID); err == nil {
+		return id
+	}
+	// Tokens issued before companies existed carry no company; use the user's own
+	if user, ok := userCache.peek(claims.UserID); ok && user.CompanyID != nil {
+		return *user.CompanyID
+	}
+	return uuid.Nil
+}
+
+// TenantScope scopes a query on a table with a company_id column to the request's company
+func TenantScope(r *http.Request) func(*gorm.DB) *gorm.DB {
+	return models.ForCompany(GetCurrentCompanyID(r))
+}
+
+// TenantVerticalScope scopes a query on a table keyed by business vertical to the
+// verticals of the request's company
+func TenantVerticalScope(r *http.Request, column string) func(*gorm.DB) *gorm.DB {
+	return models.ForCompanyVerticals(GetCurrentCompanyID(r), column)
+}
+
+// sameCompany reports whether a user may reach a vertical across the tenant boundary:
+// verticals and users not yet assigned a company are reachable as before.
+func sameCompany(user *models.User, businessID uuid.UUID) bool {
+	if user == nil || user.CompanyID == nil {
+		return true
+	}
+	companyID := VerticalCompanyID(businessID)
+	return companyID == uuid.Nil || companyID == *user.CompanyID
+}
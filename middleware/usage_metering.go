@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/metering"
+)
+
+// UsageMeteringMiddleware counts API calls and active users per business vertical.
+// It must run after JWTMiddleware so the caller can be identified; calls that cannot
+// be attributed to a vertical are metered under uuid.Nil ("unassigned").
+func UsageMeteringMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		if metering.Default() == nil {
+			return
+		}
+
+		claims := GetClaims(r)
+		if claims == nil {
+			return
+		}
+
+		verticalID := getBusinessIDFromRequest(r)
+		if verticalID == uuid.Nil {
+			if userCtx, err := authService.LoadUserContext(r); err == nil && userCtx.BusinessContext != nil {
+				verticalID = userCtx.BusinessContext.BusinessID
+			}
+		}
+
+		metering.Record(verticalID, models.UsageMetricAPICalls, 1)
+		if userID, err := uuid.Parse(claims.UserID); err == nil {
+			metering.TouchUser(verticalID, userID)
+		}
+	})
+}
@@ -6,8 +6,10 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"p9e.in/ugcl/models"
 )
 
@@ -32,6 +34,26 @@ type userContextCache struct {
 	maxEntries int
 	ll         *list.List
 	entries    map[string]*list.Element
+
+	// Counters are updated atomically so stats can be read without taking mu.
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+	expirations   atomic.Uint64
+	evictions     atomic.Uint64
+	invalidations atomic.Uint64
+}
+
+// UserCacheStats is a point-in-time snapshot of the permission cache counters.
+type UserCacheStats struct {
+	Entries       int     `json:"entries"`
+	MaxEntries    int     `json:"max_entries"`
+	TTLSeconds    int64   `json:"ttl_seconds"`
+	Hits          uint64  `json:"hits"`
+	Misses        uint64  `json:"misses"`
+	HitRatio      float64 `json:"hit_ratio"`
+	Expirations   uint64  `json:"expirations"`
+	Evictions     uint64  `json:"evictions"`
+	Invalidations uint64  `json:"invalidations"`
 }
 
 func loadUserCacheMaxEntries() int {
@@ -69,29 +91,50 @@ func (c *userContextCache) removeElement(elem *list.Element) {
 	c.ll.Remove(elem)
 }
 
-func (c *userContextCache) getEntry(userID string) (cachedUser, bool) {
+// getEntry looks up a user. When record is false the lookup is not counted in the
+// hit/miss metrics (used for singleflight double-checks that would double count).
+func (c *userContextCache) getEntry(userID string, record bool) (cachedUser, bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	elem, ok := c.entries[userID]
 	if !ok {
+		if record {
+			c.misses.Add(1)
+		}
 		return cachedUser{}, false
 	}
 
 	entry := elem.Value.(cachedUser)
 	if time.Now().After(entry.expiresAt) {
 		c.removeElement(elem)
+		c.expirations.Add(1)
+		if record {
+			c.misses.Add(1)
+		}
 		return cachedUser{}, false
 	}
 
 	c.ll.MoveToFront(elem)
+	if record {
+		c.hits.Add(1)
+	}
 	return entry, true
 }
 
 // get returns the cached user if present and not expired.
 // LRU ordering updates on cache hits, so this method always takes the write lock.
 func (c *userContextCache) get(userID string) (*models.User, bool) {
-	entry, ok := c.getEntry(userID)
+	entry, ok := c.getEntry(userID, true)
+	if !ok {
+		return nil, false
+	}
+	return entry.user, true
+}
+
+// peek is get without recording a hit or miss.
+func (c *userContextCache) peek(userID string) (*models.User, bool) {
+	entry, ok := c.getEntry(userID, false)
 	if !ok {
 		return nil, false
 	}
@@ -100,7 +143,16 @@ func (c *userContextCache) get(userID string) (*models.User, bool) {
 
 // getAuthData returns cached user plus precomputed global permissions.
 func (c *userContextCache) getAuthData(userID string) (*models.User, []string, bool) {
-	entry, ok := c.getEntry(userID)
+	return c.authData(userID, true)
+}
+
+// peekAuthData is getAuthData without recording a hit or miss.
+func (c *userContextCache) peekAuthData(userID string) (*models.User, []string, bool) {
+	return c.authData(userID, false)
+}
+
+func (c *userContextCache) authData(userID string, record bool) (*models.User, []string, bool) {
+	entry, ok := c.getEntry(userID, record)
 	if !ok {
 		return nil, nil, false
 	}
@@ -145,6 +197,7 @@ func (c *userContextCache) set(userID string, user models.User) {
 
 	if c.ll.Len() > c.maxEntries {
 		c.removeElement(c.ll.Back())
+		c.evictions.Add(1)
 	}
 }
 
@@ -153,12 +206,99 @@ func (c *userContextCache) invalidate(userID string) {
 	c.mu.Lock()
 	if elem, ok := c.entries[userID]; ok {
 		c.removeElement(elem)
+		c.invalidations.Add(1)
 	}
 	c.mu.Unlock()
 }
 
+// invalidateRole removes every cached user holding roleID, either as their global
+// role or as an active business role. It returns the number of evicted users.
+func (c *userContextCache) invalidateRole(roleID uuid.UUID) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for elem := c.ll.Front(); elem != nil; {
+		next := elem.Next()
+		if userHoldsRole(elem.Value.(cachedUser).user, roleID) {
+			c.removeElement(elem)
+			removed++
+		}
+		elem = next
+	}
+	c.invalidations.Add(uint64(removed))
+	return removed
+}
+
+// clear removes every cached user. It returns the number of evicted users.
+func (c *userContextCache) clear() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := c.ll.Len()
+	c.ll.Init()
+	c.entries = make(map[string]*list.Element, c.maxEntries)
+	c.invalidations.Add(uint64(removed))
+	return removed
+}
+
+func (c *userContextCache) stats() UserCacheStats {
+	c.mu.Lock()
+	entries := c.ll.Len()
+	c.mu.Unlock()
+
+	stats := UserCacheStats{
+		Entries:       entries,
+		MaxEntries:    c.maxEntries,
+		TTLSeconds:    int64(userCacheTTL / time.Second),
+		Hits:          c.hits.Load(),
+		Misses:        c.misses.Load(),
+		Expirations:   c.expirations.Load(),
+		Evictions:     c.evictions.Load(),
+		Invalidations: c.invalidations.Load(),
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
+
+func userHoldsRole(user *models.User, roleID uuid.UUID) bool {
+	if user == nil {
+		return false
+	}
+	if user.RoleID != nil && *user.RoleID == roleID {
+		return true
+	}
+	for _, ubr := range user.UserBusinessRoles {
+		if ubr.BusinessRoleID == roleID {
+			return true
+		}
+	}
+	return false
+}
+
 // InvalidateUserCache evicts a user so the next request re-fetches from DB.
 // Call this from any handler that updates a user's role or permissions.
 func InvalidateUserCache(userID string) {
 	userCache.invalidate(userID)
 }
+
+// InvalidateUserCacheForRole evicts every cached user that holds the given global or
+// business role. Call this after a role's permission set changes. It returns the number
+// of cached users invalidated, not the role's holders: users outside the cache load the
+// new permissions on their next request anyway.
+func InvalidateUserCacheForRole(roleID uuid.UUID) int {
+	return userCache.invalidateRole(roleID)
+}
+
+// InvalidateAllUserCaches evicts every cached user. Use after bulk permission changes
+// (e.g. permission renames or seeding) where the affected users are not known.
+func InvalidateAllUserCaches() int {
+	return userCache.clear()
+}
+
+// GetUserCacheStats returns hit/miss and size metrics for the permission cache.
+func GetUserCacheStats() UserCacheStats {
+	return userCache.stats()
+}
@@ -0,0 +1,274 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Alarm rule comparators: how a reading is compared with the rule's threshold
+const (
+	AlarmAbove     = "gt"
+	AlarmAtOrAbove = "gte"
+	AlarmBelow     = "lt"
+	AlarmAtOrBelow = "lte"
+	AlarmEqual     = "eq"
+	AlarmNotEqual  = "ne"
+)
+
+// maxAlarmDurationS is the longest a breach may be required to last before it raises
+const maxAlarmDurationS = 7 * 24 * 3600
+
+// Alarm severities, lowest first
+const (
+	AlarmSeverityLow      = "low"
+	AlarmSeverityMedium   = "medium"
+	AlarmSeverityHigh     = "high"
+	AlarmSeverityCritical = "critical"
+)
+
+// Alarm statuses. An alarm is raised, acknowledged by whoever takes it on and resolved
+// once dealt with; it may be resolved without being acknowledged.
+const (
+	AlarmRaised       = "raised"
+	AlarmAcknowledged = "acknowledged"
+	AlarmResolved     = "resolved"
+)
+
+// Alarm history actions
+const (
+	AlarmEventRaised       = "raised"
+	AlarmEventCleared      = "cleared" // the reading is back to normal
+	AlarmEventRecurred     = "recurred"
+	AlarmEventAcknowledged = "acknowledged"
+	AlarmEventAssigned     = "assigned"
+	AlarmEventResolved     = "resolved"
+)
+
+// AlarmRule raises an alarm on a device whose readings of a metric have breached a
+// threshold for at least DurationSeconds, zero raising on the first breaching reading.
+// A rule covers every device of its type in the vertical unless narrowed to a site or
+// a single device.
+type AlarmRule struct {
+	ID                 uuid.UUID   `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID   `gorm:"type:uuid;not null;index:idx_alarm_rules_match,priority:1" json:"business_vertical_id"`
+	Name               string      `gorm:"size:150;not null" json:"name"`
+	DeviceType         string      `gorm:"size:30;not null;index:idx_alarm_rules_match,priority:2" json:"device_type"`
+	Metric             string      `gorm:"size:30;not null;index:idx_alarm_rules_match,priority:3" json:"metric"`
+	Comparator         string      `gorm:"size:5;not null" json:"comparator"`
+	Threshold          float64     `gorm:"not null" json:"threshold"`
+	DurationSeconds    int         `gorm:"not null;default:0" json:"duration_seconds"`
+	Severity           string      `gorm:"size:20;not null;default:'medium'" json:"severity"`
+	SiteID             *uuid.UUID  `gorm:"type:uuid;index" json:"site_id,omitempty"`
+	DeviceID           *uuid.UUID  `gorm:"type:uuid;index" json:"device_id,omitempty"`
+	AutoResolve        bool        `gorm:"not null;default:false" json:"auto_resolve"` // resolve once readings are back to normal
+	NotifyUserIDs      StringArray `gorm:"type:jsonb;default:'[]'" json:"notify_user_ids"`
+	DefaultAssigneeID  *uuid.UUID  `gorm:"type:uuid" json:"default_assignee_id,omitempty"`
+	ConversationID     *uuid.UUID  `gorm:"type:uuid" json:"conversation_id,omitempty"` // chat alarms are posted to
+	IsActive           bool        `gorm:"default:true" json:"is_active"`
+	CreatedBy          string      `gorm:"size:255;not null" json:"created_by"` // posts the rule's alarms to chat
+	UpdatedBy          string      `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt          time.Time   `json:"created_at"`
+	UpdatedAt          time.Time   `json:"updated_at"`
+
+	Site *Site `gorm:"foreignKey:SiteID" json:"site,omitempty"`
+}
+
+func (AlarmRule) TableName() string {
+	return "alarm_rules"
+}
+
+// ValidAlarmSeverity reports whether s is a known alarm severity
+func ValidAlarmSeverity(s string) bool {
+	switch s {
+	case AlarmSeverityLow, AlarmSeverityMedium, AlarmSeverityHigh, AlarmSeverityCritical:
+		return true
+	}
+	return false
+}
+
+// Validate checks the rule watches a metric its device type reports with a known
+// comparator, severity and a duration of at most a week
+func (r AlarmRule) Validate() error {
+	switch {
+	case strings.TrimSpace(r.Name) == "":
+		return fmt.Errorf("name is required")
+	case !ValidSensorDeviceType(SensorDeviceType(r.DeviceType)):
+		return fmt.Errorf("device_type %q is not supported", r.DeviceType)
+	case math.IsNaN(r.Threshold) || math.IsInf(r.Threshold, 0):
+		return fmt.Errorf("threshold must be a number")
+	case r.DurationSeconds < 0 || r.DurationSeconds > maxAlarmDurationS:
+		return fmt.Errorf("duration_seconds must be within 0..%d", maxAlarmDurationS)
+	case !ValidAlarmSeverity(r.Severity):
+		return fmt.Errorf("severity must be low, medium, high or critical")
+	}
+	if _, ok := TelemetryMetricUnit(SensorDeviceType(r.DeviceType), r.Metric); !ok {
+		return fmt.Errorf("metric %q is not reported by %s devices", r.Metric, r.DeviceType)
+	}
+	switch r.Comparator {
+	case AlarmAbove, AlarmAtOrAbove, AlarmBelow, AlarmAtOrBelow, AlarmEqual, AlarmNotEqual:
+	default:
+		return fmt.Errorf("comparator must be gt, gte, lt, lte, eq or ne")
+	}
+	for _, id := range r.NotifyUserIDs {
+		if _, err := uuid.Parse(id); err != nil {
+			return fmt.Errorf("notify_user_ids: %q is not a user id", id)
+		}
+	}
+	return nil
+}
+
+// Breaches reports whether a reading's value breaches the rule's threshold
+func (r AlarmRule) Breaches(value float64) bool {
+	switch r.Comparator {
+	case AlarmAbove:
+		return value > r.Threshold
+	case AlarmAtOrAbove:
+		return value >= r.Threshold
+	case AlarmBelow:
+		return value < r.Threshold
+	case AlarmAtOrBelow:
+		return value <= r.Threshold
+	case AlarmEqual:
+		return value == r.Threshold
+	case AlarmNotEqual:
+		return value != r.Threshold
+	}
+	return false
+}
+
+// Condition describes the rule's breach, e.g. "inverter_temp_c > 75 for 300s"
+func (r AlarmRule) Condition() string {
+	symbols := map[string]string{
+		AlarmAbove: ">", AlarmAtOrAbove: ">=", AlarmBelow: "<", AlarmAtOrBelow: "<=", AlarmEqual: "=", AlarmNotEqual: "!=",
+	}
+	condition := fmt.Sprintf("%s %s %v", r.Metric, symbols[r.Comparator], r.Threshold)
+	if r.DurationSeconds > 0 {
+		condition += fmt.Sprintf(" for %ds", r.DurationSeconds)
+	}
+	return condition
+}
+
+// AlarmRuleState tracks how long a device's readings have breached a rule, so a rule
+// with a duration raises only on a sustained breach
+type AlarmRuleState struct {
+	RuleID          uuid.UUID  `gorm:"type:uuid;primaryKey" json:"rule_id"`
+	DeviceID        uuid.UUID  `gorm:"type:uuid;primaryKey" json:"device_id"`
+	BreachStartedAt *time.Time `json:"breach_started_at,omitempty"` // nil while readings are normal
+	LastValue       float64    `gorm:"not null" json:"last_value"`
+	LastRecordedAt  *time.Time `json:"last_recorded_at,omitempty"`
+}
+
+func (AlarmRuleState) TableName() string {
+	return "alarm_rule_states"
+}
+
+// Step advances the state by a reading and reports whether the reading breaches the rule
+// and whether the breach has lasted long enough to raise an alarm. Readings older than
+// the last one seen are stale and leave the state as it was; ok is false for them.
+func (s *AlarmRuleState) Step(rule AlarmRule, value float64, at time.Time) (breaching, due, ok bool) {
+	if s.LastRecordedAt != nil && at.Before(*s.LastRecordedAt) {
+		return false, false, false
+	}
+	s.LastValue = value
+	s.LastRecordedAt = &at
+	if !rule.Breaches(value) {
+		s.BreachStartedAt = nil
+		return false, false, true
+	}
+	if s.BreachStartedAt == nil {
+		s.BreachStartedAt = &at
+	}
+	return true, at.Sub(*s.BreachStartedAt) >= time.Duration(rule.DurationSeconds)*time.Second, true
+}
+
+// Alarm is a rule's breach on one device. A device has at most one unresolved alarm per
+// rule; a breach recurring before it is resolved updates that alarm instead of raising
+// another.
+type Alarm struct {
+	ID                 uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID  `gorm:"type:uuid;not null;index:idx_alarms_vertical_status,priority:1" json:"business_vertical_id"`
+	RuleID             uuid.UUID  `gorm:"type:uuid;not null;index" json:"rule_id"`
+	DeviceID           uuid.UUID  `gorm:"type:uuid;not null;index" json:"device_id"`
+	SiteID             *uuid.UUID `gorm:"type:uuid;index" json:"site_id,omitempty"`
+	Metric             string     `gorm:"size:30;not null" json:"metric"`
+	Condition          string     `gorm:"size:100;not null" json:"condition"` // the rule's condition when raised
+	Severity           string     `gorm:"size:20;not null" json:"severity"`
+	Status             string     `gorm:"size:20;not null;default:'raised';index:idx_alarms_vertical_status,priority:2" json:"status"`
+	TriggerValue       float64    `gorm:"not null" json:"trigger_value"`
+	LastValue          float64    `gorm:"not null" json:"last_value"`
+	BreachStartedAt    time.Time  `gorm:"not null" json:"breach_started_at"`
+	RaisedAt           time.Time  `gorm:"not null;index" json:"raised_at"`
+	ClearedAt          *time.Time `json:"cleared_at,omitempty"` // readings back to normal, nil while still breaching
+	AcknowledgedAt     *time.Time `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy     string     `gorm:"size:255" json:"acknowledged_by,omitempty"`
+	AssigneeID         *uuid.UUID `gorm:"type:uuid;index" json:"assignee_id,omitempty"`
+	AssignedAt         *time.Time `json:"assigned_at,omitempty"`
+	AssignedBy         string     `gorm:"size:255" json:"assigned_by,omitempty"`
+	ResolvedAt         *time.Time `json:"resolved_at,omitempty"`
+	ResolvedBy         string     `gorm:"size:255" json:"resolved_by,omitempty"` // empty when auto-resolved
+	ResolutionNote     string     `gorm:"type:text" json:"resolution_note,omitempty"`
+	ConversationID     *uuid.UUID `gorm:"type:uuid" json:"conversation_id,omitempty"`
+	ChatMessageID      *uuid.UUID `gorm:"type:uuid" json:"chat_message_id,omitempty"`
+
+	Rule   *AlarmRule    `gorm:"foreignKey:RuleID" json:"rule,omitempty"`
+	Device *SensorDevice `gorm:"foreignKey:DeviceID" json:"device,omitempty"`
+	Site   *Site         `gorm:"foreignKey:SiteID" json:"site,omitempty"`
+	Events []AlarmEvent  `gorm:"foreignKey:AlarmID" json:"events,omitempty"`
+}
+
+func (Alarm) TableName() string {
+	return "alarms"
+}
+
+// Acknowledge records that the user has taken the alarm on
+func (a *Alarm) Acknowledge(userID string, at time.Time) error {
+	if a.Status != AlarmRaised {
+		return fmt.Errorf("only a raised alarm can be acknowledged; this one is %s", a.Status)
+	}
+	a.Status = AlarmAcknowledged
+	a.AcknowledgedAt = &at
+	a.AcknowledgedBy = userID
+	return nil
+}
+
+// Assign hands the alarm to a user, acknowledged or not
+func (a *Alarm) Assign(assigneeID uuid.UUID, userID string, at time.Time) error {
+	if a.Status == AlarmResolved {
+		return fmt.Errorf("a resolved alarm cannot be assigned")
+	}
+	a.AssigneeID = &assigneeID
+	a.AssignedAt = &at
+	a.AssignedBy = userID
+	return nil
+}
+
+// Resolve closes the alarm. userID is empty when the alarm resolves itself.
+func (a *Alarm) Resolve(userID, note string, at time.Time) error {
+	if a.Status == AlarmResolved {
+		return fmt.Errorf("the alarm is already resolved")
+	}
+	a.Status = AlarmResolved
+	a.ResolvedAt = &at
+	a.ResolvedBy = userID
+	a.ResolutionNote = note
+	return nil
+}
+
+// AlarmEvent is one entry in an alarm's history
+type AlarmEvent struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	AlarmID   uuid.UUID `gorm:"type:uuid;not null;index" json:"alarm_id"`
+	Action    string    `gorm:"size:20;not null" json:"action"`
+	ActorID   string    `gorm:"size:255" json:"actor_id,omitempty"` // empty for the system
+	Value     *float64  `json:"value,omitempty"`
+	Note      string    `gorm:"type:text" json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (AlarmEvent) TableName() string {
+	return "alarm_events"
+}
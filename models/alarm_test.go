@@ -0,0 +1,120 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestAlarmRuleValidate(t *testing.T) {
+	rule := AlarmRule{Name: "Inverter overheating", DeviceType: string(SensorDeviceSolarInverter), Metric: "inverter_temp_c",
+		Comparator: AlarmAbove, Threshold: 75, DurationSeconds: 300, Severity: AlarmSeverityHigh}
+	if err := rule.Validate(); err != nil {
+		t.Fatalf("valid rule rejected: %v", err)
+	}
+	if err := (AlarmRule{Name: "Low level", DeviceType: string(SensorDeviceWaterLevel), Metric: "water_level",
+		Comparator: AlarmBelow, Threshold: -20, Severity: AlarmSeverityCritical}).Validate(); err != nil {
+		t.Fatalf("valid sensor rule rejected: %v", err)
+	}
+
+	wrongMetric := rule
+	wrongMetric.Metric = "rainfall"
+	badComparator := rule
+	badComparator.Comparator = ">"
+	badSeverity := rule
+	badSeverity.Severity = "urgent"
+	negative := rule
+	negative.DurationSeconds = -1
+	badUser := rule
+	badUser.NotifyUserIDs = StringArray{"someone"}
+	for name, bad := range map[string]AlarmRule{
+		"metric of another type": wrongMetric, "unknown comparator": badComparator, "unknown severity": badSeverity,
+		"negative duration": negative, "notify user not an id": badUser,
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+
+	if got := rule.Condition(); got != "inverter_temp_c > 75 for 300s" {
+		t.Errorf("condition = %q", got)
+	}
+}
+
+func TestAlarmRuleBreaches(t *testing.T) {
+	tests := []struct {
+		comparator string
+		value      float64
+		want       bool
+	}{
+		{AlarmAbove, 10, false}, {AlarmAbove, 10.1, true},
+		{AlarmAtOrAbove, 10, true}, {AlarmBelow, 10, false},
+		{AlarmAtOrBelow, 10, true}, {AlarmBelow, 9, true},
+		{AlarmEqual, 10, true}, {AlarmNotEqual, 10, false},
+	}
+	for _, tt := range tests {
+		if got := (AlarmRule{Comparator: tt.comparator, Threshold: 10}).Breaches(tt.value); got != tt.want {
+			t.Errorf("%v %s 10 = %v, want %v", tt.value, tt.comparator, got, tt.want)
+		}
+	}
+}
+
+func TestAlarmRuleStateStep(t *testing.T) {
+	rule := AlarmRule{Comparator: AlarmAbove, Threshold: 75, DurationSeconds: 300}
+	start := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	var state AlarmRuleState
+
+	if breaching, due, _ := state.Step(rule, 80, start); !breaching || due {
+		t.Fatalf("first breaching reading: breaching %v due %v, want breaching, not due", breaching, due)
+	}
+	if _, due, _ := state.Step(rule, 82, start.Add(4*time.Minute)); due {
+		t.Error("breach of 4 minutes raised a 5 minute rule")
+	}
+	if _, _, ok := state.Step(rule, 70, start.Add(time.Minute)); ok {
+		t.Error("stale reading accepted")
+	}
+	if _, due, _ := state.Step(rule, 81, start.Add(5*time.Minute)); !due {
+		t.Error("breach of 5 minutes not due")
+	}
+	if breaching, _, _ := state.Step(rule, 60, start.Add(6*time.Minute)); breaching || state.BreachStartedAt != nil {
+		t.Error("normal reading did not end the breach")
+	}
+	if _, due, _ := state.Step(rule, 90, start.Add(7*time.Minute)); due {
+		t.Error("new breach counted from the earlier one")
+	}
+
+	instant := AlarmRule{Comparator: AlarmBelow, Threshold: 1}
+	var fresh AlarmRuleState
+	if _, due, _ := fresh.Step(instant, 0, start); !due {
+		t.Error("rule without a duration did not raise on the first breach")
+	}
+}
+
+func TestAlarmLifecycle(t *testing.T) {
+	now := time.Now()
+	alarm := Alarm{Status: AlarmRaised}
+	if err := alarm.Acknowledge("u1", now); err != nil || alarm.Status != AlarmAcknowledged {
+		t.Fatalf("acknowledge: %v, status %s", err, alarm.Status)
+	}
+	if err := alarm.Acknowledge("u2", now); err == nil {
+		t.Error("acknowledged twice")
+	}
+	if err := alarm.Assign(uuid.New(), "u1", now); err != nil || alarm.AssigneeID == nil {
+		t.Fatalf("assign: %v", err)
+	}
+	if err := alarm.Resolve("u1", "fan replaced", now); err != nil || alarm.Status != AlarmResolved {
+		t.Fatalf("resolve: %v, status %s", err, alarm.Status)
+	}
+	if err := alarm.Resolve("u1", "", now); err == nil {
+		t.Error("resolved twice")
+	}
+	if err := alarm.Assign(uuid.New(), "u1", now); err == nil {
+		t.Error("resolved alarm assigned")
+	}
+
+	unacknowledged := Alarm{Status: AlarmRaised}
+	if err := unacknowledged.Resolve("", "readings back to normal", now); err != nil {
+		t.Errorf("raised alarm could not be resolved: %v", err)
+	}
+}
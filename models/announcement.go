@@ -0,0 +1,132 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Announcement states, derived from the publication window
+const (
+	AnnouncementScheduled = "scheduled" // starts in the future
+	AnnouncementLive      = "live"
+	AnnouncementEnded     = "ended"
+	AnnouncementWithdrawn = "withdrawn"
+)
+
+// Announcement is a notice an admin posts to a business vertical, optionally narrowed to
+// sites and business roles, shown between StartsAt and EndsAt. The audience is resolved
+// when it goes live and each recipient's receipt records when they saw it and, for
+// announcements that require it, accepted it.
+type Announcement struct {
+	ID                 uuid.UUID            `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID            `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	Title              string               `gorm:"size:200;not null" json:"title"`
+	Body               string               `gorm:"type:text;not null" json:"body"`
+	Priority           NotificationPriority `gorm:"size:20;not null;default:'normal'" json:"priority"`
+	RequiresAcceptance bool                 `gorm:"not null;default:false" json:"requires_acceptance"`
+
+	// Targeting: empty lists mean the whole vertical
+	SiteIDs pq.StringArray `gorm:"type:text[]" json:"site_ids"`
+	RoleIDs pq.StringArray `gorm:"type:text[]" json:"role_ids"` // business roles
+
+	StartsAt    time.Time  `gorm:"not null;index" json:"starts_at"`
+	EndsAt      *time.Time `json:"ends_at,omitempty"`
+	PublishedAt *time.Time `gorm:"index" json:"published_at,omitempty"` // when the audience was resolved and notified
+	WithdrawnAt *time.Time `json:"withdrawn_at,omitempty"`
+	WithdrawnBy *uuid.UUID `gorm:"type:uuid" json:"withdrawn_by,omitempty"`
+
+	CreatedBy uuid.UUID  `gorm:"type:uuid;not null" json:"created_by"`
+	UpdatedBy *uuid.UUID `gorm:"type:uuid" json:"updated_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+func (Announcement) TableName() string {
+	return "announcements"
+}
+
+// Validate checks the content, priority, window and targets
+func (a *Announcement) Validate() error {
+	a.Title = strings.TrimSpace(a.Title)
+	a.Body = strings.TrimSpace(a.Body)
+	if a.Title == "" || a.Body == "" {
+		return errors.New("title and body are required")
+	}
+	switch a.Priority {
+	case "":
+		a.Priority = NotificationPriorityNormal
+	case NotificationPriorityLow, NotificationPriorityNormal, NotificationPriorityHigh, NotificationPriorityCritical:
+	default:
+		return errors.New("priority must be low, normal, high or critical")
+	}
+	if a.StartsAt.IsZero() {
+		return errors.New("starts_at is required")
+	}
+	if a.EndsAt != nil && !a.EndsAt.After(a.StartsAt) {
+		return errors.New("ends_at must be after starts_at")
+	}
+	for _, ids := range []pq.StringArray{a.SiteIDs, a.RoleIDs} {
+		for _, id := range ids {
+			if _, err := uuid.Parse(id); err != nil {
+				return errors.New("site_ids and role_ids must be IDs")
+			}
+		}
+	}
+	return nil
+}
+
+// State returns where the announcement is in its window at now
+func (a *Announcement) State(now time.Time) string {
+	switch {
+	case a.WithdrawnAt != nil:
+		return AnnouncementWithdrawn
+	case now.Before(a.StartsAt):
+		return AnnouncementScheduled
+	case a.EndsAt != nil && !now.Before(*a.EndsAt):
+		return AnnouncementEnded
+	}
+	return AnnouncementLive
+}
+
+// AnnouncementReceipt tracks one recipient of an announcement
+type AnnouncementReceipt struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	AnnouncementID uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_announcement_receipt,priority:1" json:"announcement_id"`
+	UserID         uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_announcement_receipt,priority:2;index" json:"user_id"`
+	Name           string     `gorm:"size:100" json:"name"`
+	NotificationID *uuid.UUID `gorm:"type:uuid" json:"notification_id,omitempty"`
+	SeenAt         *time.Time `json:"seen_at,omitempty"`
+	AcceptedAt     *time.Time `json:"accepted_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func (AnnouncementReceipt) TableName() string {
+	return "announcement_receipts"
+}
+
+// MarkSeen records the first time the recipient opened the announcement and reports
+// whether it changed
+func (r *AnnouncementReceipt) MarkSeen(at time.Time) bool {
+	if r.SeenAt != nil {
+		return false
+	}
+	r.SeenAt = &at
+	return true
+}
+
+// Accept records the recipient's acceptance, which also counts as seeing it
+func (r *AnnouncementReceipt) Accept(a *Announcement, at time.Time) error {
+	if !a.RequiresAcceptance {
+		return errors.New("announcement does not ask to be accepted")
+	}
+	if r.AcceptedAt != nil {
+		return errors.New("announcement already accepted")
+	}
+	r.MarkSeen(at)
+	r.AcceptedAt = &at
+	return nil
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+func TestAnnouncementValidateAndState(t *testing.T) {
+	start := time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC)
+	end := start.Add(72 * time.Hour)
+	a := Announcement{Title: " Diwali holiday ", Body: "Sites close on 1 Nov.", StartsAt: start, EndsAt: &end,
+		SiteIDs: pq.StringArray{uuid.NewString()}}
+	if err := a.Validate(); err != nil {
+		t.Fatalf("valid announcement rejected: %v", err)
+	}
+	if a.Title != "Diwali holiday" || a.Priority != NotificationPriorityNormal {
+		t.Errorf("not normalised: %q %q", a.Title, a.Priority)
+	}
+
+	backwards := a
+	backwards.EndsAt = &start
+	badRole := a
+	badRole.RoleIDs = pq.StringArray{"supervisors"}
+	badPriority := a
+	badPriority.Priority = "urgent"
+	for name, bad := range map[string]Announcement{"ends before it starts": backwards, "role not an id": badRole, "unknown priority": badPriority} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+
+	for at, want := range map[time.Time]string{
+		start.Add(-time.Hour): AnnouncementScheduled, start: AnnouncementLive, end: AnnouncementEnded,
+	} {
+		if got := a.State(at); got != want {
+			t.Errorf("state at %v = %s, want %s", at, got, want)
+		}
+	}
+	withdrawn := start.Add(time.Hour)
+	a.WithdrawnAt = &withdrawn
+	if got := a.State(start.Add(2 * time.Hour)); got != AnnouncementWithdrawn {
+		t.Errorf("state after withdrawal = %s", got)
+	}
+}
+
+func TestAnnouncementReceipt(t *testing.T) {
+	now := time.Now()
+	var receipt AnnouncementReceipt
+	if !receipt.MarkSeen(now) || receipt.MarkSeen(now.Add(time.Minute)) || !receipt.SeenAt.Equal(now) {
+		t.Error("seen time not kept from the first view")
+	}
+
+	if err := receipt.Accept(&Announcement{}, now); err == nil {
+		t.Error("accepted an announcement that does not ask for it")
+	}
+	policy := &Announcement{RequiresAcceptance: true}
+	var unseen AnnouncementReceipt
+	if err := unseen.Accept(policy, now); err != nil || unseen.SeenAt == nil || unseen.AcceptedAt == nil {
+		t.Fatalf("accept: %v, %+v", err, unseen)
+	}
+	if err := unseen.Accept(policy, now); err == nil {
+		t.Error("accepted twice")
+	}
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -216,3 +217,25 @@ func (f *AppForm) IsAccessibleInVertical(verticalCode string) bool {
 	}
 	return false
 }
+
+// SummaryFields returns the fields named by the form schema's "summary_fields": the key
+// fields that summarize a record wherever it is shown without being opened, such as in
+// workflow notifications
+func (f *AppForm) SummaryFields() []string {
+	if len(f.FormSchema) == 0 {
+		return nil
+	}
+	var schema struct {
+		SummaryFields []string `json:"summary_fields"`
+	}
+	if err := json.Unmarshal(f.FormSchema, &schema); err != nil {
+		return nil
+	}
+	var fields []string
+	for _, name := range schema.SummaryFields {
+		if name = strings.TrimSpace(name); name != "" {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestAppFormSummaryFields(t *testing.T) {
+	cases := []struct {
+		schema string
+		want   []string
+	}{
+		{``, nil},
+		{`{}`, nil},
+		{`{"fields": [{"name": "amount"}]}`, nil},
+		{`{"summary_fields": ["vendor", " amount ", ""]}`, []string{"vendor", "amount"}},
+		{`{"summary_fields": "vendor"}`, nil},
+	}
+	for _, c := range cases {
+		form := AppForm{FormSchema: json.RawMessage(c.schema)}
+		if got := form.SummaryFields(); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("SummaryFields(%s) = %v, want %v", c.schema, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AppTelemetryKind classifies a mobile telemetry event.
+type AppTelemetryKind string
+
+const (
+	AppTelemetryKindFeature    AppTelemetryKind = "feature"    // a module or feature was used
+	AppTelemetryKindBreadcrumb AppTelemetryKind = "breadcrumb" // navigation/action trail kept for crash context
+	AppTelemetryKindCrash      AppTelemetryKind = "crash"      // the app crashed or hit a fatal error
+)
+
+// AppTelemetryEvent is one opt-in, anonymized usage event reported by the mobile app.
+// No user ID, device ID or IP address is stored: AnonymousID is a salted hash of the
+// user that only allows counting distinct users, and SessionID is generated by the client.
+type AppTelemetryEvent struct {
+	ID                 uuid.UUID        `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	AnonymousID        string           `gorm:"size:64;not null;index" json:"anonymous_id"`
+	SessionID          string           `gorm:"size:64;index" json:"session_id,omitempty"`
+	BusinessVerticalID *uuid.UUID       `gorm:"type:uuid;index" json:"business_vertical_id,omitempty"`
+	Kind               AppTelemetryKind `gorm:"size:20;not null;index:idx_app_telemetry_kind_time,priority:1" json:"kind"`
+	Module             string           `gorm:"size:50;not null;index:idx_app_telemetry_module_time,priority:1" json:"module"` // chat, forms, tasks, ...
+	Name               string           `gorm:"size:100;not null" json:"name"`
+	Message            string           `gorm:"size:500" json:"message,omitempty"`
+	DurationMs         int64            `json:"duration_ms,omitempty"`
+	Platform           string           `gorm:"size:20" json:"platform,omitempty"`
+	AppVersion         string           `gorm:"size:30;index" json:"app_version,omitempty"`
+	OSVersion          string           `gorm:"size:30" json:"os_version,omitempty"`
+	Properties         JSONMap          `gorm:"type:jsonb" json:"properties,omitempty"`
+	OccurredAt         time.Time        `gorm:"not null;index:idx_app_telemetry_kind_time,priority:2;index:idx_app_telemetry_module_time,priority:2" json:"occurred_at"`
+	ReceivedAt         time.Time        `gorm:"not null" json:"received_at"`
+}
+
+func (AppTelemetryEvent) TableName() string {
+	return "app_telemetry_events"
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Approval delegation statuses
+const (
+	ApprovalDelegationActive  = "active"
+	ApprovalDelegationRevoked = "revoked"
+)
+
+// ApprovalDelegationMaxDuration is the longest a single delegation may run
+const ApprovalDelegationMaxDuration = 90 * 24 * time.Hour
+
+// ApprovalDelegation lets the delegate take workflow decisions the delegator is entitled
+// to between StartsAt and EndsAt, typically while the delegator is on leave. Decisions
+// taken under it are recorded as on behalf of the delegator.
+type ApprovalDelegation struct {
+	ID                 uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	DelegatorID        uuid.UUID      `gorm:"type:uuid;not null;index" json:"delegator_id"`
+	DelegateID         uuid.UUID      `gorm:"type:uuid;not null;index:idx_approval_delegations_delegate,priority:1" json:"delegate_id"`
+	BusinessVerticalID *uuid.UUID     `gorm:"type:uuid;index" json:"business_vertical_id,omitempty"` // nil: every vertical
+	Permissions        pq.StringArray `gorm:"type:text[]" json:"permissions,omitempty"`              // empty: every workflow permission the delegator holds
+	StartsAt           time.Time      `gorm:"not null" json:"starts_at"`
+	EndsAt             time.Time      `gorm:"not null;index" json:"ends_at"`
+	Reason             string         `gorm:"type:text" json:"reason,omitempty"`
+	Status             string         `gorm:"size:20;not null;default:'active';index:idx_approval_delegations_delegate,priority:2" json:"status"`
+	CreatedBy          uuid.UUID      `gorm:"type:uuid;not null" json:"created_by"`
+	RevokedAt          *time.Time     `json:"revoked_at,omitempty"`
+	RevokedBy          *uuid.UUID     `gorm:"type:uuid" json:"revoked_by,omitempty"`
+	UsageCount         int            `gorm:"default:0" json:"usage_count"`
+	LastUsedAt         *time.Time     `json:"last_used_at,omitempty"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+
+	Delegator        *User             `gorm:"foreignKey:DelegatorID" json:"delegator,omitempty"`
+	Delegate         *User             `gorm:"foreignKey:DelegateID" json:"delegate,omitempty"`
+	BusinessVertical *BusinessVertical `gorm:"foreignKey:BusinessVerticalID" json:"business_vertical,omitempty"`
+}
+
+func (ApprovalDelegation) TableName() string {
+	return "approval_delegations"
+}
+
+// Validate checks the delegation's parties and period
+func (d *ApprovalDelegation) Validate(now time.Time) error {
+	if d.DelegatorID == uuid.Nil || d.DelegateID == uuid.Nil {
+		return errors.New("delegator and delegate are required")
+	}
+	if d.DelegatorID == d.DelegateID {
+		return errors.New("cannot delegate approvals to yourself")
+	}
+	if !d.EndsAt.After(d.StartsAt) {
+		return errors.New("ends_at must be after starts_at")
+	}
+	if !d.EndsAt.After(now) {
+		return errors.New("ends_at must be in the future")
+	}
+	if d.EndsAt.Sub(d.StartsAt) > ApprovalDelegationMaxDuration {
+		return errors.New("a delegation may not run longer than 90 days")
+	}
+	return nil
+}
+
+// Covers reports whether the delegation lets its delegate use permission in the vertical
+// at now. Whether the delegator still holds the permission is checked separately.
+func (d *ApprovalDelegation) Covers(permission string, businessID uuid.UUID, now time.Time) bool {
+	if d.Status != ApprovalDelegationActive || now.Before(d.StartsAt) || !now.Before(d.EndsAt) {
+		return false
+	}
+	if d.BusinessVerticalID != nil && *d.BusinessVerticalID != businessID {
+		return false
+	}
+	if len(d.Permissions) == 0 {
+		return true
+	}
+	for _, granted := range d.Permissions {
+		if matchesPermission(granted, permission) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestApprovalDelegationValidate(t *testing.T) {
+	now := time.Now()
+	delegator, delegate := uuid.New(), uuid.New()
+
+	valid := ApprovalDelegation{DelegatorID: delegator, DelegateID: delegate, StartsAt: now, EndsAt: now.Add(7 * 24 * time.Hour)}
+	if err := valid.Validate(now); err != nil {
+		t.Fatalf("expected valid delegation, got %v", err)
+	}
+
+	cases := map[string]ApprovalDelegation{
+		"self":     {DelegatorID: delegator, DelegateID: delegator, StartsAt: now, EndsAt: now.Add(time.Hour)},
+		"reversed": {DelegatorID: delegator, DelegateID: delegate, StartsAt: now, EndsAt: now.Add(-time.Hour)},
+		"past":     {DelegatorID: delegator, DelegateID: delegate, StartsAt: now.Add(-2 * time.Hour), EndsAt: now.Add(-time.Hour)},
+		"too long": {DelegatorID: delegator, DelegateID: delegate, StartsAt: now, EndsAt: now.Add(ApprovalDelegationMaxDuration + time.Hour)},
+	}
+	for name, d := range cases {
+		if err := d.Validate(now); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestApprovalDelegationCovers(t *testing.T) {
+	now := time.Now()
+	vertical, other := uuid.New(), uuid.New()
+	d := ApprovalDelegation{
+		BusinessVerticalID: &vertical,
+		Permissions:        []string{"purchase:*"},
+		StartsAt:           now.Add(-time.Hour),
+		EndsAt:             now.Add(time.Hour),
+		Status:             ApprovalDelegationActive,
+	}
+
+	if !d.Covers("purchase:approve", vertical, now) {
+		t.Error("expected delegation to cover a matching permission in its vertical")
+	}
+	if d.Covers("purchase:approve", other, now) {
+		t.Error("expected delegation not to cover another vertical")
+	}
+	if d.Covers("payroll:approve", vertical, now) {
+		t.Error("expected delegation not to cover an unlisted permission")
+	}
+	if d.Covers("purchase:approve", vertical, now.Add(2*time.Hour)) {
+		t.Error("expected delegation not to cover after it ends")
+	}
+
+	d.Status = ApprovalDelegationRevoked
+	if d.Covers("purchase:approve", vertical, now) {
+		t.Error("expected revoked delegation not to cover anything")
+	}
+
+	d.Status = ApprovalDelegationActive
+	d.BusinessVerticalID = nil
+	d.Permissions = nil
+	if !d.Covers("payroll:approve", other, now) {
+		t.Error("expected unscoped delegation to cover any permission in any vertical")
+	}
+}
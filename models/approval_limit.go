@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Documents financial approval limits apply to. Purchase limits cover both requisitions
+// and purchase orders.
+const (
+	ApprovalLimitPurchase = "purchase"
+	ApprovalLimitExpense  = "expense"
+	ApprovalLimitInvoice  = "invoice"
+)
+
+// ValidApprovalLimitDocument reports whether approval limits can be set for the document type
+func ValidApprovalLimitDocument(documentType string) bool {
+	switch documentType {
+	case ApprovalLimitPurchase, ApprovalLimitExpense, ApprovalLimitInvoice:
+		return true
+	}
+	return false
+}
+
+// ApprovalLimit is the largest amount holders of a business role may give final approval
+// to on a type of document. Once a business sets any limit for a document type, only
+// roles with a limit for it may approve, and amounts above every limit need a super admin.
+type ApprovalLimit struct {
+	ID                 uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	BusinessRoleID     uuid.UUID `gorm:"type:uuid;not null;index" json:"business_role_id"`
+	DocumentType       string    `gorm:"size:32;not null" json:"document_type"`
+	MaxAmount          float64   `gorm:"type:decimal(15,2);not null" json:"max_amount"`
+	CreatedBy          string    `gorm:"size:255;not null" json:"created_by"`
+	UpdatedBy          string    `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for ApprovalLimit
+func (ApprovalLimit) TableName() string {
+	return "approval_limits"
+}
+
+// ApprovalLimitFor returns the highest limit among those set for the roles, and false if
+// none of the roles has one
+func ApprovalLimitFor(limits []ApprovalLimit, roleIDs []uuid.UUID) (float64, bool) {
+	held := make(map[uuid.UUID]bool, len(roleIDs))
+	for _, id := range roleIDs {
+		held[id] = true
+	}
+	limit, ok := 0.0, false
+	for _, l := range limits {
+		if held[l.BusinessRoleID] && (!ok || l.MaxAmount > limit) {
+			limit, ok = l.MaxAmount, true
+		}
+	}
+	return limit, ok
+}
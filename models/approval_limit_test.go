@@ -0,0 +1,25 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestApprovalLimitFor(t *testing.T) {
+	manager, admin, engineer := uuid.New(), uuid.New(), uuid.New()
+	limits := []ApprovalLimit{
+		{BusinessRoleID: manager, DocumentType: ApprovalLimitPurchase, MaxAmount: 100000},
+		{BusinessRoleID: admin, DocumentType: ApprovalLimitPurchase, MaxAmount: 1000000},
+	}
+
+	if limit, ok := ApprovalLimitFor(limits, []uuid.UUID{manager, admin}); !ok || limit != 1000000 {
+		t.Errorf("expected the highest of the roles' limits, got %.2f %v", limit, ok)
+	}
+	if limit, ok := ApprovalLimitFor(limits, []uuid.UUID{manager, engineer}); !ok || limit != 100000 {
+		t.Errorf("expected the manager limit, got %.2f %v", limit, ok)
+	}
+	if _, ok := ApprovalLimitFor(limits, []uuid.UUID{engineer}); ok {
+		t.Error("expected no limit for a role without one")
+	}
+}
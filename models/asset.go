@@ -0,0 +1,281 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Asset domains decide who maintains an asset: solar plant is maintained under
+// solar:maintenance, water works plant under water:manage_supply, and general assets such
+// as vehicles under asset:manage
+const (
+	AssetDomainSolar   = "solar"
+	AssetDomainWater   = "water"
+	AssetDomainGeneral = "general"
+)
+
+// Asset statuses
+const (
+	AssetInService        = "in_service"
+	AssetUnderMaintenance = "under_maintenance"
+	AssetDown             = "down"
+	AssetRetired          = "retired"
+)
+
+// Maintenance task kinds and statuses
+const (
+	MaintenancePreventive = "preventive"
+	MaintenanceCorrective = "corrective"
+
+	MaintenanceOpen       = "open"
+	MaintenanceInProgress = "in_progress"
+	MaintenanceDone       = "done"
+	MaintenanceCancelled  = "cancelled"
+)
+
+// AssetMaintenancePermission returns the permission that lets a user maintain assets of
+// the domain
+func AssetMaintenancePermission(domain string) string {
+	switch domain {
+	case AssetDomainSolar:
+		return "solar:maintenance"
+	case AssetDomainWater:
+		return "water:manage_supply"
+	}
+	return "asset:manage"
+}
+
+// ValidAssetDomain reports whether domain is a known asset domain
+func ValidAssetDomain(domain string) bool {
+	return domain == AssetDomainSolar || domain == AssetDomainWater || domain == AssetDomainGeneral
+}
+
+// Asset is a piece of equipment or a vehicle, such as a pump, an inverter or a truck, kept
+// at a site in someone's custody
+type Asset struct {
+	ID                 uuid.UUID   `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID   `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	AssetTag           string      `gorm:"size:64;not null" json:"asset_tag"`
+	Name               string      `gorm:"size:255;not null" json:"name"`
+	Category           string      `gorm:"size:50;not null;index" json:"category"` // pump, inverter, vehicle, ...
+	Domain             string      `gorm:"size:20;not null;default:'general';index" json:"domain"`
+	Manufacturer       string      `gorm:"size:255" json:"manufacturer,omitempty"`
+	ModelNumber        string      `gorm:"size:255" json:"model_number,omitempty"`
+	SerialNumber       string      `gorm:"size:128;index" json:"serial_number,omitempty"`
+	SiteID             *uuid.UUID  `gorm:"type:uuid;index" json:"site_id,omitempty"`
+	Site               *Site       `gorm:"foreignKey:SiteID" json:"site,omitempty"`
+	LocationNote       string      `gorm:"type:text" json:"location_note,omitempty"`
+	Latitude           *float64    `gorm:"type:decimal(10,8)" json:"latitude,omitempty"`
+	Longitude          *float64    `gorm:"type:decimal(11,8)" json:"longitude,omitempty"`
+	CustodianID        string      `gorm:"size:255;index" json:"custodian_id,omitempty"`
+	CustodianName      string      `gorm:"size:255" json:"custodian_name,omitempty"`
+	CommissionedOn     *time.Time  `gorm:"type:date" json:"commissioned_on,omitempty"`
+	WarrantyUntil      *time.Time  `gorm:"type:date" json:"warranty_until,omitempty"`
+	PurchaseOrderID    *uuid.UUID  `gorm:"type:uuid;index" json:"purchase_order_id,omitempty"`
+	Status             string      `gorm:"size:32;not null;default:'in_service';index" json:"status"`
+	Attributes         StringArray `gorm:"type:jsonb;default:'[]'" json:"attributes"` // free-form ratings, e.g. "15 HP", "100 kVA"
+	Remarks            string      `gorm:"type:text" json:"remarks,omitempty"`
+	CreatedBy          string      `gorm:"size:255;not null" json:"created_by"`
+	UpdatedBy          string      `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt          time.Time   `json:"created_at"`
+	UpdatedAt          time.Time   `json:"updated_at"`
+	DeletedAt          *time.Time  `gorm:"index" json:"deleted_at,omitempty"`
+}
+
+// TableName specifies the table name for Asset
+func (Asset) TableName() string {
+	return "assets"
+}
+
+// AssetCustodyTransfer records an asset changing hands or moving between sites
+type AssetCustodyTransfer struct {
+	ID              uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	AssetID         uuid.UUID  `gorm:"type:uuid;not null;index" json:"asset_id"`
+	FromCustodianID string     `gorm:"size:255" json:"from_custodian_id,omitempty"`
+	ToCustodianID   string     `gorm:"size:255" json:"to_custodian_id,omitempty"`
+	ToCustodianName string     `gorm:"size:255" json:"to_custodian_name,omitempty"`
+	FromSiteID      *uuid.UUID `gorm:"type:uuid" json:"from_site_id,omitempty"`
+	ToSiteID        *uuid.UUID `gorm:"type:uuid" json:"to_site_id,omitempty"`
+	Remarks         string     `gorm:"type:text" json:"remarks,omitempty"`
+	TransferredBy   string     `gorm:"size:255;not null" json:"transferred_by"`
+	TransferredAt   time.Time  `gorm:"not null" json:"transferred_at"`
+}
+
+// TableName specifies the table name for AssetCustodyTransfer
+func (AssetCustodyTransfer) TableName() string {
+	return "asset_custody_transfers"
+}
+
+// MaintenanceSchedule is an asset's preventive maintenance, due every IntervalDays. A
+// task is raised LeadDays before each due date and, once done, the next due date is
+// counted from when it was done.
+type MaintenanceSchedule struct {
+	ID           uuid.UUID   `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	AssetID      uuid.UUID   `gorm:"type:uuid;not null;index" json:"asset_id"`
+	Asset        *Asset      `gorm:"foreignKey:AssetID" json:"asset,omitempty"`
+	Title        string      `gorm:"size:255;not null" json:"title"`
+	Description  string      `gorm:"type:text" json:"description,omitempty"`
+	Checklist    StringArray `gorm:"type:jsonb;default:'[]'" json:"checklist"`
+	IntervalDays int         `gorm:"not null" json:"interval_days"`
+	LeadDays     int         `gorm:"default:0" json:"lead_days"`
+	NextDueOn    time.Time   `gorm:"type:date;not null;index" json:"next_due_on"`
+	LastDoneOn   *time.Time  `gorm:"type:date" json:"last_done_on,omitempty"`
+	AssigneeID   string      `gorm:"size:255" json:"assignee_id,omitempty"`
+	IsActive     bool        `gorm:"default:true;index" json:"is_active"`
+	CreatedBy    string      `gorm:"size:255;not null" json:"created_by"`
+	UpdatedBy    string      `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt    time.Time   `json:"created_at"`
+	UpdatedAt    time.Time   `json:"updated_at"`
+}
+
+// TableName specifies the table name for MaintenanceSchedule
+func (MaintenanceSchedule) TableName() string {
+	return "maintenance_schedules"
+}
+
+// Validate checks the schedule's interval and lead time
+func (s MaintenanceSchedule) Validate() error {
+	switch {
+	case s.Title == "":
+		return fmt.Errorf("title is required")
+	case s.IntervalDays <= 0:
+		return fmt.Errorf("interval_days must be positive")
+	case s.LeadDays < 0 || s.LeadDays >= s.IntervalDays:
+		return fmt.Errorf("lead_days must be at least 0 and less than interval_days")
+	case s.NextDueOn.IsZero():
+		return fmt.Errorf("next_due_on is required")
+	}
+	return nil
+}
+
+// DueForTask reports whether the schedule's next task should be raised by now
+func (s MaintenanceSchedule) DueForTask(now time.Time) bool {
+	return s.IsActive && !s.NextDueOn.AddDate(0, 0, -s.LeadDays).After(now)
+}
+
+// Advance moves the schedule on after maintenance done on doneOn
+func (s *MaintenanceSchedule) Advance(doneOn time.Time) {
+	day := time.Date(doneOn.Year(), doneOn.Month(), doneOn.Day(), 0, 0, 0, 0, time.UTC)
+	s.LastDoneOn = &day
+	s.NextDueOn = day.AddDate(0, 0, s.IntervalDays)
+}
+
+// MaintenanceTask is a piece of maintenance work on an asset: preventive, raised from a
+// schedule, or corrective, raised by hand, often against a breakdown
+type MaintenanceTask struct {
+	ID                  uuid.UUID   `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID  uuid.UUID   `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	AssetID             uuid.UUID   `gorm:"type:uuid;not null;index" json:"asset_id"`
+	Asset               *Asset      `gorm:"foreignKey:AssetID" json:"asset,omitempty"`
+	ScheduleID          *uuid.UUID  `gorm:"type:uuid;index" json:"schedule_id,omitempty"`
+	DowntimeID          *uuid.UUID  `gorm:"type:uuid;index" json:"downtime_id,omitempty"`
+	Kind                string      `gorm:"size:20;not null" json:"kind"`
+	Title               string      `gorm:"size:255;not null" json:"title"`
+	Description         string      `gorm:"type:text" json:"description,omitempty"`
+	Checklist           StringArray `gorm:"type:jsonb;default:'[]'" json:"checklist"`
+	DueOn               time.Time   `gorm:"type:date;not null;index" json:"due_on"`
+	AssigneeID          string      `gorm:"size:255;index" json:"assignee_id,omitempty"`
+	Status              string      `gorm:"size:20;not null;default:'open';index" json:"status"`
+	StartedAt           *time.Time  `json:"started_at,omitempty"`
+	CompletedAt         *time.Time  `json:"completed_at,omitempty"`
+	CompletedBy         string      `gorm:"size:255" json:"completed_by,omitempty"`
+	Findings            string      `gorm:"type:text" json:"findings,omitempty"`
+	EvidenceDocumentIDs StringArray `gorm:"type:jsonb;default:'[]'" json:"evidence_document_ids"` // DMS photos of the finished work
+	AssignedBy          string      `gorm:"size:255" json:"assigned_by,omitempty"`
+	AssignedAt          *time.Time  `json:"assigned_at,omitempty"`
+	CreatedBy           string      `gorm:"size:255;not null" json:"created_by"`
+	CreatedAt           time.Time   `json:"created_at"`
+	UpdatedAt           time.Time   `json:"updated_at"`
+}
+
+// TableName specifies the table name for MaintenanceTask
+func (MaintenanceTask) TableName() string {
+	return "maintenance_tasks"
+}
+
+// AssetDowntime is a period an asset was out of service. It is open until EndedAt is set.
+type AssetDowntime struct {
+	ID                 uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID  `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	AssetID            uuid.UUID  `gorm:"type:uuid;not null;index" json:"asset_id"`
+	StartedAt          time.Time  `gorm:"not null;index" json:"started_at"`
+	EndedAt            *time.Time `json:"ended_at,omitempty"`
+	Cause              string     `gorm:"size:32;not null" json:"cause"` // breakdown, preventive, power_failure, other
+	Description        string     `gorm:"type:text" json:"description,omitempty"`
+	Resolution         string     `gorm:"type:text" json:"resolution,omitempty"`
+	ReportedBy         string     `gorm:"size:255;not null" json:"reported_by"`
+	ResolvedBy         string     `gorm:"size:255" json:"resolved_by,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for AssetDowntime
+func (AssetDowntime) TableName() string {
+	return "asset_downtimes"
+}
+
+// AssetAvailability sums the downtime of an asset, or of the assets at a site, over a
+// period
+type AssetAvailability struct {
+	Assets                    int     `json:"assets"`
+	PeriodHours               float64 `json:"period_hours"` // asset-hours
+	DowntimeHours             float64 `json:"downtime_hours"`
+	Incidents                 int     `json:"incidents"`
+	Failures                  int     `json:"failures"` // breakdowns that began in the period
+	AvailabilityPct           float64 `json:"availability_percent"`
+	MeanTimeToRepairHr        float64 `json:"mean_time_to_repair_hours"`        // over incidents that ended in the period
+	MeanTimeBetweenFailuresHr float64 `json:"mean_time_between_failures_hours"` // hours in service per failure
+}
+
+// SummarizeDowntime works out an asset's availability over [from, to) from its
+// downtimes, counting only the part of each that falls in the period. Open downtimes run
+// to the end of it.
+func SummarizeDowntime(downtimes []AssetDowntime, from, to time.Time) AssetAvailability {
+	return SummarizeSiteDowntime(1, downtimes, from, to)
+}
+
+// SummarizeSiteDowntime works out the availability of a number of assets over [from, to)
+// from their downtimes, as SummarizeDowntime does for one
+func SummarizeSiteDowntime(assets int, downtimes []AssetDowntime, from, to time.Time) AssetAvailability {
+	a := AssetAvailability{Assets: assets, PeriodHours: to.Sub(from).Hours() * float64(assets)}
+	var repairHours float64
+	var repaired int
+	for _, d := range downtimes {
+		end := to
+		if d.EndedAt != nil && d.EndedAt.Before(to) {
+			end = *d.EndedAt
+		}
+		start := d.StartedAt
+		if start.Before(from) {
+			start = from
+		}
+		if !end.After(start) {
+			continue
+		}
+		a.Incidents++
+		a.DowntimeHours += end.Sub(start).Hours()
+		if d.Cause == "breakdown" && !d.StartedAt.Before(from) {
+			a.Failures++
+		}
+		if d.EndedAt != nil && !d.EndedAt.After(to) {
+			repaired++
+			repairHours += d.EndedAt.Sub(d.StartedAt).Hours()
+		}
+	}
+	uptime := math.Max(0, a.PeriodHours-a.DowntimeHours)
+	a.DowntimeHours = math.Round(a.DowntimeHours*100) / 100
+	if a.PeriodHours > 0 {
+		a.AvailabilityPct = math.Round(uptime*10000/a.PeriodHours) / 100
+	}
+	if repaired > 0 {
+		a.MeanTimeToRepairHr = math.Round(repairHours*100/float64(repaired)) / 100
+	}
+	if a.Failures > 0 {
+		a.MeanTimeBetweenFailuresHr = math.Round(uptime*100/float64(a.Failures)) / 100
+	}
+	a.PeriodHours = math.Round(a.PeriodHours*100) / 100
+	return a
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaintenanceScheduleDueAndAdvance(t *testing.T) {
+	due := time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)
+	s := MaintenanceSchedule{Title: "Pump service", IntervalDays: 90, LeadDays: 7, NextDueOn: due, IsActive: true}
+	if err := s.Validate(); err != nil {
+		t.Fatalf("valid schedule rejected: %v", err)
+	}
+
+	if s.DueForTask(due.AddDate(0, 0, -8)) {
+		t.Error("task raised before the lead time")
+	}
+	if !s.DueForTask(due.AddDate(0, 0, -7)) {
+		t.Error("task not raised at the lead time")
+	}
+	s.IsActive = false
+	if s.DueForTask(due) {
+		t.Error("paused schedule raised a task")
+	}
+
+	// Done late, the next due date counts from when it was done
+	s.Advance(time.Date(2026, 10, 25, 15, 30, 0, 0, time.UTC))
+	if !s.LastDoneOn.Equal(time.Date(2026, 10, 25, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected last done date: %v", s.LastDoneOn)
+	}
+	if !s.NextDueOn.Equal(time.Date(2027, 1, 23, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected next due date: %v", s.NextDueOn)
+	}
+
+	for _, bad := range []MaintenanceSchedule{
+		{Title: "", IntervalDays: 30, NextDueOn: due},
+		{Title: "x", IntervalDays: 0, NextDueOn: due},
+		{Title: "x", IntervalDays: 30, LeadDays: 30, NextDueOn: due},
+		{Title: "x", IntervalDays: 30},
+	} {
+		if bad.Validate() == nil {
+			t.Errorf("invalid schedule accepted: %+v", bad)
+		}
+	}
+}
+
+func TestSummarizeDowntime(t *testing.T) {
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 10) // 240 hours
+	ended := func(t time.Time) *time.Time { return &t }
+	downtimes := []AssetDowntime{
+		// Began before the period: only its last 6 hours count, but all 18 go to repair time
+		{StartedAt: from.Add(-12 * time.Hour), EndedAt: ended(from.Add(6 * time.Hour))},
+		{StartedAt: from.Add(48 * time.Hour), EndedAt: ended(from.Add(54 * time.Hour))},
+		// Still open: runs to the end of the period
+		{StartedAt: to.Add(-12 * time.Hour)},
+		// Ended before the period
+		{StartedAt: from.Add(-48 * time.Hour), EndedAt: ended(from.Add(-24 * time.Hour))},
+	}
+
+	a := SummarizeDowntime(downtimes, from, to)
+	if a.PeriodHours != 240 || a.DowntimeHours != 24 || a.Incidents != 3 {
+		t.Fatalf("unexpected downtime summary: %+v", a)
+	}
+	if a.AvailabilityPct != 90 {
+		t.Errorf("expected 90%% availability, got %v", a.AvailabilityPct)
+	}
+	if a.MeanTimeToRepairHr != 12 {
+		t.Errorf("expected a 12 hour mean time to repair, got %v", a.MeanTimeToRepairHr)
+	}
+}
+
+func TestSummarizeSiteDowntime(t *testing.T) {
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 10) // 240 hours per asset
+	ended := func(t time.Time) *time.Time { return &t }
+	downtimes := []AssetDowntime{
+		{Cause: "breakdown", StartedAt: from.Add(24 * time.Hour), EndedAt: ended(from.Add(34 * time.Hour))},
+		{Cause: "breakdown", StartedAt: from.Add(100 * time.Hour), EndedAt: ended(from.Add(120 * time.Hour))},
+		// Planned work is downtime but not a failure
+		{Cause: "preventive", StartedAt: from.Add(200 * time.Hour), EndedAt: ended(from.Add(210 * time.Hour))},
+	}
+
+	a := SummarizeSiteDowntime(4, downtimes, from, to)
+	if a.Assets != 4 || a.PeriodHours != 960 || a.DowntimeHours != 40 || a.Incidents != 3 || a.Failures != 2 {
+		t.Fatalf("unexpected site summary: %+v", a)
+	}
+	if a.AvailabilityPct != 95.83 {
+		t.Errorf("expected 95.83%% availability, got %v", a.AvailabilityPct)
+	}
+	if a.MeanTimeBetweenFailuresHr != 460 {
+		t.Errorf("expected 460 hours between failures, got %v", a.MeanTimeBetweenFailuresHr)
+	}
+}
+
+func TestAssetMaintenancePermission(t *testing.T) {
+	for domain, want := range map[string]string{
+		AssetDomainSolar:   "solar:maintenance",
+		AssetDomainWater:   "water:manage_supply",
+		AssetDomainGeneral: "asset:manage",
+	} {
+		if got := AssetMaintenancePermission(domain); got != want {
+			t.Errorf("%s: expected %s, got %s", domain, want, got)
+		}
+	}
+}
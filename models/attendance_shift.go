@@ -0,0 +1,304 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Daily attendance statuses. A weekly off worked on stays a weekly off, with the time
+// worked counted as overtime.
+const (
+	AttendancePresent   = "present"
+	AttendanceHalfDay   = "half_day"
+	AttendanceAbsent    = "absent"
+	AttendanceWeeklyOff = "weekly_off"
+	AttendanceOnLeave   = "on_leave"
+)
+
+// ValidAttendanceStatus reports whether status is a known daily attendance status
+func ValidAttendanceStatus(status string) bool {
+	switch status {
+	case AttendancePresent, AttendanceHalfDay, AttendanceAbsent, AttendanceWeeklyOff, AttendanceOnLeave:
+		return true
+	}
+	return false
+}
+
+// MusterCode is the code a daily status is shown as on a muster roll
+func MusterCode(status string) string {
+	switch status {
+	case AttendancePresent:
+		return "P"
+	case AttendanceHalfDay:
+		return "HD"
+	case AttendanceWeeklyOff:
+		return "WO"
+	case AttendanceOnLeave:
+		return "L"
+	}
+	return "A"
+}
+
+// Daily attendance sources. Geofenced days are derived from attendance sessions and are
+// never allowed to overwrite days entered manually, uploaded, or marked by approved leave.
+const (
+	AttendanceSourceGeofenced = "geofenced"
+	AttendanceSourceManual    = "manual"
+	AttendanceSourceBulk      = "bulk_upload"
+	AttendanceSourceLeave     = "leave"
+)
+
+// Shift is a working shift: its hours, the grace before arriving counts as late, and the
+// rules that make a day a half day, an absence or overtime. A default shift applies to
+// employees of its site, or of the whole business if it has no site, who have no shift
+// assigned.
+type Shift struct {
+	ID                 uuid.UUID   `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID   `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	SiteID             *uuid.UUID  `gorm:"type:uuid;index" json:"site_id,omitempty"`
+	Code               string      `gorm:"size:32;not null" json:"code"`
+	Name               string      `gorm:"size:100;not null" json:"name"`
+	StartTime          string      `gorm:"size:5;not null" json:"start_time"` // HH:MM
+	EndTime            string      `gorm:"size:5;not null" json:"end_time"`   // HH:MM, before StartTime for a night shift
+	Timezone           string      `gorm:"size:64;not null;default:'Asia/Kolkata'" json:"timezone"`
+	BreakMinutes       int         `gorm:"default:0" json:"break_minutes"`
+	GraceMinutes       int         `gorm:"default:0" json:"grace_minutes"`         // arriving later than this is late
+	HalfDayLateMinutes int         `gorm:"default:0" json:"half_day_late_minutes"` // arriving this late makes a half day; 0 for never
+	FullDayMinutes     int         `gorm:"default:0" json:"full_day_minutes"`      // work needed for a full day; 0 for the shift less its break
+	HalfDayMinutes     int         `gorm:"default:0" json:"half_day_minutes"`      // work needed for a half day; 0 for half a full day
+	OvertimeMinMinutes int         `gorm:"default:0" json:"overtime_min_minutes"`  // overtime shorter than this is not counted
+	WeeklyOffs         StringArray `gorm:"type:jsonb;default:'[\"sunday\"]'" json:"weekly_offs"`
+	IsDefault          bool        `gorm:"default:false" json:"is_default"`
+	IsActive           bool        `gorm:"default:true;index" json:"is_active"`
+	CreatedBy          string      `gorm:"size:255;not null" json:"created_by"`
+	UpdatedBy          string      `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt          time.Time   `json:"created_at"`
+	UpdatedAt          time.Time   `json:"updated_at"`
+}
+
+// TableName specifies the table name for Shift
+func (Shift) TableName() string {
+	return "shifts"
+}
+
+// shiftClock parses an HH:MM time of day into minutes after midnight
+func shiftClock(v string) (int, error) {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not an HH:MM time", v)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Validate checks the shift's times, timezone, rules and weekly offs
+func (s Shift) Validate() error {
+	if strings.TrimSpace(s.Code) == "" || strings.TrimSpace(s.Name) == "" {
+		return fmt.Errorf("code and name are required")
+	}
+	start, err := shiftClock(s.StartTime)
+	if err != nil {
+		return fmt.Errorf("start_time: %v", err)
+	}
+	end, err := shiftClock(s.EndTime)
+	if err != nil {
+		return fmt.Errorf("end_time: %v", err)
+	}
+	if start == end {
+		return fmt.Errorf("a shift cannot start and end at the same time")
+	}
+	if _, err := time.LoadLocation(s.Timezone); err != nil || s.Timezone == "" {
+		return fmt.Errorf("unknown timezone %q", s.Timezone)
+	}
+	if s.BreakMinutes < 0 || s.GraceMinutes < 0 || s.HalfDayLateMinutes < 0 || s.FullDayMinutes < 0 ||
+		s.HalfDayMinutes < 0 || s.OvertimeMinMinutes < 0 {
+		return fmt.Errorf("minutes cannot be negative")
+	}
+	if s.BreakMinutes >= s.Length() {
+		return fmt.Errorf("the break must be shorter than the shift")
+	}
+	if s.HalfDayMinutes > s.FullDayWork() {
+		return fmt.Errorf("half_day_minutes cannot exceed a full day's work")
+	}
+	for _, day := range s.WeeklyOffs {
+		if _, ok := weekdays[strings.ToLower(day)]; !ok {
+			return fmt.Errorf("unknown weekly off %q", day)
+		}
+	}
+	return nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+}
+
+// Length is the shift's span in minutes, overnight for a shift ending before it starts
+func (s Shift) Length() int {
+	start, _ := shiftClock(s.StartTime)
+	end, _ := shiftClock(s.EndTime)
+	if end <= start {
+		end += 24 * 60
+	}
+	return end - start
+}
+
+// FullDayWork is the minutes of work a full day needs
+func (s Shift) FullDayWork() int {
+	if s.FullDayMinutes > 0 {
+		return s.FullDayMinutes
+	}
+	return s.Length() - s.BreakMinutes
+}
+
+// HalfDayWork is the minutes of work a half day needs
+func (s Shift) HalfDayWork() int {
+	if s.HalfDayMinutes > 0 {
+		return s.HalfDayMinutes
+	}
+	return s.FullDayWork() / 2
+}
+
+// Location returns the shift's timezone, or UTC if it cannot be loaded
+func (s Shift) Location() *time.Location {
+	if loc, err := time.LoadLocation(s.Timezone); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// StartOn returns when the shift starts on the date
+func (s Shift) StartOn(date time.Time) time.Time {
+	start, _ := shiftClock(s.StartTime)
+	return time.Date(date.Year(), date.Month(), date.Day(), start/60, start%60, 0, 0, s.Location())
+}
+
+// IsWeeklyOff reports whether the date is one of the shift's weekly offs
+func (s Shift) IsWeeklyOff(date time.Time) bool {
+	for _, day := range s.WeeklyOffs {
+		if weekdays[strings.ToLower(day)] == date.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// ShiftAssignment puts an employee on a shift from EffectiveFrom until EffectiveTo, or
+// until a later assignment if it has none
+type ShiftAssignment struct {
+	ID                 uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID  `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	UserID             uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	ShiftID            uuid.UUID  `gorm:"type:uuid;not null;index" json:"shift_id"`
+	EffectiveFrom      time.Time  `gorm:"type:date;not null" json:"effective_from"`
+	EffectiveTo        *time.Time `gorm:"type:date" json:"effective_to,omitempty"`
+	CreatedBy          string     `gorm:"size:255;not null" json:"created_by"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+	Shift              *Shift     `gorm:"foreignKey:ShiftID" json:"shift,omitempty"`
+}
+
+// TableName specifies the table name for ShiftAssignment
+func (ShiftAssignment) TableName() string {
+	return "shift_assignments"
+}
+
+// AttendanceDay is an employee's attendance for a day at a site, evaluated against their
+// shift: derived from geofenced check-ins, entered by hand, or uploaded in bulk
+type AttendanceDay struct {
+	ID                 uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID  `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	SiteID             uuid.UUID  `gorm:"type:uuid;not null;index" json:"site_id"`
+	UserID             uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Date               time.Time  `gorm:"type:date;not null;index" json:"date"`
+	ShiftID            *uuid.UUID `gorm:"type:uuid" json:"shift_id,omitempty"`
+	Source             string     `gorm:"size:20;not null" json:"source"`
+	CheckInAt          *time.Time `json:"check_in_at,omitempty"`
+	CheckOutAt         *time.Time `json:"check_out_at,omitempty"`
+	Status             string     `gorm:"size:20;not null;index" json:"status"`
+	WorkedMinutes      int        `json:"worked_minutes"`
+	LateMinutes        int        `json:"late_minutes"`
+	IsLate             bool       `json:"is_late"`
+	OvertimeMinutes    int        `json:"overtime_minutes"`
+	Remarks            string     `gorm:"type:text" json:"remarks,omitempty"`
+	RecordedBy         string     `gorm:"size:255" json:"recorded_by,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for AttendanceDay
+func (AttendanceDay) TableName() string {
+	return "attendance_days"
+}
+
+// Evaluate works out the day's status, lateness, hours and overtime from its check-in
+// and check-out under the shift, or with no shift rules if shift is nil. A day with no
+// check-in is absent, or a weekly off. The grace allowed on arrival also comes off a full
+// day's work. Without a check-out the work done is unknown, so the day stands on its
+// lateness alone. Time worked on a weekly off is all overtime.
+func (d *AttendanceDay) Evaluate(shift *Shift) {
+	d.WorkedMinutes, d.LateMinutes, d.IsLate, d.OvertimeMinutes = 0, 0, false, 0
+	weeklyOff := shift != nil && shift.IsWeeklyOff(d.Date)
+	if d.CheckInAt == nil {
+		d.Status = AttendanceAbsent
+		if weeklyOff {
+			d.Status = AttendanceWeeklyOff
+		}
+		return
+	}
+
+	if d.CheckOutAt != nil && d.CheckOutAt.After(*d.CheckInAt) {
+		d.WorkedMinutes = int(d.CheckOutAt.Sub(*d.CheckInAt).Minutes())
+		if shift != nil {
+			d.WorkedMinutes -= shift.BreakMinutes
+			if d.WorkedMinutes < 0 {
+				d.WorkedMinutes = 0
+			}
+		}
+	}
+	d.Status = AttendancePresent
+	if shift == nil {
+		return
+	}
+	if weeklyOff {
+		d.Status = AttendanceWeeklyOff
+		if d.WorkedMinutes >= shift.OvertimeMinMinutes {
+			d.OvertimeMinutes = d.WorkedMinutes
+		}
+		return
+	}
+
+	if late := int(d.CheckInAt.Sub(shift.StartOn(d.Date)).Minutes()); late > 0 {
+		d.LateMinutes = late
+		d.IsLate = late > shift.GraceMinutes
+	}
+	if d.CheckOutAt != nil {
+		switch {
+		case d.WorkedMinutes+shift.GraceMinutes >= shift.FullDayWork():
+			d.Status = AttendancePresent
+		case d.WorkedMinutes >= shift.HalfDayWork():
+			d.Status = AttendanceHalfDay
+		default:
+			d.Status = AttendanceAbsent
+		}
+		if extra := d.WorkedMinutes - shift.FullDayWork(); extra > 0 && extra >= shift.OvertimeMinMinutes {
+			d.OvertimeMinutes = extra
+		}
+	}
+	if d.Status == AttendancePresent && shift.HalfDayLateMinutes > 0 && d.LateMinutes >= shift.HalfDayLateMinutes {
+		d.Status = AttendanceHalfDay
+	}
+}
+
+// PaidDayValue is how much of a paid day the status counts for in payroll
+func PaidDayValue(status string) float64 {
+	switch status {
+	case AttendancePresent:
+		return 1
+	case AttendanceHalfDay:
+		return 0.5
+	}
+	return 0
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShiftValidate(t *testing.T) {
+	general := Shift{Code: "GEN", Name: "General", StartTime: "09:00", EndTime: "18:00", Timezone: "Asia/Kolkata", BreakMinutes: 60}
+	if err := general.Validate(); err != nil {
+		t.Fatalf("valid shift rejected: %v", err)
+	}
+	if general.FullDayWork() != 480 || general.HalfDayWork() != 240 {
+		t.Errorf("expected 480 and 240 minutes, got %d and %d", general.FullDayWork(), general.HalfDayWork())
+	}
+	night := Shift{Code: "N", Name: "Night", StartTime: "22:00", EndTime: "06:00", Timezone: "Asia/Kolkata"}
+	if night.Length() != 480 {
+		t.Errorf("expected an 8 hour night shift, got %d minutes", night.Length())
+	}
+
+	for _, bad := range []Shift{
+		{Code: "X", Name: "x", StartTime: "9am", EndTime: "18:00", Timezone: "Asia/Kolkata"},
+		{Code: "X", Name: "x", StartTime: "09:00", EndTime: "09:00", Timezone: "Asia/Kolkata"},
+		{Code: "X", Name: "x", StartTime: "09:00", EndTime: "18:00", Timezone: "Mars/Olympus"},
+		{Code: "X", Name: "x", StartTime: "09:00", EndTime: "10:00", Timezone: "UTC", BreakMinutes: 60},
+		{Code: "X", Name: "x", StartTime: "09:00", EndTime: "18:00", Timezone: "UTC", WeeklyOffs: StringArray{"funday"}},
+	} {
+		if bad.Validate() == nil {
+			t.Errorf("invalid shift accepted: %+v", bad)
+		}
+	}
+}
+
+func TestAttendanceDayEvaluate(t *testing.T) {
+	ist, _ := time.LoadLocation("Asia/Kolkata")
+	shift := &Shift{
+		Code: "GEN", Name: "General", StartTime: "09:00", EndTime: "18:00", Timezone: "Asia/Kolkata",
+		BreakMinutes: 60, GraceMinutes: 10, HalfDayLateMinutes: 120, OvertimeMinMinutes: 30,
+		WeeklyOffs: StringArray{"sunday"},
+	}
+	thursday := time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+	at := func(day time.Time, h, m int) *time.Time {
+		t := time.Date(day.Year(), day.Month(), day.Day(), h, m, 0, 0, ist)
+		return &t
+	}
+
+	cases := []struct {
+		name             string
+		date             time.Time
+		in, out          *time.Time
+		status           string
+		late             bool
+		worked, overtime int
+	}{
+		{"within grace", thursday, at(thursday, 9, 8), at(thursday, 18, 0), AttendancePresent, false, 472, 0},
+		{"late with overtime", thursday, at(thursday, 9, 20), at(thursday, 19, 30), AttendancePresent, true, 550, 70},
+		{"overtime under the minimum", thursday, at(thursday, 9, 0), at(thursday, 18, 20), AttendancePresent, false, 500, 0},
+		{"left at lunch", thursday, at(thursday, 9, 0), at(thursday, 14, 30), AttendanceHalfDay, false, 270, 0},
+		{"too short", thursday, at(thursday, 9, 0), at(thursday, 12, 0), AttendanceAbsent, false, 120, 0},
+		{"very late", thursday, at(thursday, 11, 15), nil, AttendanceHalfDay, true, 0, 0},
+		{"no check-in", thursday, nil, nil, AttendanceAbsent, false, 0, 0},
+		{"weekly off", thursday.AddDate(0, 0, 3), nil, nil, AttendanceWeeklyOff, false, 0, 0},
+		{"worked the weekly off", thursday.AddDate(0, 0, 3), at(thursday.AddDate(0, 0, 3), 10, 0), at(thursday.AddDate(0, 0, 3), 15, 0), AttendanceWeeklyOff, false, 240, 240},
+	}
+	for _, c := range cases {
+		d := AttendanceDay{Date: c.date, CheckInAt: c.in, CheckOutAt: c.out}
+		d.Evaluate(shift)
+		if d.Status != c.status || d.IsLate != c.late || d.WorkedMinutes != c.worked || d.OvertimeMinutes != c.overtime {
+			t.Errorf("%s: got status %s, late %v, worked %d, overtime %d", c.name, d.Status, d.IsLate, d.WorkedMinutes, d.OvertimeMinutes)
+		}
+	}
+
+	// Without a shift a check-in is simply a day present
+	d := AttendanceDay{Date: thursday, CheckInAt: at(thursday, 13, 0), CheckOutAt: at(thursday, 15, 0)}
+	d.Evaluate(nil)
+	if d.Status != AttendancePresent || d.WorkedMinutes != 120 || d.IsLate {
+		t.Errorf("unexpected day without a shift: %+v", d)
+	}
+}
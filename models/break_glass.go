@@ -0,0 +1,134 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Break-glass grant statuses
+const (
+	BreakGlassActive  = "active"
+	BreakGlassExpired = "expired"
+	BreakGlassRevoked = "revoked"
+)
+
+// Break-glass audit events
+const (
+	BreakGlassEventActivated = "activated"
+	BreakGlassEventUsed      = "used"
+	BreakGlassEventRevoked   = "revoked"
+	BreakGlassEventExpired   = "expired"
+)
+
+// Break-glass limits
+const (
+	BreakGlassMinJustification = 20
+	BreakGlassMaxPermissions   = 10
+	BreakGlassDefaultDuration  = 2 * time.Hour
+)
+
+// BreakGlassGrant is a self-activated, time-boxed grant of elevated permissions for an
+// emergency. It is honoured only until ExpiresAt and every use is audited.
+type BreakGlassGrant struct {
+	ID                 uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	UserID             uuid.UUID      `gorm:"type:uuid;not null;index:idx_break_glass_user_status,priority:1" json:"user_id"`
+	BusinessVerticalID *uuid.UUID     `gorm:"type:uuid;index" json:"business_vertical_id,omitempty"` // nil: global permissions
+	Permissions        pq.StringArray `gorm:"type:text[];not null" json:"permissions"`
+	Justification      string         `gorm:"type:text;not null" json:"justification"`
+	Status             string         `gorm:"size:20;not null;default:'active';index:idx_break_glass_user_status,priority:2" json:"status"`
+	ActivatedAt        time.Time      `gorm:"not null" json:"activated_at"`
+	ExpiresAt          time.Time      `gorm:"not null;index" json:"expires_at"`
+	EndedAt            *time.Time     `json:"ended_at,omitempty"`
+	EndedBy            *uuid.UUID     `gorm:"type:uuid" json:"ended_by,omitempty"`
+	EndReason          string         `gorm:"type:text" json:"end_reason,omitempty"`
+	UsageCount         int            `gorm:"default:0" json:"usage_count"`
+	LastUsedAt         *time.Time     `json:"last_used_at,omitempty"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+
+	User             *User             `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	BusinessVertical *BusinessVertical `gorm:"foreignKey:BusinessVerticalID" json:"business_vertical,omitempty"`
+}
+
+func (BreakGlassGrant) TableName() string {
+	return "break_glass_grants"
+}
+
+// Covers reports whether the grant allows permission at now. Global grants cover global
+// checks only and vertical grants cover checks in their vertical only.
+func (g *BreakGlassGrant) Covers(permission string, businessID *uuid.UUID, now time.Time) bool {
+	if g.Status != BreakGlassActive || !now.Before(g.ExpiresAt) || now.Before(g.ActivatedAt) {
+		return false
+	}
+	switch {
+	case g.BusinessVerticalID == nil && businessID != nil,
+		g.BusinessVerticalID != nil && (businessID == nil || *g.BusinessVerticalID != *businessID):
+		return false
+	}
+	for _, granted := range g.Permissions {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// BreakGlassEvent is the audit trail of a break-glass grant: its activation, every
+// request it authorised, and how it ended.
+type BreakGlassEvent struct {
+	ID         uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	GrantID    uuid.UUID `gorm:"type:uuid;not null;index" json:"grant_id"`
+	UserID     uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	ActorID    uuid.UUID `gorm:"type:uuid;not null" json:"actor_id"` // differs from UserID when a super admin revokes
+	Event      string    `gorm:"size:20;not null" json:"event"`
+	Permission string    `gorm:"size:100" json:"permission,omitempty"`
+	Method     string    `gorm:"size:10" json:"method,omitempty"`
+	Path       string    `gorm:"size:500" json:"path,omitempty"`
+	IPAddress  string    `gorm:"size:64" json:"ip_address,omitempty"`
+	UserAgent  string    `gorm:"size:255" json:"user_agent,omitempty"`
+	Details    string    `gorm:"type:text" json:"details,omitempty"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
+
+func (BreakGlassEvent) TableName() string {
+	return "break_glass_events"
+}
+
+// breakGlassForbidden are grants break-glass may never hand out: they would make the
+// holder a super admin or let them extend their own access.
+var breakGlassForbidden = map[string]bool{
+	"*":            true,
+	"*:*:*":        true,
+	"admin_all":    true,
+	"manage_roles": true,
+}
+
+// NormalizeBreakGlassPermissions trims and de-duplicates the requested permissions and
+// rejects wildcards and permissions that manage access itself.
+func NormalizeBreakGlassPermissions(requested []string) ([]string, error) {
+	seen := make(map[string]bool, len(requested))
+	permissions := make([]string, 0, len(requested))
+	for _, perm := range requested {
+		perm = strings.TrimSpace(perm)
+		if perm == "" || seen[perm] {
+			continue
+		}
+		if breakGlassForbidden[perm] || strings.Contains(perm, "*") || strings.HasPrefix(perm, "break_glass:") {
+			return nil, fmt.Errorf("permission %q cannot be granted through break-glass", perm)
+		}
+		seen[perm] = true
+		permissions = append(permissions, perm)
+	}
+	if len(permissions) == 0 {
+		return nil, errors.New("at least one permission is required")
+	}
+	if len(permissions) > BreakGlassMaxPermissions {
+		return nil, fmt.Errorf("at most %d permissions may be requested", BreakGlassMaxPermissions)
+	}
+	return permissions, nil
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestBreakGlassGrantCovers(t *testing.T) {
+	now := time.Now()
+	vertical := uuid.New()
+	other := uuid.New()
+
+	global := BreakGlassGrant{Status: BreakGlassActive, Permissions: []string{"finance:approve"}, ActivatedAt: now.Add(-time.Minute), ExpiresAt: now.Add(time.Hour)}
+	scoped := global
+	scoped.BusinessVerticalID = &vertical
+
+	tests := []struct {
+		name       string
+		grant      BreakGlassGrant
+		permission string
+		businessID *uuid.UUID
+		at         time.Time
+		want       bool
+	}{
+		{"global grant, global check", global, "finance:approve", nil, now, true},
+		{"global grant, business check", global, "finance:approve", &vertical, now, false},
+		{"scoped grant, same vertical", scoped, "finance:approve", &vertical, now, true},
+		{"scoped grant, other vertical", scoped, "finance:approve", &other, now, false},
+		{"scoped grant, global check", scoped, "finance:approve", nil, now, false},
+		{"permission not granted", global, "finance:create", nil, now, false},
+		{"expired", global, "finance:approve", nil, now.Add(2 * time.Hour), false},
+	}
+	for _, tt := range tests {
+		if got := tt.grant.Covers(tt.permission, tt.businessID, tt.at); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	revoked := global
+	revoked.Status = BreakGlassRevoked
+	if revoked.Covers("finance:approve", nil, now) {
+		t.Error("revoked grant should not cover anything")
+	}
+}
+
+func TestNormalizeBreakGlassPermissions(t *testing.T) {
+	got, err := NormalizeBreakGlassPermissions([]string{" finance:approve ", "finance:approve", "", "bg:approve"})
+	if err != nil || len(got) != 2 || got[0] != "finance:approve" || got[1] != "bg:approve" {
+		t.Errorf("got %v, %v", got, err)
+	}
+
+	for _, bad := range [][]string{nil, {"*:*:*"}, {"finance:*"}, {"admin_all"}, {"manage_roles"}, {"break_glass:request"}} {
+		if _, err := NormalizeBreakGlassPermissions(bad); err == nil {
+			t.Errorf("%v should be rejected", bad)
+		}
+	}
+}
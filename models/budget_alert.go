@@ -0,0 +1,93 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// What a budget threshold watches
+const (
+	BudgetScopeProject    = "project"    // the project's spent against total budget
+	BudgetScopeTask       = "task"       // a task's total cost against its allocated budget
+	BudgetScopeAllocation = "allocation" // a budget allocation's actual against planned amount
+)
+
+const (
+	BudgetAlertOpen     = "open"
+	BudgetAlertResolved = "resolved"
+)
+
+// BudgetThreshold raises an alert when spend in its scope reaches Percent of the budget,
+// such as a task's cost reaching 90% of its allocated budget
+type BudgetThreshold struct {
+	ID        uuid.UUID            `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	ProjectID uuid.UUID            `gorm:"type:uuid;not null;index" json:"project_id"`
+	Scope     string               `gorm:"size:20;not null" json:"scope"`
+	Percent   float64              `gorm:"type:decimal(6,2);not null" json:"percent"`
+	Priority  NotificationPriority `gorm:"size:20;not null;default:'high'" json:"priority"`
+	IsActive  bool                 `gorm:"default:true" json:"is_active"`
+	CreatedBy string               `gorm:"size:255" json:"created_by,omitempty"`
+	CreatedAt time.Time            `json:"created_at"`
+	UpdatedAt time.Time            `json:"updated_at"`
+}
+
+// TableName specifies the table name for BudgetThreshold
+func (BudgetThreshold) TableName() string {
+	return "budget_thresholds"
+}
+
+// DefaultBudgetThresholds apply to projects that have not set their own: a warning at
+// 90% and a critical alert once over budget, for tasks, allocations and the project
+func DefaultBudgetThresholds(projectID uuid.UUID) []BudgetThreshold {
+	var thresholds []BudgetThreshold
+	for _, scope := range []string{BudgetScopeProject, BudgetScopeTask, BudgetScopeAllocation} {
+		thresholds = append(thresholds,
+			BudgetThreshold{ProjectID: projectID, Scope: scope, Percent: 90, Priority: NotificationPriorityHigh, IsActive: true},
+			BudgetThreshold{ProjectID: projectID, Scope: scope, Percent: 100, Priority: NotificationPriorityCritical, IsActive: true},
+		)
+	}
+	return thresholds
+}
+
+// BudgetAlert records spend in a threshold's scope reaching it. It stays open, and is
+// raised only once, until spend drops back below the threshold.
+type BudgetAlert struct {
+	ID               uuid.UUID   `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	ProjectID        uuid.UUID   `gorm:"type:uuid;not null;index:idx_budget_alerts_project_status,priority:1" json:"project_id"`
+	Scope            string      `gorm:"size:20;not null" json:"scope"`
+	SubjectID        uuid.UUID   `gorm:"type:uuid;not null;index" json:"subject_id"` // the project, task or allocation
+	SubjectName      string      `gorm:"size:255" json:"subject_name,omitempty"`
+	ThresholdPercent float64     `gorm:"type:decimal(6,2);not null" json:"threshold_percent"`
+	Budget           float64     `gorm:"type:decimal(15,2);not null" json:"budget"`
+	Spent            float64     `gorm:"type:decimal(15,2);not null" json:"spent"`
+	UsedPercent      float64     `gorm:"type:decimal(8,2);not null" json:"used_percent"`
+	Status           string      `gorm:"size:20;not null;default:'open';index:idx_budget_alerts_project_status,priority:2" json:"status"`
+	TriggeredAt      time.Time   `gorm:"not null" json:"triggered_at"`
+	ResolvedAt       *time.Time  `json:"resolved_at,omitempty"`
+	NotifiedUserIDs  StringArray `gorm:"type:jsonb;default:'[]'" json:"notified_user_ids"`
+	CreatedAt        time.Time   `json:"created_at"`
+	UpdatedAt        time.Time   `json:"updated_at"`
+}
+
+// TableName specifies the table name for BudgetAlert
+func (BudgetAlert) TableName() string {
+	return "budget_alerts"
+}
+
+// BudgetUsedPercent is spent as a percentage of budget, or 0 without a budget
+func BudgetUsedPercent(budget, spent float64) float64 {
+	if budget <= 0 {
+		return 0
+	}
+	return spent / budget * 100
+}
+
+// BudgetThresholdReached reports whether spend against budget reaches the threshold.
+// Nothing without a budget reaches one.
+func BudgetThresholdReached(threshold BudgetThreshold, budget, spent float64) bool {
+	if !threshold.IsActive || budget <= 0 {
+		return false
+	}
+	return BudgetUsedPercent(budget, spent) >= threshold.Percent
+}
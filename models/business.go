@@ -4,19 +4,22 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"gorm.io/gorm"
 )
 
 // BusinessVertical represents different business units (Solar Farm, Water Works, etc.)
 type BusinessVertical struct {
 	ID          uuid.UUID `gorm:"type:uuid;primaryKey"`
-	Name        string    `gorm:"size:100;uniqueIndex;not null"` // e.g., "Solar Farm", "Water Works"
-	Code        string    `gorm:"size:20;uniqueIndex;not null"`  // e.g., "SOLAR", "WATER"
+	Name        string    `gorm:"size:100;not null;uniqueIndex:idx_business_verticals_company_name,priority:2"` // e.g., "Solar Farm", "Water Works"
+	Code        string    `gorm:"size:20;not null;uniqueIndex:idx_business_verticals_company_code,priority:2"`  // e.g., "SOLAR", "WATER"
 	Description string    `gorm:"size:255"`
 	IsActive    bool      `gorm:"default:true;index"`
 	Settings    *string   `gorm:"type:jsonb"` // JSON field for business-specific settings
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	// Legal entity (tenant) owning the vertical; names and codes are unique within it
+	CompanyID *uuid.UUID `gorm:"type:uuid;index;uniqueIndex:idx_business_verticals_company_name,priority:1;uniqueIndex:idx_business_verticals_company_code,priority:1"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
 
 	// Relationships
 	Users         []User         `gorm:"foreignKey:BusinessVerticalID"`
@@ -34,6 +37,8 @@ type BusinessRole struct {
 	Permissions        []Permission     `gorm:"many2many:business_role_permissions;"`
 	IsActive           bool             `gorm:"default:true;index:idx_business_roles_active_level"` // composite index with level
 	Level              int              `gorm:"default:1;index:idx_business_roles_active_level"`    // composite index with is_active
+	IsReadOnly         bool             `gorm:"default:false"`                                      // holders may only read within this vertical
+	Version            int              `gorm:"default:1"`                                          // bumped on every definition change, see BusinessRoleVersion
 	CreatedAt          time.Time
 	UpdatedAt          time.Time
 
@@ -51,10 +56,65 @@ type UserBusinessRole struct {
 	IsActive       bool         `gorm:"default:true;index:idx_ubr_user_active;index:idx_ubr_role_active"`
 	AssignedAt     time.Time    `gorm:"default:CURRENT_TIMESTAMP"`
 	AssignedBy     *uuid.UUID   `gorm:"type:uuid"` // Who assigned this role
+	ValidFrom      *time.Time   // Grant takes effect at this time; nil means immediately
+	ValidUntil     *time.Time   `gorm:"index"`           // Grant expires at this time (e.g. temporary contractor access); nil means never
+	SiteID         *uuid.UUID   `gorm:"type:uuid;index"` // Limits the role to one site of the vertical; nil means the whole vertical
+	Site           *Site        `gorm:"foreignKey:SiteID"`
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
 }
 
+// IsEffectiveAt reports whether the assignment is active and inside its validity window at t.
+func (ubr UserBusinessRole) IsEffectiveAt(t time.Time) bool {
+	return ubr.IsActive && withinValidity(ubr.ValidFrom, ubr.ValidUntil, t)
+}
+
+// Role assignment audit actions
+const (
+	RoleAuditAssigned    = "assigned"
+	RoleAuditReactivated = "reactivated"
+	RoleAuditRevoked     = "revoked"
+)
+
+// RoleAssignmentAuditLog records a business role being granted to or revoked from a user.
+// Entries written by one bulk request share a BatchID.
+type RoleAssignmentAuditLog struct {
+	ID                 uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	BatchID            *uuid.UUID `gorm:"type:uuid;index" json:"batch_id,omitempty"`
+	BusinessVerticalID uuid.UUID  `gorm:"type:uuid;not null;index:idx_role_audit_vertical_time,priority:1" json:"business_vertical_id"`
+	BusinessRoleID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"business_role_id"`
+	UserID             uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Action             string     `gorm:"size:20;not null" json:"action"`
+	PerformedBy        *uuid.UUID `gorm:"type:uuid" json:"performed_by,omitempty"`
+	Reason             string     `gorm:"type:text" json:"reason,omitempty"`
+	PerformedAt        time.Time  `gorm:"not null;index:idx_role_audit_vertical_time,priority:2" json:"performed_at"`
+}
+
+// TableName specifies the table name
+func (RoleAssignmentAuditLog) TableName() string {
+	return "role_assignment_audit_logs"
+}
+
+// BusinessRoleVersion is a snapshot of a business role definition, written when the role
+// is created and on every update, so earlier permission sets can be reviewed.
+type BusinessRoleVersion struct {
+	ID             uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	BusinessRoleID uuid.UUID      `gorm:"type:uuid;not null;uniqueIndex:idx_business_role_version,priority:1" json:"business_role_id"`
+	Version        int            `gorm:"not null;uniqueIndex:idx_business_role_version,priority:2" json:"version"`
+	Name           string         `gorm:"size:50;not null" json:"name"`
+	DisplayName    string         `gorm:"size:100" json:"display_name"`
+	Description    string         `gorm:"size:255" json:"description,omitempty"`
+	Level          int            `json:"level"`
+	Permissions    pq.StringArray `gorm:"type:text[]" json:"permissions"`
+	ChangedBy      *uuid.UUID     `gorm:"type:uuid" json:"changed_by,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+// TableName specifies the table name
+func (BusinessRoleVersion) TableName() string {
+	return "business_role_versions"
+}
+
 // BusinessRolePermission junction table
 type BusinessRolePermission struct {
 	BusinessRoleID uuid.UUID `gorm:"type:uuid;primaryKey"`
@@ -3,6 +3,8 @@ package models
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -90,6 +92,7 @@ type ChatMessage struct {
 	MessageType    MessageType   `gorm:"size:20;not null;default:'text'" json:"message_type"`
 	Status         MessageStatus `gorm:"size:20;not null;default:'sent'" json:"status"`
 	ReplyToID      *uuid.UUID    `gorm:"type:uuid;index" json:"reply_to_id,omitempty"`
+	RequiresAck    bool          `gorm:"default:false" json:"requires_ack"` // broadcast every participant must acknowledge
 	Metadata       JSONMap       `gorm:"type:jsonb;default:'{}'" json:"metadata,omitempty"`
 	SentAt         *time.Time    `json:"sent_at,omitempty"`
 	DeliveredAt    *time.Time    `json:"delivered_at,omitempty"`
@@ -100,12 +103,13 @@ type ChatMessage struct {
 	DeletedAt      *time.Time    `gorm:"index" json:"deleted_at,omitempty"`
 
 	// Relationships
-	Conversation *Conversation     `gorm:"foreignKey:ConversationID" json:"conversation,omitempty"`
-	Sender       *User             `gorm:"foreignKey:SenderID" json:"sender,omitempty"`
-	ReplyTo      *ChatMessage      `gorm:"foreignKey:ReplyToID" json:"reply_to,omitempty"`
-	Attachments  []ChatAttachment  `gorm:"foreignKey:MessageID" json:"attachments,omitempty"`
-	Reactions    []ChatReaction    `gorm:"foreignKey:MessageID" json:"reactions,omitempty"`
-	ReadReceipts []ChatReadReceipt `gorm:"foreignKey:MessageID" json:"read_receipts,omitempty"`
+	Conversation *Conversation        `gorm:"foreignKey:ConversationID" json:"conversation,omitempty"`
+	Sender       *User                `gorm:"foreignKey:SenderID" json:"sender,omitempty"`
+	ReplyTo      *ChatMessage         `gorm:"foreignKey:ReplyToID" json:"reply_to,omitempty"`
+	Attachments  []ChatAttachment     `gorm:"foreignKey:MessageID" json:"attachments,omitempty"`
+	Reactions    []ChatReaction       `gorm:"foreignKey:MessageID" json:"reactions,omitempty"`
+	ReadReceipts []ChatReadReceipt    `gorm:"foreignKey:MessageID" json:"read_receipts,omitempty"`
+	Mentions     []ChatMessageMention `gorm:"foreignKey:MessageID" json:"mentions,omitempty"`
 }
 
 // TableName specifies the table name
@@ -131,6 +135,13 @@ type ChatParticipant struct {
 	CreatedAt                time.Time       `json:"created_at"`
 	UpdatedAt                time.Time       `json:"updated_at"`
 
+	// Attention read model, maintained from chat events: mentions of this user with no
+	// later reply from them, and ack-required messages they have not acknowledged.
+	NeedsAttention     bool       `gorm:"default:false;index" json:"needs_attention"`
+	PendingMentions    int        `gorm:"default:0" json:"pending_mentions"`
+	PendingAcks        int        `gorm:"default:0" json:"pending_acks"`
+	AttentionUpdatedAt *time.Time `json:"attention_updated_at,omitempty"`
+
 	// Relationships
 	Conversation *Conversation `gorm:"foreignKey:ConversationID" json:"conversation,omitempty"`
 	User         *User         `gorm:"foreignKey:UserID" json:"user,omitempty"`
@@ -207,6 +218,86 @@ func (ChatReaction) TableName() string {
 	return "chat_reactions"
 }
 
+// ChatMessageMention records a participant mentioned in a message
+type ChatMessageMention struct {
+	MessageID      uuid.UUID `gorm:"type:uuid;primaryKey" json:"message_id"`
+	UserID         string    `gorm:"size:255;primaryKey;index:idx_mention_conv_user,priority:2" json:"user_id"`
+	ConversationID uuid.UUID `gorm:"type:uuid;not null;index:idx_mention_conv_user,priority:1" json:"conversation_id"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name
+func (ChatMessageMention) TableName() string {
+	return "chat_message_mentions"
+}
+
+// ChatMessageAck records a participant acknowledging an ack-required message
+type ChatMessageAck struct {
+	MessageID      uuid.UUID `gorm:"type:uuid;primaryKey" json:"message_id"`
+	UserID         string    `gorm:"size:255;primaryKey" json:"user_id"`
+	AcknowledgedAt time.Time `json:"acknowledged_at"`
+}
+
+// TableName specifies the table name
+func (ChatMessageAck) TableName() string {
+	return "chat_message_acks"
+}
+
+// ChatCannedResponse is an admin-managed quick reply for a business vertical, such as
+// a safety acknowledgment or a standard status update. Content may contain record
+// placeholders like {{task_code}} or {{site_name}} that are filled in at send time.
+type ChatCannedResponse struct {
+	ID                 uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID  `gorm:"type:uuid;not null;index:idx_canned_vertical_active,priority:1" json:"business_vertical_id"`
+	Category           string     `gorm:"size:50;not null;default:'general'" json:"category"` // e.g. safety_ack, status_update
+	Title              string     `gorm:"size:100;not null" json:"title"`
+	Content            string     `gorm:"type:text;not null" json:"content"`
+	Language           string     `gorm:"size:10" json:"language,omitempty"`
+	SortOrder          int        `gorm:"default:0" json:"sort_order"`
+	IsActive           bool       `gorm:"default:true;index:idx_canned_vertical_active,priority:2" json:"is_active"`
+	UsageCount         int64      `gorm:"default:0" json:"usage_count"`
+	LastUsedAt         *time.Time `json:"last_used_at,omitempty"`
+	CreatedBy          string     `gorm:"size:255;not null" json:"created_by"`
+	UpdatedBy          string     `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name
+func (ChatCannedResponse) TableName() string {
+	return "chat_canned_responses"
+}
+
+var cannedPlaceholderPattern = regexp.MustCompile(`\{\{\s*([a-z_]+)\s*\}\}`)
+
+// CannedResponsePlaceholders lists the placeholders used in a canned response's content
+func CannedResponsePlaceholders(content string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, m := range cannedPlaceholderPattern.FindAllStringSubmatch(content, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// RenderCannedResponse fills placeholders from values. Placeholders without a value are
+// left in place and returned as missing so the caller can reject the message.
+func RenderCannedResponse(content string, values map[string]string) (string, []string) {
+	var missing []string
+	rendered := cannedPlaceholderPattern.ReplaceAllStringFunc(content, func(match string) string {
+		name := cannedPlaceholderPattern.FindStringSubmatch(match)[1]
+		if v, ok := values[name]; ok && v != "" {
+			return v
+		}
+		missing = append(missing, name)
+		return match
+	})
+	return rendered, missing
+}
+
 // ============================================================================
 // DTOs (Data Transfer Objects)
 // ============================================================================
@@ -227,6 +318,9 @@ type ConversationDTO struct {
 	CreatedBy        string                 `json:"created_by"`
 	CreatedAt        time.Time              `json:"created_at"`
 	UnreadCount      int                    `json:"unread_count,omitempty"`
+	NeedsAttention   bool                   `json:"needs_attention"`
+	PendingMentions  int                    `json:"pending_mentions,omitempty"`
+	PendingAcks      int                    `json:"pending_acks,omitempty"`
 	LastMessage      *MessageDTO            `json:"last_message,omitempty"`
 	Participants     []ParticipantDTO       `json:"participants,omitempty"`
 	OtherParticipant *ParticipantDTO        `json:"other_participant,omitempty"` // For direct conversations - the other user
@@ -270,6 +364,15 @@ func (c *Conversation) ToDTO() ConversationDTO {
 func (c *Conversation) ToDTOForUser(currentUserID string) ConversationDTO {
 	dto := c.ToDTO()
 
+	for _, p := range c.Participants {
+		if p.UserID == currentUserID {
+			dto.NeedsAttention = p.NeedsAttention
+			dto.PendingMentions = p.PendingMentions
+			dto.PendingAcks = p.PendingAcks
+			break
+		}
+	}
+
 	// For direct conversations, find and set the other participant
 	if c.Type == ConversationTypeDirect && len(c.Participants) > 0 {
 		for _, p := range c.Participants {
@@ -301,6 +404,8 @@ type MessageDTO struct {
 	MessageType     MessageType            `json:"message_type"`
 	Status          MessageStatus          `json:"status"`
 	ReplyToID       *uuid.UUID             `json:"reply_to_id,omitempty"`
+	RequiresAck     bool                   `json:"requires_ack"`
+	MentionedUsers  []string               `json:"mentioned_user_ids,omitempty"`
 	Metadata        map[string]interface{} `json:"metadata,omitempty"`
 	SentAt          *time.Time             `json:"sent_at,omitempty"`
 	DeliveredAt     *time.Time             `json:"delivered_at,omitempty"`
@@ -308,6 +413,7 @@ type MessageDTO struct {
 	EditedAt        *time.Time             `json:"edited_at,omitempty"`
 	CreatedAt       time.Time              `json:"created_at"`
 	Attachments     []AttachmentDTO        `json:"attachments,omitempty"`
+	Transcript      string                 `json:"transcript,omitempty"` // text of the message's transcribed voice notes
 	Reactions       []ReactionSummaryDTO   `json:"reactions,omitempty"`
 	ReadCount       int                    `json:"read_count,omitempty"`
 }
@@ -322,6 +428,7 @@ func (m *ChatMessage) ToDTO() MessageDTO {
 		MessageType:    m.MessageType,
 		Status:         m.Status,
 		ReplyToID:      m.ReplyToID,
+		RequiresAck:    m.RequiresAck,
 		Metadata:       m.Metadata,
 		SentAt:         m.SentAt,
 		DeliveredAt:    m.DeliveredAt,
@@ -338,9 +445,14 @@ func (m *ChatMessage) ToDTO() MessageDTO {
 
 	if len(m.Attachments) > 0 {
 		dto.Attachments = make([]AttachmentDTO, len(m.Attachments))
+		var transcripts []string
 		for i, a := range m.Attachments {
 			dto.Attachments[i] = a.ToDTO()
+			if dto.Attachments[i].Transcript != "" {
+				transcripts = append(transcripts, dto.Attachments[i].Transcript)
+			}
 		}
+		dto.Transcript = strings.Join(transcripts, "\n")
 	}
 
 	// Group reactions by emoji
@@ -360,6 +472,10 @@ func (m *ChatMessage) ToDTO() MessageDTO {
 
 	dto.ReadCount = len(m.ReadReceipts)
 
+	for _, mention := range m.Mentions {
+		dto.MentionedUsers = append(dto.MentionedUsers, mention.UserID)
+	}
+
 	return dto
 }
 
@@ -404,20 +520,26 @@ func (p *ChatParticipant) ToDTO() ParticipantDTO {
 
 // AttachmentDTO represents the API response format for an attachment
 type AttachmentDTO struct {
-	ID           uuid.UUID              `json:"id"`
-	MessageID    uuid.UUID              `json:"message_id"`
-	DMSFileID    *string                `json:"dms_file_id,omitempty"`
-	DMSFileURL   *string                `json:"dms_file_url,omitempty"`
-	FileName     string                 `json:"file_name"`
-	FileSize     int64                  `json:"file_size"`
-	MimeType     string                 `json:"mime_type"`
-	ThumbnailURL *string                `json:"thumbnail_url,omitempty"`
-	Metadata     map[string]interface{} `json:"metadata,omitempty"`
-	CreatedAt    time.Time              `json:"created_at"`
+	ID                  uuid.UUID              `json:"id"`
+	MessageID           uuid.UUID              `json:"message_id"`
+	DMSFileID           *string                `json:"dms_file_id,omitempty"`
+	DMSFileURL          *string                `json:"dms_file_url,omitempty"`
+	FileName            string                 `json:"file_name"`
+	FileSize            int64                  `json:"file_size"`
+	MimeType            string                 `json:"mime_type"`
+	ThumbnailURL        *string                `json:"thumbnail_url,omitempty"`
+	Metadata            map[string]interface{} `json:"metadata,omitempty"`
+	CreatedAt           time.Time              `json:"created_at"`
+	Transcript          string                 `json:"transcript,omitempty"`           // voice notes only
+	TranscriptLanguage  string                 `json:"transcript_language,omitempty"`  // kn, hi or en
+	TranscriptionStatus string                 `json:"transcription_status,omitempty"` // pending, completed or failed
 }
 
 // ToDTO converts ChatAttachment to AttachmentDTO
 func (a *ChatAttachment) ToDTO() AttachmentDTO {
+	transcript, _ := a.Metadata["transcript"].(string)
+	language, _ := a.Metadata["transcript_language"].(string)
+	status, _ := a.Metadata["transcription_status"].(string)
 	return AttachmentDTO{
 		ID:           a.ID,
 		MessageID:    a.MessageID,
@@ -429,6 +551,10 @@ func (a *ChatAttachment) ToDTO() AttachmentDTO {
 		ThumbnailURL: a.ThumbnailURL,
 		Metadata:     a.Metadata,
 		CreatedAt:    a.CreatedAt,
+
+		Transcript:          transcript,
+		TranscriptLanguage:  language,
+		TranscriptionStatus: status,
 	}
 }
 
@@ -495,10 +621,19 @@ type CreateGroupRequest struct {
 
 // SendMessageRequest represents the request to send a message
 type SendMessageRequest struct {
-	Content     string                 `json:"content" validate:"required"`
-	MessageType MessageType            `json:"message_type,omitempty"`
-	ReplyToID   *uuid.UUID             `json:"reply_to_id,omitempty"`
-	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	Content          string                 `json:"content" validate:"required"`
+	MessageType      MessageType            `json:"message_type,omitempty"`
+	ReplyToID        *uuid.UUID             `json:"reply_to_id,omitempty"`
+	Metadata         map[string]interface{} `json:"metadata,omitempty"`
+	MentionedUserIDs []string               `json:"mentioned_user_ids,omitempty"`
+	RequiresAck      bool                   `json:"requires_ack,omitempty"` // owner/admin/moderator only
+
+	// Canned response to send instead of Content; its placeholders are filled from the
+	// referenced records and PlaceholderValues.
+	CannedResponseID  *uuid.UUID        `json:"canned_response_id,omitempty"`
+	TaskID            *uuid.UUID        `json:"task_id,omitempty"`
+	SiteID            *uuid.UUID        `json:"site_id,omitempty"`
+	PlaceholderValues map[string]string `json:"placeholder_values,omitempty"`
 }
 
 // UpdateMessageRequest represents the request to update a message
@@ -515,6 +650,16 @@ type UpdateConversationRequest struct {
 	MaxParticipants *int                   `json:"max_participants,omitempty"`
 }
 
+// CannedResponseRequest represents the request to create or update a canned response
+type CannedResponseRequest struct {
+	Category  string `json:"category,omitempty"`
+	Title     string `json:"title" validate:"required,max=100"`
+	Content   string `json:"content" validate:"required"`
+	Language  string `json:"language,omitempty"`
+	SortOrder int    `json:"sort_order,omitempty"`
+	IsActive  *bool  `json:"is_active,omitempty"`
+}
+
 // AddParticipantRequest represents the request to add a participant
 type AddParticipantRequest struct {
 	UserID string          `json:"user_id" validate:"required"`
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRenderCannedResponse(t *testing.T) {
+	content := "Work on {{task_code}} at {{ site_name }} is complete. {{task_code}} ready for QC."
+
+	got, missing := RenderCannedResponse(content, map[string]string{
+		"task_code": "T-104",
+		"site_name": "Solar Site 1",
+	})
+	if len(missing) != 0 {
+		t.Fatalf("unexpected missing placeholders: %v", missing)
+	}
+	want := "Work on T-104 at Solar Site 1 is complete. T-104 ready for QC."
+	if got != want {
+		t.Errorf("RenderCannedResponse() = %q, want %q", got, want)
+	}
+
+	got, missing = RenderCannedResponse(content, map[string]string{"task_code": "T-104", "site_name": ""})
+	if !reflect.DeepEqual(missing, []string{"site_name"}) {
+		t.Errorf("missing = %v, want [site_name]", missing)
+	}
+	if got != "Work on T-104 at {{ site_name }} is complete. T-104 ready for QC." {
+		t.Errorf("unresolved placeholder should be left in place, got %q", got)
+	}
+}
+
+func TestCannedResponsePlaceholders(t *testing.T) {
+	got := CannedResponsePlaceholders("{{task_code}} {{site_name}} {{task_code}} {{Not_Valid}}")
+	if !reflect.DeepEqual(got, []string{"task_code", "site_name"}) {
+		t.Errorf("CannedResponsePlaceholders() = %v", got)
+	}
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DefaultCompanyCode is the company existing data and seeding belong to unless told otherwise
+const DefaultCompanyCode = "DEFAULT"
+
+// Company is a legal entity (tenant) sharing the deployment. Business verticals and users
+// belong to exactly one company and never see another company's data.
+type Company struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	Name      string    `gorm:"size:150;not null" json:"name"`
+	Code      string    `gorm:"size:20;uniqueIndex;not null" json:"code"`
+	LegalName string    `gorm:"size:255" json:"legal_name"`
+	TaxID     string    `gorm:"size:50" json:"tax_id,omitempty"` // e.g. GSTIN/PAN
+	IsActive  bool      `gorm:"default:true;index" json:"is_active"`
+	Settings  *string   `gorm:"type:jsonb" json:"settings,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (c *Company) BeforeCreate(tx *gorm.DB) (err error) {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return
+}
+
+// ForCompany scopes a query on a table with a company_id column to one company. A nil
+// company leaves the query unscoped (platform-wide callers).
+func ForCompany(companyID uuid.UUID) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if companyID == uuid.Nil {
+			return db
+		}
+		return db.Where("company_id = ?", companyID)
+	}
+}
+
+// ForCompanyVerticals scopes a query on a table keyed by business vertical (column, e.g.
+// "business_vertical_id" or "p.business_vertical_id") to the verticals of one company.
+func ForCompanyVerticals(companyID uuid.UUID, column string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if companyID == uuid.Nil {
+			return db
+		}
+		return db.Where(column+" IN (SELECT id FROM business_verticals WHERE company_id = ?)", companyID)
+	}
+}
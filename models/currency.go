@@ -0,0 +1,101 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BaseCurrency is the currency amounts are reported and posted to the ledger in.
+// Transactions in other currencies keep their own amount and the rate they were
+// converted at, with the base amount alongside.
+const BaseCurrency = "INR"
+
+// Exchange rate sources: entered by hand, or fetched from the provider named in the source
+const ExchangeRateManual = "manual"
+
+var currencyCodePattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ValidCurrencyCode reports whether code is an ISO 4217 style code, e.g. USD
+func ValidCurrencyCode(code string) bool {
+	return currencyCodePattern.MatchString(code)
+}
+
+// Currency is a currency transactions may be recorded in
+type Currency struct {
+	Code          string    `gorm:"size:3;primaryKey" json:"code"`
+	Name          string    `gorm:"size:100;not null" json:"name"`
+	Symbol        string    `gorm:"size:8" json:"symbol,omitempty"`
+	DecimalPlaces int       `gorm:"default:2" json:"decimal_places"`
+	IsActive      bool      `gorm:"default:true;index" json:"is_active"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for Currency
+func (Currency) TableName() string {
+	return "currencies"
+}
+
+// ExchangeRate is what one unit of a currency was worth in the base currency on a day.
+// A transaction is converted at the latest rate on or before its date.
+type ExchangeRate struct {
+	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	CurrencyCode string    `gorm:"size:3;not null;index" json:"currency_code"`
+	RateDate     time.Time `gorm:"type:date;not null;index" json:"rate_date"`
+	Rate         float64   `gorm:"type:decimal(18,8);not null" json:"rate"`
+	Source       string    `gorm:"size:32;not null;default:'manual'" json:"source"`
+	CreatedBy    string    `gorm:"size:255" json:"created_by,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for ExchangeRate
+func (ExchangeRate) TableName() string {
+	return "exchange_rates"
+}
+
+// Validate checks the rate can be recorded
+func (r ExchangeRate) Validate() error {
+	if !ValidCurrencyCode(r.CurrencyCode) {
+		return errors.New("currency_code must be a three letter code")
+	}
+	if r.CurrencyCode == BaseCurrency {
+		return errors.New("the base currency has no exchange rate")
+	}
+	if r.Rate <= 0 || math.IsInf(r.Rate, 0) || math.IsNaN(r.Rate) {
+		return errors.New("rate must be positive")
+	}
+	if r.RateDate.IsZero() {
+		return errors.New("rate_date is required")
+	}
+	return nil
+}
+
+// ToBase converts an amount at a rate to the base currency, rounded to paise
+func ToBase(amount, rate float64) float64 {
+	return Round2(amount * rate)
+}
+
+// Round2 rounds a value to two decimals, e.g. an amount to paise
+func Round2(v float64) float64 {
+	return Round(v, 2)
+}
+
+// Round rounds a value to the given number of decimals
+func Round(v float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(v*scale) / scale
+}
+
+// ConvertAmount converts an amount from one currency to another through the base
+// currency, given each one's rate to it
+func ConvertAmount(amount, fromRate, toRate float64) float64 {
+	if toRate <= 0 {
+		return 0
+	}
+	return math.Round(amount*fromRate/toRate*100) / 100
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExchangeRateValidate(t *testing.T) {
+	day := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		rate ExchangeRate
+		ok   bool
+	}{
+		{ExchangeRate{CurrencyCode: "USD", RateDate: day, Rate: 83.25}, true},
+		{ExchangeRate{CurrencyCode: "usd", RateDate: day, Rate: 83.25}, false},
+		{ExchangeRate{CurrencyCode: BaseCurrency, RateDate: day, Rate: 1}, false},
+		{ExchangeRate{CurrencyCode: "EUR", RateDate: day, Rate: 0}, false},
+		{ExchangeRate{CurrencyCode: "EUR", Rate: 90}, false},
+	}
+	for i, c := range cases {
+		if err := c.rate.Validate(); (err == nil) != c.ok {
+			t.Errorf("case %d: expected ok=%v, got %v", i, c.ok, err)
+		}
+	}
+}
+
+func TestConvertAmount(t *testing.T) {
+	if got := ToBase(1250.50, 83.2); got != 104041.6 {
+		t.Errorf("ToBase = %.2f, want 104041.60", got)
+	}
+	// 1000 USD at 83 into EUR at 90
+	if got := ConvertAmount(1000, 83, 90); got != 922.22 {
+		t.Errorf("ConvertAmount = %.2f, want 922.22", got)
+	}
+	if got := ConvertAmount(500, 1, 1); got != 500 {
+		t.Errorf("ConvertAmount between base amounts = %.2f, want 500", got)
+	}
+}
@@ -3,6 +3,8 @@ package models
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -139,6 +141,11 @@ type Document struct {
 	Project            *Project            `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
 	TaskID             *uuid.UUID          `gorm:"type:uuid;index" json:"task_id"`
 	Task               *Tasks              `gorm:"foreignKey:TaskID" json:"task,omitempty"`
+	FolderID           *uuid.UUID          `gorm:"type:uuid;index" json:"folder_id"`
+	Folder             *DocumentFolder     `gorm:"foreignKey:FolderID" json:"folder,omitempty"`
+	SiteID             *uuid.UUID          `gorm:"type:uuid;index" json:"site_id"`
+	EntityType         string              `gorm:"size:30;index:idx_documents_entity,priority:1" json:"entity_type,omitempty"` // Linked record beyond project/task, see DocumentEntityTables
+	EntityID           *uuid.UUID          `gorm:"type:uuid;index:idx_documents_entity,priority:2" json:"entity_id,omitempty"`
 	UploadedByID       uuid.UUID           `gorm:"type:uuid;not null" json:"uploaded_by_id"`
 	UploadedBy         *User               `gorm:"foreignKey:UploadedByID" json:"uploaded_by,omitempty"`
 	WorkflowID         *uuid.UUID          `gorm:"type:uuid" json:"workflow_id"`
@@ -148,9 +155,17 @@ type Document struct {
 	IsPublic           bool                `gorm:"default:false" json:"is_public"`
 	DownloadCount      int                 `gorm:"default:0" json:"download_count"`
 	ViewCount          int                 `gorm:"default:0" json:"view_count"`
-	CreatedAt          time.Time           `json:"created_at"`
-	UpdatedAt          time.Time           `json:"updated_at"`
-	DeletedAt          gorm.DeletedAt      `gorm:"index" json:"deleted_at,omitempty"`
+	// Text extracted from the file for full-text search; the generated search_vector
+	// column indexes it along with the title and description
+	ContentText     string         `gorm:"type:text" json:"-"`
+	TextStatus      string         `gorm:"size:20;not null;default:'pending';index" json:"text_status"`
+	TextMethod      string         `gorm:"size:20" json:"text_method,omitempty"`
+	TextError       string         `gorm:"size:500" json:"text_error,omitempty"`
+	TextClaimedAt   *time.Time     `json:"-"`
+	TextExtractedAt *time.Time     `json:"text_extracted_at,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
 
 	// Relationships
 	Versions    []DocumentVersion    `gorm:"foreignKey:DocumentID" json:"versions,omitempty"`
@@ -164,6 +179,86 @@ func (d *Document) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
+// Document text extraction states
+const (
+	DocumentTextPending     = "pending"     // waiting for the indexer
+	DocumentTextIndexed     = "indexed"     // content_text holds the file's text
+	DocumentTextUnsupported = "unsupported" // no text can be read from this file type
+	DocumentTextFailed      = "failed"      // extraction errored; see text_error
+)
+
+// DocumentEntityTables maps the record types a document can be linked to, besides its
+// project and task, to their tables. Each table is scoped by business_vertical_id.
+var DocumentEntityTables = map[string]string{
+	"asset":              "assets",
+	"maintenance_ticket": "maintenance_tasks",
+	"form_submission":    "form_submissions",
+	"purchase_order":     "purchase_orders",
+	"employee":           "employees",
+}
+
+// DocumentFolder organizes a business vertical's documents into a tree
+type DocumentFolder struct {
+	ID                 uuid.UUID       `gorm:"type:uuid;primaryKey" json:"id"`
+	Name               string          `gorm:"size:255;not null" json:"name"`
+	ParentID           *uuid.UUID      `gorm:"type:uuid;index" json:"parent_id"`
+	Parent             *DocumentFolder `gorm:"foreignKey:ParentID" json:"parent,omitempty"`
+	BusinessVerticalID uuid.UUID       `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	CreatedByID        uuid.UUID       `gorm:"type:uuid;not null" json:"created_by_id"`
+	CreatedAt          time.Time       `json:"created_at"`
+	UpdatedAt          time.Time       `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt  `gorm:"index" json:"deleted_at,omitempty"`
+}
+
+func (df *DocumentFolder) BeforeCreate(tx *gorm.DB) (err error) {
+	df.ID = uuid.New()
+	return
+}
+
+// Validate trims the folder name and checks it can be shown as one path segment
+func (df *DocumentFolder) Validate() error {
+	df.Name = strings.TrimSpace(df.Name)
+	switch {
+	case df.Name == "":
+		return errors.New("folder name is required")
+	case len(df.Name) > 255:
+		return errors.New("folder name must be at most 255 characters")
+	case strings.ContainsAny(df.Name, "/\\"), df.Name == ".", df.Name == "..":
+		return errors.New("folder name must not contain slashes or be . or ..")
+	}
+	return nil
+}
+
+// DocumentUpload is a presigned upload handed to a client: the object key it may PUT to
+// until ExpiresAt, and the document created from it once the client completes it
+type DocumentUpload struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	ObjectKey    string     `gorm:"size:500;not null;uniqueIndex" json:"object_key"`
+	FileName     string     `gorm:"size:255;not null" json:"file_name"`
+	FileType     string     `gorm:"size:100;not null" json:"file_type"`
+	UploadedByID uuid.UUID  `gorm:"type:uuid;not null;index" json:"uploaded_by_id"`
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`
+	DocumentID   *uuid.UUID `gorm:"type:uuid" json:"document_id,omitempty"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+func (du *DocumentUpload) BeforeCreate(tx *gorm.DB) (err error) {
+	du.ID = uuid.New()
+	return
+}
+
+// Usable reports why the upload can no longer be completed, or nil when it can
+func (du *DocumentUpload) Usable(now time.Time) error {
+	if du.CompletedAt != nil {
+		return errors.New("upload already completed")
+	}
+	if now.After(du.ExpiresAt) {
+		return errors.New("upload has expired")
+	}
+	return nil
+}
+
 // DocumentVersion represents a version of a document
 type DocumentVersion struct {
 	ID               uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestDocumentFolderValidate(t *testing.T) {
+	f := DocumentFolder{Name: "  Drawings  "}
+	if err := f.Validate(); err != nil {
+		t.Fatalf("valid folder rejected: %v", err)
+	}
+	if f.Name != "Drawings" {
+		t.Errorf("name not trimmed: %q", f.Name)
+	}
+
+	for _, name := range []string{"", "   ", "a/b", `a\b`, ".", ".."} {
+		bad := DocumentFolder{Name: name}
+		if err := bad.Validate(); err == nil {
+			t.Errorf("folder name %q accepted", name)
+		}
+	}
+}
+
+func TestDocumentUploadUsable(t *testing.T) {
+	now := time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)
+	u := DocumentUpload{ExpiresAt: now.Add(15 * time.Minute)}
+	if err := u.Usable(now); err != nil {
+		t.Fatalf("fresh upload unusable: %v", err)
+	}
+	if err := u.Usable(now.Add(16 * time.Minute)); err == nil {
+		t.Error("expired upload usable")
+	}
+
+	docID := uuid.New()
+	u.DocumentID, u.CompletedAt = &docID, &now
+	if err := u.Usable(now); err == nil {
+		t.Error("completed upload usable twice")
+	}
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Emergency broadcast delivery channels. Voice calls are only placed to recipients who
+// have not acknowledged by the time an escalation round runs.
+const (
+	EmergencyChannelPush  = "push"
+	EmergencyChannelSMS   = "sms"
+	EmergencyChannelChat  = "chat"
+	EmergencyChannelVoice = "voice"
+)
+
+// DefaultEmergencyChannels are used when a broadcast does not name its channels
+var DefaultEmergencyChannels = []string{EmergencyChannelPush, EmergencyChannelSMS, EmergencyChannelChat, EmergencyChannelVoice}
+
+// Emergency broadcast statuses
+const (
+	EmergencyBroadcastActive = "active"
+	EmergencyBroadcastClosed = "closed"
+)
+
+// EmergencyBroadcast is a safety alert sent to everyone at a site. Every recipient must
+// acknowledge it; unacknowledged recipients are re-alerted every EscalateAfterMinutes
+// until MaxEscalations rounds have run or the broadcast is closed.
+type EmergencyBroadcast struct {
+	ID                   uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID   uuid.UUID      `gorm:"type:uuid;not null;index:idx_emergency_broadcasts_vertical_status,priority:1" json:"business_vertical_id"`
+	SiteID               uuid.UUID      `gorm:"type:uuid;not null;index" json:"site_id"`
+	Title                string         `gorm:"size:200;not null" json:"title"`
+	Message              string         `gorm:"type:text;not null" json:"message"`
+	Severity             string         `gorm:"size:20;not null;default:'critical'" json:"severity"`
+	Channels             pq.StringArray `gorm:"type:text[]" json:"channels"`
+	ConversationID       *uuid.UUID     `gorm:"type:uuid" json:"conversation_id,omitempty"`
+	ChatMessageID        *uuid.UUID     `gorm:"type:uuid;index" json:"chat_message_id,omitempty"`
+	EscalateAfterMinutes int            `gorm:"not null;default:5" json:"escalate_after_minutes"`
+	MaxEscalations       int            `gorm:"not null;default:3" json:"max_escalations"`
+	Status               string         `gorm:"size:20;not null;default:'active';index:idx_emergency_broadcasts_vertical_status,priority:2" json:"status"`
+	CreatedBy            uuid.UUID      `gorm:"type:uuid;not null" json:"created_by"`
+	CreatedAt            time.Time      `json:"created_at"`
+	ClosedAt             *time.Time     `json:"closed_at,omitempty"`
+	ClosedBy             *uuid.UUID     `gorm:"type:uuid" json:"closed_by,omitempty"`
+
+	Site *Site `gorm:"foreignKey:SiteID" json:"site,omitempty"`
+}
+
+func (EmergencyBroadcast) TableName() string {
+	return "emergency_broadcasts"
+}
+
+// HasChannel reports whether the broadcast is delivered over channel
+func (b *EmergencyBroadcast) HasChannel(channel string) bool {
+	for _, c := range b.Channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// EmergencyBroadcastRecipient tracks delivery, escalation and acknowledgment of a
+// broadcast for one user.
+type EmergencyBroadcastRecipient struct {
+	ID               uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	BroadcastID      uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_emergency_recipient,priority:1" json:"broadcast_id"`
+	UserID           uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_emergency_recipient,priority:2;index" json:"user_id"`
+	Name             string     `gorm:"size:100" json:"name"`
+	Phone            string     `gorm:"size:15" json:"phone,omitempty"`
+	PushSentAt       *time.Time `json:"push_sent_at,omitempty"`
+	SMSSentAt        *time.Time `gorm:"column:sms_sent_at" json:"sms_sent_at,omitempty"`
+	VoiceCalledAt    *time.Time `json:"voice_called_at,omitempty"`
+	DeliveryError    string     `gorm:"type:text" json:"delivery_error,omitempty"`
+	EscalationLevel  int        `gorm:"not null;default:0" json:"escalation_level"`
+	NextEscalationAt *time.Time `gorm:"index" json:"next_escalation_at,omitempty"`
+	AcknowledgedAt   *time.Time `json:"acknowledged_at,omitempty"`
+	AckChannel       string     `gorm:"size:20" json:"ack_channel,omitempty"` // app, chat, or how an admin confirmed it (phone, in_person)
+	AckNote          string     `gorm:"type:text" json:"ack_note,omitempty"`
+	AckRecordedBy    *uuid.UUID `gorm:"type:uuid" json:"ack_recorded_by,omitempty"` // set when an admin recorded the acknowledgment
+}
+
+func (EmergencyBroadcastRecipient) TableName() string {
+	return "emergency_broadcast_recipients"
+}
+
+// NormalizeEmergencyChannels lower-cases and de-duplicates the requested channels,
+// falling back to DefaultEmergencyChannels when none are given.
+func NormalizeEmergencyChannels(requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return append([]string(nil), DefaultEmergencyChannels...), nil
+	}
+	seen := make(map[string]bool, len(requested))
+	channels := make([]string, 0, len(requested))
+	for _, raw := range requested {
+		channel := strings.ToLower(strings.TrimSpace(raw))
+		switch channel {
+		case EmergencyChannelPush, EmergencyChannelSMS, EmergencyChannelChat, EmergencyChannelVoice:
+		default:
+			return nil, fmt.Errorf("unknown channel %q", raw)
+		}
+		if !seen[channel] {
+			seen[channel] = true
+			channels = append(channels, channel)
+		}
+	}
+	return channels, nil
+}
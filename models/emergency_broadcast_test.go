@@ -0,0 +1,25 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeEmergencyChannels(t *testing.T) {
+	got, err := NormalizeEmergencyChannels(nil)
+	if err != nil || !reflect.DeepEqual(got, DefaultEmergencyChannels) {
+		t.Fatalf("NormalizeEmergencyChannels(nil) = %v, %v", got, err)
+	}
+
+	got, err = NormalizeEmergencyChannels([]string{" SMS", "push", "sms"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"sms", "push"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, err := NormalizeEmergencyChannels([]string{"pager"}); err == nil {
+		t.Error("expected an error for an unknown channel")
+	}
+}
@@ -0,0 +1,265 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Employee statuses. An employee is onboarded, active, then offboarded before they exit;
+// each move out of onboarding and offboarding needs its checklist complete.
+const (
+	EmployeeOnboarding  = "onboarding"
+	EmployeeActive      = "active"
+	EmployeeOffboarding = "offboarding"
+	EmployeeExited      = "exited"
+)
+
+// Employment types
+const (
+	EmploymentPermanent = "permanent"
+	EmploymentProbation = "probation"
+	EmploymentContract  = "contract"
+	EmploymentIntern    = "intern"
+)
+
+// Checklist kinds
+const (
+	ChecklistOnboarding  = "onboarding"
+	ChecklistOffboarding = "offboarding"
+)
+
+var uanPattern = regexp.MustCompile(`^[0-9]{12}$`)
+
+// DefaultOnboardingChecklist is the onboarding checklist of businesses that have not set
+// their own
+var DefaultOnboardingChecklist = StringArray{
+	"Collect signed offer letter",
+	"Collect identity and address proof",
+	"Collect PAN and bank details",
+	"Create user account and assign business role",
+	"Set up salary structure",
+	"Issue ID card and safety equipment",
+	"Complete induction and safety training",
+}
+
+// DefaultOffboardingChecklist is the offboarding checklist of businesses that have not set
+// their own
+var DefaultOffboardingChecklist = StringArray{
+	"Accept resignation or issue termination letter",
+	"Hand over work and site responsibilities",
+	"Return assets, ID card and safety equipment",
+	"Settle expense claims and advances",
+	"Process full and final settlement",
+	"Issue relieving and experience letters",
+}
+
+// EmergencyContact is someone to call for an employee
+type EmergencyContact struct {
+	Name         string `json:"name"`
+	Relationship string `json:"relationship"`
+	Phone        string `json:"phone"`
+}
+
+// EmergencyContacts is a JSONB list of emergency contacts
+type EmergencyContacts []EmergencyContact
+
+// Scan implements the sql.Scanner interface
+func (ec *EmergencyContacts) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		*ec = EmergencyContacts{}
+		return nil
+	}
+	return json.Unmarshal(bytes, ec)
+}
+
+// Value implements the driver.Valuer interface
+func (ec EmergencyContacts) Value() (driver.Value, error) {
+	if ec == nil {
+		return json.Marshal([]EmergencyContact{})
+	}
+	return json.Marshal([]EmergencyContact(ec))
+}
+
+// Employee is a person employed in a business vertical: their HR record, linked to the
+// user account they sign in with, if they have one
+type Employee struct {
+	ID                 uuid.UUID         `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID         `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	UserID             *uuid.UUID        `gorm:"type:uuid;index" json:"user_id,omitempty"`
+	EmployeeCode       string            `gorm:"size:64;not null" json:"employee_code"`
+	FullName           string            `gorm:"size:255;not null" json:"full_name"`
+	Email              string            `gorm:"size:255" json:"email,omitempty"`
+	Phone              string            `gorm:"size:32" json:"phone,omitempty"`
+	Designation        string            `gorm:"size:100" json:"designation,omitempty"`
+	Department         string            `gorm:"size:100;index" json:"department,omitempty"`
+	EmploymentType     string            `gorm:"size:20;not null;default:'permanent'" json:"employment_type"`
+	DateOfJoining      time.Time         `gorm:"type:date;not null" json:"date_of_joining"`
+	DateOfBirth        *time.Time        `gorm:"type:date" json:"date_of_birth,omitempty"`
+	ReportingManagerID *uuid.UUID        `gorm:"type:uuid;index" json:"reporting_manager_id,omitempty"` // an Employee
+	SiteID             *uuid.UUID        `gorm:"type:uuid;index" json:"site_id,omitempty"`              // where they mostly work
+	CostCenterID       *uuid.UUID        `gorm:"type:uuid;index" json:"cost_center_id,omitempty"`       // their pay is booked to, if not their site's
+	ProjectID          *uuid.UUID        `gorm:"type:uuid;index" json:"project_id,omitempty"`           // they are deployed on
+	Address            string            `gorm:"type:text" json:"address,omitempty"`
+	EmergencyContacts  EmergencyContacts `gorm:"type:jsonb;not null;default:'[]'" json:"emergency_contacts"`
+	PAN                string            `gorm:"size:20" json:"pan,omitempty"`
+	UAN                string            `gorm:"size:12" json:"uan,omitempty"` // PF universal account number
+	BankAccountName    string            `gorm:"size:255" json:"bank_account_name,omitempty"`
+	BankAccountNumber  string            `gorm:"size:34" json:"bank_account_number,omitempty"`
+	BankIFSC           string            `gorm:"size:11" json:"bank_ifsc,omitempty"`
+	BankName           string            `gorm:"size:255" json:"bank_name,omitempty"`
+	Status             string            `gorm:"size:20;not null;default:'onboarding';index" json:"status"`
+	ExitDate           *time.Time        `gorm:"type:date" json:"exit_date,omitempty"`
+	ExitReason         string            `gorm:"type:text" json:"exit_reason,omitempty"`
+	CreatedBy          string            `gorm:"size:255;not null" json:"created_by"`
+	UpdatedBy          string            `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt          time.Time         `json:"created_at"`
+	UpdatedAt          time.Time         `json:"updated_at"`
+	DeletedAt          *time.Time        `gorm:"index" json:"deleted_at,omitempty"`
+}
+
+// TableName specifies the table name for Employee
+func (Employee) TableName() string {
+	return "employees"
+}
+
+// Normalize trims the employee's details and upper-cases their tax and bank codes
+func (e *Employee) Normalize() {
+	e.EmployeeCode = strings.ToUpper(strings.TrimSpace(e.EmployeeCode))
+	e.FullName = strings.TrimSpace(e.FullName)
+	e.Email = strings.ToLower(strings.TrimSpace(e.Email))
+	e.Phone = strings.TrimSpace(e.Phone)
+	e.Designation = strings.TrimSpace(e.Designation)
+	e.Department = strings.TrimSpace(e.Department)
+	e.PAN = strings.ToUpper(strings.TrimSpace(e.PAN))
+	e.UAN = strings.TrimSpace(e.UAN)
+	e.BankIFSC = strings.ToUpper(strings.TrimSpace(e.BankIFSC))
+	e.BankAccountNumber = strings.ReplaceAll(strings.TrimSpace(e.BankAccountNumber), " ", "")
+	if e.EmploymentType == "" {
+		e.EmploymentType = EmploymentPermanent
+	}
+	if e.EmergencyContacts == nil {
+		e.EmergencyContacts = EmergencyContacts{}
+	}
+}
+
+// Validate checks the employee's required fields and the format of their PAN, UAN and
+// IFSC
+func (e *Employee) Validate() error {
+	switch {
+	case e.EmployeeCode == "" || e.FullName == "" || e.DateOfJoining.IsZero():
+		return fmt.Errorf("employee_code, full_name and date_of_joining are required")
+	case e.EmploymentType != EmploymentPermanent && e.EmploymentType != EmploymentProbation &&
+		e.EmploymentType != EmploymentContract && e.EmploymentType != EmploymentIntern:
+		return fmt.Errorf("employment_type must be permanent, probation, contract or intern")
+	case e.DateOfBirth != nil && !e.DateOfBirth.Before(e.DateOfJoining):
+		return fmt.Errorf("date_of_birth must be before date_of_joining")
+	case e.PAN != "" && !panPattern.MatchString(e.PAN):
+		return fmt.Errorf("pan %q is not a valid PAN", e.PAN)
+	case e.UAN != "" && !uanPattern.MatchString(e.UAN):
+		return fmt.Errorf("uan %q is not a 12-digit UAN", e.UAN)
+	case e.BankIFSC != "" && !ifscPattern.MatchString(e.BankIFSC):
+		return fmt.Errorf("bank_ifsc %q is not a valid IFSC", e.BankIFSC)
+	case e.BankAccountNumber != "" && e.BankIFSC == "":
+		return fmt.Errorf("bank_ifsc is required with a bank account number")
+	case e.ReportingManagerID != nil && *e.ReportingManagerID == e.ID:
+		return fmt.Errorf("an employee cannot report to themselves")
+	}
+	for i, c := range e.EmergencyContacts {
+		if strings.TrimSpace(c.Name) == "" || strings.TrimSpace(c.Phone) == "" {
+			return fmt.Errorf("emergency contact %d needs a name and a phone", i+1)
+		}
+	}
+	return nil
+}
+
+// MaskBankAccount hides all but the last four digits of the bank account number, for
+// people who may see an employee's profile but not change it
+func (e *Employee) MaskBankAccount() {
+	if n := len(e.BankAccountNumber); n > 4 {
+		e.BankAccountNumber = strings.Repeat("X", n-4) + e.BankAccountNumber[n-4:]
+	}
+}
+
+// EmployeeDocument is a DMS document held on an employee's file, such as their ID proof
+// or offer letter
+type EmployeeDocument struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	EmployeeID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"employee_id"`
+	DocumentType string     `gorm:"size:50;not null" json:"document_type"` // id_proof, address_proof, pan, offer_letter, ...
+	DocumentID   uuid.UUID  `gorm:"type:uuid;not null" json:"document_id"`
+	ExpiresOn    *time.Time `gorm:"type:date" json:"expires_on,omitempty"`
+	Remarks      string     `gorm:"type:text" json:"remarks,omitempty"`
+	AddedBy      string     `gorm:"size:255;not null" json:"added_by"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// TableName specifies the table name for EmployeeDocument
+func (EmployeeDocument) TableName() string {
+	return "employee_documents"
+}
+
+// EmployeeChecklistTemplate is a business's onboarding or offboarding checklist, copied to
+// each employee as they join or leave
+type EmployeeChecklistTemplate struct {
+	ID                 uuid.UUID   `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID   `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	Kind               string      `gorm:"size:20;not null" json:"kind"`
+	Items              StringArray `gorm:"type:jsonb;not null;default:'[]'" json:"items"`
+	UpdatedBy          string      `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt          time.Time   `json:"created_at"`
+	UpdatedAt          time.Time   `json:"updated_at"`
+}
+
+// TableName specifies the table name for EmployeeChecklistTemplate
+func (EmployeeChecklistTemplate) TableName() string {
+	return "employee_checklist_templates"
+}
+
+// EmployeeChecklistItem is one task on an employee's onboarding or offboarding checklist
+type EmployeeChecklistItem struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	EmployeeID uuid.UUID  `gorm:"type:uuid;not null;index" json:"employee_id"`
+	Kind       string     `gorm:"size:20;not null" json:"kind"`
+	Position   int        `gorm:"not null" json:"position"`
+	Task       string     `gorm:"size:255;not null" json:"task"`
+	Done       bool       `gorm:"default:false" json:"done"`
+	DoneBy     string     `gorm:"size:255" json:"done_by,omitempty"`
+	DoneAt     *time.Time `json:"done_at,omitempty"`
+	Remarks    string     `gorm:"type:text" json:"remarks,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for EmployeeChecklistItem
+func (EmployeeChecklistItem) TableName() string {
+	return "employee_checklist_items"
+}
+
+// NewChecklist returns an employee's checklist items for the tasks, in order
+func NewChecklist(employeeID uuid.UUID, kind string, tasks []string) []EmployeeChecklistItem {
+	items := make([]EmployeeChecklistItem, 0, len(tasks))
+	for _, task := range tasks {
+		if task = strings.TrimSpace(task); task != "" {
+			items = append(items, EmployeeChecklistItem{EmployeeID: employeeID, Kind: kind, Position: len(items) + 1, Task: task})
+		}
+	}
+	return items
+}
+
+// ChecklistOutstanding lists the tasks of the kind not yet done
+func ChecklistOutstanding(items []EmployeeChecklistItem, kind string) []string {
+	outstanding := []string{}
+	for _, item := range items {
+		if item.Kind == kind && !item.Done {
+			outstanding = append(outstanding, item.Task)
+		}
+	}
+	return outstanding
+}
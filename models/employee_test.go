@@ -0,0 +1,59 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestEmployeeValidate(t *testing.T) {
+	joined := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	e := Employee{
+		EmployeeCode: " ug-0042 ", FullName: "Lakshmi Rao", DateOfJoining: joined,
+		PAN: "abcde1234f", BankIFSC: "sbin0001234", BankAccountNumber: "1234 5678 9012",
+		EmergencyContacts: EmergencyContacts{{Name: "Srinivas Rao", Relationship: "Spouse", Phone: "9876543210"}},
+	}
+	e.Normalize()
+	if err := e.Validate(); err != nil {
+		t.Fatalf("valid employee rejected: %v", err)
+	}
+	if e.EmployeeCode != "UG-0042" || e.PAN != "ABCDE1234F" || e.BankAccountNumber != "123456789012" || e.EmploymentType != EmploymentPermanent {
+		t.Errorf("unexpected normalisation: %+v", e)
+	}
+	e.MaskBankAccount()
+	if e.BankAccountNumber != "XXXXXXXX9012" {
+		t.Errorf("unexpected masked account %s", e.BankAccountNumber)
+	}
+
+	born := joined.AddDate(1, 0, 0)
+	self := uuid.New()
+	for _, bad := range []func(e *Employee){
+		func(e *Employee) { e.FullName = "" },
+		func(e *Employee) { e.EmploymentType = "freelance" },
+		func(e *Employee) { e.DateOfBirth = &born },
+		func(e *Employee) { e.UAN = "12345" },
+		func(e *Employee) { e.BankIFSC = "" },
+		func(e *Employee) { e.ID = self; e.ReportingManagerID = &self },
+		func(e *Employee) { e.EmergencyContacts = EmergencyContacts{{Name: "No phone"}} },
+	} {
+		c := e
+		bad(&c)
+		if c.Validate() == nil {
+			t.Errorf("invalid employee accepted: %+v", c)
+		}
+	}
+}
+
+func TestChecklist(t *testing.T) {
+	id := uuid.New()
+	items := NewChecklist(id, ChecklistOnboarding, []string{"Collect PAN", " ", "Issue ID card"})
+	if len(items) != 2 || items[1].Position != 2 || items[1].EmployeeID != id {
+		t.Fatalf("unexpected checklist: %+v", items)
+	}
+	items[0].Done = true
+	items = append(items, NewChecklist(id, ChecklistOffboarding, []string{"Return assets"})...)
+	if outstanding := ChecklistOutstanding(items, ChecklistOnboarding); len(outstanding) != 1 || outstanding[0] != "Issue ID card" {
+		t.Errorf("unexpected outstanding onboarding tasks: %v", outstanding)
+	}
+}
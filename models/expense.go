@@ -0,0 +1,217 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Expense claims are approved through the same multi-level approval workflow as
+// procurement documents, starting in ProcurementDraft and approved at ProcurementApproved.
+// Approved claims are then paid out in reimbursement batches.
+
+// Expense categories
+const (
+	ExpenseTravel          = "travel"
+	ExpenseLodging         = "lodging"
+	ExpenseMeals           = "meals"
+	ExpenseFuel            = "fuel"
+	ExpenseLocalConveyance = "local_conveyance"
+	ExpenseSitePurchase    = "site_purchase"
+	ExpenseCommunication   = "communication"
+	ExpenseOther           = "other"
+)
+
+// ValidExpenseCategory reports whether category is a known expense category
+func ValidExpenseCategory(category string) bool {
+	switch category {
+	case ExpenseTravel, ExpenseLodging, ExpenseMeals, ExpenseFuel, ExpenseLocalConveyance,
+		ExpenseSitePurchase, ExpenseCommunication, ExpenseOther:
+		return true
+	}
+	return false
+}
+
+// Reimbursement batch statuses: drafted by finance, exported as a payment file, then paid
+const (
+	ReimbursementDraft    = "draft"
+	ReimbursementExported = "exported"
+	ReimbursementPaid     = "paid"
+)
+
+// ExpenseClaim is an employee's claim for expenses they paid out of pocket
+type ExpenseClaim struct {
+	ID                   uuid.UUID          `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID   uuid.UUID          `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	ClaimNumber          string             `gorm:"size:64;not null" json:"claim_number"`
+	ClaimantID           string             `gorm:"size:255;not null;index" json:"claimant_id"`
+	ClaimantName         string             `gorm:"size:255" json:"claimant_name"`
+	Purpose              string             `gorm:"type:text;not null" json:"purpose"`
+	TotalAmount          float64            `gorm:"type:decimal(15,2);default:0" json:"total_amount"`
+	WorkflowID           *uuid.UUID         `gorm:"type:uuid" json:"workflow_id,omitempty"`
+	CurrentState         string             `gorm:"size:50;not null;default:'draft';index" json:"current_state"`
+	ReimbursementBatchID *uuid.UUID         `gorm:"type:uuid;index" json:"reimbursement_batch_id,omitempty"`
+	PaidAt               *time.Time         `json:"paid_at,omitempty"`
+	PaymentReference     string             `gorm:"size:100" json:"payment_reference,omitempty"`
+	CreatedBy            string             `gorm:"size:255;not null" json:"created_by"`
+	UpdatedBy            string             `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt            time.Time          `json:"created_at"`
+	UpdatedAt            time.Time          `json:"updated_at"`
+	DeletedAt            *time.Time         `gorm:"index" json:"deleted_at,omitempty"`
+	Items                []ExpenseClaimItem `gorm:"foreignKey:ClaimID" json:"items,omitempty"`
+}
+
+// TableName specifies the table name for ExpenseClaim
+func (ExpenseClaim) TableName() string {
+	return "expense_claims"
+}
+
+// ExpenseClaimItem is one expense on a claim, with the DMS documents holding its receipts
+// and the project or task it was spent on
+type ExpenseClaimItem struct {
+	ID                 uuid.UUID   `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	ClaimID            uuid.UUID   `gorm:"type:uuid;not null;index" json:"claim_id"`
+	ExpenseDate        time.Time   `gorm:"type:date;not null" json:"expense_date"`
+	Category           string      `gorm:"size:32;not null;index" json:"category"`
+	Description        string      `gorm:"type:text;not null" json:"description"`
+	Amount             float64     `gorm:"type:decimal(15,2);not null" json:"amount"`
+	ProjectID          *uuid.UUID  `gorm:"type:uuid;index" json:"project_id,omitempty"`
+	TaskID             *uuid.UUID  `gorm:"type:uuid;index" json:"task_id,omitempty"`
+	CostCenterID       *uuid.UUID  `gorm:"type:uuid;index" json:"cost_center_id,omitempty"`
+	ReceiptDocumentIDs StringArray `gorm:"type:jsonb;default:'[]'" json:"receipt_document_ids"`
+	CreatedAt          time.Time   `json:"created_at"`
+	UpdatedAt          time.Time   `json:"updated_at"`
+}
+
+// TableName specifies the table name for ExpenseClaimItem
+func (ExpenseClaimItem) TableName() string {
+	return "expense_claim_items"
+}
+
+// ExpensePolicy limits what holders of a business role may claim in a category. A zero
+// limit means none.
+type ExpensePolicy struct {
+	ID                   uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID   uuid.UUID `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	BusinessRoleID       uuid.UUID `gorm:"type:uuid;not null;index" json:"business_role_id"`
+	Category             string    `gorm:"size:32;not null" json:"category"`
+	PerExpenseLimit      float64   `gorm:"type:decimal(15,2);default:0" json:"per_expense_limit"`
+	MonthlyLimit         float64   `gorm:"type:decimal(15,2);default:0" json:"monthly_limit"`
+	ReceiptRequiredAbove float64   `gorm:"type:decimal(15,2);default:0" json:"receipt_required_above"` // receipts needed over this amount; 0 for every expense
+	CreatedBy            string    `gorm:"size:255;not null" json:"created_by"`
+	UpdatedBy            string    `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt            time.Time `json:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for ExpensePolicy
+func (ExpensePolicy) TableName() string {
+	return "expense_policies"
+}
+
+// ReimbursementBatch groups approved expense claims for finance to pay out together
+type ReimbursementBatch struct {
+	ID                 uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID      `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	BatchNumber        string         `gorm:"size:64;not null" json:"batch_number"`
+	Status             string         `gorm:"size:20;not null;default:'draft';index" json:"status"`
+	ClaimCount         int            `json:"claim_count"`
+	TotalAmount        float64        `gorm:"type:decimal(15,2);default:0" json:"total_amount"`
+	ExportedAt         *time.Time     `json:"exported_at,omitempty"`
+	ExportedBy         string         `gorm:"size:255" json:"exported_by,omitempty"`
+	PaidAt             *time.Time     `json:"paid_at,omitempty"`
+	PaidBy             string         `gorm:"size:255" json:"paid_by,omitempty"`
+	PaymentReference   string         `gorm:"size:100" json:"payment_reference,omitempty"`
+	CreatedBy          string         `gorm:"size:255;not null" json:"created_by"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	Claims             []ExpenseClaim `gorm:"foreignKey:ReimbursementBatchID" json:"claims,omitempty"`
+}
+
+// TableName specifies the table name for ReimbursementBatch
+func (ReimbursementBatch) TableName() string {
+	return "reimbursement_batches"
+}
+
+// MergeExpensePolicies combines the policies of the roles a user holds into one per
+// category, taking the most generous limit of each kind
+func MergeExpensePolicies(policies []ExpensePolicy) map[string]ExpensePolicy {
+	generous := func(a, b float64) float64 {
+		if a == 0 || b == 0 {
+			return 0
+		}
+		return math.Max(a, b)
+	}
+	merged := map[string]ExpensePolicy{}
+	for _, p := range policies {
+		m, ok := merged[p.Category]
+		if !ok {
+			merged[p.Category] = p
+			continue
+		}
+		m.PerExpenseLimit = generous(m.PerExpenseLimit, p.PerExpenseLimit)
+		m.MonthlyLimit = generous(m.MonthlyLimit, p.MonthlyLimit)
+		m.ReceiptRequiredAbove = math.Max(m.ReceiptRequiredAbove, p.ReceiptRequiredAbove)
+		merged[p.Category] = m
+	}
+	return merged
+}
+
+// ExpenseMonth identifies the calendar month an expense falls in, e.g. 2026-10
+func ExpenseMonth(t time.Time) string {
+	return t.Format("2006-01")
+}
+
+// CheckExpensePolicy returns the ways the items break the policies, or none. Categories
+// without a policy are not limited. claimedBefore holds what the claimant already has
+// claimed, by category and then month, on their other claims in approval or approved.
+func CheckExpensePolicy(items []ExpenseClaimItem, policies map[string]ExpensePolicy, claimedBefore map[string]map[string]float64) []string {
+	violations := []string{}
+	monthly := map[string]map[string]float64{}
+	for _, item := range items {
+		policy, ok := policies[item.Category]
+		if !ok {
+			continue
+		}
+		if policy.PerExpenseLimit > 0 && item.Amount > policy.PerExpenseLimit {
+			violations = append(violations, fmt.Sprintf("%s expense of %.2f on %s exceeds the limit of %.2f",
+				item.Category, item.Amount, item.ExpenseDate.Format("2006-01-02"), policy.PerExpenseLimit))
+		}
+		if item.Amount > policy.ReceiptRequiredAbove && len(item.ReceiptDocumentIDs) == 0 {
+			violations = append(violations, fmt.Sprintf("%s expense of %.2f on %s needs a receipt",
+				item.Category, item.Amount, item.ExpenseDate.Format("2006-01-02")))
+		}
+		if monthly[item.Category] == nil {
+			monthly[item.Category] = map[string]float64{}
+		}
+		monthly[item.Category][ExpenseMonth(item.ExpenseDate)] += item.Amount
+	}
+
+	categories := make([]string, 0, len(monthly))
+	for category := range monthly {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		limit := policies[category].MonthlyLimit
+		if limit <= 0 {
+			continue
+		}
+		months := make([]string, 0, len(monthly[category]))
+		for month := range monthly[category] {
+			months = append(months, month)
+		}
+		sort.Strings(months)
+		for _, month := range months {
+			total := monthly[category][month] + claimedBefore[category][month]
+			if total > limit {
+				violations = append(violations, fmt.Sprintf("%s claims of %.2f in %s exceed the monthly limit of %.2f",
+					category, total, month, limit))
+			}
+		}
+	}
+	return violations
+}
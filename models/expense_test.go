@@ -0,0 +1,53 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMergeExpensePolicies(t *testing.T) {
+	merged := MergeExpensePolicies([]ExpensePolicy{
+		{Category: ExpenseMeals, PerExpenseLimit: 500, MonthlyLimit: 5000, ReceiptRequiredAbove: 100},
+		{Category: ExpenseMeals, PerExpenseLimit: 800, MonthlyLimit: 0, ReceiptRequiredAbove: 200},
+		{Category: ExpenseFuel, PerExpenseLimit: 2000},
+	})
+
+	meals := merged[ExpenseMeals]
+	if meals.PerExpenseLimit != 800 || meals.MonthlyLimit != 0 || meals.ReceiptRequiredAbove != 200 {
+		t.Errorf("expected the most generous meal limits, got %+v", meals)
+	}
+	if merged[ExpenseFuel].PerExpenseLimit != 2000 {
+		t.Errorf("unexpected fuel policy: %+v", merged[ExpenseFuel])
+	}
+}
+
+func TestCheckExpensePolicy(t *testing.T) {
+	oct := func(day int) time.Time { return time.Date(2026, 10, day, 0, 0, 0, 0, time.UTC) }
+	policies := map[string]ExpensePolicy{
+		ExpenseMeals: {Category: ExpenseMeals, PerExpenseLimit: 500, MonthlyLimit: 2000, ReceiptRequiredAbove: 100},
+		ExpenseFuel:  {Category: ExpenseFuel, MonthlyLimit: 3000},
+	}
+	claimedBefore := map[string]map[string]float64{ExpenseMeals: {"2026-10": 1200}}
+
+	items := []ExpenseClaimItem{
+		{ExpenseDate: oct(3), Category: ExpenseMeals, Amount: 80},
+		{ExpenseDate: oct(4), Category: ExpenseMeals, Amount: 600, ReceiptDocumentIDs: StringArray{"r1"}},
+		{ExpenseDate: oct(5), Category: ExpenseMeals, Amount: 150},
+		{ExpenseDate: oct(5), Category: ExpenseFuel, Amount: 2500, ReceiptDocumentIDs: StringArray{"r2"}},
+		{ExpenseDate: oct(6), Category: ExpenseLodging, Amount: 9000},
+	}
+	violations := CheckExpensePolicy(items, policies, claimedBefore)
+	if len(violations) != 3 {
+		t.Fatalf("expected 3 violations, got %q", violations)
+	}
+	for i, want := range []string{"exceeds the limit of 500.00", "needs a receipt", "2030.00 in 2026-10 exceed the monthly limit"} {
+		if !strings.Contains(violations[i], want) {
+			t.Errorf("violation %d: expected %q, got %q", i, want, violations[i])
+		}
+	}
+
+	if v := CheckExpensePolicy(items[:1], policies, nil); len(v) != 0 {
+		t.Errorf("expected no violations for a small meal, got %q", v)
+	}
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// FormFile is a file uploaded for a file, image or camera field of a form. The field's
+// value in the record is the file's ID; the blob lives in upload storage (local disk or
+// the GCS bucket) and is read through short-lived signed URLs.
+type FormFile struct {
+	ID                 uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	FormID             uuid.UUID      `gorm:"type:uuid;not null;index:idx_form_files_field,priority:1" json:"form_id"`
+	FormCode           string         `gorm:"size:50;not null" json:"form_code"`
+	FieldName          string         `gorm:"size:63;not null;index:idx_form_files_field,priority:2" json:"field_name"`
+	RecordID           *uuid.UUID     `gorm:"type:uuid;index" json:"record_id,omitempty"` // set once a submitted record references the file
+	BusinessVerticalID uuid.UUID      `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	SiteID             *uuid.UUID     `gorm:"type:uuid" json:"site_id,omitempty"`
+	FileName           string         `gorm:"size:255;not null" json:"file_name"`
+	FileType           string         `gorm:"size:100" json:"file_type"` // MIME type
+	FileSize           int64          `gorm:"not null" json:"file_size"`
+	FileHash           string         `gorm:"size:64" json:"file_hash"` // SHA-256
+	FilePath           string         `gorm:"size:500;not null" json:"-"`
+	ThumbnailPath      string         `gorm:"size:500" json:"-"`
+	Width              int            `json:"width,omitempty"` // images only
+	Height             int            `json:"height,omitempty"`
+	UploadedBy         string         `gorm:"size:255;not null" json:"uploaded_by"`
+	CreatedAt          time.Time      `json:"created_at"`
+	DeletedAt          gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName specifies the table name for FormFile
+func (FormFile) TableName() string {
+	return "form_files"
+}
+
+// IsImage reports whether the file is an image
+func (f *FormFile) IsImage() bool {
+	return strings.HasPrefix(f.FileType, "image/")
+}
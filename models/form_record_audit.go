@@ -0,0 +1,28 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FormRecordAudit is one field change of a record in a form's dedicated table. The fields
+// changed by one update share a ChangeID; values are kept as JSON, NULL for an empty value.
+type FormRecordAudit struct {
+	ID        uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	FormID    uuid.UUID       `gorm:"type:uuid;not null;index:idx_form_record_audit_record,priority:1" json:"form_id"`
+	FormCode  string          `gorm:"size:50;not null" json:"form_code"`
+	RecordID  uuid.UUID       `gorm:"type:uuid;not null;index:idx_form_record_audit_record,priority:2" json:"record_id"`
+	ChangeID  uuid.UUID       `gorm:"type:uuid;not null" json:"change_id"`
+	Field     string          `gorm:"size:63;not null" json:"field"`
+	OldValue  json.RawMessage `gorm:"type:jsonb" json:"old_value"`
+	NewValue  json.RawMessage `gorm:"type:jsonb" json:"new_value"`
+	ChangedBy string          `gorm:"size:255;not null" json:"changed_by"`
+	ChangedAt time.Time       `gorm:"not null;index" json:"changed_at"`
+}
+
+// TableName specifies the table name for FormRecordAudit
+func (FormRecordAudit) TableName() string {
+	return "form_record_audit"
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Form record export statuses
+const (
+	FormRecordExportPending   = "pending"
+	FormRecordExportRunning   = "running"
+	FormRecordExportCompleted = "completed"
+	FormRecordExportFailed    = "failed"
+)
+
+// FormRecordExport is an export of form records too large to stream in the request, built
+// in the background into a file its requester downloads until it expires
+type FormRecordExport struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	FormID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"form_id"`
+	FormCode     string     `gorm:"size:50;not null" json:"form_code"`
+	Format       string     `gorm:"size:10;not null" json:"format"` // csv, xlsx
+	Status       string     `gorm:"size:20;not null;default:'pending';index" json:"status"`
+	Query        string     `gorm:"type:text" json:"query,omitempty"` // the request's filters, as a query string
+	RequestedBy  string     `gorm:"size:255;not null;index" json:"requested_by"`
+	RowCount     int        `json:"row_count"`
+	FileName     string     `gorm:"size:255" json:"file_name,omitempty"`
+	FilePath     string     `gorm:"size:500" json:"-"`
+	ErrorMessage string     `gorm:"type:text" json:"error_message,omitempty"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// TableName specifies the table name for FormRecordExport
+func (FormRecordExport) TableName() string {
+	return "form_record_exports"
+}
+
+// Expired reports whether the export's file is no longer offered for download
+func (e *FormRecordExport) Expired(now time.Time) bool {
+	return e.ExpiresAt != nil && now.After(*e.ExpiresAt)
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FormColumn is a column a form's fields give its dedicated table
+type FormColumn struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"` // SQL type, e.g. TEXT, DECIMAL(15,2)
+	Required bool   `json:"required,omitempty"`
+	Label    string `json:"label,omitempty"`
+}
+
+// FormColumnChange is a field whose column type changed. The old column is kept under
+// ArchivedAs and a new column of the new type takes the field's name.
+type FormColumnChange struct {
+	Name       string `json:"name"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	ArchivedAs string `json:"archived_as,omitempty"`
+}
+
+// FormSchemaDiff describes how a form's dedicated table changes between two definitions
+type FormSchemaDiff struct {
+	Added   []FormColumn       `json:"added,omitempty"`
+	Removed []FormColumn       `json:"removed,omitempty"`
+	Retyped []FormColumnChange `json:"retyped,omitempty"`
+}
+
+// Empty reports whether the table needs no change
+func (d FormSchemaDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Retyped) == 0
+}
+
+// Destructive reports whether applying the diff loses data: removed fields drop their
+// columns. Retyped fields keep their data in the archived column, so they are not.
+func (d FormSchemaDiff) Destructive() bool {
+	return len(d.Removed) > 0
+}
+
+// DiffFormColumns compares the columns of a form's old and new definitions. Column types
+// are compared case-insensitively; a change of required alone is not a table change, since
+// columns added later are always nullable.
+func DiffFormColumns(from, to []FormColumn) FormSchemaDiff {
+	var diff FormSchemaDiff
+	old := make(map[string]FormColumn, len(from))
+	for _, c := range from {
+		if _, seen := old[c.Name]; !seen {
+			old[c.Name] = c
+		}
+	}
+	current := make(map[string]bool, len(to))
+	for _, c := range to {
+		if current[c.Name] {
+			continue
+		}
+		current[c.Name] = true
+		previous, existed := old[c.Name]
+		switch {
+		case !existed:
+			diff.Added = append(diff.Added, c)
+		case !strings.EqualFold(previous.Type, c.Type):
+			diff.Retyped = append(diff.Retyped, FormColumnChange{Name: c.Name, From: previous.Type, To: c.Type})
+		}
+	}
+	removed := make(map[string]bool)
+	for _, c := range from {
+		if !current[c.Name] && !removed[c.Name] {
+			removed[c.Name] = true
+			diff.Removed = append(diff.Removed, c)
+		}
+	}
+	return diff
+}
+
+// ArchivedFormColumnName names the column that keeps a retyped field's data from the given
+// schema version, within PostgreSQL's 63-byte identifier limit
+func ArchivedFormColumnName(name string, version int) string {
+	suffix := fmt.Sprintf("_v%d", version)
+	if len(name)+len(suffix) > 63 {
+		name = name[:63-len(suffix)]
+	}
+	return name + suffix
+}
+
+// FormSchemaVersion records the columns of a form's dedicated table at one schema version
+// and the changes that produced it from the previous one
+type FormSchemaVersion struct {
+	ID          uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	FormID      uuid.UUID       `gorm:"type:uuid;not null;uniqueIndex:idx_form_schema_version,priority:1" json:"form_id"`
+	Version     int             `gorm:"not null;uniqueIndex:idx_form_schema_version,priority:2" json:"version"`
+	Columns     json.RawMessage `gorm:"type:jsonb;not null;default:'[]'" json:"columns"`
+	Changes     json.RawMessage `gorm:"type:jsonb" json:"changes,omitempty"` // FormSchemaDiff from the previous version
+	Destructive bool            `gorm:"default:false" json:"destructive"`
+	CreatedBy   string          `gorm:"size:255" json:"created_by,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// TableName specifies the table name for FormSchemaVersion
+func (FormSchemaVersion) TableName() string {
+	return "form_schema_versions"
+}
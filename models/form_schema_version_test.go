@@ -0,0 +1,50 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffFormColumns(t *testing.T) {
+	from := []FormColumn{
+		{Name: "title", Type: "TEXT", Required: true},
+		{Name: "amount", Type: "TEXT"},
+		{Name: "remarks", Type: "TEXT"},
+	}
+	to := []FormColumn{
+		{Name: "title", Type: "text"},
+		{Name: "amount", Type: "DECIMAL(15,2)"},
+		{Name: "due_date", Type: "DATE", Required: true},
+	}
+
+	diff := DiffFormColumns(from, to)
+	if len(diff.Added) != 1 || diff.Added[0].Name != "due_date" {
+		t.Errorf("added = %+v, want due_date", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Name != "remarks" {
+		t.Errorf("removed = %+v, want remarks", diff.Removed)
+	}
+	if len(diff.Retyped) != 1 || diff.Retyped[0].Name != "amount" || diff.Retyped[0].To != "DECIMAL(15,2)" {
+		t.Errorf("retyped = %+v, want amount to DECIMAL(15,2)", diff.Retyped)
+	}
+	if !diff.Destructive() {
+		t.Error("removing a field should be destructive")
+	}
+
+	if diff := DiffFormColumns(from, from); !diff.Empty() {
+		t.Errorf("same columns: got %+v, want no changes", diff)
+	}
+	if diff := DiffFormColumns(from[:2], to[:2]); diff.Destructive() {
+		t.Errorf("retyping only should not be destructive: %+v", diff)
+	}
+}
+
+func TestArchivedFormColumnName(t *testing.T) {
+	if got := ArchivedFormColumnName("amount", 3); got != "amount_v3" {
+		t.Errorf("got %q, want amount_v3", got)
+	}
+	long := strings.Repeat("a", 63)
+	if got := ArchivedFormColumnName(long, 12); len(got) != 63 || !strings.HasSuffix(got, "_v12") {
+		t.Errorf("got %q (%d bytes), want 63 bytes ending in _v12", got, len(got))
+	}
+}
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FormTemplate is a form design kept in the template library, so verticals can create
+// their own copy of a form (with its own table) instead of rebuilding it by hand
+type FormTemplate struct {
+	ID             uuid.UUID              `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Code           string                 `gorm:"size:50;uniqueIndex;not null" json:"code"`
+	Name           string                 `gorm:"size:255;not null" json:"name"`
+	Description    string                 `gorm:"type:text" json:"description,omitempty"`
+	SourceFormCode string                 `gorm:"size:50;index" json:"source_form_code,omitempty"` // form the template was exported from
+	Definition     FormTemplateDefinition `gorm:"type:jsonb;not null;default:'{}'" json:"definition"`
+	CreatedBy      string                 `gorm:"size:255;not null" json:"created_by"`
+	CreatedAt      time.Time              `json:"created_at"`
+	UpdatedAt      time.Time              `json:"updated_at"`
+}
+
+// TableName specifies the table name for FormTemplate
+func (FormTemplate) TableName() string {
+	return "form_templates"
+}
+
+// FormTemplateDefinition is the portable part of a form: what it asks and how it is
+// approved, without its code, module, verticals or table. The workflow is named by code,
+// as workflow IDs differ between environments.
+type FormTemplateDefinition struct {
+	Title              string          `json:"title"`
+	Description        string          `json:"description,omitempty"`
+	Icon               string          `json:"icon,omitempty"`
+	RequiredPermission string          `json:"required_permission,omitempty"`
+	FormSchema         json.RawMessage `json:"form_schema,omitempty"`
+	Steps              json.RawMessage `json:"steps,omitempty"`
+	CoreFields         json.RawMessage `json:"core_fields,omitempty"`
+	Validations        json.RawMessage `json:"validations,omitempty"`
+	Dependencies       json.RawMessage `json:"dependencies,omitempty"`
+	WorkflowCode       string          `json:"workflow_code,omitempty"`
+	InitialState       string          `json:"initial_state,omitempty"`
+	Audit              bool            `json:"audit,omitempty"`
+}
+
+// NewFormTemplateDefinition exports the definition of a form bound to the workflow with
+// the given code, if any
+func NewFormTemplateDefinition(form *AppForm, workflowCode string) FormTemplateDefinition {
+	return FormTemplateDefinition{
+		Title:              form.Title,
+		Description:        form.Description,
+		Icon:               form.Icon,
+		RequiredPermission: form.RequiredPermission,
+		FormSchema:         form.FormSchema,
+		Steps:              form.Steps,
+		CoreFields:         form.CoreFields,
+		Validations:        form.Validations,
+		Dependencies:       form.Dependencies,
+		WorkflowCode:       workflowCode,
+		InitialState:       form.InitialState,
+		Audit:              form.Audit,
+	}
+}
+
+// Validate checks the definition has a title and fields to ask
+func (d FormTemplateDefinition) Validate() error {
+	if strings.TrimSpace(d.Title) == "" {
+		return errors.New("title is required")
+	}
+	if isEmptyJSON(d.FormSchema) && isEmptyJSON(d.Steps) {
+		return errors.New("form_schema or steps is required")
+	}
+	return nil
+}
+
+// Apply copies the definition onto a new form. The workflow is left to the caller, who
+// resolves WorkflowCode.
+func (d FormTemplateDefinition) Apply(form *AppForm) {
+	form.Title = d.Title
+	form.Description = d.Description
+	form.Icon = d.Icon
+	form.RequiredPermission = d.RequiredPermission
+	form.FormSchema = d.FormSchema
+	form.Steps = d.Steps
+	form.CoreFields = d.CoreFields
+	form.Validations = d.Validations
+	form.Dependencies = d.Dependencies
+	form.InitialState = d.InitialState
+	form.Audit = d.Audit
+}
+
+// isEmptyJSON reports whether raw is missing, null, or an empty object or array
+func isEmptyJSON(raw json.RawMessage) bool {
+	switch strings.TrimSpace(string(raw)) {
+	case "", "null", "{}", "[]":
+		return true
+	}
+	return false
+}
+
+// Scan implements the sql.Scanner interface
+func (d *FormTemplateDefinition) Scan(value interface{}) error {
+	if value == nil {
+		*d = FormTemplateDefinition{}
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		*d = FormTemplateDefinition{}
+		return nil
+	}
+
+	return json.Unmarshal(bytes, d)
+}
+
+// Value implements the driver.Valuer interface
+func (d FormTemplateDefinition) Value() (driver.Value, error) {
+	return json.Marshal(d)
+}
+
+// GormDataType defines the data type for GORM
+func (FormTemplateDefinition) GormDataType() string {
+	return "jsonb"
+}
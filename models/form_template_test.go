@@ -0,0 +1,57 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestFormTemplateDefinition(t *testing.T) {
+	workflowID := uuid.New()
+	source := &AppForm{
+		Code:                "water_inspection",
+		Title:               "Water Inspection",
+		ModuleID:            uuid.New(),
+		AccessibleVerticals: StringArray{"WATER"},
+		FormSchema:          json.RawMessage(`{"fields":[{"name":"depth","type":"number"}]}`),
+		WorkflowID:          &workflowID,
+		InitialState:        "draft",
+		DBTableName:         "water_inspection",
+		Audit:               true,
+	}
+	definition := NewFormTemplateDefinition(source, "inspection_approval")
+	if err := definition.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	raw, err := definition.Value()
+	if err != nil {
+		t.Fatalf("Value: %v", err)
+	}
+	var stored FormTemplateDefinition
+	if err := stored.Scan(raw); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if stored.WorkflowCode != "inspection_approval" || string(stored.FormSchema) != string(source.FormSchema) {
+		t.Errorf("round trip = %+v", stored)
+	}
+
+	clone := &AppForm{Code: "solar_inspection", AccessibleVerticals: StringArray{"SOLAR"}}
+	stored.Apply(clone)
+	if clone.Title != "Water Inspection" || !clone.Audit || clone.InitialState != "draft" {
+		t.Errorf("applied form = %+v", clone)
+	}
+	if clone.Code != "solar_inspection" || clone.DBTableName != "" || clone.WorkflowID != nil || clone.AccessibleVerticals[0] != "SOLAR" {
+		t.Errorf("definition overwrote the clone's own settings: %+v", clone)
+	}
+
+	for _, invalid := range []FormTemplateDefinition{
+		{FormSchema: source.FormSchema},
+		{Title: "Empty", FormSchema: json.RawMessage(`{}`), Steps: json.RawMessage(`[]`)},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded, want an error", invalid)
+		}
+	}
+}
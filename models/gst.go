@@ -0,0 +1,249 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// GST supply types: within the supplier's state, taxed as CGST and SGST, or across
+// states, taxed as IGST
+const (
+	SupplyIntraState = "INTRA"
+	SupplyInterState = "INTER"
+)
+
+// ValidGSTIN reports whether gstin is a well formed GSTIN
+func ValidGSTIN(gstin string) bool {
+	return gstinPattern.MatchString(gstin)
+}
+
+// GSTStateCode returns the state code a GSTIN is registered in: its first two digits
+func GSTStateCode(gstin string) string {
+	if len(gstin) < 2 {
+		return ""
+	}
+	return gstin[:2]
+}
+
+// ValidGSTStateCode reports whether code is a GST state code: 01 to 38, or 97 for other
+// territory
+func ValidGSTStateCode(code string) bool {
+	if len(code) != 2 || code[0] < '0' || code[0] > '9' || code[1] < '0' || code[1] > '9' {
+		return false
+	}
+	n := int(code[0]-'0')*10 + int(code[1]-'0')
+	return (n >= 1 && n <= 38) || n == 97
+}
+
+// GSTReturnPeriod returns a YYYY-MM period as the MMYYYY the GST returns use
+func GSTReturnPeriod(period time.Time) string {
+	return period.Format("012006")
+}
+
+// SplitGST splits tax on a supply into IGST, or CGST and SGST halves when the place of
+// supply is in the supplier's state
+func SplitGST(tax float64, supplierState, placeOfSupply string) (igst, cgst, sgst float64) {
+	tax = Round2(tax)
+	if placeOfSupply != "" && placeOfSupply != supplierState {
+		return tax, 0, 0
+	}
+	cgst = Round2(tax / 2)
+	return 0, cgst, Round2(tax - cgst)
+}
+
+// GSTSupply is an invoice for a supply, outward to a client or inward from a vendor,
+// with its value in the base currency
+type GSTSupply struct {
+	PartyGSTIN    string    `json:"party_gstin,omitempty"` // the recipient's, or the supplier's on an inward supply
+	PartyName     string    `json:"party_name"`
+	InvoiceNumber string    `json:"invoice_number"`
+	InvoiceDate   time.Time `json:"invoice_date"`
+	PlaceOfSupply string    `json:"place_of_supply"` // state code
+	TaxableValue  float64   `json:"taxable_value"`
+	TaxAmount     float64   `json:"tax_amount"`
+	IGST          float64   `json:"igst"`
+	CGST          float64   `json:"cgst"`
+	SGST          float64   `json:"sgst"`
+}
+
+// Rate returns the supply's tax rate, as a percentage of its taxable value
+func (s GSTSupply) Rate() float64 {
+	if s.TaxableValue == 0 {
+		return 0
+	}
+	return Round2(s.TaxAmount / s.TaxableValue * 100)
+}
+
+// GSTR1ItemDetail is the taxable value and tax at one rate
+type GSTR1ItemDetail struct {
+	TaxableValue float64 `json:"txval"`
+	Rate         float64 `json:"rt"`
+	IGST         float64 `json:"iamt"`
+	CGST         float64 `json:"camt"`
+	SGST         float64 `json:"samt"`
+	Cess         float64 `json:"csamt"`
+}
+
+// GSTR1Item is a numbered rate line of a GSTR-1 invoice
+type GSTR1Item struct {
+	Number int             `json:"num"`
+	Detail GSTR1ItemDetail `json:"itm_det"`
+}
+
+// GSTR1Invoice is an invoice to a registered recipient in GSTR-1
+type GSTR1Invoice struct {
+	Number        string      `json:"inum"`
+	Date          string      `json:"idt"` // DD-MM-YYYY
+	Value         float64     `json:"val"`
+	PlaceOfSupply string      `json:"pos"`
+	ReverseCharge string      `json:"rchrg"`
+	Type          string      `json:"inv_typ"`
+	Items         []GSTR1Item `json:"itms"`
+}
+
+// GSTR1B2B lists the invoices to one registered recipient
+type GSTR1B2B struct {
+	RecipientGSTIN string         `json:"ctin"`
+	Invoices       []GSTR1Invoice `json:"inv"`
+}
+
+// GSTR1B2CS totals small supplies to unregistered recipients by place of supply and rate
+type GSTR1B2CS struct {
+	SupplyType    string  `json:"sply_ty"`
+	PlaceOfSupply string  `json:"pos"`
+	Type          string  `json:"typ"`
+	TaxableValue  float64 `json:"txval"`
+	Rate          float64 `json:"rt"`
+	IGST          float64 `json:"iamt"`
+	CGST          float64 `json:"camt"`
+	SGST          float64 `json:"samt"`
+	Cess          float64 `json:"csamt"`
+}
+
+// GSTR1 is a GSTR-1 return of outward supplies in the government's JSON schema
+type GSTR1 struct {
+	GSTIN  string      `json:"gstin"`
+	Period string      `json:"fp"`
+	B2B    []GSTR1B2B  `json:"b2b"`
+	B2CS   []GSTR1B2CS `json:"b2cs"`
+}
+
+// BuildGSTR1 builds the GSTR-1 of a supplier's outward supplies in a period. Supplies to
+// recipients with a GSTIN are reported invoice by invoice; the rest are totalled by place
+// of supply and rate.
+func BuildGSTR1(gstin string, period time.Time, supplies []GSTSupply) GSTR1 {
+	ret := GSTR1{GSTIN: gstin, Period: GSTReturnPeriod(period), B2B: []GSTR1B2B{}, B2CS: []GSTR1B2CS{}}
+	supplierState := GSTStateCode(gstin)
+	recipients := map[string]int{}
+	smallSupplies := map[[2]string]int{}
+	for _, s := range supplies {
+		detail := GSTR1ItemDetail{TaxableValue: Round2(s.TaxableValue), Rate: s.Rate(), IGST: s.IGST, CGST: s.CGST, SGST: s.SGST}
+		if s.PartyGSTIN != "" {
+			i, ok := recipients[s.PartyGSTIN]
+			if !ok {
+				i = len(ret.B2B)
+				recipients[s.PartyGSTIN] = i
+				ret.B2B = append(ret.B2B, GSTR1B2B{RecipientGSTIN: s.PartyGSTIN})
+			}
+			ret.B2B[i].Invoices = append(ret.B2B[i].Invoices, GSTR1Invoice{
+				Number:        s.InvoiceNumber,
+				Date:          s.InvoiceDate.Format("02-01-2006"),
+				Value:         Round2(s.TaxableValue + s.TaxAmount),
+				PlaceOfSupply: s.PlaceOfSupply,
+				ReverseCharge: "N",
+				Type:          "R",
+				Items:         []GSTR1Item{{Number: 1, Detail: detail}},
+			})
+			continue
+		}
+
+		supplyType := SupplyIntraState
+		if s.PlaceOfSupply != supplierState {
+			supplyType = SupplyInterState
+		}
+		key := [2]string{s.PlaceOfSupply, fmt.Sprintf("%.2f", detail.Rate)}
+		i, ok := smallSupplies[key]
+		if !ok {
+			i = len(ret.B2CS)
+			smallSupplies[key] = i
+			ret.B2CS = append(ret.B2CS, GSTR1B2CS{SupplyType: supplyType, PlaceOfSupply: s.PlaceOfSupply, Type: "OE", Rate: detail.Rate})
+		}
+		row := &ret.B2CS[i]
+		row.TaxableValue = Round2(row.TaxableValue + detail.TaxableValue)
+		row.IGST = Round2(row.IGST + detail.IGST)
+		row.CGST = Round2(row.CGST + detail.CGST)
+		row.SGST = Round2(row.SGST + detail.SGST)
+	}
+	sort.Slice(ret.B2B, func(i, j int) bool { return ret.B2B[i].RecipientGSTIN < ret.B2B[j].RecipientGSTIN })
+	sort.Slice(ret.B2CS, func(i, j int) bool {
+		if ret.B2CS[i].PlaceOfSupply != ret.B2CS[j].PlaceOfSupply {
+			return ret.B2CS[i].PlaceOfSupply < ret.B2CS[j].PlaceOfSupply
+		}
+		return ret.B2CS[i].Rate < ret.B2CS[j].Rate
+	})
+	return ret
+}
+
+// GSTR3BAmounts is a taxable value and the tax on it
+type GSTR3BAmounts struct {
+	TaxableValue float64 `json:"txval"`
+	IGST         float64 `json:"iamt"`
+	CGST         float64 `json:"camt"`
+	SGST         float64 `json:"samt"`
+	Cess         float64 `json:"csamt"`
+}
+
+// add adds a supply's value and tax
+func (a *GSTR3BAmounts) add(s GSTSupply) {
+	a.TaxableValue = Round2(a.TaxableValue + s.TaxableValue)
+	a.IGST = Round2(a.IGST + s.IGST)
+	a.CGST = Round2(a.CGST + s.CGST)
+	a.SGST = Round2(a.SGST + s.SGST)
+}
+
+// GSTR3BITC is an input tax credit line of GSTR-3B, by its type
+type GSTR3BITC struct {
+	Type string  `json:"ty"`
+	IGST float64 `json:"iamt"`
+	CGST float64 `json:"camt"`
+	SGST float64 `json:"samt"`
+	Cess float64 `json:"csamt"`
+}
+
+// GSTR3B is a GSTR-3B summary return in the government's JSON schema
+type GSTR3B struct {
+	GSTIN      string `json:"gstin"`
+	Period     string `json:"ret_period"`
+	SupDetails struct {
+		Outward GSTR3BAmounts `json:"osup_det"`
+	} `json:"sup_details"`
+	ITCEligible struct {
+		Available  []GSTR3BITC `json:"itc_avl"`
+		Ineligible []GSTR3BITC `json:"itc_inelg"`
+		Net        GSTR3BITC   `json:"itc_net"`
+	} `json:"itc_elg"`
+}
+
+// BuildGSTR3B builds the GSTR-3B summary of a supplier's outward supplies in a period and
+// the input tax credit on its inward supplies. Credit is only available on supplies from
+// vendors with a GSTIN; tax paid to others is reported as ineligible.
+func BuildGSTR3B(gstin string, period time.Time, outward, inward []GSTSupply) GSTR3B {
+	var ret GSTR3B
+	ret.GSTIN, ret.Period = gstin, GSTReturnPeriod(period)
+	for _, s := range outward {
+		ret.SupDetails.Outward.add(s)
+	}
+	var eligible, ineligible GSTR3BAmounts
+	for _, s := range inward {
+		if s.PartyGSTIN != "" {
+			eligible.add(s)
+		} else {
+			ineligible.add(s)
+		}
+	}
+	ret.ITCEligible.Available = []GSTR3BITC{{Type: "OTH", IGST: eligible.IGST, CGST: eligible.CGST, SGST: eligible.SGST}}
+	ret.ITCEligible.Ineligible = []GSTR3BITC{{Type: "OTH", IGST: ineligible.IGST, CGST: ineligible.CGST, SGST: ineligible.SGST}}
+	ret.ITCEligible.Net = GSTR3BITC{IGST: eligible.IGST, CGST: eligible.CGST, SGST: eligible.SGST}
+	return ret
+}
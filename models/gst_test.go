@@ -0,0 +1,77 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGSTINAndStateCode(t *testing.T) {
+	if !ValidGSTIN("29ABCDE1234F1Z5") || ValidGSTIN("29abcde1234f1z5") || ValidGSTIN("29ABCDE1234F1Y5") {
+		t.Error("GSTIN validation mismatch")
+	}
+	if got := GSTStateCode("29ABCDE1234F1Z5"); got != "29" {
+		t.Errorf("GSTStateCode = %q, want 29", got)
+	}
+	for code, ok := range map[string]bool{"01": true, "36": true, "97": true, "00": false, "39": false, "7": false, "AB": false} {
+		if ValidGSTStateCode(code) != ok {
+			t.Errorf("ValidGSTStateCode(%q) = %v, want %v", code, !ok, ok)
+		}
+	}
+}
+
+func TestSplitGST(t *testing.T) {
+	if igst, cgst, sgst := SplitGST(1800.01, "29", "27"); igst != 1800.01 || cgst != 0 || sgst != 0 {
+		t.Errorf("inter-state split = %v/%v/%v, want all IGST", igst, cgst, sgst)
+	}
+	// The odd paisa goes to SGST
+	if igst, cgst, sgst := SplitGST(1800.01, "29", "29"); igst != 0 || cgst != 900.01 || sgst != 900 {
+		t.Errorf("intra-state split = %v/%v/%v, want 0/900.01/900", igst, cgst, sgst)
+	}
+}
+
+func TestBuildGSTR1(t *testing.T) {
+	period := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	day := time.Date(2026, 9, 14, 0, 0, 0, 0, time.UTC)
+	supplies := []GSTSupply{
+		{PartyGSTIN: "27ABCDE1234F1Z5", InvoiceNumber: "RA-2", InvoiceDate: day, PlaceOfSupply: "27", TaxableValue: 100000, TaxAmount: 18000, IGST: 18000},
+		{PartyGSTIN: "27ABCDE1234F1Z5", InvoiceNumber: "RA-3", InvoiceDate: day, PlaceOfSupply: "27", TaxableValue: 50000, TaxAmount: 9000, IGST: 9000},
+		{InvoiceNumber: "RA-4", InvoiceDate: day, PlaceOfSupply: "29", TaxableValue: 10000, TaxAmount: 1800, CGST: 900, SGST: 900},
+		{InvoiceNumber: "RA-5", InvoiceDate: day, PlaceOfSupply: "29", TaxableValue: 5000, TaxAmount: 900, CGST: 450, SGST: 450},
+	}
+	ret := BuildGSTR1("29AAACU1234B1Z2", period, supplies)
+	if ret.Period != "092026" {
+		t.Errorf("period = %q, want 092026", ret.Period)
+	}
+	if len(ret.B2B) != 1 || len(ret.B2B[0].Invoices) != 2 {
+		t.Fatalf("expected one B2B recipient with two invoices, got %+v", ret.B2B)
+	}
+	inv := ret.B2B[0].Invoices[0]
+	if inv.Date != "14-09-2026" || inv.Value != 118000 || inv.Items[0].Detail.Rate != 18 || inv.Items[0].Detail.IGST != 18000 {
+		t.Errorf("unexpected B2B invoice %+v", inv)
+	}
+	if len(ret.B2CS) != 1 {
+		t.Fatalf("expected the unregistered supplies in one B2CS row, got %+v", ret.B2CS)
+	}
+	if row := ret.B2CS[0]; row.SupplyType != SupplyIntraState || row.TaxableValue != 15000 || row.CGST != 1350 || row.SGST != 1350 {
+		t.Errorf("unexpected B2CS row %+v", row)
+	}
+}
+
+func TestBuildGSTR3B(t *testing.T) {
+	period := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	outward := []GSTSupply{{TaxableValue: 100000, TaxAmount: 18000, IGST: 18000}}
+	inward := []GSTSupply{
+		{PartyGSTIN: "29ABCDE1234F1Z5", TaxableValue: 40000, TaxAmount: 7200, CGST: 3600, SGST: 3600},
+		{TaxableValue: 1000, TaxAmount: 180, CGST: 90, SGST: 90},
+	}
+	ret := BuildGSTR3B("29AAACU1234B1Z2", period, outward, inward)
+	if ret.Period != "092026" || ret.SupDetails.Outward.TaxableValue != 100000 || ret.SupDetails.Outward.IGST != 18000 {
+		t.Errorf("unexpected outward supplies %+v", ret.SupDetails.Outward)
+	}
+	if net := ret.ITCEligible.Net; net.CGST != 3600 || net.SGST != 3600 {
+		t.Errorf("net ITC = %+v, want only the registered vendor's tax", net)
+	}
+	if inelg := ret.ITCEligible.Ineligible[0]; inelg.CGST != 90 || inelg.SGST != 90 {
+		t.Errorf("ineligible ITC = %+v, want the unregistered vendor's tax", inelg)
+	}
+}
@@ -0,0 +1,179 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Leave accrual methods: a leave type credits its days each month, all at the start of
+// the year, or not at all, as for unpaid leave, which is then not held to a balance
+const (
+	LeaveAccrualMonthly = "monthly"
+	LeaveAccrualYearly  = "yearly"
+	LeaveAccrualNone    = "none"
+)
+
+// LeaveApplicationCancelled is the state of a leave application withdrawn by the employee
+// or cancelled by HR, outside its approval workflow
+const LeaveApplicationCancelled = "cancelled"
+
+// LeaveType is a kind of leave a business grants, with the policy it accrues under
+type LeaveType struct {
+	ID                 uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	Code               string    `gorm:"size:32;not null" json:"code"` // CL, SL, EL, LOP, ...
+	Name               string    `gorm:"size:100;not null" json:"name"`
+	Paid               bool      `gorm:"default:true" json:"paid"`
+	AccrualMethod      string    `gorm:"size:20;not null;default:'monthly'" json:"accrual_method"`
+	AccrualDays        float64   `gorm:"type:decimal(5,2);default:0" json:"accrual_days"`      // credited each month, or each year
+	MaxBalance         float64   `gorm:"type:decimal(6,2);default:0" json:"max_balance"`       // 0 for no cap
+	CarryForwardMax    float64   `gorm:"type:decimal(6,2);default:0" json:"carry_forward_max"` // days carried into the next year
+	AllowHalfDay       bool      `gorm:"default:true" json:"allow_half_day"`
+	AllowNegative      bool      `gorm:"default:false" json:"allow_negative"`
+	MinNoticeDays      int       `gorm:"default:0" json:"min_notice_days"`
+	IsActive           bool      `gorm:"default:true;index" json:"is_active"`
+	CreatedBy          string    `gorm:"size:255;not null" json:"created_by"`
+	UpdatedBy          string    `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for LeaveType
+func (LeaveType) TableName() string {
+	return "leave_types"
+}
+
+// Validate checks the leave type's code, name and accrual policy
+func (t LeaveType) Validate() error {
+	switch {
+	case t.Code == "" || t.Name == "":
+		return fmt.Errorf("code and name are required")
+	case t.AccrualMethod != LeaveAccrualMonthly && t.AccrualMethod != LeaveAccrualYearly && t.AccrualMethod != LeaveAccrualNone:
+		return fmt.Errorf("accrual_method must be monthly, yearly or none")
+	case t.AccrualDays < 0 || t.MaxBalance < 0 || t.CarryForwardMax < 0 || t.MinNoticeDays < 0:
+		return fmt.Errorf("accrual_days, max_balance, carry_forward_max and min_notice_days cannot be negative")
+	case t.AccrualMethod != LeaveAccrualNone && t.AccrualDays == 0:
+		return fmt.Errorf("accrual_days is required for leave that accrues")
+	case t.MaxBalance > 0 && t.CarryForwardMax > t.MaxBalance:
+		return fmt.Errorf("carry_forward_max cannot exceed max_balance")
+	}
+	return nil
+}
+
+// TracksBalance reports whether leave of the type is taken from a balance
+func (t LeaveType) TracksBalance() bool {
+	return t.AccrualMethod != LeaveAccrualNone
+}
+
+// LeaveBalance is an employee's balance of a leave type for a calendar year: carried in,
+// accrued through the year, adjusted by HR, and taken on approved leave
+type LeaveBalance struct {
+	ID                 uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	UserID             string    `gorm:"size:255;not null;index" json:"user_id"`
+	LeaveTypeID        uuid.UUID `gorm:"type:uuid;not null;index" json:"leave_type_id"`
+	Year               int       `gorm:"not null" json:"year"`
+	Opening            float64   `gorm:"type:decimal(6,2);default:0" json:"opening"`
+	Accrued            float64   `gorm:"type:decimal(6,2);default:0" json:"accrued"`
+	Adjusted           float64   `gorm:"type:decimal(6,2);default:0" json:"adjusted"`
+	Used               float64   `gorm:"type:decimal(6,2);default:0" json:"used"`
+	AccruedThrough     string    `gorm:"size:7" json:"accrued_through,omitempty"` // YYYY-MM of the last month credited
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for LeaveBalance
+func (LeaveBalance) TableName() string {
+	return "leave_balances"
+}
+
+// Available returns the days left to take
+func (b LeaveBalance) Available() float64 {
+	return math.Round((b.Opening+b.Accrued+b.Adjusted-b.Used)*100) / 100
+}
+
+// Accrue credits the leave type's days for each month after the balance's last accrual
+// up to and including through (YYYY-MM) within the balance's year, capped at the type's
+// maximum balance. Yearly leave is credited once, with the year's first month. It returns
+// the days credited.
+func (b *LeaveBalance) Accrue(t LeaveType, through string) (float64, error) {
+	end, err := time.Parse("2006-01", through)
+	if err != nil {
+		return 0, fmt.Errorf("period must be YYYY-MM")
+	}
+	if !t.TracksBalance() || end.Year() < b.Year {
+		return 0, nil
+	}
+	if end.Year() > b.Year {
+		end = time.Date(b.Year, time.December, 1, 0, 0, 0, 0, time.UTC)
+	}
+	month := time.Date(b.Year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	if b.AccruedThrough != "" {
+		last, err := time.Parse("2006-01", b.AccruedThrough)
+		if err != nil {
+			return 0, err
+		}
+		month = last.AddDate(0, 1, 0)
+	}
+
+	credited := 0.0
+	for ; !month.After(end); month = month.AddDate(0, 1, 0) {
+		if t.AccrualMethod == LeaveAccrualMonthly || month.Month() == time.January {
+			credited += t.AccrualDays
+		}
+		b.AccruedThrough = month.Format("2006-01")
+	}
+	if t.MaxBalance > 0 {
+		credited = math.Max(0, math.Min(credited, t.MaxBalance-b.Available()))
+	}
+	b.Accrued += credited
+	return credited, nil
+}
+
+// CarryForward returns the opening balance of the next year: what is left of this one,
+// up to the type's carry-forward limit
+func (b LeaveBalance) CarryForward(t LeaveType) float64 {
+	return math.Max(0, math.Min(b.Available(), t.CarryForwardMax))
+}
+
+// LeaveApplication is an employee's request for leave, approved through the standard
+// approval workflow. Approval records it as EmployeeLeave for payroll and attendance and
+// takes it from the employee's balance.
+type LeaveApplication struct {
+	ID                 uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID  `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	UserID             string     `gorm:"size:255;not null;index" json:"user_id"`
+	EmployeeName       string     `gorm:"size:255" json:"employee_name"`
+	LeaveTypeID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"leave_type_id"`
+	FromDate           time.Time  `gorm:"type:date;not null;index" json:"from_date"`
+	ToDate             time.Time  `gorm:"type:date;not null;index" json:"to_date"`
+	HalfDay            bool       `gorm:"default:false" json:"half_day"`
+	Days               float64    `gorm:"type:decimal(5,1);not null" json:"days"`
+	Reason             string     `gorm:"type:text" json:"reason,omitempty"`
+	WorkflowID         *uuid.UUID `gorm:"type:uuid" json:"workflow_id,omitempty"`
+	CurrentState       string     `gorm:"size:50;not null;default:'draft';index" json:"current_state"`
+	LeaveID            *uuid.UUID `gorm:"type:uuid" json:"leave_id,omitempty"` // the EmployeeLeave recorded on approval
+	CreatedBy          string     `gorm:"size:255;not null" json:"created_by"`
+	UpdatedBy          string     `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+	LeaveType          *LeaveType `gorm:"foreignKey:LeaveTypeID" json:"leave_type,omitempty"`
+}
+
+// TableName specifies the table name for LeaveApplication
+func (LeaveApplication) TableName() string {
+	return "leave_applications"
+}
+
+// LeaveDays counts the working days, excluding Sundays, from from to to inclusive, or
+// half a day for a half-day application
+func LeaveDays(from, to time.Time, halfDay bool) float64 {
+	days := float64(WorkingDaysBetween(from, to.AddDate(0, 0, 1)))
+	if halfDay && days > 0 {
+		return 0.5
+	}
+	return days
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLeaveTypeValidate(t *testing.T) {
+	casual := LeaveType{Code: "CL", Name: "Casual leave", AccrualMethod: LeaveAccrualMonthly, AccrualDays: 1, MaxBalance: 12, CarryForwardMax: 6}
+	if err := casual.Validate(); err != nil {
+		t.Fatalf("valid leave type rejected: %v", err)
+	}
+	unpaid := LeaveType{Code: "LOP", Name: "Loss of pay", AccrualMethod: LeaveAccrualNone}
+	if err := unpaid.Validate(); err != nil || unpaid.TracksBalance() {
+		t.Errorf("unpaid leave should be valid and untracked: %v", err)
+	}
+
+	for _, bad := range []LeaveType{
+		{Code: "CL", Name: "Casual", AccrualMethod: "weekly", AccrualDays: 1},
+		{Code: "CL", Name: "Casual", AccrualMethod: LeaveAccrualMonthly},
+		{Code: "CL", Name: "Casual", AccrualMethod: LeaveAccrualMonthly, AccrualDays: 1, MaxBalance: 5, CarryForwardMax: 10},
+		{Code: "CL", Name: "Casual", AccrualMethod: LeaveAccrualYearly, AccrualDays: -2},
+	} {
+		if bad.Validate() == nil {
+			t.Errorf("invalid leave type accepted: %+v", bad)
+		}
+	}
+}
+
+func TestLeaveBalanceAccrue(t *testing.T) {
+	casual := LeaveType{AccrualMethod: LeaveAccrualMonthly, AccrualDays: 1.5, MaxBalance: 10, CarryForwardMax: 4}
+	b := LeaveBalance{Year: 2026, Opening: 2}
+
+	if days, err := b.Accrue(casual, "2026-03"); err != nil || days != 4.5 {
+		t.Fatalf("expected 4.5 days for January to March, got %v (%v)", days, err)
+	}
+	// Accruing the same months again credits nothing
+	if days, _ := b.Accrue(casual, "2026-03"); days != 0 || b.AccruedThrough != "2026-03" {
+		t.Errorf("re-accrual credited %v days through %s", days, b.AccruedThrough)
+	}
+	b.Used = 1
+	// Six more months would credit 9 days, but the balance is capped at 10
+	if days, _ := b.Accrue(casual, "2026-09"); days != 4.5 || b.Available() != 10 {
+		t.Errorf("expected the cap to hold the balance at 10, credited %v to %v", days, b.Available())
+	}
+	// A later year's period accrues only to the end of the balance's year
+	if _, err := b.Accrue(casual, "2027-02"); err != nil || b.AccruedThrough != "2026-12" {
+		t.Errorf("accrued through %s", b.AccruedThrough)
+	}
+	if carried := b.CarryForward(casual); carried != 4 {
+		t.Errorf("expected 4 days carried forward, got %v", carried)
+	}
+
+	earned := LeaveType{AccrualMethod: LeaveAccrualYearly, AccrualDays: 15}
+	y := LeaveBalance{Year: 2026}
+	if days, _ := y.Accrue(earned, "2026-06"); days != 15 {
+		t.Errorf("expected the year's 15 days at once, got %v", days)
+	}
+	if days, _ := y.Accrue(earned, "2026-12"); days != 0 {
+		t.Errorf("yearly leave credited again: %v", days)
+	}
+	if _, err := y.Accrue(earned, "June"); err == nil {
+		t.Error("invalid period accepted")
+	}
+}
+
+func TestLeaveDays(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 0, 0, 0, 0, time.UTC) }
+	// October 2 to 6 2026 spans Sunday the 4th
+	if days := LeaveDays(day(2), day(6), false); days != 4 {
+		t.Errorf("expected 4 working days, got %v", days)
+	}
+	if days := LeaveDays(day(5), day(5), true); days != 0.5 {
+		t.Errorf("expected half a day, got %v", days)
+	}
+	if days := LeaveDays(day(4), day(4), false); days != 0 {
+		t.Errorf("expected no working days on a Sunday, got %v", days)
+	}
+
+	from, to, _ := PayrollMonth("2026-10")
+	paid, _ := LeaveDaysBetween([]EmployeeLeave{
+		{FromDate: day(5), ToDate: day(5), Paid: true, HalfDay: true, Status: LeaveApproved},
+		{FromDate: day(7), ToDate: day(8), Paid: true, Status: LeaveApproved},
+	}, from, to)
+	if paid != 2.5 {
+		t.Errorf("expected 2.5 paid leave days, got %v", paid)
+	}
+}
@@ -0,0 +1,270 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Ledger account types
+const (
+	AccountAsset     = "asset"
+	AccountLiability = "liability"
+	AccountEquity    = "equity"
+	AccountIncome    = "income"
+	AccountExpense   = "expense"
+)
+
+// ValidAccountType reports whether t is a known ledger account type
+func ValidAccountType(t string) bool {
+	switch t {
+	case AccountAsset, AccountLiability, AccountEquity, AccountIncome, AccountExpense:
+		return true
+	}
+	return false
+}
+
+// DebitNormal reports whether accounts of the type carry a debit balance
+func DebitNormal(accountType string) bool {
+	return accountType == AccountAsset || accountType == AccountExpense
+}
+
+// Journal entry statuses: manual entries are drafted and then posted; posted entries are
+// only ever undone by a reversing entry
+const (
+	JournalDraft    = "draft"
+	JournalPosted   = "posted"
+	JournalReversed = "reversed"
+)
+
+// Journal entry sources: entered by hand, or posted automatically when a payroll run,
+// vendor invoice or expense claim is approved or a vendor invoice or reimbursement batch
+// is paid
+const (
+	JournalManual        = "manual"
+	JournalPayroll       = "payroll"
+	JournalPurchase      = "purchase"
+	JournalVendorPayment = "vendor_payment"
+	JournalExpense       = "expense"
+	JournalReimbursement = "reimbursement"
+	JournalReversal      = "reversal"
+)
+
+// Codes of the accounts automatic postings use. A business's chart gets them the first
+// time something posts to them, and may rename them but keeps the codes.
+const (
+	AccountCodeBank                  = "1000"
+	AccountCodeInputTax              = "1200"
+	AccountCodeTradePayables         = "2000"
+	AccountCodeSalariesPayable       = "2100"
+	AccountCodeStatutoryPayable      = "2110"
+	AccountCodeReimbursementsDue     = "2200"
+	AccountCodeCapital               = "3000"
+	AccountCodeRevenue               = "4000"
+	AccountCodeSalaries              = "5000"
+	AccountCodeEmployerContributions = "5010"
+	AccountCodeMaterials             = "5100"
+	AccountCodeEmployeeExpenses      = "5200"
+)
+
+// DefaultChartOfAccounts returns the accounts a business starts with
+func DefaultChartOfAccounts() []LedgerAccount {
+	return []LedgerAccount{
+		{Code: AccountCodeBank, Name: "Cash and bank", Type: AccountAsset},
+		{Code: AccountCodeInputTax, Name: "GST input tax credit", Type: AccountAsset},
+		{Code: AccountCodeTradePayables, Name: "Trade payables", Type: AccountLiability},
+		{Code: AccountCodeSalariesPayable, Name: "Salaries payable", Type: AccountLiability},
+		{Code: AccountCodeStatutoryPayable, Name: "Statutory dues payable", Type: AccountLiability},
+		{Code: AccountCodeReimbursementsDue, Name: "Employee reimbursements payable", Type: AccountLiability},
+		{Code: AccountCodeCapital, Name: "Capital", Type: AccountEquity},
+		{Code: AccountCodeRevenue, Name: "Contract revenue", Type: AccountIncome},
+		{Code: AccountCodeSalaries, Name: "Salaries and wages", Type: AccountExpense},
+		{Code: AccountCodeEmployerContributions, Name: "Employer statutory contributions", Type: AccountExpense},
+		{Code: AccountCodeMaterials, Name: "Materials purchased", Type: AccountExpense},
+		{Code: AccountCodeEmployeeExpenses, Name: "Employee expenses", Type: AccountExpense},
+	}
+}
+
+// LedgerAccount is an account in a business's chart of accounts
+type LedgerAccount struct {
+	ID                 uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID  `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	Code               string     `gorm:"size:20;not null" json:"code"`
+	Name               string     `gorm:"size:255;not null" json:"name"`
+	Type               string     `gorm:"size:20;not null;index" json:"type"`
+	ParentID           *uuid.UUID `gorm:"type:uuid;index" json:"parent_id,omitempty"`
+	Description        string     `gorm:"type:text" json:"description,omitempty"`
+	IsActive           bool       `gorm:"default:true" json:"is_active"`
+	CreatedBy          string     `gorm:"size:255" json:"created_by,omitempty"`
+	UpdatedBy          string     `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for LedgerAccount
+func (LedgerAccount) TableName() string {
+	return "ledger_accounts"
+}
+
+// Validate checks the account's code, name and type
+func (a LedgerAccount) Validate() error {
+	switch {
+	case a.Code == "" || a.Name == "":
+		return fmt.Errorf("code and name are required")
+	case !ValidAccountType(a.Type):
+		return fmt.Errorf("unknown account type %q", a.Type)
+	case a.ParentID != nil && *a.ParentID == a.ID:
+		return fmt.Errorf("an account cannot be its own parent")
+	}
+	return nil
+}
+
+// CostCenter is a unit of the business that postings are reported by, usually a site
+type CostCenter struct {
+	ID                 uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID  `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	Code               string     `gorm:"size:20;not null" json:"code"`
+	Name               string     `gorm:"size:255;not null" json:"name"`
+	SiteID             *uuid.UUID `gorm:"type:uuid;index" json:"site_id,omitempty"` // purchases for the site post here
+	IsActive           bool       `gorm:"default:true" json:"is_active"`
+	CreatedBy          string     `gorm:"size:255" json:"created_by,omitempty"`
+	UpdatedBy          string     `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for CostCenter
+func (CostCenter) TableName() string {
+	return "cost_centers"
+}
+
+// JournalEntry is a balanced set of debits and credits on a date
+type JournalEntry struct {
+	ID                 uuid.UUID     `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID     `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	EntryNumber        string        `gorm:"size:64;not null" json:"entry_number"`
+	EntryDate          time.Time     `gorm:"type:date;not null;index" json:"entry_date"`
+	Narration          string        `gorm:"type:text;not null" json:"narration"`
+	Source             string        `gorm:"size:20;not null;index" json:"source"`
+	SourceID           *uuid.UUID    `gorm:"type:uuid;index" json:"source_id,omitempty"` // the payroll run, invoice, claim, batch or reversed entry
+	Status             string        `gorm:"size:20;not null;default:'draft';index" json:"status"`
+	TotalAmount        float64       `gorm:"type:decimal(15,2);default:0" json:"total_amount"`
+	PostedAt           *time.Time    `json:"posted_at,omitempty"`
+	PostedBy           string        `gorm:"size:255" json:"posted_by,omitempty"`
+	ReversedByID       *uuid.UUID    `gorm:"type:uuid" json:"reversed_by_id,omitempty"`
+	CreatedBy          string        `gorm:"size:255;not null" json:"created_by"`
+	UpdatedBy          string        `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt          time.Time     `json:"created_at"`
+	UpdatedAt          time.Time     `json:"updated_at"`
+	Lines              []JournalLine `gorm:"foreignKey:EntryID" json:"lines,omitempty"`
+}
+
+// TableName specifies the table name for JournalEntry
+func (JournalEntry) TableName() string {
+	return "journal_entries"
+}
+
+// JournalLine debits or credits an account, optionally against a cost center and project
+type JournalLine struct {
+	ID           uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	EntryID      uuid.UUID      `gorm:"type:uuid;not null;index" json:"entry_id"`
+	AccountID    uuid.UUID      `gorm:"type:uuid;not null;index" json:"account_id"`
+	Account      *LedgerAccount `gorm:"foreignKey:AccountID" json:"account,omitempty"`
+	Debit        float64        `gorm:"type:decimal(15,2);default:0" json:"debit"`
+	Credit       float64        `gorm:"type:decimal(15,2);default:0" json:"credit"`
+	CostCenterID *uuid.UUID     `gorm:"type:uuid;index" json:"cost_center_id,omitempty"`
+	ProjectID    *uuid.UUID     `gorm:"type:uuid;index" json:"project_id,omitempty"`
+	Narration    string         `gorm:"type:text" json:"narration,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
+
+// TableName specifies the table name for JournalLine
+func (JournalLine) TableName() string {
+	return "journal_lines"
+}
+
+// BalanceJournal rounds the lines to paise, drops empty ones and checks that each line
+// is either a debit or a credit and that the debits equal the credits. It returns the
+// lines kept and their total.
+func BalanceJournal(lines []JournalLine) ([]JournalLine, float64, error) {
+	kept := make([]JournalLine, 0, len(lines))
+	var debits, credits float64
+	for i, line := range lines {
+		line.Debit = math.Round(line.Debit*100) / 100
+		line.Credit = math.Round(line.Credit*100) / 100
+		switch {
+		case line.Debit < 0 || line.Credit < 0:
+			return nil, 0, fmt.Errorf("line %d: amounts cannot be negative", i+1)
+		case line.Debit > 0 && line.Credit > 0:
+			return nil, 0, fmt.Errorf("line %d: a line is either a debit or a credit", i+1)
+		case line.Debit == 0 && line.Credit == 0:
+			continue
+		case line.AccountID == uuid.Nil:
+			return nil, 0, fmt.Errorf("line %d: account is required", i+1)
+		}
+		debits += line.Debit
+		credits += line.Credit
+		kept = append(kept, line)
+	}
+	debits = math.Round(debits*100) / 100
+	credits = math.Round(credits*100) / 100
+	if len(kept) < 2 {
+		return nil, 0, fmt.Errorf("a journal entry needs at least one debit and one credit")
+	}
+	if debits != credits {
+		return nil, 0, fmt.Errorf("debits (%.2f) and credits (%.2f) do not balance", debits, credits)
+	}
+	return kept, debits, nil
+}
+
+// ReverseLines returns the lines with debits and credits swapped
+func ReverseLines(lines []JournalLine) []JournalLine {
+	reversed := make([]JournalLine, 0, len(lines))
+	for _, line := range lines {
+		reversed = append(reversed, JournalLine{
+			AccountID: line.AccountID, Debit: line.Credit, Credit: line.Debit,
+			CostCenterID: line.CostCenterID, ProjectID: line.ProjectID, Narration: line.Narration,
+		})
+	}
+	return reversed
+}
+
+// LedgerPeriodLock closes a month of a business's books: no entry dated in it can be
+// posted or reversed until it is unlocked
+type LedgerPeriodLock struct {
+	ID                 uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	Period             string    `gorm:"size:7;not null" json:"period"` // YYYY-MM
+	Remarks            string    `gorm:"type:text" json:"remarks,omitempty"`
+	LockedBy           string    `gorm:"size:255;not null" json:"locked_by"`
+	LockedAt           time.Time `json:"locked_at"`
+}
+
+// TableName specifies the table name for LedgerPeriodLock
+func (LedgerPeriodLock) TableName() string {
+	return "ledger_period_locks"
+}
+
+// AccountBalance is an account's debits and credits over a period, for trial balances
+type AccountBalance struct {
+	AccountID uuid.UUID `json:"account_id"`
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Debit     float64   `json:"debit"`
+	Credit    float64   `json:"credit"`
+	Balance   float64   `json:"balance"` // debit less credit for debit-normal accounts, credit less debit otherwise
+}
+
+// Settle sets the balance from the account's debits and credits on its normal side
+func (b *AccountBalance) Settle() {
+	b.Debit = math.Round(b.Debit*100) / 100
+	b.Credit = math.Round(b.Credit*100) / 100
+	if DebitNormal(b.Type) {
+		b.Balance = math.Round((b.Debit-b.Credit)*100) / 100
+	} else {
+		b.Balance = math.Round((b.Credit-b.Debit)*100) / 100
+	}
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestBalanceJournal(t *testing.T) {
+	salaries, payable, dues := uuid.New(), uuid.New(), uuid.New()
+	lines, total, err := BalanceJournal([]JournalLine{
+		{AccountID: salaries, Debit: 50000.004},
+		{AccountID: payable, Credit: 44200},
+		{AccountID: dues, Credit: 5800},
+		{AccountID: dues},
+	})
+	if err != nil {
+		t.Fatalf("balanced journal rejected: %v", err)
+	}
+	if len(lines) != 3 || total != 50000 || lines[0].Debit != 50000 {
+		t.Errorf("unexpected lines %+v totalling %v", lines, total)
+	}
+
+	for _, bad := range [][]JournalLine{
+		{{AccountID: salaries, Debit: 100}, {AccountID: payable, Credit: 99}},
+		{{AccountID: salaries, Debit: 100, Credit: 100}, {AccountID: payable, Credit: 0}},
+		{{AccountID: salaries, Debit: -100}, {AccountID: payable, Credit: -100}},
+		{{AccountID: uuid.Nil, Debit: 100}, {AccountID: payable, Credit: 100}},
+		{{AccountID: salaries, Debit: 100}},
+	} {
+		if _, _, err := BalanceJournal(bad); err == nil {
+			t.Errorf("unbalanced journal accepted: %+v", bad)
+		}
+	}
+
+	reversed := ReverseLines(lines)
+	if reversed[0].Credit != 50000 || reversed[0].Debit != 0 || reversed[1].Debit != 44200 {
+		t.Errorf("unexpected reversal %+v", reversed)
+	}
+}
+
+func TestAccountBalanceSettle(t *testing.T) {
+	bank := AccountBalance{Type: AccountAsset, Debit: 1000, Credit: 250.5}
+	bank.Settle()
+	payables := AccountBalance{Type: AccountLiability, Debit: 100, Credit: 400}
+	payables.Settle()
+	if bank.Balance != 749.5 || payables.Balance != 300 {
+		t.Errorf("unexpected balances %v and %v", bank.Balance, payables.Balance)
+	}
+	if (LedgerAccount{Code: "9000", Name: "Suspense", Type: "memo"}).Validate() == nil {
+		t.Error("unknown account type accepted")
+	}
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StockReferenceIssue marks stock ledger entries issuing material to a project's work
+const StockReferenceIssue = "issue"
+
+// BOQMaterialNorm is how much of a material one unit of a BOQ item consumes, e.g. bags of
+// cement per cubic metre of concrete, with the wastage allowed on top of it
+type BOQMaterialNorm struct {
+	ID              uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BOQItemID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"boq_item_id"`
+	BOQItem         *BOQItem   `gorm:"foreignKey:BOQItemID" json:"boq_item,omitempty"`
+	MaterialCode    string     `gorm:"size:64;not null" json:"material_code"`
+	UOM             string     `gorm:"size:32;not null" json:"uom"`
+	QuantityPerUnit float64    `gorm:"type:decimal(15,6);not null" json:"quantity_per_unit"`
+	WastagePercent  float64    `gorm:"type:decimal(5,2);default:0" json:"wastage_percent"`
+	CreatedBy       string     `gorm:"size:255;not null" json:"created_by"`
+	UpdatedBy       string     `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	DeletedAt       *time.Time `gorm:"index" json:"deleted_at,omitempty"`
+}
+
+// TableName specifies the table name for BOQMaterialNorm
+func (BOQMaterialNorm) TableName() string {
+	return "boq_material_norms"
+}
+
+// MaterialIssued is the net quantity of a material issued to a project's work, less what
+// came back, and its value at the rates it was issued at
+type MaterialIssued struct {
+	MaterialCode string  `json:"material_code"`
+	UOM          string  `json:"uom"`
+	Quantity     float64 `json:"quantity"`
+	Value        float64 `json:"value"`
+}
+
+// MaterialReconciliationLine compares what was issued of a material with what the work
+// executed should have consumed. Excess marks consumption beyond the tolerance.
+type MaterialReconciliationLine struct {
+	MaterialCode        string   `json:"material_code"`
+	UOM                 string   `json:"uom"`
+	IssuedQuantity      float64  `json:"issued_quantity"`
+	IssuedValue         float64  `json:"issued_value"`
+	TheoreticalQuantity float64  `json:"theoretical_quantity"`
+	Variance            float64  `json:"variance"`                   // issued less theoretical
+	VariancePercent     *float64 `json:"variance_percent,omitempty"` // of theoretical, when there is any
+	ExcessValue         float64  `json:"excess_value"`               // the variance at the average issue rate
+	Excess              bool     `json:"excess"`
+	BOQItemCodes        []string `json:"boq_item_codes"`
+}
+
+// ReconcileMaterials works out each material's theoretical consumption from the executed
+// quantities of the BOQ items whose norms use it, including their wastage allowance, and
+// compares it with what was issued. Material issued with no norm to account for it counts
+// wholly as excess. Flagged materials come first, the largest excess value first.
+func ReconcileMaterials(issued []MaterialIssued, items []BOQItem, executed map[uuid.UUID]float64, norms []BOQMaterialNorm, tolerancePercent float64) []MaterialReconciliationLine {
+	codes := make(map[uuid.UUID]string, len(items))
+	for _, item := range items {
+		codes[item.ID] = item.Code
+	}
+
+	lines := map[string]*MaterialReconciliationLine{}
+	line := func(code, uom string) *MaterialReconciliationLine {
+		key := code + "\x00" + uom
+		l, ok := lines[key]
+		if !ok {
+			l = &MaterialReconciliationLine{MaterialCode: code, UOM: uom, BOQItemCodes: []string{}}
+			lines[key] = l
+		}
+		return l
+	}
+	for _, n := range norms {
+		l := line(n.MaterialCode, n.UOM)
+		l.TheoreticalQuantity += executed[n.BOQItemID] * n.QuantityPerUnit * (1 + n.WastagePercent/100)
+		if code, ok := codes[n.BOQItemID]; ok {
+			l.BOQItemCodes = append(l.BOQItemCodes, code)
+		}
+	}
+	for _, i := range issued {
+		l := line(i.MaterialCode, i.UOM)
+		l.IssuedQuantity += i.Quantity
+		l.IssuedValue += i.Value
+	}
+
+	out := make([]MaterialReconciliationLine, 0, len(lines))
+	for _, l := range lines {
+		l.TheoreticalQuantity = math.Round(l.TheoreticalQuantity*10000) / 10000
+		l.IssuedQuantity = math.Round(l.IssuedQuantity*10000) / 10000
+		l.IssuedValue = math.Round(l.IssuedValue*100) / 100
+		l.Variance = math.Round((l.IssuedQuantity-l.TheoreticalQuantity)*10000) / 10000
+		if l.TheoreticalQuantity > 0 {
+			pct := math.Round(l.Variance*10000/l.TheoreticalQuantity) / 100
+			l.VariancePercent = &pct
+			l.Excess = pct > tolerancePercent
+		} else {
+			l.Excess = l.IssuedQuantity > 0
+		}
+		if l.Variance > 0 && l.IssuedQuantity > 0 {
+			l.ExcessValue = math.Round(l.Variance*l.IssuedValue/l.IssuedQuantity*100) / 100
+		}
+		sort.Strings(l.BOQItemCodes)
+		out = append(out, *l)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Excess != out[j].Excess {
+			return out[i].Excess
+		}
+		if out[i].ExcessValue != out[j].ExcessValue {
+			return out[i].ExcessValue > out[j].ExcessValue
+		}
+		return out[i].MaterialCode < out[j].MaterialCode
+	})
+	return out
+}
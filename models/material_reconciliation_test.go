@@ -0,0 +1,52 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestReconcileMaterials(t *testing.T) {
+	concrete := BOQItem{ID: uuid.New(), Code: "2.1"}
+	plaster := BOQItem{ID: uuid.New(), Code: "4.3"}
+	executed := map[uuid.UUID]float64{concrete.ID: 100, plaster.ID: 200}
+	norms := []BOQMaterialNorm{
+		{BOQItemID: concrete.ID, MaterialCode: "CEM-OPC", UOM: "bag", QuantityPerUnit: 8, WastagePercent: 2},
+		{BOQItemID: plaster.ID, MaterialCode: "CEM-OPC", UOM: "bag", QuantityPerUnit: 0.5},
+		{BOQItemID: concrete.ID, MaterialCode: "SAND", UOM: "cum", QuantityPerUnit: 0.45},
+	}
+	issued := []MaterialIssued{
+		{MaterialCode: "CEM-OPC", UOM: "bag", Quantity: 1000, Value: 400000},
+		{MaterialCode: "SAND", UOM: "cum", Quantity: 45.5, Value: 68250},
+		{MaterialCode: "STEEL", UOM: "kg", Quantity: 10, Value: 650},
+	}
+
+	lines := ReconcileMaterials(issued, []BOQItem{concrete, plaster}, executed, norms, 2)
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %+v", lines)
+	}
+
+	// Cement: 100 x 8 x 1.02 + 200 x 0.5 = 916 bags, so 84 issued in excess at 400 each
+	cement := lines[0]
+	if cement.MaterialCode != "CEM-OPC" || cement.TheoreticalQuantity != 916 || cement.Variance != 84 {
+		t.Fatalf("unexpected cement line: %+v", cement)
+	}
+	if !cement.Excess || cement.ExcessValue != 33600 || *cement.VariancePercent != 9.17 {
+		t.Errorf("cement excess not flagged as expected: %+v", cement)
+	}
+	if len(cement.BOQItemCodes) != 2 || cement.BOQItemCodes[0] != "2.1" {
+		t.Errorf("unexpected BOQ items: %v", cement.BOQItemCodes)
+	}
+
+	// Steel has no norm, so all of it is unaccounted for
+	steel := lines[1]
+	if steel.MaterialCode != "STEEL" || !steel.Excess || steel.VariancePercent != nil || steel.ExcessValue != 650 {
+		t.Errorf("unexpected steel line: %+v", steel)
+	}
+
+	// Sand is 1.11% over, within the tolerance
+	sand := lines[2]
+	if sand.MaterialCode != "SAND" || sand.Excess || *sand.VariancePercent != 1.11 {
+		t.Errorf("unexpected sand line: %+v", sand)
+	}
+}
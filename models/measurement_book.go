@@ -0,0 +1,162 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"math"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// Measurement book (MB) entry statuses. An entry is prepared on site, checked by a
+// second engineer and approved before it is billed.
+const (
+	MBStatusPrepared = "prepared"
+	MBStatusChecked  = "checked"
+	MBStatusApproved = "approved"
+	MBStatusRejected = "rejected"
+)
+
+// mbTransitions lists the statuses an MB entry may move to from each status. A rejected
+// entry goes back to prepared when it is corrected.
+var mbTransitions = map[string][]string{
+	MBStatusPrepared: {MBStatusChecked, MBStatusRejected},
+	MBStatusChecked:  {MBStatusApproved, MBStatusRejected},
+	MBStatusRejected: {MBStatusPrepared},
+}
+
+// CanTransitionMB reports whether an MB entry may move from one status to another
+func CanTransitionMB(from, to string) bool {
+	for _, next := range mbTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// MBEditable reports whether an MB entry in the status may still be edited
+func MBEditable(status string) bool {
+	return status == MBStatusPrepared || status == MBStatusRejected
+}
+
+// MBDimension is one measured line of an MB entry, such as 2 nos of 12.5 x 0.6 x 1.2 m
+// of trench. Dimensions left at 0 are not measured and count as 1, so a line with only
+// a length measures a running length. Deductions, such as openings, subtract.
+type MBDimension struct {
+	Description string  `json:"description,omitempty"`
+	Nos         float64 `json:"nos,omitempty"`
+	Length      float64 `json:"length,omitempty"`
+	Breadth     float64 `json:"breadth,omitempty"`
+	Depth       float64 `json:"depth,omitempty"`
+	Deduction   bool    `json:"deduction,omitempty"`
+	Quantity    float64 `json:"quantity"`
+}
+
+// MBDimensions are the measured lines of an MB entry
+type MBDimensions []MBDimension
+
+// Calculate fills in the line's quantity from its dimensions
+func (d *MBDimension) Calculate() {
+	quantity := 1.0
+	for _, factor := range []float64{d.Nos, d.Length, d.Breadth, d.Depth} {
+		if factor != 0 {
+			quantity *= factor
+		}
+	}
+	if d.Deduction {
+		quantity = -quantity
+	}
+	d.Quantity = math.Round(quantity*10000) / 10000
+}
+
+// Calculate fills in each line's quantity and returns their total
+func (ds MBDimensions) Calculate() float64 {
+	var total float64
+	for i := range ds {
+		ds[i].Calculate()
+		total += ds[i].Quantity
+	}
+	return math.Round(total*10000) / 10000
+}
+
+// Scan implements the sql.Scanner interface
+func (ds *MBDimensions) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		*ds = MBDimensions{}
+		return nil
+	}
+	return json.Unmarshal(bytes, ds)
+}
+
+// Value implements the driver.Valuer interface
+func (ds MBDimensions) Value() (driver.Value, error) {
+	if ds == nil {
+		return json.Marshal([]MBDimension{})
+	}
+	return json.Marshal([]MBDimension(ds))
+}
+
+// RABillAbstractLine sums an RA bill's quantities for one BOQ item: what was billed
+// before this bill, on it, and up to date
+type RABillAbstractLine struct {
+	BOQItemID        uuid.UUID `json:"boq_item_id"`
+	Code             string    `json:"code"`
+	Description      string    `json:"description"`
+	UOM              string    `json:"uom"`
+	PlannedQuantity  float64   `json:"planned_quantity"`
+	PreviousQuantity float64   `json:"previous_quantity"`
+	ThisBillQuantity float64   `json:"this_bill_quantity"`
+	UpToDateQuantity float64   `json:"up_to_date_quantity"`
+	ThisBillAmount   float64   `json:"this_bill_amount"`
+	Entries          int       `json:"entries"`
+}
+
+// NewRABillLines turns approved MB entries into RA bill lines, one per entry, and
+// abstracts them by BOQ item in code order. items holds the entries' BOQ items as they
+// stood before the bill.
+func NewRABillLines(entries []MBEntry, items map[uuid.UUID]BOQItem) ([]RABillLine, []RABillAbstractLine, float64) {
+	lines := make([]RABillLine, 0, len(entries))
+	abstract := map[uuid.UUID]*RABillAbstractLine{}
+	var gross float64
+	for _, entry := range entries {
+		entryID := entry.ID
+		amount := math.Round(entry.MeasuredQty*entry.Rate*100) / 100
+		lines = append(lines, RABillLine{
+			BOQItemID:  entry.BOQItemID,
+			MBEntryID:  &entryID,
+			Quantity:   entry.MeasuredQty,
+			Rate:       entry.Rate,
+			Amount:     amount,
+			LineRemark: "MB " + entry.EntryNumber,
+		})
+		gross += amount
+
+		line, ok := abstract[entry.BOQItemID]
+		if !ok {
+			item := items[entry.BOQItemID]
+			line = &RABillAbstractLine{
+				BOQItemID:        entry.BOQItemID,
+				Code:             item.Code,
+				Description:      item.Description,
+				UOM:              item.UOM,
+				PlannedQuantity:  item.PlannedQuantity,
+				PreviousQuantity: item.BilledQuantity,
+			}
+			abstract[entry.BOQItemID] = line
+		}
+		line.Entries++
+		line.ThisBillQuantity += entry.MeasuredQty
+		line.ThisBillAmount += amount
+	}
+
+	abstractLines := make([]RABillAbstractLine, 0, len(abstract))
+	for _, line := range abstract {
+		line.UpToDateQuantity = line.PreviousQuantity + line.ThisBillQuantity
+		abstractLines = append(abstractLines, *line)
+	}
+	sort.Slice(abstractLines, func(i, j int) bool { return abstractLines[i].Code < abstractLines[j].Code })
+	return lines, abstractLines, math.Round(gross*100) / 100
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestMBDimensionsCalculate(t *testing.T) {
+	dims := MBDimensions{
+		{Description: "trench", Nos: 2, Length: 12.5, Breadth: 0.6, Depth: 1.2},
+		{Description: "running length", Length: 40},
+		{Description: "culvert crossing", Length: 3, Breadth: 0.6, Depth: 1.2, Deduction: true},
+	}
+	if got := dims.Calculate(); got != 55.84 {
+		t.Errorf("total = %.4f, want 55.84", got)
+	}
+	if dims[0].Quantity != 18 || dims[1].Quantity != 40 || dims[2].Quantity != -2.16 {
+		t.Errorf("line quantities = %.4f, %.4f, %.4f, want 18, 40, -2.16", dims[0].Quantity, dims[1].Quantity, dims[2].Quantity)
+	}
+}
+
+func TestCanTransitionMB(t *testing.T) {
+	cases := []struct {
+		from, to string
+		want     bool
+	}{
+		{MBStatusPrepared, MBStatusChecked, true},
+		{MBStatusPrepared, MBStatusApproved, false},
+		{MBStatusChecked, MBStatusApproved, true},
+		{MBStatusChecked, MBStatusRejected, true},
+		{MBStatusRejected, MBStatusPrepared, true},
+		{MBStatusApproved, MBStatusRejected, false},
+	}
+	for _, c := range cases {
+		if got := CanTransitionMB(c.from, c.to); got != c.want {
+			t.Errorf("%s -> %s = %v, want %v", c.from, c.to, got, c.want)
+		}
+	}
+}
+
+func TestNewRABillLines(t *testing.T) {
+	earthwork, pipe := uuid.New(), uuid.New()
+	items := map[uuid.UUID]BOQItem{
+		earthwork: {ID: earthwork, Code: "1.1", UOM: "cum", PlannedQuantity: 500, BilledQuantity: 120},
+		pipe:      {ID: pipe, Code: "2.4", UOM: "m", PlannedQuantity: 1000},
+	}
+	entries := []MBEntry{
+		{ID: uuid.New(), BOQItemID: pipe, EntryNumber: "MB-3", MeasuredQty: 250, Rate: 410},
+		{ID: uuid.New(), BOQItemID: earthwork, EntryNumber: "MB-1", MeasuredQty: 55.84, Rate: 312.5},
+		{ID: uuid.New(), BOQItemID: earthwork, EntryNumber: "MB-2", MeasuredQty: 30, Rate: 312.5},
+	}
+
+	lines, abstract, gross := NewRABillLines(entries, items)
+	if len(lines) != 3 || *lines[1].MBEntryID != entries[1].ID || lines[1].Amount != 17450 {
+		t.Fatalf("lines = %+v", lines)
+	}
+	if gross != 129325 {
+		t.Errorf("gross = %.2f, want 129325", gross)
+	}
+	if len(abstract) != 2 || abstract[0].Code != "1.1" {
+		t.Fatalf("abstract = %+v", abstract)
+	}
+	if got := abstract[0]; got.Entries != 2 || got.PreviousQuantity != 120 || got.ThisBillQuantity != 85.84 || got.UpToDateQuantity != 205.84 {
+		t.Errorf("earthwork abstract = %+v", got)
+	}
+}
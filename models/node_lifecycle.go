@@ -0,0 +1,79 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+const (
+	NodeStatusAvailable  = "available"
+	NodeStatusAllocated  = "allocated"
+	NodeStatusInProgress = "in-progress"
+	NodeStatusCompleted  = "completed"
+)
+
+// nodeTransitions lists the statuses a node may move to from each status. Allocated and
+// in-progress nodes go back to available when their task is cancelled; a completed node
+// goes back to in-progress only when its task is reopened for rework.
+var nodeTransitions = map[string][]string{
+	NodeStatusAvailable:  {NodeStatusAllocated},
+	NodeStatusAllocated:  {NodeStatusAvailable, NodeStatusInProgress, NodeStatusCompleted},
+	NodeStatusInProgress: {NodeStatusAvailable, NodeStatusAllocated, NodeStatusCompleted},
+	NodeStatusCompleted:  {NodeStatusInProgress},
+}
+
+// CanTransitionNode reports whether a node may move from one status to another. Staying
+// in the same status is always allowed.
+func CanTransitionNode(from, to string) bool {
+	if from == to {
+		return true
+	}
+	for _, next := range nodeTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// NodeStatusForTask is the status a task in the given status puts its nodes in. It is
+// empty for statuses, such as on-hold, that leave the nodes as they are.
+func NodeStatusForTask(taskStatus string) string {
+	switch taskStatus {
+	case "pending", "assigned":
+		return NodeStatusAllocated
+	case "in-progress":
+		return NodeStatusInProgress
+	case "completed":
+		return NodeStatusCompleted
+	case "cancelled":
+		return NodeStatusAvailable
+	}
+	return ""
+}
+
+// NodeConflictError is returned when a task would take a node another open task holds
+type NodeConflictError struct {
+	NodeID   uuid.UUID
+	NodeName string
+	TaskID   uuid.UUID
+	TaskCode string
+}
+
+func (e *NodeConflictError) Error() string {
+	return fmt.Sprintf("node %s is already allocated to task %s", e.NodeName, e.TaskCode)
+}
+
+// NodeTransitionError is returned when a task would move a node to a status its current
+// one does not lead to
+type NodeTransitionError struct {
+	NodeID   uuid.UUID
+	NodeName string
+	From     string
+	To       string
+}
+
+func (e *NodeTransitionError) Error() string {
+	return fmt.Sprintf("node %s cannot go from %s to %s", e.NodeName, e.From, e.To)
+}
@@ -0,0 +1,37 @@
+package models
+
+import "testing"
+
+func TestCanTransitionNode(t *testing.T) {
+	cases := []struct {
+		from, to string
+		want     bool
+	}{
+		{NodeStatusAvailable, NodeStatusAllocated, true},
+		{NodeStatusAvailable, NodeStatusCompleted, false},
+		{NodeStatusAllocated, NodeStatusInProgress, true},
+		{NodeStatusInProgress, NodeStatusAvailable, true},
+		{NodeStatusCompleted, NodeStatusAllocated, false},
+		{NodeStatusCompleted, NodeStatusInProgress, true},
+		{NodeStatusCompleted, NodeStatusCompleted, true},
+	}
+	for _, c := range cases {
+		if got := CanTransitionNode(c.from, c.to); got != c.want {
+			t.Errorf("CanTransitionNode(%s, %s) = %v, want %v", c.from, c.to, got, c.want)
+		}
+	}
+}
+
+func TestNodeStatusForTask(t *testing.T) {
+	for status, want := range map[string]string{
+		"pending":     NodeStatusAllocated,
+		"in-progress": NodeStatusInProgress,
+		"completed":   NodeStatusCompleted,
+		"cancelled":   NodeStatusAvailable,
+		"on-hold":     "",
+	} {
+		if got := NodeStatusForTask(status); got != want {
+			t.Errorf("NodeStatusForTask(%s) = %q, want %q", status, got, want)
+		}
+	}
+}
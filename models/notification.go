@@ -23,6 +23,16 @@ const (
 	NotificationTypeSystemAlert        NotificationType = "system_alert"
 	NotificationTypeChatMessage        NotificationType = "chat_message"
 	NotificationTypeChatMention        NotificationType = "chat_mention"
+	NotificationTypeEmergency          NotificationType = "emergency_broadcast"
+	NotificationTypeApprovalDelegation NotificationType = "approval_delegation"
+	NotificationTypeTaskComment        NotificationType = "task_comment"
+	NotificationTypeTaskMention        NotificationType = "task_mention"
+	NotificationTypeTaskAttachment     NotificationType = "task_attachment"
+	NotificationTypeBudgetAlert        NotificationType = "budget_alert"
+	NotificationTypeTelemetryAlarm     NotificationType = "telemetry_alarm"
+	NotificationTypeDigest             NotificationType = "notification_digest"
+	NotificationTypeAnnouncement       NotificationType = "announcement"
+	NotificationTypeReminder           NotificationType = "reminder"
 )
 
 // NotificationChannel defines how notification is delivered
@@ -34,6 +44,7 @@ const (
 	NotificationChannelSMS        NotificationChannel = "sms"
 	NotificationChannelWebPush    NotificationChannel = "web_push"
 	NotificationChannelMobilePush NotificationChannel = "mobile_push"
+	NotificationChannelWhatsApp   NotificationChannel = "whatsapp"
 )
 
 // NotificationStatus defines the status of a notification
@@ -178,6 +189,11 @@ type Notification struct {
 	// Grouping (for batching similar notifications)
 	GroupKey string `gorm:"size:200;index" json:"group_key,omitempty"`
 
+	// Digest batching: held for the user's hourly or daily digest instead of being pushed,
+	// then linked to the digest that summarised it
+	DigestFrequency string     `gorm:"size:10" json:"digest_frequency,omitempty"`
+	DigestID        *uuid.UUID `gorm:"type:uuid;index" json:"digest_id,omitempty"`
+
 	// Timestamps
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
@@ -208,6 +224,22 @@ func (n *Notification) MarkAsSent() {
 	n.Status = NotificationStatusSent
 }
 
+// Archive moves the notification out of the user's list; archiving marks it read
+func (n *Notification) Archive() {
+	now := time.Now()
+	if n.ReadAt == nil {
+		n.ReadAt = &now
+	}
+	n.ArchivedAt = &now
+	n.Status = NotificationStatusArchived
+}
+
+// Unarchive returns an archived notification to the user's list
+func (n *Notification) Unarchive() {
+	n.ArchivedAt = nil
+	n.Status = NotificationStatusRead
+}
+
 // MarkAsFailed marks the notification as failed
 func (n *Notification) MarkAsFailed(reason string) {
 	n.Status = NotificationStatusFailed
@@ -275,15 +307,22 @@ type NotificationPreference struct {
 
 	// Type preferences (can disable specific types)
 	DisabledTypes StringArray `gorm:"type:jsonb;default:'[]'" json:"disabled_types"`
+	// Types kept in the app but not pushed to the user's phones
+	MobilePushDisabledTypes StringArray `gorm:"type:jsonb;default:'[]'" json:"mobile_push_disabled_types"`
 
 	// Quiet hours
 	QuietHoursEnabled bool   `gorm:"default:false" json:"quiet_hours_enabled"`
 	QuietHoursStart   string `gorm:"size:5" json:"quiet_hours_start,omitempty"` // HH:MM format
 	QuietHoursEnd     string `gorm:"size:5" json:"quiet_hours_end,omitempty"`   // HH:MM format
 
-	// Digest settings
-	DigestEnabled   bool   `gorm:"default:false" json:"digest_enabled"`
-	DigestFrequency string `gorm:"size:20" json:"digest_frequency,omitempty"` // daily, weekly
+	// Digest settings: notifications of the types in DigestTypes that are not urgent are
+	// batched into one summary per period instead of being pushed one by one. DigestTypes
+	// maps a type to hourly or daily, or to "" for DigestFrequency; daily digests are sent
+	// at DigestTime.
+	DigestEnabled   bool    `gorm:"default:false" json:"digest_enabled"`
+	DigestFrequency string  `gorm:"size:20" json:"digest_frequency,omitempty"` // hourly, daily
+	DigestTypes     JSONMap `gorm:"type:jsonb" json:"digest_types,omitempty"`
+	DigestTime      string  `gorm:"size:5" json:"digest_time,omitempty"` // HH:MM format, default 08:00
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
@@ -314,6 +353,7 @@ type NotificationDTO struct {
 	Metadata       map[string]interface{} `json:"metadata,omitempty"`
 	CreatedAt      time.Time              `json:"created_at"`
 	ReadAt         *time.Time             `json:"read_at,omitempty"`
+	ArchivedAt     *time.Time             `json:"archived_at,omitempty"`
 }
 
 // ToDTO converts Notification to DTO
@@ -334,5 +374,6 @@ func (n *Notification) ToDTO() NotificationDTO {
 		Metadata:       n.Metadata,
 		CreatedAt:      n.CreatedAt,
 		ReadAt:         n.ReadAt,
+		ArchivedAt:     n.ArchivedAt,
 	}
 }
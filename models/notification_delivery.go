@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Delivery attempt outcomes
+const (
+	DeliveryAttemptSucceeded = "succeeded"
+	DeliveryAttemptFailed    = "failed"
+	DeliveryAttemptSkipped   = "skipped"  // muted by the user, or nowhere to deliver to
+	DeliveryAttemptRequeued  = "requeued" // an admin put a failed delivery back in the queue
+)
+
+// NotificationDeliveryAttempt audits one try at delivering a queued push or text: what
+// happened, why it failed and whether the failure was worth retrying. DeliveryID is the
+// push_deliveries or sms_messages row, depending on Channel.
+type NotificationDeliveryAttempt struct {
+	ID             uuid.UUID           `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Channel        NotificationChannel `gorm:"size:20;not null;index:idx_delivery_attempts_delivery,priority:1" json:"channel"`
+	DeliveryID     uuid.UUID           `gorm:"type:uuid;not null;index:idx_delivery_attempts_delivery,priority:2" json:"delivery_id"`
+	NotificationID *uuid.UUID          `gorm:"type:uuid;index" json:"notification_id,omitempty"` // none for texts not tied to a notification
+	UserID         string              `gorm:"size:255;index" json:"user_id,omitempty"`
+	Attempt        int                 `gorm:"not null" json:"attempt"`
+	Status         string              `gorm:"size:20;not null;index" json:"status"`
+	Transient      bool                `gorm:"not null;default:false" json:"transient"` // the failure may clear on retry
+	Error          string              `gorm:"type:text" json:"error,omitempty"`
+	Provider       string              `gorm:"size:20" json:"provider,omitempty"`
+	RequeuedBy     *uuid.UUID          `gorm:"type:uuid" json:"requeued_by,omitempty"`
+	StartedAt      time.Time           `gorm:"not null" json:"started_at"`
+	DurationMS     int64               `json:"duration_ms"`
+	CreatedAt      time.Time           `gorm:"index" json:"created_at"`
+}
+
+func (NotificationDeliveryAttempt) TableName() string {
+	return "notification_delivery_attempts"
+}
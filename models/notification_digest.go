@@ -0,0 +1,135 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Digest frequencies
+const (
+	DigestHourly = "hourly"
+	DigestDaily  = "daily"
+)
+
+// defaultDigestTime is when daily digests go out unless the user picks another time
+const defaultDigestTime = "08:00"
+
+// maxDigestHeadlines is how many notification titles a digest lists
+const maxDigestHeadlines = 5
+
+// ValidDigestFrequency reports whether f is a supported digest frequency
+func ValidDigestFrequency(f string) bool {
+	return f == DigestHourly || f == DigestDaily
+}
+
+// NotificationDigest is one summary sent to a user in place of the notifications it
+// batched over a period.
+type NotificationDigest struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID         string     `gorm:"size:255;not null;index" json:"user_id"`
+	Frequency      string     `gorm:"size:10;not null" json:"frequency"`
+	PeriodStart    time.Time  `gorm:"not null" json:"period_start"` // oldest batched notification
+	PeriodEnd      time.Time  `gorm:"not null" json:"period_end"`
+	ItemCount      int        `gorm:"not null" json:"item_count"`
+	UnreadCount    int        `gorm:"not null" json:"unread_count"`
+	NotificationID *uuid.UUID `gorm:"type:uuid" json:"notification_id,omitempty"` // the summary; none when all were read already
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func (NotificationDigest) TableName() string {
+	return "notification_digests"
+}
+
+// DigestFrequencyFor returns how the user batches notifications of the type and priority,
+// or "" when they are sent on their own. High and critical notifications, emergency
+// broadcasts and digests themselves are never batched.
+func (p *NotificationPreference) DigestFrequencyFor(t NotificationType, priority NotificationPriority) string {
+	if !p.DigestEnabled || t == NotificationTypeEmergency || t == NotificationTypeDigest ||
+		priority == NotificationPriorityHigh || priority == NotificationPriorityCritical {
+		return ""
+	}
+	raw, ok := p.DigestTypes[string(t)]
+	if !ok {
+		return ""
+	}
+	frequency, _ := raw.(string)
+	if frequency == "" {
+		frequency = p.DigestFrequency
+	}
+	if !ValidDigestFrequency(frequency) {
+		return DigestDaily
+	}
+	return frequency
+}
+
+// DigestDueAt returns when the digest holding notifications since oldest is sent: at the
+// top of the next hour for hourly digests, and at the user's digest time for daily ones.
+// Times are read in oldest's location.
+func (p *NotificationPreference) DigestDueAt(frequency string, oldest time.Time) time.Time {
+	loc := oldest.Location()
+	if frequency == DigestHourly {
+		return time.Date(oldest.Year(), oldest.Month(), oldest.Day(), oldest.Hour(), 0, 0, 0, loc).Add(time.Hour)
+	}
+
+	at, err := time.Parse("15:04", p.DigestTime)
+	if err != nil {
+		at, _ = time.Parse("15:04", defaultDigestTime)
+	}
+	due := time.Date(oldest.Year(), oldest.Month(), oldest.Day(), at.Hour(), at.Minute(), 0, 0, loc)
+	if !due.After(oldest) {
+		due = due.AddDate(0, 0, 1)
+	}
+	return due
+}
+
+// DigestSummary composes a digest's title and body from the notifications it batches,
+// newest first: counts per type, then the latest few titles.
+func DigestSummary(items []Notification, frequency string) (string, string) {
+	counts := map[NotificationType]int{}
+	for _, n := range items {
+		counts[n.Type]++
+	}
+	types := make([]NotificationType, 0, len(counts))
+	for t := range counts {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if counts[types[i]] != counts[types[j]] {
+			return counts[types[i]] > counts[types[j]]
+		}
+		return types[i] < types[j]
+	})
+
+	plural := func(n int, word string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s", word)
+		}
+		return fmt.Sprintf("%d %ss", n, word)
+	}
+	parts := make([]string, len(types))
+	for i, t := range types {
+		parts[i] = plural(counts[t], strings.ReplaceAll(string(t), "_", " "))
+	}
+
+	period := "in the last day"
+	if frequency == DigestHourly {
+		period = "in the last hour"
+	}
+	title := fmt.Sprintf("%s %s", plural(len(items), "notification"), period)
+
+	var b strings.Builder
+	b.WriteString(strings.Join(parts, ", "))
+	for i, n := range items {
+		if i == maxDigestHeadlines {
+			fmt.Fprintf(&b, "\n…and %d more", len(items)-maxDigestHeadlines)
+			break
+		}
+		b.WriteString("\n• ")
+		b.WriteString(n.Title)
+	}
+	return title, b.String()
+}
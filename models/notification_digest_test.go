@@ -0,0 +1,70 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDigestFrequencyFor(t *testing.T) {
+	prefs := NotificationPreference{
+		DigestEnabled:   true,
+		DigestFrequency: DigestDaily,
+		DigestTypes:     JSONMap{"chat_message": DigestHourly, "telemetry_alarm": ""},
+	}
+	tests := []struct {
+		t        NotificationType
+		priority NotificationPriority
+		want     string
+	}{
+		{NotificationTypeChatMessage, NotificationPriorityNormal, DigestHourly},
+		{NotificationTypeTelemetryAlarm, NotificationPriorityLow, DigestDaily},
+		{NotificationTypeTelemetryAlarm, NotificationPriorityHigh, ""},
+		{NotificationTypeWorkflowTransition, NotificationPriorityNormal, ""},
+		{NotificationTypeDigest, NotificationPriorityNormal, ""},
+	}
+	for _, tt := range tests {
+		if got := prefs.DigestFrequencyFor(tt.t, tt.priority); got != tt.want {
+			t.Errorf("%s %s batched %q, want %q", tt.t, tt.priority, got, tt.want)
+		}
+	}
+
+	prefs.DigestEnabled = false
+	if got := prefs.DigestFrequencyFor(NotificationTypeChatMessage, NotificationPriorityNormal); got != "" {
+		t.Errorf("batched %q with digests off", got)
+	}
+}
+
+func TestDigestDueAt(t *testing.T) {
+	loc := time.FixedZone("IST", 5*3600+1800)
+	oldest := time.Date(2026, 10, 16, 14, 20, 0, 0, loc)
+
+	prefs := NotificationPreference{DigestTime: "18:30"}
+	if got := prefs.DigestDueAt(DigestHourly, oldest); !got.Equal(time.Date(2026, 10, 16, 15, 0, 0, 0, loc)) {
+		t.Errorf("hourly digest due %v, want 15:00", got)
+	}
+	if got := prefs.DigestDueAt(DigestDaily, oldest); !got.Equal(time.Date(2026, 10, 16, 18, 30, 0, 0, loc)) {
+		t.Errorf("daily digest due %v, want 18:30 the same day", got)
+	}
+
+	var defaults NotificationPreference
+	if got := defaults.DigestDueAt(DigestDaily, oldest); !got.Equal(time.Date(2026, 10, 17, 8, 0, 0, 0, loc)) {
+		t.Errorf("daily digest due %v, want 08:00 the next day", got)
+	}
+}
+
+func TestDigestSummary(t *testing.T) {
+	var items []Notification
+	for i := 0; i < 6; i++ {
+		items = append(items, Notification{Type: NotificationTypeChatMessage, Title: "Message"})
+	}
+	items = append(items, Notification{Type: NotificationTypeTelemetryAlarm, Title: "Low alarm: tank level"})
+
+	title, body := DigestSummary(items, DigestHourly)
+	if title != "7 notifications in the last hour" {
+		t.Errorf("title = %q", title)
+	}
+	if !strings.HasPrefix(body, "6 chat messages, 1 telemetry alarm\n") || !strings.HasSuffix(body, "…and 2 more") {
+		t.Errorf("body = %q", body)
+	}
+}
@@ -0,0 +1,560 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Payroll runs are approved through the payroll approval workflow: drafted and computed,
+// submitted, then approved under payroll:approve, or rejected and revised back to draft.
+const (
+	PayrollWorkflowCode = "payroll_approval"
+	PayrollDraft        = "draft"
+	PayrollApproved     = "approved"
+)
+
+// PayrollWorkflowDefinition returns the payroll approval workflow. Submitting and revising
+// a run needs payroll:generate; approving or rejecting it needs payroll:approve.
+func PayrollWorkflowDefinition() WorkflowDefinition {
+	return WorkflowDefinition{
+		Code:         PayrollWorkflowCode,
+		Name:         "Payroll Approval Workflow",
+		Description:  "Approval of monthly payroll runs before salaries are paid",
+		Version:      "1.0.0",
+		InitialState: PayrollDraft,
+		States: []byte(`[
+			{"code": "draft", "name": "Draft", "description": "Computed and open to recomputation", "color": "gray", "is_final": false},
+			{"code": "submitted", "name": "Submitted", "description": "Awaiting payroll approval", "color": "blue", "is_final": false},
+			{"code": "approved", "name": "Approved", "description": "Approved; payslips released", "color": "green", "is_final": true},
+			{"code": "rejected", "name": "Rejected", "description": "Sent back for correction", "color": "red", "is_final": false}
+		]`),
+		Transitions: []byte(`[
+			{"from": "draft", "to": "submitted", "action": "submit", "label": "Submit for Approval", "required_permission": ""},
+			{"from": "submitted", "to": "approved", "action": "approve", "label": "Approve", "required_permission": "payroll:approve",
+				"notifications": [{"title_template": "Payroll approved", "body_template": "Payroll run {{.SubmissionID}} has been approved by {{.ApproverName}}.", "channels": ["in_app"], "priority": "high", "recipients": [{"type": "submitter"}]}]},
+			{"from": "submitted", "to": "rejected", "action": "reject", "label": "Reject", "required_permission": "payroll:approve",
+				"notifications": [{"title_template": "Payroll rejected", "body_template": "Payroll run {{.SubmissionID}} was rejected by {{.ApproverName}}. Comment: {{.Comment}}", "channels": ["in_app"], "priority": "high", "recipients": [{"type": "submitter"}]}]},
+			{"from": "rejected", "to": "draft", "action": "revise", "label": "Revise", "required_permission": ""}
+		]`),
+		IsActive: true,
+	}
+}
+
+// Salary component kinds
+const (
+	SalaryEarning   = "earning"
+	SalaryDeduction = "deduction"
+)
+
+// Leave statuses
+const (
+	LeaveApproved  = "approved"
+	LeaveCancelled = "cancelled"
+)
+
+// SalaryComponent is a monthly earning or deduction in an employee's salary structure
+type SalaryComponent struct {
+	Code          string  `json:"code"`
+	Name          string  `json:"name"`
+	Kind          string  `json:"kind"`
+	MonthlyAmount float64 `json:"monthly_amount"`
+	Prorated      bool    `json:"prorated"` // earned in proportion to the days paid
+	PFWage        bool    `json:"pf_wage"`  // counts towards the PF wage, as basic and DA do
+}
+
+// SalaryComponents is a JSONB list of salary components
+type SalaryComponents []SalaryComponent
+
+// Scan implements the sql.Scanner interface
+func (sc *SalaryComponents) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		*sc = SalaryComponents{}
+		return nil
+	}
+	return json.Unmarshal(bytes, sc)
+}
+
+// Value implements the driver.Valuer interface
+func (sc SalaryComponents) Value() (driver.Value, error) {
+	if sc == nil {
+		return json.Marshal([]SalaryComponent{})
+	}
+	return json.Marshal([]SalaryComponent(sc))
+}
+
+// SalaryStructure is an employee's pay from EffectiveFrom until a later structure takes
+// over, and which statutory deductions apply to them
+type SalaryStructure struct {
+	ID                 uuid.UUID        `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID        `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	UserID             string           `gorm:"size:255;not null;index" json:"user_id"`
+	EmployeeName       string           `gorm:"size:255;not null" json:"employee_name"`
+	EmployeeCode       string           `gorm:"size:64" json:"employee_code,omitempty"`
+	EffectiveFrom      time.Time        `gorm:"type:date;not null" json:"effective_from"`
+	Components         SalaryComponents `gorm:"type:jsonb;not null;default:'[]'" json:"components"`
+	PFApplicable       bool             `gorm:"default:true" json:"pf_applicable"`
+	ESIApplicable      bool             `gorm:"default:true" json:"esi_applicable"`
+	PTApplicable       bool             `gorm:"default:true" json:"pt_applicable"`
+	TDSApplicable      bool             `gorm:"default:true" json:"tds_applicable"`
+	OvertimeHourlyRate float64          `gorm:"type:decimal(10,2);default:0" json:"overtime_hourly_rate"` // 0 for no overtime pay
+	IsActive           bool             `gorm:"default:true;index" json:"is_active"`
+	CreatedBy          string           `gorm:"size:255;not null" json:"created_by"`
+	UpdatedBy          string           `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt          time.Time        `json:"created_at"`
+	UpdatedAt          time.Time        `json:"updated_at"`
+}
+
+// TableName specifies the table name for SalaryStructure
+func (SalaryStructure) TableName() string {
+	return "salary_structures"
+}
+
+// Validate checks the structure's components
+func (s SalaryStructure) Validate() error {
+	if s.UserID == "" || s.EmployeeName == "" || s.EffectiveFrom.IsZero() {
+		return fmt.Errorf("user_id, employee_name and effective_from are required")
+	}
+	codes := map[string]bool{}
+	earnings := 0
+	for _, c := range s.Components {
+		switch {
+		case c.Code == "" || c.Name == "":
+			return fmt.Errorf("each component needs a code and a name")
+		case codes[c.Code]:
+			return fmt.Errorf("component %s appears twice", c.Code)
+		case c.Kind != SalaryEarning && c.Kind != SalaryDeduction:
+			return fmt.Errorf("component %s: kind must be earning or deduction", c.Code)
+		case c.MonthlyAmount < 0:
+			return fmt.Errorf("component %s: monthly_amount cannot be negative", c.Code)
+		}
+		codes[c.Code] = true
+		if c.Kind == SalaryEarning {
+			earnings++
+		}
+	}
+	if earnings == 0 {
+		return fmt.Errorf("a salary structure needs at least one earning")
+	}
+	if s.OvertimeHourlyRate < 0 {
+		return fmt.Errorf("overtime_hourly_rate cannot be negative")
+	}
+	return nil
+}
+
+// MonthlyGross is the structure's earnings for a full month
+func (s SalaryStructure) MonthlyGross() float64 {
+	gross := 0.0
+	for _, c := range s.Components {
+		if c.Kind == SalaryEarning {
+			gross += c.MonthlyAmount
+		}
+	}
+	return gross
+}
+
+// PayrollSlab is a band of a slab rule, up to UpTo (0 for no upper bound), charged at
+// Rate percent of the income within it or a fixed Amount
+type PayrollSlab struct {
+	UpTo   float64 `json:"up_to"`
+	Rate   float64 `json:"rate,omitempty"`
+	Amount float64 `json:"amount,omitempty"`
+}
+
+// PayrollSlabs is a JSONB list of slabs in ascending order
+type PayrollSlabs []PayrollSlab
+
+// Scan implements the sql.Scanner interface
+func (ps *PayrollSlabs) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		*ps = PayrollSlabs{}
+		return nil
+	}
+	return json.Unmarshal(bytes, ps)
+}
+
+// Value implements the driver.Valuer interface
+func (ps PayrollSlabs) Value() (driver.Value, error) {
+	if ps == nil {
+		return json.Marshal([]PayrollSlab{})
+	}
+	return json.Marshal([]PayrollSlab(ps))
+}
+
+// FixedAmount returns the Amount of the slab income falls in
+func (ps PayrollSlabs) FixedAmount(income float64) float64 {
+	for _, slab := range ps {
+		if slab.UpTo == 0 || income <= slab.UpTo {
+			return slab.Amount
+		}
+	}
+	return 0
+}
+
+// MarginalTax charges each part of income at the rate of the slab it falls in
+func (ps PayrollSlabs) MarginalTax(income float64) float64 {
+	tax, lower := 0.0, 0.0
+	for _, slab := range ps {
+		if slab.UpTo == 0 || income <= slab.UpTo {
+			return tax + math.Max(0, income-lower)*slab.Rate/100
+		}
+		tax += (slab.UpTo - lower) * slab.Rate / 100
+		lower = slab.UpTo
+	}
+	return tax
+}
+
+// PayrollStatutoryRules configures a business's statutory deductions: provident fund,
+// employees' state insurance, professional tax and income tax deducted at source
+type PayrollStatutoryRules struct {
+	ID                   uuid.UUID    `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID   uuid.UUID    `gorm:"type:uuid;not null;uniqueIndex" json:"business_vertical_id"`
+	PFEmployeePercent    float64      `gorm:"type:decimal(5,2)" json:"pf_employee_percent"`
+	PFEmployerPercent    float64      `gorm:"type:decimal(5,2)" json:"pf_employer_percent"`
+	PFWageCeiling        float64      `gorm:"type:decimal(15,2)" json:"pf_wage_ceiling"` // 0 for none
+	ESIEmployeePercent   float64      `gorm:"type:decimal(5,2)" json:"esi_employee_percent"`
+	ESIEmployerPercent   float64      `gorm:"type:decimal(5,2)" json:"esi_employer_percent"`
+	ESIGrossCeiling      float64      `gorm:"type:decimal(15,2)" json:"esi_gross_ceiling"` // ESI applies up to this monthly gross
+	PTSlabs              PayrollSlabs `gorm:"type:jsonb;default:'[]'" json:"pt_slabs"`     // fixed amounts by monthly gross
+	TDSStandardDeduction float64      `gorm:"type:decimal(15,2)" json:"tds_standard_deduction"`
+	TDSSlabs             PayrollSlabs `gorm:"type:jsonb;default:'[]'" json:"tds_slabs"`   // marginal rates by annual taxable income
+	TDSRebateLimit       float64      `gorm:"type:decimal(15,2)" json:"tds_rebate_limit"` // no tax up to this taxable income
+	TDSCessPercent       float64      `gorm:"type:decimal(5,2)" json:"tds_cess_percent"`
+	UpdatedBy            string       `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt            time.Time    `json:"created_at"`
+	UpdatedAt            time.Time    `json:"updated_at"`
+}
+
+// TableName specifies the table name for PayrollStatutoryRules
+func (PayrollStatutoryRules) TableName() string {
+	return "payroll_statutory_rules"
+}
+
+// DefaultPayrollStatutoryRules returns the statutory rules a business uses until it sets
+// its own: the PF and ESI rates and ceilings, Telangana professional tax and the new
+// income tax regime
+func DefaultPayrollStatutoryRules(businessID uuid.UUID) PayrollStatutoryRules {
+	return PayrollStatutoryRules{
+		BusinessVerticalID:   businessID,
+		PFEmployeePercent:    12,
+		PFEmployerPercent:    12,
+		PFWageCeiling:        15000,
+		ESIEmployeePercent:   0.75,
+		ESIEmployerPercent:   3.25,
+		ESIGrossCeiling:      21000,
+		PTSlabs:              PayrollSlabs{{UpTo: 15000}, {UpTo: 20000, Amount: 150}, {Amount: 200}},
+		TDSStandardDeduction: 75000,
+		TDSSlabs: PayrollSlabs{
+			{UpTo: 400000}, {UpTo: 800000, Rate: 5}, {UpTo: 1200000, Rate: 10}, {UpTo: 1600000, Rate: 15},
+			{UpTo: 2000000, Rate: 20}, {UpTo: 2400000, Rate: 25}, {Rate: 30},
+		},
+		TDSRebateLimit: 1200000,
+		TDSCessPercent: 4,
+	}
+}
+
+// Validate checks the rates are percentages and each slab list rises to an unbounded slab
+func (r PayrollStatutoryRules) Validate() error {
+	for name, pct := range map[string]float64{
+		"pf_employee_percent": r.PFEmployeePercent, "pf_employer_percent": r.PFEmployerPercent,
+		"esi_employee_percent": r.ESIEmployeePercent, "esi_employer_percent": r.ESIEmployerPercent,
+		"tds_cess_percent": r.TDSCessPercent,
+	} {
+		if pct < 0 || pct > 100 {
+			return fmt.Errorf("%s must be between 0 and 100", name)
+		}
+	}
+	if r.PFWageCeiling < 0 || r.ESIGrossCeiling < 0 || r.TDSStandardDeduction < 0 || r.TDSRebateLimit < 0 {
+		return fmt.Errorf("ceilings, deductions and limits cannot be negative")
+	}
+	for name, slabs := range map[string]PayrollSlabs{"pt_slabs": r.PTSlabs, "tds_slabs": r.TDSSlabs} {
+		lower := 0.0
+		for i, slab := range slabs {
+			last := i == len(slabs)-1
+			switch {
+			case slab.Rate < 0 || slab.Rate > 100 || slab.Amount < 0:
+				return fmt.Errorf("%s: slab %d has a negative amount or a rate outside 0 to 100", name, i+1)
+			case last && slab.UpTo != 0:
+				return fmt.Errorf("%s: the last slab must have no upper bound", name)
+			case !last && slab.UpTo <= lower:
+				return fmt.Errorf("%s: slabs must rise in order", name)
+			}
+			lower = slab.UpTo
+		}
+	}
+	return nil
+}
+
+// EmployeeLeave is leave approved for an employee, paid or not, over whole days or half a
+// day. HR records it directly, or it comes from an approved leave application.
+type EmployeeLeave struct {
+	ID                 uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID  `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	UserID             string     `gorm:"size:255;not null;index" json:"user_id"`
+	LeaveType          string     `gorm:"size:32;not null" json:"leave_type"` // casual, sick, earned, unpaid, ...
+	FromDate           time.Time  `gorm:"type:date;not null;index" json:"from_date"`
+	ToDate             time.Time  `gorm:"type:date;not null;index" json:"to_date"`
+	Paid               bool       `gorm:"default:true" json:"paid"`
+	HalfDay            bool       `gorm:"default:false" json:"half_day"`
+	Status             string     `gorm:"size:20;not null;default:'approved';index" json:"status"`
+	Reason             string     `gorm:"type:text" json:"reason,omitempty"`
+	ApplicationID      *uuid.UUID `gorm:"type:uuid;index" json:"application_id,omitempty"` // the leave application approved
+	RecordedBy         string     `gorm:"size:255;not null" json:"recorded_by"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for EmployeeLeave
+func (EmployeeLeave) TableName() string {
+	return "employee_leaves"
+}
+
+// PayrollRun is a business's payroll for a month, with a payslip for each employee who
+// has a salary structure
+type PayrollRun struct {
+	ID                         uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID         uuid.UUID  `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	Period                     string     `gorm:"size:7;not null" json:"period"` // YYYY-MM
+	WorkingDays                int        `gorm:"not null" json:"working_days"`
+	WorkflowID                 *uuid.UUID `gorm:"type:uuid" json:"workflow_id,omitempty"`
+	CurrentState               string     `gorm:"size:50;not null;default:'draft';index" json:"current_state"`
+	EmployeeCount              int        `json:"employee_count"`
+	TotalGross                 float64    `gorm:"type:decimal(15,2);default:0" json:"total_gross"`
+	TotalDeductions            float64    `gorm:"type:decimal(15,2);default:0" json:"total_deductions"`
+	TotalNetPay                float64    `gorm:"type:decimal(15,2);default:0" json:"total_net_pay"`
+	TotalEmployerContributions float64    `gorm:"type:decimal(15,2);default:0" json:"total_employer_contributions"`
+	ComputedAt                 *time.Time `json:"computed_at,omitempty"`
+	CreatedBy                  string     `gorm:"size:255;not null" json:"created_by"`
+	UpdatedBy                  string     `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt                  time.Time  `json:"created_at"`
+	UpdatedAt                  time.Time  `json:"updated_at"`
+	Payslips                   []Payslip  `gorm:"foreignKey:RunID" json:"payslips,omitempty"`
+}
+
+// TableName specifies the table name for PayrollRun
+func (PayrollRun) TableName() string {
+	return "payroll_runs"
+}
+
+// PayslipLine is an amount on a payslip
+type PayslipLine struct {
+	Code   string  `json:"code"`
+	Name   string  `json:"name"`
+	Amount float64 `json:"amount"`
+}
+
+// PayslipLines is a JSONB list of payslip lines
+type PayslipLines []PayslipLine
+
+// Scan implements the sql.Scanner interface
+func (pl *PayslipLines) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		*pl = PayslipLines{}
+		return nil
+	}
+	return json.Unmarshal(bytes, pl)
+}
+
+// Value implements the driver.Valuer interface
+func (pl PayslipLines) Value() (driver.Value, error) {
+	if pl == nil {
+		return json.Marshal([]PayslipLine{})
+	}
+	return json.Marshal([]PayslipLine(pl))
+}
+
+// Payslip is an employee's pay for a payroll run's month
+type Payslip struct {
+	ID                    uuid.UUID    `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	RunID                 uuid.UUID    `gorm:"type:uuid;not null;index" json:"run_id"`
+	BusinessVerticalID    uuid.UUID    `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	SalaryStructureID     uuid.UUID    `gorm:"type:uuid;not null" json:"salary_structure_id"`
+	UserID                string       `gorm:"size:255;not null;index" json:"user_id"`
+	EmployeeName          string       `gorm:"size:255;not null" json:"employee_name"`
+	EmployeeCode          string       `gorm:"size:64" json:"employee_code,omitempty"`
+	CostCenterID          *uuid.UUID   `gorm:"type:uuid;index" json:"cost_center_id,omitempty"` // the employee's when the run was computed
+	ProjectID             *uuid.UUID   `gorm:"type:uuid;index" json:"project_id,omitempty"`
+	Period                string       `gorm:"size:7;not null" json:"period"`
+	WorkingDays           int          `json:"working_days"`
+	PresentDays           float64      `gorm:"type:decimal(5,1)" json:"present_days"`
+	PaidLeaveDays         float64      `gorm:"type:decimal(5,1)" json:"paid_leave_days"`
+	UnpaidLeaveDays       float64      `gorm:"type:decimal(5,1)" json:"unpaid_leave_days"`
+	PaidDays              float64      `gorm:"type:decimal(5,1)" json:"paid_days"`
+	LOPDays               float64      `gorm:"type:decimal(5,1)" json:"loss_of_pay_days"`
+	OvertimeHours         float64      `gorm:"type:decimal(6,2)" json:"overtime_hours"`
+	Earnings              PayslipLines `gorm:"type:jsonb;default:'[]'" json:"earnings"`
+	Deductions            PayslipLines `gorm:"type:jsonb;default:'[]'" json:"deductions"`
+	EmployerContributions PayslipLines `gorm:"type:jsonb;default:'[]'" json:"employer_contributions"`
+	GrossEarnings         float64      `gorm:"type:decimal(15,2)" json:"gross_earnings"`
+	TotalDeductions       float64      `gorm:"type:decimal(15,2)" json:"total_deductions"`
+	NetPay                float64      `gorm:"type:decimal(15,2)" json:"net_pay"`
+	CreatedAt             time.Time    `json:"created_at"`
+	UpdatedAt             time.Time    `json:"updated_at"`
+}
+
+// TableName specifies the table name for Payslip
+func (Payslip) TableName() string {
+	return "payslips"
+}
+
+// PayrollAttendance is an employee's attendance over a payroll month
+type PayrollAttendance struct {
+	WorkingDays     int
+	PresentDays     float64
+	PaidLeaveDays   float64
+	UnpaidLeaveDays float64
+	OvertimeHours   float64
+}
+
+// PayrollMonth returns the first day of the YYYY-MM period and the first day of the next
+func PayrollMonth(period string) (time.Time, time.Time, error) {
+	start, err := time.Parse("2006-01", period)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("period must be YYYY-MM")
+	}
+	return start, start.AddDate(0, 1, 0), nil
+}
+
+// WorkingDaysBetween counts the days in [from, to) that are not Sundays
+func WorkingDaysBetween(from, to time.Time) int {
+	days := 0
+	for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+		if d.Weekday() != time.Sunday {
+			days++
+		}
+	}
+	return days
+}
+
+// LeaveDaysBetween counts the working days of approved leave falling in [from, to),
+// split into paid and unpaid; half-day leave counts half a day
+func LeaveDaysBetween(leaves []EmployeeLeave, from, to time.Time) (paid, unpaid float64) {
+	for _, l := range leaves {
+		if l.Status != LeaveApproved {
+			continue
+		}
+		start, end := l.FromDate, l.ToDate.AddDate(0, 0, 1)
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if !end.After(start) {
+			continue
+		}
+		days := float64(WorkingDaysBetween(start, end))
+		if l.HalfDay && days > 0 {
+			days = 0.5
+		}
+		if l.Paid {
+			paid += days
+		} else {
+			unpaid += days
+		}
+	}
+	return paid, unpaid
+}
+
+// ComputePayslip works out an employee's pay for a month. Days present and on paid leave
+// are paid, up to the working days; prorated earnings are cut for the rest, and overtime
+// is paid at the structure's hourly rate. PF is taken on the earned PF wage up to its
+// ceiling, ESI on gross earnings for employees whose full monthly gross is within the
+// ESI ceiling, professional tax by slab of gross earnings, and TDS as a twelfth of the
+// tax on the structure's annual gross less the standard deduction. The structure's own
+// deductions are taken as they stand.
+func ComputePayslip(s SalaryStructure, a PayrollAttendance, rules PayrollStatutoryRules) Payslip {
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+	p := Payslip{
+		SalaryStructureID:     s.ID,
+		BusinessVerticalID:    s.BusinessVerticalID,
+		UserID:                s.UserID,
+		EmployeeName:          s.EmployeeName,
+		EmployeeCode:          s.EmployeeCode,
+		WorkingDays:           a.WorkingDays,
+		PresentDays:           a.PresentDays,
+		PaidLeaveDays:         a.PaidLeaveDays,
+		UnpaidLeaveDays:       a.UnpaidLeaveDays,
+		OvertimeHours:         a.OvertimeHours,
+		Earnings:              PayslipLines{},
+		Deductions:            PayslipLines{},
+		EmployerContributions: PayslipLines{},
+	}
+	ratio := 1.0
+	if a.WorkingDays > 0 {
+		p.PaidDays = math.Min(float64(a.WorkingDays), a.PresentDays+a.PaidLeaveDays)
+		p.LOPDays = float64(a.WorkingDays) - p.PaidDays
+		ratio = p.PaidDays / float64(a.WorkingDays)
+	}
+
+	pfWage := 0.0
+	for _, c := range s.Components {
+		if c.Kind != SalaryEarning {
+			continue
+		}
+		amount := c.MonthlyAmount
+		if c.Prorated {
+			amount = round(amount * ratio)
+		}
+		p.Earnings = append(p.Earnings, PayslipLine{Code: c.Code, Name: c.Name, Amount: amount})
+		p.GrossEarnings += amount
+		if c.PFWage {
+			pfWage += amount
+		}
+	}
+	if overtime := round(a.OvertimeHours * s.OvertimeHourlyRate); overtime > 0 {
+		p.Earnings = append(p.Earnings, PayslipLine{Code: "OT", Name: "Overtime", Amount: overtime})
+		p.GrossEarnings += overtime
+	}
+	p.GrossEarnings = round(p.GrossEarnings)
+
+	deduct := func(code, name string, amount float64) {
+		if amount > 0 {
+			p.Deductions = append(p.Deductions, PayslipLine{Code: code, Name: name, Amount: amount})
+			p.TotalDeductions += amount
+		}
+	}
+	contribute := func(code, name string, amount float64) {
+		if amount > 0 {
+			p.EmployerContributions = append(p.EmployerContributions, PayslipLine{Code: code, Name: name, Amount: amount})
+		}
+	}
+	if s.PFApplicable {
+		if rules.PFWageCeiling > 0 {
+			pfWage = math.Min(pfWage, rules.PFWageCeiling)
+		}
+		deduct("PF", "Provident fund", math.Round(pfWage*rules.PFEmployeePercent/100))
+		contribute("PF", "Provident fund (employer)", math.Round(pfWage*rules.PFEmployerPercent/100))
+	}
+	if s.ESIApplicable && s.MonthlyGross() <= rules.ESIGrossCeiling {
+		deduct("ESI", "Employees' state insurance", math.Ceil(p.GrossEarnings*rules.ESIEmployeePercent/100))
+		contribute("ESI", "Employees' state insurance (employer)", math.Ceil(p.GrossEarnings*rules.ESIEmployerPercent/100))
+	}
+	if s.PTApplicable {
+		deduct("PT", "Professional tax", rules.PTSlabs.FixedAmount(p.GrossEarnings))
+	}
+	if s.TDSApplicable {
+		taxable := math.Max(0, s.MonthlyGross()*12-rules.TDSStandardDeduction)
+		tax := 0.0
+		if taxable > rules.TDSRebateLimit {
+			tax = rules.TDSSlabs.MarginalTax(taxable) * (1 + rules.TDSCessPercent/100)
+		}
+		deduct("TDS", "Income tax (TDS)", math.Round(tax/12))
+	}
+	for _, c := range s.Components {
+		if c.Kind == SalaryDeduction {
+			deduct(c.Code, c.Name, c.MonthlyAmount)
+		}
+	}
+
+	p.TotalDeductions = round(p.TotalDeductions)
+	p.NetPay = round(p.GrossEarnings - p.TotalDeductions)
+	return p
+}
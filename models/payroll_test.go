@@ -0,0 +1,134 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestComputePayslipProratesAndDeducts(t *testing.T) {
+	s := SalaryStructure{
+		UserID: "u1", EmployeeName: "Ravi", EffectiveFrom: time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC),
+		Components: SalaryComponents{
+			{Code: "BASIC", Name: "Basic", Kind: SalaryEarning, MonthlyAmount: 20000, Prorated: true, PFWage: true},
+			{Code: "HRA", Name: "House rent allowance", Kind: SalaryEarning, MonthlyAmount: 8000, Prorated: true},
+			{Code: "CONV", Name: "Conveyance", Kind: SalaryEarning, MonthlyAmount: 1600},
+			{Code: "LOAN", Name: "Salary advance recovery", Kind: SalaryDeduction, MonthlyAmount: 1000},
+		},
+		PFApplicable: true, ESIApplicable: true, PTApplicable: true, TDSApplicable: true,
+	}
+	if err := s.Validate(); err != nil {
+		t.Fatalf("valid structure rejected: %v", err)
+	}
+
+	p := ComputePayslip(s, PayrollAttendance{WorkingDays: 26, PresentDays: 22, PaidLeaveDays: 2, UnpaidLeaveDays: 1},
+		DefaultPayrollStatutoryRules(s.BusinessVerticalID))
+	if p.PaidDays != 24 || p.LOPDays != 2 {
+		t.Fatalf("expected 24 paid and 2 loss of pay days, got %v and %v", p.PaidDays, p.LOPDays)
+	}
+	if p.GrossEarnings != 27446.16 {
+		t.Errorf("expected gross earnings of 27446.16, got %v", p.GrossEarnings)
+	}
+
+	deductions := map[string]float64{}
+	for _, d := range p.Deductions {
+		deductions[d.Code] = d.Amount
+	}
+	// PF on the 15000 ceiling, no ESI over the 21000 gross ceiling, top PT slab, and no
+	// TDS within the rebate
+	want := map[string]float64{"PF": 1800, "PT": 200, "LOAN": 1000}
+	if len(deductions) != len(want) {
+		t.Fatalf("unexpected deductions: %+v", p.Deductions)
+	}
+	for code, amount := range want {
+		if deductions[code] != amount {
+			t.Errorf("%s: expected %v, got %v", code, amount, deductions[code])
+		}
+	}
+	if p.TotalDeductions != 3000 || p.NetPay != 24446.16 {
+		t.Errorf("expected 3000 deducted and 24446.16 net, got %v and %v", p.TotalDeductions, p.NetPay)
+	}
+	if len(p.EmployerContributions) != 1 || p.EmployerContributions[0].Amount != 1800 {
+		t.Errorf("unexpected employer contributions: %+v", p.EmployerContributions)
+	}
+}
+
+func TestComputePayslipESIAndTDS(t *testing.T) {
+	rules := DefaultPayrollStatutoryRules(uuid.New())
+	full := PayrollAttendance{WorkingDays: 26, PresentDays: 26}
+
+	low := SalaryStructure{
+		Components:   SalaryComponents{{Code: "BASIC", Name: "Basic", Kind: SalaryEarning, MonthlyAmount: 12000, Prorated: true, PFWage: true}},
+		PFApplicable: true, ESIApplicable: true, PTApplicable: true, TDSApplicable: true,
+	}
+	p := ComputePayslip(low, full, rules)
+	if p.TotalDeductions != 1530 || p.NetPay != 10470 {
+		t.Errorf("expected PF of 1440 and ESI of 90, got %+v", p.Deductions)
+	}
+	if len(p.EmployerContributions) != 2 || p.EmployerContributions[1].Amount != 390 {
+		t.Errorf("unexpected employer contributions: %+v", p.EmployerContributions)
+	}
+
+	// Overtime adds to gross earnings, and so to ESI, but not to the PF wage
+	low.OvertimeHourlyRate = 150
+	p = ComputePayslip(low, PayrollAttendance{WorkingDays: 26, PresentDays: 26, OvertimeHours: 6.5}, rules)
+	if p.GrossEarnings != 12975 || p.Deductions[0].Amount != 1440 || p.Deductions[1].Amount != 98 {
+		t.Errorf("unexpected pay with overtime: gross %v, deductions %+v", p.GrossEarnings, p.Deductions)
+	}
+
+	high := SalaryStructure{
+		Components:    SalaryComponents{{Code: "BASIC", Name: "Basic", Kind: SalaryEarning, MonthlyAmount: 150000}},
+		TDSApplicable: true,
+	}
+	p = ComputePayslip(high, full, rules)
+	// 1725000 taxable: 20000 + 40000 + 60000 + 25000, plus 4% cess, over twelve months
+	if len(p.Deductions) != 1 || p.Deductions[0].Code != "TDS" || p.Deductions[0].Amount != 12567 {
+		t.Errorf("unexpected TDS: %+v", p.Deductions)
+	}
+}
+
+func TestLeaveDaysBetween(t *testing.T) {
+	from, to, err := PayrollMonth("2026-10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if days := WorkingDaysBetween(from, to); days != 27 {
+		t.Errorf("expected 27 working days in October 2026, got %d", days)
+	}
+	day := func(m time.Month, d int) time.Time { return time.Date(2026, m, d, 0, 0, 0, 0, time.UTC) }
+	leaves := []EmployeeLeave{
+		// Only October 1 to 5 fall in the month, and the 4th is a Sunday
+		{FromDate: day(9, 29), ToDate: day(10, 5), Paid: true, Status: LeaveApproved},
+		{FromDate: day(10, 31), ToDate: day(11, 2), Paid: false, Status: LeaveApproved},
+		{FromDate: day(10, 12), ToDate: day(10, 14), Paid: true, Status: LeaveCancelled},
+	}
+	paid, unpaid := LeaveDaysBetween(leaves, from, to)
+	if paid != 4 || unpaid != 1 {
+		t.Errorf("expected 4 paid and 1 unpaid leave days, got %v and %v", paid, unpaid)
+	}
+
+	if _, _, err := PayrollMonth("2026-13"); err == nil {
+		t.Error("invalid period accepted")
+	}
+}
+
+func TestPayrollStatutoryRulesValidate(t *testing.T) {
+	rules := DefaultPayrollStatutoryRules(uuid.New())
+	if err := rules.Validate(); err != nil {
+		t.Fatalf("default rules rejected: %v", err)
+	}
+	for _, bad := range []func(r *PayrollStatutoryRules){
+		func(r *PayrollStatutoryRules) { r.PFEmployeePercent = 120 },
+		func(r *PayrollStatutoryRules) { r.PTSlabs = PayrollSlabs{{UpTo: 15000}, {UpTo: 20000, Amount: 150}} },
+		func(r *PayrollStatutoryRules) {
+			r.TDSSlabs = PayrollSlabs{{UpTo: 800000}, {UpTo: 400000, Rate: 5}, {Rate: 10}}
+		},
+	} {
+		r := DefaultPayrollStatutoryRules(uuid.New())
+		bad(&r)
+		if r.Validate() == nil {
+			t.Errorf("invalid rules accepted: %+v", r)
+		}
+	}
+}
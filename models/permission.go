@@ -26,6 +26,7 @@ type Role struct {
 	IsActive    bool         `gorm:"default:true;index:idx_roles_active_level"` // composite index with level
 	IsGlobal    bool         `gorm:"default:true"`
 	Level       int          `gorm:"default:5;index:idx_roles_active_level"` // composite index with is_active
+	IsReadOnly  bool         `gorm:"default:false"`                          // holders may only read; writes are rejected by ReadOnlyRoleMiddleware
 	Permissions []Permission `gorm:"many2many:role_permissions;"`
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PermissionDeny withholds a permission from a global role, a business role or a single
+// user. Denies override every allow during resolution (deny-overrides), so a role can be
+// granted broadly and narrowed per user. Permission may be a pattern such as "payroll:*".
+type PermissionDeny struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	Permission     string     `gorm:"size:100;not null" json:"permission"`
+	RoleID         *uuid.UUID `gorm:"type:uuid;index" json:"role_id,omitempty"`
+	BusinessRoleID *uuid.UUID `gorm:"type:uuid;index" json:"business_role_id,omitempty"`
+	UserID         *uuid.UUID `gorm:"type:uuid;index" json:"user_id,omitempty"`
+	// BusinessVerticalID limits a user-level deny to one vertical; nil applies it everywhere.
+	// Role denies follow their role and business role denies their role's vertical.
+	BusinessVerticalID *uuid.UUID `gorm:"type:uuid;index" json:"business_vertical_id,omitempty"`
+	Reason             string     `gorm:"type:text" json:"reason,omitempty"`
+	CreatedBy          *uuid.UUID `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+func (PermissionDeny) TableName() string {
+	return "permission_denies"
+}
+
+// Validate checks that the deny names a permission and exactly one target
+func (d *PermissionDeny) Validate() error {
+	d.Permission = strings.TrimSpace(d.Permission)
+	if d.Permission == "" {
+		return errors.New("permission is required")
+	}
+
+	targets := 0
+	for _, id := range []*uuid.UUID{d.RoleID, d.BusinessRoleID, d.UserID} {
+		if id != nil {
+			targets++
+		}
+	}
+	if targets != 1 {
+		return errors.New("exactly one of role_id, business_role_id or user_id is required")
+	}
+	if d.BusinessVerticalID != nil && d.UserID == nil {
+		return errors.New("business_vertical_id only applies to user denies")
+	}
+	return nil
+}
+
+// DeniesPermission reports whether any deny entry covers permission
+func DeniesPermission(denied []string, permission string) bool {
+	for _, deny := range denied {
+		if matchesPermission(deny, permission) {
+			return true
+		}
+	}
+	return false
+}
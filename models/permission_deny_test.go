@@ -0,0 +1,46 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestPermissionDenyValidate(t *testing.T) {
+	id := uuid.New()
+	vertical := uuid.New()
+
+	tests := []struct {
+		name    string
+		deny    PermissionDeny
+		wantErr bool
+	}{
+		{"user deny", PermissionDeny{Permission: "payroll:*", UserID: &id}, false},
+		{"user deny in vertical", PermissionDeny{Permission: "payroll:*", UserID: &id, BusinessVerticalID: &vertical}, false},
+		{"role deny", PermissionDeny{Permission: "payroll:approve", RoleID: &id}, false},
+		{"missing permission", PermissionDeny{Permission: " ", UserID: &id}, true},
+		{"no target", PermissionDeny{Permission: "payroll:*"}, true},
+		{"two targets", PermissionDeny{Permission: "payroll:*", UserID: &id, RoleID: &id}, true},
+		{"vertical on role deny", PermissionDeny{Permission: "payroll:*", RoleID: &id, BusinessVerticalID: &vertical}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.deny.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestDeniesPermission(t *testing.T) {
+	denied := []string{"payroll:*", "hr:delete"}
+
+	for _, perm := range []string{"payroll:approve", "payroll:generate", "hr:delete"} {
+		if !DeniesPermission(denied, perm) {
+			t.Errorf("%s should be denied", perm)
+		}
+	}
+	for _, perm := range []string{"hr:read", "finance:approve"} {
+		if DeniesPermission(denied, perm) {
+			t.Errorf("%s should not be denied", perm)
+		}
+	}
+}
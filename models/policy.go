@@ -71,22 +71,34 @@ type PolicyRule struct {
 	Policy *Policy `gorm:"foreignKey:PolicyID" json:"policy,omitempty"`
 }
 
-// PolicyEvaluation stores the results of policy evaluations for audit
+// Policy evaluation sources
+const (
+	PolicyEvaluationSourceRBAC = "rbac"
+	PolicyEvaluationSourceABAC = "abac"
+)
+
+// PolicyEvaluation stores the results of policy evaluations for audit.
+// RBAC decisions have no PolicyID; ABAC decisions reference the deciding policy.
 type PolicyEvaluation struct {
 	ID                 uuid.UUID    `gorm:"type:uuid;primaryKey" json:"id"`
-	PolicyID           uuid.UUID    `gorm:"type:uuid;not null;index" json:"policy_id"`
+	PolicyID           *uuid.UUID   `gorm:"type:uuid;index" json:"policy_id,omitempty"` // Deciding policy (ABAC only)
+	PolicyName         string       `gorm:"size:255" json:"policy_name,omitempty"`
+	Source             string       `gorm:"size:10;not null;default:'abac';index" json:"source"` // rbac, abac
 	UserID             uuid.UUID    `gorm:"type:uuid;not null;index" json:"user_id"`
+	BusinessVerticalID *uuid.UUID   `gorm:"type:uuid;index" json:"business_vertical_id,omitempty"`
 	ResourceType       string       `gorm:"size:50;index" json:"resource_type"`
 	ResourceID         *uuid.UUID   `gorm:"type:uuid;index" json:"resource_id"`
 	Action             string       `gorm:"size:100;not null" json:"action"`
 	Effect             PolicyEffect `gorm:"size:10;not null" json:"effect"` // Final decision
-	Context            JSONMap      `gorm:"type:jsonb" json:"context"`      // Context at evaluation time
-	MatchedConditions  JSONArray    `gorm:"type:jsonb" json:"matched_conditions"`
+	Reason             string       `gorm:"size:255" json:"reason,omitempty"`
+	Context            JSONMap      `gorm:"type:jsonb" json:"context"`            // Context at evaluation time
+	MatchedConditions  JSONArray    `gorm:"type:jsonb" json:"matched_conditions"` // IDs of every matched policy
 	EvaluationTime     time.Time    `gorm:"default:CURRENT_TIMESTAMP;index" json:"evaluation_time"`
 	IPAddress          string       `gorm:"size:50" json:"ip_address"`
 	UserAgent          string       `gorm:"size:500" json:"user_agent"`
 	RequestPath        string       `gorm:"size:500" json:"request_path"`
 	EvaluationDuration int          `gorm:"default:0" json:"evaluation_duration_ms"` // Duration in milliseconds
+	LatencyMicros      int64        `gorm:"default:0" json:"latency_us"`
 
 	// Relationships
 	Policy *Policy `gorm:"foreignKey:PolicyID" json:"policy,omitempty"`
@@ -221,6 +233,7 @@ type PolicyRequest struct {
 	Action             string            `json:"action"`
 	ResourceType       string            `json:"resource_type"`
 	ResourceID         *uuid.UUID        `json:"resource_id"`
+	BusinessVerticalID *uuid.UUID        `json:"business_vertical_id,omitempty"`
 	UserAttributes     map[string]string `json:"user_attributes"`
 	ResourceAttributes map[string]string `json:"resource_attributes"`
 	Environment        map[string]string `json:"environment"`
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Portfolio risk levels derived from a project's risk score.
+const (
+	PortfolioRiskLow      = "low"
+	PortfolioRiskMedium   = "medium"
+	PortfolioRiskHigh     = "high"
+	PortfolioRiskCritical = "critical"
+)
+
+// PortfolioSnapshot stores one project's health indicators for a week so leadership
+// can compare the portfolio over time. There is at most one snapshot per project per week.
+type PortfolioSnapshot struct {
+	ID                 uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	ProjectID          uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_portfolio_snapshot_project_week" json:"project_id"`
+	BusinessVerticalID uuid.UUID `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	WeekStart          time.Time `gorm:"type:date;not null;uniqueIndex:idx_portfolio_snapshot_project_week;index" json:"week_start"` // Monday, UTC
+
+	ProjectStatus     string  `gorm:"size:50" json:"project_status"`
+	Progress          float64 `gorm:"type:decimal(5,2);default:0" json:"progress"`
+	PlannedProgress   float64 `gorm:"type:decimal(5,2);default:0" json:"planned_progress"`
+	ScheduleVariance  float64 `gorm:"type:decimal(7,2);default:0" json:"schedule_variance"` // percentage points, negative = behind
+	TotalBudget       float64 `gorm:"type:decimal(15,2);default:0" json:"total_budget"`
+	SpentBudget       float64 `gorm:"type:decimal(15,2);default:0" json:"spent_budget"`
+	BudgetVariance    float64 `gorm:"type:decimal(7,2);default:0" json:"budget_variance"` // % of budget, negative = overrun
+	CriticalIncidents int     `gorm:"default:0" json:"critical_incidents"`
+	OverdueTasks      int     `gorm:"default:0" json:"overdue_tasks"`
+	RiskScore         float64 `gorm:"type:decimal(5,2);default:0;index" json:"risk_score"`
+	RiskLevel         string  `gorm:"size:20;index" json:"risk_level"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (PortfolioSnapshot) TableName() string {
+	return "portfolio_snapshots"
+}
@@ -0,0 +1,547 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Procurement documents (requisitions, purchase orders and vendor invoices) are approved
+// through the multi-level approval workflow: drafted, submitted, L1 then L2 approved, or
+// rejected and revised back to draft.
+const (
+	ProcurementWorkflowCode = "multi_level_approval"
+	ProcurementDraft        = "draft"
+	ProcurementApproved     = "l2_approved"
+)
+
+// Vendor invoice three-way match results. A mismatch is either accepted by finance, with
+// a reason, or disputed with the vendor; only a matched invoice or one whose exceptions
+// were accepted can go through approval.
+const (
+	InvoiceMatched           = "matched"
+	InvoiceMismatch          = "mismatch"
+	InvoiceExceptionAccepted = "exception_accepted"
+	InvoiceDisputed          = "disputed"
+)
+
+// Vendor invoice payment statuses
+const (
+	InvoiceUnpaid     = "unpaid"
+	InvoicePartlyPaid = "partly_paid"
+	InvoicePaid       = "paid"
+)
+
+// StockReferenceGRN marks stock ledger entries posted by goods receipt notes
+const StockReferenceGRN = "grn"
+
+// PurchaseRequisition asks for materials to be bought for a site. Once approved its lines
+// are ordered on one or more purchase orders.
+type PurchaseRequisition struct {
+	ID                 uuid.UUID                 `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID                 `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	SiteID             uuid.UUID                 `gorm:"type:uuid;not null;index" json:"site_id"`
+	Site               *Site                     `gorm:"foreignKey:SiteID" json:"site,omitempty"`
+	ProjectID          *uuid.UUID                `gorm:"type:uuid;index" json:"project_id,omitempty"`
+	RequisitionNumber  string                    `gorm:"size:64;not null" json:"requisition_number"`
+	Purpose            string                    `gorm:"type:text" json:"purpose,omitempty"`
+	RequiredBy         *time.Time                `gorm:"type:date" json:"required_by,omitempty"`
+	Priority           string                    `gorm:"size:20;default:'normal'" json:"priority"`
+	WorkflowID         *uuid.UUID                `gorm:"type:uuid" json:"workflow_id,omitempty"`
+	CurrentState       string                    `gorm:"size:50;not null;default:'draft';index" json:"current_state"`
+	CreatedBy          string                    `gorm:"size:255;not null" json:"created_by"`
+	UpdatedBy          string                    `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt          time.Time                 `json:"created_at"`
+	UpdatedAt          time.Time                 `json:"updated_at"`
+	DeletedAt          *time.Time                `gorm:"index" json:"deleted_at,omitempty"`
+	Lines              []PurchaseRequisitionLine `gorm:"foreignKey:RequisitionID" json:"lines,omitempty"`
+}
+
+// TableName specifies the table name for PurchaseRequisition
+func (PurchaseRequisition) TableName() string {
+	return "purchase_requisitions"
+}
+
+// PurchaseRequisitionLine is a material asked for on a requisition. OrderedQuantity is how
+// much of it purchase orders have taken up.
+type PurchaseRequisitionLine struct {
+	ID              uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	RequisitionID   uuid.UUID `gorm:"type:uuid;not null;index" json:"requisition_id"`
+	MaterialCode    string    `gorm:"size:64;not null" json:"material_code"`
+	Description     string    `gorm:"type:text;not null" json:"description"`
+	UOM             string    `gorm:"size:32;not null" json:"uom"`
+	Quantity        float64   `gorm:"type:decimal(15,4);not null" json:"quantity"`
+	OrderedQuantity float64   `gorm:"type:decimal(15,4);default:0" json:"ordered_quantity"`
+	EstimatedRate   float64   `gorm:"type:decimal(15,2);default:0" json:"estimated_rate"`
+	Remarks         string    `gorm:"type:text" json:"remarks,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for PurchaseRequisitionLine
+func (PurchaseRequisitionLine) TableName() string {
+	return "purchase_requisition_lines"
+}
+
+// EstimatedValue is what the requisition's lines come to at their estimated rates. Lines
+// must be loaded.
+func (pr PurchaseRequisition) EstimatedValue() float64 {
+	value := 0.0
+	for _, l := range pr.Lines {
+		value += l.Quantity * l.EstimatedRate
+	}
+	return math.Round(value*100) / 100
+}
+
+// RemainingQuantity is how much of the line is still to be ordered
+func (l PurchaseRequisitionLine) RemainingQuantity() float64 {
+	return math.Max(0, math.Round((l.Quantity-l.OrderedQuantity)*10000)/10000)
+}
+
+// PurchaseOrder orders materials from a vendor at agreed rates for delivery to a site.
+// Once approved, goods are received against it on GRNs and invoiced by the vendor. The
+// vendor's name, GSTIN and contact are copied onto the order as they stood when raised.
+type PurchaseOrder struct {
+	ID                 uuid.UUID            `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID            `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	SiteID             uuid.UUID            `gorm:"type:uuid;not null;index" json:"site_id"`
+	Site               *Site                `gorm:"foreignKey:SiteID" json:"site,omitempty"`
+	RequisitionID      *uuid.UUID           `gorm:"type:uuid;index" json:"requisition_id,omitempty"`
+	Requisition        *PurchaseRequisition `gorm:"foreignKey:RequisitionID" json:"requisition,omitempty"`
+	ProjectID          *uuid.UUID           `gorm:"type:uuid;index" json:"project_id,omitempty"`     // the requisition's
+	CostCenterID       *uuid.UUID           `gorm:"type:uuid;index" json:"cost_center_id,omitempty"` // its invoices are booked to
+	OrderNumber        string               `gorm:"size:64;not null" json:"order_number"`
+	OrderDate          time.Time            `gorm:"type:date;not null" json:"order_date"`
+	VendorID           *uuid.UUID           `gorm:"type:uuid;index" json:"vendor_id,omitempty"`
+	Vendor             *Vendor              `gorm:"foreignKey:VendorID" json:"vendor,omitempty"`
+	VendorName         string               `gorm:"size:255;not null" json:"vendor_name"`
+	VendorGSTIN        string               `gorm:"size:20" json:"vendor_gstin,omitempty"`
+	VendorAddress      string               `gorm:"type:text" json:"vendor_address,omitempty"`
+	VendorContact      string               `gorm:"size:255" json:"vendor_contact,omitempty"`
+	VendorPhone        string               `gorm:"size:32" json:"vendor_phone,omitempty"`
+	VendorEmail        string               `gorm:"size:255" json:"vendor_email,omitempty"`
+	DeliveryAddress    string               `gorm:"type:text" json:"delivery_address,omitempty"`
+	DeliveryDate       *time.Time           `gorm:"type:date" json:"delivery_date,omitempty"`
+	PaymentTerms       string               `gorm:"type:text" json:"payment_terms,omitempty"`
+	Terms              string               `gorm:"type:text" json:"terms,omitempty"`
+	SubTotal           float64              `gorm:"type:decimal(15,2);default:0" json:"sub_total"`
+	TaxAmount          float64              `gorm:"type:decimal(15,2);default:0" json:"tax_amount"`
+	TotalAmount        float64              `gorm:"type:decimal(15,2);default:0" json:"total_amount"`
+	Currency           string               `gorm:"size:3;not null;default:'INR'" json:"currency"`
+	ExchangeRate       float64              `gorm:"type:decimal(18,8);default:1" json:"exchange_rate"` // to BaseCurrency on the order date
+	BaseTotalAmount    float64              `gorm:"->;-:migration" json:"base_total_amount"`           // kept by the database
+	WorkflowID         *uuid.UUID           `gorm:"type:uuid" json:"workflow_id,omitempty"`
+	CurrentState       string               `gorm:"size:50;not null;default:'draft';index" json:"current_state"`
+	CreatedBy          string               `gorm:"size:255;not null" json:"created_by"`
+	UpdatedBy          string               `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt          time.Time            `json:"created_at"`
+	UpdatedAt          time.Time            `json:"updated_at"`
+	DeletedAt          *time.Time           `gorm:"index" json:"deleted_at,omitempty"`
+	Lines              []PurchaseOrderLine  `gorm:"foreignKey:PurchaseOrderID" json:"lines,omitempty"`
+}
+
+// TableName specifies the table name for PurchaseOrder
+func (PurchaseOrder) TableName() string {
+	return "purchase_orders"
+}
+
+// PurchaseOrderLine is a material ordered at a rate, usually against a requisition line.
+// ReceivedQuantity counts what GRNs accepted and InvoicedQuantity what approved vendor
+// invoices billed.
+type PurchaseOrderLine struct {
+	ID                uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	PurchaseOrderID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"purchase_order_id"`
+	RequisitionLineID *uuid.UUID `gorm:"type:uuid;index" json:"requisition_line_id,omitempty"`
+	MaterialCode      string     `gorm:"size:64;not null" json:"material_code"`
+	Description       string     `gorm:"type:text;not null" json:"description"`
+	UOM               string     `gorm:"size:32;not null" json:"uom"`
+	Quantity          float64    `gorm:"type:decimal(15,4);not null" json:"quantity"`
+	Rate              float64    `gorm:"type:decimal(15,2);not null" json:"rate"`
+	TaxPercent        float64    `gorm:"type:decimal(5,2);default:0" json:"tax_percent"`
+	Amount            float64    `gorm:"type:decimal(15,2);not null" json:"amount"`
+	TaxAmount         float64    `gorm:"type:decimal(15,2);default:0" json:"tax_amount"`
+	ReceivedQuantity  float64    `gorm:"type:decimal(15,4);default:0" json:"received_quantity"`
+	InvoicedQuantity  float64    `gorm:"type:decimal(15,4);default:0" json:"invoiced_quantity"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for PurchaseOrderLine
+func (PurchaseOrderLine) TableName() string {
+	return "purchase_order_lines"
+}
+
+// Price works out the line's amount and tax from its quantity, rate and tax percentage
+func (l *PurchaseOrderLine) Price() {
+	l.Amount = math.Round(l.Quantity*l.Rate*100) / 100
+	l.TaxAmount = math.Round(l.Amount*l.TaxPercent) / 100
+}
+
+// Total sums the order's lines into its sub-total, tax and total
+func (po *PurchaseOrder) Total() {
+	var sub, tax float64
+	for _, line := range po.Lines {
+		sub += line.Amount
+		tax += line.TaxAmount
+	}
+	po.SubTotal = math.Round(sub*100) / 100
+	po.TaxAmount = math.Round(tax*100) / 100
+	po.TotalAmount = math.Round((sub+tax)*100) / 100
+}
+
+// GRNLine is the quantity of a purchase order line received on a GRN, split into what
+// was accepted into stock and what was rejected at the gate
+type GRNLine struct {
+	PurchaseOrderLineID uuid.UUID `json:"purchase_order_line_id"`
+	MaterialCode        string    `json:"material_code,omitempty"`
+	Description         string    `json:"description,omitempty"`
+	UOM                 string    `json:"uom,omitempty"`
+	ReceivedQuantity    float64   `json:"received_quantity"`
+	AcceptedQuantity    float64   `json:"accepted_quantity"`
+	RejectedQuantity    float64   `json:"rejected_quantity"`
+	Rate                float64   `json:"rate"`
+	Remarks             string    `json:"remarks,omitempty"`
+}
+
+// GRNLines are the lines of a goods receipt note
+type GRNLines []GRNLine
+
+// GoodsReceiptNote records materials received at a site against an approved purchase
+// order. Its accepted quantities are posted to the stock ledger when it is recorded.
+type GoodsReceiptNote struct {
+	ID                 uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID      `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	PurchaseOrderID    uuid.UUID      `gorm:"type:uuid;not null;index" json:"purchase_order_id"`
+	PurchaseOrder      *PurchaseOrder `gorm:"foreignKey:PurchaseOrderID" json:"purchase_order,omitempty"`
+	SiteID             uuid.UUID      `gorm:"type:uuid;not null;index" json:"site_id"`
+	VendorID           *uuid.UUID     `gorm:"type:uuid;index" json:"vendor_id,omitempty"`
+	GRNNumber          string         `gorm:"column:grn_number;size:64;not null" json:"grn_number"`
+	ReceivedDate       time.Time      `gorm:"type:date;not null" json:"received_date"`
+	ChallanNumber      string         `gorm:"size:64" json:"challan_number,omitempty"`
+	VehicleNumber      string         `gorm:"size:32" json:"vehicle_number,omitempty"`
+	Lines              GRNLines       `gorm:"type:jsonb;default:'[]'" json:"lines"`
+	AcceptedValue      float64        `gorm:"type:decimal(15,2);default:0" json:"accepted_value"`
+	Remarks            string         `gorm:"type:text" json:"remarks,omitempty"`
+	ReceivedBy         string         `gorm:"size:255;not null" json:"received_by"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+}
+
+// TableName specifies the table name for GoodsReceiptNote
+func (GoodsReceiptNote) TableName() string {
+	return "goods_receipt_notes"
+}
+
+// StockLedgerEntry is one movement of a material into or out of a site's stock. The
+// reference names the document that moved it, such as a GRN. Issues to work name the
+// project, and the task if any, the material went to.
+type StockLedgerEntry struct {
+	ID                 uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID  `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	SiteID             uuid.UUID  `gorm:"type:uuid;not null;index:idx_stock_ledger_site_material" json:"site_id"`
+	MaterialCode       string     `gorm:"size:64;not null;index:idx_stock_ledger_site_material" json:"material_code"`
+	ProjectID          *uuid.UUID `gorm:"type:uuid;index" json:"project_id,omitempty"`
+	TaskID             *uuid.UUID `gorm:"type:uuid;index" json:"task_id,omitempty"`
+	Description        string     `gorm:"type:text" json:"description,omitempty"`
+	UOM                string     `gorm:"size:32;not null" json:"uom"`
+	EntryDate          time.Time  `gorm:"type:date;not null;index" json:"entry_date"`
+	QuantityIn         float64    `gorm:"type:decimal(15,4);default:0" json:"quantity_in"`
+	QuantityOut        float64    `gorm:"type:decimal(15,4);default:0" json:"quantity_out"`
+	Rate               float64    `gorm:"type:decimal(15,2);default:0" json:"rate"`
+	Value              float64    `gorm:"type:decimal(15,2);default:0" json:"value"`
+	ReferenceType      string     `gorm:"size:32;not null" json:"reference_type"`
+	ReferenceID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"reference_id"`
+	ReferenceNumber    string     `gorm:"size:64" json:"reference_number,omitempty"`
+	CreatedBy          string     `gorm:"size:255;not null" json:"created_by"`
+	CreatedAt          time.Time  `json:"created_at"`
+}
+
+// TableName specifies the table name for StockLedgerEntry
+func (StockLedgerEntry) TableName() string {
+	return "stock_ledger_entries"
+}
+
+// InvoiceLine is the quantity of a purchase order line billed on a vendor invoice
+type InvoiceLine struct {
+	PurchaseOrderLineID uuid.UUID `json:"purchase_order_line_id"`
+	MaterialCode        string    `json:"material_code,omitempty"`
+	Quantity            float64   `json:"quantity"`
+	Rate                float64   `json:"rate"`
+	TaxPercent          float64   `json:"tax_percent"`
+	Amount              float64   `json:"amount"`
+	TaxAmount           float64   `json:"tax_amount"`
+}
+
+// InvoiceLines are the lines of a vendor invoice
+type InvoiceLines []InvoiceLine
+
+// VendorInvoice is a vendor's bill against a purchase order. It is three-way matched
+// against the order's rates and the quantities its GRNs accepted, and only a matched
+// invoice can go through approval.
+type VendorInvoice struct {
+	ID                 uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID      `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	PurchaseOrderID    uuid.UUID      `gorm:"type:uuid;not null;index" json:"purchase_order_id"`
+	PurchaseOrder      *PurchaseOrder `gorm:"foreignKey:PurchaseOrderID" json:"purchase_order,omitempty"`
+	InvoiceNumber      string         `gorm:"size:64;not null" json:"invoice_number"`
+	InvoiceDate        time.Time      `gorm:"type:date;not null" json:"invoice_date"`
+	Lines              InvoiceLines   `gorm:"type:jsonb;default:'[]'" json:"lines"`
+	SubTotal           float64        `gorm:"type:decimal(15,2);default:0" json:"sub_total"`
+	TaxAmount          float64        `gorm:"type:decimal(15,2);default:0" json:"tax_amount"`
+	TotalAmount        float64        `gorm:"type:decimal(15,2);default:0" json:"total_amount"`
+	MatchStatus        string         `gorm:"size:20;not null;index" json:"match_status"`
+	MatchExceptions    StringArray    `gorm:"type:jsonb;default:'[]'" json:"match_exceptions"`
+	MatchedAt          *time.Time     `json:"matched_at,omitempty"`
+	ExceptionComment   string         `gorm:"type:text" json:"exception_comment,omitempty"`
+	ExceptionBy        string         `gorm:"size:255" json:"exception_by,omitempty"`
+	ExceptionAt        *time.Time     `json:"exception_at,omitempty"`
+	DueDate            *time.Time     `gorm:"type:date;index" json:"due_date,omitempty"`
+	PaidAmount         float64        `gorm:"type:decimal(15,2);default:0" json:"paid_amount"`
+	PaymentStatus      string         `gorm:"size:20;not null;default:'unpaid';index" json:"payment_status"`
+	Currency           string         `gorm:"size:3;not null;default:'INR'" json:"currency"`     // the order's
+	ExchangeRate       float64        `gorm:"type:decimal(18,8);default:1" json:"exchange_rate"` // to BaseCurrency on the invoice date
+	BaseTotalAmount    float64        `gorm:"->;-:migration" json:"base_total_amount"`           // kept by the database
+	BasePaidAmount     float64        `gorm:"->;-:migration" json:"base_paid_amount"`
+	WorkflowID         *uuid.UUID     `gorm:"type:uuid" json:"workflow_id,omitempty"`
+	CurrentState       string         `gorm:"size:50;not null;default:'draft';index" json:"current_state"`
+	Remarks            string         `gorm:"type:text" json:"remarks,omitempty"`
+	CreatedBy          string         `gorm:"size:255;not null" json:"created_by"`
+	UpdatedBy          string         `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+}
+
+// TableName specifies the table name for VendorInvoice
+func (VendorInvoice) TableName() string {
+	return "vendor_invoices"
+}
+
+// Outstanding returns what is left to pay on the invoice
+func (i VendorInvoice) Outstanding() float64 {
+	return math.Round((i.TotalAmount-i.PaidAmount)*100) / 100
+}
+
+// VendorInvoicePayment is a payment made against an approved vendor invoice
+type VendorInvoicePayment struct {
+	ID                 uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	InvoiceID          uuid.UUID `gorm:"type:uuid;not null;index" json:"invoice_id"`
+	Amount             float64   `gorm:"type:decimal(15,2);not null" json:"amount"`
+	PaidOn             time.Time `gorm:"type:date;not null" json:"paid_on"`
+	Mode               string    `gorm:"size:32" json:"mode,omitempty"` // neft, rtgs, cheque...
+	Reference          string    `gorm:"size:100;not null" json:"reference"`
+	Remarks            string    `gorm:"type:text" json:"remarks,omitempty"`
+	RecordedBy         string    `gorm:"size:255;not null" json:"recorded_by"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for VendorInvoicePayment
+func (VendorInvoicePayment) TableName() string {
+	return "vendor_invoice_payments"
+}
+
+// InvoiceMatchTolerance is how far a business lets a vendor invoice stray from its order
+// and receipts and still match, and the credit period its invoices fall due after
+type InvoiceMatchTolerance struct {
+	ID                 uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex" json:"business_vertical_id"`
+	RatePercent        float64   `gorm:"type:decimal(5,2);default:0.5" json:"rate_percent"`   // rate over or under the ordered rate
+	RateAmount         float64   `gorm:"type:decimal(15,2);default:0" json:"rate_amount"`     // or a line's value off by at most this
+	QuantityPercent    float64   `gorm:"type:decimal(5,2);default:0" json:"quantity_percent"` // quantity billed over what was received
+	CreditDays         int       `gorm:"default:30" json:"credit_days"`                       // for vendors without their own
+	UpdatedBy          string    `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for InvoiceMatchTolerance
+func (InvoiceMatchTolerance) TableName() string {
+	return "invoice_match_tolerances"
+}
+
+// DefaultInvoiceMatchTolerance returns the tolerance a business matches invoices with
+// until it sets its own
+func DefaultInvoiceMatchTolerance(businessID uuid.UUID) InvoiceMatchTolerance {
+	return InvoiceMatchTolerance{BusinessVerticalID: businessID, RatePercent: 0.5, CreditDays: 30}
+}
+
+// Validate checks the tolerances are sensible
+func (t InvoiceMatchTolerance) Validate() error {
+	switch {
+	case t.RatePercent < 0 || t.RatePercent > 25 || t.QuantityPercent < 0 || t.QuantityPercent > 25:
+		return fmt.Errorf("rate and quantity tolerances must be between 0 and 25 percent")
+	case t.RateAmount < 0:
+		return fmt.Errorf("rate_amount cannot be negative")
+	case t.CreditDays < 0 || t.CreditDays > 365:
+		return fmt.Errorf("credit_days must be between 0 and 365")
+	}
+	return nil
+}
+
+// Payables aging buckets, by days past the due date
+const (
+	AgingNotDue = "not_due"
+	Aging1To30  = "1_30"
+	Aging31To60 = "31_60"
+	Aging61To90 = "61_90"
+	AgingOver90 = "over_90"
+)
+
+// AgingBuckets lists the aging buckets in order
+var AgingBuckets = []string{AgingNotDue, Aging1To30, Aging31To60, Aging61To90, AgingOver90}
+
+// AgingBucket returns the bucket an amount due on due falls in as of asOf, and the days
+// it is overdue
+func AgingBucket(due, asOf time.Time) (string, int) {
+	days := int(asOf.Sub(due).Hours() / 24)
+	switch {
+	case days <= 0:
+		return AgingNotDue, 0
+	case days <= 30:
+		return Aging1To30, days
+	case days <= 60:
+		return Aging31To60, days
+	case days <= 90:
+		return Aging61To90, days
+	}
+	return AgingOver90, days
+}
+
+// ReceiveLines checks the quantities received on a GRN against the purchase order's
+// lines and returns them filled in with each line's material and rate, along with the
+// value accepted into stock. A line giving neither accepted nor rejected quantities is
+// accepted in full. Accepted quantities may not take a line past its ordered quantity.
+func ReceiveLines(orderLines []PurchaseOrderLine, received GRNLines) (GRNLines, float64, error) {
+	byID := make(map[uuid.UUID]PurchaseOrderLine, len(orderLines))
+	for _, line := range orderLines {
+		byID[line.ID] = line
+	}
+	if len(received) == 0 {
+		return nil, 0, fmt.Errorf("a GRN needs at least one line")
+	}
+
+	lines := make(GRNLines, 0, len(received))
+	accepted := map[uuid.UUID]float64{}
+	var value float64
+	for _, r := range received {
+		line, ok := byID[r.PurchaseOrderLineID]
+		if !ok {
+			return nil, 0, fmt.Errorf("line %s is not on the purchase order", r.PurchaseOrderLineID)
+		}
+		if r.ReceivedQuantity <= 0 || r.AcceptedQuantity < 0 || r.RejectedQuantity < 0 {
+			return nil, 0, fmt.Errorf("%s: received quantity must be positive and accepted and rejected not negative", line.MaterialCode)
+		}
+		if r.AcceptedQuantity == 0 && r.RejectedQuantity == 0 {
+			r.AcceptedQuantity = r.ReceivedQuantity
+		}
+		if math.Abs(r.AcceptedQuantity+r.RejectedQuantity-r.ReceivedQuantity) > 1e-9 {
+			return nil, 0, fmt.Errorf("%s: accepted and rejected quantities must add up to the %.4f received", line.MaterialCode, r.ReceivedQuantity)
+		}
+		accepted[line.ID] += r.AcceptedQuantity
+		if line.ReceivedQuantity+accepted[line.ID] > line.Quantity+1e-9 {
+			return nil, 0, fmt.Errorf("%s: accepting %.4f %s would exceed the ordered %.4f (%.4f already received)",
+				line.MaterialCode, r.AcceptedQuantity, line.UOM, line.Quantity, line.ReceivedQuantity)
+		}
+
+		r.MaterialCode = line.MaterialCode
+		r.Description = line.Description
+		r.UOM = line.UOM
+		r.Rate = line.Rate
+		lines = append(lines, r)
+		value += r.AcceptedQuantity * line.Rate
+	}
+	return lines, math.Round(value*100) / 100, nil
+}
+
+// MatchInvoice three-way matches invoice lines against the purchase order's lines: the
+// rate billed against the order's rate, within the tolerance's percentage or line
+// amount, the tax percentage against the order's, and the quantity billed against what
+// GRNs accepted less what earlier invoices billed, within the tolerance's percentage. It
+// returns the lines priced as billed with the invoice's totals, and any exceptions; an
+// invoice with none is matched. Lines not on the order or without a positive quantity
+// are errors rather than exceptions.
+func MatchInvoice(orderLines []PurchaseOrderLine, billed InvoiceLines, tolerance InvoiceMatchTolerance) (InvoiceLines, float64, float64, []string, error) {
+	byID := make(map[uuid.UUID]PurchaseOrderLine, len(orderLines))
+	for _, line := range orderLines {
+		byID[line.ID] = line
+	}
+	if len(billed) == 0 {
+		return nil, 0, 0, nil, fmt.Errorf("an invoice needs at least one line")
+	}
+
+	lines := make(InvoiceLines, 0, len(billed))
+	exceptions := []string{}
+	quantities := map[uuid.UUID]float64{}
+	var sub, tax float64
+	for _, b := range billed {
+		line, ok := byID[b.PurchaseOrderLineID]
+		if !ok {
+			return nil, 0, 0, nil, fmt.Errorf("line %s is not on the purchase order", b.PurchaseOrderLineID)
+		}
+		if b.Quantity <= 0 || b.Rate < 0 || b.TaxPercent < 0 {
+			return nil, 0, 0, nil, fmt.Errorf("%s: quantity must be positive and rate and tax not negative", line.MaterialCode)
+		}
+
+		if diff := math.Abs(b.Rate - line.Rate); diff > line.Rate*tolerance.RatePercent/100+1e-9 &&
+			diff*b.Quantity > tolerance.RateAmount+1e-9 {
+			exceptions = append(exceptions, fmt.Sprintf("%s: rate %.2f differs from the ordered %.2f", line.MaterialCode, b.Rate, line.Rate))
+		}
+		if math.Abs(b.TaxPercent-line.TaxPercent) > 1e-9 {
+			exceptions = append(exceptions, fmt.Sprintf("%s: tax %.2f%% differs from the ordered %.2f%%", line.MaterialCode, b.TaxPercent, line.TaxPercent))
+		}
+		quantities[line.ID] += b.Quantity
+		open := line.ReceivedQuantity - line.InvoicedQuantity
+		if quantities[line.ID] > open+line.ReceivedQuantity*tolerance.QuantityPercent/100+1e-9 {
+			exceptions = append(exceptions, fmt.Sprintf("%s: %.4f %s billed but only %.4f received and not yet invoiced",
+				line.MaterialCode, quantities[line.ID], line.UOM, math.Max(0, open)))
+		}
+
+		b.MaterialCode = line.MaterialCode
+		b.Amount = math.Round(b.Quantity*b.Rate*100) / 100
+		b.TaxAmount = math.Round(b.Amount*b.TaxPercent) / 100
+		lines = append(lines, b)
+		sub += b.Amount
+		tax += b.TaxAmount
+	}
+	return lines, math.Round(sub*100) / 100, math.Round(tax*100) / 100, exceptions, nil
+}
+
+// Scan implements the sql.Scanner interface
+func (gl *GRNLines) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		*gl = GRNLines{}
+		return nil
+	}
+	return json.Unmarshal(bytes, gl)
+}
+
+// Value implements the driver.Valuer interface
+func (gl GRNLines) Value() (driver.Value, error) {
+	if gl == nil {
+		return json.Marshal([]GRNLine{})
+	}
+	return json.Marshal([]GRNLine(gl))
+}
+
+// Scan implements the sql.Scanner interface
+func (il *InvoiceLines) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		*il = InvoiceLines{}
+		return nil
+	}
+	return json.Unmarshal(bytes, il)
+}
+
+// Value implements the driver.Valuer interface
+func (il InvoiceLines) Value() (driver.Value, error) {
+	if il == nil {
+		return json.Marshal([]InvoiceLine{})
+	}
+	return json.Marshal([]InvoiceLine(il))
+}
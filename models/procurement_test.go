@@ -0,0 +1,101 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestReceiveLines(t *testing.T) {
+	pipe := PurchaseOrderLine{ID: uuid.New(), MaterialCode: "DI-300", UOM: "m", Quantity: 600, Rate: 2450, ReceivedQuantity: 480}
+	cement := PurchaseOrderLine{ID: uuid.New(), MaterialCode: "OPC-53", UOM: "bag", Quantity: 1000, Rate: 380}
+
+	lines, value, err := ReceiveLines([]PurchaseOrderLine{pipe, cement}, GRNLines{
+		{PurchaseOrderLineID: pipe.ID, ReceivedQuantity: 120, AcceptedQuantity: 114, RejectedQuantity: 6},
+		{PurchaseOrderLineID: cement.ID, ReceivedQuantity: 250},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines[1].AcceptedQuantity != 250 || lines[0].MaterialCode != "DI-300" || lines[0].Rate != 2450 {
+		t.Errorf("lines = %+v", lines)
+	}
+	if value != 374300 {
+		t.Errorf("value = %.2f, want 374300", value)
+	}
+
+	if _, _, err := ReceiveLines([]PurchaseOrderLine{pipe}, GRNLines{
+		{PurchaseOrderLineID: pipe.ID, ReceivedQuantity: 121},
+	}); err == nil || !strings.Contains(err.Error(), "exceed the ordered") {
+		t.Errorf("over-receipt err = %v", err)
+	}
+	if _, _, err := ReceiveLines([]PurchaseOrderLine{pipe}, GRNLines{
+		{PurchaseOrderLineID: pipe.ID, ReceivedQuantity: 10, AcceptedQuantity: 8},
+	}); err == nil {
+		t.Error("accepted and rejected not adding up to received was allowed")
+	}
+}
+
+func TestMatchInvoice(t *testing.T) {
+	pipe := PurchaseOrderLine{ID: uuid.New(), MaterialCode: "DI-300", UOM: "m", Quantity: 600, Rate: 2450, TaxPercent: 18, ReceivedQuantity: 480, InvoicedQuantity: 300}
+	cement := PurchaseOrderLine{ID: uuid.New(), MaterialCode: "OPC-53", UOM: "bag", Quantity: 1000, Rate: 380, TaxPercent: 28, ReceivedQuantity: 250}
+	order := []PurchaseOrderLine{pipe, cement}
+
+	lines, sub, tax, exceptions, err := MatchInvoice(order, InvoiceLines{
+		{PurchaseOrderLineID: pipe.ID, Quantity: 180, Rate: 2460, TaxPercent: 18},
+		{PurchaseOrderLineID: cement.ID, Quantity: 250, Rate: 380, TaxPercent: 28},
+	}, InvoiceMatchTolerance{RatePercent: 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exceptions) != 0 {
+		t.Errorf("exceptions = %v, want none", exceptions)
+	}
+	if lines[0].Amount != 442800 || sub != 537800 || tax != 106304 {
+		t.Errorf("amount = %.2f, sub = %.2f, tax = %.2f", lines[0].Amount, sub, tax)
+	}
+
+	_, _, _, exceptions, err = MatchInvoice(order, InvoiceLines{
+		{PurchaseOrderLineID: pipe.ID, Quantity: 150, Rate: 2450, TaxPercent: 18},
+		{PurchaseOrderLineID: pipe.ID, Quantity: 40, Rate: 2500, TaxPercent: 12},
+	}, InvoiceMatchTolerance{RatePercent: 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exceptions) != 3 {
+		t.Errorf("exceptions = %v, want rate, tax and quantity", exceptions)
+	}
+
+	if _, _, _, _, err := MatchInvoice(order, InvoiceLines{{PurchaseOrderLineID: uuid.New(), Quantity: 1}}, InvoiceMatchTolerance{}); err == nil {
+		t.Error("a line not on the order was matched")
+	}
+
+	// 2% over on quantity and ₹200 off on value fall within a business's wider tolerance
+	_, _, _, exceptions, _ = MatchInvoice(order, InvoiceLines{
+		{PurchaseOrderLineID: cement.ID, Quantity: 255, Rate: 380.5, TaxPercent: 28},
+	}, InvoiceMatchTolerance{QuantityPercent: 2, RateAmount: 200})
+	if len(exceptions) != 0 {
+		t.Errorf("exceptions = %v, want none within tolerance", exceptions)
+	}
+	_, _, _, exceptions, _ = MatchInvoice(order, InvoiceLines{
+		{PurchaseOrderLineID: cement.ID, Quantity: 255, Rate: 380.5, TaxPercent: 28},
+	}, InvoiceMatchTolerance{})
+	if len(exceptions) != 2 {
+		t.Errorf("exceptions = %v, want rate and quantity without tolerance", exceptions)
+	}
+}
+
+func TestAgingBucket(t *testing.T) {
+	due := time.Date(2026, 7, 31, 0, 0, 0, 0, time.UTC)
+	for asOf, want := range map[string]string{
+		"2026-07-15": AgingNotDue, "2026-07-31": AgingNotDue, "2026-08-01": Aging1To30,
+		"2026-09-29": Aging31To60, "2026-10-16": Aging61To90, "2026-12-01": AgingOver90,
+	} {
+		date, _ := time.Parse("2006-01-02", asOf)
+		if got, _ := AgingBucket(due, date); got != want {
+			t.Errorf("as of %s: bucket %s, want %s", asOf, got, want)
+		}
+	}
+}
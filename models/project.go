@@ -36,6 +36,19 @@ type Project struct {
 	AllocatedBudget float64 `gorm:"type:decimal(15,2);default:0" json:"allocated_budget"`
 	SpentBudget     float64 `gorm:"type:decimal(15,2);default:0" json:"spent_budget"`
 	Currency        string  `gorm:"size:10;default:'INR'" json:"currency"`
+	ExchangeRate    float64 `gorm:"type:decimal(18,8);default:1" json:"exchange_rate"` // to BaseCurrency, fixed when the project is created
+
+	// The budget in BaseCurrency, kept by the database
+	BaseTotalBudget float64 `gorm:"->;-:migration" json:"base_total_budget"`
+	BaseSpentBudget float64 `gorm:"->;-:migration" json:"base_spent_budget"`
+
+	// Cost center the project's costs are reported by unless tagged with another
+	CostCenterID *uuid.UUID `gorm:"type:uuid;index" json:"cost_center_id,omitempty"`
+
+	// Client billed for the project, for GST returns
+	ClientName    string `gorm:"size:255" json:"client_name,omitempty"`
+	ClientGSTIN   string `gorm:"size:15" json:"client_gstin,omitempty"`   // blank for an unregistered client
+	PlaceOfSupply string `gorm:"size:2" json:"place_of_supply,omitempty"` // GST state code of the site
 
 	// Status
 	Status   string  `gorm:"size:50;not null;default:'draft';index" json:"status"` // draft, active, on-hold, completed, cancelled
@@ -45,6 +58,9 @@ type Project struct {
 	WorkflowID *uuid.UUID          `gorm:"type:uuid" json:"workflow_id,omitempty"`
 	Workflow   *WorkflowDefinition `gorm:"foreignKey:WorkflowID" json:"workflow,omitempty"`
 
+	// Blueprint the project was instantiated from, if any
+	BlueprintID *uuid.UUID `gorm:"type:uuid;index" json:"blueprint_id,omitempty"`
+
 	// Metadata
 	CreatedBy string     `gorm:"size:255;not null" json:"created_by"`
 	UpdatedBy string     `gorm:"size:255" json:"updated_by,omitempty"`
@@ -192,11 +208,19 @@ type Tasks struct {
 	OtherCost       float64 `gorm:"type:decimal(15,2);default:0" json:"other_cost"`
 	TotalCost       float64 `gorm:"type:decimal(15,2);default:0" json:"total_cost"`
 
+	// Cost center the task's costs are reported by, if not its project's
+	CostCenterID *uuid.UUID `gorm:"type:uuid;index" json:"cost_center_id,omitempty"`
+
 	// Status and progress
 	Status   string  `gorm:"size:50;not null;default:'pending';index" json:"status"` // pending, assigned, in-progress, on-hold, completed, cancelled
 	Progress float64 `gorm:"type:decimal(5,2);default:0" json:"progress"`            // 0-100
 	Priority string  `gorm:"size:20;default:'medium';index" json:"priority"`         // low, medium, high, critical
 
+	// Quantities, when progress is measured by quantity executed
+	PlannedQuantity  float64 `gorm:"type:decimal(15,4);default:0" json:"planned_quantity"`
+	ExecutedQuantity float64 `gorm:"type:decimal(15,4);default:0" json:"executed_quantity"`
+	QuantityUOM      string  `gorm:"size:32" json:"quantity_uom,omitempty"`
+
 	// Workflow integration
 	WorkflowID   *uuid.UUID          `gorm:"type:uuid" json:"workflow_id,omitempty"`
 	Workflow     *WorkflowDefinition `gorm:"foreignKey:WorkflowID" json:"workflow,omitempty"`
@@ -282,6 +306,11 @@ type BudgetAllocation struct {
 	PlannedAmount float64 `gorm:"type:decimal(15,2);not null" json:"planned_amount"`
 	ActualAmount  float64 `gorm:"type:decimal(15,2);default:0" json:"actual_amount"`
 	Currency      string  `gorm:"size:10;default:'INR'" json:"currency"`
+	ExchangeRate  float64 `gorm:"type:decimal(18,8);default:1" json:"exchange_rate"` // to BaseCurrency on the allocation date
+
+	// The amounts in BaseCurrency, kept by the database
+	BasePlannedAmount float64 `gorm:"->;-:migration" json:"base_planned_amount"`
+	BaseActualAmount  float64 `gorm:"->;-:migration" json:"base_actual_amount"`
 
 	// Timeline
 	AllocationDate time.Time  `gorm:"not null" json:"allocation_date"`
@@ -359,6 +388,9 @@ type TaskComment struct {
 	ParentID *uuid.UUID   `gorm:"type:uuid;index" json:"parent_id,omitempty"`
 	Parent   *TaskComment `gorm:"foreignKey:ParentID" json:"parent,omitempty"`
 
+	// Users @-mentioned in the comment
+	MentionedUserIDs StringArray `gorm:"type:jsonb;default:'[]'" json:"mentioned_user_ids"`
+
 	// Metadata
 	IsEdited  bool       `gorm:"default:false" json:"is_edited"`
 	EditedAt  *time.Time `json:"edited_at,omitempty"`
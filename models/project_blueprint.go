@@ -0,0 +1,211 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProjectBlueprint is a reusable project template. Instantiating a blueprint creates
+// a project together with its zones, WBS task templates, BOQ skeleton and chat channels.
+type ProjectBlueprint struct {
+	ID                  uuid.UUID           `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Code                string              `gorm:"size:50;uniqueIndex;not null" json:"code"`
+	Name                string              `gorm:"size:255;not null" json:"name"`
+	Description         string              `gorm:"type:text" json:"description,omitempty"`
+	BusinessVerticalID  *uuid.UUID          `gorm:"type:uuid;index" json:"business_vertical_id,omitempty"` // null = usable by every vertical
+	BusinessVertical    *BusinessVertical   `gorm:"foreignKey:BusinessVerticalID" json:"business_vertical,omitempty"`
+	WorkflowID          *uuid.UUID          `gorm:"type:uuid" json:"workflow_id,omitempty"` // default workflow for created projects
+	Workflow            *WorkflowDefinition `gorm:"foreignKey:WorkflowID" json:"workflow,omitempty"`
+	Currency            string              `gorm:"size:10;default:'INR'" json:"currency"`
+	DefaultDurationDays int                 `gorm:"default:0" json:"default_duration_days"`
+	Spec                BlueprintSpec       `gorm:"type:jsonb;not null;default:'{}'" json:"spec"`
+	Version             int                 `gorm:"default:1" json:"version"`
+	IsActive            bool                `gorm:"default:true;index" json:"is_active"`
+
+	CreatedBy string     `gorm:"size:255;not null" json:"created_by"`
+	UpdatedBy string     `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `gorm:"index" json:"deleted_at,omitempty"`
+}
+
+func (ProjectBlueprint) TableName() string {
+	return "project_blueprints"
+}
+
+// BlueprintSpec is the content instantiated for each project created from a blueprint.
+type BlueprintSpec struct {
+	Zones         []BlueprintZone         `json:"zones"`
+	TaskTemplates []BlueprintTaskTemplate `json:"task_templates"`
+	BOQItems      []BlueprintBOQItem      `json:"boq_items"`
+	Channels      []BlueprintChannel      `json:"channels"`
+}
+
+// BlueprintZone is a zone created without geometry; geometry arrives later via KMZ upload.
+type BlueprintZone struct {
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Label       string `json:"label,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// BlueprintTaskTemplate becomes a WBS node. Offsets are relative to the project start date.
+type BlueprintTaskTemplate struct {
+	Code            string  `json:"code"`
+	ParentCode      string  `json:"parent_code,omitempty"`
+	Name            string  `json:"name"`
+	Description     string  `json:"description,omitempty"`
+	NodeType        string  `json:"node_type"` // package, activity, milestone
+	SortOrder       int     `json:"sort_order"`
+	StartOffsetDays int     `json:"start_offset_days"`
+	DurationDays    int     `json:"duration_days"`
+	Weightage       float64 `json:"weightage"`
+}
+
+// BlueprintBOQItem is a BOQ skeleton line, optionally linked to a task template by code.
+type BlueprintBOQItem struct {
+	Code            string  `json:"code"`
+	WBSCode         string  `json:"wbs_code,omitempty"`
+	Description     string  `json:"description"`
+	UOM             string  `json:"uom"`
+	PlannedQuantity float64 `json:"planned_quantity"`
+	UnitRate        float64 `json:"unit_rate"`
+}
+
+// BlueprintChannel is a chat channel created for the project's team.
+type BlueprintChannel struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description,omitempty"`
+	MemberIDs   []string `json:"member_ids,omitempty"`
+}
+
+// Scan implements the sql.Scanner interface
+func (s *BlueprintSpec) Scan(value interface{}) error {
+	if value == nil {
+		*s = BlueprintSpec{}
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		*s = BlueprintSpec{}
+		return nil
+	}
+
+	return json.Unmarshal(bytes, s)
+}
+
+// Value implements the driver.Valuer interface
+func (s BlueprintSpec) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// GormDataType defines the data type for GORM
+func (BlueprintSpec) GormDataType() string {
+	return "jsonb"
+}
+
+// Validate checks codes are unique, parent and WBS references resolve, and the
+// task template hierarchy has no cycles.
+func (s BlueprintSpec) Validate() error {
+	zoneCodes := make(map[string]bool, len(s.Zones))
+	for i, zone := range s.Zones {
+		if strings.TrimSpace(zone.Name) == "" {
+			return fmt.Errorf("zones[%d]: name is required", i)
+		}
+		if zone.Code != "" {
+			if zoneCodes[zone.Code] {
+				return fmt.Errorf("zones[%d]: duplicate code %s", i, zone.Code)
+			}
+			zoneCodes[zone.Code] = true
+		}
+	}
+
+	parents := make(map[string]string, len(s.TaskTemplates))
+	for i, task := range s.TaskTemplates {
+		if strings.TrimSpace(task.Code) == "" || strings.TrimSpace(task.Name) == "" {
+			return fmt.Errorf("task_templates[%d]: code and name are required", i)
+		}
+		if _, exists := parents[task.Code]; exists {
+			return fmt.Errorf("task_templates[%d]: duplicate code %s", i, task.Code)
+		}
+		switch task.NodeType {
+		case "", "package", "activity", "milestone":
+		default:
+			return fmt.Errorf("task_templates[%d]: node_type must be package, activity, or milestone", i)
+		}
+		if task.StartOffsetDays < 0 || task.DurationDays < 0 {
+			return fmt.Errorf("task_templates[%d]: offsets and durations cannot be negative", i)
+		}
+		parents[task.Code] = task.ParentCode
+	}
+	for code, parent := range parents {
+		if parent == "" {
+			continue
+		}
+		if _, ok := parents[parent]; !ok {
+			return fmt.Errorf("task template %s: unknown parent_code %s", code, parent)
+		}
+		seen := map[string]bool{code: true}
+		for p := parent; p != ""; p = parents[p] {
+			if seen[p] {
+				return fmt.Errorf("task template %s: parent_code cycle detected", code)
+			}
+			seen[p] = true
+		}
+	}
+
+	boqCodes := make(map[string]bool, len(s.BOQItems))
+	for i, item := range s.BOQItems {
+		if strings.TrimSpace(item.Code) == "" || strings.TrimSpace(item.Description) == "" || strings.TrimSpace(item.UOM) == "" {
+			return fmt.Errorf("boq_items[%d]: code, description and uom are required", i)
+		}
+		if boqCodes[item.Code] {
+			return fmt.Errorf("boq_items[%d]: duplicate code %s", i, item.Code)
+		}
+		boqCodes[item.Code] = true
+		if item.WBSCode != "" {
+			if _, ok := parents[item.WBSCode]; !ok {
+				return fmt.Errorf("boq_items[%d]: unknown wbs_code %s", i, item.WBSCode)
+			}
+		}
+		if item.PlannedQuantity < 0 || item.UnitRate < 0 {
+			return fmt.Errorf("boq_items[%d]: quantity and rate cannot be negative", i)
+		}
+	}
+
+	for i, channel := range s.Channels {
+		if strings.TrimSpace(channel.Title) == "" {
+			return fmt.Errorf("channels[%d]: title is required", i)
+		}
+	}
+
+	return nil
+}
+
+// OrderedTaskTemplates returns task templates with every parent before its children.
+// The spec must already be valid.
+func (s BlueprintSpec) OrderedTaskTemplates() []BlueprintTaskTemplate {
+	ordered := make([]BlueprintTaskTemplate, 0, len(s.TaskTemplates))
+	placed := make(map[string]bool, len(s.TaskTemplates))
+	for len(ordered) < len(s.TaskTemplates) {
+		progressed := false
+		for _, task := range s.TaskTemplates {
+			if placed[task.Code] || (task.ParentCode != "" && !placed[task.ParentCode]) {
+				continue
+			}
+			ordered = append(ordered, task)
+			placed[task.Code] = true
+			progressed = true
+		}
+		if !progressed {
+			break
+		}
+	}
+	return ordered
+}
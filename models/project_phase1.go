@@ -87,23 +87,36 @@ func (BOQItem) TableName() string {
 	return "boq_items"
 }
 
-// MBEntry stores measured quantities used for billing and progress.
+// MBEntry stores measured quantities used for billing and progress. Its quantity comes
+// from its dimension lines when it has any. It is prepared, checked and approved before
+// its quantity counts as executed and it can be billed on an RA bill.
 type MBEntry struct {
-	ID              uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	ProjectID       uuid.UUID `gorm:"type:uuid;not null;index" json:"project_id"`
-	Project         *Project  `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
-	BOQItemID       uuid.UUID `gorm:"type:uuid;not null;index" json:"boq_item_id"`
-	BOQItem         *BOQItem  `gorm:"foreignKey:BOQItemID" json:"boq_item,omitempty"`
-	EntryNumber     string    `gorm:"size:64;not null;index" json:"entry_number"`
-	MeasurementDate time.Time `gorm:"not null;index" json:"measurement_date"`
-	MeasuredQty     float64   `gorm:"type:decimal(15,4);not null" json:"measured_qty"`
-	Rate            float64   `gorm:"type:decimal(15,2);default:0" json:"rate"`
-	Amount          float64   `gorm:"type:decimal(15,2);default:0" json:"amount"`
-	LocationRef     string    `gorm:"size:255" json:"location_ref,omitempty"`
-	Remarks         string    `gorm:"type:text" json:"remarks,omitempty"`
-	RecordedBy      string    `gorm:"size:255;not null" json:"recorded_by"`
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
+	ID              uuid.UUID    `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	ProjectID       uuid.UUID    `gorm:"type:uuid;not null;index" json:"project_id"`
+	Project         *Project     `gorm:"foreignKey:ProjectID" json:"project,omitempty"`
+	BOQItemID       uuid.UUID    `gorm:"type:uuid;not null;index" json:"boq_item_id"`
+	BOQItem         *BOQItem     `gorm:"foreignKey:BOQItemID" json:"boq_item,omitempty"`
+	TaskID          *uuid.UUID   `gorm:"type:uuid;index" json:"task_id,omitempty"`
+	Task            *Tasks       `gorm:"foreignKey:TaskID" json:"task,omitempty"`
+	EntryNumber     string       `gorm:"size:64;not null;index" json:"entry_number"`
+	MeasurementDate time.Time    `gorm:"not null;index" json:"measurement_date"`
+	Description     string       `gorm:"type:text" json:"description,omitempty"`
+	Dimensions      MBDimensions `gorm:"type:jsonb;default:'[]'" json:"dimensions"`
+	MeasuredQty     float64      `gorm:"type:decimal(15,4);not null" json:"measured_qty"`
+	Rate            float64      `gorm:"type:decimal(15,2);default:0" json:"rate"`
+	Amount          float64      `gorm:"type:decimal(15,2);default:0" json:"amount"`
+	LocationRef     string       `gorm:"size:255" json:"location_ref,omitempty"`
+	Remarks         string       `gorm:"type:text" json:"remarks,omitempty"`
+	Status          string       `gorm:"size:20;not null;default:'prepared';index" json:"status"`
+	RecordedBy      string       `gorm:"size:255;not null" json:"recorded_by"`
+	CheckedBy       string       `gorm:"size:255" json:"checked_by,omitempty"`
+	CheckedAt       *time.Time   `json:"checked_at,omitempty"`
+	ApprovedBy      string       `gorm:"size:255" json:"approved_by,omitempty"`
+	ApprovedAt      *time.Time   `json:"approved_at,omitempty"`
+	ReviewRemarks   string       `gorm:"type:text" json:"review_remarks,omitempty"`
+	RABillID        *uuid.UUID   `gorm:"type:uuid;index" json:"ra_bill_id,omitempty"` // the bill it was billed on
+	CreatedAt       time.Time    `json:"created_at"`
+	UpdatedAt       time.Time    `json:"updated_at"`
 }
 
 func (MBEntry) TableName() string {
@@ -0,0 +1,132 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Push delivery statuses
+const (
+	PushDeliveryPending = "pending"
+	PushDeliverySent    = "sent"
+	PushDeliveryFailed  = "failed"  // every attempt failed
+	PushDeliverySkipped = "skipped" // muted by the user, or no device to push to
+)
+
+// DefaultPushMaxAttempts is how many times a push is tried before it is given up
+const DefaultPushMaxAttempts = 5
+
+// PushDelivery queues a notification to be pushed to the recipient's registered phones
+// through FCM, which relays to APNs for iOS devices. Failed sends are retried with
+// backoff; pushes held back by quiet hours wait in the queue until they end.
+type PushDelivery struct {
+	ID             uuid.UUID            `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	NotificationID uuid.UUID            `gorm:"type:uuid;not null;uniqueIndex" json:"notification_id"`
+	UserID         string               `gorm:"size:255;not null;index" json:"user_id"`
+	Type           NotificationType     `gorm:"size:50;not null" json:"type"`
+	Priority       NotificationPriority `gorm:"size:20;not null;default:'normal'" json:"priority"`
+	Title          string               `gorm:"size:500;not null" json:"title"`
+	Body           string               `gorm:"type:text;not null" json:"body"`
+	Data           JSONMap              `gorm:"type:jsonb" json:"data,omitempty"`
+
+	Status        string     `gorm:"size:20;not null;default:'pending';index:idx_push_deliveries_due,priority:1" json:"status"`
+	Attempts      int        `gorm:"default:0" json:"attempts"`
+	MaxAttempts   int        `gorm:"default:5" json:"max_attempts"`
+	LastError     string     `gorm:"type:text" json:"last_error,omitempty"`
+	NextAttemptAt time.Time  `gorm:"not null;index:idx_push_deliveries_due,priority:2" json:"next_attempt_at"`
+	DevicesSent   int        `gorm:"default:0" json:"devices_sent"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (PushDelivery) TableName() string {
+	return "push_deliveries"
+}
+
+// StringData returns the push's data payload as FCM takes it, all values strings
+func (d PushDelivery) StringData() map[string]string {
+	data := make(map[string]string, len(d.Data))
+	for k, v := range d.Data {
+		if s, ok := v.(string); ok {
+			data[k] = s
+		}
+	}
+	return data
+}
+
+// Requeue puts a failed push back in the queue to be sent again from its first attempt
+func (d *PushDelivery) Requeue(now time.Time) error {
+	if d.Status != PushDeliveryFailed {
+		return errors.New("only failed pushes can be requeued")
+	}
+	d.Status = PushDeliveryPending
+	d.Attempts = 0
+	d.NextAttemptAt = now
+	d.LastError = ""
+	return nil
+}
+
+// PushRetryDelay is how long a push waits before its next attempt after attempts failed
+// ones: a minute, then four times longer each time, at most two hours
+func PushRetryDelay(attempts int) time.Duration {
+	delay := time.Minute
+	for i := 1; i < attempts && delay < 2*time.Hour; i++ {
+		delay *= 4
+	}
+	if delay > 2*time.Hour {
+		delay = 2 * time.Hour
+	}
+	return delay
+}
+
+// MobilePushAt decides whether a notification of the type and priority is pushed to the
+// user's phones and when: never when mobile push or the type is switched off, and at the
+// end of quiet hours rather than during them unless it is critical. Quiet hours are read
+// in now's location. Emergency broadcasts are always pushed at once.
+func (p *NotificationPreference) MobilePushAt(t NotificationType, priority NotificationPriority, now time.Time) (time.Time, bool) {
+	if t == NotificationTypeEmergency {
+		return now, true
+	}
+	if !p.EnableMobilePush {
+		return time.Time{}, false
+	}
+	for _, disabled := range p.DisabledTypes {
+		if disabled == string(t) {
+			return time.Time{}, false
+		}
+	}
+	for _, disabled := range p.MobilePushDisabledTypes {
+		if disabled == string(t) {
+			return time.Time{}, false
+		}
+	}
+	if !p.QuietHoursEnabled || priority == NotificationPriorityCritical {
+		return now, true
+	}
+
+	start, err1 := time.Parse("15:04", p.QuietHoursStart)
+	end, err2 := time.Parse("15:04", p.QuietHoursEnd)
+	if err1 != nil || err2 != nil || start.Equal(end) {
+		return now, true
+	}
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	at := func(d time.Time, clock time.Time) time.Time {
+		return d.Add(time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute)
+	}
+	quietFrom, quietUntil := at(day, start), at(day, end)
+	if end.Before(start) {
+		// quiet overnight: from start today to end tomorrow, or from yesterday into this morning
+		if now.Before(quietUntil) {
+			quietFrom = at(day.AddDate(0, 0, -1), start)
+		} else {
+			quietUntil = at(day.AddDate(0, 0, 1), end)
+		}
+	}
+	if !now.Before(quietFrom) && now.Before(quietUntil) {
+		return quietUntil, true
+	}
+	return now, true
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPushRetryDelay(t *testing.T) {
+	want := []time.Duration{time.Minute, 4 * time.Minute, 16 * time.Minute, 64 * time.Minute, 2 * time.Hour, 2 * time.Hour}
+	for i, w := range want {
+		if got := PushRetryDelay(i + 1); got != w {
+			t.Errorf("PushRetryDelay(%d) = %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestPushDeliveryRequeue(t *testing.T) {
+	now := time.Now()
+	d := PushDelivery{Status: PushDeliveryFailed, Attempts: 5, LastError: "unavailable"}
+	if err := d.Requeue(now); err != nil || d.Status != PushDeliveryPending || d.Attempts != 0 || !d.NextAttemptAt.Equal(now) {
+		t.Errorf("requeue: %v, %+v", err, d)
+	}
+	for _, status := range []string{PushDeliveryPending, PushDeliverySent, PushDeliverySkipped} {
+		if err := (&PushDelivery{Status: status}).Requeue(now); err == nil {
+			t.Errorf("%s push requeued", status)
+		}
+	}
+}
+
+func TestMobilePushAt(t *testing.T) {
+	loc := time.FixedZone("IST", 5*3600+1800)
+	prefs := NotificationPreference{
+		EnableMobilePush:        true,
+		MobilePushDisabledTypes: StringArray{string(NotificationTypeChatMessage)},
+		QuietHoursEnabled:       true,
+		QuietHoursStart:         "22:00",
+		QuietHoursEnd:           "07:00",
+	}
+	afternoon := time.Date(2026, 10, 16, 15, 0, 0, 0, loc)
+	lateNight := time.Date(2026, 10, 16, 23, 30, 0, 0, loc)
+	earlyMorning := time.Date(2026, 10, 16, 5, 0, 0, 0, loc)
+
+	if at, ok := prefs.MobilePushAt(NotificationTypeTelemetryAlarm, NotificationPriorityHigh, afternoon); !ok || !at.Equal(afternoon) {
+		t.Errorf("afternoon push at %v, %v; want now", at, ok)
+	}
+	if at, ok := prefs.MobilePushAt(NotificationTypeTelemetryAlarm, NotificationPriorityHigh, lateNight); !ok ||
+		!at.Equal(time.Date(2026, 10, 17, 7, 0, 0, 0, loc)) {
+		t.Errorf("late night push at %v; want 07:00 next day", at)
+	}
+	if at, ok := prefs.MobilePushAt(NotificationTypeWorkflowTransition, NotificationPriorityNormal, earlyMorning); !ok ||
+		!at.Equal(time.Date(2026, 10, 16, 7, 0, 0, 0, loc)) {
+		t.Errorf("early morning push at %v; want 07:00 the same day", at)
+	}
+	if at, _ := prefs.MobilePushAt(NotificationTypeTelemetryAlarm, NotificationPriorityCritical, lateNight); !at.Equal(lateNight) {
+		t.Error("critical push held back by quiet hours")
+	}
+	if _, ok := prefs.MobilePushAt(NotificationTypeChatMessage, NotificationPriorityNormal, afternoon); ok {
+		t.Error("type muted on push was pushed")
+	}
+
+	off := NotificationPreference{EnableMobilePush: false}
+	if _, ok := off.MobilePushAt(NotificationTypeApprovalDelegation, NotificationPriorityNormal, afternoon); ok {
+		t.Error("pushed with mobile push off")
+	}
+	if _, ok := off.MobilePushAt(NotificationTypeEmergency, NotificationPriorityCritical, afternoon); !ok {
+		t.Error("emergency broadcast not pushed")
+	}
+
+	daytime := NotificationPreference{EnableMobilePush: true, QuietHoursEnabled: true, QuietHoursStart: "13:00", QuietHoursEnd: "14:00"}
+	lunch := time.Date(2026, 10, 16, 13, 30, 0, 0, loc)
+	if at, _ := daytime.MobilePushAt(NotificationTypeChatMessage, NotificationPriorityNormal, lunch); !at.Equal(time.Date(2026, 10, 16, 14, 0, 0, 0, loc)) {
+		t.Errorf("push during daytime quiet hours at %v; want 14:00", at)
+	}
+}
@@ -0,0 +1,213 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// What a reminder can be about
+const (
+	ReminderEntityTask        = "task"
+	ReminderEntityApproval    = "approval"           // a form submission awaiting a workflow decision
+	ReminderEntityMaintenance = "maintenance_ticket" // an asset maintenance task
+)
+
+// Reminder recurrences; a reminder without one fires once
+const (
+	ReminderDaily   = "daily"
+	ReminderWeekly  = "weekly"
+	ReminderMonthly = "monthly"
+)
+
+// Reminder statuses
+const (
+	ReminderScheduled = "scheduled"
+	ReminderCompleted = "completed" // fired for the last time
+	ReminderCancelled = "cancelled"
+)
+
+// maxReminderInterval bounds how many days, weeks or months apart a recurrence may be
+const maxReminderInterval = 365
+
+// Reminder nudges a user, or everyone holding a business role, about a task, approval
+// or maintenance ticket at RemindAt and then on its recurrence until Until. Each firing
+// is an in-app notification, also pushed and texted when those channels are chosen.
+type Reminder struct {
+	ID                 uuid.UUID            `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID            `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	EntityType         string               `gorm:"size:30;not null;index:idx_reminders_entity,priority:1" json:"entity_type"`
+	EntityID           uuid.UUID            `gorm:"type:uuid;not null;index:idx_reminders_entity,priority:2" json:"entity_id"`
+	Title              string               `gorm:"size:255;not null" json:"title"`
+	Message            string               `gorm:"type:text" json:"message,omitempty"`
+	Priority           NotificationPriority `gorm:"size:20;not null;default:'normal'" json:"priority"`
+
+	// Exactly one recipient: a user, or the holders of a business role when it fires
+	UserID   *uuid.UUID     `gorm:"type:uuid;index" json:"user_id,omitempty"`
+	RoleID   *uuid.UUID     `gorm:"type:uuid;index" json:"role_id,omitempty"`
+	Channels pq.StringArray `gorm:"type:text[]" json:"channels"` // in_app, mobile_push, sms
+
+	RemindAt  time.Time  `gorm:"not null" json:"remind_at"`          // first firing, and the anchor of the recurrence
+	Frequency string     `gorm:"size:10" json:"frequency,omitempty"` // daily, weekly or monthly; empty fires once
+	Interval  int        `gorm:"not null;default:1" json:"interval"` // every Interval days, weeks or months
+	Until     *time.Time `json:"until,omitempty"`
+
+	Status      string     `gorm:"size:20;not null;default:'scheduled';index" json:"status"`
+	NextRunAt   *time.Time `gorm:"index" json:"next_run_at,omitempty"` // none once completed or cancelled
+	LastSentAt  *time.Time `json:"last_sent_at,omitempty"`
+	Occurrences int        `gorm:"not null;default:0" json:"occurrences"`
+	CancelledAt *time.Time `json:"cancelled_at,omitempty"`
+	CancelledBy *uuid.UUID `gorm:"type:uuid" json:"cancelled_by,omitempty"`
+
+	CreatedBy uuid.UUID `gorm:"type:uuid;not null;index" json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Reminder) TableName() string {
+	return "reminders"
+}
+
+// ValidReminderEntity reports whether reminders can be set on the entity type
+func ValidReminderEntity(entityType string) bool {
+	switch entityType {
+	case ReminderEntityTask, ReminderEntityApproval, ReminderEntityMaintenance:
+		return true
+	}
+	return false
+}
+
+// Validate checks a new reminder, fills in defaults and schedules its first firing
+func (r *Reminder) Validate(now time.Time) error {
+	r.Title = strings.TrimSpace(r.Title)
+	if r.Title == "" {
+		return errors.New("title is required")
+	}
+	if !ValidReminderEntity(r.EntityType) {
+		return errors.New("entity_type must be task, approval or maintenance_ticket")
+	}
+	if r.EntityID == uuid.Nil {
+		return errors.New("entity_id is required")
+	}
+	if (r.UserID == nil) == (r.RoleID == nil) {
+		return errors.New("remind either a user or a role")
+	}
+	switch r.Priority {
+	case "":
+		r.Priority = NotificationPriorityNormal
+	case NotificationPriorityLow, NotificationPriorityNormal, NotificationPriorityHigh, NotificationPriorityCritical:
+	default:
+		return errors.New("priority must be low, normal, high or critical")
+	}
+
+	if len(r.Channels) == 0 {
+		r.Channels = pq.StringArray{string(NotificationChannelInApp), string(NotificationChannelMobilePush)}
+	}
+	if !r.HasChannel(NotificationChannelInApp) {
+		r.Channels = append(pq.StringArray{string(NotificationChannelInApp)}, r.Channels...)
+	}
+	for _, c := range r.Channels {
+		switch NotificationChannel(c) {
+		case NotificationChannelInApp, NotificationChannelMobilePush, NotificationChannelSMS:
+		default:
+			return errors.New("channels must be in_app, mobile_push or sms")
+		}
+	}
+
+	if r.RemindAt.IsZero() {
+		return errors.New("remind_at is required")
+	}
+	if r.RemindAt.Before(now.Add(-time.Minute)) {
+		return errors.New("remind_at must not be in the past")
+	}
+	switch r.Frequency {
+	case "":
+		r.Interval = 1
+		r.Until = nil
+	case ReminderDaily, ReminderWeekly, ReminderMonthly:
+		if r.Interval == 0 {
+			r.Interval = 1
+		}
+		if r.Interval < 1 || r.Interval > maxReminderInterval {
+			return errors.New("interval must be between 1 and 365")
+		}
+		if r.Until != nil && r.Until.Before(r.RemindAt) {
+			return errors.New("until must not be before remind_at")
+		}
+	default:
+		return errors.New("frequency must be daily, weekly or monthly")
+	}
+
+	r.Status = ReminderScheduled
+	next := r.RemindAt
+	r.NextRunAt = &next
+	return nil
+}
+
+// HasChannel reports whether the reminder is delivered on the channel
+func (r *Reminder) HasChannel(channel NotificationChannel) bool {
+	for _, c := range r.Channels {
+		if c == string(channel) {
+			return true
+		}
+	}
+	return false
+}
+
+// Occurrence returns the reminder's k-th firing after RemindAt, counted in loc so that
+// daily reminders keep their wall-clock time. Monthly reminders anchored on a day some
+// months lack fire on those months' last day.
+func (r *Reminder) Occurrence(k int, loc *time.Location) time.Time {
+	at := r.RemindAt.In(loc)
+	switch r.Frequency {
+	case ReminderDaily:
+		return at.AddDate(0, 0, k*r.Interval)
+	case ReminderWeekly:
+		return at.AddDate(0, 0, 7*k*r.Interval)
+	case ReminderMonthly:
+		first := time.Date(at.Year(), at.Month()+time.Month(k*r.Interval), 1, at.Hour(), at.Minute(), at.Second(), 0, loc)
+		day := at.Day()
+		if last := first.AddDate(0, 1, -1).Day(); day > last {
+			day = last
+		}
+		return first.AddDate(0, 0, day-1)
+	}
+	return at
+}
+
+// Advance records a firing at now and schedules the next occurrence after it, skipping
+// any missed while the reminder could not fire. The reminder completes when it does not
+// recur or its next occurrence is past Until.
+func (r *Reminder) Advance(now time.Time, loc *time.Location) {
+	r.Occurrences++
+	r.LastSentAt = &now
+	r.NextRunAt = nil
+	if r.Frequency != "" {
+		for k := 1; ; k++ {
+			next := r.Occurrence(k, loc)
+			if r.Until != nil && next.After(*r.Until) {
+				break
+			}
+			if next.After(now) {
+				r.NextRunAt = &next
+				return
+			}
+		}
+	}
+	r.Status = ReminderCompleted
+}
+
+// Cancel stops a scheduled reminder
+func (r *Reminder) Cancel(by uuid.UUID, now time.Time) error {
+	if r.Status != ReminderScheduled {
+		return errors.New("reminder is already " + r.Status)
+	}
+	r.Status = ReminderCancelled
+	r.NextRunAt = nil
+	r.CancelledAt = &now
+	r.CancelledBy = &by
+	return nil
+}
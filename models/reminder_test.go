@@ -0,0 +1,86 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestReminderValidate(t *testing.T) {
+	now := time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)
+	userID := uuid.New()
+	valid := func() Reminder {
+		return Reminder{Title: " Submit the pump log ", EntityType: ReminderEntityMaintenance, EntityID: uuid.New(),
+			UserID: &userID, RemindAt: now.Add(time.Hour), Frequency: ReminderWeekly}
+	}
+
+	r := valid()
+	if err := r.Validate(now); err != nil {
+		t.Fatalf("valid reminder rejected: %v", err)
+	}
+	if r.Title != "Submit the pump log" || r.Interval != 1 || r.Status != ReminderScheduled ||
+		!r.NextRunAt.Equal(r.RemindAt) || !r.HasChannel(NotificationChannelMobilePush) || r.HasChannel(NotificationChannelSMS) {
+		t.Errorf("defaults not applied: %+v", r)
+	}
+
+	roleID := uuid.New()
+	cases := map[string]func(*Reminder){
+		"unknown entity":      func(r *Reminder) { r.EntityType = "invoice" },
+		"user and role":       func(r *Reminder) { r.RoleID = &roleID },
+		"no recipient":        func(r *Reminder) { r.UserID = nil },
+		"in the past":         func(r *Reminder) { r.RemindAt = now.Add(-time.Hour) },
+		"unknown frequency":   func(r *Reminder) { r.Frequency = "hourly" },
+		"until before start":  func(r *Reminder) { until := now; r.Until = &until },
+		"unsupported channel": func(r *Reminder) { r.Channels = []string{"whatsapp"} },
+		"interval too long":   func(r *Reminder) { r.Interval = 400 },
+	}
+	for name, mutate := range cases {
+		r := valid()
+		mutate(&r)
+		if err := r.Validate(now); err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+}
+
+func TestReminderAdvance(t *testing.T) {
+	loc := time.FixedZone("IST", 5*3600+1800)
+	start := time.Date(2026, 1, 31, 9, 0, 0, 0, loc)
+
+	monthly := Reminder{RemindAt: start, Frequency: ReminderMonthly, Interval: 1, Status: ReminderScheduled}
+	want := []time.Time{
+		time.Date(2026, 2, 28, 9, 0, 0, 0, loc),
+		time.Date(2026, 3, 31, 9, 0, 0, 0, loc),
+		time.Date(2026, 4, 30, 9, 0, 0, 0, loc),
+	}
+	at := start
+	for _, w := range want {
+		monthly.Advance(at, loc)
+		if monthly.NextRunAt == nil || !monthly.NextRunAt.Equal(w) {
+			t.Fatalf("after %v next = %v, want %v", at, monthly.NextRunAt, w)
+		}
+		at = *monthly.NextRunAt
+	}
+
+	// a daily reminder that could not fire for three days resumes with the next one due
+	until := start.AddDate(0, 0, 10)
+	daily := Reminder{RemindAt: start, Frequency: ReminderDaily, Interval: 2, Until: &until, Status: ReminderScheduled}
+	daily.Advance(start.AddDate(0, 0, 3).Add(time.Hour), loc)
+	if !daily.NextRunAt.Equal(start.AddDate(0, 0, 4)) || daily.Occurrences != 1 {
+		t.Errorf("missed occurrences not skipped: next %v", daily.NextRunAt)
+	}
+	daily.Advance(start.AddDate(0, 0, 10), loc)
+	if daily.Status != ReminderCompleted || daily.NextRunAt != nil {
+		t.Errorf("reminder past until still scheduled: %+v", daily)
+	}
+
+	once := Reminder{RemindAt: start, Status: ReminderScheduled}
+	once.Advance(start, loc)
+	if once.Status != ReminderCompleted || once.NextRunAt != nil {
+		t.Errorf("one-off reminder still scheduled: %+v", once)
+	}
+	if err := once.Cancel(uuid.New(), start); err == nil {
+		t.Error("completed reminder cancelled")
+	}
+}
@@ -0,0 +1,202 @@
+package models
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Role kinds tracked by RoleChange
+const (
+	RoleTypeGlobal   = "role"
+	RoleTypeBusiness = "business_role"
+)
+
+// Role change actions
+const (
+	RoleChangeCreated = "created"
+	RoleChangeUpdated = "updated"
+	RoleChangeDeleted = "deleted"
+)
+
+// Role change sources
+const (
+	RoleChangeSourceAPI     = "api"
+	RoleChangeSourceSeeding = "seeding"
+)
+
+// RoleChange is one versioned entry in the history of a global or business role: who
+// changed it, when, and what changed in its fields and permission set.
+type RoleChange struct {
+	ID                 uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	RoleType           string         `gorm:"size:20;not null;uniqueIndex:idx_role_change_version,priority:1" json:"role_type"`
+	RoleID             uuid.UUID      `gorm:"type:uuid;not null;uniqueIndex:idx_role_change_version,priority:2" json:"role_id"`
+	Version            int            `gorm:"not null;uniqueIndex:idx_role_change_version,priority:3" json:"version"`
+	BusinessVerticalID *uuid.UUID     `gorm:"type:uuid;index" json:"business_vertical_id,omitempty"`
+	RoleName           string         `gorm:"size:100" json:"role_name"`
+	Action             string         `gorm:"size:20;not null" json:"action"`
+	Source             string         `gorm:"size:20;not null" json:"source"`
+	ChangedBy          *uuid.UUID     `gorm:"type:uuid" json:"changed_by,omitempty"`
+	FieldChanges       datatypes.JSON `gorm:"type:jsonb" json:"field_changes,omitempty"`
+	PermissionsAdded   pq.StringArray `gorm:"type:text[]" json:"permissions_added"`
+	PermissionsRemoved pq.StringArray `gorm:"type:text[]" json:"permissions_removed"`
+	Permissions        pq.StringArray `gorm:"type:text[]" json:"permissions"` // the full set after the change
+	CreatedAt          time.Time      `gorm:"index" json:"created_at"`
+}
+
+func (RoleChange) TableName() string {
+	return "role_changes"
+}
+
+// RoleSnapshot is the state of a role compared between history entries
+type RoleSnapshot struct {
+	Name               string
+	DisplayName        string
+	Description        string
+	Level              int
+	IsActive           bool
+	IsReadOnly         bool
+	BusinessVerticalID *uuid.UUID
+	Permissions        []string
+}
+
+// RoleFieldChange is the before and after value of one role field
+type RoleFieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// SnapshotRole loads the current state of a role, or nil when it no longer exists
+func SnapshotRole(db *gorm.DB, roleType string, roleID uuid.UUID) (*RoleSnapshot, error) {
+	var snapshot RoleSnapshot
+	switch roleType {
+	case RoleTypeGlobal:
+		var role Role
+		if err := db.Preload("Permissions").First(&role, "id = ?", roleID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, nil
+			}
+			return nil, err
+		}
+		snapshot = RoleSnapshot{Name: role.Name, Description: role.Description, Level: role.Level, IsActive: role.IsActive, IsReadOnly: role.IsReadOnly}
+		for _, perm := range role.Permissions {
+			snapshot.Permissions = append(snapshot.Permissions, perm.Name)
+		}
+	default:
+		var role BusinessRole
+		if err := db.Preload("Permissions").First(&role, "id = ?", roleID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return nil, nil
+			}
+			return nil, err
+		}
+		snapshot = RoleSnapshot{Name: role.Name, DisplayName: role.DisplayName, Description: role.Description, Level: role.Level,
+			IsActive: role.IsActive, IsReadOnly: role.IsReadOnly, BusinessVerticalID: &role.BusinessVerticalID}
+		for _, perm := range role.Permissions {
+			snapshot.Permissions = append(snapshot.Permissions, perm.Name)
+		}
+	}
+	sort.Strings(snapshot.Permissions)
+	return &snapshot, nil
+}
+
+// DiffRoleSnapshots compares two states of a role. before is nil for a new role and
+// after is nil for a deleted one.
+func DiffRoleSnapshots(before, after *RoleSnapshot) (fields map[string]RoleFieldChange, added, removed []string) {
+	var zero RoleSnapshot
+	if before == nil {
+		before = &zero
+	}
+	if after == nil {
+		after = &zero
+	}
+
+	fields = make(map[string]RoleFieldChange)
+	compare := func(name string, from, to interface{}) {
+		if from != to {
+			fields[name] = RoleFieldChange{From: from, To: to}
+		}
+	}
+	compare("name", before.Name, after.Name)
+	compare("display_name", before.DisplayName, after.DisplayName)
+	compare("description", before.Description, after.Description)
+	compare("level", before.Level, after.Level)
+	compare("is_active", before.IsActive, after.IsActive)
+	compare("is_read_only", before.IsReadOnly, after.IsReadOnly)
+
+	had := make(map[string]bool, len(before.Permissions))
+	for _, perm := range before.Permissions {
+		had[perm] = true
+	}
+	has := make(map[string]bool, len(after.Permissions))
+	for _, perm := range after.Permissions {
+		has[perm] = true
+		if !had[perm] {
+			added = append(added, perm)
+		}
+	}
+	for _, perm := range before.Permissions {
+		if !has[perm] {
+			removed = append(removed, perm)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return fields, added, removed
+}
+
+// RecordRoleChange appends a history entry comparing before with the role's current
+// state (nil once hard-deleted). Updates that change nothing, e.g. re-seeding an
+// unchanged role, are not recorded.
+func RecordRoleChange(db *gorm.DB, roleType string, roleID uuid.UUID, action, source string, changedBy *uuid.UUID, before *RoleSnapshot) error {
+	after, err := SnapshotRole(db, roleType, roleID)
+	if err != nil {
+		return err
+	}
+
+	fields, added, removed := DiffRoleSnapshots(before, after)
+	if action == RoleChangeUpdated && len(fields) == 0 && len(added) == 0 && len(removed) == 0 {
+		return nil
+	}
+
+	current := after
+	if current == nil {
+		current = before
+	}
+	change := RoleChange{
+		RoleType:           roleType,
+		RoleID:             roleID,
+		Action:             action,
+		Source:             source,
+		ChangedBy:          changedBy,
+		PermissionsAdded:   added,
+		PermissionsRemoved: removed,
+	}
+	if current != nil {
+		change.RoleName = current.Name
+		change.BusinessVerticalID = current.BusinessVerticalID
+	}
+	if after != nil {
+		change.Permissions = after.Permissions
+	}
+	if len(fields) > 0 {
+		encoded, err := json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+		change.FieldChanges = encoded
+	}
+
+	if err := db.Model(&RoleChange{}).
+		Where("role_type = ? AND role_id = ?", roleType, roleID).
+		Select("COALESCE(MAX(version), 0) + 1").
+		Scan(&change.Version).Error; err != nil {
+		return err
+	}
+	return db.Create(&change).Error
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffRoleSnapshots(t *testing.T) {
+	before := &RoleSnapshot{Name: "Manager", Description: "old", Level: 3, IsActive: true, Permissions: []string{"hr:read", "payroll:approve"}}
+	after := &RoleSnapshot{Name: "Manager", Description: "new", Level: 3, IsActive: true, Permissions: []string{"finance:read", "hr:read"}}
+
+	fields, added, removed := DiffRoleSnapshots(before, after)
+	if len(fields) != 1 || fields["description"].From != "old" || fields["description"].To != "new" {
+		t.Errorf("unexpected field changes: %v", fields)
+	}
+	if !reflect.DeepEqual(added, []string{"finance:read"}) || !reflect.DeepEqual(removed, []string{"payroll:approve"}) {
+		t.Errorf("added %v removed %v", added, removed)
+	}
+
+	fields, added, removed = DiffRoleSnapshots(before, before)
+	if len(fields) != 0 || len(added) != 0 || len(removed) != 0 {
+		t.Errorf("identical snapshots should not differ: %v %v %v", fields, added, removed)
+	}
+
+	_, added, _ = DiffRoleSnapshots(nil, after)
+	if len(added) != 2 {
+		t.Errorf("new role should add all permissions, got %v", added)
+	}
+	_, _, removed = DiffRoleSnapshots(before, nil)
+	if len(removed) != 2 {
+		t.Errorf("deleted role should remove all permissions, got %v", removed)
+	}
+}
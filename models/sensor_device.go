@@ -0,0 +1,195 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SensorDeviceType identifies the kind of field sensor
+type SensorDeviceType string
+
+const (
+	SensorDeviceRainGauge     SensorDeviceType = "rain_gauge"
+	SensorDeviceWaterLevel    SensorDeviceType = "water_level"
+	SensorDeviceSolarInverter SensorDeviceType = "solar_inverter"
+	SensorDeviceEnergyMeter   SensorDeviceType = "energy_meter"
+	SensorDeviceFlowMeter     SensorDeviceType = "flow_meter"
+	SensorDevicePump          SensorDeviceType = "pump"
+	SensorDevicePyranometer   SensorDeviceType = "pyranometer"
+)
+
+// DefaultSensorHeartbeatInterval is how often a device without its own interval is
+// expected to report
+const DefaultSensorHeartbeatInterval = 15 * time.Minute
+
+// SensorMissedHeartbeats is how many heartbeats a device may miss before it is offline
+const SensorMissedHeartbeats = 3
+
+// SensorDeviceStatus is the approval state of a sensor device. Only approved devices
+// may post readings.
+type SensorDeviceStatus string
+
+const (
+	SensorDevicePending  SensorDeviceStatus = "pending"
+	SensorDeviceApproved SensorDeviceStatus = "approved"
+	SensorDeviceRejected SensorDeviceStatus = "rejected"
+	SensorDeviceDisabled SensorDeviceStatus = "disabled"
+)
+
+// SensorDevice is an HTTP-capable field device (rain gauge, level sensor, solar inverter,
+// pyranometer, energy or flow meter, pump controller) that posts readings and heartbeats with its own
+// bearer token. Devices register themselves and wait in an approval queue until a site
+// engineer approves them. Only a hash of the token is stored.
+type SensorDevice struct {
+	ID                 uuid.UUID          `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	DeviceKey          string             `gorm:"size:100;not null;uniqueIndex" json:"device_key"` // serial number or MAC reported by the device
+	Name               string             `gorm:"size:100" json:"name,omitempty"`
+	DeviceType         SensorDeviceType   `gorm:"size:20;not null" json:"device_type"`
+	BusinessVerticalID uuid.UUID          `gorm:"type:uuid;not null;index:idx_sensor_devices_vertical_status,priority:1" json:"business_vertical_id"`
+	SiteID             *uuid.UUID         `gorm:"type:uuid;index" json:"site_id,omitempty"`
+	Status             SensorDeviceStatus `gorm:"size:20;not null;default:'pending';index:idx_sensor_devices_vertical_status,priority:2" json:"status"`
+	TokenHash          string             `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	TokenPrefix        string             `gorm:"size:12" json:"token_prefix"`            // lets engineers match a device to its token
+	RateLimitPerMinute int                `gorm:"default:0" json:"rate_limit_per_minute"` // 0 uses the server default
+	FirmwareVersion    string             `gorm:"size:50" json:"firmware_version,omitempty"`
+	RegisteredFromIP   string             `gorm:"size:50" json:"registered_from_ip,omitempty"`
+	ReviewedBy         *uuid.UUID         `gorm:"type:uuid" json:"reviewed_by,omitempty"`
+	ReviewedAt         *time.Time         `json:"reviewed_at,omitempty"`
+	RejectionReason    string             `gorm:"type:text" json:"rejection_reason,omitempty"`
+	LastSeenAt         *time.Time         `json:"last_seen_at,omitempty"` // last reading or heartbeat
+
+	// Health: the device is expected to report every HeartbeatIntervalMinutes (0 uses
+	// DefaultSensorHeartbeatInterval); OfflineAlertedAt is set when it was reported
+	// offline and cleared when it is heard from again
+	HeartbeatIntervalMinutes int        `gorm:"default:0" json:"heartbeat_interval_minutes"`
+	LastHeartbeatAt          *time.Time `json:"last_heartbeat_at,omitempty"`
+	SignalStrength           *int       `json:"signal_strength,omitempty"` // dBm, as last reported
+	OfflineAlertedAt         *time.Time `json:"offline_alerted_at,omitempty"`
+	Offline                  bool       `gorm:"-" json:"offline"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Site *Site `gorm:"foreignKey:SiteID" json:"site,omitempty"`
+}
+
+func (SensorDevice) TableName() string {
+	return "sensor_devices"
+}
+
+// HeartbeatInterval returns how often the device is expected to report
+func (d SensorDevice) HeartbeatInterval() time.Duration {
+	if d.HeartbeatIntervalMinutes > 0 {
+		return time.Duration(d.HeartbeatIntervalMinutes) * time.Minute
+	}
+	return DefaultSensorHeartbeatInterval
+}
+
+// IsOffline reports whether an approved device has missed its last few heartbeats at
+// now, counting from its approval when it was last heard from before then or never
+func (d SensorDevice) IsOffline(now time.Time) bool {
+	if d.Status != SensorDeviceApproved {
+		return false
+	}
+	since := d.CreatedAt
+	if d.ReviewedAt != nil {
+		since = *d.ReviewedAt
+	}
+	if d.LastSeenAt != nil && d.LastSeenAt.After(since) {
+		since = *d.LastSeenAt
+	}
+	return now.Sub(since) > SensorMissedHeartbeats*d.HeartbeatInterval()
+}
+
+// SensorReading is one measurement accepted from a sensor device.
+type SensorReading struct {
+	ID                 uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	DeviceID           uuid.UUID  `gorm:"type:uuid;not null;index:idx_sensor_readings_device_time,priority:1" json:"device_id"`
+	BusinessVerticalID uuid.UUID  `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	SiteID             *uuid.UUID `gorm:"type:uuid;index:idx_sensor_readings_site_time,priority:1" json:"site_id,omitempty"`
+	Metric             string     `gorm:"size:30;not null" json:"metric"`
+	Value              float64    `gorm:"not null" json:"value"`
+	Unit               string     `gorm:"size:10;not null" json:"unit"`
+	RecordedAt         time.Time  `gorm:"not null;index:idx_sensor_readings_device_time,priority:2;index:idx_sensor_readings_site_time,priority:2" json:"recorded_at"`
+	ReceivedAt         time.Time  `gorm:"not null" json:"received_at"`
+}
+
+func (SensorReading) TableName() string {
+	return "sensor_readings"
+}
+
+// sensorMetricSpec is the accepted unit and value range of a metric
+type sensorMetricSpec struct {
+	unit     string
+	min, max float64
+}
+
+// sensorMetrics lists the metrics each device type may report. Readings outside these
+// are rejected rather than stored.
+var sensorMetrics = map[SensorDeviceType]map[string]sensorMetricSpec{
+	SensorDeviceRainGauge: {
+		"rainfall":        {unit: "mm", min: 0, max: 500},
+		"battery_voltage": {unit: "V", min: 0, max: 30},
+	},
+	SensorDeviceWaterLevel: {
+		"water_level":     {unit: "m", min: -200, max: 200},
+		"battery_voltage": {unit: "V", min: 0, max: 30},
+	},
+	// Inverters register and are approved like any sensor, but report through the solar
+	// generation API rather than as sensor readings
+	SensorDeviceSolarInverter: {},
+	SensorDeviceEnergyMeter: {
+		"energy": {unit: "kWh", min: 0, max: 1e9}, // cumulative register
+		"power":  {unit: "kW", min: -10000, max: 10000},
+	},
+	SensorDeviceFlowMeter: {
+		"flow_rate":    {unit: "m3/h", min: 0, max: 10000},
+		"total_volume": {unit: "m3", min: 0, max: 1e9},
+	},
+	SensorDevicePump: {
+		"running":            {unit: "state", min: 0, max: 1},
+		"motor_current":      {unit: "A", min: 0, max: 1000},
+		"discharge_pressure": {unit: "bar", min: 0, max: 100},
+	},
+	// Plane-of-array irradiation since the previous reading, for solar performance ratios
+	SensorDevicePyranometer: {
+		"irradiation": {unit: "kWh/m2", min: 0, max: 10},
+		"irradiance":  {unit: "W/m2", min: 0, max: 2000},
+	},
+}
+
+// ValidSensorDeviceType reports whether t is a supported device type
+func ValidSensorDeviceType(t SensorDeviceType) bool {
+	_, ok := sensorMetrics[t]
+	return ok
+}
+
+// ValidateSensorReading checks a reading against the device type's schema. An empty unit
+// is accepted and means the metric's canonical unit.
+func ValidateSensorReading(deviceType SensorDeviceType, metric string, value float64, unit string) (string, error) {
+	spec, ok := sensorMetrics[deviceType][metric]
+	if !ok {
+		return "", fmt.Errorf("metric %q is not accepted from %s devices", metric, deviceType)
+	}
+	if unit != "" && unit != spec.unit {
+		return "", fmt.Errorf("metric %q must be reported in %s", metric, spec.unit)
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) || value < spec.min || value > spec.max {
+		return "", fmt.Errorf("metric %q value %v is outside %v..%v", metric, value, spec.min, spec.max)
+	}
+	return spec.unit, nil
+}
+
+// TelemetryMetricUnit returns the unit a device type's metric is reported in, for sensor
+// readings and the inverter values of solar generation readings alike
+func TelemetryMetricUnit(deviceType SensorDeviceType, metric string) (string, bool) {
+	if deviceType == SensorDeviceSolarInverter {
+		unit, ok := solarTelemetryUnits[metric]
+		return unit, ok
+	}
+	spec, ok := sensorMetrics[deviceType][metric]
+	return spec.unit, ok
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestValidateSensorReading(t *testing.T) {
+	tests := []struct {
+		name       string
+		deviceType SensorDeviceType
+		metric     string
+		value      float64
+		unit       string
+		wantUnit   string
+		wantErr    bool
+	}{
+		{"rainfall defaults unit", SensorDeviceRainGauge, "rainfall", 12.5, "", "mm", false},
+		{"rainfall explicit unit", SensorDeviceRainGauge, "rainfall", 0, "mm", "mm", false},
+		{"level below datum", SensorDeviceWaterLevel, "water_level", -35.2, "m", "m", false},
+		{"wrong unit", SensorDeviceRainGauge, "rainfall", 4, "cm", "", true},
+		{"metric from other type", SensorDeviceRainGauge, "water_level", 4, "m", "", true},
+		{"negative rainfall", SensorDeviceRainGauge, "rainfall", -1, "", "", true},
+		{"out of range", SensorDeviceRainGauge, "rainfall", 900, "", "", true},
+		{"not a number", SensorDeviceWaterLevel, "water_level", math.NaN(), "", "", true},
+		{"flow rate", SensorDeviceFlowMeter, "flow_rate", 42.5, "m3/h", "m3/h", false},
+		{"pump state", SensorDevicePump, "running", 2, "", "", true},
+		{"unknown device type", SensorDeviceType("anemometer"), "rainfall", 1, "", "", true},
+		{"inverter as sensor reading", SensorDeviceSolarInverter, "battery_voltage", 12, "V", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unit, err := ValidateSensorReading(tt.deviceType, tt.metric, tt.value, tt.unit)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if unit != tt.wantUnit {
+				t.Errorf("unit = %q, want %q", unit, tt.wantUnit)
+			}
+		})
+	}
+}
+
+func TestSensorDeviceIsOffline(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time { ts := now.Add(-d); return &ts }
+	tests := []struct {
+		name   string
+		device SensorDevice
+		want   bool
+	}{
+		{"recent heartbeat", SensorDevice{Status: SensorDeviceApproved, ReviewedAt: at(48 * time.Hour), LastSeenAt: at(30 * time.Minute)}, false},
+		{"three heartbeats missed", SensorDevice{Status: SensorDeviceApproved, ReviewedAt: at(48 * time.Hour), LastSeenAt: at(46 * time.Minute)}, true},
+		{"own interval", SensorDevice{Status: SensorDeviceApproved, HeartbeatIntervalMinutes: 60, LastSeenAt: at(2 * time.Hour), CreatedAt: now.Add(-72 * time.Hour)}, false},
+		{"never reported since approval", SensorDevice{Status: SensorDeviceApproved, ReviewedAt: at(time.Hour), CreatedAt: now.Add(-72 * time.Hour)}, true},
+		{"reapproved after going quiet", SensorDevice{Status: SensorDeviceApproved, ReviewedAt: at(10 * time.Minute), LastSeenAt: at(30 * 24 * time.Hour)}, false},
+		{"disabled", SensorDevice{Status: SensorDeviceDisabled, LastSeenAt: at(30 * 24 * time.Hour)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.device.IsOffline(now); got != tt.want {
+				t.Errorf("IsOffline() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
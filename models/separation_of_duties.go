@@ -0,0 +1,91 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SoD override contexts
+const (
+	SoDContextRoleAssignment = "role_assignment"
+	SoDContextApproval       = "approval"
+)
+
+// SoDRule is a separation-of-duties constraint: no user may hold both permissions in the
+// same business vertical. Rules without a BusinessVerticalID apply to every vertical.
+type SoDRule struct {
+	ID                 uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID *uuid.UUID `gorm:"type:uuid;index" json:"business_vertical_id,omitempty"`
+	Name               string     `gorm:"size:100;not null" json:"name"`
+	Description        string     `gorm:"type:text" json:"description,omitempty"`
+	PermissionA        string     `gorm:"size:100;not null" json:"permission_a"`
+	PermissionB        string     `gorm:"size:100;not null" json:"permission_b"`
+	IsActive           bool       `gorm:"default:true;index" json:"is_active"`
+	CreatedBy          *uuid.UUID `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+func (SoDRule) TableName() string {
+	return "sod_rules"
+}
+
+// SoDOverride records a super admin allowing an action that violates a SoD rule.
+type SoDOverride struct {
+	ID                 uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()" json:"id"`
+	RuleID             uuid.UUID  `gorm:"type:uuid;not null;index" json:"rule_id"`
+	BusinessVerticalID *uuid.UUID `gorm:"type:uuid;index:idx_sod_overrides_vertical_time,priority:1" json:"business_vertical_id,omitempty"`
+	UserID             uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"` // user holding the conflicting permissions
+	ActorID            uuid.UUID  `gorm:"type:uuid;not null" json:"actor_id"`      // super admin who overrode the rule
+	Context            string     `gorm:"size:30;not null" json:"context"`
+	Reference          string     `gorm:"size:255" json:"reference,omitempty"` // what was allowed, e.g. business_role:<id> or the approval path
+	Reason             string     `gorm:"type:text;not null" json:"reason"`
+	CreatedAt          time.Time  `gorm:"index:idx_sod_overrides_vertical_time,priority:2" json:"created_at"`
+
+	Rule *SoDRule `gorm:"foreignKey:RuleID" json:"rule,omitempty"`
+}
+
+func (SoDOverride) TableName() string {
+	return "sod_overrides"
+}
+
+// sodIgnoredGrants are universal grants that would match every rule. Their holders are
+// the super admins who decide overrides, so they are not treated as holding both sides.
+var sodIgnoredGrants = map[string]bool{"*:*:*": true, "*": true, "admin_all": true}
+
+func holdsSoDPermission(held []string, permission string) bool {
+	for _, perm := range held {
+		if !sodIgnoredGrants[perm] && matchesPermission(perm, permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindSoDConflicts returns the rules violated by holding all of the held permissions
+func FindSoDConflicts(rules []SoDRule, held []string) []SoDRule {
+	var conflicts []SoDRule
+	for _, rule := range rules {
+		if rule.IsActive && holdsSoDPermission(held, rule.PermissionA) && holdsSoDPermission(held, rule.PermissionB) {
+			conflicts = append(conflicts, rule)
+		}
+	}
+	return conflicts
+}
+
+// FindApprovalSoDConflicts returns the rules that forbid acting with permission while
+// holding the held permissions: permission is one side of the rule and the actor holds the other.
+func FindApprovalSoDConflicts(rules []SoDRule, held []string, permission string) []SoDRule {
+	var conflicts []SoDRule
+	for _, rule := range rules {
+		if !rule.IsActive {
+			continue
+		}
+		if (matchesPermission(rule.PermissionA, permission) && holdsSoDPermission(held, rule.PermissionB)) ||
+			(matchesPermission(rule.PermissionB, permission) && holdsSoDPermission(held, rule.PermissionA)) {
+			conflicts = append(conflicts, rule)
+		}
+	}
+	return conflicts
+}
@@ -0,0 +1,40 @@
+package models
+
+import "testing"
+
+func TestFindSoDConflicts(t *testing.T) {
+	rules := []SoDRule{
+		{Name: "purchase maker-checker", PermissionA: "purchase:create", PermissionB: "purchase:approve", IsActive: true},
+		{Name: "inactive", PermissionA: "bg:create", PermissionB: "bg:approve", IsActive: false},
+	}
+
+	tests := []struct {
+		name string
+		held []string
+		want int
+	}{
+		{"one side only", []string{"purchase:create", "bg:create", "bg:approve"}, 0},
+		{"both sides", []string{"purchase:create", "purchase:approve"}, 1},
+		{"wildcard covers both", []string{"purchase:*"}, 1},
+		{"universal grant ignored", []string{"*:*:*"}, 0},
+	}
+	for _, tt := range tests {
+		if got := FindSoDConflicts(rules, tt.held); len(got) != tt.want {
+			t.Errorf("%s: got %d conflicts, want %d", tt.name, len(got), tt.want)
+		}
+	}
+}
+
+func TestFindApprovalSoDConflicts(t *testing.T) {
+	rules := []SoDRule{{PermissionA: "purchase:create", PermissionB: "purchase:approve", IsActive: true}}
+
+	if got := FindApprovalSoDConflicts(rules, []string{"purchase:create", "purchase:approve"}, "purchase:approve"); len(got) != 1 {
+		t.Errorf("approver holding purchase:create should conflict, got %d", len(got))
+	}
+	if got := FindApprovalSoDConflicts(rules, []string{"purchase:approve"}, "purchase:approve"); len(got) != 0 {
+		t.Errorf("approver without purchase:create should not conflict, got %d", len(got))
+	}
+	if got := FindApprovalSoDConflicts(rules, []string{"purchase:create"}, "bg:approve"); len(got) != 0 {
+		t.Errorf("unrelated permission should not conflict, got %d", len(got))
+	}
+}
@@ -0,0 +1,185 @@
+package models
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// What an SMS was sent for
+const (
+	SMSPurposeOTP              = "otp"
+	SMSPurposeAlarm            = "alarm"
+	SMSPurposeApprovalReminder = "approval_reminder"
+	SMSPurposeReminder         = "reminder"
+)
+
+// SMS delivery statuses, in the order they are reached
+const (
+	SMSQueued    = "queued"
+	SMSSent      = "sent"      // accepted by the provider
+	SMSDelivered = "delivered" // confirmed by the carrier
+	SMSFailed    = "failed"
+)
+
+// SMSMaxAttempts is how many times a text is sent before it is given up
+const SMSMaxAttempts = 3
+
+// SMSMessage logs one text sent through an SMS provider and its delivery status as
+// reported by the provider's callbacks. One-time codes are not kept in Body.
+type SMSMessage struct {
+	ID                uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID            string     `gorm:"size:255;index" json:"user_id,omitempty"`
+	To                string     `gorm:"column:to_number;size:20;not null" json:"to"`
+	Purpose           string     `gorm:"size:30;not null;index:idx_sms_messages_reference,priority:1" json:"purpose"`
+	ReferenceID       *uuid.UUID `gorm:"type:uuid;index:idx_sms_messages_reference,priority:2" json:"reference_id,omitempty"` // alarm or notification the text is about
+	Body              string     `gorm:"type:text" json:"body,omitempty"`
+	Provider          string     `gorm:"size:20" json:"provider,omitempty"`
+	SenderID          string     `gorm:"size:50" json:"sender_id,omitempty"`
+	ProviderMessageID string     `gorm:"size:100;index" json:"provider_message_id,omitempty"`
+	Status            string     `gorm:"size:20;not null;default:'queued';index" json:"status"`
+	Error             string     `gorm:"type:text" json:"error,omitempty"`
+	Attempts          int        `gorm:"default:0" json:"attempts"`
+	NextAttemptAt     *time.Time `gorm:"index" json:"next_attempt_at,omitempty"` // set while a retry is queued
+	SentAt            *time.Time `json:"sent_at,omitempty"`
+	DeliveredAt       *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+func (SMSMessage) TableName() string {
+	return "sms_messages"
+}
+
+// ApplyReport records a delivery status from the provider and reports whether it changed
+// anything. Statuses only move forward, so a late "sent" never overwrites "delivered",
+// and delivered or failed messages stay that way.
+func (m *SMSMessage) ApplyReport(status, reason string, at time.Time) bool {
+	rank := map[string]int{SMSQueued: 0, SMSSent: 1, SMSDelivered: 2, SMSFailed: 2}
+	next, ok := rank[status]
+	if !ok || m.Status == SMSDelivered || m.Status == SMSFailed || next <= rank[m.Status] {
+		return false
+	}
+	m.Status = status
+	switch status {
+	case SMSSent:
+		if m.SentAt == nil {
+			m.SentAt = &at
+		}
+	case SMSDelivered:
+		m.DeliveredAt = &at
+	case SMSFailed:
+		m.Error = reason
+	}
+	return true
+}
+
+// FailAttempt records a failed send. A transient failure is queued for another attempt
+// after the same backoff as pushes, until SMSMaxAttempts; one-time codes are never
+// resent, since their text is not kept. It reports whether the text will be retried.
+func (m *SMSMessage) FailAttempt(reason string, transient bool, now time.Time) bool {
+	m.Error = reason
+	if transient && m.Purpose != SMSPurposeOTP && m.Attempts < SMSMaxAttempts {
+		next := now.Add(PushRetryDelay(m.Attempts))
+		m.Status = SMSQueued
+		m.NextAttemptAt = &next
+		return true
+	}
+	m.Status = SMSFailed
+	m.NextAttemptAt = nil
+	return false
+}
+
+// Requeue puts a failed text back in the queue to be sent again from its first attempt
+func (m *SMSMessage) Requeue(now time.Time) error {
+	if m.Status != SMSFailed {
+		return errors.New("only failed texts can be requeued")
+	}
+	if m.Purpose == SMSPurposeOTP || m.Body == "" {
+		return errors.New("one-time codes are not resent")
+	}
+	m.Status = SMSQueued
+	m.Attempts = 0
+	m.NextAttemptAt = &now
+	m.Error = ""
+	m.SentAt = nil
+	m.DeliveredAt = nil
+	return nil
+}
+
+// One-time login code settings
+const (
+	OTPLength         = 6
+	OTPTTL            = 5 * time.Minute
+	OTPMaxAttempts    = 5
+	OTPResendInterval = time.Minute
+)
+
+// Errors returned when a one-time code is checked
+var (
+	ErrOTPExpired     = errors.New("code has expired")
+	ErrOTPUsed        = errors.New("code has already been used")
+	ErrOTPTooMany     = errors.New("too many wrong attempts, request a new code")
+	ErrOTPWrongCode   = errors.New("wrong code")
+	errOTPUnavailable = errors.New("could not generate a code")
+)
+
+// SMSOTP is a one-time login code texted to a user's phone, for field staff signing in
+// without their password. Only a hash of the code is stored.
+type SMSOTP struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Phone      string     `gorm:"size:20;not null;index" json:"phone"`
+	CodeHash   string     `gorm:"size:100;not null" json:"-"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	Attempts   int        `gorm:"default:0" json:"attempts"`
+	ConsumedAt *time.Time `json:"consumed_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (SMSOTP) TableName() string {
+	return "sms_otps"
+}
+
+// NewSMSOTP generates a random code for the user and returns it with the record that
+// holds its hash
+func NewSMSOTP(userID uuid.UUID, phone string, now time.Time) (*SMSOTP, string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < OTPLength; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return nil, "", errOTPUnavailable
+	}
+	code := fmt.Sprintf("%0*d", OTPLength, n)
+	hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, "", errOTPUnavailable
+	}
+	return &SMSOTP{UserID: userID, Phone: phone, CodeHash: string(hash), ExpiresAt: now.Add(OTPTTL)}, code, nil
+}
+
+// Verify checks a code entered by the user, counting the attempt. A matching code is
+// consumed and cannot be used again.
+func (o *SMSOTP) Verify(code string, now time.Time) error {
+	switch {
+	case o.ConsumedAt != nil:
+		return ErrOTPUsed
+	case !now.Before(o.ExpiresAt):
+		return ErrOTPExpired
+	case o.Attempts >= OTPMaxAttempts:
+		return ErrOTPTooMany
+	}
+	o.Attempts++
+	if bcrypt.CompareHashAndPassword([]byte(o.CodeHash), []byte(code)) != nil {
+		return ErrOTPWrongCode
+	}
+	o.ConsumedAt = &now
+	return nil
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSMSMessageApplyReport(t *testing.T) {
+	now := time.Now()
+	msg := SMSMessage{Status: SMSQueued}
+	if !msg.ApplyReport(SMSSent, "", now) || msg.SentAt == nil {
+		t.Fatalf("sent not applied: %+v", msg)
+	}
+	if !msg.ApplyReport(SMSDelivered, "", now) || msg.DeliveredAt == nil {
+		t.Fatalf("delivered not applied: %+v", msg)
+	}
+	if msg.ApplyReport(SMSSent, "", now) || msg.Status != SMSDelivered {
+		t.Error("late sent report overwrote delivered")
+	}
+	if msg.ApplyReport(SMSFailed, "expired", now) {
+		t.Error("failure applied to a delivered message")
+	}
+
+	failed := SMSMessage{Status: SMSSent}
+	if !failed.ApplyReport(SMSFailed, "undelivered", now) || failed.Error != "undelivered" {
+		t.Errorf("failure not applied: %+v", failed)
+	}
+	if failed.ApplyReport("read", "", now) {
+		t.Error("unknown status applied")
+	}
+}
+
+func TestSMSMessageRetry(t *testing.T) {
+	now := time.Now()
+	msg := SMSMessage{Purpose: SMSPurposeAlarm, Body: "Pump 3 tripped", Attempts: 1}
+	if !msg.FailAttempt("twilio returned 503", true, now) || msg.Status != SMSQueued || !msg.NextAttemptAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("transient failure not retried: %+v", msg)
+	}
+	msg.Attempts = SMSMaxAttempts
+	if msg.FailAttempt("twilio returned 503", true, now) || msg.Status != SMSFailed || msg.NextAttemptAt != nil {
+		t.Errorf("retried past the last attempt: %+v", msg)
+	}
+	rejected := SMSMessage{Purpose: SMSPurposeAlarm, Body: "x", Attempts: 1}
+	if rejected.FailAttempt("invalid number", false, now) || rejected.Status != SMSFailed {
+		t.Error("permanent failure retried")
+	}
+	otp := SMSMessage{Purpose: SMSPurposeOTP, Attempts: 1}
+	if otp.FailAttempt("timeout", true, now) {
+		t.Error("one-time code retried")
+	}
+
+	if err := msg.Requeue(now); err != nil || msg.Status != SMSQueued || msg.Attempts != 0 || msg.Error != "" {
+		t.Errorf("requeue: %v, %+v", err, msg)
+	}
+	if err := msg.Requeue(now); err == nil {
+		t.Error("queued text requeued")
+	}
+	otp.Status = SMSFailed
+	if err := otp.Requeue(now); err == nil {
+		t.Error("one-time code requeued")
+	}
+}
+
+func TestSMSOTPVerify(t *testing.T) {
+	now := time.Now()
+	otp, code, err := NewSMSOTP(uuid.New(), "+919876543210", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(code) != OTPLength {
+		t.Fatalf("code %q is not %d digits", code, OTPLength)
+	}
+	if otp.CodeHash == code {
+		t.Fatal("code stored in clear")
+	}
+
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	if err := otp.Verify(wrong, now); err != ErrOTPWrongCode {
+		t.Errorf("wrong code: %v", err)
+	}
+	if err := otp.Verify(code, now.Add(time.Minute)); err != nil {
+		t.Fatalf("right code rejected: %v", err)
+	}
+	if err := otp.Verify(code, now.Add(time.Minute)); err != ErrOTPUsed {
+		t.Errorf("code used twice: %v", err)
+	}
+
+	expired, code, _ := NewSMSOTP(uuid.New(), "+919876543210", now)
+	if err := expired.Verify(code, now.Add(OTPTTL)); err != ErrOTPExpired {
+		t.Errorf("expired code: %v", err)
+	}
+
+	guessed, code, _ := NewSMSOTP(uuid.New(), "+919876543210", now)
+	guessed.Attempts = OTPMaxAttempts
+	if err := guessed.Verify(code, now); err != ErrOTPTooMany {
+		t.Errorf("code after too many attempts: %v", err)
+	}
+}
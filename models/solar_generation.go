@@ -0,0 +1,96 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SolarGenerationReading is one timestamped reading from a solar inverter: the energy
+// generated since its previous reading and the inverter's state at the time. A device
+// reports at most one reading per timestamp.
+//
+// The table is range-partitioned by month on RecordedAt, so it is created by migration
+// rather than AutoMigrate and each month's partition by EnsureSolarGenerationPartition.
+type SolarGenerationReading struct {
+	DeviceID           uuid.UUID `gorm:"type:uuid;primaryKey" json:"device_id"`
+	RecordedAt         time.Time `gorm:"primaryKey" json:"recorded_at"`
+	BusinessVerticalID uuid.UUID `gorm:"type:uuid;not null" json:"business_vertical_id"`
+	SiteID             uuid.UUID `gorm:"type:uuid;not null" json:"site_id"`
+	EnergyKWh          float64   `gorm:"column:energy_kwh;type:decimal(14,4);not null" json:"energy_kwh"`
+	PowerKW            *float64  `gorm:"type:decimal(12,3)" json:"power_kw,omitempty"` // AC output
+	DCVoltage          *float64  `gorm:"type:decimal(8,2)" json:"dc_voltage,omitempty"`
+	InverterTempC      *float64  `gorm:"type:decimal(6,2)" json:"inverter_temp_c,omitempty"`
+	InverterStatus     string    `gorm:"size:30" json:"inverter_status,omitempty"`
+	ReceivedAt         time.Time `gorm:"not null" json:"received_at"`
+	ReceivedBy         string    `gorm:"size:255" json:"received_by,omitempty"`
+}
+
+func (SolarGenerationReading) TableName() string {
+	return "solar_generation_readings"
+}
+
+// Validate checks a reading's values are physically plausible for a single inverter
+func (g SolarGenerationReading) Validate() error {
+	inRange := func(v, min, max float64) bool {
+		return !math.IsNaN(v) && !math.IsInf(v, 0) && v >= min && v <= max
+	}
+	switch {
+	case g.RecordedAt.IsZero():
+		return fmt.Errorf("recorded_at is required")
+	case !inRange(g.EnergyKWh, 0, 100000):
+		return fmt.Errorf("energy_kwh %v is outside 0..100000", g.EnergyKWh)
+	case g.PowerKW != nil && !inRange(*g.PowerKW, 0, 10000):
+		return fmt.Errorf("power_kw %v is outside 0..10000", *g.PowerKW)
+	case g.DCVoltage != nil && !inRange(*g.DCVoltage, 0, 2000):
+		return fmt.Errorf("dc_voltage %v is outside 0..2000", *g.DCVoltage)
+	case g.InverterTempC != nil && !inRange(*g.InverterTempC, -40, 150):
+		return fmt.Errorf("inverter_temp_c %v is outside -40..150", *g.InverterTempC)
+	case len(g.InverterStatus) > 30:
+		return fmt.Errorf("inverter_status is longer than 30 characters")
+	}
+	return nil
+}
+
+// solarTelemetryUnits are the units of the inverter values fired as telemetry, by metric
+var solarTelemetryUnits = map[string]string{
+	"power_kw":        "kW",
+	"dc_voltage":      "V",
+	"inverter_temp_c": "C",
+}
+
+// Telemetry returns the inverter's state values the reading reports, by the metric names
+// they are fired as telemetry under
+func (g SolarGenerationReading) Telemetry() map[string]float64 {
+	values := map[string]float64{}
+	if g.PowerKW != nil {
+		values["power_kw"] = *g.PowerKW
+	}
+	if g.DCVoltage != nil {
+		values["dc_voltage"] = *g.DCVoltage
+	}
+	if g.InverterTempC != nil {
+		values["inverter_temp_c"] = *g.InverterTempC
+	}
+	return values
+}
+
+// SolarGenerationPartition returns the name and [from, to) bounds of the monthly
+// partition holding readings recorded at t
+func SolarGenerationPartition(t time.Time) (name string, from, to time.Time) {
+	t = t.UTC()
+	from = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return fmt.Sprintf("solar_generation_readings_%s", from.Format("200601")), from, from.AddDate(0, 1, 0)
+}
+
+// EnsureSolarGenerationPartition creates the monthly partition holding readings recorded
+// at t unless it exists. Callers creating partitions concurrently should hold a lock.
+func EnsureSolarGenerationPartition(db *gorm.DB, t time.Time) error {
+	name, from, to := SolarGenerationPartition(t)
+	return db.Exec(fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s PARTITION OF solar_generation_readings FOR VALUES FROM ('%s') TO ('%s')",
+		name, from.Format(time.RFC3339), to.Format(time.RFC3339))).Error
+}
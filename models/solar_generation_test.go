@@ -0,0 +1,41 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSolarGenerationReadingValidate(t *testing.T) {
+	at := time.Date(2026, 10, 16, 6, 30, 0, 0, time.UTC)
+	power, hot := 48.5, 180.0
+	tests := []struct {
+		name    string
+		reading SolarGenerationReading
+		wantErr bool
+	}{
+		{"energy only", SolarGenerationReading{RecordedAt: at, EnergyKWh: 12.4}, false},
+		{"with power", SolarGenerationReading{RecordedAt: at, EnergyKWh: 0, PowerKW: &power}, false},
+		{"no timestamp", SolarGenerationReading{EnergyKWh: 12.4}, true},
+		{"negative energy", SolarGenerationReading{RecordedAt: at, EnergyKWh: -1}, true},
+		{"implausible temperature", SolarGenerationReading{RecordedAt: at, EnergyKWh: 1, InverterTempC: &hot}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.reading.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSolarGenerationPartition(t *testing.T) {
+	// 02:00 on 1 November in India is still October in UTC
+	ist := time.FixedZone("IST", 5*3600+1800)
+	name, from, to := SolarGenerationPartition(time.Date(2026, 11, 1, 2, 0, 0, 0, ist))
+	if name != "solar_generation_readings_202610" {
+		t.Errorf("name = %q, want solar_generation_readings_202610", name)
+	}
+	if !from.Equal(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)) || !to.Equal(time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("bounds = %v..%v, want October 2026", from, to)
+	}
+}
@@ -0,0 +1,219 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Solar string fault types found in field inspections
+const (
+	SolarFaultHotspot         = "hotspot"
+	SolarFaultCrackedCell     = "cracked_cell"
+	SolarFaultDiodeFailure    = "diode_failure"
+	SolarFaultOpenCircuit     = "open_circuit"
+	SolarFaultSoiling         = "soiling"
+	SolarFaultShading         = "shading"
+	SolarFaultDelamination    = "delamination"
+	SolarFaultUnderperforming = "underperforming"
+	SolarFaultOther           = "other"
+)
+
+// Solar fault severities, from least to most severe, and statuses
+const (
+	SolarFaultLow      = "low"
+	SolarFaultMedium   = "medium"
+	SolarFaultHigh     = "high"
+	SolarFaultCritical = "critical"
+
+	SolarFaultOpen     = "open"
+	SolarFaultResolved = "resolved"
+)
+
+// Solar string and panel statuses
+const (
+	SolarEquipmentActive         = "active"
+	SolarEquipmentDecommissioned = "decommissioned"
+)
+
+// DefaultSolarUnderperformanceThreshold is the measured share of expected string current,
+// in percent, below which a string counts as underperforming
+const DefaultSolarUnderperformanceThreshold = 90.0
+
+var solarFaultTypes = map[string]bool{
+	SolarFaultHotspot: true, SolarFaultCrackedCell: true, SolarFaultDiodeFailure: true,
+	SolarFaultOpenCircuit: true, SolarFaultSoiling: true, SolarFaultShading: true,
+	SolarFaultDelamination: true, SolarFaultUnderperforming: true, SolarFaultOther: true,
+}
+
+var solarFaultSeverityRank = map[string]int{
+	SolarFaultLow: 1, SolarFaultMedium: 2, SolarFaultHigh: 3, SolarFaultCritical: 4,
+}
+
+// ValidSolarFaultType reports whether t is a known string fault type
+func ValidSolarFaultType(t string) bool {
+	return solarFaultTypes[t]
+}
+
+// ValidSolarFaultSeverity reports whether s is a known fault severity
+func ValidSolarFaultSeverity(s string) bool {
+	return solarFaultSeverityRank[s] > 0
+}
+
+// SolarBlock is a block of a solar plant: a group of strings wired to one inverter,
+// placed on the site layout
+type SolarBlock struct {
+	ID                 uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID  `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	SiteID             uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_solar_blocks_site_code,priority:1" json:"site_id"`
+	Code               string     `gorm:"size:50;not null;uniqueIndex:idx_solar_blocks_site_code,priority:2" json:"code"`
+	Name               string     `gorm:"size:255" json:"name,omitempty"`
+	InverterDeviceID   *uuid.UUID `gorm:"type:uuid;index" json:"inverter_device_id,omitempty"`
+	LayoutX            float64    `gorm:"not null;default:0" json:"layout_x"` // position on the site layout, in metres
+	LayoutY            float64    `gorm:"not null;default:0" json:"layout_y"`
+	CreatedBy          string     `gorm:"size:255" json:"created_by,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+
+	Strings []SolarString `gorm:"foreignKey:BlockID" json:"strings,omitempty"`
+}
+
+func (SolarBlock) TableName() string {
+	return "solar_blocks"
+}
+
+// SolarString is a series-connected string of panels in a block
+type SolarString struct {
+	ID                 uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	SiteID             uuid.UUID `gorm:"type:uuid;not null;index" json:"site_id"`
+	BlockID            uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_solar_strings_block_code,priority:1" json:"block_id"`
+	Code               string    `gorm:"size:50;not null;uniqueIndex:idx_solar_strings_block_code,priority:2" json:"code"`
+	PanelCount         int       `gorm:"not null;default:0" json:"panel_count"`
+	RatedCurrentA      float64   `gorm:"type:decimal(8,3);not null;default:0" json:"rated_current_a"` // maximum power current at 1000 W/m2
+	LayoutX            float64   `gorm:"not null;default:0" json:"layout_x"`
+	LayoutY            float64   `gorm:"not null;default:0" json:"layout_y"`
+	Status             string    `gorm:"size:20;not null;default:'active'" json:"status"`
+	CreatedBy          string    `gorm:"size:255" json:"created_by,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+
+	Block *SolarBlock `gorm:"foreignKey:BlockID" json:"block,omitempty"`
+}
+
+func (SolarString) TableName() string {
+	return "solar_strings"
+}
+
+// ExpectedCurrent returns the current the string should carry at the irradiance, from its
+// rated current, or nil when either is unknown
+func (s SolarString) ExpectedCurrent(irradianceWM2 *float64) *float64 {
+	if s.RatedCurrentA <= 0 || irradianceWM2 == nil || *irradianceWM2 <= 0 {
+		return nil
+	}
+	v := math.Round(s.RatedCurrentA**irradianceWM2/1000*1000) / 1000
+	return &v
+}
+
+// SolarPanel is one module of a string, identified by its manufacturer serial
+type SolarPanel struct {
+	ID                 uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_solar_panels_serial,priority:1" json:"business_vertical_id"`
+	SiteID             uuid.UUID  `gorm:"type:uuid;not null;index" json:"site_id"`
+	StringID           uuid.UUID  `gorm:"type:uuid;not null;index" json:"string_id"`
+	Serial             string     `gorm:"size:100;not null;uniqueIndex:idx_solar_panels_serial,priority:2" json:"serial"`
+	Position           int        `gorm:"not null" json:"position"` // order in the string, from 1
+	Manufacturer       string     `gorm:"size:255" json:"manufacturer,omitempty"`
+	ModelNumber        string     `gorm:"size:255" json:"model_number,omitempty"`
+	RatedPowerW        float64    `gorm:"type:decimal(8,2);not null;default:0" json:"rated_power_w"`
+	LayoutX            float64    `gorm:"not null;default:0" json:"layout_x"`
+	LayoutY            float64    `gorm:"not null;default:0" json:"layout_y"`
+	InstalledOn        *time.Time `gorm:"type:date" json:"installed_on,omitempty"`
+	Status             string     `gorm:"size:20;not null;default:'active'" json:"status"`
+	CreatedBy          string     `gorm:"size:255" json:"created_by,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+func (SolarPanel) TableName() string {
+	return "solar_panels"
+}
+
+// SolarStringFault is a fault found on a string, or one of its panels, in an inspection,
+// with the string current measured at the time when the inspector took it
+type SolarStringFault struct {
+	ID                 uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID  `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	SiteID             uuid.UUID  `gorm:"type:uuid;not null;index" json:"site_id"`
+	StringID           uuid.UUID  `gorm:"type:uuid;not null;index" json:"string_id"`
+	PanelID            *uuid.UUID `gorm:"type:uuid;index" json:"panel_id,omitempty"`
+	FaultType          string     `gorm:"size:30;not null" json:"fault_type"`
+	Severity           string     `gorm:"size:20;not null" json:"severity"`
+	MeasuredCurrentA   *float64   `gorm:"type:decimal(8,3)" json:"measured_current_a,omitempty"`
+	ExpectedCurrentA   *float64   `gorm:"type:decimal(8,3)" json:"expected_current_a,omitempty"`
+	IrradianceWM2      *float64   `gorm:"column:irradiance_wm2;type:decimal(8,2)" json:"irradiance_wm2,omitempty"`
+	Notes              string     `gorm:"type:text" json:"notes,omitempty"`
+	InspectedAt        time.Time  `gorm:"not null;index" json:"inspected_at"`
+	ReportedBy         string     `gorm:"size:255;not null" json:"reported_by"`
+	Status             string     `gorm:"size:20;not null;default:'open';index" json:"status"`
+	ResolvedAt         *time.Time `json:"resolved_at,omitempty"`
+	ResolvedBy         string     `gorm:"size:255" json:"resolved_by,omitempty"`
+	ResolutionNotes    string     `gorm:"type:text" json:"resolution_notes,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+
+	String *SolarString `gorm:"foreignKey:StringID" json:"string,omitempty"`
+	Panel  *SolarPanel  `gorm:"foreignKey:PanelID" json:"panel,omitempty"`
+}
+
+func (SolarStringFault) TableName() string {
+	return "solar_string_faults"
+}
+
+// PerformancePercent returns the measured current as a percentage of the expected, or nil
+// when either was not taken
+func (f SolarStringFault) PerformancePercent() *float64 {
+	if f.MeasuredCurrentA == nil || f.ExpectedCurrentA == nil || *f.ExpectedCurrentA <= 0 {
+		return nil
+	}
+	v := math.Round(*f.MeasuredCurrentA / *f.ExpectedCurrentA * 10000) / 100
+	return &v
+}
+
+// SolarStringHealth is how a string fared in its inspections
+type SolarStringHealth struct {
+	OpenFaults         int        `json:"open_faults"`
+	WorstSeverity      string     `json:"worst_severity,omitempty"` // of the open faults
+	PerformancePercent *float64   `json:"performance_percent,omitempty"`
+	LastInspectedAt    *time.Time `json:"last_inspected_at,omitempty"`
+	Underperforming    bool       `json:"underperforming"`
+}
+
+// SolarStringHealthOf summarises a string's faults: when it was last inspected, its open
+// faults and the performance measured with the latest of them; a resolved fault's
+// measurement predates the repair. The string is underperforming when that performance
+// is below thresholdPercent or it has an open high or critical fault.
+func SolarStringHealthOf(faults []SolarStringFault, thresholdPercent float64) SolarStringHealth {
+	var health SolarStringHealth
+	var measuredAt time.Time
+	for _, f := range faults {
+		if health.LastInspectedAt == nil || f.InspectedAt.After(*health.LastInspectedAt) {
+			at := f.InspectedAt
+			health.LastInspectedAt = &at
+		}
+		if f.Status != SolarFaultOpen {
+			continue
+		}
+		health.OpenFaults++
+		if solarFaultSeverityRank[f.Severity] > solarFaultSeverityRank[health.WorstSeverity] {
+			health.WorstSeverity = f.Severity
+		}
+		if p := f.PerformancePercent(); p != nil && f.InspectedAt.After(measuredAt) {
+			health.PerformancePercent, measuredAt = p, f.InspectedAt
+		}
+	}
+	health.Underperforming = (health.PerformancePercent != nil && *health.PerformancePercent < thresholdPercent) ||
+		solarFaultSeverityRank[health.WorstSeverity] >= solarFaultSeverityRank[SolarFaultHigh]
+	return health
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSolarStringExpectedCurrent(t *testing.T) {
+	str := SolarString{RatedCurrentA: 10.5}
+	irradiance := 800.0
+	if got := str.ExpectedCurrent(&irradiance); got == nil || *got != 8.4 {
+		t.Errorf("expected current = %v, want 8.4", got)
+	}
+	if got := str.ExpectedCurrent(nil); got != nil {
+		t.Errorf("expected current without irradiance = %v, want none", *got)
+	}
+}
+
+func TestSolarStringHealthOf(t *testing.T) {
+	day := time.Date(2026, 10, 1, 11, 0, 0, 0, time.UTC)
+	current := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name            string
+		faults          []SolarStringFault
+		wantOpen        int
+		wantWorst       string
+		wantPerformance *float64
+		wantUnder       bool
+	}{
+		{"never inspected", nil, 0, "", nil, false},
+		{
+			"latest measurement counts",
+			[]SolarStringFault{
+				{Status: SolarFaultOpen, Severity: SolarFaultLow, InspectedAt: day, MeasuredCurrentA: current(6), ExpectedCurrentA: current(8)},
+				{Status: SolarFaultOpen, Severity: SolarFaultMedium, InspectedAt: day.AddDate(0, 0, 7), MeasuredCurrentA: current(7.6), ExpectedCurrentA: current(8)},
+			},
+			2, SolarFaultMedium, current(95), false,
+		},
+		{
+			"low measurement",
+			[]SolarStringFault{{Status: SolarFaultOpen, Severity: SolarFaultLow, InspectedAt: day, MeasuredCurrentA: current(6), ExpectedCurrentA: current(8)}},
+			1, SolarFaultLow, current(75), true,
+		},
+		{
+			"severe fault",
+			[]SolarStringFault{{Status: SolarFaultOpen, Severity: SolarFaultCritical, InspectedAt: day}},
+			1, SolarFaultCritical, nil, true,
+		},
+		{
+			"resolved faults are repaired",
+			[]SolarStringFault{{Status: SolarFaultResolved, Severity: SolarFaultCritical, InspectedAt: day, MeasuredCurrentA: current(1), ExpectedCurrentA: current(8)}},
+			0, "", nil, false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := SolarStringHealthOf(tt.faults, DefaultSolarUnderperformanceThreshold)
+			if h.OpenFaults != tt.wantOpen || h.WorstSeverity != tt.wantWorst || h.Underperforming != tt.wantUnder {
+				t.Errorf("health = %+v, want %d open, worst %q, underperforming %v", h, tt.wantOpen, tt.wantWorst, tt.wantUnder)
+			}
+			if (h.PerformancePercent == nil) != (tt.wantPerformance == nil) ||
+				(h.PerformancePercent != nil && *h.PerformancePercent != *tt.wantPerformance) {
+				t.Errorf("performance = %v, want %v", h.PerformancePercent, tt.wantPerformance)
+			}
+			if len(tt.faults) > 0 && h.LastInspectedAt == nil {
+				t.Errorf("last inspection not set")
+			}
+		})
+	}
+}
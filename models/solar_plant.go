@@ -0,0 +1,178 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultSolarTimezone places a plant's days and daylight hours unless it has its own
+const DefaultSolarTimezone = "Asia/Kolkata"
+
+// SolarInverterCapacities maps inverter device IDs to the DC capacity (kWp) of the array
+// each one is connected to
+type SolarInverterCapacities map[string]float64
+
+// Scan implements the sql.Scanner interface for SolarInverterCapacities
+func (c *SolarInverterCapacities) Scan(value interface{}) error {
+	if value == nil {
+		*c = SolarInverterCapacities{}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		*c = SolarInverterCapacities{}
+		return nil
+	}
+	return json.Unmarshal(bytes, c)
+}
+
+// Value implements the driver.Valuer interface for SolarInverterCapacities
+func (c SolarInverterCapacities) Value() (driver.Value, error) {
+	if c == nil {
+		return json.Marshal(map[string]float64{})
+	}
+	return json.Marshal(c)
+}
+
+// GormDataType defines the data type for GORM
+func (SolarInverterCapacities) GormDataType() string {
+	return "jsonb"
+}
+
+// SolarPlant is the capacity configuration of the solar plant at a site, the basis of its
+// performance figures. Inverters without a capacity of their own share what is left of
+// the plant's DC capacity equally.
+type SolarPlant struct {
+	ID                 uuid.UUID               `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID               `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	SiteID             uuid.UUID               `gorm:"type:uuid;not null;uniqueIndex" json:"site_id"`
+	DCCapacityKWp      float64                 `gorm:"column:dc_capacity_kwp;type:decimal(12,3);not null" json:"dc_capacity_kwp"`
+	ACCapacityKW       float64                 `gorm:"column:ac_capacity_kw;type:decimal(12,3);not null" json:"ac_capacity_kw"`
+	InverterCapacities SolarInverterCapacities `gorm:"type:jsonb;default:'{}'" json:"inverter_capacities"`
+	Timezone           string                  `gorm:"size:50;not null;default:'Asia/Kolkata'" json:"timezone"`
+	DaylightStartHour  int                     `gorm:"not null;default:6" json:"daylight_start_hour"` // local hours the plant is expected to generate
+	DaylightEndHour    int                     `gorm:"not null;default:18" json:"daylight_end_hour"`
+	CommissionedOn     *time.Time              `gorm:"type:date" json:"commissioned_on,omitempty"`
+	UpdatedBy          string                  `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt          time.Time               `json:"created_at"`
+	UpdatedAt          time.Time               `json:"updated_at"`
+
+	Site *Site `gorm:"foreignKey:SiteID" json:"site,omitempty"`
+}
+
+func (SolarPlant) TableName() string {
+	return "solar_plants"
+}
+
+// Validate checks the plant's capacities, timezone and daylight window
+func (p SolarPlant) Validate() error {
+	if p.DCCapacityKWp <= 0 {
+		return fmt.Errorf("dc_capacity_kwp must be positive")
+	}
+	if p.ACCapacityKW <= 0 {
+		return fmt.Errorf("ac_capacity_kw must be positive")
+	}
+	if _, err := time.LoadLocation(p.Timezone); err != nil || p.Timezone == "" {
+		return fmt.Errorf("timezone %q is not a known time zone", p.Timezone)
+	}
+	if p.DaylightStartHour < 0 || p.DaylightEndHour > 24 || p.DaylightStartHour >= p.DaylightEndHour {
+		return fmt.Errorf("daylight hours must satisfy 0 <= start < end <= 24")
+	}
+	var assigned float64
+	for id, kwp := range p.InverterCapacities {
+		if _, err := uuid.Parse(id); err != nil {
+			return fmt.Errorf("inverter_capacities: %q is not a device ID", id)
+		}
+		if kwp <= 0 {
+			return fmt.Errorf("inverter_capacities: capacity of %s must be positive", id)
+		}
+		assigned += kwp
+	}
+	if assigned > p.DCCapacityKWp+0.001 {
+		return fmt.Errorf("inverter_capacities add up to more than dc_capacity_kwp")
+	}
+	return nil
+}
+
+// Location returns the plant's time zone
+func (p SolarPlant) Location() *time.Location {
+	if loc, err := time.LoadLocation(p.Timezone); err == nil && p.Timezone != "" {
+		return loc
+	}
+	if loc, err := time.LoadLocation(DefaultSolarTimezone); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// InverterCapacity returns the DC capacity (kWp) behind an inverter: its configured
+// capacity, or an equal share of the capacity not configured for any of the plant's
+// inverters
+func (p SolarPlant) InverterCapacity(deviceID uuid.UUID, inverters []uuid.UUID) float64 {
+	if kwp, ok := p.InverterCapacities[deviceID.String()]; ok {
+		return kwp
+	}
+	remaining, unassigned := p.DCCapacityKWp, 0
+	for _, id := range inverters {
+		if kwp, ok := p.InverterCapacities[id.String()]; ok {
+			remaining -= kwp
+		} else {
+			unassigned++
+		}
+	}
+	if unassigned == 0 || remaining <= 0 {
+		return 0
+	}
+	return remaining / float64(unassigned)
+}
+
+// SolarFigures are the standard performance figures of a plant or inverter over a period
+type SolarFigures struct {
+	SpecificYield       *float64 `json:"specific_yield,omitempty"`       // kWh per kWp
+	CUFPercent          *float64 `json:"cuf_percent,omitempty"`          // capacity utilisation factor, on AC capacity
+	PRPercent           *float64 `json:"pr_percent,omitempty"`           // performance ratio, where irradiation was measured
+	DowntimeHours       float64  `json:"downtime_hours"`                 // daylight inverter-hours without generation
+	AvailabilityPercent *float64 `json:"availability_percent,omitempty"` // daylight inverter-hours with generation
+}
+
+// SolarInputs are the measured energy and conditions a period's figures derive from
+type SolarInputs struct {
+	EnergyKWh        float64 // generated in the period
+	IrradiatedEnergy float64 // generated on the days irradiation was measured
+	IrradiationKWhM2 float64 // plane-of-array irradiation over those days
+	DCCapacityKWp    float64
+	ACCapacityKW     float64
+	Hours            float64 // length of the period
+	DaylightHours    float64 // inverter-hours the plant was expected to generate
+	ProductiveHours  float64 // of those, inverter-hours with generation
+}
+
+// Figures derives the period's performance figures, rounded to two places. A figure is
+// left out when its basis is missing.
+func (in SolarInputs) Figures() SolarFigures {
+	percent := func(num, den float64) *float64 {
+		if den <= 0 {
+			return nil
+		}
+		v := math.Round(num/den*10000) / 100
+		return &v
+	}
+	var figures SolarFigures
+	if in.DCCapacityKWp > 0 {
+		v := math.Round(in.EnergyKWh/in.DCCapacityKWp*100) / 100
+		figures.SpecificYield = &v
+	}
+	figures.CUFPercent = percent(in.EnergyKWh, in.ACCapacityKW*in.Hours)
+	if in.IrradiationKWhM2 > 0 {
+		// Reference yield is the irradiation over the 1 kW/m2 standard test condition
+		figures.PRPercent = percent(in.IrradiatedEnergy, in.IrradiationKWhM2*in.DCCapacityKWp)
+	}
+	figures.DowntimeHours = math.Round(math.Max(in.DaylightHours-in.ProductiveHours, 0)*100) / 100
+	figures.AvailabilityPercent = percent(math.Min(in.ProductiveHours, in.DaylightHours), in.DaylightHours)
+	return figures
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSolarPlantValidate(t *testing.T) {
+	inverter := uuid.New().String()
+	base := SolarPlant{DCCapacityKWp: 1200, ACCapacityKW: 1000, Timezone: "Asia/Kolkata", DaylightStartHour: 6, DaylightEndHour: 18}
+	tests := []struct {
+		name    string
+		modify  func(p *SolarPlant)
+		wantErr bool
+	}{
+		{"valid", func(p *SolarPlant) {}, false},
+		{"inverter capacity", func(p *SolarPlant) { p.InverterCapacities = SolarInverterCapacities{inverter: 600} }, false},
+		{"no dc capacity", func(p *SolarPlant) { p.DCCapacityKWp = 0 }, true},
+		{"unknown timezone", func(p *SolarPlant) { p.Timezone = "Mars/Olympus" }, true},
+		{"empty daylight", func(p *SolarPlant) { p.DaylightEndHour = 6 }, true},
+		{"inverters exceed plant", func(p *SolarPlant) { p.InverterCapacities = SolarInverterCapacities{inverter: 1500} }, true},
+		{"bad inverter ID", func(p *SolarPlant) { p.InverterCapacities = SolarInverterCapacities{"INV-1": 100} }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plant := base
+			tt.modify(&plant)
+			if err := plant.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSolarPlantInverterCapacity(t *testing.T) {
+	a, b, c := uuid.New(), uuid.New(), uuid.New()
+	plant := SolarPlant{DCCapacityKWp: 1000, InverterCapacities: SolarInverterCapacities{a.String(): 400}}
+	inverters := []uuid.UUID{a, b, c}
+	if got := plant.InverterCapacity(a, inverters); got != 400 {
+		t.Errorf("configured capacity = %v, want 400", got)
+	}
+	if got := plant.InverterCapacity(b, inverters); got != 300 {
+		t.Errorf("shared capacity = %v, want 300", got)
+	}
+}
+
+func TestSolarInputsFigures(t *testing.T) {
+	// A 1 MWp / 800 kW plant over one day: 4.8 kWh/m2 irradiation, 4000 kWh generated,
+	// two inverters of which one was down for 3 of its 12 daylight hours
+	figures := SolarInputs{
+		EnergyKWh: 4000, IrradiatedEnergy: 4000, IrradiationKWhM2: 4.8,
+		DCCapacityKWp: 1000, ACCapacityKW: 800, Hours: 24,
+		DaylightHours: 24, ProductiveHours: 21,
+	}.Figures()
+	check := func(name string, got *float64, want float64) {
+		t.Helper()
+		if got == nil || *got != want {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+	check("specific yield", figures.SpecificYield, 4)
+	check("CUF", figures.CUFPercent, 20.83)
+	check("PR", figures.PRPercent, 83.33)
+	check("availability", figures.AvailabilityPercent, 87.5)
+	if figures.DowntimeHours != 3 {
+		t.Errorf("downtime = %v, want 3", figures.DowntimeHours)
+	}
+
+	// Without irradiation there is no performance ratio
+	if f := (SolarInputs{EnergyKWh: 10, DCCapacityKWp: 5, ACCapacityKW: 5, Hours: 24}).Figures(); f.PRPercent != nil {
+		t.Errorf("PR without irradiation = %v, want none", *f.PRPercent)
+	}
+}
@@ -0,0 +1,275 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Work order statuses. A work order is drafted, issued to its subcontractor and closed
+// once its work is billed; only issued orders take progress certificates.
+const (
+	WorkOrderDraft     = "draft"
+	WorkOrderIssued    = "issued"
+	WorkOrderClosed    = "closed"
+	WorkOrderCancelled = "cancelled"
+)
+
+// Progress certificate statuses
+const (
+	CertificatePending   = "pending"
+	CertificateCertified = "certified"
+	CertificateRejected  = "rejected"
+)
+
+// Subcontractor is a firm work orders are issued to. PortalUserIDs are the users, usually
+// holding the Sub_Contractor role, who may view its work orders, certificates and bills.
+type Subcontractor struct {
+	ID                 uuid.UUID   `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID   `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	Code               string      `gorm:"size:64;not null" json:"code"`
+	Name               string      `gorm:"size:255;not null" json:"name"`
+	GSTIN              string      `gorm:"size:20" json:"gstin,omitempty"`
+	PAN                string      `gorm:"size:20" json:"pan,omitempty"`
+	ContactName        string      `gorm:"size:255" json:"contact_name,omitempty"`
+	Phone              string      `gorm:"size:32" json:"phone,omitempty"`
+	Email              string      `gorm:"size:255" json:"email,omitempty"`
+	Address            string      `gorm:"type:text" json:"address,omitempty"`
+	PortalUserIDs      StringArray `gorm:"type:jsonb;default:'[]'" json:"portal_user_ids"`
+	IsActive           bool        `gorm:"default:true" json:"is_active"`
+	CreatedBy          string      `gorm:"size:255;not null" json:"created_by"`
+	UpdatedBy          string      `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt          time.Time   `json:"created_at"`
+	UpdatedAt          time.Time   `json:"updated_at"`
+	DeletedAt          *time.Time  `gorm:"index" json:"deleted_at,omitempty"`
+}
+
+// TableName specifies the table name for Subcontractor
+func (Subcontractor) TableName() string {
+	return "subcontractors"
+}
+
+// WorkOrder issues a scope of work to a subcontractor at agreed rates for a validity
+// period. Retention is held back from each bill and an advance paid at issue is
+// recovered from bills at AdvanceRecoveryPercent of their gross. The running totals
+// track what has been certified and billed against it.
+type WorkOrder struct {
+	ID                     uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID     uuid.UUID       `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	SubcontractorID        uuid.UUID       `gorm:"type:uuid;not null;index" json:"subcontractor_id"`
+	Subcontractor          *Subcontractor  `gorm:"foreignKey:SubcontractorID" json:"subcontractor,omitempty"`
+	ProjectID              *uuid.UUID      `gorm:"type:uuid;index" json:"project_id,omitempty"`
+	OrderNumber            string          `gorm:"size:64;not null" json:"order_number"`
+	Title                  string          `gorm:"size:255;not null" json:"title"`
+	Scope                  string          `gorm:"type:text" json:"scope,omitempty"`
+	ValidFrom              time.Time       `gorm:"type:date;not null" json:"valid_from"`
+	ValidUntil             time.Time       `gorm:"type:date;not null" json:"valid_until"`
+	ContractValue          float64         `gorm:"type:decimal(15,2);default:0" json:"contract_value"`
+	RetentionPercent       float64         `gorm:"type:decimal(5,2);default:0" json:"retention_percent"`
+	AdvanceAmount          float64         `gorm:"type:decimal(15,2);default:0" json:"advance_amount"`
+	AdvanceRecoveryPercent float64         `gorm:"type:decimal(5,2);default:0" json:"advance_recovery_percent"`
+	CertifiedAmount        float64         `gorm:"type:decimal(15,2);default:0" json:"certified_amount"`
+	BilledAmount           float64         `gorm:"type:decimal(15,2);default:0" json:"billed_amount"`
+	RetentionHeld          float64         `gorm:"type:decimal(15,2);default:0" json:"retention_held"`
+	AdvanceRecovered       float64         `gorm:"type:decimal(15,2);default:0" json:"advance_recovered"`
+	Status                 string          `gorm:"size:20;not null;default:'draft';index" json:"status"`
+	IssuedBy               string          `gorm:"size:255" json:"issued_by,omitempty"`
+	IssuedAt               *time.Time      `json:"issued_at,omitempty"`
+	Terms                  string          `gorm:"type:text" json:"terms,omitempty"`
+	CreatedBy              string          `gorm:"size:255;not null" json:"created_by"`
+	UpdatedBy              string          `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt              time.Time       `json:"created_at"`
+	UpdatedAt              time.Time       `json:"updated_at"`
+	DeletedAt              *time.Time      `gorm:"index" json:"deleted_at,omitempty"`
+	Lines                  []WorkOrderLine `gorm:"foreignKey:WorkOrderID" json:"lines,omitempty"`
+}
+
+// TableName specifies the table name for WorkOrder
+func (WorkOrder) TableName() string {
+	return "work_orders"
+}
+
+// ValidOn reports whether the work order's validity covers the day
+func (wo WorkOrder) ValidOn(day time.Time) bool {
+	d := day.Format("2006-01-02")
+	return d >= wo.ValidFrom.Format("2006-01-02") && d <= wo.ValidUntil.Format("2006-01-02")
+}
+
+// WorkOrderLine is an item of work on a work order at its agreed rate, optionally
+// against a project BOQ item
+type WorkOrderLine struct {
+	ID                uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	WorkOrderID       uuid.UUID  `gorm:"type:uuid;not null;index" json:"work_order_id"`
+	BOQItemID         *uuid.UUID `gorm:"type:uuid;index" json:"boq_item_id,omitempty"`
+	Code              string     `gorm:"size:64;not null" json:"code"`
+	Description       string     `gorm:"type:text;not null" json:"description"`
+	UOM               string     `gorm:"size:32;not null" json:"uom"`
+	Quantity          float64    `gorm:"type:decimal(15,4);not null" json:"quantity"`
+	Rate              float64    `gorm:"type:decimal(15,2);not null" json:"rate"`
+	Amount            float64    `gorm:"type:decimal(15,2);not null" json:"amount"`
+	CertifiedQuantity float64    `gorm:"type:decimal(15,4);default:0" json:"certified_quantity"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for WorkOrderLine
+func (WorkOrderLine) TableName() string {
+	return "work_order_lines"
+}
+
+// CertificateLine is the quantity of one work order line certified on a progress
+// certificate
+type CertificateLine struct {
+	WorkOrderLineID uuid.UUID `json:"work_order_line_id"`
+	Code            string    `json:"code,omitempty"`
+	Quantity        float64   `json:"quantity"`
+	Rate            float64   `json:"rate"`
+	Amount          float64   `json:"amount"`
+}
+
+// CertificateLines are the lines of a progress certificate
+type CertificateLines []CertificateLine
+
+// WorkOrderCertificate certifies the work done against a work order over a period. Once
+// certified its quantities count against the order's lines and it can be billed.
+type WorkOrderCertificate struct {
+	ID                uuid.UUID        `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	WorkOrderID       uuid.UUID        `gorm:"type:uuid;not null;index" json:"work_order_id"`
+	CertificateNumber string           `gorm:"size:64;not null" json:"certificate_number"`
+	PeriodStart       time.Time        `gorm:"type:date;not null" json:"period_start"`
+	PeriodEnd         time.Time        `gorm:"type:date;not null" json:"period_end"`
+	Lines             CertificateLines `gorm:"type:jsonb;default:'[]'" json:"lines"`
+	Amount            float64          `gorm:"type:decimal(15,2);default:0" json:"amount"`
+	Status            string           `gorm:"size:20;not null;default:'pending';index" json:"status"`
+	Remarks           string           `gorm:"type:text" json:"remarks,omitempty"`
+	PreparedBy        string           `gorm:"size:255;not null" json:"prepared_by"`
+	CertifiedBy       string           `gorm:"size:255" json:"certified_by,omitempty"`
+	CertifiedAt       *time.Time       `json:"certified_at,omitempty"`
+	BillID            *uuid.UUID       `gorm:"type:uuid;index" json:"bill_id,omitempty"`
+	CreatedAt         time.Time        `json:"created_at"`
+	UpdatedAt         time.Time        `json:"updated_at"`
+}
+
+// TableName specifies the table name for WorkOrderCertificate
+func (WorkOrderCertificate) TableName() string {
+	return "work_order_certificates"
+}
+
+// SubcontractBill bills a subcontractor for certified work on a work order. It moves
+// draft, submitted, approved or rejected, and paid like an RA bill.
+type SubcontractBill struct {
+	ID                 uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID  `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	WorkOrderID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"work_order_id"`
+	WorkOrder          *WorkOrder `gorm:"foreignKey:WorkOrderID" json:"work_order,omitempty"`
+	SubcontractorID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"subcontractor_id"`
+	BillNumber         string     `gorm:"size:64;not null" json:"bill_number"`
+	GrossAmount        float64    `gorm:"type:decimal(15,2);default:0" json:"gross_amount"`
+	RetentionAmount    float64    `gorm:"type:decimal(15,2);default:0" json:"retention_amount"`
+	AdvanceRecovery    float64    `gorm:"type:decimal(15,2);default:0" json:"advance_recovery"`
+	DeductionsAmount   float64    `gorm:"type:decimal(15,2);default:0" json:"deductions_amount"`
+	TaxAmount          float64    `gorm:"type:decimal(15,2);default:0" json:"tax_amount"`
+	NetAmount          float64    `gorm:"type:decimal(15,2);default:0" json:"net_amount"`
+	Status             string     `gorm:"size:20;not null;default:'draft';index" json:"status"`
+	SubmittedBy        string     `gorm:"size:255" json:"submitted_by,omitempty"`
+	SubmittedAt        *time.Time `json:"submitted_at,omitempty"`
+	ApprovedBy         string     `gorm:"size:255" json:"approved_by,omitempty"`
+	ApprovedAt         *time.Time `json:"approved_at,omitempty"`
+	PaymentReference   string     `gorm:"size:255" json:"payment_reference,omitempty"`
+	Remarks            string     `gorm:"type:text" json:"remarks,omitempty"`
+	CreatedBy          string     `gorm:"size:255;not null" json:"created_by"`
+	UpdatedBy          string     `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for SubcontractBill
+func (SubcontractBill) TableName() string {
+	return "subcontract_bills"
+}
+
+// CertifyLines prices the quantities claimed on a certificate at their work order line
+// rates. It rejects lines not on the order and quantities that would take a line past its
+// ordered quantity.
+func CertifyLines(orderLines []WorkOrderLine, claimed CertificateLines) (CertificateLines, float64, error) {
+	byID := make(map[uuid.UUID]WorkOrderLine, len(orderLines))
+	for _, line := range orderLines {
+		byID[line.ID] = line
+	}
+	if len(claimed) == 0 {
+		return nil, 0, fmt.Errorf("a certificate needs at least one line")
+	}
+
+	lines := make(CertificateLines, 0, len(claimed))
+	claimedQty := map[uuid.UUID]float64{}
+	var total float64
+	for _, c := range claimed {
+		line, ok := byID[c.WorkOrderLineID]
+		if !ok {
+			return nil, 0, fmt.Errorf("line %s is not on the work order", c.WorkOrderLineID)
+		}
+		if c.Quantity <= 0 {
+			return nil, 0, fmt.Errorf("line %s: quantity must be positive", line.Code)
+		}
+		claimedQty[line.ID] += c.Quantity
+		if line.CertifiedQuantity+claimedQty[line.ID] > line.Quantity+1e-9 {
+			return nil, 0, fmt.Errorf("line %s: %.4f %s would exceed the ordered %.4f (%.4f already certified)",
+				line.Code, c.Quantity, line.UOM, line.Quantity, line.CertifiedQuantity)
+		}
+		amount := math.Round(c.Quantity*line.Rate*100) / 100
+		lines = append(lines, CertificateLine{
+			WorkOrderLineID: line.ID,
+			Code:            line.Code,
+			Quantity:        c.Quantity,
+			Rate:            line.Rate,
+			Amount:          amount,
+		})
+		total += amount
+	}
+	return lines, math.Round(total*100) / 100, nil
+}
+
+// NewSubcontractBill works out a bill for gross worth of certified work on the work
+// order: retention at the order's percentage, and advance recovery at its percentage but
+// never more than the advance still outstanding.
+func NewSubcontractBill(wo WorkOrder, gross, deductions, tax float64) SubcontractBill {
+	retention := math.Round(gross*wo.RetentionPercent) / 100
+	recovery := math.Round(gross*wo.AdvanceRecoveryPercent) / 100
+	if outstanding := wo.AdvanceAmount - wo.AdvanceRecovered; recovery > outstanding {
+		recovery = math.Max(0, outstanding)
+	}
+	return SubcontractBill{
+		BusinessVerticalID: wo.BusinessVerticalID,
+		WorkOrderID:        wo.ID,
+		SubcontractorID:    wo.SubcontractorID,
+		GrossAmount:        gross,
+		RetentionAmount:    retention,
+		AdvanceRecovery:    recovery,
+		DeductionsAmount:   deductions,
+		TaxAmount:          tax,
+		NetAmount:          math.Round((gross-retention-recovery-deductions+tax)*100) / 100,
+		Status:             "draft",
+	}
+}
+
+// Scan implements the sql.Scanner interface
+func (cl *CertificateLines) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		*cl = CertificateLines{}
+		return nil
+	}
+	return json.Unmarshal(bytes, cl)
+}
+
+// Value implements the driver.Valuer interface
+func (cl CertificateLines) Value() (driver.Value, error) {
+	if cl == nil {
+		return json.Marshal([]CertificateLine{})
+	}
+	return json.Marshal([]CertificateLine(cl))
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCertifyLines(t *testing.T) {
+	trench := WorkOrderLine{ID: uuid.New(), Code: "WO-1", UOM: "m", Quantity: 1000, Rate: 180, CertifiedQuantity: 600}
+	pipe := WorkOrderLine{ID: uuid.New(), Code: "WO-2", UOM: "m", Quantity: 1000, Rate: 95.5}
+	order := []WorkOrderLine{trench, pipe}
+
+	lines, total, err := CertifyLines(order, CertificateLines{
+		{WorkOrderLineID: trench.ID, Quantity: 250},
+		{WorkOrderLineID: pipe.ID, Quantity: 120, Rate: 999},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 56460 || lines[1].Rate != 95.5 || lines[1].Amount != 11460 || lines[0].Code != "WO-1" {
+		t.Errorf("total = %.2f, lines = %+v", total, lines)
+	}
+
+	if _, _, err := CertifyLines(order, CertificateLines{
+		{WorkOrderLineID: trench.ID, Quantity: 300},
+		{WorkOrderLineID: trench.ID, Quantity: 150},
+	}); err == nil {
+		t.Error("certifying past the ordered quantity should fail")
+	}
+	if _, _, err := CertifyLines(order, CertificateLines{{WorkOrderLineID: uuid.New(), Quantity: 1}}); err == nil {
+		t.Error("a line not on the work order should fail")
+	}
+	if _, _, err := CertifyLines(order, nil); err == nil {
+		t.Error("an empty certificate should fail")
+	}
+}
+
+func TestNewSubcontractBill(t *testing.T) {
+	wo := WorkOrder{RetentionPercent: 5, AdvanceAmount: 50000, AdvanceRecoveryPercent: 10, AdvanceRecovered: 40000}
+
+	bill := NewSubcontractBill(wo, 200000, 2500, 36000)
+	if bill.RetentionAmount != 10000 {
+		t.Errorf("retention = %.2f, want 10000", bill.RetentionAmount)
+	}
+	if bill.AdvanceRecovery != 10000 {
+		t.Errorf("advance recovery = %.2f, want the 10000 outstanding rather than 20000", bill.AdvanceRecovery)
+	}
+	if bill.NetAmount != 213500 || bill.Status != "draft" {
+		t.Errorf("net = %.2f (%s), want 213500 draft", bill.NetAmount, bill.Status)
+	}
+
+	wo.AdvanceRecovered = wo.AdvanceAmount
+	if bill := NewSubcontractBill(wo, 100000, 0, 0); bill.AdvanceRecovery != 0 {
+		t.Errorf("advance recovery once recovered = %.2f, want 0", bill.AdvanceRecovery)
+	}
+}
+
+func TestWorkOrderValidOn(t *testing.T) {
+	wo := WorkOrder{
+		ValidFrom:  time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC),
+		ValidUntil: time.Date(2026, 9, 30, 0, 0, 0, 0, time.UTC),
+	}
+	if !wo.ValidOn(time.Date(2026, 9, 30, 18, 0, 0, 0, time.UTC)) {
+		t.Error("the last day of validity should be valid")
+	}
+	if wo.ValidOn(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Error("the day after validity should not be valid")
+	}
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	TaskAttendanceStatusActive    = "active"
+	TaskAttendanceStatusCompleted = "completed"
+)
+
+// TaskAttendance records a worker checking in to and out of a task they are assigned to,
+// at a point validated against the task's zone. Each one is tied to the HR attendance
+// session it fed; OpenedSession says the check-in opened that session, so the check-out
+// closes it.
+type TaskAttendance struct {
+	ID                  uuid.UUID          `gorm:"type:uuid;primaryKey" json:"id"`
+	TaskID              uuid.UUID          `gorm:"type:uuid;not null;index:idx_task_attendances_task_time,priority:1" json:"taskId"`
+	AssignmentID        uuid.UUID          `gorm:"type:uuid;not null;index" json:"assignmentId"`
+	ProjectID           uuid.UUID          `gorm:"type:uuid;not null;index" json:"projectId"`
+	ZoneID              *uuid.UUID         `gorm:"type:uuid;index" json:"zoneId,omitempty"`
+	UserID              uuid.UUID          `gorm:"type:uuid;not null;index" json:"userId"`
+	UserName            string             `gorm:"size:255" json:"userName,omitempty"`
+	BusinessVerticalID  uuid.UUID          `gorm:"type:uuid;not null;index" json:"businessVerticalId"`
+	AttendanceSessionID uuid.UUID          `gorm:"type:uuid;not null;index" json:"attendanceSessionId"`
+	AttendanceSession   *AttendanceSession `gorm:"foreignKey:AttendanceSessionID" json:"attendanceSession,omitempty"`
+	OpenedSession       bool               `gorm:"default:false" json:"openedSession"`
+	Status              string             `gorm:"size:20;not null;default:'active';index" json:"status"`
+	CheckInAt           time.Time          `gorm:"not null;index:idx_task_attendances_task_time,priority:2" json:"checkInAt"`
+	CheckInLatitude     float64            `gorm:"not null" json:"checkInLatitude"`
+	CheckInLongitude    float64            `gorm:"not null" json:"checkInLongitude"`
+	CheckInAccuracy     float64            `gorm:"not null" json:"checkInAccuracy"`
+	CheckInDistanceM    float64            `gorm:"not null" json:"checkInDistanceM"`
+	CheckOutAt          *time.Time         `json:"checkOutAt,omitempty"`
+	CheckOutLatitude    *float64           `json:"checkOutLatitude,omitempty"`
+	CheckOutLongitude   *float64           `json:"checkOutLongitude,omitempty"`
+	CheckOutAccuracy    *float64           `json:"checkOutAccuracy,omitempty"`
+	CheckOutDistanceM   *float64           `json:"checkOutDistanceM,omitempty"`
+	DeviceID            string             `gorm:"size:128;not null" json:"deviceId"`
+	ValidationMethod    string             `gorm:"size:50;not null" json:"validationMethod"`
+	ValidationStatus    string             `gorm:"size:20;not null" json:"validationStatus"`
+	AnomalyFlags        *string            `gorm:"type:jsonb" json:"anomalyFlags,omitempty"`
+	CreatedAt           time.Time          `json:"createdAt"`
+	UpdatedAt           time.Time          `json:"updatedAt"`
+	DeletedAt           gorm.DeletedAt     `gorm:"index" json:"-"`
+}
+
+// Hours is how long the worker was checked in, up to now while still checked in
+func (a TaskAttendance) Hours(now time.Time) float64 {
+	end := now
+	if a.CheckOutAt != nil {
+		end = *a.CheckOutAt
+	}
+	if end.Before(a.CheckInAt) {
+		return 0
+	}
+	return end.Sub(a.CheckInAt).Hours()
+}
+
+func (a *TaskAttendance) BeforeCreate(tx *gorm.DB) (err error) {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
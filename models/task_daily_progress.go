@@ -0,0 +1,230 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DPRManpowerLine is one category of workers on a daily progress entry, such as 6 masons
+// at a day rate
+type DPRManpowerLine struct {
+	Category string  `json:"category"`
+	Count    int     `json:"count"`
+	Hours    float64 `json:"hours,omitempty"`
+	DayRate  float64 `json:"day_rate,omitempty"`
+}
+
+// DPRMachineryLine is one machine run on a daily progress entry
+type DPRMachineryLine struct {
+	Equipment  string  `json:"equipment"`
+	Hours      float64 `json:"hours"`
+	HourlyRate float64 `json:"hourly_rate,omitempty"`
+}
+
+// DPRManpower is the manpower of a daily progress entry
+type DPRManpower []DPRManpowerLine
+
+// DPRMachinery is the machinery of a daily progress entry
+type DPRMachinery []DPRMachineryLine
+
+// TaskDailyProgress is a daily progress report (DPR) entry for a task: the quantity done
+// that day, the manpower and machinery it took and photos of the work. Recording one adds
+// its quantity and costs to the task and moves the task's progress from ProgressBefore to
+// ProgressAfter.
+type TaskDailyProgress struct {
+	ID             uuid.UUID    `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	TaskID         uuid.UUID    `gorm:"type:uuid;not null;index:idx_task_daily_progress_task_date,priority:1" json:"task_id"`
+	Task           *Tasks       `gorm:"foreignKey:TaskID" json:"task,omitempty"`
+	ProjectID      uuid.UUID    `gorm:"type:uuid;not null;index:idx_task_daily_progress_project_date,priority:1" json:"project_id"`
+	SiteID         *uuid.UUID   `gorm:"type:uuid;index" json:"site_id,omitempty"`
+	ReportDate     time.Time    `gorm:"type:date;not null;index:idx_task_daily_progress_task_date,priority:2;index:idx_task_daily_progress_project_date,priority:2" json:"report_date"`
+	QuantityDone   float64      `gorm:"type:decimal(15,4);default:0" json:"quantity_done"`
+	UOM            string       `gorm:"size:32" json:"uom,omitempty"`
+	ProgressBefore float64      `gorm:"type:decimal(5,2);default:0" json:"progress_before"`
+	ProgressAfter  float64      `gorm:"type:decimal(5,2);default:0" json:"progress_after"`
+	Manpower       DPRManpower  `gorm:"type:jsonb;default:'[]'" json:"manpower"`
+	ManpowerCount  int          `gorm:"default:0" json:"manpower_count"`
+	LaborCost      float64      `gorm:"type:decimal(15,2);default:0" json:"labor_cost"`
+	Machinery      DPRMachinery `gorm:"type:jsonb;default:'[]'" json:"machinery"`
+	MachineryHours float64      `gorm:"type:decimal(10,2);default:0" json:"machinery_hours"`
+	EquipmentCost  float64      `gorm:"type:decimal(15,2);default:0" json:"equipment_cost"`
+	MaterialCost   float64      `gorm:"type:decimal(15,2);default:0" json:"material_cost"`
+	Photos         StringArray  `gorm:"type:jsonb;default:'[]'" json:"photos"`
+	Weather        string       `gorm:"size:100" json:"weather,omitempty"`
+	Remarks        string       `gorm:"type:text" json:"remarks,omitempty"`
+	ReportedBy     string       `gorm:"size:255;not null" json:"reported_by"`
+	ReportedByName string       `gorm:"size:255" json:"reported_by_name,omitempty"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+	DeletedAt      *time.Time   `gorm:"index" json:"deleted_at,omitempty"`
+}
+
+// TableName specifies the table name for TaskDailyProgress
+func (TaskDailyProgress) TableName() string {
+	return "task_daily_progress"
+}
+
+// Tally fills in the entry's manpower count, machinery hours and the labour and equipment
+// costs from its lines
+func (e *TaskDailyProgress) Tally() {
+	e.ManpowerCount, e.LaborCost = 0, 0
+	for _, line := range e.Manpower {
+		e.ManpowerCount += line.Count
+		e.LaborCost += float64(line.Count) * line.DayRate
+	}
+	e.MachineryHours, e.EquipmentCost = 0, 0
+	for _, line := range e.Machinery {
+		e.MachineryHours += line.Hours
+		e.EquipmentCost += line.Hours * line.HourlyRate
+	}
+	e.LaborCost = math.Round(e.LaborCost*100) / 100
+	e.EquipmentCost = math.Round(e.EquipmentCost*100) / 100
+}
+
+// TotalCost is what the entry adds to its task's costs
+func (e TaskDailyProgress) TotalCost() float64 {
+	return e.LaborCost + e.EquipmentCost + e.MaterialCost
+}
+
+// DailyProgressAfter is a task's progress once quantityDone more is executed. A task with
+// a planned quantity progresses by the share of it executed; one without takes the
+// reported progress, if any. Progress never goes back or past 100.
+func DailyProgressAfter(task Tasks, quantityDone float64, reported *float64) float64 {
+	progress := task.Progress
+	switch {
+	case task.PlannedQuantity > 0:
+		progress = (task.ExecutedQuantity + quantityDone) / task.PlannedQuantity * 100
+	case reported != nil:
+		progress = *reported
+	}
+	progress = math.Round(progress*100) / 100
+	return math.Min(100, math.Max(task.Progress, progress))
+}
+
+// DPRTaskLine is one task's part of a day's progress report
+type DPRTaskLine struct {
+	TaskID        uuid.UUID `json:"task_id"`
+	Code          string    `json:"code"`
+	Title         string    `json:"title"`
+	QuantityDone  float64   `json:"quantity_done"`
+	UOM           string    `json:"uom,omitempty"`
+	ProgressAfter float64   `json:"progress_after"`
+	Entries       int       `json:"entries"`
+}
+
+// DPRSummary totals a day's progress entries at one site, or at none when SiteID is nil
+type DPRSummary struct {
+	Date           string        `json:"date"`
+	SiteID         *uuid.UUID    `json:"site_id"`
+	SiteName       string        `json:"site_name,omitempty"`
+	Entries        int           `json:"entries"`
+	ManpowerCount  int           `json:"manpower_count"`
+	MachineryHours float64       `json:"machinery_hours"`
+	LaborCost      float64       `json:"labor_cost"`
+	EquipmentCost  float64       `json:"equipment_cost"`
+	MaterialCost   float64       `json:"material_cost"`
+	TotalCost      float64       `json:"total_cost"`
+	Photos         int           `json:"photos"`
+	Tasks          []DPRTaskLine `json:"tasks"`
+}
+
+// NewDPRReport sums progress entries by day and site, latest day first. tasks names the
+// entries' tasks.
+func NewDPRReport(entries []TaskDailyProgress, tasks map[uuid.UUID]Tasks) []DPRSummary {
+	type key struct {
+		date string
+		site uuid.UUID
+	}
+	summaries := map[key]*DPRSummary{}
+	lines := map[key]map[uuid.UUID]*DPRTaskLine{}
+	for _, e := range entries {
+		k := key{date: e.ReportDate.Format("2006-01-02")}
+		if e.SiteID != nil {
+			k.site = *e.SiteID
+		}
+		summary, ok := summaries[k]
+		if !ok {
+			summary = &DPRSummary{Date: k.date, SiteID: e.SiteID, Tasks: []DPRTaskLine{}}
+			summaries[k] = summary
+			lines[k] = map[uuid.UUID]*DPRTaskLine{}
+		}
+		summary.Entries++
+		summary.ManpowerCount += e.ManpowerCount
+		summary.MachineryHours += e.MachineryHours
+		summary.LaborCost += e.LaborCost
+		summary.EquipmentCost += e.EquipmentCost
+		summary.MaterialCost += e.MaterialCost
+		summary.TotalCost += e.TotalCost()
+		summary.Photos += len(e.Photos)
+
+		line, ok := lines[k][e.TaskID]
+		if !ok {
+			task := tasks[e.TaskID]
+			line = &DPRTaskLine{TaskID: e.TaskID, Code: task.Code, Title: task.Title, UOM: e.UOM}
+			lines[k][e.TaskID] = line
+		}
+		line.Entries++
+		line.QuantityDone += e.QuantityDone
+		line.ProgressAfter = math.Max(line.ProgressAfter, e.ProgressAfter)
+	}
+
+	report := make([]DPRSummary, 0, len(summaries))
+	for k, summary := range summaries {
+		for _, line := range lines[k] {
+			summary.Tasks = append(summary.Tasks, *line)
+		}
+		sort.Slice(summary.Tasks, func(i, j int) bool { return summary.Tasks[i].Code < summary.Tasks[j].Code })
+		report = append(report, *summary)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Date != report[j].Date {
+			return report[i].Date > report[j].Date
+		}
+		if (report[i].SiteID == nil) != (report[j].SiteID == nil) {
+			return report[i].SiteID == nil
+		}
+		return report[i].SiteID != nil && report[i].SiteID.String() < report[j].SiteID.String()
+	})
+	return report
+}
+
+// Scan implements the sql.Scanner interface
+func (m *DPRManpower) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		*m = DPRManpower{}
+		return nil
+	}
+	return json.Unmarshal(bytes, m)
+}
+
+// Value implements the driver.Valuer interface
+func (m DPRManpower) Value() (driver.Value, error) {
+	if m == nil {
+		return json.Marshal([]DPRManpowerLine{})
+	}
+	return json.Marshal([]DPRManpowerLine(m))
+}
+
+// Scan implements the sql.Scanner interface
+func (m *DPRMachinery) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		*m = DPRMachinery{}
+		return nil
+	}
+	return json.Unmarshal(bytes, m)
+}
+
+// Value implements the driver.Valuer interface
+func (m DPRMachinery) Value() (driver.Value, error) {
+	if m == nil {
+		return json.Marshal([]DPRMachineryLine{})
+	}
+	return json.Marshal([]DPRMachineryLine(m))
+}
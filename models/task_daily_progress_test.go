@@ -0,0 +1,69 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestTaskDailyProgressTally(t *testing.T) {
+	entry := TaskDailyProgress{
+		Manpower:     DPRManpower{{Category: "mason", Count: 4, DayRate: 900}, {Category: "helper", Count: 6, DayRate: 600}},
+		Machinery:    DPRMachinery{{Equipment: "JCB", Hours: 5.5, HourlyRate: 1200}},
+		MaterialCost: 2000,
+	}
+	entry.Tally()
+	if entry.ManpowerCount != 10 || entry.LaborCost != 7200 {
+		t.Errorf("manpower = %d at %.2f, want 10 at 7200", entry.ManpowerCount, entry.LaborCost)
+	}
+	if entry.MachineryHours != 5.5 || entry.EquipmentCost != 6600 {
+		t.Errorf("machinery = %.1fh at %.2f, want 5.5h at 6600", entry.MachineryHours, entry.EquipmentCost)
+	}
+	if entry.TotalCost() != 15800 {
+		t.Errorf("total cost = %.2f, want 15800", entry.TotalCost())
+	}
+}
+
+func TestDailyProgressAfter(t *testing.T) {
+	measured := Tasks{Progress: 40, PlannedQuantity: 500, ExecutedQuantity: 200}
+	if got := DailyProgressAfter(measured, 50, nil); got != 50 {
+		t.Errorf("measured task = %.2f, want 50", got)
+	}
+	if got := DailyProgressAfter(measured, 400, nil); got != 100 {
+		t.Errorf("over-executed task = %.2f, want capped at 100", got)
+	}
+
+	reported := 30.0
+	unmeasured := Tasks{Progress: 40}
+	if got := DailyProgressAfter(unmeasured, 0, &reported); got != 40 {
+		t.Errorf("unmeasured task = %.2f, want progress kept at 40", got)
+	}
+	reported = 55
+	if got := DailyProgressAfter(unmeasured, 0, &reported); got != 55 {
+		t.Errorf("unmeasured task = %.2f, want the reported 55", got)
+	}
+}
+
+func TestNewDPRReport(t *testing.T) {
+	taskA, taskB, site := uuid.New(), uuid.New(), uuid.New()
+	day1 := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	report := NewDPRReport([]TaskDailyProgress{
+		{TaskID: taskA, SiteID: &site, ReportDate: day1, QuantityDone: 10, ManpowerCount: 5, LaborCost: 100, ProgressAfter: 20},
+		{TaskID: taskA, SiteID: &site, ReportDate: day1, QuantityDone: 5, ManpowerCount: 2, LaborCost: 50, ProgressAfter: 30},
+		{TaskID: taskB, SiteID: &site, ReportDate: day1, QuantityDone: 1, MaterialCost: 75, Photos: StringArray{"a.jpg"}},
+		{TaskID: taskB, ReportDate: day2, QuantityDone: 2},
+	}, map[uuid.UUID]Tasks{taskA: {Code: "T-A"}, taskB: {Code: "T-B"}})
+
+	if len(report) != 2 || report[0].Date != "2026-03-03" || report[1].Date != "2026-03-02" {
+		t.Fatalf("report = %+v, want 3 March then 2 March", report)
+	}
+	day := report[1]
+	if day.Entries != 3 || day.ManpowerCount != 7 || day.TotalCost != 225 || day.Photos != 1 {
+		t.Errorf("2 March = %+v, want 3 entries, 7 workers, 225 cost, 1 photo", day)
+	}
+	if len(day.Tasks) != 2 || day.Tasks[0].Code != "T-A" || day.Tasks[0].QuantityDone != 15 || day.Tasks[0].ProgressAfter != 30 {
+		t.Errorf("2 March tasks = %+v, want T-A with 15 done at 30%%", day.Tasks)
+	}
+}
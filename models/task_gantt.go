@@ -0,0 +1,118 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GanttBar is a span on the timeline. Either end may be missing, such as the end of a
+// task still in progress.
+type GanttBar struct {
+	Start *time.Time `json:"start"`
+	End   *time.Time `json:"end"`
+}
+
+// GanttAssignee is someone actively assigned to a Gantt task
+type GanttAssignee struct {
+	UserID   string `json:"user_id"`
+	UserName string `json:"user_name,omitempty"`
+	Role     string `json:"role"`
+}
+
+// GanttTask is a task as a Gantt chart draws it. Baseline is the plan, Actual what
+// happened; the variances are how many days the actual start and finish are behind the
+// baseline, negative when ahead. A task that should have started or finished by now but
+// has not is behind by the days since.
+type GanttTask struct {
+	ID                 uuid.UUID       `json:"id"`
+	Code               string          `json:"code"`
+	Title              string          `json:"title"`
+	ZoneID             *uuid.UUID      `json:"zone_id,omitempty"`
+	Status             string          `json:"status"`
+	CurrentState       string          `json:"current_state,omitempty"`
+	Priority           string          `json:"priority"`
+	Progress           float64         `json:"progress"`
+	Baseline           GanttBar        `json:"baseline"`
+	Actual             GanttBar        `json:"actual"`
+	StartVarianceDays  *int            `json:"start_variance_days"`
+	FinishVarianceDays *int            `json:"finish_variance_days"`
+	Assignees          []GanttAssignee `json:"assignees"`
+}
+
+// GanttLink is a dependency drawn between two Gantt tasks
+type GanttLink struct {
+	ID      uuid.UUID `json:"id"`
+	Source  uuid.UUID `json:"source"` // predecessor
+	Target  uuid.UUID `json:"target"` // successor
+	Type    string    `json:"type"`
+	LagDays int       `json:"lag_days"`
+}
+
+// NewGanttTask lays a task out for the Gantt chart as of now. Its baseline is its planned
+// dates, falling back to its start and end dates.
+func NewGanttTask(task Tasks, now time.Time) GanttTask {
+	baseline := GanttBar{Start: task.PlannedStartDate, End: task.PlannedEndDate}
+	if baseline.Start == nil {
+		start := task.StartDate
+		baseline.Start = &start
+	}
+	if baseline.End == nil {
+		end := task.EndDate
+		baseline.End = &end
+	}
+	gantt := GanttTask{
+		ID:                 task.ID,
+		Code:               task.Code,
+		Title:              task.Title,
+		ZoneID:             task.ZoneID,
+		Status:             task.Status,
+		CurrentState:       task.CurrentState,
+		Priority:           task.Priority,
+		Progress:           task.Progress,
+		Baseline:           baseline,
+		Actual:             GanttBar{Start: task.ActualStartDate, End: task.ActualEndDate},
+		StartVarianceDays:  ganttVariance(*baseline.Start, task.ActualStartDate, now),
+		FinishVarianceDays: ganttVariance(*baseline.End, task.ActualEndDate, now),
+		Assignees:          []GanttAssignee{},
+	}
+	if task.Status == "cancelled" {
+		gantt.StartVarianceDays, gantt.FinishVarianceDays = nil, nil
+	}
+	return gantt
+}
+
+// ganttVariance is how many days actual is behind planned. Without an actual date it is
+// the days the task is overdue, or nothing while planned is still ahead.
+func ganttVariance(planned time.Time, actual *time.Time, now time.Time) *int {
+	if actual != nil {
+		days := scheduleDays(planned, *actual)
+		return &days
+	}
+	if days := scheduleDays(planned, now); days > 0 {
+		return &days
+	}
+	return nil
+}
+
+// NewGanttLinks turns the active dependencies between the given tasks into Gantt links
+func NewGanttLinks(tasks []GanttTask, dependencies []TaskDependency) []GanttLink {
+	shown := make(map[uuid.UUID]bool, len(tasks))
+	for _, task := range tasks {
+		shown[task.ID] = true
+	}
+	links := []GanttLink{}
+	for _, dep := range dependencies {
+		if !dep.IsActive || !shown[dep.PredecessorTaskID] || !shown[dep.SuccessorTaskID] {
+			continue
+		}
+		links = append(links, GanttLink{
+			ID:      dep.ID,
+			Source:  dep.PredecessorTaskID,
+			Target:  dep.SuccessorTaskID,
+			Type:    dep.DependencyType,
+			LagDays: dep.LagDays,
+		})
+	}
+	return links
+}
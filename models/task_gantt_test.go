@@ -0,0 +1,57 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestNewGanttTask(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 9, 0, 0, 0, time.UTC) }
+	plannedStart, plannedEnd, actualStart := day(2), day(10), day(4)
+	task := Tasks{
+		ID:               uuid.New(),
+		Status:           "in-progress",
+		StartDate:        day(1),
+		EndDate:          day(5),
+		PlannedStartDate: &plannedStart,
+		PlannedEndDate:   &plannedEnd,
+		ActualStartDate:  &actualStart,
+	}
+
+	gantt := NewGanttTask(task, day(8))
+	if !gantt.Baseline.Start.Equal(plannedStart) || !gantt.Baseline.End.Equal(plannedEnd) {
+		t.Errorf("baseline = %+v, want the planned dates", gantt.Baseline)
+	}
+	if gantt.StartVarianceDays == nil || *gantt.StartVarianceDays != 2 {
+		t.Errorf("start variance = %v, want 2", gantt.StartVarianceDays)
+	}
+	if gantt.FinishVarianceDays != nil {
+		t.Errorf("finish variance = %v before the planned end, want none", *gantt.FinishVarianceDays)
+	}
+
+	overdue := NewGanttTask(task, day(13))
+	if overdue.FinishVarianceDays == nil || *overdue.FinishVarianceDays != 3 {
+		t.Errorf("finish variance = %v, want 3 days overdue", overdue.FinishVarianceDays)
+	}
+
+	task.PlannedStartDate, task.PlannedEndDate = nil, nil
+	unplanned := NewGanttTask(task, day(3))
+	if !unplanned.Baseline.Start.Equal(task.StartDate) || !unplanned.Baseline.End.Equal(task.EndDate) {
+		t.Errorf("baseline = %+v, want the start and end dates", unplanned.Baseline)
+	}
+}
+
+func TestNewGanttLinks(t *testing.T) {
+	a, b, hidden := uuid.New(), uuid.New(), uuid.New()
+	tasks := []GanttTask{{ID: a}, {ID: b}}
+	links := NewGanttLinks(tasks, []TaskDependency{
+		{PredecessorTaskID: a, SuccessorTaskID: b, DependencyType: "FS", IsActive: true},
+		{PredecessorTaskID: a, SuccessorTaskID: hidden, DependencyType: "FS", IsActive: true},
+		{PredecessorTaskID: b, SuccessorTaskID: a, DependencyType: "SS", IsActive: false},
+	})
+	if len(links) != 1 || links[0].Source != a || links[0].Target != b {
+		t.Errorf("links = %+v, want only a -> b", links)
+	}
+}
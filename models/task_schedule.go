@@ -0,0 +1,274 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrDependencyCycle is returned when task dependencies loop back on themselves
+var ErrDependencyCycle = errors.New("task dependencies form a cycle")
+
+// ScheduleTask is a task as the scheduler sees it: when it may start at the earliest and
+// how many days it takes
+type ScheduleTask struct {
+	ID           uuid.UUID
+	Start        time.Time
+	DurationDays int
+}
+
+// NewScheduleTask takes a task's planned start, falling back to its start date, and its
+// duration: the planned dates' span, else its expected completion days, else its start
+// and end dates' span
+func NewScheduleTask(task Tasks) ScheduleTask {
+	start := task.StartDate
+	if task.PlannedStartDate != nil {
+		start = *task.PlannedStartDate
+	}
+	var duration int
+	if task.PlannedStartDate != nil && task.PlannedEndDate != nil {
+		duration = scheduleDays(*task.PlannedStartDate, *task.PlannedEndDate)
+	} else if days, err := strconv.Atoi(strings.TrimSpace(task.ExpectedCompletionDays)); err == nil && days >= 0 {
+		duration = days
+	} else {
+		duration = scheduleDays(task.StartDate, task.EndDate)
+	}
+	if duration < 0 {
+		duration = 0
+	}
+	return ScheduleTask{ID: task.ID, Start: scheduleDay(start), DurationDays: duration}
+}
+
+// TaskScheduleEntry is a task's place in the schedule. Slack is how many days the task
+// can slip without delaying the project; critical tasks have none.
+type TaskScheduleEntry struct {
+	TaskID         uuid.UUID `json:"task_id"`
+	DurationDays   int       `json:"duration_days"`
+	EarliestStart  time.Time `json:"earliest_start"`
+	EarliestFinish time.Time `json:"earliest_finish"`
+	LatestStart    time.Time `json:"latest_start"`
+	LatestFinish   time.Time `json:"latest_finish"`
+	SlackDays      int       `json:"slack_days"`
+	Critical       bool      `json:"critical"`
+}
+
+// TaskSchedule is the outcome of scheduling a project's tasks
+type TaskSchedule struct {
+	Start        time.Time           `json:"start"`
+	Finish       time.Time           `json:"finish"`
+	DurationDays int                 `json:"duration_days"`
+	Tasks        []TaskScheduleEntry `json:"tasks"`         // by earliest start
+	CriticalPath []uuid.UUID         `json:"critical_path"` // critical tasks by earliest start
+}
+
+// scheduleDay truncates t to its day
+func scheduleDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// scheduleDays is the number of days from one day to another
+func scheduleDays(from, to time.Time) int {
+	return int(math.Round(scheduleDay(to).Sub(scheduleDay(from)).Hours() / 24))
+}
+
+// orderTasks sorts task IDs so each comes after its predecessors, or returns
+// ErrDependencyCycle. Dependencies on tasks not in ids are ignored.
+func orderTasks(ids []uuid.UUID, dependencies []TaskDependency) ([]uuid.UUID, error) {
+	known := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		known[id] = true
+	}
+	incoming := make(map[uuid.UUID]int, len(ids))
+	successors := make(map[uuid.UUID][]uuid.UUID)
+	for _, dep := range dependencies {
+		if !known[dep.PredecessorTaskID] || !known[dep.SuccessorTaskID] {
+			continue
+		}
+		successors[dep.PredecessorTaskID] = append(successors[dep.PredecessorTaskID], dep.SuccessorTaskID)
+		incoming[dep.SuccessorTaskID]++
+	}
+
+	var ready, order []uuid.UUID
+	for _, id := range ids {
+		if incoming[id] == 0 {
+			ready = append(ready, id)
+		}
+	}
+	for len(ready) > 0 {
+		id := ready[0]
+		ready = ready[1:]
+		order = append(order, id)
+		for _, next := range successors[id] {
+			incoming[next]--
+			if incoming[next] == 0 {
+				ready = append(ready, next)
+			}
+		}
+	}
+	if len(order) < len(ids) {
+		return nil, ErrDependencyCycle
+	}
+	return order, nil
+}
+
+// DependencyCreatesCycle reports whether adding dependency to dependencies would make
+// tasks depend on themselves
+func DependencyCreatesCycle(dependencies []TaskDependency, dependency TaskDependency) bool {
+	if dependency.PredecessorTaskID == dependency.SuccessorTaskID {
+		return true
+	}
+	successors := make(map[uuid.UUID][]uuid.UUID)
+	for _, dep := range dependencies {
+		successors[dep.PredecessorTaskID] = append(successors[dep.PredecessorTaskID], dep.SuccessorTaskID)
+	}
+	// The new edge closes a loop when its predecessor is reachable from its successor
+	seen := map[uuid.UUID]bool{}
+	stack := []uuid.UUID{dependency.SuccessorTaskID}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if id == dependency.PredecessorTaskID {
+			return true
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		stack = append(stack, successors[id]...)
+	}
+	return false
+}
+
+// ScheduleTasks computes the critical path schedule of tasks: a forward pass finds when
+// each task can start at the earliest, given its own start and its predecessors, and a
+// backward pass from the project's finish finds when it must start at the latest.
+// Dependencies are finish-to-start (FS), start-to-start (SS), finish-to-finish (FF) or
+// start-to-finish (SF), each shifted by its lag. Inactive dependencies and ones on tasks
+// not given are left out.
+func ScheduleTasks(tasks []ScheduleTask, dependencies []TaskDependency) (TaskSchedule, error) {
+	if len(tasks) == 0 {
+		return TaskSchedule{Tasks: []TaskScheduleEntry{}, CriticalPath: []uuid.UUID{}}, nil
+	}
+
+	byID := make(map[uuid.UUID]ScheduleTask, len(tasks))
+	ids := make([]uuid.UUID, len(tasks))
+	start := tasks[0].Start
+	for i, task := range tasks {
+		byID[task.ID] = task
+		ids[i] = task.ID
+		if task.Start.Before(start) {
+			start = task.Start
+		}
+	}
+	var active []TaskDependency
+	for _, dep := range dependencies {
+		_, hasPredecessor := byID[dep.PredecessorTaskID]
+		_, hasSuccessor := byID[dep.SuccessorTaskID]
+		if dep.IsActive && hasPredecessor && hasSuccessor {
+			active = append(active, dep)
+		}
+	}
+	order, err := orderTasks(ids, active)
+	if err != nil {
+		return TaskSchedule{}, err
+	}
+	into := make(map[uuid.UUID][]TaskDependency)
+	outof := make(map[uuid.UUID][]TaskDependency)
+	for _, dep := range active {
+		into[dep.SuccessorTaskID] = append(into[dep.SuccessorTaskID], dep)
+		outof[dep.PredecessorTaskID] = append(outof[dep.PredecessorTaskID], dep)
+	}
+
+	// Days are counted from the earliest task start
+	es := make(map[uuid.UUID]int, len(tasks))
+	finish := 0
+	for _, id := range order {
+		task := byID[id]
+		earliest := scheduleDays(start, task.Start)
+		for _, dep := range into[id] {
+			p := dep.PredecessorTaskID
+			var bound int
+			switch strings.ToUpper(dep.DependencyType) {
+			case "SS":
+				bound = es[p] + dep.LagDays
+			case "FF":
+				bound = es[p] + byID[p].DurationDays + dep.LagDays - task.DurationDays
+			case "SF":
+				bound = es[p] + dep.LagDays - task.DurationDays
+			default: // FS
+				bound = es[p] + byID[p].DurationDays + dep.LagDays
+			}
+			if bound > earliest {
+				earliest = bound
+			}
+		}
+		es[id] = earliest
+		if f := earliest + task.DurationDays; f > finish {
+			finish = f
+		}
+	}
+
+	lf := make(map[uuid.UUID]int, len(tasks))
+	for i := len(order) - 1; i >= 0; i-- {
+		id := order[i]
+		task := byID[id]
+		latest := finish
+		for _, dep := range outof[id] {
+			s := dep.SuccessorTaskID
+			ls := lf[s] - byID[s].DurationDays
+			var bound int
+			switch strings.ToUpper(dep.DependencyType) {
+			case "SS":
+				bound = ls - dep.LagDays + task.DurationDays
+			case "FF":
+				bound = lf[s] - dep.LagDays
+			case "SF":
+				bound = lf[s] - dep.LagDays + task.DurationDays
+			default: // FS
+				bound = ls - dep.LagDays
+			}
+			if bound < latest {
+				latest = bound
+			}
+		}
+		lf[id] = latest
+	}
+
+	day := func(offset int) time.Time { return start.AddDate(0, 0, offset) }
+	schedule := TaskSchedule{
+		Start:        start,
+		Finish:       day(finish),
+		DurationDays: finish,
+		Tasks:        make([]TaskScheduleEntry, 0, len(tasks)),
+		CriticalPath: []uuid.UUID{},
+	}
+	for _, id := range order {
+		task := byID[id]
+		ls := lf[id] - task.DurationDays
+		slack := ls - es[id]
+		schedule.Tasks = append(schedule.Tasks, TaskScheduleEntry{
+			TaskID:         id,
+			DurationDays:   task.DurationDays,
+			EarliestStart:  day(es[id]),
+			EarliestFinish: day(es[id] + task.DurationDays),
+			LatestStart:    day(ls),
+			LatestFinish:   day(lf[id]),
+			SlackDays:      slack,
+			Critical:       slack <= 0,
+		})
+	}
+	sort.SliceStable(schedule.Tasks, func(i, j int) bool {
+		return schedule.Tasks[i].EarliestStart.Before(schedule.Tasks[j].EarliestStart)
+	})
+	for _, entry := range schedule.Tasks {
+		if entry.Critical {
+			schedule.CriticalPath = append(schedule.CriticalPath, entry.TaskID)
+		}
+	}
+	return schedule, nil
+}
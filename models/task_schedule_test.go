@@ -0,0 +1,96 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestScheduleTasks(t *testing.T) {
+	day0 := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	trench, lay, backfill, survey := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	tasks := []ScheduleTask{
+		{ID: trench, Start: day0, DurationDays: 5},
+		{ID: lay, Start: day0, DurationDays: 3},
+		{ID: backfill, Start: day0, DurationDays: 2},
+		{ID: survey, Start: day0, DurationDays: 4},
+	}
+	dependencies := []TaskDependency{
+		{PredecessorTaskID: trench, SuccessorTaskID: lay, DependencyType: "FS", LagDays: 1, IsActive: true},
+		{PredecessorTaskID: lay, SuccessorTaskID: backfill, DependencyType: "FS", IsActive: true},
+		// Surveying runs alongside, starting two days into trenching
+		{PredecessorTaskID: trench, SuccessorTaskID: survey, DependencyType: "SS", LagDays: 2, IsActive: true},
+		// Ignored
+		{PredecessorTaskID: survey, SuccessorTaskID: trench, DependencyType: "FS", IsActive: false},
+	}
+
+	schedule, err := ScheduleTasks(tasks, dependencies)
+	if err != nil {
+		t.Fatalf("ScheduleTasks: %v", err)
+	}
+	if schedule.DurationDays != 11 || !schedule.Finish.Equal(day0.AddDate(0, 0, 11)) {
+		t.Errorf("project takes %d days to %v, want 11", schedule.DurationDays, schedule.Finish)
+	}
+	entries := map[uuid.UUID]TaskScheduleEntry{}
+	for _, entry := range schedule.Tasks {
+		entries[entry.TaskID] = entry
+	}
+	if got := entries[lay].EarliestStart; !got.Equal(day0.AddDate(0, 0, 6)) {
+		t.Errorf("laying starts %v, want day 6", got)
+	}
+	if got := entries[survey]; !got.EarliestStart.Equal(day0.AddDate(0, 0, 2)) || got.SlackDays != 5 || got.Critical {
+		t.Errorf("survey = %+v, want start on day 2 with 5 days slack", got)
+	}
+	if len(schedule.CriticalPath) != 3 || schedule.CriticalPath[0] != trench || schedule.CriticalPath[2] != backfill {
+		t.Errorf("critical path = %v, want trench, lay, backfill", schedule.CriticalPath)
+	}
+
+	// A later planned start holds a task back
+	tasks[3].Start = day0.AddDate(0, 0, 9)
+	schedule, _ = ScheduleTasks(tasks, dependencies)
+	if schedule.DurationDays != 13 {
+		t.Errorf("late survey: project takes %d days, want 13", schedule.DurationDays)
+	}
+
+	dependencies[3].IsActive = true
+	if _, err := ScheduleTasks(tasks, dependencies); !errors.Is(err, ErrDependencyCycle) {
+		t.Errorf("cycle: err = %v, want ErrDependencyCycle", err)
+	}
+}
+
+func TestDependencyCreatesCycle(t *testing.T) {
+	a, b, c := uuid.New(), uuid.New(), uuid.New()
+	existing := []TaskDependency{
+		{PredecessorTaskID: a, SuccessorTaskID: b},
+		{PredecessorTaskID: b, SuccessorTaskID: c},
+	}
+	if !DependencyCreatesCycle(existing, TaskDependency{PredecessorTaskID: c, SuccessorTaskID: a}) {
+		t.Error("c -> a closes a loop")
+	}
+	if !DependencyCreatesCycle(existing, TaskDependency{PredecessorTaskID: a, SuccessorTaskID: a}) {
+		t.Error("a task cannot depend on itself")
+	}
+	if DependencyCreatesCycle(existing, TaskDependency{PredecessorTaskID: a, SuccessorTaskID: c}) {
+		t.Error("a -> c is not a loop")
+	}
+}
+
+func TestNewScheduleTask(t *testing.T) {
+	start := time.Date(2026, 4, 1, 9, 30, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 6)
+	task := Tasks{ID: uuid.New(), StartDate: start, EndDate: end, ExpectedCompletionDays: "4"}
+	if got := NewScheduleTask(task); got.DurationDays != 4 || got.Start.Hour() != 0 {
+		t.Errorf("expected days: %+v", got)
+	}
+	plannedEnd := start.AddDate(0, 0, 3)
+	task.PlannedStartDate, task.PlannedEndDate = &start, &plannedEnd
+	if got := NewScheduleTask(task); got.DurationDays != 3 {
+		t.Errorf("planned dates: %+v", got)
+	}
+	task.PlannedStartDate, task.PlannedEndDate, task.ExpectedCompletionDays = nil, nil, ""
+	if got := NewScheduleTask(task); got.DurationDays != 6 {
+		t.Errorf("start and end dates: %+v", got)
+	}
+}
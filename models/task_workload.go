@@ -0,0 +1,123 @@
+package models
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WorkloadAssignment is one active assignment of a user to a task, over the days it
+// covers
+type WorkloadAssignment struct {
+	AssignmentID uuid.UUID
+	TaskID       uuid.UUID
+	ProjectID    uuid.UUID
+	UserID       string
+	UserName     string
+	Start        time.Time
+	End          time.Time
+}
+
+// WorkloadWeek is a user's load in the week starting on Monday WeekStart. PlannedManDays
+// counts each assignment's days in the week, Sundays excepted; OverlappingAssignments is
+// how many of the week's assignments share a working day with an assignment to another
+// task.
+type WorkloadWeek struct {
+	UserID                 string      `json:"user_id"`
+	UserName               string      `json:"user_name,omitempty"`
+	WeekStart              time.Time   `json:"week_start"`
+	ActiveTasks            int         `json:"active_tasks"`
+	PlannedManDays         float64     `json:"planned_man_days"`
+	OverlappingAssignments int         `json:"overlapping_assignments"`
+	TaskIDs                []uuid.UUID `json:"task_ids"`
+}
+
+// workloadWeekStart is Monday of the week containing t
+func workloadWeekStart(t time.Time) time.Time {
+	day := scheduleDay(t)
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
+
+// workloadDays lists the working days of [start, end] that fall in [from, to)
+func workloadDays(start, end, from, to time.Time) []time.Time {
+	day, last := scheduleDay(start), scheduleDay(end)
+	if day.Before(from) {
+		day = from
+	}
+	var days []time.Time
+	for ; !day.After(last) && day.Before(to); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() != time.Sunday {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// NewWorkload buckets assignments by user and week for the weeks from the one containing
+// from to the one containing to. Weeks in which a user has nothing are left out.
+func NewWorkload(assignments []WorkloadAssignment, from, to time.Time) []WorkloadWeek {
+	first := workloadWeekStart(from)
+	end := workloadWeekStart(to).AddDate(0, 0, 7)
+
+	type key struct {
+		user string
+		week time.Time
+	}
+	type entry struct {
+		week  WorkloadWeek
+		tasks map[uuid.UUID]bool
+		days  map[time.Time]map[uuid.UUID]bool // day -> tasks worked that day
+		spans map[uuid.UUID][]time.Time        // assignment -> its days in the week
+	}
+	weeks := map[key]*entry{}
+	for _, a := range assignments {
+		for _, day := range workloadDays(a.Start, a.End, first, end) {
+			k := key{a.UserID, workloadWeekStart(day)}
+			e, ok := weeks[k]
+			if !ok {
+				e = &entry{
+					week:  WorkloadWeek{UserID: a.UserID, UserName: a.UserName, WeekStart: k.week, TaskIDs: []uuid.UUID{}},
+					tasks: map[uuid.UUID]bool{},
+					days:  map[time.Time]map[uuid.UUID]bool{},
+					spans: map[uuid.UUID][]time.Time{},
+				}
+				weeks[k] = e
+			}
+			if e.week.UserName == "" {
+				e.week.UserName = a.UserName
+			}
+			if !e.tasks[a.TaskID] {
+				e.tasks[a.TaskID] = true
+				e.week.TaskIDs = append(e.week.TaskIDs, a.TaskID)
+			}
+			if e.days[day] == nil {
+				e.days[day] = map[uuid.UUID]bool{}
+			}
+			e.days[day][a.TaskID] = true
+			e.spans[a.AssignmentID] = append(e.spans[a.AssignmentID], day)
+			e.week.PlannedManDays++
+		}
+	}
+
+	result := make([]WorkloadWeek, 0, len(weeks))
+	for _, e := range weeks {
+		e.week.ActiveTasks = len(e.tasks)
+		for _, days := range e.spans {
+			for _, day := range days {
+				if len(e.days[day]) > 1 {
+					e.week.OverlappingAssignments++
+					break
+				}
+			}
+		}
+		result = append(result, e.week)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].UserID != result[j].UserID {
+			return result[i].UserID < result[j].UserID
+		}
+		return result[i].WeekStart.Before(result[j].WeekStart)
+	})
+	return result
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestNewWorkload(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 9, 0, 0, 0, time.UTC) }
+	taskA, taskB := uuid.New(), uuid.New()
+	// 2 March 2026 is a Monday
+	workload := NewWorkload([]WorkloadAssignment{
+		{AssignmentID: uuid.New(), TaskID: taskA, UserID: "u1", UserName: "Asha", Start: day(2), End: day(10)},
+		{AssignmentID: uuid.New(), TaskID: taskB, UserID: "u1", Start: day(6), End: day(7)},
+		{AssignmentID: uuid.New(), TaskID: taskB, UserID: "u2", Start: day(5), End: day(5)},
+	}, day(2), day(9))
+
+	if len(workload) != 3 {
+		t.Fatalf("got %d weeks, want 3: %+v", len(workload), workload)
+	}
+	first := workload[0]
+	if first.UserID != "u1" || !first.WeekStart.Equal(time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("first week = %s %s, want u1 from 2 March", first.UserID, first.WeekStart)
+	}
+	// Task A Mon-Sat (Sunday skipped) plus task B Fri-Sat
+	if first.ActiveTasks != 2 || first.PlannedManDays != 8 || first.OverlappingAssignments != 2 {
+		t.Errorf("first week = %+v, want 2 tasks, 8 man-days, 2 overlapping", first)
+	}
+	second := workload[1]
+	if second.ActiveTasks != 1 || second.PlannedManDays != 2 || second.OverlappingAssignments != 0 {
+		t.Errorf("second week = %+v, want task A's last 2 days alone", second)
+	}
+	if workload[2].UserID != "u2" || workload[2].PlannedManDays != 1 {
+		t.Errorf("u2 week = %+v, want 1 man-day", workload[2])
+	}
+}
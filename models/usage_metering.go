@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UsageMetric identifies a billable resource tracked per business vertical.
+type UsageMetric string
+
+const (
+	UsageMetricAPICalls     UsageMetric = "api_calls"
+	UsageMetricStorageBytes UsageMetric = "storage_bytes"
+	UsageMetricSMSSent      UsageMetric = "sms_sent"
+	UsageMetricWhatsAppSent UsageMetric = "whatsapp_sent"
+	UsageMetricActiveUsers  UsageMetric = "active_users"
+)
+
+// UsagePeriodLayout is the time layout of a monthly billing period key.
+const UsagePeriodLayout = "2006-01"
+
+// AllUsageMetrics lists metrics in the order they appear on billing reports.
+var AllUsageMetrics = []UsageMetric{
+	UsageMetricAPICalls,
+	UsageMetricStorageBytes,
+	UsageMetricSMSSent,
+	UsageMetricWhatsAppSent,
+	UsageMetricActiveUsers,
+}
+
+// UsageAggregate stores the monthly quantity of a metric consumed by a business vertical.
+// BusinessVerticalID is uuid.Nil for usage that could not be attributed to a vertical.
+type UsageAggregate struct {
+	ID                 uuid.UUID   `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID   `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	Period             string      `gorm:"size:7;not null;index" json:"period"` // YYYY-MM
+	Metric             UsageMetric `gorm:"size:50;not null" json:"metric"`
+	Quantity           float64     `gorm:"type:decimal(20,2);not null;default:0" json:"quantity"`
+	CreatedAt          time.Time   `json:"created_at"`
+	UpdatedAt          time.Time   `json:"updated_at"`
+}
+
+func (UsageAggregate) TableName() string {
+	return "usage_aggregates"
+}
+
+// UsageActiveUser records that a user made at least one API call for a vertical in a period.
+// Distinct rows per (vertical, period) give the monthly active user count.
+type UsageActiveUser struct {
+	ID                 uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID `gorm:"type:uuid;not null" json:"business_vertical_id"`
+	Period             string    `gorm:"size:7;not null" json:"period"`
+	UserID             uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	FirstSeenAt        time.Time `json:"first_seen_at"`
+	LastSeenAt         time.Time `json:"last_seen_at"`
+}
+
+func (UsageActiveUser) TableName() string {
+	return "usage_active_users"
+}
+
+// UsagePeriod returns the billing period key for t.
+func UsagePeriod(t time.Time) string {
+	return t.UTC().Format(UsagePeriodLayout)
+}
@@ -18,7 +18,11 @@ type User struct {
 	RoleModel          *Role             `gorm:"foreignKey:RoleID"`             // Relationship to global Role
 	BusinessVerticalID *uuid.UUID        `gorm:"type:uuid;index"`               // Primary business vertical
 	BusinessVertical   *BusinessVertical `gorm:"foreignKey:BusinessVerticalID"` // Primary business relationship
+	CompanyID          *uuid.UUID        `gorm:"type:uuid;index"`               // Legal entity (tenant) the user belongs to
 	IsActive           bool              `gorm:"default:true;index"`
+	RoleValidFrom      *time.Time        // Global role takes effect at this time; nil means immediately
+	RoleValidUntil     *time.Time        `gorm:"index"`     // Global role expires at this time; nil means never
+	RoleAssignedBy     *uuid.UUID        `gorm:"type:uuid"` // Who granted the global role, notified when it expires
 	CreatedAt          time.Time
 	UpdatedAt          time.Time
 
@@ -34,11 +38,31 @@ func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
+// EffectiveRole returns the user's global role, or nil when it is not currently inside
+// its validity window.
+func (u *User) EffectiveRole() *Role {
+	if u.RoleModel == nil || !withinValidity(u.RoleValidFrom, u.RoleValidUntil, time.Now()) {
+		return nil
+	}
+	return u.RoleModel
+}
+
+// withinValidity reports whether t falls inside an optional [from, until) window.
+func withinValidity(from, until *time.Time, t time.Time) bool {
+	if from != nil && t.Before(*from) {
+		return false
+	}
+	if until != nil && !t.Before(*until) {
+		return false
+	}
+	return true
+}
+
 // HasPermission checks if user has a specific permission
 func (u *User) HasPermission(permissionName string) bool {
-	if u.RoleModel != nil {
+	if role := u.EffectiveRole(); role != nil {
 		// Check for wildcard or exact match
-		for _, perm := range u.RoleModel.Permissions {
+		for _, perm := range role.Permissions {
 			if matchesPermission(perm.Name, permissionName) {
 				return true
 			}
@@ -69,19 +93,20 @@ func (u *User) GetHighestRoleLevel() int {
 	minLevel := 5 // Default to lowest privilege
 
 	// Check global role level
-	if u.RoleModel != nil {
-		if u.RoleModel.Name == "super_admin" {
+	if role := u.EffectiveRole(); role != nil {
+		if role.Name == "super_admin" {
 			return 0 // Super Admin is level 0
 		}
 		// System Admin and other global roles are typically level 1
-		if u.RoleModel.IsGlobal {
+		if role.IsGlobal {
 			minLevel = 1
 		}
 	}
 
 	// Check business role levels
+	now := time.Now()
 	for _, ubr := range u.UserBusinessRoles {
-		if ubr.IsActive && ubr.BusinessRole.ID != uuid.Nil {
+		if ubr.IsEffectiveAt(now) && ubr.BusinessRole.ID != uuid.Nil {
 			if ubr.BusinessRole.Level < minLevel {
 				minLevel = ubr.BusinessRole.Level
 			}
@@ -95,21 +120,24 @@ func (u *User) GetHighestRoleLevel() int {
 func (u *User) GetAllPermissions() []string {
 	permissions := make(map[string]bool) // Use map to avoid duplicates
 
+	role := u.EffectiveRole()
+
 	// Check for Super Admin wildcard
-	if u.RoleModel != nil && u.RoleModel.Name == "super_admin" {
+	if role != nil && role.Name == "super_admin" {
 		return []string{"*:*:*"}
 	}
 
 	// Add global role permissions
-	if u.RoleModel != nil {
-		for _, perm := range u.RoleModel.Permissions {
+	if role != nil {
+		for _, perm := range role.Permissions {
 			permissions[perm.Name] = true
 		}
 	}
 
 	// Add business role permissions
+	now := time.Now()
 	for _, ubr := range u.UserBusinessRoles {
-		if ubr.IsActive && ubr.BusinessRole.ID != uuid.Nil {
+		if ubr.IsEffectiveAt(now) && ubr.BusinessRole.ID != uuid.Nil {
 			for _, perm := range ubr.BusinessRole.Permissions {
 				permissions[perm.Name] = true
 			}
@@ -128,13 +156,14 @@ func (u *User) GetAllPermissions() []string {
 // HasPermissionInVertical checks if user has permission in specific vertical
 func (u *User) HasPermissionInVertical(permission string, verticalID uuid.UUID) bool {
 	// Super Admin has all permissions in all verticals
-	if u.RoleModel != nil && u.RoleModel.Name == "super_admin" {
+	if role := u.EffectiveRole(); role != nil && role.Name == "super_admin" {
 		return true
 	}
 
 	// Check if user has role in this vertical with the required permission
+	now := time.Now()
 	for _, ubr := range u.UserBusinessRoles {
-		if ubr.IsActive &&
+		if ubr.IsEffectiveAt(now) &&
 			ubr.BusinessRole.ID != uuid.Nil &&
 			ubr.BusinessRole.BusinessVerticalID == verticalID {
 			// Check if this business role has the permission (supports wildcards)
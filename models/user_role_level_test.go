@@ -2,6 +2,7 @@ package models
 
 import (
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -54,3 +55,36 @@ func TestCanAssignRole_BusinessRoleBoundary(t *testing.T) {
 		t.Fatalf("expected level 2 business user to be blocked from assigning level 2 role")
 	}
 }
+
+func TestGetHighestRoleLevel_IgnoresExpiredGrants(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	u := User{
+		RoleModel:      &Role{Name: "super_admin", IsGlobal: true},
+		RoleValidUntil: &past,
+		UserBusinessRoles: []UserBusinessRole{{
+			IsActive:     true,
+			ValidUntil:   &past,
+			BusinessRole: BusinessRole{ID: uuid.New(), Level: 2},
+		}},
+	}
+
+	if got := u.GetHighestRoleLevel(); got != 5 {
+		t.Fatalf("expected expired grants to be ignored, got level %d", got)
+	}
+}
+
+func TestUserBusinessRole_IsEffectiveAt(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+	ubr := UserBusinessRole{IsActive: true, ValidFrom: &now, ValidUntil: &later}
+
+	if ubr.IsEffectiveAt(now.Add(-time.Minute)) {
+		t.Errorf("expected grant not yet effective before valid_from")
+	}
+	if !ubr.IsEffectiveAt(now.Add(time.Minute)) {
+		t.Errorf("expected grant effective inside window")
+	}
+	if ubr.IsEffectiveAt(later) {
+		t.Errorf("expected grant expired at valid_until")
+	}
+}
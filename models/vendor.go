@@ -0,0 +1,250 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	gstinPattern = regexp.MustCompile(`^[0-9]{2}[A-Z]{5}[0-9]{4}[A-Z][1-9A-Z]Z[0-9A-Z]$`)
+	panPattern   = regexp.MustCompile(`^[A-Z]{5}[0-9]{4}[A-Z]$`)
+	ifscPattern  = regexp.MustCompile(`^[A-Z]{4}0[A-Z0-9]{6}$`)
+)
+
+// Vendor is a supplier purchase orders are raised on. Categories name what it supplies
+// and ApplicableSiteIDs the sites it may supply; an empty list means every site.
+type Vendor struct {
+	ID                 uuid.UUID   `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID   `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	Code               string      `gorm:"size:64;not null" json:"code"`
+	Name               string      `gorm:"size:255;not null" json:"name"`
+	GSTIN              string      `gorm:"size:20" json:"gstin,omitempty"`
+	PAN                string      `gorm:"size:20" json:"pan,omitempty"`
+	Categories         StringArray `gorm:"type:jsonb;default:'[]'" json:"categories"`
+	ApplicableSiteIDs  StringArray `gorm:"type:jsonb;default:'[]'" json:"applicable_site_ids"`
+	ContactName        string      `gorm:"size:255" json:"contact_name,omitempty"`
+	Phone              string      `gorm:"size:32" json:"phone,omitempty"`
+	Email              string      `gorm:"size:255" json:"email,omitempty"`
+	Address            string      `gorm:"type:text" json:"address,omitempty"`
+	BankAccountName    string      `gorm:"size:255" json:"bank_account_name,omitempty"`
+	BankAccountNumber  string      `gorm:"size:34" json:"bank_account_number,omitempty"`
+	BankIFSC           string      `gorm:"size:11" json:"bank_ifsc,omitempty"`
+	BankName           string      `gorm:"size:255" json:"bank_name,omitempty"`
+	BankBranch         string      `gorm:"size:255" json:"bank_branch,omitempty"`
+	PaymentTerms       string      `gorm:"type:text" json:"payment_terms,omitempty"`
+	CreditDays         int         `gorm:"default:0" json:"credit_days"` // days its invoices fall due after; 0 for the business's default
+	IsActive           bool        `gorm:"default:true" json:"is_active"`
+	Remarks            string      `gorm:"type:text" json:"remarks,omitempty"`
+	CreatedBy          string      `gorm:"size:255;not null" json:"created_by"`
+	UpdatedBy          string      `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt          time.Time   `json:"created_at"`
+	UpdatedAt          time.Time   `json:"updated_at"`
+	DeletedAt          *time.Time  `gorm:"index" json:"deleted_at,omitempty"`
+}
+
+// TableName specifies the table name for Vendor
+func (Vendor) TableName() string {
+	return "vendors"
+}
+
+// Normalize trims the vendor's details and upper-cases its tax and bank codes
+func (v *Vendor) Normalize() {
+	v.Code = strings.TrimSpace(v.Code)
+	v.Name = strings.TrimSpace(v.Name)
+	v.GSTIN = strings.ToUpper(strings.TrimSpace(v.GSTIN))
+	v.PAN = strings.ToUpper(strings.TrimSpace(v.PAN))
+	v.BankIFSC = strings.ToUpper(strings.TrimSpace(v.BankIFSC))
+	v.BankAccountNumber = strings.ReplaceAll(strings.TrimSpace(v.BankAccountNumber), " ", "")
+	if v.Categories == nil {
+		v.Categories = StringArray{}
+	}
+	if v.ApplicableSiteIDs == nil {
+		v.ApplicableSiteIDs = StringArray{}
+	}
+}
+
+// Validate checks the vendor's required fields and the format of its GSTIN, PAN and
+// IFSC. A GSTIN embeds the holder's PAN, so the two must agree when both are given.
+func (v *Vendor) Validate() error {
+	switch {
+	case v.Code == "" || v.Name == "":
+		return fmt.Errorf("code and name are required")
+	case v.GSTIN != "" && !gstinPattern.MatchString(v.GSTIN):
+		return fmt.Errorf("gstin %q is not a valid GSTIN", v.GSTIN)
+	case v.PAN != "" && !panPattern.MatchString(v.PAN):
+		return fmt.Errorf("pan %q is not a valid PAN", v.PAN)
+	case v.GSTIN != "" && v.PAN != "" && v.GSTIN[2:12] != v.PAN:
+		return fmt.Errorf("the GSTIN does not belong to PAN %s", v.PAN)
+	case v.BankIFSC != "" && !ifscPattern.MatchString(v.BankIFSC):
+		return fmt.Errorf("bank_ifsc %q is not a valid IFSC", v.BankIFSC)
+	case v.BankAccountNumber != "" && v.BankIFSC == "":
+		return fmt.Errorf("bank_ifsc is required with a bank account number")
+	case v.CreditDays < 0 || v.CreditDays > 365:
+		return fmt.Errorf("credit_days must be between 0 and 365")
+	}
+	for _, id := range v.ApplicableSiteIDs {
+		if _, err := uuid.Parse(id); err != nil {
+			return fmt.Errorf("applicable site %q is not a valid id", id)
+		}
+	}
+	return nil
+}
+
+// AppliesToSite reports whether the vendor may supply the site
+func (v Vendor) AppliesToSite(siteID uuid.UUID) bool {
+	if len(v.ApplicableSiteIDs) == 0 {
+		return true
+	}
+	for _, id := range v.ApplicableSiteIDs {
+		if id == siteID.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// VendorDelivery is one goods receipt against a vendor's purchase order, as delivery
+// performance is worked out from
+type VendorDelivery struct {
+	PurchaseOrderID  uuid.UUID
+	DeliveryDate     *time.Time // promised on the order, if any
+	ReceivedDate     time.Time
+	ReceivedQuantity float64
+	RejectedQuantity float64
+	AcceptedValue    float64
+}
+
+// VendorPerformance summarises a vendor's deliveries. Receipts on orders without a
+// promised delivery date count as neither on time nor late.
+type VendorPerformance struct {
+	VendorID         uuid.UUID `json:"vendor_id"`
+	VendorCode       string    `json:"vendor_code,omitempty"`
+	VendorName       string    `json:"vendor_name,omitempty"`
+	Orders           int       `json:"orders"`
+	OrderedValue     float64   `json:"ordered_value"`
+	Receipts         int       `json:"receipts"`
+	OnTime           int       `json:"on_time"`
+	Late             int       `json:"late"`
+	Undated          int       `json:"undated"`
+	OnTimePercent    float64   `json:"on_time_percent"`
+	AverageDelayDays float64   `json:"average_delay_days"` // over late receipts
+	MaxDelayDays     int       `json:"max_delay_days"`
+	ReceivedQuantity float64   `json:"received_quantity"`
+	RejectedQuantity float64   `json:"rejected_quantity"`
+	RejectionPercent float64   `json:"rejection_percent"`
+	AcceptedValue    float64   `json:"accepted_value"`
+}
+
+// SummarizeVendorDeliveries works out delivery performance from a vendor's receipts
+func SummarizeVendorDeliveries(deliveries []VendorDelivery) VendorPerformance {
+	var p VendorPerformance
+	var delayDays int
+	for _, d := range deliveries {
+		p.Receipts++
+		p.ReceivedQuantity += d.ReceivedQuantity
+		p.RejectedQuantity += d.RejectedQuantity
+		p.AcceptedValue += d.AcceptedValue
+		if d.DeliveryDate == nil {
+			p.Undated++
+			continue
+		}
+		promised := d.DeliveryDate.Format("2006-01-02")
+		if d.ReceivedDate.Format("2006-01-02") <= promised {
+			p.OnTime++
+			continue
+		}
+		p.Late++
+		due, _ := time.Parse("2006-01-02", promised)
+		received, _ := time.Parse("2006-01-02", d.ReceivedDate.Format("2006-01-02"))
+		delay := int(received.Sub(due).Hours() / 24)
+		delayDays += delay
+		if delay > p.MaxDelayDays {
+			p.MaxDelayDays = delay
+		}
+	}
+	if dated := p.OnTime + p.Late; dated > 0 {
+		p.OnTimePercent = math.Round(float64(p.OnTime)*10000/float64(dated)) / 100
+	}
+	if p.Late > 0 {
+		p.AverageDelayDays = math.Round(float64(delayDays)*100/float64(p.Late)) / 100
+	}
+	if p.ReceivedQuantity > 0 {
+		p.RejectionPercent = math.Round(p.RejectedQuantity*10000/p.ReceivedQuantity) / 100
+	}
+	p.AcceptedValue = math.Round(p.AcceptedValue*100) / 100
+	return p
+}
+
+// VendorPricePoint is a rate a material was ordered at from a vendor
+type VendorPricePoint struct {
+	MaterialCode string    `json:"material_code"`
+	UOM          string    `json:"uom"`
+	VendorID     uuid.UUID `json:"vendor_id"`
+	VendorName   string    `json:"vendor_name"`
+	OrderNumber  string    `json:"order_number"`
+	OrderDate    time.Time `json:"order_date"`
+	Quantity     float64   `json:"quantity"`
+	Rate         float64   `json:"rate"`
+}
+
+// MaterialPriceSummary sums up the rates a material was ordered at: the range, the
+// quantity-weighted average and the latest, with the vendor who offered the lowest
+type MaterialPriceSummary struct {
+	MaterialCode     string    `json:"material_code"`
+	UOM              string    `json:"uom"`
+	Orders           int       `json:"orders"`
+	Quantity         float64   `json:"quantity"`
+	MinRate          float64   `json:"min_rate"`
+	MaxRate          float64   `json:"max_rate"`
+	WeightedAverage  float64   `json:"weighted_average_rate"`
+	LastRate         float64   `json:"last_rate"`
+	LastOrderDate    time.Time `json:"last_order_date"`
+	LowestVendorID   uuid.UUID `json:"lowest_vendor_id"`
+	LowestVendorName string    `json:"lowest_vendor_name"`
+}
+
+// SummarizePriceHistory sums up price points by material, in material code order
+func SummarizePriceHistory(points []VendorPricePoint) []MaterialPriceSummary {
+	byMaterial := map[string]*MaterialPriceSummary{}
+	values := map[string]float64{}
+	for _, p := range points {
+		key := p.MaterialCode + "\x00" + p.UOM
+		s, ok := byMaterial[key]
+		if !ok {
+			s = &MaterialPriceSummary{MaterialCode: p.MaterialCode, UOM: p.UOM, MinRate: p.Rate, MaxRate: p.Rate,
+				LowestVendorID: p.VendorID, LowestVendorName: p.VendorName}
+			byMaterial[key] = s
+		}
+		s.Orders++
+		s.Quantity += p.Quantity
+		values[key] += p.Quantity * p.Rate
+		if p.Rate < s.MinRate {
+			s.MinRate, s.LowestVendorID, s.LowestVendorName = p.Rate, p.VendorID, p.VendorName
+		}
+		s.MaxRate = math.Max(s.MaxRate, p.Rate)
+		if !p.OrderDate.Before(s.LastOrderDate) {
+			s.LastRate, s.LastOrderDate = p.Rate, p.OrderDate
+		}
+	}
+
+	summaries := make([]MaterialPriceSummary, 0, len(byMaterial))
+	for key, s := range byMaterial {
+		if s.Quantity > 0 {
+			s.WeightedAverage = math.Round(values[key]*100/s.Quantity) / 100
+		}
+		summaries = append(summaries, *s)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].MaterialCode != summaries[j].MaterialCode {
+			return summaries[i].MaterialCode < summaries[j].MaterialCode
+		}
+		return summaries[i].UOM < summaries[j].UOM
+	})
+	return summaries
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestVendorValidate(t *testing.T) {
+	valid := Vendor{Code: "V-001", Name: "Deccan Pipes", GSTIN: "36AABCD1234E1Z5", PAN: "AABCD1234E", BankAccountNumber: "1234567890", BankIFSC: "HDFC0001234"}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("valid vendor rejected: %v", err)
+	}
+
+	cases := map[string]func(v *Vendor){
+		"missing name":       func(v *Vendor) { v.Name = "" },
+		"malformed GSTIN":    func(v *Vendor) { v.GSTIN = "36AABCD1234E1X5" },
+		"malformed PAN":      func(v *Vendor) { v.PAN = "AABC1234E" },
+		"GSTIN of other PAN": func(v *Vendor) { v.PAN = "AABCX1234E" },
+		"malformed IFSC":     func(v *Vendor) { v.BankIFSC = "HDFC1001234" },
+		"account sans IFSC":  func(v *Vendor) { v.BankIFSC = "" },
+		"bad site id":        func(v *Vendor) { v.ApplicableSiteIDs = StringArray{"site-1"} },
+	}
+	for name, mutate := range cases {
+		v := valid
+		mutate(&v)
+		if err := v.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestVendorNormalizeAndAppliesToSite(t *testing.T) {
+	site, other := uuid.New(), uuid.New()
+	v := Vendor{Code: " V-001 ", GSTIN: " 36aabcd1234e1z5", BankAccountNumber: "1234 5678 90"}
+	v.Normalize()
+	if v.Code != "V-001" || v.GSTIN != "36AABCD1234E1Z5" || v.BankAccountNumber != "1234567890" {
+		t.Errorf("unexpected normalized vendor: %+v", v)
+	}
+	if !v.AppliesToSite(site) {
+		t.Error("a vendor without listed sites should supply every site")
+	}
+	v.ApplicableSiteIDs = StringArray{site.String()}
+	if !v.AppliesToSite(site) || v.AppliesToSite(other) {
+		t.Error("site applicability not honoured")
+	}
+}
+
+func TestSummarizeVendorDeliveries(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 0, 0, 0, 0, time.UTC) }
+	due := day(10)
+	p := SummarizeVendorDeliveries([]VendorDelivery{
+		{DeliveryDate: &due, ReceivedDate: day(10).Add(15 * time.Hour), ReceivedQuantity: 100, AcceptedValue: 1000},
+		{DeliveryDate: &due, ReceivedDate: day(13), ReceivedQuantity: 50, RejectedQuantity: 5, AcceptedValue: 450},
+		{DeliveryDate: &due, ReceivedDate: day(11), ReceivedQuantity: 50, RejectedQuantity: 5, AcceptedValue: 450},
+		{ReceivedDate: day(12), ReceivedQuantity: 0},
+	})
+	if p.Receipts != 4 || p.OnTime != 1 || p.Late != 2 || p.Undated != 1 {
+		t.Fatalf("unexpected counts: %+v", p)
+	}
+	if p.OnTimePercent != 33.33 || p.AverageDelayDays != 2 || p.MaxDelayDays != 3 {
+		t.Errorf("unexpected timeliness: %+v", p)
+	}
+	if p.RejectionPercent != 5 || p.AcceptedValue != 1900 {
+		t.Errorf("unexpected quality: %+v", p)
+	}
+}
+
+func TestSummarizePriceHistory(t *testing.T) {
+	cheap, dear := uuid.New(), uuid.New()
+	summaries := SummarizePriceHistory([]VendorPricePoint{
+		{MaterialCode: "PIPE", UOM: "m", VendorID: dear, VendorName: "Dear", OrderDate: time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC), Quantity: 100, Rate: 250},
+		{MaterialCode: "PIPE", UOM: "m", VendorID: cheap, VendorName: "Cheap", OrderDate: time.Date(2026, 8, 1, 0, 0, 0, 0, time.UTC), Quantity: 300, Rate: 230},
+		{MaterialCode: "CEMENT", UOM: "bag", VendorID: cheap, VendorName: "Cheap", OrderDate: time.Date(2026, 8, 1, 0, 0, 0, 0, time.UTC), Quantity: 10, Rate: 400},
+	})
+	if len(summaries) != 2 || summaries[0].MaterialCode != "CEMENT" {
+		t.Fatalf("unexpected summaries: %+v", summaries)
+	}
+	pipe := summaries[1]
+	if pipe.Orders != 2 || pipe.MinRate != 230 || pipe.MaxRate != 250 || pipe.WeightedAverage != 235 {
+		t.Errorf("unexpected pipe rates: %+v", pipe)
+	}
+	if pipe.LastRate != 250 || pipe.LowestVendorID != cheap {
+		t.Errorf("unexpected latest or lowest: %+v", pipe)
+	}
+}
@@ -0,0 +1,146 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultWaterLossThresholdPct is the share of a zone's supply, in percent, lost before
+// the zone raises an alert
+const DefaultWaterLossThresholdPct = 20.0
+
+// WaterZone is a metered distribution zone of a site: the bulk meters its supply comes in
+// through and the downstream meters its consumption is measured at, both flow meters
+// reporting their cumulative volume. What goes in and is not consumed is non-revenue
+// water, lost to leaks, theft or metering error.
+type WaterZone struct {
+	ID                  uuid.UUID   `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID  uuid.UUID   `gorm:"type:uuid;not null;uniqueIndex:idx_water_zones_code,priority:1" json:"business_vertical_id"`
+	SiteID              uuid.UUID   `gorm:"type:uuid;not null;index" json:"site_id"`
+	Code                string      `gorm:"size:50;not null;uniqueIndex:idx_water_zones_code,priority:2" json:"code"` // as sampling points name the zone
+	Name                string      `gorm:"size:255;not null" json:"name"`
+	SupplyMeterIDs      StringArray `gorm:"type:jsonb;default:'[]'" json:"supply_meter_ids"`
+	ConsumptionMeterIDs StringArray `gorm:"type:jsonb;default:'[]'" json:"consumption_meter_ids"`
+	LossThresholdPct    float64     `gorm:"type:decimal(5,2);not null;default:20" json:"loss_threshold_percent"`
+	MinSupplyM3         float64     `gorm:"column:min_supply_m3;type:decimal(12,3);not null;default:0" json:"min_supply_m3"` // below this a day's loss is not alerted
+	IsActive            bool        `gorm:"default:true" json:"is_active"`
+	UpdatedBy           string      `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt           time.Time   `json:"created_at"`
+	UpdatedAt           time.Time   `json:"updated_at"`
+
+	Site *Site `gorm:"foreignKey:SiteID" json:"site,omitempty"`
+}
+
+func (WaterZone) TableName() string {
+	return "water_zones"
+}
+
+// Validate checks the zone has supply meters and a usable threshold, and no meter counted
+// on both sides
+func (z WaterZone) Validate() error {
+	switch {
+	case z.Code == "" || z.Name == "":
+		return fmt.Errorf("code and name are required")
+	case len(z.SupplyMeterIDs) == 0:
+		return fmt.Errorf("at least one supply meter is required")
+	case z.LossThresholdPct <= 0 || z.LossThresholdPct > 100:
+		return fmt.Errorf("loss_threshold_percent must be within 0..100")
+	case z.MinSupplyM3 < 0:
+		return fmt.Errorf("min_supply_m3 must not be negative")
+	}
+	supply := map[string]bool{}
+	for _, id := range z.SupplyMeterIDs {
+		supply[id] = true
+	}
+	for _, id := range z.ConsumptionMeterIDs {
+		if supply[id] {
+			return fmt.Errorf("meter %s cannot be both a supply and a consumption meter", id)
+		}
+	}
+	return nil
+}
+
+// WaterZoneLoss is a zone's water balance for a day
+type WaterZoneLoss struct {
+	ID                 uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID  `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	SiteID             uuid.UUID  `gorm:"type:uuid;not null;index" json:"site_id"`
+	ZoneID             uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_water_zone_losses_zone_date,priority:1" json:"zone_id"`
+	LossDate           time.Time  `gorm:"type:date;not null;uniqueIndex:idx_water_zone_losses_zone_date,priority:2" json:"loss_date"`
+	SupplyM3           float64    `gorm:"column:supply_m3;type:decimal(14,3);not null" json:"supply_m3"`
+	ConsumptionM3      float64    `gorm:"column:consumption_m3;type:decimal(14,3);not null" json:"consumption_m3"`
+	LossM3             float64    `gorm:"column:loss_m3;type:decimal(14,3);not null" json:"loss_m3"`
+	LossPct            *float64   `gorm:"type:decimal(6,2)" json:"loss_percent,omitempty"`
+	ThresholdPct       float64    `gorm:"type:decimal(5,2);not null" json:"threshold_percent"`
+	Exceeded           bool       `gorm:"not null;default:false;index" json:"exceeded"`
+	MetersWithoutData  int        `gorm:"not null;default:0" json:"meters_without_data"` // the balance is incomplete when above 0
+	AlertedAt          *time.Time `json:"alerted_at,omitempty"`
+	ComputedAt         time.Time  `gorm:"not null" json:"computed_at"`
+
+	Zone *WaterZone `gorm:"foreignKey:ZoneID" json:"zone,omitempty"`
+}
+
+func (WaterZoneLoss) TableName() string {
+	return "water_zone_losses"
+}
+
+// NewWaterZoneLoss balances a zone's supply against its consumption for a day. The loss
+// exceeds the zone's threshold only on a complete balance of at least its minimum supply,
+// so a meter that did not report does not raise a false alert.
+func NewWaterZoneLoss(zone WaterZone, date time.Time, supplyM3, consumptionM3 float64, metersWithoutData int) WaterZoneLoss {
+	loss := WaterZoneLoss{
+		BusinessVerticalID: zone.BusinessVerticalID,
+		SiteID:             zone.SiteID,
+		ZoneID:             zone.ID,
+		LossDate:           date,
+		SupplyM3:           math.Round(supplyM3*1000) / 1000,
+		ConsumptionM3:      math.Round(consumptionM3*1000) / 1000,
+		LossM3:             math.Round((supplyM3-consumptionM3)*1000) / 1000,
+		ThresholdPct:       zone.LossThresholdPct,
+		MetersWithoutData:  metersWithoutData,
+	}
+	if supplyM3 > 0 {
+		pct := math.Round(loss.LossM3/supplyM3*10000) / 100
+		loss.LossPct = &pct
+		loss.Exceeded = metersWithoutData == 0 && supplyM3 >= zone.MinSupplyM3 && pct > zone.LossThresholdPct
+	}
+	return loss
+}
+
+// WaterLossTrendPoint sums a zone's daily balances over a period
+type WaterLossTrendPoint struct {
+	Period         string   `json:"period"` // YYYY-MM-DD, YYYY-MM for monthly trends
+	Days           int      `json:"days"`
+	SupplyM3       float64  `json:"supply_m3"`
+	ConsumptionM3  float64  `json:"consumption_m3"`
+	LossM3         float64  `json:"loss_m3"`
+	LossPct        *float64 `json:"loss_percent,omitempty"`
+	DaysExceeded   int      `json:"days_exceeded"`
+	IncompleteDays int      `json:"incomplete_days"`
+}
+
+// SummarizeWaterLoss sums balances already grouped under one period
+func SummarizeWaterLoss(period string, losses []WaterZoneLoss) WaterLossTrendPoint {
+	point := WaterLossTrendPoint{Period: period, Days: len(losses)}
+	for _, l := range losses {
+		point.SupplyM3 += l.SupplyM3
+		point.ConsumptionM3 += l.ConsumptionM3
+		if l.Exceeded {
+			point.DaysExceeded++
+		}
+		if l.MetersWithoutData > 0 {
+			point.IncompleteDays++
+		}
+	}
+	point.SupplyM3 = math.Round(point.SupplyM3*1000) / 1000
+	point.ConsumptionM3 = math.Round(point.ConsumptionM3*1000) / 1000
+	point.LossM3 = math.Round((point.SupplyM3-point.ConsumptionM3)*1000) / 1000
+	if point.SupplyM3 > 0 {
+		pct := math.Round(point.LossM3/point.SupplyM3*10000) / 100
+		point.LossPct = &pct
+	}
+	return point
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestWaterZoneValidate(t *testing.T) {
+	meter := uuid.NewString()
+	zone := WaterZone{Code: "Z1", Name: "Ward 1", SupplyMeterIDs: StringArray{meter}, LossThresholdPct: DefaultWaterLossThresholdPct}
+	if err := zone.Validate(); err != nil {
+		t.Fatalf("valid zone rejected: %v", err)
+	}
+
+	both := zone
+	both.ConsumptionMeterIDs = StringArray{uuid.NewString(), meter}
+	noSupply := zone
+	noSupply.SupplyMeterIDs = nil
+	badThreshold := zone
+	badThreshold.LossThresholdPct = 0
+	for name, bad := range map[string]WaterZone{"meter on both sides": both, "no supply meter": noSupply, "no threshold": badThreshold} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+}
+
+func TestNewWaterZoneLoss(t *testing.T) {
+	zone := WaterZone{ID: uuid.New(), LossThresholdPct: 20, MinSupplyM3: 100}
+	day := time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+
+	loss := NewWaterZoneLoss(zone, day, 1000, 750, 0)
+	if loss.LossM3 != 250 || loss.LossPct == nil || *loss.LossPct != 25 || !loss.Exceeded {
+		t.Errorf("loss = %v m3, %v%%, exceeded %v; want 250 m3, 25%%, exceeded", loss.LossM3, loss.LossPct, loss.Exceeded)
+	}
+	if NewWaterZoneLoss(zone, day, 1000, 850, 0).Exceeded {
+		t.Error("15% loss exceeded a 20% threshold")
+	}
+	if NewWaterZoneLoss(zone, day, 1000, 500, 1).Exceeded {
+		t.Error("incomplete balance raised an alert")
+	}
+	if NewWaterZoneLoss(zone, day, 50, 10, 0).Exceeded {
+		t.Error("supply below the minimum raised an alert")
+	}
+	if l := NewWaterZoneLoss(zone, day, 0, 0, 0); l.LossPct != nil || l.Exceeded {
+		t.Errorf("no supply gave a loss of %v%%", *l.LossPct)
+	}
+
+	point := SummarizeWaterLoss("2026-10", []WaterZoneLoss{
+		loss,
+		NewWaterZoneLoss(zone, day.AddDate(0, 0, 1), 1000, 950, 0),
+		NewWaterZoneLoss(zone, day.AddDate(0, 0, 2), 0, 0, 2),
+	})
+	if point.Days != 3 || point.LossM3 != 300 || point.LossPct == nil || *point.LossPct != 15 ||
+		point.DaysExceeded != 1 || point.IncompleteDays != 1 {
+		t.Errorf("unexpected trend point %+v", point)
+	}
+}
@@ -0,0 +1,242 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Sources of a pump's daily log
+const (
+	PumpLogManual = "manual"
+	PumpLogDevice = "device"
+)
+
+// DefaultPumpDegradationPct is how much worse, in percent, a pump's specific energy must
+// get before it counts as degrading
+const DefaultPumpDegradationPct = 10.0
+
+// WaterPump is the operating configuration of a pump in the asset register: the field
+// devices reporting its operation, its ratings and the daily windows it is scheduled to
+// run in, as "HH:MM-HH:MM" in local time
+type WaterPump struct {
+	ID                  uuid.UUID   `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID  uuid.UUID   `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	AssetID             uuid.UUID   `gorm:"type:uuid;not null;uniqueIndex" json:"asset_id"`
+	SiteID              uuid.UUID   `gorm:"type:uuid;not null;index" json:"site_id"`
+	PumpDeviceID        *uuid.UUID  `gorm:"type:uuid" json:"pump_device_id,omitempty"`         // reports running state
+	EnergyMeterDeviceID *uuid.UUID  `gorm:"type:uuid" json:"energy_meter_device_id,omitempty"` // reports cumulative kWh
+	FlowMeterDeviceID   *uuid.UUID  `gorm:"type:uuid" json:"flow_meter_device_id,omitempty"`   // reports cumulative m3
+	RatedPowerKW        float64     `gorm:"column:rated_power_kw;type:decimal(10,2);not null;default:0" json:"rated_power_kw"`
+	RatedFlowM3H        float64     `gorm:"column:rated_flow_m3h;type:decimal(10,2);not null;default:0" json:"rated_flow_m3h"`
+	ScheduleWindows     StringArray `gorm:"type:jsonb;default:'[]'" json:"schedule_windows"`
+	UpdatedBy           string      `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt           time.Time   `json:"created_at"`
+	UpdatedAt           time.Time   `json:"updated_at"`
+
+	Asset *Asset `gorm:"foreignKey:AssetID" json:"asset,omitempty"`
+}
+
+func (WaterPump) TableName() string {
+	return "water_pumps"
+}
+
+// ScheduledHours returns how many hours a day the pump is scheduled to run, or an error
+// naming a malformed or overlapping window. A window may run past midnight.
+func (p WaterPump) ScheduledHours() (float64, error) {
+	type span struct{ from, to int }
+	var spans []span
+	var minutes int
+	for _, window := range p.ScheduleWindows {
+		parts := strings.Split(strings.TrimSpace(window), "-")
+		if len(parts) != 2 {
+			return 0, fmt.Errorf("schedule window %q must be HH:MM-HH:MM", window)
+		}
+		var bounds [2]int
+		for i, part := range parts {
+			t, err := time.Parse("15:04", strings.TrimSpace(part))
+			if err != nil {
+				return 0, fmt.Errorf("schedule window %q must be HH:MM-HH:MM", window)
+			}
+			bounds[i] = t.Hour()*60 + t.Minute()
+		}
+		if bounds[0] == bounds[1] {
+			return 0, fmt.Errorf("schedule window %q is empty", window)
+		}
+		if bounds[1] < bounds[0] {
+			spans = append(spans, span{bounds[0], 24 * 60}, span{0, bounds[1]})
+		} else {
+			spans = append(spans, span{bounds[0], bounds[1]})
+		}
+	}
+	for i, a := range spans {
+		for _, b := range spans[i+1:] {
+			if a.from < b.to && b.from < a.to {
+				return 0, fmt.Errorf("schedule windows overlap")
+			}
+		}
+		minutes += a.to - a.from
+	}
+	return float64(minutes) / 60, nil
+}
+
+// PumpDailyLog is how long a pump ran on a day and the energy and water it used. A manual
+// entry takes precedence over figures worked out from the pump's devices.
+type PumpDailyLog struct {
+	ID                 uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	SiteID             uuid.UUID `gorm:"type:uuid;not null;index" json:"site_id"`
+	AssetID            uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_pump_daily_logs_asset_date,priority:1" json:"asset_id"`
+	LogDate            time.Time `gorm:"type:date;not null;uniqueIndex:idx_pump_daily_logs_asset_date,priority:2" json:"log_date"`
+	RunHours           float64   `gorm:"type:decimal(6,2);not null" json:"run_hours"`
+	EnergyKWh          float64   `gorm:"column:energy_kwh;type:decimal(12,3);not null;default:0" json:"energy_kwh"`
+	VolumeM3           *float64  `gorm:"column:volume_m3;type:decimal(14,3)" json:"volume_m3,omitempty"`
+	Source             string    `gorm:"size:10;not null" json:"source"`
+	Remarks            string    `gorm:"type:text" json:"remarks,omitempty"`
+	RecordedBy         string    `gorm:"size:255" json:"recorded_by,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+func (PumpDailyLog) TableName() string {
+	return "pump_daily_logs"
+}
+
+// Validate checks the day's figures are possible for one pump
+func (l PumpDailyLog) Validate() error {
+	switch {
+	case l.RunHours < 0 || l.RunHours > 24:
+		return fmt.Errorf("run_hours must be within 0..24")
+	case l.EnergyKWh < 0:
+		return fmt.Errorf("energy_kwh must not be negative")
+	case l.VolumeM3 != nil && *l.VolumeM3 < 0:
+		return fmt.Errorf("volume_m3 must not be negative")
+	}
+	return nil
+}
+
+// PumpReading is one timestamped value from a pump's device
+type PumpReading struct {
+	At    time.Time
+	Value float64
+}
+
+// PumpRunHours works out from a pump's running-state readings over [from, to) how long it
+// ran: from each reading that it was running until the next, for at most maxGap, so an
+// outage of the device does not count as running
+func PumpRunHours(readings []PumpReading, from, to time.Time, maxGap time.Duration) float64 {
+	var run time.Duration
+	for i, r := range readings {
+		if r.Value < 0.5 {
+			continue
+		}
+		end := to
+		if i+1 < len(readings) {
+			end = readings[i+1].At
+		}
+		if end.Sub(r.At) > maxGap {
+			end = r.At.Add(maxGap)
+		}
+		start := r.At
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			run += end.Sub(start)
+		}
+	}
+	return math.Round(run.Hours()*100) / 100
+}
+
+// RegisterDelta returns how far a cumulative register advanced over the readings, ignoring
+// resets where it went backwards
+func RegisterDelta(readings []PumpReading) float64 {
+	var delta float64
+	for i := 1; i < len(readings); i++ {
+		if d := readings[i].Value - readings[i-1].Value; d > 0 {
+			delta += d
+		}
+	}
+	return math.Round(delta*1000) / 1000
+}
+
+// PumpEfficiencyPoint sums a pump's logs over one period
+type PumpEfficiencyPoint struct {
+	Period         string   `json:"period"`
+	RunHours       float64  `json:"run_hours"`
+	EnergyKWh      float64  `json:"energy_kwh"`
+	VolumeM3       float64  `json:"volume_m3"`
+	AveragePowerKW *float64 `json:"average_power_kw,omitempty"`
+	FlowM3H        *float64 `json:"flow_m3h,omitempty"`
+	SpecificEnergy *float64 `json:"specific_energy_kwh_m3,omitempty"` // over days the volume was logged
+	LoadPct        *float64 `json:"load_percent,omitempty"`           // average power over the rated power
+
+	meteredEnergy float64
+}
+
+// Add adds a day's log to the period
+func (p *PumpEfficiencyPoint) Add(l PumpDailyLog) {
+	p.RunHours += l.RunHours
+	p.EnergyKWh += l.EnergyKWh
+	if l.VolumeM3 != nil {
+		p.VolumeM3 += *l.VolumeM3
+		p.meteredEnergy += l.EnergyKWh
+	}
+}
+
+// Finish rounds the period's sums and derives its figures for a pump of the rated power
+func (p *PumpEfficiencyPoint) Finish(ratedPowerKW float64) {
+	ratio := func(num, den float64, places int) *float64 {
+		if den <= 0 || num <= 0 {
+			return nil
+		}
+		scale := math.Pow(10, float64(places))
+		v := math.Round(num/den*scale) / scale
+		return &v
+	}
+	p.AveragePowerKW = ratio(p.EnergyKWh, p.RunHours, 2)
+	p.FlowM3H = ratio(p.VolumeM3, p.RunHours, 2)
+	p.SpecificEnergy = ratio(p.meteredEnergy, p.VolumeM3, 4)
+	if p.AveragePowerKW != nil {
+		p.LoadPct = ratio(*p.AveragePowerKW*100, ratedPowerKW, 1)
+	}
+	p.RunHours = math.Round(p.RunHours*100) / 100
+	p.EnergyKWh = math.Round(p.EnergyKWh*1000) / 1000
+	p.VolumeM3 = math.Round(p.VolumeM3*1000) / 1000
+}
+
+// PumpDegradation compares a pump's specific energy at the end of a trend with the start
+// of it: the first and last thirds of the periods with a specific energy, averaged. It
+// returns the change in percent and whether it exceeds thresholdPct, or nil with fewer
+// than three such periods.
+func PumpDegradation(trend []PumpEfficiencyPoint, thresholdPct float64) (*float64, bool) {
+	var values []float64
+	for _, p := range trend {
+		if p.SpecificEnergy != nil {
+			values = append(values, *p.SpecificEnergy)
+		}
+	}
+	if len(values) < 3 {
+		return nil, false
+	}
+	n := len(values) / 3
+	average := func(vs []float64) float64 {
+		var sum float64
+		for _, v := range vs {
+			sum += v
+		}
+		return sum / float64(len(vs))
+	}
+	baseline, recent := average(values[:n]), average(values[len(values)-n:])
+	if baseline <= 0 {
+		return nil, false
+	}
+	change := math.Round((recent-baseline)/baseline*10000) / 100
+	return &change, change > thresholdPct
+}
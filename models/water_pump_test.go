@@ -0,0 +1,92 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWaterPumpScheduledHours(t *testing.T) {
+	pump := WaterPump{ScheduleWindows: StringArray{"05:30-09:00", "17:00-20:30", "23:00-01:00"}}
+	hours, err := pump.ScheduledHours()
+	if err != nil {
+		t.Fatalf("ScheduledHours: %v", err)
+	}
+	if hours != 9 {
+		t.Errorf("scheduled hours = %v, want 9", hours)
+	}
+
+	for _, bad := range []StringArray{
+		{"0600-0900"},
+		{"06:00-06:00"},
+		{"06:00-09:00", "08:00-10:00"},
+		{"22:00-02:00", "01:00-03:00"},
+	} {
+		if _, err := (WaterPump{ScheduleWindows: bad}).ScheduledHours(); err == nil {
+			t.Errorf("schedule %v accepted", bad)
+		}
+	}
+}
+
+func TestPumpRunHours(t *testing.T) {
+	day := time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+	at := func(h, m int) time.Time { return day.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute) }
+	readings := []PumpReading{
+		{At: day.Add(-10 * time.Minute), Value: 1}, // running from before midnight
+		{At: at(0, 5), Value: 1},
+		{At: at(0, 20), Value: 0},
+		{At: at(6, 0), Value: 1},
+		{At: at(6, 15), Value: 1},
+		{At: at(9, 0), Value: 0}, // device silent while running: only 30 minutes count
+		{At: at(23, 30), Value: 1},
+	}
+	got := PumpRunHours(readings, day, day.AddDate(0, 0, 1), 30*time.Minute)
+	// 00:00-00:20, 06:00-06:45, 23:30-24:00
+	if got != 1.58 {
+		t.Errorf("run hours = %v, want 1.58", got)
+	}
+}
+
+func TestRegisterDelta(t *testing.T) {
+	readings := []PumpReading{{Value: 1200}, {Value: 1210.5}, {Value: 3}, {Value: 9}}
+	if got := RegisterDelta(readings); got != 16.5 {
+		t.Errorf("delta = %v, want 16.5 across the reset", got)
+	}
+	if got := RegisterDelta(readings[:1]); got != 0 {
+		t.Errorf("delta of one reading = %v", got)
+	}
+}
+
+func TestPumpEfficiency(t *testing.T) {
+	volume := func(v float64) *float64 { return &v }
+	var point PumpEfficiencyPoint
+	point.Add(PumpDailyLog{RunHours: 10, EnergyKWh: 150, VolumeM3: volume(500)})
+	point.Add(PumpDailyLog{RunHours: 2, EnergyKWh: 30}) // no flow meter reading
+	point.Finish(15)
+	if point.AveragePowerKW == nil || *point.AveragePowerKW != 15 {
+		t.Errorf("average power = %v, want 15", point.AveragePowerKW)
+	}
+	if point.SpecificEnergy == nil || *point.SpecificEnergy != 0.3 {
+		t.Errorf("specific energy = %v, want 0.3 over the metered day", point.SpecificEnergy)
+	}
+	if point.LoadPct == nil || *point.LoadPct != 100 {
+		t.Errorf("load = %v, want 100", point.LoadPct)
+	}
+
+	trend := func(values ...float64) []PumpEfficiencyPoint {
+		points := make([]PumpEfficiencyPoint, len(values))
+		for i := range values {
+			points[i].SpecificEnergy = &values[i]
+		}
+		return points
+	}
+	change, degrading := PumpDegradation(trend(0.30, 0.31, 0.30, 0.33, 0.35, 0.36), DefaultPumpDegradationPct)
+	if change == nil || *change != 16.39 || !degrading {
+		t.Errorf("change = %v, degrading = %v, want 16.39 and degrading", change, degrading)
+	}
+	if _, degrading := PumpDegradation(trend(0.30, 0.30, 0.31), DefaultPumpDegradationPct); degrading {
+		t.Error("steady pump flagged as degrading")
+	}
+	if change, _ := PumpDegradation(trend(0.30, 0.40), DefaultPumpDegradationPct); change != nil {
+		t.Errorf("change from two periods = %v, want none", *change)
+	}
+}
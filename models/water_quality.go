@@ -0,0 +1,231 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Water quality parameter categories
+const (
+	WaterParameterPhysical        = "physical"
+	WaterParameterChemical        = "chemical"
+	WaterParameterBacteriological = "bacteriological"
+)
+
+// Water sampling point types, from source to tap
+const (
+	WaterPointSource         = "source"
+	WaterPointTreatmentPlant = "treatment_plant"
+	WaterPointReservoir      = "reservoir"
+	WaterPointDistribution   = "distribution"
+	WaterPointConsumerTap    = "consumer_tap"
+)
+
+// Non-conformance statuses of a water quality test
+const (
+	WaterNonConformanceOpen   = "open"
+	WaterNonConformanceClosed = "closed"
+)
+
+// ValidWaterPointType reports whether t is a known sampling point type
+func ValidWaterPointType(t string) bool {
+	switch t {
+	case WaterPointSource, WaterPointTreatmentPlant, WaterPointReservoir, WaterPointDistribution, WaterPointConsumerTap:
+		return true
+	}
+	return false
+}
+
+// WaterQualityParameter is something a business tests water for, with the limits a
+// result must fall within. Either limit may be open.
+type WaterQualityParameter struct {
+	ID                 uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_water_quality_parameters_code,priority:1" json:"business_vertical_id"`
+	Code               string    `gorm:"size:50;not null;uniqueIndex:idx_water_quality_parameters_code,priority:2" json:"code"`
+	Name               string    `gorm:"size:255;not null" json:"name"`
+	Category           string    `gorm:"size:20;not null" json:"category"`
+	Unit               string    `gorm:"size:30" json:"unit,omitempty"`
+	MinLimit           *float64  `gorm:"type:decimal(12,4)" json:"min_limit,omitempty"`
+	MaxLimit           *float64  `gorm:"type:decimal(12,4)" json:"max_limit,omitempty"`
+	IsActive           bool      `gorm:"default:true" json:"is_active"`
+	UpdatedBy          string    `gorm:"size:255" json:"updated_by,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+func (WaterQualityParameter) TableName() string {
+	return "water_quality_parameters"
+}
+
+// Validate checks the parameter's code, category and limits
+func (p WaterQualityParameter) Validate() error {
+	switch {
+	case p.Code == "" || p.Name == "":
+		return fmt.Errorf("code and name are required")
+	case p.Category != WaterParameterPhysical && p.Category != WaterParameterChemical && p.Category != WaterParameterBacteriological:
+		return fmt.Errorf("category must be physical, chemical or bacteriological")
+	case p.MinLimit == nil && p.MaxLimit == nil:
+		return fmt.Errorf("at least one of min_limit and max_limit is required")
+	case p.MinLimit != nil && p.MaxLimit != nil && *p.MinLimit > *p.MaxLimit:
+		return fmt.Errorf("min_limit must not exceed max_limit")
+	}
+	return nil
+}
+
+// Within reports whether a result falls within the parameter's limits
+func (p WaterQualityParameter) Within(value float64) bool {
+	return (p.MinLimit == nil || value >= *p.MinLimit) && (p.MaxLimit == nil || value <= *p.MaxLimit)
+}
+
+// Limits describes the parameter's limits, such as "6.5–8.5" or "≤ 1 NTU"
+func (p WaterQualityParameter) Limits() string {
+	format := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	var limits string
+	switch {
+	case p.MinLimit != nil && p.MaxLimit != nil:
+		limits = format(*p.MinLimit) + "–" + format(*p.MaxLimit)
+	case p.MinLimit != nil:
+		limits = "≥ " + format(*p.MinLimit)
+	case p.MaxLimit != nil:
+		limits = "≤ " + format(*p.MaxLimit)
+	}
+	if p.Unit != "" {
+		limits += " " + p.Unit
+	}
+	return limits
+}
+
+// DefaultWaterQualityParameters are the drinking water parameters a water business starts
+// with, at the acceptable limits of IS 10500
+func DefaultWaterQualityParameters(businessID uuid.UUID) []WaterQualityParameter {
+	limit := func(v float64) *float64 { return &v }
+	return []WaterQualityParameter{
+		{BusinessVerticalID: businessID, Code: "ph", Name: "pH", Category: WaterParameterChemical, MinLimit: limit(6.5), MaxLimit: limit(8.5), IsActive: true},
+		{BusinessVerticalID: businessID, Code: "turbidity", Name: "Turbidity", Category: WaterParameterPhysical, Unit: "NTU", MaxLimit: limit(1), IsActive: true},
+		{BusinessVerticalID: businessID, Code: "free_residual_chlorine", Name: "Free residual chlorine", Category: WaterParameterChemical, Unit: "mg/L", MinLimit: limit(0.2), MaxLimit: limit(1), IsActive: true},
+		{BusinessVerticalID: businessID, Code: "tds", Name: "Total dissolved solids", Category: WaterParameterChemical, Unit: "mg/L", MaxLimit: limit(500), IsActive: true},
+		{BusinessVerticalID: businessID, Code: "total_coliform", Name: "Total coliform", Category: WaterParameterBacteriological, Unit: "MPN/100 mL", MaxLimit: limit(0), IsActive: true},
+		{BusinessVerticalID: businessID, Code: "e_coli", Name: "E. coli", Category: WaterParameterBacteriological, Unit: "MPN/100 mL", MaxLimit: limit(0), IsActive: true},
+	}
+}
+
+// WaterSamplingPoint is a registered place water is sampled at, in a zone of a site
+type WaterSamplingPoint struct {
+	ID                 uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_water_sampling_points_code,priority:1" json:"business_vertical_id"`
+	SiteID             uuid.UUID `gorm:"type:uuid;not null;index" json:"site_id"`
+	Zone               string    `gorm:"size:100;index" json:"zone,omitempty"` // distribution zone or ward within the site
+	Code               string    `gorm:"size:50;not null;uniqueIndex:idx_water_sampling_points_code,priority:2" json:"code"`
+	Name               string    `gorm:"size:255;not null" json:"name"`
+	PointType          string    `gorm:"size:30;not null" json:"point_type"`
+	Latitude           *float64  `gorm:"type:decimal(10,8)" json:"latitude,omitempty"`
+	Longitude          *float64  `gorm:"type:decimal(11,8)" json:"longitude,omitempty"`
+	IsActive           bool      `gorm:"default:true" json:"is_active"`
+	CreatedBy          string    `gorm:"size:255" json:"created_by,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+
+	Site *Site `gorm:"foreignKey:SiteID" json:"site,omitempty"`
+}
+
+func (WaterSamplingPoint) TableName() string {
+	return "water_sampling_points"
+}
+
+// WaterQualityTest is the testing of one sample from a sampling point. It is
+// non-conforming when any result falls outside its limits, and the non-conformance stays
+// open until the corrective action is recorded.
+type WaterQualityTest struct {
+	ID                   uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	BusinessVerticalID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"business_vertical_id"`
+	SiteID               uuid.UUID  `gorm:"type:uuid;not null;index" json:"site_id"`
+	SamplingPointID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"sampling_point_id"`
+	SampledAt            time.Time  `gorm:"not null;index" json:"sampled_at"`
+	SampledBy            string     `gorm:"size:255" json:"sampled_by,omitempty"`
+	Laboratory           string     `gorm:"size:255" json:"laboratory,omitempty"`
+	Remarks              string     `gorm:"type:text" json:"remarks,omitempty"`
+	NonConforming        bool       `gorm:"not null;default:false;index" json:"non_conforming"`
+	NonConformanceStatus string     `gorm:"size:20" json:"non_conformance_status,omitempty"`
+	CorrectiveAction     string     `gorm:"type:text" json:"corrective_action,omitempty"`
+	ClosedAt             *time.Time `json:"closed_at,omitempty"`
+	ClosedBy             string     `gorm:"size:255" json:"closed_by,omitempty"`
+	RecordedBy           string     `gorm:"size:255;not null" json:"recorded_by"`
+	CreatedAt            time.Time  `json:"created_at"`
+	UpdatedAt            time.Time  `json:"updated_at"`
+
+	SamplingPoint *WaterSamplingPoint  `gorm:"foreignKey:SamplingPointID" json:"sampling_point,omitempty"`
+	Results       []WaterQualityResult `gorm:"foreignKey:TestID" json:"results,omitempty"`
+}
+
+func (WaterQualityTest) TableName() string {
+	return "water_quality_tests"
+}
+
+// WaterQualityResult is one parameter's result in a test, with the limits in force when
+// it was recorded
+type WaterQualityResult struct {
+	ID            uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	TestID        uuid.UUID `gorm:"type:uuid;not null;index" json:"test_id"`
+	ParameterID   uuid.UUID `gorm:"type:uuid;not null;index" json:"parameter_id"`
+	ParameterCode string    `gorm:"size:50;not null" json:"parameter_code"`
+	ParameterName string    `gorm:"size:255;not null" json:"parameter_name"`
+	Value         float64   `gorm:"type:decimal(12,4);not null" json:"value"`
+	Unit          string    `gorm:"size:30" json:"unit,omitempty"`
+	MinLimit      *float64  `gorm:"type:decimal(12,4)" json:"min_limit,omitempty"`
+	MaxLimit      *float64  `gorm:"type:decimal(12,4)" json:"max_limit,omitempty"`
+	WithinLimits  bool      `gorm:"not null" json:"within_limits"`
+}
+
+func (WaterQualityResult) TableName() string {
+	return "water_quality_results"
+}
+
+// NewWaterQualityResult records value against the parameter and its current limits
+func NewWaterQualityResult(p WaterQualityParameter, value float64) WaterQualityResult {
+	return WaterQualityResult{
+		ParameterID:   p.ID,
+		ParameterCode: p.Code,
+		ParameterName: p.Name,
+		Value:         value,
+		Unit:          p.Unit,
+		MinLimit:      p.MinLimit,
+		MaxLimit:      p.MaxLimit,
+		WithinLimits:  p.Within(value),
+	}
+}
+
+// WaterQualityTrendPoint sums one parameter's results at a sampling point over a period
+type WaterQualityTrendPoint struct {
+	Period        string  `json:"period"` // YYYY-MM-DD, YYYY-MM for monthly trends
+	Samples       int     `json:"samples"`
+	Min           float64 `json:"min"`
+	Max           float64 `json:"max"`
+	Average       float64 `json:"average"`
+	Exceedances   int     `json:"exceedances"`
+	CompliancePct float64 `json:"compliance_percent"`
+}
+
+// SummarizeWaterQuality sums results already grouped under one period
+func SummarizeWaterQuality(period string, results []WaterQualityResult) WaterQualityTrendPoint {
+	point := WaterQualityTrendPoint{Period: period, Samples: len(results)}
+	if len(results) == 0 {
+		return point
+	}
+	point.Min, point.Max = math.Inf(1), math.Inf(-1)
+	var sum float64
+	for _, r := range results {
+		point.Min = math.Min(point.Min, r.Value)
+		point.Max = math.Max(point.Max, r.Value)
+		sum += r.Value
+		if !r.WithinLimits {
+			point.Exceedances++
+		}
+	}
+	point.Average = math.Round(sum/float64(len(results))*10000) / 10000
+	point.CompliancePct = math.Round(float64(len(results)-point.Exceedances)*10000/float64(len(results))) / 100
+	return point
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestWaterQualityParameterLimits(t *testing.T) {
+	parameters := map[string]WaterQualityParameter{}
+	for _, p := range DefaultWaterQualityParameters(uuid.New()) {
+		if err := p.Validate(); err != nil {
+			t.Fatalf("default parameter %s is invalid: %v", p.Code, err)
+		}
+		parameters[p.Code] = p
+	}
+
+	tests := []struct {
+		code  string
+		value float64
+		want  bool
+	}{
+		{"ph", 7.2, true},
+		{"ph", 6.5, true},
+		{"ph", 9.1, false},
+		{"turbidity", 0.8, true},
+		{"turbidity", 4, false},
+		{"free_residual_chlorine", 0.1, false},
+		{"e_coli", 0, true},
+		{"e_coli", 2, false},
+	}
+	for _, tt := range tests {
+		if got := parameters[tt.code].Within(tt.value); got != tt.want {
+			t.Errorf("%s %v within limits = %v, want %v", tt.code, tt.value, got, tt.want)
+		}
+	}
+
+	if got := parameters["ph"].Limits(); got != "6.5–8.5" {
+		t.Errorf("pH limits = %q", got)
+	}
+	if got := parameters["turbidity"].Limits(); got != "≤ 1 NTU" {
+		t.Errorf("turbidity limits = %q", got)
+	}
+}
+
+func TestWaterQualityParameterValidate(t *testing.T) {
+	low, high := 8.0, 6.0
+	for _, bad := range []WaterQualityParameter{
+		{Code: "x", Name: "X", Category: WaterParameterChemical},
+		{Code: "x", Name: "X", Category: "radiological", MaxLimit: &high},
+		{Code: "x", Name: "X", Category: WaterParameterChemical, MinLimit: &low, MaxLimit: &high},
+	} {
+		if bad.Validate() == nil {
+			t.Errorf("invalid parameter accepted: %+v", bad)
+		}
+	}
+}
+
+func TestSummarizeWaterQuality(t *testing.T) {
+	limit := 1.0
+	turbidity := WaterQualityParameter{Code: "turbidity", Name: "Turbidity", MaxLimit: &limit}
+	results := []WaterQualityResult{
+		NewWaterQualityResult(turbidity, 0.4),
+		NewWaterQualityResult(turbidity, 0.6),
+		NewWaterQualityResult(turbidity, 2.0),
+		NewWaterQualityResult(turbidity, 0.2),
+	}
+	p := SummarizeWaterQuality("2026-10", results)
+	if p.Samples != 4 || p.Min != 0.2 || p.Max != 2 || p.Average != 0.8 || p.Exceedances != 1 || p.CompliancePct != 75 {
+		t.Errorf("unexpected summary: %+v", p)
+	}
+	if empty := SummarizeWaterQuality("2026-11", nil); empty.Samples != 0 || empty.Min != 0 {
+		t.Errorf("unexpected empty summary: %+v", empty)
+	}
+}
@@ -17,6 +17,8 @@ const (
 	EventCreate        WebhookEventType = "CREATE"
 	EventUpdate        WebhookEventType = "UPDATE"
 	EventFormSubmitted WebhookEventType = "form.submitted"
+	// EventWorkflowTransition is sent by call_webhook transition actions without a URL
+	EventWorkflowTransition WebhookEventType = "workflow.transition"
 )
 
 // WebhookStatus represents the status of a webhook subscription
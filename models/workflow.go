@@ -3,6 +3,10 @@ package models
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -21,6 +25,10 @@ type WorkflowDefinition struct {
 	States      json.RawMessage `gorm:"type:jsonb;not null;default:'[]'" json:"states"`
 	Transitions json.RawMessage `gorm:"type:jsonb;not null;default:'[]'" json:"transitions"`
 
+	// CurrentVersion numbers the latest snapshot in workflow_definition_versions; new
+	// instances are pinned to it
+	CurrentVersion int `gorm:"not null;default:1" json:"current_version"`
+
 	IsActive  bool      `gorm:"default:true" json:"is_active"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
@@ -31,6 +39,95 @@ func (WorkflowDefinition) TableName() string {
 	return "workflow_definitions"
 }
 
+// StandardApprovalWorkflowCode is the code of the standard approval workflow
+const StandardApprovalWorkflowCode = "standard_approval"
+
+// StandardApprovalWorkflowDefinition returns the standard approval workflow: draft,
+// submitted, then approved or rejected under workflow:approve
+func StandardApprovalWorkflowDefinition() WorkflowDefinition {
+	return WorkflowDefinition{
+		Code:         StandardApprovalWorkflowCode,
+		Name:         "Standard Approval Workflow",
+		Description:  "Basic approval workflow with draft, submit, approve, and reject states",
+		Version:      "1.0.0",
+		InitialState: "draft",
+		States: []byte(`[
+			{"code": "draft", "name": "Draft", "description": "Initial draft state", "color": "gray", "is_final": false},
+			{"code": "submitted", "name": "Submitted", "description": "Submitted for review", "color": "blue", "is_final": false},
+			{"code": "approved", "name": "Approved", "description": "Approved by reviewer", "color": "green", "is_final": true},
+			{"code": "rejected", "name": "Rejected", "description": "Rejected by reviewer", "color": "red", "is_final": true}
+		]`),
+		Transitions: []byte(`[
+			{"from": "draft", "to": "submitted", "action": "submit", "label": "Submit for Review", "required_permission": "",
+				"notifications": [{"title_template": "Form submitted: {{.FormCode}}", "body_template": "Your {{.FormCode}} submission ({{.SubmissionID}}) has been submitted for review.", "channels": ["in_app"], "recipients": [{"type": "submitter"}]}]},
+			{"from": "submitted", "to": "approved", "action": "approve", "label": "Approve", "required_permission": "workflow:approve",
+				"notifications": [{"title_template": "Form approved: {{.FormCode}}", "body_template": "Your {{.FormCode}} submission ({{.SubmissionID}}) has been approved by {{.ApproverName}}.", "channels": ["in_app"], "priority": "high", "recipients": [{"type": "submitter"}]}]},
+			{"from": "submitted", "to": "rejected", "action": "reject", "label": "Reject", "required_permission": "workflow:approve",
+				"notifications": [{"title_template": "Form rejected: {{.FormCode}}", "body_template": "Your {{.FormCode}} submission ({{.SubmissionID}}) was rejected by {{.ApproverName}}. Comment: {{.Comment}}", "channels": ["in_app"], "priority": "high", "recipients": [{"type": "submitter"}]}]},
+			{"from": "rejected", "to": "draft", "action": "revise", "label": "Revise", "required_permission": "",
+				"notifications": [{"title_template": "Revision requested: {{.FormCode}}", "body_template": "Your {{.FormCode}} submission ({{.SubmissionID}}) has been sent back for revision. Please update and resubmit.", "channels": ["in_app"], "priority": "high", "recipients": [{"type": "submitter"}]}]}
+		]`),
+		IsActive: true,
+	}
+}
+
+// ParseStates decodes the workflow's States JSON
+func (w *WorkflowDefinition) ParseStates() ([]WorkflowState, error) {
+	var states []WorkflowState
+	if len(w.States) == 0 {
+		return states, nil
+	}
+	if err := json.Unmarshal(w.States, &states); err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+// ParseTransitions decodes the workflow's Transitions JSON
+func (w *WorkflowDefinition) ParseTransitions() ([]WorkflowTransitionDef, error) {
+	var transitions []WorkflowTransitionDef
+	if len(w.Transitions) == 0 {
+		return transitions, nil
+	}
+	if err := json.Unmarshal(w.Transitions, &transitions); err != nil {
+		return nil, err
+	}
+	return transitions, nil
+}
+
+// FindTransition returns the transition taking action from state, validating both ends
+// against the declared states when the workflow declares any.
+func (w *WorkflowDefinition) FindTransition(from, action string) (*WorkflowTransitionDef, error) {
+	states, err := w.ParseStates()
+	if err != nil {
+		return nil, fmt.Errorf("invalid workflow states: %w", err)
+	}
+	transitions, err := w.ParseTransitions()
+	if err != nil {
+		return nil, fmt.Errorf("invalid workflow transitions: %w", err)
+	}
+
+	declared := make(map[string]bool, len(states))
+	for _, state := range states {
+		declared[state.Code] = true
+	}
+	if len(declared) > 0 && !declared[from] {
+		return nil, fmt.Errorf("current state '%s' is not defined in workflow '%s'", from, w.Code)
+	}
+
+	for i := range transitions {
+		t := transitions[i]
+		if t.From != from || t.Action != action {
+			continue
+		}
+		if len(declared) > 0 && !declared[t.To] {
+			return nil, fmt.Errorf("transition '%s' targets undefined state '%s'", action, t.To)
+		}
+		return &t, nil
+	}
+	return nil, fmt.Errorf("invalid transition: action '%s' not allowed from state '%s'", action, from)
+}
+
 // WorkflowState represents a state in the workflow
 type WorkflowState struct {
 	Code        string `json:"code"`
@@ -48,11 +145,105 @@ type WorkflowTransitionDef struct {
 	Action               string                                  `json:"action"`
 	Label                string                                  `json:"label,omitempty"`
 	Permission           string                                  `json:"permission,omitempty"`
+	RequiredPermission   string                                  `json:"required_permission,omitempty"` // Alias of Permission used by seeded definitions
 	RequiresComment      bool                                    `json:"requires_comment,omitempty"`
 	DocumentRequirements *WorkflowTransitionDocumentRequirements `json:"document_requirements,omitempty"`
 
 	// Notification configuration
 	Notifications []TransitionNotification `json:"notifications,omitempty"`
+
+	// Side effects run when the transition fires
+	Actions []TransitionAction `json:"actions,omitempty"`
+
+	// Timer makes the scheduler take the transition on its own once an instance has sat in
+	// From long enough; it can still be taken by hand
+	Timer *TransitionTimer `json:"timer,omitempty"`
+}
+
+// TransitionTimer is how long an instance waits in a transition's from-state before the
+// transition fires automatically
+type TransitionTimer struct {
+	After string `json:"after"` // Go duration, or whole days such as "30d"
+}
+
+// Duration parses After
+func (t TransitionTimer) Duration() (time.Duration, error) {
+	after := strings.TrimSpace(t.After)
+	var d time.Duration
+	if days, ok := strings.CutSuffix(after, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timer '%s'", t.After)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		parsed, err := time.ParseDuration(after)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timer '%s'", t.After)
+		}
+		d = parsed
+	}
+	if d < time.Minute {
+		return 0, fmt.Errorf("timer '%s' must be at least a minute", t.After)
+	}
+	return d, nil
+}
+
+// WorkflowTimerFailure records a timed transition the scheduler could not take on an
+// instance. The instance is skipped until NextRetryAt, so instances that keep failing do
+// not hold up the other due ones.
+type WorkflowTimerFailure struct {
+	ID            uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	InstanceID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_workflow_timer_failures_instance_action,priority:1" json:"instance_id"`
+	Action        string    `gorm:"size:100;not null;uniqueIndex:idx_workflow_timer_failures_instance_action,priority:2" json:"action"`
+	Attempts      int       `gorm:"not null;default:0" json:"attempts"`
+	LastError     string    `gorm:"type:text" json:"last_error"`
+	LastAttemptAt time.Time `json:"last_attempt_at"`
+	NextRetryAt   time.Time `gorm:"index" json:"next_retry_at"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// maxWorkflowTimerBackoff caps the wait between attempts at a failing timer
+const maxWorkflowTimerBackoff = 24 * time.Hour
+
+// RecordAttempt counts a failed attempt at now and schedules the next one, waiting five
+// minutes after the first failure and doubling up to a day
+func (f *WorkflowTimerFailure) RecordAttempt(err error, now time.Time) {
+	f.Attempts++
+	f.LastError = err.Error()
+	f.LastAttemptAt = now
+	backoff := maxWorkflowTimerBackoff
+	if f.Attempts <= 9 {
+		backoff = min(5*time.Minute<<(f.Attempts-1), maxWorkflowTimerBackoff)
+	}
+	f.NextRetryAt = now.Add(backoff)
+}
+
+// ValidateTimers checks the timers declared on the workflow's transitions
+func (w *WorkflowDefinition) ValidateTimers() error {
+	transitions, err := w.ParseTransitions()
+	if err != nil {
+		return fmt.Errorf("invalid workflow transitions: %w", err)
+	}
+	for _, t := range transitions {
+		if t.Timer == nil {
+			continue
+		}
+		if _, err := t.Timer.Duration(); err != nil {
+			return fmt.Errorf("transition '%s' from '%s': %w", t.Action, t.From, err)
+		}
+	}
+	return nil
+}
+
+// RequiredPermissionCode returns the permission needed to take the transition, whichever
+// of the two keys the definition uses; empty means anyone may take it.
+func (t WorkflowTransitionDef) RequiredPermissionCode() string {
+	if t.Permission != "" {
+		return t.Permission
+	}
+	return t.RequiredPermission
 }
 
 type WorkflowTransitionDocumentRequirements struct {
@@ -111,6 +302,9 @@ type FormSubmission struct {
 	Workflow     *WorkflowDefinition `gorm:"foreignKey:WorkflowID" json:"workflow,omitempty"`
 	CurrentState string              `gorm:"size:50;not null;default:'draft';index" json:"current_state"`
 
+	// WorkflowVersion pins the submission to the workflow definition version it runs under
+	WorkflowVersion *int `gorm:"index" json:"workflow_version,omitempty"`
+
 	// Form data (submitted field values)
 	FormData json.RawMessage `gorm:"type:jsonb;not null;default:'{}'" json:"form_data"`
 
@@ -129,7 +323,7 @@ type FormSubmission struct {
 	DeletedAt *time.Time `gorm:"index" json:"deleted_at,omitempty"`
 
 	// Relationships
-	Transitions []WorkflowTransition `gorm:"foreignKey:SubmissionID" json:"transitions,omitempty"`
+	Transitions []WorkflowTransition `gorm:"foreignKey:SubmissionID;constraint:-" json:"transitions,omitempty"`
 }
 
 // TableName specifies the table name for FormSubmission
@@ -141,9 +335,10 @@ func (FormSubmission) TableName() string {
 type WorkflowTransition struct {
 	ID uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
 
-	// Submission reference
+	// Submission reference. Records in dedicated form tables are workflow instances too, so
+	// submission_id is not constrained to form_submissions.
 	SubmissionID uuid.UUID       `gorm:"type:uuid;not null;index" json:"submission_id"`
-	Submission   *FormSubmission `gorm:"foreignKey:SubmissionID" json:"submission,omitempty"`
+	Submission   *FormSubmission `gorm:"foreignKey:SubmissionID;constraint:-" json:"submission,omitempty"`
 
 	// Transition details
 	FromState string `gorm:"size:50;not null" json:"from_state"`
@@ -155,6 +350,11 @@ type WorkflowTransition struct {
 	ActorName string `gorm:"size:255" json:"actor_name,omitempty"`
 	ActorRole string `gorm:"size:100" json:"actor_role,omitempty"`
 
+	// Set when the actor decided under an approval delegation
+	OnBehalfOfID   string     `gorm:"size:255;index" json:"on_behalf_of_id,omitempty"`
+	OnBehalfOfName string     `gorm:"size:255" json:"on_behalf_of_name,omitempty"`
+	DelegationID   *uuid.UUID `gorm:"type:uuid" json:"delegation_id,omitempty"`
+
 	// Additional context
 	Comment  string          `gorm:"type:text" json:"comment,omitempty"`
 	Metadata json.RawMessage `gorm:"type:jsonb;default:'{}'" json:"metadata,omitempty"`
@@ -232,7 +432,7 @@ func (s *FormSubmission) GetAvailableActions(workflowDef *WorkflowDefinition) ([
 				Label:           t.Label,
 				ToState:         t.To,
 				RequiresComment: t.RequiresComment,
-				Permission:      t.Permission,
+				Permission:      t.RequiredPermissionCode(),
 			}
 
 			// Set default label if not specified
@@ -309,3 +509,32 @@ func (s *FormSubmission) ToDTO(workflowDef *WorkflowDefinition) FormSubmissionDT
 
 	return dto
 }
+
+// WorkflowTimelineEntry is one state change of a workflow instance, with how long the
+// instance had been in the state it left
+type WorkflowTimelineEntry struct {
+	WorkflowTransition
+	TimeInStateSeconds int64 `json:"time_in_state_seconds"`
+}
+
+// BuildWorkflowTimeline orders transitions oldest first and measures each one's time in the
+// previous state from the one before it, or from createdAt for the first
+func BuildWorkflowTimeline(transitions []WorkflowTransition, createdAt time.Time) []WorkflowTimelineEntry {
+	sorted := make([]WorkflowTransition, len(transitions))
+	copy(sorted, transitions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].TransitionedAt.Before(sorted[j].TransitionedAt)
+	})
+
+	timeline := make([]WorkflowTimelineEntry, 0, len(sorted))
+	enteredAt := createdAt
+	for _, t := range sorted {
+		entry := WorkflowTimelineEntry{WorkflowTransition: t}
+		if !enteredAt.IsZero() && t.TransitionedAt.After(enteredAt) {
+			entry.TimeInStateSeconds = int64(t.TransitionedAt.Sub(enteredAt).Seconds())
+		}
+		timeline = append(timeline, entry)
+		enteredAt = t.TransitionedAt
+	}
+	return timeline
+}
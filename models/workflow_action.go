@@ -0,0 +1,178 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TransitionActionType names a side effect a workflow transition performs when it fires
+type TransitionActionType string
+
+const (
+	TransitionActionNotify     TransitionActionType = "send_notification"
+	TransitionActionWebhook    TransitionActionType = "call_webhook"
+	TransitionActionSetField   TransitionActionType = "set_field"
+	TransitionActionAssignTask TransitionActionType = "assign_task"
+)
+
+// Targets of a set_field action
+const (
+	TransitionActionTargetRecord           = "record"
+	TransitionActionTargetBudgetAllocation = "budget_allocation"
+)
+
+// TransitionAction is one entry of a transition's "actions". set_field and assign_task run
+// inside the transition's transaction; notifications and webhooks are written to the
+// workflow action outbox in that transaction and delivered once it has committed.
+type TransitionAction struct {
+	Type TransitionActionType `json:"type"`
+
+	// send_notification
+	Notification *TransitionNotification `json:"notification,omitempty"`
+
+	// call_webhook: POST the transition event to URL, or to the vertical's webhooks
+	// subscribed to workflow.transition when URL is empty
+	URL     string            `json:"url,omitempty"`
+	Secret  string            `json:"secret,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// set_field: writes Field on the record itself, or on the budget allocation whose ID
+	// is held in the record's TargetIDField. The value is Value, or the record's ValueField.
+	Target        string      `json:"target,omitempty"` // record (default), budget_allocation
+	TargetIDField string      `json:"target_id_field,omitempty"`
+	Field         string      `json:"field,omitempty"`
+	Value         interface{} `json:"value,omitempty"`
+	ValueField    string      `json:"value_field,omitempty"`
+	Operation     string      `json:"operation,omitempty"` // set (default), increment, decrement
+
+	// assign_task: assigns Assignee, or the user held in the record's AssigneeField, to the
+	// project task whose ID is held in the record's TaskIDField
+	TaskIDField   string `json:"task_id_field,omitempty"`
+	Assignee      string `json:"assignee,omitempty"`
+	AssigneeField string `json:"assignee_field,omitempty"`
+	Role          string `json:"role,omitempty"` // defaults to worker
+}
+
+var transitionFieldPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+// BudgetAllocationActionFields are the budget allocation columns a set_field action may write
+var BudgetAllocationActionFields = map[string]bool{
+	"actual_amount":  true,
+	"planned_amount": true,
+	"status":         true,
+	"notes":          true,
+}
+
+// ProtectedRecordFields are form table columns that only the workflow engine itself writes
+var ProtectedRecordFields = map[string]bool{
+	"id": true, "form_id": true, "form_code": true, "form_version": true,
+	"business_vertical_id": true, "site_id": true, "workflow_id": true, "workflow_version": true, "current_state": true,
+	"created_by": true, "created_at": true, "updated_by": true, "updated_at": true, "deleted_at": true,
+}
+
+// IsDeferred reports whether the action is delivered through the outbox after commit
+func (a TransitionAction) IsDeferred() bool {
+	return a.Type == TransitionActionNotify || a.Type == TransitionActionWebhook
+}
+
+// Validate checks that the action has what its type needs
+func (a TransitionAction) Validate() error {
+	switch a.Type {
+	case TransitionActionNotify:
+		if a.Notification == nil || len(a.Notification.Recipients) == 0 {
+			return fmt.Errorf("send_notification requires a notification with recipients")
+		}
+	case TransitionActionWebhook:
+		// An empty URL fans out to the vertical's registered webhooks
+	case TransitionActionSetField:
+		if !transitionFieldPattern.MatchString(a.Field) {
+			return fmt.Errorf("set_field requires a valid field name, got '%s'", a.Field)
+		}
+		switch a.Target {
+		case "", TransitionActionTargetRecord:
+			if ProtectedRecordFields[a.Field] {
+				return fmt.Errorf("set_field cannot write system field '%s'", a.Field)
+			}
+		case TransitionActionTargetBudgetAllocation:
+			if a.TargetIDField == "" {
+				return fmt.Errorf("set_field on budget_allocation requires target_id_field")
+			}
+			if !BudgetAllocationActionFields[a.Field] {
+				return fmt.Errorf("set_field cannot write budget allocation field '%s'", a.Field)
+			}
+		default:
+			return fmt.Errorf("unknown set_field target '%s'", a.Target)
+		}
+		switch a.Operation {
+		case "", "set", "increment", "decrement":
+		default:
+			return fmt.Errorf("unknown set_field operation '%s'", a.Operation)
+		}
+		if a.Value == nil && a.ValueField == "" {
+			return fmt.Errorf("set_field requires value or value_field")
+		}
+	case TransitionActionAssignTask:
+		if a.TaskIDField == "" {
+			return fmt.Errorf("assign_task requires task_id_field")
+		}
+		if a.Assignee == "" && a.AssigneeField == "" {
+			return fmt.Errorf("assign_task requires assignee or assignee_field")
+		}
+	default:
+		return fmt.Errorf("unknown action type '%s'", a.Type)
+	}
+	return nil
+}
+
+// Workflow action outbox statuses
+const (
+	WorkflowActionPending   = "pending"
+	WorkflowActionDelivered = "delivered"
+	WorkflowActionFailed    = "failed"
+)
+
+// WorkflowActionOutbox holds a deferred transition action until it has been delivered.
+// Rows are written in the transition's transaction, so an action is queued exactly when
+// its transition commits.
+type WorkflowActionOutbox struct {
+	ID           uuid.UUID            `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	TransitionID uuid.UUID            `gorm:"type:uuid;not null;index" json:"transition_id"`
+	SubmissionID uuid.UUID            `gorm:"type:uuid;not null;index" json:"submission_id"`
+	ActionType   TransitionActionType `gorm:"size:30;not null" json:"action_type"`
+	Action       json.RawMessage      `gorm:"type:jsonb;not null" json:"action"`
+	Event        json.RawMessage      `gorm:"type:jsonb;not null" json:"event"` // transition event with the record's data at the time
+
+	Status        string     `gorm:"size:20;not null;default:'pending';index" json:"status"`
+	Attempts      int        `gorm:"default:0" json:"attempts"`
+	MaxAttempts   int        `gorm:"default:5" json:"max_attempts"`
+	LastError     string     `gorm:"type:text" json:"last_error,omitempty"`
+	NextAttemptAt time.Time  `gorm:"not null;index" json:"next_attempt_at"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for WorkflowActionOutbox
+func (WorkflowActionOutbox) TableName() string {
+	return "workflow_action_outbox"
+}
+
+// ValidateActions checks the actions declared on every transition of the workflow
+func (w *WorkflowDefinition) ValidateActions() error {
+	transitions, err := w.ParseTransitions()
+	if err != nil {
+		return fmt.Errorf("invalid workflow transitions: %w", err)
+	}
+	for _, t := range transitions {
+		for i, action := range t.Actions {
+			if err := action.Validate(); err != nil {
+				return fmt.Errorf("transition '%s' from '%s', action %d: %w", t.Action, t.From, i+1, err)
+			}
+		}
+	}
+	return nil
+}
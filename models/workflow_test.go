@@ -0,0 +1,160 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWorkflowDefinitionFindTransition(t *testing.T) {
+	workflow := WorkflowDefinition{
+		Code:   "approval",
+		States: json.RawMessage(`[{"code":"submitted"},{"code":"approved"},{"code":"rejected","is_final":true}]`),
+		Transitions: json.RawMessage(`[
+			{"from":"submitted","to":"approved","action":"approve","required_permission":"project:approve"},
+			{"from":"submitted","to":"rejected","action":"reject","permission":"project:reject","requires_comment":true},
+			{"from":"rejected","to":"submitted","action":"revise"},
+			{"from":"approved","to":"archived","action":"archive"}
+		]`),
+	}
+
+	tests := []struct {
+		name       string
+		from       string
+		action     string
+		wantTo     string
+		permission string
+		wantErr    bool
+	}{
+		{"seeded permission key", "submitted", "approve", "approved", "project:approve", false},
+		{"permission key", "submitted", "reject", "rejected", "project:reject", false},
+		{"final state keeps explicit transitions", "rejected", "revise", "submitted", "", false},
+		{"action not allowed from state", "approved", "approve", "", "", true},
+		{"undeclared current state", "draft", "approve", "", "", true},
+		{"undeclared target state", "approved", "archive", "", "", true},
+	}
+	for _, tt := range tests {
+		got, err := workflow.FindTransition(tt.from, tt.action)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if got.To != tt.wantTo || got.RequiredPermissionCode() != tt.permission {
+			t.Errorf("%s: got to=%q permission=%q", tt.name, got.To, got.RequiredPermissionCode())
+		}
+	}
+}
+
+func TestTransitionActionValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  TransitionAction
+		wantErr bool
+	}{
+		{"notification", TransitionAction{Type: TransitionActionNotify, Notification: &TransitionNotification{
+			Recipients: []NotificationRecipientDef{{Type: "permission", Value: "finance:read"}}}}, false},
+		{"notification without recipients", TransitionAction{Type: TransitionActionNotify, Notification: &TransitionNotification{}}, true},
+		{"registered webhooks", TransitionAction{Type: TransitionActionWebhook}, false},
+		{"record field", TransitionAction{Type: TransitionActionSetField, Field: "approved_amount", ValueField: "amount"}, false},
+		{"system field", TransitionAction{Type: TransitionActionSetField, Field: "current_state", Value: "approved"}, true},
+		{"unsafe field name", TransitionAction{Type: TransitionActionSetField, Field: "x = 1; --", Value: 1}, true},
+		{"budget increment", TransitionAction{Type: TransitionActionSetField, Target: TransitionActionTargetBudgetAllocation,
+			TargetIDField: "budget_allocation_id", Field: "actual_amount", ValueField: "amount", Operation: "increment"}, false},
+		{"budget field not allowed", TransitionAction{Type: TransitionActionSetField, Target: TransitionActionTargetBudgetAllocation,
+			TargetIDField: "budget_allocation_id", Field: "created_by", Value: "x"}, true},
+		{"set without value", TransitionAction{Type: TransitionActionSetField, Field: "remarks"}, true},
+		{"assign task", TransitionAction{Type: TransitionActionAssignTask, TaskIDField: "task_id", AssigneeField: "engineer_id"}, false},
+		{"assign task without assignee", TransitionAction{Type: TransitionActionAssignTask, TaskIDField: "task_id"}, true},
+		{"unknown type", TransitionAction{Type: "send_fax"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.action.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestBuildWorkflowTimeline(t *testing.T) {
+	created := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	transitions := []WorkflowTransition{
+		{FromState: "submitted", ToState: "approved", Action: "approve", TransitionedAt: created.Add(3 * time.Hour)},
+		{FromState: "draft", ToState: "submitted", Action: "submit", TransitionedAt: created.Add(time.Hour)},
+	}
+
+	timeline := BuildWorkflowTimeline(transitions, created)
+	if len(timeline) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(timeline))
+	}
+	if timeline[0].Action != "submit" || timeline[1].Action != "approve" {
+		t.Errorf("expected oldest first, got %s then %s", timeline[0].Action, timeline[1].Action)
+	}
+	if timeline[0].TimeInStateSeconds != 3600 {
+		t.Errorf("expected 3600s in draft, got %d", timeline[0].TimeInStateSeconds)
+	}
+	if timeline[1].TimeInStateSeconds != 7200 {
+		t.Errorf("expected 7200s in submitted, got %d", timeline[1].TimeInStateSeconds)
+	}
+
+	if got := BuildWorkflowTimeline(transitions, time.Time{}); got[0].TimeInStateSeconds != 0 {
+		t.Errorf("expected no duration without a creation time, got %d", got[0].TimeInStateSeconds)
+	}
+}
+
+func TestTransitionTimerDuration(t *testing.T) {
+	tests := []struct {
+		after   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"30d", 30 * 24 * time.Hour, false},
+		{"12h", 12 * time.Hour, false},
+		{"90m", 90 * time.Minute, false},
+		{"10s", 0, true},
+		{"d", 0, true},
+		{"soon", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := TransitionTimer{After: tt.after}.Duration()
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected error", tt.after)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%q: got %v, %v; want %v", tt.after, got, err, tt.want)
+		}
+	}
+
+	workflow := WorkflowDefinition{Transitions: json.RawMessage(`[{"from":"draft","to":"cancelled","action":"auto_cancel","timer":{"after":"later"}}]`)}
+	if err := workflow.ValidateTimers(); err == nil {
+		t.Error("expected invalid timer to fail validation")
+	}
+}
+
+func TestWorkflowTimerFailureBacksOff(t *testing.T) {
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	failure := WorkflowTimerFailure{}
+	for _, want := range []time.Duration{5 * time.Minute, 10 * time.Minute, 20 * time.Minute} {
+		failure.RecordAttempt(errors.New("target state missing"), now)
+		if got := failure.NextRetryAt.Sub(now); got != want {
+			t.Errorf("attempt %d: retry after %v, want %v", failure.Attempts, got, want)
+		}
+	}
+	if failure.LastError != "target state missing" || !failure.LastAttemptAt.Equal(now) {
+		t.Errorf("last attempt = %q at %v", failure.LastError, failure.LastAttemptAt)
+	}
+
+	failure.Attempts = 40
+	failure.RecordAttempt(errors.New("still failing"), now)
+	if got := failure.NextRetryAt.Sub(now); got != maxWorkflowTimerBackoff {
+		t.Errorf("retry after %v, want the %v cap", got, maxWorkflowTimerBackoff)
+	}
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WorkflowDefinitionVersion is an immutable snapshot of a workflow definition's states and
+// transitions. Instances run under the version they were pinned to, so editing a definition
+// never changes the rules for records already in flight.
+type WorkflowDefinitionVersion struct {
+	ID            uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	WorkflowID    uuid.UUID       `gorm:"type:uuid;not null;uniqueIndex:idx_workflow_definition_version,priority:1" json:"workflow_id"`
+	VersionNumber int             `gorm:"not null;uniqueIndex:idx_workflow_definition_version,priority:2" json:"version_number"`
+	Version       string          `gorm:"size:50" json:"version,omitempty"` // the definition's free-form version label at the time
+	InitialState  string          `gorm:"size:50;not null" json:"initial_state"`
+	States        json.RawMessage `gorm:"type:jsonb;not null;default:'[]'" json:"states"`
+	Transitions   json.RawMessage `gorm:"type:jsonb;not null;default:'[]'" json:"transitions"`
+	CreatedBy     string          `gorm:"size:255" json:"created_by,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// TableName specifies the table name for WorkflowDefinitionVersion
+func (WorkflowDefinitionVersion) TableName() string {
+	return "workflow_definition_versions"
+}
+
+// Snapshot captures the definition's current states and transitions as its CurrentVersion
+func (w *WorkflowDefinition) Snapshot(createdBy string) WorkflowDefinitionVersion {
+	return WorkflowDefinitionVersion{
+		WorkflowID:    w.ID,
+		VersionNumber: w.CurrentVersion,
+		Version:       w.Version,
+		InitialState:  w.InitialState,
+		States:        w.States,
+		Transitions:   w.Transitions,
+		CreatedBy:     createdBy,
+	}
+}
+
+// AtVersion returns a copy of the definition carrying the snapshot's states and transitions,
+// so it can be used wherever the definition is
+func (w *WorkflowDefinition) AtVersion(v *WorkflowDefinitionVersion) *WorkflowDefinition {
+	pinned := *w
+	pinned.CurrentVersion = v.VersionNumber
+	pinned.InitialState = v.InitialState
+	pinned.States = v.States
+	pinned.Transitions = v.Transitions
+	return &pinned
+}
+
+// StructureChanged reports whether other differs from the definition in anything that
+// governs instances: the initial state, states or transitions
+func (w *WorkflowDefinition) StructureChanged(other *WorkflowDefinition) bool {
+	return w.InitialState != other.InitialState ||
+		!sameJSON(w.States, other.States) ||
+		!sameJSON(w.Transitions, other.Transitions)
+}
+
+// MigrateState returns the state an instance in state takes when moved to this definition:
+// the mapped state when mapping has an entry for it, otherwise the same state if the
+// definition still declares it.
+func (w *WorkflowDefinition) MigrateState(state string, mapping map[string]string) (string, error) {
+	states, err := w.ParseStates()
+	if err != nil {
+		return "", fmt.Errorf("invalid workflow states: %w", err)
+	}
+	declared := make(map[string]bool, len(states))
+	for _, s := range states {
+		declared[s.Code] = true
+	}
+
+	target, mapped := mapping[state]
+	if !mapped {
+		target = state
+	}
+	if len(declared) > 0 && !declared[target] {
+		if mapped {
+			return "", fmt.Errorf("state '%s' is mapped to '%s', which is not defined in version %d", state, target, w.CurrentVersion)
+		}
+		return "", fmt.Errorf("state '%s' is not defined in version %d and has no mapping", state, w.CurrentVersion)
+	}
+	return target, nil
+}
+
+func sameJSON(a, b json.RawMessage) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	var av, bv interface{}
+	if json.Unmarshal(a, &av) != nil || json.Unmarshal(b, &bv) != nil {
+		return false
+	}
+	an, _ := json.Marshal(av)
+	bn, _ := json.Marshal(bv)
+	return bytes.Equal(an, bn)
+}
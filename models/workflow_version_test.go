@@ -0,0 +1,67 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestWorkflowDefinitionMigrateState(t *testing.T) {
+	target := WorkflowDefinition{
+		Code:           "approval",
+		CurrentVersion: 2,
+		States:         json.RawMessage(`[{"code":"submitted"},{"code":"in_review"},{"code":"approved"}]`),
+	}
+
+	tests := []struct {
+		name    string
+		state   string
+		mapping map[string]string
+		want    string
+		wantErr bool
+	}{
+		{"kept state", "submitted", nil, "submitted", false},
+		{"mapped state", "pending_review", map[string]string{"pending_review": "in_review"}, "in_review", false},
+		{"mapping overrides a kept state", "submitted", map[string]string{"submitted": "in_review"}, "in_review", false},
+		{"removed state without mapping", "pending_review", nil, "", true},
+		{"mapped to undeclared state", "pending_review", map[string]string{"pending_review": "reviewing"}, "", true},
+	}
+	for _, tt := range tests {
+		got, err := target.MigrateState(tt.state, tt.mapping)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error", tt.name)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: got %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestWorkflowDefinitionStructureChanged(t *testing.T) {
+	base := WorkflowDefinition{
+		InitialState: "draft",
+		States:       json.RawMessage(`[{"code":"draft"},{"code":"submitted"}]`),
+		Transitions:  json.RawMessage(`[{"from":"draft","to":"submitted","action":"submit"}]`),
+	}
+
+	reformatted := base
+	reformatted.Name = "Renamed"
+	reformatted.Transitions = json.RawMessage(`[ {"action":"submit", "from":"draft", "to":"submitted"} ]`)
+	if base.StructureChanged(&reformatted) {
+		t.Error("expected formatting and non-structural edits not to count as a change")
+	}
+
+	edited := base
+	edited.Transitions = json.RawMessage(`[{"from":"draft","to":"submitted","action":"send"}]`)
+	if !base.StructureChanged(&edited) {
+		t.Error("expected a changed transition to count as a change")
+	}
+
+	edited = base
+	edited.InitialState = "submitted"
+	if !base.StructureChanged(&edited) {
+		t.Error("expected a changed initial state to count as a change")
+	}
+}
@@ -0,0 +1,164 @@
+package abac
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/models"
+)
+
+// AttributeResolver derives attribute values at evaluation time instead of reading
+// assigned UserAttribute/ResourceAttribute rows, e.g. a user's location from the
+// sites they are assigned to. Assigned values take precedence over resolved ones.
+type AttributeResolver struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Provides    []string `json:"provides"` // attribute names the resolver may set
+	// Resolve returns the attributes it could derive for the request. db is nil when
+	// the engine has no database, in which case database backed resolvers return nothing.
+	Resolve func(db *gorm.DB, req models.PolicyRequest, now time.Time) (map[string]string, error) `json:"-"`
+}
+
+var (
+	resolversMu sync.RWMutex
+	resolvers   []AttributeResolver
+)
+
+// RegisterAttributeResolver adds a resolver called on every policy evaluation. A
+// resolver registered under an existing name replaces it.
+func RegisterAttributeResolver(resolver AttributeResolver) {
+	resolversMu.Lock()
+	defer resolversMu.Unlock()
+
+	for i := range resolvers {
+		if resolvers[i].Name == resolver.Name {
+			resolvers[i] = resolver
+			return
+		}
+	}
+	resolvers = append(resolvers, resolver)
+}
+
+// AttributeResolvers lists the registered resolvers in registration order
+func AttributeResolvers() []AttributeResolver {
+	resolversMu.RLock()
+	defer resolversMu.RUnlock()
+	return append([]AttributeResolver(nil), resolvers...)
+}
+
+// ResolveAttributes runs every registered resolver for the request. A failing resolver
+// is logged and skipped: its attributes stay missing, so conditions on them do not match.
+func ResolveAttributes(db *gorm.DB, req models.PolicyRequest, now time.Time) map[string]string {
+	resolved := make(map[string]string)
+	for _, resolver := range AttributeResolvers() {
+		values, err := resolver.Resolve(db, req, now)
+		if err != nil {
+			log.Printf("attribute resolver %s failed: %v", resolver.Name, err)
+			continue
+		}
+		for k, v := range values {
+			resolved[k] = v
+		}
+	}
+	return resolved
+}
+
+func init() {
+	RegisterAttributeResolver(AttributeResolver{
+		Name:        "user_sites",
+		Description: "Derives the user's location and site codes from their site assignments",
+		Provides:    []string{"user.location", "user.site_codes", "user.site_count"},
+		Resolve:     resolveUserSites,
+	})
+	RegisterAttributeResolver(AttributeResolver{
+		Name:        "resource_site",
+		Description: "Derives the code and business vertical of a site resource",
+		Provides:    []string{"resource.site_code", "resource.business_id"},
+		Resolve:     resolveResourceSite,
+	})
+	RegisterAttributeResolver(AttributeResolver{
+		Name:        "environment_clock",
+		Description: "Derives the time of day and weekend flag at request time",
+		Provides:    []string{"environment.time_of_day", "environment.is_weekend"},
+		Resolve:     resolveEnvironmentClock,
+	})
+}
+
+// resolveUserSites sets user.location to the code of the user's most recently assigned
+// site and user.site_codes to all of their site codes (comma separated, sorted).
+func resolveUserSites(db *gorm.DB, req models.PolicyRequest, now time.Time) (map[string]string, error) {
+	if db == nil || req.UserID == uuid.Nil {
+		return nil, nil
+	}
+
+	var sites []struct {
+		Code       string
+		AssignedAt time.Time
+	}
+	query := db.Table("user_site_accesses").
+		Select("sites.code, user_site_accesses.assigned_at").
+		Joins("JOIN sites ON sites.id = user_site_accesses.site_id AND sites.deleted_at IS NULL AND sites.is_active = ?", true).
+		Where("user_site_accesses.user_id = ?", req.UserID)
+	if req.BusinessVerticalID != nil {
+		query = query.Where("sites.business_vertical_id = ?", *req.BusinessVerticalID)
+	}
+	if err := query.Order("user_site_accesses.assigned_at DESC").Scan(&sites).Error; err != nil {
+		return nil, err
+	}
+	if len(sites) == 0 {
+		return nil, nil
+	}
+
+	codes := make([]string, 0, len(sites))
+	for _, site := range sites {
+		codes = append(codes, site.Code)
+	}
+	location := codes[0]
+	sort.Strings(codes)
+
+	return map[string]string{
+		"user.location":   location,
+		"user.site_codes": strings.Join(codes, ","),
+		"user.site_count": strconv.Itoa(len(codes)),
+	}, nil
+}
+
+// resolveResourceSite describes the site a "site" resource refers to
+func resolveResourceSite(db *gorm.DB, req models.PolicyRequest, now time.Time) (map[string]string, error) {
+	if db == nil || req.ResourceType != "site" || req.ResourceID == nil {
+		return nil, nil
+	}
+
+	var site models.Site
+	if err := db.Select("code", "business_vertical_id").First(&site, "id = ?", *req.ResourceID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return map[string]string{
+		"resource.site_code":   site.Code,
+		"resource.business_id": site.BusinessVerticalID.String(),
+	}, nil
+}
+
+// resolveEnvironmentClock sets environment.time_of_day (HH:MM) and environment.is_weekend.
+// A request timestamp supplied by the caller is used instead of now.
+func resolveEnvironmentClock(db *gorm.DB, req models.PolicyRequest, now time.Time) (map[string]string, error) {
+	if ts, ok := req.Environment["environment.timestamp"]; ok {
+		if parsed, err := time.Parse(time.RFC3339, ts); err == nil {
+			now = parsed
+		}
+	}
+	weekend := now.Weekday() == time.Saturday || now.Weekday() == time.Sunday
+	return map[string]string{
+		"environment.time_of_day": now.Format("15:04"),
+		"environment.is_weekend":  strconv.FormatBool(weekend),
+	}, nil
+}
@@ -0,0 +1,47 @@
+package abac
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"p9e.in/ugcl/models"
+)
+
+func TestResolveEnvironmentClock(t *testing.T) {
+	req := models.PolicyRequest{Environment: map[string]string{"environment.timestamp": "2026-10-17T21:45:00+05:30"}}
+	values, err := resolveEnvironmentClock(nil, req, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values["environment.time_of_day"] != "21:45" || values["environment.is_weekend"] != "true" {
+		t.Errorf("unexpected clock attributes: %v", values)
+	}
+}
+
+func TestBuildContextResolvedAttributes(t *testing.T) {
+	RegisterAttributeResolver(AttributeResolver{
+		Name: "test_department",
+		Resolve: func(db *gorm.DB, req models.PolicyRequest, now time.Time) (map[string]string, error) {
+			return map[string]string{"user.department": "derived", "user.team": "derived"}, nil
+		},
+	})
+	defer func() {
+		resolversMu.Lock()
+		resolvers = resolvers[:len(resolvers)-1]
+		resolversMu.Unlock()
+	}()
+
+	pe := &PolicyEngine{}
+	context := pe.buildContext(models.PolicyRequest{UserAttributes: map[string]string{"user.department": "assigned"}})
+
+	if context["user.department"] != "assigned" {
+		t.Errorf("assigned attribute should override resolved one, got %q", context["user.department"])
+	}
+	if context["user.team"] != "derived" {
+		t.Errorf("resolved attribute missing, got %q", context["user.team"])
+	}
+	if _, ok := context["environment.time_of_day"]; !ok {
+		t.Error("environment.time_of_day should be resolved at request time")
+	}
+}
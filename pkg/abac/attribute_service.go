@@ -2,6 +2,7 @@ package abac
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -26,6 +27,9 @@ func (as *AttributeService) AssignUserAttribute(userID, attributeID, assignedBy
 	if err := as.db.First(&attribute, "id = ? AND is_active = ?", attributeID, true).Error; err != nil {
 		return fmt.Errorf("attribute not found: %v", err)
 	}
+	if err := ValidateAttributeValue(attribute, value); err != nil {
+		return err
+	}
 
 	// Check if user exists
 	var user models.User
@@ -85,6 +89,12 @@ func (as *AttributeService) AssignResourceAttribute(resourceType string, resourc
 	if err := as.db.First(&attribute, "id = ? AND is_active = ?", attributeID, true).Error; err != nil {
 		return fmt.Errorf("attribute not found: %v", err)
 	}
+	if err := ValidateAttributeValue(attribute, value); err != nil {
+		return err
+	}
+	if strings.TrimSpace(resourceType) == "" {
+		return fmt.Errorf("resource_type is required")
+	}
 
 	// Deactivate existing attribute assignment
 	as.db.Model(&models.ResourceAttribute{}).
@@ -136,6 +146,10 @@ func (as *AttributeService) GetResourceAttributes(resourceType string, resourceI
 
 // CreateAttribute creates a new attribute definition
 func (as *AttributeService) CreateAttribute(attr models.Attribute) (*models.Attribute, error) {
+	if err := validateAttributeDefinition(attr); err != nil {
+		return nil, err
+	}
+
 	// Check for duplicate
 	var existing models.Attribute
 	if err := as.db.Where("name = ?", attr.Name).First(&existing).Error; err == nil {
@@ -156,6 +170,36 @@ func (as *AttributeService) UpdateAttribute(id uuid.UUID, updates map[string]int
 		return nil, fmt.Errorf("attribute not found: %v", err)
 	}
 
+	// Names are referenced by policy conditions and system attributes by code
+	if name, ok := updates["name"]; ok && name != attr.Name {
+		return nil, fmt.Errorf("attribute name cannot be changed")
+	}
+	if _, ok := updates["is_system"]; ok {
+		return nil, fmt.Errorf("is_system cannot be changed")
+	}
+
+	candidate := attr
+	if t, ok := updates["type"].(string); ok {
+		candidate.Type = models.AttributeType(t)
+	}
+	if dt, ok := updates["data_type"].(string); ok {
+		candidate.DataType = models.AttributeDataType(dt)
+	}
+	if metadata, ok := updates["metadata"].(map[string]interface{}); ok {
+		candidate.Metadata = metadata
+		updates["metadata"] = models.JSONMap(metadata)
+	}
+	if err := validateAttributeDefinition(candidate); err != nil {
+		return nil, err
+	}
+
+	// A new data type or value list must still fit the values already assigned
+	if candidate.DataType != attr.DataType || updates["metadata"] != nil {
+		if err := as.checkAssignedValues(candidate); err != nil {
+			return nil, err
+		}
+	}
+
 	if err := as.db.Model(&attr).Updates(updates).Error; err != nil {
 		return nil, fmt.Errorf("failed to update attribute: %v", err)
 	}
@@ -163,6 +207,53 @@ func (as *AttributeService) UpdateAttribute(id uuid.UUID, updates map[string]int
 	return &attr, nil
 }
 
+// validateAttributeDefinition checks an attribute's name, category and data type
+func validateAttributeDefinition(attr models.Attribute) error {
+	if strings.TrimSpace(attr.Name) == "" {
+		return fmt.Errorf("attribute name is required")
+	}
+	if strings.TrimSpace(attr.DisplayName) == "" {
+		return fmt.Errorf("attribute display_name is required")
+	}
+	if !IsValidAttributeType(attr.Type) {
+		return fmt.Errorf("invalid attribute type '%s'", attr.Type)
+	}
+	if !IsValidAttributeDataType(attr.DataType) {
+		return fmt.Errorf("invalid attribute data_type '%s'", attr.DataType)
+	}
+	if raw, ok := attr.Metadata["allowed_values"]; ok {
+		if _, isArray := raw.([]interface{}); !isArray {
+			return fmt.Errorf("metadata.allowed_values must be an array")
+		}
+		for value := range AllowedValues(attr) {
+			if err := checkDataType(attr.DataType, value); err != nil {
+				return fmt.Errorf("metadata.allowed_values: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkAssignedValues verifies every active assignment of the attribute against its
+// (updated) definition
+func (as *AttributeService) checkAssignedValues(attr models.Attribute) error {
+	userValues, resourceValues, err := as.ListAttributeValues(attr.ID)
+	if err != nil {
+		return err
+	}
+	for _, ua := range userValues {
+		if err := ValidateAttributeValue(attr, ua.Value); err != nil {
+			return fmt.Errorf("user %s: %v", ua.UserID, err)
+		}
+	}
+	for _, ra := range resourceValues {
+		if err := ValidateAttributeValue(attr, ra.Value); err != nil {
+			return fmt.Errorf("%s %s: %v", ra.ResourceType, ra.ResourceID, err)
+		}
+	}
+	return nil
+}
+
 // DeleteAttribute soft deletes an attribute
 func (as *AttributeService) DeleteAttribute(id uuid.UUID) error {
 	result := as.db.Model(&models.Attribute{}).
@@ -180,6 +271,15 @@ func (as *AttributeService) DeleteAttribute(id uuid.UUID) error {
 	return nil
 }
 
+// GetAttribute retrieves an attribute by ID, including inactive ones
+func (as *AttributeService) GetAttribute(id uuid.UUID) (*models.Attribute, error) {
+	var attr models.Attribute
+	if err := as.db.First(&attr, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("attribute not found: %v", err)
+	}
+	return &attr, nil
+}
+
 // GetAttributeByName retrieves an attribute by its name
 func (as *AttributeService) GetAttributeByName(name string) (*models.Attribute, error) {
 	var attr models.Attribute
@@ -253,3 +353,47 @@ func (as *AttributeService) GetUserAttributeHistory(userID, attributeID uuid.UUI
 
 	return history, nil
 }
+
+// ListAttributeValues retrieves the active user and resource values of an attribute
+func (as *AttributeService) ListAttributeValues(attributeID uuid.UUID) ([]models.UserAttribute, []models.ResourceAttribute, error) {
+	var userValues []models.UserAttribute
+	if err := as.db.Where("attribute_id = ? AND is_active = ?", attributeID, true).
+		Order("created_at DESC").
+		Find(&userValues).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to list user attribute values: %v", err)
+	}
+
+	var resourceValues []models.ResourceAttribute
+	if err := as.db.Where("attribute_id = ? AND is_active = ?", attributeID, true).
+		Order("resource_type, created_at DESC").
+		Find(&resourceValues).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to list resource attribute values: %v", err)
+	}
+
+	return userValues, resourceValues, nil
+}
+
+// GetEffectiveUserAttributes returns the user's assigned attributes merged over those
+// derived by the registered attribute resolvers, as the policy engine sees them.
+func (as *AttributeService) GetEffectiveUserAttributes(userID uuid.UUID, businessVerticalID *uuid.UUID) (map[string]string, error) {
+	assigned, err := models.GetAllUserAttributes(as.db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	req := models.PolicyRequest{
+		UserID:             userID,
+		BusinessVerticalID: businessVerticalID,
+		UserAttributes:     assigned,
+	}
+	effective := make(map[string]string)
+	for k, v := range ResolveAttributes(as.db, req, time.Now()) {
+		if strings.HasPrefix(k, "user.") {
+			effective[k] = v
+		}
+	}
+	for k, v := range assigned {
+		effective[k] = v
+	}
+	return effective, nil
+}
@@ -0,0 +1,262 @@
+package abac
+
+import (
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"p9e.in/ugcl/models"
+)
+
+const (
+	defaultEvaluationQueueSize     = 4096
+	defaultEvaluationBatchSize     = 200
+	defaultEvaluationFlushInterval = 2 * time.Second
+)
+
+// SamplingConfig controls which authorization decisions are persisted.
+// Rates are probabilities between 0 and 1; allows and denies are sampled separately
+// so denials can be kept in full while high-volume allows are thinned.
+type SamplingConfig struct {
+	Enabled   bool    `json:"enabled"`
+	AllowRate float64 `json:"allow_rate"`
+	DenyRate  float64 `json:"deny_rate"`
+}
+
+// RecorderStats are counters describing what the recorder kept and discarded.
+type RecorderStats struct {
+	Sampling   SamplingConfig `json:"sampling"`
+	Recorded   uint64         `json:"recorded"`
+	SampledOut uint64         `json:"sampled_out"`
+	Dropped    uint64         `json:"dropped"`
+	Failed     uint64         `json:"failed"`
+	QueueDepth int            `json:"queue_depth"`
+	QueueSize  int            `json:"queue_size"`
+}
+
+// EvaluationRecorder persists policy evaluations to policy_evaluations in batches.
+// Record never blocks the request path: a full queue drops the evaluation.
+type EvaluationRecorder struct {
+	db            *gorm.DB
+	queue         chan models.PolicyEvaluation
+	batchSize     int
+	flushInterval time.Duration
+
+	samplingMu sync.RWMutex
+	sampling   SamplingConfig
+
+	recorded   atomic.Uint64
+	sampledOut atomic.Uint64
+	dropped    atomic.Uint64
+	failed     atomic.Uint64
+
+	stopChan chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewEvaluationRecorder creates a recorder with the given sampling configuration.
+func NewEvaluationRecorder(db *gorm.DB, sampling SamplingConfig, queueSize int, flushInterval time.Duration) *EvaluationRecorder {
+	if queueSize <= 0 {
+		queueSize = defaultEvaluationQueueSize
+	}
+	if flushInterval <= 0 {
+		flushInterval = defaultEvaluationFlushInterval
+	}
+	return &EvaluationRecorder{
+		db:            db,
+		queue:         make(chan models.PolicyEvaluation, queueSize),
+		batchSize:     defaultEvaluationBatchSize,
+		flushInterval: flushInterval,
+		sampling:      normalizeSampling(sampling),
+		stopChan:      make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+// SamplingFromEnv reads POLICY_EVAL_LOG_ENABLED, POLICY_EVAL_SAMPLE_RATE_ALLOW and
+// POLICY_EVAL_SAMPLE_RATE_DENY. Every decision is recorded by default.
+func SamplingFromEnv() SamplingConfig {
+	return SamplingConfig{
+		Enabled:   !strings.EqualFold(strings.TrimSpace(os.Getenv("POLICY_EVAL_LOG_ENABLED")), "false"),
+		AllowRate: envRate("POLICY_EVAL_SAMPLE_RATE_ALLOW", 1),
+		DenyRate:  envRate("POLICY_EVAL_SAMPLE_RATE_DENY", 1),
+	}
+}
+
+// Start runs the background writer.
+func (er *EvaluationRecorder) Start() {
+	go func() {
+		defer close(er.done)
+		ticker := time.NewTicker(er.flushInterval)
+		defer ticker.Stop()
+
+		batch := make([]models.PolicyEvaluation, 0, er.batchSize)
+		for {
+			select {
+			case <-er.stopChan:
+				// Drain whatever is still queued before exiting.
+				for {
+					select {
+					case evaluation := <-er.queue:
+						batch = append(batch, evaluation)
+						if len(batch) >= er.batchSize {
+							batch = er.write(batch)
+						}
+					default:
+						er.write(batch)
+						return
+					}
+				}
+			case evaluation := <-er.queue:
+				batch = append(batch, evaluation)
+				if len(batch) >= er.batchSize {
+					batch = er.write(batch)
+				}
+			case <-ticker.C:
+				batch = er.write(batch)
+			}
+		}
+	}()
+
+	log.Printf("Policy evaluation recorder started (allow rate %.2f, deny rate %.2f)", er.sampling.AllowRate, er.sampling.DenyRate)
+}
+
+// Stop flushes queued evaluations and stops the writer.
+func (er *EvaluationRecorder) Stop() {
+	er.stopOnce.Do(func() {
+		close(er.stopChan)
+		<-er.done
+	})
+}
+
+// Record queues an evaluation if it passes sampling.
+func (er *EvaluationRecorder) Record(evaluation models.PolicyEvaluation) {
+	if !er.Sample(evaluation.Effect == models.PolicyEffectAllow) {
+		return
+	}
+	er.Enqueue(evaluation)
+}
+
+// Enqueue queues an evaluation that the caller has already sampled with Sample.
+func (er *EvaluationRecorder) Enqueue(evaluation models.PolicyEvaluation) {
+	if evaluation.EvaluationTime.IsZero() {
+		evaluation.EvaluationTime = time.Now()
+	}
+
+	select {
+	case er.queue <- evaluation:
+	default:
+		er.dropped.Add(1)
+	}
+}
+
+// Sample reports whether a decision with the given outcome should be recorded.
+// Callers can use it to skip building an evaluation that would be discarded.
+func (er *EvaluationRecorder) Sample(allowed bool) bool {
+	sampling := er.Sampling()
+	rate := sampling.DenyRate
+	if allowed {
+		rate = sampling.AllowRate
+	}
+	if sampling.Enabled && (rate >= 1 || (rate > 0 && rand.Float64() < rate)) {
+		return true
+	}
+	er.sampledOut.Add(1)
+	return false
+}
+
+// Sampling returns the current sampling configuration.
+func (er *EvaluationRecorder) Sampling() SamplingConfig {
+	er.samplingMu.RLock()
+	defer er.samplingMu.RUnlock()
+	return er.sampling
+}
+
+// SetSampling replaces the sampling configuration until the next restart.
+func (er *EvaluationRecorder) SetSampling(sampling SamplingConfig) SamplingConfig {
+	sampling = normalizeSampling(sampling)
+	er.samplingMu.Lock()
+	er.sampling = sampling
+	er.samplingMu.Unlock()
+	return sampling
+}
+
+// Stats returns the recorder counters.
+func (er *EvaluationRecorder) Stats() RecorderStats {
+	return RecorderStats{
+		Sampling:   er.Sampling(),
+		Recorded:   er.recorded.Load(),
+		SampledOut: er.sampledOut.Load(),
+		Dropped:    er.dropped.Load(),
+		Failed:     er.failed.Load(),
+		QueueDepth: len(er.queue),
+		QueueSize:  cap(er.queue),
+	}
+}
+
+func (er *EvaluationRecorder) write(batch []models.PolicyEvaluation) []models.PolicyEvaluation {
+	if len(batch) == 0 {
+		return batch
+	}
+	if err := er.db.CreateInBatches(batch, er.batchSize).Error; err != nil {
+		er.failed.Add(uint64(len(batch)))
+		log.Printf("Error writing %d policy evaluations: %v", len(batch), err)
+	} else {
+		er.recorded.Add(uint64(len(batch)))
+	}
+	return batch[:0]
+}
+
+var (
+	defaultRecorderMu sync.RWMutex
+	defaultRecorder   *EvaluationRecorder
+)
+
+// SetDefaultRecorder installs the process-wide evaluation recorder.
+func SetDefaultRecorder(er *EvaluationRecorder) {
+	defaultRecorderMu.Lock()
+	defaultRecorder = er
+	defaultRecorderMu.Unlock()
+}
+
+// DefaultRecorder returns the process-wide recorder, or nil when evaluation logging is not configured.
+func DefaultRecorder() *EvaluationRecorder {
+	defaultRecorderMu.RLock()
+	defer defaultRecorderMu.RUnlock()
+	return defaultRecorder
+}
+
+func normalizeSampling(sampling SamplingConfig) SamplingConfig {
+	sampling.AllowRate = clampRate(sampling.AllowRate)
+	sampling.DenyRate = clampRate(sampling.DenyRate)
+	return sampling
+}
+
+func clampRate(rate float64) float64 {
+	if rate < 0 {
+		return 0
+	}
+	if rate > 1 {
+		return 1
+	}
+	return rate
+}
+
+func envRate(key string, fallback float64) float64 {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return fallback
+	}
+	rate, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return fallback
+	}
+	return clampRate(rate)
+}
@@ -0,0 +1,200 @@
+package abac
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// timeLayouts are the accepted formats for time-valued attributes.
+var timeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+func parseAttributeTime(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// compareTimes compares actual and expected when both parse as timestamps or dates.
+// ok is false when either side is not a time value.
+func compareTimes(actual string, expected interface{}) (cmp int, ok bool) {
+	expectedStr, isString := expected.(string)
+	if !isString {
+		return 0, false
+	}
+	a, okA := parseAttributeTime(actual)
+	e, okE := parseAttributeTime(expectedStr)
+	if !okA || !okE {
+		return 0, false
+	}
+	switch {
+	case a.Before(e):
+		return -1, true
+	case a.After(e):
+		return 1, true
+	default:
+		return 0, true
+	}
+}
+
+// ipInCIDR reports whether actual is an IP address inside any of the expected
+// CIDR blocks. expected is a single CIDR/IP string or an array of them.
+func ipInCIDR(actual string, expected interface{}) (bool, error) {
+	ip := net.ParseIP(strings.TrimSpace(actual))
+	if ip == nil {
+		return false, nil
+	}
+
+	var blocks []string
+	switch v := expected.(type) {
+	case string:
+		blocks = []string{v}
+	case []string:
+		blocks = v
+	case []interface{}:
+		for _, item := range v {
+			blocks = append(blocks, fmt.Sprintf("%v", item))
+		}
+	default:
+		return false, fmt.Errorf("IP_IN_CIDR requires a CIDR string or array")
+	}
+
+	for _, block := range blocks {
+		block = strings.TrimSpace(block)
+		if !strings.Contains(block, "/") {
+			if other := net.ParseIP(block); other != nil && other.Equal(ip) {
+				return true, nil
+			}
+			continue
+		}
+		_, network, err := net.ParseCIDR(block)
+		if err != nil {
+			return false, fmt.Errorf("invalid CIDR %q", block)
+		}
+		if network.Contains(ip) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// timeWindow is the value of a TIME_WINDOW condition, e.g.
+//
+//	{"start": "09:00", "end": "18:00", "days": ["Monday", "Friday"], "timezone": "Asia/Kolkata"}
+//
+// or the short form ["09:00", "18:00"]. Times are in UTC unless a timezone is given,
+// so a policy means the same wherever the server runs. Windows whose end is before
+// their start wrap past midnight (e.g. 22:00-06:00).
+type timeWindow struct {
+	start    int // minutes since midnight
+	end      int
+	days     map[time.Weekday]bool
+	location *time.Location
+}
+
+func parseTimeWindow(expected interface{}) (*timeWindow, error) {
+	window := &timeWindow{location: time.UTC}
+
+	var startStr, endStr string
+	switch v := expected.(type) {
+	case []interface{}:
+		if len(v) != 2 {
+			return nil, fmt.Errorf("TIME_WINDOW requires [start, end]")
+		}
+		startStr, endStr = fmt.Sprintf("%v", v[0]), fmt.Sprintf("%v", v[1])
+	case map[string]interface{}:
+		startStr, _ = v["start"].(string)
+		endStr, _ = v["end"].(string)
+		if tz, ok := v["timezone"].(string); ok && tz != "" {
+			loc, err := time.LoadLocation(tz)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timezone %q", tz)
+			}
+			window.location = loc
+		}
+		if days, ok := v["days"].([]interface{}); ok && len(days) > 0 {
+			window.days = make(map[time.Weekday]bool, len(days))
+			for _, d := range days {
+				day, err := parseWeekday(fmt.Sprintf("%v", d))
+				if err != nil {
+					return nil, err
+				}
+				window.days[day] = true
+			}
+		}
+	default:
+		return nil, fmt.Errorf("TIME_WINDOW requires an object or [start, end]")
+	}
+
+	var err error
+	if window.start, err = parseClock(startStr); err != nil {
+		return nil, err
+	}
+	if window.end, err = parseClock(endStr); err != nil {
+		return nil, err
+	}
+	return window, nil
+}
+
+// contains reports whether t falls inside the window in the window's timezone.
+func (w *timeWindow) contains(t time.Time) bool {
+	local := t.In(w.location)
+	minute := local.Hour()*60 + local.Minute()
+
+	day := local.Weekday()
+	inRange := false
+	if w.start <= w.end {
+		inRange = minute >= w.start && minute < w.end
+	} else {
+		// Overnight window: the part after midnight belongs to the previous day's window.
+		if minute >= w.start {
+			inRange = true
+		} else if minute < w.end {
+			inRange = true
+			day = (day + 6) % 7
+		}
+	}
+
+	if !inRange {
+		return false
+	}
+	return w.days == nil || w.days[day]
+}
+
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func parseWeekday(value string) (time.Weekday, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if value == name || value == name[:3] {
+			return d, nil
+		}
+	}
+	return time.Sunday, fmt.Errorf("invalid weekday %q", value)
+}
+
+// inTimeWindow evaluates actual (an RFC3339 timestamp, normally
+// environment.timestamp) against a TIME_WINDOW value.
+func inTimeWindow(actual string, expected interface{}) (bool, error) {
+	window, err := parseTimeWindow(expected)
+	if err != nil {
+		return false, err
+	}
+	t, ok := parseAttributeTime(actual)
+	if !ok {
+		return false, nil
+	}
+	return window.contains(t), nil
+}
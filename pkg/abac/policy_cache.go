@@ -0,0 +1,92 @@
+package abac
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/models"
+)
+
+const defaultPolicyCacheTTL = 30 * time.Second
+
+// activePolicyCache holds every ACTIVE policy ordered by priority. Validity windows
+// are checked at evaluation time so the cached set stays correct between reloads.
+type activePolicyCache struct {
+	mu        sync.RWMutex
+	policies  []models.Policy
+	expiresAt time.Time
+}
+
+var (
+	policyCache     activePolicyCache
+	policyLoadGroup singleflight.Group
+	policyCacheTTL  = loadPolicyCacheTTL()
+)
+
+func loadPolicyCacheTTL() time.Duration {
+	raw := strings.TrimSpace(os.Getenv("ABAC_POLICY_CACHE_TTL"))
+	if raw == "" {
+		return defaultPolicyCacheTTL
+	}
+	ttl, err := time.ParseDuration(raw)
+	if err != nil || ttl < 0 {
+		return defaultPolicyCacheTTL
+	}
+	return ttl
+}
+
+// InvalidatePolicyCache drops the cached active policy set. Call it after any
+// policy is created, updated, activated, deactivated or deleted.
+func InvalidatePolicyCache() {
+	policyCache.mu.Lock()
+	policyCache.policies = nil
+	policyCache.expiresAt = time.Time{}
+	policyCache.mu.Unlock()
+}
+
+// loadActivePolicies returns active policies that are currently within their
+// validity window, highest priority first.
+func loadActivePolicies(db *gorm.DB) ([]models.Policy, error) {
+	policyCache.mu.RLock()
+	cached := policyCache.policies
+	fresh := cached != nil && time.Now().Before(policyCache.expiresAt)
+	policyCache.mu.RUnlock()
+
+	if !fresh {
+		loaded, err, _ := policyLoadGroup.Do("active", func() (interface{}, error) {
+			var policies []models.Policy
+			if err := db.Where("status = ?", models.PolicyStatusActive).
+				Order("priority DESC").
+				Find(&policies).Error; err != nil {
+				return nil, err
+			}
+
+			policyCache.mu.Lock()
+			policyCache.policies = policies
+			policyCache.expiresAt = time.Now().Add(policyCacheTTL)
+			policyCache.mu.Unlock()
+			return policies, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		cached = loaded.([]models.Policy)
+	}
+
+	now := time.Now()
+	active := make([]models.Policy, 0, len(cached))
+	for _, policy := range cached {
+		if policy.ValidFrom.After(now) {
+			continue
+		}
+		if policy.ValidUntil != nil && !policy.ValidUntil.After(now) {
+			continue
+		}
+		active = append(active, policy)
+	}
+	return active, nil
+}
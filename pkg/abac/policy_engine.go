@@ -27,13 +27,8 @@ func (pe *PolicyEngine) EvaluateRequest(req models.PolicyRequest) (*models.Polic
 	startTime := time.Now()
 
 	// Get all active policies sorted by priority (highest first)
-	var policies []models.Policy
-	query := pe.db.Where("status = ?", models.PolicyStatusActive).
-		Where("valid_from <= ?", time.Now()).
-		Where("valid_until IS NULL OR valid_until > ?", time.Now()).
-		Order("priority DESC")
-
-	if err := query.Find(&policies).Error; err != nil {
+	policies, err := loadActivePolicies(pe.db)
+	if err != nil {
 		return nil, fmt.Errorf("failed to fetch policies: %v", err)
 	}
 
@@ -51,9 +46,10 @@ func (pe *PolicyEngine) EvaluateRequest(req models.PolicyRequest) (*models.Polic
 
 	var denyPolicies []uuid.UUID
 	var allowPolicies []uuid.UUID
+	var firstDeny, firstAllow *models.Policy
 
 	// Evaluate each policy
-	for _, policy := range policies {
+	for i, policy := range policies {
 		// Check if policy applies to this action
 		if !pe.policyAppliesToAction(policy, req.Action) {
 			continue
@@ -64,6 +60,11 @@ func (pe *PolicyEngine) EvaluateRequest(req models.PolicyRequest) (*models.Polic
 			continue
 		}
 
+		// Vertical-scoped policies only apply to requests in that vertical
+		if !pe.policyAppliesToBusiness(policy, req.BusinessVerticalID) {
+			continue
+		}
+
 		// Evaluate policy conditions
 		matches, err := pe.evaluateConditions(policy.Conditions, context)
 		if err != nil {
@@ -76,12 +77,15 @@ func (pe *PolicyEngine) EvaluateRequest(req models.PolicyRequest) (*models.Polic
 
 			if policy.Effect == models.PolicyEffectDeny {
 				denyPolicies = append(denyPolicies, policy.ID)
+				if firstDeny == nil {
+					firstDeny = &policies[i]
+				}
 			} else if policy.Effect == models.PolicyEffectAllow {
 				allowPolicies = append(allowPolicies, policy.ID)
+				if firstAllow == nil {
+					firstAllow = &policies[i]
+				}
 			}
-
-			// Log evaluation asynchronously
-			go pe.logEvaluation(policy.ID, req, policy.Effect, context, time.Since(startTime))
 		}
 	}
 
@@ -103,12 +107,24 @@ func (pe *PolicyEngine) EvaluateRequest(req models.PolicyRequest) (*models.Polic
 
 	decision.Context = context
 
+	deciding := firstAllow
+	if !decision.Allowed {
+		deciding = firstDeny
+	}
+	pe.logEvaluation(deciding, req, decision, time.Since(startTime))
+
 	return decision, nil
 }
 
 // buildContext creates a complete context map from the request
 func (pe *PolicyEngine) buildContext(req models.PolicyRequest) map[string]string {
 	context := make(map[string]string)
+	now := time.Now()
+
+	// Add resolved attributes first so assigned and request attributes override them
+	for k, v := range ResolveAttributes(pe.db, req, now) {
+		context[k] = v
+	}
 
 	// Add user attributes
 	for k, v := range req.UserAttributes {
@@ -132,13 +148,18 @@ func (pe *PolicyEngine) buildContext(req models.PolicyRequest) map[string]string
 	if req.ResourceID != nil {
 		context["resource.id"] = req.ResourceID.String()
 	}
+	if req.BusinessVerticalID != nil {
+		context["business.id"] = req.BusinessVerticalID.String()
+	}
 
 	// Add time-based attributes
-	now := time.Now()
 	context["environment.hour"] = strconv.Itoa(now.Hour())
 	context["environment.day_of_week"] = now.Weekday().String()
 	context["environment.date"] = now.Format("2006-01-02")
-	context["environment.timestamp"] = now.Format(time.RFC3339)
+	context["environment.time"] = now.Format("15:04")
+	if _, ok := context["environment.timestamp"]; !ok {
+		context["environment.timestamp"] = now.Format(time.RFC3339)
+	}
 
 	return context
 }
@@ -237,43 +258,47 @@ func (pe *PolicyEngine) evaluateCondition(condition models.JSONMap, context map[
 // evaluateOperator evaluates a comparison operator
 func (pe *PolicyEngine) evaluateOperator(actual string, operator string, expected interface{}) (bool, error) {
 	switch strings.ToUpper(operator) {
-	case "=", "==", "EQUALS":
+	case "=", "==", "EQ", "EQUALS":
 		return actual == fmt.Sprintf("%v", expected), nil
 
-	case "!=", "NOT_EQUALS":
+	case "!=", "NE", "NOT_EQUALS":
 		return actual != fmt.Sprintf("%v", expected), nil
 
-	case ">", "GREATER_THAN":
-		actualNum, err1 := strconv.ParseFloat(actual, 64)
-		expectedNum, err2 := pe.toFloat64(expected)
-		if err1 != nil || err2 != nil {
-			return false, nil
-		}
-		return actualNum > expectedNum, nil
+	case ">", "GT", "GREATER_THAN", "AFTER":
+		cmp, ok := pe.compareOrdered(actual, expected)
+		return ok && cmp > 0, nil
 
-	case "<", "LESS_THAN":
-		actualNum, err1 := strconv.ParseFloat(actual, 64)
-		expectedNum, err2 := pe.toFloat64(expected)
-		if err1 != nil || err2 != nil {
-			return false, nil
-		}
-		return actualNum < expectedNum, nil
+	case "<", "LT", "LESS_THAN", "BEFORE":
+		cmp, ok := pe.compareOrdered(actual, expected)
+		return ok && cmp < 0, nil
 
-	case ">=", "GREATER_THAN_OR_EQUAL":
-		actualNum, err1 := strconv.ParseFloat(actual, 64)
-		expectedNum, err2 := pe.toFloat64(expected)
-		if err1 != nil || err2 != nil {
-			return false, nil
+	case ">=", "GTE", "GREATER_THAN_OR_EQUAL":
+		cmp, ok := pe.compareOrdered(actual, expected)
+		return ok && cmp >= 0, nil
+
+	case "<=", "LTE", "LESS_THAN_OR_EQUAL":
+		cmp, ok := pe.compareOrdered(actual, expected)
+		return ok && cmp <= 0, nil
+
+	case "IP_IN_CIDR", "IN_CIDR":
+		return ipInCIDR(actual, expected)
+
+	case "IP_NOT_IN_CIDR", "NOT_IN_CIDR":
+		result, err := ipInCIDR(actual, expected)
+		if err != nil {
+			return false, err
 		}
-		return actualNum >= expectedNum, nil
+		return !result, nil
 
-	case "<=", "LESS_THAN_OR_EQUAL":
-		actualNum, err1 := strconv.ParseFloat(actual, 64)
-		expectedNum, err2 := pe.toFloat64(expected)
-		if err1 != nil || err2 != nil {
-			return false, nil
+	case "TIME_WINDOW", "IN_TIME_WINDOW":
+		return inTimeWindow(actual, expected)
+
+	case "NOT_IN_TIME_WINDOW":
+		result, err := inTimeWindow(actual, expected)
+		if err != nil {
+			return false, err
 		}
-		return actualNum <= expectedNum, nil
+		return !result, nil
 
 	case "IN":
 		expectedArray, ok := expected.([]interface{})
@@ -339,6 +364,24 @@ func (pe *PolicyEngine) evaluateOperator(actual string, operator string, expecte
 	}
 }
 
+// compareOrdered compares actual with expected numerically, falling back to
+// timestamp comparison. ok is false when the values are not comparable.
+func (pe *PolicyEngine) compareOrdered(actual string, expected interface{}) (int, bool) {
+	actualNum, err1 := strconv.ParseFloat(actual, 64)
+	expectedNum, err2 := pe.toFloat64(expected)
+	if err1 == nil && err2 == nil {
+		switch {
+		case actualNum < expectedNum:
+			return -1, true
+		case actualNum > expectedNum:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+	return compareTimes(actual, expected)
+}
+
 // toFloat64 converts various types to float64
 func (pe *PolicyEngine) toFloat64(value interface{}) (float64, error) {
 	switch v := value.(type) {
@@ -396,26 +439,95 @@ func (pe *PolicyEngine) policyAppliesToResource(policy models.Policy, resourceTy
 	return false
 }
 
-// logEvaluation logs policy evaluation for audit
-func (pe *PolicyEngine) logEvaluation(policyID uuid.UUID, req models.PolicyRequest, effect models.PolicyEffect, context map[string]string, duration time.Duration) {
+// policyAppliesToBusiness checks if policy applies to the request's business vertical
+func (pe *PolicyEngine) policyAppliesToBusiness(policy models.Policy, businessVerticalID *uuid.UUID) bool {
+	if policy.BusinessVerticalID == nil {
+		return true // Global policy
+	}
+	return businessVerticalID != nil && *policy.BusinessVerticalID == *businessVerticalID
+}
+
+// HasApplicablePolicies reports whether any active policy could apply to the
+// action and resource type. Callers use it to skip attribute loading entirely
+// on the hot path when no policy targets the request.
+func (pe *PolicyEngine) HasApplicablePolicies(action string, resourceType string, businessVerticalID *uuid.UUID) (bool, error) {
+	policies, err := loadActivePolicies(pe.db)
+	if err != nil {
+		return false, err
+	}
+	for _, policy := range policies {
+		if pe.policyAppliesToAction(policy, action) &&
+			pe.policyAppliesToResource(policy, resourceType) &&
+			pe.policyAppliesToBusiness(policy, businessVerticalID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// MatchingUserPolicies returns the active policies whose conditions hold for the
+// request's user and environment attributes alone, ignoring action and resource
+// filters. It is used to explain which permissions policies grant or withhold.
+func (pe *PolicyEngine) MatchingUserPolicies(req models.PolicyRequest) ([]models.Policy, error) {
+	policies, err := loadActivePolicies(pe.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch policies: %v", err)
+	}
+
+	context := pe.buildContext(req)
+	matched := make([]models.Policy, 0)
+	for _, policy := range policies {
+		if !pe.policyAppliesToBusiness(policy, req.BusinessVerticalID) {
+			continue
+		}
+		if ok, err := pe.evaluateConditions(policy.Conditions, context); err == nil && ok {
+			matched = append(matched, policy)
+		}
+	}
+	return matched, nil
+}
+
+// logEvaluation queues the decision on the evaluation recorder for audit.
+// deciding is the highest-priority policy that produced the decision, if any.
+func (pe *PolicyEngine) logEvaluation(deciding *models.Policy, req models.PolicyRequest, decision *models.PolicyDecision, duration time.Duration) {
+	recorder := DefaultRecorder()
+	if recorder == nil {
+		return
+	}
+
 	// Convert map[string]string to models.JSONMap (map[string]interface{})
 	jsonContext := make(models.JSONMap)
-	for k, v := range context {
+	for k, v := range decision.Context {
 		jsonContext[k] = v
 	}
 
+	matched := make(models.JSONArray, 0, len(decision.MatchedPolicies))
+	for _, id := range decision.MatchedPolicies {
+		matched = append(matched, id.String())
+	}
+
 	evaluation := models.PolicyEvaluation{
-		PolicyID:           policyID,
+		Source:             models.PolicyEvaluationSourceABAC,
 		UserID:             req.UserID,
+		BusinessVerticalID: req.BusinessVerticalID,
 		ResourceType:       req.ResourceType,
 		ResourceID:         req.ResourceID,
 		Action:             req.Action,
-		Effect:             effect,
+		Effect:             decision.Effect,
+		Reason:             decision.Reason,
 		Context:            jsonContext,
+		MatchedConditions:  matched,
 		EvaluationTime:     time.Now(),
+		IPAddress:          req.Environment["environment.ip_address"],
+		UserAgent:          req.Environment["environment.user_agent"],
+		RequestPath:        req.Environment["environment.request_path"],
 		EvaluationDuration: int(duration.Milliseconds()),
+		LatencyMicros:      duration.Microseconds(),
+	}
+	if deciding != nil {
+		evaluation.PolicyID = &deciding.ID
+		evaluation.PolicyName = deciding.Name
 	}
 
-	// Store in database (ignore errors for async logging)
-	pe.db.Create(&evaluation)
+	recorder.Record(evaluation)
 }
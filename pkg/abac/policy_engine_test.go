@@ -0,0 +1,147 @@
+package abac
+
+import (
+	"testing"
+	"time"
+
+	"p9e.in/ugcl/models"
+)
+
+func TestEvaluateOperatorIPCIDR(t *testing.T) {
+	pe := &PolicyEngine{}
+
+	cases := []struct {
+		ip       string
+		expected interface{}
+		want     bool
+	}{
+		{"10.20.1.5", "10.20.0.0/16", true},
+		{"192.168.1.9", "10.20.0.0/16", false},
+		{"192.168.1.9", []interface{}{"10.0.0.0/8", "192.168.1.0/24"}, true},
+		{"203.0.113.7", []interface{}{"203.0.113.7"}, true},
+		{"not-an-ip", "10.0.0.0/8", false},
+	}
+	for _, tc := range cases {
+		got, err := pe.evaluateOperator(tc.ip, "ip_in_cidr", tc.expected)
+		if err != nil {
+			t.Fatalf("ip_in_cidr(%s): %v", tc.ip, err)
+		}
+		if got != tc.want {
+			t.Errorf("ip_in_cidr(%s, %v) = %v, want %v", tc.ip, tc.expected, got, tc.want)
+		}
+	}
+
+	if _, err := pe.evaluateOperator("10.0.0.1", "IP_IN_CIDR", "10.0.0.0/99"); err == nil {
+		t.Error("expected error for invalid CIDR")
+	}
+}
+
+func TestEvaluateOperatorTimeWindow(t *testing.T) {
+	pe := &PolicyEngine{}
+
+	officeHours := map[string]interface{}{
+		"start":    "09:00",
+		"end":      "18:00",
+		"days":     []interface{}{"Mon", "Tue", "Wed", "Thu", "Fri"},
+		"timezone": "Asia/Kolkata",
+	}
+	nightShift := []interface{}{"22:00", "06:00"}
+
+	cases := []struct {
+		timestamp string
+		window    interface{}
+		want      bool
+	}{
+		{"2026-10-14T05:00:00Z", officeHours, true},  // Wed 10:30 IST
+		{"2026-10-14T13:00:00Z", officeHours, false}, // Wed 18:30 IST
+		{"2026-10-17T05:00:00Z", officeHours, false}, // Sat 10:30 IST
+		{"2026-10-14T23:30:00Z", nightShift, true},
+		{"2026-10-14T03:00:00Z", nightShift, true},
+		{"2026-10-14T12:00:00Z", nightShift, false},
+	}
+	for _, tc := range cases {
+		got, err := pe.evaluateOperator(tc.timestamp, "TIME_WINDOW", tc.window)
+		if err != nil {
+			t.Fatalf("time_window(%s): %v", tc.timestamp, err)
+		}
+		if got != tc.want {
+			t.Errorf("time_window(%s, %v) = %v, want %v", tc.timestamp, tc.window, got, tc.want)
+		}
+	}
+}
+
+func TestEvaluateOperatorTimeWindowDefaultsToUTC(t *testing.T) {
+	pe := &PolicyEngine{}
+
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	restore := time.Local
+	time.Local = kolkata
+	defer func() { time.Local = restore }()
+
+	// 03:00 UTC is 08:30 on a server running in IST
+	got, err := pe.evaluateOperator("2026-10-14T03:00:00Z", "TIME_WINDOW", []interface{}{"02:00", "04:00"})
+	if err != nil {
+		t.Fatalf("time_window: %v", err)
+	}
+	if !got {
+		t.Error("window without a timezone was not evaluated in UTC")
+	}
+}
+
+func TestEvaluateOperatorComparisonAliases(t *testing.T) {
+	pe := &PolicyEngine{}
+
+	cases := []struct {
+		actual   string
+		operator string
+		expected interface{}
+		want     bool
+	}{
+		{"admin", "eq", "admin", true},
+		{"admin", "ne", "admin", false},
+		{"5", "gt", float64(3), true},
+		{"5", "lt", "3", false},
+		{"2026-10-16", "lt", "2026-12-31", true},
+		{"2026-10-16T10:00:00Z", "after", "2026-10-16T09:00:00Z", true},
+		{"site_engineer", "in", []interface{}{"site_engineer", "manager"}, true},
+	}
+	for _, tc := range cases {
+		got, err := pe.evaluateOperator(tc.actual, tc.operator, tc.expected)
+		if err != nil {
+			t.Fatalf("%s %s %v: %v", tc.actual, tc.operator, tc.expected, err)
+		}
+		if got != tc.want {
+			t.Errorf("%s %s %v = %v, want %v", tc.actual, tc.operator, tc.expected, got, tc.want)
+		}
+	}
+}
+
+func TestEvaluateConditionsNestedTree(t *testing.T) {
+	pe := &PolicyEngine{}
+	context := map[string]string{
+		"user.department":        "finance",
+		"environment.ip_address": "10.1.2.3",
+		"environment.timestamp":  "2026-10-14T05:00:00Z",
+	}
+
+	conditions := models.JSONMap{
+		"AND": []interface{}{
+			map[string]interface{}{"attribute": "user.department", "operator": "eq", "value": "finance"},
+			map[string]interface{}{"attribute": "environment.ip_address", "operator": "ip_in_cidr", "value": "10.0.0.0/8"},
+			map[string]interface{}{"NOT": map[string]interface{}{
+				"attribute": "environment.timestamp", "operator": "time_window", "value": []interface{}{"00:00", "04:00"},
+			}},
+		},
+	}
+
+	matches, err := pe.evaluateConditions(conditions, context)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !matches {
+		t.Error("expected nested condition tree to match")
+	}
+}
@@ -2,6 +2,7 @@ package abac
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -27,9 +28,13 @@ func (ps *PolicyService) CreatePolicy(policy models.Policy) (*models.Policy, err
 		return nil, fmt.Errorf("policy with name '%s' already exists", policy.Name)
 	}
 
-	// Validate conditions
-	if err := ps.validateConditions(policy.Conditions); err != nil {
-		return nil, fmt.Errorf("invalid conditions: %v", err)
+	// Validate fields and conditions against the attribute catalog
+	validation, err := ps.ValidatePolicy(policy)
+	if err != nil {
+		return nil, err
+	}
+	if err := validation.Err(); err != nil {
+		return nil, err
 	}
 
 	// Set defaults
@@ -54,14 +59,26 @@ func (ps *PolicyService) UpdatePolicy(id uuid.UUID, updates models.Policy) (*mod
 		return nil, fmt.Errorf("policy not found: %v", err)
 	}
 
-	// Validate conditions if being updated
-	if updates.Conditions != nil {
-		if err := ps.validateConditions(updates.Conditions); err != nil {
-			return nil, fmt.Errorf("invalid conditions: %v", err)
-		}
+	policy = mergePolicyUpdates(policy, updates)
+
+	// Validate the merged policy so a partial update cannot leave it inconsistent
+	validation, err := ps.ValidatePolicy(policy)
+	if err != nil {
+		return nil, err
+	}
+	if err := validation.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := ps.db.Save(&policy).Error; err != nil {
+		return nil, fmt.Errorf("failed to update policy: %v", err)
 	}
 
-	// Update fields
+	return &policy, nil
+}
+
+// mergePolicyUpdates applies the non-empty fields of updates to policy
+func mergePolicyUpdates(policy, updates models.Policy) models.Policy {
 	if updates.DisplayName != "" {
 		policy.DisplayName = updates.DisplayName
 	}
@@ -91,12 +108,7 @@ func (ps *PolicyService) UpdatePolicy(id uuid.UUID, updates models.Policy) (*mod
 	}
 
 	policy.UpdatedBy = updates.UpdatedBy
-
-	if err := ps.db.Save(&policy).Error; err != nil {
-		return nil, fmt.Errorf("failed to update policy: %v", err)
-	}
-
-	return &policy, nil
+	return policy
 }
 
 // DeletePolicy deletes a policy
@@ -298,66 +310,90 @@ func (ps *PolicyService) ClonePolicy(id, createdBy uuid.UUID, newName string) (*
 	return &clone, nil
 }
 
-// validateConditions validates policy conditions structure
-func (ps *PolicyService) validateConditions(conditions models.JSONMap) error {
-	if conditions == nil || len(conditions) == 0 {
-		return fmt.Errorf("conditions cannot be empty")
+// ValidatePolicy checks a policy before it is saved: required fields, effect, status,
+// validity window and the condition tree against the attribute catalog's data types.
+func (ps *PolicyService) ValidatePolicy(policy models.Policy) (*PolicyValidation, error) {
+	var attributes []models.Attribute
+	if err := ps.db.Find(&attributes).Error; err != nil {
+		return nil, fmt.Errorf("failed to load attributes: %v", err)
 	}
 
-	// Check for logical operators
-	if _, hasAnd := conditions["AND"]; hasAnd {
-		return ps.validateLogicalConditions(conditions["AND"])
+	result := &PolicyValidation{Errors: []ValidationIssue{}, Warnings: []ValidationIssue{}}
+	if strings.TrimSpace(policy.Name) == "" {
+		result.addError("name", "", "name is required")
 	}
-	if _, hasOr := conditions["OR"]; hasOr {
-		return ps.validateLogicalConditions(conditions["OR"])
+	if strings.TrimSpace(policy.DisplayName) == "" {
+		result.addError("display_name", "", "display_name is required")
 	}
-	if _, hasNot := conditions["NOT"]; hasNot {
-		return ps.validateConditions(conditions["NOT"].(map[string]interface{}))
+	if policy.Effect != models.PolicyEffectAllow && policy.Effect != models.PolicyEffectDeny {
+		result.addError("effect", "", "effect must be %s or %s", models.PolicyEffectAllow, models.PolicyEffectDeny)
 	}
-
-	// Validate single condition
-	return ps.validateSingleCondition(conditions)
-}
-
-// validateLogicalConditions validates logical operator conditions
-func (ps *PolicyService) validateLogicalConditions(conditions interface{}) error {
-	condArray, ok := conditions.([]interface{})
-	if !ok {
-		return fmt.Errorf("logical operator conditions must be an array")
+	switch policy.Status {
+	case "", models.PolicyStatusActive, models.PolicyStatusInactive, models.PolicyStatusDraft, models.PolicyStatusArchived:
+	default:
+		result.addError("status", "", "unknown status %q", policy.Status)
 	}
-
-	if len(condArray) == 0 {
-		return fmt.Errorf("logical operator must have at least one condition")
+	if policy.ValidUntil != nil && !policy.ValidFrom.IsZero() && !policy.ValidUntil.After(policy.ValidFrom) {
+		result.addError("valid_until", "", "valid_until must be after valid_from")
 	}
-
-	for _, cond := range condArray {
-		condMap, ok := cond.(map[string]interface{})
-		if !ok {
-			return fmt.Errorf("condition must be an object")
-		}
-		if err := ps.validateConditions(condMap); err != nil {
-			return err
-		}
+	if len(policy.Actions) == 0 {
+		result.addWarning("actions", "", "policy applies to every action")
+	}
+	if len(policy.Resources) == 0 {
+		result.addWarning("resources", "", "policy applies to every resource type")
 	}
 
-	return nil
+	NewConditionValidator(attributes).Validate(policy.Conditions, "conditions", result)
+	result.Valid = len(result.Errors) == 0
+	return result, nil
 }
 
-// validateSingleCondition validates a single condition
-func (ps *PolicyService) validateSingleCondition(condition models.JSONMap) error {
-	if _, ok := condition["attribute"]; !ok {
-		return fmt.Errorf("condition must have 'attribute' field")
+// DryRunPolicy validates a policy without saving it. When id is set the policy is
+// validated as an update of the stored one, and when req is set the (valid) policy is
+// evaluated against it as TestPolicy would.
+func (ps *PolicyService) DryRunPolicy(id *uuid.UUID, policy models.Policy, req *models.PolicyRequest) (*PolicyValidation, error) {
+	if id != nil {
+		var existing models.Policy
+		if err := ps.db.First(&existing, "id = ?", *id).Error; err != nil {
+			return nil, fmt.Errorf("policy not found: %v", err)
+		}
+		policy = mergePolicyUpdates(existing, policy)
+	} else {
+		var existing models.Policy
+		if err := ps.db.Where("name = ?", policy.Name).First(&existing).Error; err == nil {
+			result := &PolicyValidation{Errors: []ValidationIssue{}, Warnings: []ValidationIssue{}}
+			result.addError("name", "", "policy with name '%s' already exists", policy.Name)
+			return result, nil
+		}
 	}
 
-	if _, ok := condition["operator"]; !ok {
-		return fmt.Errorf("condition must have 'operator' field")
+	result, err := ps.ValidatePolicy(policy)
+	if err != nil || !result.Valid || req == nil {
+		return result, err
 	}
 
-	if _, ok := condition["value"]; !ok {
-		return fmt.Errorf("condition must have 'value' field")
+	engine := NewPolicyEngine(ps.db)
+	context := engine.buildContext(*req)
+	matches, err := engine.evaluateConditions(policy.Conditions, context)
+	if err != nil {
+		result.addError("conditions", "", "evaluation failed: %v", err)
+		result.Valid = false
+		return result, nil
 	}
-
-	return nil
+	result.Decision = &models.PolicyDecision{
+		Allowed:           matches && policy.Effect == models.PolicyEffectAllow,
+		Effect:            policy.Effect,
+		MatchedPolicies:   []uuid.UUID{},
+		EvaluationTime:    time.Now(),
+		EvaluatedPolicies: 1,
+		Context:           context,
+	}
+	if matches {
+		result.Decision.Reason = fmt.Sprintf("Policy '%s' matched", policy.DisplayName)
+	} else {
+		result.Decision.Reason = fmt.Sprintf("Policy '%s' did not match", policy.DisplayName)
+	}
+	return result, nil
 }
 
 // GetPolicyStatistics returns statistics about policies
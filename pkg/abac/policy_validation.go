@@ -0,0 +1,410 @@
+package abac
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"p9e.in/ugcl/models"
+)
+
+// ValidationIssue describes one problem found in a policy. Path points at the offending
+// condition, e.g. "conditions.AND[1].NOT".
+type ValidationIssue struct {
+	Path      string `json:"path"`
+	Attribute string `json:"attribute,omitempty"`
+	Message   string `json:"message"`
+}
+
+// PolicyValidation is the result of validating a policy. Errors block saving; warnings
+// (e.g. attributes missing from the catalog) do not.
+type PolicyValidation struct {
+	Valid    bool                   `json:"valid"`
+	Errors   []ValidationIssue      `json:"errors"`
+	Warnings []ValidationIssue      `json:"warnings"`
+	Decision *models.PolicyDecision `json:"decision,omitempty"` // Dry-run evaluation, when a request was supplied
+}
+
+// Err joins the validation errors into one error, nil when the policy is valid
+func (v *PolicyValidation) Err() error {
+	if len(v.Errors) == 0 {
+		return nil
+	}
+	messages := make([]string, 0, len(v.Errors))
+	for _, issue := range v.Errors {
+		messages = append(messages, issue.Path+": "+issue.Message)
+	}
+	return fmt.Errorf("invalid policy: %s", strings.Join(messages, "; "))
+}
+
+func (v *PolicyValidation) addError(path, attribute, format string, args ...interface{}) {
+	v.Errors = append(v.Errors, ValidationIssue{Path: path, Attribute: attribute, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *PolicyValidation) addWarning(path, attribute, format string, args ...interface{}) {
+	v.Warnings = append(v.Warnings, ValidationIssue{Path: path, Attribute: attribute, Message: fmt.Sprintf(format, args...)})
+}
+
+// builtinAttributes are set by the engine on every evaluation (see buildContext)
+var builtinAttributes = map[string]models.AttributeDataType{
+	"user.id":                 models.DataTypeString,
+	"action":                  models.DataTypeString,
+	"resource.type":           models.DataTypeString,
+	"resource.id":             models.DataTypeString,
+	"business.id":             models.DataTypeString,
+	"environment.hour":        models.DataTypeInteger,
+	"environment.day_of_week": models.DataTypeString,
+	"environment.date":        models.DataTypeDateTime,
+	"environment.time":        models.DataTypeString,
+	"environment.timestamp":   models.DataTypeDateTime,
+}
+
+// operatorKind groups the operator aliases accepted by evaluateOperator
+type operatorKind int
+
+const (
+	opEquality operatorKind = iota
+	opOrdered
+	opMembership
+	opString
+	opRegex
+	opRange
+	opCIDR
+	opTimeWindow
+)
+
+var operatorKinds = map[string]operatorKind{
+	"=": opEquality, "==": opEquality, "EQ": opEquality, "EQUALS": opEquality,
+	"!=": opEquality, "NE": opEquality, "NOT_EQUALS": opEquality,
+	">": opOrdered, "GT": opOrdered, "GREATER_THAN": opOrdered, "AFTER": opOrdered,
+	"<": opOrdered, "LT": opOrdered, "LESS_THAN": opOrdered, "BEFORE": opOrdered,
+	">=": opOrdered, "GTE": opOrdered, "GREATER_THAN_OR_EQUAL": opOrdered,
+	"<=": opOrdered, "LTE": opOrdered, "LESS_THAN_OR_EQUAL": opOrdered,
+	"IN": opMembership, "NOT_IN": opMembership,
+	"CONTAINS": opString, "STARTS_WITH": opString, "ENDS_WITH": opString,
+	"MATCHES": opRegex,
+	"BETWEEN": opRange, "NOT_BETWEEN": opRange,
+	"IP_IN_CIDR": opCIDR, "IN_CIDR": opCIDR, "IP_NOT_IN_CIDR": opCIDR, "NOT_IN_CIDR": opCIDR,
+	"TIME_WINDOW": opTimeWindow, "IN_TIME_WINDOW": opTimeWindow, "NOT_IN_TIME_WINDOW": opTimeWindow,
+}
+
+// ConditionValidator checks condition trees against the attribute catalog: every
+// operator must be known, and values must fit the operator and the attribute's data type.
+type ConditionValidator struct {
+	attributes map[string]models.Attribute
+	resolved   map[string]bool
+}
+
+// NewConditionValidator builds a validator for the given attribute catalog. Attributes
+// provided by registered resolvers are accepted without a catalog entry.
+func NewConditionValidator(attributes []models.Attribute) *ConditionValidator {
+	cv := &ConditionValidator{
+		attributes: make(map[string]models.Attribute, len(attributes)),
+		resolved:   make(map[string]bool),
+	}
+	for _, attr := range attributes {
+		cv.attributes[attr.Name] = attr
+	}
+	for _, resolver := range AttributeResolvers() {
+		for _, name := range resolver.Provides {
+			cv.resolved[name] = true
+		}
+	}
+	return cv
+}
+
+// Validate checks a condition tree and records problems on result under path
+func (cv *ConditionValidator) Validate(conditions models.JSONMap, path string, result *PolicyValidation) {
+	if len(conditions) == 0 {
+		result.addError(path, "", "conditions cannot be empty")
+		return
+	}
+
+	for _, op := range []string{"AND", "OR"} {
+		if raw, ok := conditions[op]; ok {
+			children, ok := raw.([]interface{})
+			if !ok || len(children) == 0 {
+				result.addError(path+"."+op, "", "%s requires a non-empty array of conditions", op)
+				return
+			}
+			for i, child := range children {
+				childPath := fmt.Sprintf("%s.%s[%d]", path, op, i)
+				childMap, ok := child.(map[string]interface{})
+				if !ok {
+					result.addError(childPath, "", "condition must be an object")
+					continue
+				}
+				cv.Validate(childMap, childPath, result)
+			}
+			return
+		}
+	}
+	if raw, ok := conditions["NOT"]; ok {
+		child, ok := raw.(map[string]interface{})
+		if !ok {
+			result.addError(path+".NOT", "", "NOT requires a condition object")
+			return
+		}
+		cv.Validate(child, path+".NOT", result)
+		return
+	}
+
+	cv.validateLeaf(conditions, path, result)
+}
+
+func (cv *ConditionValidator) validateLeaf(condition models.JSONMap, path string, result *PolicyValidation) {
+	attribute, _ := condition["attribute"].(string)
+	if strings.TrimSpace(attribute) == "" {
+		result.addError(path, "", "condition must have an 'attribute' string")
+		return
+	}
+	operator, _ := condition["operator"].(string)
+	kind, known := operatorKinds[strings.ToUpper(operator)]
+	if !known {
+		result.addError(path, attribute, "unsupported operator %q", operator)
+		return
+	}
+	value, ok := condition["value"]
+	if !ok {
+		result.addError(path, attribute, "condition must have a 'value' field")
+		return
+	}
+
+	dataType, typed := cv.dataTypeOf(attribute, path, result)
+
+	// Template values are resolved from the context at evaluation time
+	if s, ok := value.(string); ok && strings.HasPrefix(s, "{{") && strings.HasSuffix(s, "}}") {
+		ref := strings.TrimSpace(s[2 : len(s)-2])
+		cv.dataTypeOf(ref, path, result)
+		return
+	}
+
+	switch kind {
+	case opOrdered:
+		if typed && !orderedDataType(dataType) {
+			result.addError(path, attribute, "operator %s cannot compare %s values", operator, dataType)
+			return
+		}
+		if isCollection(value) {
+			result.addError(path, attribute, "operator %s requires a single value", operator)
+			return
+		}
+	case opMembership:
+		values, ok := value.([]interface{})
+		if !ok {
+			result.addError(path, attribute, "operator %s requires an array value", operator)
+			return
+		}
+		if typed {
+			for i, item := range values {
+				if err := checkDataType(dataType, item); err != nil {
+					result.addError(fmt.Sprintf("%s.value[%d]", path, i), attribute, "%v", err)
+				}
+			}
+			cv.checkAllowedValues(attribute, values, path, result)
+		}
+		return
+	case opString:
+		if isCollection(value) {
+			result.addError(path, attribute, "operator %s requires a string value", operator)
+		}
+		return
+	case opRegex:
+		if _, err := regexp.Compile(fmt.Sprintf("%v", value)); err != nil {
+			result.addError(path, attribute, "invalid regular expression: %v", err)
+		}
+		return
+	case opRange:
+		values, ok := value.([]interface{})
+		if !ok || len(values) != 2 {
+			result.addError(path, attribute, "operator %s requires an array of 2 values", operator)
+			return
+		}
+		if typed && dataType != models.DataTypeInteger && dataType != models.DataTypeFloat {
+			result.addError(path, attribute, "operator %s requires a numeric attribute, got %s", operator, dataType)
+			return
+		}
+		for i, item := range values {
+			if _, err := toNumber(item); err != nil {
+				result.addError(fmt.Sprintf("%s.value[%d]", path, i), attribute, "expected a number, got %v", item)
+			}
+		}
+		return
+	case opCIDR:
+		for _, block := range stringValues(value) {
+			if strings.Contains(block, "/") {
+				if _, _, err := net.ParseCIDR(block); err != nil {
+					result.addError(path, attribute, "invalid CIDR %q", block)
+				}
+			} else if net.ParseIP(block) == nil {
+				result.addError(path, attribute, "invalid IP address %q", block)
+			}
+		}
+		return
+	case opTimeWindow:
+		if _, err := parseTimeWindow(value); err != nil {
+			result.addError(path, attribute, "%v", err)
+		}
+		return
+	}
+
+	if typed {
+		if err := checkDataType(dataType, value); err != nil {
+			result.addError(path, attribute, "%v", err)
+			return
+		}
+		if kind == opEquality {
+			cv.checkAllowedValues(attribute, []interface{}{value}, path, result)
+		}
+	}
+}
+
+// dataTypeOf looks up the attribute's data type. Unknown attributes are warned about,
+// not rejected: callers may pass ad-hoc attributes with the evaluation request.
+func (cv *ConditionValidator) dataTypeOf(attribute, path string, result *PolicyValidation) (models.AttributeDataType, bool) {
+	if attr, ok := cv.attributes[attribute]; ok {
+		if !attr.IsActive {
+			result.addWarning(path, attribute, "attribute is inactive")
+		}
+		return attr.DataType, true
+	}
+	if dataType, ok := builtinAttributes[attribute]; ok {
+		return dataType, true
+	}
+	if !cv.resolved[attribute] {
+		result.addWarning(path, attribute, "attribute is not defined in the attribute catalog")
+	}
+	return "", false
+}
+
+// checkAllowedValues enforces the attribute's metadata.allowed_values, when set
+func (cv *ConditionValidator) checkAllowedValues(attribute string, values []interface{}, path string, result *PolicyValidation) {
+	allowed := AllowedValues(cv.attributes[attribute])
+	if allowed == nil {
+		return
+	}
+	for _, value := range values {
+		if s := fmt.Sprintf("%v", value); !allowed[s] {
+			result.addError(path, attribute, "value %q is not one of the attribute's allowed values", s)
+		}
+	}
+}
+
+// AllowedValues returns the set in the attribute's metadata.allowed_values, or nil when
+// any value is allowed
+func AllowedValues(attr models.Attribute) map[string]bool {
+	raw, ok := attr.Metadata["allowed_values"].([]interface{})
+	if !ok || len(raw) == 0 {
+		return nil
+	}
+	allowed := make(map[string]bool, len(raw))
+	for _, v := range raw {
+		allowed[fmt.Sprintf("%v", v)] = true
+	}
+	return allowed
+}
+
+// ValidateAttributeValue checks an assigned (string) value against the attribute's data
+// type and allowed values
+func ValidateAttributeValue(attr models.Attribute, value string) error {
+	if err := checkDataType(attr.DataType, value); err != nil {
+		return fmt.Errorf("invalid value for attribute '%s': %v", attr.Name, err)
+	}
+	if allowed := AllowedValues(attr); allowed != nil && !allowed[value] {
+		return fmt.Errorf("invalid value for attribute '%s': %q is not an allowed value", attr.Name, value)
+	}
+	return nil
+}
+
+// IsValidAttributeType reports whether t is a known attribute category
+func IsValidAttributeType(t models.AttributeType) bool {
+	switch t {
+	case models.AttributeTypeUser, models.AttributeTypeResource, models.AttributeTypeEnvironment, models.AttributeTypeAction:
+		return true
+	}
+	return false
+}
+
+// IsValidAttributeDataType reports whether dt is a known attribute data type
+func IsValidAttributeDataType(dt models.AttributeDataType) bool {
+	switch dt {
+	case models.DataTypeString, models.DataTypeInteger, models.DataTypeFloat, models.DataTypeBoolean,
+		models.DataTypeDateTime, models.DataTypeJSON, models.DataTypeArray:
+		return true
+	}
+	return false
+}
+
+// checkDataType reports whether a condition or assigned value fits the data type.
+// Numbers and booleans may be given as JSON values or as their string form.
+func checkDataType(dataType models.AttributeDataType, value interface{}) error {
+	switch dataType {
+	case models.DataTypeInteger:
+		n, err := toNumber(value)
+		if err != nil || n != float64(int64(n)) {
+			return fmt.Errorf("expected an integer, got %v", value)
+		}
+	case models.DataTypeFloat:
+		if _, err := toNumber(value); err != nil {
+			return fmt.Errorf("expected a number, got %v", value)
+		}
+	case models.DataTypeBoolean:
+		switch v := value.(type) {
+		case bool:
+		case string:
+			if _, err := strconv.ParseBool(v); err != nil {
+				return fmt.Errorf("expected a boolean, got %q", v)
+			}
+		default:
+			return fmt.Errorf("expected a boolean, got %v", value)
+		}
+	case models.DataTypeDateTime:
+		s, ok := value.(string)
+		if _, valid := parseAttributeTime(s); !ok || !valid {
+			return fmt.Errorf("expected a date or RFC3339 timestamp, got %v", value)
+		}
+	case models.DataTypeString:
+		if isCollection(value) {
+			return fmt.Errorf("expected a string, got %v", value)
+		}
+	}
+	return nil
+}
+
+func orderedDataType(dataType models.AttributeDataType) bool {
+	return dataType == models.DataTypeInteger || dataType == models.DataTypeFloat || dataType == models.DataTypeDateTime
+}
+
+func isCollection(value interface{}) bool {
+	switch value.(type) {
+	case []interface{}, map[string]interface{}:
+		return true
+	}
+	return false
+}
+
+func toNumber(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	}
+	return 0, fmt.Errorf("not a number: %v", value)
+}
+
+func stringValues(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{strings.TrimSpace(v)}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			values = append(values, strings.TrimSpace(fmt.Sprintf("%v", item)))
+		}
+		return values
+	}
+	return []string{fmt.Sprintf("%v", value)}
+}
@@ -0,0 +1,78 @@
+package abac
+
+import (
+	"strings"
+	"testing"
+
+	"p9e.in/ugcl/models"
+)
+
+func TestConditionValidatorChecksDataTypes(t *testing.T) {
+	cv := NewConditionValidator([]models.Attribute{
+		{Name: "user.clearance_level", DataType: models.DataTypeInteger, IsActive: true},
+		{Name: "user.department", DataType: models.DataTypeString, IsActive: true,
+			Metadata: models.JSONMap{"allowed_values": []interface{}{"finance", "operations"}}},
+		{Name: "resource.is_confidential", DataType: models.DataTypeBoolean, IsActive: true},
+	})
+
+	cases := []struct {
+		name       string
+		conditions models.JSONMap
+		wantError  string
+	}{
+		{"valid tree", models.JSONMap{"AND": []interface{}{
+			map[string]interface{}{"attribute": "user.clearance_level", "operator": ">=", "value": float64(3)},
+			map[string]interface{}{"attribute": "user.department", "operator": "IN", "value": []interface{}{"finance"}},
+			map[string]interface{}{"NOT": map[string]interface{}{"attribute": "resource.is_confidential", "operator": "=", "value": true}},
+		}}, ""},
+		{"integer mismatch", models.JSONMap{"attribute": "user.clearance_level", "operator": "=", "value": "high"}, "expected an integer"},
+		{"ordered string", models.JSONMap{"attribute": "user.department", "operator": ">", "value": "finance"}, "cannot compare string"},
+		{"disallowed value", models.JSONMap{"attribute": "user.department", "operator": "=", "value": "hr"}, "allowed values"},
+		{"IN without array", models.JSONMap{"attribute": "user.department", "operator": "IN", "value": "finance"}, "array value"},
+		{"unknown operator", models.JSONMap{"attribute": "user.department", "operator": "LIKE", "value": "f%"}, "unsupported operator"},
+		{"bad CIDR", models.JSONMap{"attribute": "environment.ip_address", "operator": "IP_IN_CIDR", "value": "10.0.0.0/99"}, "invalid CIDR"},
+		{"empty OR", models.JSONMap{"OR": []interface{}{}}, "non-empty array"},
+		{"NOT not an object", models.JSONMap{"NOT": "x"}, "condition object"},
+	}
+	for _, tc := range cases {
+		result := &PolicyValidation{}
+		cv.Validate(tc.conditions, "conditions", result)
+		err := result.Err()
+		if tc.wantError == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tc.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+			t.Errorf("%s: expected error containing %q, got %v", tc.name, tc.wantError, err)
+		}
+	}
+}
+
+func TestConditionValidatorWarnsOnUnknownAttributes(t *testing.T) {
+	cv := NewConditionValidator(nil)
+	result := &PolicyValidation{}
+	cv.Validate(models.JSONMap{"AND": []interface{}{
+		map[string]interface{}{"attribute": "user.unknown", "operator": "=", "value": "x"},
+		map[string]interface{}{"attribute": "user.location", "operator": "=", "value": "HYD"}, // resolver provided
+		map[string]interface{}{"attribute": "environment.hour", "operator": "BETWEEN", "value": []interface{}{float64(9), float64(18)}},
+	}}, "conditions", result)
+
+	if len(result.Errors) != 0 {
+		t.Fatalf("unexpected errors: %v", result.Errors)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Attribute != "user.unknown" {
+		t.Errorf("expected one warning for user.unknown, got %v", result.Warnings)
+	}
+}
+
+func TestValidateAttributeValue(t *testing.T) {
+	attr := models.Attribute{Name: "resource.valid_till", DataType: models.DataTypeDateTime}
+	if err := ValidateAttributeValue(attr, "2026-12-31"); err != nil {
+		t.Errorf("date rejected: %v", err)
+	}
+	if err := ValidateAttributeValue(attr, "next week"); err == nil {
+		t.Error("expected error for non-date value")
+	}
+}
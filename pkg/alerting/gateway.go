@@ -0,0 +1,116 @@
+// Package alerting delivers urgent SMS messages and voice calls through an external
+// telephony gateway.
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Message channels understood by the gateway
+const (
+	ChannelSMS   = "sms"
+	ChannelVoice = "voice"
+)
+
+// Gateway sends SMS messages and places text-to-speech voice calls to phone numbers.
+type Gateway interface {
+	Name() string
+	SendSMS(ctx context.Context, to, text string) error
+	PlaceVoiceCall(ctx context.Context, to, text string) error
+}
+
+// NewGatewayFromEnv builds the HTTP gateway configured by ALERT_GATEWAY_URL and the
+// optional ALERT_GATEWAY_TOKEN and ALERT_GATEWAY_SENDER_ID. It returns nil when no URL
+// is configured, in which case callers skip SMS and voice delivery.
+func NewGatewayFromEnv() Gateway {
+	endpoint := strings.TrimSpace(os.Getenv("ALERT_GATEWAY_URL"))
+	if endpoint == "" {
+		return nil
+	}
+	return NewHTTPGateway(
+		endpoint,
+		strings.TrimSpace(os.Getenv("ALERT_GATEWAY_TOKEN")),
+		strings.TrimSpace(os.Getenv("ALERT_GATEWAY_SENDER_ID")),
+	)
+}
+
+// HTTPGateway posts each message as JSON to a provider endpoint (or a small relay in
+// front of one), authenticated with a bearer token:
+//
+//	{"channel": "sms"|"voice", "to": "+91...", "message": "...", "sender_id": "..."}
+//
+// Any 2xx response counts as accepted.
+type HTTPGateway struct {
+	endpoint string
+	token    string
+	senderID string
+	client   *http.Client
+}
+
+// NewHTTPGateway creates an HTTP gateway
+func NewHTTPGateway(endpoint, token, senderID string) *HTTPGateway {
+	return &HTTPGateway{
+		endpoint: endpoint,
+		token:    token,
+		senderID: senderID,
+		client:   &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (g *HTTPGateway) Name() string { return "http" }
+
+// SendSMS sends a text message
+func (g *HTTPGateway) SendSMS(ctx context.Context, to, text string) error {
+	return g.send(ctx, ChannelSMS, to, text)
+}
+
+// PlaceVoiceCall places a call that reads text aloud
+func (g *HTTPGateway) PlaceVoiceCall(ctx context.Context, to, text string) error {
+	return g.send(ctx, ChannelVoice, to, text)
+}
+
+func (g *HTTPGateway) send(ctx context.Context, channel, to, text string) error {
+	to = strings.TrimSpace(to)
+	if to == "" {
+		return fmt.Errorf("%s: no phone number", channel)
+	}
+
+	payload, err := json.Marshal(map[string]string{
+		"channel":   channel,
+		"to":        to,
+		"message":   text,
+		"sender_id": g.senderID,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if g.token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.token)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s gateway request failed: %w", channel, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s gateway returned %d: %s", channel, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPGatewaySend(t *testing.T) {
+	var got map[string]string
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		if got["to"] == "+910000000000" {
+			http.Error(w, "blocked number", http.StatusUnprocessableEntity)
+		}
+	}))
+	defer server.Close()
+
+	gateway := NewHTTPGateway(server.URL, "secret", "UGCL")
+
+	if err := gateway.PlaceVoiceCall(context.Background(), " +919876543210 ", "Evacuate site"); err != nil {
+		t.Fatalf("PlaceVoiceCall() error = %v", err)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	if got["channel"] != ChannelVoice || got["to"] != "+919876543210" || got["message"] != "Evacuate site" || got["sender_id"] != "UGCL" {
+		t.Errorf("unexpected payload %v", got)
+	}
+
+	if err := gateway.SendSMS(context.Background(), "+910000000000", "x"); err == nil {
+		t.Error("expected an error for a non-2xx response")
+	}
+	if err := gateway.SendSMS(context.Background(), "", "x"); err == nil {
+		t.Error("expected an error for a missing phone number")
+	}
+}
@@ -0,0 +1,75 @@
+package breakglass
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/jobrunner"
+)
+
+// Expirer closes break-glass grants whose time box has ended and tells the super admins
+// how much each was used. Authorization already ignores grants past expires_at, so the
+// job only records the expiry in the data and the audit trail.
+type Expirer struct {
+	db *gorm.DB
+	*jobrunner.Runner
+}
+
+// NewExpirer creates a break-glass expiry job
+func NewExpirer(db *gorm.DB) *Expirer {
+	e := &Expirer{db: db}
+	e.Runner = jobrunner.New("Break-glass expiry job", e.run).RunAtStart()
+	return e
+}
+
+func (e *Expirer) run() {
+	if n, err := e.ExpireGrants(time.Now()); err != nil {
+		log.Printf("Error expiring break-glass grants: %v", err)
+	} else if n > 0 {
+		log.Printf("Break-glass expiry: closed %d grants", n)
+	}
+}
+
+// ExpireGrants marks active grants that expired at or before now as expired and returns
+// how many were closed.
+func (e *Expirer) ExpireGrants(now time.Time) (int, error) {
+	var expired []models.BreakGlassGrant
+	if err := e.db.Preload("User").
+		Where("status = ? AND expires_at <= ?", models.BreakGlassActive, now).
+		Find(&expired).Error; err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := range expired {
+		grant := &expired[i]
+		// Guard on status so that concurrent instances record the expiry only once.
+		result := e.db.Model(&models.BreakGlassGrant{}).
+			Where("id = ? AND status = ?", grant.ID, models.BreakGlassActive).
+			Updates(map[string]interface{}{"status": models.BreakGlassExpired, "ended_at": now})
+		if result.Error != nil {
+			log.Printf("Error expiring break-glass grant %s: %v", grant.ID, result.Error)
+			continue
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		count++
+
+		middleware.RecordBreakGlassEvent(nil, grant, uuid.Nil, models.BreakGlassEventExpired, "", "")
+
+		name := grant.UserID.String()
+		if grant.User != nil {
+			name = grant.User.Name
+		}
+		middleware.NotifySuperAdmins("Break-glass access expired",
+			fmt.Sprintf("%s's break-glass access expired after %d authorised request(s).", name, grant.UsageCount),
+			grant, models.NotificationPriorityNormal)
+	}
+	return count, nil
+}
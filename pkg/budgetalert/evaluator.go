@@ -0,0 +1,272 @@
+// Package budgetalert raises alerts when spend on a project, its tasks or its budget
+// allocations reaches the project's budget thresholds, and notifies the project's
+// approvers.
+package budgetalert
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"p9e.in/ugcl/models"
+)
+
+// approverPermissions are the project role permissions that make a user one of the
+// project's approvers
+var approverPermissions = []string{"task:approve", "budget:manage", "admin_all", "project:*"}
+
+// projectApproversSQL lists the users holding an approver role on a project
+const projectApproversSQL = `SELECT DISTINCT upr.user_id
+	FROM user_project_roles upr
+	JOIN project_roles pr ON pr.id = upr.role_id
+	WHERE upr.project_id = ? AND upr.is_active AND pr.is_active
+	AND (upr.valid_until IS NULL OR upr.valid_until > NOW())
+	AND EXISTS (SELECT 1 FROM jsonb_array_elements_text(pr.permissions) p WHERE p IN ?)`
+
+// Subject is something with a budget and spend against it
+type Subject struct {
+	Scope  string
+	ID     uuid.UUID
+	Name   string
+	Budget float64
+	Spent  float64
+}
+
+// Reading is a subject that has reached a threshold
+type Reading struct {
+	Subject   Subject
+	Threshold models.BudgetThreshold
+}
+
+// Reached lists each threshold each subject of its scope has reached
+func Reached(thresholds []models.BudgetThreshold, subjects []Subject) []Reading {
+	var readings []Reading
+	for _, subject := range subjects {
+		for _, threshold := range thresholds {
+			if threshold.Scope == subject.Scope && models.BudgetThresholdReached(threshold, subject.Budget, subject.Spent) {
+				readings = append(readings, Reading{Subject: subject, Threshold: threshold})
+			}
+		}
+	}
+	return readings
+}
+
+// alertKey identifies an open alert: one per subject and threshold level
+type alertKey struct {
+	scope   string
+	subject uuid.UUID
+	percent float64
+}
+
+// Evaluator checks a project's spend against its thresholds
+type Evaluator struct {
+	db *gorm.DB
+}
+
+// NewEvaluator creates a budget alert evaluator
+func NewEvaluator(db *gorm.DB) *Evaluator {
+	return &Evaluator{db: db}
+}
+
+// Thresholds returns the project's thresholds, or the defaults when it has none
+func (e *Evaluator) Thresholds(projectID uuid.UUID) ([]models.BudgetThreshold, error) {
+	var thresholds []models.BudgetThreshold
+	if err := e.db.Where("project_id = ?", projectID).Order("scope, percent").Find(&thresholds).Error; err != nil {
+		return nil, err
+	}
+	if len(thresholds) == 0 {
+		return models.DefaultBudgetThresholds(projectID), nil
+	}
+	return thresholds, nil
+}
+
+// subjects loads the project, its open tasks and its allocations with their spend
+func (e *Evaluator) subjects(project models.Project) ([]Subject, error) {
+	subjects := []Subject{{
+		Scope:  models.BudgetScopeProject,
+		ID:     project.ID,
+		Name:   project.Name,
+		Budget: project.TotalBudget,
+		Spent:  project.SpentBudget,
+	}}
+
+	var tasks []models.Tasks
+	if err := e.db.Select("id", "code", "title", "allocated_budget", "total_cost").
+		Where("project_id = ? AND deleted_at IS NULL AND status <> ?", project.ID, "cancelled").
+		Find(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("failed to load tasks: %w", err)
+	}
+	for _, task := range tasks {
+		subjects = append(subjects, Subject{
+			Scope:  models.BudgetScopeTask,
+			ID:     task.ID,
+			Name:   fmt.Sprintf("%s %s", task.Code, task.Title),
+			Budget: task.AllocatedBudget,
+			Spent:  task.TotalCost,
+		})
+	}
+
+	var allocations []models.BudgetAllocation
+	if err := e.db.Select("id", "category", "description", "planned_amount", "actual_amount").
+		Where("deleted_at IS NULL AND status <> ?", "cancelled").
+		Where("project_id = ? OR task_id IN (?)", project.ID,
+			e.db.Model(&models.Tasks{}).Select("id").Where("project_id = ?", project.ID)).
+		Find(&allocations).Error; err != nil {
+		return nil, fmt.Errorf("failed to load budget allocations: %w", err)
+	}
+	for _, allocation := range allocations {
+		name := allocation.Category
+		if allocation.Description != "" {
+			name += ": " + allocation.Description
+		}
+		subjects = append(subjects, Subject{
+			Scope:  models.BudgetScopeAllocation,
+			ID:     allocation.ID,
+			Name:   name,
+			Budget: allocation.PlannedAmount,
+			Spent:  allocation.ActualAmount,
+		})
+	}
+	return subjects, nil
+}
+
+// Evaluate checks the project's spend against its thresholds. It opens an alert, and
+// notifies the project's approvers, for each threshold newly reached, refreshes the
+// figures on alerts still reached and resolves those no longer reached. It returns the
+// alerts it opened.
+func (e *Evaluator) Evaluate(projectID uuid.UUID) ([]models.BudgetAlert, error) {
+	var project models.Project
+	if err := e.db.Select("id", "name", "total_budget", "spent_budget", "business_vertical_id", "created_by").
+		First(&project, "id = ? AND deleted_at IS NULL", projectID).Error; err != nil {
+		return nil, err
+	}
+	thresholds, err := e.Thresholds(projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load thresholds: %w", err)
+	}
+	subjects, err := e.subjects(project)
+	if err != nil {
+		return nil, err
+	}
+
+	var open []models.BudgetAlert
+	if err := e.db.Where("project_id = ? AND status = ?", projectID, models.BudgetAlertOpen).Find(&open).Error; err != nil {
+		return nil, fmt.Errorf("failed to load open alerts: %w", err)
+	}
+	openByKey := make(map[alertKey]models.BudgetAlert, len(open))
+	for _, alert := range open {
+		openByKey[alertKey{alert.Scope, alert.SubjectID, alert.ThresholdPercent}] = alert
+	}
+
+	now := time.Now()
+	var raised []models.BudgetAlert
+	priorities := map[uuid.UUID]models.NotificationPriority{}
+	for _, reading := range Reached(thresholds, subjects) {
+		subject := reading.Subject
+		used := models.BudgetUsedPercent(subject.Budget, subject.Spent)
+		key := alertKey{subject.Scope, subject.ID, reading.Threshold.Percent}
+		if alert, ok := openByKey[key]; ok {
+			delete(openByKey, key)
+			if err := e.db.Model(&alert).Updates(map[string]interface{}{
+				"budget": subject.Budget, "spent": subject.Spent, "used_percent": used,
+			}).Error; err != nil {
+				return raised, err
+			}
+			continue
+		}
+
+		alert := models.BudgetAlert{
+			ProjectID:        projectID,
+			Scope:            subject.Scope,
+			SubjectID:        subject.ID,
+			SubjectName:      subject.Name,
+			ThresholdPercent: reading.Threshold.Percent,
+			Budget:           subject.Budget,
+			Spent:            subject.Spent,
+			UsedPercent:      used,
+			Status:           models.BudgetAlertOpen,
+			TriggeredAt:      now,
+		}
+		// A concurrent evaluation may have opened the same alert; the unique index on
+		// open alerts keeps just one and only its creator notifies
+		result := e.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&alert)
+		if result.Error != nil {
+			return raised, result.Error
+		}
+		if result.RowsAffected > 0 {
+			raised = append(raised, alert)
+			priorities[alert.ID] = reading.Threshold.Priority
+		}
+	}
+
+	for _, alert := range openByKey {
+		if err := e.db.Model(&alert).Updates(map[string]interface{}{
+			"status": models.BudgetAlertResolved, "resolved_at": now,
+		}).Error; err != nil {
+			return raised, err
+		}
+	}
+
+	if len(raised) > 0 {
+		e.notify(project, raised, priorities)
+	}
+	return raised, nil
+}
+
+// Approvers lists the users holding an approver role on the project, or its creator
+// when nobody does
+func (e *Evaluator) Approvers(project models.Project) ([]string, error) {
+	var userIDs []string
+	if err := e.db.Raw(projectApproversSQL, project.ID, approverPermissions).Scan(&userIDs).Error; err != nil {
+		return nil, err
+	}
+	if len(userIDs) == 0 && project.CreatedBy != "" {
+		userIDs = []string{project.CreatedBy}
+	}
+	sort.Strings(userIDs)
+	return userIDs, nil
+}
+
+// notify sends the project's approvers an in-app notification for each alert raised
+func (e *Evaluator) notify(project models.Project, alerts []models.BudgetAlert, priorities map[uuid.UUID]models.NotificationPriority) {
+	approvers, err := e.Approvers(project)
+	if err != nil {
+		log.Printf("⚠️  Failed to load approvers of project %s: %v", project.ID, err)
+		return
+	}
+	now := time.Now()
+	verticalID := project.BusinessVerticalID
+	for _, alert := range alerts {
+		for _, userID := range approvers {
+			notification := &models.Notification{
+				UserID:   userID,
+				Type:     models.NotificationTypeBudgetAlert,
+				Priority: priorities[alert.ID],
+				Title:    fmt.Sprintf("Budget alert: %s", alert.SubjectName),
+				Body: fmt.Sprintf("%s of project %s has used %.1f%% of its budget (%.2f of %.2f), past the %.0f%% threshold.",
+					alert.SubjectName, project.Name, alert.UsedPercent, alert.Spent, alert.Budget, alert.ThresholdPercent),
+				ActionURL:          fmt.Sprintf("/budget/projects/%s/over-budget", project.ID),
+				BusinessVerticalID: &verticalID,
+				Status:             models.NotificationStatusSent,
+				Channel:            models.NotificationChannelInApp,
+				SentAt:             &now,
+				Metadata: models.JSONMap{
+					"budget_alert_id": alert.ID.String(),
+					"project_id":      project.ID.String(),
+					"scope":           alert.Scope,
+					"subject_id":      alert.SubjectID.String(),
+				},
+			}
+			if err := e.db.Create(notification).Error; err != nil {
+				log.Printf("⚠️  Failed to notify %s of budget alert %s: %v", userID, alert.ID, err)
+			}
+		}
+		if err := e.db.Model(&alert).Update("notified_user_ids", models.StringArray(approvers)).Error; err != nil {
+			log.Printf("⚠️  Failed to record who was notified of budget alert %s: %v", alert.ID, err)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package budgetalert
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"p9e.in/ugcl/models"
+)
+
+func TestReached(t *testing.T) {
+	projectID := uuid.New()
+	thresholds := models.DefaultBudgetThresholds(projectID)
+	thresholds = append(thresholds, models.BudgetThreshold{Scope: models.BudgetScopeTask, Percent: 50, IsActive: false})
+
+	nearly := Subject{Scope: models.BudgetScopeTask, ID: uuid.New(), Budget: 1000, Spent: 920}
+	over := Subject{Scope: models.BudgetScopeAllocation, ID: uuid.New(), Budget: 1000, Spent: 1000}
+	under := Subject{Scope: models.BudgetScopeTask, ID: uuid.New(), Budget: 1000, Spent: 600}
+	unbudgeted := Subject{Scope: models.BudgetScopeTask, ID: uuid.New(), Spent: 500}
+
+	readings := Reached(thresholds, []Subject{nearly, over, under, unbudgeted})
+	got := map[uuid.UUID][]float64{}
+	for _, reading := range readings {
+		got[reading.Subject.ID] = append(got[reading.Subject.ID], reading.Threshold.Percent)
+	}
+	if len(got[nearly.ID]) != 1 || got[nearly.ID][0] != 90 {
+		t.Errorf("task at 92%% reached %v, want only 90", got[nearly.ID])
+	}
+	if len(got[over.ID]) != 2 {
+		t.Errorf("allocation at 100%% reached %v, want 90 and 100", got[over.ID])
+	}
+	if len(got[under.ID]) != 0 || len(got[unbudgeted.ID]) != 0 {
+		t.Errorf("under budget and unbudgeted tasks reached %v and %v, want none", got[under.ID], got[unbudgeted.ID])
+	}
+}
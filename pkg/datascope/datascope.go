@@ -0,0 +1,325 @@
+// Package datascope enforces row-level data scoping in GORM. A Scope attached to a
+// statement's context limits every query, update and delete on tables with a
+// business_vertical_id or site_id column to the caller's allowed verticals and sites,
+// and rejects inserts outside them, so a handler that forgets its WHERE clause cannot
+// leak another vertical's rows. Tables registered with ScopeThrough are scoped via their
+// parent instead. Rows with a NULL business_vertical_id are shared and
+// stay visible; rows with a NULL site_id are hidden from site-restricted callers.
+package datascope
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	verticalColumn = "business_vertical_id"
+	siteColumn     = "site_id"
+)
+
+// ErrOutOfScope is returned when a write targets a vertical or site outside the scope
+var ErrOutOfScope = errors.New("record is outside the caller's data scope")
+
+// Scope is the set of rows a caller may touch
+type Scope struct {
+	// AllVerticals lifts the vertical filter; CompanyID still limits it to one company's
+	// verticals unless it is uuid.Nil.
+	AllVerticals bool
+	CompanyID    uuid.UUID
+	VerticalIDs  []uuid.UUID
+
+	// SiteRestricted limits rows with a site_id column to SiteIDs
+	SiteRestricted bool
+	SiteIDs        []uuid.UUID
+}
+
+// Unrestricted reports whether the scope filters nothing
+func (s *Scope) Unrestricted() bool {
+	return s.AllVerticals && s.CompanyID == uuid.Nil && !s.SiteRestricted
+}
+
+// AllowsVertical reports whether rows of the vertical are in scope. companyOf resolves a
+// vertical's company and is only consulted for company-wide scopes; nil skips the check.
+func (s *Scope) AllowsVertical(verticalID uuid.UUID, companyOf func(uuid.UUID) uuid.UUID) bool {
+	if s.AllVerticals {
+		return s.CompanyID == uuid.Nil || companyOf == nil || companyOf(verticalID) == s.CompanyID
+	}
+	return containsID(s.VerticalIDs, verticalID)
+}
+
+// AllowsSite reports whether rows of the site are in scope
+func (s *Scope) AllowsSite(siteID uuid.UUID) bool {
+	return !s.SiteRestricted || containsID(s.SiteIDs, siteID)
+}
+
+func containsID(ids []uuid.UUID, id uuid.UUID) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+type ctxKey struct{}
+type skipKey struct{}
+
+// WithScope attaches a scope to ctx; statements run with the context are filtered by it
+func WithScope(ctx context.Context, scope *Scope) context.Context {
+	return context.WithValue(ctx, ctxKey{}, scope)
+}
+
+// FromContext returns the scope attached to ctx, if any
+func FromContext(ctx context.Context) (*Scope, bool) {
+	scope, ok := ctx.Value(ctxKey{}).(*Scope)
+	return scope, ok && scope != nil
+}
+
+// Unscoped marks ctx so statements skip scoping, for deliberate cross-vertical work such
+// as platform reports run on behalf of a scoped caller.
+func Unscoped(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipKey{}, true)
+}
+
+var (
+	registryMu   sync.RWMutex
+	exemptTables = map[string]bool{
+		// A user's primary vertical is not ownership of the user record; users are
+		// preloaded across verticals (creators, approvers, assignees).
+		"users": true,
+	}
+)
+
+// Exempt excludes a table from scoping
+func Exempt(table string) {
+	registryMu.Lock()
+	exemptTables[table] = true
+	registryMu.Unlock()
+}
+
+func isExempt(table string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return exemptTables[table]
+}
+
+type parentRef struct {
+	column string
+	table  string
+	field  *schema.Field
+}
+
+var parentTables = map[string]string{}
+
+// ScopeThrough scopes tables without a business_vertical_id column through a reference
+// to a vertical-owned parent, e.g. ScopeThrough("project_id", "projects") limits zones,
+// nodes and tasks to the projects in scope.
+func ScopeThrough(column, parentTable string) {
+	registryMu.Lock()
+	parentTables[column] = parentTable
+	registryMu.Unlock()
+}
+
+func parentsOf(s *schema.Schema) []parentRef {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	var parents []parentRef
+	for column, table := range parentTables {
+		if field, has := s.FieldsByDBName[column]; has && table != s.Table {
+			parents = append(parents, parentRef{column: column, table: table, field: field})
+		}
+	}
+	return parents
+}
+
+// Plugin registers the scoping callbacks; enable it with db.Use(datascope.Plugin{})
+type Plugin struct{}
+
+func (Plugin) Name() string {
+	return "datascope"
+}
+
+func (Plugin) Initialize(db *gorm.DB) error {
+	filter := func(db *gorm.DB) { applyFilter(db) }
+	if err := db.Callback().Query().Before("gorm:query").Register("datascope:query", filter); err != nil {
+		return err
+	}
+	if err := db.Callback().Row().Before("gorm:row").Register("datascope:row", filter); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register("datascope:update", filter); err != nil {
+		return err
+	}
+	if err := db.Callback().Delete().Before("gorm:delete").Register("datascope:delete", filter); err != nil {
+		return err
+	}
+	return db.Callback().Create().Before("gorm:create").Register("datascope:create", checkCreate)
+}
+
+// activeScope returns the statement's scope when the statement targets a scoped table
+func activeScope(db *gorm.DB) (*Scope, bool) {
+	stmt := db.Statement
+	// Raw SQL is already built and cannot be rewritten; callers scope it themselves
+	if stmt.Context == nil || stmt.Schema == nil || stmt.SQL.Len() > 0 {
+		return nil, false
+	}
+	if skip, _ := stmt.Context.Value(skipKey{}).(bool); skip {
+		return nil, false
+	}
+	scope, ok := FromContext(stmt.Context)
+	if !ok || scope.Unrestricted() || isExempt(stmt.Table) {
+		return nil, false
+	}
+	return scope, true
+}
+
+func applyFilter(db *gorm.DB) {
+	scope, ok := activeScope(db)
+	if !ok {
+		return
+	}
+	stmt := db.Statement
+
+	var exprs []clause.Expression
+	if field, has := stmt.Schema.FieldsByDBName[verticalColumn]; has {
+		column := clause.Column{Table: clause.CurrentTable, Name: verticalColumn}
+		if expr := verticalFilter(scope, column); expr != nil {
+			exprs = append(exprs, orNull(field, column, expr))
+		}
+	} else {
+		for _, parent := range parentsOf(stmt.Schema) {
+			column := clause.Column{Table: clause.CurrentTable, Name: parent.column}
+			if expr := verticalFilter(scope, clause.Column{Name: verticalColumn}); expr != nil {
+				subquery := clause.Expr{
+					SQL:  "? IN (SELECT id FROM " + parent.table + " WHERE ?)",
+					Vars: []interface{}{column, expr},
+				}
+				exprs = append(exprs, orNull(parent.field, column, subquery))
+			}
+		}
+	}
+	if _, has := stmt.Schema.FieldsByDBName[siteColumn]; has && scope.SiteRestricted {
+		exprs = append(exprs, inIDs(clause.Column{Table: clause.CurrentTable, Name: siteColumn}, scope.SiteIDs))
+	}
+	if len(exprs) > 0 {
+		stmt.AddClause(clause.Where{Exprs: exprs})
+	}
+}
+
+// verticalFilter limits column to the scope's verticals; nil means no vertical limit
+func verticalFilter(scope *Scope, column clause.Column) clause.Expression {
+	switch {
+	case !scope.AllVerticals:
+		return inIDs(column, scope.VerticalIDs)
+	case scope.CompanyID != uuid.Nil:
+		return clause.Expr{
+			SQL:  "? IN (SELECT id FROM business_verticals WHERE company_id = ?)",
+			Vars: []interface{}{column, scope.CompanyID},
+		}
+	}
+	return nil
+}
+
+// orNull keeps rows whose nullable column is NULL: a NULL vertical marks shared rows
+// (e.g. global templates), a NULL parent rows not tied to one.
+func orNull(field *schema.Field, column clause.Column, expr clause.Expression) clause.Expression {
+	if field.FieldType.Kind() != reflect.Ptr {
+		return expr
+	}
+	return clause.Or(expr, clause.Expr{SQL: "? IS NULL", Vars: []interface{}{column}})
+}
+
+// inIDs builds "column IN ids"; an empty set matches nothing rather than everything
+func inIDs(column clause.Column, ids []uuid.UUID) clause.Expression {
+	if len(ids) == 0 {
+		return clause.Expr{SQL: "1 = 0"}
+	}
+	values := make([]interface{}, len(ids))
+	for i, id := range ids {
+		values[i] = id
+	}
+	return clause.IN{Column: column, Values: values}
+}
+
+func checkCreate(db *gorm.DB) {
+	scope, ok := activeScope(db)
+	if !ok {
+		return
+	}
+	stmt := db.Statement
+	verticalField := stmt.Schema.FieldsByDBName[verticalColumn]
+	siteField := stmt.Schema.FieldsByDBName[siteColumn]
+	if verticalField == nil && (siteField == nil || !scope.SiteRestricted) {
+		return
+	}
+
+	companyOf := func(verticalID uuid.UUID) uuid.UUID {
+		var companyID uuid.NullUUID
+		// A fresh context so the lookup itself is not scoped
+		db.Session(&gorm.Session{NewDB: true, Context: context.Background()}).
+			Raw("SELECT company_id FROM business_verticals WHERE id = ?", verticalID).Row().Scan(&companyID)
+		return companyID.UUID
+	}
+
+	for _, row := range structRows(stmt.ReflectValue) {
+		if verticalField != nil {
+			// A missing vertical is left to the column's NOT NULL constraint
+			if id, set := uuidValue(verticalField.ValueOf(stmt.Context, row)); set && !scope.AllowsVertical(id, companyOf) {
+				db.AddError(ErrOutOfScope)
+				return
+			}
+		}
+		if siteField != nil && scope.SiteRestricted {
+			// Site-restricted callers may only create rows at one of their sites
+			if id, set := uuidValue(siteField.ValueOf(stmt.Context, row)); !set || !scope.AllowsSite(id) {
+				db.AddError(ErrOutOfScope)
+				return
+			}
+		}
+	}
+}
+
+// structRows flattens the value being created into its struct rows; map creates are not
+// checked.
+func structRows(value reflect.Value) []reflect.Value {
+	value = reflect.Indirect(value)
+	switch value.Kind() {
+	case reflect.Struct:
+		return []reflect.Value{value}
+	case reflect.Slice, reflect.Array:
+		rows := make([]reflect.Value, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			if row := reflect.Indirect(value.Index(i)); row.Kind() == reflect.Struct {
+				rows = append(rows, row)
+			}
+		}
+		return rows
+	}
+	return nil
+}
+
+func uuidValue(value interface{}, zero bool) (uuid.UUID, bool) {
+	if zero {
+		return uuid.Nil, false
+	}
+	switch v := value.(type) {
+	case uuid.UUID:
+		return v, v != uuid.Nil
+	case *uuid.UUID:
+		if v == nil {
+			return uuid.Nil, false
+		}
+		return *v, *v != uuid.Nil
+	case string:
+		id, err := uuid.Parse(v)
+		return id, err == nil
+	}
+	return uuid.Nil, false
+}
@@ -0,0 +1,117 @@
+package datascope
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type scopedRow struct {
+	ID                 uuid.UUID
+	BusinessVerticalID uuid.UUID
+	SiteID             *uuid.UUID
+}
+
+type sharedRow struct {
+	ID                 uuid.UUID
+	BusinessVerticalID *uuid.UUID
+}
+
+type childRow struct {
+	ID        uuid.UUID
+	ProjectID uuid.UUID
+}
+
+type unscopedRow struct {
+	ID   uuid.UUID
+	Name string
+}
+
+func dryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(Plugin{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestQueryIsFilteredByVerticalAndSite(t *testing.T) {
+	db := dryRunDB(t)
+	vertical, site := uuid.New(), uuid.New()
+	ctx := WithScope(context.Background(), &Scope{VerticalIDs: []uuid.UUID{vertical}, SiteRestricted: true, SiteIDs: []uuid.UUID{site}})
+
+	var rows []scopedRow
+	sql := db.WithContext(ctx).Where("id = ?", uuid.New()).Find(&rows).Statement.SQL.String()
+	if !strings.Contains(sql, `"scoped_rows"."business_vertical_id" =`) || !strings.Contains(sql, `"scoped_rows"."site_id" =`) {
+		t.Errorf("expected vertical and site filters, got %s", sql)
+	}
+
+	sql = db.WithContext(ctx).Find(&[]sharedRow{}).Statement.SQL.String()
+	if !strings.Contains(sql, `"shared_rows"."business_vertical_id" IS NULL`) {
+		t.Errorf("expected shared rows to stay visible, got %s", sql)
+	}
+
+	sql = db.WithContext(ctx).Find(&[]unscopedRow{}).Statement.SQL.String()
+	if strings.Contains(sql, "WHERE") {
+		t.Errorf("tables without scoped columns must not be filtered, got %s", sql)
+	}
+
+	sql = db.WithContext(Unscoped(ctx)).Find(&rows).Statement.SQL.String()
+	if strings.Contains(sql, "WHERE") {
+		t.Errorf("unscoped context must not be filtered, got %s", sql)
+	}
+}
+
+func TestChildRowsAreScopedThroughParent(t *testing.T) {
+	ScopeThrough("project_id", "projects")
+	db := dryRunDB(t)
+	ctx := WithScope(context.Background(), &Scope{VerticalIDs: []uuid.UUID{uuid.New()}})
+
+	sql := db.WithContext(ctx).Find(&[]childRow{}).Statement.SQL.String()
+	if !strings.Contains(sql, `"child_rows"."project_id" IN (SELECT id FROM projects WHERE "business_vertical_id" =`) {
+		t.Errorf("expected parent subquery, got %s", sql)
+	}
+}
+
+func TestEmptyScopeMatchesNothing(t *testing.T) {
+	db := dryRunDB(t)
+	ctx := WithScope(context.Background(), &Scope{})
+	sql := db.WithContext(ctx).Model(&scopedRow{}).Where("id = ?", uuid.New()).Update("site_id", nil).Statement.SQL.String()
+	if !strings.Contains(sql, "1 = 0") {
+		t.Errorf("expected deny-all filter, got %s", sql)
+	}
+}
+
+func TestCompanyWideScopeUsesSubquery(t *testing.T) {
+	db := dryRunDB(t)
+	ctx := WithScope(context.Background(), &Scope{AllVerticals: true, CompanyID: uuid.New()})
+	sql := db.WithContext(ctx).Delete(&scopedRow{}, "id = ?", uuid.New()).Statement.SQL.String()
+	if !strings.Contains(sql, "SELECT id FROM business_verticals WHERE company_id") {
+		t.Errorf("expected company vertical subquery, got %s", sql)
+	}
+}
+
+func TestCreateOutsideScopeIsRejected(t *testing.T) {
+	db := dryRunDB(t)
+	vertical, site := uuid.New(), uuid.New()
+	ctx := WithScope(context.Background(), &Scope{VerticalIDs: []uuid.UUID{vertical}, SiteRestricted: true, SiteIDs: []uuid.UUID{site}})
+
+	if err := db.WithContext(ctx).Create(&scopedRow{ID: uuid.New(), BusinessVerticalID: vertical, SiteID: &site}).Error; err != nil {
+		t.Errorf("in-scope create failed: %v", err)
+	}
+	if err := db.WithContext(ctx).Create(&scopedRow{ID: uuid.New(), BusinessVerticalID: uuid.New(), SiteID: &site}).Error; !errors.Is(err, ErrOutOfScope) {
+		t.Errorf("expected ErrOutOfScope for foreign vertical, got %v", err)
+	}
+	if err := db.WithContext(ctx).Create(&[]scopedRow{{ID: uuid.New(), BusinessVerticalID: vertical}}).Error; !errors.Is(err, ErrOutOfScope) {
+		t.Errorf("expected ErrOutOfScope for missing site, got %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+// Package devicehealth watches field devices for missed heartbeats and alerts the site
+// engineers managing them.
+package devicehealth
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/jobrunner"
+)
+
+// devicePermission is held by the engineers told about offline devices
+const devicePermission = "sensor:device:manage"
+
+// deviceManagersSQL lists the users holding devicePermission in a vertical through a role
+// that covers the whole vertical or the device's site
+const deviceManagersSQL = `SELECT DISTINCT ubr.user_id
+	FROM user_business_roles ubr
+	JOIN business_roles br ON br.id = ubr.business_role_id
+	JOIN business_role_permissions brp ON brp.business_role_id = br.id
+	JOIN permissions p ON p.id = brp.permission_id
+	WHERE br.business_vertical_id = ? AND br.is_active AND ubr.is_active
+	AND (ubr.valid_from IS NULL OR ubr.valid_from <= NOW())
+	AND (ubr.valid_until IS NULL OR ubr.valid_until > NOW())
+	AND (ubr.site_id IS NULL OR ubr.site_id = ?)
+	AND p.name = ?`
+
+// Monitor marks approved devices offline once they miss their heartbeats and notifies
+// the engineers managing them, once per outage. A device is back online as soon as it
+// sends a reading or heartbeat.
+type Monitor struct {
+	db *gorm.DB
+	*jobrunner.Runner
+}
+
+// NewMonitor creates an offline device monitor
+func NewMonitor(db *gorm.DB) *Monitor {
+	m := &Monitor{db: db}
+	m.Runner = jobrunner.New("Device health monitor", m.run).RunAtStart()
+	return m
+}
+
+func (m *Monitor) run() {
+	count, err := m.AlertOffline(time.Now())
+	if err != nil {
+		log.Printf("Error checking device health: %v", err)
+	}
+	if count > 0 {
+		log.Printf("Device health monitor: %d devices went offline", count)
+	}
+}
+
+// AlertOffline marks the approved devices that are offline at now and not yet alerted,
+// notifies their managers and returns how many were marked. Marking is conditional, so
+// concurrent instances alert each outage once.
+func (m *Monitor) AlertOffline(now time.Time) (int, error) {
+	var devices []models.SensorDevice
+	if err := m.db.Preload("Site").
+		Where("status = ? AND offline_alerted_at IS NULL", models.SensorDeviceApproved).
+		Where(`COALESCE(GREATEST(last_seen_at, reviewed_at), created_at)
+			+ ? * (CASE WHEN heartbeat_interval_minutes > 0 THEN heartbeat_interval_minutes ELSE ? END) * INTERVAL '1 minute' < ?`,
+			models.SensorMissedHeartbeats, int(models.DefaultSensorHeartbeatInterval/time.Minute), now).
+		Find(&devices).Error; err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := range devices {
+		device := &devices[i]
+		if !device.IsOffline(now) {
+			continue
+		}
+		result := m.db.Model(&models.SensorDevice{}).
+			Where("id = ? AND offline_alerted_at IS NULL", device.ID).
+			UpdateColumn("offline_alerted_at", now)
+		if result.Error != nil {
+			log.Printf("Error marking device %s offline: %v", device.ID, result.Error)
+			continue
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		count++
+		m.notifyManagers(device, now)
+	}
+	return count, nil
+}
+
+func (m *Monitor) notifyManagers(device *models.SensorDevice, now time.Time) {
+	siteID := uuid.Nil
+	if device.SiteID != nil {
+		siteID = *device.SiteID
+	}
+	var userIDs []string
+	if err := m.db.Raw(deviceManagersSQL, device.BusinessVerticalID, siteID, devicePermission).Scan(&userIDs).Error; err != nil {
+		log.Printf("Error loading managers of device %s: %v", device.ID, err)
+		return
+	}
+
+	name := device.Name
+	if name == "" {
+		name = device.DeviceKey
+	}
+	where := ""
+	if device.Site != nil {
+		where = fmt.Sprintf(" at %s", device.Site.Name)
+	}
+	lastSeen := "never reported"
+	if device.LastSeenAt != nil {
+		lastSeen = "last reported " + device.LastSeenAt.Format("02 Jan 2006 15:04 MST")
+	}
+	for _, userID := range userIDs {
+		notification := &models.Notification{
+			UserID:             userID,
+			Type:               models.NotificationTypeSystemAlert,
+			Priority:           models.NotificationPriorityHigh,
+			Title:              "Device offline",
+			Body:               fmt.Sprintf("%s (%s)%s has %s.", name, device.DeviceType, where, lastSeen),
+			ActionURL:          fmt.Sprintf("/sensors/devices/%s", device.ID),
+			BusinessVerticalID: &device.BusinessVerticalID,
+			Status:             models.NotificationStatusSent,
+			Channel:            models.NotificationChannelInApp,
+			SentAt:             &now,
+			Metadata: models.JSONMap{
+				"device_id":   device.ID.String(),
+				"device_type": string(device.DeviceType),
+			},
+		}
+		if err := m.db.Create(notification).Error; err != nil {
+			log.Printf("Error notifying %s that device %s is offline: %v", userID, device.ID, err)
+		}
+	}
+}
@@ -0,0 +1,527 @@
+package formvalidation
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// expression is a compiled cross-field condition, visibility rule or formula. The
+// language is the small JavaScript subset form definitions use: field names, 'string' and
+// "string" literals, numbers, true/false/null/undefined, the comparisons === !== == != <
+// <= > >=, ! && ||, the arithmetic + - * / %, the functions round(x[, digits]), floor,
+// ceil, abs, min and max, and parentheses. Equality compares numbers numerically and
+// everything else as text, and ordering compares text lexically, which orders ISO dates
+// correctly. Arithmetic on an empty value is empty, and + joins values that are not
+// numbers as text.
+type expression interface {
+	eval(data map[string]interface{}) interface{}
+}
+
+type fieldRef string
+
+func (f fieldRef) eval(data map[string]interface{}) interface{} { return data[string(f)] }
+
+type literal struct{ value interface{} }
+
+func (l literal) eval(map[string]interface{}) interface{} { return l.value }
+
+type not struct{ operand expression }
+
+func (n not) eval(data map[string]interface{}) interface{} { return !truthy(n.operand.eval(data)) }
+
+type binary struct {
+	op          string
+	left, right expression
+}
+
+func (b binary) eval(data map[string]interface{}) interface{} {
+	switch b.op {
+	case "&&":
+		return truthy(b.left.eval(data)) && truthy(b.right.eval(data))
+	case "||":
+		return truthy(b.left.eval(data)) || truthy(b.right.eval(data))
+	}
+	left, right := b.left.eval(data), b.right.eval(data)
+	switch b.op {
+	case "===", "==":
+		return equal(left, right)
+	case "!==", "!=":
+		return !equal(left, right)
+	case "<":
+		return comparable(left, right) && compare(left, right) < 0
+	case "<=":
+		return comparable(left, right) && compare(left, right) <= 0
+	case ">":
+		return comparable(left, right) && compare(left, right) > 0
+	case ">=":
+		return comparable(left, right) && compare(left, right) >= 0
+	}
+	return false
+}
+
+type negate struct{ operand expression }
+
+func (n negate) eval(data map[string]interface{}) interface{} {
+	if x, ok := number(n.operand.eval(data)); ok {
+		return -x
+	}
+	return nil
+}
+
+type arithmetic struct {
+	op          string
+	left, right expression
+}
+
+func (a arithmetic) eval(data map[string]interface{}) interface{} {
+	left, right := a.left.eval(data), a.right.eval(data)
+	if left == nil || left == "" || right == nil || right == "" {
+		return nil
+	}
+	x, xNum := number(left)
+	y, yNum := number(right)
+	if !xNum || !yNum {
+		if a.op == "+" {
+			return fmt.Sprint(left) + fmt.Sprint(right)
+		}
+		return nil
+	}
+	switch a.op {
+	case "+":
+		return x + y
+	case "-":
+		return x - y
+	case "*":
+		return x * y
+	case "/":
+		if y == 0 {
+			return nil
+		}
+		return x / y
+	case "%":
+		if y == 0 {
+			return nil
+		}
+		return math.Mod(x, y)
+	}
+	return nil
+}
+
+// functions are the functions formulas may call, with their argument counts
+var functions = map[string]struct{ min, max int }{
+	"round": {1, 2}, "floor": {1, 1}, "ceil": {1, 1}, "abs": {1, 1}, "min": {1, -1}, "max": {1, -1},
+}
+
+type call struct {
+	name string
+	args []expression
+}
+
+func (c call) eval(data map[string]interface{}) interface{} {
+	values := make([]float64, len(c.args))
+	for i, arg := range c.args {
+		n, ok := number(arg.eval(data))
+		if !ok {
+			return nil
+		}
+		values[i] = n
+	}
+	switch c.name {
+	case "round":
+		if len(values) == 2 {
+			return roundTo(values[0], int(values[1]))
+		}
+		return math.Round(values[0])
+	case "floor":
+		return math.Floor(values[0])
+	case "ceil":
+		return math.Ceil(values[0])
+	case "abs":
+		return math.Abs(values[0])
+	case "min":
+		return slices.Min(values)
+	case "max":
+		return slices.Max(values)
+	}
+	return nil
+}
+
+// roundTo rounds x half away from zero to the given number of decimal places
+func roundTo(x float64, digits int) float64 {
+	scale := math.Pow(10, float64(digits))
+	return math.Round(x*scale) / scale
+}
+
+// referencedFields lists the fields an expression reads, in order of first use
+func referencedFields(expr expression) []string {
+	var fields []string
+	var walk func(expression)
+	walk = func(e expression) {
+		switch x := e.(type) {
+		case fieldRef:
+			if !slices.Contains(fields, string(x)) {
+				fields = append(fields, string(x))
+			}
+		case not:
+			walk(x.operand)
+		case negate:
+			walk(x.operand)
+		case binary:
+			walk(x.left)
+			walk(x.right)
+		case arithmetic:
+			walk(x.left)
+			walk(x.right)
+		case call:
+			for _, arg := range x.args {
+				walk(arg)
+			}
+		}
+	}
+	walk(expr)
+	return fields
+}
+
+func parseExpression(source string) (expression, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty condition")
+	}
+	p := &parser{tokens: tokens}
+	expr, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return expr, nil
+}
+
+type tokenKind int
+
+const (
+	tokenIdent tokenKind = iota
+	tokenString
+	tokenNumber
+	tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+var operators = []string{"===", "!==", "==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")", "+", "-", "*", "/", "%", ","}
+
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+	runes := []rune(source)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '\'' || r == '"':
+			j := i + 1
+			var sb strings.Builder
+			for j < len(runes) && runes[j] != r {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				sb.WriteRune(runes[j])
+				j++
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, token{tokenString, sb.String()})
+			i = j + 1
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]) && !followsOperand(tokens)):
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{tokenNumber, string(runes[i:j])})
+			i = j
+		case unicode.IsLetter(r) || r == '_' || r == '$':
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_' || runes[j] == '$' || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{tokenIdent, string(runes[i:j])})
+			i = j
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(string(runes[i:]), op) {
+					tokens = append(tokens, token{tokenOp, op})
+					i += len([]rune(op))
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q", r)
+			}
+		}
+	}
+	return tokens, nil
+}
+
+// followsOperand reports whether the next token comes after a value, where a '-' is
+// subtraction rather than the sign of a number
+func followsOperand(tokens []token) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	last := tokens[len(tokens)-1]
+	return last.kind != tokenOp || last.text == ")"
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peekOp(ops ...string) (string, bool) {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != tokenOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if p.tokens[p.pos].text == op {
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) or() (expression, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.peekOp("||"); !ok {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{"||", left, right}
+	}
+}
+
+func (p *parser) and() (expression, error) {
+	left, err := p.comparison()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.peekOp("&&"); !ok {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.comparison()
+		if err != nil {
+			return nil, err
+		}
+		left = binary{"&&", left, right}
+	}
+}
+
+func (p *parser) comparison() (expression, error) {
+	left, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.peekOp("===", "!==", "==", "!=", "<=", ">=", "<", ">")
+	if !ok {
+		return left, nil
+	}
+	p.pos++
+	right, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+	return binary{op, left, right}, nil
+}
+
+func (p *parser) additive() (expression, error) {
+	left, err := p.multiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.peekOp("+", "-")
+		if !ok {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.multiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = arithmetic{op, left, right}
+	}
+}
+
+func (p *parser) multiplicative() (expression, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.peekOp("*", "/", "%")
+		if !ok {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = arithmetic{op, left, right}
+	}
+}
+
+func (p *parser) unary() (expression, error) {
+	if op, ok := p.peekOp("!", "-"); ok {
+		p.pos++
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if op == "-" {
+			return negate{operand}, nil
+		}
+		return not{operand}, nil
+	}
+	return p.primary()
+}
+
+// call parses the arguments of a function call, after its opening parenthesis
+func (p *parser) call(name string) (expression, error) {
+	arity, ok := functions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q", name)
+	}
+	var args []expression
+	if _, ok := p.peekOp(")"); !ok {
+		for {
+			arg, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if _, ok := p.peekOp(","); !ok {
+				break
+			}
+			p.pos++
+		}
+	}
+	if _, ok := p.peekOp(")"); !ok {
+		return nil, fmt.Errorf("missing ) after arguments of %s", name)
+	}
+	p.pos++
+	if len(args) < arity.min || (arity.max >= 0 && len(args) > arity.max) {
+		return nil, fmt.Errorf("wrong number of arguments to %s", name)
+	}
+	return call{name, args}, nil
+}
+
+func (p *parser) primary() (expression, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of condition")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	switch t.kind {
+	case tokenString:
+		return literal{t.text}, nil
+	case tokenNumber:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", t.text)
+		}
+		return literal{n}, nil
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			return literal{true}, nil
+		case "false":
+			return literal{false}, nil
+		case "null", "undefined":
+			return literal{nil}, nil
+		}
+		if _, ok := p.peekOp("("); ok {
+			p.pos++
+			return p.call(t.text)
+		}
+		return fieldRef(t.text), nil
+	}
+	if t.text == "(" {
+		expr, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.peekOp(")"); !ok {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return expr, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", t.text)
+}
+
+// truthy follows JavaScript, except that an empty list is false like an empty value
+func truthy(v interface{}) bool {
+	switch x := v.(type) {
+	case bool:
+		return x
+	case float64:
+		return x != 0
+	}
+	return !isEmpty(v)
+}
+
+func equal(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return isEmpty(a) && isEmpty(b)
+	}
+	if x, ok := number(a); ok {
+		if y, ok := number(b); ok {
+			return x == y
+		}
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func comparable(a, b interface{}) bool {
+	return !isEmpty(a) && !isEmpty(b)
+}
+
+// compare orders numbers numerically and anything else as text
+func compare(a, b interface{}) int {
+	if x, ok := number(a); ok {
+		if y, ok := number(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
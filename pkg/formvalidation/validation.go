@@ -0,0 +1,832 @@
+// Package formvalidation checks submitted form data against the field definitions of a
+// form: value types, required fields, min/max, lengths, regex patterns, option lists and
+// the form's cross-field rules. Field definitions come from the form schema ("fields", keyed
+// by "name") or its steps (keyed by "id"); constraints may sit on the field itself or in
+// its "validation" object, which wins. Fields hidden by their "visible" rule, a condition
+// object or an expression, are not validated, and fields with a "computed" formula are
+// worked out on the server. A repeatable section (a "repeater" field) holds a list of rows,
+// each validated against the section's own "fields".
+package formvalidation
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldError is one problem with one field. Field is empty for form-level rules.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"` // required, type, min, max, min_length, max_length, pattern, option, rule, file, geofence
+	Message string `json:"message"`
+}
+
+// Errors is the list of problems found in a submission
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, fe := range e {
+		if fe.Field == "" {
+			parts[i] = fe.Message
+		} else {
+			parts[i] = fe.Field + ": " + fe.Message
+		}
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+// Field is the validation-relevant part of a field definition
+type Field struct {
+	Name      string
+	Label     string
+	Type      string
+	Required  bool
+	Multiple  bool
+	Min       *float64
+	Max       *float64
+	MinLength *int
+	MaxLength *int
+	Pattern   *regexp.Regexp
+	MinDate   string
+	MaxDate   string
+	Options   []string
+	Message   string
+	Visible   *Condition
+	// VisibleWhen is a visibility rule written as an expression, for conditions the
+	// field/operator/value form cannot say
+	VisibleWhen string
+	// Computed is the formula of a derived field, such as "qty * rate". The server
+	// evaluates it on submission, replacing any value sent for the field.
+	Computed string
+	// Decimals rounds a computed number to this many decimal places
+	Decimals *int
+	// Geofence restricts a geopoint field to a project zone's boundary. The check needs
+	// the zone's geometry, so it is left to the caller.
+	Geofence *Geofence
+	// Section holds the rules of each row of a repeatable section; Min and Max then bound
+	// the number of rows
+	Section *Rules
+
+	visibleWhen expression
+	computed    expression
+}
+
+// shown reports whether the field applies to data under its visibility rules
+func (f Field) shown(data map[string]interface{}) bool {
+	if f.Visible != nil && !f.Visible.holds(data) {
+		return false
+	}
+	return f.visibleWhen == nil || truthy(f.visibleWhen.eval(data))
+}
+
+// Condition is a field's "visible" rule: the field applies only when it holds
+type Condition struct {
+	Field    string      `json:"field"`
+	Operator string      `json:"operator"`
+	Value    interface{} `json:"value"`
+}
+
+// Geofence is a geopoint field's "geofence" rule: the point must fall inside the project
+// zone named by the form field ZoneField, or else the zone ZoneID. Tolerance widens the
+// boundary by that many metres to allow for GPS error.
+type Geofence struct {
+	ZoneField string  `json:"zone_field,omitempty"`
+	ZoneID    string  `json:"zone_id,omitempty"`
+	Tolerance float64 `json:"tolerance,omitempty"`
+}
+
+// GeoPoint is the value of a geopoint field, in WGS 84 degrees. Accuracy is the radius in
+// metres the device reported, when it did.
+type GeoPoint struct {
+	Latitude  float64
+	Longitude float64
+	Accuracy  *float64
+}
+
+// Value is the point as stored: {"latitude", "longitude"} and "accuracy" when known
+func (p GeoPoint) Value() map[string]interface{} {
+	value := map[string]interface{}{"latitude": p.Latitude, "longitude": p.Longitude}
+	if p.Accuracy != nil {
+		value["accuracy"] = *p.Accuracy
+	}
+	return value
+}
+
+// ParseGeoPoint reads a geopoint value: an object with latitude and longitude (or lat and
+// lng/lon), or a GeoJSON Point. It reports false for anything else, including
+// coordinates out of range.
+func ParseGeoPoint(value interface{}) (GeoPoint, bool) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return GeoPoint{}, false
+	}
+	var point GeoPoint
+	if coordinates, ok := object["coordinates"].([]interface{}); ok && object["type"] == "Point" {
+		if len(coordinates) < 2 {
+			return GeoPoint{}, false
+		}
+		lng, lngOK := number(coordinates[0])
+		lat, latOK := number(coordinates[1])
+		if !lngOK || !latOK {
+			return GeoPoint{}, false
+		}
+		point.Latitude, point.Longitude = lat, lng
+	} else {
+		lat, latOK := firstNumber(object, "latitude", "lat")
+		lng, lngOK := firstNumber(object, "longitude", "lng", "lon")
+		if !latOK || !lngOK {
+			return GeoPoint{}, false
+		}
+		point.Latitude, point.Longitude = lat, lng
+	}
+	if point.Latitude < -90 || point.Latitude > 90 || point.Longitude < -180 || point.Longitude > 180 {
+		return GeoPoint{}, false
+	}
+	if accuracy, ok := number(object["accuracy"]); ok && accuracy >= 0 {
+		point.Accuracy = &accuracy
+	}
+	return point, true
+}
+
+// firstNumber returns the number under the first of keys that object has
+func firstNumber(object map[string]interface{}, keys ...string) (float64, bool) {
+	for _, key := range keys {
+		if value, present := object[key]; present {
+			return number(value)
+		}
+	}
+	return 0, false
+}
+
+// CrossFieldRule flags the submission when Condition, an expression over field values
+// such as "purpose === 'other' && !purpose_other", holds
+type CrossFieldRule struct {
+	Rule      string   `json:"rule"`
+	Fields    []string `json:"fields"`
+	Condition string   `json:"condition"`
+	Message   string   `json:"message"`
+	expr      expression
+}
+
+// SectionType is the field type of a repeatable section
+const SectionType = "repeater"
+
+// Rules validates submissions of one form
+type Rules struct {
+	Fields     []Field
+	CrossField []CrossFieldRule
+	// Invalid lists definitions that could not be compiled and are not enforced
+	Invalid []error
+}
+
+// Options tunes a validation run
+type Options struct {
+	// Partial checks only the values present, skipping required fields and cross-field
+	// rules, as drafts may be saved incomplete
+	Partial bool
+	// Now is the reference time for "today" in date bounds; zero means time.Now()
+	Now time.Time
+}
+
+// FieldDefinitions returns the raw field definitions of a form: the form schema's fields,
+// or else the fields of every step in order
+func FieldDefinitions(formSchema, steps json.RawMessage) ([]map[string]interface{}, []error) {
+	var raw []map[string]interface{}
+	var errs []error
+	if len(formSchema) > 0 && string(formSchema) != "{}" {
+		var schema struct {
+			Fields []map[string]interface{} `json:"fields"`
+		}
+		if err := json.Unmarshal(formSchema, &schema); err != nil {
+			errs = append(errs, fmt.Errorf("form schema: %v", err))
+		}
+		raw = schema.Fields
+	}
+	if len(raw) == 0 && len(steps) > 0 && string(steps) != "[]" {
+		var stepList []struct {
+			Fields []map[string]interface{} `json:"fields"`
+		}
+		if err := json.Unmarshal(steps, &stepList); err != nil {
+			errs = append(errs, fmt.Errorf("form steps: %v", err))
+		}
+		for _, step := range stepList {
+			raw = append(raw, step.Fields...)
+		}
+	}
+	return raw, errs
+}
+
+// FromForm builds the rules of a form from its schema, steps and validations JSON
+func FromForm(formSchema, steps, validations json.RawMessage) *Rules {
+	rules := &Rules{}
+
+	raw, errs := FieldDefinitions(formSchema, steps)
+	rules.Invalid = append(rules.Invalid, errs...)
+	for _, def := range raw {
+		field, err := parseField(def)
+		if err != nil {
+			rules.Invalid = append(rules.Invalid, err)
+			continue
+		}
+		if field.Name != "" {
+			rules.Fields = append(rules.Fields, field)
+		}
+		if field.Section != nil {
+			for _, err := range field.Section.Invalid {
+				rules.Invalid = append(rules.Invalid, fmt.Errorf("section %s: %v", field.Name, err))
+			}
+		}
+	}
+
+	if len(validations) > 0 && string(validations) != "{}" {
+		var formRules struct {
+			CrossField []CrossFieldRule `json:"cross_field"`
+		}
+		if err := json.Unmarshal(validations, &formRules); err != nil {
+			rules.Invalid = append(rules.Invalid, fmt.Errorf("form validations: %v", err))
+		}
+		for _, rule := range formRules.CrossField {
+			expr, err := parseExpression(rule.Condition)
+			if err != nil {
+				rules.Invalid = append(rules.Invalid, fmt.Errorf("cross-field rule %q: %v", rule.Condition, err))
+				continue
+			}
+			rule.expr = expr
+			rules.CrossField = append(rules.CrossField, rule)
+		}
+	}
+	return rules
+}
+
+func parseField(def map[string]interface{}) (Field, error) {
+	field := Field{}
+	field.Name, _ = def["name"].(string)
+	if field.Name == "" {
+		field.Name, _ = def["id"].(string)
+	}
+	field.Label, _ = def["label"].(string)
+	field.Type, _ = def["type"].(string)
+	field.Required, _ = def["required"].(bool)
+	field.Multiple, _ = def["multiple"].(bool)
+
+	constraints := []map[string]interface{}{def}
+	if nested, ok := def["validation"].(map[string]interface{}); ok {
+		constraints = append(constraints, nested)
+	}
+	for _, c := range constraints {
+		if v, ok := c["required"].(bool); ok {
+			field.Required = v
+		}
+		if v, ok := number(c["min"]); ok {
+			field.Min = &v
+		}
+		if v, ok := number(c["max"]); ok {
+			field.Max = &v
+		}
+		for _, key := range []string{"minLength", "min_length"} {
+			if v, ok := number(c[key]); ok {
+				n := int(v)
+				field.MinLength = &n
+			}
+		}
+		for _, key := range []string{"maxLength", "max_length"} {
+			if v, ok := number(c[key]); ok {
+				n := int(v)
+				field.MaxLength = &n
+			}
+		}
+		if v, ok := c["pattern"].(string); ok && v != "" {
+			pattern, err := regexp.Compile(v)
+			if err != nil {
+				return field, fmt.Errorf("field %s: invalid pattern: %v", field.Name, err)
+			}
+			field.Pattern = pattern
+		}
+		if v, ok := c["minDate"].(string); ok {
+			field.MinDate = v
+		}
+		if v, ok := c["maxDate"].(string); ok {
+			field.MaxDate = v
+		}
+		if v, ok := c["message"].(string); ok && v != "" {
+			field.Message = v
+		}
+		if v, ok := c["geofence"].(map[string]interface{}); ok {
+			geofence := &Geofence{}
+			geofence.ZoneField, _ = v["zone_field"].(string)
+			geofence.ZoneID, _ = v["zone_id"].(string)
+			if tolerance, ok := number(v["tolerance"]); ok && tolerance > 0 {
+				geofence.Tolerance = tolerance
+			}
+			if geofence.ZoneField == "" && geofence.ZoneID == "" {
+				return field, fmt.Errorf("field %s: geofence needs a zone_field or zone_id", field.Name)
+			}
+			field.Geofence = geofence
+		}
+	}
+
+	if options, ok := def["options"].([]interface{}); ok {
+		for _, option := range options {
+			switch o := option.(type) {
+			case string:
+				field.Options = append(field.Options, o)
+			case map[string]interface{}:
+				if v, ok := o["value"]; ok && v != nil {
+					field.Options = append(field.Options, fmt.Sprint(v))
+				}
+			}
+		}
+	}
+
+	switch visible := def["visible"].(type) {
+	case map[string]interface{}:
+		raw, _ := json.Marshal(visible)
+		var condition Condition
+		if err := json.Unmarshal(raw, &condition); err == nil && condition.Field != "" {
+			field.Visible = &condition
+		}
+	case string:
+		expr, err := parseExpression(visible)
+		if err != nil {
+			return field, fmt.Errorf("field %s: invalid visible rule: %v", field.Name, err)
+		}
+		field.VisibleWhen, field.visibleWhen = visible, expr
+	}
+
+	formula, _ := def["computed"].(string)
+	if formula == "" {
+		formula, _ = def["formula"].(string)
+	}
+	if formula != "" {
+		expr, err := parseExpression(formula)
+		if err != nil {
+			return field, fmt.Errorf("field %s: invalid formula: %v", field.Name, err)
+		}
+		field.Computed, field.computed = formula, expr
+		if v, ok := number(def["decimals"]); ok && v >= 0 {
+			n := int(v)
+			field.Decimals = &n
+		}
+	}
+
+	if field.Type == SectionType {
+		field.Section = &Rules{}
+		subFields, _ := def["fields"].([]interface{})
+		for _, raw := range subFields {
+			subDef, ok := raw.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			subField, err := parseField(subDef)
+			if err != nil {
+				field.Section.Invalid = append(field.Section.Invalid, err)
+				continue
+			}
+			if subField.Name != "" {
+				field.Section.Fields = append(field.Section.Fields, subField)
+			}
+		}
+	}
+	return field, nil
+}
+
+// Compute sets each computed field in data to its formula's value, replacing whatever was
+// sent, so stored values are the server's. Formulas may use other computed fields. In
+// partial mode a formula that cannot be worked out yet leaves its field as it is.
+func (r *Rules) Compute(data map[string]interface{}, partial bool) {
+	var computed []Field
+	for _, field := range r.Fields {
+		if field.computed != nil {
+			computed = append(computed, field)
+		}
+	}
+	// Each pass settles at least one more link of a chain of formulas
+	for pass := 0; pass < len(computed); pass++ {
+		changed := false
+		for _, field := range computed {
+			value := field.computed.eval(data)
+			if n, ok := value.(float64); ok {
+				switch {
+				case math.IsNaN(n) || math.IsInf(n, 0):
+					value = nil
+				case field.Decimals != nil:
+					value = roundTo(n, *field.Decimals)
+				}
+			}
+			if value == nil && partial {
+				continue
+			}
+			if current, present := data[field.Name]; !present || !reflect.DeepEqual(current, value) {
+				data[field.Name] = value
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+}
+
+// FieldLogic is the conditional logic of a field, for clients to show, hide and
+// recompute fields as the user types the way the server will on submission
+type FieldLogic struct {
+	Name        string     `json:"name"`
+	Visible     *Condition `json:"visible,omitempty"`
+	VisibleWhen string     `json:"visible_when,omitempty"`
+	Computed    string     `json:"computed,omitempty"`
+	Decimals    *int       `json:"decimals,omitempty"`
+	// DependsOn lists the fields whose changes can alter the field's visibility or value
+	DependsOn []string `json:"depends_on"`
+}
+
+// Logic returns the logic of the fields that have visibility rules or formulas
+func (r *Rules) Logic() []FieldLogic {
+	logic := make([]FieldLogic, 0)
+	for _, field := range r.Fields {
+		if field.Visible == nil && field.visibleWhen == nil && field.computed == nil {
+			continue
+		}
+		entry := FieldLogic{
+			Name:        field.Name,
+			Visible:     field.Visible,
+			VisibleWhen: field.VisibleWhen,
+			Computed:    field.Computed,
+			Decimals:    field.Decimals,
+			DependsOn:   []string{},
+		}
+		if field.Visible != nil {
+			entry.DependsOn = append(entry.DependsOn, field.Visible.Field)
+		}
+		for _, expr := range []expression{field.visibleWhen, field.computed} {
+			if expr == nil {
+				continue
+			}
+			for _, name := range referencedFields(expr) {
+				if !slices.Contains(entry.DependsOn, name) {
+					entry.DependsOn = append(entry.DependsOn, name)
+				}
+			}
+		}
+		logic = append(logic, entry)
+	}
+	return logic
+}
+
+// Validate checks data against the rules. Computed fields are worked out first, and
+// values that coerce to the field's type, such as a numeric string for a number field,
+// are replaced in data with their typed form. A full validation also clears the values of
+// hidden fields, which no longer apply.
+func (r *Rules) Validate(data map[string]interface{}, opts Options) Errors {
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	r.Compute(data, opts.Partial)
+
+	var errs Errors
+	for _, field := range r.Fields {
+		if !field.shown(data) {
+			if _, present := data[field.Name]; present && !opts.Partial {
+				data[field.Name] = nil
+			}
+			continue
+		}
+		value, present := data[field.Name]
+		if !present || isEmpty(value) {
+			if field.Required && !opts.Partial {
+				errs = append(errs, field.fail("required", "%s is required", field.display()))
+			}
+			continue
+		}
+		var coerced interface{}
+		var fieldErrs Errors
+		if field.Section != nil {
+			coerced, fieldErrs = field.checkRows(value, opts)
+		} else {
+			coerced, fieldErrs = field.check(value, now)
+		}
+		if len(fieldErrs) > 0 {
+			errs = append(errs, fieldErrs...)
+			continue
+		}
+		data[field.Name] = coerced
+	}
+
+	if !opts.Partial {
+		for _, rule := range r.CrossField {
+			if !truthy(rule.expr.eval(data)) {
+				continue
+			}
+			field := ""
+			if len(rule.Fields) > 0 {
+				field = rule.Fields[len(rule.Fields)-1]
+			}
+			message := rule.Message
+			if message == "" {
+				message = rule.Rule
+			}
+			if message == "" {
+				message = "violates rule " + rule.Condition
+			}
+			errs = append(errs, FieldError{Field: field, Code: "rule", Message: message})
+		}
+	}
+
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+	return errs
+}
+
+func (f Field) display() string {
+	if f.Label != "" {
+		return f.Label
+	}
+	return f.Name
+}
+
+// fail builds an error carrying the field's own message when it defines one
+func (f Field) fail(code, format string, args ...interface{}) FieldError {
+	message := f.Message
+	if message == "" {
+		message = fmt.Sprintf(format, args...)
+	}
+	return FieldError{Field: f.Name, Code: code, Message: message}
+}
+
+// check validates a present value and returns it in its typed form
+func (f Field) check(value interface{}, now time.Time) (interface{}, Errors) {
+	switch f.Type {
+	case "number", "integer", "decimal", "currency":
+		n, ok := number(value)
+		if !ok {
+			return nil, Errors{f.fail("type", "%s must be a number", f.display())}
+		}
+		if f.Type == "integer" && n != math.Trunc(n) {
+			return nil, Errors{f.fail("type", "%s must be a whole number", f.display())}
+		}
+		var errs Errors
+		if f.Min != nil && n < *f.Min {
+			errs = append(errs, f.fail("min", "%s must be at least %v", f.display(), *f.Min))
+		}
+		if f.Max != nil && n > *f.Max {
+			errs = append(errs, f.fail("max", "%s must be at most %v", f.display(), *f.Max))
+		}
+		return n, errs
+
+	case "boolean", "checkbox":
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b, nil
+			}
+		}
+		return nil, Errors{f.fail("type", "%s must be true or false", f.display())}
+
+	case "date", "datetime", "timestamp", "time":
+		s, ok := value.(string)
+		if !ok {
+			return nil, Errors{f.fail("type", "%s must be a %s string", f.display(), f.Type)}
+		}
+		t, ok := parseTemporal(f.Type, s)
+		if !ok {
+			return nil, Errors{f.fail("type", "%s is not a valid %s", f.display(), f.Type)}
+		}
+		var errs Errors
+		if f.Type != "time" {
+			if bound, ok := dateBound(f.MinDate, now); ok && t.Before(bound) {
+				errs = append(errs, f.fail("min", "%s must not be before %s", f.display(), bound.Format("2006-01-02")))
+			}
+			if bound, ok := dateBound(f.MaxDate, now); ok && t.After(bound.Add(24*time.Hour-time.Nanosecond)) {
+				errs = append(errs, f.fail("max", "%s must not be after %s", f.display(), bound.Format("2006-01-02")))
+			}
+		}
+		return s, errs
+
+	case "multiselect", "checkbox_group":
+		return f.checkList(value)
+
+	case "geopoint":
+		point, ok := ParseGeoPoint(value)
+		if !ok {
+			return nil, Errors{f.fail("type", "%s must be a location with latitude and longitude", f.display())}
+		}
+		return point.Value(), nil
+	}
+
+	if f.Multiple {
+		return f.checkList(value)
+	}
+
+	// Text-like and option fields hold strings; other values are stored as given
+	s, isString := value.(string)
+	if !isString {
+		if len(f.Options) > 0 || f.Pattern != nil || f.MinLength != nil || f.MaxLength != nil {
+			s = fmt.Sprint(value)
+		} else {
+			return value, nil
+		}
+	}
+	var errs Errors
+	switch f.Type {
+	case "email":
+		if _, err := mail.ParseAddress(s); err != nil {
+			errs = append(errs, f.fail("type", "%s must be an email address", f.display()))
+		}
+	case "url":
+		if u, err := url.ParseRequestURI(s); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, f.fail("type", "%s must be a URL", f.display()))
+		}
+	}
+	length := len([]rune(s))
+	if f.MinLength != nil && length < *f.MinLength {
+		errs = append(errs, f.fail("min_length", "%s must be at least %d characters", f.display(), *f.MinLength))
+	}
+	if f.MaxLength != nil && length > *f.MaxLength {
+		errs = append(errs, f.fail("max_length", "%s must be at most %d characters", f.display(), *f.MaxLength))
+	}
+	if f.Pattern != nil && !f.Pattern.MatchString(s) {
+		errs = append(errs, f.fail("pattern", "%s has an invalid format", f.display()))
+	}
+	if len(f.Options) > 0 && !contains(f.Options, s) {
+		errs = append(errs, f.fail("option", "%s must be one of %s", f.display(), strings.Join(f.Options, ", ")))
+	}
+	return value, errs
+}
+
+// checkRows validates the rows of a repeatable section, naming each row's problems
+// "section[index].field". Drafts may have fewer rows than the section's minimum.
+func (f Field) checkRows(value interface{}, opts Options) (interface{}, Errors) {
+	rows, ok := value.([]interface{})
+	if !ok {
+		return nil, Errors{f.fail("type", "%s must be a list of rows", f.display())}
+	}
+	var errs Errors
+	if f.Min != nil && float64(len(rows)) < *f.Min && !opts.Partial {
+		errs = append(errs, f.fail("min", "%s needs at least %v rows", f.display(), *f.Min))
+	}
+	if f.Max != nil && float64(len(rows)) > *f.Max {
+		errs = append(errs, f.fail("max", "%s allows at most %v rows", f.display(), *f.Max))
+	}
+	for i, raw := range rows {
+		prefix := fmt.Sprintf("%s[%d]", f.Name, i)
+		row, ok := raw.(map[string]interface{})
+		if !ok {
+			errs = append(errs, FieldError{Field: prefix, Code: "type", Message: fmt.Sprintf("row %d of %s must be an object", i+1, f.display())})
+			continue
+		}
+		for _, e := range f.Section.Validate(row, opts) {
+			if e.Field == "" {
+				e.Field = prefix
+			} else {
+				e.Field = prefix + "." + e.Field
+			}
+			errs = append(errs, e)
+		}
+	}
+	return rows, errs
+}
+
+// checkList validates a multi-value field: a list whose items are among the options
+func (f Field) checkList(value interface{}) (interface{}, Errors) {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, Errors{f.fail("type", "%s must be a list", f.display())}
+	}
+	var errs Errors
+	if f.Min != nil && float64(len(items)) < *f.Min {
+		errs = append(errs, f.fail("min", "%s needs at least %v choices", f.display(), *f.Min))
+	}
+	if f.Max != nil && float64(len(items)) > *f.Max {
+		errs = append(errs, f.fail("max", "%s allows at most %v choices", f.display(), *f.Max))
+	}
+	if len(f.Options) > 0 {
+		for _, item := range items {
+			if !contains(f.Options, fmt.Sprint(item)) {
+				errs = append(errs, f.fail("option", "%v is not an option of %s", item, f.display()))
+				break
+			}
+		}
+	}
+	return value, errs
+}
+
+func (c *Condition) holds(data map[string]interface{}) bool {
+	value := data[c.Field]
+	switch c.Operator {
+	case "", "equals", "eq", "===", "==":
+		return equal(value, c.Value)
+	case "not_equals", "neq", "!==", "!=":
+		return !equal(value, c.Value)
+	case "in":
+		list, _ := c.Value.([]interface{})
+		for _, v := range list {
+			if equal(value, v) {
+				return true
+			}
+		}
+		return false
+	case "not_in":
+		list, _ := c.Value.([]interface{})
+		for _, v := range list {
+			if equal(value, v) {
+				return false
+			}
+		}
+		return true
+	case "is_empty", "empty":
+		return isEmpty(value)
+	case "is_not_empty", "not_empty":
+		return !isEmpty(value)
+	case "greater_than", "gt":
+		return compare(value, c.Value) > 0
+	case "less_than", "lt":
+		return compare(value, c.Value) < 0
+	}
+	// Unknown operators leave the field visible, so it is still validated
+	return true
+}
+
+func parseTemporal(fieldType, s string) (time.Time, bool) {
+	var layouts []string
+	switch fieldType {
+	case "date":
+		layouts = []string{"2006-01-02", time.RFC3339, time.RFC3339Nano}
+	case "time":
+		layouts = []string{"15:04", "15:04:05"}
+	default:
+		layouts = []string{time.RFC3339, time.RFC3339Nano, "2006-01-02T15:04", "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// dateBound resolves "today" or a YYYY-MM-DD date to the start of that day
+func dateBound(bound string, now time.Time) (time.Time, bool) {
+	switch bound {
+	case "":
+		return time.Time{}, false
+	case "today":
+		y, m, d := now.Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC), true
+	}
+	t, err := time.Parse("2006-01-02", bound)
+	return t, err == nil
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil && !math.IsNaN(f) && !math.IsInf(f, 0)
+	}
+	return 0, false
+}
+
+func isEmpty(v interface{}) bool {
+	switch x := v.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(x) == ""
+	case []interface{}:
+		return len(x) == 0
+	case map[string]interface{}:
+		return len(x) == 0
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
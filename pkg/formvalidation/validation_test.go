@@ -0,0 +1,329 @@
+package formvalidation
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+var waterSteps = json.RawMessage(`[
+  {"id": "basic", "fields": [
+    {"id": "date", "type": "date", "label": "Date", "required": true, "validation": {"maxDate": "today"}},
+    {"id": "shift", "type": "radio", "required": true,
+     "options": [{"label": "Morning", "value": "morning"}, {"label": "Night", "value": "night"}]}
+  ]},
+  {"id": "details", "fields": [
+    {"id": "tanker_number", "type": "text", "required": true,
+     "validation": {"pattern": "^[A-Z0-9-]+$", "message": "Invalid tanker number format"}},
+    {"id": "quantity", "type": "number", "label": "Quantity", "required": true, "min": 1, "max": 100000},
+    {"id": "purpose", "type": "dropdown", "options": [{"value": "construction"}, {"value": "other"}]},
+    {"id": "purpose_other", "type": "text", "maxLength": 20,
+     "visible": {"field": "purpose", "operator": "equals", "value": "other"}}
+  ]}
+]`)
+
+var waterValidations = json.RawMessage(`{"cross_field": [
+  {"fields": ["purpose", "purpose_other"], "condition": "purpose === 'other' && !purpose_other",
+   "message": "Please specify the purpose"}
+]}`)
+
+func errorCodes(errs Errors) map[string]string {
+	codes := make(map[string]string)
+	for _, e := range errs {
+		codes[e.Field] = e.Code
+	}
+	return codes
+}
+
+func TestValidate(t *testing.T) {
+	rules := FromForm(nil, waterSteps, waterValidations)
+	if len(rules.Invalid) > 0 {
+		t.Fatalf("unexpected invalid definitions: %v", rules.Invalid)
+	}
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	valid := map[string]interface{}{
+		"date": "2026-10-16", "shift": "night", "tanker_number": "KA-01-1234",
+		"quantity": "2500", "purpose": "construction",
+	}
+	if errs := rules.Validate(valid, Options{Now: now}); len(errs) > 0 {
+		t.Fatalf("valid submission: %v", errs)
+	}
+	if valid["quantity"] != 2500.0 {
+		t.Errorf("quantity not coerced to a number: %#v", valid["quantity"])
+	}
+
+	invalid := map[string]interface{}{
+		"date": "2026-10-17", "shift": "evening", "tanker_number": "ka 01",
+		"quantity": 0, "purpose": "other",
+	}
+	got := errorCodes(rules.Validate(invalid, Options{Now: now}))
+	want := map[string]string{
+		"date": "max", "shift": "option", "tanker_number": "pattern", "quantity": "min", "purpose_other": "rule",
+	}
+	for field, code := range want {
+		if got[field] != code {
+			t.Errorf("%s: got code %q, want %q (all: %v)", field, got[field], code, got)
+		}
+	}
+
+	missing := map[string]interface{}{"quantity": "lots", "purpose_other": "ignored while hidden, far too long"}
+	got = errorCodes(rules.Validate(missing, Options{Now: now}))
+	for _, field := range []string{"date", "shift", "tanker_number"} {
+		if got[field] != "required" {
+			t.Errorf("%s: got code %q, want required", field, got[field])
+		}
+	}
+	if got["quantity"] != "type" {
+		t.Errorf("quantity: got code %q, want type", got["quantity"])
+	}
+	if _, flagged := got["purpose_other"]; flagged {
+		t.Error("hidden purpose_other should not be validated")
+	}
+
+	draft := map[string]interface{}{"quantity": "12"}
+	if errs := rules.Validate(draft, Options{Partial: true, Now: now}); len(errs) > 0 {
+		t.Errorf("partial draft: %v", errs)
+	}
+}
+
+func TestFormSchemaFields(t *testing.T) {
+	rules := FromForm(json.RawMessage(`{"fields": [
+		{"name": "count", "type": "integer", "required": true},
+		{"name": "email", "type": "email"},
+		{"name": "tags", "type": "multiselect", "options": ["a", "b"]},
+		{"name": "bad", "type": "text", "pattern": "("}
+	]}`), nil, nil)
+	if len(rules.Invalid) != 1 {
+		t.Errorf("want the bad pattern reported, got %v", rules.Invalid)
+	}
+	got := errorCodes(rules.Validate(map[string]interface{}{
+		"count": 2.5, "email": "not-an-email", "tags": []interface{}{"a", "c"},
+	}, Options{}))
+	want := map[string]string{"count": "type", "email": "type", "tags": "option"}
+	for field, code := range want {
+		if got[field] != code {
+			t.Errorf("%s: got code %q, want %q", field, got[field], code)
+		}
+	}
+}
+
+func TestParseExpression(t *testing.T) {
+	data := map[string]interface{}{"a": "x", "n": 5.0, "start": "2026-01-02", "end": "2026-01-01", "empty": ""}
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{"a === 'x'", true},
+		{"a !== \"x\"", false},
+		{"n > 3 && n <= 5", true},
+		{"n == '5'", true},
+		{"!(a === 'y') || missing", true},
+		{"end < start", true},
+		{"!empty && !missing", true},
+		{"!a", false},
+		{"missing === null", true},
+	}
+	for _, tt := range tests {
+		expr, err := parseExpression(tt.source)
+		if err != nil {
+			t.Errorf("%s: %v", tt.source, err)
+			continue
+		}
+		if got := truthy(expr.eval(data)); got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.source, got, tt.want)
+		}
+	}
+
+	for _, source := range []string{"", "a ===", "(a", "a = 'x'", "'open"} {
+		if _, err := parseExpression(source); err == nil {
+			t.Errorf("%q: want a parse error", source)
+		}
+	}
+}
+
+func TestFormulas(t *testing.T) {
+	data := map[string]interface{}{"qty": "4", "rate": 2.5, "first": "Asha", "last": "Rao", "zero": 0.0}
+	tests := []struct {
+		source string
+		want   interface{}
+	}{
+		{"qty * rate", 10.0},
+		{"qty - 1 + rate * 2", 8.0},
+		{"qty-1", 3.0},
+		{"-(qty + 1) % 3", -2.0},
+		{"round(rate / 3, 2)", 0.83},
+		{"max(qty, rate, 7) - min(qty, rate)", 4.5},
+		{"first + ' ' + last", "Asha Rao"},
+		{"qty * missing", nil},
+		{"qty / zero", nil},
+		{"qty * rate >= 10", true},
+	}
+	for _, tt := range tests {
+		expr, err := parseExpression(tt.source)
+		if err != nil {
+			t.Errorf("%s: %v", tt.source, err)
+			continue
+		}
+		if got := expr.eval(data); got != tt.want {
+			t.Errorf("%s = %#v, want %#v", tt.source, got, tt.want)
+		}
+	}
+
+	for _, source := range []string{"qty *", "round()", "sqrt(qty)", "max(qty,"} {
+		if _, err := parseExpression(source); err == nil {
+			t.Errorf("%q: want a parse error", source)
+		}
+	}
+}
+
+func TestComputedAndVisibleFields(t *testing.T) {
+	rules := FromForm(json.RawMessage(`{"fields": [
+		{"name": "qty", "type": "number", "required": true},
+		{"name": "rate", "type": "number", "required": true},
+		{"name": "subtotal", "type": "number", "computed": "qty * rate"},
+		{"name": "total", "type": "number", "formula": "subtotal * 1.18", "decimals": 2, "max": 1000},
+		{"name": "discount_reason", "type": "text", "required": true, "visible": "total > 500"}
+	]}`), nil, nil)
+	if len(rules.Invalid) > 0 {
+		t.Fatalf("unexpected invalid definitions: %v", rules.Invalid)
+	}
+
+	data := map[string]interface{}{"qty": "3", "rate": "33.33", "total": 1, "discount_reason": "stale"}
+	if errs := rules.Validate(data, Options{}); len(errs) > 0 {
+		t.Fatalf("valid submission: %v", errs)
+	}
+	if data["subtotal"] != 99.99 || data["total"] != 117.99 {
+		t.Errorf("got subtotal %v, total %v", data["subtotal"], data["total"])
+	}
+	if data["discount_reason"] != nil {
+		t.Errorf("hidden field kept %v", data["discount_reason"])
+	}
+
+	data = map[string]interface{}{"qty": 10, "rate": 100}
+	got := errorCodes(rules.Validate(data, Options{}))
+	if got["total"] != "max" || got["discount_reason"] != "required" {
+		t.Errorf("got %v", got)
+	}
+
+	draft := map[string]interface{}{"qty": 2, "total": 5}
+	if errs := rules.Validate(draft, Options{Partial: true}); len(errs) > 0 {
+		t.Errorf("partial draft: %v", errs)
+	}
+	if draft["total"] != 5.0 {
+		t.Errorf("partial draft total changed to %v", draft["total"])
+	}
+
+	logic := rules.Logic()
+	if len(logic) != 3 {
+		t.Fatalf("got logic for %d fields", len(logic))
+	}
+	if logic[1].Name != "total" || logic[1].Computed != "subtotal * 1.18" || len(logic[1].DependsOn) != 1 {
+		t.Errorf("got %+v", logic[1])
+	}
+	if logic[2].VisibleWhen != "total > 500" || logic[2].DependsOn[0] != "total" {
+		t.Errorf("got %+v", logic[2])
+	}
+}
+
+func TestGeoPointFields(t *testing.T) {
+	schema := json.RawMessage(`{"fields": [
+	  {"name": "zone_id", "type": "text"},
+	  {"name": "reading_location", "type": "geopoint", "required": true,
+	   "validation": {"geofence": {"zone_field": "zone_id", "tolerance": 25}}},
+	  {"name": "broken", "type": "geopoint", "geofence": {"tolerance": 10}}
+	]}`)
+	rules := FromForm(schema, nil, nil)
+	if len(rules.Invalid) != 1 {
+		t.Fatalf("want the geofence without a zone reported, got %v", rules.Invalid)
+	}
+	geofence := rules.Fields[1].Geofence
+	if geofence == nil || geofence.ZoneField != "zone_id" || geofence.Tolerance != 25 {
+		t.Fatalf("geofence not parsed: %+v", geofence)
+	}
+
+	for _, value := range []interface{}{
+		map[string]interface{}{"latitude": 12.97, "longitude": 77.59},
+		map[string]interface{}{"lat": "12.97", "lng": "77.59"},
+		map[string]interface{}{"type": "Point", "coordinates": []interface{}{77.59, 12.97}},
+	} {
+		data := map[string]interface{}{"reading_location": value}
+		if errs := rules.Validate(data, Options{}); len(errs) > 0 {
+			t.Errorf("%v: %v", value, errs)
+			continue
+		}
+		if got := data["reading_location"].(map[string]interface{}); got["latitude"] != 12.97 || got["longitude"] != 77.59 {
+			t.Errorf("%v stored as %v", value, got)
+		}
+	}
+
+	for _, value := range []interface{}{
+		"12.97,77.59",
+		map[string]interface{}{"latitude": 12.97},
+		map[string]interface{}{"latitude": 91.0, "longitude": 77.59},
+		map[string]interface{}{"type": "Point", "coordinates": []interface{}{77.59}},
+	} {
+		errs := rules.Validate(map[string]interface{}{"reading_location": value}, Options{})
+		if errorCodes(errs)["reading_location"] != "type" {
+			t.Errorf("%v: want a type error, got %v", value, errs)
+		}
+	}
+
+	point, ok := ParseGeoPoint(map[string]interface{}{"latitude": 1.0, "longitude": 2.0, "accuracy": 8.0})
+	if !ok || point.Accuracy == nil || *point.Accuracy != 8 || point.Value()["accuracy"] != 8.0 {
+		t.Errorf("accuracy not kept: %+v", point)
+	}
+}
+
+func TestSections(t *testing.T) {
+	schema := json.RawMessage(`{"fields": [
+	  {"name": "segments", "label": "Pipe segments", "type": "repeater", "required": true, "min": 1, "max": 3,
+	   "fields": [
+	     {"name": "diameter", "type": "number", "required": true, "min": 50},
+	     {"name": "material", "type": "select", "options": ["pvc", "steel"]},
+	     {"name": "length", "type": "decimal"},
+	     {"name": "cost", "type": "decimal", "computed": "length * 10"}
+	   ]}
+	]}`)
+	rules := FromForm(schema, nil, nil)
+	if len(rules.Invalid) > 0 || rules.Fields[0].Section == nil || len(rules.Fields[0].Section.Fields) != 4 {
+		t.Fatalf("section not parsed: %+v, %v", rules.Fields, rules.Invalid)
+	}
+
+	data := map[string]interface{}{"segments": []interface{}{
+		map[string]interface{}{"diameter": "110", "material": "pvc", "length": 4},
+	}}
+	if errs := rules.Validate(data, Options{}); len(errs) > 0 {
+		t.Fatalf("valid rows: %v", errs)
+	}
+	row := data["segments"].([]interface{})[0].(map[string]interface{})
+	if row["diameter"] != 110.0 || row["cost"] != 40.0 {
+		t.Errorf("row not coerced and computed: %v", row)
+	}
+
+	data = map[string]interface{}{"segments": []interface{}{
+		map[string]interface{}{"diameter": 20, "material": "clay"},
+		"not a row",
+	}}
+	got := errorCodes(rules.Validate(data, Options{}))
+	want := map[string]string{"segments[0].diameter": "min", "segments[0].material": "option", "segments[1]": "type"}
+	for field, code := range want {
+		if got[field] != code {
+			t.Errorf("%s: got %q, want %q (all: %v)", field, got[field], code, got)
+		}
+	}
+
+	if got := errorCodes(rules.Validate(map[string]interface{}{"segments": []interface{}{}}, Options{})); got["segments"] != "required" {
+		t.Errorf("empty section: %v", got)
+	}
+	if errs := rules.Validate(map[string]interface{}{"segments": []interface{}{}}, Options{Partial: true}); len(errs) > 0 {
+		t.Errorf("drafts may have no rows yet: %v", errs)
+	}
+	rows := []interface{}{}
+	for i := 0; i < 4; i++ {
+		rows = append(rows, map[string]interface{}{"diameter": 60})
+	}
+	if got := errorCodes(rules.Validate(map[string]interface{}{"segments": rows}, Options{})); got["segments"] != "max" {
+		t.Errorf("too many rows: %v", got)
+	}
+}
@@ -0,0 +1,85 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultFrankfurterURL = "https://api.frankfurter.app/latest"
+
+func init() {
+	RegisterProvider("frankfurter", newFrankfurterProvider)
+}
+
+// frankfurterProvider calls a Frankfurter-compatible /latest endpoint, which quotes
+// reference rates as units of each currency per unit of the base. An API key, if set,
+// is sent as a bearer token for hosted variants that need one.
+type frankfurterProvider struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+func newFrankfurterProvider() (Provider, error) {
+	endpoint := strings.TrimSpace(os.Getenv("EXCHANGE_RATE_API_URL"))
+	if endpoint == "" {
+		endpoint = defaultFrankfurterURL
+	}
+	return &frankfurterProvider{
+		url:    endpoint,
+		apiKey: strings.TrimSpace(os.Getenv("EXCHANGE_RATE_API_KEY")),
+		client: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (p *frankfurterProvider) Name() string { return "frankfurter" }
+
+func (p *frankfurterProvider) Latest(ctx context.Context, base string, currencies []string) (*Quote, error) {
+	query := url.Values{"from": {base}, "to": {strings.Join(currencies, ",")}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("frankfurter api error (%d): %s", resp.StatusCode, string(body))
+	}
+
+	var payload struct {
+		Date  string             `json:"date"`
+		Rates map[string]float64 `json:"rates"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	date, err := time.Parse("2006-01-02", payload.Date)
+	if err != nil {
+		return nil, fmt.Errorf("frankfurter api returned an invalid date %q", payload.Date)
+	}
+
+	// Quoted per unit of the base; invert to the base's worth of one unit of each
+	quote := &Quote{Date: date, Rates: make(map[string]float64, len(payload.Rates))}
+	for code, perBase := range payload.Rates {
+		if perBase > 0 {
+			quote.Rates[strings.ToUpper(code)] = 1 / perBase
+		}
+	}
+	return quote, nil
+}
@@ -0,0 +1,68 @@
+// Package fx fetches daily exchange rates to the base currency from a pluggable provider.
+package fx
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Quote is what one unit of each currency was worth in the base currency on a day.
+type Quote struct {
+	Date  time.Time
+	Rates map[string]float64
+}
+
+// Provider is an exchange rate backend.
+type Provider interface {
+	Name() string
+	// Latest returns the latest rates of currencies to base.
+	Latest(ctx context.Context, base string, currencies []string) (*Quote, error)
+}
+
+// Factory builds a provider from its environment configuration.
+type Factory func() (Provider, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = map[string]Factory{}
+)
+
+// RegisterProvider makes a provider available under name. Providers register from init().
+func RegisterProvider(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[strings.ToLower(name)] = factory
+}
+
+// Providers lists the registered provider names.
+func Providers() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewProviderFromEnv builds the provider named by EXCHANGE_RATE_PROVIDER (default "frankfurter").
+func NewProviderFromEnv() (Provider, error) {
+	name := strings.ToLower(strings.TrimSpace(os.Getenv("EXCHANGE_RATE_PROVIDER")))
+	if name == "" {
+		name = "frankfurter"
+	}
+
+	factoriesMu.RLock()
+	factory, ok := factories[name]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown exchange rate provider %q (available: %s)", name, strings.Join(Providers(), ", "))
+	}
+	return factory()
+}
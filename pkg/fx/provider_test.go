@@ -0,0 +1,42 @@
+package fx
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFrankfurterLatest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("from") != "INR" || r.URL.Query().Get("to") != "USD,EUR" {
+			t.Errorf("unexpected query %q", r.URL.RawQuery)
+		}
+		_, _ = w.Write([]byte(`{"amount":1.0,"base":"INR","date":"2026-10-16","rates":{"USD":0.0125,"EUR":0.0111}}`))
+	}))
+	defer server.Close()
+	t.Setenv("EXCHANGE_RATE_API_URL", server.URL)
+
+	provider, err := NewProviderFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	quote, err := provider.Latest(context.Background(), "INR", []string{"USD", "EUR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.Date.Format("2006-01-02") != "2026-10-16" {
+		t.Errorf("unexpected date %s", quote.Date)
+	}
+	if math.Abs(quote.Rates["USD"]-80) > 1e-9 {
+		t.Errorf("expected one USD to be worth 80 INR, got %v", quote.Rates["USD"])
+	}
+}
+
+func TestUnknownProvider(t *testing.T) {
+	t.Setenv("EXCHANGE_RATE_PROVIDER", "nope")
+	if _, err := NewProviderFromEnv(); err == nil {
+		t.Error("expected an error for an unknown provider")
+	}
+}
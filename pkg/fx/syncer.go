@@ -0,0 +1,79 @@
+package fx
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/jobrunner"
+)
+
+// Syncer records the provider's latest rate for every active currency once a day. A rate
+// entered by hand for the same day is kept.
+type Syncer struct {
+	db       *gorm.DB
+	provider Provider
+	*jobrunner.Runner
+}
+
+// NewSyncer creates an exchange rate sync job
+func NewSyncer(db *gorm.DB, provider Provider) *Syncer {
+	s := &Syncer{db: db, provider: provider}
+	s.Runner = jobrunner.New("Exchange rate sync", s.run).RunAtStart()
+	return s
+}
+
+func (s *Syncer) run() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	count, err := Sync(ctx, s.db, s.provider)
+	if err != nil {
+		log.Printf("Error syncing exchange rates: %v", err)
+	}
+	if count > 0 {
+		log.Printf("Exchange rate sync: recorded %d rates from %s", count, s.provider.Name())
+	}
+}
+
+// Sync fetches the latest rates of the active currencies from the provider and records
+// them against the date the provider quotes them for, returning how many were recorded
+func Sync(ctx context.Context, db *gorm.DB, provider Provider) (int, error) {
+	var codes []string
+	if err := db.Model(&models.Currency{}).Where("is_active = ? AND code <> ?", true, models.BaseCurrency).
+		Order("code").Pluck("code", &codes).Error; err != nil {
+		return 0, err
+	}
+	if len(codes) == 0 {
+		return 0, nil
+	}
+
+	quote, err := provider.Latest(ctx, models.BaseCurrency, codes)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, code := range codes {
+		rate, ok := quote.Rates[code]
+		if !ok {
+			continue
+		}
+		result := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "currency_code"}, {Name: "rate_date"}},
+			DoUpdates: clause.AssignmentColumns([]string{"rate", "source", "updated_at"}),
+			Where:     clause.Where{Exprs: []clause.Expression{clause.Neq{Column: "exchange_rates.source", Value: models.ExchangeRateManual}}},
+		}).Create(&models.ExchangeRate{
+			CurrencyCode: code,
+			RateDate:     quote.Date,
+			Rate:         rate,
+			Source:       provider.Name(),
+		})
+		if result.Error != nil {
+			return count, result.Error
+		}
+		count += int(result.RowsAffected)
+	}
+	return count, nil
+}
@@ -0,0 +1,103 @@
+package hooks
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Hook names a lifecycle point that plugins can attach handlers to.
+type Hook string
+
+const (
+	HookFormSubmitted      Hook = "form_submitted"
+	HookWorkflowTransition Hook = "workflow_transition"
+	HookTelemetryReading   Hook = "telemetry_reading"
+	HookChatMessage        Hook = "chat_message"
+	HookInvoiceMatched     Hook = "invoice_matched"
+)
+
+// FormSubmittedEvent is fired after a form submission has been persisted.
+type FormSubmittedEvent struct {
+	FormCode           string                 `json:"form_code"`
+	FormID             uuid.UUID              `json:"form_id"`
+	SubmissionID       uuid.UUID              `json:"submission_id"`
+	TableName          string                 `json:"table_name,omitempty"` // set for dedicated-table forms
+	BusinessVerticalID uuid.UUID              `json:"business_vertical_id"`
+	SiteID             *uuid.UUID             `json:"site_id,omitempty"`
+	State              string                 `json:"state"`
+	FormData           map[string]interface{} `json:"form_data"`
+	SubmittedBy        string                 `json:"submitted_by"`
+	SubmittedAt        time.Time              `json:"submitted_at"`
+}
+
+// WorkflowTransitionEvent is fired after a workflow transition has been committed.
+type WorkflowTransitionEvent struct {
+	FormCode           string                 `json:"form_code"`
+	SubmissionID       uuid.UUID              `json:"submission_id"`
+	TableName          string                 `json:"table_name,omitempty"`
+	BusinessVerticalID uuid.UUID              `json:"business_vertical_id"`
+	FromState          string                 `json:"from_state"`
+	ToState            string                 `json:"to_state"`
+	Action             string                 `json:"action"`
+	ActorID            string                 `json:"actor_id"`
+	ActorName          string                 `json:"actor_name"`
+	ActorRole          string                 `json:"actor_role"`
+	Comment            string                 `json:"comment,omitempty"`
+	Metadata           map[string]interface{} `json:"metadata,omitempty"`
+	TransitionedAt     time.Time              `json:"transitioned_at"`
+}
+
+// TelemetryReadingEvent is fired for each accepted reading from a field device
+// (solar inverter, flow meter, rain gauge, ...).
+type TelemetryReadingEvent struct {
+	DeviceID           string                 `json:"device_id"`
+	DeviceType         string                 `json:"device_type"`
+	BusinessVerticalID uuid.UUID              `json:"business_vertical_id"`
+	SiteID             *uuid.UUID             `json:"site_id,omitempty"`
+	Metric             string                 `json:"metric"`
+	Value              float64                `json:"value"`
+	Unit               string                 `json:"unit,omitempty"`
+	RecordedAt         time.Time              `json:"recorded_at"`
+	Attributes         map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// ChatMessageAction names what happened to a chat message.
+type ChatMessageAction string
+
+const (
+	ChatMessageSent         ChatMessageAction = "sent"
+	ChatMessageAcknowledged ChatMessageAction = "acknowledged"
+	ChatMessageDeleted      ChatMessageAction = "deleted"
+)
+
+// ChatMessageEvent is fired after a chat message is sent, acknowledged or deleted.
+// ActorID is the sender for "sent", the acknowledging user for "acknowledged" and
+// the deleting user for "deleted".
+type ChatMessageEvent struct {
+	Action           ChatMessageAction `json:"action"`
+	MessageID        uuid.UUID         `json:"message_id"`
+	ConversationID   uuid.UUID         `json:"conversation_id"`
+	SenderID         string            `json:"sender_id"`
+	ActorID          string            `json:"actor_id"`
+	MentionedUserIDs []string          `json:"mentioned_user_ids,omitempty"`
+	RequiresAck      bool              `json:"requires_ack"`
+	OccurredAt       time.Time         `json:"occurred_at"`
+}
+
+// InvoiceMatchedEvent is fired each time a vendor invoice is three-way matched against
+// its purchase order and goods receipts. Status is "matched" or "mismatch", with the
+// reasons in Exceptions.
+type InvoiceMatchedEvent struct {
+	InvoiceID          uuid.UUID `json:"invoice_id"`
+	InvoiceNumber      string    `json:"invoice_number"`
+	PurchaseOrderID    uuid.UUID `json:"purchase_order_id"`
+	OrderNumber        string    `json:"order_number"`
+	BusinessVerticalID uuid.UUID `json:"business_vertical_id"`
+	VendorName         string    `json:"vendor_name"`
+	TotalAmount        float64   `json:"total_amount"`
+	Status             string    `json:"status"`
+	Exceptions         []string  `json:"exceptions,omitempty"`
+	MatchedBy          string    `json:"matched_by"`
+	MatchedAt          time.Time `json:"matched_at"`
+}
@@ -0,0 +1,109 @@
+package hooks
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type outcome struct {
+	err      error
+	panicked bool
+	timedOut bool
+}
+
+// HookMetrics are the execution counters for one plugin handler on one hook.
+type HookMetrics struct {
+	Plugin          string     `json:"plugin"`
+	Hook            Hook       `json:"hook"`
+	Invocations     uint64     `json:"invocations"`
+	Successes       uint64     `json:"successes"`
+	Failures        uint64     `json:"failures"`
+	Panics          uint64     `json:"panics"`
+	Timeouts        uint64     `json:"timeouts"`
+	Dropped         uint64     `json:"dropped"`
+	AvgDurationMs   float64    `json:"avg_duration_ms"`
+	MaxDurationMs   float64    `json:"max_duration_ms"`
+	LastError       string     `json:"last_error,omitempty"`
+	LastErrorAt     *time.Time `json:"last_error_at,omitempty"`
+	LastInvokedAt   *time.Time `json:"last_invoked_at,omitempty"`
+	totalDurationMs float64
+}
+
+type metricsKey struct {
+	plugin string
+	hook   Hook
+}
+
+type metricsStore struct {
+	mu      sync.Mutex
+	entries map[metricsKey]*HookMetrics
+}
+
+func newMetricsStore() *metricsStore {
+	return &metricsStore{entries: make(map[metricsKey]*HookMetrics)}
+}
+
+func (s *metricsStore) entry(plugin string, hook Hook) *HookMetrics {
+	key := metricsKey{plugin: plugin, hook: hook}
+	m, ok := s.entries[key]
+	if !ok {
+		m = &HookMetrics{Plugin: plugin, Hook: hook}
+		s.entries[key] = m
+	}
+	return m
+}
+
+func (s *metricsStore) record(plugin string, hook Hook, duration time.Duration, result outcome) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := s.entry(plugin, hook)
+	now := time.Now()
+	durationMs := float64(duration.Microseconds()) / 1000
+
+	m.Invocations++
+	m.totalDurationMs += durationMs
+	m.AvgDurationMs = m.totalDurationMs / float64(m.Invocations)
+	if durationMs > m.MaxDurationMs {
+		m.MaxDurationMs = durationMs
+	}
+	m.LastInvokedAt = &now
+
+	switch {
+	case result.timedOut:
+		m.Timeouts++
+	case result.panicked:
+		m.Panics++
+	case result.err != nil:
+		m.Failures++
+	default:
+		m.Successes++
+		return
+	}
+	m.LastError = result.err.Error()
+	m.LastErrorAt = &now
+}
+
+func (s *metricsStore) recordDropped(plugin string, hook Hook) {
+	s.mu.Lock()
+	s.entry(plugin, hook).Dropped++
+	s.mu.Unlock()
+}
+
+func (s *metricsStore) snapshot() []HookMetrics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]HookMetrics, 0, len(s.entries))
+	for _, m := range s.entries {
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Plugin != out[j].Plugin {
+			return out[i].Plugin < out[j].Plugin
+		}
+		return out[i].Hook < out[j].Hook
+	})
+	return out
+}
@@ -0,0 +1,349 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHookWorkers   = 8
+	defaultHookQueueSize = 1024
+	defaultHookTimeout   = 10 * time.Second
+)
+
+// Plugin is a vertical-specific extension compiled into the binary. Plugins call
+// RegisterPlugin from an init() function and attach their handlers in Register.
+// Event payloads are shared between plugins and must be treated as read-only.
+type Plugin interface {
+	Name() string
+	Register(r *Registrar) error
+}
+
+type handlerFunc func(ctx context.Context, event interface{}) error
+
+type registration struct {
+	plugin  string
+	hook    Hook
+	handler handlerFunc
+}
+
+// Registrar collects a single plugin's handlers. Handlers are only installed if
+// the plugin's Register call succeeds, so a half-registered plugin never runs.
+type Registrar struct {
+	plugin        string
+	registrations []registration
+}
+
+// OnFormSubmitted attaches a handler that runs after a form submission is stored.
+func (r *Registrar) OnFormSubmitted(fn func(ctx context.Context, event FormSubmittedEvent) error) {
+	r.add(HookFormSubmitted, func(ctx context.Context, event interface{}) error {
+		return fn(ctx, event.(FormSubmittedEvent))
+	})
+}
+
+// OnWorkflowTransition attaches a handler that runs after a workflow transition commits.
+func (r *Registrar) OnWorkflowTransition(fn func(ctx context.Context, event WorkflowTransitionEvent) error) {
+	r.add(HookWorkflowTransition, func(ctx context.Context, event interface{}) error {
+		return fn(ctx, event.(WorkflowTransitionEvent))
+	})
+}
+
+// OnTelemetryReading attaches a handler that runs for each accepted telemetry reading.
+func (r *Registrar) OnTelemetryReading(fn func(ctx context.Context, event TelemetryReadingEvent) error) {
+	r.add(HookTelemetryReading, func(ctx context.Context, event interface{}) error {
+		return fn(ctx, event.(TelemetryReadingEvent))
+	})
+}
+
+// OnChatMessage attaches a handler that runs after a chat message is sent, acknowledged or deleted.
+func (r *Registrar) OnChatMessage(fn func(ctx context.Context, event ChatMessageEvent) error) {
+	r.add(HookChatMessage, func(ctx context.Context, event interface{}) error {
+		return fn(ctx, event.(ChatMessageEvent))
+	})
+}
+
+// OnInvoiceMatched attaches a handler that runs after a vendor invoice is three-way matched.
+func (r *Registrar) OnInvoiceMatched(fn func(ctx context.Context, event InvoiceMatchedEvent) error) {
+	r.add(HookInvoiceMatched, func(ctx context.Context, event interface{}) error {
+		return fn(ctx, event.(InvoiceMatchedEvent))
+	})
+}
+
+func (r *Registrar) add(hook Hook, fn handlerFunc) {
+	r.registrations = append(r.registrations, registration{plugin: r.plugin, hook: hook, handler: fn})
+}
+
+type dispatchJob struct {
+	registration registration
+	event        interface{}
+}
+
+// Registry dispatches hook events to plugin handlers on a bounded worker pool.
+// Handlers never run on the caller's goroutine: panics are recovered, slow handlers
+// are abandoned after the timeout, and a full queue drops events instead of blocking.
+type Registry struct {
+	mu            sync.RWMutex
+	registrations map[Hook][]registration
+	plugins       map[string]*PluginStatus
+
+	timeout time.Duration
+	queue   chan dispatchJob
+	metrics *metricsStore
+}
+
+// NewRegistry creates a registry and starts its dispatch workers.
+func NewRegistry(workers, queueSize int, timeout time.Duration) *Registry {
+	if workers <= 0 {
+		workers = defaultHookWorkers
+	}
+	if queueSize <= 0 {
+		queueSize = defaultHookQueueSize
+	}
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+
+	r := &Registry{
+		registrations: make(map[Hook][]registration),
+		plugins:       make(map[string]*PluginStatus),
+		timeout:       timeout,
+		queue:         make(chan dispatchJob, queueSize),
+		metrics:       newMetricsStore(),
+	}
+	for i := 0; i < workers; i++ {
+		go r.worker()
+	}
+	return r
+}
+
+// PluginStatus reports whether a plugin loaded and which hooks it attached to.
+type PluginStatus struct {
+	Name     string    `json:"name"`
+	Loaded   bool      `json:"loaded"`
+	Error    string    `json:"error,omitempty"`
+	Hooks    []Hook    `json:"hooks"`
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
+// Load runs a plugin's Register function and installs its handlers. A plugin that
+// returns an error or panics is recorded as failed and contributes no handlers.
+func (r *Registry) Load(p Plugin) (err error) {
+	name := strings.TrimSpace(p.Name())
+	if name == "" {
+		return fmt.Errorf("plugin name is required")
+	}
+
+	r.mu.RLock()
+	existing, duplicate := r.plugins[name]
+	r.mu.RUnlock()
+	if duplicate && existing.Loaded {
+		return fmt.Errorf("plugin %s is already loaded", name)
+	}
+
+	registrar := &Registrar{plugin: name}
+	func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				err = fmt.Errorf("plugin %s panicked during registration: %v", name, rec)
+			}
+		}()
+		err = p.Register(registrar)
+	}()
+
+	status := &PluginStatus{Name: name, Hooks: make([]Hook, 0), LoadedAt: time.Now()}
+	if err != nil {
+		status.Error = err.Error()
+		r.mu.Lock()
+		r.plugins[name] = status
+		r.mu.Unlock()
+		return err
+	}
+
+	seen := make(map[Hook]bool)
+	r.mu.Lock()
+	for _, reg := range registrar.registrations {
+		r.registrations[reg.hook] = append(r.registrations[reg.hook], reg)
+		if !seen[reg.hook] {
+			seen[reg.hook] = true
+			status.Hooks = append(status.Hooks, reg.hook)
+		}
+	}
+	status.Loaded = true
+	r.plugins[name] = status
+	r.mu.Unlock()
+
+	return nil
+}
+
+// Fire queues event for every handler registered on hook and returns immediately.
+func (r *Registry) Fire(hook Hook, event interface{}) {
+	r.mu.RLock()
+	regs := r.registrations[hook]
+	r.mu.RUnlock()
+
+	for _, reg := range regs {
+		select {
+		case r.queue <- dispatchJob{registration: reg, event: event}:
+		default:
+			r.metrics.recordDropped(reg.plugin, reg.hook)
+			log.Printf("⚠️  Hook queue full, dropped %s event for plugin %s", reg.hook, reg.plugin)
+		}
+	}
+}
+
+func (r *Registry) worker() {
+	for job := range r.queue {
+		r.run(job)
+	}
+}
+
+func (r *Registry) run(job dispatchJob) {
+	reg := job.registration
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				log.Printf("❌ Plugin %s panicked in %s hook: %v\n%s", reg.plugin, reg.hook, rec, debug.Stack())
+				done <- outcome{err: fmt.Errorf("panic: %v", rec), panicked: true}
+			}
+		}()
+		done <- outcome{err: reg.handler(ctx, job.event)}
+	}()
+
+	select {
+	case result := <-done:
+		if result.err != nil && !result.panicked {
+			log.Printf("⚠️  Plugin %s failed in %s hook: %v", reg.plugin, reg.hook, result.err)
+		}
+		r.metrics.record(reg.plugin, reg.hook, time.Since(start), result)
+	case <-ctx.Done():
+		log.Printf("⚠️  Plugin %s timed out in %s hook after %v", reg.plugin, reg.hook, r.timeout)
+		r.metrics.record(reg.plugin, reg.hook, time.Since(start), outcome{err: ctx.Err(), timedOut: true})
+	}
+}
+
+// Plugins returns the load status of every plugin, sorted by name.
+func (r *Registry) Plugins() []PluginStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	statuses := make([]PluginStatus, 0, len(r.plugins))
+	for _, status := range r.plugins {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// Metrics returns per-plugin, per-hook execution counters.
+func (r *Registry) Metrics() []HookMetrics {
+	return r.metrics.snapshot()
+}
+
+var (
+	pendingMu      sync.Mutex
+	pendingPlugins []Plugin
+
+	defaultRegistry     *Registry
+	defaultRegistryOnce sync.Once
+)
+
+// RegisterPlugin queues a plugin for loading at startup. Call it from init().
+func RegisterPlugin(p Plugin) {
+	pendingMu.Lock()
+	pendingPlugins = append(pendingPlugins, p)
+	pendingMu.Unlock()
+}
+
+// Default returns the process-wide registry, configured from HOOK_WORKERS,
+// HOOK_QUEUE_SIZE and HOOK_HANDLER_TIMEOUT.
+func Default() *Registry {
+	defaultRegistryOnce.Do(func() {
+		defaultRegistry = NewRegistry(
+			envInt("HOOK_WORKERS", defaultHookWorkers),
+			envInt("HOOK_QUEUE_SIZE", defaultHookQueueSize),
+			envDuration("HOOK_HANDLER_TIMEOUT", defaultHookTimeout),
+		)
+	})
+	return defaultRegistry
+}
+
+// LoadPlugins loads every plugin queued with RegisterPlugin into the default registry.
+// Failures are logged and isolated to the failing plugin.
+func LoadPlugins() []PluginStatus {
+	pendingMu.Lock()
+	plugins := pendingPlugins
+	pendingPlugins = nil
+	pendingMu.Unlock()
+
+	registry := Default()
+	for _, p := range plugins {
+		if err := registry.Load(p); err != nil {
+			log.Printf("❌ Failed to load plugin %s: %v", p.Name(), err)
+			continue
+		}
+		log.Printf("✅ Loaded plugin %s", p.Name())
+	}
+	return registry.Plugins()
+}
+
+// FireFormSubmitted dispatches a form submission event on the default registry.
+func FireFormSubmitted(event FormSubmittedEvent) {
+	Default().Fire(HookFormSubmitted, event)
+}
+
+// FireWorkflowTransition dispatches a workflow transition event on the default registry.
+func FireWorkflowTransition(event WorkflowTransitionEvent) {
+	Default().Fire(HookWorkflowTransition, event)
+}
+
+// FireTelemetryReading dispatches a telemetry reading event on the default registry.
+func FireTelemetryReading(event TelemetryReadingEvent) {
+	Default().Fire(HookTelemetryReading, event)
+}
+
+// FireChatMessage dispatches a chat message event on the default registry.
+func FireChatMessage(event ChatMessageEvent) {
+	Default().Fire(HookChatMessage, event)
+}
+
+// FireInvoiceMatched dispatches a vendor invoice match event on the default registry.
+func FireInvoiceMatched(event InvoiceMatchedEvent) {
+	Default().Fire(HookInvoiceMatched, event)
+}
+
+func envInt(key string, fallback int) int {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return fallback
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return fallback
+	}
+	value, err := time.ParseDuration(raw)
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
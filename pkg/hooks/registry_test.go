@@ -0,0 +1,110 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type testPlugin struct {
+	name     string
+	register func(r *Registrar) error
+}
+
+func (p testPlugin) Name() string                { return p.name }
+func (p testPlugin) Register(r *Registrar) error { return p.register(r) }
+
+func waitForInvocations(t *testing.T, r *Registry, plugin string, want uint64) HookMetrics {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, m := range r.Metrics() {
+			if m.Plugin == plugin && m.Invocations >= want {
+				return m
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("plugin %s did not reach %d invocations", plugin, want)
+	return HookMetrics{}
+}
+
+func TestRegistryIsolatesFailingHandlers(t *testing.T) {
+	r := NewRegistry(2, 16, 50*time.Millisecond)
+
+	received := make(chan string, 1)
+	plugins := []Plugin{
+		testPlugin{name: "panics", register: func(reg *Registrar) error {
+			reg.OnFormSubmitted(func(ctx context.Context, e FormSubmittedEvent) error { panic("boom") })
+			return nil
+		}},
+		testPlugin{name: "errors", register: func(reg *Registrar) error {
+			reg.OnFormSubmitted(func(ctx context.Context, e FormSubmittedEvent) error { return errors.New("bad data") })
+			return nil
+		}},
+		testPlugin{name: "slow", register: func(reg *Registrar) error {
+			reg.OnFormSubmitted(func(ctx context.Context, e FormSubmittedEvent) error {
+				time.Sleep(200 * time.Millisecond)
+				return nil
+			})
+			return nil
+		}},
+		testPlugin{name: "healthy", register: func(reg *Registrar) error {
+			reg.OnFormSubmitted(func(ctx context.Context, e FormSubmittedEvent) error {
+				received <- e.FormCode
+				return nil
+			})
+			return nil
+		}},
+	}
+	for _, p := range plugins {
+		if err := r.Load(p); err != nil {
+			t.Fatalf("load %s: %v", p.Name(), err)
+		}
+	}
+
+	r.Fire(HookFormSubmitted, FormSubmittedEvent{FormCode: "solar_inspection"})
+
+	select {
+	case code := <-received:
+		if code != "solar_inspection" {
+			t.Fatalf("unexpected form code %q", code)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("healthy plugin did not receive event")
+	}
+
+	if m := waitForInvocations(t, r, "panics", 1); m.Panics != 1 {
+		t.Fatalf("expected 1 panic, got %+v", m)
+	}
+	if m := waitForInvocations(t, r, "errors", 1); m.Failures != 1 || m.LastError != "bad data" {
+		t.Fatalf("expected 1 failure, got %+v", m)
+	}
+	if m := waitForInvocations(t, r, "slow", 1); m.Timeouts != 1 {
+		t.Fatalf("expected 1 timeout, got %+v", m)
+	}
+	if m := waitForInvocations(t, r, "healthy", 1); m.Successes != 1 {
+		t.Fatalf("expected 1 success, got %+v", m)
+	}
+}
+
+func TestRegistrySkipsPluginWhenRegistrationFails(t *testing.T) {
+	r := NewRegistry(1, 4, time.Second)
+
+	err := r.Load(testPlugin{name: "broken", register: func(reg *Registrar) error {
+		reg.OnWorkflowTransition(func(ctx context.Context, e WorkflowTransitionEvent) error { return nil })
+		return errors.New("missing config")
+	}})
+	if err == nil {
+		t.Fatal("expected registration error")
+	}
+
+	statuses := r.Plugins()
+	if len(statuses) != 1 || statuses[0].Loaded || statuses[0].Error != "missing config" {
+		t.Fatalf("unexpected plugin status %+v", statuses)
+	}
+	if regs := r.registrations[HookWorkflowTransition]; len(regs) != 0 {
+		t.Fatalf("expected no handlers from failed plugin, got %d", len(regs))
+	}
+}
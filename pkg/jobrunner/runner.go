@@ -0,0 +1,87 @@
+// Package jobrunner runs the server's background jobs: each calls its job once every
+// interval until stopped, and is switched on or off by an environment variable.
+package jobrunner
+
+import (
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Runner calls a job once every interval in the background until Stop is called. Job
+// types embed it to get Start and Stop.
+type Runner struct {
+	name     string
+	job      func()
+	atStart  bool
+	wake     <-chan struct{}
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// New creates a runner for job; name is how the job is logged, e.g. "Role expiry job"
+func New(name string, job func()) *Runner {
+	return &Runner{name: name, job: job, stopChan: make(chan struct{})}
+}
+
+// RunAtStart makes the runner call the job as soon as it starts as well, rather than
+// first after one interval
+func (r *Runner) RunAtStart() *Runner {
+	r.atStart = true
+	return r
+}
+
+// WakeOn makes the runner also call the job whenever wake receives, e.g. when work is
+// queued between ticks
+func (r *Runner) WakeOn(wake <-chan struct{}) *Runner {
+	r.wake = wake
+	return r
+}
+
+// Start calls the job once every interval until Stop is called.
+func (r *Runner) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		if r.atStart {
+			r.job()
+		}
+		for {
+			select {
+			case <-r.stopChan:
+				log.Printf("%s stopped", r.name)
+				return
+			case <-ticker.C:
+				r.job()
+			case <-r.wake:
+				r.job()
+			}
+		}
+	}()
+
+	log.Printf("%s started with interval: %v", r.name, interval)
+}
+
+// Stop stops the background loop.
+func (r *Runner) Stop() {
+	r.stopOnce.Do(func() { close(r.stopChan) })
+}
+
+// Enabled reports whether the job switched by the environment variable env should run.
+// Jobs that run by default are off only when it is "false"; the others are on only when
+// it is "true". A job left off is logged.
+func Enabled(env string, byDefault bool) bool {
+	value := strings.TrimSpace(os.Getenv(env))
+	enabled := !strings.EqualFold(value, "false")
+	if !byDefault {
+		enabled = strings.EqualFold(value, "true")
+	}
+	if !enabled {
+		slog.Info("background job disabled", "env", env)
+	}
+	return enabled
+}
@@ -0,0 +1,71 @@
+package jobrunner
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRunnerRunsAtStartAndOnWake(t *testing.T) {
+	ran := make(chan struct{}, 4)
+	wake := make(chan struct{})
+	r := New("Test job", func() { ran <- struct{}{} }).RunAtStart().WakeOn(wake)
+	r.Start(time.Hour)
+	defer r.Stop()
+
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("job did not run at start")
+	}
+	wake <- struct{}{}
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("job did not run on wake")
+	}
+}
+
+func TestRunnerStopsAndStopIsIdempotent(t *testing.T) {
+	ran := make(chan struct{}, 16)
+	r := New("Test job", func() { ran <- struct{}{} })
+	r.Start(5 * time.Millisecond)
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("job did not run on the interval")
+	}
+
+	r.Stop()
+	r.Stop()
+	time.Sleep(20 * time.Millisecond)
+	for len(ran) > 0 {
+		<-ran
+	}
+	time.Sleep(30 * time.Millisecond)
+	if len(ran) > 0 {
+		t.Errorf("job ran %d times after Stop", len(ran))
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	cases := []struct {
+		value     string
+		byDefault bool
+		want      bool
+	}{
+		{"", true, true},
+		{"false", true, false},
+		{" FALSE ", true, false},
+		{"true", true, true},
+		{"", false, false},
+		{"false", false, false},
+		{"true", false, true},
+		{"True", false, true},
+	}
+	for _, c := range cases {
+		t.Setenv("JOBRUNNER_TEST_ENABLED", c.value)
+		if got := Enabled("JOBRUNNER_TEST_ENABLED", c.byDefault); got != c.want {
+			t.Errorf("Enabled(%q, %v) = %v, want %v", c.value, c.byDefault, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package maintenance
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/jobrunner"
+)
+
+// Scheduler raises preventive maintenance tasks from asset maintenance schedules as they
+// come due and tells the assignee. A schedule raises one task per due date; it moves on
+// to the next when that task is done.
+type Scheduler struct {
+	db *gorm.DB
+	*jobrunner.Runner
+}
+
+// NewScheduler creates a preventive maintenance job
+func NewScheduler(db *gorm.DB) *Scheduler {
+	s := &Scheduler{db: db}
+	s.Runner = jobrunner.New("Maintenance scheduler", s.run).RunAtStart()
+	return s
+}
+
+func (s *Scheduler) run() {
+	count, err := s.RaiseDueTasks(time.Now())
+	if err != nil {
+		log.Printf("Error raising maintenance tasks: %v", err)
+	}
+	if count > 0 {
+		log.Printf("Maintenance scheduler: raised %d preventive maintenance tasks", count)
+	}
+}
+
+// RaiseDueTasks raises a task for each active schedule of an asset in service whose next
+// due date, less its lead time, has arrived, and returns how many were raised. Schedules
+// that already have a task for their due date are skipped, so concurrent instances and
+// repeated runs raise each task once.
+func (s *Scheduler) RaiseDueTasks(now time.Time) (int, error) {
+	var schedules []models.MaintenanceSchedule
+	if err := s.db.Preload("Asset").
+		Joins("JOIN assets ON assets.id = maintenance_schedules.asset_id").
+		Where("maintenance_schedules.is_active = ? AND assets.deleted_at IS NULL AND assets.status <> ?", true, models.AssetRetired).
+		Where("maintenance_schedules.next_due_on - maintenance_schedules.lead_days * INTERVAL '1 day' <= ?", now).
+		Where("NOT EXISTS (SELECT 1 FROM maintenance_tasks t WHERE t.schedule_id = maintenance_schedules.id AND t.due_on = maintenance_schedules.next_due_on)").
+		Find(&schedules).Error; err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, schedule := range schedules {
+		if !schedule.DueForTask(now) || schedule.Asset == nil {
+			continue
+		}
+		scheduleID := schedule.ID
+		task := models.MaintenanceTask{
+			BusinessVerticalID: schedule.Asset.BusinessVerticalID,
+			AssetID:            schedule.AssetID,
+			ScheduleID:         &scheduleID,
+			Kind:               models.MaintenancePreventive,
+			Title:              schedule.Title,
+			Description:        schedule.Description,
+			Checklist:          schedule.Checklist,
+			DueOn:              schedule.NextDueOn,
+			AssigneeID:         schedule.AssigneeID,
+			Status:             models.MaintenanceOpen,
+			CreatedBy:          "system",
+		}
+		if task.AssigneeID == "" {
+			task.AssigneeID = schedule.Asset.CustodianID
+		}
+		if task.Checklist == nil {
+			task.Checklist = models.StringArray{}
+		}
+		result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&task)
+		if result.Error != nil {
+			log.Printf("Error raising maintenance task for schedule %s: %v", schedule.ID, result.Error)
+			continue
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		count++
+		s.notifyAssignee(&task, schedule.Asset, now)
+	}
+	return count, nil
+}
+
+func (s *Scheduler) notifyAssignee(task *models.MaintenanceTask, asset *models.Asset, now time.Time) {
+	if task.AssigneeID == "" {
+		return
+	}
+	notification := &models.Notification{
+		UserID:             task.AssigneeID,
+		Type:               models.NotificationTypeTaskAssigned,
+		Priority:           models.NotificationPriorityNormal,
+		Title:              "Preventive maintenance due",
+		Body:               fmt.Sprintf("%s on %s (%s) is due on %s.", task.Title, asset.Name, asset.AssetTag, task.DueOn.Format("02 Jan 2006")),
+		ActionURL:          fmt.Sprintf("/assets/%s/maintenance/%s", asset.ID, task.ID),
+		BusinessVerticalID: &asset.BusinessVerticalID,
+		Status:             models.NotificationStatusSent,
+		Channel:            models.NotificationChannelInApp,
+		SentAt:             &now,
+		Metadata: models.JSONMap{
+			"maintenance_task_id": task.ID.String(),
+			"asset_id":            asset.ID.String(),
+		},
+	}
+	if err := s.db.Create(notification).Error; err != nil {
+		log.Printf("Error notifying %s about maintenance task %s: %v", task.AssigneeID, task.ID, err)
+	}
+}
@@ -0,0 +1,196 @@
+package metering
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/models"
+)
+
+type counterKey struct {
+	verticalID uuid.UUID
+	period     string
+	metric     models.UsageMetric
+}
+
+type activeUserKey struct {
+	verticalID uuid.UUID
+	period     string
+	userID     uuid.UUID
+}
+
+// Meter buffers usage counters in memory and periodically flushes them to
+// usage_aggregates so the request hot path never waits on a database write.
+type Meter struct {
+	db *gorm.DB
+
+	mu          sync.Mutex
+	counters    map[counterKey]float64
+	activeUsers map[activeUserKey]time.Time
+
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+// NewMeter creates a new usage meter
+func NewMeter(db *gorm.DB) *Meter {
+	return &Meter{
+		db:          db,
+		counters:    make(map[counterKey]float64),
+		activeUsers: make(map[activeUserKey]time.Time),
+		stopChan:    make(chan struct{}),
+	}
+}
+
+var (
+	defaultMeterMu sync.RWMutex
+	defaultMeter   *Meter
+)
+
+// SetDefault installs the process-wide meter used by the package-level helpers.
+func SetDefault(m *Meter) {
+	defaultMeterMu.Lock()
+	defaultMeter = m
+	defaultMeterMu.Unlock()
+}
+
+// Default returns the process-wide meter, or nil when metering is not configured.
+func Default() *Meter {
+	defaultMeterMu.RLock()
+	defer defaultMeterMu.RUnlock()
+	return defaultMeter
+}
+
+// Record adds quantity to a metric on the default meter. It is a no-op when no meter is installed.
+func Record(verticalID uuid.UUID, metric models.UsageMetric, quantity float64) {
+	if m := Default(); m != nil {
+		m.Record(verticalID, metric, quantity)
+	}
+}
+
+// TouchUser marks a user as active for a vertical on the default meter.
+func TouchUser(verticalID, userID uuid.UUID) {
+	if m := Default(); m != nil {
+		m.TouchUser(verticalID, userID)
+	}
+}
+
+// Record adds quantity to the in-memory counter for the current period.
+func (m *Meter) Record(verticalID uuid.UUID, metric models.UsageMetric, quantity float64) {
+	if quantity == 0 {
+		return
+	}
+	key := counterKey{verticalID: verticalID, period: models.UsagePeriod(time.Now()), metric: metric}
+
+	m.mu.Lock()
+	m.counters[key] += quantity
+	m.mu.Unlock()
+}
+
+// TouchUser records that userID was active for verticalID in the current period.
+func (m *Meter) TouchUser(verticalID, userID uuid.UUID) {
+	if userID == uuid.Nil {
+		return
+	}
+	now := time.Now()
+	key := activeUserKey{verticalID: verticalID, period: models.UsagePeriod(now), userID: userID}
+
+	m.mu.Lock()
+	m.activeUsers[key] = now
+	m.mu.Unlock()
+}
+
+// Flush persists buffered counters. Counters that fail to persist are merged
+// back into the buffer so they are retried on the next flush.
+func (m *Meter) Flush() error {
+	m.mu.Lock()
+	counters := m.counters
+	activeUsers := m.activeUsers
+	m.counters = make(map[counterKey]float64)
+	m.activeUsers = make(map[activeUserKey]time.Time)
+	m.mu.Unlock()
+
+	var firstErr error
+	for key, quantity := range counters {
+		err := m.db.Exec(
+			`INSERT INTO usage_aggregates (id, business_vertical_id, period, metric, quantity, created_at, updated_at)
+			 VALUES (?, ?, ?, ?, ?, NOW(), NOW())
+			 ON CONFLICT (business_vertical_id, period, metric)
+			 DO UPDATE SET quantity = usage_aggregates.quantity + EXCLUDED.quantity, updated_at = NOW()`,
+			uuid.New(), key.verticalID, key.period, key.metric, quantity,
+		).Error
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to flush usage counter %s: %v", key.metric, err)
+			}
+			m.mu.Lock()
+			m.counters[key] += quantity
+			m.mu.Unlock()
+		}
+	}
+
+	for key, seenAt := range activeUsers {
+		err := m.db.Exec(
+			`INSERT INTO usage_active_users (id, business_vertical_id, period, user_id, first_seen_at, last_seen_at)
+			 VALUES (?, ?, ?, ?, ?, ?)
+			 ON CONFLICT (business_vertical_id, period, user_id)
+			 DO UPDATE SET last_seen_at = GREATEST(usage_active_users.last_seen_at, EXCLUDED.last_seen_at)`,
+			uuid.New(), key.verticalID, key.period, key.userID, seenAt, seenAt,
+		).Error
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to flush active user: %v", err)
+			}
+			m.mu.Lock()
+			if existing, ok := m.activeUsers[key]; !ok || existing.Before(seenAt) {
+				m.activeUsers[key] = seenAt
+			}
+			m.mu.Unlock()
+		}
+	}
+
+	return firstErr
+}
+
+// Start flushes counters every flushInterval and refreshes derived metrics for
+// the current period every aggregateInterval until Stop is called.
+func (m *Meter) Start(flushInterval, aggregateInterval time.Duration) {
+	go func() {
+		flushTicker := time.NewTicker(flushInterval)
+		aggregateTicker := time.NewTicker(aggregateInterval)
+		defer flushTicker.Stop()
+		defer aggregateTicker.Stop()
+
+		for {
+			select {
+			case <-m.stopChan:
+				log.Println("Usage meter stopped")
+				return
+			case <-flushTicker.C:
+				if err := m.Flush(); err != nil {
+					log.Printf("Error flushing usage meter: %v", err)
+				}
+			case <-aggregateTicker.C:
+				if err := NewAggregator(m.db).AggregatePeriod(models.UsagePeriod(time.Now())); err != nil {
+					log.Printf("Error aggregating usage: %v", err)
+				}
+			}
+		}
+	}()
+
+	log.Printf("Usage meter started with flush interval: %v, aggregate interval: %v", flushInterval, aggregateInterval)
+}
+
+// Stop stops the background loop and synchronously flushes any buffered counters.
+func (m *Meter) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stopChan)
+		if err := m.Flush(); err != nil {
+			log.Printf("Error flushing usage meter on stop: %v", err)
+		}
+	})
+}
@@ -0,0 +1,251 @@
+package metering
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/models"
+)
+
+const bytesPerGB = 1 << 30
+
+// Aggregator recomputes metrics that are derived from other tables rather than
+// counted on the request path (storage, outbound messages, active users).
+type Aggregator struct {
+	db *gorm.DB
+}
+
+// NewAggregator creates a new usage aggregator
+func NewAggregator(db *gorm.DB) *Aggregator {
+	return &Aggregator{db: db}
+}
+
+type verticalQuantity struct {
+	BusinessVerticalID uuid.UUID
+	Quantity           float64
+}
+
+// ParsePeriod validates a YYYY-MM period and returns its [start, end) range in UTC.
+func ParsePeriod(period string) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation(models.UsagePeriodLayout, strings.TrimSpace(period), time.UTC)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid period %q, expected YYYY-MM", period)
+	}
+	return start, start.AddDate(0, 1, 0), nil
+}
+
+// AggregatePeriod refreshes derived metrics for the given period.
+func (a *Aggregator) AggregatePeriod(period string) error {
+	start, end, err := ParsePeriod(period)
+	if err != nil {
+		return err
+	}
+
+	nilVertical := uuid.Nil.String()
+
+	var storage []verticalQuantity
+	if err := a.db.Table("documents").
+		Select("COALESCE(business_vertical_id, ?::uuid) AS business_vertical_id, COALESCE(SUM(file_size), 0) AS quantity", nilVertical).
+		Where("deleted_at IS NULL AND created_at < ?", end).
+		Group("1").
+		Scan(&storage).Error; err != nil {
+		return fmt.Errorf("failed to aggregate storage: %v", err)
+	}
+
+	countMessages := func(channel models.NotificationChannel) ([]verticalQuantity, error) {
+		var rows []verticalQuantity
+		err := a.db.Table("notifications").
+			Select("COALESCE(business_vertical_id, ?::uuid) AS business_vertical_id, COUNT(*) AS quantity", nilVertical).
+			Where("channel = ? AND status IN ? AND created_at >= ? AND created_at < ?",
+				channel, []models.NotificationStatus{models.NotificationStatusSent, models.NotificationStatusRead}, start, end).
+			Group("1").
+			Scan(&rows).Error
+		return rows, err
+	}
+
+	sms, err := countMessages(models.NotificationChannelSMS)
+	if err != nil {
+		return fmt.Errorf("failed to aggregate sms sends: %v", err)
+	}
+	whatsapp, err := countMessages(models.NotificationChannelWhatsApp)
+	if err != nil {
+		return fmt.Errorf("failed to aggregate whatsapp sends: %v", err)
+	}
+
+	var activeUsers []verticalQuantity
+	if err := a.db.Model(&models.UsageActiveUser{}).
+		Select("business_vertical_id, COUNT(DISTINCT user_id) AS quantity").
+		Where("period = ?", period).
+		Group("business_vertical_id").
+		Scan(&activeUsers).Error; err != nil {
+		return fmt.Errorf("failed to aggregate active users: %v", err)
+	}
+
+	return a.db.Transaction(func(tx *gorm.DB) error {
+		for metric, rows := range map[models.UsageMetric][]verticalQuantity{
+			models.UsageMetricStorageBytes: storage,
+			models.UsageMetricSMSSent:      sms,
+			models.UsageMetricWhatsAppSent: whatsapp,
+			models.UsageMetricActiveUsers:  activeUsers,
+		} {
+			for _, row := range rows {
+				if err := tx.Exec(
+					`INSERT INTO usage_aggregates (id, business_vertical_id, period, metric, quantity, created_at, updated_at)
+					 VALUES (?, ?, ?, ?, ?, NOW(), NOW())
+					 ON CONFLICT (business_vertical_id, period, metric)
+					 DO UPDATE SET quantity = EXCLUDED.quantity, updated_at = NOW()`,
+					uuid.New(), row.BusinessVerticalID, period, metric, row.Quantity,
+				).Error; err != nil {
+					return fmt.Errorf("failed to store %s aggregate: %v", metric, err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Rates holds the chargeback price per billable unit of each metric.
+// Storage is billed per GB; all other metrics are billed per unit.
+type Rates map[models.UsageMetric]float64
+
+// RatesFromEnv reads BILLING_RATE_<METRIC> variables (e.g. BILLING_RATE_API_CALLS).
+// Missing or invalid values default to zero so usage is still reported.
+func RatesFromEnv() Rates {
+	rates := make(Rates, len(models.AllUsageMetrics))
+	for _, metric := range models.AllUsageMetrics {
+		raw := strings.TrimSpace(os.Getenv("BILLING_RATE_" + strings.ToUpper(string(metric))))
+		if raw == "" {
+			rates[metric] = 0
+			continue
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil || value < 0 {
+			value = 0
+		}
+		rates[metric] = value
+	}
+	return rates
+}
+
+// BillingLine is one metric row for one vertical on the chargeback report.
+type BillingLine struct {
+	BusinessVerticalID   uuid.UUID          `json:"business_vertical_id"`
+	BusinessVerticalCode string             `json:"business_vertical_code"`
+	BusinessVerticalName string             `json:"business_vertical_name"`
+	Metric               models.UsageMetric `json:"metric"`
+	Quantity             float64            `json:"quantity"`
+	BillableQuantity     float64            `json:"billable_quantity"`
+	BillableUnit         string             `json:"billable_unit"`
+	UnitRate             float64            `json:"unit_rate"`
+	Amount               float64            `json:"amount"`
+}
+
+// VerticalBill groups billing lines for a single business vertical.
+type VerticalBill struct {
+	BusinessVerticalID   uuid.UUID     `json:"business_vertical_id"`
+	BusinessVerticalCode string        `json:"business_vertical_code"`
+	BusinessVerticalName string        `json:"business_vertical_name"`
+	Lines                []BillingLine `json:"lines"`
+	Total                float64       `json:"total"`
+}
+
+// BillingReport is the monthly chargeback report across all verticals.
+type BillingReport struct {
+	Period      string         `json:"period"`
+	GeneratedAt time.Time      `json:"generated_at"`
+	Verticals   []VerticalBill `json:"verticals"`
+	GrandTotal  float64        `json:"grand_total"`
+}
+
+// BuildBillingReport prices the stored aggregates for a period. When verticalID is
+// non-nil, only that vertical is included; a non-nil companyID limits it to the company.
+func (a *Aggregator) BuildBillingReport(period string, verticalID *uuid.UUID, companyID uuid.UUID, rates Rates) (*BillingReport, error) {
+	if _, _, err := ParsePeriod(period); err != nil {
+		return nil, err
+	}
+
+	query := a.db.Where("period = ?", period).Scopes(models.ForCompanyVerticals(companyID, "business_vertical_id"))
+	if verticalID != nil {
+		query = query.Where("business_vertical_id = ?", *verticalID)
+	}
+
+	var aggregates []models.UsageAggregate
+	if err := query.Find(&aggregates).Error; err != nil {
+		return nil, fmt.Errorf("failed to load usage aggregates: %v", err)
+	}
+
+	var verticals []models.BusinessVertical
+	if err := a.db.Find(&verticals).Error; err != nil {
+		return nil, fmt.Errorf("failed to load business verticals: %v", err)
+	}
+	verticalByID := make(map[uuid.UUID]models.BusinessVertical, len(verticals))
+	for _, v := range verticals {
+		verticalByID[v.ID] = v
+	}
+
+	bills := make(map[uuid.UUID]*VerticalBill)
+	for _, agg := range aggregates {
+		bill, ok := bills[agg.BusinessVerticalID]
+		if !ok {
+			bill = &VerticalBill{BusinessVerticalID: agg.BusinessVerticalID, BusinessVerticalCode: "UNASSIGNED", BusinessVerticalName: "Unassigned"}
+			if v, found := verticalByID[agg.BusinessVerticalID]; found {
+				bill.BusinessVerticalCode = v.Code
+				bill.BusinessVerticalName = v.Name
+			}
+			bills[agg.BusinessVerticalID] = bill
+		}
+
+		billable, unit := billableQuantity(agg.Metric, agg.Quantity)
+		line := BillingLine{
+			BusinessVerticalID:   bill.BusinessVerticalID,
+			BusinessVerticalCode: bill.BusinessVerticalCode,
+			BusinessVerticalName: bill.BusinessVerticalName,
+			Metric:               agg.Metric,
+			Quantity:             agg.Quantity,
+			BillableQuantity:     billable,
+			BillableUnit:         unit,
+			UnitRate:             rates[agg.Metric],
+			Amount:               models.Round2(billable * rates[agg.Metric]),
+		}
+		bill.Lines = append(bill.Lines, line)
+		bill.Total = models.Round2(bill.Total + line.Amount)
+	}
+
+	metricOrder := make(map[models.UsageMetric]int, len(models.AllUsageMetrics))
+	for i, metric := range models.AllUsageMetrics {
+		metricOrder[metric] = i
+	}
+
+	report := &BillingReport{Period: period, GeneratedAt: time.Now().UTC(), Verticals: make([]VerticalBill, 0, len(bills))}
+	for _, bill := range bills {
+		sort.Slice(bill.Lines, func(i, j int) bool {
+			return metricOrder[bill.Lines[i].Metric] < metricOrder[bill.Lines[j].Metric]
+		})
+		report.Verticals = append(report.Verticals, *bill)
+		report.GrandTotal = models.Round2(report.GrandTotal + bill.Total)
+	}
+	sort.Slice(report.Verticals, func(i, j int) bool {
+		return report.Verticals[i].BusinessVerticalCode < report.Verticals[j].BusinessVerticalCode
+	})
+
+	return report, nil
+}
+
+func billableQuantity(metric models.UsageMetric, quantity float64) (float64, string) {
+	switch metric {
+	case models.UsageMetricStorageBytes:
+		return quantity / bytesPerGB, "GB"
+	case models.UsageMetricActiveUsers:
+		return quantity, "user"
+	case models.UsageMetricAPICalls:
+		return quantity, "call"
+	default:
+		return quantity, "message"
+	}
+}
@@ -0,0 +1,245 @@
+// Package nrw estimates non-revenue water: each water zone's daily loss between the bulk
+// supply metered into it and the consumption metered downstream, alerting the vertical's
+// water admins when a zone loses more than its threshold.
+package nrw
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/jobrunner"
+)
+
+// zoneTimezone is the timezone zone days are reckoned in
+const zoneTimezone = "Asia/Kolkata"
+
+// volumeMetric is the cumulative volume register of a flow meter
+const volumeMetric = "total_volume"
+
+// alertRole is the role told about zones losing more than their threshold
+const alertRole = "Water_Admin"
+
+// alertRecipientsSQL lists the users holding alertRole in a vertical, for the whole
+// vertical or the zone's site
+const alertRecipientsSQL = `SELECT DISTINCT ubr.user_id
+	FROM user_business_roles ubr
+	JOIN business_roles br ON br.id = ubr.business_role_id
+	WHERE br.business_vertical_id = ? AND br.is_active AND ubr.is_active
+	AND (ubr.valid_from IS NULL OR ubr.valid_from <= NOW())
+	AND (ubr.valid_until IS NULL OR ubr.valid_until > NOW())
+	AND (ubr.site_id IS NULL OR ubr.site_id = ?)
+	AND br.name = ?`
+
+// Location returns the timezone zone days are reckoned in
+func Location() *time.Location {
+	loc, err := time.LoadLocation(zoneTimezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Estimator balances every active zone's supply against its consumption for the days
+// just ended and alerts each zone-day that exceeds its threshold once. Days are
+// recomputed while readings may still arrive late.
+type Estimator struct {
+	db *gorm.DB
+	*jobrunner.Runner
+}
+
+// NewEstimator creates a non-revenue water job
+func NewEstimator(db *gorm.DB) *Estimator {
+	e := &Estimator{db: db}
+	e.Runner = jobrunner.New("Non-revenue water estimator", e.run).RunAtStart()
+	return e
+}
+
+func (e *Estimator) run() {
+	local := time.Now().In(Location())
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+	// yesterday, and the day before for readings delivered late
+	alerts, err := e.EstimateDays(nil, today.AddDate(0, 0, -2), today.AddDate(0, 0, -1))
+	if err != nil {
+		log.Printf("Error estimating non-revenue water: %v", err)
+	}
+	if alerts > 0 {
+		log.Printf("Non-revenue water estimator: %d zones over their loss threshold", alerts)
+	}
+}
+
+// EstimateDays balances the active zones, all or those in zoneIDs, for each date from
+// from to to (midnight UTC, as date columns hold them), replacing earlier balances, and
+// returns how many zone-days were newly alerted
+func (e *Estimator) EstimateDays(zoneIDs []uuid.UUID, from, to time.Time) (int, error) {
+	query := e.db.Preload("Site").Where("is_active = ?", true)
+	if zoneIDs != nil {
+		query = query.Where("id IN ?", zoneIDs)
+	}
+	var zones []models.WaterZone
+	if err := query.Find(&zones).Error; err != nil {
+		return 0, err
+	}
+
+	alerts := 0
+	for i := range zones {
+		zone := &zones[i]
+		for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+			loss, err := e.Estimate(zone, date)
+			if err != nil {
+				return alerts, fmt.Errorf("zone %s on %s: %w", zone.Code, date.Format("2006-01-02"), err)
+			}
+			if loss.Exceeded && e.markAlerted(loss) {
+				alerts++
+				e.notifyAdmins(zone, loss)
+			}
+		}
+	}
+	return alerts, nil
+}
+
+// Estimate balances the zone's supply against its consumption on the date and saves the
+// balance. An alert already raised for the day stands.
+func (e *Estimator) Estimate(zone *models.WaterZone, date time.Time) (*models.WaterZoneLoss, error) {
+	loc := Location()
+	from := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+	to := from.AddDate(0, 0, 1)
+
+	missing := 0
+	meterTotal := func(ids []string) (float64, error) {
+		var total float64
+		for _, id := range ids {
+			deviceID, err := uuid.Parse(id)
+			if err != nil {
+				missing++
+				continue
+			}
+			volume, ok, err := e.meterVolume(deviceID, from, to)
+			if err != nil {
+				return 0, err
+			}
+			if !ok {
+				missing++
+			}
+			total += volume
+		}
+		return total, nil
+	}
+	supply, err := meterTotal(zone.SupplyMeterIDs)
+	if err != nil {
+		return nil, err
+	}
+	consumption, err := meterTotal(zone.ConsumptionMeterIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	loss := models.NewWaterZoneLoss(*zone, date, supply, consumption, missing)
+	loss.ComputedAt = time.Now()
+	if err := e.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "zone_id"}, {Name: "loss_date"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"business_vertical_id", "site_id", "supply_m3", "consumption_m3", "loss_m3", "loss_pct",
+			"threshold_pct", "exceeded", "meters_without_data", "computed_at",
+		}),
+	}).Create(&loss).Error; err != nil {
+		return nil, err
+	}
+	if err := e.db.Where("zone_id = ? AND loss_date = ?", zone.ID, date).First(&loss).Error; err != nil {
+		return nil, err
+	}
+	return &loss, nil
+}
+
+// meterVolume returns how far a flow meter's volume register advanced over [from, to),
+// counting from its last reading before from, and whether it reported in the period
+func (e *Estimator) meterVolume(deviceID uuid.UUID, from, to time.Time) (float64, bool, error) {
+	var readings []models.SensorReading
+	var previous models.SensorReading
+	err := e.db.Where("device_id = ? AND metric = ? AND recorded_at < ? AND recorded_at >= ?", deviceID, volumeMetric, from, from.AddDate(0, 0, -1)).
+		Order("recorded_at DESC").First(&previous).Error
+	switch {
+	case err == nil:
+		readings = append(readings, previous)
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return 0, false, err
+	}
+	var day []models.SensorReading
+	if err := e.db.Where("device_id = ? AND metric = ? AND recorded_at >= ? AND recorded_at < ?", deviceID, volumeMetric, from, to).
+		Order("recorded_at").Find(&day).Error; err != nil {
+		return 0, false, err
+	}
+	if len(day) == 0 {
+		return 0, false, nil
+	}
+	readings = append(readings, day...)
+
+	var volume float64
+	for i := 1; i < len(readings); i++ {
+		// a register going backwards was reset or replaced
+		if d := readings[i].Value - readings[i-1].Value; d > 0 {
+			volume += d
+		}
+	}
+	return math.Round(volume*1000) / 1000, true, nil
+}
+
+// markAlerted marks the balance alerted, returning false when it already was
+func (e *Estimator) markAlerted(loss *models.WaterZoneLoss) bool {
+	now := time.Now()
+	result := e.db.Model(&models.WaterZoneLoss{}).
+		Where("id = ? AND alerted_at IS NULL", loss.ID).
+		UpdateColumn("alerted_at", now)
+	if result.Error != nil {
+		log.Printf("Error marking zone loss %s alerted: %v", loss.ID, result.Error)
+		return false
+	}
+	if result.RowsAffected == 0 {
+		return false
+	}
+	loss.AlertedAt = &now
+	return true
+}
+
+func (e *Estimator) notifyAdmins(zone *models.WaterZone, loss *models.WaterZoneLoss) {
+	var userIDs []string
+	if err := e.db.Raw(alertRecipientsSQL, zone.BusinessVerticalID, zone.SiteID, alertRole).Scan(&userIDs).Error; err != nil {
+		log.Printf("Error loading water admins of zone %s: %v", zone.ID, err)
+		return
+	}
+
+	where := zone.Name
+	if zone.Site != nil {
+		where += ", " + zone.Site.Name
+	}
+	now := time.Now()
+	for _, userID := range userIDs {
+		notification := &models.Notification{
+			UserID:   userID,
+			Type:     models.NotificationTypeSystemAlert,
+			Priority: models.NotificationPriorityHigh,
+			Title:    "Water loss above threshold",
+			Body: fmt.Sprintf("%s lost %.1f m3 of %.1f m3 supplied on %s (%.2f%%, threshold %.2f%%). Check for leaks.",
+				where, loss.LossM3, loss.SupplyM3, loss.LossDate.Format("02 Jan 2006"), *loss.LossPct, loss.ThresholdPct),
+			ActionURL:          fmt.Sprintf("/water/nrw/losses?zone_id=%s", zone.ID),
+			BusinessVerticalID: &zone.BusinessVerticalID,
+			Status:             models.NotificationStatusSent,
+			Channel:            models.NotificationChannelInApp,
+			SentAt:             &now,
+			Metadata: models.JSONMap{
+				"water_zone_id":      zone.ID.String(),
+				"water_zone_loss_id": loss.ID.String(),
+				"loss_date":          loss.LossDate.Format("2006-01-02"),
+			},
+		}
+		if err := e.db.Create(notification).Error; err != nil {
+			log.Printf("Error notifying %s of zone loss %s: %v", userID, loss.ID, err)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+// Package pdfdoc writes simple PDF documents: pages of text in the standard Helvetica
+// fonts and ruled lines, which is enough for printable forms such as purchase orders.
+// Positions are in points from the top-left corner of the page.
+package pdfdoc
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A4 page size in points
+const (
+	A4Width  = 595.28
+	A4Height = 841.89
+)
+
+// Document is a PDF being built page by page
+type Document struct {
+	width, height float64
+	title         string
+	pages         []*bytes.Buffer
+}
+
+// New starts an A4 portrait document with no pages
+func New() *Document {
+	return &Document{width: A4Width, height: A4Height}
+}
+
+// SetTitle sets the title shown by PDF viewers
+func (d *Document) SetTitle(title string) {
+	d.title = title
+}
+
+// AddPage starts a new page; later drawing goes on it
+func (d *Document) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+// PageCount returns the number of pages added
+func (d *Document) PageCount() int {
+	return len(d.pages)
+}
+
+func (d *Document) page() *bytes.Buffer {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+	return d.pages[len(d.pages)-1]
+}
+
+// Text draws s with its baseline at y, starting at x
+func (d *Document) Text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.page(), "BT /%s %s Tf %s %s Td (%s) Tj ET\n",
+		font, num(size), num(x), num(d.height-y), escape(s))
+}
+
+// TextRight draws s so that it ends at right, as for columns of amounts
+func (d *Document) TextRight(right, y, size float64, bold bool, s string) {
+	d.Text(right-TextWidth(s, size), y, size, bold, s)
+}
+
+// Line draws a thin ruled line between two points
+func (d *Document) Line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(d.page(), "0.5 w %s %s m %s %s l S\n",
+		num(x1), num(d.height-y1), num(x2), num(d.height-y2))
+}
+
+// Rect draws the outline of a rectangle whose top-left corner is at x, y
+func (d *Document) Rect(x, y, width, height float64) {
+	fmt.Fprintf(d.page(), "0.5 w %s %s %s %s re S\n",
+		num(x), num(d.height-y-height), num(width), num(height))
+}
+
+// Bytes renders the document. A document with no pages gets one blank page.
+func (d *Document) Bytes() []byte {
+	d.page()
+
+	var out bytes.Buffer
+	var offsets []int
+	begin := func() int {
+		offsets = append(offsets, out.Len())
+		n := len(offsets)
+		fmt.Fprintf(&out, "%d 0 obj\n", n)
+		return n
+	}
+	end := func() { out.WriteString("endobj\n") }
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1-4 are the catalog, page tree, fonts; each page then takes two, its page
+	// object and its content stream, so page i is object 5+2i
+	begin()
+	out.WriteString("<< /Type /Catalog /Pages 2 0 R >>\n")
+	end()
+
+	begin()
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	fmt.Fprintf(&out, "<< /Type /Pages /Kids [%s] /Count %d >>\n", strings.Join(kids, " "), len(d.pages))
+	end()
+
+	for _, font := range []string{"Helvetica", "Helvetica-Bold"} {
+		begin()
+		fmt.Fprintf(&out, "<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>\n", font)
+		end()
+	}
+
+	for _, content := range d.pages {
+		n := begin()
+		fmt.Fprintf(&out, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>\n",
+			num(d.width), num(d.height), n+1)
+		end()
+
+		begin()
+		fmt.Fprintf(&out, "<< /Length %d >>\nstream\n", content.Len())
+		out.Write(content.Bytes())
+		out.WriteString("endstream\n")
+		end()
+	}
+
+	info := 0
+	if d.title != "" {
+		info = begin()
+		fmt.Fprintf(&out, "<< /Title (%s) /Producer (UGCL) >>\n", escape(d.title))
+		end()
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R", len(offsets)+1)
+	if info > 0 {
+		fmt.Fprintf(&out, " /Info %d 0 R", info)
+	}
+	fmt.Fprintf(&out, " >>\nstartxref\n%d\n%%%%EOF\n", xref)
+	return out.Bytes()
+}
+
+// num formats a coordinate without trailing zeros
+func num(v float64) string {
+	s := strings.TrimRight(fmt.Sprintf("%.2f", v), "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// escape encodes s as the body of a PDF string in WinAnsi. Characters outside Latin-1
+// have no glyph in the standard fonts and print as '?'.
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\t':
+			b.WriteByte(' ')
+		case r >= 32 && r < 127:
+			b.WriteRune(r)
+		case r >= 160 && r <= 255:
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// helveticaWidths are the Helvetica advance widths of the printable ASCII characters,
+// in thousandths of the font size
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // space to /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, // 0-9
+	278, 278, 584, 584, 584, 556, 1015, // : to @
+	667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, // A-M
+	722, 778, 667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, // N-Z
+	278, 278, 278, 469, 556, 333, // [ to `
+	556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, // a-m
+	556, 556, 556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, // n-z
+	334, 260, 334, 584, // { to ~
+}
+
+// TextWidth returns the width of s in Helvetica at the size. Bold text runs slightly
+// wider except for digits, which have the same width in both.
+func TextWidth(s string, size float64) float64 {
+	var units int
+	for _, r := range s {
+		if r >= 32 && r < 127 {
+			units += helveticaWidths[r-32]
+		} else {
+			units += 556
+		}
+	}
+	return float64(units) * size / 1000
+}
+
+// Wrap breaks s into lines no wider than width at the size, breaking between words
+// where it can
+func Wrap(s string, size, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(s, "\n") {
+		words := strings.Fields(paragraph)
+		if len(words) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+		line := ""
+		for _, word := range words {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if line != "" && TextWidth(candidate, size) > width {
+				lines = append(lines, line)
+				candidate = word
+			}
+			for TextWidth(candidate, size) > width && len([]rune(candidate)) > 1 {
+				runes := []rune(candidate)
+				cut := len(runes) - 1
+				for cut > 1 && TextWidth(string(runes[:cut]), size) > width {
+					cut--
+				}
+				lines = append(lines, string(runes[:cut]))
+				candidate = string(runes[cut:])
+			}
+			line = candidate
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
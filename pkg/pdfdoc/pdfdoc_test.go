@@ -0,0 +1,62 @@
+package pdfdoc
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestBytesCrossReferencesObjects(t *testing.T) {
+	doc := New()
+	doc.SetTitle("PO-001 (draft)")
+	doc.Text(40, 60, 14, true, "Purchase Order")
+	doc.Line(40, 70, 555, 70)
+	doc.AddPage()
+	doc.TextRight(555, 60, 9, false, "1,250.00")
+	out := doc.Bytes()
+
+	if !bytes.HasPrefix(out, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(out, []byte("%%EOF\n")) {
+		t.Fatalf("missing header or trailer: %q", out)
+	}
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(out)
+	if m == nil {
+		t.Fatal("missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	if !bytes.HasPrefix(out[xref:], []byte("xref\n0 10\n")) {
+		t.Fatalf("startxref %d does not point at an xref table of 10 entries: %q", xref, out[xref:xref+12])
+	}
+
+	// Every in-use entry points at its object
+	entries := strings.Split(string(out[xref:]), "\n")[3:12]
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(entry[:10])
+		want := fmt.Sprintf("%d 0 obj\n", i+1)
+		if !bytes.HasPrefix(out[offset:], []byte(want)) {
+			t.Errorf("object %d: offset %d points at %q", i+1, offset, out[offset:offset+10])
+		}
+	}
+	if !bytes.Contains(out, []byte("/Count 2")) || !bytes.Contains(out, []byte(`/Title (PO-001 \(draft\))`)) {
+		t.Error("page count or escaped title missing")
+	}
+}
+
+func TestTextWidthAndWrap(t *testing.T) {
+	if got := TextWidth("100.00", 10); got != 30.58 {
+		t.Errorf("width = %v, want 30.58", got)
+	}
+	lines := Wrap("Supply of DI K9 pipes 300 mm dia\nwith rubber gaskets", 10, 100)
+	want := []string{"Supply of DI K9 pipes", "300 mm dia", "with rubber gaskets"}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+}
+
+func TestEscapeReplacesUnsupportedCharacters(t *testing.T) {
+	if got := escape(`₹ 5\(a)`); got != `? 5\\\(a\)` {
+		t.Errorf("escape = %q", got)
+	}
+}
@@ -0,0 +1,183 @@
+package portfolio
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"p9e.in/ugcl/models"
+)
+
+// billedRABillStatuses are the RA bill statuses whose value counts as billed to the client.
+var billedRABillStatuses = []string{"approved", "paid"}
+
+// ProjectProfitability is one project's cost over a period against its budget and the
+// value billed to the client. Amounts are in the base currency.
+type ProjectProfitability struct {
+	ProjectID            uuid.UUID          `json:"project_id"`
+	Code                 string             `json:"code"`
+	Name                 string             `json:"name"`
+	Status               string             `json:"status"`
+	BusinessVerticalID   uuid.UUID          `json:"business_vertical_id"`
+	BusinessVerticalCode string             `json:"business_vertical_code,omitempty"`
+	BusinessVerticalName string             `json:"business_vertical_name,omitempty"`
+	Budget               float64            `json:"budget"`
+	Cost                 float64            `json:"cost"`
+	CostBySource         map[string]float64 `json:"cost_by_source"` // purchase, payroll, expense, manual, reversal
+	Billed               float64            `json:"billed"`
+	Figures
+}
+
+// Figures are the margin and budget use derived from cost, budget and billed value.
+type Figures struct {
+	Margin            float64  `json:"margin"`
+	MarginPercent     *float64 `json:"margin_percent,omitempty"`      // of billed value, when anything was billed
+	BudgetUsedPercent *float64 `json:"budget_used_percent,omitempty"` // cost over budget, when there is a budget
+}
+
+// VerticalProfitability totals the projects of one business vertical.
+type VerticalProfitability struct {
+	BusinessVerticalID   uuid.UUID `json:"business_vertical_id"`
+	BusinessVerticalCode string    `json:"business_vertical_code,omitempty"`
+	BusinessVerticalName string    `json:"business_vertical_name,omitempty"`
+	ProjectCount         int       `json:"project_count"`
+	Budget               float64   `json:"budget"`
+	Cost                 float64   `json:"cost"`
+	Billed               float64   `json:"billed"`
+	Figures
+}
+
+// Settle derives the margin and budget use from cost, budget and billed value, all
+// rounded to paise.
+func Settle(budget, cost, billed float64) Figures {
+	figures := Figures{Margin: models.Round2(billed - cost)}
+	if billed > 0 {
+		percent := models.Round2((billed - cost) / billed * 100)
+		figures.MarginPercent = &percent
+	}
+	if budget > 0 {
+		percent := models.Round2(cost / budget * 100)
+		figures.BudgetUsedPercent = &percent
+	}
+	return figures
+}
+
+// Profitability returns the cost, budget and billed value of every project matching
+// filter from from to to (inclusive), by code, with the totals by business vertical.
+//
+// Cost is what posted ledger entries dated in the period booked to the project's expense
+// accounts, net of reversals. Billed value is the gross value of the project's RA bills
+// approved in the period, converted at the project's rate. Budget is the project's total
+// budget.
+func (s *Service) Profitability(filter Filter, from, to time.Time) ([]ProjectProfitability, []VerticalProfitability, error) {
+	var projects []struct {
+		ID                   uuid.UUID
+		Code                 string
+		Name                 string
+		Status               string
+		BusinessVerticalID   uuid.UUID
+		BusinessVerticalCode string
+		BusinessVerticalName string
+		BaseTotalBudget      float64
+	}
+	if err := s.filteredProjects(filter).
+		Select(`p.id, p.code, p.name, p.status, p.business_vertical_id, bv.code AS business_vertical_code,
+			bv.name AS business_vertical_name, p.base_total_budget`).
+		Order("bv.code, p.code").Scan(&projects).Error; err != nil {
+		return nil, nil, fmt.Errorf("load projects: %w", err)
+	}
+	if len(projects) == 0 {
+		return []ProjectProfitability{}, []VerticalProfitability{}, nil
+	}
+	projectIDs := make([]uuid.UUID, len(projects))
+	for i, p := range projects {
+		projectIDs[i] = p.ID
+	}
+
+	var costs []struct {
+		ProjectID uuid.UUID
+		Source    string
+		Amount    float64
+	}
+	if err := s.db.Table("journal_lines jl").
+		Select("jl.project_id, je.source, SUM(jl.debit - jl.credit) AS amount").
+		Joins("JOIN journal_entries je ON je.id = jl.entry_id").
+		Joins("JOIN ledger_accounts la ON la.id = jl.account_id").
+		Where("jl.project_id IN ? AND la.type = ? AND je.status IN ? AND je.entry_date BETWEEN ? AND ?",
+			projectIDs, models.AccountExpense, []string{models.JournalPosted, models.JournalReversed}, from, to).
+		Group("jl.project_id, je.source").Scan(&costs).Error; err != nil {
+		return nil, nil, fmt.Errorf("sum project costs: %w", err)
+	}
+	costBySource := make(map[uuid.UUID]map[string]float64, len(projects))
+	for _, c := range costs {
+		if costBySource[c.ProjectID] == nil {
+			costBySource[c.ProjectID] = map[string]float64{}
+		}
+		costBySource[c.ProjectID][c.Source] = models.Round2(c.Amount)
+	}
+
+	var bills []struct {
+		ProjectID uuid.UUID
+		Amount    float64
+	}
+	if err := s.db.Table("ra_bills rb").
+		Select("rb.project_id, SUM(rb.gross_amount * p.exchange_rate) AS amount").
+		Joins("JOIN projects p ON p.id = rb.project_id").
+		Where("rb.project_id IN ? AND rb.status IN ? AND rb.deleted_at IS NULL", projectIDs, billedRABillStatuses).
+		Where("CAST(COALESCE(rb.approved_at, rb.period_end, rb.created_at) AS date) BETWEEN ? AND ?", from, to).
+		Group("rb.project_id").Scan(&bills).Error; err != nil {
+		return nil, nil, fmt.Errorf("sum project billing: %w", err)
+	}
+	billed := make(map[uuid.UUID]float64, len(bills))
+	for _, b := range bills {
+		billed[b.ProjectID] = models.Round2(b.Amount)
+	}
+
+	results := make([]ProjectProfitability, 0, len(projects))
+	verticals := map[uuid.UUID]*VerticalProfitability{}
+	var order []uuid.UUID
+	for _, p := range projects {
+		row := ProjectProfitability{
+			ProjectID:            p.ID,
+			Code:                 p.Code,
+			Name:                 p.Name,
+			Status:               p.Status,
+			BusinessVerticalID:   p.BusinessVerticalID,
+			BusinessVerticalCode: p.BusinessVerticalCode,
+			BusinessVerticalName: p.BusinessVerticalName,
+			Budget:               models.Round2(p.BaseTotalBudget),
+			CostBySource:         map[string]float64{},
+			Billed:               billed[p.ID],
+		}
+		for source, amount := range costBySource[p.ID] {
+			row.CostBySource[source] = amount
+			row.Cost += amount
+		}
+		row.Cost = models.Round2(row.Cost)
+		row.Figures = Settle(row.Budget, row.Cost, row.Billed)
+		results = append(results, row)
+
+		v, ok := verticals[p.BusinessVerticalID]
+		if !ok {
+			v = &VerticalProfitability{
+				BusinessVerticalID:   p.BusinessVerticalID,
+				BusinessVerticalCode: p.BusinessVerticalCode,
+				BusinessVerticalName: p.BusinessVerticalName,
+			}
+			verticals[p.BusinessVerticalID] = v
+			order = append(order, p.BusinessVerticalID)
+		}
+		v.ProjectCount++
+		v.Budget = models.Round2(v.Budget + row.Budget)
+		v.Cost = models.Round2(v.Cost + row.Cost)
+		v.Billed = models.Round2(v.Billed + row.Billed)
+	}
+
+	byVertical := make([]VerticalProfitability, 0, len(order))
+	for _, id := range order {
+		v := verticals[id]
+		v.Figures = Settle(v.Budget, v.Cost, v.Billed)
+		byVertical = append(byVertical, *v)
+	}
+	return results, byVertical, nil
+}
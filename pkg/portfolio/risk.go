@@ -0,0 +1,118 @@
+package portfolio
+
+import (
+	"math"
+	"time"
+
+	"p9e.in/ugcl/models"
+)
+
+// Risk score weights. The components sum to 100 so the score reads as a percentage.
+const (
+	scheduleWeight = 40.0
+	budgetWeight   = 30.0
+	incidentWeight = 20.0
+	overdueWeight  = 10.0
+
+	// Variances at or beyond these thresholds saturate their component.
+	scheduleSaturation = 30.0 // percentage points behind plan
+	budgetSaturation   = 20.0 // percent of budget overrun
+	incidentSaturation = 5.0  // open critical incidents
+	overdueSaturation  = 0.25 // share of open tasks past their planned end
+)
+
+// Indicators are the raw inputs used to score one project.
+type Indicators struct {
+	Progress          float64
+	StartDate         *time.Time
+	EndDate           *time.Time
+	TotalBudget       float64
+	SpentBudget       float64
+	CriticalIncidents int
+	OpenTasks         int
+	OverdueTasks      int
+}
+
+// Assessment is the derived health of one project at a point in time.
+type Assessment struct {
+	PlannedProgress  float64 `json:"planned_progress"`
+	ScheduleVariance float64 `json:"schedule_variance"`
+	BudgetVariance   float64 `json:"budget_variance"`
+	EarnedValue      float64 `json:"earned_value"`
+	RiskScore        float64 `json:"risk_score"`
+	RiskLevel        string  `json:"risk_level"`
+}
+
+// PlannedProgress returns the share of the project timeline elapsed at now, as 0-100.
+// Projects without a complete timeline are treated as on plan.
+func PlannedProgress(start, end *time.Time, now time.Time, actual float64) float64 {
+	if start == nil || end == nil || !end.After(*start) {
+		return actual
+	}
+	if now.Before(*start) {
+		return 0
+	}
+	if !now.Before(*end) {
+		return 100
+	}
+	return models.Round2(now.Sub(*start).Seconds() / end.Sub(*start).Seconds() * 100)
+}
+
+// Assess computes schedule variance, budget variance and a 0-100 risk score.
+//
+// Schedule variance is actual minus planned progress in percentage points. Budget
+// variance compares earned value (budget x progress) against spend as a percentage
+// of the total budget. Both are negative when the project is doing worse than plan.
+func Assess(in Indicators, now time.Time) Assessment {
+	planned := PlannedProgress(in.StartDate, in.EndDate, now, in.Progress)
+	a := Assessment{
+		PlannedProgress:  planned,
+		ScheduleVariance: models.Round2(in.Progress - planned),
+	}
+
+	if in.TotalBudget > 0 {
+		a.EarnedValue = models.Round2(in.TotalBudget * in.Progress / 100)
+		a.BudgetVariance = models.Round2((a.EarnedValue - in.SpentBudget) / in.TotalBudget * 100)
+	} else if in.SpentBudget > 0 {
+		a.BudgetVariance = -100
+	}
+
+	score := scheduleWeight * saturate(-a.ScheduleVariance, scheduleSaturation)
+	score += budgetWeight * saturate(-a.BudgetVariance, budgetSaturation)
+	score += incidentWeight * saturate(float64(in.CriticalIncidents), incidentSaturation)
+	if in.OpenTasks > 0 {
+		score += overdueWeight * saturate(float64(in.OverdueTasks)/float64(in.OpenTasks), overdueSaturation)
+	}
+
+	a.RiskScore = models.Round2(score)
+	a.RiskLevel = RiskLevel(a.RiskScore)
+	return a
+}
+
+// RiskLevel buckets a risk score.
+func RiskLevel(score float64) string {
+	switch {
+	case score >= 75:
+		return models.PortfolioRiskCritical
+	case score >= 50:
+		return models.PortfolioRiskHigh
+	case score >= 25:
+		return models.PortfolioRiskMedium
+	default:
+		return models.PortfolioRiskLow
+	}
+}
+
+// WeekStart returns Monday 00:00 UTC of the week containing t.
+func WeekStart(t time.Time) time.Time {
+	t = t.UTC()
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, time.UTC)
+}
+
+func saturate(value, limit float64) float64 {
+	if value <= 0 {
+		return 0
+	}
+	return math.Min(value/limit, 1)
+}
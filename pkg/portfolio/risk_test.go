@@ -0,0 +1,87 @@
+package portfolio
+
+import (
+	"testing"
+	"time"
+
+	"p9e.in/ugcl/models"
+)
+
+func TestRiskLevelBands(t *testing.T) {
+	cases := []struct {
+		score float64
+		want  string
+	}{
+		{0, models.PortfolioRiskLow},
+		{24.99, models.PortfolioRiskLow},
+		{25, models.PortfolioRiskMedium},
+		{49.99, models.PortfolioRiskMedium},
+		{50, models.PortfolioRiskHigh},
+		{74.99, models.PortfolioRiskHigh},
+		{75, models.PortfolioRiskCritical},
+		{100, models.PortfolioRiskCritical},
+	}
+	for _, c := range cases {
+		if got := RiskLevel(c.score); got != c.want {
+			t.Errorf("RiskLevel(%v) = %s, want %s", c.score, got, c.want)
+		}
+	}
+}
+
+func TestAssessScoresIntoBands(t *testing.T) {
+	now := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	start, end := now.AddDate(0, 0, -50), now.AddDate(0, 0, 50) // half way, planned 50%
+
+	cases := []struct {
+		name      string
+		in        Indicators
+		wantScore float64
+		wantLevel string
+	}{
+		{
+			name:      "on plan",
+			in:        Indicators{Progress: 50, StartDate: &start, EndDate: &end, TotalBudget: 1000, SpentBudget: 500},
+			wantLevel: models.PortfolioRiskLow,
+		},
+		{
+			// 15 points behind: 40 x 15/30
+			name:      "behind schedule",
+			in:        Indicators{Progress: 35, StartDate: &start, EndDate: &end, TotalBudget: 1000, SpentBudget: 350},
+			wantScore: 20,
+			wantLevel: models.PortfolioRiskLow,
+		},
+		{
+			// schedule 20, plus 10% overrun: 30 x 10/20
+			name:      "behind schedule and over budget",
+			in:        Indicators{Progress: 35, StartDate: &start, EndDate: &end, TotalBudget: 1000, SpentBudget: 450},
+			wantScore: 35,
+			wantLevel: models.PortfolioRiskMedium,
+		},
+		{
+			// schedule saturated 40, incidents 20 x 3/5, overdue 10 x 0.1/0.25
+			name:      "incidents and overdue tasks",
+			in:        Indicators{Progress: 20, StartDate: &start, EndDate: &end, TotalBudget: 1000, SpentBudget: 200, CriticalIncidents: 3, OpenTasks: 10, OverdueTasks: 1},
+			wantScore: 56,
+			wantLevel: models.PortfolioRiskHigh,
+		},
+		{
+			name:      "every component saturated",
+			in:        Indicators{StartDate: &start, EndDate: &end, TotalBudget: 1000, SpentBudget: 500, CriticalIncidents: 5, OpenTasks: 10, OverdueTasks: 5},
+			wantScore: 100,
+			wantLevel: models.PortfolioRiskCritical,
+		},
+		{
+			// without a timeline the project is on plan, but spend with no budget is a full overrun
+			name:      "spend without a budget",
+			in:        Indicators{Progress: 10, SpentBudget: 100},
+			wantScore: 30,
+			wantLevel: models.PortfolioRiskMedium,
+		},
+	}
+	for _, c := range cases {
+		got := Assess(c.in, now)
+		if got.RiskScore != c.wantScore || got.RiskLevel != c.wantLevel {
+			t.Errorf("%s: score %v (%s), want %v (%s)", c.name, got.RiskScore, got.RiskLevel, c.wantScore, c.wantLevel)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package portfolio
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/jobrunner"
+)
+
+// closedProjectStatuses are excluded from the portfolio unless explicitly requested.
+var closedProjectStatuses = []string{"completed", "cancelled"}
+
+// Filter narrows the set of projects evaluated for the portfolio.
+type Filter struct {
+	BusinessVerticalID *uuid.UUID
+	CompanyID          uuid.UUID // limits projects to the company's verticals; uuid.Nil for all
+	Status             string
+	IncludeClosed      bool
+}
+
+// ProjectHealth is one ranked row of the portfolio dashboard.
+type ProjectHealth struct {
+	ProjectID            uuid.UUID `json:"project_id"`
+	Code                 string    `json:"code"`
+	Name                 string    `json:"name"`
+	Status               string    `json:"status"`
+	BusinessVerticalID   uuid.UUID `json:"business_vertical_id"`
+	BusinessVerticalCode string    `json:"business_vertical_code,omitempty"`
+	BusinessVerticalName string    `json:"business_vertical_name,omitempty"`
+	Progress             float64   `json:"progress"`
+	TotalBudget          float64   `json:"total_budget"`
+	SpentBudget          float64   `json:"spent_budget"`
+	Currency             string    `json:"currency"`
+	BaseTotalBudget      float64   `json:"base_total_budget"` // in the base currency, for totals across projects
+	BaseSpentBudget      float64   `json:"base_spent_budget"`
+	CriticalIncidents    int       `json:"critical_incidents"`
+	OpenTasks            int       `json:"open_tasks"`
+	OverdueTasks         int       `json:"overdue_tasks"`
+	Assessment
+
+	// Change since the most recent snapshot before the current week, if any.
+	PreviousRiskScore *float64 `json:"previous_risk_score,omitempty"`
+	RiskScoreDelta    *float64 `json:"risk_score_delta,omitempty"`
+}
+
+// Service evaluates project health across the portfolio.
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService creates a portfolio service
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+type projectRow struct {
+	ID                   uuid.UUID
+	Code                 string
+	Name                 string
+	Status               string
+	BusinessVerticalID   uuid.UUID
+	BusinessVerticalCode string
+	BusinessVerticalName string
+	StartDate            *time.Time
+	EndDate              *time.Time
+	Progress             float64
+	TotalBudget          float64
+	SpentBudget          float64
+	Currency             string
+	BaseTotalBudget      float64
+	BaseSpentBudget      float64
+}
+
+type taskCounts struct {
+	ProjectID         uuid.UUID
+	CriticalIncidents int
+	OpenTasks         int
+	OverdueTasks      int
+}
+
+// Evaluate scores every project matching filter and returns them ranked by risk, highest first.
+//
+// Open critical incidents are tasks with priority "critical" that are not completed or
+// cancelled; overdue tasks are open tasks whose planned end date has passed.
+func (s *Service) Evaluate(filter Filter, now time.Time) ([]ProjectHealth, error) {
+	query := s.filteredProjects(filter).
+		Select(`p.id, p.code, p.name, p.status, p.business_vertical_id, bv.code AS business_vertical_code,
+			bv.name AS business_vertical_name, p.start_date, p.end_date, p.progress, p.total_budget,
+			p.spent_budget, p.currency, p.base_total_budget, p.base_spent_budget`)
+
+	var projects []projectRow
+	if err := query.Scan(&projects).Error; err != nil {
+		return nil, fmt.Errorf("load projects: %w", err)
+	}
+	if len(projects) == 0 {
+		return []ProjectHealth{}, nil
+	}
+
+	projectIDs := make([]uuid.UUID, len(projects))
+	for i, p := range projects {
+		projectIDs[i] = p.ID
+	}
+
+	var counts []taskCounts
+	if err := s.db.Table("tasks").
+		Select(`project_id,
+			COUNT(*) FILTER (WHERE priority = 'critical' AND status NOT IN ('completed', 'cancelled')) AS critical_incidents,
+			COUNT(*) FILTER (WHERE status NOT IN ('completed', 'cancelled')) AS open_tasks,
+			COUNT(*) FILTER (WHERE status NOT IN ('completed', 'cancelled') AND planned_end_date < ?) AS overdue_tasks`, now).
+		Where("deleted_at IS NULL AND project_id IN ?", projectIDs).
+		Group("project_id").
+		Scan(&counts).Error; err != nil {
+		return nil, fmt.Errorf("count project tasks: %w", err)
+	}
+	countsByProject := make(map[uuid.UUID]taskCounts, len(counts))
+	for _, c := range counts {
+		countsByProject[c.ProjectID] = c
+	}
+
+	previous, err := s.previousRiskScores(projectIDs, WeekStart(now))
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ProjectHealth, 0, len(projects))
+	for _, p := range projects {
+		c := countsByProject[p.ID]
+		health := ProjectHealth{
+			ProjectID:            p.ID,
+			Code:                 p.Code,
+			Name:                 p.Name,
+			Status:               p.Status,
+			BusinessVerticalID:   p.BusinessVerticalID,
+			BusinessVerticalCode: p.BusinessVerticalCode,
+			BusinessVerticalName: p.BusinessVerticalName,
+			Progress:             p.Progress,
+			TotalBudget:          p.TotalBudget,
+			SpentBudget:          p.SpentBudget,
+			Currency:             p.Currency,
+			BaseTotalBudget:      p.BaseTotalBudget,
+			BaseSpentBudget:      p.BaseSpentBudget,
+			CriticalIncidents:    c.CriticalIncidents,
+			OpenTasks:            c.OpenTasks,
+			OverdueTasks:         c.OverdueTasks,
+			Assessment: Assess(Indicators{
+				Progress:          p.Progress,
+				StartDate:         p.StartDate,
+				EndDate:           p.EndDate,
+				TotalBudget:       p.TotalBudget,
+				SpentBudget:       p.SpentBudget,
+				CriticalIncidents: c.CriticalIncidents,
+				OpenTasks:         c.OpenTasks,
+				OverdueTasks:      c.OverdueTasks,
+			}, now),
+		}
+		if prev, ok := previous[p.ID]; ok {
+			delta := models.Round2(health.RiskScore - prev)
+			health.PreviousRiskScore = &prev
+			health.RiskScoreDelta = &delta
+		}
+		results = append(results, health)
+	}
+
+	SortByRisk(results)
+	return results, nil
+}
+
+// filteredProjects selects the projects matching filter as "p", joined to their business
+// vertical as "bv".
+func (s *Service) filteredProjects(filter Filter) *gorm.DB {
+	query := s.db.Table("projects p").
+		Joins("LEFT JOIN business_verticals bv ON bv.id = p.business_vertical_id").
+		Where("p.deleted_at IS NULL")
+	if filter.BusinessVerticalID != nil {
+		query = query.Where("p.business_vertical_id = ?", *filter.BusinessVerticalID)
+	}
+	query = query.Scopes(models.ForCompanyVerticals(filter.CompanyID, "p.business_vertical_id"))
+	if status := strings.TrimSpace(filter.Status); status != "" {
+		query = query.Where("p.status = ?", status)
+	} else if !filter.IncludeClosed {
+		query = query.Where("p.status NOT IN ?", closedProjectStatuses)
+	}
+	return query
+}
+
+// SortByRisk orders projects by descending risk score, breaking ties by code.
+func SortByRisk(results []ProjectHealth) {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].RiskScore != results[j].RiskScore {
+			return results[i].RiskScore > results[j].RiskScore
+		}
+		return results[i].Code < results[j].Code
+	})
+}
+
+// previousRiskScores returns each project's latest snapshot score from before week.
+func (s *Service) previousRiskScores(projectIDs []uuid.UUID, week time.Time) (map[uuid.UUID]float64, error) {
+	var snapshots []models.PortfolioSnapshot
+	if err := s.db.Raw(`
+		SELECT DISTINCT ON (project_id) project_id, risk_score
+		FROM portfolio_snapshots
+		WHERE project_id IN ? AND week_start < ?
+		ORDER BY project_id, week_start DESC`, projectIDs, week).
+		Scan(&snapshots).Error; err != nil {
+		return nil, fmt.Errorf("load previous snapshots: %w", err)
+	}
+
+	scores := make(map[uuid.UUID]float64, len(snapshots))
+	for _, snap := range snapshots {
+		scores[snap.ProjectID] = snap.RiskScore
+	}
+	return scores, nil
+}
+
+// Snapshot stores the current health of every open project for the week containing now.
+// Re-running it within the same week overwrites that week's snapshot.
+func (s *Service) Snapshot(now time.Time) (int, error) {
+	results, err := s.Evaluate(Filter{}, now)
+	if err != nil {
+		return 0, err
+	}
+	if len(results) == 0 {
+		return 0, nil
+	}
+
+	week := WeekStart(now)
+	snapshots := make([]models.PortfolioSnapshot, 0, len(results))
+	for _, r := range results {
+		snapshots = append(snapshots, models.PortfolioSnapshot{
+			ProjectID:          r.ProjectID,
+			BusinessVerticalID: r.BusinessVerticalID,
+			WeekStart:          week,
+			ProjectStatus:      r.Status,
+			Progress:           r.Progress,
+			PlannedProgress:    r.PlannedProgress,
+			ScheduleVariance:   r.ScheduleVariance,
+			TotalBudget:        r.TotalBudget,
+			SpentBudget:        r.SpentBudget,
+			BudgetVariance:     r.BudgetVariance,
+			CriticalIncidents:  r.CriticalIncidents,
+			OverdueTasks:       r.OverdueTasks,
+			RiskScore:          r.RiskScore,
+			RiskLevel:          r.RiskLevel,
+		})
+	}
+
+	err = s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "project_id"}, {Name: "week_start"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"business_vertical_id", "project_status", "progress", "planned_progress", "schedule_variance",
+			"total_budget", "spent_budget", "budget_variance", "critical_incidents", "overdue_tasks",
+			"risk_score", "risk_level", "updated_at",
+		}),
+	}).CreateInBatches(&snapshots, 200).Error
+	if err != nil {
+		return 0, fmt.Errorf("store portfolio snapshots: %w", err)
+	}
+
+	return len(snapshots), nil
+}
+
+// HasSnapshot reports whether any snapshot exists for the week containing now.
+func (s *Service) HasSnapshot(now time.Time) (bool, error) {
+	var count int64
+	if err := s.db.Model(&models.PortfolioSnapshot{}).Where("week_start = ?", WeekStart(now)).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// Snapshotter takes one portfolio snapshot per week in the background.
+type Snapshotter struct {
+	service *Service
+	*jobrunner.Runner
+}
+
+// NewSnapshotter creates a weekly snapshot scheduler. Every interval it checks whether
+// this week's snapshot exists and takes it if not, so a restart mid-week never produces a
+// gap or a duplicate.
+func NewSnapshotter(db *gorm.DB) *Snapshotter {
+	s := &Snapshotter{service: NewService(db)}
+	s.Runner = jobrunner.New("Portfolio snapshotter", s.runIfDue).RunAtStart()
+	return s
+}
+
+func (s *Snapshotter) runIfDue() {
+	now := time.Now()
+	exists, err := s.service.HasSnapshot(now)
+	if err != nil {
+		log.Printf("Error checking portfolio snapshot: %v", err)
+		return
+	}
+	if exists {
+		return
+	}
+
+	count, err := s.service.Snapshot(now)
+	if err != nil {
+		log.Printf("Error taking portfolio snapshot: %v", err)
+		return
+	}
+	log.Printf("Portfolio snapshot for week %s stored for %d projects", WeekStart(now).Format("2006-01-02"), count)
+}
@@ -0,0 +1,179 @@
+package progress
+
+import (
+	"math"
+	"sort"
+
+	"github.com/google/uuid"
+	"p9e.in/ugcl/models"
+)
+
+// Task is what the roll-up needs of one task.
+type Task struct {
+	ID              uuid.UUID
+	ZoneID          *uuid.UUID
+	Status          string
+	Progress        float64 // 0-100
+	AllocatedBudget float64
+	TotalCost       float64
+}
+
+// Zone is a zone tasks may belong to.
+type Zone struct {
+	ID   uuid.UUID
+	Name string
+	Code string
+}
+
+// ZoneProgress is one zone's share of the roll-up. Completion is the share of its
+// tasks that are completed; Progress weighs each task's progress by its budget.
+type ZoneProgress struct {
+	ZoneID            *uuid.UUID `json:"zone_id"` // nil for tasks outside any zone
+	Name              string     `json:"name"`
+	Code              string     `json:"code,omitempty"`
+	Tasks             int        `json:"tasks"`
+	CompletedTasks    int        `json:"completed_tasks"`
+	CompletionPercent float64    `json:"completion_percent"`
+	Progress          float64    `json:"progress"`
+	AllocatedBudget   float64    `json:"allocated_budget"`
+	ActualCost        float64    `json:"actual_cost"`
+}
+
+// Breakdown is a project's progress rolled up from its tasks.
+type Breakdown struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	// Progress is the tasks' progress weighted by allocated budget, or weighted equally
+	// when no task has a budget. Weighting says which.
+	Progress          float64        `json:"progress"`
+	Weighting         string         `json:"weighting"`
+	Tasks             int            `json:"tasks"`
+	CompletedTasks    int            `json:"completed_tasks"`
+	CancelledTasks    int            `json:"cancelled_tasks"`
+	CompletionPercent float64        `json:"completion_percent"`
+	TotalBudget       float64        `json:"total_budget"`
+	AllocatedBudget   float64        `json:"allocated_budget"`
+	SpentBudget       float64        `json:"spent_budget"` // sum of the tasks' actual costs
+	Zones             []ZoneProgress `json:"zones"`
+}
+
+const (
+	// WeightingBudget weighs each task by its allocated budget
+	WeightingBudget = "budget"
+	// WeightingEqual weighs every task the same
+	WeightingEqual = "equal"
+)
+
+// accumulator sums tasks for the project or one zone
+type accumulator struct {
+	tasks, completed int
+	budget, cost     float64
+	weighted, plain  float64 // progress times budget, and progress alone
+}
+
+func (a *accumulator) add(task Task) {
+	progress := math.Max(0, math.Min(100, task.Progress))
+	if task.Status == "completed" {
+		progress = 100
+		a.completed++
+	}
+	a.tasks++
+	a.budget += task.AllocatedBudget
+	a.weighted += progress * task.AllocatedBudget
+	a.plain += progress
+}
+
+// progress is the weighted progress and the weighting used
+func (a *accumulator) progress() (float64, string) {
+	if a.tasks == 0 {
+		return 0, WeightingEqual
+	}
+	if a.budget > 0 {
+		return models.Round2(a.weighted / a.budget), WeightingBudget
+	}
+	return models.Round2(a.plain / float64(a.tasks)), WeightingEqual
+}
+
+func (a *accumulator) completion() float64 {
+	if a.tasks == 0 {
+		return 0
+	}
+	return models.Round2(float64(a.completed) * 100 / float64(a.tasks))
+}
+
+// Compute rolls tasks up into a breakdown. Cancelled tasks count towards actual cost
+// but not towards progress, since their work will never be done. Every zone given is
+// listed, in name order, followed by tasks outside any zone if there are any.
+func Compute(projectID uuid.UUID, totalBudget float64, tasks []Task, zones []Zone) Breakdown {
+	breakdown := Breakdown{ProjectID: projectID, TotalBudget: totalBudget, Zones: []ZoneProgress{}}
+
+	var project accumulator
+	byZone := map[uuid.UUID]*accumulator{}
+	var unzoned accumulator
+	zoneCosts := map[uuid.UUID]float64{}
+	var unzonedCost float64
+	for _, task := range tasks {
+		breakdown.SpentBudget += task.TotalCost
+		if task.ZoneID != nil {
+			zoneCosts[*task.ZoneID] += task.TotalCost
+		} else {
+			unzonedCost += task.TotalCost
+		}
+		if task.Status == "cancelled" {
+			breakdown.CancelledTasks++
+			continue
+		}
+		project.add(task)
+		if task.ZoneID == nil {
+			unzoned.add(task)
+			continue
+		}
+		zone := byZone[*task.ZoneID]
+		if zone == nil {
+			zone = &accumulator{}
+			byZone[*task.ZoneID] = zone
+		}
+		zone.add(task)
+	}
+
+	breakdown.Progress, breakdown.Weighting = project.progress()
+	breakdown.Tasks = project.tasks
+	breakdown.CompletedTasks = project.completed
+	breakdown.CompletionPercent = project.completion()
+	breakdown.AllocatedBudget = models.Round2(project.budget)
+	breakdown.SpentBudget = models.Round2(breakdown.SpentBudget)
+
+	sorted := append([]Zone(nil), zones...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	for _, zone := range sorted {
+		id := zone.ID
+		sums := byZone[id]
+		if sums == nil {
+			sums = &accumulator{}
+		}
+		zoneProgress, _ := sums.progress()
+		breakdown.Zones = append(breakdown.Zones, ZoneProgress{
+			ZoneID:            &id,
+			Name:              zone.Name,
+			Code:              zone.Code,
+			Tasks:             sums.tasks,
+			CompletedTasks:    sums.completed,
+			CompletionPercent: sums.completion(),
+			Progress:          zoneProgress,
+			AllocatedBudget:   models.Round2(sums.budget),
+			ActualCost:        models.Round2(zoneCosts[id]),
+		})
+	}
+	if unzoned.tasks > 0 || unzonedCost > 0 {
+		unzonedProgress, _ := unzoned.progress()
+		breakdown.Zones = append(breakdown.Zones, ZoneProgress{
+			Name:              "Unzoned",
+			Tasks:             unzoned.tasks,
+			CompletedTasks:    unzoned.completed,
+			CompletionPercent: unzoned.completion(),
+			Progress:          unzonedProgress,
+			AllocatedBudget:   models.Round2(unzoned.budget),
+			ActualCost:        models.Round2(unzonedCost),
+		})
+	}
+	return breakdown
+}
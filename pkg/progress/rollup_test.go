@@ -0,0 +1,55 @@
+package progress
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestComputeWeighsByBudget(t *testing.T) {
+	north := Zone{ID: uuid.New(), Name: "North"}
+	south := Zone{ID: uuid.New(), Name: "South"}
+	tasks := []Task{
+		{ID: uuid.New(), ZoneID: &north.ID, Status: "completed", Progress: 80, AllocatedBudget: 300, TotalCost: 250},
+		{ID: uuid.New(), ZoneID: &north.ID, Status: "in-progress", Progress: 50, AllocatedBudget: 100, TotalCost: 40},
+		{ID: uuid.New(), Status: "pending", AllocatedBudget: 100},
+		{ID: uuid.New(), ZoneID: &south.ID, Status: "cancelled", Progress: 20, AllocatedBudget: 1000, TotalCost: 10},
+	}
+
+	got := Compute(uuid.New(), 2000, tasks, []Zone{south, north})
+	// (100*300 + 50*100 + 0*100) / 500
+	if got.Progress != 70 || got.Weighting != WeightingBudget {
+		t.Errorf("progress = %v (%s), want 70 (budget)", got.Progress, got.Weighting)
+	}
+	if got.Tasks != 3 || got.CompletedTasks != 1 || got.CancelledTasks != 1 {
+		t.Errorf("tasks = %d, completed = %d, cancelled = %d", got.Tasks, got.CompletedTasks, got.CancelledTasks)
+	}
+	if got.SpentBudget != 300 || got.AllocatedBudget != 500 {
+		t.Errorf("spent = %v, allocated = %v", got.SpentBudget, got.AllocatedBudget)
+	}
+	if len(got.Zones) != 3 || got.Zones[0].Name != "North" || got.Zones[2].ZoneID != nil {
+		t.Fatalf("zones = %+v", got.Zones)
+	}
+	if zone := got.Zones[0]; zone.CompletionPercent != 50 || zone.Progress != 87.5 || zone.ActualCost != 290 {
+		t.Errorf("north = %+v", zone)
+	}
+	if zone := got.Zones[1]; zone.Tasks != 0 || zone.ActualCost != 10 {
+		t.Errorf("south = %+v", zone)
+	}
+}
+
+func TestComputeFallsBackToEqualWeights(t *testing.T) {
+	tasks := []Task{
+		{ID: uuid.New(), Status: "in-progress", Progress: 30},
+		{ID: uuid.New(), Status: "in-progress", Progress: 120},
+	}
+	got := Compute(uuid.New(), 0, tasks, nil)
+	if got.Progress != 65 || got.Weighting != WeightingEqual {
+		t.Errorf("progress = %v (%s), want 65 (equal)", got.Progress, got.Weighting)
+	}
+
+	empty := Compute(uuid.New(), 0, nil, nil)
+	if empty.Progress != 0 || empty.Zones == nil {
+		t.Errorf("empty breakdown = %+v", empty)
+	}
+}
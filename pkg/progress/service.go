@@ -0,0 +1,105 @@
+package progress
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/jobrunner"
+)
+
+// Service rolls task progress and costs up to their project.
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService creates a progress roll-up service
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// Breakdown computes a project's progress from its tasks and zones without storing it.
+func (s *Service) Breakdown(projectID uuid.UUID) (Breakdown, error) {
+	var project models.Project
+	if err := s.db.Select("id", "total_budget").First(&project, "id = ? AND deleted_at IS NULL", projectID).Error; err != nil {
+		return Breakdown{}, err
+	}
+
+	var tasks []Task
+	if err := s.db.Model(&models.Tasks{}).
+		Select("id, zone_id, status, progress, allocated_budget, total_cost").
+		Where("project_id = ? AND deleted_at IS NULL", projectID).
+		Scan(&tasks).Error; err != nil {
+		return Breakdown{}, fmt.Errorf("failed to load tasks: %w", err)
+	}
+	var zones []Zone
+	if err := s.db.Model(&models.Zone{}).
+		Select("id, name, code").
+		Where("project_id = ? AND deleted_at IS NULL", projectID).
+		Scan(&zones).Error; err != nil {
+		return Breakdown{}, fmt.Errorf("failed to load zones: %w", err)
+	}
+	return Compute(projectID, project.TotalBudget, tasks, zones), nil
+}
+
+// Rollup recomputes a project's breakdown and stores its progress and spent budget on
+// the project.
+func (s *Service) Rollup(projectID uuid.UUID) (Breakdown, error) {
+	breakdown, err := s.Breakdown(projectID)
+	if err != nil {
+		return Breakdown{}, err
+	}
+	if err := s.db.Model(&models.Project{}).
+		Where("id = ?", projectID).
+		UpdateColumns(map[string]interface{}{
+			"progress":     breakdown.Progress,
+			"spent_budget": breakdown.SpentBudget,
+		}).Error; err != nil {
+		return Breakdown{}, fmt.Errorf("failed to store project progress: %w", err)
+	}
+	return breakdown, nil
+}
+
+// RollupAll rolls up every project that is not deleted and returns how many were
+// updated. A project that fails is logged and skipped so one bad project cannot stall
+// the rest.
+func (s *Service) RollupAll() (int, error) {
+	var ids []uuid.UUID
+	if err := s.db.Model(&models.Project{}).Where("deleted_at IS NULL").Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	updated := 0
+	for _, id := range ids {
+		if _, err := s.Rollup(id); err != nil {
+			log.Printf("Error rolling up progress of project %s: %v", id, err)
+			continue
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+// Reconciler rolls every project up in the background, catching task changes that
+// bypassed the handlers that trigger a roll-up.
+type Reconciler struct {
+	service *Service
+	*jobrunner.Runner
+}
+
+// NewReconciler creates a progress reconciliation job
+func NewReconciler(db *gorm.DB) *Reconciler {
+	r := &Reconciler{service: NewService(db)}
+	r.Runner = jobrunner.New("Project progress reconciler", r.run).RunAtStart()
+	return r
+}
+
+func (r *Reconciler) run() {
+	count, err := r.service.RollupAll()
+	if err != nil {
+		log.Printf("Error reconciling project progress: %v", err)
+		return
+	}
+	log.Printf("Project progress reconciled for %d projects", count)
+}
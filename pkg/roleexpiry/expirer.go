@@ -0,0 +1,150 @@
+package roleexpiry
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/middleware"
+	"p9e.in/ugcl/models"
+	"p9e.in/ugcl/pkg/jobrunner"
+)
+
+// Expirer deactivates role assignments whose valid_until has passed and notifies
+// whoever granted them. The permission resolver already ignores expired grants, so
+// the job only makes the expiry visible in the data and to the assigner.
+type Expirer struct {
+	db *gorm.DB
+	*jobrunner.Runner
+}
+
+// NewExpirer creates a role expiry job
+func NewExpirer(db *gorm.DB) *Expirer {
+	e := &Expirer{db: db}
+	e.Runner = jobrunner.New("Role expiry job", e.run).RunAtStart()
+	return e
+}
+
+func (e *Expirer) run() {
+	now := time.Now()
+	business, err := e.ExpireBusinessRoles(now)
+	if err != nil {
+		log.Printf("Error expiring business role assignments: %v", err)
+	}
+	global, err := e.ExpireGlobalRoles(now)
+	if err != nil {
+		log.Printf("Error expiring global role assignments: %v", err)
+	}
+	if business > 0 || global > 0 {
+		log.Printf("Role expiry: deactivated %d business and %d global role assignments", business, global)
+	}
+}
+
+// ExpireBusinessRoles deactivates active business role assignments that expired at or
+// before now and returns how many were deactivated.
+func (e *Expirer) ExpireBusinessRoles(now time.Time) (int, error) {
+	var expired []models.UserBusinessRole
+	if err := e.db.
+		Preload("User").
+		Preload("BusinessRole.BusinessVertical").
+		Where("is_active = ? AND valid_until IS NOT NULL AND valid_until <= ?", true, now).
+		Find(&expired).Error; err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, ubr := range expired {
+		// Guard on is_active so that concurrent instances notify only once.
+		result := e.db.Model(&models.UserBusinessRole{}).
+			Where("id = ? AND is_active = ?", ubr.ID, true).
+			Updates(map[string]interface{}{"is_active": false, "updated_at": now})
+		if result.Error != nil {
+			log.Printf("Error deactivating business role assignment %s: %v", ubr.ID, result.Error)
+			continue
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		count++
+		middleware.InvalidateUserCache(ubr.UserID.String())
+
+		role := ubr.BusinessRole.DisplayName
+		if vertical := ubr.BusinessRole.BusinessVertical.Name; vertical != "" {
+			role = fmt.Sprintf("%s (%s)", role, vertical)
+		}
+		e.notifyAssigner(ubr.AssignedBy, ubr.User, role, *ubr.ValidUntil, now, models.JSONMap{
+			"user_business_role_id": ubr.ID.String(),
+			"business_role_id":      ubr.BusinessRoleID.String(),
+		}, &ubr.BusinessRole.BusinessVerticalID)
+	}
+	return count, nil
+}
+
+// ExpireGlobalRoles removes global roles whose validity ended at or before now and
+// returns how many were removed.
+func (e *Expirer) ExpireGlobalRoles(now time.Time) (int, error) {
+	var users []models.User
+	if err := e.db.
+		Preload("RoleModel").
+		Where("role_id IS NOT NULL AND role_valid_until IS NOT NULL AND role_valid_until <= ?", now).
+		Find(&users).Error; err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, user := range users {
+		result := e.db.Model(&models.User{}).
+			Where("id = ? AND role_id = ?", user.ID, *user.RoleID).
+			Updates(map[string]interface{}{
+				"role_id":          nil,
+				"role_valid_from":  nil,
+				"role_valid_until": nil,
+				"updated_at":       now,
+			})
+		if result.Error != nil {
+			log.Printf("Error removing expired global role for user %s: %v", user.ID, result.Error)
+			continue
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		count++
+		middleware.InvalidateUserCache(user.ID.String())
+
+		role := "global role"
+		if user.RoleModel != nil {
+			role = user.RoleModel.Name
+		}
+		e.notifyAssigner(user.RoleAssignedBy, user, role, *user.RoleValidUntil, now, models.JSONMap{
+			"role_id": user.RoleID.String(),
+		}, nil)
+	}
+	return count, nil
+}
+
+func (e *Expirer) notifyAssigner(assignedBy *uuid.UUID, user models.User, role string, validUntil, now time.Time, metadata models.JSONMap, businessVerticalID *uuid.UUID) {
+	if assignedBy == nil {
+		return
+	}
+
+	metadata["user_id"] = user.ID.String()
+	metadata["valid_until"] = validUntil.Format(time.RFC3339)
+	notification := &models.Notification{
+		UserID:             assignedBy.String(),
+		Type:               models.NotificationTypeSystemAlert,
+		Priority:           models.NotificationPriorityNormal,
+		Title:              "Role assignment expired",
+		Body:               fmt.Sprintf("%s's %s access expired on %s and has been deactivated.", user.Name, role, validUntil.Format("02 Jan 2006 15:04")),
+		ActionURL:          fmt.Sprintf("/admin/users/%s", user.ID),
+		BusinessVerticalID: businessVerticalID,
+		Status:             models.NotificationStatusSent,
+		Channel:            models.NotificationChannelInApp,
+		SentAt:             &now,
+		Metadata:           metadata,
+	}
+	if err := e.db.Create(notification).Error; err != nil {
+		log.Printf("Error notifying %s about expired role of user %s: %v", assignedBy, user.ID, err)
+	}
+}
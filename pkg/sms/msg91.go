@@ -0,0 +1,137 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ProviderMSG91 is the name of the MSG91 provider
+const ProviderMSG91 = "msg91"
+
+const msg91Endpoint = "https://api.msg91.com/api/v2/sendsms"
+
+// msg91FailedCodes are the delivery report codes MSG91 uses for undeliverable messages:
+// failed, DND number, rejected and blocked
+var msg91FailedCodes = map[string]bool{"2": true, "9": true, "16": true, "17": true, "25": true, "26": true}
+
+// MSG91 sends through MSG91's transactional route. Delivery reports are posted to the
+// webhook set in the MSG91 panel, which carries the callback token as ?token=.
+type MSG91 struct {
+	authKey       string
+	callbackToken string
+	endpoint      string
+	client        *http.Client
+}
+
+// NewMSG91 creates the MSG91 provider
+func NewMSG91(authKey, callbackToken string) *MSG91 {
+	return &MSG91{
+		authKey:       authKey,
+		callbackToken: callbackToken,
+		endpoint:      msg91Endpoint,
+		client:        &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (p *MSG91) Name() string { return ProviderMSG91 }
+
+// Send sends one message and returns MSG91's request ID
+func (p *MSG91) Send(ctx context.Context, msg Message) (string, error) {
+	payload := map[string]interface{}{
+		"sender":  msg.SenderID,
+		"route":   "4", // transactional
+		"country": "0", // numbers carry their country code
+		"sms": []map[string]interface{}{
+			{"message": msg.Text, "to": []string{strings.TrimPrefix(msg.To, "+")}},
+		},
+	}
+	if msg.TemplateID != "" {
+		payload["DLT_TE_ID"] = msg.TemplateID
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("authkey", p.authKey)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("msg91 request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var out struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(raw, &out); err != nil || resp.StatusCode < 200 || resp.StatusCode >= 300 || out.Type != "success" {
+		return "", &ProviderError{Provider: ProviderMSG91, StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(raw))}
+	}
+	return out.Message, nil
+}
+
+// ParseCallback reads a delivery report webhook, posted either as a form with the reports
+// in its data field or as the JSON itself:
+//
+//	[{"requestId": "...", "report": [{"number": "91...", "status": "1", "desc": "DELIVERED"}]}]
+func (p *MSG91) ParseCallback(r *http.Request) ([]DeliveryReport, error) {
+	token := r.URL.Query().Get("token")
+	if p.callbackToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(p.callbackToken)) != 1 {
+		return nil, ErrUnauthenticatedCallback
+	}
+
+	var raw []byte
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		if err := r.ParseForm(); err != nil {
+			return nil, err
+		}
+		raw = []byte(r.PostForm.Get("data"))
+	} else {
+		var err error
+		if raw, err = io.ReadAll(io.LimitReader(r.Body, 1<<20)); err != nil {
+			return nil, err
+		}
+	}
+
+	var batches []struct {
+		RequestID string `json:"requestId"`
+		Report    []struct {
+			Number string `json:"number"`
+			Status string `json:"status"`
+			Desc   string `json:"desc"`
+		} `json:"report"`
+	}
+	if err := json.Unmarshal(raw, &batches); err != nil {
+		return nil, fmt.Errorf("msg91: invalid delivery report: %w", err)
+	}
+
+	var reports []DeliveryReport
+	for _, batch := range batches {
+		for _, rep := range batch.Report {
+			report := DeliveryReport{ProviderMessageID: batch.RequestID, To: "+" + rep.Number, Status: StatusSent}
+			switch {
+			case rep.Status == "1" || strings.EqualFold(rep.Desc, "DELIVERED"):
+				report.Status = StatusDelivered
+			case msg91FailedCodes[rep.Status]:
+				report.Status = StatusFailed
+				report.Error = rep.Desc
+			}
+			reports = append(reports, report)
+		}
+	}
+	return reports, nil
+}
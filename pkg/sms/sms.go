@@ -0,0 +1,220 @@
+// Package sms sends text messages through pluggable providers (MSG91, Twilio), picking the
+// provider and sender ID by the destination's country, and parses the providers' delivery
+// status callbacks.
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Delivery statuses reported by providers
+const (
+	StatusSent      = "sent"      // accepted by the provider or handed to the carrier
+	StatusDelivered = "delivered" // confirmed on the handset
+	StatusFailed    = "failed"    // rejected, undeliverable or expired
+)
+
+// Message is one text to one number
+type Message struct {
+	To         string // E.164, e.g. +919876543210
+	Text       string
+	SenderID   string // alphanumeric sender ID or a number, depending on the provider
+	TemplateID string // registered template, required by DLT for Indian traffic
+}
+
+// DeliveryReport is a status update a provider posted for a sent message
+type DeliveryReport struct {
+	ProviderMessageID string
+	To                string
+	Status            string
+	Error             string
+}
+
+// Provider sends messages and reads the delivery callbacks it posts back.
+type Provider interface {
+	Name() string
+	// Send hands the message to the provider and returns its message ID
+	Send(ctx context.Context, msg Message) (string, error)
+	// ParseCallback authenticates and decodes a delivery status callback
+	ParseCallback(r *http.Request) ([]DeliveryReport, error)
+}
+
+// ErrUnauthenticatedCallback is returned for callbacks that fail the provider's check
+var ErrUnauthenticatedCallback = errors.New("sms: callback failed authentication")
+
+// ProviderError is a provider's refusal of a message
+type ProviderError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("%s returned %d: %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// IsTransient reports whether a Send error may clear on retry: the provider could not be
+// reached, timed out, was throttling or failed on its side. Rejected messages, bad
+// credentials and unroutable numbers are not.
+func IsTransient(err error) bool {
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return providerErr.StatusCode == http.StatusTooManyRequests || providerErr.StatusCode >= 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// Sender is the provider and sender ID used for one country. Templates maps a message
+// purpose (otp, alarm, approval_reminder) to its registered template ID.
+type Sender struct {
+	Provider  string            `json:"provider"`
+	SenderID  string            `json:"sender_id"`
+	Templates map[string]string `json:"templates,omitempty"`
+}
+
+// Router picks the provider and sender for each destination.
+type Router struct {
+	providers      map[string]Provider
+	senders        map[string]Sender // by country dial code without +, "*" for the rest
+	defaultCountry string
+}
+
+// NewRouter creates a router. senders is keyed by country dial code ("91", "1", "971")
+// with "*" as the fallback; numbers without a country code get defaultCountry.
+func NewRouter(providers []Provider, senders map[string]Sender, defaultCountry string) (*Router, error) {
+	rt := &Router{
+		providers:      make(map[string]Provider, len(providers)),
+		senders:        senders,
+		defaultCountry: strings.TrimPrefix(strings.TrimSpace(defaultCountry), "+"),
+	}
+	for _, p := range providers {
+		rt.providers[p.Name()] = p
+	}
+	for country, sender := range senders {
+		if _, ok := rt.providers[sender.Provider]; !ok {
+			return nil, fmt.Errorf("sms: sender for %q uses provider %q, which is not configured", country, sender.Provider)
+		}
+	}
+	return rt, nil
+}
+
+// NewRouterFromEnv builds the router from the environment. Providers are enabled by their
+// credentials: MSG91_AUTH_KEY, and TWILIO_ACCOUNT_SID with TWILIO_AUTH_TOKEN.
+// SMS_CALLBACK_BASE_URL is the public base URL delivery callbacks are posted to and
+// SMS_CALLBACK_TOKEN authenticates those without a signature. SMS_SENDERS is a JSON object
+// of Sender keyed by dial code, e.g.
+//
+//	{"91": {"provider": "msg91", "sender_id": "UGCLIN", "templates": {"otp": "1207..."}},
+//	 "*":  {"provider": "twilio", "sender_id": "+15550100"}}
+//
+// and defaults to SMS_SENDER_ID on the first configured provider for every country.
+// SMS_DEFAULT_COUNTRY_CODE (default 91) is used for numbers stored without one. It
+// returns nil when no provider is configured, in which case callers skip SMS.
+func NewRouterFromEnv() (*Router, error) {
+	callbackBase := strings.TrimRight(strings.TrimSpace(os.Getenv("SMS_CALLBACK_BASE_URL")), "/")
+	callbackURL := func(provider string) string {
+		if callbackBase == "" {
+			return ""
+		}
+		return callbackBase + "/api/v1/sms/callbacks/" + provider
+	}
+
+	var providers []Provider
+	if key := strings.TrimSpace(os.Getenv("MSG91_AUTH_KEY")); key != "" {
+		providers = append(providers, NewMSG91(key, strings.TrimSpace(os.Getenv("SMS_CALLBACK_TOKEN"))))
+	}
+	sid := strings.TrimSpace(os.Getenv("TWILIO_ACCOUNT_SID"))
+	authToken := strings.TrimSpace(os.Getenv("TWILIO_AUTH_TOKEN"))
+	if sid != "" && authToken != "" {
+		providers = append(providers, NewTwilio(sid, authToken, callbackURL(ProviderTwilio)))
+	}
+	if len(providers) == 0 {
+		return nil, nil
+	}
+
+	senders := map[string]Sender{}
+	if raw := strings.TrimSpace(os.Getenv("SMS_SENDERS")); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &senders); err != nil {
+			return nil, fmt.Errorf("sms: invalid SMS_SENDERS: %w", err)
+		}
+	} else {
+		senders["*"] = Sender{Provider: providers[0].Name(), SenderID: strings.TrimSpace(os.Getenv("SMS_SENDER_ID"))}
+	}
+
+	country := strings.TrimSpace(os.Getenv("SMS_DEFAULT_COUNTRY_CODE"))
+	if country == "" {
+		country = "91"
+	}
+	return NewRouter(providers, senders, country)
+}
+
+// Provider returns the named provider, or nil
+func (rt *Router) Provider(name string) Provider {
+	return rt.providers[name]
+}
+
+// Route normalises the number and returns it with the provider and the message to send
+// for the purpose. The sender is chosen by the longest matching dial code.
+func (rt *Router) Route(phone, purpose, text string) (Provider, Message, error) {
+	to, err := NormalizePhone(phone, rt.defaultCountry)
+	if err != nil {
+		return nil, Message{}, err
+	}
+	digits := strings.TrimPrefix(to, "+")
+	best, found := "", false
+	for code := range rt.senders {
+		if code == "*" || !strings.HasPrefix(digits, code) {
+			continue
+		}
+		if !found || len(code) > len(best) {
+			best, found = code, true
+		}
+	}
+	if !found {
+		best = "*"
+	}
+	sender, ok := rt.senders[best]
+	if !ok {
+		return nil, Message{}, fmt.Errorf("sms: no sender configured for %s", to)
+	}
+	return rt.providers[sender.Provider], Message{
+		To:         to,
+		Text:       text,
+		SenderID:   sender.SenderID,
+		TemplateID: sender.Templates[purpose],
+	}, nil
+}
+
+// NormalizePhone turns a stored phone number into E.164. Numbers without a leading + or
+// 00 are local: a leading 0 trunk prefix is dropped and defaultCountry is prepended.
+func NormalizePhone(phone, defaultCountry string) (string, error) {
+	phone = strings.TrimSpace(phone)
+	international := strings.HasPrefix(phone, "+") || strings.HasPrefix(phone, "00")
+	var b strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		} else if !strings.ContainsRune("+ -().", r) {
+			return "", fmt.Errorf("sms: invalid phone number %q", phone)
+		}
+	}
+	digits := b.String()
+	switch {
+	case strings.HasPrefix(phone, "00"):
+		digits = strings.TrimPrefix(digits, "00")
+	case !international:
+		digits = strings.TrimLeft(defaultCountry, "+") + strings.TrimPrefix(digits, "0")
+	}
+	if len(digits) < 8 || len(digits) > 15 {
+		return "", fmt.Errorf("sms: invalid phone number %q", phone)
+	}
+	return "+" + digits, nil
+}
@@ -0,0 +1,189 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"9876543210", "+919876543210"},
+		{"09876543210", "+919876543210"},
+		{"+1 (555) 010-0199", "+15550100199"},
+		{"00971501234567", "+971501234567"},
+	}
+	for _, tt := range tests {
+		if got, err := NormalizePhone(tt.in, "91"); err != nil || got != tt.want {
+			t.Errorf("NormalizePhone(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"", "12345", "98765x3210"} {
+		if _, err := NormalizePhone(bad, "91"); err == nil {
+			t.Errorf("NormalizePhone(%q) accepted", bad)
+		}
+	}
+}
+
+func TestRouterRoute(t *testing.T) {
+	msg91, twilio := NewMSG91("key", "tok"), NewTwilio("AC1", "secret", "")
+	router, err := NewRouter([]Provider{msg91, twilio}, map[string]Sender{
+		"91":  {Provider: ProviderMSG91, SenderID: "UGCLIN", Templates: map[string]string{"otp": "1207001"}},
+		"971": {Provider: ProviderTwilio, SenderID: "UGCL"},
+		"*":   {Provider: ProviderTwilio, SenderID: "+15550100"},
+	}, "91")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, msg, err := router.Route("9876543210", "otp", "123456 is your code")
+	if err != nil || p.Name() != ProviderMSG91 || msg.To != "+919876543210" || msg.SenderID != "UGCLIN" || msg.TemplateID != "1207001" {
+		t.Errorf("Indian number routed to %v %+v, %v", p, msg, err)
+	}
+	if p, msg, _ := router.Route("+971501234567", "alarm", "x"); p.Name() != ProviderTwilio || msg.SenderID != "UGCL" || msg.TemplateID != "" {
+		t.Errorf("UAE number routed to %s %+v", p.Name(), msg)
+	}
+	if _, msg, _ := router.Route("+447700900123", "alarm", "x"); msg.SenderID != "+15550100" {
+		t.Errorf("other number used sender %q, want the fallback", msg.SenderID)
+	}
+
+	if _, err := NewRouter([]Provider{msg91}, map[string]Sender{"*": {Provider: ProviderTwilio}}, "91"); err == nil {
+		t.Error("sender on an unconfigured provider accepted")
+	}
+	noFallback, _ := NewRouter([]Provider{msg91}, map[string]Sender{"91": {Provider: ProviderMSG91}}, "91")
+	if _, _, err := noFallback.Route("+15550100199", "otp", "x"); err == nil {
+		t.Error("number without a sender routed")
+	}
+}
+
+func TestMSG91(t *testing.T) {
+	var got map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("authkey") != "key" {
+			w.Write([]byte(`{"type":"error","message":"Authentication failure"}`))
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"type":"success","message":"3763646c3058"}`))
+	}))
+	defer server.Close()
+
+	p := NewMSG91("key", "tok")
+	p.endpoint = server.URL
+	id, err := p.Send(context.Background(), Message{To: "+919876543210", Text: "hi", SenderID: "UGCLIN", TemplateID: "1207001"})
+	if err != nil || id != "3763646c3058" {
+		t.Fatalf("Send() = %q, %v", id, err)
+	}
+	if got["sender"] != "UGCLIN" || got["DLT_TE_ID"] != "1207001" || !strings.Contains(toJSON(got["sms"]), `"to":["919876543210"]`) {
+		t.Errorf("unexpected payload %v", got)
+	}
+
+	p.authKey = "wrong"
+	if _, err := p.Send(context.Background(), Message{To: "+919876543210", Text: "hi"}); err == nil {
+		t.Error("error response accepted")
+	}
+
+	data := `[{"requestId":"3763646c3058","report":[{"number":"919876543210","status":"1","desc":"DELIVERED"},{"number":"919876543211","status":"9","desc":"NDNC"}]}]`
+	req := httptest.NewRequest(http.MethodPost, "/cb?token=tok", strings.NewReader(url.Values{"data": {data}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	reports, err := p.ParseCallback(req)
+	if err != nil || len(reports) != 2 {
+		t.Fatalf("ParseCallback() = %v, %v", reports, err)
+	}
+	if reports[0].Status != StatusDelivered || reports[0].ProviderMessageID != "3763646c3058" || reports[1].Status != StatusFailed {
+		t.Errorf("unexpected reports %+v", reports)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/cb?token=guess", strings.NewReader(data))
+	if _, err := p.ParseCallback(req); err != ErrUnauthenticatedCallback {
+		t.Errorf("callback with a wrong token: %v", err)
+	}
+}
+
+func TestTwilio(t *testing.T) {
+	var form url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "AC1" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"code":20003,"message":"Authenticate"}`))
+			return
+		}
+		r.ParseForm()
+		form = r.PostForm
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"sid":"SM123","status":"queued"}`))
+	}))
+	defer server.Close()
+
+	callback := "https://erp.example.com/api/v1/sms/callbacks/twilio"
+	p := NewTwilio("AC1", "secret", callback)
+	p.baseURL = server.URL
+	id, err := p.Send(context.Background(), Message{To: "+15550100199", Text: "hi", SenderID: "+15550100"})
+	if err != nil || id != "SM123" {
+		t.Fatalf("Send() = %q, %v", id, err)
+	}
+	if form.Get("From") != "+15550100" || form.Get("StatusCallback") != callback {
+		t.Errorf("unexpected form %v", form)
+	}
+	if _, err := p.Send(context.Background(), Message{To: "+15550100199", SenderID: "MG9"}); err != nil || form.Get("MessagingServiceSid") != "MG9" {
+		t.Errorf("messaging service sender: %v, form %v", err, form)
+	}
+
+	status := url.Values{"MessageSid": {"SM123"}, "MessageStatus": {"undelivered"}, "ErrorCode": {"30003"}, "To": {"+15550100199"}}
+	req := httptest.NewRequest(http.MethodPost, "/cb", strings.NewReader(status.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Twilio-Signature", p.signature(callback, status))
+	reports, err := p.ParseCallback(req)
+	if err != nil || len(reports) != 1 || reports[0].Status != StatusFailed || reports[0].ProviderMessageID != "SM123" {
+		t.Fatalf("ParseCallback() = %+v, %v", reports, err)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/cb", strings.NewReader(status.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Twilio-Signature", "forged")
+	if _, err := p.ParseCallback(req); err != ErrUnauthenticatedCallback {
+		t.Errorf("forged callback: %v", err)
+	}
+}
+
+func toJSON(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func TestIsTransient(t *testing.T) {
+	busy := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if busy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":21211,"message":"Invalid 'To' Phone Number"}`))
+	}))
+	p := NewTwilio("AC1", "secret", "")
+	p.baseURL = server.URL
+	msg := Message{To: "+15550100100", Text: "hi", SenderID: "+15550100001"}
+
+	if _, err := p.Send(context.Background(), msg); !IsTransient(err) {
+		t.Errorf("provider outage not transient: %v", err)
+	}
+	busy = false
+	if _, err := p.Send(context.Background(), msg); err == nil || IsTransient(err) {
+		t.Errorf("rejected message treated as transient: %v", err)
+	}
+	server.Close()
+	if _, err := p.Send(context.Background(), msg); !IsTransient(err) {
+		t.Errorf("unreachable provider not transient: %v", err)
+	}
+	if IsTransient(fmt.Errorf("sms: invalid phone number %q", "12")) {
+		t.Error("invalid number treated as transient")
+	}
+}
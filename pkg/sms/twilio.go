@@ -0,0 +1,139 @@
+package sms
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ProviderTwilio is the name of the Twilio provider
+const ProviderTwilio = "twilio"
+
+const twilioAPI = "https://api.twilio.com/2010-04-01"
+
+// Twilio sends through Twilio's Messages API. The sender ID is a Twilio number, an
+// alphanumeric sender ID or a messaging service SID (MG...). Status callbacks are signed
+// with the auth token and checked against the callback URL given to Twilio.
+type Twilio struct {
+	accountSID  string
+	authToken   string
+	callbackURL string
+	baseURL     string
+	client      *http.Client
+}
+
+// NewTwilio creates the Twilio provider. Without a callbackURL Twilio posts no statuses.
+func NewTwilio(accountSID, authToken, callbackURL string) *Twilio {
+	return &Twilio{
+		accountSID:  accountSID,
+		authToken:   authToken,
+		callbackURL: callbackURL,
+		baseURL:     twilioAPI,
+		client:      &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (p *Twilio) Name() string { return ProviderTwilio }
+
+// Send sends one message and returns its Twilio SID
+func (p *Twilio) Send(ctx context.Context, msg Message) (string, error) {
+	form := url.Values{"To": {msg.To}, "Body": {msg.Text}}
+	if strings.HasPrefix(msg.SenderID, "MG") {
+		form.Set("MessagingServiceSid", msg.SenderID)
+	} else {
+		form.Set("From", msg.SenderID)
+	}
+	if p.callbackURL != "" {
+		form.Set("StatusCallback", p.callbackURL)
+	}
+
+	endpoint := fmt.Sprintf("%s/Accounts/%s/Messages.json", p.baseURL, p.accountSID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(p.accountSID, p.authToken)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("twilio request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var out struct {
+		SID     string `json:"sid"`
+		Message string `json:"message"`
+	}
+	_ = json.Unmarshal(raw, &out)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 || out.SID == "" {
+		if out.Message == "" {
+			out.Message = strings.TrimSpace(string(raw))
+		}
+		return "", &ProviderError{Provider: ProviderTwilio, StatusCode: resp.StatusCode, Message: out.Message}
+	}
+	return out.SID, nil
+}
+
+// ParseCallback verifies the X-Twilio-Signature of a status callback and reads its
+// MessageSid and MessageStatus
+func (p *Twilio) ParseCallback(r *http.Request) ([]DeliveryReport, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	if p.callbackURL == "" || !hmac.Equal([]byte(r.Header.Get("X-Twilio-Signature")), []byte(p.signature(p.callbackURL, r.PostForm))) {
+		return nil, ErrUnauthenticatedCallback
+	}
+
+	report := DeliveryReport{
+		ProviderMessageID: r.PostForm.Get("MessageSid"),
+		To:                r.PostForm.Get("To"),
+		Status:            StatusSent,
+	}
+	switch r.PostForm.Get("MessageStatus") {
+	case "delivered":
+		report.Status = StatusDelivered
+	case "failed", "undelivered", "canceled":
+		report.Status = StatusFailed
+		report.Error = r.PostForm.Get("MessageStatus")
+		if code := r.PostForm.Get("ErrorCode"); code != "" {
+			report.Error += ", error " + code
+		}
+	}
+	if report.ProviderMessageID == "" {
+		return nil, fmt.Errorf("twilio: status callback without MessageSid")
+	}
+	return []DeliveryReport{report}, nil
+}
+
+// signature is Twilio's request signature: base64 HMAC-SHA1, keyed by the auth token, of
+// the URL followed by every form field name and value sorted by name
+func (p *Twilio) signature(callbackURL string, form url.Values) string {
+	keys := make([]string, 0, len(form))
+	for k := range form {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(callbackURL)
+	for _, k := range keys {
+		for _, v := range form[k] {
+			b.WriteString(k)
+			b.WriteString(v)
+		}
+	}
+	mac := hmac.New(sha1.New, []byte(p.authToken))
+	mac.Write([]byte(b.String()))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
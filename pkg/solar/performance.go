@@ -0,0 +1,278 @@
+// Package solar derives solar plant performance figures from ingested generation and
+// irradiation readings.
+package solar
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"p9e.in/ugcl/models"
+)
+
+// Query selects the plants and period of a performance report
+type Query struct {
+	BusinessVerticalID uuid.UUID
+	SiteIDs            []uuid.UUID // limits the report to these sites; nil for all the business's plants
+	DeviceID           *uuid.UUID  // with ByInverter, limits the report to one inverter
+	From, To           time.Time   // dates, inclusive, in each plant's time zone
+	Monthly            bool        // one row per month rather than per day
+	ByInverter         bool        // one row per inverter rather than per plant
+}
+
+// Row is the performance of one plant or inverter over one day or month
+type Row struct {
+	SiteID           uuid.UUID  `json:"site_id"`
+	SiteName         string     `json:"site_name"`
+	DeviceID         *uuid.UUID `json:"device_id,omitempty"`
+	DeviceName       string     `json:"device_name,omitempty"`
+	Period           string     `json:"period"` // YYYY-MM-DD or YYYY-MM
+	DCCapacityKWp    float64    `json:"dc_capacity_kwp"`
+	ACCapacityKW     float64    `json:"ac_capacity_kw"`
+	EnergyKWh        float64    `json:"energy_kwh"`
+	IrradiationKWhM2 *float64   `json:"irradiation_kwh_m2,omitempty"`
+	models.SolarFigures
+}
+
+// Service computes plant performance
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService creates a solar performance service
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// inverterDay is what one inverter generated on one local day
+type inverterDay struct {
+	DeviceID        uuid.UUID
+	Day             time.Time
+	EnergyKWh       float64 `gorm:"column:energy_kwh"`
+	ProductiveHours float64
+}
+
+// accumulator sums a row's inputs over the days of its period
+type accumulator struct {
+	row        Row
+	inputs     models.SolarInputs
+	irradiated bool
+}
+
+// Performance returns the performance of the business's configured plants matching q,
+// as of now: the current day counts only up to now. Days before a plant was
+// commissioned are left out.
+//
+// Energy is what the plant's inverters reported; a daylight hour counts as productive
+// for an inverter when it reported generation in it. Irradiation is the average over
+// the site's pyranometers, so the performance ratio only covers days they reported.
+func (s *Service) Performance(q Query, now time.Time) ([]Row, error) {
+	query := s.db.Preload("Site").Where("business_vertical_id = ?", q.BusinessVerticalID)
+	if q.SiteIDs != nil {
+		query = query.Where("site_id IN ?", q.SiteIDs)
+	}
+	var plants []models.SolarPlant
+	if err := query.Find(&plants).Error; err != nil {
+		return nil, fmt.Errorf("load plants: %w", err)
+	}
+
+	rows := []Row{}
+	for _, plant := range plants {
+		plantRows, err := s.plantPerformance(plant, q, now)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, plantRows...)
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Period != rows[j].Period {
+			return rows[i].Period < rows[j].Period
+		}
+		if rows[i].SiteName != rows[j].SiteName {
+			return rows[i].SiteName < rows[j].SiteName
+		}
+		return rows[i].DeviceName < rows[j].DeviceName
+	})
+	return rows, nil
+}
+
+func (s *Service) plantPerformance(plant models.SolarPlant, q Query, now time.Time) ([]Row, error) {
+	loc := plant.Location()
+	from := time.Date(q.From.Year(), q.From.Month(), q.From.Day(), 0, 0, 0, 0, loc)
+	end := time.Date(q.To.Year(), q.To.Month(), q.To.Day()+1, 0, 0, 0, 0, loc)
+	if c := plant.CommissionedOn; c != nil {
+		if commissioned := time.Date(c.Year(), c.Month(), c.Day(), 0, 0, 0, 0, loc); commissioned.After(from) {
+			from = commissioned
+		}
+	}
+	if end.After(now) {
+		end = now
+	}
+	if !from.Before(end) {
+		return nil, nil
+	}
+
+	var daily []inverterDay
+	if err := s.db.Raw(`SELECT device_id, (recorded_at AT TIME ZONE ?)::date AS day, SUM(energy_kwh) AS energy_kwh,
+			COUNT(DISTINCT date_trunc('hour', recorded_at AT TIME ZONE ?)) FILTER (
+				WHERE (energy_kwh > 0 OR power_kw > 0)
+				AND EXTRACT(HOUR FROM recorded_at AT TIME ZONE ?) >= ?
+				AND EXTRACT(HOUR FROM recorded_at AT TIME ZONE ?) < ?) AS productive_hours
+		FROM solar_generation_readings
+		WHERE site_id = ? AND recorded_at >= ? AND recorded_at < ?
+		GROUP BY device_id, day`,
+		plant.Timezone, plant.Timezone, plant.Timezone, plant.DaylightStartHour, plant.Timezone, plant.DaylightEndHour,
+		plant.SiteID, from, end).Scan(&daily).Error; err != nil {
+		return nil, fmt.Errorf("sum generation of site %s: %w", plant.SiteID, err)
+	}
+
+	var irradiation []struct {
+		Day         time.Time
+		Irradiation float64
+	}
+	if err := s.db.Raw(`SELECT (recorded_at AT TIME ZONE ?)::date AS day, SUM(value) / COUNT(DISTINCT device_id) AS irradiation
+		FROM sensor_readings
+		WHERE site_id = ? AND metric = 'irradiation' AND recorded_at >= ? AND recorded_at < ?
+		GROUP BY day`, plant.Timezone, plant.SiteID, from, end).Scan(&irradiation).Error; err != nil {
+		return nil, fmt.Errorf("sum irradiation of site %s: %w", plant.SiteID, err)
+	}
+	irradiationByDay := make(map[string]float64, len(irradiation))
+	for _, i := range irradiation {
+		irradiationByDay[i.Day.Format("2006-01-02")] = i.Irradiation
+	}
+
+	// The inverters expected to generate are the approved ones and any that reported
+	var devices []models.SensorDevice
+	if err := s.db.Where("site_id = ? AND device_type = ?", plant.SiteID, models.SensorDeviceSolarInverter).
+		Order("name, device_key").Find(&devices).Error; err != nil {
+		return nil, fmt.Errorf("load inverters of site %s: %w", plant.SiteID, err)
+	}
+	reported := map[uuid.UUID]bool{}
+	byDay := map[string]map[uuid.UUID]inverterDay{}
+	for _, d := range daily {
+		reported[d.DeviceID] = true
+		key := d.Day.Format("2006-01-02")
+		if byDay[key] == nil {
+			byDay[key] = map[uuid.UUID]inverterDay{}
+		}
+		byDay[key][d.DeviceID] = d
+	}
+	var inverters []models.SensorDevice
+	var inverterIDs []uuid.UUID
+	for _, device := range devices {
+		if device.Status == models.SensorDeviceApproved || reported[device.ID] {
+			inverters = append(inverters, device)
+			inverterIDs = append(inverterIDs, device.ID)
+		}
+	}
+
+	siteName := ""
+	if plant.Site != nil {
+		siteName = plant.Site.Name
+	}
+	accumulators := map[string]*accumulator{}
+	var order []string
+	accumulate := func(key, period string, device *models.SensorDevice, dc float64, fn func(*accumulator)) {
+		acc, ok := accumulators[key]
+		if !ok {
+			acc = &accumulator{row: Row{SiteID: plant.SiteID, SiteName: siteName, Period: period}}
+			acc.inputs.DCCapacityKWp = dc
+			acc.inputs.ACCapacityKW = plant.ACCapacityKW * dc / plant.DCCapacityKWp
+			if device != nil {
+				id := device.ID
+				acc.row.DeviceID = &id
+				acc.row.DeviceName = device.Name
+				if acc.row.DeviceName == "" {
+					acc.row.DeviceName = device.DeviceKey
+				}
+			}
+			accumulators[key] = acc
+			order = append(order, key)
+		}
+		fn(acc)
+	}
+
+	for day := from; day.Before(end); day = time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, loc) {
+		dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+		dayEnd := time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, loc)
+		hours := overlapHours(dayStart, dayEnd, from, end)
+		daylight := overlapHours(dayStart.Add(time.Duration(plant.DaylightStartHour)*time.Hour),
+			dayStart.Add(time.Duration(plant.DaylightEndHour)*time.Hour), from, end)
+		dayKey := dayStart.Format("2006-01-02")
+		period := dayKey
+		if q.Monthly {
+			period = dayStart.Format("2006-01")
+		}
+		dayIrradiation, irradiated := irradiationByDay[dayKey]
+
+		if !q.ByInverter {
+			accumulate(period, period, nil, plant.DCCapacityKWp, func(acc *accumulator) {
+				var energy float64
+				for _, inverter := range inverters {
+					d := byDay[dayKey][inverter.ID]
+					energy += d.EnergyKWh
+					acc.inputs.ProductiveHours += math.Min(d.ProductiveHours, daylight)
+					acc.inputs.DaylightHours += daylight
+				}
+				acc.add(energy, hours, dayIrradiation, irradiated)
+			})
+			continue
+		}
+		for i := range inverters {
+			inverter := &inverters[i]
+			if q.DeviceID != nil && inverter.ID != *q.DeviceID {
+				continue
+			}
+			d := byDay[dayKey][inverter.ID]
+			dc := plant.InverterCapacity(inverter.ID, inverterIDs)
+			accumulate(period+"/"+inverter.ID.String(), period, inverter, dc, func(acc *accumulator) {
+				acc.inputs.ProductiveHours += math.Min(d.ProductiveHours, daylight)
+				acc.inputs.DaylightHours += daylight
+				acc.add(d.EnergyKWh, hours, dayIrradiation, irradiated)
+			})
+		}
+	}
+
+	rows := make([]Row, 0, len(order))
+	for _, key := range order {
+		acc := accumulators[key]
+		acc.row.DCCapacityKWp = models.Round(acc.inputs.DCCapacityKWp, 3)
+		acc.row.ACCapacityKW = models.Round(acc.inputs.ACCapacityKW, 3)
+		acc.row.EnergyKWh = models.Round(acc.inputs.EnergyKWh, 3)
+		if acc.irradiated {
+			v := models.Round(acc.inputs.IrradiationKWhM2, 3)
+			acc.row.IrradiationKWhM2 = &v
+		}
+		acc.row.SolarFigures = acc.inputs.Figures()
+		rows = append(rows, acc.row)
+	}
+	return rows, nil
+}
+
+// add adds a day's energy, length and irradiation, if measured
+func (acc *accumulator) add(energy, hours, irradiation float64, irradiated bool) {
+	acc.inputs.EnergyKWh += energy
+	acc.inputs.Hours += hours
+	if irradiated {
+		acc.irradiated = true
+		acc.inputs.IrradiationKWhM2 += irradiation
+		acc.inputs.IrradiatedEnergy += energy
+	}
+}
+
+// overlapHours returns the hours [from, to) and [windowFrom, windowTo) have in common
+func overlapHours(from, to, windowFrom, windowTo time.Time) float64 {
+	if from.Before(windowFrom) {
+		from = windowFrom
+	}
+	if to.After(windowTo) {
+		to = windowTo
+	}
+	if !from.Before(to) {
+		return 0
+	}
+	return to.Sub(from).Hours()
+}
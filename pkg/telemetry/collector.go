@@ -0,0 +1,163 @@
+package telemetry
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"p9e.in/ugcl/models"
+)
+
+const (
+	defaultQueueSize     = 8192
+	defaultBatchSize     = 500
+	defaultFlushInterval = 5 * time.Second
+)
+
+// CollectorStats are counters describing what the collector stored and discarded.
+type CollectorStats struct {
+	Stored     uint64 `json:"stored"`
+	Dropped    uint64 `json:"dropped"`
+	Failed     uint64 `json:"failed"`
+	QueueDepth int    `json:"queue_depth"`
+	QueueSize  int    `json:"queue_size"`
+}
+
+// Collector writes mobile telemetry events to app_telemetry_events in batches.
+// Add never blocks the request path: events that do not fit in the queue are dropped.
+type Collector struct {
+	db            *gorm.DB
+	queue         chan models.AppTelemetryEvent
+	batchSize     int
+	flushInterval time.Duration
+
+	stored  atomic.Uint64
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+
+	stopChan chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewCollector creates a telemetry collector
+func NewCollector(db *gorm.DB, queueSize int, flushInterval time.Duration) *Collector {
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	if flushInterval <= 0 {
+		flushInterval = defaultFlushInterval
+	}
+	return &Collector{
+		db:            db,
+		queue:         make(chan models.AppTelemetryEvent, queueSize),
+		batchSize:     defaultBatchSize,
+		flushInterval: flushInterval,
+		stopChan:      make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+// Start runs the background writer.
+func (c *Collector) Start() {
+	go func() {
+		defer close(c.done)
+		ticker := time.NewTicker(c.flushInterval)
+		defer ticker.Stop()
+
+		batch := make([]models.AppTelemetryEvent, 0, c.batchSize)
+		for {
+			select {
+			case <-c.stopChan:
+				for {
+					select {
+					case event := <-c.queue:
+						batch = append(batch, event)
+						if len(batch) >= c.batchSize {
+							batch = c.write(batch)
+						}
+					default:
+						c.write(batch)
+						return
+					}
+				}
+			case event := <-c.queue:
+				batch = append(batch, event)
+				if len(batch) >= c.batchSize {
+					batch = c.write(batch)
+				}
+			case <-ticker.C:
+				batch = c.write(batch)
+			}
+		}
+	}()
+
+	log.Printf("Mobile telemetry collector started with flush interval: %v", c.flushInterval)
+}
+
+// Stop flushes queued events and stops the writer.
+func (c *Collector) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stopChan)
+		<-c.done
+	})
+}
+
+// Add queues events and returns how many were accepted.
+func (c *Collector) Add(events []models.AppTelemetryEvent) int {
+	accepted := 0
+	for _, event := range events {
+		select {
+		case c.queue <- event:
+			accepted++
+		default:
+			c.dropped.Add(1)
+		}
+	}
+	return accepted
+}
+
+// Stats returns the collector counters.
+func (c *Collector) Stats() CollectorStats {
+	return CollectorStats{
+		Stored:     c.stored.Load(),
+		Dropped:    c.dropped.Load(),
+		Failed:     c.failed.Load(),
+		QueueDepth: len(c.queue),
+		QueueSize:  cap(c.queue),
+	}
+}
+
+func (c *Collector) write(batch []models.AppTelemetryEvent) []models.AppTelemetryEvent {
+	if len(batch) == 0 {
+		return batch
+	}
+	if err := c.db.CreateInBatches(batch, c.batchSize).Error; err != nil {
+		c.failed.Add(uint64(len(batch)))
+		log.Printf("Error writing %d telemetry events: %v", len(batch), err)
+	} else {
+		c.stored.Add(uint64(len(batch)))
+	}
+	return batch[:0]
+}
+
+var (
+	defaultCollectorMu sync.RWMutex
+	defaultCollector   *Collector
+)
+
+// SetDefault installs the process-wide collector.
+func SetDefault(c *Collector) {
+	defaultCollectorMu.Lock()
+	defaultCollector = c
+	defaultCollectorMu.Unlock()
+}
+
+// Default returns the process-wide collector, or nil when telemetry ingestion is disabled.
+func Default() *Collector {
+	defaultCollectorMu.RLock()
+	defer defaultCollectorMu.RUnlock()
+	return defaultCollector
+}
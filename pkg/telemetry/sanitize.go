@@ -0,0 +1,106 @@
+package telemetry
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	maxProperties     = 20
+	maxPropertyKeyLen = 50
+	maxPropertyStrLen = 200
+	maxMessageLen     = 500
+)
+
+var identifierPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.\-]*$`)
+
+// sensitivePropertyKeys are never stored even if the client sends them; telemetry must
+// not be traceable to a person, device or location.
+var sensitivePropertyKeys = []string{
+	"user", "email", "phone", "mobile", "name", "address", "token", "password", "device_id",
+	"imei", "ip", "lat", "lng", "lon", "latitude", "longitude", "location", "aadhaar", "pan",
+}
+
+// AnonymousID derives a stable, non-reversible identifier for a user so distinct users
+// can be counted without storing who they are. The salt comes from TELEMETRY_SALT,
+// falling back to JWT_SECRET.
+func AnonymousID(userID string) string {
+	salt := os.Getenv("TELEMETRY_SALT")
+	if salt == "" {
+		salt = os.Getenv("JWT_SECRET")
+	}
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(userID))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// NormalizeIdentifier lowercases a module or event name and reports whether it is a
+// valid identifier (letters, digits, "_", "." and "-") no longer than maxLen.
+func NormalizeIdentifier(value string, maxLen int) (string, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" || len(value) > maxLen || !identifierPattern.MatchString(value) {
+		return "", false
+	}
+	return value, true
+}
+
+// SanitizeProperties keeps at most maxProperties scalar properties, dropping nested values
+// and any key that could identify a person, device or location.
+func SanitizeProperties(properties map[string]interface{}) map[string]interface{} {
+	if len(properties) == 0 {
+		return nil
+	}
+	cleaned := make(map[string]interface{})
+	for key, value := range properties {
+		if len(cleaned) >= maxProperties {
+			break
+		}
+		key, ok := NormalizeIdentifier(key, maxPropertyKeyLen)
+		if !ok || isSensitiveKey(key) {
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			cleaned[key] = Truncate(v, maxPropertyStrLen)
+		case bool, float64, int, int64:
+			cleaned[key] = v
+		}
+	}
+	if len(cleaned) == 0 {
+		return nil
+	}
+	return cleaned
+}
+
+// Truncate shortens s to at most n bytes without splitting a UTF-8 character.
+func Truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !isRuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// TruncateMessage bounds a breadcrumb or crash message.
+func TruncateMessage(s string) string {
+	return Truncate(strings.TrimSpace(s), maxMessageLen)
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+func isSensitiveKey(key string) bool {
+	for _, sensitive := range sensitivePropertyKeys {
+		if key == sensitive || strings.HasPrefix(key, sensitive+"_") || strings.HasSuffix(key, "_"+sensitive) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package telemetry
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizePropertiesDropsIdentifyingKeys(t *testing.T) {
+	got := SanitizeProperties(map[string]interface{}{
+		"screen":      "task_list",
+		"user_email":  "someone@example.com",
+		"latitude":    17.38,
+		"device_id":   "abc",
+		"offline":     true,
+		"items":       float64(3),
+		"nested":      map[string]interface{}{"a": 1},
+		"Bad Key!":    "x",
+		"description": strings.Repeat("x", 300),
+	})
+
+	for _, key := range []string{"user_email", "latitude", "device_id", "nested", "Bad Key!"} {
+		if _, ok := got[key]; ok {
+			t.Errorf("expected %q to be dropped", key)
+		}
+	}
+	for _, key := range []string{"screen", "offline", "items"} {
+		if _, ok := got[key]; !ok {
+			t.Errorf("expected %q to be kept", key)
+		}
+	}
+	if s, _ := got["description"].(string); len(s) != maxPropertyStrLen {
+		t.Errorf("description length = %d, want %d", len(s), maxPropertyStrLen)
+	}
+}
+
+func TestAnonymousIDIsStableAndOpaque(t *testing.T) {
+	t.Setenv("TELEMETRY_SALT", "salt")
+	a, b := AnonymousID("user-1"), AnonymousID("user-1")
+	if a != b || len(a) != 32 {
+		t.Fatalf("AnonymousID not stable: %q %q", a, b)
+	}
+	if strings.Contains(a, "user-1") || a == AnonymousID("user-2") {
+		t.Errorf("AnonymousID leaks or collides: %q", a)
+	}
+}
+
+func TestTruncateKeepsRunesWhole(t *testing.T) {
+	if got := Truncate("héllo", 2); got != "h" {
+		t.Errorf("Truncate = %q, want %q", got, "h")
+	}
+}
@@ -0,0 +1,250 @@
+// Package textextract pulls searchable text out of stored files: the text layer of PDFs,
+// and OCR of scanned PDFs and images. It drives the poppler (pdftotext, pdftoppm) and
+// tesseract command-line tools; a tool that is not installed disables what needs it.
+package textextract
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Extraction methods
+const (
+	MethodPDFText = "pdf_text" // the PDF's own text layer
+	MethodOCR     = "ocr"      // recognised from page images
+)
+
+// minCharsPerPage is how much text a PDF page's text layer must average before the PDF
+// is taken as born-digital; scans usually carry none, or only a stamp or page number
+const minCharsPerPage = 40
+
+var (
+	// ErrUnsupported means the file is neither a PDF nor an image
+	ErrUnsupported = errors.New("file type not supported for text extraction")
+	// ErrToolMissing means a command-line tool needed for this file is not installed
+	ErrToolMissing = errors.New("text extraction tool not installed")
+)
+
+// Result is the text found in one file
+type Result struct {
+	Text   string
+	Method string
+	Pages  int // PDF pages read; 0 for images
+}
+
+// Extractor runs the extraction tools
+type Extractor struct {
+	PDFToText string // paths of the tools, empty when not installed
+	PDFToPPM  string
+	Tesseract string
+
+	Languages   string // tesseract languages, e.g. "eng+hin+kan"
+	MaxOCRPages int    // pages of a scanned PDF to OCR
+}
+
+// NewFromEnv locates the tools on PATH, or at PDFTOTEXT_PATH, PDFTOPPM_PATH and
+// TESSERACT_PATH, and reads OCR_LANGUAGES (default "eng") and OCR_MAX_PAGES (default 20).
+func NewFromEnv() *Extractor {
+	e := &Extractor{
+		PDFToText:   lookTool("PDFTOTEXT_PATH", "pdftotext"),
+		PDFToPPM:    lookTool("PDFTOPPM_PATH", "pdftoppm"),
+		Tesseract:   lookTool("TESSERACT_PATH", "tesseract"),
+		Languages:   strings.TrimSpace(os.Getenv("OCR_LANGUAGES")),
+		MaxOCRPages: 20,
+	}
+	if e.Languages == "" {
+		e.Languages = "eng"
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv("OCR_MAX_PAGES"))); err == nil && n > 0 {
+		e.MaxOCRPages = n
+	}
+	return e
+}
+
+func lookTool(env, name string) string {
+	if path := strings.TrimSpace(os.Getenv(env)); path != "" {
+		name = path
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+// CanOCR reports whether scanned files can be read
+func (e *Extractor) CanOCR() bool {
+	return e.Tesseract != ""
+}
+
+// Supports reports whether the file is a PDF or an image
+func Supports(fileName, mimeType string) bool {
+	return IsPDF(fileName, mimeType) || IsImage(fileName, mimeType)
+}
+
+// IsPDF reports whether the file is a PDF
+func IsPDF(fileName, mimeType string) bool {
+	return strings.EqualFold(strings.TrimSpace(mimeType), "application/pdf") ||
+		strings.EqualFold(filepath.Ext(fileName), ".pdf")
+}
+
+// IsImage reports whether the file is an image tesseract can read
+func IsImage(fileName, mimeType string) bool {
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+	switch mimeType {
+	case "image/png", "image/jpeg", "image/jpg", "image/tiff", "image/bmp", "image/webp", "image/gif":
+		return true
+	}
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".png", ".jpg", ".jpeg", ".tif", ".tiff", ".bmp", ".webp", ".gif":
+		return true
+	}
+	return false
+}
+
+// Extract returns the text of a PDF or image. PDFs are read from their text layer, and
+// OCRed page by page when it is too thin to be anything but a scan.
+func (e *Extractor) Extract(ctx context.Context, data []byte, fileName, mimeType string) (*Result, error) {
+	dir, err := os.MkdirTemp("", "textextract-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	switch {
+	case IsPDF(fileName, mimeType):
+		input := filepath.Join(dir, "input.pdf")
+		if err := os.WriteFile(input, data, 0600); err != nil {
+			return nil, err
+		}
+		return e.extractPDF(ctx, dir, input)
+	case IsImage(fileName, mimeType):
+		if !e.CanOCR() {
+			return nil, fmt.Errorf("%w: tesseract", ErrToolMissing)
+		}
+		input := filepath.Join(dir, "input"+strings.ToLower(filepath.Ext(fileName)))
+		if err := os.WriteFile(input, data, 0600); err != nil {
+			return nil, err
+		}
+		text, err := e.ocr(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		return &Result{Text: text, Method: MethodOCR}, nil
+	}
+	return nil, ErrUnsupported
+}
+
+func (e *Extractor) extractPDF(ctx context.Context, dir, input string) (*Result, error) {
+	if e.PDFToText == "" {
+		return nil, fmt.Errorf("%w: pdftotext", ErrToolMissing)
+	}
+	out, err := run(ctx, e.PDFToText, "-enc", "UTF-8", input, "-")
+	if err != nil {
+		return nil, err
+	}
+	// pdftotext ends every page with a form feed
+	pages := strings.Count(out, "\f")
+	if pages == 0 {
+		pages = 1
+	}
+	text := Normalize(out)
+	if !NeedsOCR(text, pages) {
+		return &Result{Text: text, Method: MethodPDFText, Pages: pages}, nil
+	}
+	if !e.CanOCR() || e.PDFToPPM == "" {
+		if text != "" {
+			return &Result{Text: text, Method: MethodPDFText, Pages: pages}, nil
+		}
+		return nil, fmt.Errorf("%w: scanned PDF needs pdftoppm and tesseract", ErrToolMissing)
+	}
+
+	// Render the pages as greyscale images at a resolution tesseract reads well
+	prefix := filepath.Join(dir, "page")
+	if _, err := run(ctx, e.PDFToPPM, "-r", "300", "-gray", "-png", "-l", strconv.Itoa(e.MaxOCRPages), input, prefix); err != nil {
+		return nil, err
+	}
+	images, err := filepath.Glob(prefix + "*.png")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(images)
+
+	var b strings.Builder
+	b.WriteString(text)
+	for _, image := range images {
+		pageText, err := e.ocr(ctx, image)
+		if err != nil {
+			return nil, err
+		}
+		if pageText != "" {
+			if b.Len() > 0 {
+				b.WriteString("\n")
+			}
+			b.WriteString(pageText)
+		}
+	}
+	return &Result{Text: b.String(), Method: MethodOCR, Pages: len(images)}, nil
+}
+
+func (e *Extractor) ocr(ctx context.Context, image string) (string, error) {
+	out, err := run(ctx, e.Tesseract, image, "stdout", "-l", e.Languages)
+	if err != nil {
+		return "", err
+	}
+	return Normalize(out), nil
+}
+
+func run(ctx context.Context, tool string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, tool, args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) > 200 {
+			msg = msg[:200]
+		}
+		return "", fmt.Errorf("%s failed: %v %s", filepath.Base(tool), err, msg)
+	}
+	return stdout.String(), nil
+}
+
+// NeedsOCR reports whether a PDF's text layer is too thin for its page count to be
+// anything but a scan
+func NeedsOCR(text string, pages int) bool {
+	if pages < 1 {
+		pages = 1
+	}
+	chars := 0
+	for _, r := range text {
+		if !unicode.IsSpace(r) {
+			chars++
+		}
+	}
+	return chars < minCharsPerPage*pages
+}
+
+// Normalize drops control characters and collapses runs of blank space, keeping line
+// breaks between lines of text
+func Normalize(text string) string {
+	lines := strings.FieldsFunc(text, func(r rune) bool { return r == '\n' || r == '\r' || r == '\f' })
+	kept := lines[:0]
+	for _, line := range lines {
+		line = strings.Join(strings.FieldsFunc(line, func(r rune) bool {
+			return unicode.IsSpace(r) || unicode.IsControl(r) || r == unicode.ReplacementChar
+		}), " ")
+		if line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
@@ -0,0 +1,67 @@
+package textextract
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNeedsOCR(t *testing.T) {
+	page := strings.Repeat("Work order 4471 replace valve at chamber 12. ", 3)
+	cases := []struct {
+		name  string
+		text  string
+		pages int
+		want  bool
+	}{
+		{"empty scan", "", 3, true},
+		{"page numbers only", "1\n2\n3", 3, true},
+		{"born digital", page + page + page, 3, false},
+		{"one good page of three", page, 3, true},
+		{"unknown page count", page, 0, false},
+	}
+	for _, c := range cases {
+		if got := NeedsOCR(c.text, c.pages); got != c.want {
+			t.Errorf("%s: NeedsOCR = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	in := "  Work   order\t4471 \r\n\n\f\x00 Valve replaced \n\n"
+	if got, want := Normalize(in), "Work order 4471\nValve replaced"; got != want {
+		t.Errorf("Normalize = %q, want %q", got, want)
+	}
+}
+
+func TestSupports(t *testing.T) {
+	for _, c := range []struct {
+		name, mime string
+		want       bool
+	}{
+		{"scan.PDF", "", true},
+		{"upload", "application/pdf", true},
+		{"site.jpeg", "application/octet-stream", true},
+		{"photo", "image/png", true},
+		{"notes.txt", "text/plain", false},
+		{"sheet.xlsx", "", false},
+	} {
+		if got := Supports(c.name, c.mime); got != c.want {
+			t.Errorf("Supports(%q, %q) = %v, want %v", c.name, c.mime, got, c.want)
+		}
+	}
+}
+
+func TestExtractWithoutTools(t *testing.T) {
+	e := &Extractor{Languages: "eng", MaxOCRPages: 1}
+	if _, err := e.Extract(context.Background(), []byte("%PDF-1.4"), "a.pdf", "application/pdf"); !errors.Is(err, ErrToolMissing) {
+		t.Errorf("PDF without pdftotext: err = %v, want ErrToolMissing", err)
+	}
+	if _, err := e.Extract(context.Background(), []byte{0x89, 'P', 'N', 'G'}, "a.png", "image/png"); !errors.Is(err, ErrToolMissing) {
+		t.Errorf("image without tesseract: err = %v, want ErrToolMissing", err)
+	}
+	if _, err := e.Extract(context.Background(), []byte("x"), "a.xlsx", ""); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("spreadsheet: err = %v, want ErrUnsupported", err)
+	}
+}
@@ -0,0 +1,100 @@
+// Package transcribe converts voice notes to text through a pluggable speech-to-text provider.
+package transcribe
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SupportedLanguages are the ISO 639-1 codes voice notes may be transcribed in:
+// Kannada, Hindi and English.
+var SupportedLanguages = []string{"kn", "hi", "en"}
+
+// Result is the text recognised in one audio file.
+type Result struct {
+	Text     string
+	Language string // detected or requested ISO 639-1 code
+}
+
+// Provider is a speech-to-text backend. language is one of SupportedLanguages, or
+// empty to let the provider detect it.
+type Provider interface {
+	Name() string
+	Transcribe(ctx context.Context, audio io.Reader, fileName, mimeType, language string) (*Result, error)
+}
+
+// Factory builds a provider from its environment configuration.
+type Factory func() (Provider, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = map[string]Factory{}
+)
+
+// RegisterProvider makes a provider available under name. Providers register from init().
+func RegisterProvider(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[strings.ToLower(name)] = factory
+}
+
+// Providers lists the registered provider names.
+func Providers() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewProviderFromEnv builds the provider named by SPEECH_TO_TEXT_PROVIDER (default "whisper").
+func NewProviderFromEnv() (Provider, error) {
+	name := strings.ToLower(strings.TrimSpace(os.Getenv("SPEECH_TO_TEXT_PROVIDER")))
+	if name == "" {
+		name = "whisper"
+	}
+
+	factoriesMu.RLock()
+	factory, ok := factories[name]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown speech-to-text provider %q (available: %s)", name, strings.Join(Providers(), ", "))
+	}
+	return factory()
+}
+
+// NormalizeLanguage maps a language hint such as "kn-IN", "Hindi" or "EN" to a
+// supported ISO 639-1 code, or returns "" when the hint is empty or unsupported.
+func NormalizeLanguage(hint string) string {
+	hint = strings.ToLower(strings.TrimSpace(hint))
+	switch hint {
+	case "kannada":
+		return "kn"
+	case "hindi":
+		return "hi"
+	case "english":
+		return "en"
+	}
+	if i := strings.IndexAny(hint, "-_"); i > 0 {
+		hint = hint[:i]
+	}
+	for _, lang := range SupportedLanguages {
+		if hint == lang {
+			return lang
+		}
+	}
+	return ""
+}
+
+// IsAudio reports whether a MIME type is an audio format worth transcribing.
+func IsAudio(mimeType string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(mimeType)), "audio/")
+}
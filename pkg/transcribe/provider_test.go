@@ -0,0 +1,20 @@
+package transcribe
+
+import "testing"
+
+func TestNormalizeLanguage(t *testing.T) {
+	cases := map[string]string{
+		"kn":      "kn",
+		"kn-IN":   "kn",
+		"Hindi":   "hi",
+		"EN_us":   "en",
+		"english": "en",
+		"ta":      "",
+		"":        "",
+	}
+	for hint, want := range cases {
+		if got := NormalizeLanguage(hint); got != want {
+			t.Errorf("NormalizeLanguage(%q) = %q, want %q", hint, got, want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package transcribe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultWhisperURL = "https://api.openai.com/v1/audio/transcriptions"
+
+func init() {
+	RegisterProvider("whisper", newWhisperProvider)
+}
+
+// whisperProvider calls an OpenAI-compatible /audio/transcriptions endpoint, which
+// covers the hosted Whisper API as well as self-hosted Whisper servers.
+type whisperProvider struct {
+	url    string
+	apiKey string
+	model  string
+	client *http.Client
+}
+
+func newWhisperProvider() (Provider, error) {
+	url := strings.TrimSpace(os.Getenv("SPEECH_TO_TEXT_API_URL"))
+	if url == "" {
+		url = defaultWhisperURL
+	}
+	apiKey := strings.TrimSpace(os.Getenv("SPEECH_TO_TEXT_API_KEY"))
+	if apiKey == "" && url == defaultWhisperURL {
+		return nil, errors.New("SPEECH_TO_TEXT_API_KEY is required for the hosted whisper API")
+	}
+	model := strings.TrimSpace(os.Getenv("SPEECH_TO_TEXT_MODEL"))
+	if model == "" {
+		model = "whisper-1"
+	}
+	return &whisperProvider{
+		url:    url,
+		apiKey: apiKey,
+		model:  model,
+		client: &http.Client{Timeout: 2 * time.Minute},
+	}, nil
+}
+
+func (p *whisperProvider) Name() string { return "whisper" }
+
+func (p *whisperProvider) Transcribe(ctx context.Context, audio io.Reader, fileName, mimeType, language string) (*Result, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", fileName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, audio); err != nil {
+		return nil, fmt.Errorf("failed to read audio: %w", err)
+	}
+	_ = form.WriteField("model", p.model)
+	_ = form.WriteField("response_format", "verbose_json")
+	if language != "" {
+		_ = form.WriteField("language", language)
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBytes, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("whisper api error (%d): %s", resp.StatusCode, string(respBytes))
+	}
+
+	var payload struct {
+		Text     string `json:"text"`
+		Language string `json:"language"`
+	}
+	if err := json.Unmarshal(respBytes, &payload); err != nil {
+		return nil, err
+	}
+
+	// verbose_json reports the detected language by name ("kannada"), not by code.
+	detected := NormalizeLanguage(payload.Language)
+	if detected == "" {
+		detected = language
+	}
+	return &Result{Text: strings.TrimSpace(payload.Text), Language: detected}, nil
+}
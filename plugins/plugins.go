@@ -0,0 +1,6 @@
+// Package plugins links vertical-specific hook plugins into the binary.
+//
+// Each plugin lives in its own sub-package and calls hooks.RegisterPlugin from
+// init(). Add a blank import for the sub-package below to enable it; main loads
+// every registered plugin at startup via hooks.LoadPlugins.
+package plugins
@@ -20,6 +20,9 @@ func RegisterABACRoutes(api *mux.Router) {
 	// Policy statistics
 	policyRouter.Handle("/statistics", middleware.RequirePermission("manage_policies")(http.HandlerFunc(handlers.GetPolicyStatistics))).Methods("GET")
 
+	// Dry-run validation of a new or updated policy before saving
+	policyRouter.Handle("/validate", middleware.RequirePermission("manage_policies")(http.HandlerFunc(handlers.ValidatePolicy))).Methods("POST")
+
 	// Policy evaluation endpoint (any authenticated user can test policies)
 	policyRouter.Handle("/evaluate", http.HandlerFunc(handlers.EvaluatePolicyRequest)).Methods("POST")
 
@@ -45,6 +48,13 @@ func RegisterABACRoutes(api *mux.Router) {
 	policyRouter.Handle("/{id}/versions", middleware.RequirePermission("manage_policies")(http.HandlerFunc(handlers.GetPolicyVersions))).Methods("GET")
 	policyRouter.Handle("/{id}/changelog", middleware.RequirePermission("manage_policies")(http.HandlerFunc(handlers.GetPolicyChangeLogs))).Methods("GET")
 
+	// Policy evaluation audit log (RBAC and ABAC decisions)
+	evaluationRouter := api.PathPrefix("/policy-evaluations").Subrouter()
+	evaluationRouter.Handle("", middleware.RequirePermission("view_policy_evaluations")(http.HandlerFunc(handlers.ListPolicyEvaluationLog))).Methods("GET")
+	evaluationRouter.Handle("/export", middleware.RequirePermission("view_policy_evaluations")(http.HandlerFunc(handlers.ExportPolicyEvaluationLog))).Methods("GET")
+	evaluationRouter.Handle("/settings", middleware.RequirePermission("view_policy_evaluations")(http.HandlerFunc(handlers.GetPolicyEvaluationLogSettings))).Methods("GET")
+	evaluationRouter.Handle("/settings", middleware.RequirePermission("manage_policies")(http.HandlerFunc(handlers.UpdatePolicyEvaluationLogSettings))).Methods("PUT")
+
 	// Attribute Management Routes
 	attributeRouter := api.PathPrefix("/attributes").Subrouter()
 
@@ -52,15 +62,21 @@ func RegisterABACRoutes(api *mux.Router) {
 	attributeRouter.Handle("", middleware.RequirePermission("manage_attributes")(http.HandlerFunc(handlers.ListAttributes))).Methods("GET")
 	attributeRouter.Handle("", middleware.RequirePermission("manage_attributes")(http.HandlerFunc(handlers.CreateAttribute))).Methods("POST")
 
+	// Attribute resolvers consulted during policy evaluation
+	attributeRouter.Handle("/resolvers", middleware.RequirePermission("manage_attributes")(http.HandlerFunc(handlers.ListAttributeResolvers))).Methods("GET")
+
 	// Individual attribute operations
+	attributeRouter.Handle("/{id}", middleware.RequirePermission("manage_attributes")(http.HandlerFunc(handlers.GetAttribute))).Methods("GET")
 	attributeRouter.Handle("/{id}", middleware.RequirePermission("manage_attributes")(http.HandlerFunc(handlers.UpdateAttribute))).Methods("PUT")
 	attributeRouter.Handle("/{id}", middleware.RequirePermission("manage_attributes")(http.HandlerFunc(handlers.DeleteAttribute))).Methods("DELETE")
+	attributeRouter.Handle("/{id}/values", middleware.RequirePermission("manage_attributes")(http.HandlerFunc(handlers.ListAttributeValues))).Methods("GET")
 
 	// User Attribute Management
 	userAttrRouter := api.PathPrefix("/users").Subrouter()
 
 	// Get user attributes
 	userAttrRouter.Handle("/{user_id}/attributes", http.HandlerFunc(handlers.GetUserAttributes)).Methods("GET")
+	userAttrRouter.Handle("/{user_id}/attributes/effective", middleware.RequirePermission("manage_user_attributes")(http.HandlerFunc(handlers.GetEffectiveUserAttributes))).Methods("GET")
 
 	// Assign/remove user attributes
 	userAttrRouter.Handle("/{user_id}/attributes", middleware.RequirePermission("manage_user_attributes")(http.HandlerFunc(handlers.AssignUserAttribute))).Methods("POST")
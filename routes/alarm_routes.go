@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/handlers/alarms"
+	"p9e.in/ugcl/middleware"
+)
+
+// registerAlarmRoutes registers telemetry alarm rules and the alarm lifecycle
+func registerAlarmRoutes(business *mux.Router) {
+	alarmHandler := alarms.NewHandler()
+	read := middleware.RequireBusinessPermission("alarm:read")
+	respond := middleware.RequireBusinessPermission("alarm:respond")
+	manage := middleware.RequireBusinessPermission("alarm:manage")
+	group := business.PathPrefix("/alarms").Subrouter()
+
+	// Rules: metric, comparator, threshold and duration per device type, with routing
+	group.Handle("/rules", read(http.HandlerFunc(alarmHandler.ListRules))).Methods(http.MethodGet)
+	group.Handle("/rules", manage(http.HandlerFunc(alarmHandler.CreateRule))).Methods(http.MethodPost)
+	group.Handle("/rules/{id}", manage(http.HandlerFunc(alarmHandler.UpdateRule))).Methods(http.MethodPut)
+
+	// Alarms: raised -> acknowledged -> resolved, with assignment
+	group.Handle("", read(http.HandlerFunc(alarmHandler.ListAlarms))).Methods(http.MethodGet)
+	group.Handle("/{id}", read(http.HandlerFunc(alarmHandler.GetAlarm))).Methods(http.MethodGet)
+	group.Handle("/{id}/acknowledge", respond(http.HandlerFunc(alarmHandler.AcknowledgeAlarm))).Methods(http.MethodPost)
+	group.Handle("/{id}/assign", respond(http.HandlerFunc(alarmHandler.AssignAlarm))).Methods(http.MethodPost)
+	group.Handle("/{id}/resolve", respond(http.HandlerFunc(alarmHandler.ResolveAlarm))).Methods(http.MethodPost)
+}
@@ -0,0 +1,35 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/handlers/announcements"
+	"p9e.in/ugcl/middleware"
+)
+
+// RegisterAnnouncementRoutes registers the recipient-facing announcement endpoints
+func RegisterAnnouncementRoutes(api *mux.Router) {
+	announcementHandler := announcements.NewHandler()
+
+	// Live announcements addressed to the current user (?pending=true)
+	api.HandleFunc("/announcements", announcementHandler.ListMyAnnouncements).Methods(http.MethodGet)
+	api.HandleFunc("/announcements/{id}/seen", announcementHandler.MarkAnnouncementSeen).Methods(http.MethodPost)
+	api.HandleFunc("/announcements/{id}/accept", announcementHandler.AcceptAnnouncement).Methods(http.MethodPost)
+}
+
+// registerAnnouncementAdminRoutes registers business-scoped announcement management and receipt tracking
+func registerAnnouncementAdminRoutes(business *mux.Router) {
+	announcementHandler := announcements.NewHandler()
+	read := middleware.RequireBusinessPermission("announcement:read")
+	manage := middleware.RequireBusinessPermission("announcement:manage")
+	group := business.PathPrefix("/announcements").Subrouter()
+
+	group.Handle("", manage(http.HandlerFunc(announcementHandler.CreateAnnouncement))).Methods(http.MethodPost)
+	group.Handle("", read(http.HandlerFunc(announcementHandler.ListAnnouncements))).Methods(http.MethodGet)
+
+	// Who has seen and accepted it (?status=unseen|seen|accepted|pending)
+	group.Handle("/{id}", read(http.HandlerFunc(announcementHandler.GetAnnouncementDashboard))).Methods(http.MethodGet)
+	group.Handle("/{id}", manage(http.HandlerFunc(announcementHandler.UpdateAnnouncement))).Methods(http.MethodPut)
+	group.Handle("/{id}/withdraw", manage(http.HandlerFunc(announcementHandler.WithdrawAnnouncement))).Methods(http.MethodPost)
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/handlers"
+)
+
+// RegisterApprovalDelegationRoutes registers the approval delegation endpoints. Users manage
+// their own delegations, so the routes only need an authenticated user.
+func RegisterApprovalDelegationRoutes(api *mux.Router) {
+	api.HandleFunc("/approval-delegations", handlers.CreateApprovalDelegation).Methods(http.MethodPost)
+	api.HandleFunc("/approval-delegations", handlers.ListMyApprovalDelegations).Methods(http.MethodGet)
+	api.HandleFunc("/approval-delegations/{id}/revoke", handlers.RevokeApprovalDelegation).Methods(http.MethodPost)
+}
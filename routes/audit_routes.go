@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/handlers"
+	"p9e.in/ugcl/middleware"
+)
+
+// RegisterAuditRoutes registers the evidence bundle exports used by auditors
+func RegisterAuditRoutes(api *mux.Router) {
+	evidenceHandler := handlers.NewEvidenceBundleHandler()
+
+	// Record counts per source (?from=&to=&scopes=finance,purchases,workflows,audit,documents&business_vertical_id=)
+	api.Handle("/audit/evidence-bundle/preview", middleware.RequirePermission("audit:export")(
+		http.HandlerFunc(evidenceHandler.PreviewEvidenceBundle))).Methods("GET")
+
+	// ZIP of records, linked documents and a SHA-256 manifest (same query, plus include_documents=false)
+	api.Handle("/audit/evidence-bundle", middleware.RequirePermission("audit:export")(
+		http.HandlerFunc(evidenceHandler.ExportEvidenceBundle))).Methods("GET")
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/handlers"
+	"p9e.in/ugcl/middleware"
+)
+
+// RegisterBillingRoutes registers usage metering and chargeback report routes
+func RegisterBillingRoutes(admin *mux.Router) {
+	billingHandler := handlers.NewUsageBillingHandler()
+
+	// Raw monthly usage aggregates per business vertical
+	admin.Handle("/billing/usage", middleware.RequirePermission("view_billing_reports")(
+		http.HandlerFunc(billingHandler.GetUsageAggregates))).Methods("GET")
+
+	// Flush buffered counters and recompute derived metrics for a period
+	admin.Handle("/billing/usage/refresh", middleware.RequirePermission("manage_billing")(
+		http.HandlerFunc(billingHandler.RefreshUsageAggregates))).Methods("POST")
+
+	// Priced chargeback report (?period=YYYY-MM&format=csv)
+	admin.Handle("/billing/report", middleware.RequirePermission("view_billing_reports")(
+		http.HandlerFunc(billingHandler.GetBillingReport))).Methods("GET")
+}
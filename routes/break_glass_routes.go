@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/handlers"
+	"p9e.in/ugcl/middleware"
+)
+
+// RegisterBreakGlassRoutes registers self-service break-glass activation. Review and
+// revocation by super admins live under /admin/break-glass.
+func RegisterBreakGlassRoutes(api *mux.Router) {
+	api.Handle("/break-glass", middleware.RequirePermission("break_glass:request")(
+		http.HandlerFunc(handlers.ActivateBreakGlass))).Methods(http.MethodPost)
+	api.HandleFunc("/break-glass", handlers.ListMyBreakGlassGrants).Methods(http.MethodGet)
+	api.HandleFunc("/break-glass/{id}/end", handlers.EndBreakGlassGrant).Methods(http.MethodPost)
+}
@@ -19,6 +19,8 @@ func RegisterBusinessRoutes(r *mux.Router) {
 	admin := r.PathPrefix("/api/v1/admin").Subrouter()
 	admin.Use(middleware.SecurityMiddleware)
 	admin.Use(middleware.JWTMiddleware)
+	admin.Use(middleware.ReadOnlyRoleMiddleware)
+	admin.Use(middleware.DataScopeMiddleware)
 
 	registerGlobalAdminRoutes(admin)
 
@@ -28,6 +30,8 @@ func RegisterBusinessRoutes(r *mux.Router) {
 	api := r.PathPrefix("/api/v1").Subrouter()
 	api.Use(middleware.SecurityMiddleware)
 	api.Use(middleware.JWTMiddleware)
+	api.Use(middleware.ReadOnlyRoleMiddleware)
+	api.Use(middleware.DataScopeMiddleware)
 
 	api.HandleFunc("/my-businesses", biz.GetUserBusinessAccess).Methods("GET")
 	api.HandleFunc("/modules", masters.GetModules).Methods("GET")
@@ -39,12 +43,19 @@ func RegisterBusinessRoutes(r *mux.Router) {
 	api.HandleFunc("/users/{id}/assignable-roles", handlers.GetAssignableRoles).Methods("GET")
 	api.HandleFunc("/business-verticals/{id}/roles", handlers.GetVerticalRoles).Methods("GET")
 
+	// Currencies and exchange rates
+	api.HandleFunc("/currencies", handlers.ListCurrencies).Methods("GET")
+	api.HandleFunc("/currencies/convert", handlers.ConvertCurrency).Methods("GET")
+	api.HandleFunc("/exchange-rates", handlers.ListExchangeRates).Methods("GET")
+
 	// =====================================================
 	// Business-Specific Routes
 	// =====================================================
 	business := r.PathPrefix("/api/v1/business/{businessCode}").Subrouter()
 	business.Use(middleware.SecurityMiddleware)
 	business.Use(middleware.JWTMiddleware)
+	business.Use(middleware.ReadOnlyRoleMiddleware)
+	business.Use(middleware.DataScopeMiddleware)
 	business.Use(middleware.RequireBusinessAccess())
 
 	registerBusinessRoleRoutes(business)
@@ -54,8 +65,22 @@ func RegisterBusinessRoutes(r *mux.Router) {
 	registerBusinessIntegrationRoutes(business)
 	registerBusinessAttendanceRoutes(business)
 	registerBusinessFinanceRoutes(business)
+	registerBusinessLedgerRoutes(business)
+	registerBusinessApprovalLimitRoutes(business)
+	registerBusinessSubcontractRoutes(business)
+	registerBusinessProcurementRoutes(business)
+	registerBusinessAssetRoutes(business)
+	registerBusinessExpenseRoutes(business)
+	registerBusinessPayrollRoutes(business)
+	registerBusinessLeaveRoutes(business)
+	registerBusinessEmployeeRoutes(business)
 	registerSolarRoutes(business)
 	registerWaterRoutes(business)
+	registerSensorDeviceRoutes(business)
+	registerEmergencyBroadcastRoutes(business)
+	registerAlarmRoutes(business)
+	registerAnnouncementAdminRoutes(business)
+	registerReminderRoutes(business)
 }
 
 // registerGlobalAdminRoutes registers admin-level business management routes
@@ -70,6 +95,14 @@ func registerGlobalAdminRoutes(admin *mux.Router) {
 	admin.Handle("/businesses/{id}", middleware.RequirePermission("manage_businesses")(
 		http.HandlerFunc(biz.DeleteBusinessVertical))).Methods("DELETE")
 
+	// Currencies and exchange rates
+	admin.Handle("/currencies", middleware.RequirePermission("admin_all")(
+		http.HandlerFunc(handlers.SaveCurrency))).Methods("POST")
+	admin.Handle("/exchange-rates", middleware.RequirePermission("admin_all")(
+		http.HandlerFunc(handlers.SetExchangeRate))).Methods("POST")
+	admin.Handle("/exchange-rates/sync", middleware.RequirePermission("admin_all")(
+		http.HandlerFunc(handlers.SyncExchangeRates))).Methods("POST")
+
 	// Super admin dashboard
 	admin.Handle("/dashboard", middleware.RequirePermission("admin_all")(
 		http.HandlerFunc(biz.GetSuperAdminDashboard))).Methods("GET")
@@ -94,12 +127,30 @@ func registerGlobalAdminRoutes(admin *mux.Router) {
 		http.HandlerFunc(handlers.ToggleFormStatus))).Methods("PATCH")
 	admin.Handle("/app-forms/{formCode}/verticals", middleware.RequirePermission("admin_all")(
 		http.HandlerFunc(handlers.UpdateFormVerticalAccess))).Methods("POST")
+	admin.Handle("/app-forms/{formCode}/schema-versions", middleware.RequirePermission("admin_all")(
+		http.HandlerFunc(handlers.ListFormSchemaVersions))).Methods("GET")
+	admin.Handle("/app-forms/{formCode}/template", middleware.RequirePermission("admin_all")(
+		http.HandlerFunc(handlers.ExportFormTemplate))).Methods("GET")
+	admin.Handle("/app-forms/{formCode}/clone", middleware.RequirePermission("admin_all")(
+		http.HandlerFunc(handlers.CloneForm))).Methods("POST")
 	// General form routes LAST
 	admin.Handle("/app-forms/{formCode}", middleware.RequirePermission("admin_all")(
 		http.HandlerFunc(handlers.UpdateForm))).Methods("PUT")
 	admin.Handle("/app-forms/{formCode}", middleware.RequirePermission("admin_all")(
 		http.HandlerFunc(handlers.DeleteForm))).Methods("DELETE")
 
+	// Form template library
+	admin.Handle("/form-templates", middleware.RequirePermission("admin_all")(
+		http.HandlerFunc(handlers.ListFormTemplates))).Methods("GET")
+	admin.Handle("/form-templates", middleware.RequirePermission("admin_all")(
+		http.HandlerFunc(handlers.CreateFormTemplate))).Methods("POST")
+	admin.Handle("/form-templates/{templateId}/instantiate", middleware.RequirePermission("admin_all")(
+		http.HandlerFunc(handlers.InstantiateFormTemplate))).Methods("POST")
+	admin.Handle("/form-templates/{templateId}", middleware.RequirePermission("admin_all")(
+		http.HandlerFunc(handlers.GetFormTemplate))).Methods("GET")
+	admin.Handle("/form-templates/{templateId}", middleware.RequirePermission("admin_all")(
+		http.HandlerFunc(handlers.DeleteFormTemplate))).Methods("DELETE")
+
 	// Workflow management
 	admin.Handle("/workflows", middleware.RequirePermission("super_admin")(
 		http.HandlerFunc(handlers.GetAllWorkflows))).Methods("GET")
@@ -109,6 +160,10 @@ func registerGlobalAdminRoutes(admin *mux.Router) {
 		http.HandlerFunc(handlers.UpdateWorkflowDefinition))).Methods("PUT")
 	admin.Handle("/workflows/{workflowId}", middleware.RequirePermission("admin_all")(
 		http.HandlerFunc(handlers.DeleteWorkflowDefinition))).Methods("DELETE")
+	admin.Handle("/workflows/{workflowId}/versions", middleware.RequirePermission("admin_all")(
+		http.HandlerFunc(handlers.ListWorkflowVersions))).Methods("GET")
+	admin.Handle("/workflows/{workflowId}/migrate", middleware.RequirePermission("admin_all")(
+		http.HandlerFunc(handlers.MigrateWorkflowInstances))).Methods("POST")
 
 	// Form configuration endpoints
 	admin.HandleFunc("/forms", handlers.GetFormsForVertical).Methods("GET")
@@ -126,12 +181,38 @@ func registerBusinessRoleRoutes(business *mux.Router) {
 		http.HandlerFunc(biz.UpdateBusinessRole))).Methods("PUT")
 	business.Handle("/roles/{roleId}", middleware.RequireBusinessPermission("business_manage_roles")(
 		http.HandlerFunc(biz.DeleteBusinessRole))).Methods("DELETE")
+	business.Handle("/roles/{roleId}/versions", middleware.RequireBusinessPermission("business_manage_roles")(
+		http.HandlerFunc(biz.GetBusinessRoleVersions))).Methods("GET")
+	business.Handle("/roles/{roleId}/history", middleware.RequireBusinessPermission("business_manage_roles")(
+		http.HandlerFunc(handlers.GetRoleHistory))).Methods("GET")
+
+	// Separation-of-duties rules for this vertical and their override audit trail
+	business.Handle("/sod-rules", middleware.RequireBusinessPermission("business_manage_roles")(
+		http.HandlerFunc(handlers.ListSoDRules))).Methods("GET")
+	business.Handle("/sod-rules", middleware.RequireBusinessPermission("business_manage_roles")(
+		http.HandlerFunc(handlers.CreateSoDRule))).Methods("POST")
+	business.Handle("/sod-rules/{id}", middleware.RequireBusinessPermission("business_manage_roles")(
+		http.HandlerFunc(handlers.UpdateSoDRule))).Methods("PUT")
+	business.Handle("/sod-rules/{id}", middleware.RequireBusinessPermission("business_manage_roles")(
+		http.HandlerFunc(handlers.DeleteSoDRule))).Methods("DELETE")
+	business.Handle("/sod-overrides", middleware.RequireBusinessPermission("business_manage_roles")(
+		http.HandlerFunc(handlers.ListSoDOverrides))).Methods("GET")
+
+	// Deny entries for this vertical's roles and users; denies override role grants
+	business.Handle("/permission-denies", middleware.RequireBusinessPermission("business_manage_roles")(
+		http.HandlerFunc(handlers.ListPermissionDenies))).Methods("GET")
+	business.Handle("/permission-denies", middleware.RequireBusinessPermission("business_manage_roles")(
+		http.HandlerFunc(handlers.CreatePermissionDeny))).Methods("POST")
+	business.Handle("/permission-denies/{id}", middleware.RequireBusinessPermission("business_manage_roles")(
+		http.HandlerFunc(handlers.DeletePermissionDeny))).Methods("DELETE")
 
 	// Business user management
 	business.Handle("/users", middleware.RequireBusinessPermission("business_manage_users")(
 		http.HandlerFunc(biz.GetBusinessUsers))).Methods("GET")
 	business.Handle("/users/assign", middleware.RequireBusinessPermission("business_manage_users")(
 		http.HandlerFunc(biz.AssignUserToBusinessRole))).Methods("POST")
+	business.Handle("/roles/{roleId}/assignments/bulk", middleware.RequireBusinessPermission("business_manage_users")(
+		http.HandlerFunc(biz.BulkAssignBusinessRole))).Methods("POST")
 }
 
 // registerBusinessReportRoutes registers business-specific report routes
@@ -205,6 +286,15 @@ func registerBusinessSiteRoutes(business *mux.Router) {
 	business.Handle("/sites/{siteId}/users",
 		middleware.RequireBusinessPermission("site:view")(
 			http.HandlerFunc(masters.GetSiteUsers))).Methods("GET")
+	business.Handle("/sites/{siteId}/roles",
+		middleware.RequireBusinessPermission("site:view")(
+			http.HandlerFunc(masters.GetSiteRoles))).Methods("GET")
+	business.Handle("/sites/{siteId}/roles",
+		middleware.RequireBusinessPermission("site:manage_access")(
+			http.HandlerFunc(masters.AssignSiteRole))).Methods("POST")
+	business.Handle("/sites/{siteId}/roles/{assignmentId}",
+		middleware.RequireBusinessPermission("site:manage_access")(
+			http.HandlerFunc(masters.RevokeSiteRole))).Methods("DELETE")
 	business.Handle("/sites/user/{userId}/access",
 		middleware.RequireBusinessPermission("site:view")(
 			http.HandlerFunc(masters.GetUserSiteAccessByUserID))).Methods("GET")
@@ -241,6 +331,47 @@ func registerBusinessAttendanceRoutes(business *mux.Router) {
 	business.Handle("/attendance/users/{userId}/timeline",
 		middleware.RequireBusinessPermission("attendance:read")(
 			http.HandlerFunc(handlers.GetEmployeeAttendanceTimeline))).Methods("GET")
+	business.Handle("/attendance/tasks/{taskId}/check-in",
+		middleware.RequireBusinessPermission("attendance:checkin")(
+			http.HandlerFunc(handlers.CheckInTaskAttendance))).Methods("POST")
+	business.Handle("/attendance/tasks/{taskId}/check-out",
+		middleware.RequireBusinessPermission("attendance:checkout")(
+			http.HandlerFunc(handlers.CheckOutTaskAttendance))).Methods("POST")
+	business.Handle("/attendance/tasks/{taskId}/report",
+		middleware.RequireBusinessPermission("attendance:read")(
+			http.HandlerFunc(handlers.GetTaskAttendanceReport))).Methods("GET")
+
+	// Shifts, daily attendance and the muster roll
+	business.Handle("/attendance/shifts",
+		middleware.RequireBusinessPermission("attendance:read")(
+			http.HandlerFunc(handlers.ListShifts))).Methods("GET")
+	business.Handle("/attendance/shifts",
+		middleware.RequireBusinessPermission("attendance:manage")(
+			http.HandlerFunc(handlers.CreateShift))).Methods("POST")
+	business.Handle("/attendance/shifts/{id}",
+		middleware.RequireBusinessPermission("attendance:manage")(
+			http.HandlerFunc(handlers.UpdateShift))).Methods("PUT")
+	business.Handle("/attendance/shift-assignments",
+		middleware.RequireBusinessPermission("attendance:read")(
+			http.HandlerFunc(handlers.ListShiftAssignments))).Methods("GET")
+	business.Handle("/attendance/shift-assignments",
+		middleware.RequireBusinessPermission("attendance:manage")(
+			http.HandlerFunc(handlers.AssignShift))).Methods("POST")
+	business.Handle("/attendance/days",
+		middleware.RequireBusinessPermission("attendance:read")(
+			http.HandlerFunc(handlers.ListAttendanceDays))).Methods("GET")
+	business.Handle("/attendance/days",
+		middleware.RequireBusinessPermission("attendance:manage")(
+			http.HandlerFunc(handlers.RecordAttendanceDay))).Methods("POST")
+	business.Handle("/attendance/days/import",
+		middleware.RequireBusinessPermission("attendance:manage")(
+			http.HandlerFunc(handlers.ImportAttendanceDays))).Methods("POST")
+	business.Handle("/attendance/days/compute",
+		middleware.RequireBusinessPermission("attendance:manage")(
+			http.HandlerFunc(handlers.ComputeAttendanceDays))).Methods("POST")
+	business.Handle("/attendance/muster-roll",
+		middleware.RequireBusinessPermission("attendance:read")(
+			http.HandlerFunc(handlers.GetMusterRoll))).Methods("GET")
 }
 
 func registerBusinessFinanceRoutes(business *mux.Router) {
@@ -259,7 +390,8 @@ func registerBusinessFinanceRoutes(business *mux.Router) {
 			http.HandlerFunc(handlers.UpdateBankGuarantee))).Methods("PUT")
 	business.Handle("/bank-guarantees/{id}/approve",
 		middleware.RequireBusinessPermission("bg:approve")(
-			http.HandlerFunc(handlers.ApproveBankGuarantee))).Methods("POST")
+			middleware.RequireSoDClearance("bg:approve")(
+				http.HandlerFunc(handlers.ApproveBankGuarantee)))).Methods("POST")
 	business.Handle("/bank-guarantees/{id}/claim",
 		middleware.RequireBusinessPermission("bg:claim")(
 			http.HandlerFunc(handlers.ClaimBankGuarantee))).Methods("POST")
@@ -328,7 +460,8 @@ func registerBusinessFinanceRoutes(business *mux.Router) {
 			http.HandlerFunc(handlers.UpdateInsuranceClaim))).Methods("PUT")
 	business.Handle("/insurance-claims/{id}/approve",
 		middleware.RequireBusinessPermission("insurance:approve_claim")(
-			http.HandlerFunc(handlers.ApproveInsuranceClaim))).Methods("POST")
+			middleware.RequireSoDClearance("insurance:approve_claim")(
+				http.HandlerFunc(handlers.ApproveInsuranceClaim)))).Methods("POST")
 	business.Handle("/insurance-claims/{id}/settle",
 		middleware.RequireBusinessPermission("insurance:approve_claim")(
 			http.HandlerFunc(handlers.SettleInsuranceClaim))).Methods("POST")
@@ -338,10 +471,29 @@ func registerBusinessFinanceRoutes(business *mux.Router) {
 func registerSolarRoutes(business *mux.Router) {
 	solar := business.PathPrefix("/solar").Subrouter()
 
-	solar.Handle("/generation", middleware.RequireBusinessPermission("solar_read_generation")(
+	solar.Handle("/generation", middleware.RequireBusinessPermission("solar:read_generation")(
 		http.HandlerFunc(handlers.GetSolarGeneration))).Methods("GET")
-	solar.Handle("/panels", middleware.RequireBusinessPermission("solar_manage_panels")(
-		http.HandlerFunc(handlers.GetSolarPanels))).Methods("GET")
+	solar.Handle("/performance", middleware.RequireBusinessPermission("solar:read_generation")(
+		http.HandlerFunc(handlers.GetSolarPerformance))).Methods("GET")
+	solar.Handle("/plants", middleware.RequireBusinessPermission("solar:read_generation")(
+		http.HandlerFunc(handlers.GetSolarPlants))).Methods("GET")
+	solar.Handle("/plants/{siteId}", middleware.RequireBusinessPermission("solar:manage_panels")(
+		http.HandlerFunc(handlers.SaveSolarPlant))).Methods("PUT")
+
+	// Panel registry and string faults from inspections
+	managePanels := middleware.RequireBusinessPermission("solar:manage_panels")
+	readGeneration := middleware.RequireBusinessPermission("solar:read_generation")
+	maintain := middleware.RequireBusinessPermission("solar:maintenance")
+	solar.Handle("/panels", managePanels(http.HandlerFunc(handlers.ListSolarPanels))).Methods("GET")
+	solar.Handle("/sites/{siteId}/blocks", managePanels(http.HandlerFunc(handlers.ListSolarBlocks))).Methods("GET")
+	solar.Handle("/sites/{siteId}/blocks", managePanels(http.HandlerFunc(handlers.CreateSolarBlock))).Methods("POST")
+	solar.Handle("/blocks/{blockId}/strings", managePanels(http.HandlerFunc(handlers.CreateSolarString))).Methods("POST")
+	solar.Handle("/strings/{stringId}/panels", managePanels(http.HandlerFunc(handlers.RegisterSolarPanels))).Methods("POST")
+	solar.Handle("/strings/{stringId}/faults", maintain(http.HandlerFunc(handlers.LogSolarStringFault))).Methods("POST")
+	solar.Handle("/faults", readGeneration(http.HandlerFunc(handlers.ListSolarStringFaults))).Methods("GET")
+	solar.Handle("/faults/{id}/resolve", maintain(http.HandlerFunc(handlers.ResolveSolarStringFault))).Methods("POST")
+	solar.Handle("/sites/{siteId}/heatmap", readGeneration(http.HandlerFunc(handlers.GetSolarStringHeatmap))).Methods("GET")
+
 	solar.Handle("/maintenance", middleware.RequireBusinessPermission("solar_maintenance")(
 		http.HandlerFunc(handlers.GetSolarMaintenance))).Methods("GET")
 }
@@ -359,4 +511,352 @@ func registerWaterRoutes(business *mux.Router) {
 		http.HandlerFunc(handlers.UpdateWaterTankerReport))).Methods("PUT")
 	water.Handle("/reports/tanker/{id}", middleware.RequireBusinessPermission("inventory:delete")(
 		http.HandlerFunc(handlers.DeleteWaterTankerReport))).Methods("DELETE")
+
+	// Water quality testing
+	readConsumption := middleware.RequireBusinessPermission("water:read_consumption")
+	qualityControl := middleware.RequireBusinessPermission("water:quality_control")
+	water.Handle("/quality/parameters", readConsumption(http.HandlerFunc(handlers.ListWaterQualityParameters))).Methods("GET")
+	water.Handle("/quality/parameters", qualityControl(http.HandlerFunc(handlers.CreateWaterQualityParameter))).Methods("POST")
+	water.Handle("/quality/parameters/{id}", qualityControl(http.HandlerFunc(handlers.UpdateWaterQualityParameter))).Methods("PUT")
+	water.Handle("/quality/sampling-points", readConsumption(http.HandlerFunc(handlers.ListWaterSamplingPoints))).Methods("GET")
+	water.Handle("/quality/sampling-points", qualityControl(http.HandlerFunc(handlers.CreateWaterSamplingPoint))).Methods("POST")
+	water.Handle("/quality/tests", qualityControl(http.HandlerFunc(handlers.ListWaterQualityTests))).Methods("GET")
+	water.Handle("/quality/tests", qualityControl(http.HandlerFunc(handlers.RecordWaterQualityTest))).Methods("POST")
+	water.Handle("/quality/tests/{id}/close", qualityControl(http.HandlerFunc(handlers.CloseWaterNonConformance))).Methods("POST")
+	water.Handle("/quality/trends", readConsumption(http.HandlerFunc(handlers.GetWaterQualityTrends))).Methods("GET")
+
+	// Pump operation and energy logging
+	manageSupply := middleware.RequireBusinessPermission("water:manage_supply")
+	water.Handle("/pumps", readConsumption(http.HandlerFunc(handlers.ListWaterPumps))).Methods("GET")
+	water.Handle("/pumps/logs", readConsumption(http.HandlerFunc(handlers.ListPumpLogs))).Methods("GET")
+	water.Handle("/pumps/efficiency", readConsumption(http.HandlerFunc(handlers.GetPumpEfficiencyTrends))).Methods("GET")
+	water.Handle("/pumps/{assetId}", manageSupply(http.HandlerFunc(handlers.SaveWaterPump))).Methods("PUT")
+	water.Handle("/pumps/{assetId}/logs", manageSupply(http.HandlerFunc(handlers.LogPumpOperation))).Methods("POST")
+	water.Handle("/pumps/{assetId}/logs/sync", manageSupply(http.HandlerFunc(handlers.SyncPumpLogs))).Methods("POST")
+
+	// Non-revenue water: zone balances and loss alerts
+	water.Handle("/zones", readConsumption(http.HandlerFunc(handlers.ListWaterZones))).Methods("GET")
+	water.Handle("/zones", manageSupply(http.HandlerFunc(handlers.CreateWaterZone))).Methods("POST")
+	water.Handle("/zones/{id}", manageSupply(http.HandlerFunc(handlers.UpdateWaterZone))).Methods("PUT")
+	water.Handle("/nrw/compute", manageSupply(http.HandlerFunc(handlers.ComputeWaterLosses))).Methods("POST")
+	water.Handle("/nrw/losses", readConsumption(http.HandlerFunc(handlers.GetWaterLossTrends))).Methods("GET")
+}
+
+// registerBusinessSubcontractRoutes registers subcontractor work order and billing routes.
+// Reads are checked in the handlers: subcontract:read sees the whole business and
+// subcontract:read_own only the user's own subcontractors.
+func registerBusinessSubcontractRoutes(business *mux.Router) {
+	business.HandleFunc("/subcontractors", handlers.ListSubcontractors).Methods("GET")
+	business.Handle("/subcontractors",
+		middleware.RequireBusinessPermission("subcontract:manage")(
+			http.HandlerFunc(handlers.CreateSubcontractor))).Methods("POST")
+	business.Handle("/subcontractors/{id}",
+		middleware.RequireBusinessPermission("subcontract:manage")(
+			http.HandlerFunc(handlers.UpdateSubcontractor))).Methods("PUT")
+
+	business.HandleFunc("/work-orders", handlers.ListWorkOrders).Methods("GET")
+	business.Handle("/work-orders",
+		middleware.RequireBusinessPermission("subcontract:manage")(
+			http.HandlerFunc(handlers.CreateWorkOrder))).Methods("POST")
+	business.HandleFunc("/work-orders/{id}", handlers.GetWorkOrder).Methods("GET")
+	business.Handle("/work-orders/{id}/issue",
+		middleware.RequireBusinessPermission("subcontract:approve")(
+			http.HandlerFunc(handlers.IssueWorkOrder))).Methods("POST")
+	business.Handle("/work-orders/{id}/close",
+		middleware.RequireBusinessPermission("subcontract:manage")(
+			http.HandlerFunc(handlers.CloseWorkOrder))).Methods("POST")
+	business.Handle("/work-orders/{id}/cancel",
+		middleware.RequireBusinessPermission("subcontract:manage")(
+			http.HandlerFunc(handlers.CancelWorkOrder))).Methods("POST")
+
+	business.HandleFunc("/work-orders/{id}/certificates", handlers.ListWorkOrderCertificates).Methods("GET")
+	business.Handle("/work-orders/{id}/certificates",
+		middleware.RequireBusinessPermission("subcontract:manage")(
+			http.HandlerFunc(handlers.CreateWorkOrderCertificate))).Methods("POST")
+	business.Handle("/work-orders/{id}/certificates/{certId}/certify",
+		middleware.RequireBusinessPermission("subcontract:certify")(
+			http.HandlerFunc(handlers.CertifyWorkOrderCertificate))).Methods("POST")
+	business.Handle("/work-orders/{id}/certificates/{certId}/reject",
+		middleware.RequireBusinessPermission("subcontract:certify")(
+			http.HandlerFunc(handlers.RejectWorkOrderCertificate))).Methods("POST")
+
+	business.Handle("/work-orders/{id}/bills",
+		middleware.RequireBusinessPermission("subcontract:manage")(
+			http.HandlerFunc(handlers.GenerateSubcontractBill))).Methods("POST")
+	business.HandleFunc("/subcontract-bills", handlers.ListSubcontractBills).Methods("GET")
+	business.HandleFunc("/subcontract-bills/{billId}", handlers.GetSubcontractBill).Methods("GET")
+	business.Handle("/subcontract-bills/{billId}/submit",
+		middleware.RequireBusinessPermission("subcontract:manage")(
+			http.HandlerFunc(handlers.SubmitSubcontractBill))).Methods("POST")
+	business.Handle("/subcontract-bills/{billId}/approve",
+		middleware.RequireBusinessPermission("subcontract:approve")(
+			http.HandlerFunc(handlers.ApproveSubcontractBill))).Methods("POST")
+	business.Handle("/subcontract-bills/{billId}/reject",
+		middleware.RequireBusinessPermission("subcontract:approve")(
+			http.HandlerFunc(handlers.RejectSubcontractBill))).Methods("POST")
+	business.Handle("/subcontract-bills/{billId}/pay",
+		middleware.RequireBusinessPermission("subcontract:pay")(
+			http.HandlerFunc(handlers.PaySubcontractBill))).Methods("POST")
+}
+
+// registerBusinessProcurementRoutes registers the requisition, purchase order, goods
+// receipt, stock ledger and vendor invoice routes. Workflow actions only need read access
+// here; the handlers check the permission the multi-level approval workflow names for
+// the action, or the document's own permission for submit and revise.
+func registerBusinessProcurementRoutes(business *mux.Router) {
+	purchaseRead := middleware.RequireBusinessPermission("purchase:read")
+	purchaseCreate := middleware.RequireBusinessPermission("purchase:create")
+	inventoryRead := middleware.RequireBusinessPermission("inventory:read")
+	inventoryUpdate := middleware.RequireBusinessPermission("inventory:update")
+	financeRead := middleware.RequireBusinessPermission("finance:read")
+	financeCreate := middleware.RequireBusinessPermission("finance:create")
+	financeApprove := middleware.RequireBusinessPermission("finance:approve")
+	purchaseUpdate := middleware.RequireBusinessPermission("purchase:update")
+	purchaseApprove := middleware.RequireBusinessPermission("purchase:approve")
+
+	// Vendor reports are registered ahead of /vendors/{id} so their paths are not taken as ids
+	business.Handle("/vendors/performance", purchaseApprove(http.HandlerFunc(handlers.ListVendorPerformance))).Methods("GET")
+	business.Handle("/vendors/price-history", purchaseApprove(http.HandlerFunc(handlers.GetVendorPriceHistory))).Methods("GET")
+	business.Handle("/vendors", purchaseRead(http.HandlerFunc(handlers.ListVendors))).Methods("GET")
+	business.Handle("/vendors", purchaseCreate(http.HandlerFunc(handlers.CreateVendor))).Methods("POST")
+	business.Handle("/vendors/{id}", purchaseRead(http.HandlerFunc(handlers.GetVendor))).Methods("GET")
+	business.Handle("/vendors/{id}", purchaseUpdate(http.HandlerFunc(handlers.UpdateVendor))).Methods("PUT")
+	business.Handle("/vendors/{id}/performance", purchaseApprove(http.HandlerFunc(handlers.GetVendorPerformance))).Methods("GET")
+
+	business.Handle("/purchase-requisitions", purchaseRead(http.HandlerFunc(handlers.ListPurchaseRequisitions))).Methods("GET")
+	business.Handle("/purchase-requisitions", purchaseCreate(http.HandlerFunc(handlers.CreatePurchaseRequisition))).Methods("POST")
+	business.Handle("/purchase-requisitions/{id}", purchaseRead(http.HandlerFunc(handlers.GetPurchaseRequisition))).Methods("GET")
+	business.Handle("/purchase-requisitions/{id}", purchaseCreate(http.HandlerFunc(handlers.UpdatePurchaseRequisition))).Methods("PUT")
+	business.Handle("/purchase-requisitions/{id}/actions/{action}",
+		purchaseRead(http.HandlerFunc(handlers.TakePurchaseRequisitionAction))).Methods("POST")
+	business.Handle("/purchase-requisitions/{id}/purchase-orders",
+		purchaseCreate(http.HandlerFunc(handlers.ConvertRequisitionToPurchaseOrder))).Methods("POST")
+
+	business.Handle("/purchase-orders", purchaseRead(http.HandlerFunc(handlers.ListPurchaseOrders))).Methods("GET")
+	business.Handle("/purchase-orders/{id}", purchaseRead(http.HandlerFunc(handlers.GetPurchaseOrder))).Methods("GET")
+	business.Handle("/purchase-orders/{id}", purchaseCreate(http.HandlerFunc(handlers.UpdatePurchaseOrder))).Methods("PUT")
+	business.Handle("/purchase-orders/{id}/actions/{action}",
+		purchaseRead(http.HandlerFunc(handlers.TakePurchaseOrderAction))).Methods("POST")
+	business.Handle("/purchase-orders/{id}/pdf", purchaseRead(http.HandlerFunc(handlers.DownloadPurchaseOrderPDF))).Methods("GET")
+	business.Handle("/purchase-orders/{id}/grns", inventoryUpdate(http.HandlerFunc(handlers.CreateGoodsReceiptNote))).Methods("POST")
+	business.Handle("/purchase-orders/{id}/invoices", financeCreate(http.HandlerFunc(handlers.CreateVendorInvoice))).Methods("POST")
+
+	business.Handle("/grns", purchaseRead(http.HandlerFunc(handlers.ListGoodsReceiptNotes))).Methods("GET")
+	business.Handle("/stock-ledger", inventoryRead(http.HandlerFunc(handlers.ListStockLedger))).Methods("GET")
+	business.Handle("/stock-ledger/balances", inventoryRead(http.HandlerFunc(handlers.GetStockBalances))).Methods("GET")
+	business.Handle("/stock-ledger/issues", inventoryUpdate(http.HandlerFunc(handlers.IssueStock))).Methods("POST")
+
+	business.Handle("/vendor-invoices", financeRead(http.HandlerFunc(handlers.ListVendorInvoices))).Methods("GET")
+	business.Handle("/vendor-invoices/due", financeApprove(http.HandlerFunc(handlers.ListPayablesDue))).Methods("GET")
+	business.Handle("/vendor-invoices/aging", financeApprove(http.HandlerFunc(handlers.GetPayablesAging))).Methods("GET")
+	business.Handle("/vendor-invoices/{id}", financeRead(http.HandlerFunc(handlers.GetVendorInvoice))).Methods("GET")
+	business.Handle("/vendor-invoices/{id}/match", financeCreate(http.HandlerFunc(handlers.RematchVendorInvoice))).Methods("POST")
+	business.Handle("/vendor-invoices/{id}/exceptions",
+		financeApprove(http.HandlerFunc(handlers.ResolveVendorInvoiceExceptions))).Methods("POST")
+	business.Handle("/vendor-invoices/{id}/actions/{action}",
+		financeRead(http.HandlerFunc(handlers.TakeVendorInvoiceAction))).Methods("POST")
+	business.Handle("/vendor-invoices/{id}/payments", financeRead(http.HandlerFunc(handlers.ListVendorInvoicePayments))).Methods("GET")
+	business.Handle("/vendor-invoices/{id}/payments", financeApprove(http.HandlerFunc(handlers.RecordVendorInvoicePayment))).Methods("POST")
+	business.Handle("/invoice-match-tolerance", financeRead(http.HandlerFunc(handlers.GetInvoiceMatchTolerance))).Methods("GET")
+	business.Handle("/invoice-match-tolerance", financeApprove(http.HandlerFunc(handlers.UpdateInvoiceMatchTolerance))).Methods("PUT")
+}
+
+// registerBusinessAssetRoutes registers the asset register, preventive maintenance and
+// downtime routes. Maintenance tasks and downtime only need business access here; the
+// handlers check asset:manage or the maintenance permission of the asset's domain, so
+// solar and water operations staff maintain their own plant.
+func registerBusinessAssetRoutes(business *mux.Router) {
+	assetRead := middleware.RequireBusinessPermission("asset:read")
+	assetManage := middleware.RequireBusinessPermission("asset:manage")
+	businessAccess := middleware.RequireBusinessAccess()
+
+	business.Handle("/assets", assetRead(http.HandlerFunc(handlers.ListAssets))).Methods("GET")
+	business.Handle("/assets", assetManage(http.HandlerFunc(handlers.CreateAsset))).Methods("POST")
+	business.Handle("/assets/{id}", assetRead(http.HandlerFunc(handlers.GetAsset))).Methods("GET")
+	business.Handle("/assets/{id}", assetManage(http.HandlerFunc(handlers.UpdateAsset))).Methods("PUT")
+	business.Handle("/assets/{id}/custody", assetManage(http.HandlerFunc(handlers.TransferAssetCustody))).Methods("POST")
+
+	business.Handle("/assets/{id}/maintenance-schedules", assetRead(http.HandlerFunc(handlers.ListMaintenanceSchedules))).Methods("GET")
+	business.Handle("/assets/{id}/maintenance-schedules", assetManage(http.HandlerFunc(handlers.CreateMaintenanceSchedule))).Methods("POST")
+	business.Handle("/maintenance-schedules/{id}", assetManage(http.HandlerFunc(handlers.UpdateMaintenanceSchedule))).Methods("PUT")
+
+	business.Handle("/maintenance-tasks", businessAccess(http.HandlerFunc(handlers.ListMaintenanceTasks))).Methods("GET")
+	business.Handle("/assets/{id}/maintenance-tasks", businessAccess(http.HandlerFunc(handlers.CreateMaintenanceTask))).Methods("POST")
+	business.Handle("/maintenance-tasks/{id}/{action}", businessAccess(http.HandlerFunc(handlers.TakeMaintenanceTaskAction))).Methods("POST")
+	business.Handle("/maintenance/availability", businessAccess(http.HandlerFunc(handlers.GetMaintenanceAvailability))).Methods("GET")
+
+	business.Handle("/assets/{id}/downtime", assetRead(http.HandlerFunc(handlers.ListAssetDowntime))).Methods("GET")
+	business.Handle("/assets/{id}/downtime", businessAccess(http.HandlerFunc(handlers.ReportAssetDowntime))).Methods("POST")
+	business.Handle("/asset-downtime/{id}/close", businessAccess(http.HandlerFunc(handlers.CloseAssetDowntime))).Methods("POST")
+}
+
+// registerBusinessExpenseRoutes registers the expense claim, expense policy and
+// reimbursement batch routes. Claims are listed and read with business access; the
+// handlers show users their own claims unless they hold expense:read or approve claims,
+// and check the workflow's permissions for claim actions.
+func registerBusinessExpenseRoutes(business *mux.Router) {
+	businessAccess := middleware.RequireBusinessAccess()
+	expenseCreate := middleware.RequireBusinessPermission("expense:create")
+	expensePolicy := middleware.RequireBusinessPermission("expense:policy")
+	financeRead := middleware.RequireBusinessPermission("finance:read")
+	financeCreate := middleware.RequireBusinessPermission("finance:create")
+	financeApprove := middleware.RequireBusinessPermission("finance:approve")
+
+	business.Handle("/expense-claims", businessAccess(http.HandlerFunc(handlers.ListExpenseClaims))).Methods("GET")
+	business.Handle("/expense-claims", expenseCreate(http.HandlerFunc(handlers.CreateExpenseClaim))).Methods("POST")
+	business.Handle("/expense-claims/{id}", businessAccess(http.HandlerFunc(handlers.GetExpenseClaim))).Methods("GET")
+	business.Handle("/expense-claims/{id}", expenseCreate(http.HandlerFunc(handlers.UpdateExpenseClaim))).Methods("PUT")
+	business.Handle("/expense-claims/{id}/actions/{action}",
+		businessAccess(http.HandlerFunc(handlers.TakeExpenseClaimAction))).Methods("POST")
+
+	business.Handle("/expense-policies", expensePolicy(http.HandlerFunc(handlers.ListExpensePolicies))).Methods("GET")
+	business.Handle("/expense-policies", expensePolicy(http.HandlerFunc(handlers.UpsertExpensePolicy))).Methods("POST")
+	business.Handle("/expense-policies/{id}", expensePolicy(http.HandlerFunc(handlers.DeleteExpensePolicy))).Methods("DELETE")
+
+	business.Handle("/reimbursement-batches", financeRead(http.HandlerFunc(handlers.ListReimbursementBatches))).Methods("GET")
+	business.Handle("/reimbursement-batches", financeCreate(http.HandlerFunc(handlers.CreateReimbursementBatch))).Methods("POST")
+	business.Handle("/reimbursement-batches/{id}", financeRead(http.HandlerFunc(handlers.GetReimbursementBatch))).Methods("GET")
+	business.Handle("/reimbursement-batches/{id}/claims/{claimId}",
+		financeCreate(http.HandlerFunc(handlers.RemoveClaimFromReimbursementBatch))).Methods("DELETE")
+	business.Handle("/reimbursement-batches/{id}/export", financeCreate(http.HandlerFunc(handlers.ExportReimbursementBatch))).Methods("POST")
+	business.Handle("/reimbursement-batches/{id}/pay", financeApprove(http.HandlerFunc(handlers.PayReimbursementBatch))).Methods("POST")
+}
+
+// registerBusinessPayrollRoutes registers the payroll routes. Salary structures, leave,
+// statutory rules and drafting runs need payroll:generate. Runs are read and moved through
+// their approval workflow with business access; the handlers let in holders of
+// payroll:generate or payroll:approve and check the workflow's permissions for actions.
+// Employees download their own approved payslips.
+func registerBusinessPayrollRoutes(business *mux.Router) {
+	businessAccess := middleware.RequireBusinessAccess()
+	payrollGenerate := middleware.RequireBusinessPermission("payroll:generate")
+
+	business.Handle("/payroll/statutory-rules", payrollGenerate(http.HandlerFunc(handlers.GetPayrollStatutoryRules))).Methods("GET")
+	business.Handle("/payroll/statutory-rules", payrollGenerate(http.HandlerFunc(handlers.UpdatePayrollStatutoryRules))).Methods("PUT")
+
+	business.Handle("/salary-structures", payrollGenerate(http.HandlerFunc(handlers.ListSalaryStructures))).Methods("GET")
+	business.Handle("/salary-structures", payrollGenerate(http.HandlerFunc(handlers.CreateSalaryStructure))).Methods("POST")
+	business.Handle("/salary-structures/{id}", payrollGenerate(http.HandlerFunc(handlers.UpdateSalaryStructure))).Methods("PUT")
+
+	business.Handle("/employee-leaves", payrollGenerate(http.HandlerFunc(handlers.ListEmployeeLeaves))).Methods("GET")
+	business.Handle("/employee-leaves", payrollGenerate(http.HandlerFunc(handlers.RecordEmployeeLeave))).Methods("POST")
+	business.Handle("/employee-leaves/{id}/cancel", payrollGenerate(http.HandlerFunc(handlers.CancelEmployeeLeave))).Methods("POST")
+
+	business.Handle("/payroll-runs", businessAccess(http.HandlerFunc(handlers.ListPayrollRuns))).Methods("GET")
+	business.Handle("/payroll-runs", payrollGenerate(http.HandlerFunc(handlers.CreatePayrollRun))).Methods("POST")
+	business.Handle("/payroll-runs/{id}", businessAccess(http.HandlerFunc(handlers.GetPayrollRun))).Methods("GET")
+	business.Handle("/payroll-runs/{id}/recompute", payrollGenerate(http.HandlerFunc(handlers.RecomputePayrollRun))).Methods("POST")
+	business.Handle("/payroll-runs/{id}/actions/{action}",
+		businessAccess(http.HandlerFunc(handlers.TakePayrollRunAction))).Methods("POST")
+	business.Handle("/payroll-runs/{id}/payslips/{payslipId}/pdf",
+		businessAccess(http.HandlerFunc(handlers.DownloadPayslipPDF))).Methods("GET")
+
+	business.Handle("/my-payslips", businessAccess(http.HandlerFunc(handlers.ListMyPayslips))).Methods("GET")
+	business.Handle("/my-payslips/{id}/pdf", businessAccess(http.HandlerFunc(handlers.DownloadMyPayslipPDF))).Methods("GET")
+}
+
+// registerBusinessLeaveRoutes registers the leave routes. Employees apply for leave under
+// leave:apply; applications are approved in the standard approval workflow, whose action
+// checks permissions itself, and HR sets leave types and balances under leave:manage.
+func registerBusinessLeaveRoutes(business *mux.Router) {
+	businessAccess := middleware.RequireBusinessAccess()
+	leaveApply := middleware.RequireBusinessPermission("leave:apply")
+	leaveManage := middleware.RequireBusinessPermission("leave:manage")
+
+	business.Handle("/leave-types", leaveApply(http.HandlerFunc(handlers.ListLeaveTypes))).Methods("GET")
+	business.Handle("/leave-types", leaveManage(http.HandlerFunc(handlers.CreateLeaveType))).Methods("POST")
+	business.Handle("/leave-types/{id}", leaveManage(http.HandlerFunc(handlers.UpdateLeaveType))).Methods("PUT")
+
+	business.Handle("/leave/balances", leaveApply(http.HandlerFunc(handlers.ListLeaveBalances))).Methods("GET")
+	business.Handle("/leave/balances/adjust", leaveManage(http.HandlerFunc(handlers.AdjustLeaveBalance))).Methods("POST")
+	business.Handle("/leave/accruals", leaveManage(http.HandlerFunc(handlers.RunLeaveAccrual))).Methods("POST")
+
+	business.Handle("/leave/applications", businessAccess(http.HandlerFunc(handlers.ListLeaveApplications))).Methods("GET")
+	business.Handle("/leave/applications", leaveApply(http.HandlerFunc(handlers.CreateLeaveApplication))).Methods("POST")
+	business.Handle("/leave/applications/{id}", businessAccess(http.HandlerFunc(handlers.GetLeaveApplication))).Methods("GET")
+	business.Handle("/leave/applications/{id}", leaveApply(http.HandlerFunc(handlers.UpdateLeaveApplication))).Methods("PUT")
+	business.Handle("/leave/applications/{id}/actions/{action}",
+		businessAccess(http.HandlerFunc(handlers.TakeLeaveApplicationAction))).Methods("POST")
+	business.Handle("/leave/applications/{id}/cancel",
+		businessAccess(http.HandlerFunc(handlers.CancelLeaveApplication))).Methods("POST")
+
+	business.Handle("/leave/calendar", leaveApply(http.HandlerFunc(handlers.GetLeaveCalendar))).Methods("GET")
+}
+
+func registerBusinessEmployeeRoutes(business *mux.Router) {
+	businessAccess := middleware.RequireBusinessAccess()
+	hrRead := middleware.RequireBusinessPermission("hr:read")
+	hrCreate := middleware.RequireBusinessPermission("hr:create")
+	hrUpdate := middleware.RequireBusinessPermission("hr:update")
+	hrDelete := middleware.RequireBusinessPermission("hr:delete")
+
+	business.Handle("/employees", hrRead(http.HandlerFunc(handlers.ListEmployees))).Methods("GET")
+	business.Handle("/employees", hrCreate(http.HandlerFunc(handlers.CreateEmployee))).Methods("POST")
+	business.Handle("/employees/{id}", hrRead(http.HandlerFunc(handlers.GetEmployee))).Methods("GET")
+	business.Handle("/employees/{id}", hrUpdate(http.HandlerFunc(handlers.UpdateEmployee))).Methods("PUT")
+	business.Handle("/employees/{id}", hrDelete(http.HandlerFunc(handlers.DeleteEmployee))).Methods("DELETE")
+	business.Handle("/my-employee-profile", businessAccess(http.HandlerFunc(handlers.GetMyEmployeeProfile))).Methods("GET")
+
+	business.Handle("/employees/{id}/activate", hrUpdate(http.HandlerFunc(handlers.ActivateEmployee))).Methods("POST")
+	business.Handle("/employees/{id}/offboard", hrUpdate(http.HandlerFunc(handlers.OffboardEmployee))).Methods("POST")
+	business.Handle("/employees/{id}/exit", hrUpdate(http.HandlerFunc(handlers.ExitEmployee))).Methods("POST")
+	business.Handle("/employees/{id}/checklist/{itemId}",
+		hrUpdate(http.HandlerFunc(handlers.UpdateEmployeeChecklistItem))).Methods("PUT")
+
+	business.Handle("/employees/{id}/documents", hrUpdate(http.HandlerFunc(handlers.AddEmployeeDocument))).Methods("POST")
+	business.Handle("/employees/{id}/documents/{documentId}",
+		hrUpdate(http.HandlerFunc(handlers.RemoveEmployeeDocument))).Methods("DELETE")
+
+	business.Handle("/employee-checklists/{kind}", hrRead(http.HandlerFunc(handlers.GetEmployeeChecklistTemplate))).Methods("GET")
+	business.Handle("/employee-checklists/{kind}", hrUpdate(http.HandlerFunc(handlers.UpdateEmployeeChecklistTemplate))).Methods("PUT")
+}
+
+func registerBusinessLedgerRoutes(business *mux.Router) {
+	financeRead := middleware.RequireBusinessPermission("finance:read")
+	financeCreate := middleware.RequireBusinessPermission("finance:create")
+	financeUpdate := middleware.RequireBusinessPermission("finance:update")
+	financeApprove := middleware.RequireBusinessPermission("finance:approve")
+
+	business.Handle("/ledger/accounts", financeRead(http.HandlerFunc(handlers.ListLedgerAccounts))).Methods("GET")
+	business.Handle("/ledger/accounts", financeUpdate(http.HandlerFunc(handlers.CreateLedgerAccount))).Methods("POST")
+	business.Handle("/ledger/accounts/defaults",
+		financeUpdate(http.HandlerFunc(handlers.InstallDefaultChartOfAccounts))).Methods("POST")
+	business.Handle("/ledger/accounts/{id}", financeUpdate(http.HandlerFunc(handlers.UpdateLedgerAccount))).Methods("PUT")
+	business.Handle("/ledger/accounts/{id}/ledger", financeRead(http.HandlerFunc(handlers.GetAccountLedger))).Methods("GET")
+
+	business.Handle("/ledger/cost-centers", financeRead(http.HandlerFunc(handlers.ListCostCenters))).Methods("GET")
+	business.Handle("/ledger/cost-centers", financeUpdate(http.HandlerFunc(handlers.CreateCostCenter))).Methods("POST")
+	business.Handle("/ledger/cost-centers/{id}", financeUpdate(http.HandlerFunc(handlers.UpdateCostCenter))).Methods("PUT")
+
+	business.Handle("/ledger/journal-entries", financeRead(http.HandlerFunc(handlers.ListJournalEntries))).Methods("GET")
+	business.Handle("/ledger/journal-entries", financeCreate(http.HandlerFunc(handlers.CreateJournalEntry))).Methods("POST")
+	business.Handle("/ledger/journal-entries/{id}", financeRead(http.HandlerFunc(handlers.GetJournalEntry))).Methods("GET")
+	business.Handle("/ledger/journal-entries/{id}", financeCreate(http.HandlerFunc(handlers.UpdateJournalEntry))).Methods("PUT")
+	business.Handle("/ledger/journal-entries/{id}", financeCreate(http.HandlerFunc(handlers.DeleteJournalEntry))).Methods("DELETE")
+	business.Handle("/ledger/journal-entries/{id}/post",
+		financeApprove(http.HandlerFunc(handlers.PostJournalEntry))).Methods("POST")
+	business.Handle("/ledger/journal-entries/{id}/reverse",
+		financeApprove(http.HandlerFunc(handlers.ReverseJournalEntry))).Methods("POST")
+
+	business.Handle("/ledger/period-locks", financeRead(http.HandlerFunc(handlers.ListLedgerPeriodLocks))).Methods("GET")
+	business.Handle("/ledger/period-locks", financeApprove(http.HandlerFunc(handlers.LockLedgerPeriod))).Methods("POST")
+	business.Handle("/ledger/period-locks/{period}",
+		financeApprove(http.HandlerFunc(handlers.UnlockLedgerPeriod))).Methods("DELETE")
+
+	business.Handle("/ledger/trial-balance", financeRead(http.HandlerFunc(handlers.GetTrialBalance))).Methods("GET")
+
+	business.Handle("/gst/gstr1", financeRead(http.HandlerFunc(handlers.GetGSTR1))).Methods("GET")
+	business.Handle("/gst/gstr3b", financeRead(http.HandlerFunc(handlers.GetGSTR3B))).Methods("GET")
+}
+
+// registerBusinessApprovalLimitRoutes registers the approval limit matrix routes. The
+// limits are enforced by the approval workflow itself, for purchases, expense claims and
+// vendor invoices alike.
+func registerBusinessApprovalLimitRoutes(business *mux.Router) {
+	financeRead := middleware.RequireBusinessPermission("finance:read")
+	financeLimits := middleware.RequireBusinessPermission("finance:limits")
+
+	business.Handle("/approval-limits", financeRead(http.HandlerFunc(handlers.ListApprovalLimits))).Methods("GET")
+	business.Handle("/approval-limits", financeLimits(http.HandlerFunc(handlers.UpsertApprovalLimit))).Methods("POST")
+	business.Handle("/approval-limits/{id}", financeLimits(http.HandlerFunc(handlers.DeleteApprovalLimit))).Methods("DELETE")
 }
@@ -93,6 +93,31 @@ func RegisterChatRoutes(api *mux.Router) {
 	// DELETE /api/v1/chat/messages/{id}
 	chat.HandleFunc("/messages/{id}", chatHandler.DeleteMessage).Methods("DELETE")
 
+	// Acknowledge an ack-required message (service checks if user is participant)
+	// POST /api/v1/chat/messages/{id}/ack
+	chat.HandleFunc("/messages/{id}/ack", chatHandler.AcknowledgeMessage).Methods("POST")
+
+	// ============================================================================
+	// Canned response endpoints (scoped to the active business vertical)
+	// ============================================================================
+
+	// List canned responses for quick replies
+	// GET /api/v1/chat/canned-responses
+	chat.HandleFunc("/canned-responses", chatHandler.ListCannedResponses).Methods("GET")
+
+	// Manage canned responses (vertical admins)
+	// POST /api/v1/chat/canned-responses
+	chat.Handle("/canned-responses", middleware.RequireBusinessPermission("chat:canned_response:manage")(
+		http.HandlerFunc(chatHandler.CreateCannedResponse))).Methods("POST")
+
+	// PUT /api/v1/chat/canned-responses/{id}
+	chat.Handle("/canned-responses/{id}", middleware.RequireBusinessPermission("chat:canned_response:manage")(
+		http.HandlerFunc(chatHandler.UpdateCannedResponse))).Methods("PUT")
+
+	// DELETE /api/v1/chat/canned-responses/{id}
+	chat.Handle("/canned-responses/{id}", middleware.RequireBusinessPermission("chat:canned_response:manage")(
+		http.HandlerFunc(chatHandler.DeleteCannedResponse))).Methods("DELETE")
+
 	// ============================================================================
 	// Participant endpoints
 	// ============================================================================
@@ -44,6 +44,23 @@ func RegisterDocumentRoutes(api *mux.Router, admin *mux.Router) {
 	api.Handle("/documents/tags/{id}", middleware.RequirePermission("document:manage_tags")(
 		http.HandlerFunc(handlers.DeleteDocumentTagHandler))).Methods("DELETE")
 
+	api.Handle("/documents/folders", middleware.RequirePermission("document:read")(
+		http.HandlerFunc(handlers.GetDocumentFoldersHandler))).Methods("GET")
+	api.Handle("/documents/folders", middleware.RequirePermission("document:upload")(
+		http.HandlerFunc(handlers.CreateDocumentFolderHandler))).Methods("POST")
+	api.Handle("/documents/folders/{id}", middleware.RequirePermission("document:read")(
+		http.HandlerFunc(handlers.GetDocumentFolderHandler))).Methods("GET")
+	api.Handle("/documents/folders/{id}", middleware.RequirePermission("document:update")(
+		http.HandlerFunc(handlers.UpdateDocumentFolderHandler))).Methods("PUT")
+	api.Handle("/documents/folders/{id}", middleware.RequirePermission("document:delete")(
+		http.HandlerFunc(handlers.DeleteDocumentFolderHandler))).Methods("DELETE")
+
+	// Direct-to-storage uploads: presign, PUT the file to upload_url, then complete
+	api.Handle("/documents/uploads", middleware.RequirePermission("document:upload")(
+		http.HandlerFunc(handlers.CreateDocumentUploadURLHandler))).Methods("POST")
+	api.Handle("/documents/uploads/{id}/complete", middleware.RequirePermission("document:upload")(
+		http.HandlerFunc(handlers.CompleteDocumentUploadHandler))).Methods("POST")
+
 	api.Handle("/documents/bulk/delete", middleware.RequirePermission("document:delete")(
 		http.HandlerFunc(handlers.BulkDeleteDocumentsHandler))).Methods("POST")
 	api.Handle("/documents/bulk/update", middleware.RequirePermission("document:update")(
@@ -67,6 +84,10 @@ func RegisterDocumentRoutes(api *mux.Router, admin *mux.Router) {
 		http.HandlerFunc(handlers.DeleteDocumentHandler))).Methods("DELETE")
 	api.Handle("/documents/{id}/download", middleware.RequirePermission("document:read")(
 		http.HandlerFunc(handlers.DownloadDocumentHandler))).Methods("GET")
+	api.Handle("/documents/{id}/download-url", middleware.RequirePermission("document:read")(
+		http.HandlerFunc(handlers.GetDocumentDownloadURLHandler))).Methods("GET")
+	api.Handle("/documents/{id}/reindex", middleware.RequirePermission("document:update")(
+		http.HandlerFunc(handlers.ReindexDocumentTextHandler))).Methods("POST")
 	api.Handle("/documents/{id}/ai/process", middleware.RequirePermission("document:read")(
 		http.HandlerFunc(handlers.ProcessDocumentAIHandler))).Methods("POST")
 	api.Handle("/documents/{id}/workflow", middleware.RequirePermission("document:read")(
@@ -0,0 +1,37 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/handlers/emergency"
+	"p9e.in/ugcl/middleware"
+)
+
+// RegisterEmergencyRoutes registers the recipient-facing emergency broadcast endpoints
+func RegisterEmergencyRoutes(api *mux.Router) {
+	emergencyHandler := emergency.NewHandler()
+
+	// Active broadcasts the current user still has to acknowledge
+	api.HandleFunc("/emergency/broadcasts/pending", emergencyHandler.ListMyPendingBroadcasts).Methods(http.MethodGet)
+	api.HandleFunc("/emergency/broadcasts/{id}/acknowledge", emergencyHandler.AcknowledgeBroadcast).Methods(http.MethodPost)
+}
+
+// registerEmergencyBroadcastRoutes registers business-scoped broadcast sending and the acknowledgment dashboard
+func registerEmergencyBroadcastRoutes(business *mux.Router) {
+	emergencyHandler := emergency.NewHandler()
+	broadcasts := business.PathPrefix("/emergency/broadcasts").Subrouter()
+
+	broadcasts.Handle("", middleware.RequireBusinessPermission("emergency:broadcast:send")(
+		http.HandlerFunc(emergencyHandler.SendBroadcast))).Methods(http.MethodPost)
+	broadcasts.Handle("", middleware.RequireBusinessPermission("emergency:broadcast:view")(
+		http.HandlerFunc(emergencyHandler.ListBroadcasts))).Methods(http.MethodGet)
+
+	// Live acknowledgment dashboard (?status=pending|acknowledged)
+	broadcasts.Handle("/{id}", middleware.RequireBusinessPermission("emergency:broadcast:view")(
+		http.HandlerFunc(emergencyHandler.GetBroadcastDashboard))).Methods(http.MethodGet)
+	broadcasts.Handle("/{id}/close", middleware.RequireBusinessPermission("emergency:broadcast:send")(
+		http.HandlerFunc(emergencyHandler.CloseBroadcast))).Methods(http.MethodPost)
+	broadcasts.Handle("/{id}/recipients/{userId}/acknowledge", middleware.RequireBusinessPermission("emergency:broadcast:send")(
+		http.HandlerFunc(emergencyHandler.RecordAcknowledgment))).Methods(http.MethodPost)
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/handlers"
+)
+
+// RegisterFormDraftRoutes registers saving, resuming, discarding and submitting drafts of
+// dynamic forms. Drafts belong to the user who saved them; handlers check the form's
+// permission in the draft's vertical as record endpoints do.
+func RegisterFormDraftRoutes(api *mux.Router) {
+	api.HandleFunc("/forms/{code}/drafts", handlers.ListFormDrafts).Methods(http.MethodGet)
+	api.HandleFunc("/forms/{code}/drafts", handlers.CreateFormDraft).Methods(http.MethodPost)
+	api.HandleFunc("/forms/{code}/drafts/{id}", handlers.GetFormDraft).Methods(http.MethodGet)
+	api.HandleFunc("/forms/{code}/drafts/{id}", handlers.UpdateFormDraft).Methods(http.MethodPut)
+	api.HandleFunc("/forms/{code}/drafts/{id}", handlers.DiscardFormDraft).Methods(http.MethodDelete)
+	api.HandleFunc("/forms/{code}/drafts/{id}/submit", handlers.SubmitFormDraft).Methods(http.MethodPost)
+}
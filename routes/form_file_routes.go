@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/handlers"
+)
+
+// RegisterFormFileRoutes registers uploads for file and image fields of dynamic forms.
+// Upload and lookup check the form's permission in the vertical like record endpoints do.
+// Content is served on the root router, without a login, to holders of a signed URL.
+func RegisterFormFileRoutes(r *mux.Router, api *mux.Router) {
+	r.HandleFunc("/api/v1/form-files/{id}/content", handlers.ServeFormFileContent).Methods(http.MethodGet, http.MethodHead)
+	api.HandleFunc("/forms/{code}/fields/{field}/files", handlers.UploadFormFieldFile).Methods(http.MethodPost)
+	api.HandleFunc("/forms/{code}/files/{id}", handlers.GetFormFile).Methods(http.MethodGet)
+}
@@ -0,0 +1,41 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/handlers"
+	"p9e.in/ugcl/middleware"
+)
+
+// RegisterFormRecordRoutes registers the generic record endpoints of dynamic forms. Each
+// handler checks the form's required permission in the record's vertical, so the routes
+// only need an authenticated user. Exports also need report:export, and the recycle bin
+// form_record:restore (form_record:purge to purge). Exports, imports, search and the
+// recycle bin are registered before the record routes so their paths are not taken for
+// record IDs. The form's field logic is served alongside for clients rendering record forms,
+// and its analytics for dashboards. Rows of a record's repeatable sections are managed under
+// the record.
+func RegisterFormRecordRoutes(api *mux.Router) {
+	api.HandleFunc("/forms/{code}/rules", handlers.GetFormRules).Methods(http.MethodGet)
+	api.HandleFunc("/forms/{code}/analytics", handlers.GetFormAnalytics).Methods(http.MethodGet)
+	api.Handle("/forms/{code}/records/export", middleware.RequirePermission("report:export")(http.HandlerFunc(handlers.ExportFormRecords))).Methods(http.MethodGet)
+	api.HandleFunc("/forms/{code}/records/exports/{id}", handlers.GetFormRecordExport).Methods(http.MethodGet)
+	api.HandleFunc("/forms/{code}/records/exports/{id}/download", handlers.DownloadFormRecordExport).Methods(http.MethodGet)
+	api.HandleFunc("/forms/{code}/records/import", handlers.ImportFormRecords).Methods(http.MethodPost)
+	api.HandleFunc("/forms/{code}/records/search", handlers.SearchFormRecords).Methods(http.MethodGet)
+	api.Handle("/forms/{code}/records/deleted", middleware.RequirePermission("form_record:restore")(http.HandlerFunc(handlers.ListDeletedFormRecords))).Methods(http.MethodGet)
+	api.Handle("/forms/{code}/records/deleted/{id}/restore", middleware.RequirePermission("form_record:restore")(http.HandlerFunc(handlers.RestoreFormRecord))).Methods(http.MethodPost)
+	api.Handle("/forms/{code}/records/deleted/{id}", middleware.RequirePermission("form_record:purge")(http.HandlerFunc(handlers.PurgeFormRecord))).Methods(http.MethodDelete)
+	api.HandleFunc("/forms/{code}/records", handlers.ListFormRecords).Methods(http.MethodGet)
+	api.HandleFunc("/forms/{code}/records", handlers.CreateFormRecord).Methods(http.MethodPost)
+	api.HandleFunc("/forms/{code}/records/{id}", handlers.GetFormRecord).Methods(http.MethodGet)
+	api.HandleFunc("/forms/{code}/records/{id}", handlers.UpdateFormRecord).Methods(http.MethodPut)
+	api.HandleFunc("/forms/{code}/records/{id}", handlers.DeleteFormRecord).Methods(http.MethodDelete)
+	api.HandleFunc("/forms/{code}/records/{id}/history", handlers.GetFormRecordHistory).Methods(http.MethodGet)
+	api.HandleFunc("/forms/{code}/records/{id}/sections/{section}", handlers.ListFormSectionRows).Methods(http.MethodGet)
+	api.HandleFunc("/forms/{code}/records/{id}/sections/{section}", handlers.CreateFormSectionRow).Methods(http.MethodPost)
+	api.HandleFunc("/forms/{code}/records/{id}/sections/{section}/{row}", handlers.GetFormSectionRow).Methods(http.MethodGet)
+	api.HandleFunc("/forms/{code}/records/{id}/sections/{section}/{row}", handlers.UpdateFormSectionRow).Methods(http.MethodPut)
+	api.HandleFunc("/forms/{code}/records/{id}/sections/{section}/{row}", handlers.DeleteFormSectionRow).Methods(http.MethodDelete)
+}
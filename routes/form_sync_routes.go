@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/handlers"
+)
+
+// RegisterFormSyncRoutes registers the offline sync endpoints of dynamic forms: pulling
+// the records changed since the last sync and pushing queued changes. Handlers check the
+// form's permission in each record's vertical as record endpoints do.
+func RegisterFormSyncRoutes(api *mux.Router) {
+	api.HandleFunc("/forms/{code}/sync", handlers.PullFormRecordChanges).Methods(http.MethodGet)
+	api.HandleFunc("/forms/{code}/sync", handlers.PushFormRecordChanges).Methods(http.MethodPost)
+}
@@ -46,4 +46,8 @@ func RegisterAdminIntegrationRoutes(admin *mux.Router) {
 		http.HandlerFunc(handlers.UpdateIntegration))).Methods(http.MethodPatch)
 	admin.Handle("/integrations/{id}", middleware.RequirePermission("manage_integrations")(
 		http.HandlerFunc(handlers.DeleteIntegration))).Methods(http.MethodDelete)
+
+	// Vertical plugin hooks: load status and per-hook metrics
+	admin.Handle("/plugins", middleware.RequirePermission("manage_integrations")(
+		http.HandlerFunc(handlers.GetPluginHooks))).Methods(http.MethodGet)
 }
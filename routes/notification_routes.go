@@ -25,6 +25,9 @@ func RegisterNotificationRoutes(api *mux.Router, admin *mux.Router) {
 	// Get unread count
 	api.HandleFunc("/notifications/unread-count", notifHandler.GetUnreadCount).Methods("GET")
 
+	// Unread counts for badges
+	api.HandleFunc("/notifications/badge", notifHandler.GetNotificationBadge).Methods("GET")
+
 	// Mark all notifications as read
 	api.HandleFunc("/notifications/read-all", notifHandler.MarkAllNotificationsAsRead).Methods("PATCH")
 
@@ -54,6 +57,10 @@ func RegisterNotificationRoutes(api *mux.Router, admin *mux.Router) {
 	// Mark notification as read
 	api.HandleFunc("/notifications/{id}/read", notifHandler.MarkNotificationAsRead).Methods("PATCH")
 
+	// Archive and unarchive notification
+	api.HandleFunc("/notifications/{id}/archive", notifHandler.ArchiveNotification).Methods("PATCH")
+	api.HandleFunc("/notifications/{id}/unarchive", notifHandler.UnarchiveNotification).Methods("PATCH")
+
 	// Delete notification
 	api.HandleFunc("/notifications/{id}", notifHandler.DeleteNotification).Methods("DELETE")
 
@@ -107,4 +114,14 @@ func RegisterNotificationRoutes(api *mux.Router, admin *mux.Router) {
 		http.HandlerFunc(adminHandler.GetNotificationStats))).Methods("GET")
 	admin.Handle("/notifications/push/mobile-tokens", middleware.RequirePermission("manage_notifications")(
 		http.HandlerFunc(notifHandler.GetMobilePushTokensForAdmin))).Methods("GET")
+
+	// Delivery audit: queued pushes and texts, each try at them, and requeueing failures
+	admin.Handle("/notification-deliveries", middleware.RequirePermission("manage_notifications")(
+		http.HandlerFunc(adminHandler.ListNotificationDeliveries))).Methods("GET")
+	admin.Handle("/notification-deliveries/attempts", middleware.RequirePermission("manage_notifications")(
+		http.HandlerFunc(adminHandler.ListDeliveryAttempts))).Methods("GET")
+	admin.Handle("/notification-deliveries/{channel}/{id}", middleware.RequirePermission("manage_notifications")(
+		http.HandlerFunc(adminHandler.GetNotificationDelivery))).Methods("GET")
+	admin.Handle("/notification-deliveries/{channel}/{id}/requeue", middleware.RequirePermission("manage_notifications")(
+		http.HandlerFunc(adminHandler.RequeueNotificationDelivery))).Methods("POST")
 }
@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/handlers"
+	"p9e.in/ugcl/middleware"
+)
+
+// RegisterPortfolioRoutes registers the cross-project portfolio dashboard routes
+func RegisterPortfolioRoutes(api *mux.Router, admin *mux.Router) {
+	portfolioHandler := handlers.NewPortfolioHandler()
+
+	// Projects ranked by risk score (?business_vertical_id=&risk_level=&status=&limit=)
+	api.Handle("/portfolio/dashboard", middleware.RequirePermission("portfolio:read")(
+		http.HandlerFunc(portfolioHandler.GetPortfolioDashboard))).Methods("GET")
+
+	// Weekly snapshot trends (?weeks=12&project_id=)
+	api.Handle("/portfolio/trends", middleware.RequirePermission("portfolio:read")(
+		http.HandlerFunc(portfolioHandler.GetPortfolioTrends))).Methods("GET")
+
+	// Cost vs budget vs billed value per project and vertical (?from=&to=&business_vertical_id=&status=&format=csv)
+	api.Handle("/portfolio/profitability", middleware.RequirePermission("portfolio:read")(
+		http.HandlerFunc(portfolioHandler.GetPortfolioProfitability))).Methods("GET")
+
+	// Store this week's snapshot now instead of waiting for the scheduler
+	admin.Handle("/portfolio/snapshots", middleware.RequirePermission("portfolio:manage")(
+		http.HandlerFunc(portfolioHandler.TakePortfolioSnapshot))).Methods("POST")
+}
@@ -18,6 +18,7 @@ func RegisterProjectRoutes(r *mux.Router) {
 	roleHandler := handlers.NewProjectRoleHandler()
 	workflowHandler := handlers.NewProjectWorkflowHandler()
 	phase1Handler := handlers.NewProjectPhase1Handler()
+	blueprintHandler := handlers.NewProjectBlueprintHandler()
 
 	// =====================================================
 	// Project Management Routes
@@ -34,12 +35,34 @@ func RegisterProjectRoutes(r *mux.Router) {
 		http.HandlerFunc(projectHandler.UpdateProject))).Methods("PUT")
 	r.Handle("/projects/{id}", middleware.RequirePermission("project:delete")(
 		http.HandlerFunc(projectHandler.DeleteProject))).Methods("DELETE")
+	r.Handle("/projects/{id}/clone", middleware.RequirePermission("project:create")(
+		http.HandlerFunc(projectHandler.CloneProject))).Methods("POST")
+
+	// Project Blueprints
+	r.Handle("/project-blueprints", middleware.RequirePermission("project:blueprint_manage")(
+		http.HandlerFunc(blueprintHandler.CreateBlueprint))).Methods("POST")
+	r.Handle("/project-blueprints", middleware.RequirePermission("project:blueprint_read")(
+		http.HandlerFunc(blueprintHandler.ListBlueprints))).Methods("GET")
+	r.Handle("/project-blueprints/{blueprintId}", middleware.RequirePermission("project:blueprint_read")(
+		http.HandlerFunc(blueprintHandler.GetBlueprint))).Methods("GET")
+	r.Handle("/project-blueprints/{blueprintId}", middleware.RequirePermission("project:blueprint_manage")(
+		http.HandlerFunc(blueprintHandler.UpdateBlueprint))).Methods("PUT")
+	r.Handle("/project-blueprints/{blueprintId}", middleware.RequirePermission("project:blueprint_manage")(
+		http.HandlerFunc(blueprintHandler.DeleteBlueprint))).Methods("DELETE")
+	r.Handle("/project-blueprints/{blueprintId}/projects", middleware.RequirePermission("project:create")(
+		http.HandlerFunc(blueprintHandler.CreateProjectFromBlueprint))).Methods("POST")
+	r.Handle("/projects/{id}/blueprint", middleware.RequirePermission("project:blueprint_manage")(
+		http.HandlerFunc(blueprintHandler.CaptureBlueprint))).Methods("POST")
 
 	// KMZ Upload
 	r.Handle("/projects/{id}/kmz", middleware.RequirePermission("project:update")(
 		http.HandlerFunc(projectHandler.UploadKMZ))).Methods("POST")
 	r.Handle("/projects/{id}/geojson", middleware.RequirePermission("project:read")(
 		http.HandlerFunc(projectHandler.GetProjectGeoJSON))).Methods("GET")
+	r.Handle("/projects/{id}/export.kmz", middleware.RequirePermission("project:read")(
+		http.HandlerFunc(projectHandler.ExportProjectKMZ))).Methods("GET")
+	r.Handle("/projects/{id}/tiles/{z}/{x}/{y}", middleware.RequirePermission("project:read")(
+		http.HandlerFunc(projectHandler.GetProjectTile))).Methods("GET")
 
 	// Project Zones and Nodes
 	r.Handle("/projects/{id}/zones", middleware.RequirePermission("project:read")(
@@ -47,9 +70,17 @@ func RegisterProjectRoutes(r *mux.Router) {
 	r.Handle("/projects/{id}/nodes", middleware.RequirePermission("project:read")(
 		http.HandlerFunc(projectHandler.GetProjectNodes))).Methods("GET")
 
+	// Spatial queries for the mobile map
+	r.Handle("/projects/{id}/nodes/near", middleware.RequirePermission("project:read")(
+		http.HandlerFunc(projectHandler.GetNearbyNodes))).Methods("GET")
+	r.Handle("/zones/contains", middleware.RequirePermission("project:read")(
+		http.HandlerFunc(projectHandler.GetZonesContaining))).Methods("GET")
+
 	// Project Statistics
 	r.Handle("/projects/{id}/stats", middleware.RequirePermission("project:read")(
 		http.HandlerFunc(projectHandler.GetProjectStats))).Methods("GET")
+	r.Handle("/projects/{id}/progress", middleware.RequirePermission("project:read")(
+		http.HandlerFunc(projectHandler.GetProjectProgress))).Methods("GET")
 
 	// Phase 1 - WBS and planning controls
 	r.Handle("/projects/{id}/wbs-nodes", middleware.RequirePermission("project:wbs_manage")(
@@ -61,6 +92,10 @@ func RegisterProjectRoutes(r *mux.Router) {
 		http.HandlerFunc(phase1Handler.CreateTaskDependency))).Methods("POST")
 	r.Handle("/projects/{id}/task-dependencies", middleware.RequirePermission("task:dependency_read")(
 		http.HandlerFunc(phase1Handler.ListTaskDependencies))).Methods("GET")
+	r.Handle("/projects/{id}/schedule", middleware.RequirePermission("task:dependency_read")(
+		http.HandlerFunc(phase1Handler.GetProjectSchedule))).Methods("GET")
+	r.Handle("/projects/{id}/gantt", middleware.RequirePermission("task:read")(
+		http.HandlerFunc(phase1Handler.GetProjectGantt))).Methods("GET")
 
 	// Phase 1 - BOQ and measurement book
 	r.Handle("/projects/{id}/boq-items", middleware.RequirePermission("project:boq_manage")(
@@ -68,16 +103,45 @@ func RegisterProjectRoutes(r *mux.Router) {
 	r.Handle("/projects/{id}/boq-items", middleware.RequirePermission("project:boq_read")(
 		http.HandlerFunc(phase1Handler.ListBOQItems))).Methods("GET")
 
+	r.Handle("/projects/{id}/boq-items/{itemId}/material-norms", middleware.RequirePermission("project:boq_manage")(
+		http.HandlerFunc(phase1Handler.UpsertBOQMaterialNorm))).Methods("POST")
+	r.Handle("/projects/{id}/material-norms", middleware.RequirePermission("project:boq_read")(
+		http.HandlerFunc(phase1Handler.ListBOQMaterialNorms))).Methods("GET")
+	r.Handle("/projects/{id}/material-norms/{normId}", middleware.RequirePermission("project:boq_manage")(
+		http.HandlerFunc(phase1Handler.DeleteBOQMaterialNorm))).Methods("DELETE")
+	r.Handle("/projects/{id}/material-reconciliation", middleware.RequirePermission("project:boq_read")(
+		http.HandlerFunc(phase1Handler.GetMaterialReconciliation))).Methods("GET")
+
 	r.Handle("/projects/{id}/mb-entries", middleware.RequirePermission("project:mb_manage")(
 		http.HandlerFunc(phase1Handler.CreateMBEntry))).Methods("POST")
 	r.Handle("/projects/{id}/mb-entries", middleware.RequirePermission("project:mb_read")(
 		http.HandlerFunc(phase1Handler.ListMBEntries))).Methods("GET")
+	r.Handle("/projects/{id}/mb-entries/{entryId}", middleware.RequirePermission("project:mb_read")(
+		http.HandlerFunc(phase1Handler.GetMBEntry))).Methods("GET")
+	r.Handle("/projects/{id}/mb-entries/{entryId}", middleware.RequirePermission("project:mb_manage")(
+		http.HandlerFunc(phase1Handler.UpdateMBEntry))).Methods("PUT")
+	r.Handle("/projects/{id}/mb-entries/{entryId}/check", middleware.RequirePermission("project:mb_check")(
+		http.HandlerFunc(phase1Handler.CheckMBEntry))).Methods("POST")
+	r.Handle("/projects/{id}/mb-entries/{entryId}/approve", middleware.RequirePermission("project:mb_approve")(
+		http.HandlerFunc(phase1Handler.ApproveMBEntry))).Methods("POST")
+	r.Handle("/projects/{id}/mb-entries/{entryId}/reject", middleware.RequirePermission("project:mb_check")(
+		http.HandlerFunc(phase1Handler.RejectMBEntry))).Methods("POST")
+
+	// Daily progress reporting (DPR)
+	r.Handle("/projects/{id}/tasks/{taskId}/daily-progress", middleware.RequirePermission("task:update")(
+		http.HandlerFunc(phase1Handler.CreateTaskDailyProgress))).Methods("POST")
+	r.Handle("/projects/{id}/tasks/{taskId}/daily-progress", middleware.RequirePermission("task:read")(
+		http.HandlerFunc(phase1Handler.ListTaskDailyProgress))).Methods("GET")
+	r.Handle("/projects/{id}/dpr", middleware.RequirePermission("project:read")(
+		http.HandlerFunc(phase1Handler.GetProjectDPR))).Methods("GET")
 
 	// Phase 1 - Running account billing
 	r.Handle("/projects/{id}/ra-bills", middleware.RequirePermission("project:billing_manage")(
 		http.HandlerFunc(phase1Handler.CreateRABill))).Methods("POST")
 	r.Handle("/projects/{id}/ra-bills", middleware.RequirePermission("project:billing_read")(
 		http.HandlerFunc(phase1Handler.ListRABills))).Methods("GET")
+	r.Handle("/projects/{id}/ra-bills/generate", middleware.RequirePermission("project:billing_manage")(
+		http.HandlerFunc(phase1Handler.GenerateRABill))).Methods("POST")
 	r.Handle("/projects/{id}/ra-bills/{billId}", middleware.RequirePermission("project:billing_read")(
 		http.HandlerFunc(phase1Handler.GetRABill))).Methods("GET")
 	r.Handle("/projects/{id}/ra-bills/{billId}/lines", middleware.RequirePermission("project:billing_manage")(
@@ -100,6 +164,8 @@ func RegisterProjectRoutes(r *mux.Router) {
 		http.HandlerFunc(taskHandler.CreateTask))).Methods("POST")
 	r.Handle("/project-tasks", middleware.RequirePermission("task:read")(
 		http.HandlerFunc(taskHandler.ListTasks))).Methods("GET")
+	r.Handle("/assignments/workload", middleware.RequirePermission("project:assign")(
+		http.HandlerFunc(taskHandler.GetAssignmentWorkload))).Methods("GET")
 	r.Handle("/project-tasks/{id}", middleware.RequirePermission("task:read")(
 		http.HandlerFunc(taskHandler.GetTask))).Methods("GET")
 	r.Handle("/project-tasks/{id}", middleware.RequirePermission("task:update")(
@@ -118,10 +184,20 @@ func RegisterProjectRoutes(r *mux.Router) {
 		http.HandlerFunc(taskHandler.AddTaskComment))).Methods("POST")
 	r.Handle("/project-tasks/{id}/comments", middleware.RequirePermission("task:read")(
 		http.HandlerFunc(taskHandler.GetTaskComments))).Methods("GET")
+	r.Handle("/project-tasks/{id}/comments/{commentId}", middleware.RequirePermission("task:comment")(
+		http.HandlerFunc(taskHandler.UpdateTaskComment))).Methods("PUT")
+	r.Handle("/project-tasks/{id}/comments/{commentId}", middleware.RequirePermission("task:comment")(
+		http.HandlerFunc(taskHandler.DeleteTaskComment))).Methods("DELETE")
 	r.Handle("/project-tasks/{id}/attachments", middleware.RequirePermission("task:update")(
 		http.HandlerFunc(taskHandler.AddTaskAttachment))).Methods("POST")
 	r.Handle("/project-tasks/{id}/attachments", middleware.RequirePermission("task:read")(
 		http.HandlerFunc(taskHandler.GetTaskAttachments))).Methods("GET")
+	r.Handle("/project-tasks/{id}/attachments/{attachmentId}", middleware.RequirePermission("task:read")(
+		http.HandlerFunc(taskHandler.GetTaskAttachment))).Methods("GET")
+	r.Handle("/project-tasks/{id}/attachments/{attachmentId}", middleware.RequirePermission("task:update")(
+		http.HandlerFunc(taskHandler.UpdateTaskAttachment))).Methods("PUT")
+	r.Handle("/project-tasks/{id}/attachments/{attachmentId}", middleware.RequirePermission("task:update")(
+		http.HandlerFunc(taskHandler.DeleteTaskAttachment))).Methods("DELETE")
 
 	// Task Audit Log
 	r.Handle("/project-tasks/{id}/audit", middleware.RequirePermission("task:read")(
@@ -169,6 +245,14 @@ func RegisterProjectRoutes(r *mux.Router) {
 	r.Handle("/api/v1/budget/tasks/{id}/summary", middleware.RequirePermission("budget:view")(
 		http.HandlerFunc(budgetHandler.GetTaskBudgetSummary))).Methods("GET")
 
+	// Budget thresholds and alerts
+	r.Handle("/api/v1/budget/projects/{id}/thresholds", middleware.RequirePermission("budget:view")(
+		http.HandlerFunc(budgetHandler.GetBudgetThresholds))).Methods("GET")
+	r.Handle("/api/v1/budget/projects/{id}/thresholds", middleware.RequirePermission("budget:manage")(
+		http.HandlerFunc(budgetHandler.SetBudgetThresholds))).Methods("PUT")
+	r.Handle("/api/v1/budget/projects/{id}/over-budget", middleware.RequirePermission("budget:view")(
+		http.HandlerFunc(budgetHandler.GetOverBudget))).Methods("GET")
+
 	// =====================================================
 	// Project Roles & Permissions Routes
 	// =====================================================
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/handlers"
+	"p9e.in/ugcl/middleware"
+)
+
+// registerReminderRoutes registers business-scoped reminders on tasks, approvals and
+// maintenance tickets. Who may remind whom is checked in the handlers.
+func registerReminderRoutes(business *mux.Router) {
+	businessAccess := middleware.RequireBusinessAccess()
+	group := business.PathPrefix("/reminders").Subrouter()
+
+	group.Handle("", businessAccess(http.HandlerFunc(handlers.CreateReminder))).Methods(http.MethodPost)
+	// ?entity_type=&entity_id=&status=&user_id=
+	group.Handle("", businessAccess(http.HandlerFunc(handlers.ListReminders))).Methods(http.MethodGet)
+	group.Handle("/{id}/cancel", businessAccess(http.HandlerFunc(handlers.CancelReminder))).Methods(http.MethodPost)
+}
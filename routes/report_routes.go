@@ -15,6 +15,8 @@ func RegisterReportRoutes(r *mux.Router) {
 	// Report Builder API v1 - Protected routes
 	api := r.PathPrefix("/api/v1").Subrouter()
 	api.Use(middleware.JWTMiddleware)
+	api.Use(middleware.ReadOnlyRoleMiddleware)
+	api.Use(middleware.DataScopeMiddleware)
 
 	// Report read/write subrouters with permission guards
 	reportRead := api.PathPrefix("").Subrouter()
@@ -8,7 +8,6 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
-	"p9e.in/ugcl/config"
 	_ "p9e.in/ugcl/docs"
 	"p9e.in/ugcl/handlers"
 	kpi_handlers "p9e.in/ugcl/handlers/kpis"
@@ -28,6 +27,9 @@ func RegisterRoutes() http.Handler {
 	// =====================================================
 	r.HandleFunc("/api/v1/register", handlers.Register).Methods("POST")
 	r.Handle("/api/v1/login", middleware.LoginRateLimit(http.HandlerFunc(handlers.Login))).Methods("POST")
+	r.Handle("/api/v1/login/otp", middleware.LoginRateLimit(http.HandlerFunc(handlers.RequestLoginOTP))).Methods("POST")
+	r.Handle("/api/v1/login/otp/verify", middleware.LoginRateLimit(http.HandlerFunc(handlers.VerifyLoginOTP))).Methods("POST")
+	r.HandleFunc("/api/v1/sms/callbacks/{provider}", handlers.HandleSMSCallback).Methods("POST")
 	r.PathPrefix("/uploads/").Handler(
 		http.StripPrefix("/uploads/", http.FileServer(http.Dir("./uploads"))),
 	)
@@ -38,12 +40,19 @@ func RegisterRoutes() http.Handler {
 	api := r.PathPrefix("/api/v1").Subrouter()
 	api.Use(middleware.SecurityMiddleware)
 	api.Use(middleware.JWTMiddleware)
+	api.Use(middleware.UsageMeteringMiddleware)
+	api.Use(middleware.ReadOnlyRoleMiddleware)
+	api.Use(middleware.DataScopeMiddleware)
 
 	// User profile endpoint
 	api.HandleFunc("/profile", handleProfile).Methods("GET")
 	api.HandleFunc("/profile/logins", handleProfileLogins).Methods("GET")
 	api.HandleFunc("/profile", handleUpdateProfile).Methods("PUT")
 	api.HandleFunc("/token", handlers.GetCurrentUser).Methods("GET")
+	api.HandleFunc("/me/permissions", handlers.GetMyPermissions).Methods("GET")
+	api.HandleFunc("/permissions", handlers.GetPermissionCatalog).Methods("GET")
+	api.Handle("/roles/{id}/history", middleware.RequireAnyPermission([]string{"role:read", "manage_roles"})(
+		http.HandlerFunc(handlers.GetRoleHistory))).Methods("GET")
 	api.HandleFunc("/context/business", handlers.GetActiveBusinessContext).Methods("GET")
 	api.HandleFunc("/context/business", handlers.SetActiveBusinessContext).Methods("PUT")
 
@@ -79,6 +88,21 @@ func RegisterRoutes() http.Handler {
 	RegisterWebhookMuxRoutes(r)
 	RegisterIntegrationRoutes(r)
 	RegisterAdminIntegrationRoutes(admin)
+	RegisterBillingRoutes(admin)
+	RegisterPortfolioRoutes(api, admin)
+	RegisterAuditRoutes(api)
+	RegisterTelemetryRoutes(api)
+	RegisterSensorRoutes(r)
+	RegisterSolarRoutes(api)
+	RegisterEmergencyRoutes(api)
+	RegisterAnnouncementRoutes(api)
+	RegisterBreakGlassRoutes(api)
+	RegisterWorkflowRoutes(api)
+	RegisterApprovalDelegationRoutes(api)
+	RegisterFormRecordRoutes(api)
+	RegisterFormFileRoutes(r, api)
+	RegisterFormDraftRoutes(api)
+	RegisterFormSyncRoutes(api)
 
 	return r
 }
@@ -184,7 +208,7 @@ func handleProfileLogins(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var loginEvents []models.UserLoginEvent
-	if err := config.DB.
+	if err := middleware.ScopedDB(r).
 		Where("user_id = ?", userID).
 		Order("login_at DESC").
 		Limit(limit).
@@ -255,7 +279,7 @@ func handleUpdateProfile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var user models.User
-	if err := config.DB.First(&user, "id = ?", userID).Error; err != nil {
+	if err := middleware.ScopedDB(r).First(&user, "id = ?", userID).Error; err != nil {
 		http.Error(w, "user not found", http.StatusNotFound)
 		return
 	}
@@ -264,7 +288,7 @@ func handleUpdateProfile(w http.ResponseWriter, r *http.Request) {
 	user.Email = req.Email
 	user.Phone = req.Phone
 
-	if err := config.DB.Save(&user).Error; err != nil {
+	if err := middleware.ScopedDB(r).Save(&user).Error; err != nil {
 		if utils.IsUniqueViolation(err) {
 			http.Error(w, "email or phone already in use", http.StatusConflict)
 			return
@@ -553,6 +577,50 @@ func registerAdminRoutes(admin *mux.Router) {
 		http.HandlerFunc(handlers.GetAllPermissions))).Methods("GET")
 	admin.Handle("/permissions", middleware.RequirePermission("manage_roles")(
 		http.HandlerFunc(handlers.CreatePermission))).Methods("POST")
+
+	// Global separation-of-duties rules and the override audit trail across verticals
+	admin.Handle("/sod-rules", middleware.RequirePermission("manage_roles")(
+		http.HandlerFunc(handlers.ListSoDRules))).Methods("GET")
+	admin.Handle("/sod-rules", middleware.RequirePermission("manage_roles")(
+		http.HandlerFunc(handlers.CreateSoDRule))).Methods("POST")
+	admin.Handle("/sod-rules/{id}", middleware.RequirePermission("manage_roles")(
+		http.HandlerFunc(handlers.UpdateSoDRule))).Methods("PUT")
+	admin.Handle("/sod-rules/{id}", middleware.RequirePermission("manage_roles")(
+		http.HandlerFunc(handlers.DeleteSoDRule))).Methods("DELETE")
+	admin.Handle("/sod-overrides", middleware.RequirePermission("manage_roles")(
+		http.HandlerFunc(handlers.ListSoDOverrides))).Methods("GET")
+
+	// Deny entries on global roles, business roles and users (deny-overrides)
+	admin.Handle("/permission-denies", middleware.RequirePermission("manage_roles")(
+		http.HandlerFunc(handlers.ListPermissionDenies))).Methods("GET")
+	admin.Handle("/permission-denies", middleware.RequirePermission("manage_roles")(
+		http.HandlerFunc(handlers.CreatePermissionDeny))).Methods("POST")
+	admin.Handle("/permission-denies/{id}", middleware.RequirePermission("manage_roles")(
+		http.HandlerFunc(handlers.DeletePermissionDeny))).Methods("DELETE")
+
+	// Companies (legal entities sharing the deployment)
+	admin.Handle("/companies", middleware.RequireSuperAdmin()(
+		http.HandlerFunc(handlers.ListCompanies))).Methods("GET")
+	admin.Handle("/companies", middleware.RequireSuperAdmin()(
+		http.HandlerFunc(handlers.CreateCompany))).Methods("POST")
+	admin.Handle("/companies/{id}", middleware.RequireSuperAdmin()(
+		http.HandlerFunc(handlers.UpdateCompany))).Methods("PUT")
+	admin.Handle("/companies/{id}/users", middleware.RequireSuperAdmin()(
+		http.HandlerFunc(handlers.AssignUsersToCompany))).Methods("POST")
+
+	// Break-glass grants: review with full audit trail and revocation
+	admin.Handle("/break-glass", middleware.RequireSuperAdmin()(
+		http.HandlerFunc(handlers.ListBreakGlassGrants))).Methods("GET")
+	admin.Handle("/break-glass/{id}", middleware.RequireSuperAdmin()(
+		http.HandlerFunc(handlers.GetBreakGlassGrant))).Methods("GET")
+	admin.Handle("/break-glass/{id}/revoke", middleware.RequireSuperAdmin()(
+		http.HandlerFunc(handlers.EndBreakGlassGrant))).Methods("POST")
+
+	// Permission cache metrics and manual invalidation
+	admin.Handle("/auth/cache", middleware.RequirePermission("manage_roles")(
+		http.HandlerFunc(handlers.GetAuthCacheStats))).Methods("GET")
+	admin.Handle("/auth/cache", middleware.RequirePermission("manage_roles")(
+		http.HandlerFunc(handlers.InvalidateAuthCache))).Methods("DELETE")
 }
 
 // registerPartnerRoutes registers partner API routes (read-only)
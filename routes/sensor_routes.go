@@ -0,0 +1,47 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/handlers"
+	"p9e.in/ugcl/middleware"
+)
+
+// RegisterSensorRoutes registers the public ingestion endpoints for field devices
+// (rain gauges, level sensors, meters, pumps). Devices authenticate with their own token rather
+// than a JWT or API key, so these routes sit outside the /api/v1 security middleware.
+func RegisterSensorRoutes(r *mux.Router) {
+	sensorHandler := handlers.NewSensorDeviceHandler()
+
+	// Self-registration into the site engineers' approval queue
+	r.HandleFunc("/api/v1/sensors/register", sensorHandler.RegisterSensorDevice).Methods(http.MethodPost)
+
+	// Batch of readings from an approved device (Authorization: Bearer <device token>)
+	r.HandleFunc("/api/v1/sensors/readings", sensorHandler.IngestSensorReadings).Methods(http.MethodPost)
+
+	// Liveness check-in from an approved device, with the same token
+	r.HandleFunc("/api/v1/sensors/heartbeat", sensorHandler.SendSensorHeartbeat).Methods(http.MethodPost)
+}
+
+// registerSensorDeviceRoutes registers the business-scoped sensor approval queue and readings
+func registerSensorDeviceRoutes(business *mux.Router) {
+	sensorHandler := handlers.NewSensorDeviceHandler()
+	sensors := business.PathPrefix("/sensors/devices").Subrouter()
+
+	// Devices (?status=pending for the approval queue)
+	sensors.Handle("", middleware.RequireBusinessPermission("sensor:device:manage")(
+		http.HandlerFunc(sensorHandler.ListSensorDevices))).Methods(http.MethodGet)
+	sensors.Handle("/{deviceId}/approve", middleware.RequireBusinessPermission("sensor:device:manage")(
+		http.HandlerFunc(sensorHandler.ApproveSensorDevice))).Methods(http.MethodPost)
+	sensors.Handle("/{deviceId}/reject", middleware.RequireBusinessPermission("sensor:device:manage")(
+		http.HandlerFunc(sensorHandler.RejectSensorDevice))).Methods(http.MethodPost)
+	sensors.Handle("/{deviceId}/disable", middleware.RequireBusinessPermission("sensor:device:manage")(
+		http.HandlerFunc(sensorHandler.DisableSensorDevice))).Methods(http.MethodPost)
+	sensors.Handle("/{deviceId}/rotate-token", middleware.RequireBusinessPermission("sensor:device:manage")(
+		http.HandlerFunc(sensorHandler.RotateSensorDeviceToken))).Methods(http.MethodPost)
+
+	// Stored readings (?from=&to=&metric=&limit=)
+	sensors.Handle("/{deviceId}/readings", middleware.RequireBusinessPermission("sensor:reading:read")(
+		http.HandlerFunc(sensorHandler.ListSensorReadings))).Methods(http.MethodGet)
+}
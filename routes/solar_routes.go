@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/handlers"
+)
+
+// RegisterSolarRoutes registers solar generation ingestion. The site decides the business,
+// so solar:write_generation is checked in the handler against the site's vertical.
+func RegisterSolarRoutes(api *mux.Router) {
+	// One inverter reading, or a batch as {"readings": [...]}
+	api.HandleFunc("/solar/sites/{siteId}/generation", handlers.IngestSolarGeneration).Methods("POST")
+}
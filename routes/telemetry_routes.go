@@ -0,0 +1,25 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/handlers"
+	"p9e.in/ugcl/middleware"
+)
+
+// RegisterTelemetryRoutes registers mobile app feature telemetry ingestion and reporting
+func RegisterTelemetryRoutes(api *mux.Router) {
+	telemetryHandler := handlers.NewAppTelemetryHandler()
+
+	// Opt-in batch of feature-usage events and crash breadcrumbs from the mobile app
+	api.HandleFunc("/telemetry/events", telemetryHandler.IngestAppTelemetry).Methods("POST")
+
+	// Usage by module (?from=&to=&business_vertical_id=&platform=&app_version=&limit=)
+	api.Handle("/telemetry/summary", middleware.RequirePermission("telemetry:read")(
+		http.HandlerFunc(telemetryHandler.GetAppTelemetrySummary))).Methods("GET")
+
+	// Recent crashes with their preceding breadcrumbs (?module=&limit=)
+	api.Handle("/telemetry/crashes", middleware.RequirePermission("telemetry:read")(
+		http.HandlerFunc(telemetryHandler.ListAppCrashes))).Methods("GET")
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"p9e.in/ugcl/handlers"
+)
+
+// RegisterWorkflowRoutes registers the workflow runtime endpoints. Instances are records
+// in form tables; each action checks the transition's own required permission, so the
+// routes only need an authenticated user.
+func RegisterWorkflowRoutes(api *mux.Router) {
+	api.HandleFunc("/workflow/inbox", handlers.GetWorkflowInbox).Methods(http.MethodGet)
+	api.HandleFunc("/workflow/instances/{id}", handlers.GetWorkflowInstance).Methods(http.MethodGet)
+	api.HandleFunc("/workflow/instances/{id}/history", handlers.GetWorkflowInstanceHistory).Methods(http.MethodGet)
+	api.HandleFunc("/workflow/instances/{id}/actions/{action}", handlers.ExecuteWorkflowAction).Methods(http.MethodPost)
+}
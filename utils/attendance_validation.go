@@ -174,18 +174,7 @@ func ValidateAttendanceInput(input AttendanceValidationInput) (*AttendanceValida
 		result.AnomalyFlags = append(result.AnomalyFlags, AttendanceAnomalyOutsideBoundary)
 	}
 
-	if input.AccuracyMeters > policy.MaxAccuracyMeters {
-		result.AnomalyFlags = append(result.AnomalyFlags, AttendanceAnomalyPoorAccuracy)
-	}
-	if input.Integrity.IsMockLocation {
-		result.AnomalyFlags = append(result.AnomalyFlags, AttendanceAnomalyMockLocation)
-	}
-	if !input.Integrity.IsGPSEnabled {
-		result.AnomalyFlags = append(result.AnomalyFlags, AttendanceAnomalyGpsDisabled)
-	}
-	if input.Integrity.ClockSkewSeconds != 0 && absInt(input.Integrity.ClockSkewSeconds) > policy.MaxClockSkewSeconds {
-		result.AnomalyFlags = append(result.AnomalyFlags, AttendanceAnomalyClockSkew)
-	}
+	result.AnomalyFlags = append(result.AnomalyFlags, deviceAnomalies(input.AccuracyMeters, input.Integrity, policy)...)
 
 	if input.LastAcceptedPing != nil && policy.MaxPingInterval > 0 {
 		seconds := input.CapturedAt.Sub(*input.LastAcceptedPing).Seconds()
@@ -227,6 +216,66 @@ func ValidateAttendanceInput(input AttendanceValidationInput) (*AttendanceValida
 	return result, nil
 }
 
+// TaskAttendanceInput is a check-in or check-out against a task, with the distance from
+// the task's zone already measured
+type TaskAttendanceInput struct {
+	DistanceMeters  float64 // from the zone boundary; 0 inside it
+	ToleranceMeters float64 // how far outside the zone still counts as inside
+	Method          string  // how the distance was measured
+	AccuracyMeters  float64
+	Integrity       DeviceIntegritySnapshot
+	Policy          AttendanceValidationPolicy
+}
+
+// ValidateTaskAttendance validates a task check-in or check-out. Unlike site attendance,
+// being outside the task's zone plus tolerance is always rejected; other anomalies are
+// flagged, or rejected under strict enforcement.
+func ValidateTaskAttendance(input TaskAttendanceInput) *AttendanceValidationResult {
+	policy := NormalizeAttendancePolicy(input.Policy)
+	distance := input.DistanceMeters
+	result := &AttendanceValidationResult{
+		InsideBoundary:    distance <= input.ToleranceMeters,
+		DistanceFromSiteM: &distance,
+		ValidationStatus:  models.AttendanceValidationAccepted,
+		ValidationMethod:  input.Method,
+		NormalizedPolicy:  policy,
+	}
+	if !result.InsideBoundary {
+		result.AnomalyFlags = append(result.AnomalyFlags, AttendanceAnomalyOutsideBoundary)
+	}
+	result.AnomalyFlags = append(result.AnomalyFlags, deviceAnomalies(input.AccuracyMeters, input.Integrity, policy)...)
+
+	switch {
+	case !result.InsideBoundary || (policy.StrictEnforcement && containsCriticalAnomaly(result.AnomalyFlags)):
+		result.ValidationStatus = models.AttendanceValidationRejected
+		result.ValidationReason = buildValidationReason(result.AnomalyFlags)
+	case len(result.AnomalyFlags) > 0:
+		result.ValidationStatus = models.AttendanceValidationFlagged
+		result.ValidationReason = buildValidationReason(result.AnomalyFlags)
+	default:
+		result.ValidationReason = "validated"
+	}
+	return result
+}
+
+// deviceAnomalies flags a location sample the device itself makes untrustworthy
+func deviceAnomalies(accuracyMeters float64, integrity DeviceIntegritySnapshot, policy AttendanceValidationPolicy) []string {
+	var flags []string
+	if accuracyMeters > policy.MaxAccuracyMeters {
+		flags = append(flags, AttendanceAnomalyPoorAccuracy)
+	}
+	if integrity.IsMockLocation {
+		flags = append(flags, AttendanceAnomalyMockLocation)
+	}
+	if !integrity.IsGPSEnabled {
+		flags = append(flags, AttendanceAnomalyGpsDisabled)
+	}
+	if integrity.ClockSkewSeconds != 0 && absInt(integrity.ClockSkewSeconds) > policy.MaxClockSkewSeconds {
+		flags = append(flags, AttendanceAnomalyClockSkew)
+	}
+	return flags
+}
+
 func ParseGeofenceValue(geofenceJSON *string) (*Geofence, error) {
 	if geofenceJSON == nil {
 		return nil, nil
@@ -189,3 +189,37 @@ func TestValidateAttendanceInputRejectsImpossibleSpeedWhenStrict(t *testing.T) {
 		t.Fatalf("expected rejected status, got %s", result.ValidationStatus)
 	}
 }
+
+func TestValidateTaskAttendance(t *testing.T) {
+	inside := ValidateTaskAttendance(TaskAttendanceInput{
+		DistanceMeters:  30,
+		ToleranceMeters: 50,
+		Method:          "task_zone",
+		AccuracyMeters:  10,
+		Integrity:       DeviceIntegritySnapshot{IsGPSEnabled: true},
+	})
+	if !inside.InsideBoundary || inside.ValidationStatus != models.AttendanceValidationAccepted {
+		t.Fatalf("expected a point within tolerance to be accepted, got %+v", inside)
+	}
+
+	outside := ValidateTaskAttendance(TaskAttendanceInput{
+		DistanceMeters:  80,
+		ToleranceMeters: 50,
+		Method:          "task_zone",
+		AccuracyMeters:  10,
+		Integrity:       DeviceIntegritySnapshot{IsGPSEnabled: true},
+	})
+	if outside.InsideBoundary || outside.ValidationStatus != models.AttendanceValidationRejected {
+		t.Fatalf("expected a point outside the zone to be rejected without strict enforcement, got %+v", outside)
+	}
+
+	mocked := ValidateTaskAttendance(TaskAttendanceInput{
+		ToleranceMeters: 50,
+		Method:          "task_zone",
+		AccuracyMeters:  10,
+		Integrity:       DeviceIntegritySnapshot{IsGPSEnabled: true, IsMockLocation: true},
+	})
+	if mocked.ValidationStatus != models.AttendanceValidationFlagged {
+		t.Fatalf("expected a mock location to be flagged, got %s", mocked.ValidationStatus)
+	}
+}